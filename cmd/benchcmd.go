@@ -0,0 +1,111 @@
+package main
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"flag"
+	"fmt"
+	"io"
+	"os"
+	"os/signal"
+
+	"github.com/cexll/swe/internal/bench"
+	"github.com/cexll/swe/internal/config"
+	"github.com/cexll/swe/internal/provider"
+)
+
+const benchUsage = `usage: swe-agent bench -suite suite.json -targets claude,codex:gpt-5-codex [-repeat n] [-o report.json]
+
+Runs every case of the suite on each target (provider or provider:model) in
+a fresh clone of the suite's sample repository, and prints success rate,
+duration, cost and diff metrics per target.`
+
+// allow tests to stub the benchmark runs
+var runBench = func(ctx context.Context, r *bench.Runner) (*bench.Report, error) { return r.Run(ctx) }
+
+// runBenchCmd implements `swe-agent bench`.
+func runBenchCmd(args []string, stdout, stderr io.Writer) int {
+	fs := flag.NewFlagSet("bench", flag.ContinueOnError)
+	fs.SetOutput(stderr)
+	fs.Usage = func() {
+		_, _ = fmt.Fprintln(stderr, benchUsage)
+		fs.PrintDefaults()
+	}
+	suitePath := fs.String("suite", "", "benchmark suite (JSON)")
+	targetList := fs.String("targets", "", "comma-separated provider[:model] targets (default: PROVIDER env)")
+	repeat := fs.Int("repeat", 1, "runs of each case per target")
+	output := fs.String("o", "", "also write the full report as JSON to this file")
+	if err := fs.Parse(args); err != nil {
+		if errors.Is(err, flag.ErrHelp) {
+			return 0
+		}
+		return 2
+	}
+	if *suitePath == "" || fs.NArg() > 0 || *repeat < 1 {
+		fs.Usage()
+		return 2
+	}
+	suite, err := bench.LoadSuite(*suitePath)
+	if err != nil {
+		_, _ = fmt.Fprintf(stderr, "bench: %v\n", err)
+		return 2
+	}
+
+	if envFile := os.Getenv("ENV_FILE"); envFile != "" {
+		_ = loadDotEnv(envFile)
+	} else {
+		_ = loadDotEnv()
+	}
+	if *targetList == "" {
+		*targetList = os.Getenv("PROVIDER")
+	}
+	targets, err := bench.ParseTargets(*targetList)
+	if err != nil {
+		_, _ = fmt.Fprintf(stderr, "bench: %v\n", err)
+		return 2
+	}
+	_ = os.Setenv("PROVIDER", targets[0].Provider)
+	cfg, err := config.LoadProvider()
+	if err != nil {
+		_, _ = fmt.Fprintf(stderr, "bench: %v\n", err)
+		return 1
+	}
+
+	ctx, stop := signal.NotifyContext(context.Background(), os.Interrupt)
+	defer stop()
+	report, err := runBench(ctx, &bench.Runner{
+		Suite:   suite,
+		Targets: targets,
+		Repeat:  *repeat,
+		NewProvider: func(t bench.Target) (provider.Provider, error) {
+			c := *cfg
+			c.Provider = t.Provider
+			if t.Model != "" {
+				c.ClaudeModel, c.CodexModel = t.Model, t.Model
+			}
+			return newLocalProvider(&c)
+		},
+		Progress: stderr,
+	})
+	if report == nil {
+		_, _ = fmt.Fprintf(stderr, "bench: %v\n", err)
+		return 1
+	}
+	_ = report.WriteMarkdown(stdout)
+	if *output != "" {
+		data, jerr := json.MarshalIndent(report, "", "  ")
+		if jerr == nil {
+			jerr = os.WriteFile(*output, append(data, '\n'), 0o644)
+		}
+		if jerr != nil {
+			_, _ = fmt.Fprintf(stderr, "bench: write report: %v\n", jerr)
+			return 1
+		}
+	}
+	if err != nil {
+		_, _ = fmt.Fprintf(stderr, "bench: %v\n", err)
+		return 1
+	}
+	return 0
+}
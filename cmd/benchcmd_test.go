@@ -0,0 +1,59 @@
+package main
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"github.com/cexll/swe/internal/bench"
+)
+
+func TestRunBench(t *testing.T) {
+	t.Setenv("ANTHROPIC_API_KEY", "sk-test")
+	t.Setenv("OPENAI_API_KEY", "")
+	used := stubLocalRun(t, nil)
+	origRun := runBench
+	t.Cleanup(func() { runBench = origRun })
+	var got []string
+	runBench = func(_ context.Context, r *bench.Runner) (*bench.Report, error) {
+		for _, target := range r.Targets {
+			p, err := r.NewProvider(target)
+			if err != nil {
+				return nil, err
+			}
+			got = append(got, p.Name()+"/"+used.ClaudeModel+"/"+used.CodexModel)
+		}
+		return &bench.Report{Suite: r.Suite.Name, Targets: []bench.Summary{{Target: "claude", Runs: 2, Successes: 1}}}, nil
+	}
+
+	dir := t.TempDir()
+	suite := filepath.Join(dir, "suite.json")
+	_ = os.WriteFile(suite, []byte(`{"repo":"sample","cases":[{"name":"a","prompt":"p"}]}`), 0o644)
+	out := filepath.Join(dir, "report.json")
+	var stdout, stderr bytes.Buffer
+	code := runBenchCmd([]string{"-suite", suite, "-targets", "claude,codex:gpt-x", "-o", out}, &stdout, &stderr)
+	if code != 0 {
+		t.Fatalf("exit code = %d, stderr = %s", code, stderr.String())
+	}
+	if len(got) != 2 || !strings.HasPrefix(got[0], "claude/") || got[1] != "codex/gpt-x/gpt-x" {
+		t.Fatalf("providers = %q", got)
+	}
+	if !strings.Contains(stdout.String(), "| claude | 1/2 (50%) |") {
+		t.Fatalf("stdout = %s", stdout.String())
+	}
+	var report bench.Report
+	if data, err := os.ReadFile(out); err != nil || json.Unmarshal(data, &report) != nil || report.Suite != "suite" {
+		t.Fatalf("report = %+v, %v", report, err)
+	}
+
+	for _, args := range [][]string{{}, {"-suite", suite, "-targets", "gemini"}, {"-suite", filepath.Join(dir, "missing.json")}} {
+		stderr.Reset()
+		if code := runBenchCmd(args, &stdout, &stderr); code != 2 {
+			t.Errorf("%q: exit code = %d", args, code)
+		}
+	}
+}
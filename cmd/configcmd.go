@@ -0,0 +1,88 @@
+package main
+
+import (
+	"errors"
+	"flag"
+	"fmt"
+	"io"
+	"os"
+	"strings"
+
+	"github.com/cexll/swe/internal/config"
+)
+
+const configUsage = "usage: swe-agent config validate [-config path]"
+
+// runConfig implements `swe-agent config validate`: it loads the
+// configuration exactly as the server would and reports every problem.
+func runConfig(args []string, stdout, stderr io.Writer) int {
+	if len(args) == 0 || args[0] != "validate" {
+		_, _ = fmt.Fprintln(stderr, configUsage)
+		return 2
+	}
+
+	fs := flag.NewFlagSet("config validate", flag.ContinueOnError)
+	fs.SetOutput(stderr)
+	path := fs.String("config", os.Getenv("CONFIG_FILE"), "YAML configuration file (default: CONFIG_FILE env)")
+	if err := fs.Parse(args[1:]); err != nil {
+		if errors.Is(err, flag.ErrHelp) {
+			return 0
+		}
+		return 2
+	}
+	if fs.NArg() > 0 {
+		_, _ = fmt.Fprintf(stderr, "config validate: unexpected arguments: %s\n", strings.Join(fs.Args(), " "))
+		return 2
+	}
+
+	if envFile := os.Getenv("ENV_FILE"); envFile != "" {
+		_ = loadDotEnv(envFile)
+	} else {
+		_ = loadDotEnv()
+	}
+	_ = os.Setenv("CONFIG_FILE", *path)
+
+	cfg, err := config.Load()
+	if err != nil {
+		var verr *config.ValidationError
+		if errors.As(err, &verr) {
+			_, _ = fmt.Fprintf(stderr, "configuration is invalid (%d problems):\n", len(verr.Problems))
+			for _, p := range verr.Problems {
+				_, _ = fmt.Fprintf(stderr, "  - %s\n", p)
+			}
+		} else {
+			_, _ = fmt.Fprintf(stderr, "configuration is invalid: %v\n", err)
+		}
+		return 1
+	}
+
+	source := "environment"
+	if *path != "" {
+		source = *path + " + environment"
+	}
+	_, _ = fmt.Fprintf(stdout, "configuration OK (%s)\n", source)
+	_, _ = fmt.Fprintf(stdout, "  provider: %s\n  port: %d\n  trigger: %s\n  dispatcher: %d workers, queue %d\n",
+		cfg.Provider, cfg.Port, cfg.TriggerKeyword, cfg.DispatcherWorkers, cfg.DispatcherQueueSize)
+	return 0
+}
+
+// extractConfigFlag consumes a leading -config/--config flag, which applies
+// to every subcommand, and returns the remaining arguments.
+func extractConfigFlag(args []string) (path string, rest []string, err error) {
+	if len(args) == 0 {
+		return "", args, nil
+	}
+	name := strings.TrimLeft(args[0], "-")
+	switch {
+	case args[0] == name:
+		return "", args, nil
+	case name == "config":
+		if len(args) < 2 {
+			return "", nil, errors.New("flag needs an argument: -config")
+		}
+		return args[1], args[2:], nil
+	case strings.HasPrefix(name, "config="):
+		return strings.TrimPrefix(name, "config="), args[1:], nil
+	}
+	return "", args, nil
+}
@@ -0,0 +1,88 @@
+package main
+
+import (
+	"bytes"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+)
+
+func stubConfigEnv(t *testing.T) {
+	t.Helper()
+	orig := loadDotEnv
+	t.Cleanup(func() { loadDotEnv = orig })
+	loadDotEnv = func(...string) error { return nil }
+	for _, env := range []string{"CONFIG_FILE", "GITHUB_APP_ID", "GITHUB_PRIVATE_KEY", "GITHUB_WEBHOOK_SECRET", "PROVIDER", "ANTHROPIC_API_KEY", "PORT"} {
+		t.Setenv(env, "")
+	}
+}
+
+func TestRunConfigValidate_OK(t *testing.T) {
+	stubConfigEnv(t)
+	path := filepath.Join(t.TempDir(), "config.yaml")
+	content := "port: 9100\ngithub:\n  app_id: \"1\"\n  private_key: key\n  webhook_secret: secret\nclaude:\n  api_key: sk-test\n"
+	if err := os.WriteFile(path, []byte(content), 0o600); err != nil {
+		t.Fatal(err)
+	}
+
+	var stdout, stderr bytes.Buffer
+	if code := runConfig([]string{"validate", "-config", path}, &stdout, &stderr); code != 0 {
+		t.Fatalf("exit code = %d, stderr = %s", code, stderr.String())
+	}
+	out := stdout.String()
+	if !strings.Contains(out, "configuration OK ("+path+" + environment)") || !strings.Contains(out, "port: 9100") {
+		t.Fatalf("unexpected output: %s", out)
+	}
+}
+
+func TestRunConfigValidate_ListsProblems(t *testing.T) {
+	stubConfigEnv(t)
+	t.Setenv("PROVIDER", "gemini")
+
+	var stdout, stderr bytes.Buffer
+	if code := runConfig([]string{"validate"}, &stdout, &stderr); code != 1 {
+		t.Fatalf("exit code = %d, want 1", code)
+	}
+	out := stderr.String()
+	for _, want := range []string{"configuration is invalid (4 problems):", "  - GITHUB_APP_ID is required", "  - invalid provider: gemini"} {
+		if !strings.Contains(out, want) {
+			t.Errorf("stderr missing %q:\n%s", want, out)
+		}
+	}
+}
+
+func TestRunConfig_Usage(t *testing.T) {
+	var stdout, stderr bytes.Buffer
+	if code := runConfig(nil, &stdout, &stderr); code != 2 || !strings.Contains(stderr.String(), configUsage) {
+		t.Fatalf("code = %d, stderr = %q", code, stderr.String())
+	}
+	if code := runConfig([]string{"validate", "extra"}, &stdout, &stderr); code != 2 {
+		t.Fatalf("code = %d, want 2 for unexpected arguments", code)
+	}
+}
+
+func TestExtractConfigFlag(t *testing.T) {
+	tests := []struct {
+		args     []string
+		wantPath string
+		wantRest []string
+		wantErr  bool
+	}{
+		{args: nil},
+		{args: []string{"run", "-config", "x"}, wantRest: []string{"run", "-config", "x"}},
+		{args: []string{"--config", "swe.yaml", "config", "validate"}, wantPath: "swe.yaml", wantRest: []string{"config", "validate"}},
+		{args: []string{"-config=swe.yaml"}, wantPath: "swe.yaml", wantRest: []string{}},
+		{args: []string{"--config"}, wantErr: true},
+		{args: []string{"-verbose"}, wantRest: []string{"-verbose"}},
+	}
+	for _, tt := range tests {
+		path, rest, err := extractConfigFlag(tt.args)
+		if (err != nil) != tt.wantErr {
+			t.Fatalf("%v: err = %v", tt.args, err)
+		}
+		if path != tt.wantPath || strings.Join(rest, " ") != strings.Join(tt.wantRest, " ") {
+			t.Errorf("%v: got (%q, %v), want (%q, %v)", tt.args, path, rest, tt.wantPath, tt.wantRest)
+		}
+	}
+}
@@ -0,0 +1,81 @@
+package main
+
+import (
+	"context"
+	"encoding/json"
+	"log/slog"
+	"net/http"
+	"os"
+	"os/signal"
+	"sync"
+	"syscall"
+
+	"github.com/cexll/swe/internal/logging"
+	"github.com/cexll/swe/internal/webhook"
+)
+
+// drainer takes the server out of rotation for a rollout. Draining starts on
+// SIGTERM, SIGINT or POST /admin/drain: the server stops accepting
+// connections, lets webhook requests in flight finish and waits for queued
+// and running tasks before exiting, so no delivery is dropped and no run is
+// killed mid-push.
+type drainer struct {
+	apiToken string
+	once     sync.Once
+	started  chan struct{}
+}
+
+func newDrainer(apiToken string) *drainer {
+	return &drainer{apiToken: apiToken, started: make(chan struct{})}
+}
+
+// Start begins draining; later calls do nothing.
+func (d *drainer) Start(reason string) {
+	d.once.Do(func() {
+		slog.Info("Draining: no longer accepting connections", logging.KeyPhase, "drain", "reason", reason)
+		close(d.started)
+	})
+}
+
+// Done is closed once draining has started.
+func (d *drainer) Done() <-chan struct{} { return d.started }
+
+// Draining reports whether draining has started.
+func (d *drainer) Draining() bool {
+	select {
+	case <-d.started:
+		return true
+	default:
+		return false
+	}
+}
+
+// watchSignals starts draining on the first SIGTERM or SIGINT. The signals
+// are then handled by default again, so a second one exits at once.
+func (d *drainer) watchSignals(ctx context.Context) {
+	sig := make(chan os.Signal, 1)
+	signal.Notify(sig, syscall.SIGTERM, os.Interrupt)
+	defer signal.Stop(sig)
+	select {
+	case s := <-sig:
+		d.Start(s.String())
+	case <-ctx.Done():
+	}
+}
+
+// Handle serves POST /admin/drain for operators holding API_TOKEN.
+func (d *drainer) Handle(w http.ResponseWriter, r *http.Request) {
+	if d.apiToken == "" {
+		http.Error(w, "drain API disabled (API_TOKEN not set)", http.StatusServiceUnavailable)
+		return
+	}
+	if !webhook.OperatorAuthorized(r, d.apiToken) {
+		w.Header().Set("WWW-Authenticate", `Bearer realm="swe-agent"`)
+		http.Error(w, "unauthorized", http.StatusUnauthorized)
+		return
+	}
+	d.Start("POST /admin/drain")
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(http.StatusAccepted)
+	_ = json.NewEncoder(w).Encode(map[string]any{"status": "draining", "pid": os.Getpid()})
+}
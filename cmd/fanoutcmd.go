@@ -0,0 +1,168 @@
+package main
+
+import (
+	"bytes"
+	"encoding/json"
+	"errors"
+	"flag"
+	"fmt"
+	"io"
+	"net/http"
+	"os"
+	"strings"
+	"time"
+
+	"github.com/cexll/swe/internal/webhook"
+)
+
+const fanoutUsage = "usage: swe-agent fanout -repos owner/a,owner/b [-repos-file path] -title text -prompt text [-origin owner/repo#123] [-label l] [-base branch] [-server url] [-token t]"
+
+// runFanout implements `swe-agent fanout`: it asks a running server to
+// launch one prompt across several repositories and prints the group and
+// the task started in each.
+func runFanout(args []string, stdin io.Reader, stdout, stderr io.Writer) int {
+	fs := flag.NewFlagSet("fanout", flag.ContinueOnError)
+	fs.SetOutput(stderr)
+	server := fs.String("server", defaultServerURL(), "server URL (default: PUBLIC_URL, or localhost on PORT)")
+	token := fs.String("token", os.Getenv("API_TOKEN"), "operator token (default: API_TOKEN env)")
+	repos := fs.String("repos", "", "comma-separated repositories (owner/name)")
+	reposFile := fs.String("repos-file", "", "file listing repositories, one per line (- for stdin)")
+	title := fs.String("title", "", "title of the issue opened in each repository")
+	prompt := fs.String("prompt", "", "instruction, as written after the trigger keyword")
+	origin := fs.String("origin", "", "issue that gets the combined summary (owner/repo#123)")
+	labels := fs.String("label", "", "comma-separated labels added to each issue")
+	base := fs.String("base", "", "base branch (default: each repository's default branch)")
+	actor := fs.String("actor", "", "operator recorded in the tasks (default: api)")
+	if err := fs.Parse(args); err != nil {
+		if errors.Is(err, flag.ErrHelp) {
+			return 0
+		}
+		return 2
+	}
+	if fs.NArg() > 0 {
+		_, _ = fmt.Fprintf(stderr, "fanout: unexpected arguments: %s\n%s\n", strings.Join(fs.Args(), " "), fanoutUsage)
+		return 2
+	}
+
+	req := webhook.FanOutRequest{
+		Repos:      splitList(*repos),
+		Title:      *title,
+		Prompt:     *prompt,
+		Labels:     splitList(*labels),
+		BaseBranch: *base,
+		Origin:     *origin,
+		Actor:      *actor,
+	}
+	if *reposFile != "" {
+		listed, err := readRepoList(*reposFile, stdin)
+		if err != nil {
+			_, _ = fmt.Fprintf(stderr, "fanout: %v\n", err)
+			return 1
+		}
+		req.Repos = append(req.Repos, listed...)
+	}
+	if len(req.Repos) == 0 || strings.TrimSpace(req.Title) == "" || strings.TrimSpace(req.Prompt) == "" {
+		_, _ = fmt.Fprintln(stderr, fanoutUsage)
+		return 2
+	}
+	if *token == "" {
+		_, _ = fmt.Fprintln(stderr, "fanout: an operator token is required (-token or API_TOKEN)")
+		return 2
+	}
+
+	resp, err := submitFanout(strings.TrimRight(*server, "/"), *token, req)
+	if err != nil {
+		_, _ = fmt.Fprintf(stderr, "fanout: %v\n", err)
+		return 1
+	}
+	_, _ = fmt.Fprintf(stdout, "fan-out %s: %s%s\n", resp.GroupID, strings.TrimRight(*server, "/"), resp.URL)
+	failed := 0
+	for _, t := range resp.Tasks {
+		switch {
+		case t.Error != "":
+			failed++
+			_, _ = fmt.Fprintf(stdout, "  %s: not launched: %s\n", t.Repo, t.Error)
+		default:
+			_, _ = fmt.Fprintf(stdout, "  %s#%d: task %s\n", t.Repo, t.Issue, t.TaskID)
+		}
+	}
+	if failed > 0 {
+		_, _ = fmt.Fprintf(stderr, "fanout: %d of %d repositories were not launched\n", failed, len(resp.Tasks))
+		return 1
+	}
+	return 0
+}
+
+// defaultServerURL is where `swe-agent fanout` finds the server by default.
+func defaultServerURL() string {
+	if u := os.Getenv("PUBLIC_URL"); u != "" {
+		return u
+	}
+	port := os.Getenv("PORT")
+	if port == "" {
+		port = "8000"
+	}
+	return "http://localhost:" + port
+}
+
+// splitList splits a comma-separated flag value, dropping empty items.
+func splitList(s string) []string {
+	var items []string
+	for _, item := range strings.Split(s, ",") {
+		if item = strings.TrimSpace(item); item != "" {
+			items = append(items, item)
+		}
+	}
+	return items
+}
+
+// readRepoList reads repositories one per line, skipping blank lines and
+// # comments.
+func readRepoList(path string, stdin io.Reader) ([]string, error) {
+	var data []byte
+	var err error
+	if path == "-" {
+		data, err = io.ReadAll(stdin)
+	} else {
+		data, err = os.ReadFile(path)
+	}
+	if err != nil {
+		return nil, err
+	}
+	var repos []string
+	for _, line := range strings.Split(string(data), "\n") {
+		if line = strings.TrimSpace(line); line != "" && !strings.HasPrefix(line, "#") {
+			repos = append(repos, line)
+		}
+	}
+	return repos, nil
+}
+
+// submitFanout posts req to the server's fan-out API.
+func submitFanout(server, token string, req webhook.FanOutRequest) (*webhook.FanOutResponse, error) {
+	body, err := json.Marshal(req)
+	if err != nil {
+		return nil, err
+	}
+	httpReq, err := http.NewRequest(http.MethodPost, server+"/api/v1/fanout", bytes.NewReader(body))
+	if err != nil {
+		return nil, err
+	}
+	httpReq.Header.Set("Authorization", "Bearer "+token)
+	httpReq.Header.Set("Content-Type", "application/json")
+	client := &http.Client{Timeout: 5 * time.Minute} // each repository opens an issue
+	resp, err := client.Do(httpReq)
+	if err != nil {
+		return nil, err
+	}
+	defer func() { _ = resp.Body.Close() }()
+	data, _ := io.ReadAll(resp.Body)
+	if resp.StatusCode != http.StatusAccepted {
+		return nil, fmt.Errorf("server returned %d: %s", resp.StatusCode, strings.TrimSpace(string(data)))
+	}
+	var out webhook.FanOutResponse
+	if err := json.Unmarshal(data, &out); err != nil {
+		return nil, fmt.Errorf("decode response: %w", err)
+	}
+	return &out, nil
+}
@@ -0,0 +1,70 @@
+package main
+
+import (
+	"bytes"
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+
+	"github.com/cexll/swe/internal/taskstore"
+	"github.com/cexll/swe/internal/webhook"
+)
+
+func TestRunFanout_SubmitsAndPrints(t *testing.T) {
+	var got webhook.FanOutRequest
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.URL.Path != "/api/v1/fanout" || r.Header.Get("Authorization") != "Bearer op-token" {
+			t.Errorf("%s %s, auth %q", r.Method, r.URL.Path, r.Header.Get("Authorization"))
+		}
+		if err := json.NewDecoder(r.Body).Decode(&got); err != nil {
+			t.Error(err)
+		}
+		w.WriteHeader(http.StatusAccepted)
+		_ = json.NewEncoder(w).Encode(webhook.FanOutResponse{GroupID: "fanout-1", URL: "/groups/fanout-1", Tasks: []taskstore.GroupChild{
+			{Repo: "acme/api", Issue: 4, TaskID: "task-1"},
+			{Repo: "acme/web", Error: "repository not enabled"},
+			{Repo: "acme/cli", Issue: 9, TaskID: "task-2"},
+		}})
+	}))
+	defer server.Close()
+
+	var stdout, stderr bytes.Buffer
+	code := runFanout([]string{"-server", server.URL, "-token", "op-token", "-repos", "acme/api, acme/web", "-repos-file", "-",
+		"-title", "Bump lib", "-prompt", "bump lib to v2", "-label", "deps", "-origin", "acme/meta#3"},
+		strings.NewReader("# services\nacme/cli\n\n"), &stdout, &stderr)
+	if code != 1 || !strings.Contains(stderr.String(), "1 of 3 repositories were not launched") {
+		t.Fatalf("exit code = %d, stderr = %s", code, stderr.String())
+	}
+	if strings.Join(got.Repos, ",") != "acme/api,acme/web,acme/cli" || got.Labels[0] != "deps" || got.Origin != "acme/meta#3" || got.Title != "Bump lib" {
+		t.Fatalf("request = %+v", got)
+	}
+	for _, want := range []string{"fan-out fanout-1: " + server.URL + "/groups/fanout-1", "acme/api#4: task task-1", "acme/web: not launched: repository not enabled"} {
+		if !strings.Contains(stdout.String(), want) {
+			t.Fatalf("stdout lacks %q:\n%s", want, stdout.String())
+		}
+	}
+}
+
+func TestRunFanout_Failures(t *testing.T) {
+	t.Setenv("API_TOKEN", "")
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		http.Error(w, "repo \"x\" must be in owner/name form", http.StatusBadRequest)
+	}))
+	defer server.Close()
+
+	var stdout, stderr bytes.Buffer
+	if code := runFanout([]string{"-repos", "acme/api"}, nil, &stdout, &stderr); code != 2 || !strings.Contains(stderr.String(), fanoutUsage) {
+		t.Fatalf("missing flags: code = %d, stderr = %q", code, stderr.String())
+	}
+	stderr.Reset()
+	if code := runFanout([]string{"-repos", "acme/api", "-title", "t", "-prompt", "p"}, nil, &stdout, &stderr); code != 2 || !strings.Contains(stderr.String(), "token is required") {
+		t.Fatalf("no token: code = %d, stderr = %q", code, stderr.String())
+	}
+	stderr.Reset()
+	if code := runFanout([]string{"-server", server.URL, "-token", "x", "-repos", "x", "-title", "t", "-prompt", "p"}, nil, &stdout, &stderr); code != 1 ||
+		!strings.Contains(stderr.String(), "server returned 400: repo \"x\" must be in owner/name form") {
+		t.Fatalf("rejected: code = %d, stderr = %q", code, stderr.String())
+	}
+}
@@ -0,0 +1,185 @@
+package main
+
+import (
+	"encoding/json"
+	"errors"
+	"flag"
+	"fmt"
+	"io"
+	"os"
+	"os/exec"
+	"path/filepath"
+	"sort"
+	"strings"
+
+	"github.com/cexll/swe/internal/reposettings"
+)
+
+const importActionUsage = `usage: swe-agent import-action [-o repos.json] [-repo owner/name] [owner/name=]path...
+
+Each path is a repository checkout (its .github/workflows are searched) or a
+workflow file. The repository is taken from -repo, an owner/name= prefix or
+the checkout's origin remote.`
+
+// allow tests to stub the origin remote lookup
+var originRemote = func(dir string) (string, error) {
+	out, err := exec.Command("git", "-C", dir, "remote", "get-url", "origin").Output()
+	return strings.TrimSpace(string(out)), err
+}
+
+// runImportAction implements `swe-agent import-action`: it turns the
+// claude-code-action workflows of one or more repositories into entries of
+// REPO_SETTINGS_FILE (trigger keyword, allowed and disallowed tools, custom
+// instructions). With -o the entries are merged into that file; otherwise
+// the JSON is printed. Inputs that have no equivalent are reported.
+func runImportAction(args []string, stdout, stderr io.Writer) int {
+	fs := flag.NewFlagSet("import-action", flag.ContinueOnError)
+	fs.SetOutput(stderr)
+	fs.Usage = func() {
+		_, _ = fmt.Fprintln(stderr, importActionUsage)
+		fs.PrintDefaults()
+	}
+	output := fs.String("o", "", "merge the settings into this REPO_SETTINGS_FILE (default: print them)")
+	repoFlag := fs.String("repo", "", "owner/name of the repository (only with a single path)")
+	if err := fs.Parse(args); err != nil {
+		if errors.Is(err, flag.ErrHelp) {
+			return 0
+		}
+		return 2
+	}
+	if fs.NArg() == 0 {
+		fs.Usage()
+		return 2
+	}
+	if *repoFlag != "" && fs.NArg() > 1 {
+		_, _ = fmt.Fprintln(stderr, "import-action: -repo needs a single path; prefix paths with owner/name= instead")
+		return 2
+	}
+
+	settings := make(map[string]reposettings.Settings)
+	if *output != "" {
+		if err := readRepoSettings(*output, settings); err != nil {
+			_, _ = fmt.Fprintf(stderr, "import-action: %v\n", err)
+			return 1
+		}
+	}
+
+	failed := false
+	for _, arg := range fs.Args() {
+		repo, path := *repoFlag, arg
+		if r, p, ok := strings.Cut(arg, "="); ok && strings.Count(r, "/") == 1 {
+			repo, path = r, p
+		}
+		imp, source, err := importWorkflows(path)
+		if err == nil && repo == "" {
+			repo, err = repoOfCheckout(path)
+		}
+		if err != nil {
+			_, _ = fmt.Fprintf(stderr, "%s: %v\n", arg, err)
+			failed = true
+			continue
+		}
+		repo = strings.ToLower(repo)
+		settings[repo] = imp.Settings
+		_, _ = fmt.Fprintf(stderr, "%s: imported %s (trigger %q, %d allowed and %d disallowed tools, instructions: %t)\n",
+			repo, source, imp.Settings.TriggerKeyword, len(imp.Settings.AllowedTools), len(imp.Settings.DisallowedTools), imp.Settings.Instructions != "")
+		for _, note := range imp.Notes {
+			_, _ = fmt.Fprintf(stderr, "%s:   %s\n", repo, note)
+		}
+	}
+
+	data, err := json.MarshalIndent(settings, "", "  ")
+	if err != nil {
+		_, _ = fmt.Fprintf(stderr, "import-action: %v\n", err)
+		return 1
+	}
+	data = append(data, '\n')
+	if *output == "" {
+		_, _ = stdout.Write(data)
+	} else if err := os.WriteFile(*output, data, 0o644); err != nil {
+		_, _ = fmt.Fprintf(stderr, "import-action: %v\n", err)
+		return 1
+	} else {
+		_, _ = fmt.Fprintf(stderr, "wrote %d repositories to %s\n", len(settings), *output)
+	}
+	if failed {
+		return 1
+	}
+	return 0
+}
+
+// readRepoSettings loads an existing settings file into settings so imports
+// add to it; a missing file is empty.
+func readRepoSettings(path string, settings map[string]reposettings.Settings) error {
+	data, err := os.ReadFile(path)
+	if errors.Is(err, os.ErrNotExist) {
+		return nil
+	}
+	if err != nil {
+		return err
+	}
+	if _, err := reposettings.Parse(data); err != nil {
+		return fmt.Errorf("%s: %w", path, err)
+	}
+	var existing map[string]reposettings.Settings
+	if err := json.Unmarshal(data, &existing); err != nil {
+		return fmt.Errorf("%s: %w", path, err)
+	}
+	for repo, s := range existing {
+		settings[strings.ToLower(repo)] = s
+	}
+	return nil
+}
+
+// importWorkflows imports the first claude-code-action workflow at path (a
+// workflow file or a checkout), returning the file it came from.
+func importWorkflows(path string) (reposettings.Import, string, error) {
+	files := []string{path}
+	if info, err := os.Stat(path); err != nil {
+		return reposettings.Import{}, "", err
+	} else if info.IsDir() {
+		files = nil
+		for _, pattern := range []string{"*.yml", "*.yaml"} {
+			matches, _ := filepath.Glob(filepath.Join(path, ".github", "workflows", pattern))
+			files = append(files, matches...)
+		}
+		sort.Strings(files)
+	}
+	for _, file := range files {
+		data, err := os.ReadFile(file)
+		if err != nil {
+			return reposettings.Import{}, "", err
+		}
+		imp, ok, err := reposettings.FromWorkflow(data)
+		if err != nil {
+			return reposettings.Import{}, "", fmt.Errorf("%s: %w", file, err)
+		}
+		if ok {
+			return imp, file, nil
+		}
+	}
+	return reposettings.Import{}, "", errors.New("no workflow uses claude-code-action")
+}
+
+// repoOfCheckout returns owner/name from the GitHub origin remote of the
+// checkout holding path.
+func repoOfCheckout(path string) (string, error) {
+	dir := path
+	if info, err := os.Stat(path); err == nil && !info.IsDir() {
+		dir = filepath.Dir(path)
+	}
+	url, err := originRemote(dir)
+	if err != nil || url == "" {
+		return "", errors.New("cannot tell the repository; pass -repo or prefix the path with owner/name=")
+	}
+	url = strings.TrimSuffix(url, ".git")
+	i := strings.Index(url, "github.com")
+	if i < 0 {
+		return "", fmt.Errorf("origin %s is not on GitHub; pass -repo or prefix the path with owner/name=", url)
+	}
+	repo := strings.TrimLeft(url[i+len("github.com"):], ":/")
+	if strings.Count(repo, "/") != 1 {
+		return "", fmt.Errorf("cannot tell the repository from origin %s", url)
+	}
+	return repo, nil
+}
@@ -0,0 +1,128 @@
+package main
+
+import (
+	"bytes"
+	"encoding/json"
+	"errors"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"github.com/cexll/swe/internal/reposettings"
+)
+
+const importWorkflow = `on: issue_comment
+jobs:
+  claude:
+    runs-on: ubuntu-latest
+    steps:
+      - uses: anthropics/claude-code-action@v1
+        with:
+          trigger_phrase: /claude
+          claude_args: --allowedTools "Bash(make test)" --model opus
+`
+
+// writeCheckout creates a checkout with a claude-code-action workflow and
+// makes its origin remote url.
+func writeCheckout(t *testing.T, remotes map[string]string, url string) string {
+	t.Helper()
+	dir := t.TempDir()
+	workflows := filepath.Join(dir, ".github", "workflows")
+	if err := os.MkdirAll(workflows, 0o755); err != nil {
+		t.Fatal(err)
+	}
+	if err := os.WriteFile(filepath.Join(workflows, "ci.yml"), []byte("on: push\njobs: {}\n"), 0o644); err != nil {
+		t.Fatal(err)
+	}
+	if err := os.WriteFile(filepath.Join(workflows, "claude.yaml"), []byte(importWorkflow), 0o644); err != nil {
+		t.Fatal(err)
+	}
+	remotes[dir] = url
+	return dir
+}
+
+func stubOriginRemote(t *testing.T) map[string]string {
+	t.Helper()
+	orig := originRemote
+	t.Cleanup(func() { originRemote = orig })
+	remotes := make(map[string]string)
+	originRemote = func(dir string) (string, error) {
+		if url, ok := remotes[dir]; ok && url != "" {
+			return url, nil
+		}
+		return "", errors.New("no origin")
+	}
+	return remotes
+}
+
+func TestRunImportAction_Prints(t *testing.T) {
+	remotes := stubOriginRemote(t)
+	api := writeCheckout(t, remotes, "git@github.com:Acme/API.git")
+	docs := writeCheckout(t, remotes, "")
+
+	var stdout, stderr bytes.Buffer
+	if code := runImportAction([]string{api, "acme/docs=" + docs}, &stdout, &stderr); code != 0 {
+		t.Fatalf("exit code = %d, stderr = %s", code, stderr.String())
+	}
+	var got map[string]reposettings.Settings
+	if err := json.Unmarshal(stdout.Bytes(), &got); err != nil {
+		t.Fatalf("output is not JSON: %v\n%s", err, stdout.String())
+	}
+	want := reposettings.Settings{TriggerKeyword: "/claude", AllowedTools: []string{"Bash(make test)"}}
+	for _, repo := range []string{"acme/api", "acme/docs"} {
+		if s := got[repo]; s.TriggerKeyword != want.TriggerKeyword || len(s.AllowedTools) != 1 || s.AllowedTools[0] != want.AllowedTools[0] {
+			t.Errorf("%s = %+v", repo, s)
+		}
+	}
+	if !strings.Contains(stderr.String(), "acme/api:   claude_args --model") {
+		t.Errorf("the model flag is not reported:\n%s", stderr.String())
+	}
+}
+
+func TestRunImportAction_MergesIntoFile(t *testing.T) {
+	remotes := stubOriginRemote(t)
+	web := writeCheckout(t, remotes, "https://github.com/acme/web")
+	out := filepath.Join(t.TempDir(), "repos.json")
+	if err := os.WriteFile(out, []byte(`{"acme/keep": {"instructions": "Be brief."}, "acme/web": {"trigger_keyword": "/old"}}`), 0o644); err != nil {
+		t.Fatal(err)
+	}
+
+	var stdout, stderr bytes.Buffer
+	if code := runImportAction([]string{"-o", out, web}, &stdout, &stderr); code != 0 {
+		t.Fatalf("exit code = %d, stderr = %s", code, stderr.String())
+	}
+	set, err := reposettings.Load(out)
+	if err != nil {
+		t.Fatalf("the written file does not load: %v", err)
+	}
+	if set.For("acme/keep").Instructions != "Be brief." || set.For("acme/web").TriggerKeyword != "/claude" {
+		t.Fatalf("merged settings: keep=%+v web=%+v", set.For("acme/keep"), set.For("acme/web"))
+	}
+	if stdout.Len() != 0 || !strings.Contains(stderr.String(), "wrote 2 repositories to "+out) {
+		t.Fatalf("stdout = %q, stderr = %s", stdout.String(), stderr.String())
+	}
+}
+
+func TestRunImportAction_Failures(t *testing.T) {
+	remotes := stubOriginRemote(t)
+	unknown := writeCheckout(t, remotes, "")
+	empty := t.TempDir()
+
+	var stdout, stderr bytes.Buffer
+	if code := runImportAction([]string{unknown, "acme/empty=" + empty}, &stdout, &stderr); code != 1 {
+		t.Fatalf("exit code = %d, want 1", code)
+	}
+	for _, want := range []string{"pass -repo or prefix the path with owner/name=", "no workflow uses claude-code-action"} {
+		if !strings.Contains(stderr.String(), want) {
+			t.Errorf("stderr missing %q:\n%s", want, stderr.String())
+		}
+	}
+
+	if code := runImportAction(nil, &stdout, &stderr); code != 2 {
+		t.Fatalf("exit code without paths = %d, want 2", code)
+	}
+	if code := runImportAction([]string{"-repo", "acme/a", unknown, empty}, &stdout, &stderr); code != 2 {
+		t.Fatalf("exit code for -repo with two paths = %d, want 2", code)
+	}
+}
@@ -0,0 +1,185 @@
+package main
+
+import (
+	"context"
+	"errors"
+	"flag"
+	"fmt"
+	"io"
+	"os"
+	"os/signal"
+	"strings"
+
+	"github.com/cexll/swe/internal/config"
+	"github.com/cexll/swe/internal/executor"
+	"github.com/cexll/swe/internal/provider"
+)
+
+// localRunOptions configures `swe-agent run`.
+type localRunOptions struct {
+	RepoPath   string
+	Prompt     string
+	PromptFile string
+	Provider   string
+	Model      string
+	Branch     string
+	RepoName   string
+	ShowPrompt bool
+	NoDiff     bool
+}
+
+// allow tests to stub provider construction and execution
+var (
+	newLocalProvider = func(cfg *config.Config) (provider.Provider, error) { return cfg.NewProvider() }
+	runLocalTask     = executor.RunLocal
+)
+
+func runLocal(args []string, stdin io.Reader, stdout, stderr io.Writer) int {
+	opts, err := parseLocalRunFlags(args, stderr)
+	if errors.Is(err, flag.ErrHelp) {
+		return 0
+	}
+	if err != nil {
+		_, _ = fmt.Fprintf(stderr, "run: %v\n", err)
+		return 2
+	}
+
+	taskPrompt, err := readLocalPrompt(opts, stdin)
+	if err != nil {
+		_, _ = fmt.Fprintf(stderr, "run: %v\n", err)
+		return 2
+	}
+
+	if envFile := os.Getenv("ENV_FILE"); envFile != "" {
+		_ = loadDotEnv(envFile)
+	} else {
+		_ = loadDotEnv()
+	}
+	if opts.Provider != "" {
+		_ = os.Setenv("PROVIDER", opts.Provider)
+	}
+	cfg, err := config.LoadProvider()
+	if err != nil {
+		_, _ = fmt.Fprintf(stderr, "run: %v\n", err)
+		return 1
+	}
+	if opts.Model != "" {
+		cfg.ClaudeModel = opts.Model
+		cfg.CodexModel = opts.Model
+	}
+	p, err := newLocalProvider(cfg)
+	if err != nil {
+		_, _ = fmt.Fprintf(stderr, "run: %v\n", err)
+		return 1
+	}
+
+	ctx, stop := signal.NotifyContext(context.Background(), os.Interrupt)
+	defer stop()
+
+	res, err := runLocalTask(ctx, p, executor.LocalRequest{
+		RepoPath: opts.RepoPath,
+		Prompt:   taskPrompt,
+		Repo:     opts.RepoName,
+		Branch:   opts.Branch,
+		User:     localUser(),
+
+		PromptDir: cfg.PromptDir,
+	})
+	if res != nil {
+		printLocalResult(stdout, opts, p, res)
+	}
+	if err != nil {
+		_, _ = fmt.Fprintf(stderr, "run: %v\n", err)
+		return 1
+	}
+	return 0
+}
+
+func parseLocalRunFlags(args []string, stderr io.Writer) (localRunOptions, error) {
+	var opts localRunOptions
+	fs := flag.NewFlagSet("run", flag.ContinueOnError)
+	fs.SetOutput(stderr)
+	fs.StringVar(&opts.RepoPath, "repo", ".", "path to the local repository checkout")
+	fs.StringVar(&opts.Prompt, "prompt", "", "task instruction")
+	fs.StringVar(&opts.PromptFile, "prompt-file", "", "read the task instruction from this file (- for stdin)")
+	fs.StringVar(&opts.Provider, "provider", "", "AI provider: claude or codex (default: PROVIDER env)")
+	fs.StringVar(&opts.Model, "model", "", "model override for the selected provider")
+	fs.StringVar(&opts.Branch, "branch", "", "create or reset this branch before running (default: stay on the current branch)")
+	fs.StringVar(&opts.RepoName, "repo-name", "", "owner/name shown to the model (default: derived from the origin remote)")
+	fs.BoolVar(&opts.ShowPrompt, "show-prompt", false, "print the full prompt sent to the provider")
+	fs.BoolVar(&opts.NoDiff, "no-diff", false, "print only the diff stat, not the full diff")
+	if err := fs.Parse(args); err != nil {
+		return opts, err
+	}
+	if fs.NArg() > 0 {
+		return opts, fmt.Errorf("unexpected arguments: %s", strings.Join(fs.Args(), " "))
+	}
+	if opts.Prompt == "" && opts.PromptFile == "" {
+		return opts, errors.New("one of -prompt or -prompt-file is required")
+	}
+	if opts.Prompt != "" && opts.PromptFile != "" {
+		return opts, errors.New("-prompt and -prompt-file are mutually exclusive")
+	}
+	if opts.Provider != "" && opts.Provider != "claude" && opts.Provider != "codex" {
+		return opts, fmt.Errorf("invalid provider %q (must be claude or codex)", opts.Provider)
+	}
+	return opts, nil
+}
+
+func readLocalPrompt(opts localRunOptions, stdin io.Reader) (string, error) {
+	text := opts.Prompt
+	if opts.PromptFile != "" {
+		var data []byte
+		var err error
+		if opts.PromptFile == "-" {
+			data, err = io.ReadAll(stdin)
+		} else {
+			data, err = os.ReadFile(opts.PromptFile)
+		}
+		if err != nil {
+			return "", fmt.Errorf("read prompt: %w", err)
+		}
+		text = string(data)
+	}
+	text = strings.TrimSpace(text)
+	if text == "" {
+		return "", errors.New("prompt is empty")
+	}
+	return text, nil
+}
+
+func printLocalResult(w io.Writer, opts localRunOptions, p provider.Provider, res *executor.LocalResult) {
+	if opts.ShowPrompt {
+		_, _ = fmt.Fprintf(w, "=== Prompt ===\n%s\n\n", res.Prompt)
+	}
+	_, _ = fmt.Fprintf(w, "=== Summary (%s", p.Name())
+	if res.CostUSD > 0 {
+		_, _ = fmt.Fprintf(w, ", $%.4f", res.CostUSD)
+	}
+	_, _ = fmt.Fprintf(w, ") ===\n%s\n\n", strings.TrimSpace(res.Summary))
+
+	if strings.TrimSpace(res.DiffStat) == "" {
+		_, _ = fmt.Fprintln(w, "=== No changes ===")
+		return
+	}
+	_, _ = fmt.Fprintf(w, "=== Changes since %s ===\n%s\n", shortSHA(res.BaseSHA), strings.TrimRight(res.DiffStat, "\n"))
+	if !opts.NoDiff {
+		_, _ = fmt.Fprintf(w, "\n=== Diff ===\n%s", res.Diff)
+	}
+}
+
+func shortSHA(sha string) string {
+	if len(sha) > 12 {
+		return sha[:12]
+	}
+	return sha
+}
+
+func localUser() string {
+	for _, key := range []string{"USER", "USERNAME"} {
+		if v := os.Getenv(key); v != "" {
+			return v
+		}
+	}
+	return "local"
+}
@@ -0,0 +1,158 @@
+package main
+
+import (
+	"bytes"
+	"context"
+	"errors"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"github.com/cexll/swe/internal/config"
+	"github.com/cexll/swe/internal/executor"
+	"github.com/cexll/swe/internal/provider"
+)
+
+type localStubProvider struct{ name string }
+
+func (p *localStubProvider) Name() string { return p.name }
+
+func (p *localStubProvider) GenerateCode(context.Context, *provider.CodeRequest) (*provider.CodeResponse, error) {
+	return &provider.CodeResponse{}, nil
+}
+
+func stubLocalRun(t *testing.T, run func(context.Context, provider.Provider, executor.LocalRequest) (*executor.LocalResult, error)) *config.Config {
+	t.Helper()
+	origLoad, origProvider, origRun := loadDotEnv, newLocalProvider, runLocalTask
+	t.Cleanup(func() { loadDotEnv, newLocalProvider, runLocalTask = origLoad, origProvider, origRun })
+
+	loadDotEnv = func(...string) error { return nil }
+	var used config.Config
+	newLocalProvider = func(cfg *config.Config) (provider.Provider, error) {
+		used = *cfg
+		return &localStubProvider{name: cfg.Provider}, nil
+	}
+	runLocalTask = run
+	return &used
+}
+
+func TestRunLocal_PrintsSummaryAndDiff(t *testing.T) {
+	t.Setenv("GITHUB_APP_ID", "")
+	t.Setenv("PROVIDER", "claude")
+	t.Setenv("OPENAI_API_KEY", "")
+	var got executor.LocalRequest
+	used := stubLocalRun(t, func(_ context.Context, _ provider.Provider, req executor.LocalRequest) (*executor.LocalResult, error) {
+		got = req
+		return &executor.LocalResult{
+			Summary:  "Fixed the bug",
+			CostUSD:  0.5,
+			BaseSHA:  "0123456789abcdef0123",
+			DiffStat: " main.go | 2 +-\n",
+			Diff:     "diff --git a/main.go b/main.go\n+fixed\n",
+		}, nil
+	})
+
+	var stdout, stderr bytes.Buffer
+	code := runLocal([]string{"-repo", "/tmp/checkout", "-prompt", "fix it", "-provider", "codex", "-model", "gpt-x", "-branch", "feat/local"}, nil, &stdout, &stderr)
+	if code != 0 {
+		t.Fatalf("exit code = %d, stderr = %s", code, stderr.String())
+	}
+	if got.RepoPath != "/tmp/checkout" || got.Prompt != "fix it" || got.Branch != "feat/local" {
+		t.Fatalf("unexpected request: %+v", got)
+	}
+	if used.Provider != "codex" || used.CodexModel != "gpt-x" {
+		t.Fatalf("provider flags not applied: %s/%s", used.Provider, used.CodexModel)
+	}
+	out := stdout.String()
+	for _, want := range []string{"Summary (codex, $0.5000)", "Fixed the bug", "Changes since 0123456789ab", "main.go | 2 +-", "+fixed"} {
+		if !strings.Contains(out, want) {
+			t.Fatalf("output missing %q:\n%s", want, out)
+		}
+	}
+}
+
+func TestRunLocal_PromptFromStdinAndNoDiff(t *testing.T) {
+	t.Setenv("PROVIDER", "codex")
+	var got executor.LocalRequest
+	stubLocalRun(t, func(_ context.Context, _ provider.Provider, req executor.LocalRequest) (*executor.LocalResult, error) {
+		got = req
+		return &executor.LocalResult{Summary: "ok", DiffStat: " a.go | 1 +\n", Diff: "SECRET-DIFF"}, nil
+	})
+
+	var stdout, stderr bytes.Buffer
+	code := runLocal([]string{"-prompt-file", "-", "-no-diff"}, strings.NewReader("  add tests \n"), &stdout, &stderr)
+	if code != 0 {
+		t.Fatalf("exit code = %d, stderr = %s", code, stderr.String())
+	}
+	if got.Prompt != "add tests" || got.RepoPath != "." {
+		t.Fatalf("unexpected request: %+v", got)
+	}
+	if strings.Contains(stdout.String(), "SECRET-DIFF") || !strings.Contains(stdout.String(), "a.go | 1 +") {
+		t.Fatalf("-no-diff output:\n%s", stdout.String())
+	}
+}
+
+func TestRunLocal_PromptFile(t *testing.T) {
+	t.Setenv("PROVIDER", "codex")
+	path := filepath.Join(t.TempDir(), "task.md")
+	if err := os.WriteFile(path, []byte("refactor parser"), 0o644); err != nil {
+		t.Fatal(err)
+	}
+	var got executor.LocalRequest
+	stubLocalRun(t, func(_ context.Context, _ provider.Provider, req executor.LocalRequest) (*executor.LocalResult, error) {
+		got = req
+		return &executor.LocalResult{}, nil
+	})
+
+	var stdout, stderr bytes.Buffer
+	if code := runLocal([]string{"-prompt-file", path}, nil, &stdout, &stderr); code != 0 {
+		t.Fatalf("exit code = %d, stderr = %s", code, stderr.String())
+	}
+	if got.Prompt != "refactor parser" || !strings.Contains(stdout.String(), "No changes") {
+		t.Fatalf("prompt = %q, output:\n%s", got.Prompt, stdout.String())
+	}
+}
+
+func TestRunLocal_Failures(t *testing.T) {
+	t.Setenv("PROVIDER", "codex")
+	stubLocalRun(t, func(context.Context, provider.Provider, executor.LocalRequest) (*executor.LocalResult, error) {
+		return nil, errors.New("provider exploded")
+	})
+
+	cases := []struct {
+		args []string
+		code int
+		want string
+	}{
+		{nil, 2, "-prompt or -prompt-file is required"},
+		{[]string{"-prompt", "x", "-prompt-file", "y"}, 2, "mutually exclusive"},
+		{[]string{"-prompt", "x", "-provider", "gpt"}, 2, "invalid provider"},
+		{[]string{"-prompt", "x", "extra"}, 2, "unexpected arguments"},
+		{[]string{"-prompt", "   "}, 2, "prompt is empty"},
+		{[]string{"-prompt-file", filepath.Join(t.TempDir(), "missing")}, 2, "read prompt"},
+		{[]string{"-prompt", "x"}, 1, "provider exploded"},
+	}
+	for _, tc := range cases {
+		var stdout, stderr bytes.Buffer
+		if code := runLocal(tc.args, nil, &stdout, &stderr); code != tc.code || !strings.Contains(stderr.String(), tc.want) {
+			t.Errorf("args %v: code = %d, stderr = %q; want %d containing %q", tc.args, code, stderr.String(), tc.code, tc.want)
+		}
+	}
+
+	var stdout, stderr bytes.Buffer
+	if code := runLocal([]string{"-h"}, nil, &stdout, &stderr); code != 0 {
+		t.Fatalf("-h exit code = %d", code)
+	}
+}
+
+func TestRunLocal_ProviderConfigError(t *testing.T) {
+	t.Setenv("PROVIDER", "claude")
+	t.Setenv("ANTHROPIC_API_KEY", "")
+	stubLocalRun(t, nil)
+
+	var stdout, stderr bytes.Buffer
+	if code := runLocal([]string{"-prompt", "x"}, nil, &stdout, &stderr); code != 1 || !strings.Contains(stderr.String(), "ANTHROPIC_API_KEY") {
+		t.Fatalf("code = %d, stderr = %q", code, stderr.String())
+	}
+}
@@ -2,56 +2,299 @@ package main
 
 import (
 	"context"
+	"encoding/json"
 	"fmt"
 	"log"
+	"log/slog"
 	"net/http"
+	"os"
 
+	"github.com/cexll/swe/internal/audit"
+	"github.com/cexll/swe/internal/budget"
 	"github.com/cexll/swe/internal/config"
+	"github.com/cexll/swe/internal/delivery"
 	"github.com/cexll/swe/internal/dispatcher"
 	"github.com/cexll/swe/internal/executor"
 	"github.com/cexll/swe/internal/github"
+	"github.com/cexll/swe/internal/integrations/jira"
+	"github.com/cexll/swe/internal/integrations/linear"
+	"github.com/cexll/swe/internal/integrations/slack"
+	"github.com/cexll/swe/internal/integrations/telegram"
+	"github.com/cexll/swe/internal/journal"
+	"github.com/cexll/swe/internal/knowledge"
+	"github.com/cexll/swe/internal/logging"
 	_ "github.com/cexll/swe/internal/modes/command" // Register CommandMode
+	_ "github.com/cexll/swe/internal/modes/release" // Register ReleaseMode
+	_ "github.com/cexll/swe/internal/modes/triage"  // Register TriageMode
+	"github.com/cexll/swe/internal/notify"
+	"github.com/cexll/swe/internal/policy"
+	"github.com/cexll/swe/internal/reposettings"
+	"github.com/cexll/swe/internal/schedule"
+	"github.com/cexll/swe/internal/share"
 	"github.com/cexll/swe/internal/taskstore"
+	"github.com/cexll/swe/internal/version"
 	"github.com/cexll/swe/internal/web"
 	"github.com/cexll/swe/internal/webhook"
 	"github.com/gorilla/mux"
-	"github.com/joho/godotenv"
 )
 
 var (
-	loadDotEnv         = godotenv.Load
+	loadDotEnv         = config.ApplyDotEnv
 	newTaskStore       = taskstore.NewStore
 	newDispatcher      = dispatcher.New
 	newWebHandler      = web.NewHandler
-	defaultListenServe = http.ListenAndServe
+	defaultListenServe = listenAndServe
 )
 
 func main() {
+	configFile, args, err := extractConfigFlag(os.Args[1:])
+	if err != nil {
+		log.Fatal(err)
+	}
+	if configFile != "" {
+		_ = os.Setenv("CONFIG_FILE", configFile)
+	}
+	if len(args) > 0 {
+		switch args[0] {
+		case "install-service":
+			os.Exit(runInstallService(args[1:], os.Stdout, os.Stderr))
+		case "run":
+			os.Exit(runLocal(args[1:], os.Stdin, os.Stdout, os.Stderr))
+		case "config":
+			os.Exit(runConfig(args[1:], os.Stdout, os.Stderr))
+		case "version", "--version", "-version":
+			fmt.Println(version.Get().String())
+			os.Exit(0)
+		case "import-action":
+			os.Exit(runImportAction(args[1:], os.Stdout, os.Stderr))
+		case "prompt":
+			os.Exit(runPrompt(args[1:], os.Stdin, os.Stdout, os.Stderr))
+		case "fanout":
+			os.Exit(runFanout(args[1:], os.Stdin, os.Stdout, os.Stderr))
+		case "bench":
+			os.Exit(runBenchCmd(args[1:], os.Stdout, os.Stderr))
+		case executor.PushCheckCommand:
+			// run by the git guard's pre-push hook
+			os.Exit(executor.RunPushCheck(args[1:], os.Stdin, os.Stderr))
+		case executor.SuperviseMCPCommand:
+			// run by the provider CLI for each MCP server
+			os.Exit(executor.RunSuperviseMCP(args[1:], os.Stdin, os.Stdout, os.Stderr))
+		}
+	}
 	if err := run(context.Background(), defaultListenServe); err != nil {
 		log.Fatalf("Server failed: %v", err)
 	}
 }
 
-func run(ctx context.Context, serve func(string, http.Handler) error) error {
-	// Load .env file (ignore error if file doesn't exist)
-	_ = loadDotEnv()
+// envFileName is the dotenv file loaded at startup and on reload.
+func envFileName() string {
+	if envFile := os.Getenv("ENV_FILE"); envFile != "" {
+		return envFile
+	}
+	return ".env"
+}
+
+// loadEnvFile loads .env, or ENV_FILE when set; a missing file is not an error.
+func loadEnvFile() error {
+	return loadDotEnv(envFileName())
+}
+
+// dispatcherConfig maps the dispatcher settings of cfg.
+func dispatcherConfig(cfg *config.Config) dispatcher.Config {
+	return dispatcher.Config{
+		Workers:           cfg.DispatcherWorkers,
+		QueueSize:         cfg.DispatcherQueueSize,
+		MaxAttempts:       cfg.DispatcherMaxAttempts,
+		InitialBackoff:    cfg.DispatcherRetryInitial,
+		BackoffMultiplier: cfg.DispatcherBackoffMultiplier,
+		MaxBackoff:        cfg.DispatcherRetryMax,
+		MaxRunTime:        cfg.DispatcherMaxRunTime,
+		RequeueTimedOut:   cfg.DispatcherRequeueTimedOut,
+	}
+}
+
+// orgLimits maps the per-organization task caps of cfg.
+func orgLimits(cfg *config.Config) dispatcher.OrgLimits {
+	orgs, _ := dispatcher.ParseOrgLimits(cfg.DispatcherOrgLimits) // validated by Load
+	return dispatcher.OrgLimits{Default: cfg.DispatcherOrgMaxTasks, Orgs: orgs}
+}
+
+// budgetLimits maps the monthly budget settings of cfg.
+func budgetLimits(cfg *config.Config) budget.Limits {
+	orgs, _ := budget.ParseOrgBudgets(cfg.BudgetOrgs) // validated by Load
+	return budget.Limits{
+		Default:        cfg.BudgetMonthlyUSD,
+		Orgs:           orgs,
+		WarnPercent:    cfg.BudgetWarnPercent,
+		HardCapPercent: cfg.BudgetHardCapPercent,
+	}
+}
+
+// costEstimate maps the cost estimate settings of cfg.
+func costEstimate(cfg *config.Config) executor.CostEstimate {
+	return executor.CostEstimate{PerMTokUSD: cfg.CostPerMTokUSD, ConfirmUSD: cfg.CostConfirmUSD}
+}
+
+// commitStatus maps the commit status settings of cfg.
+func commitStatus(cfg *config.Config) executor.CommitStatusConfig {
+	return executor.CommitStatusConfig{Enabled: cfg.CommitStatus, Context: cfg.CommitStatusContext, BaseURL: cfg.PublicURL}
+}
+
+// taskTimeouts maps the task run time limits of cfg.
+func taskTimeouts(cfg *config.Config) executor.TaskTimeouts {
+	return executor.TaskTimeouts{Default: cfg.TaskTimeout, Max: cfg.TaskMaxTimeout}
+}
+
+// bootstrapConfig maps the environment bootstrap settings of cfg.
+func bootstrapConfig(cfg *config.Config) executor.BootstrapConfig {
+	return executor.BootstrapConfig{Enabled: cfg.EnvBootstrap, Commands: cfg.SetupCommands, Timeout: cfg.SetupTimeout}
+}
+
+// cacheConfig maps the dependency cache settings of cfg.
+func cacheConfig(cfg *config.Config) executor.CacheConfig {
+	return executor.CacheConfig{Dir: cfg.CacheDir, MaxBytes: int64(cfg.CacheMaxSizeMB) << 20}
+}
+
+// workspaceConfig maps the checkout settings of cfg.
+func workspaceConfig(cfg *config.Config) executor.WorkspaceConfig {
+	return executor.WorkspaceConfig{Dir: cfg.WorkspaceDir, MaxBytes: int64(cfg.WorkspaceMaxSizeMB) << 20, Worktrees: cfg.WorkspaceWorktrees}
+}
+
+// jiraConfig maps the Jira integration settings of cfg.
+func jiraConfig(cfg *config.Config) (jira.Config, error) {
+	projects, err := jira.ParseProjects(cfg.JiraProjects)
+	if err != nil {
+		return jira.Config{}, err
+	}
+	users, err := jira.ParseUsers(cfg.JiraUsers)
+	return jira.Config{
+		BaseURL:       cfg.JiraBaseURL,
+		Email:         cfg.JiraEmail,
+		APIToken:      cfg.JiraAPIToken,
+		WebhookSecret: cfg.JiraWebhookSecret,
+		Projects:      projects,
+		Users:         users,
+	}, err
+}
+
+// linearConfig maps the Linear integration settings of cfg.
+func linearConfig(cfg *config.Config) (linear.Config, error) {
+	teams, err := linear.ParseTeams(cfg.LinearTeams)
+	if err != nil {
+		return linear.Config{}, err
+	}
+	users, err := linear.ParseUsers(cfg.LinearUsers)
+	return linear.Config{APIKey: cfg.LinearAPIKey, WebhookSecret: cfg.LinearWebhookSecret, Teams: teams, Users: users}, err
+}
+
+// slackConfig maps the Slack slash command settings of cfg.
+func slackConfig(cfg *config.Config) (slack.Config, error) {
+	users, err := slack.ParseUsers(cfg.SlackUsers)
+	return slack.Config{SigningSecret: cfg.SlackSigningSecret, BotToken: cfg.SlackBotToken, Users: users}, err
+}
+
+// telegramConfig maps the Telegram bot settings of cfg.
+func telegramConfig(cfg *config.Config) (telegram.Config, error) {
+	chats, err := telegram.ParseChats(cfg.TelegramChats)
+	return telegram.Config{
+		BotToken:      cfg.TelegramBotToken,
+		WebhookSecret: cfg.TelegramWebhookSecret,
+		Chats:         chats,
+		Repos:         cfg.TelegramRepos,
+	}, err
+}
+
+// subtaskConfig maps the sub-task settings of cfg.
+func subtaskConfig(cfg *config.Config) executor.SubtaskConfig {
+	return executor.SubtaskConfig{Parallel: cfg.SubtaskParallelism, Max: cfg.SubtaskMax}
+}
+
+// releaseConfig maps the /release settings of cfg.
+func releaseConfig(cfg *config.Config) executor.ReleaseConfig {
+	return executor.ReleaseConfig{
+		Scheme:        cfg.ReleaseScheme,
+		TagPrefix:     cfg.ReleaseTagPrefix,
+		VersionFiles:  cfg.ReleaseVersionFiles,
+		Changelog:     cfg.ReleaseChangelog,
+		GitHubRelease: cfg.ReleaseGitHubRelease,
+	}
+}
+
+func run(ctx context.Context, serve func(context.Context, listenOptions, http.Handler) error) error {
+	ctx, cancel := context.WithCancel(ctx)
+	defer cancel()
+
+	// Load .env file, or ENV_FILE when set (ignore error if file doesn't exist)
+	_ = loadEnvFile()
 
 	// Load configuration
-	cfg, err := config.Load()
+	cfg, err := loadConfig()
 	if err != nil {
 		return fmt.Errorf("failed to load configuration: %w", err)
 	}
+	logLevel, _ := logging.ParseLevel(cfg.LogLevel) // validated by Load
+	logging.Setup(os.Stderr, logLevel, cfg.LogFormat)
 
-	log.Printf("Starting SWE-Agent server...")
-	log.Printf("Port: %d", cfg.Port)
-	log.Printf("Trigger keyword: %s", cfg.TriggerKeyword)
-	log.Printf("Provider: %s", cfg.Provider)
-	log.Printf("GitHub App ID: %s", cfg.GitHubAppID)
-	log.Printf("Dispatcher workers: %d, queue size: %d, max attempts: %d", cfg.DispatcherWorkers, cfg.DispatcherQueueSize, cfg.DispatcherMaxAttempts)
+	slog.Info("Starting SWE-Agent server", "version", version.Short(), "port", cfg.Port, "trigger_keyword", cfg.TriggerKeyword,
+		"provider", cfg.Provider, "github_app_id", cfg.GitHubAppID, "log_level", logLevel.String(), "log_format", cfg.LogFormat)
+	slog.Info("Dispatcher configured", "workers", cfg.DispatcherWorkers, "queue_size", cfg.DispatcherQueueSize, "max_attempts", cfg.DispatcherMaxAttempts)
 
 	// Initialize in-memory task store for UI
 	taskStore := newTaskStore()
 
+	// Initialize append-only audit log (JSON lines when AUDIT_LOG_PATH is set)
+	auditLog, err := audit.New(audit.Config{Path: cfg.AuditLogPath, Retention: cfg.AuditRetention})
+	if err != nil {
+		return fmt.Errorf("failed to initialize audit log: %w", err)
+	}
+	defer func() { _ = auditLog.Close() }()
+
+	// Keep task transcripts, diffs, test output and long log messages
+	artifactStore, logStore, err := openBlobStores(cfg)
+	if err != nil {
+		return fmt.Errorf("failed to initialize storage: %w", err)
+	}
+
+	// Elect one replica among those sharing storage to run periodic jobs
+	elector, err := newElector(cfg)
+	if err != nil {
+		return fmt.Errorf("failed to initialize leader election: %w", err)
+	}
+	go elector.Run(ctx)
+	defer func() { _ = elector.Resign(context.WithoutCancel(ctx)) }()
+
+	// Track webhook deliveries for replay protection and the deliveries API
+	deliveries, err := delivery.New(delivery.Config{Path: cfg.DeliveryLogPath, TTL: cfg.DeliveryTTL})
+	if err != nil {
+		return fmt.Errorf("failed to initialize delivery tracking: %w", err)
+	}
+	defer func() { _ = deliveries.Close() }()
+
+	// Keep accepted tasks until they finish so that a crash does not lose them
+	queueJournal, err := journal.Open(cfg.DispatcherJournalPath)
+	if err != nil {
+		return fmt.Errorf("failed to open dispatcher journal: %w", err)
+	}
+	defer func() { _ = queueJournal.Close() }()
+
+	// Add up what each organization spends against its monthly budget
+	budgets, err := budget.Open(cfg.BudgetPath)
+	if err != nil {
+		return fmt.Errorf("failed to open budget tracking: %w", err)
+	}
+	budgets.SetLimits(budgetLimits(cfg))
+
+	// Initialize task lifecycle notifications (empty when no endpoints
+	// configured, so a reload can add some)
+	notifier, err := notify.New(cfg.Notify)
+	if err != nil {
+		return fmt.Errorf("failed to initialize notifications: %w", err)
+	}
+	if notifier == nil {
+		notifier = notify.NewManager()
+	}
+	defer notifier.Close()
+
 	// Initialize GitHub App authentication
 	appAuth := &github.AppAuth{
 		AppID:      cfg.GitHubAppID,
@@ -63,48 +306,323 @@ func run(ctx context.Context, serve func(string, http.Handler) error) error {
 	if err != nil {
 		return fmt.Errorf("failed to initialize AI provider: %w", err)
 	}
-	log.Printf("AI Provider: %s", aiProvider.Name())
+	slog.Info("AI provider ready", "provider", aiProvider.Name())
 
 	// Initialize executor
 	exec := executor.New(aiProvider, appAuth)
+	exec.SetAuditLog(auditLog)
+	exec.SetBudgets(budgets)
+	exec.SetCostEstimate(costEstimate(cfg))
+	exec.SetNotifier(notifier)
+	exec.SetTaskStore(taskStore)
+	exec.SetArtifacts(artifactStore)
+	exec.SetLogStorage(logStore)
+	exec.SetWikiEditing(cfg.EnableWikiEditing)
+	exec.SetReleaseConfig(releaseConfig(cfg))
+	secretRules, err := executor.LoadSecretRules(cfg.SecretScanRulesFile)
+	if err != nil {
+		return err
+	}
+	exec.SetSecretRules(secretRules)
+	exec.SetBlockedPaths(cfg.BlockedPaths)
+	exec.SetHeartbeatInterval(cfg.HeartbeatInterval)
+	exec.SetTaskTimeouts(taskTimeouts(cfg))
+	exec.SetCommitStatus(commitStatus(cfg))
+	exec.SetBootstrap(bootstrapConfig(cfg))
+	exec.SetCache(cacheConfig(cfg))
+	if err := exec.SetWorkspaces(workspaceConfig(cfg)); err != nil {
+		return fmt.Errorf("failed to set up workspaces: %w", err)
+	}
+	if n, err := exec.RemoveStaleWorkspaces(); err != nil {
+		slog.Warn("Removing stale workspaces failed", "err", err)
+	} else if n > 0 {
+		slog.Info("Removed workspaces left by an earlier run", "count", n)
+	}
+	exec.SetSubtasks(subtaskConfig(cfg))
+	knowledgeStore, err := knowledge.Open(cfg.KnowledgeDir, cfg.KnowledgeMaxEntries)
+	if err != nil {
+		return err
+	}
+	exec.SetKnowledge(knowledgeStore, cfg.KnowledgePromptEntries)
+	exec.SetPromptTemplateDir(cfg.PromptDir)
+	exec.SetCommentTemplate(cfg.CommentTemplateFile)
+	exec.SetMinimizeOutdated(cfg.MinimizeOutdatedComments)
+	exec.SetContextBudget(cfg.ContextMaxTokens)
+	exec.SetRepoFileList(cfg.RepoFileListMax)
+	exec.SetPRDiffLimit(cfg.PRDiffMaxLines)
+	exec.SetFetchCache(cfg.FetchCacheTTL)
+	debugLevel, _ := logging.ParseLevel(cfg.DebugTaskLogLevel) // validated by Load
+	exec.SetDebugLogLevel(debugLevel)
+	if cfg.VerifyCommand != "" {
+		exec.SetPostPushChecks(executor.CommandCheck{Command: cfg.VerifyCommand, ScopedCommand: cfg.VerifyScopedCommand, Timeout: cfg.VerifyTimeout})
+		exec.SetArtifactDir(cfg.VerifyArtifactDir)
+	}
 	// Wrap the new executor with an adapter to satisfy dispatcher.TaskExecutor
 	adapted := executor.NewAdapter(exec)
 
 	// Initialize dispatcher (task queue with retries)
-	dispatcherConfig := dispatcher.Config{
-		Workers:           cfg.DispatcherWorkers,
-		QueueSize:         cfg.DispatcherQueueSize,
-		MaxAttempts:       cfg.DispatcherMaxAttempts,
-		InitialBackoff:    cfg.DispatcherRetryInitial,
-		BackoffMultiplier: cfg.DispatcherBackoffMultiplier,
-		MaxBackoff:        cfg.DispatcherRetryMax,
+	taskDispatcher := newDispatcher(adapted, dispatcherConfig(cfg))
+	taskDispatcher.SetNotifier(notifier)
+	taskDispatcher.SetQueueListener(adapted)
+	taskDispatcher.SetPrerequisites(taskStore)
+	taskDispatcher.SetJournal(queueJournal)
+	taskDispatcher.SetOrgLimits(orgLimits(cfg))
+	sharedKeys, err := sharedKeyStore(cfg)
+	if err != nil {
+		return fmt.Errorf("failed to initialize idempotency key storage: %w", err)
 	}
-	taskDispatcher := newDispatcher(adapted, dispatcherConfig)
-	defer taskDispatcher.Shutdown(ctx)
+	taskDispatcher.SetSharedKeys(sharedKeys)
+	go elector.Every(ctx, cleanupInterval, func(ctx context.Context) {
+		pruneBlobStores(ctx, artifactStore, logStore)
+		pruneSharedKeys(ctx, taskDispatcher)
+	})
+	shutdownCtx := ctx // replaced by the drain deadline once draining
+	defer func() { taskDispatcher.Shutdown(shutdownCtx) }()
 
 	// Initialize webhook handler
 	handler := webhook.NewHandler(cfg.GitHubWebhookSecret, cfg.TriggerKeyword, taskDispatcher, taskStore, appAuth)
+	handler.SetAuditLog(auditLog)
+	handler.SetBudgets(budgets)
+	handler.SetNotifier(notifier)
+	handler.SetDeliveryStore(deliveries)
+	handler.SetAPIToken(cfg.APIToken)
+	handler.SetGenericWebhookSecret(cfg.GenericWebhookSecret)
+	handler.SetPermissionCacheTTL(cfg.PermissionCacheTTL, cfg.PermissionCacheNegativeTTL)
+	handler.SetFetchInvalidator(exec.InvalidateFetch)
+	handler.SetProviderName(aiProvider.Name())
+	var jiraReceiver *jira.Receiver
+	if cfg.JiraWebhookSecret != "" {
+		jc, err := jiraConfig(cfg)
+		if err != nil {
+			return fmt.Errorf("invalid Jira settings: %w", err)
+		}
+		jiraReceiver = jira.New(jc, cfg.TriggerKeyword, handler.LaunchJira)
+		notifier.Observe(jiraReceiver)
+		slog.Info("Jira integration enabled", "url", jc.BaseURL)
+	}
+	var linearReceiver *linear.Receiver
+	if cfg.LinearWebhookSecret != "" {
+		lc, err := linearConfig(cfg)
+		if err != nil {
+			return fmt.Errorf("invalid Linear settings: %w", err)
+		}
+		linearReceiver = linear.New(lc, cfg.TriggerKeyword, handler.LaunchLinear)
+		notifier.Observe(linearReceiver)
+		slog.Info("Linear integration enabled")
+	}
+	taskStatus := func(id string) (string, bool) {
+		task, ok := taskStore.Get(id)
+		if !ok {
+			return "", false
+		}
+		return string(task.Status), true
+	}
+	var slackReceiver *slack.Receiver
+	if cfg.SlackSigningSecret != "" {
+		sc, err := slackConfig(cfg)
+		if err != nil {
+			return fmt.Errorf("invalid Slack settings: %w", err)
+		}
+		slackReceiver = slack.New(sc, handler.LaunchSlack, taskStatus)
+		notifier.Observe(slackReceiver)
+		slog.Info("Slack slash command enabled", "users", len(sc.Users))
+	}
+	var telegramReceiver *telegram.Receiver
+	if cfg.TelegramBotToken != "" {
+		tc, err := telegramConfig(cfg)
+		if err != nil {
+			return fmt.Errorf("invalid Telegram settings: %w", err)
+		}
+		telegramReceiver = telegram.New(tc, handler.LaunchTelegram, taskStatus)
+		notifier.Observe(telegramReceiver)
+		if tc.WebhookSecret == "" {
+			// one replica polls, or Telegram refuses the others
+			go elector.Every(ctx, telegram.PollInterval, telegramReceiver.Poll)
+			slog.Info("Telegram bot enabled", "mode", "long polling", "chats", len(tc.Chats))
+		} else {
+			slog.Info("Telegram bot enabled", "mode", "webhook", "chats", len(tc.Chats))
+		}
+	}
+	repoFilter, err := webhook.NewRepoFilter(cfg.RepoAllowlist, cfg.RepoDenylist)
+	if err != nil {
+		return fmt.Errorf("invalid repository filter: %w", err)
+	}
+	handler.SetRepoFilter(repoFilter)
+	if repoFilter != nil {
+		slog.Info("Repository filter", "allowlist", cfg.RepoAllowlist, "denylist", cfg.RepoDenylist)
+	}
+	handler.SetReleaseMode(cfg.EnableReleaseMode)
+	handler.SetReuseTrackingComment(cfg.ReuseTrackingComment)
+	handler.SetTriageMode(cfg.EnableTriageMode)
+	handler.SetApprovalMode(cfg.EnableApprovalMode)
+	handler.SetDefaultDryRun(cfg.DefaultDryRun)
+	handler.SetCostConfirmation(cfg.CostConfirmUSD > 0)
+	authzPolicy, err := policy.Load(cfg.PolicyFile)
+	if err != nil {
+		return err
+	}
+	handler.SetPolicy(authzPolicy)
+	exec.SetPolicy(authzPolicy)
+	adapted.SetHoldHandler(handler.HoldPushes)
+	repoSettings, err := reposettings.Load(cfg.RepoSettingsFile)
+	if err != nil {
+		return err
+	}
+	handler.SetRepoSettings(repoSettings)
+	exec.SetRepoSettings(repoSettings)
+	if repoSettings != nil {
+		slog.Info("Repository settings loaded", "repositories", len(repoSettings.Repos()), "file", cfg.RepoSettingsFile)
+	}
+	if authzPolicy != nil {
+		slog.Info("Authorization policy loaded", "file", cfg.PolicyFile)
+	}
+
+	// Fan-outs report to their origin issue once every child has finished
+	taskStore.SetGroupDoneHook(handler.ReportGroup)
+
+	// Queue again the tasks a crash or an undrained shutdown left unfinished
+	go recoverQueue(ctx, queueJournal, handler, taskDispatcher.AcceptKeys)
+
+	// Run recurring tasks from SCHEDULES_FILE; only the leader starts them
+	jobs, err := schedule.Load(cfg.SchedulesFile)
+	if err != nil {
+		return err
+	}
+	scheduler := schedule.New(jobs, handler.LaunchScheduled)
+	catchUp, _ := schedule.ParseCatchUp(cfg.SchedulesCatchUp) // validated by Load
+	scheduler.SetCatchUp(catchUp)
+	if cfg.SchedulesStatePath != "" {
+		if err := scheduler.LoadState(cfg.SchedulesStatePath); err != nil {
+			return fmt.Errorf("failed to load schedules state: %w", err)
+		}
+	}
+	go elector.Every(ctx, schedule.TickInterval, scheduler.Tick)
+	if len(jobs) > 0 {
+		slog.Info("Scheduled jobs loaded", "jobs", len(jobs), "file", cfg.SchedulesFile)
+	}
 
 	// Initialize web UI handler
 	webHandler, err := newWebHandler(taskStore)
 	if err != nil {
 		return fmt.Errorf("failed to initialize web handler: %w", err)
 	}
+	webHandler.SetStatsSource(taskDispatcher)
+	webHandler.SetWorkspaceSource(exec)
+	webHandler.SetAuditLog(auditLog)
+	webHandler.SetDeliveryStore(deliveries)
+	webHandler.SetArtifacts(artifactStore)
+	webHandler.SetLogStorage(logStore)
+	webHandler.SetAPIToken(cfg.APIToken)
+	webHandler.SetAdminPublic(cfg.AdminPublic)
+	webHandler.SetScheduler(scheduler)
+	webHandler.SetBudgets(budgets)
+	secrets := []string{cfg.GitHubWebhookSecret, cfg.GitHubPrivateKey, cfg.ClaudeAPIKey, cfg.OpenAIAPIKey, cfg.APIToken, cfg.GenericWebhookSecret, cfg.JiraAPIToken, cfg.JiraWebhookSecret, cfg.LinearAPIKey, cfg.LinearWebhookSecret, cfg.SlackSigningSecret, cfg.SlackBotToken, cfg.TelegramBotToken, cfg.TelegramWebhookSecret, cfg.ShareLinkSecret}
+	if cfg.Notify.Email != nil {
+		secrets = append(secrets, cfg.Notify.Email.Password)
+	}
+	webHandler.SetSharing(share.NewSigner(cfg.ShareLinkSecret, cfg.ShareLinkMaxTTL), share.NewRedactor(secrets...))
+
+	// Apply safe configuration changes on SIGHUP or, when polling, file edits
+	reloads := newReloader(cfg, handler, exec, taskDispatcher, notifier)
+	reloads.policy, reloads.repoSettings, reloads.scheduler = authzPolicy, repoSettings, scheduler
+	reloads.jira, reloads.linear = jiraReceiver, linearReceiver
+	reloads.budgets = budgets
+	go reloads.watch(ctx, cfg.ReloadPollInterval, os.Getenv("CONFIG_FILE"), envFileName(), cfg.PolicyFile, cfg.RepoSettingsFile, cfg.SchedulesFile)
+
+	// Serve until draining starts (SIGTERM, SIGINT or POST /admin/drain)
+	drain := newDrainer(cfg.APIToken)
+	serveCtx, stopServing := context.WithCancel(ctx)
+	defer stopServing()
+	go drain.watchSignals(serveCtx)
+	go func() {
+		select {
+		case <-drain.Done():
+			stopServing()
+		case <-serveCtx.Done():
+		}
+	}()
 
 	// Setup router
 	r := mux.NewRouter()
+	// Request and delivery IDs on every line logged while handling a request
+	r.Use(logging.Middleware)
 
 	// Webhook endpoint
 	r.HandleFunc("/webhook", handler.Handle).Methods("POST")
 
+	// Signed task requests from tools that are not GitHub
+	r.HandleFunc("/webhook/generic", handler.HandleGeneric).Methods("POST")
+	if jiraReceiver != nil {
+		r.HandleFunc("/webhook/jira", jiraReceiver.Handle).Methods("POST")
+	}
+	if linearReceiver != nil {
+		r.HandleFunc("/webhook/linear", linearReceiver.Handle).Methods("POST")
+	}
+	if slackReceiver != nil {
+		r.HandleFunc("/webhook/slack", slackReceiver.Handle).Methods("POST")
+	}
+	if telegramReceiver != nil && cfg.TelegramWebhookSecret != "" {
+		r.HandleFunc("/webhook/telegram", telegramReceiver.Handle).Methods("POST")
+	}
+
 	// Task UI endpoints
 	r.HandleFunc("/tasks", webHandler.ListTasks).Methods("GET")
 	r.HandleFunc("/tasks/{id}", webHandler.TaskDetail).Methods("GET")
+	r.HandleFunc("/tasks/{id}/timeline", webHandler.TaskTimeline).Methods("GET")
+	r.HandleFunc("/tasks/{id}/compare/{other}", webHandler.CompareTasks).Methods("GET")
+	r.HandleFunc("/tasks/{id}/artifacts/{name}", webHandler.TaskArtifact).Methods("GET")
+	r.HandleFunc("/tasks/{id}/logs/{name}", webHandler.TaskLog).Methods("GET")
+	r.HandleFunc("/tasks/{id}/share", webHandler.CreateShareLink).Methods("POST")
+	r.HandleFunc("/groups/{id}", webHandler.GroupDetail).Methods("GET")
+
+	// Signed, redacted transcript links for people without UI access
+	r.HandleFunc("/share/{token}", webHandler.SharedTask).Methods("GET")
+
+	// Admin dashboard endpoints
+	r.HandleFunc("/admin", webHandler.AdminDashboard).Methods("GET")
+	r.HandleFunc("/admin/api/stats", webHandler.AdminStats).Methods("GET")
+	r.HandleFunc("/admin/simulate", handler.Simulate).Methods("POST")
+	r.HandleFunc("/admin/drain", drain.Handle).Methods("POST")
+	r.HandleFunc("/schedules", webHandler.SchedulesPage).Methods("GET")
+	r.HandleFunc("/admin/api/schedules", webHandler.Schedules).Methods("GET")
+	r.HandleFunc("/admin/api/schedules/{name}/run", webHandler.RunSchedule).Methods("POST")
+	r.HandleFunc("/admin/api/budgets", webHandler.Budgets).Methods("GET")
+	r.HandleFunc("/admin/api/budgets/{org}/reset", webHandler.ResetBudget).Methods("POST")
+
+	// Audit log viewer and JSON lines export
+	r.HandleFunc("/audit", webHandler.AuditLog).Methods("GET")
+	r.HandleFunc("/audit/export", webHandler.AuditExport).Methods("GET")
+
+	// Manual task submission for operators (bypasses webhooks)
+	r.HandleFunc("/api/v1/tasks", handler.SubmitTask).Methods("POST")
+	r.HandleFunc("/api/v1/tasks/{id}/replay", handler.ReplayTask).Methods("POST")
+
+	// One prompt across several repositories, grouped (swe-agent fanout)
+	r.HandleFunc("/api/v1/fanout", handler.SubmitFanOut).Methods("POST")
+	r.HandleFunc("/api/v1/groups/{id}", webHandler.GroupStatus).Methods("GET")
 
-	// Health check endpoint
+	// The prompt a task handed to the provider, for operators
+	r.HandleFunc("/api/v1/tasks/{id}/prompt", webHandler.TaskPrompt).Methods("GET")
+
+	// Recent webhook deliveries and their outcomes
+	r.HandleFunc("/api/v1/deliveries", webHandler.Deliveries).Methods("GET")
+
+	// Health check endpoint, reporting the running build
 	r.HandleFunc("/health", func(w http.ResponseWriter, _ *http.Request) {
-		w.WriteHeader(http.StatusOK)
-		_, _ = w.Write([]byte("OK"))
+		health := struct {
+			Status string `json:"status"`
+			Leader bool   `json:"leader"` // runs the periodic jobs
+			version.Info
+		}{Status: "ok", Leader: elector.IsLeader(), Info: version.Get()}
+		code := http.StatusOK
+		if drain.Draining() {
+			// take this instance out of load balancer rotation
+			health.Status = "draining"
+			code = http.StatusServiceUnavailable
+		}
+		w.Header().Set("Content-Type", "application/json")
+		w.WriteHeader(code)
+		_ = json.NewEncoder(w).Encode(health)
 	}).Methods("GET")
 
 	// Root endpoint with info
@@ -116,14 +634,34 @@ func run(ctx context.Context, serve func(string, http.Handler) error) error {
 
 	// Start server
 	addr := fmt.Sprintf(":%d", cfg.Port)
-	log.Printf("Server listening on %s", addr)
-	log.Printf("Webhook endpoint: http://localhost%s/webhook", addr)
-	log.Printf("Health check: http://localhost%s/health", addr)
-	log.Printf("Tasks UI: http://localhost%s/tasks", addr)
+	slog.Info("Server listening", "addr", addr,
+		"webhook", "http://localhost"+addr+"/webhook",
+		"health", "http://localhost"+addr+"/health",
+		"tasks_ui", "http://localhost"+addr+"/tasks",
+		"admin", "http://localhost"+addr+"/admin")
 
-	if err := serve(addr, r); err != nil {
+	if cfg.ReusePort {
+		slog.Info("SO_REUSEPORT enabled: a new version may listen while this one drains", "addr", addr)
+	}
+
+	if err := serve(serveCtx, listenOptions{Addr: addr, ReusePort: cfg.ReusePort}, r); err != nil {
 		return fmt.Errorf("server failed to start: %w", err)
 	}
 
+	if drain.Draining() {
+		drainCtx, cancelDrain := context.WithCancel(context.Background())
+		if cfg.DrainTimeout > 0 {
+			drainCtx, cancelDrain = context.WithTimeout(context.Background(), cfg.DrainTimeout)
+		}
+		defer cancelDrain()
+		slog.Info("Draining: waiting for queued and running tasks", logging.KeyPhase, "drain")
+		if err := taskDispatcher.Drain(drainCtx); err != nil {
+			slog.Warn("Draining: gave up with tasks still running", logging.KeyPhase, "drain", "timeout", cfg.DrainTimeout)
+			shutdownCtx = drainCtx
+		} else {
+			slog.Info("Draining: all tasks finished", logging.KeyPhase, "drain")
+		}
+	}
+
 	return nil
 }
@@ -50,8 +50,8 @@ func TestRun_StartsServerWithValidConfig(t *testing.T) {
 	var servedAddr string
 	var servedHandler http.Handler
 
-	serve := func(addr string, handler http.Handler) error {
-		servedAddr = addr
+	serve := func(_ context.Context, opts listenOptions, handler http.Handler) error {
+		servedAddr = opts.Addr
 		servedHandler = handler
 		return nil
 	}
@@ -77,6 +77,9 @@ func TestRun_StartsServerWithValidConfig(t *testing.T) {
 	if rec.Code != http.StatusOK {
 		t.Fatalf("/health status = %d, want 200", rec.Code)
 	}
+	if body := rec.Body.String(); !strings.Contains(body, `"status":"ok"`) || !strings.Contains(body, `"version":`) {
+		t.Fatalf("/health body = %q, want status and version", body)
+	}
 
 	rec = httptest.NewRecorder()
 	req = httptest.NewRequest(http.MethodGet, "/", nil)
@@ -94,7 +97,7 @@ func TestRun_ReturnsErrorWhenServeFails(t *testing.T) {
 	chdirToRepoRoot(t)
 
 	expected := errors.New("listen failed")
-	err := run(context.Background(), func(string, http.Handler) error {
+	err := run(context.Background(), func(context.Context, listenOptions, http.Handler) error {
 		return expected
 	})
 
@@ -110,7 +113,7 @@ func TestRun_UnsupportedProvider(t *testing.T) {
 	setRequiredEnv(t, "unknown")
 
 	called := false
-	err := run(context.Background(), func(string, http.Handler) error {
+	err := run(context.Background(), func(context.Context, listenOptions, http.Handler) error {
 		called = true
 		return nil
 	})
@@ -132,8 +135,8 @@ func TestRun_UsesClaudeProvider(t *testing.T) {
 	// This test verifies that the Claude provider configuration path works end-to-end
 
 	var servedAddr string
-	err := run(context.Background(), func(addr string, handler http.Handler) error {
-		servedAddr = addr
+	err := run(context.Background(), func(_ context.Context, opts listenOptions, handler http.Handler) error {
+		servedAddr = opts.Addr
 		return nil
 	})
 	if err != nil {
@@ -154,7 +157,7 @@ func TestRun_WebHandlerError(t *testing.T) {
 		return nil, errors.New("inject failure")
 	}
 
-	err := run(context.Background(), func(string, http.Handler) error {
+	err := run(context.Background(), func(context.Context, listenOptions, http.Handler) error {
 		t.Fatalf("serve should not be called on web handler failure")
 		return nil
 	})
@@ -0,0 +1,233 @@
+package main
+
+import (
+	"encoding/json"
+	"errors"
+	"flag"
+	"fmt"
+	"io"
+	"os"
+	"strconv"
+	"strings"
+
+	"github.com/cexll/swe/internal/config"
+	"github.com/cexll/swe/internal/executor"
+	"github.com/cexll/swe/internal/github"
+	ghdata "github.com/cexll/swe/internal/github/data"
+	"github.com/cexll/swe/internal/prompt"
+	"github.com/cexll/swe/internal/reposettings"
+)
+
+const promptUsage = "usage: swe-agent prompt render [-payload file -event name | -repo owner/name -number n ...] [-checkout dir] [-json]"
+
+// promptRenderOptions configures `swe-agent prompt render`.
+type promptRenderOptions struct {
+	Payload   string
+	Event     string
+	Repo      string
+	Number    int
+	IsPR      bool
+	Title     string
+	Body      string
+	Comment   string
+	User      string
+	Base      string
+	Branch    string
+	Checkout  string
+	PromptDir string
+	MaxTokens int
+	MaxFiles  int
+	JSON      bool
+}
+
+// runPrompt implements `swe-agent prompt render`: it renders the prompt a
+// task would start with for a webhook payload or an issue described by
+// flags, without cloning, fetching from GitHub or running a provider.
+func runPrompt(args []string, stdin io.Reader, stdout, stderr io.Writer) int {
+	if len(args) == 0 || args[0] != "render" {
+		_, _ = fmt.Fprintln(stderr, promptUsage)
+		return 2
+	}
+	if envFile := os.Getenv("ENV_FILE"); envFile != "" {
+		_ = loadDotEnv(envFile)
+	} else {
+		_ = loadDotEnv()
+	}
+	if path := os.Getenv("CONFIG_FILE"); path != "" {
+		if err := config.ApplyFile(path); err != nil {
+			_, _ = fmt.Fprintf(stderr, "prompt render: %v\n", err)
+			return 1
+		}
+	}
+
+	opts, err := parsePromptRenderFlags(args[1:], stderr)
+	if errors.Is(err, flag.ErrHelp) {
+		return 0
+	}
+	if err != nil {
+		_, _ = fmt.Fprintf(stderr, "prompt render: %v\n", err)
+		return 2
+	}
+	preview, err := promptPreview(opts, stdin)
+	if err != nil {
+		_, _ = fmt.Fprintf(stderr, "prompt render: %v\n", err)
+		return 1
+	}
+
+	record, problems := executor.RenderPrompt(preview)
+	for _, err := range problems {
+		_, _ = fmt.Fprintf(stderr, "prompt render: warning: %v\n", err)
+	}
+	if opts.JSON {
+		enc := json.NewEncoder(stdout)
+		enc.SetEscapeHTML(false)
+		enc.SetIndent("", "  ")
+		_ = enc.Encode(record)
+		return 0
+	}
+	_, _ = fmt.Fprintf(stderr, "template: %s\n", record.Template)
+	_, _ = fmt.Fprintln(stdout, record.System)
+	return 0
+}
+
+func parsePromptRenderFlags(args []string, stderr io.Writer) (promptRenderOptions, error) {
+	var opts promptRenderOptions
+	fs := flag.NewFlagSet("prompt render", flag.ContinueOnError)
+	fs.SetOutput(stderr)
+	fs.StringVar(&opts.Payload, "payload", "", "webhook payload as GitHub delivers it (- for stdin)")
+	fs.StringVar(&opts.Event, "event", "issue_comment", "event type of -payload (X-GitHub-Event)")
+	fs.StringVar(&opts.Repo, "repo", "", "owner/name, without -payload")
+	fs.IntVar(&opts.Number, "number", 1, "issue or pull request number, without -payload")
+	fs.BoolVar(&opts.IsPR, "pr", false, "-number is a pull request")
+	fs.StringVar(&opts.Title, "title", "", "issue or pull request title, without -payload")
+	fs.StringVar(&opts.Body, "body", "", "issue or pull request description, without -payload")
+	fs.StringVar(&opts.Comment, "comment", "/code", "trigger comment, without -payload")
+	fs.StringVar(&opts.User, "user", "octocat", "comment author, without -payload")
+	fs.StringVar(&opts.Base, "base", "main", "base branch, without -payload")
+	fs.StringVar(&opts.Branch, "branch", "", "branch the task works on (default: the base branch)")
+	fs.StringVar(&opts.Checkout, "checkout", "", "repository checkout, for its .swe-agent/prompts and issue/PR templates")
+	fs.StringVar(&opts.PromptDir, "prompt-dir", os.Getenv("PROMPT_DIR"), "operator prompt templates (default: PROMPT_DIR)")
+	fs.IntVar(&opts.MaxTokens, "max-tokens", envInt("CONTEXT_MAX_TOKENS", prompt.DefaultMaxContextTokens), "GitHub context budget in estimated tokens, 0 for none (default: CONTEXT_MAX_TOKENS)")
+	fs.IntVar(&opts.MaxFiles, "max-files", envInt("REPO_FILE_LIST_MAX", prompt.DefaultRepoFileListMax), "entries in the -checkout file list, 0 for none (default: REPO_FILE_LIST_MAX)")
+	fs.BoolVar(&opts.JSON, "json", false, "print the prompt record as the task API returns it")
+	if err := fs.Parse(args); err != nil {
+		return opts, err
+	}
+	if fs.NArg() > 0 {
+		return opts, fmt.Errorf("unexpected arguments: %s", strings.Join(fs.Args(), " "))
+	}
+	if (opts.Payload == "") == (opts.Repo == "") {
+		return opts, errors.New("exactly one of -payload or -repo is required")
+	}
+	return opts, nil
+}
+
+// envInt returns the integer in the environment variable key, or def.
+func envInt(key string, def int) int {
+	if n, err := strconv.Atoi(os.Getenv(key)); err == nil {
+		return n
+	}
+	return def
+}
+
+// promptPreview builds the task context of opts. Only what the payload or
+// the flags say about the issue goes in; comments and reviews need GitHub.
+func promptPreview(opts promptRenderOptions, stdin io.Reader) (executor.PromptPreview, error) {
+	settings, err := reposettings.Load(os.Getenv("REPO_SETTINGS_FILE"))
+	if err != nil {
+		return executor.PromptPreview{}, err
+	}
+	preview := executor.PromptPreview{
+		Checkout:         opts.Checkout,
+		PromptDir:        opts.PromptDir,
+		Settings:         settings,
+		MaxContextTokens: opts.MaxTokens,
+		MaxRepoFiles:     opts.MaxFiles,
+	}
+
+	if opts.Payload == "" {
+		owner, name, ok := strings.Cut(opts.Repo, "/")
+		if !ok || owner == "" || name == "" {
+			return preview, fmt.Errorf("-repo must be in owner/name form")
+		}
+		ctx := &github.Context{
+			EventName:      github.EventIssueComment,
+			EventAction:    "created",
+			Repository:     github.Repository{Owner: owner, Name: name, FullName: opts.Repo, DefaultBranch: opts.Base},
+			IsPR:           opts.IsPR,
+			IssueNumber:    opts.Number,
+			IssueTitle:     opts.Title,
+			Actor:          opts.User,
+			TriggerUser:    opts.User,
+			TriggerComment: &github.Comment{Body: opts.Comment, User: opts.User},
+			BaseBranch:     opts.Base,
+			PreparedBranch: opts.Branch,
+		}
+		if opts.IsPR {
+			ctx.PRNumber = opts.Number
+		}
+		preview.Context = ctx
+		preview.Fetched = subjectFetched(ctx.IsPR, opts.Title, opts.Body, "", "open", opts.Base, opts.Branch)
+		return preview, nil
+	}
+
+	var payload []byte
+	if opts.Payload == "-" {
+		payload, err = io.ReadAll(stdin)
+	} else {
+		payload, err = os.ReadFile(opts.Payload)
+	}
+	if err != nil {
+		return preview, fmt.Errorf("read payload: %w", err)
+	}
+	ctx, err := github.ParseWebhookEvent(opts.Event, payload)
+	if err != nil {
+		return preview, err
+	}
+	if opts.Branch != "" {
+		ctx.PreparedBranch = opts.Branch
+	}
+	var subject struct {
+		Issue       *payloadSubject `json:"issue"`
+		PullRequest *payloadSubject `json:"pull_request"`
+	}
+	_ = json.Unmarshal(payload, &subject)
+	s := subject.PullRequest
+	if s == nil {
+		s = subject.Issue
+	}
+	if s == nil {
+		s = &payloadSubject{}
+	}
+	preview.Context = ctx
+	preview.Fetched = subjectFetched(ctx.IsPR, s.Title, s.Body, s.User.Login, s.State, s.Base.Ref, s.Head.Ref)
+	return preview, nil
+}
+
+// payloadSubject is the issue or pull request of a webhook payload.
+type payloadSubject struct {
+	Title string `json:"title"`
+	Body  string `json:"body"`
+	State string `json:"state"`
+	User  struct {
+		Login string `json:"login"`
+	} `json:"user"`
+	Base struct {
+		Ref string `json:"ref"`
+	} `json:"base"`
+	Head struct {
+		Ref string `json:"ref"`
+	} `json:"head"`
+}
+
+// subjectFetched stands in for the issue or pull request GitHub would return.
+func subjectFetched(isPR bool, title, body, author, state, base, head string) *ghdata.FetchResult {
+	if isPR {
+		return &ghdata.FetchResult{ContextData: ghdata.PullRequest{
+			Title: title, Body: body, Author: ghdata.Author{Login: author}, State: state, BaseRefName: base, HeadRefName: head,
+		}}
+	}
+	return &ghdata.FetchResult{ContextData: ghdata.Issue{
+		Title: title, Body: body, Author: ghdata.Author{Login: author}, State: state,
+	}}
+}
@@ -0,0 +1,84 @@
+package main
+
+import (
+	"bytes"
+	"encoding/json"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+)
+
+func stubPromptEnv(t *testing.T) {
+	t.Helper()
+	stubConfigEnv(t)
+	for _, env := range []string{"PROMPT_DIR", "REPO_SETTINGS_FILE", "CONTEXT_MAX_TOKENS", "REPO_FILE_LIST_MAX"} {
+		t.Setenv(env, "")
+	}
+}
+
+func TestRunPromptRender_Payload(t *testing.T) {
+	stubPromptEnv(t)
+	checkout := t.TempDir()
+	dir := filepath.Join(checkout, ".swe-agent", "prompts")
+	if err := os.MkdirAll(dir, 0o755); err != nil {
+		t.Fatal(err)
+	}
+	if err := os.WriteFile(filepath.Join(dir, "issue.tmpl"), []byte("Fix {{.Repository}}#{{.Number}}\n{{.GitHubContext}}"), 0o644); err != nil {
+		t.Fatal(err)
+	}
+	payload := `{"action":"created","issue":{"number":7,"title":"Parser crash","body":"It panics on <empty> input","state":"open","user":{"login":"alice"}},
+"comment":{"id":1,"body":"/code fix it","user":{"login":"bob"}},
+"repository":{"name":"app","full_name":"acme/app","default_branch":"main","owner":{"login":"acme"}},"sender":{"login":"bob"}}`
+
+	var stdout, stderr bytes.Buffer
+	code := runPrompt([]string{"render", "-payload", "-", "-checkout", checkout, "-json"}, strings.NewReader(payload), &stdout, &stderr)
+	if code != 0 {
+		t.Fatalf("exit code = %d, stderr = %s", code, stderr.String())
+	}
+	var record struct {
+		Template, System, User string
+	}
+	if err := json.Unmarshal(stdout.Bytes(), &record); err != nil {
+		t.Fatalf("%v: %s", err, stdout.String())
+	}
+	if record.Template != ".swe-agent/prompts/issue.tmpl" || record.User != "/code fix it" {
+		t.Fatalf("record = %+v", record)
+	}
+	if !strings.HasPrefix(record.System, "Fix acme/app#7\n") || !strings.Contains(record.System, "Parser crash") || !strings.Contains(stdout.String(), "<empty>") {
+		t.Fatalf("prompt = %s", record.System)
+	}
+}
+
+func TestRunPromptRender_Flags(t *testing.T) {
+	stubPromptEnv(t)
+	var stdout, stderr bytes.Buffer
+	code := runPrompt([]string{"render", "-repo", "acme/app", "-number", "3", "-pr", "-title", "Speed up export"}, nil, &stdout, &stderr)
+	if code != 0 {
+		t.Fatalf("exit code = %d, stderr = %s", code, stderr.String())
+	}
+	if !strings.Contains(stdout.String(), "Speed up export") || stderr.String() != "template: built-in\n" {
+		t.Fatalf("stdout = %s\nstderr = %s", stdout.String(), stderr.String())
+	}
+}
+
+func TestRunPrompt_Usage(t *testing.T) {
+	stubPromptEnv(t)
+	var stdout, stderr bytes.Buffer
+	if code := runPrompt(nil, nil, &stdout, &stderr); code != 2 || !strings.Contains(stderr.String(), promptUsage) {
+		t.Fatalf("code = %d, stderr = %q", code, stderr.String())
+	}
+	for _, args := range [][]string{
+		{"render"},
+		{"render", "-repo", "acme/app", "-payload", "event.json"},
+		{"render", "-repo", "acme/app", "extra"},
+	} {
+		stderr.Reset()
+		if code := runPrompt(args, nil, &stdout, &stderr); code != 2 {
+			t.Errorf("%v: code = %d, stderr = %q", args, code, stderr.String())
+		}
+	}
+	if code := runPrompt([]string{"render", "-repo", "acme"}, nil, &stdout, &stderr); code != 1 {
+		t.Errorf("-repo acme: code = %d", code)
+	}
+}
@@ -0,0 +1,80 @@
+package main
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"log/slog"
+	"time"
+
+	"github.com/cexll/swe/internal/journal"
+	"github.com/cexll/swe/internal/logging"
+	"github.com/cexll/swe/internal/webhook"
+)
+
+// requeueRetryInterval is how long recovery waits for room in a full queue.
+var requeueRetryInterval = time.Second
+
+// requeuer queues a recovered task again; *webhook.Handler implements it.
+type requeuer interface {
+	Requeue(task *webhook.Task) error
+}
+
+// recoverQueue queues again the tasks j recorded as accepted but not
+// finished, as after a crash, in the order they were accepted. When another
+// process still owns the journal, such as the version this one takes the
+// port over from while it drains, it first waits for that process to exit,
+// so the tasks it runs are not run twice; accept is then given the keys of
+// the tasks it finished. It waits while the queue is full and stops when
+// ctx is done or the queue closes, leaving the rest for the next start. It
+// returns how many were queued.
+func recoverQueue(ctx context.Context, j *journal.Journal, q requeuer, accept func(keys []string)) int {
+	if j != nil && !j.Owned() {
+		slog.Info("Waiting for the previous instance to release the dispatcher journal", logging.KeyPhase, "recover")
+	}
+	pending, err := j.Acquire(ctx)
+	if err != nil {
+		if ctx.Err() == nil {
+			slog.Error("Taking over the dispatcher journal failed", logging.KeyPhase, "recover", "err", err)
+		}
+		return 0
+	}
+	accept(j.Keys())
+	if len(pending) == 0 {
+		return 0
+	}
+	slog.Info("Recovering tasks accepted before the last shutdown", logging.KeyPhase, "recover", "tasks", len(pending))
+	n := 0
+	for _, e := range pending {
+		var task webhook.Task
+		if err := json.Unmarshal(e.Task, &task); err != nil {
+			slog.Warn("Dropping unreadable journal entry", logging.KeyPhase, "recover", logging.KeyTaskID, e.ID, "err", err)
+			_ = j.Done(e.ID)
+			continue
+		}
+		for {
+			err := q.Requeue(&task)
+			if err == nil {
+				n++
+				break
+			}
+			if errors.Is(err, webhook.ErrDuplicateTask) {
+				// a redelivery of the same event was accepted first
+				slog.Info("Dropping journal entry", logging.KeyPhase, "recover", logging.KeyTaskID, e.ID, logging.KeyRepo, task.Repo, "err", err)
+				_ = j.Done(e.ID)
+				break
+			}
+			if !errors.Is(err, webhook.ErrQueueFull) {
+				slog.Error("Stopped recovering tasks", logging.KeyPhase, "recover", logging.KeyTaskID, e.ID, logging.KeyRepo, task.Repo, "err", err)
+				return n
+			}
+			select {
+			case <-ctx.Done():
+				return n
+			case <-time.After(requeueRetryInterval):
+			}
+		}
+	}
+	slog.Info("Recovered tasks", logging.KeyPhase, "recover", "tasks", n)
+	return n
+}
@@ -0,0 +1,130 @@
+package main
+
+import (
+	"context"
+	"path/filepath"
+	"slices"
+	"testing"
+	"time"
+
+	"github.com/cexll/swe/internal/journal"
+	"github.com/cexll/swe/internal/webhook"
+)
+
+type stubRequeuer struct {
+	full   int // ErrQueueFull answers left
+	queued []*webhook.Task
+}
+
+func (s *stubRequeuer) Requeue(task *webhook.Task) error {
+	if s.full > 0 {
+		s.full--
+		return webhook.ErrQueueFull
+	}
+	s.queued = append(s.queued, task)
+	return nil
+}
+
+func TestRecoverQueue(t *testing.T) {
+	orig := requeueRetryInterval
+	t.Cleanup(func() { requeueRetryInterval = orig })
+	requeueRetryInterval = time.Millisecond
+
+	path := filepath.Join(t.TempDir(), "journal.jsonl")
+	j, err := journal.Open(path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	_ = j.Add("first", "", &webhook.Task{ID: "first", Repo: "owner/repo", Number: 1, CommentID: 9, RawPayload: []byte(`{"action":"created"}`)})
+	_ = j.Add("broken", "", "not a task")
+	_ = j.Add("second", "", &webhook.Task{ID: "second", Repo: "owner/repo", Number: 2, DependsOn: []string{"first"}})
+	_ = j.Add("finished", "", &webhook.Task{ID: "finished"})
+	_ = j.Done("finished")
+	_ = j.Close()
+
+	// as after a restart
+	j, err = journal.Open(path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	q := &stubRequeuer{full: 2}
+	var accepted []string
+	if n := recoverQueue(context.Background(), j, q, func(keys []string) { accepted = keys }); n != 2 {
+		t.Fatalf("recovered %d tasks, want 2", n)
+	}
+	var ids []string
+	for _, task := range q.queued {
+		ids = append(ids, task.ID)
+	}
+	if !slices.Equal(ids, []string{"first", "second"}) {
+		t.Fatalf("requeued %v", ids)
+	}
+	if first := q.queued[0]; first.CommentID != 9 || string(first.RawPayload) != `{"action":"created"}` {
+		t.Fatalf("recovered task = %+v", first)
+	}
+	if !slices.Equal(q.queued[1].DependsOn, []string{"first"}) {
+		t.Fatalf("dependencies lost: %+v", q.queued[1])
+	}
+	// the unreadable entry is dropped; the requeued ones stay until they finish
+	for _, e := range j.Pending() {
+		if e.ID == "broken" {
+			t.Fatal("unreadable entry kept")
+		}
+	}
+
+	if len(accepted) != 0 {
+		t.Fatalf("accepted keys %v", accepted)
+	}
+	// the requeued tasks are recovered at most once per start
+	if n := recoverQueue(context.Background(), j, &stubRequeuer{}, func([]string) {}); n != 0 {
+		t.Fatalf("recovered %d tasks again", n)
+	}
+	_ = j.Close()
+
+	// a queue that stays full gives up when the server stops
+	j, err = journal.Open(path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer j.Close()
+	ctx, cancel := context.WithCancel(context.Background())
+	cancel()
+	if n := recoverQueue(ctx, j, &stubRequeuer{full: 1 << 30}, func([]string) {}); n != 0 {
+		t.Fatalf("recovered %d tasks into a full queue", n)
+	}
+}
+
+func TestRecoverQueue_WaitsForThePreviousInstance(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "journal.jsonl")
+	old, err := journal.Open(path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	_ = old.Add("running", "delivery-1", &webhook.Task{ID: "running"})
+
+	// started by a REUSE_PORT handoff while the old instance drains
+	j, err := journal.Open(path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer j.Close()
+	q := &stubRequeuer{}
+	done := make(chan int)
+	var accepted []string
+	go func() { done <- recoverQueue(context.Background(), j, q, func(keys []string) { accepted = keys }) }()
+	select {
+	case n := <-done:
+		t.Fatalf("recovered %d tasks while the old instance runs them", n)
+	case <-time.After(50 * time.Millisecond):
+	}
+
+	// the old instance finishes its task and exits
+	_ = old.Done("running")
+	_ = old.Close()
+	if n := <-done; n != 0 || len(q.queued) != 0 {
+		t.Fatalf("recovered %d tasks: %v", n, q.queued)
+	}
+	if !slices.Equal(accepted, []string{"delivery-1"}) {
+		t.Fatalf("accepted keys %v", accepted)
+	}
+}
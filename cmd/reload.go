@@ -0,0 +1,340 @@
+package main
+
+import (
+	"context"
+	"fmt"
+	"log/slog"
+	"os"
+	"os/signal"
+	"reflect"
+	"strings"
+	"sync"
+	"syscall"
+	"time"
+
+	"github.com/cexll/swe/internal/budget"
+	"github.com/cexll/swe/internal/config"
+	"github.com/cexll/swe/internal/dispatcher"
+	"github.com/cexll/swe/internal/executor"
+	"github.com/cexll/swe/internal/integrations/jira"
+	"github.com/cexll/swe/internal/integrations/linear"
+	"github.com/cexll/swe/internal/logging"
+	"github.com/cexll/swe/internal/notify"
+	"github.com/cexll/swe/internal/policy"
+	"github.com/cexll/swe/internal/provider"
+	"github.com/cexll/swe/internal/reposettings"
+	"github.com/cexll/swe/internal/schedule"
+	"github.com/cexll/swe/internal/webhook"
+)
+
+// allow tests to stub configuration loading
+var loadConfig = config.Load
+
+// reloader re-reads .env and CONFIG_FILE and applies the settings that are
+// safe to change while running: trigger keyword, repository allow/denylist,
+// repository settings, permission cache TTLs, authorization policy,
+// dispatcher retry policy and per-organization task caps, organization
+// budgets, cost confirmation, scheduled jobs and their catch-up policy,
+// notification endpoints, wiki editing, release and approval modes, default
+// dry run, heartbeat interval, prompt templates, prompt context budget, file
+// list and PR diffs, fetch cache TTL, and provider model or credentials.
+// Tasks already running keep the settings they started with.
+type reloader struct {
+	mu           sync.Mutex
+	startup      *config.Config    // settings that need a restart are compared to this
+	cfg          *config.Config    // last applied configuration
+	providerOf   *config.Config    // configuration the running provider was built from
+	policy       *policy.Policy    // authorization policy last applied
+	repoSettings *reposettings.Set // per-repository settings last applied
+	scheduler    *schedule.Scheduler
+	jira         *jira.Receiver   // nil without the Jira integration
+	linear       *linear.Receiver // nil without the Linear integration
+	budgets      *budget.Tracker  // nil without budget tracking
+	handler      *webhook.Handler
+	executor     *executor.Executor
+	dispatcher   *dispatcher.Dispatcher
+	notifier     *notify.Manager
+}
+
+func newReloader(cfg *config.Config, h *webhook.Handler, e *executor.Executor, d *dispatcher.Dispatcher, n *notify.Manager) *reloader {
+	return &reloader{startup: cfg, cfg: cfg, providerOf: cfg, handler: h, executor: e, dispatcher: d, notifier: n}
+}
+
+// Reload loads the configuration again and applies what changed. On any
+// error the running configuration is left as it was.
+func (r *reloader) Reload() error {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	if err := loadEnvFile(); err != nil {
+		return err
+	}
+	cfg, err := loadConfig()
+	if err != nil {
+		return err
+	}
+	old := r.cfg
+
+	// Do everything that can fail before changing anything
+	var newProvider provider.Provider
+	if cfg.Provider == r.providerOf.Provider && config.ProviderChanged(r.providerOf, cfg) {
+		if newProvider, err = cfg.NewProvider(); err != nil {
+			return err
+		}
+	}
+	reposChanged := !reflect.DeepEqual(old.RepoAllowlist, cfg.RepoAllowlist) || !reflect.DeepEqual(old.RepoDenylist, cfg.RepoDenylist)
+	repoFilter, err := webhook.NewRepoFilter(cfg.RepoAllowlist, cfg.RepoDenylist)
+	if err != nil {
+		return err
+	}
+	// the policy, repository settings and schedules files are read on every
+	// reload: they change without the configuration changing
+	authzPolicy, err := policy.Load(cfg.PolicyFile)
+	if err != nil {
+		return err
+	}
+	repoSettings, err := reposettings.Load(cfg.RepoSettingsFile)
+	if err != nil {
+		return err
+	}
+	jobs, err := schedule.Load(cfg.SchedulesFile)
+	if err != nil {
+		return err
+	}
+	var applied []string
+	if !reflect.DeepEqual(old.Notify, cfg.Notify) {
+		if err := r.notifier.Update(cfg.Notify); err != nil {
+			return err
+		}
+		applied = append(applied, "notifications")
+	}
+
+	if newProvider != nil {
+		r.executor.SetProvider(newProvider)
+		r.providerOf = cfg
+		applied = append(applied, "provider "+newProvider.Name())
+	}
+	if cfg.EnableWikiEditing != old.EnableWikiEditing {
+		r.executor.SetWikiEditing(cfg.EnableWikiEditing)
+		applied = append(applied, fmt.Sprintf("wiki editing %t", cfg.EnableWikiEditing))
+	}
+	if cfg.ReuseTrackingComment != old.ReuseTrackingComment {
+		r.handler.SetReuseTrackingComment(cfg.ReuseTrackingComment)
+		applied = append(applied, fmt.Sprintf("reuse tracking comment %t", cfg.ReuseTrackingComment))
+	}
+	if cfg.EnableReleaseMode != old.EnableReleaseMode {
+		r.handler.SetReleaseMode(cfg.EnableReleaseMode)
+		applied = append(applied, fmt.Sprintf("release mode %t", cfg.EnableReleaseMode))
+	}
+	if cfg.EnableTriageMode != old.EnableTriageMode {
+		r.handler.SetTriageMode(cfg.EnableTriageMode)
+		applied = append(applied, fmt.Sprintf("triage mode %t", cfg.EnableTriageMode))
+	}
+	if cfg.EnableApprovalMode != old.EnableApprovalMode {
+		r.handler.SetApprovalMode(cfg.EnableApprovalMode)
+		applied = append(applied, fmt.Sprintf("approval mode %t", cfg.EnableApprovalMode))
+	}
+	if cfg.DefaultDryRun != old.DefaultDryRun {
+		r.handler.SetDefaultDryRun(cfg.DefaultDryRun)
+		applied = append(applied, fmt.Sprintf("default dry run %t", cfg.DefaultDryRun))
+	}
+	if cfg.HeartbeatInterval != old.HeartbeatInterval {
+		r.executor.SetHeartbeatInterval(cfg.HeartbeatInterval)
+		applied = append(applied, fmt.Sprintf("heartbeat every %v", cfg.HeartbeatInterval))
+	}
+	if cfg.TaskTimeout != old.TaskTimeout || cfg.TaskMaxTimeout != old.TaskMaxTimeout {
+		r.executor.SetTaskTimeouts(taskTimeouts(cfg))
+		applied = append(applied, fmt.Sprintf("task timeout %v (max %v)", cfg.TaskTimeout, cfg.TaskMaxTimeout))
+	}
+	if bootstrap := bootstrapConfig(cfg); !reflect.DeepEqual(bootstrap, bootstrapConfig(old)) {
+		r.executor.SetBootstrap(bootstrap)
+		applied = append(applied, fmt.Sprintf("environment bootstrap %t", bootstrap.Enabled))
+	}
+	if cache := cacheConfig(cfg); cache != cacheConfig(old) {
+		r.executor.SetCache(cache)
+		applied = append(applied, fmt.Sprintf("dependency caches %q (max %d MB)", cache.Dir, cfg.CacheMaxSizeMB))
+	}
+	if cfg.WorkspaceMaxSizeMB != old.WorkspaceMaxSizeMB || cfg.WorkspaceWorktrees != old.WorkspaceWorktrees {
+		// the directory only changes on restart
+		workspaces := workspaceConfig(cfg)
+		workspaces.Dir = old.WorkspaceDir
+		if err := r.executor.SetWorkspaces(workspaces); err != nil {
+			slog.Warn("Workspace settings not applied", logging.KeyPhase, "reload", "err", err)
+		} else {
+			applied = append(applied, fmt.Sprintf("workspace quota %d MB, worktrees %t", cfg.WorkspaceMaxSizeMB, cfg.WorkspaceWorktrees))
+		}
+	}
+	if subtasks := subtaskConfig(cfg); subtasks != subtaskConfig(old) {
+		r.executor.SetSubtasks(subtasks)
+		applied = append(applied, fmt.Sprintf("sub-tasks %d in parallel (max %d)", subtasks.Parallel, subtasks.Max))
+	}
+	if status := commitStatus(cfg); status != commitStatus(old) {
+		r.executor.SetCommitStatus(status)
+		applied = append(applied, fmt.Sprintf("commit status %t", status.Enabled))
+	}
+	if cfg.PromptDir != old.PromptDir {
+		r.executor.SetPromptTemplateDir(cfg.PromptDir)
+		applied = append(applied, "prompt templates "+cfg.PromptDir)
+	}
+	if cfg.MinimizeOutdatedComments != old.MinimizeOutdatedComments {
+		r.executor.SetMinimizeOutdated(cfg.MinimizeOutdatedComments)
+		applied = append(applied, fmt.Sprintf("minimize outdated comments %t", cfg.MinimizeOutdatedComments))
+	}
+	if cfg.CommentTemplateFile != old.CommentTemplateFile {
+		r.executor.SetCommentTemplate(cfg.CommentTemplateFile)
+		applied = append(applied, "comment template "+cfg.CommentTemplateFile)
+	}
+	if cfg.ContextMaxTokens != old.ContextMaxTokens {
+		r.executor.SetContextBudget(cfg.ContextMaxTokens)
+		applied = append(applied, fmt.Sprintf("context budget %d tokens", cfg.ContextMaxTokens))
+	}
+	if cfg.RepoFileListMax != old.RepoFileListMax {
+		r.executor.SetRepoFileList(cfg.RepoFileListMax)
+		applied = append(applied, fmt.Sprintf("repository file list of %d entries", cfg.RepoFileListMax))
+	}
+	if cfg.PRDiffMaxLines != old.PRDiffMaxLines {
+		r.executor.SetPRDiffLimit(cfg.PRDiffMaxLines)
+		applied = append(applied, fmt.Sprintf("PR diffs up to %d lines", cfg.PRDiffMaxLines))
+	}
+	if cfg.FetchCacheTTL != old.FetchCacheTTL {
+		r.executor.SetFetchCache(cfg.FetchCacheTTL)
+		applied = append(applied, fmt.Sprintf("fetch cache TTL %v", cfg.FetchCacheTTL))
+	}
+	if !reflect.DeepEqual(cfg.BlockedPaths, old.BlockedPaths) {
+		r.executor.SetBlockedPaths(cfg.BlockedPaths)
+		applied = append(applied, fmt.Sprintf("blocked paths %v", cfg.BlockedPaths))
+	}
+	if release := releaseConfig(cfg); !reflect.DeepEqual(release, releaseConfig(old)) {
+		r.executor.SetReleaseConfig(release)
+		applied = append(applied, "release settings")
+	}
+	if cfg.TriggerKeyword != old.TriggerKeyword {
+		r.handler.SetTriggerKeyword(cfg.TriggerKeyword)
+		if r.jira != nil {
+			r.jira.SetTriggerKeyword(cfg.TriggerKeyword)
+		}
+		if r.linear != nil {
+			r.linear.SetTriggerKeyword(cfg.TriggerKeyword)
+		}
+		applied = append(applied, "trigger keyword "+cfg.TriggerKeyword)
+	}
+	if reposChanged {
+		r.handler.SetRepoFilter(repoFilter)
+		applied = append(applied, "repository allow/denylist")
+	}
+	if !authzPolicy.Equal(r.policy) {
+		r.handler.SetPolicy(authzPolicy)
+		r.executor.SetPolicy(authzPolicy)
+		r.policy = authzPolicy
+		applied = append(applied, "authorization policy")
+	}
+	if !repoSettings.Equal(r.repoSettings) {
+		r.handler.SetRepoSettings(repoSettings)
+		r.executor.SetRepoSettings(repoSettings)
+		r.repoSettings = repoSettings
+		applied = append(applied, "repository settings")
+	}
+	if r.scheduler != nil {
+		if current := r.scheduler.Jobs(); (len(jobs) > 0 || len(current) > 0) && !reflect.DeepEqual(jobs, current) {
+			r.scheduler.Replace(jobs)
+			applied = append(applied, fmt.Sprintf("%d scheduled jobs", len(jobs)))
+		}
+		if cfg.SchedulesCatchUp != old.SchedulesCatchUp {
+			catchUp, _ := schedule.ParseCatchUp(cfg.SchedulesCatchUp) // validated by Load
+			r.scheduler.SetCatchUp(catchUp)
+			applied = append(applied, "schedules catch-up "+string(catchUp))
+		}
+	}
+	if cfg.PermissionCacheTTL != old.PermissionCacheTTL || cfg.PermissionCacheNegativeTTL != old.PermissionCacheNegativeTTL {
+		r.handler.SetPermissionCacheTTL(cfg.PermissionCacheTTL, cfg.PermissionCacheNegativeTTL)
+		applied = append(applied, "permission cache TTLs")
+	}
+	if cfg.LogLevel != old.LogLevel {
+		if lvl, err := logging.ParseLevel(cfg.LogLevel); err == nil {
+			logging.SetLevel(lvl)
+			applied = append(applied, "log level "+lvl.String())
+		}
+	}
+	if cfg.DebugTaskLogLevel != old.DebugTaskLogLevel {
+		if lvl, err := logging.ParseLevel(cfg.DebugTaskLogLevel); err == nil {
+			r.executor.SetDebugLogLevel(lvl)
+			applied = append(applied, "debug task log level "+lvl.String())
+		}
+	}
+	if retry := dispatcherConfig(cfg); retry != dispatcherConfig(old) {
+		r.dispatcher.SetRetryPolicy(retry)
+		applied = append(applied, "dispatcher retry policy")
+	}
+	if limits := orgLimits(cfg); !reflect.DeepEqual(limits, orgLimits(old)) {
+		r.dispatcher.SetOrgLimits(limits)
+		applied = append(applied, "per-organization task caps")
+	}
+	if limits := budgetLimits(cfg); r.budgets != nil && !reflect.DeepEqual(limits, budgetLimits(old)) {
+		r.budgets.SetLimits(limits)
+		applied = append(applied, "organization budgets")
+	}
+	if estimate := costEstimate(cfg); estimate != costEstimate(old) {
+		r.executor.SetCostEstimate(estimate)
+		r.handler.SetCostConfirmation(estimate.ConfirmUSD > 0)
+		applied = append(applied, fmt.Sprintf("cost confirmation from $%.2f", estimate.ConfirmUSD))
+	}
+	r.cfg = cfg
+
+	if len(applied) == 0 {
+		slog.Info("Configuration reloaded; nothing to apply", logging.KeyPhase, "reload")
+	} else {
+		slog.Info("Configuration reloaded", logging.KeyPhase, "reload", "applied", strings.Join(applied, ", "))
+	}
+	if restart := config.RestartRequired(r.startup, cfg); len(restart) > 0 {
+		slog.Warn("Changes take effect after a restart", logging.KeyPhase, "reload", "settings", strings.Join(restart, ", "))
+	}
+	return nil
+}
+
+// watch reloads on SIGHUP and, when interval > 0, whenever one of files
+// changes. It returns when ctx is done.
+func (r *reloader) watch(ctx context.Context, interval time.Duration, files ...string) {
+	hup := make(chan os.Signal, 1)
+	signal.Notify(hup, syscall.SIGHUP)
+	defer signal.Stop(hup)
+
+	var tick <-chan time.Time
+	if interval > 0 {
+		ticker := time.NewTicker(interval)
+		defer ticker.Stop()
+		tick = ticker.C
+	}
+	stamps := modTimes(files)
+
+	for {
+		select {
+		case <-ctx.Done():
+			return
+		case <-hup:
+			slog.Info("SIGHUP received", logging.KeyPhase, "reload")
+		case <-tick:
+			current := modTimes(files)
+			if current == stamps {
+				continue
+			}
+			stamps = current
+			slog.Info("Configuration file changed", logging.KeyPhase, "reload")
+		}
+		if err := r.Reload(); err != nil {
+			slog.Error("Keeping current configuration", logging.KeyPhase, "reload", "err", err)
+		}
+	}
+}
+
+// modTimes fingerprints files by modification time and size; missing files
+// contribute nothing.
+func modTimes(files []string) string {
+	var b strings.Builder
+	for _, f := range files {
+		if info, err := os.Stat(f); err == nil {
+			fmt.Fprintf(&b, "%s/%d", info.ModTime(), info.Size())
+		}
+		b.WriteString(";")
+	}
+	return b.String()
+}
@@ -0,0 +1,277 @@
+package main
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"errors"
+	"log"
+	"log/slog"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/cexll/swe/internal/config"
+	"github.com/cexll/swe/internal/dispatcher"
+	"github.com/cexll/swe/internal/executor"
+	"github.com/cexll/swe/internal/logging"
+	"github.com/cexll/swe/internal/notify"
+	"github.com/cexll/swe/internal/schedule"
+	"github.com/cexll/swe/internal/webhook"
+)
+
+func reloadConfig() *config.Config {
+	return &config.Config{
+		Port:                  8000,
+		Provider:              "claude",
+		ClaudeAPIKey:          "sk-test",
+		ClaudeModel:           "model-a",
+		TriggerKeyword:        "/code",
+		DispatcherWorkers:     1,
+		DispatcherQueueSize:   1,
+		DispatcherMaxAttempts: 3,
+	}
+}
+
+// newTestReloader returns a reloader whose next Reload loads *next.
+func newTestReloader(t *testing.T) (*reloader, **config.Config, *bytes.Buffer) {
+	t.Helper()
+	origLoadEnv, origLoadConfig := loadDotEnv, loadConfig
+	t.Cleanup(func() { loadDotEnv, loadConfig = origLoadEnv, origLoadConfig })
+	loadDotEnv = func(...string) error { return nil }
+	next := reloadConfig()
+	loadConfig = func() (*config.Config, error) {
+		if next == nil {
+			return nil, errors.New("GITHUB_APP_ID is required")
+		}
+		return next, nil
+	}
+
+	var logs bytes.Buffer
+	prev := slog.Default()
+	logging.Setup(&logs, slog.LevelInfo, logging.FormatText)
+	t.Cleanup(func() {
+		slog.SetDefault(prev)
+		logging.SetLevel(slog.LevelInfo)
+		log.SetOutput(os.Stderr)
+	})
+
+	cfg := reloadConfig()
+	p, err := cfg.NewProvider()
+	if err != nil {
+		t.Fatal(err)
+	}
+	handler := webhook.NewHandler("secret", cfg.TriggerKeyword, nil, nil, nil)
+	exec := executor.New(p, nil)
+	d := dispatcher.New(executor.NewAdapter(exec), dispatcherConfig(cfg))
+	t.Cleanup(func() { d.Shutdown(context.Background()) })
+	return newReloader(cfg, handler, exec, d, notify.NewManager()), &next, &logs
+}
+
+func triggerKeyword(t *testing.T, h *webhook.Handler) string {
+	t.Helper()
+	h.SetAPIToken("op-token")
+	req := httptest.NewRequest(http.MethodPost, "/admin/simulate", strings.NewReader(`{"repo":"o/r","user":"u","body":"hi"}`))
+	req.Header.Set("Authorization", "Bearer op-token")
+	w := httptest.NewRecorder()
+	h.Simulate(w, req)
+	var res webhook.SimulationResult
+	if err := json.Unmarshal(w.Body.Bytes(), &res); err != nil {
+		t.Fatalf("simulate: %v (%s)", err, w.Body.String())
+	}
+	return res.TriggerKeyword
+}
+
+func TestReloader_AppliesSafeChanges(t *testing.T) {
+	r, next, logs := newTestReloader(t)
+	updated := reloadConfig()
+	updated.TriggerKeyword = "/agent"
+	updated.ClaudeModel = "model-b"
+	updated.DispatcherMaxAttempts = 5
+	updated.DispatcherRetryInitial = time.Second
+	updated.Notify = notify.Config{Global: []notify.Endpoint{{Type: "slack", URL: "https://hooks.slack.test/x"}}}
+	updated.RepoAllowlist = []string{"enabled/*"}
+	updated.Port = 9000
+	updated.LogLevel = "debug"
+	*next = updated
+
+	if err := r.Reload(); err != nil {
+		t.Fatalf("Reload: %v", err)
+	}
+	if got := triggerKeyword(t, r.handler); got != "/agent" {
+		t.Fatalf("trigger keyword = %q, want /agent", got)
+	}
+	out := logs.String()
+	for _, want := range []string{
+		`applied="notifications, provider claude, trigger keyword /agent, repository allow/denylist, log level DEBUG, dispatcher retry policy"`,
+		"settings=PORT",
+	} {
+		if !strings.Contains(out, want) {
+			t.Errorf("log does not mention %q:\n%s", want, out)
+		}
+	}
+
+	// reloading the same configuration changes nothing but still warns
+	logs.Reset()
+	if err := r.Reload(); err != nil {
+		t.Fatalf("Reload: %v", err)
+	}
+	if out := logs.String(); !strings.Contains(out, "nothing to apply") || !strings.Contains(out, "PORT") {
+		t.Fatalf("unexpected log:\n%s", out)
+	}
+}
+
+func TestReloader_KeepsConfigurationOnError(t *testing.T) {
+	r, next, _ := newTestReloader(t)
+
+	*next = nil
+	if err := r.Reload(); err == nil {
+		t.Fatal("expected load error")
+	}
+
+	invalid := reloadConfig()
+	invalid.TriggerKeyword = "/agent"
+	invalid.Notify = notify.Config{Global: []notify.Endpoint{{Type: "pager", URL: "https://x"}}}
+	*next = invalid
+	if err := r.Reload(); err == nil {
+		t.Fatal("expected notifier error")
+	}
+	if got := triggerKeyword(t, r.handler); got != "/code" {
+		t.Fatalf("trigger keyword = %q; nothing should change when a reload fails", got)
+	}
+	if r.cfg.TriggerKeyword != "/code" {
+		t.Fatalf("reloader recorded a configuration it did not apply")
+	}
+}
+
+func TestReloader_RereadsPolicyFile(t *testing.T) {
+	r, next, logs := newTestReloader(t)
+	path := filepath.Join(t.TempDir(), "policy.json")
+	if err := os.WriteFile(path, []byte(`{"rules": [{"effect": "deny"}]}`), 0o600); err != nil {
+		t.Fatal(err)
+	}
+	updated := reloadConfig()
+	updated.PolicyFile = path
+	*next = updated
+	if err := r.Reload(); err != nil {
+		t.Fatalf("Reload: %v", err)
+	}
+	if !strings.Contains(logs.String(), "applied=\"authorization policy\"") {
+		t.Fatalf("unexpected log:\n%s", logs.String())
+	}
+
+	// the file changes while the configuration does not
+	logs.Reset()
+	if err := os.WriteFile(path, []byte(`{"default": "allow", "rules": []}`), 0o600); err != nil {
+		t.Fatal(err)
+	}
+	if err := r.Reload(); err != nil {
+		t.Fatalf("Reload: %v", err)
+	}
+	if !strings.Contains(logs.String(), "applied=\"authorization policy\"") {
+		t.Fatalf("unexpected log:\n%s", logs.String())
+	}
+
+	// a broken policy keeps the running one
+	if err := os.WriteFile(path, []byte(`{"rules": [{"effect": "allow", "when": "team"}]}`), 0o600); err != nil {
+		t.Fatal(err)
+	}
+	if err := r.Reload(); err == nil || !strings.Contains(err.Error(), `unknown variable "team"`) {
+		t.Fatalf("Reload = %v", err)
+	}
+}
+
+func TestReloader_RereadsRepoSettingsFile(t *testing.T) {
+	r, next, logs := newTestReloader(t)
+	path := filepath.Join(t.TempDir(), "repos.json")
+	if err := os.WriteFile(path, []byte(`{"o/r": {"trigger_keyword": "@claude"}}`), 0o600); err != nil {
+		t.Fatal(err)
+	}
+	updated := reloadConfig()
+	updated.RepoSettingsFile = path
+	*next = updated
+	if err := r.Reload(); err != nil {
+		t.Fatalf("Reload: %v", err)
+	}
+	if !strings.Contains(logs.String(), "applied=\"repository settings\"") {
+		t.Fatalf("unexpected log:\n%s", logs.String())
+	}
+	if got := triggerKeyword(t, r.handler); got != "@claude" {
+		t.Fatalf("trigger keyword of o/r = %q, want @claude", got)
+	}
+
+	if err := os.WriteFile(path, []byte(`{"o/r": {"trigger": "@claude"}}`), 0o600); err != nil {
+		t.Fatal(err)
+	}
+	if err := r.Reload(); err == nil || !strings.Contains(err.Error(), "unknown field") {
+		t.Fatalf("Reload = %v", err)
+	}
+	if got := triggerKeyword(t, r.handler); got != "@claude" {
+		t.Fatalf("trigger keyword of o/r = %q; a broken file keeps the running settings", got)
+	}
+}
+
+func TestReloader_RereadsSchedulesFile(t *testing.T) {
+	r, next, logs := newTestReloader(t)
+	r.scheduler = schedule.New(nil, r.handler.LaunchScheduled)
+	if err := r.Reload(); err != nil {
+		t.Fatalf("Reload: %v", err)
+	}
+	if !strings.Contains(logs.String(), "nothing to apply") {
+		t.Fatalf("no schedules before or after, yet:\n%s", logs.String())
+	}
+
+	path := filepath.Join(t.TempDir(), "schedules.json")
+	if err := os.WriteFile(path, []byte(`[{"name": "deps", "cron": "@weekly", "repo": "o/r", "prompt": "audit"}]`), 0o600); err != nil {
+		t.Fatal(err)
+	}
+	updated := reloadConfig()
+	updated.SchedulesFile = path
+	*next = updated
+	if err := r.Reload(); err != nil {
+		t.Fatalf("Reload: %v", err)
+	}
+	if !strings.Contains(logs.String(), "applied=\"1 scheduled jobs\"") {
+		t.Fatalf("unexpected log:\n%s", logs.String())
+	}
+
+	if err := os.WriteFile(path, []byte(`[{"name": "deps", "cron": "weekly", "repo": "o/r", "prompt": "audit"}]`), 0o600); err != nil {
+		t.Fatal(err)
+	}
+	if err := r.Reload(); err == nil || !strings.Contains(err.Error(), "want 5 fields") {
+		t.Fatalf("Reload = %v", err)
+	}
+	if jobs := r.scheduler.Jobs(); len(jobs) != 1 || jobs[0].Cron != "@weekly" {
+		t.Fatalf("jobs = %+v; a broken file keeps the running schedules", jobs)
+	}
+}
+
+func TestReloader_ProviderSwitchNeedsRestart(t *testing.T) {
+	r, next, logs := newTestReloader(t)
+	updated := reloadConfig()
+	updated.Provider = "codex"
+	updated.CodexModel = "gpt-test"
+	*next = updated
+
+	if err := r.Reload(); err != nil {
+		t.Fatalf("Reload: %v", err)
+	}
+	if out := logs.String(); strings.Contains(out, "provider codex") || !strings.Contains(out, "settings=PROVIDER") {
+		t.Fatalf("provider type must not be swapped live:\n%s", out)
+	}
+}
+
+func TestModTimes(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "config.yaml")
+	before := modTimes([]string{path, ""})
+	if err := os.WriteFile(path, []byte("port: 1\n"), 0o600); err != nil {
+		t.Fatal(err)
+	}
+	if modTimes([]string{path, ""}) == before {
+		t.Fatal("creating a watched file should change the fingerprint")
+	}
+}
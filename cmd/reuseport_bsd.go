@@ -0,0 +1,7 @@
+//go:build darwin || freebsd || netbsd || openbsd
+
+package main
+
+import "syscall"
+
+const soReusePort = syscall.SO_REUSEPORT
@@ -0,0 +1,12 @@
+package main
+
+import "runtime"
+
+// soReusePort is SO_REUSEPORT, which package syscall lacks on Linux.
+var soReusePort = func() int {
+	switch runtime.GOARCH {
+	case "mips", "mipsle", "mips64", "mips64le", "sparc64":
+		return 0x200
+	}
+	return 0xf
+}()
@@ -0,0 +1,14 @@
+//go:build !(linux || darwin || freebsd || netbsd || openbsd)
+
+package main
+
+import (
+	"fmt"
+	"runtime"
+	"syscall"
+)
+
+// reusePortControl fails: SO_REUSEPORT is not available on this platform.
+func reusePortControl(_, _ string, _ syscall.RawConn) error {
+	return fmt.Errorf("REUSE_PORT is not supported on %s", runtime.GOOS)
+}
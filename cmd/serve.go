@@ -0,0 +1,92 @@
+package main
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"net"
+	"net/http"
+	"os"
+	"strconv"
+	"time"
+)
+
+// shutdownTimeout bounds how long webhook requests in flight may take once
+// the server stops accepting connections.
+const shutdownTimeout = 30 * time.Second
+
+// listenOptions says where and how the HTTP server listens.
+type listenOptions struct {
+	Addr string
+	// ReusePort sets SO_REUSEPORT so a new version can bind Addr while
+	// this one drains
+	ReusePort bool
+}
+
+// listenAndServe serves handler until ctx is done, then stops accepting
+// connections and waits for the requests in flight.
+func listenAndServe(ctx context.Context, opts listenOptions, handler http.Handler) error {
+	ln, err := listen(opts)
+	if err != nil {
+		return err
+	}
+	srv := &http.Server{Handler: handler}
+	served := make(chan error, 1)
+	go func() { served <- srv.Serve(ln) }()
+
+	select {
+	case err := <-served:
+		return err
+	case <-ctx.Done():
+	}
+	shutdownCtx, cancel := context.WithTimeout(context.Background(), shutdownTimeout)
+	defer cancel()
+	if err := srv.Shutdown(shutdownCtx); err != nil {
+		return fmt.Errorf("stop accepting connections: %w", err)
+	}
+	if err := <-served; !errors.Is(err, http.ErrServerClosed) {
+		return err
+	}
+	return nil
+}
+
+// listenFDsStart is the first file descriptor passed by socket activation
+// (a variable so tests can pass another).
+var listenFDsStart uintptr = 3
+
+// listen uses the socket handed over by systemd socket activation when
+// there is one: it stays open across restarts, so connections made while no
+// version runs wait in its backlog instead of being refused. Otherwise it
+// binds opts.Addr.
+func listen(opts listenOptions) (net.Listener, error) {
+	if ln, err := inheritedListener(); ln != nil || err != nil {
+		return ln, err
+	}
+	var lc net.ListenConfig
+	if opts.ReusePort {
+		lc.Control = reusePortControl
+	}
+	return lc.Listen(context.Background(), "tcp", opts.Addr)
+}
+
+// inheritedListener returns the first socket passed in LISTEN_FDS, or nil.
+func inheritedListener() (net.Listener, error) {
+	if os.Getenv("LISTEN_PID") != strconv.Itoa(os.Getpid()) {
+		return nil, nil
+	}
+	n, err := strconv.Atoi(os.Getenv("LISTEN_FDS"))
+	if err != nil || n < 1 {
+		return nil, fmt.Errorf("invalid LISTEN_FDS %q", os.Getenv("LISTEN_FDS"))
+	}
+	// task processes must not take the socket for theirs
+	for _, key := range []string{"LISTEN_PID", "LISTEN_FDS", "LISTEN_FDNAMES"} {
+		_ = os.Unsetenv(key)
+	}
+	f := os.NewFile(listenFDsStart, "listen-fd")
+	defer f.Close()
+	ln, err := net.FileListener(f)
+	if err != nil {
+		return nil, fmt.Errorf("use inherited socket: %w", err)
+	}
+	return ln, nil
+}
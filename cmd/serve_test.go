@@ -0,0 +1,180 @@
+package main
+
+import (
+	"context"
+	"io"
+	"net"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"runtime"
+	"strconv"
+	"strings"
+	"testing"
+	"time"
+)
+
+func TestListenAndServe_FinishesRequestsInFlight(t *testing.T) {
+	ln, err := net.Listen("tcp", "127.0.0.1:0")
+	if err != nil {
+		t.Fatal(err)
+	}
+	addr := ln.Addr().String()
+	_ = ln.Close()
+
+	inFlight := make(chan struct{})
+	handler := http.HandlerFunc(func(w http.ResponseWriter, _ *http.Request) {
+		close(inFlight)
+		time.Sleep(50 * time.Millisecond)
+		_, _ = io.WriteString(w, "done")
+	})
+	ctx, cancel := context.WithCancel(context.Background())
+	served := make(chan error, 1)
+	go func() { served <- listenAndServe(ctx, listenOptions{Addr: addr}, handler) }()
+
+	body := make(chan string, 1)
+	go func() {
+		var resp *http.Response
+		for i := 0; i < 100; i++ {
+			if resp, err = http.Get("http://" + addr); err == nil {
+				break
+			}
+			time.Sleep(10 * time.Millisecond)
+		}
+		if err != nil {
+			body <- err.Error()
+			return
+		}
+		defer resp.Body.Close()
+		data, _ := io.ReadAll(resp.Body)
+		body <- string(data)
+	}()
+	<-inFlight
+	cancel()
+
+	if got := <-body; got != "done" {
+		t.Fatalf("response = %q, want the in-flight request to finish", got)
+	}
+	if err := <-served; err != nil {
+		t.Fatalf("listenAndServe = %v, want nil after shutdown", err)
+	}
+	if _, err := net.DialTimeout("tcp", addr, 100*time.Millisecond); err == nil {
+		t.Fatal("server still accepts connections after shutdown")
+	}
+}
+
+func TestListen_ReusePortSharesAddress(t *testing.T) {
+	if runtime.GOOS == "windows" {
+		t.Skip("SO_REUSEPORT is not supported on windows")
+	}
+	first, err := listen(listenOptions{Addr: "127.0.0.1:0", ReusePort: true})
+	if err != nil {
+		t.Fatalf("listen: %v", err)
+	}
+	defer first.Close()
+	addr := first.Addr().String()
+
+	second, err := listen(listenOptions{Addr: addr, ReusePort: true})
+	if err != nil {
+		t.Fatalf("second listen on %s with SO_REUSEPORT: %v", addr, err)
+	}
+	_ = second.Close()
+	if _, err := listen(listenOptions{Addr: addr}); err == nil {
+		t.Fatal("listen without SO_REUSEPORT should fail while the address is taken")
+	}
+}
+
+func TestListen_UsesInheritedSocket(t *testing.T) {
+	if runtime.GOOS == "windows" {
+		t.Skip("socket activation is systemd-only")
+	}
+	orig, err := net.Listen("tcp", "127.0.0.1:0")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer orig.Close()
+	f, err := orig.(*net.TCPListener).File()
+	if err != nil {
+		t.Fatal(err)
+	}
+	// listen takes over the duplicated descriptor
+	origFD := listenFDsStart
+	listenFDsStart = f.Fd()
+	t.Cleanup(func() { listenFDsStart = origFD })
+	t.Setenv("LISTEN_PID", strconv.Itoa(os.Getpid()))
+	t.Setenv("LISTEN_FDS", "1")
+
+	ln, err := listen(listenOptions{Addr: "127.0.0.1:1"})
+	if err != nil {
+		t.Fatalf("listen: %v", err)
+	}
+	if ln.Addr().String() != orig.Addr().String() {
+		t.Fatalf("listening on %s, want the inherited %s", ln.Addr(), orig.Addr())
+	}
+	if os.Getenv("LISTEN_FDS") != "" {
+		t.Fatal("LISTEN_FDS should be cleared for child processes")
+	}
+	_ = ln.Close()
+}
+
+func TestDrainer_Handle(t *testing.T) {
+	post := func(d *drainer, auth string) *httptest.ResponseRecorder {
+		req := httptest.NewRequest(http.MethodPost, "/admin/drain", nil)
+		if auth != "" {
+			req.Header.Set("Authorization", auth)
+		}
+		rec := httptest.NewRecorder()
+		d.Handle(rec, req)
+		return rec
+	}
+
+	if rec := post(newDrainer(""), "Bearer x"); rec.Code != http.StatusServiceUnavailable {
+		t.Fatalf("without API_TOKEN status = %d, want 503", rec.Code)
+	}
+	d := newDrainer("ops-token")
+	if rec := post(d, "Bearer wrong"); rec.Code != http.StatusUnauthorized || d.Draining() {
+		t.Fatalf("wrong token status = %d draining = %t", rec.Code, d.Draining())
+	}
+	rec := post(d, "Bearer ops-token")
+	if rec.Code != http.StatusAccepted || !strings.Contains(rec.Body.String(), `"status":"draining"`) {
+		t.Fatalf("drain = %d %s", rec.Code, rec.Body)
+	}
+	select {
+	case <-d.Done():
+	default:
+		t.Fatal("Done not closed after drain request")
+	}
+	if rec := post(d, "Bearer ops-token"); rec.Code != http.StatusAccepted {
+		t.Fatalf("repeated drain status = %d, want 202", rec.Code)
+	}
+}
+
+func TestRun_DrainStopsServing(t *testing.T) {
+	setRequiredEnv(t, "codex")
+	t.Setenv("API_TOKEN", "ops-token")
+	chdirToRepoRoot(t)
+
+	err := run(context.Background(), func(ctx context.Context, _ listenOptions, handler http.Handler) error {
+		req := httptest.NewRequest(http.MethodPost, "/admin/drain", nil)
+		req.Header.Set("Authorization", "Bearer ops-token")
+		rec := httptest.NewRecorder()
+		handler.ServeHTTP(rec, req)
+		if rec.Code != http.StatusAccepted {
+			t.Errorf("/admin/drain status = %d, want 202", rec.Code)
+		}
+		select {
+		case <-ctx.Done():
+		case <-time.After(2 * time.Second):
+			t.Error("serve context not cancelled after drain request")
+		}
+		rec = httptest.NewRecorder()
+		handler.ServeHTTP(rec, httptest.NewRequest(http.MethodGet, "/health", nil))
+		if rec.Code != http.StatusServiceUnavailable {
+			t.Errorf("/health while draining = %d, want 503", rec.Code)
+		}
+		return nil
+	})
+	if err != nil {
+		t.Fatalf("run() returned error: %v", err)
+	}
+}
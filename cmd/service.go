@@ -0,0 +1,270 @@
+package main
+
+import (
+	"bytes"
+	"errors"
+	"flag"
+	"fmt"
+	"io"
+	"os"
+	"os/exec"
+	"path/filepath"
+	"runtime"
+	"strings"
+	"text/template"
+)
+
+// serviceOptions configures `swe-agent install-service`.
+type serviceOptions struct {
+	Name        string
+	Description string
+	Binary      string
+	WorkingDir  string
+	EnvFile     string
+	User        string
+	Group       string
+	LogFile     string // empty routes logs to journald (systemd) or <workdir>\logs (Windows)
+	UnitDir     string
+	DryRun      bool
+	NoStart     bool
+}
+
+// allow tests to stub platform detection and service manager calls
+var (
+	serviceGOOS    = runtime.GOOS
+	serviceCommand = func(stdout io.Writer, name string, args ...string) error {
+		cmd := exec.Command(name, args...)
+		cmd.Stdout = stdout
+		cmd.Stderr = stdout
+		return cmd.Run()
+	}
+	serviceLookPath = exec.LookPath
+)
+
+func runInstallService(args []string, stdout, stderr io.Writer) int {
+	opts, err := parseServiceFlags(args, stderr)
+	if errors.Is(err, flag.ErrHelp) {
+		return 0
+	}
+	if err != nil {
+		_, _ = fmt.Fprintf(stderr, "install-service: %v\n", err)
+		return 2
+	}
+
+	if serviceGOOS == "windows" {
+		err = installWindowsService(opts, stdout)
+	} else {
+		err = installSystemdUnit(opts, stdout)
+	}
+	if err != nil {
+		_, _ = fmt.Fprintf(stderr, "install-service: %v\n", err)
+		return 1
+	}
+	return 0
+}
+
+func parseServiceFlags(args []string, stderr io.Writer) (serviceOptions, error) {
+	var opts serviceOptions
+	fs := flag.NewFlagSet("install-service", flag.ContinueOnError)
+	fs.SetOutput(stderr)
+	fs.StringVar(&opts.Name, "name", "swe-agent", "service name")
+	fs.StringVar(&opts.Description, "description", "SWE-Agent GitHub App server", "service description")
+	fs.StringVar(&opts.Binary, "binary", "", "path to the swe-agent binary (default: this executable)")
+	fs.StringVar(&opts.WorkingDir, "working-dir", "", "working directory containing templates/ (default: current directory)")
+	fs.StringVar(&opts.EnvFile, "env-file", "", "environment file with the service configuration (default: /etc/<name>/<name>.env, or <working-dir>\\.env on Windows)")
+	fs.StringVar(&opts.User, "user", "", "run the service as this user (systemd only)")
+	fs.StringVar(&opts.Group, "group", "", "run the service as this group (systemd only)")
+	fs.StringVar(&opts.LogFile, "log-file", "", "append logs to this file instead of journald")
+	fs.StringVar(&opts.UnitDir, "unit-dir", "/etc/systemd/system", "directory for the systemd unit file")
+	fs.BoolVar(&opts.DryRun, "dry-run", false, "print what would be installed without changing the system")
+	fs.BoolVar(&opts.NoStart, "no-start", false, "enable the service without starting it")
+	if err := fs.Parse(args); err != nil {
+		return opts, err
+	}
+	if fs.NArg() > 0 {
+		return opts, fmt.Errorf("unexpected arguments: %s", strings.Join(fs.Args(), " "))
+	}
+	if opts.Name == "" || strings.ContainsAny(opts.Name, " /\\") {
+		return opts, fmt.Errorf("invalid service name %q", opts.Name)
+	}
+
+	if opts.Binary == "" {
+		exe, err := os.Executable()
+		if err != nil {
+			return opts, fmt.Errorf("resolve executable: %w", err)
+		}
+		opts.Binary = exe
+	}
+	if opts.WorkingDir == "" {
+		wd, err := os.Getwd()
+		if err != nil {
+			return opts, fmt.Errorf("resolve working directory: %w", err)
+		}
+		opts.WorkingDir = wd
+	}
+	if opts.EnvFile == "" {
+		if serviceGOOS == "windows" {
+			opts.EnvFile = filepath.Join(opts.WorkingDir, ".env")
+		} else {
+			opts.EnvFile = fmt.Sprintf("/etc/%s/%s.env", opts.Name, opts.Name)
+		}
+	}
+
+	for _, p := range []*string{&opts.Binary, &opts.WorkingDir, &opts.EnvFile, &opts.LogFile} {
+		if *p == "" {
+			continue
+		}
+		abs, err := filepath.Abs(*p)
+		if err != nil {
+			return opts, fmt.Errorf("resolve %s: %w", *p, err)
+		}
+		*p = abs
+	}
+	return opts, nil
+}
+
+var systemdUnitTemplate = template.Must(template.New("unit").Funcs(template.FuncMap{
+	"quote": systemdQuote,
+}).Parse(`[Unit]
+Description={{.Description}}
+After=network-online.target
+Wants=network-online.target
+
+[Service]
+Type=simple
+{{- if .User}}
+User={{.User}}
+{{- end}}
+{{- if .Group}}
+Group={{.Group}}
+{{- end}}
+WorkingDirectory={{quote .WorkingDir}}
+EnvironmentFile={{quote .EnvFile}}
+ExecStart={{quote .Binary}}
+ExecReload=/bin/kill -HUP $MAINPID
+Restart=on-failure
+RestartSec=5
+# SIGTERM drains: queued and running tasks finish first (DRAIN_TIMEOUT_SECONDS)
+TimeoutStopSec=31min
+{{- if .LogFile}}
+StandardOutput=append:{{.LogFile}}
+StandardError=append:{{.LogFile}}
+{{- else}}
+StandardOutput=journal
+StandardError=journal
+{{- end}}
+SyslogIdentifier={{.Name}}
+NoNewPrivileges=true
+PrivateTmp=true
+LimitNOFILE=65536
+
+[Install]
+WantedBy=multi-user.target
+`))
+
+// renderSystemdUnit renders the unit file for opts.
+func renderSystemdUnit(opts serviceOptions) (string, error) {
+	var buf bytes.Buffer
+	if err := systemdUnitTemplate.Execute(&buf, opts); err != nil {
+		return "", fmt.Errorf("render unit: %w", err)
+	}
+	return buf.String(), nil
+}
+
+// systemdQuote quotes paths containing whitespace for unit directives.
+func systemdQuote(s string) string {
+	if !strings.ContainsAny(s, " \t") {
+		return s
+	}
+	return `"` + strings.ReplaceAll(s, `"`, `\"`) + `"`
+}
+
+func installSystemdUnit(opts serviceOptions, stdout io.Writer) error {
+	unit, err := renderSystemdUnit(opts)
+	if err != nil {
+		return err
+	}
+	unitPath := filepath.Join(opts.UnitDir, opts.Name+".service")
+	steps := [][]string{{"systemctl", "daemon-reload"}}
+	if opts.NoStart {
+		steps = append(steps, []string{"systemctl", "enable", opts.Name})
+	} else {
+		steps = append(steps, []string{"systemctl", "enable", "--now", opts.Name})
+	}
+
+	if opts.DryRun {
+		_, _ = fmt.Fprintf(stdout, "# %s\n%s\n", unitPath, unit)
+		for _, step := range steps {
+			_, _ = fmt.Fprintf(stdout, "$ %s\n", strings.Join(step, " "))
+		}
+		return nil
+	}
+
+	if _, err := os.Stat(opts.EnvFile); err != nil {
+		_, _ = fmt.Fprintf(stdout, "Warning: env file %s not found; create it before starting the service (see .env.example)\n", opts.EnvFile)
+	}
+	if err := os.WriteFile(unitPath, []byte(unit), 0o644); err != nil {
+		return fmt.Errorf("write unit %s: %w", unitPath, err)
+	}
+	_, _ = fmt.Fprintf(stdout, "Wrote %s\n", unitPath)
+
+	for _, step := range steps {
+		if err := serviceCommand(stdout, step[0], step[1:]...); err != nil {
+			return fmt.Errorf("%s: %w", strings.Join(step, " "), err)
+		}
+	}
+	_, _ = fmt.Fprintf(stdout, "Installed %s. Logs: %s\n", opts.Name, logHint(opts))
+	return nil
+}
+
+// installWindowsService registers the service through NSSM, which handles
+// service control and log file routing for plain console binaries.
+func installWindowsService(opts serviceOptions, stdout io.Writer) error {
+	logFile := opts.LogFile
+	if logFile == "" {
+		logFile = filepath.Join(opts.WorkingDir, "logs", opts.Name+".log")
+	}
+	steps := [][]string{
+		{"nssm", "install", opts.Name, opts.Binary},
+		{"nssm", "set", opts.Name, "DisplayName", opts.Description},
+		{"nssm", "set", opts.Name, "AppDirectory", opts.WorkingDir},
+		// run() loads ENV_FILE via godotenv, mirroring systemd's EnvironmentFile
+		{"nssm", "set", opts.Name, "AppEnvironmentExtra", "ENV_FILE=" + opts.EnvFile},
+		{"nssm", "set", opts.Name, "AppStdout", logFile},
+		{"nssm", "set", opts.Name, "AppStderr", logFile},
+		{"nssm", "set", opts.Name, "AppRotateFiles", "1"},
+		{"nssm", "set", opts.Name, "AppRotateBytes", "10485760"},
+		{"nssm", "set", opts.Name, "Start", "SERVICE_AUTO_START"},
+	}
+	if !opts.NoStart {
+		steps = append(steps, []string{"nssm", "start", opts.Name})
+	}
+
+	if opts.DryRun {
+		for _, step := range steps {
+			_, _ = fmt.Fprintf(stdout, "> %s\n", strings.Join(step, " "))
+		}
+		return nil
+	}
+
+	if _, err := serviceLookPath("nssm"); err != nil {
+		return fmt.Errorf("nssm not found in PATH; install it from https://nssm.cc or run with -dry-run to see the commands")
+	}
+	if err := os.MkdirAll(filepath.Dir(logFile), 0o755); err != nil {
+		return fmt.Errorf("create log dir: %w", err)
+	}
+	for _, step := range steps {
+		if err := serviceCommand(stdout, step[0], step[1:]...); err != nil {
+			return fmt.Errorf("%s: %w", strings.Join(step, " "), err)
+		}
+	}
+	_, _ = fmt.Fprintf(stdout, "Installed %s. Logs: %s\n", opts.Name, logFile)
+	return nil
+}
+
+func logHint(opts serviceOptions) string {
+	if opts.LogFile != "" {
+		return opts.LogFile
+	}
+	return "journalctl -u " + opts.Name + " -f"
+}
@@ -0,0 +1,156 @@
+package main
+
+import (
+	"bytes"
+	"errors"
+	"io"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+)
+
+func stubServiceCommands(t *testing.T, goos string) *[]string {
+	t.Helper()
+	origGOOS, origCmd, origLook := serviceGOOS, serviceCommand, serviceLookPath
+	t.Cleanup(func() {
+		serviceGOOS, serviceCommand, serviceLookPath = origGOOS, origCmd, origLook
+	})
+	serviceGOOS = goos
+	var calls []string
+	serviceCommand = func(_ io.Writer, name string, args ...string) error {
+		calls = append(calls, strings.Join(append([]string{name}, args...), " "))
+		return nil
+	}
+	serviceLookPath = func(name string) (string, error) { return "/usr/bin/" + name, nil }
+	return &calls
+}
+
+func TestRenderSystemdUnit(t *testing.T) {
+	unit, err := renderSystemdUnit(serviceOptions{
+		Name:        "swe-agent",
+		Description: "SWE-Agent",
+		Binary:      "/opt/swe agent/swe-agent",
+		WorkingDir:  "/opt/swe-agent",
+		EnvFile:     "/etc/swe-agent/swe-agent.env",
+		User:        "swe",
+	})
+	if err != nil {
+		t.Fatalf("renderSystemdUnit error: %v", err)
+	}
+	for _, want := range []string{
+		"User=swe\n",
+		"WorkingDirectory=/opt/swe-agent\n",
+		"EnvironmentFile=/etc/swe-agent/swe-agent.env\n",
+		`ExecStart="/opt/swe agent/swe-agent"` + "\n",
+		"ExecReload=/bin/kill -HUP $MAINPID\n",
+		"StandardOutput=journal\n",
+		"SyslogIdentifier=swe-agent\n",
+		"WantedBy=multi-user.target\n",
+	} {
+		if !strings.Contains(unit, want) {
+			t.Fatalf("unit missing %q:\n%s", want, unit)
+		}
+	}
+	if strings.Contains(unit, "Group=") {
+		t.Fatalf("unit should omit empty Group:\n%s", unit)
+	}
+
+	unit, _ = renderSystemdUnit(serviceOptions{Name: "x", LogFile: "/var/log/x.log"})
+	if !strings.Contains(unit, "StandardOutput=append:/var/log/x.log\n") {
+		t.Fatalf("log file not routed:\n%s", unit)
+	}
+}
+
+func TestInstallService_SystemdDryRun(t *testing.T) {
+	calls := stubServiceCommands(t, "linux")
+	var stdout, stderr bytes.Buffer
+
+	code := runInstallService([]string{"-dry-run", "-name", "bot", "-binary", "/usr/local/bin/swe-agent", "-working-dir", "/srv/bot"}, &stdout, &stderr)
+	if code != 0 {
+		t.Fatalf("exit code = %d, stderr: %s", code, stderr.String())
+	}
+	out := stdout.String()
+	for _, want := range []string{"# /etc/systemd/system/bot.service", "EnvironmentFile=/etc/bot/bot.env", "$ systemctl enable --now bot"} {
+		if !strings.Contains(out, want) {
+			t.Fatalf("dry run output missing %q:\n%s", want, out)
+		}
+	}
+	if len(*calls) != 0 {
+		t.Fatalf("dry run should not call systemctl, got %v", *calls)
+	}
+}
+
+func TestInstallService_SystemdInstall(t *testing.T) {
+	calls := stubServiceCommands(t, "linux")
+	unitDir := t.TempDir()
+	var stdout, stderr bytes.Buffer
+
+	code := runInstallService([]string{"-unit-dir", unitDir, "-no-start", "-binary", "/usr/local/bin/swe-agent"}, &stdout, &stderr)
+	if code != 0 {
+		t.Fatalf("exit code = %d, stderr: %s", code, stderr.String())
+	}
+	info, err := os.Stat(filepath.Join(unitDir, "swe-agent.service"))
+	if err != nil {
+		t.Fatalf("unit not written: %v", err)
+	}
+	if info.Mode().Perm() != 0o644 {
+		t.Fatalf("unit permissions = %o, want 644", info.Mode().Perm())
+	}
+	if got := strings.Join(*calls, "; "); got != "systemctl daemon-reload; systemctl enable swe-agent" {
+		t.Fatalf("systemctl calls = %s", got)
+	}
+	if !strings.Contains(stdout.String(), "journalctl -u swe-agent") {
+		t.Fatalf("expected log hint, got:\n%s", stdout.String())
+	}
+}
+
+func TestInstallService_SystemctlFailure(t *testing.T) {
+	stubServiceCommands(t, "linux")
+	serviceCommand = func(io.Writer, string, ...string) error { return errors.New("exit status 1") }
+	var stdout, stderr bytes.Buffer
+
+	code := runInstallService([]string{"-unit-dir", t.TempDir()}, &stdout, &stderr)
+	if code != 1 || !strings.Contains(stderr.String(), "systemctl daemon-reload") {
+		t.Fatalf("code = %d, stderr = %s", code, stderr.String())
+	}
+}
+
+func TestInstallService_Windows(t *testing.T) {
+	calls := stubServiceCommands(t, "windows")
+	workDir := t.TempDir()
+	var stdout, stderr bytes.Buffer
+
+	code := runInstallService([]string{"-working-dir", workDir, "-binary", filepath.Join(workDir, "swe-agent.exe")}, &stdout, &stderr)
+	if code != 0 {
+		t.Fatalf("exit code = %d, stderr: %s", code, stderr.String())
+	}
+	joined := strings.Join(*calls, "\n")
+	for _, want := range []string{
+		"nssm install swe-agent " + filepath.Join(workDir, "swe-agent.exe"),
+		"nssm set swe-agent AppEnvironmentExtra ENV_FILE=" + filepath.Join(workDir, ".env"),
+		"nssm set swe-agent AppStdout " + filepath.Join(workDir, "logs", "swe-agent.log"),
+		"nssm start swe-agent",
+	} {
+		if !strings.Contains(joined, want) {
+			t.Fatalf("nssm calls missing %q:\n%s", want, joined)
+		}
+	}
+
+	serviceLookPath = func(string) (string, error) { return "", errors.New("not found") }
+	stderr.Reset()
+	if code := runInstallService([]string{"-working-dir", workDir}, &stdout, &stderr); code != 1 || !strings.Contains(stderr.String(), "nssm not found") {
+		t.Fatalf("code = %d, stderr = %s", code, stderr.String())
+	}
+}
+
+func TestInstallService_InvalidFlags(t *testing.T) {
+	stubServiceCommands(t, "linux")
+	var stdout, stderr bytes.Buffer
+	if code := runInstallService([]string{"-name", "bad name"}, &stdout, &stderr); code != 2 {
+		t.Fatalf("exit code = %d, want 2", code)
+	}
+	if code := runInstallService([]string{"extra"}, &stdout, &stderr); code != 2 {
+		t.Fatalf("exit code = %d, want 2", code)
+	}
+}
@@ -0,0 +1,95 @@
+package main
+
+import (
+	"context"
+	"fmt"
+	"log/slog"
+	"time"
+
+	"github.com/cexll/swe/internal/artifacts"
+	"github.com/cexll/swe/internal/config"
+	"github.com/cexll/swe/internal/dispatcher"
+	"github.com/cexll/swe/internal/leader"
+	"github.com/cexll/swe/internal/logging"
+	"github.com/cexll/swe/internal/storage"
+)
+
+// cleanupInterval is how often the leader prunes expired artifacts, logs
+// and idempotency keys.
+const cleanupInterval = time.Hour
+
+// openBlobStores opens where task artifacts and offloaded task logs are
+// kept. Both share the STORAGE_BACKEND bucket or directory, under
+// artifacts/ and logs/; ARTIFACTS_STORAGE moves artifacts elsewhere. Either
+// store is nil when it has nowhere to go.
+func openBlobStores(cfg *config.Config) (arts, logs *artifacts.Store, err error) {
+	arts, err = artifacts.Open(cfg.ArtifactsStorage, cfg.ArtifactsRetention)
+	if err != nil {
+		return nil, nil, fmt.Errorf("artifact storage: %w", err)
+	}
+	if cfg.Storage.Backend == "" {
+		return arts, nil, nil
+	}
+	shared, err := storage.New(cfg.Storage)
+	if err != nil {
+		return nil, nil, err
+	}
+	if arts == nil {
+		arts = artifacts.New(storage.WithPrefix(shared, "artifacts"), cfg.ArtifactsRetention)
+	}
+	logs = artifacts.New(storage.WithPrefix(shared, "logs"), cfg.ArtifactsRetention)
+	return arts, logs, nil
+}
+
+// newElector returns the leader election among replicas sharing
+// STORAGE_BACKEND; without one this instance is always the leader.
+func newElector(cfg *config.Config) (*leader.Elector, error) {
+	if cfg.Storage.Backend == "" {
+		return leader.New(nil, cfg.LeaderLeaseTTL), nil
+	}
+	shared, err := storage.New(cfg.Storage)
+	if err != nil {
+		return nil, err
+	}
+	return leader.New(shared, cfg.LeaderLeaseTTL), nil
+}
+
+// sharedKeyStore returns where replicas sharing STORAGE_BACKEND keep the
+// idempotency keys of the tasks they accept, under idempotency/; it is nil
+// without one.
+func sharedKeyStore(cfg *config.Config) (storage.Backend, error) {
+	if cfg.Storage.Backend == "" {
+		return nil, nil
+	}
+	shared, err := storage.New(cfg.Storage)
+	if err != nil {
+		return nil, err
+	}
+	return storage.WithPrefix(shared, "idempotency"), nil
+}
+
+// pruneBlobStores deletes artifacts and logs past their retention.
+func pruneBlobStores(ctx context.Context, stores ...*artifacts.Store) {
+	for _, s := range stores {
+		n, err := s.Prune(ctx)
+		if err != nil {
+			slog.Warn("Pruning storage failed", logging.KeyPhase, "cleanup", "err", err)
+			continue
+		}
+		if n > 0 {
+			slog.Info("Pruned expired objects", logging.KeyPhase, "cleanup", "objects", n)
+		}
+	}
+}
+
+// pruneSharedKeys deletes the shared idempotency keys past journal.KeyTTL.
+func pruneSharedKeys(ctx context.Context, d *dispatcher.Dispatcher) {
+	n, err := d.PruneSharedKeys(ctx)
+	if err != nil {
+		slog.Warn("Pruning idempotency keys failed", logging.KeyPhase, "cleanup", "err", err)
+		return
+	}
+	if n > 0 {
+		slog.Info("Pruned expired idempotency keys", logging.KeyPhase, "cleanup", "keys", n)
+	}
+}
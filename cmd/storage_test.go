@@ -0,0 +1,44 @@
+package main
+
+import (
+	"context"
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/cexll/swe/internal/config"
+	"github.com/cexll/swe/internal/storage"
+)
+
+func TestOpenBlobStores(t *testing.T) {
+	if arts, logs, err := openBlobStores(&config.Config{}); arts != nil || logs != nil || err != nil {
+		t.Fatalf("nothing configured = %v, %v, %v", arts, logs, err)
+	}
+
+	dir := t.TempDir()
+	cfg := &config.Config{Storage: storage.Config{Backend: storage.KindLocal, Dir: dir}}
+	arts, logs, err := openBlobStores(cfg)
+	if err != nil || arts == nil || logs == nil {
+		t.Fatalf("shared storage = %v, %v, %v", arts, logs, err)
+	}
+	ctx := context.Background()
+	_ = arts.Save(ctx, "task-1", "diff.patch", []byte("d"))
+	_ = logs.Save(ctx, "task-1", "error.log", []byte("e"))
+	for _, p := range []string{"artifacts/task-1/diff.patch", "logs/task-1/error.log"} {
+		if _, err := os.Stat(filepath.Join(dir, p)); err != nil {
+			t.Errorf("%s: %v", p, err)
+		}
+	}
+
+	other := t.TempDir()
+	cfg.ArtifactsStorage = other
+	arts, _, _ = openBlobStores(cfg)
+	_ = arts.Save(ctx, "task-1", "diff.patch", []byte("d"))
+	if _, err := os.Stat(filepath.Join(other, "task-1", "diff.patch")); err != nil {
+		t.Errorf("ARTIFACTS_STORAGE not used: %v", err)
+	}
+
+	if _, _, err := openBlobStores(&config.Config{Storage: storage.Config{Backend: "ftp"}}); err == nil {
+		t.Error("unknown backend accepted")
+	}
+}
@@ -0,0 +1,146 @@
+package main
+
+import (
+	"context"
+	"fmt"
+	"os"
+	"strconv"
+
+	"github.com/cexll/swe/internal/github"
+	"github.com/cexll/swe/internal/github/comment"
+	"github.com/cexll/swe/internal/version"
+	"github.com/modelcontextprotocol/go-sdk/mcp"
+)
+
+// registerCommentTools registers the tools served by the comment subcommand.
+func registerCommentTools(server *mcp.Server) {
+	tool := &mcp.Tool{
+		Name:        "update_claude_comment",
+		Description: "Update the Claude comment with progress and results (automatically handles both issue and PR comments)",
+	}
+	mcp.AddTool(server, tool, HandleUpdateComment)
+	logger.Info("Registered tool", "tool", tool.Name)
+}
+
+// UpdateCommentParams defines the input parameters for the tool
+// Corresponds to TypeScript: { body: z.string() }
+type UpdateCommentParams struct {
+	Body string `json:"body" jsonschema:"The updated comment content"`
+}
+
+// HandleUpdateComment handles the update_claude_comment tool call
+// Corresponds to TypeScript: async ({ body }) => {...}
+func HandleUpdateComment(
+	_ context.Context,
+	_ *mcp.CallToolRequest,
+	params UpdateCommentParams,
+) (*mcp.CallToolResult, any, error) {
+	logger.Info("Received tool call", "tool", "update_claude_comment")
+
+	// 1. Read shared configuration from environment variables (process.env in TypeScript)
+	cfg, err := loadConfig("CLAUDE_COMMENT_ID")
+	if err != nil {
+		return nil, nil, err
+	}
+	owner, repo, token, eventName := cfg.Owner, cfg.Repo, cfg.Token, cfg.EventName
+	commentIDStr := os.Getenv("CLAUDE_COMMENT_ID")
+
+	// 2. Validate parameters
+	if params.Body == "" {
+		return nil, nil, fmt.Errorf("body parameter is required")
+	}
+
+	// 3. Parse comment ID
+	commentID, err := strconv.ParseInt(commentIDStr, 10, 64)
+	if err != nil {
+		logger.Error("Invalid CLAUDE_COMMENT_ID", "err", err)
+		return nil, nil, fmt.Errorf("invalid CLAUDE_COMMENT_ID: %w", err)
+	}
+
+	// 4. Content sanitization (corresponds to TypeScript sanitizeContent)
+	// Note: Go version simplified for now, can add sanitizer later
+	// The version footer, or the operator's layout, survives the provider
+	// rewriting the comment.
+	sanitizedBody := withLayout(params.Body, owner+"/"+repo)
+	// So does the history of earlier runs in a comment reused across runs.
+	if os.Getenv("SWE_COMMENT_HISTORY") != "" {
+		old, err := github.GetComment(owner, repo, commentID, token)
+		if err != nil {
+			logger.Warn("Reading comment history failed", "comment_id", commentID, "err", err)
+		} else {
+			sanitizedBody = comment.CarryHistory(sanitizedBody, old)
+		}
+	}
+	logger.Info("Updating comment", "comment_id", commentID, "chars", len(sanitizedBody))
+
+	// 5. Call GitHub API to update comment
+	// Corresponds to TypeScript: updateClaudeComment(octokit, {...})
+	if err := github.UpdateComment(owner, repo, commentID, sanitizedBody, token); err != nil {
+		logger.Error("Updating comment failed", "comment_id", commentID, "err", err)
+
+		// Return error result (corresponds to TypeScript isError: true)
+		return &mcp.CallToolResult{
+			Content: []mcp.Content{
+				&mcp.TextContent{
+					Text: fmt.Sprintf("Error: %v", err),
+				},
+			},
+			IsError: true,
+		}, nil, nil
+	}
+
+	// 6. Construct success response
+	// Corresponds to TypeScript: { content: [{ type: "text", text: JSON.stringify(result) }] }
+	resultText := fmt.Sprintf(`{
+  "success": true,
+  "owner": "%s",
+  "repo": "%s",
+  "comment_id": %d,
+  "event_name": "%s",
+  "body_length": %d
+}`, owner, repo, commentID, eventName, len(sanitizedBody))
+
+	logger.Info("Updated comment", "comment_id", commentID)
+
+	return &mcp.CallToolResult{
+		Content: []mcp.Content{
+			&mcp.TextContent{Text: resultText},
+		},
+	}, nil, nil
+}
+
+// withLayout adds the footer to body, or the header and footer of the layout
+// in SWE_COMMENT_TEMPLATE when one is set and loads.
+func withLayout(body, repo string) string {
+	path := os.Getenv("SWE_COMMENT_TEMPLATE")
+	if path == "" {
+		return comment.WithFooter(body, footerVersion())
+	}
+	layout, err := comment.LoadLayout(path)
+	if err != nil {
+		logger.Warn("Ignoring layout template", "err", err)
+	}
+	number, _ := strconv.Atoi(os.Getenv("SWE_ISSUE_NUMBER"))
+	return layout.Render(body, comment.LayoutData{
+		Repo:    repo,
+		Number:  number,
+		TaskID:  os.Getenv("SWE_TASK_ID"),
+		Version: footerVersion(),
+		Lang:    comment.Lang(os.Getenv("SWE_COMMENT_LANG")),
+	})
+}
+
+// footerVersion is the version shown in the comment footer: the server's
+// (SWE_AGENT_VERSION), followed by this binary's when the two differ.
+func footerVersion() string {
+	own := version.Short()
+	server := os.Getenv("SWE_AGENT_VERSION")
+	switch {
+	case server == "":
+		return own
+	case server != own:
+		return server + " · swe-mcp " + own
+	default:
+		return server
+	}
+}
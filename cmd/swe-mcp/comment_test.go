@@ -3,6 +3,8 @@ package main
 import (
 	"context"
 	"os"
+	"path/filepath"
+	"strings"
 	"testing"
 )
 
@@ -74,3 +76,21 @@ func setupTestEnv(t *testing.T) {
 	os.Setenv("GITHUB_TOKEN", "test-token")
 	os.Setenv("GITHUB_EVENT_NAME", "issue_comment")
 }
+
+func TestWithLayout(t *testing.T) {
+	t.Setenv("SWE_AGENT_VERSION", "")
+	t.Setenv("SWE_COMMENT_TEMPLATE", "")
+	if got := withLayout("Done.", "acme/api"); !strings.HasPrefix(got, "Done.\n\n<!-- swe-agent:version -->\n<sub>swe-agent ") {
+		t.Fatalf("without a layout = %q", got)
+	}
+
+	path := filepath.Join(t.TempDir(), "comment.tmpl")
+	if err := os.WriteFile(path, []byte(`{{define "header"}}Acme bot: {{.Repo}}#{{.Number}}{{end}}{{define "footer"}}{{end}}`), 0o644); err != nil {
+		t.Fatal(err)
+	}
+	t.Setenv("SWE_COMMENT_TEMPLATE", path)
+	t.Setenv("SWE_ISSUE_NUMBER", "7")
+	if got := withLayout("Done.", "acme/api"); got != "<!-- swe-agent:header -->\nAcme bot: acme/api#7\n<!-- /swe-agent:header -->\n\nDone." {
+		t.Fatalf("with a layout = %q", got)
+	}
+}
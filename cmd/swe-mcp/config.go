@@ -0,0 +1,33 @@
+package main
+
+import (
+	"fmt"
+	"os"
+)
+
+// sharedEnv is the auth/config environment every subcommand needs.
+var sharedEnv = []string{"GITHUB_TOKEN", "REPO_OWNER", "REPO_NAME"}
+
+// config holds the shared auth/config read from the environment.
+type config struct {
+	Token     string
+	Owner     string
+	Repo      string
+	EventName string
+}
+
+// loadConfig reads the shared environment and validates it together with
+// any subcommand-specific variables.
+func loadConfig(extra ...string) (*config, error) {
+	for _, env := range append(append([]string{}, sharedEnv...), extra...) {
+		if os.Getenv(env) == "" {
+			return nil, fmt.Errorf("missing required environment variable: %s", env)
+		}
+	}
+	return &config{
+		Token:     os.Getenv("GITHUB_TOKEN"),
+		Owner:     os.Getenv("REPO_OWNER"),
+		Repo:      os.Getenv("REPO_NAME"),
+		EventName: os.Getenv("GITHUB_EVENT_NAME"),
+	}, nil
+}
@@ -0,0 +1,147 @@
+// swe-mcp bundles the MCP servers spawned by provider CLIs into one binary.
+// Each server is a subcommand sharing the same auth/config environment:
+//
+//	swe-mcp comment   # update_claude_comment tool (coordinating comment)
+//	swe-mcp review    # reply to and resolve the pull request's review threads
+package main
+
+import (
+	"context"
+	"fmt"
+	"io"
+	"log/slog"
+	"os"
+	"os/signal"
+	"sort"
+	"syscall"
+
+	"github.com/modelcontextprotocol/go-sdk/mcp"
+
+	"github.com/cexll/swe/internal/logging"
+	"github.com/cexll/swe/internal/version"
+)
+
+// subcommand describes one MCP server hosted by this binary.
+type subcommand struct {
+	name        string
+	description string
+	// requiredEnv lists variables beyond the shared auth env (GITHUB_TOKEN, REPO_OWNER, REPO_NAME).
+	requiredEnv []string
+	register    func(server *mcp.Server)
+}
+
+var subcommands = map[string]subcommand{
+	"comment": {
+		name:        "comment",
+		description: "Coordinating comment updater (update_claude_comment)",
+		requiredEnv: []string{"CLAUDE_COMMENT_ID"},
+		register:    registerCommentTools,
+	},
+	"review": {
+		name:        "review",
+		description: "Pull request review threads (list, reply to, resolve, unresolve)",
+		requiredEnv: []string{"PR_NUMBER"},
+		register:    registerReviewTools,
+	},
+}
+
+// logger writes to stderr, as stdout carries the MCP protocol; run tags it
+// with the subcommand being served.
+var logger = slog.New(logging.NewHandler(os.Stderr, logging.FormatText))
+
+// allow tests to stub the transport
+var runServer = func(ctx context.Context, server *mcp.Server) error {
+	return server.Run(ctx, &mcp.StdioTransport{})
+}
+
+func main() {
+	os.Exit(run(os.Args[1:], os.Stderr))
+}
+
+func run(args []string, stderr io.Writer) int {
+	if len(args) == 0 {
+		usage(stderr)
+		return 2
+	}
+
+	switch args[0] {
+	case "help", "-h", "--help":
+		usage(stderr)
+		return 0
+	case "version", "--version":
+		_, _ = fmt.Fprintf(stderr, "swe-mcp %s\n", version.Get().Short())
+		return 0
+	}
+
+	cmd, ok := subcommands[args[0]]
+	if !ok {
+		_, _ = fmt.Fprintf(stderr, "unknown subcommand: %s\n\n", args[0])
+		usage(stderr)
+		return 2
+	}
+	logger = newLogger(stderr, cmd.name)
+
+	cfg, err := loadConfig(cmd.requiredEnv...)
+	if err != nil {
+		logger.Error("Invalid configuration", "err", err)
+		return 1
+	}
+
+	logger.Info("Starting", "version", version.Get().Short(), logging.KeyRepo, cfg.Owner+"/"+cfg.Repo)
+
+	server := mcp.NewServer(&mcp.Implementation{
+		Name:    "swe-mcp-" + cmd.name,
+		Version: version.Get().Version,
+	}, nil)
+	cmd.register(server)
+
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+
+	sigChan := make(chan os.Signal, 1)
+	signal.Notify(sigChan, os.Interrupt, syscall.SIGTERM)
+	defer signal.Stop(sigChan)
+	go func() {
+		select {
+		case <-sigChan:
+			logger.Info("Received shutdown signal")
+			cancel()
+		case <-ctx.Done():
+		}
+	}()
+
+	logger.Info("Serving on stdio")
+	if err := runServer(ctx, server); err != nil {
+		logger.Error("Server failed", "err", err)
+		return 1
+	}
+	logger.Info("Server stopped")
+	return 0
+}
+
+// newLogger returns the logger of subcommand name, writing to w in the
+// LOG_FORMAT the agent runs with (text when unset).
+func newLogger(w io.Writer, name string) *slog.Logger {
+	format := os.Getenv("LOG_FORMAT")
+	if !logging.ValidFormat(format) {
+		format = logging.FormatText
+	}
+	return slog.New(logging.NewHandler(w, format)).With("subcommand", name)
+}
+
+func usage(w io.Writer) {
+	_, _ = fmt.Fprintln(w, "Usage: swe-mcp <subcommand>")
+	_, _ = fmt.Fprintln(w, "")
+	_, _ = fmt.Fprintln(w, "Subcommands:")
+	names := make([]string, 0, len(subcommands))
+	for name := range subcommands {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+	for _, name := range names {
+		_, _ = fmt.Fprintf(w, "  %-10s %s\n", name, subcommands[name].description)
+	}
+	_, _ = fmt.Fprintf(w, "  %-10s %s\n", "version", "Print version")
+	_, _ = fmt.Fprintln(w, "")
+	_, _ = fmt.Fprintln(w, "Shared environment: GITHUB_TOKEN, REPO_OWNER, REPO_NAME (GITHUB_EVENT_NAME optional)")
+}
@@ -0,0 +1,100 @@
+package main
+
+import (
+	"bytes"
+	"context"
+	"strings"
+	"testing"
+
+	"github.com/modelcontextprotocol/go-sdk/mcp"
+
+	"github.com/cexll/swe/internal/version"
+)
+
+func TestRun_UsageAndVersion(t *testing.T) {
+	var buf bytes.Buffer
+	if code := run(nil, &buf); code != 2 {
+		t.Fatalf("run() without args = %d, want 2", code)
+	}
+	if !strings.Contains(buf.String(), "comment") {
+		t.Fatalf("usage should list subcommands, got %q", buf.String())
+	}
+
+	buf.Reset()
+	if code := run([]string{"help"}, &buf); code != 0 {
+		t.Fatalf("run(help) = %d, want 0", code)
+	}
+
+	buf.Reset()
+	if code := run([]string{"version"}, &buf); code != 0 || !strings.Contains(buf.String(), version.Get().Version) {
+		t.Fatalf("run(version) = %d, output %q", code, buf.String())
+	}
+}
+
+func TestRun_UnknownSubcommand(t *testing.T) {
+	var buf bytes.Buffer
+	if code := run([]string{"bogus"}, &buf); code != 2 {
+		t.Fatalf("run(bogus) = %d, want 2", code)
+	}
+	if !strings.Contains(buf.String(), "unknown subcommand: bogus") {
+		t.Fatalf("unexpected output %q", buf.String())
+	}
+}
+
+func TestRun_MissingSharedEnv(t *testing.T) {
+	t.Setenv("GITHUB_TOKEN", "")
+	t.Setenv("REPO_OWNER", "owner")
+	t.Setenv("REPO_NAME", "repo")
+	t.Setenv("CLAUDE_COMMENT_ID", "1")
+	t.Setenv("LOG_FORMAT", "")
+
+	var buf bytes.Buffer
+	if code := run([]string{"comment"}, &buf); code != 1 {
+		t.Fatalf("run(comment) without token = %d, want 1", code)
+	}
+	if out := buf.String(); !strings.Contains(out, "level=ERROR") || !strings.Contains(out, "subcommand=comment") || !strings.Contains(out, "GITHUB_TOKEN") {
+		t.Fatalf("stderr = %q", out)
+	}
+}
+
+func TestRun_CommentSubcommand(t *testing.T) {
+	t.Setenv("GITHUB_TOKEN", "token")
+	t.Setenv("REPO_OWNER", "owner")
+	t.Setenv("REPO_NAME", "repo")
+	t.Setenv("CLAUDE_COMMENT_ID", "1")
+
+	prev := runServer
+	t.Cleanup(func() { runServer = prev })
+	called := false
+	runServer = func(ctx context.Context, server *mcp.Server) error {
+		called = server != nil
+		return nil
+	}
+
+	if code := run([]string{"comment"}, &bytes.Buffer{}); code != 0 {
+		t.Fatalf("run(comment) = %d, want 0", code)
+	}
+	if !called {
+		t.Fatal("expected MCP server to run")
+	}
+}
+
+func TestLoadConfig(t *testing.T) {
+	t.Setenv("GITHUB_TOKEN", "token")
+	t.Setenv("REPO_OWNER", "owner")
+	t.Setenv("REPO_NAME", "repo")
+	t.Setenv("GITHUB_EVENT_NAME", "issue_comment")
+	t.Setenv("EXTRA_VAR", "")
+
+	cfg, err := loadConfig()
+	if err != nil {
+		t.Fatalf("loadConfig error: %v", err)
+	}
+	if cfg.Token != "token" || cfg.Owner != "owner" || cfg.Repo != "repo" || cfg.EventName != "issue_comment" {
+		t.Fatalf("unexpected config: %+v", cfg)
+	}
+
+	if _, err := loadConfig("EXTRA_VAR"); err == nil || !strings.Contains(err.Error(), "EXTRA_VAR") {
+		t.Fatalf("expected missing EXTRA_VAR error, got %v", err)
+	}
+}
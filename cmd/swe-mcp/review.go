@@ -0,0 +1,187 @@
+package main
+
+import (
+	"context"
+	"fmt"
+	"os"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/modelcontextprotocol/go-sdk/mcp"
+
+	"github.com/cexll/swe/internal/github"
+	ghdata "github.com/cexll/swe/internal/github/data"
+)
+
+// reviewThreadsAPI is the part of *ghdata.Client the review tools use.
+type reviewThreadsAPI interface {
+	UnresolvedReviewThreads(ctx context.Context, repo string, number int) ([]ghdata.ReviewThread, error)
+	ReviewThreadPullRequest(ctx context.Context, repo, threadID string) (string, int, error)
+	ReplyToReviewThread(ctx context.Context, repo, threadID, body string) error
+	ResolveReviewThread(ctx context.Context, repo, threadID string) error
+	UnresolveReviewThread(ctx context.Context, repo, threadID string) error
+}
+
+// allow tests to stub the GitHub API
+var newReviewClient = func(token string) reviewThreadsAPI {
+	return ghdata.NewClient(staticToken(token))
+}
+
+// staticToken authenticates with the task's installation token, which the
+// server is handed rather than minting its own.
+type staticToken string
+
+func (t staticToken) GetInstallationToken(string) (*github.InstallationToken, error) {
+	return &github.InstallationToken{Token: string(t), ExpiresAt: time.Now().Add(time.Hour)}, nil
+}
+
+func (t staticToken) GetInstallationOwner(repo string) (string, error) {
+	owner, _, _ := strings.Cut(repo, "/")
+	return owner, nil
+}
+
+// registerReviewTools registers the tools served by the review subcommand.
+func registerReviewTools(server *mcp.Server) {
+	mcp.AddTool(server, &mcp.Tool{
+		Name:        "list_review_threads",
+		Description: "List the unresolved review threads of the pull request being worked on, with the thread IDs the other review tools take",
+	}, HandleListReviewThreads)
+	mcp.AddTool(server, &mcp.Tool{
+		Name:        "reply_to_review_thread",
+		Description: "Reply in a review thread of the pull request being worked on",
+	}, HandleReplyToReviewThread)
+	mcp.AddTool(server, &mcp.Tool{
+		Name:        "resolve_review_thread",
+		Description: "Mark a review thread of the pull request being worked on resolved, once its feedback is addressed",
+	}, HandleResolveReviewThread)
+	mcp.AddTool(server, &mcp.Tool{
+		Name:        "unresolve_review_thread",
+		Description: "Reopen a review thread of the pull request being worked on that was resolved by mistake",
+	}, HandleUnresolveReviewThread)
+	logger.Info("Registered tools", "tools", "list_review_threads, reply_to_review_thread, resolve_review_thread, unresolve_review_thread")
+}
+
+// ListReviewThreadsParams defines the (empty) input of list_review_threads.
+type ListReviewThreadsParams struct{}
+
+// HandleListReviewThreads handles the list_review_threads tool call.
+func HandleListReviewThreads(ctx context.Context, _ *mcp.CallToolRequest, _ ListReviewThreadsParams) (*mcp.CallToolResult, any, error) {
+	logger.Info("Received tool call", "tool", "list_review_threads")
+	repo, number, err := reviewScope()
+	if err != nil {
+		return nil, nil, err
+	}
+	threads, err := newReviewClient(os.Getenv("GITHUB_TOKEN")).UnresolvedReviewThreads(ctx, repo, number)
+	if err != nil {
+		return toolError("list_review_threads", err), nil, nil
+	}
+	if len(threads) == 0 {
+		return toolText(fmt.Sprintf("%s#%d has no unresolved review threads.", repo, number)), nil, nil
+	}
+	// FormatReviewThreads numbers the threads; the IDs follow in the same order.
+	var ids strings.Builder
+	for i, t := range threads {
+		fmt.Fprintf(&ids, "\n%d. %s", i+1, t.ID)
+	}
+	return toolText(ghdata.FormatReviewThreads(threads) + "\n\nThread IDs:" + ids.String()), nil, nil
+}
+
+// ReviewThreadParams identifies the review thread a tool acts on.
+type ReviewThreadParams struct {
+	ThreadID string `json:"thread_id" jsonschema:"The review thread's node ID (e.g. PRRT_...)"`
+}
+
+// ReplyToReviewThreadParams defines the input parameters for reply_to_review_thread.
+type ReplyToReviewThreadParams struct {
+	ThreadID string `json:"thread_id" jsonschema:"The review thread's node ID (e.g. PRRT_...)"`
+	Body     string `json:"body" jsonschema:"The reply content"`
+}
+
+// HandleReplyToReviewThread handles the reply_to_review_thread tool call.
+func HandleReplyToReviewThread(ctx context.Context, _ *mcp.CallToolRequest, params ReplyToReviewThreadParams) (*mcp.CallToolResult, any, error) {
+	if params.Body == "" {
+		return nil, nil, fmt.Errorf("body parameter is required")
+	}
+	return onReviewThread(ctx, "reply_to_review_thread", params.ThreadID, func(client reviewThreadsAPI, repo string) error {
+		return client.ReplyToReviewThread(ctx, repo, params.ThreadID, github.FilterOutput(github.SanitizeContent(params.Body), os.Getenv("GITHUB_TOKEN")))
+	})
+}
+
+// HandleResolveReviewThread handles the resolve_review_thread tool call.
+func HandleResolveReviewThread(ctx context.Context, _ *mcp.CallToolRequest, params ReviewThreadParams) (*mcp.CallToolResult, any, error) {
+	return onReviewThread(ctx, "resolve_review_thread", params.ThreadID, func(client reviewThreadsAPI, repo string) error {
+		return client.ResolveReviewThread(ctx, repo, params.ThreadID)
+	})
+}
+
+// HandleUnresolveReviewThread handles the unresolve_review_thread tool call.
+func HandleUnresolveReviewThread(ctx context.Context, _ *mcp.CallToolRequest, params ReviewThreadParams) (*mcp.CallToolResult, any, error) {
+	return onReviewThread(ctx, "unresolve_review_thread", params.ThreadID, func(client reviewThreadsAPI, repo string) error {
+		return client.UnresolveReviewThread(ctx, repo, params.ThreadID)
+	})
+}
+
+// onReviewThread runs act on review thread threadID once it is confirmed to
+// belong to the pull request the server was started for (REPO_OWNER,
+// REPO_NAME, PR_NUMBER): the model only ever hands over an ID, and one from
+// another pull request or repository the token reaches must not be touched.
+func onReviewThread(ctx context.Context, tool, threadID string, act func(client reviewThreadsAPI, repo string) error) (*mcp.CallToolResult, any, error) {
+	logger.Info("Received tool call", "tool", tool, "thread", threadID)
+	repo, number, err := reviewScope()
+	if err != nil {
+		return nil, nil, err
+	}
+	if threadID == "" {
+		return nil, nil, fmt.Errorf("thread_id parameter is required")
+	}
+	client := newReviewClient(os.Getenv("GITHUB_TOKEN"))
+
+	threadRepo, threadPR, err := client.ReviewThreadPullRequest(ctx, repo, threadID)
+	if err == nil && (!strings.EqualFold(threadRepo, repo) || threadPR != number) {
+		err = fmt.Errorf("review thread %s belongs to %s#%d, not %s#%d", threadID, threadRepo, threadPR, repo, number)
+	}
+	if err == nil {
+		err = act(client, repo)
+	}
+	if err != nil {
+		return toolError(tool, err), nil, nil
+	}
+
+	logger.Info("Tool call succeeded", "tool", tool, "thread", threadID)
+	return toolText(fmt.Sprintf(`{
+  "success": true,
+  "repository": "%s",
+  "pr_number": %d,
+  "thread_id": "%s"
+}`, repo, number, threadID)), nil, nil
+}
+
+// reviewScope returns the repository ("owner/repo") and pull request number
+// the server was started for.
+func reviewScope() (string, int, error) {
+	cfg, err := loadConfig("PR_NUMBER")
+	if err != nil {
+		return "", 0, err
+	}
+	number, err := strconv.Atoi(os.Getenv("PR_NUMBER"))
+	if err != nil || number <= 0 {
+		return "", 0, fmt.Errorf("invalid PR_NUMBER: %q", os.Getenv("PR_NUMBER"))
+	}
+	return cfg.Owner + "/" + cfg.Repo, number, nil
+}
+
+func toolText(text string) *mcp.CallToolResult {
+	return &mcp.CallToolResult{Content: []mcp.Content{&mcp.TextContent{Text: text}}}
+}
+
+// toolError reports a failed GitHub call as a tool error the model can read.
+func toolError(tool string, err error) *mcp.CallToolResult {
+	logger.Warn("Tool call failed", "tool", tool, "err", err)
+	return &mcp.CallToolResult{
+		Content: []mcp.Content{
+			&mcp.TextContent{Text: fmt.Sprintf("Error: %v", err)},
+		},
+		IsError: true,
+	}
+}
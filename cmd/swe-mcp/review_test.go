@@ -0,0 +1,127 @@
+package main
+
+import (
+	"context"
+	"errors"
+	"strings"
+	"testing"
+
+	"github.com/modelcontextprotocol/go-sdk/mcp"
+
+	ghdata "github.com/cexll/swe/internal/github/data"
+)
+
+// fakeReviewAPI places thread "T1" on owner/repo#7, "T2" on owner/repo#8
+// and "T3" in another repository.
+type fakeReviewAPI struct {
+	calls []string
+}
+
+func (f *fakeReviewAPI) UnresolvedReviewThreads(_ context.Context, repo string, number int) ([]ghdata.ReviewThread, error) {
+	var t ghdata.ReviewThread
+	t.ID, t.Path = "T1", "app.go"
+	t.Comments.Nodes = []ghdata.Comment{{Body: "nit", Author: ghdata.Author{Login: "rev"}}}
+	return []ghdata.ReviewThread{t}, nil
+}
+
+func (f *fakeReviewAPI) ReviewThreadPullRequest(_ context.Context, _, threadID string) (string, int, error) {
+	switch threadID {
+	case "T1":
+		return "Owner/Repo", 7, nil
+	case "T2":
+		return "owner/repo", 8, nil
+	case "T3":
+		return "other/repo", 7, nil
+	}
+	return "", 0, errors.New("review thread " + threadID + " not found")
+}
+
+func (f *fakeReviewAPI) ReplyToReviewThread(_ context.Context, repo, threadID, body string) error {
+	f.calls = append(f.calls, "reply "+repo+" "+threadID+": "+body)
+	return nil
+}
+
+func (f *fakeReviewAPI) ResolveReviewThread(_ context.Context, repo, threadID string) error {
+	f.calls = append(f.calls, "resolve "+repo+" "+threadID)
+	return nil
+}
+
+func (f *fakeReviewAPI) UnresolveReviewThread(_ context.Context, repo, threadID string) error {
+	f.calls = append(f.calls, "unresolve "+repo+" "+threadID)
+	return nil
+}
+
+func stubReviewAPI(t *testing.T) *fakeReviewAPI {
+	t.Helper()
+	t.Setenv("GITHUB_TOKEN", "token")
+	t.Setenv("REPO_OWNER", "owner")
+	t.Setenv("REPO_NAME", "repo")
+	t.Setenv("PR_NUMBER", "7")
+	api := &fakeReviewAPI{}
+	prev := newReviewClient
+	t.Cleanup(func() { newReviewClient = prev })
+	newReviewClient = func(string) reviewThreadsAPI { return api }
+	return api
+}
+
+func resultText(res *mcp.CallToolResult) string {
+	return res.Content[0].(*mcp.TextContent).Text
+}
+
+func TestReviewTools_ScopedToPullRequest(t *testing.T) {
+	api := stubReviewAPI(t)
+	ctx := context.Background()
+
+	res, _, err := HandleResolveReviewThread(ctx, nil, ReviewThreadParams{ThreadID: "T1"})
+	if err != nil || res.IsError {
+		t.Fatalf("resolve T1 = %v, %v", res, err)
+	}
+	if res, _, _ = HandleReplyToReviewThread(ctx, nil, ReplyToReviewThreadParams{ThreadID: "T1", Body: "Fixed."}); res.IsError {
+		t.Fatalf("reply T1 = %s", resultText(res))
+	}
+	if res, _, _ = HandleUnresolveReviewThread(ctx, nil, ReviewThreadParams{ThreadID: "T1"}); res.IsError {
+		t.Fatalf("unresolve T1 = %s", resultText(res))
+	}
+
+	for id, want := range map[string]string{
+		"T2":   "belongs to owner/repo#8, not owner/repo#7",
+		"T3":   "belongs to other/repo#7",
+		"IC_1": "not found",
+	} {
+		res, _, err := HandleResolveReviewThread(ctx, nil, ReviewThreadParams{ThreadID: id})
+		if err != nil || !res.IsError || !strings.Contains(resultText(res), want) {
+			t.Fatalf("resolve %s = %v, %v; want error %q", id, res, err, want)
+		}
+	}
+
+	if got := strings.Join(api.calls, "; "); got != "resolve owner/repo T1; reply owner/repo T1: Fixed.; unresolve owner/repo T1" {
+		t.Fatalf("calls = %s", got)
+	}
+}
+
+func TestReviewTools_InvalidInput(t *testing.T) {
+	stubReviewAPI(t)
+	ctx := context.Background()
+
+	if _, _, err := HandleResolveReviewThread(ctx, nil, ReviewThreadParams{}); err == nil {
+		t.Fatal("expected error for missing thread_id")
+	}
+	if _, _, err := HandleReplyToReviewThread(ctx, nil, ReplyToReviewThreadParams{ThreadID: "T1"}); err == nil {
+		t.Fatal("expected error for empty body")
+	}
+	t.Setenv("PR_NUMBER", "seven")
+	if _, _, err := HandleResolveReviewThread(ctx, nil, ReviewThreadParams{ThreadID: "T1"}); err == nil || !strings.Contains(err.Error(), "PR_NUMBER") {
+		t.Fatalf("expected invalid PR_NUMBER error, got %v", err)
+	}
+}
+
+func TestHandleListReviewThreads(t *testing.T) {
+	stubReviewAPI(t)
+	res, _, err := HandleListReviewThreads(context.Background(), nil, ListReviewThreadsParams{})
+	if err != nil || res.IsError {
+		t.Fatalf("list = %v, %v", res, err)
+	}
+	if got := resultText(res); !strings.Contains(got, "1. [ ] app.go\n   [rev at ]: nit") || !strings.HasSuffix(got, "Thread IDs:\n1. T1") {
+		t.Fatalf("list = %q", got)
+	}
+}
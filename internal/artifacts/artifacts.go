@@ -0,0 +1,146 @@
+// Package artifacts keeps the files a task leaves behind — the provider
+// transcript, the final diff and the test output — in a storage backend,
+// one folder per task, for as long as the retention policy allows.
+package artifacts
+
+import (
+	"context"
+	"fmt"
+	"io"
+	"io/fs"
+	"sort"
+	"strings"
+	"time"
+
+	"github.com/cexll/swe/internal/storage"
+)
+
+// Artifact names saved for each task.
+const (
+	Transcript = "transcript.jsonl" // provider CLI output
+	Diff       = "diff.patch"       // everything the provider run changed
+	TestOutput = "test-output.log"  // output of the verify command
+	Summary    = "summary.md"       // what the provider reported it did
+	// DebugLog holds the provider CLI's stderr and the git commands of a
+	// task run with --debug, with their output
+	DebugLog = "debug.log"
+	// Prompt is the prompt the provider got; only operators may read it
+	Prompt = "prompt.json"
+	// DryRunPatch holds a dry run's commits (git format-patch output) and
+	// DryRunTarget where they apply; /code apply replays them
+	DryRunPatch  = "dry-run.patch"
+	DryRunTarget = "dry-run.json"
+)
+
+// Artifact is one file saved for a task.
+type Artifact struct {
+	Name     string
+	Size     int64
+	Modified time.Time
+}
+
+// Store saves and lists task files in a storage backend. A nil Store saves
+// nothing.
+type Store struct {
+	backend   storage.Backend
+	retention time.Duration
+}
+
+// allow tests to control time
+var now = time.Now
+
+// Open returns the store for spec (see storage.Open); an empty spec returns
+// nil. Artifacts older than retention are deleted (0 keeps them).
+func Open(spec string, retention time.Duration) (*Store, error) {
+	if spec == "" {
+		return nil, nil
+	}
+	backend, err := storage.Open(spec)
+	if err != nil {
+		return nil, err
+	}
+	return New(backend, retention), nil
+}
+
+// New returns a store keeping artifacts in backend.
+func New(backend storage.Backend, retention time.Duration) *Store {
+	return &Store{backend: backend, retention: retention}
+}
+
+func artifactKey(taskID, name string) (string, error) {
+	key := taskID + "/" + name
+	if strings.ContainsAny(taskID, `/\`) || strings.ContainsAny(name, `/\`) || storage.ValidKey(key) != nil {
+		return "", fmt.Errorf("invalid artifact %q of task %q: %w", name, taskID, fs.ErrInvalid)
+	}
+	return key, nil
+}
+
+// Save stores data as artifact name of task taskID, replacing an earlier
+// one.
+func (s *Store) Save(ctx context.Context, taskID, name string, data []byte) error {
+	if s == nil {
+		return nil
+	}
+	key, err := artifactKey(taskID, name)
+	if err != nil {
+		return err
+	}
+	if err := s.backend.Put(ctx, key, data); err != nil {
+		return fmt.Errorf("save artifact %s: %w", key, err)
+	}
+	return nil
+}
+
+// List returns the artifacts of task taskID by name.
+func (s *Store) List(ctx context.Context, taskID string) ([]Artifact, error) {
+	if s == nil {
+		return nil, nil
+	}
+	if _, err := artifactKey(taskID, "x"); err != nil {
+		return nil, err
+	}
+	objects, err := s.backend.List(ctx, taskID+"/")
+	if err != nil {
+		return nil, err
+	}
+	out := make([]Artifact, 0, len(objects))
+	for _, o := range objects {
+		out = append(out, Artifact{Name: strings.TrimPrefix(o.Key, taskID+"/"), Size: o.Size, Modified: o.Modified})
+	}
+	sort.Slice(out, func(i, j int) bool { return out[i].Name < out[j].Name })
+	return out, nil
+}
+
+// Open returns the content of artifact name of task taskID; a missing
+// artifact is an error matching fs.ErrNotExist.
+func (s *Store) Open(ctx context.Context, taskID, name string) (io.ReadCloser, error) {
+	key, err := artifactKey(taskID, name)
+	if err != nil {
+		return nil, err
+	}
+	return s.backend.Get(ctx, key)
+}
+
+// Prune deletes artifacts older than the retention period and returns how
+// many it deleted. The server runs it hourly on the leader replica.
+func (s *Store) Prune(ctx context.Context) (int, error) {
+	if s == nil || s.retention <= 0 {
+		return 0, nil
+	}
+	objects, err := s.backend.List(ctx, "")
+	if err != nil {
+		return 0, err
+	}
+	cutoff := now().Add(-s.retention)
+	n := 0
+	for _, o := range objects {
+		if !o.Modified.Before(cutoff) {
+			continue
+		}
+		if err := s.backend.Delete(ctx, o.Key); err != nil {
+			return n, err
+		}
+		n++
+	}
+	return n, nil
+}
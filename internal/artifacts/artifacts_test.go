@@ -0,0 +1,103 @@
+package artifacts
+
+import (
+	"context"
+	"errors"
+	"io"
+	"io/fs"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"github.com/cexll/swe/internal/storage"
+)
+
+func TestStore_LocalRoundTrip(t *testing.T) {
+	ctx := context.Background()
+	dir := t.TempDir()
+	s, err := Open("file://"+dir, 0)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err := s.Save(ctx, "task-1", Diff, []byte("diff --git a/x b/x\n")); err != nil {
+		t.Fatalf("Save: %v", err)
+	}
+	if err := s.Save(ctx, "task-1", Transcript, []byte("{}\n")); err != nil {
+		t.Fatalf("Save: %v", err)
+	}
+	if err := s.Save(ctx, "task-2", Diff, []byte("other")); err != nil {
+		t.Fatalf("Save: %v", err)
+	}
+
+	list, err := s.List(ctx, "task-1")
+	if err != nil {
+		t.Fatalf("List: %v", err)
+	}
+	if len(list) != 2 || list[0].Name != Diff || list[1].Name != Transcript || list[0].Size != 19 {
+		t.Fatalf("List = %+v", list)
+	}
+	rc, err := s.Open(ctx, "task-1", Diff)
+	if err != nil {
+		t.Fatalf("Open: %v", err)
+	}
+	data, _ := io.ReadAll(rc)
+	_ = rc.Close()
+	if string(data) != "diff --git a/x b/x\n" {
+		t.Fatalf("content = %q", data)
+	}
+	if _, err := s.Open(ctx, "task-1", TestOutput); !errors.Is(err, fs.ErrNotExist) {
+		t.Fatalf("Open missing = %v", err)
+	}
+	for _, bad := range [][2]string{{"../etc", "passwd"}, {"task-1", "../x"}, {"", Diff}, {"task-1", ""}, {"..", Diff}} {
+		if err := s.Save(ctx, bad[0], bad[1], nil); !errors.Is(err, fs.ErrInvalid) {
+			t.Errorf("Save(%q, %q) accepted", bad[0], bad[1])
+		}
+	}
+	if _, err := os.Stat(filepath.Join(dir, "task-1", Diff)); err != nil {
+		t.Fatalf("artifact not stored under the task folder: %v", err)
+	}
+}
+
+func TestStore_Prune(t *testing.T) {
+	ctx := context.Background()
+	dir := t.TempDir()
+	s := New(&storage.Local{Dir: dir}, 24*time.Hour)
+	if err := s.Save(ctx, "old", Diff, []byte("a")); err != nil {
+		t.Fatal(err)
+	}
+	if err := s.Save(ctx, "new", Diff, []byte("b")); err != nil {
+		t.Fatal(err)
+	}
+	past := time.Now().Add(-48 * time.Hour)
+	if err := os.Chtimes(filepath.Join(dir, "old", Diff), past, past); err != nil {
+		t.Fatal(err)
+	}
+
+	n, err := s.Prune(ctx)
+	if err != nil || n != 1 {
+		t.Fatalf("Prune = %d, %v", n, err)
+	}
+	if list, _ := s.List(ctx, "old"); len(list) != 0 {
+		t.Fatalf("expired artifacts kept: %+v", list)
+	}
+	if _, err := os.Stat(filepath.Join(dir, "old")); !errors.Is(err, fs.ErrNotExist) {
+		t.Fatalf("empty task folder kept: %v", err)
+	}
+	if list, _ := s.List(ctx, "new"); len(list) != 1 {
+		t.Fatalf("recent artifacts pruned: %+v", list)
+	}
+}
+
+func TestStore_Nil(t *testing.T) {
+	var s *Store
+	if err := s.Save(context.Background(), "t", Diff, nil); err != nil {
+		t.Fatal(err)
+	}
+	if list, err := s.List(context.Background(), "t"); list != nil || err != nil {
+		t.Fatalf("List = %v, %v", list, err)
+	}
+	if s, err := Open("", time.Hour); s != nil || err != nil {
+		t.Fatalf("Open(\"\") = %v, %v", s, err)
+	}
+}
@@ -0,0 +1,308 @@
+package audit
+
+import (
+	"bufio"
+	"encoding/json"
+	"fmt"
+	"io"
+	"os"
+	"path/filepath"
+	"strings"
+	"sync"
+	"time"
+)
+
+// Action identifies the kind of externally-triggered action being audited.
+type Action string
+
+const (
+	ActionPermission       Action = "permission_decision"
+	ActionTaskQueued       Action = "task_queued"
+	ActionExecutionStarted Action = "execution_started"
+	ActionExecutionDone    Action = "execution_completed"
+	ActionExecutionFailed  Action = "execution_failed"
+	ActionBranchPushed     Action = "branch_pushed"
+	ActionBranchWithdrawn  Action = "branch_withdrawn"
+	ActionShareLinkCreated Action = "share_link_created"
+	ActionRepoRejected     Action = "repo_rejected"
+	ActionWikiUpdated      Action = "wiki_updated"
+	ActionReleaseRequested Action = "release_requested"
+	ActionReleasePublished Action = "release_published"
+	ActionGitBlocked       Action = "git_blocked"
+	ActionPlanApproved     Action = "plan_approved"
+	ActionLabelsApplied    Action = "labels_applied"
+	ActionBackportOpened   Action = "backport_opened"
+	ActionDepsUpdated      Action = "deps_updated"
+	ActionSubtasksMerged   Action = "subtasks_merged"
+	ActionBudgetRefused    Action = "budget_refused"
+	ActionBudgetReset      Action = "budget_reset"
+	ActionCostConfirmed    Action = "cost_confirmed"
+)
+
+// Permission decisions recorded with ActionPermission.
+const (
+	DecisionAllowed = "allowed"
+	DecisionDenied  = "denied"
+)
+
+// Event is a single audit record. Fields are optional unless noted.
+type Event struct {
+	ID        int64     `json:"id"`        // assigned by Record
+	Timestamp time.Time `json:"timestamp"` // assigned by Record when zero
+	Action    Action    `json:"action"`
+	Actor     string    `json:"actor,omitempty"`
+	Repo      string    `json:"repo,omitempty"`
+	Number    int       `json:"number,omitempty"`
+	TaskID    string    `json:"task_id,omitempty"`
+	Decision  string    `json:"decision,omitempty"`
+	Branch    string    `json:"branch,omitempty"`
+	PRNumber  int       `json:"pr_number,omitempty"`
+	// TriggerCommentID is the /code comment; TrackingCommentID is the coordinating comment.
+	TriggerCommentID  int64   `json:"trigger_comment_id,omitempty"`
+	TrackingCommentID int64   `json:"tracking_comment_id,omitempty"`
+	Provider          string  `json:"provider,omitempty"`
+	Model             string  `json:"model,omitempty"`
+	CostUSD           float64 `json:"cost_usd,omitempty"`
+	Detail            string  `json:"detail,omitempty"`
+}
+
+// Config controls where the audit log is persisted and how long entries are kept.
+type Config struct {
+	// Path of the JSON lines file. Empty keeps the log in memory only.
+	Path string
+	// Retention drops entries older than this duration. Zero keeps everything.
+	Retention time.Duration
+}
+
+// Filter narrows List results. Zero values match everything.
+type Filter struct {
+	Repo   string
+	Actor  string
+	Action Action
+	TaskID string
+	Since  time.Time
+	Limit  int // most recent N entries when > 0
+}
+
+// Log is an append-only audit log. Entries are only ever removed by retention.
+type Log struct {
+	mu        sync.Mutex
+	cfg       Config
+	events    []Event
+	nextID    int64
+	file      *os.File
+	lastPrune time.Time
+}
+
+// allow tests to control time
+var now = time.Now
+
+// New opens (or creates) the audit log described by cfg, loading existing entries.
+func New(cfg Config) (*Log, error) {
+	l := &Log{cfg: cfg, nextID: 1}
+	if cfg.Path == "" {
+		return l, nil
+	}
+
+	if err := os.MkdirAll(filepath.Dir(cfg.Path), 0o755); err != nil {
+		return nil, fmt.Errorf("create audit log dir: %w", err)
+	}
+	if err := l.load(); err != nil {
+		return nil, err
+	}
+	if err := l.compactLocked(); err != nil {
+		return nil, err
+	}
+	return l, nil
+}
+
+func (l *Log) load() error {
+	f, err := os.Open(l.cfg.Path)
+	if os.IsNotExist(err) {
+		return nil
+	}
+	if err != nil {
+		return fmt.Errorf("open audit log: %w", err)
+	}
+	defer func() { _ = f.Close() }()
+
+	scanner := bufio.NewScanner(f)
+	scanner.Buffer(make([]byte, 0, 64*1024), 1024*1024)
+	for scanner.Scan() {
+		line := strings.TrimSpace(scanner.Text())
+		if line == "" {
+			continue
+		}
+		var e Event
+		if err := json.Unmarshal([]byte(line), &e); err != nil {
+			// skip corrupt lines rather than refusing to start
+			continue
+		}
+		l.events = append(l.events, e)
+		if e.ID >= l.nextID {
+			l.nextID = e.ID + 1
+		}
+	}
+	return scanner.Err()
+}
+
+// Record appends an event, stamping its ID and timestamp. Persistence errors
+// are returned but the event is still kept in memory.
+func (l *Log) Record(e Event) error {
+	if l == nil {
+		return nil
+	}
+	l.mu.Lock()
+	defer l.mu.Unlock()
+
+	e.ID = l.nextID
+	l.nextID++
+	if e.Timestamp.IsZero() {
+		e.Timestamp = now()
+	}
+	l.events = append(l.events, e)
+
+	// Prune at most hourly to keep Record cheap
+	if l.cfg.Retention > 0 && now().Sub(l.lastPrune) > time.Hour {
+		if err := l.compactLocked(); err != nil {
+			return err
+		}
+		return nil
+	}
+	return l.appendLocked(e)
+}
+
+func (l *Log) appendLocked(e Event) error {
+	if l.cfg.Path == "" {
+		return nil
+	}
+	if l.file == nil {
+		f, err := os.OpenFile(l.cfg.Path, os.O_CREATE|os.O_APPEND|os.O_WRONLY, 0o600)
+		if err != nil {
+			return fmt.Errorf("open audit log: %w", err)
+		}
+		l.file = f
+	}
+	blob, err := json.Marshal(e)
+	if err != nil {
+		return fmt.Errorf("marshal audit event: %w", err)
+	}
+	if _, err := l.file.Write(append(blob, '\n')); err != nil {
+		return fmt.Errorf("write audit event: %w", err)
+	}
+	return nil
+}
+
+// compactLocked applies retention in memory and rewrites the backing file.
+func (l *Log) compactLocked() error {
+	l.lastPrune = now()
+	if l.cfg.Retention > 0 {
+		cutoff := now().Add(-l.cfg.Retention)
+		kept := l.events[:0]
+		for _, e := range l.events {
+			if !e.Timestamp.Before(cutoff) {
+				kept = append(kept, e)
+			}
+		}
+		l.events = kept
+	}
+	if l.cfg.Path == "" {
+		return nil
+	}
+
+	if l.file != nil {
+		_ = l.file.Close()
+		l.file = nil
+	}
+	tmp := l.cfg.Path + ".tmp"
+	f, err := os.OpenFile(tmp, os.O_CREATE|os.O_TRUNC|os.O_WRONLY, 0o600)
+	if err != nil {
+		return fmt.Errorf("rewrite audit log: %w", err)
+	}
+	if err := writeJSONLines(f, l.events); err != nil {
+		_ = f.Close()
+		return err
+	}
+	if err := f.Close(); err != nil {
+		return fmt.Errorf("rewrite audit log: %w", err)
+	}
+	if err := os.Rename(tmp, l.cfg.Path); err != nil {
+		return fmt.Errorf("rewrite audit log: %w", err)
+	}
+	return nil
+}
+
+// List returns matching events, newest first.
+func (l *Log) List(f Filter) []Event {
+	if l == nil {
+		return nil
+	}
+	l.mu.Lock()
+	defer l.mu.Unlock()
+
+	out := make([]Event, 0)
+	for i := len(l.events) - 1; i >= 0; i-- {
+		e := l.events[i]
+		if !f.matches(e) {
+			continue
+		}
+		out = append(out, e)
+		if f.Limit > 0 && len(out) >= f.Limit {
+			break
+		}
+	}
+	return out
+}
+
+func (f Filter) matches(e Event) bool {
+	if f.Repo != "" && !strings.EqualFold(e.Repo, f.Repo) {
+		return false
+	}
+	if f.Actor != "" && !strings.EqualFold(e.Actor, f.Actor) {
+		return false
+	}
+	if f.Action != "" && e.Action != f.Action {
+		return false
+	}
+	if f.TaskID != "" && e.TaskID != f.TaskID {
+		return false
+	}
+	if !f.Since.IsZero() && e.Timestamp.Before(f.Since) {
+		return false
+	}
+	return true
+}
+
+// Export writes matching events as JSON lines in chronological order.
+func (l *Log) Export(w io.Writer, f Filter) error {
+	events := l.List(f)
+	for i, j := 0, len(events)-1; i < j; i, j = i+1, j-1 {
+		events[i], events[j] = events[j], events[i]
+	}
+	return writeJSONLines(w, events)
+}
+
+func writeJSONLines(w io.Writer, events []Event) error {
+	enc := json.NewEncoder(w)
+	for _, e := range events {
+		if err := enc.Encode(e); err != nil {
+			return fmt.Errorf("encode audit event: %w", err)
+		}
+	}
+	return nil
+}
+
+// Close releases the backing file.
+func (l *Log) Close() error {
+	if l == nil {
+		return nil
+	}
+	l.mu.Lock()
+	defer l.mu.Unlock()
+	if l.file == nil {
+		return nil
+	}
+	err := l.file.Close()
+	l.file = nil
+	return err
+}
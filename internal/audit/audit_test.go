@@ -0,0 +1,141 @@
+package audit
+
+import (
+	"bytes"
+	"encoding/json"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+)
+
+func TestLog_RecordAndList(t *testing.T) {
+	l, err := New(Config{})
+	if err != nil {
+		t.Fatalf("New error: %v", err)
+	}
+
+	_ = l.Record(Event{Action: ActionPermission, Actor: "alice", Repo: "acme/api", Decision: DecisionAllowed})
+	_ = l.Record(Event{Action: ActionTaskQueued, Actor: "alice", Repo: "acme/api", TaskID: "t1"})
+	_ = l.Record(Event{Action: ActionPermission, Actor: "bob", Repo: "acme/web", Decision: DecisionDenied})
+
+	all := l.List(Filter{})
+	if len(all) != 3 {
+		t.Fatalf("List len = %d, want 3", len(all))
+	}
+	if all[0].Actor != "bob" || all[0].ID != 3 {
+		t.Fatalf("List should be newest first, got %+v", all[0])
+	}
+	if all[2].Timestamp.IsZero() {
+		t.Fatal("Record should stamp timestamps")
+	}
+
+	if got := l.List(Filter{Repo: "ACME/api"}); len(got) != 2 {
+		t.Fatalf("repo filter len = %d, want 2", len(got))
+	}
+	if got := l.List(Filter{Action: ActionPermission, Actor: "bob"}); len(got) != 1 || got[0].Decision != DecisionDenied {
+		t.Fatalf("action/actor filter = %+v", got)
+	}
+	if got := l.List(Filter{Limit: 1}); len(got) != 1 || got[0].ID != 3 {
+		t.Fatalf("limit filter = %+v", got)
+	}
+}
+
+func TestLog_PersistsAndReloads(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "audit", "audit.jsonl")
+
+	l, err := New(Config{Path: path})
+	if err != nil {
+		t.Fatalf("New error: %v", err)
+	}
+	if err := l.Record(Event{Action: ActionTaskQueued, TaskID: "t1", TrackingCommentID: 42}); err != nil {
+		t.Fatalf("Record error: %v", err)
+	}
+	if err := l.Record(Event{Action: ActionExecutionDone, TaskID: "t1", Provider: "claude", CostUSD: 0.12}); err != nil {
+		t.Fatalf("Record error: %v", err)
+	}
+	_ = l.Close()
+
+	raw, err := os.ReadFile(path)
+	if err != nil {
+		t.Fatalf("read audit file: %v", err)
+	}
+	lines := strings.Split(strings.TrimSpace(string(raw)), "\n")
+	if len(lines) != 2 {
+		t.Fatalf("file lines = %d, want 2:\n%s", len(lines), raw)
+	}
+
+	reloaded, err := New(Config{Path: path})
+	if err != nil {
+		t.Fatalf("reload error: %v", err)
+	}
+	defer func() { _ = reloaded.Close() }()
+	if got := reloaded.List(Filter{}); len(got) != 2 || got[0].CostUSD != 0.12 {
+		t.Fatalf("reloaded events = %+v", got)
+	}
+	_ = reloaded.Record(Event{Action: ActionExecutionStarted})
+	if got := reloaded.List(Filter{Limit: 1}); got[0].ID != 3 {
+		t.Fatalf("IDs should continue after reload, got %d", got[0].ID)
+	}
+}
+
+func TestLog_Retention(t *testing.T) {
+	current := time.Date(2025, 10, 1, 0, 0, 0, 0, time.UTC)
+	prevNow := now
+	now = func() time.Time { return current }
+	t.Cleanup(func() { now = prevNow })
+
+	path := filepath.Join(t.TempDir(), "audit.jsonl")
+	old := Event{ID: 1, Action: ActionTaskQueued, Timestamp: current.Add(-48 * time.Hour)}
+	recent := Event{ID: 2, Action: ActionTaskQueued, Timestamp: current.Add(-time.Hour)}
+	var buf bytes.Buffer
+	_ = json.NewEncoder(&buf).Encode(old)
+	_ = json.NewEncoder(&buf).Encode(recent)
+	buf.WriteString("not json\n")
+	if err := os.WriteFile(path, buf.Bytes(), 0o600); err != nil {
+		t.Fatalf("seed audit file: %v", err)
+	}
+
+	l, err := New(Config{Path: path, Retention: 24 * time.Hour})
+	if err != nil {
+		t.Fatalf("New error: %v", err)
+	}
+	defer func() { _ = l.Close() }()
+
+	if got := l.List(Filter{}); len(got) != 1 || got[0].ID != 2 {
+		t.Fatalf("retention should drop old entries, got %+v", got)
+	}
+	raw, _ := os.ReadFile(path)
+	if strings.Count(string(raw), "\n") != 1 {
+		t.Fatalf("file should be compacted to 1 line, got:\n%s", raw)
+	}
+}
+
+func TestLog_Export(t *testing.T) {
+	l, _ := New(Config{})
+	_ = l.Record(Event{Action: ActionTaskQueued, TaskID: "a"})
+	_ = l.Record(Event{Action: ActionTaskQueued, TaskID: "b"})
+
+	var buf bytes.Buffer
+	if err := l.Export(&buf, Filter{}); err != nil {
+		t.Fatalf("Export error: %v", err)
+	}
+	lines := strings.Split(strings.TrimSpace(buf.String()), "\n")
+	if len(lines) != 2 || !strings.Contains(lines[0], `"task_id":"a"`) {
+		t.Fatalf("Export should be chronological JSON lines, got:\n%s", buf.String())
+	}
+}
+
+func TestLog_NilSafe(t *testing.T) {
+	var l *Log
+	if err := l.Record(Event{Action: ActionTaskQueued}); err != nil {
+		t.Fatalf("nil Record error: %v", err)
+	}
+	if l.List(Filter{}) != nil {
+		t.Fatal("nil List should return nil")
+	}
+	if err := l.Close(); err != nil {
+		t.Fatalf("nil Close error: %v", err)
+	}
+}
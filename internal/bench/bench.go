@@ -0,0 +1,158 @@
+// Package bench runs a fixed suite of prompts against several providers and
+// models on a sample repository, and reports how each did: whether the
+// change passed the suite's check, how long it took, what it cost and how
+// large and well-aimed the diff was.
+package bench
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"os"
+	"path/filepath"
+	"strings"
+	"time"
+)
+
+// Suite is a benchmark definition, read from a JSON file.
+type Suite struct {
+	Name string `json:"name,omitempty"`
+	// Repo is the sample repository every case starts from: a local path,
+	// relative to the suite file, or any URL git clone accepts
+	Repo string `json:"repo"`
+	// Ref is checked out before each case (default: the clone's HEAD)
+	Ref string `json:"ref,omitempty"`
+	// RepoName is the owner/name shown to the model (default: bench/<name>)
+	RepoName string `json:"repo_name,omitempty"`
+	// Check is the shell command run in the checkout after each case to
+	// decide whether it succeeded, unless the case has its own
+	Check string `json:"check,omitempty"`
+	// Timeout bounds each run, provider and check together (default 15m)
+	Timeout Duration `json:"timeout,omitempty"`
+	Cases   []Case   `json:"cases"`
+}
+
+// Case is one prompt of a suite.
+type Case struct {
+	Name   string `json:"name"`
+	Prompt string `json:"prompt"`
+	Check  string `json:"check,omitempty"`
+	// ExpectFiles are the paths a good change touches; the report counts
+	// how many of them a run changed and how many other files it changed
+	ExpectFiles []string `json:"expect_files,omitempty"`
+}
+
+// Duration is a time.Duration read from a JSON string such as "10m".
+type Duration time.Duration
+
+func (d *Duration) UnmarshalJSON(data []byte) error {
+	var s string
+	if err := json.Unmarshal(data, &s); err != nil {
+		return fmt.Errorf("duration must be a string such as \"10m\": %w", err)
+	}
+	v, err := time.ParseDuration(s)
+	if err != nil {
+		return err
+	}
+	*d = Duration(v)
+	return nil
+}
+
+func (d Duration) MarshalJSON() ([]byte, error) {
+	return json.Marshal(time.Duration(d).String())
+}
+
+// defaultTimeout bounds a run when the suite sets no timeout.
+const defaultTimeout = 15 * time.Minute
+
+// LoadSuite reads and checks the suite in path; a relative local Repo is
+// resolved against the suite's directory.
+func LoadSuite(path string) (*Suite, error) {
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return nil, err
+	}
+	var s Suite
+	if err := json.Unmarshal(data, &s); err != nil {
+		return nil, fmt.Errorf("%s: %w", path, err)
+	}
+	if s.Name == "" {
+		s.Name = strings.TrimSuffix(filepath.Base(path), filepath.Ext(path))
+	}
+	if s.Repo != "" && !strings.Contains(s.Repo, "://") && !strings.Contains(s.Repo, "@") && !filepath.IsAbs(s.Repo) {
+		s.Repo = filepath.Join(filepath.Dir(path), s.Repo)
+	}
+	if err := s.validate(); err != nil {
+		return nil, fmt.Errorf("%s: %w", path, err)
+	}
+	return &s, nil
+}
+
+func (s *Suite) validate() error {
+	if s.Repo == "" {
+		return errors.New("repo is required")
+	}
+	if len(s.Cases) == 0 {
+		return errors.New("the suite has no cases")
+	}
+	seen := make(map[string]bool)
+	for i, c := range s.Cases {
+		if c.Name == "" {
+			return fmt.Errorf("case %d: name is required", i+1)
+		}
+		if seen[c.Name] {
+			return fmt.Errorf("case %q: duplicate name", c.Name)
+		}
+		seen[c.Name] = true
+		if strings.TrimSpace(c.Prompt) == "" {
+			return fmt.Errorf("case %q: prompt is required", c.Name)
+		}
+	}
+	return nil
+}
+
+func (s *Suite) timeout() time.Duration {
+	if s.Timeout > 0 {
+		return time.Duration(s.Timeout)
+	}
+	return defaultTimeout
+}
+
+// Target is a provider and, optionally, the model it runs, written
+// "provider" or "provider:model".
+type Target struct {
+	Provider string
+	Model    string
+}
+
+func (t Target) String() string {
+	if t.Model == "" {
+		return t.Provider
+	}
+	return t.Provider + ":" + t.Model
+}
+
+// ParseTargets parses a comma-separated list of targets.
+func ParseTargets(list string) ([]Target, error) {
+	var targets []Target
+	seen := make(map[Target]bool)
+	for _, item := range strings.Split(list, ",") {
+		item = strings.TrimSpace(item)
+		if item == "" {
+			continue
+		}
+		p, model, _ := strings.Cut(item, ":")
+		t := Target{Provider: strings.TrimSpace(p), Model: strings.TrimSpace(model)}
+		if t.Provider != "claude" && t.Provider != "codex" {
+			return nil, fmt.Errorf("invalid provider %q in %q (must be claude or codex)", t.Provider, item)
+		}
+		if !seen[t] {
+			seen[t] = true
+			targets = append(targets, t)
+		}
+	}
+	if len(targets) == 0 {
+		return nil, errors.New("no targets")
+	}
+	return targets, nil
+}
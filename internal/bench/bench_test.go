@@ -0,0 +1,126 @@
+package bench
+
+import (
+	"context"
+	"errors"
+	"os"
+	"os/exec"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"github.com/cexll/swe/internal/executor"
+	"github.com/cexll/swe/internal/provider"
+)
+
+type stubProvider struct{ name string }
+
+func (p stubProvider) Name() string { return p.name }
+
+func (p stubProvider) GenerateCode(context.Context, *provider.CodeRequest) (*provider.CodeResponse, error) {
+	return &provider.CodeResponse{}, nil
+}
+
+func sampleRepo(t *testing.T) string {
+	t.Helper()
+	dir := t.TempDir()
+	for _, args := range [][]string{
+		{"init", "-q"},
+		{"-c", "user.name=t", "-c", "user.email=t@example.com", "commit", "-q", "--allow-empty", "-m", "init"},
+	} {
+		if out, err := exec.Command("git", append([]string{"-C", dir}, args...)...).CombinedOutput(); err != nil {
+			t.Fatalf("git %v: %v\n%s", args, err, out)
+		}
+	}
+	return dir
+}
+
+func TestLoadSuite(t *testing.T) {
+	dir := t.TempDir()
+	path := filepath.Join(dir, "smoke.json")
+	_ = os.WriteFile(path, []byte(`{"repo":"sample","timeout":"2m","cases":[{"name":"add","prompt":"add a file"}]}`), 0o644)
+	s, err := LoadSuite(path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if s.Name != "smoke" || s.Repo != filepath.Join(dir, "sample") || s.timeout().String() != "2m0s" {
+		t.Fatalf("suite = %+v", s)
+	}
+
+	for body, want := range map[string]string{
+		`{"cases":[{"name":"a","prompt":"p"}]}`:                                      "repo is required",
+		`{"repo":"r","cases":[]}`:                                                    "no cases",
+		`{"repo":"r","cases":[{"name":"a","prompt":" "}]}`:                           "prompt is required",
+		`{"repo":"r","cases":[{"name":"a","prompt":"p"},{"name":"a","prompt":"p"}]}`: "duplicate name",
+		`{"repo":"r","timeout":5,"cases":[{"name":"a","prompt":"p"}]}`:               "duration",
+	} {
+		_ = os.WriteFile(path, []byte(body), 0o644)
+		if _, err := LoadSuite(path); err == nil || !strings.Contains(err.Error(), want) {
+			t.Errorf("%s: err = %v, want %q", body, err, want)
+		}
+	}
+}
+
+func TestParseTargets(t *testing.T) {
+	targets, err := ParseTargets("claude, codex:gpt-5-codex,claude")
+	if err != nil || len(targets) != 2 || targets[1].String() != "codex:gpt-5-codex" {
+		t.Fatalf("targets = %v, %v", targets, err)
+	}
+	for _, list := range []string{"", "gemini", " , "} {
+		if _, err := ParseTargets(list); err == nil {
+			t.Errorf("%q: expected an error", list)
+		}
+	}
+}
+
+func TestRunner_Run(t *testing.T) {
+	suite := &Suite{
+		Name:  "smoke",
+		Repo:  sampleRepo(t),
+		Check: "test -f done.txt",
+		Cases: []Case{
+			{Name: "add", Prompt: "add done.txt", ExpectFiles: []string{"done.txt"}},
+			{Name: "broken", Prompt: "break it"},
+		},
+	}
+	r := &Runner{
+		Suite:       suite,
+		Targets:     []Target{{Provider: "claude"}, {Provider: "codex", Model: "m"}},
+		Repeat:      2,
+		NewProvider: func(t Target) (provider.Provider, error) { return stubProvider{t.Provider}, nil },
+		Exec: func(_ context.Context, p provider.Provider, req executor.LocalRequest) (*executor.LocalResult, error) {
+			if req.Prompt == "break it" {
+				return nil, errors.New("provider crashed")
+			}
+			if p.Name() == "claude" {
+				_ = os.WriteFile(filepath.Join(req.RepoPath, "done.txt"), []byte("ok\n"), 0o644)
+				return &executor.LocalResult{CostUSD: 0.25, Diff: "diff --git a/done.txt b/done.txt\n--- /dev/null\n+++ b/done.txt\n@@ -0,0 +1 @@\n+ok\n"}, nil
+			}
+			return &executor.LocalResult{CostUSD: 0.1, Diff: "diff --git a/other.txt b/other.txt\n-a\n+b\n"}, nil
+		},
+	}
+	report, err := r.Run(context.Background())
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(report.Results) != 8 {
+		t.Fatalf("%d results", len(report.Results))
+	}
+	claude, codex := report.Targets[0], report.Targets[1]
+	if claude.Runs != 4 || claude.Successes != 2 || claude.CostUSD != 0.5 || claude.ExpectedRecall != 1 || claude.Unexpected != 0 || claude.MeanLinesChanged != 0.5 {
+		t.Fatalf("claude = %+v", claude)
+	}
+	if codex.Target != "codex:m" || codex.Successes != 0 || codex.ExpectedRecall != 0 || codex.Unexpected != 2 {
+		t.Fatalf("codex = %+v", codex)
+	}
+	if res := report.Results[4]; res.Success || res.Error != "" || res.LinesAdded != 1 || res.LinesRemoved != 1 {
+		t.Fatalf("codex run = %+v", res)
+	}
+
+	var md strings.Builder
+	_ = report.WriteMarkdown(&md)
+	if !strings.Contains(md.String(), "| claude | 2/4 (50%) |") || !strings.Contains(md.String(), "- claude broken #1: provider crashed") ||
+		!strings.Contains(md.String(), "- codex:m add #2: check failed") {
+		t.Fatalf("markdown:\n%s", md.String())
+	}
+}
@@ -0,0 +1,102 @@
+package bench
+
+import (
+	"fmt"
+	"io"
+	"time"
+)
+
+// Report is the outcome of a benchmark: every run and a summary per target.
+type Report struct {
+	Suite    string    `json:"suite"`
+	Started  time.Time `json:"started"`
+	Finished time.Time `json:"finished"`
+	Targets  []Summary `json:"targets"`
+	Results  []Result  `json:"results"`
+}
+
+// Summary aggregates the runs of one target.
+type Summary struct {
+	Target    string  `json:"target"`
+	Runs      int     `json:"runs"`
+	Successes int     `json:"successes"`
+	CostUSD   float64 `json:"cost_usd"`
+	// MeanDuration and MeanLinesChanged are averaged over all runs
+	MeanDuration     time.Duration `json:"mean_duration_ns"`
+	MeanLinesChanged float64       `json:"mean_lines_changed"`
+	// ExpectedRecall is the share of expected files the runs changed, and
+	// Unexpected the other files they changed, over cases that list some
+	ExpectedRecall float64 `json:"expected_recall"`
+	Unexpected     int     `json:"unexpected"`
+}
+
+// SuccessRate is the share of runs that succeeded.
+func (s Summary) SuccessRate() float64 {
+	if s.Runs == 0 {
+		return 0
+	}
+	return float64(s.Successes) / float64(s.Runs)
+}
+
+// summarize fills r.Targets from r.Results, in the order of targets.
+func (r *Report) summarize(targets []Target) {
+	r.Targets = r.Targets[:0]
+	for _, t := range targets {
+		s := Summary{Target: t.String()}
+		var total time.Duration
+		lines, hit, expected := 0, 0, 0
+		for _, res := range r.Results {
+			if res.Target != s.Target {
+				continue
+			}
+			s.Runs++
+			if res.Success {
+				s.Successes++
+			}
+			s.CostUSD += res.CostUSD
+			total += res.Duration
+			lines += res.LinesAdded + res.LinesRemoved
+			if res.ExpectedTotal > 0 {
+				hit += res.ExpectedHit
+				expected += res.ExpectedTotal
+				s.Unexpected += res.Unexpected
+			}
+		}
+		if s.Runs > 0 {
+			s.MeanDuration = total / time.Duration(s.Runs)
+			s.MeanLinesChanged = float64(lines) / float64(s.Runs)
+		}
+		if expected > 0 {
+			s.ExpectedRecall = float64(hit) / float64(expected)
+		}
+		r.Targets = append(r.Targets, s)
+	}
+}
+
+// WriteMarkdown writes the summary table and the failed runs as Markdown.
+func (r *Report) WriteMarkdown(w io.Writer) error {
+	_, _ = fmt.Fprintf(w, "## Benchmark: %s\n\n", r.Suite)
+	_, _ = fmt.Fprintln(w, "| Target | Success | Mean duration | Cost | Mean lines changed | Expected files | Other files |")
+	_, _ = fmt.Fprintln(w, "|---|---|---|---|---|---|---|")
+	for _, s := range r.Targets {
+		_, _ = fmt.Fprintf(w, "| %s | %d/%d (%.0f%%) | %s | $%.2f | %.1f | %.0f%% | %d |\n",
+			s.Target, s.Successes, s.Runs, 100*s.SuccessRate(), s.MeanDuration.Round(time.Second), s.CostUSD, s.MeanLinesChanged, 100*s.ExpectedRecall, s.Unexpected)
+	}
+	failed := false
+	for _, res := range r.Results {
+		if res.Success {
+			continue
+		}
+		if !failed {
+			_, _ = fmt.Fprintln(w, "\n### Failed runs")
+			failed = true
+		}
+		reason := res.Error
+		if reason == "" {
+			reason = "check failed"
+		}
+		_, _ = fmt.Fprintf(w, "- %s %s #%d: %s\n", res.Target, res.Case, res.Attempt, reason)
+	}
+	_, err := fmt.Fprintln(w)
+	return err
+}
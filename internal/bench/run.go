@@ -0,0 +1,205 @@
+package bench
+
+import (
+	"bytes"
+	"context"
+	"fmt"
+	"io"
+	"os"
+	"os/exec"
+	"slices"
+	"strings"
+	"time"
+
+	"github.com/cexll/swe/internal/executor"
+	"github.com/cexll/swe/internal/provider"
+)
+
+// checkOutputLimit caps the check output kept in a result.
+const checkOutputLimit = 4 << 10
+
+// Runner runs a suite against targets.
+type Runner struct {
+	Suite   *Suite
+	Targets []Target
+	// Repeat runs each case this many times per target (default 1)
+	Repeat int
+	// NewProvider builds the provider a target runs
+	NewProvider func(Target) (provider.Provider, error)
+	// Exec runs one case in a fresh checkout (default executor.RunLocal)
+	Exec func(context.Context, provider.Provider, executor.LocalRequest) (*executor.LocalResult, error)
+	// Progress, when set, gets a line per finished run
+	Progress io.Writer
+}
+
+// Result is the outcome of one run of a case on a target.
+type Result struct {
+	Case    string `json:"case"`
+	Target  string `json:"target"`
+	Attempt int    `json:"attempt"`
+	Success bool   `json:"success"`
+	// Error is why the run failed before its check, if it did
+	Error       string        `json:"error,omitempty"`
+	CheckOutput string        `json:"check_output,omitempty"`
+	Duration    time.Duration `json:"duration_ns"`
+	CostUSD     float64       `json:"cost_usd"`
+	Diff
+}
+
+// Diff measures the change a run made.
+type Diff struct {
+	FilesChanged int `json:"files_changed"`
+	LinesAdded   int `json:"lines_added"`
+	LinesRemoved int `json:"lines_removed"`
+	// ExpectedHit of the case's ExpectFiles were changed, and Unexpected
+	// other files
+	ExpectedHit   int `json:"expected_hit"`
+	ExpectedTotal int `json:"expected_total"`
+	Unexpected    int `json:"unexpected"`
+}
+
+// Run runs every case on every target, in that order, and reports the
+// results. Only a cancelled ctx stops it early; a failing run is recorded
+// and the next one starts.
+func (r *Runner) Run(ctx context.Context) (*Report, error) {
+	run := r.Exec
+	if run == nil {
+		run = executor.RunLocal
+	}
+	repeat := max(r.Repeat, 1)
+	report := &Report{Suite: r.Suite.Name, Started: time.Now()}
+	for _, target := range r.Targets {
+		p, err := r.NewProvider(target)
+		if err != nil {
+			return nil, fmt.Errorf("%s: %w", target, err)
+		}
+		for _, c := range r.Suite.Cases {
+			for attempt := 1; attempt <= repeat; attempt++ {
+				if err := ctx.Err(); err != nil {
+					return report, err
+				}
+				res := r.runCase(ctx, run, p, target, c)
+				res.Attempt = attempt
+				report.Results = append(report.Results, res)
+				if r.Progress != nil {
+					outcome := "ok"
+					if !res.Success {
+						outcome = "FAILED"
+					}
+					_, _ = fmt.Fprintf(r.Progress, "%s %s #%d: %s in %s\n", target, c.Name, attempt, outcome, res.Duration.Round(time.Second))
+				}
+			}
+		}
+	}
+	report.Finished = time.Now()
+	report.summarize(r.Targets)
+	return report, nil
+}
+
+// runCase runs c on target in a fresh clone of the sample repository.
+func (r *Runner) runCase(ctx context.Context, run func(context.Context, provider.Provider, executor.LocalRequest) (*executor.LocalResult, error), p provider.Provider, target Target, c Case) Result {
+	res := Result{Case: c.Name, Target: target.String()}
+	ctx, cancel := context.WithTimeout(ctx, r.Suite.timeout())
+	defer cancel()
+
+	dir, err := os.MkdirTemp("", "swe-bench-")
+	if err != nil {
+		res.Error = err.Error()
+		return res
+	}
+	defer func() { _ = os.RemoveAll(dir) }()
+	if err := r.checkout(ctx, dir); err != nil {
+		res.Error = err.Error()
+		return res
+	}
+
+	repoName := r.Suite.RepoName
+	if repoName == "" {
+		repoName = "bench/" + r.Suite.Name
+	}
+	start := time.Now()
+	out, err := run(ctx, p, executor.LocalRequest{RepoPath: dir, Prompt: c.Prompt, Repo: repoName, User: "bench"})
+	res.Duration = time.Since(start)
+	if out != nil {
+		res.CostUSD = out.CostUSD
+		res.Diff = measureDiff(out.Diff, c.ExpectFiles)
+	}
+	if err != nil {
+		res.Error = err.Error()
+		return res
+	}
+
+	check := c.Check
+	if check == "" {
+		check = r.Suite.Check
+	}
+	if check == "" {
+		res.Success = true
+		return res
+	}
+	cmd := exec.CommandContext(ctx, "sh", "-c", check)
+	cmd.Dir = dir
+	output, err := cmd.CombinedOutput()
+	res.Duration = time.Since(start)
+	res.Success = err == nil
+	if !res.Success {
+		res.CheckOutput = tail(string(output), checkOutputLimit)
+	}
+	return res
+}
+
+// checkout clones the suite's sample repository into dir.
+func (r *Runner) checkout(ctx context.Context, dir string) error {
+	steps := [][]string{{"clone", "-q", r.Suite.Repo, dir}}
+	if r.Suite.Ref != "" {
+		steps = append(steps, []string{"-C", dir, "checkout", "-q", r.Suite.Ref})
+	}
+	for _, args := range steps {
+		var stderr bytes.Buffer
+		cmd := exec.CommandContext(ctx, "git", args...)
+		cmd.Stderr = &stderr
+		if err := cmd.Run(); err != nil {
+			return fmt.Errorf("git %s: %w: %s", args[0], err, strings.TrimSpace(stderr.String()))
+		}
+	}
+	return nil
+}
+
+// measureDiff counts the files and lines a git patch changes, and how well
+// it matches expected.
+func measureDiff(patch string, expected []string) Diff {
+	d := Diff{ExpectedTotal: len(expected)}
+	var files []string
+	for _, line := range strings.Split(patch, "\n") {
+		switch {
+		case strings.HasPrefix(line, "diff --git "):
+			path := strings.TrimPrefix(line, "diff --git ")
+			if _, b, ok := strings.Cut(path, " b/"); ok {
+				path = b
+			}
+			files = append(files, path)
+		case strings.HasPrefix(line, "+++ "), strings.HasPrefix(line, "--- "):
+		case strings.HasPrefix(line, "+"):
+			d.LinesAdded++
+		case strings.HasPrefix(line, "-"):
+			d.LinesRemoved++
+		}
+	}
+	d.FilesChanged = len(files)
+	for _, f := range files {
+		if slices.Contains(expected, f) {
+			d.ExpectedHit++
+		} else {
+			d.Unexpected++
+		}
+	}
+	return d
+}
+
+// tail returns the last n bytes of s.
+func tail(s string, n int) string {
+	if len(s) <= n {
+		return s
+	}
+	return "…" + s[len(s)-n:]
+}
@@ -0,0 +1,229 @@
+// Package budget tracks what each organization spends on provider runs in
+// the current month against the budget the operator gave it, so the agent
+// can warn as a budget runs low and refuse tasks past the hard cap.
+package budget
+
+import (
+	"encoding/json"
+	"fmt"
+	"maps"
+	"math"
+	"os"
+	"path/filepath"
+	"slices"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+)
+
+// DefaultWarnPercent is the share of a budget spent from which tasks warn.
+const DefaultWarnPercent = 80
+
+// Limits are the monthly budgets, in USD, and how they are enforced.
+type Limits struct {
+	Default float64            // organizations not in Orgs (0: no budget)
+	Orgs    map[string]float64 // by lower-case organization; 0 lifts the budget
+	// WarnPercent is the share of the budget spent from which the tracking
+	// comments of finished tasks warn (0: DefaultWarnPercent)
+	WarnPercent int
+	// HardCapPercent is the share of the budget spent from which new tasks
+	// are refused (0: never refused, the budget only warns)
+	HardCapPercent int
+}
+
+// Status is an organization's spending this month.
+type Status struct {
+	Org        string  `json:"org"`
+	Month      string  `json:"month"` // YYYY-MM, UTC
+	SpentUSD   float64 `json:"spent_usd"`
+	BudgetUSD  float64 `json:"budget_usd,omitempty"`   // 0: no budget
+	HardCapUSD float64 `json:"hard_cap_usd,omitempty"` // 0: no hard cap
+	Warning    bool    `json:"warning"`                // WarnPercent of the budget spent
+	Exhausted  bool    `json:"exhausted"`              // hard cap reached; tasks are refused
+}
+
+// Percent is the share of the budget spent, rounded down.
+func (s Status) Percent() int {
+	if s.BudgetUSD <= 0 {
+		return 0
+	}
+	return int(math.Floor(s.SpentUSD / s.BudgetUSD * 100))
+}
+
+// spend is what an organization spent in month.
+type spend struct {
+	Month string  `json:"month"`
+	USD   float64 `json:"usd"`
+}
+
+// Tracker adds up the spending of each organization by month. With a path
+// it is kept in a JSON file, rewritten on every change, so a restart does
+// not forget it.
+type Tracker struct {
+	mu     sync.Mutex
+	path   string
+	limits Limits
+	spent  map[string]spend // by lower-case organization
+}
+
+// allow tests to control time
+var now = time.Now
+
+// Open opens (or creates) the tracker kept at path; an empty path keeps the
+// spending in memory only.
+func Open(path string) (*Tracker, error) {
+	t := &Tracker{path: path, spent: make(map[string]spend)}
+	if path == "" {
+		return t, nil
+	}
+	if err := os.MkdirAll(filepath.Dir(path), 0o755); err != nil {
+		return nil, fmt.Errorf("create budget dir: %w", err)
+	}
+	data, err := os.ReadFile(path)
+	if os.IsNotExist(err) {
+		return t, nil
+	}
+	if err != nil {
+		return nil, fmt.Errorf("open budget file: %w", err)
+	}
+	if err := json.Unmarshal(data, &t.spent); err != nil {
+		return nil, fmt.Errorf("parse budget file %s: %w", path, err)
+	}
+	return t, nil
+}
+
+// ParseOrgBudgets parses org=USD entries.
+func ParseOrgBudgets(entries []string) (map[string]float64, error) {
+	orgs := make(map[string]float64, len(entries))
+	for _, entry := range entries {
+		org, value, ok := strings.Cut(entry, "=")
+		org = strings.ToLower(strings.TrimSpace(org))
+		usd, err := strconv.ParseFloat(strings.TrimSpace(value), 64)
+		if !ok || org == "" || strings.Contains(org, "/") || err != nil || !(usd >= 0) || math.IsInf(usd, 1) {
+			return nil, fmt.Errorf("org budget %q must be org=USD", entry)
+		}
+		orgs[org] = usd
+	}
+	return orgs, nil
+}
+
+// Org is the organization repo (owner/name) belongs to.
+func Org(repo string) string {
+	org, _, _ := strings.Cut(strings.ToLower(strings.TrimSpace(repo)), "/")
+	return org
+}
+
+// SetLimits replaces the budgets and how they are enforced.
+func (t *Tracker) SetLimits(l Limits) {
+	t.mu.Lock()
+	defer t.mu.Unlock()
+	l.Orgs = maps.Clone(l.Orgs)
+	t.limits = l
+}
+
+// Status reports what org spent this month.
+func (t *Tracker) Status(org string) Status {
+	t.mu.Lock()
+	defer t.mu.Unlock()
+	return t.statusLocked(strings.ToLower(org), month(now()))
+}
+
+// List reports the organizations with a budget or spending this month, by
+// name.
+func (t *Tracker) List() []Status {
+	t.mu.Lock()
+	defer t.mu.Unlock()
+	m := month(now())
+	orgs := make(map[string]bool)
+	for org := range t.limits.Orgs {
+		orgs[org] = true
+	}
+	for org, s := range t.spent {
+		if s.Month == m {
+			orgs[org] = true
+		}
+	}
+	out := make([]Status, 0, len(orgs))
+	for _, org := range slices.Sorted(maps.Keys(orgs)) {
+		out = append(out, t.statusLocked(org, m))
+	}
+	return out
+}
+
+// Add records usd spent by org and returns its status afterwards, with
+// crossed set when the spending reached the warning threshold with it.
+func (t *Tracker) Add(org string, usd float64) (status Status, crossed bool, err error) {
+	t.mu.Lock()
+	defer t.mu.Unlock()
+	org = strings.ToLower(org)
+	m := month(now())
+	before := t.statusLocked(org, m)
+	s := t.spent[org]
+	if s.Month != m {
+		s = spend{Month: m}
+	}
+	s.USD += usd
+	t.spent[org] = s
+	status = t.statusLocked(org, m)
+	return status, status.Warning && !before.Warning, t.saveLocked()
+}
+
+// Reset forgets what org spent this month, lifting its warning and hard
+// cap until it spends again.
+func (t *Tracker) Reset(org string) (Status, error) {
+	t.mu.Lock()
+	defer t.mu.Unlock()
+	org = strings.ToLower(org)
+	delete(t.spent, org)
+	return t.statusLocked(org, month(now())), t.saveLocked()
+}
+
+func (t *Tracker) statusLocked(org, m string) Status {
+	st := Status{Org: org, Month: m}
+	if s := t.spent[org]; s.Month == m {
+		st.SpentUSD = s.USD
+	}
+	st.BudgetUSD = t.limits.Default
+	if usd, ok := t.limits.Orgs[org]; ok {
+		st.BudgetUSD = usd
+	}
+	if st.BudgetUSD <= 0 {
+		return st
+	}
+	warn := t.limits.WarnPercent
+	if warn <= 0 {
+		warn = DefaultWarnPercent
+	}
+	st.Warning = st.SpentUSD >= st.BudgetUSD*float64(warn)/100
+	if t.limits.HardCapPercent > 0 {
+		st.HardCapUSD = st.BudgetUSD * float64(t.limits.HardCapPercent) / 100
+		st.Exhausted = st.SpentUSD >= st.HardCapUSD
+	}
+	return st
+}
+
+// saveLocked rewrites the file with this month's spending.
+func (t *Tracker) saveLocked() error {
+	if t.path == "" {
+		return nil
+	}
+	m := month(now())
+	maps.DeleteFunc(t.spent, func(_ string, s spend) bool { return s.Month != m })
+	data, err := json.MarshalIndent(t.spent, "", "  ")
+	if err != nil {
+		return fmt.Errorf("encode budget file: %w", err)
+	}
+	tmp := t.path + ".tmp"
+	if err := os.WriteFile(tmp, append(data, '\n'), 0o600); err != nil {
+		return fmt.Errorf("write budget file: %w", err)
+	}
+	if err := os.Rename(tmp, t.path); err != nil {
+		return fmt.Errorf("write budget file: %w", err)
+	}
+	return nil
+}
+
+func month(t time.Time) string {
+	return t.UTC().Format("2006-01")
+}
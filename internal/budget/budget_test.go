@@ -0,0 +1,87 @@
+package budget
+
+import (
+	"path/filepath"
+	"testing"
+	"time"
+)
+
+func setNow(t *testing.T, at time.Time) {
+	t.Helper()
+	orig := now
+	now = func() time.Time { return at }
+	t.Cleanup(func() { now = orig })
+}
+
+func TestTracker_WarnsAndCapsByMonth(t *testing.T) {
+	setNow(t, time.Date(2026, 10, 5, 12, 0, 0, 0, time.UTC))
+	path := filepath.Join(t.TempDir(), "budget", "spend.json")
+	tr, err := Open(path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	tr.SetLimits(Limits{Default: 100, Orgs: map[string]float64{"oss": 0}, HardCapPercent: 100})
+
+	if st, crossed, err := tr.Add("Acme", 70); err != nil || crossed || st.Warning || st.Exhausted {
+		t.Fatalf("Add(70) = %+v, %t, %v", st, crossed, err)
+	}
+	st, crossed, err := tr.Add("acme", 15)
+	if err != nil || !crossed || !st.Warning || st.Exhausted || st.Percent() != 85 {
+		t.Fatalf("Add(15) = %+v, %t, %v; want the warning crossed", st, crossed, err)
+	}
+	if st, crossed, _ := tr.Add("acme", 20); crossed || !st.Exhausted || st.HardCapUSD != 100 {
+		t.Fatalf("Add(20) = %+v, %t; want exhausted", st, crossed)
+	}
+	if st, _, _ := tr.Add("oss", 1000); st.Warning || st.Exhausted || st.BudgetUSD != 0 {
+		t.Fatalf("org without a budget: %+v", st)
+	}
+
+	// the spending survives a restart
+	reopened, err := Open(path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	reopened.SetLimits(Limits{Default: 100, HardCapPercent: 120})
+	if st := reopened.Status("ACME"); st.SpentUSD != 105 || st.Exhausted || st.HardCapUSD != 120 {
+		t.Fatalf("reopened status = %+v", st)
+	}
+	if list := reopened.List(); len(list) != 2 || list[0].Org != "acme" || list[1].Org != "oss" {
+		t.Fatalf("List() = %+v", list)
+	}
+
+	// and is forgotten next month, or when reset
+	setNow(t, time.Date(2026, 11, 1, 0, 0, 0, 0, time.UTC))
+	if st := reopened.Status("acme"); st.SpentUSD != 0 || st.Month != "2026-11" {
+		t.Fatalf("next month status = %+v", st)
+	}
+	setNow(t, time.Date(2026, 10, 31, 23, 0, 0, 0, time.UTC))
+	if st, err := reopened.Reset("acme"); err != nil || st.SpentUSD != 0 || st.Warning {
+		t.Fatalf("Reset = %+v, %v", st, err)
+	}
+	if again, err := Open(path); err != nil || again.Status("acme").SpentUSD != 0 || again.Status("oss").SpentUSD != 1000 {
+		t.Fatalf("reset not saved: %v", err)
+	}
+}
+
+func TestTracker_SoftBudgetOnly(t *testing.T) {
+	tr, err := Open("")
+	if err != nil {
+		t.Fatal(err)
+	}
+	tr.SetLimits(Limits{Default: 10, WarnPercent: 50})
+	if st, crossed, _ := tr.Add("acme", 50); !crossed || !st.Warning || st.Exhausted || st.HardCapUSD != 0 {
+		t.Fatalf("Add = %+v, %t; want a warning and no hard cap", st, crossed)
+	}
+}
+
+func TestParseOrgBudgets(t *testing.T) {
+	got, err := ParseOrgBudgets([]string{"Acme=250.5", " oss = 0 "})
+	if err != nil || len(got) != 2 || got["acme"] != 250.5 || got["oss"] != 0 {
+		t.Fatalf("ParseOrgBudgets = %v, %v", got, err)
+	}
+	for _, bad := range []string{"acme", "acme=x", "acme=-1", "=2", "acme/repo=2", "acme=Inf", "acme=NaN"} {
+		if _, err := ParseOrgBudgets([]string{bad}); err == nil {
+			t.Errorf("ParseOrgBudgets(%q) accepted", bad)
+		}
+	}
+}
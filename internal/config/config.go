@@ -2,15 +2,33 @@ package config
 
 import (
 	"fmt"
-	"log"
+	"log/slog"
+	"net/url"
 	"os"
+	"path/filepath"
 	"strconv"
 	"strings"
 	"time"
 
+	"github.com/cexll/swe/internal/budget"
+	"github.com/cexll/swe/internal/dispatcher"
+	"github.com/cexll/swe/internal/executor"
+	"github.com/cexll/swe/internal/github/comment"
+	"github.com/cexll/swe/internal/integrations/jira"
+	"github.com/cexll/swe/internal/integrations/linear"
+	"github.com/cexll/swe/internal/integrations/slack"
+	"github.com/cexll/swe/internal/integrations/telegram"
+	"github.com/cexll/swe/internal/logging"
+	"github.com/cexll/swe/internal/notify"
+	"github.com/cexll/swe/internal/policy"
+	"github.com/cexll/swe/internal/prompt"
 	"github.com/cexll/swe/internal/provider"
 	"github.com/cexll/swe/internal/provider/claude"
 	"github.com/cexll/swe/internal/provider/codex"
+	"github.com/cexll/swe/internal/reposettings"
+	"github.com/cexll/swe/internal/schedule"
+	"github.com/cexll/swe/internal/storage"
+	"github.com/cexll/swe/internal/webhook"
 )
 
 // Config holds all configuration for the swe-agent service
@@ -29,6 +47,9 @@ type Config struct {
 	// Claude settings
 	ClaudeAPIKey string
 	ClaudeModel  string
+	// ClaudeStandbySessions caps Claude CLI sessions started ahead of their
+	// prompt while tasks prepare (0 disables)
+	ClaudeStandbySessions int
 
 	// Codex settings (uses OpenAI-compatible environment variables)
 	OpenAIAPIKey  string
@@ -38,6 +59,10 @@ type Config struct {
 	// Trigger settings
 	TriggerKeyword string
 
+	// Repositories the agent acts on (owner/name globs); empty allowlist allows all
+	RepoAllowlist []string
+	RepoDenylist  []string
+
 	// Security settings
 	DisallowedTools string
 
@@ -47,6 +72,27 @@ type Config struct {
 	EnableGitHubCIMCP      bool
 	UseCommitSigning       bool
 
+	// Let tasks that mention the wiki clone, edit and push repo.wiki.git
+	EnableWikiEditing bool
+
+	// /release mode: maintainers bump, tag and publish the default branch
+	EnableReleaseMode    bool
+	ReleaseScheme        string   // "semver" or "calver"
+	ReleaseTagPrefix     string   // e.g. "v" for v1.2.3
+	ReleaseVersionFiles  []string // files holding the current version string
+	ReleaseChangelog     string   // empty skips the changelog
+	ReleaseGitHubRelease bool     // publish a GitHub Release with drafted notes
+
+	// /triage mode: label the issue, look for duplicates, assign a priority
+	EnableTriageMode bool
+
+	// Approval mode: tasks post a plan and push only after another user
+	// with write access comments "<trigger> approve"
+	EnableApprovalMode bool
+
+	// Make every task a dry run, as if triggered with --dry-run
+	DefaultDryRun bool
+
 	// Dispatcher settings
 	DispatcherWorkers           int
 	DispatcherQueueSize         int
@@ -54,13 +100,308 @@ type Config struct {
 	DispatcherRetryInitial      time.Duration
 	DispatcherRetryMax          time.Duration
 	DispatcherBackoffMultiplier float64
+	DispatcherMaxRunTime        time.Duration // running tasks are cancelled after this; 0 never
+	DispatcherRequeueTimedOut   bool          // run a cancelled task once more
+	// DispatcherJournalPath is the JSON lines file keeping accepted tasks
+	// until they finish, requeued at startup; empty keeps none
+	DispatcherJournalPath string
+	// DispatcherOrgMaxTasks caps the tasks of each organization running at
+	// once (0: no cap); DispatcherOrgLimits (org=N) overrides it per
+	// organization
+	DispatcherOrgMaxTasks int
+	DispatcherOrgLimits   []string
+
+	// Audit log settings
+	AuditLogPath   string        // JSON lines file; empty keeps the audit log in memory
+	AuditRetention time.Duration // entries older than this are pruned; 0 keeps everything
+
+	// Permission check caching (seconds-resolution TTLs; 0 disables)
+	PermissionCacheTTL         time.Duration
+	PermissionCacheNegativeTTL time.Duration
+
+	// RepoSettingsFile holds per-repository trigger keywords, tools and
+	// instructions (JSON by owner/name); "" applies the server settings everywhere
+	RepoSettingsFile string
+
+	// PolicyFile holds JSON allow/deny rules deciding who may trigger tasks
+	// and releases; "" keeps the built-in installer and maintainer checks
+	PolicyFile string
+
+	// SchedulesFile holds recurring tasks run on cron schedules (a JSON
+	// array of jobs); "" schedules nothing
+	SchedulesFile string
+	// SchedulesStatePath keeps each job's last run and how far its schedule
+	// was followed (JSON), so runs missed while down are known after a
+	// restart; "" keeps it in memory
+	SchedulesStatePath string
+	SchedulesCatchUp   string // missed runs: "skip" (default) or "once"
+
+	// Bearer token for the operator API (POST /api/v1/tasks) and the admin
+	// endpoints; empty disables them
+	APIToken string
+	// AdminPublic serves /admin and /admin/api/stats without APIToken
+	AdminPublic bool
+
+	// HMAC key signing POST /webhook/generic requests from tools that are
+	// not GitHub; empty disables the endpoint
+	GenericWebhookSecret string
+
+	// Jira integration: comments on Jira issues start tasks; empty
+	// JiraWebhookSecret disables it
+	JiraBaseURL       string
+	JiraEmail         string
+	JiraAPIToken      string
+	JiraWebhookSecret string
+	JiraProjects      []string // PROJECT=owner/repo repositories each project may work in
+	JiraUsers         []string // ACCOUNTID=github-login identity mapping
+
+	// Linear integration: comments on Linear issues start tasks; empty
+	// LinearWebhookSecret disables it
+	LinearAPIKey        string
+	LinearWebhookSecret string
+	LinearTeams         []string // TEAM=owner/repo repositories each team may work in
+	LinearUsers         []string // USERID=github-login identity mapping
+
+	// Slack slash command (/swe) launching tasks; empty SlackSigningSecret
+	// disables it
+	SlackSigningSecret string
+	SlackBotToken      string
+	SlackUsers         []string // SLACKID=github-login identity mapping
+
+	// Telegram bot launching tasks; empty TelegramBotToken disables it
+	TelegramBotToken      string
+	TelegramWebhookSecret string   // webhook mode; empty polls for updates
+	TelegramChats         []string // allowlisted chat IDs
+	TelegramRepos         []string // owner/repo the bot may work on
+
+	// Webhook delivery tracking (replay protection)
+	DeliveryLogPath string        // JSON lines file; empty keeps deliveries in memory
+	DeliveryTTL     time.Duration // how long delivery GUIDs are remembered; 0 uses the default
+
+	// Monthly spending budgets per organization, in USD
+	BudgetMonthlyUSD     float64  // organizations not in BudgetOrgs (0: no budget)
+	BudgetOrgs           []string // org=USD budgets overriding it
+	BudgetWarnPercent    int      // share spent from which tracking comments warn
+	BudgetHardCapPercent int      // share spent from which tasks are refused (0: never)
+	BudgetPath           string   // JSON file keeping the spending; empty keeps it in memory
+
+	// Cost estimates before the provider runs: a triggered task estimated at
+	// CostConfirmUSD or more waits for "/code confirm" (0: never)
+	CostConfirmUSD float64
+	CostPerMTokUSD float64 // estimated cost of a run per million prompt tokens
+
+	// Post-push verification; a failing command withdraws the pushed change
+	VerifyCommand string
+	// VerifyScopedCommand replaces VerifyCommand for pull requests whose
+	// affected packages are known; "" always runs VerifyCommand.
+	VerifyScopedCommand string
+	VerifyTimeout       time.Duration
+	// VerifyArtifactDir keeps test reports, screenshots and logs of failed
+	// verification runs, one subdirectory per task; "" keeps none.
+	VerifyArtifactDir string
+
+	// EnvBootstrap runs the setup of the toolchains detected in a checkout
+	// (go.mod, package.json, pyproject.toml) before the provider.
+	// SetupCommands replaces a toolchain's built-in setup by name ("go",
+	// "node", "python"; "" skips it); SetupTimeout bounds each command
+	EnvBootstrap  bool
+	SetupCommands map[string]string
+	SetupTimeout  time.Duration
+
+	// CacheDir keeps the dependency caches of each repository (Go modules,
+	// npm/pnpm/yarn/pip/uv caches, node_modules) between tasks; "" disables
+	// them. CacheMaxSizeMB bounds them together, evicting the least
+	// recently used (0 keeps everything)
+	CacheDir       string
+	CacheMaxSizeMB int
+
+	// WorkspaceDir holds the checkouts of tasks; what earlier runs left in
+	// it is removed at startup ("" uses swe-workspaces in the system temp
+	// directory). WorkspaceMaxSizeMB bounds the checkouts together: tasks
+	// wait to clone while they are past it (0: no limit). With
+	// WorkspaceWorktrees, tasks check out git worktrees of one mirror per
+	// repository kept in WorkspaceDir instead of cloning
+	WorkspaceDir       string
+	WorkspaceMaxSizeMB int
+	WorkspaceWorktrees bool
+
+	// SubtaskParallelism lets the provider hand independent parts of a task
+	// to that many parallel provider runs, each in its own worktree; 0
+	// disables sub-tasks. SubtaskMax bounds the sub-tasks of one task (0
+	// uses the executor's default)
+	SubtaskParallelism int
+	SubtaskMax         int
+
+	// Storage is the shared blob backend (local, s3 or gcs) holding task
+	// artifacts under artifacts/ and offloaded task logs under logs/; an
+	// empty Backend disables both
+	Storage storage.Config
+
+	// ArtifactsStorage keeps each task's provider transcript, final diff and
+	// test output elsewhere than Storage: a directory (or file://),
+	// s3://bucket/prefix or gs://bucket/prefix
+	ArtifactsStorage   string
+	ArtifactsRetention time.Duration // artifacts older than this are pruned; 0 keeps everything
+
+	// LeaderLeaseTTL is how long the leader lease in Storage lasts without
+	// renewal. Replicas sharing Storage elect one leader to run periodic
+	// jobs; without Storage every instance runs them. 0 uses 30 seconds.
+	LeaderLeaseTTL time.Duration
+
+	// SecretScanRulesFile adds gitleaks-style rules to the built-in secret
+	// scan of pushed commits; "" uses the built-in rules only.
+	SecretScanRulesFile string
+
+	// BlockedPaths may not be changed by the agent's pushes (directories,
+	// files or globs); defaults to .github/workflows
+	BlockedPaths []string
+
+	// HeartbeatInterval is how often the tracking comment of a running task
+	// shows the elapsed time and the provider's latest step; 0 disables it
+	HeartbeatInterval time.Duration
+
+	// TaskTimeout bounds a task's run (clone, fetch, provider, verify) unless
+	// it asks for another with /code --timeout, which TaskMaxTimeout caps;
+	// 0 means no limit for either
+	TaskTimeout    time.Duration
+	TaskMaxTimeout time.Duration
+
+	// CommitStatus reports tasks as commit statuses named
+	// CommitStatusContext, linking to the task page under PublicURL
+	CommitStatus        bool
+	CommitStatusContext string
+	// KnowledgeDir keeps the summaries of finished tasks per repository;
+	// KnowledgePromptEntries related ones go into each new task's prompt.
+	// "" disables it
+	KnowledgeDir           string
+	KnowledgeMaxEntries    int
+	KnowledgePromptEntries int
+	// PromptDir holds prompt template overrides (system.tmpl, issue.tmpl,
+	// pr.tmpl, local.tmpl) for repositories without their own; "" uses the
+	// built-in prompt
+	PromptDir string
+	// CommentTemplateFile holds Go templates for the header, links, files
+	// and footer of tracking comments; "" uses the built-in layout
+	CommentTemplateFile string
+	// ReuseTrackingComment makes /code runs on an issue or pull request
+	// share one tracking comment that keeps a collapsible history of the
+	// earlier runs and their outcomes
+	ReuseTrackingComment bool
+	// MinimizeOutdatedComments hides the earlier tracking comments on an
+	// issue or pull request as outdated when a new task starts there
+	MinimizeOutdatedComments bool
+	// ContextMaxTokens bounds the GitHub context of a prompt (comments,
+	// reviews, file lists) in estimated tokens; 0 keeps it whole
+	ContextMaxTokens int
+	// RepoFileListMax bounds the entries of the repository file list in a
+	// prompt; 0 lists no files
+	RepoFileListMax int
+	// PRDiffMaxLines is the largest pull request, in added plus deleted
+	// lines, whose diff goes into the prompt; 0 embeds none
+	PRDiffMaxLines int
+	// FetchCacheTTL is how long GitHub data fetched for a task is reused by
+	// later tasks on the same unchanged issue or pull request; 0 disables
+	FetchCacheTTL time.Duration
+
+	// PublicURL is where this server is reachable from GitHub users
+	// (https://swe.example.com); "" adds no links to the task pages
+	PublicURL string
+
+	// Signed share links for task transcripts; empty secret disables them
+	ShareLinkSecret string
+	ShareLinkMaxTTL time.Duration
+
+	// How often CONFIG_FILE and .env are checked for changes; 0 reloads on SIGHUP only
+	ReloadPollInterval time.Duration
+
+	// ReusePort binds PORT with SO_REUSEPORT so a new version can start
+	// listening while the old one drains
+	ReusePort bool
+	// DrainTimeout bounds how long a draining server waits for queued and
+	// running tasks before exiting; 0 waits for all of them
+	DrainTimeout time.Duration
+
+	// LogLevel is the minimum level logged (debug, info, warn or error) and
+	// LogFormat the output format (text or json)
+	LogLevel  string
+	LogFormat string
+	// DebugTaskLogLevel is the level a task run with /code --debug logs
+	// from, whatever LogLevel is
+	DebugTaskLogLevel string
+
+	// Notification settings
+	Notify notify.Config
 }
 
-// Load loads configuration from environment variables
+// Load loads configuration from environment variables, falling back to the
+// YAML file named by CONFIG_FILE for variables that are unset.
 func Load() (*Config, error) {
+	if err := applyConfigFile(); err != nil {
+		return nil, err
+	}
+	cfg := fromEnv()
+
+	var problems []string
+	notifyCfg, err := loadNotifyConfig()
+	if err != nil {
+		problems = append(problems, err.Error())
+	}
+	cfg.Notify = notifyCfg
+
+	// Validate required fields, reporting every problem at once
+	problems = append(cfg.problems(), problems...)
+	if err := validationError(problems); err != nil {
+		return nil, err
+	}
+
+	return cfg, nil
+}
+
+// LoadProvider loads configuration but validates only the AI provider
+// settings, for commands that run without GitHub (e.g. `run`).
+func LoadProvider() (*Config, error) {
+	if err := applyConfigFile(); err != nil {
+		return nil, err
+	}
+	cfg := fromEnv()
+	if err := cfg.validateProviderConfig(); err != nil {
+		return nil, err
+	}
+	return cfg, nil
+}
+
+// storageFromEnv reads the shared blob backend; credentials stay in the
+// variables each backend reads itself (see storage.Config).
+func storageFromEnv() storage.Config {
+	return storage.Config{
+		Backend:  os.Getenv("STORAGE_BACKEND"),
+		Dir:      os.Getenv("STORAGE_DIR"),
+		Bucket:   os.Getenv("STORAGE_BUCKET"),
+		Prefix:   os.Getenv("STORAGE_PREFIX"),
+		Endpoint: os.Getenv("STORAGE_ENDPOINT"),
+		Region:   os.Getenv("STORAGE_REGION"),
+	}
+}
+
+// setupToolchains are the toolchains whose setup SETUP_<NAME> replaces.
+var setupToolchains = []string{"go", "node", "python"}
+
+// setupCommandsFromEnv reads the SETUP_<TOOLCHAIN> commands that are set,
+// including empty ones, which skip a toolchain's setup.
+func setupCommandsFromEnv() map[string]string {
+	commands := make(map[string]string)
+	for _, name := range setupToolchains {
+		if command, ok := os.LookupEnv("SETUP_" + strings.ToUpper(name)); ok {
+			commands[name] = strings.TrimSpace(command)
+		}
+	}
+	return commands
+}
+
+func fromEnv() *Config {
 	privateKey := normalizePrivateKey(os.Getenv("GITHUB_PRIVATE_KEY"))
 
-	cfg := &Config{
+	return &Config{
 		Port:                        getEnvInt("PORT", 8000),
 		GitHubAppID:                 os.Getenv("GITHUB_APP_ID"),
 		GitHubPrivateKey:            privateKey,
@@ -68,6 +409,7 @@ func Load() (*Config, error) {
 		Provider:                    getEnv("PROVIDER", "claude"),
 		ClaudeAPIKey:                os.Getenv("ANTHROPIC_API_KEY"),
 		ClaudeModel:                 getEnv("CLAUDE_MODEL", "claude-sonnet-4-5-20250929"),
+		ClaudeStandbySessions:       getEnvInt("CLAUDE_STANDBY_SESSIONS", 0),
 		OpenAIAPIKey:                os.Getenv("OPENAI_API_KEY"),
 		OpenAIBaseURL:               os.Getenv("OPENAI_BASE_URL"),
 		CodexModel:                  getEnv("CODEX_MODEL", "gpt-5-codex"),
@@ -83,16 +425,183 @@ func Load() (*Config, error) {
 		DispatcherRetryInitial:      time.Duration(getEnvInt("DISPATCHER_RETRY_SECONDS", 15)) * time.Second,
 		DispatcherRetryMax:          time.Duration(getEnvInt("DISPATCHER_RETRY_MAX_SECONDS", 300)) * time.Second,
 		DispatcherBackoffMultiplier: getEnvFloat("DISPATCHER_BACKOFF_MULTIPLIER", 2.0),
+		DispatcherMaxRunTime:        time.Duration(getEnvInt("DISPATCHER_MAX_RUN_MINUTES", 120)) * time.Minute,
+		DispatcherRequeueTimedOut:   getEnvBool("DISPATCHER_REQUEUE_TIMED_OUT"),
+		DispatcherJournalPath:       os.Getenv("DISPATCHER_JOURNAL_PATH"),
+		DispatcherOrgMaxTasks:       getEnvInt("DISPATCHER_ORG_MAX_TASKS", 0),
+		DispatcherOrgLimits:         getEnvList("DISPATCHER_ORG_LIMITS"),
+		AuditLogPath:                os.Getenv("AUDIT_LOG_PATH"),
+		AuditRetention:              time.Duration(getEnvInt("AUDIT_RETENTION_DAYS", 90)) * 24 * time.Hour,
+		PermissionCacheTTL:          time.Duration(getEnvInt("PERMISSION_CACHE_TTL_SECONDS", 300)) * time.Second,
+		PermissionCacheNegativeTTL:  time.Duration(getEnvInt("PERMISSION_CACHE_NEGATIVE_TTL_SECONDS", 60)) * time.Second,
+		PolicyFile:                  os.Getenv("POLICY_FILE"),
+		RepoSettingsFile:            os.Getenv("REPO_SETTINGS_FILE"),
+		SchedulesFile:               os.Getenv("SCHEDULES_FILE"),
+		SchedulesStatePath:          os.Getenv("SCHEDULES_STATE_PATH"),
+		SchedulesCatchUp:            os.Getenv("SCHEDULES_CATCH_UP"),
+		APIToken:                    os.Getenv("API_TOKEN"),
+		AdminPublic:                 getEnvBool("ADMIN_PUBLIC"),
+		GenericWebhookSecret:        os.Getenv("GENERIC_WEBHOOK_SECRET"),
+		JiraBaseURL:                 os.Getenv("JIRA_BASE_URL"),
+		JiraEmail:                   os.Getenv("JIRA_EMAIL"),
+		JiraAPIToken:                os.Getenv("JIRA_API_TOKEN"),
+		JiraWebhookSecret:           os.Getenv("JIRA_WEBHOOK_SECRET"),
+		JiraProjects:                getEnvList("JIRA_PROJECTS"),
+		JiraUsers:                   getEnvList("JIRA_USERS"),
+		LinearAPIKey:                os.Getenv("LINEAR_API_KEY"),
+		LinearWebhookSecret:         os.Getenv("LINEAR_WEBHOOK_SECRET"),
+		LinearTeams:                 getEnvList("LINEAR_TEAMS"),
+		LinearUsers:                 getEnvList("LINEAR_USERS"),
+		SlackSigningSecret:          os.Getenv("SLACK_SIGNING_SECRET"),
+		SlackBotToken:               os.Getenv("SLACK_BOT_TOKEN"),
+		SlackUsers:                  getEnvList("SLACK_USERS"),
+		TelegramBotToken:            os.Getenv("TELEGRAM_BOT_TOKEN"),
+		TelegramWebhookSecret:       os.Getenv("TELEGRAM_WEBHOOK_SECRET"),
+		TelegramChats:               getEnvList("TELEGRAM_CHAT_IDS"),
+		TelegramRepos:               getEnvList("TELEGRAM_REPOS"),
+		DeliveryLogPath:             os.Getenv("DELIVERY_LOG_PATH"),
+		DeliveryTTL:                 time.Duration(getEnvInt("DELIVERY_TTL_HOURS", 72)) * time.Hour,
+		BudgetMonthlyUSD:            getEnvFloat("BUDGET_MONTHLY_USD", 0),
+		BudgetOrgs:                  getEnvList("BUDGET_ORGS"),
+		BudgetWarnPercent:           getEnvInt("BUDGET_WARN_PERCENT", budget.DefaultWarnPercent),
+		BudgetHardCapPercent:        getEnvInt("BUDGET_HARD_CAP_PERCENT", 100),
+		BudgetPath:                  os.Getenv("BUDGET_PATH"),
+		CostConfirmUSD:              getEnvFloat("COST_CONFIRM_USD", 0),
+		CostPerMTokUSD:              getEnvFloat("COST_PER_MTOK_USD", executor.DefaultCostPerMTokUSD),
+		VerifyCommand:               os.Getenv("VERIFY_COMMAND"),
+		VerifyScopedCommand:         os.Getenv("VERIFY_SCOPED_COMMAND"),
+		VerifyTimeout:               time.Duration(getEnvInt("VERIFY_TIMEOUT_SECONDS", 600)) * time.Second,
+		VerifyArtifactDir:           os.Getenv("VERIFY_ARTIFACT_DIR"),
+		EnvBootstrap:                getEnvBool("ENV_BOOTSTRAP"),
+		SetupCommands:               setupCommandsFromEnv(),
+		SetupTimeout:                time.Duration(getEnvInt("SETUP_TIMEOUT_SECONDS", 600)) * time.Second,
+		CacheDir:                    os.Getenv("CACHE_DIR"),
+		CacheMaxSizeMB:              getEnvInt("CACHE_MAX_SIZE_MB", 10240),
+		WorkspaceDir:                os.Getenv("WORKSPACE_DIR"),
+		WorkspaceMaxSizeMB:          getEnvInt("WORKSPACE_MAX_SIZE_MB", 0),
+		WorkspaceWorktrees:          getEnvBool("WORKSPACE_WORKTREES"),
+		SubtaskParallelism:          getEnvInt("SUBTASK_PARALLELISM", 0),
+		SubtaskMax:                  getEnvInt("SUBTASK_MAX", 6),
+		Storage:                     storageFromEnv(),
+		ArtifactsStorage:            os.Getenv("ARTIFACTS_STORAGE"),
+		ArtifactsRetention:          time.Duration(getEnvInt("ARTIFACTS_RETENTION_DAYS", 30)) * 24 * time.Hour,
+		LeaderLeaseTTL:              time.Duration(getEnvInt("LEADER_LEASE_SECONDS", 30)) * time.Second,
+		SecretScanRulesFile:         os.Getenv("SECRET_SCAN_RULES_FILE"),
+		BlockedPaths:                getEnvListOrEmpty("BLOCKED_PATHS", ".github/workflows"),
+		EnableWikiEditing:           getEnvBool("ENABLE_WIKI_EDITING"),
+		EnableReleaseMode:           getEnvBool("ENABLE_RELEASE_MODE"),
+		EnableTriageMode:            getEnvBool("ENABLE_TRIAGE_MODE"),
+		ReleaseScheme:               getEnv("RELEASE_SCHEME", "semver"),
+		ReleaseTagPrefix:            getEnvOrEmpty("RELEASE_TAG_PREFIX", "v"),
+		ReleaseVersionFiles:         getEnvList("RELEASE_VERSION_FILES"),
+		ReleaseChangelog:            getEnvOrEmpty("RELEASE_CHANGELOG", "CHANGELOG.md"),
+		ReleaseGitHubRelease:        getEnvBool("RELEASE_GITHUB_RELEASE"),
+		EnableApprovalMode:          getEnvBool("ENABLE_APPROVAL_MODE"),
+		DefaultDryRun:               getEnvBool("DEFAULT_DRY_RUN"),
+		RepoAllowlist:               getEnvList("REPO_ALLOWLIST"),
+		RepoDenylist:                getEnvList("REPO_DENYLIST"),
+		HeartbeatInterval:           time.Duration(getEnvInt("HEARTBEAT_MINUTES", 5)) * time.Minute,
+		TaskTimeout:                 time.Duration(getEnvInt("TASK_TIMEOUT_MINUTES", 30)) * time.Minute,
+		TaskMaxTimeout:              time.Duration(getEnvInt("TASK_MAX_TIMEOUT_MINUTES", 120)) * time.Minute,
+		CommitStatus:                getEnvBool("COMMIT_STATUS"),
+		CommitStatusContext:         getEnv("COMMIT_STATUS_CONTEXT", "swe-agent"),
+		PublicURL:                   strings.TrimRight(os.Getenv("PUBLIC_URL"), "/"),
+		KnowledgeDir:                os.Getenv("KNOWLEDGE_DIR"),
+		KnowledgeMaxEntries:         getEnvInt("KNOWLEDGE_MAX_ENTRIES", 200),
+		KnowledgePromptEntries:      getEnvInt("KNOWLEDGE_PROMPT_ENTRIES", 3),
+		PromptDir:                   os.Getenv("PROMPT_DIR"),
+		CommentTemplateFile:         os.Getenv("COMMENT_TEMPLATE_FILE"),
+		ReuseTrackingComment:        getEnvBool("REUSE_TRACKING_COMMENT"),
+		MinimizeOutdatedComments:    getEnvBool("MINIMIZE_OUTDATED_COMMENTS"),
+		ContextMaxTokens:            getEnvInt("CONTEXT_MAX_TOKENS", prompt.DefaultMaxContextTokens),
+		RepoFileListMax:             getEnvInt("REPO_FILE_LIST_MAX", prompt.DefaultRepoFileListMax),
+		PRDiffMaxLines:              getEnvInt("PR_DIFF_MAX_LINES", 500),
+		FetchCacheTTL:               time.Duration(getEnvInt("FETCH_CACHE_TTL_SECONDS", 600)) * time.Second,
+		ShareLinkSecret:             os.Getenv("SHARE_LINK_SECRET"),
+		ShareLinkMaxTTL:             time.Duration(getEnvInt("SHARE_LINK_MAX_TTL_HOURS", 168)) * time.Hour,
+		ReloadPollInterval:          time.Duration(getEnvInt("RELOAD_POLL_SECONDS", 0)) * time.Second,
+		ReusePort:                   getEnvBool("REUSE_PORT"),
+		DrainTimeout:                time.Duration(getEnvInt("DRAIN_TIMEOUT_SECONDS", 1800)) * time.Second,
+		LogLevel:                    getEnv("LOG_LEVEL", "info"),
+		LogFormat:                   getEnv("LOG_FORMAT", logging.FormatText),
+		DebugTaskLogLevel:           getEnv("DEBUG_TASK_LOG_LEVEL", "debug"),
+	}
+}
+
+// loadNotifyConfig reads global notification endpoints (NOTIFY_SLACK_WEBHOOK_URL,
+// NOTIFY_DISCORD_WEBHOOK_URL, NOTIFY_WEBHOOK_URL filtered by NOTIFY_EVENTS) and
+// per-repository overrides from NOTIFY_REPOS (JSON).
+func loadNotifyConfig() (notify.Config, error) {
+	var cfg notify.Config
+
+	events, err := notify.ParseEvents(os.Getenv("NOTIFY_EVENTS"))
+	if err != nil {
+		return cfg, fmt.Errorf("NOTIFY_EVENTS: %w", err)
+	}
+	for _, ep := range []struct{ kind, env string }{
+		{"slack", "NOTIFY_SLACK_WEBHOOK_URL"},
+		{"discord", "NOTIFY_DISCORD_WEBHOOK_URL"},
+		{"webhook", "NOTIFY_WEBHOOK_URL"},
+	} {
+		if url := os.Getenv(ep.env); url != "" {
+			cfg.Global = append(cfg.Global, notify.Endpoint{Type: ep.kind, URL: url, Events: events})
+		}
 	}
 
-	// Validate required fields
-	if err := cfg.validate(); err != nil {
-		return nil, err
+	repos, err := notify.ParseRepoEndpoints(os.Getenv("NOTIFY_REPOS"))
+	if err != nil {
+		return cfg, fmt.Errorf("NOTIFY_REPOS: %w", err)
 	}
+	cfg.Repos = repos
 
+	email, err := loadEmailConfig()
+	if err != nil {
+		return cfg, err
+	}
+	cfg.Email = email
 	return cfg, nil
 }
 
+// loadEmailConfig reads the SMTP failure channel. It is enabled by NOTIFY_EMAIL_TO.
+func loadEmailConfig() (*notify.EmailConfig, error) {
+	var to []string
+	for _, addr := range strings.Split(os.Getenv("NOTIFY_EMAIL_TO"), ",") {
+		if addr = strings.TrimSpace(addr); addr != "" {
+			to = append(to, addr)
+		}
+	}
+	if len(to) == 0 {
+		return nil, nil
+	}
+
+	events, err := notify.ParseEvents(getEnv("NOTIFY_EMAIL_EVENTS", "failed,dead_lettered"))
+	if err != nil {
+		return nil, fmt.Errorf("NOTIFY_EMAIL_EVENTS: %w", err)
+	}
+	digestMinutes := getEnvInt("NOTIFY_EMAIL_DIGEST_MINUTES", 0)
+	if digestMinutes < 0 {
+		return nil, fmt.Errorf("NOTIFY_EMAIL_DIGEST_MINUTES must be >= 0")
+	}
+
+	email := &notify.EmailConfig{
+		Host:     os.Getenv("SMTP_HOST"),
+		Port:     getEnvInt("SMTP_PORT", 587),
+		Username: os.Getenv("SMTP_USERNAME"),
+		Password: os.Getenv("SMTP_PASSWORD"),
+		From:     getEnv("NOTIFY_EMAIL_FROM", os.Getenv("SMTP_USERNAME")),
+		To:       to,
+		Events:   events,
+		Digest:   time.Duration(digestMinutes) * time.Minute,
+	}
+	if email.Host == "" {
+		return nil, fmt.Errorf("SMTP_HOST is required when NOTIFY_EMAIL_TO is set")
+	}
+	if email.From == "" {
+		return nil, fmt.Errorf("NOTIFY_EMAIL_FROM is required when NOTIFY_EMAIL_TO is set")
+	}
+	return email, nil
+}
+
 func normalizePrivateKey(value string) string {
 	trimmed := strings.TrimSpace(value)
 	if trimmed == "" {
@@ -120,29 +629,210 @@ func normalizePrivateKey(value string) string {
 
 // validate checks that all required configuration is present
 func (c *Config) validate() error {
-	if err := c.validateGitHubCredentials(); err != nil {
-		return err
+	return validationError(c.problems())
+}
+
+// problems lists every missing or invalid setting.
+func (c *Config) problems() []string {
+	var problems []string
+	check := func(err error) {
+		if err != nil {
+			problems = append(problems, err.Error())
+		}
 	}
 
-	if err := c.validateProviderConfig(); err != nil {
-		return err
+	for _, required := range []struct{ env, value string }{
+		{"GITHUB_APP_ID", c.GitHubAppID},
+		{"GITHUB_PRIVATE_KEY", c.GitHubPrivateKey},
+		{"GITHUB_WEBHOOK_SECRET", c.GitHubWebhookSecret},
+	} {
+		if required.value == "" {
+			problems = append(problems, required.env+" is required")
+		}
 	}
 
+	check(c.validateProviderConfig())
+
 	c.applyDispatcherDefaults()
-	return c.validateDispatcherConfig()
-}
+	check(c.validateDispatcherConfig())
 
-func (c *Config) validateGitHubCredentials() error {
-	if c.GitHubAppID == "" {
-		return fmt.Errorf("GITHUB_APP_ID is required")
+	if c.AuditRetention < 0 {
+		problems = append(problems, "AUDIT_RETENTION_DAYS must be >= 0")
 	}
-	if c.GitHubPrivateKey == "" {
-		return fmt.Errorf("GITHUB_PRIVATE_KEY is required")
+	if c.ClaudeStandbySessions < 0 {
+		problems = append(problems, "CLAUDE_STANDBY_SESSIONS must be >= 0")
 	}
-	if c.GitHubWebhookSecret == "" {
-		return fmt.Errorf("GITHUB_WEBHOOK_SECRET is required")
+	if c.DeliveryTTL < 0 {
+		problems = append(problems, "DELIVERY_TTL_HOURS must be >= 0")
 	}
-	return nil
+	if !(c.BudgetMonthlyUSD >= 0) {
+		problems = append(problems, "BUDGET_MONTHLY_USD must be >= 0")
+	}
+	if _, err := budget.ParseOrgBudgets(c.BudgetOrgs); err != nil {
+		problems = append(problems, "BUDGET_ORGS: "+err.Error())
+	}
+	if c.BudgetWarnPercent < 0 || c.BudgetWarnPercent > 100 {
+		problems = append(problems, "BUDGET_WARN_PERCENT must be between 0 and 100")
+	}
+	if c.BudgetHardCapPercent < 0 {
+		problems = append(problems, "BUDGET_HARD_CAP_PERCENT must be >= 0")
+	}
+	if !(c.CostConfirmUSD >= 0) || !(c.CostPerMTokUSD >= 0) {
+		problems = append(problems, "COST_CONFIRM_USD and COST_PER_MTOK_USD must be >= 0")
+	}
+	if c.VerifyTimeout < 0 {
+		problems = append(problems, "VERIFY_TIMEOUT_SECONDS must be >= 0")
+	}
+	if c.VerifyScopedCommand != "" && c.VerifyCommand == "" {
+		problems = append(problems, "VERIFY_SCOPED_COMMAND requires VERIFY_COMMAND (run when the affected packages are unknown)")
+	}
+	if c.SetupTimeout < 0 {
+		problems = append(problems, "SETUP_TIMEOUT_SECONDS must be >= 0")
+	}
+	if c.CacheMaxSizeMB < 0 {
+		problems = append(problems, "CACHE_MAX_SIZE_MB must be >= 0")
+	}
+	if c.WorkspaceMaxSizeMB < 0 {
+		problems = append(problems, "WORKSPACE_MAX_SIZE_MB must be >= 0")
+	}
+	if c.SubtaskParallelism < 0 {
+		problems = append(problems, "SUBTASK_PARALLELISM must be >= 0")
+	}
+	if c.SubtaskMax < 0 {
+		problems = append(problems, "SUBTASK_MAX must be >= 0")
+	}
+	if c.ArtifactsRetention < 0 {
+		problems = append(problems, "ARTIFACTS_RETENTION_DAYS must be >= 0")
+	}
+	if c.LeaderLeaseTTL < 0 {
+		problems = append(problems, "LEADER_LEASE_SECONDS must be >= 0")
+	}
+	if c.Storage.Backend != "" {
+		if _, err := storage.New(c.Storage); err != nil {
+			problems = append(problems, "STORAGE_BACKEND: "+err.Error())
+		}
+	}
+	if c.ArtifactsStorage != "" {
+		if _, err := storage.Open(c.ArtifactsStorage); err != nil {
+			problems = append(problems, "ARTIFACTS_STORAGE: "+err.Error())
+		}
+	}
+	if c.HeartbeatInterval < 0 {
+		problems = append(problems, "HEARTBEAT_MINUTES must be >= 0")
+	}
+	if c.TaskTimeout < 0 || c.TaskMaxTimeout < 0 {
+		problems = append(problems, "TASK_TIMEOUT_MINUTES and TASK_MAX_TIMEOUT_MINUTES must be >= 0")
+	} else if c.TaskMaxTimeout > 0 && (c.TaskTimeout == 0 || c.TaskTimeout > c.TaskMaxTimeout) {
+		problems = append(problems, "TASK_TIMEOUT_MINUTES must be between 1 and TASK_MAX_TIMEOUT_MINUTES")
+	}
+	if c.KnowledgeMaxEntries < 0 || c.KnowledgePromptEntries < 0 {
+		problems = append(problems, "KNOWLEDGE_MAX_ENTRIES and KNOWLEDGE_PROMPT_ENTRIES must be >= 0")
+	}
+	if err := prompt.CheckTemplateDir(c.PromptDir); err != nil {
+		problems = append(problems, "PROMPT_DIR: "+err.Error())
+	}
+	if _, err := comment.LoadLayout(c.CommentTemplateFile); err != nil {
+		problems = append(problems, "COMMENT_TEMPLATE_FILE: "+err.Error())
+	}
+	if c.ContextMaxTokens < 0 || c.RepoFileListMax < 0 || c.PRDiffMaxLines < 0 {
+		problems = append(problems, "CONTEXT_MAX_TOKENS, REPO_FILE_LIST_MAX and PR_DIFF_MAX_LINES must be >= 0")
+	}
+	if c.FetchCacheTTL < 0 {
+		problems = append(problems, "FETCH_CACHE_TTL_SECONDS must be >= 0")
+	}
+	if c.PublicURL != "" {
+		if u, err := url.Parse(c.PublicURL); err != nil || (u.Scheme != "http" && u.Scheme != "https") || u.Host == "" {
+			problems = append(problems, "PUBLIC_URL must be an http(s) URL")
+		}
+	}
+	if c.ShareLinkMaxTTL < 0 {
+		problems = append(problems, "SHARE_LINK_MAX_TTL_HOURS must be >= 0")
+	}
+	if c.JiraWebhookSecret != "" {
+		if u, err := url.Parse(c.JiraBaseURL); err != nil || (u.Scheme != "http" && u.Scheme != "https") || u.Host == "" {
+			problems = append(problems, "JIRA_BASE_URL must be an http(s) URL when JIRA_WEBHOOK_SECRET is set")
+		}
+		if c.JiraEmail == "" || c.JiraAPIToken == "" {
+			problems = append(problems, "JIRA_EMAIL and JIRA_API_TOKEN are required when JIRA_WEBHOOK_SECRET is set")
+		}
+		if _, err := jira.ParseProjects(c.JiraProjects); err != nil {
+			problems = append(problems, "JIRA_PROJECTS: "+err.Error())
+		}
+		if _, err := jira.ParseUsers(c.JiraUsers); err != nil {
+			problems = append(problems, "JIRA_USERS: "+err.Error())
+		}
+	}
+	if c.LinearWebhookSecret != "" {
+		if c.LinearAPIKey == "" {
+			problems = append(problems, "LINEAR_API_KEY is required when LINEAR_WEBHOOK_SECRET is set")
+		}
+		if _, err := linear.ParseTeams(c.LinearTeams); err != nil {
+			problems = append(problems, "LINEAR_TEAMS: "+err.Error())
+		}
+		if _, err := linear.ParseUsers(c.LinearUsers); err != nil {
+			problems = append(problems, "LINEAR_USERS: "+err.Error())
+		}
+	}
+	if c.SlackSigningSecret != "" {
+		if c.SlackBotToken == "" {
+			problems = append(problems, "SLACK_BOT_TOKEN is required when SLACK_SIGNING_SECRET is set")
+		}
+		if _, err := slack.ParseUsers(c.SlackUsers); err != nil {
+			problems = append(problems, "SLACK_USERS: "+err.Error())
+		}
+	}
+	if c.TelegramBotToken != "" {
+		if len(c.TelegramChats) == 0 || len(c.TelegramRepos) == 0 {
+			problems = append(problems, "TELEGRAM_CHAT_IDS and TELEGRAM_REPOS are required when TELEGRAM_BOT_TOKEN is set")
+		}
+		if _, err := telegram.ParseChats(c.TelegramChats); err != nil {
+			problems = append(problems, "TELEGRAM_CHAT_IDS: "+err.Error())
+		}
+		if err := telegram.CheckRepos(c.TelegramRepos); err != nil {
+			problems = append(problems, "TELEGRAM_REPOS: "+err.Error())
+		}
+	}
+	if _, err := webhook.NewRepoFilter(c.RepoAllowlist, c.RepoDenylist); err != nil {
+		problems = append(problems, "REPO_ALLOWLIST/REPO_DENYLIST: "+err.Error())
+	}
+	if _, err := reposettings.Load(c.RepoSettingsFile); err != nil {
+		problems = append(problems, "REPO_SETTINGS_FILE: "+err.Error())
+	}
+	if _, err := policy.Load(c.PolicyFile); err != nil {
+		problems = append(problems, "POLICY_FILE: "+err.Error())
+	}
+	if _, err := schedule.Load(c.SchedulesFile); err != nil {
+		problems = append(problems, "SCHEDULES_FILE: "+err.Error())
+	}
+	if catchUp, err := schedule.ParseCatchUp(c.SchedulesCatchUp); err != nil {
+		problems = append(problems, "SCHEDULES_CATCH_UP: "+err.Error())
+	} else if catchUp == schedule.CatchUpOnce && c.SchedulesStatePath == "" {
+		problems = append(problems, "SCHEDULES_CATCH_UP=once needs SCHEDULES_STATE_PATH")
+	}
+	if c.ReloadPollInterval < 0 {
+		problems = append(problems, "RELOAD_POLL_SECONDS must be >= 0")
+	}
+	if c.DrainTimeout < 0 {
+		problems = append(problems, "DRAIN_TIMEOUT_SECONDS must be >= 0")
+	}
+	if _, err := logging.ParseLevel(c.LogLevel); err != nil {
+		problems = append(problems, "LOG_LEVEL: "+err.Error())
+	}
+	if _, err := logging.ParseLevel(c.DebugTaskLogLevel); err != nil {
+		problems = append(problems, "DEBUG_TASK_LOG_LEVEL: "+err.Error())
+	}
+	if c.LogFormat != "" && !logging.ValidFormat(c.LogFormat) {
+		problems = append(problems, fmt.Sprintf("LOG_FORMAT must be text or json, got %q", c.LogFormat))
+	}
+	if c.ReleaseScheme != "" && c.ReleaseScheme != "semver" && c.ReleaseScheme != "calver" {
+		problems = append(problems, fmt.Sprintf("RELEASE_SCHEME must be semver or calver, got %q", c.ReleaseScheme))
+	}
+	for _, f := range append(append([]string{}, c.ReleaseVersionFiles...), c.ReleaseChangelog) {
+		if f != "" && !filepath.IsLocal(f) {
+			problems = append(problems, fmt.Sprintf("RELEASE_VERSION_FILES/RELEASE_CHANGELOG: %q must be a path inside the repository", f))
+		}
+	}
+	return problems
 }
 
 func (c *Config) validateProviderConfig() error {
@@ -153,7 +843,7 @@ func (c *Config) validateProviderConfig() error {
 		}
 	case "codex":
 		if c.OpenAIAPIKey == "" {
-			log.Printf("Warning: OPENAI_API_KEY not set, using default OpenAI credentials")
+			slog.Warn("OPENAI_API_KEY not set, using default OpenAI credentials")
 		}
 	default:
 		return fmt.Errorf("invalid provider: %s (must be 'claude' or 'codex')", c.Provider)
@@ -201,6 +891,15 @@ func (c *Config) validateDispatcherConfig() error {
 	if c.DispatcherBackoffMultiplier < 1 {
 		return fmt.Errorf("DISPATCHER_BACKOFF_MULTIPLIER must be >= 1")
 	}
+	if c.DispatcherMaxRunTime < 0 {
+		return fmt.Errorf("DISPATCHER_MAX_RUN_MINUTES must be >= 0")
+	}
+	if c.DispatcherOrgMaxTasks < 0 {
+		return fmt.Errorf("DISPATCHER_ORG_MAX_TASKS must be >= 0")
+	}
+	if _, err := dispatcher.ParseOrgLimits(c.DispatcherOrgLimits); err != nil {
+		return fmt.Errorf("DISPATCHER_ORG_LIMITS: %w", err)
+	}
 	return nil
 }
 
@@ -212,6 +911,14 @@ func getEnv(key, defaultValue string) string {
 	return defaultValue
 }
 
+// getEnvOrEmpty is getEnv, except that a variable set to "" stays empty
+func getEnvOrEmpty(key, defaultValue string) string {
+	if value, ok := os.LookupEnv(key); ok {
+		return value
+	}
+	return defaultValue
+}
+
 // getEnvInt gets environment variable as int with a default value
 func getEnvInt(key string, defaultValue int) int {
 	if value := os.Getenv(key); value != "" {
@@ -231,6 +938,26 @@ func getEnvFloat(key string, defaultValue float64) float64 {
 	return defaultValue
 }
 
+// getEnvList splits a comma-separated variable, dropping empty items
+func getEnvList(key string) []string {
+	var items []string
+	for _, item := range strings.Split(os.Getenv(key), ",") {
+		if item = strings.TrimSpace(item); item != "" {
+			items = append(items, item)
+		}
+	}
+	return items
+}
+
+// getEnvListOrEmpty is getEnvList with defaults, except that a variable set
+// to "" yields no items
+func getEnvListOrEmpty(key string, defaults ...string) []string {
+	if _, ok := os.LookupEnv(key); !ok {
+		return defaults
+	}
+	return getEnvList(key)
+}
+
 func getEnvBool(key string) bool {
 	v := os.Getenv(key)
 	if v == "" {
@@ -258,7 +985,9 @@ func (c *Config) NewProvider() (provider.Provider, error) {
 		if model == "" {
 			model = "claude-sonnet-4-5-20250929"
 		}
-		return claude.NewProvider(c.ClaudeAPIKey, model), nil
+		p := claude.NewProvider(c.ClaudeAPIKey, model)
+		p.SetStandby(c.ClaudeStandbySessions)
+		return p, nil
 
 	case "codex":
 		model := c.CodexModel
@@ -54,6 +54,9 @@ func TestLoad(t *testing.T) {
 				if cfg.DispatcherWorkers != 4 {
 					t.Errorf("DispatcherWorkers = %d, want 4", cfg.DispatcherWorkers)
 				}
+				if cfg.PermissionCacheTTL != 5*time.Minute || cfg.PermissionCacheNegativeTTL != time.Minute {
+					t.Errorf("PermissionCache TTLs = %v/%v, want 5m/1m", cfg.PermissionCacheTTL, cfg.PermissionCacheNegativeTTL)
+				}
 				if cfg.DispatcherQueueSize != 16 {
 					t.Errorf("DispatcherQueueSize = %d, want 16", cfg.DispatcherQueueSize)
 				}
@@ -272,6 +275,66 @@ func TestConfigValidateRetryWindow(t *testing.T) {
 	}
 }
 
+func TestConfigValidateOrgLimits(t *testing.T) {
+	cfg := &Config{
+		GitHubAppID:         "app",
+		GitHubPrivateKey:    "key",
+		GitHubWebhookSecret: "secret",
+		Provider:            "claude",
+		ClaudeAPIKey:        "api",
+		DispatcherOrgLimits: []string{"acme=4", "bigcorp"},
+	}
+	applyDispatcherDefaults(cfg)
+
+	err := cfg.validate()
+	if err == nil || !strings.Contains(err.Error(), "DISPATCHER_ORG_LIMITS") {
+		t.Fatalf("expected org limits error, got %v", err)
+	}
+}
+
+func TestConfigValidateBudgets(t *testing.T) {
+	cfg := &Config{
+		GitHubAppID:          "app",
+		GitHubPrivateKey:     "key",
+		GitHubWebhookSecret:  "secret",
+		Provider:             "claude",
+		ClaudeAPIKey:         "api",
+		BudgetOrgs:           []string{"acme=500", "oss=free"},
+		BudgetHardCapPercent: -1,
+		CostConfirmUSD:       -5,
+	}
+	applyDispatcherDefaults(cfg)
+
+	err := cfg.validate()
+	if err == nil || !strings.Contains(err.Error(), "BUDGET_ORGS") || !strings.Contains(err.Error(), "BUDGET_HARD_CAP_PERCENT") || !strings.Contains(err.Error(), "COST_CONFIRM_USD") {
+		t.Fatalf("expected budget errors, got %v", err)
+	}
+}
+
+func TestConfigValidateSchedulesCatchUp(t *testing.T) {
+	cfg := &Config{
+		GitHubAppID:         "app",
+		GitHubPrivateKey:    "key",
+		GitHubWebhookSecret: "secret",
+		Provider:            "claude",
+		ClaudeAPIKey:        "api",
+		SchedulesCatchUp:    "once",
+	}
+	applyDispatcherDefaults(cfg)
+
+	if err := cfg.validate(); err == nil || !strings.Contains(err.Error(), "needs SCHEDULES_STATE_PATH") {
+		t.Fatalf("expected state path error, got %v", err)
+	}
+	cfg.SchedulesStatePath = "/var/lib/swe-agent/schedules-state.json"
+	if err := cfg.validate(); err != nil {
+		t.Fatalf("validate: %v", err)
+	}
+	cfg.SchedulesCatchUp = "all"
+	if err := cfg.validate(); err == nil || !strings.Contains(err.Error(), "SCHEDULES_CATCH_UP") {
+		t.Fatalf("expected catch-up error, got %v", err)
+	}
+}
+
 func TestGetEnvFloat(t *testing.T) {
 	t.Setenv("TEST_FLOAT", "3.14")
 	if got := getEnvFloat("TEST_FLOAT", 1.0); got != 3.14 {
@@ -316,6 +379,7 @@ func TestConfig_validate(t *testing.T) {
 			cfg: &Config{
 				GitHubPrivateKey:    "test-key",
 				GitHubWebhookSecret: "test-secret",
+				Provider:            "claude",
 				ClaudeAPIKey:        "sk-ant-test",
 			},
 			wantErr: true,
@@ -326,6 +390,7 @@ func TestConfig_validate(t *testing.T) {
 			cfg: &Config{
 				GitHubAppID:         "123456",
 				GitHubWebhookSecret: "test-secret",
+				Provider:            "claude",
 				ClaudeAPIKey:        "sk-ant-test",
 			},
 			wantErr: true,
@@ -336,6 +401,7 @@ func TestConfig_validate(t *testing.T) {
 			cfg: &Config{
 				GitHubAppID:      "123456",
 				GitHubPrivateKey: "test-key",
+				Provider:         "claude",
 				ClaudeAPIKey:     "sk-ant-test",
 			},
 			wantErr: true,
@@ -513,3 +579,245 @@ func TestGetEnvInt(t *testing.T) {
 		})
 	}
 }
+
+func TestLoadNotifyConfig(t *testing.T) {
+	t.Setenv("NOTIFY_SLACK_WEBHOOK_URL", "https://hooks.slack.test/x")
+	t.Setenv("NOTIFY_WEBHOOK_URL", "https://example.test/hook")
+	t.Setenv("NOTIFY_EVENTS", "completed, failed")
+	t.Setenv("NOTIFY_REPOS", `{"acme/api":[{"type":"discord","url":"https://discord.test/y","events":["failed"]}]}`)
+
+	cfg, err := loadNotifyConfig()
+	if err != nil {
+		t.Fatalf("loadNotifyConfig error: %v", err)
+	}
+	if len(cfg.Global) != 2 || cfg.Global[0].Type != "slack" || cfg.Global[1].Type != "webhook" {
+		t.Fatalf("Global = %+v", cfg.Global)
+	}
+	if len(cfg.Global[0].Events) != 2 {
+		t.Fatalf("NOTIFY_EVENTS not applied: %+v", cfg.Global[0].Events)
+	}
+	if eps := cfg.Repos["acme/api"]; len(eps) != 1 || eps[0].Type != "discord" {
+		t.Fatalf("Repos = %+v", cfg.Repos)
+	}
+
+	t.Setenv("NOTIFY_EVENTS", "started")
+	if _, err := loadNotifyConfig(); err == nil {
+		t.Fatal("expected error for unknown NOTIFY_EVENTS entry")
+	}
+
+	t.Setenv("NOTIFY_EVENTS", "")
+	t.Setenv("NOTIFY_REPOS", `{"acme/api":[{"type":"teams","url":"https://x"}]}`)
+	if _, err := loadNotifyConfig(); err == nil {
+		t.Fatal("expected error for unknown notifier type")
+	}
+}
+
+func TestLoadEmailConfig(t *testing.T) {
+	t.Setenv("NOTIFY_EMAIL_TO", "")
+	if email, err := loadEmailConfig(); err != nil || email != nil {
+		t.Fatalf("email should be disabled without NOTIFY_EMAIL_TO, got %+v, %v", email, err)
+	}
+
+	t.Setenv("NOTIFY_EMAIL_TO", "ops@example.com, dev@example.com")
+	if _, err := loadEmailConfig(); err == nil {
+		t.Fatal("expected error without SMTP_HOST")
+	}
+
+	t.Setenv("SMTP_HOST", "smtp.example.com")
+	t.Setenv("SMTP_USERNAME", "bot@example.com")
+	t.Setenv("NOTIFY_EMAIL_DIGEST_MINUTES", "60")
+	email, err := loadEmailConfig()
+	if err != nil {
+		t.Fatalf("loadEmailConfig error: %v", err)
+	}
+	if email.Port != 587 || email.From != "bot@example.com" || len(email.To) != 2 || email.Digest != time.Hour {
+		t.Fatalf("unexpected email config: %+v", email)
+	}
+	if len(email.Events) != 2 || email.Events[1] != "dead_lettered" {
+		t.Fatalf("default events = %v", email.Events)
+	}
+}
+
+func TestLoadProvider(t *testing.T) {
+	t.Setenv("GITHUB_APP_ID", "")
+	t.Setenv("GITHUB_PRIVATE_KEY", "")
+	t.Setenv("GITHUB_WEBHOOK_SECRET", "")
+	t.Setenv("PROVIDER", "codex")
+	t.Setenv("CODEX_MODEL", "gpt-test")
+
+	cfg, err := LoadProvider()
+	if err != nil {
+		t.Fatalf("LoadProvider without GitHub credentials: %v", err)
+	}
+	if cfg.Provider != "codex" || cfg.CodexModel != "gpt-test" {
+		t.Fatalf("unexpected provider config: %s/%s", cfg.Provider, cfg.CodexModel)
+	}
+
+	t.Setenv("PROVIDER", "claude")
+	t.Setenv("ANTHROPIC_API_KEY", "")
+	if _, err := LoadProvider(); err == nil {
+		t.Fatal("expected error when claude key is missing")
+	}
+}
+
+func TestLoad_RepoFilter(t *testing.T) {
+	os.Clearenv()
+	t.Cleanup(os.Clearenv)
+	t.Setenv("GITHUB_APP_ID", "1")
+	t.Setenv("GITHUB_PRIVATE_KEY", "key")
+	t.Setenv("GITHUB_WEBHOOK_SECRET", "secret")
+	t.Setenv("ANTHROPIC_API_KEY", "sk-test")
+	t.Setenv("REPO_ALLOWLIST", " my-org/* , ,other/docs")
+	t.Setenv("REPO_DENYLIST", "my-org/secret")
+
+	cfg, err := Load()
+	if err != nil {
+		t.Fatalf("Load: %v", err)
+	}
+	if strings.Join(cfg.RepoAllowlist, "|") != "my-org/*|other/docs" || strings.Join(cfg.RepoDenylist, "|") != "my-org/secret" {
+		t.Fatalf("allow=%q deny=%q", cfg.RepoAllowlist, cfg.RepoDenylist)
+	}
+
+	t.Setenv("REPO_ALLOWLIST", "my-org")
+	if _, err := Load(); err == nil || !strings.Contains(err.Error(), `REPO_ALLOWLIST/REPO_DENYLIST: repository pattern "my-org" must be owner/name`) {
+		t.Fatalf("expected pattern error, got %v", err)
+	}
+}
+
+func TestLoad_ReleaseMode(t *testing.T) {
+	os.Clearenv()
+	t.Cleanup(os.Clearenv)
+	t.Setenv("GITHUB_APP_ID", "1")
+	t.Setenv("GITHUB_PRIVATE_KEY", "key")
+	t.Setenv("GITHUB_WEBHOOK_SECRET", "secret")
+	t.Setenv("ANTHROPIC_API_KEY", "sk-test")
+
+	cfg, err := Load()
+	if err != nil {
+		t.Fatalf("Load: %v", err)
+	}
+	if cfg.EnableReleaseMode || cfg.ReleaseScheme != "semver" || cfg.ReleaseTagPrefix != "v" || cfg.ReleaseChangelog != "CHANGELOG.md" || cfg.ReleaseGitHubRelease {
+		t.Fatalf("unexpected release defaults: %+v", cfg)
+	}
+
+	t.Setenv("ENABLE_RELEASE_MODE", "true")
+	t.Setenv("RELEASE_TAG_PREFIX", "")
+	t.Setenv("RELEASE_VERSION_FILES", "VERSION, package.json")
+	cfg, err = Load()
+	if err != nil {
+		t.Fatalf("Load: %v", err)
+	}
+	if !cfg.EnableReleaseMode || cfg.ReleaseTagPrefix != "" || strings.Join(cfg.ReleaseVersionFiles, "|") != "VERSION|package.json" {
+		t.Fatalf("unexpected release settings: %+v", cfg)
+	}
+
+	t.Setenv("RELEASE_SCHEME", "romver")
+	t.Setenv("RELEASE_CHANGELOG", "../CHANGELOG.md")
+	_, err = Load()
+	if err == nil || !strings.Contains(err.Error(), "RELEASE_SCHEME must be semver or calver") || !strings.Contains(err.Error(), `"../CHANGELOG.md" must be a path inside the repository`) {
+		t.Fatalf("expected release validation errors, got %v", err)
+	}
+}
+
+func TestLoad_BlockedPaths(t *testing.T) {
+	os.Clearenv()
+	t.Cleanup(os.Clearenv)
+	t.Setenv("GITHUB_APP_ID", "1")
+	t.Setenv("GITHUB_PRIVATE_KEY", "key")
+	t.Setenv("GITHUB_WEBHOOK_SECRET", "secret")
+	t.Setenv("ANTHROPIC_API_KEY", "sk-test")
+
+	for _, tc := range []struct {
+		env  *string
+		want string
+	}{
+		{nil, ".github/workflows"},
+		{ptr(""), ""},
+		{ptr(".github, deploy/*.yaml"), ".github|deploy/*.yaml"},
+	} {
+		if tc.env != nil {
+			t.Setenv("BLOCKED_PATHS", *tc.env)
+		}
+		cfg, err := Load()
+		if err != nil {
+			t.Fatalf("Load: %v", err)
+		}
+		if got := strings.Join(cfg.BlockedPaths, "|"); got != tc.want {
+			t.Fatalf("BLOCKED_PATHS %v: got %q, want %q", tc.env, got, tc.want)
+		}
+	}
+}
+
+func ptr(s string) *string { return &s }
+
+func TestLoad_Bootstrap(t *testing.T) {
+	os.Clearenv()
+	t.Cleanup(os.Clearenv)
+	t.Setenv("GITHUB_APP_ID", "1")
+	t.Setenv("GITHUB_PRIVATE_KEY", "key")
+	t.Setenv("GITHUB_WEBHOOK_SECRET", "secret")
+	t.Setenv("ANTHROPIC_API_KEY", "sk-test")
+	t.Setenv("ENV_BOOTSTRAP", "true")
+	t.Setenv("SETUP_NODE", " pnpm install ")
+	t.Setenv("SETUP_PYTHON", "")
+
+	cfg, err := Load()
+	if err != nil {
+		t.Fatalf("Load: %v", err)
+	}
+	if !cfg.EnvBootstrap || cfg.SetupTimeout != 10*time.Minute || len(cfg.SetupCommands) != 2 ||
+		cfg.SetupCommands["node"] != "pnpm install" || cfg.SetupCommands["python"] != "" {
+		t.Fatalf("bootstrap = %t, %v, %v", cfg.EnvBootstrap, cfg.SetupCommands, cfg.SetupTimeout)
+	}
+
+	t.Setenv("SETUP_TIMEOUT_SECONDS", "-1")
+	if _, err := Load(); err == nil || !strings.Contains(err.Error(), "SETUP_TIMEOUT_SECONDS") {
+		t.Fatalf("Load = %v", err)
+	}
+}
+
+func TestLoad_Cache(t *testing.T) {
+	os.Clearenv()
+	t.Cleanup(os.Clearenv)
+	t.Setenv("GITHUB_APP_ID", "1")
+	t.Setenv("GITHUB_PRIVATE_KEY", "key")
+	t.Setenv("GITHUB_WEBHOOK_SECRET", "secret")
+	t.Setenv("ANTHROPIC_API_KEY", "sk-test")
+
+	cfg, err := Load()
+	if err != nil {
+		t.Fatalf("Load: %v", err)
+	}
+	if cfg.CacheDir != "" || cfg.CacheMaxSizeMB != 10240 {
+		t.Fatalf("cache defaults = %q, %d", cfg.CacheDir, cfg.CacheMaxSizeMB)
+	}
+
+	t.Setenv("CACHE_DIR", "/var/cache/swe-agent")
+	t.Setenv("CACHE_MAX_SIZE_MB", "-1")
+	if _, err := Load(); err == nil || !strings.Contains(err.Error(), "CACHE_MAX_SIZE_MB") {
+		t.Fatalf("Load = %v", err)
+	}
+}
+
+func TestLoad_Subtasks(t *testing.T) {
+	os.Clearenv()
+	t.Cleanup(os.Clearenv)
+	t.Setenv("GITHUB_APP_ID", "1")
+	t.Setenv("GITHUB_PRIVATE_KEY", "key")
+	t.Setenv("GITHUB_WEBHOOK_SECRET", "secret")
+	t.Setenv("ANTHROPIC_API_KEY", "sk-test")
+
+	cfg, err := Load()
+	if err != nil {
+		t.Fatalf("Load: %v", err)
+	}
+	if cfg.SubtaskParallelism != 0 || cfg.SubtaskMax != 6 {
+		t.Fatalf("sub-task defaults = %d, %d", cfg.SubtaskParallelism, cfg.SubtaskMax)
+	}
+
+	t.Setenv("SUBTASK_PARALLELISM", "3")
+	t.Setenv("SUBTASK_MAX", "-1")
+	if _, err := Load(); err == nil || !strings.Contains(err.Error(), "SUBTASK_MAX must be >= 0") {
+		t.Fatalf("Load = %v", err)
+	}
+}
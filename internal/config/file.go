@@ -0,0 +1,404 @@
+package config
+
+import (
+	"encoding/json"
+	"fmt"
+	"os"
+	"sort"
+	"strconv"
+	"strings"
+
+	"github.com/cexll/swe/internal/miniyaml"
+)
+
+// ValidationError reports every configuration problem found, not just the first.
+type ValidationError struct {
+	Problems []string
+}
+
+func (e *ValidationError) Error() string {
+	if len(e.Problems) == 1 {
+		return e.Problems[0]
+	}
+	return fmt.Sprintf("%d configuration problems:\n  - %s", len(e.Problems), strings.Join(e.Problems, "\n  - "))
+}
+
+func validationError(problems []string) error {
+	if len(problems) == 0 {
+		return nil
+	}
+	return &ValidationError{Problems: problems}
+}
+
+type valueKind int
+
+const (
+	kindString valueKind = iota
+	kindInt
+	kindFloat
+	kindBool
+	kindList // comma-separated in the environment
+	kindJSON // JSON-encoded in the environment
+)
+
+type fileKey struct {
+	env  string
+	kind valueKind
+}
+
+// fileKeys maps config file keys (dotted paths) to the environment variables
+// they populate.
+var fileKeys = map[string]fileKey{
+	"port":                                  {"PORT", kindInt},
+	"github.app_id":                         {"GITHUB_APP_ID", kindString},
+	"github.private_key":                    {"GITHUB_PRIVATE_KEY", kindString},
+	"github.webhook_secret":                 {"GITHUB_WEBHOOK_SECRET", kindString},
+	"provider":                              {"PROVIDER", kindString},
+	"claude.api_key":                        {"ANTHROPIC_API_KEY", kindString},
+	"claude.model":                          {"CLAUDE_MODEL", kindString},
+	"claude.standby_sessions":               {"CLAUDE_STANDBY_SESSIONS", kindInt},
+	"codex.api_key":                         {"OPENAI_API_KEY", kindString},
+	"codex.base_url":                        {"OPENAI_BASE_URL", kindString},
+	"codex.model":                           {"CODEX_MODEL", kindString},
+	"trigger_keyword":                       {"TRIGGER_KEYWORD", kindString},
+	"repos.allow":                           {"REPO_ALLOWLIST", kindList},
+	"repos.deny":                            {"REPO_DENYLIST", kindList},
+	"repos.settings_file":                   {"REPO_SETTINGS_FILE", kindString},
+	"disallowed_tools":                      {"DISALLOWED_TOOLS", kindList},
+	"mcp.github_comment":                    {"ENABLE_GITHUB_MCP_COMMENT", kindBool},
+	"mcp.github_files":                      {"ENABLE_GITHUB_MCP_FILES", kindBool},
+	"mcp.github_ci":                         {"ENABLE_GITHUB_MCP_CI", kindBool},
+	"use_commit_signing":                    {"USE_COMMIT_SIGNING", kindBool},
+	"wiki.enabled":                          {"ENABLE_WIKI_EDITING", kindBool},
+	"release.enabled":                       {"ENABLE_RELEASE_MODE", kindBool},
+	"triage.enabled":                        {"ENABLE_TRIAGE_MODE", kindBool},
+	"release.scheme":                        {"RELEASE_SCHEME", kindString},
+	"release.tag_prefix":                    {"RELEASE_TAG_PREFIX", kindString},
+	"release.version_files":                 {"RELEASE_VERSION_FILES", kindList},
+	"release.changelog":                     {"RELEASE_CHANGELOG", kindString},
+	"release.github_release":                {"RELEASE_GITHUB_RELEASE", kindBool},
+	"approval.enabled":                      {"ENABLE_APPROVAL_MODE", kindBool},
+	"dry_run.default":                       {"DEFAULT_DRY_RUN", kindBool},
+	"dispatcher.workers":                    {"DISPATCHER_WORKERS", kindInt},
+	"dispatcher.queue_size":                 {"DISPATCHER_QUEUE_SIZE", kindInt},
+	"dispatcher.max_attempts":               {"DISPATCHER_MAX_ATTEMPTS", kindInt},
+	"dispatcher.retry_seconds":              {"DISPATCHER_RETRY_SECONDS", kindInt},
+	"dispatcher.retry_max_seconds":          {"DISPATCHER_RETRY_MAX_SECONDS", kindInt},
+	"dispatcher.backoff_multiplier":         {"DISPATCHER_BACKOFF_MULTIPLIER", kindFloat},
+	"dispatcher.max_run_minutes":            {"DISPATCHER_MAX_RUN_MINUTES", kindInt},
+	"dispatcher.requeue_timed_out":          {"DISPATCHER_REQUEUE_TIMED_OUT", kindBool},
+	"dispatcher.journal_path":               {"DISPATCHER_JOURNAL_PATH", kindString},
+	"dispatcher.org_max_tasks":              {"DISPATCHER_ORG_MAX_TASKS", kindInt},
+	"dispatcher.org_limits":                 {"DISPATCHER_ORG_LIMITS", kindList},
+	"audit.log_path":                        {"AUDIT_LOG_PATH", kindString},
+	"audit.retention_days":                  {"AUDIT_RETENTION_DAYS", kindInt},
+	"permission_cache.ttl_seconds":          {"PERMISSION_CACHE_TTL_SECONDS", kindInt},
+	"permission_cache.negative_ttl_seconds": {"PERMISSION_CACHE_NEGATIVE_TTL_SECONDS", kindInt},
+	"policy_file":                           {"POLICY_FILE", kindString},
+	"schedules_file":                        {"SCHEDULES_FILE", kindString},
+	"schedules_state_path":                  {"SCHEDULES_STATE_PATH", kindString},
+	"schedules_catch_up":                    {"SCHEDULES_CATCH_UP", kindString},
+	"api_token":                             {"API_TOKEN", kindString},
+	"admin_public":                          {"ADMIN_PUBLIC", kindBool},
+	"generic_webhook_secret":                {"GENERIC_WEBHOOK_SECRET", kindString},
+	"jira.base_url":                         {"JIRA_BASE_URL", kindString},
+	"jira.email":                            {"JIRA_EMAIL", kindString},
+	"jira.api_token":                        {"JIRA_API_TOKEN", kindString},
+	"jira.webhook_secret":                   {"JIRA_WEBHOOK_SECRET", kindString},
+	"jira.projects":                         {"JIRA_PROJECTS", kindList},
+	"jira.users":                            {"JIRA_USERS", kindList},
+	"linear.api_key":                        {"LINEAR_API_KEY", kindString},
+	"linear.webhook_secret":                 {"LINEAR_WEBHOOK_SECRET", kindString},
+	"linear.teams":                          {"LINEAR_TEAMS", kindList},
+	"linear.users":                          {"LINEAR_USERS", kindList},
+	"slack.signing_secret":                  {"SLACK_SIGNING_SECRET", kindString},
+	"slack.bot_token":                       {"SLACK_BOT_TOKEN", kindString},
+	"slack.users":                           {"SLACK_USERS", kindList},
+	"telegram.bot_token":                    {"TELEGRAM_BOT_TOKEN", kindString},
+	"telegram.webhook_secret":               {"TELEGRAM_WEBHOOK_SECRET", kindString},
+	"telegram.chat_ids":                     {"TELEGRAM_CHAT_IDS", kindList},
+	"telegram.repos":                        {"TELEGRAM_REPOS", kindList},
+	"delivery.log_path":                     {"DELIVERY_LOG_PATH", kindString},
+	"delivery.ttl_hours":                    {"DELIVERY_TTL_HOURS", kindInt},
+	"budget.monthly_usd":                    {"BUDGET_MONTHLY_USD", kindFloat},
+	"budget.orgs":                           {"BUDGET_ORGS", kindList},
+	"budget.warn_percent":                   {"BUDGET_WARN_PERCENT", kindInt},
+	"budget.hard_cap_percent":               {"BUDGET_HARD_CAP_PERCENT", kindInt},
+	"budget.path":                           {"BUDGET_PATH", kindString},
+	"cost.confirm_usd":                      {"COST_CONFIRM_USD", kindFloat},
+	"cost.per_mtok_usd":                     {"COST_PER_MTOK_USD", kindFloat},
+	"verify.command":                        {"VERIFY_COMMAND", kindString},
+	"verify.scoped_command":                 {"VERIFY_SCOPED_COMMAND", kindString},
+	"verify.timeout_seconds":                {"VERIFY_TIMEOUT_SECONDS", kindInt},
+	"verify.artifact_dir":                   {"VERIFY_ARTIFACT_DIR", kindString},
+	"bootstrap.enabled":                     {"ENV_BOOTSTRAP", kindBool},
+	"bootstrap.go":                          {"SETUP_GO", kindString},
+	"bootstrap.node":                        {"SETUP_NODE", kindString},
+	"bootstrap.python":                      {"SETUP_PYTHON", kindString},
+	"bootstrap.timeout_seconds":             {"SETUP_TIMEOUT_SECONDS", kindInt},
+	"cache.dir":                             {"CACHE_DIR", kindString},
+	"cache.max_size_mb":                     {"CACHE_MAX_SIZE_MB", kindInt},
+	"workspace.dir":                         {"WORKSPACE_DIR", kindString},
+	"workspace.max_size_mb":                 {"WORKSPACE_MAX_SIZE_MB", kindInt},
+	"workspace.worktrees":                   {"WORKSPACE_WORKTREES", kindBool},
+	"subtasks.parallelism":                  {"SUBTASK_PARALLELISM", kindInt},
+	"subtasks.max":                          {"SUBTASK_MAX", kindInt},
+	"storage.backend":                       {"STORAGE_BACKEND", kindString},
+	"storage.dir":                           {"STORAGE_DIR", kindString},
+	"storage.bucket":                        {"STORAGE_BUCKET", kindString},
+	"storage.prefix":                        {"STORAGE_PREFIX", kindString},
+	"storage.endpoint":                      {"STORAGE_ENDPOINT", kindString},
+	"storage.region":                        {"STORAGE_REGION", kindString},
+	"artifacts.storage":                     {"ARTIFACTS_STORAGE", kindString},
+	"artifacts.retention_days":              {"ARTIFACTS_RETENTION_DAYS", kindInt},
+	"leader.lease_seconds":                  {"LEADER_LEASE_SECONDS", kindInt},
+	"secret_scan.rules_file":                {"SECRET_SCAN_RULES_FILE", kindString},
+	"blocked_paths":                         {"BLOCKED_PATHS", kindList},
+	"heartbeat_minutes":                     {"HEARTBEAT_MINUTES", kindInt},
+	"task.timeout_minutes":                  {"TASK_TIMEOUT_MINUTES", kindInt},
+	"task.max_timeout_minutes":              {"TASK_MAX_TIMEOUT_MINUTES", kindInt},
+	"commit_status.enabled":                 {"COMMIT_STATUS", kindBool},
+	"commit_status.context":                 {"COMMIT_STATUS_CONTEXT", kindString},
+	"public_url":                            {"PUBLIC_URL", kindString},
+	"knowledge.dir":                         {"KNOWLEDGE_DIR", kindString},
+	"knowledge.max_entries":                 {"KNOWLEDGE_MAX_ENTRIES", kindInt},
+	"knowledge.prompt_entries":              {"KNOWLEDGE_PROMPT_ENTRIES", kindInt},
+	"prompt_dir":                            {"PROMPT_DIR", kindString},
+	"comment_template_file":                 {"COMMENT_TEMPLATE_FILE", kindString},
+	"reuse_tracking_comment":                {"REUSE_TRACKING_COMMENT", kindBool},
+	"minimize_outdated_comments":            {"MINIMIZE_OUTDATED_COMMENTS", kindBool},
+	"context_max_tokens":                    {"CONTEXT_MAX_TOKENS", kindInt},
+	"repo_file_list_max":                    {"REPO_FILE_LIST_MAX", kindInt},
+	"pr_diff_max_lines":                     {"PR_DIFF_MAX_LINES", kindInt},
+	"fetch_cache_ttl_seconds":               {"FETCH_CACHE_TTL_SECONDS", kindInt},
+	"share.secret":                          {"SHARE_LINK_SECRET", kindString},
+	"share.max_ttl_hours":                   {"SHARE_LINK_MAX_TTL_HOURS", kindInt},
+	"reload.poll_seconds":                   {"RELOAD_POLL_SECONDS", kindInt},
+	"reuse_port":                            {"REUSE_PORT", kindBool},
+	"drain.timeout_seconds":                 {"DRAIN_TIMEOUT_SECONDS", kindInt},
+	"log.level":                             {"LOG_LEVEL", kindString},
+	"log.format":                            {"LOG_FORMAT", kindString},
+	"log.debug_task_level":                  {"DEBUG_TASK_LOG_LEVEL", kindString},
+	"notify.events":                         {"NOTIFY_EVENTS", kindList},
+	"notify.slack_webhook_url":              {"NOTIFY_SLACK_WEBHOOK_URL", kindString},
+	"notify.discord_webhook_url":            {"NOTIFY_DISCORD_WEBHOOK_URL", kindString},
+	"notify.webhook_url":                    {"NOTIFY_WEBHOOK_URL", kindString},
+	"notify.repos":                          {"NOTIFY_REPOS", kindJSON},
+	"notify.email.to":                       {"NOTIFY_EMAIL_TO", kindList},
+	"notify.email.from":                     {"NOTIFY_EMAIL_FROM", kindString},
+	"notify.email.events":                   {"NOTIFY_EMAIL_EVENTS", kindList},
+	"notify.email.digest_minutes":           {"NOTIFY_EMAIL_DIGEST_MINUTES", kindInt},
+	"smtp.host":                             {"SMTP_HOST", kindString},
+	"smtp.port":                             {"SMTP_PORT", kindInt},
+	"smtp.username":                         {"SMTP_USERNAME", kindString},
+	"smtp.password":                         {"SMTP_PASSWORD", kindString},
+}
+
+// LoadFile reads a YAML config file and returns the environment variables it
+// sets. Unknown keys and values of the wrong type are reported together.
+func LoadFile(path string) (map[string]string, error) {
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return nil, fmt.Errorf("read config file: %w", err)
+	}
+	entries, err := parseYAML(data)
+	if err != nil {
+		return nil, fmt.Errorf("%s: %w", path, err)
+	}
+
+	values := make(map[string]string)
+	var problems []string
+	for _, e := range entries {
+		key, ok := fileKeys[e.path]
+		if !ok {
+			problems = append(problems, fmt.Sprintf("%s:%d: unknown key %q%s", path, e.line, e.path, suggestKey(e.path)))
+			continue
+		}
+		value, err := convertValue(e.value, key.kind)
+		if err != nil {
+			problems = append(problems, fmt.Sprintf("%s:%d: %s: %v", path, e.line, e.path, err))
+			continue
+		}
+		if value != "" {
+			values[key.env] = value
+		}
+	}
+	if err := validationError(problems); err != nil {
+		return nil, err
+	}
+	return values, nil
+}
+
+// ApplyFile loads a config file into the process environment. Variables that
+// are already set take precedence over the file.
+func ApplyFile(path string) error {
+	values, err := LoadFile(path)
+	if err != nil {
+		return err
+	}
+	return applyOwned(sourceFile, values)
+}
+
+// applyConfigFile applies CONFIG_FILE when it is set.
+func applyConfigFile() error {
+	path := os.Getenv("CONFIG_FILE")
+	if path == "" {
+		return nil
+	}
+	return ApplyFile(path)
+}
+
+// suggestKey lists known keys sharing the unknown key's section.
+func suggestKey(path string) string {
+	section := ""
+	if i := strings.LastIndex(path, "."); i >= 0 {
+		section = path[:i+1]
+	}
+	var known []string
+	for k := range fileKeys {
+		if section == "" && !strings.Contains(k, ".") || section != "" && strings.HasPrefix(k, section) && !strings.Contains(k[len(section):], ".") {
+			known = append(known, k)
+		}
+	}
+	if len(known) == 0 {
+		return ""
+	}
+	sort.Strings(known)
+	return fmt.Sprintf(" (known keys: %s)", strings.Join(known, ", "))
+}
+
+func convertValue(v any, kind valueKind) (string, error) {
+	switch kind {
+	case kindList:
+		switch val := v.(type) {
+		case string:
+			return val, nil
+		case []string:
+			return strings.Join(val, ","), nil
+		case []any:
+			items := make([]string, 0, len(val))
+			for _, item := range val {
+				s, ok := item.(string)
+				if !ok {
+					return "", fmt.Errorf("list items must be strings")
+				}
+				items = append(items, s)
+			}
+			return strings.Join(items, ","), nil
+		}
+		return "", fmt.Errorf("must be a list of strings")
+	case kindJSON:
+		if s, ok := v.(string); ok {
+			return s, nil
+		}
+		if _, ok := v.([]string); ok {
+			return "", fmt.Errorf("must be a JSON object")
+		}
+		data, err := json.Marshal(v)
+		if err != nil {
+			return "", err
+		}
+		return string(data), nil
+	}
+
+	s, ok := v.(string)
+	if !ok {
+		return "", fmt.Errorf("must be a scalar value")
+	}
+	if s == "" {
+		return "", nil
+	}
+	switch kind {
+	case kindInt:
+		if _, err := strconv.Atoi(s); err != nil {
+			return "", fmt.Errorf("%q is not an integer", s)
+		}
+	case kindFloat:
+		if _, err := strconv.ParseFloat(s, 64); err != nil {
+			return "", fmt.Errorf("%q is not a number", s)
+		}
+	case kindBool:
+		switch strings.ToLower(s) {
+		case "true", "yes", "y", "1":
+			return "true", nil
+		case "false", "no", "n", "0":
+			return "false", nil
+		}
+		return "", fmt.Errorf("%q is not a boolean", s)
+	}
+	return s, nil
+}
+
+// yamlEntry is a leaf value from a config file. value is a string, a
+// []string for block lists, or a decoded JSON value for flow collections.
+type yamlEntry struct {
+	path  string
+	value any
+	line  int
+}
+
+// parseYAML parses a config file: nested mappings whose leaves are scalars,
+// lists of scalars, or JSON-compatible flow collections. Only leaf values
+// are returned, keyed by their dotted path.
+func parseYAML(data []byte) ([]yamlEntry, error) {
+	root, err := miniyaml.Parse(data)
+	if err != nil {
+		return nil, err
+	}
+	if root.Kind != miniyaml.Mapping {
+		return nil, fmt.Errorf("line %d: expected \"key: value\"", root.Line)
+	}
+	return appendEntries(nil, "", root)
+}
+
+// appendEntries appends the leaves of the mapping n, whose path is prefix.
+func appendEntries(entries []yamlEntry, prefix string, n *miniyaml.Node) ([]yamlEntry, error) {
+	for _, pair := range n.Pairs {
+		path, v := prefix+pair.Key, pair.Value
+		switch v.Kind {
+		case miniyaml.Mapping:
+			var err error
+			if entries, err = appendEntries(entries, path+".", v); err != nil {
+				return nil, err
+			}
+		case miniyaml.Sequence:
+			items := make([]string, 0, len(v.Items))
+			for _, item := range v.Items {
+				if item.Kind != miniyaml.Scalar {
+					return nil, fmt.Errorf("line %d: only scalar list items are supported", item.Line)
+				}
+				items = append(items, item.Value)
+			}
+			entries = append(entries, yamlEntry{path, items, v.Line})
+		case miniyaml.Flow:
+			var value any
+			if err := json.Unmarshal([]byte(v.Value), &value); err != nil {
+				items, ok := flowList(v.Value)
+				if !ok {
+					return nil, fmt.Errorf("line %d: %s: flow collections must be valid JSON: %v", v.Line, path, err)
+				}
+				value = items
+			}
+			entries = append(entries, yamlEntry{path, value, v.Line})
+		default:
+			entries = append(entries, yamlEntry{path, v.Value, v.Line})
+		}
+	}
+	return entries, nil
+}
+
+// flowList parses a flow sequence of plain scalars such as `[a, b]`.
+func flowList(s string) ([]string, bool) {
+	if !strings.HasSuffix(s, "]") {
+		return nil, false
+	}
+	inner := strings.TrimSpace(s[1 : len(s)-1])
+	if inner == "" || strings.ContainsAny(inner, "[]{}\"'") {
+		return nil, false
+	}
+	var items []string
+	for _, item := range strings.Split(inner, ",") {
+		items = append(items, strings.TrimSpace(item))
+	}
+	return items, true
+}
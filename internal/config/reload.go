@@ -0,0 +1,188 @@
+package config
+
+import (
+	"fmt"
+	"os"
+	"reflect"
+	"sync"
+
+	"github.com/joho/godotenv"
+)
+
+// Sources that may set environment variables on the process's behalf. The
+// real environment always wins; .env wins over CONFIG_FILE.
+const (
+	sourceDotEnv = "dotenv"
+	sourceFile   = "file"
+)
+
+var sourceRank = map[string]int{sourceFile: 1, sourceDotEnv: 2}
+
+// envOwners remembers which variables were set from .env or CONFIG_FILE, and
+// to what, so a reload can change or remove them without touching the real
+// environment.
+var (
+	envMu     sync.Mutex
+	envOwners = make(map[string]envOwner)
+)
+
+type envOwner struct {
+	source string
+	value  string
+}
+
+// owner returns who set env, or "" when its current value did not come from
+// a source (including when something else changed it since).
+func owner(env string) string {
+	o, ok := envOwners[env]
+	if !ok {
+		return ""
+	}
+	if v, set := os.LookupEnv(env); !set || v != o.value {
+		delete(envOwners, env)
+		return ""
+	}
+	return o.source
+}
+
+// applyOwned sets values on behalf of source and unsets variables source set
+// previously but no longer provides. Variables already set by the real
+// environment or a higher-ranked source are left alone.
+func applyOwned(source string, values map[string]string) error {
+	envMu.Lock()
+	defer envMu.Unlock()
+
+	for env := range envOwners {
+		if _, ok := values[env]; !ok && owner(env) == source {
+			delete(envOwners, env)
+			if err := os.Unsetenv(env); err != nil {
+				return fmt.Errorf("unset %s: %w", env, err)
+			}
+		}
+	}
+	for env, value := range values {
+		current := owner(env)
+		if current == "" && os.Getenv(env) != "" {
+			continue
+		}
+		if sourceRank[current] > sourceRank[source] {
+			continue
+		}
+		if err := os.Setenv(env, value); err != nil {
+			return fmt.Errorf("set %s: %w", env, err)
+		}
+		envOwners[env] = envOwner{source: source, value: value}
+	}
+	return nil
+}
+
+// ApplyDotEnv loads variables from the given .env files (default ".env")
+// without overriding the real environment. Calling it again picks up edits,
+// including removed lines. Missing files are treated as empty.
+func ApplyDotEnv(filenames ...string) error {
+	if len(filenames) == 0 {
+		filenames = []string{".env"}
+	}
+	values := make(map[string]string)
+	for _, name := range filenames {
+		read, err := godotenv.Read(name)
+		if err != nil && !os.IsNotExist(err) {
+			return err
+		}
+		for k, v := range read {
+			if _, ok := values[k]; !ok {
+				values[k] = v
+			}
+		}
+	}
+	return applyOwned(sourceDotEnv, values)
+}
+
+// restartFields are settings read once at startup; changing them in a
+// running process has no effect until restart.
+var restartFields = []struct {
+	name string
+	get  func(*Config) any
+}{
+	{"PORT", func(c *Config) any { return c.Port }},
+	{"GITHUB_APP_ID", func(c *Config) any { return c.GitHubAppID }},
+	{"GITHUB_PRIVATE_KEY", func(c *Config) any { return c.GitHubPrivateKey }},
+	{"GITHUB_WEBHOOK_SECRET", func(c *Config) any { return c.GitHubWebhookSecret }},
+	{"PROVIDER", func(c *Config) any { return c.Provider }},
+	{"DISPATCHER_WORKERS", func(c *Config) any { return c.DispatcherWorkers }},
+	{"DISPATCHER_QUEUE_SIZE", func(c *Config) any { return c.DispatcherQueueSize }},
+	{"DISPATCHER_JOURNAL_PATH", func(c *Config) any { return c.DispatcherJournalPath }},
+	{"AUDIT_LOG_PATH", func(c *Config) any { return c.AuditLogPath }},
+	{"AUDIT_RETENTION_DAYS", func(c *Config) any { return c.AuditRetention }},
+	{"API_TOKEN", func(c *Config) any { return c.APIToken }},
+	{"ADMIN_PUBLIC", func(c *Config) any { return c.AdminPublic }},
+	{"GENERIC_WEBHOOK_SECRET", func(c *Config) any { return c.GenericWebhookSecret }},
+	{"JIRA_BASE_URL", func(c *Config) any { return c.JiraBaseURL }},
+	{"JIRA_EMAIL", func(c *Config) any { return c.JiraEmail }},
+	{"JIRA_API_TOKEN", func(c *Config) any { return c.JiraAPIToken }},
+	{"JIRA_WEBHOOK_SECRET", func(c *Config) any { return c.JiraWebhookSecret }},
+	{"JIRA_PROJECTS", func(c *Config) any { return c.JiraProjects }},
+	{"JIRA_USERS", func(c *Config) any { return c.JiraUsers }},
+	{"LINEAR_API_KEY", func(c *Config) any { return c.LinearAPIKey }},
+	{"LINEAR_WEBHOOK_SECRET", func(c *Config) any { return c.LinearWebhookSecret }},
+	{"LINEAR_TEAMS", func(c *Config) any { return c.LinearTeams }},
+	{"LINEAR_USERS", func(c *Config) any { return c.LinearUsers }},
+	{"SLACK_SIGNING_SECRET", func(c *Config) any { return c.SlackSigningSecret }},
+	{"SLACK_BOT_TOKEN", func(c *Config) any { return c.SlackBotToken }},
+	{"SLACK_USERS", func(c *Config) any { return c.SlackUsers }},
+	{"TELEGRAM_BOT_TOKEN", func(c *Config) any { return c.TelegramBotToken }},
+	{"TELEGRAM_WEBHOOK_SECRET", func(c *Config) any { return c.TelegramWebhookSecret }},
+	{"TELEGRAM_CHAT_IDS", func(c *Config) any { return c.TelegramChats }},
+	{"TELEGRAM_REPOS", func(c *Config) any { return c.TelegramRepos }},
+	{"DELIVERY_LOG_PATH", func(c *Config) any { return c.DeliveryLogPath }},
+	{"BUDGET_PATH", func(c *Config) any { return c.BudgetPath }},
+	{"SCHEDULES_STATE_PATH", func(c *Config) any { return c.SchedulesStatePath }},
+	{"DELIVERY_TTL_HOURS", func(c *Config) any { return c.DeliveryTTL }},
+	{"VERIFY_COMMAND", func(c *Config) any { return c.VerifyCommand }},
+	{"VERIFY_SCOPED_COMMAND", func(c *Config) any { return c.VerifyScopedCommand }},
+	{"VERIFY_TIMEOUT_SECONDS", func(c *Config) any { return c.VerifyTimeout }},
+	{"VERIFY_ARTIFACT_DIR", func(c *Config) any { return c.VerifyArtifactDir }},
+	{"STORAGE_BACKEND", func(c *Config) any { return c.Storage.Backend }},
+	{"STORAGE_DIR", func(c *Config) any { return c.Storage.Dir }},
+	{"STORAGE_BUCKET", func(c *Config) any { return c.Storage.Bucket }},
+	{"STORAGE_PREFIX", func(c *Config) any { return c.Storage.Prefix }},
+	{"STORAGE_ENDPOINT", func(c *Config) any { return c.Storage.Endpoint }},
+	{"STORAGE_REGION", func(c *Config) any { return c.Storage.Region }},
+	{"ARTIFACTS_STORAGE", func(c *Config) any { return c.ArtifactsStorage }},
+	{"ARTIFACTS_RETENTION_DAYS", func(c *Config) any { return c.ArtifactsRetention }},
+	{"LEADER_LEASE_SECONDS", func(c *Config) any { return c.LeaderLeaseTTL }},
+	{"WORKSPACE_DIR", func(c *Config) any { return c.WorkspaceDir }},
+	{"KNOWLEDGE_DIR", func(c *Config) any { return c.KnowledgeDir }},
+	{"KNOWLEDGE_MAX_ENTRIES", func(c *Config) any { return c.KnowledgeMaxEntries }},
+	{"KNOWLEDGE_PROMPT_ENTRIES", func(c *Config) any { return c.KnowledgePromptEntries }},
+	{"SECRET_SCAN_RULES_FILE", func(c *Config) any { return c.SecretScanRulesFile }},
+	{"SHARE_LINK_SECRET", func(c *Config) any { return c.ShareLinkSecret }},
+	{"SHARE_LINK_MAX_TTL_HOURS", func(c *Config) any { return c.ShareLinkMaxTTL }},
+	{"RELOAD_POLL_SECONDS", func(c *Config) any { return c.ReloadPollInterval }},
+	{"REUSE_PORT", func(c *Config) any { return c.ReusePort }},
+	{"DRAIN_TIMEOUT_SECONDS", func(c *Config) any { return c.DrainTimeout }},
+	{"LOG_FORMAT", func(c *Config) any { return c.LogFormat }},
+}
+
+// RestartRequired lists the settings that differ between old and updated
+// but cannot be applied without restarting.
+func RestartRequired(old, updated *Config) []string {
+	var changed []string
+	for _, f := range restartFields {
+		if !reflect.DeepEqual(f.get(old), f.get(updated)) {
+			changed = append(changed, f.name)
+		}
+	}
+	return changed
+}
+
+// ProviderChanged reports whether the AI provider must be rebuilt to apply
+// updated (model, credentials or endpoint).
+func ProviderChanged(old, updated *Config) bool {
+	return old.ClaudeAPIKey != updated.ClaudeAPIKey ||
+		old.ClaudeModel != updated.ClaudeModel ||
+		old.ClaudeStandbySessions != updated.ClaudeStandbySessions ||
+		old.OpenAIAPIKey != updated.OpenAIAPIKey ||
+		old.OpenAIBaseURL != updated.OpenAIBaseURL ||
+		old.CodexModel != updated.CodexModel
+}
@@ -0,0 +1,108 @@
+package config
+
+import (
+	"os"
+	"path/filepath"
+	"reflect"
+	"testing"
+)
+
+func resetEnvOwners(t *testing.T) {
+	t.Helper()
+	os.Clearenv()
+	envOwners = make(map[string]envOwner)
+	t.Cleanup(func() {
+		os.Clearenv()
+		envOwners = make(map[string]envOwner)
+	})
+}
+
+func TestApplyFile_ReapplyUpdatesOwnedValues(t *testing.T) {
+	resetEnvOwners(t)
+	t.Setenv("PORT", "7000")
+	path := writeConfigFile(t, "port: 9000\ntrigger_keyword: /bot\ncodex:\n  model: a\n")
+
+	if err := ApplyFile(path); err != nil {
+		t.Fatalf("ApplyFile: %v", err)
+	}
+	if os.Getenv("TRIGGER_KEYWORD") != "/bot" || os.Getenv("PORT") != "7000" {
+		t.Fatalf("trigger=%q port=%q", os.Getenv("TRIGGER_KEYWORD"), os.Getenv("PORT"))
+	}
+
+	if err := os.WriteFile(path, []byte("port: 9001\ntrigger_keyword: /agent\n"), 0o600); err != nil {
+		t.Fatal(err)
+	}
+	if err := ApplyFile(path); err != nil {
+		t.Fatalf("ApplyFile: %v", err)
+	}
+	if os.Getenv("TRIGGER_KEYWORD") != "/agent" {
+		t.Errorf("edited value not applied: %q", os.Getenv("TRIGGER_KEYWORD"))
+	}
+	if _, set := os.LookupEnv("CODEX_MODEL"); set {
+		t.Error("value removed from the file should be unset")
+	}
+	if os.Getenv("PORT") != "7000" {
+		t.Errorf("real environment overridden: PORT=%q", os.Getenv("PORT"))
+	}
+}
+
+func TestApplyDotEnv_OutranksFile(t *testing.T) {
+	resetEnvOwners(t)
+	dir := t.TempDir()
+	envFile := filepath.Join(dir, ".env")
+	configFile := writeConfigFile(t, "trigger_keyword: /file\nclaude:\n  model: file-model\n")
+	if err := os.WriteFile(envFile, []byte("CLAUDE_MODEL=env-model\n"), 0o600); err != nil {
+		t.Fatal(err)
+	}
+
+	// startup order: .env first, then CONFIG_FILE
+	if err := ApplyDotEnv(envFile); err != nil {
+		t.Fatalf("ApplyDotEnv: %v", err)
+	}
+	if err := ApplyFile(configFile); err != nil {
+		t.Fatalf("ApplyFile: %v", err)
+	}
+	if os.Getenv("CLAUDE_MODEL") != "env-model" || os.Getenv("TRIGGER_KEYWORD") != "/file" {
+		t.Fatalf("model=%q trigger=%q", os.Getenv("CLAUDE_MODEL"), os.Getenv("TRIGGER_KEYWORD"))
+	}
+
+	// .env now sets the trigger too and drops the model
+	if err := os.WriteFile(envFile, []byte("TRIGGER_KEYWORD=/env\n"), 0o600); err != nil {
+		t.Fatal(err)
+	}
+	if err := ApplyDotEnv(envFile); err != nil {
+		t.Fatalf("ApplyDotEnv: %v", err)
+	}
+	if err := ApplyFile(configFile); err != nil {
+		t.Fatalf("ApplyFile: %v", err)
+	}
+	if os.Getenv("TRIGGER_KEYWORD") != "/env" || os.Getenv("CLAUDE_MODEL") != "file-model" {
+		t.Fatalf("model=%q trigger=%q", os.Getenv("CLAUDE_MODEL"), os.Getenv("TRIGGER_KEYWORD"))
+	}
+
+	if err := ApplyDotEnv(filepath.Join(dir, "missing.env")); err != nil {
+		t.Fatalf("missing .env should be ignored: %v", err)
+	}
+	if _, set := os.LookupEnv("TRIGGER_KEYWORD"); set {
+		t.Error("values from a removed .env should be unset")
+	}
+}
+
+func TestRestartRequired(t *testing.T) {
+	old := &Config{Port: 8000, Provider: "claude", ClaudeModel: "a", TriggerKeyword: "/code", DispatcherWorkers: 4}
+	updated := *old
+	updated.TriggerKeyword = "/agent"
+	updated.ClaudeModel = "b"
+	if got := RestartRequired(old, &updated); len(got) != 0 {
+		t.Fatalf("safe changes reported as restart-required: %v", got)
+	}
+	if !ProviderChanged(old, &updated) {
+		t.Fatal("model change should rebuild the provider")
+	}
+
+	updated.Port = 9000
+	updated.DispatcherWorkers = 8
+	if got := RestartRequired(old, &updated); !reflect.DeepEqual(got, []string{"PORT", "DISPATCHER_WORKERS"}) {
+		t.Fatalf("RestartRequired = %v", got)
+	}
+}
@@ -0,0 +1,309 @@
+// Package delivery tracks GitHub webhook deliveries by their X-GitHub-Delivery
+// GUID so replays can be rejected and recent outcomes inspected.
+package delivery
+
+import (
+	"bufio"
+	"encoding/json"
+	"fmt"
+	"os"
+	"path/filepath"
+	"strings"
+	"sync"
+	"time"
+)
+
+// DefaultTTL matches how long GitHub allows a delivery to be redelivered.
+const DefaultTTL = 72 * time.Hour
+
+// OutcomePending marks a delivery that is still being handled.
+const OutcomePending = "processing"
+
+// Record describes one processed delivery and how the webhook handler answered it.
+type Record struct {
+	ID         string    `json:"id"`
+	Event      string    `json:"event"`
+	Action     string    `json:"action,omitempty"`
+	Repo       string    `json:"repo,omitempty"`
+	Number     int       `json:"number,omitempty"`
+	Sender     string    `json:"sender,omitempty"`
+	ReceivedAt time.Time `json:"received_at"`
+	// Status and Outcome are the HTTP status and message returned to GitHub.
+	Status       int        `json:"status,omitempty"`
+	Outcome      string     `json:"outcome"`
+	TaskID       string     `json:"task_id,omitempty"`
+	Duration     string     `json:"duration,omitempty"`
+	Replays      int        `json:"replays,omitempty"`
+	LastReplayAt *time.Time `json:"last_replay_at,omitempty"`
+}
+
+// Config controls where deliveries are persisted and how long they are remembered.
+type Config struct {
+	// Path of the JSON lines file. Empty keeps deliveries in memory only.
+	Path string
+	// TTL forgets deliveries older than this duration. Zero uses DefaultTTL.
+	TTL time.Duration
+}
+
+// Filter narrows List results. Zero values match everything.
+type Filter struct {
+	Repo    string
+	Event   string
+	Outcome string
+	Limit   int // most recent N records when > 0
+}
+
+// Store remembers delivery GUIDs until their TTL expires.
+type Store struct {
+	mu        sync.Mutex
+	cfg       Config
+	records   map[string]*Record
+	order     []string // delivery IDs by first receipt
+	file      *os.File
+	lastPrune time.Time
+}
+
+// allow tests to control time
+var now = time.Now
+
+// New opens (or creates) the delivery store described by cfg, loading
+// deliveries that are still within the TTL.
+func New(cfg Config) (*Store, error) {
+	if cfg.TTL <= 0 {
+		cfg.TTL = DefaultTTL
+	}
+	s := &Store{cfg: cfg, records: make(map[string]*Record)}
+	if cfg.Path == "" {
+		return s, nil
+	}
+
+	if err := os.MkdirAll(filepath.Dir(cfg.Path), 0o755); err != nil {
+		return nil, fmt.Errorf("create delivery log dir: %w", err)
+	}
+	if err := s.load(); err != nil {
+		return nil, err
+	}
+	if err := s.compactLocked(); err != nil {
+		return nil, err
+	}
+	return s, nil
+}
+
+func (s *Store) load() error {
+	f, err := os.Open(s.cfg.Path)
+	if os.IsNotExist(err) {
+		return nil
+	}
+	if err != nil {
+		return fmt.Errorf("open delivery log: %w", err)
+	}
+	defer func() { _ = f.Close() }()
+
+	scanner := bufio.NewScanner(f)
+	scanner.Buffer(make([]byte, 0, 64*1024), 1024*1024)
+	for scanner.Scan() {
+		line := strings.TrimSpace(scanner.Text())
+		if line == "" {
+			continue
+		}
+		var rec Record
+		if err := json.Unmarshal([]byte(line), &rec); err != nil || rec.ID == "" {
+			// skip corrupt lines rather than refusing to start
+			continue
+		}
+		// later lines are updates of earlier ones
+		if _, ok := s.records[rec.ID]; !ok {
+			s.order = append(s.order, rec.ID)
+		}
+		r := rec
+		s.records[rec.ID] = &r
+	}
+	return scanner.Err()
+}
+
+// Begin claims a delivery ID. It returns true the first time an ID is seen
+// within the TTL. Otherwise it counts the replay and returns false together
+// with the original record.
+func (s *Store) Begin(id, event string) (Record, bool) {
+	if s == nil || id == "" {
+		return Record{}, true
+	}
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.maybePruneLocked()
+
+	ts := now()
+	if rec, ok := s.records[id]; ok {
+		rec.Replays++
+		rec.LastReplayAt = &ts
+		_ = s.appendLocked(*rec)
+		return *rec, false
+	}
+
+	s.records[id] = &Record{ID: id, Event: event, ReceivedAt: ts, Outcome: OutcomePending}
+	s.order = append(s.order, id)
+	return *s.records[id], true
+}
+
+// Complete stores the final state of a delivery claimed with Begin. Replay
+// counters and the receive time of the original claim are preserved.
+func (s *Store) Complete(rec Record) error {
+	if s == nil || rec.ID == "" {
+		return nil
+	}
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	existing, ok := s.records[rec.ID]
+	if !ok {
+		rec.ReceivedAt = now()
+		s.order = append(s.order, rec.ID)
+	} else {
+		rec.ReceivedAt = existing.ReceivedAt
+		rec.Replays = existing.Replays
+		rec.LastReplayAt = existing.LastReplayAt
+	}
+	if rec.Event == "" && ok {
+		rec.Event = existing.Event
+	}
+	s.records[rec.ID] = &rec
+	return s.appendLocked(rec)
+}
+
+// Get returns the record for a delivery ID.
+func (s *Store) Get(id string) (Record, bool) {
+	if s == nil {
+		return Record{}, false
+	}
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	rec, ok := s.records[id]
+	if !ok || s.expired(*rec) {
+		return Record{}, false
+	}
+	return *rec, true
+}
+
+// List returns matching deliveries, newest first.
+func (s *Store) List(f Filter) []Record {
+	if s == nil {
+		return nil
+	}
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	out := make([]Record, 0)
+	for i := len(s.order) - 1; i >= 0; i-- {
+		rec := *s.records[s.order[i]]
+		if s.expired(rec) || !f.matches(rec) {
+			continue
+		}
+		out = append(out, rec)
+		if f.Limit > 0 && len(out) >= f.Limit {
+			break
+		}
+	}
+	return out
+}
+
+func (f Filter) matches(rec Record) bool {
+	if f.Repo != "" && !strings.EqualFold(rec.Repo, f.Repo) {
+		return false
+	}
+	if f.Event != "" && rec.Event != f.Event {
+		return false
+	}
+	if f.Outcome != "" && !strings.EqualFold(rec.Outcome, f.Outcome) {
+		return false
+	}
+	return true
+}
+
+func (s *Store) expired(rec Record) bool {
+	return now().Sub(rec.ReceivedAt) > s.cfg.TTL
+}
+
+// maybePruneLocked drops expired deliveries at most once a minute.
+func (s *Store) maybePruneLocked() {
+	if now().Sub(s.lastPrune) < time.Minute {
+		return
+	}
+	_ = s.compactLocked()
+}
+
+// compactLocked drops expired deliveries and rewrites the backing file.
+func (s *Store) compactLocked() error {
+	s.lastPrune = now()
+	kept := s.order[:0]
+	for _, id := range s.order {
+		if s.expired(*s.records[id]) {
+			delete(s.records, id)
+			continue
+		}
+		kept = append(kept, id)
+	}
+	s.order = kept
+	if s.cfg.Path == "" {
+		return nil
+	}
+
+	if s.file != nil {
+		_ = s.file.Close()
+		s.file = nil
+	}
+	tmp := s.cfg.Path + ".tmp"
+	f, err := os.OpenFile(tmp, os.O_CREATE|os.O_TRUNC|os.O_WRONLY, 0o600)
+	if err != nil {
+		return fmt.Errorf("rewrite delivery log: %w", err)
+	}
+	enc := json.NewEncoder(f)
+	for _, id := range s.order {
+		if err := enc.Encode(s.records[id]); err != nil {
+			_ = f.Close()
+			return fmt.Errorf("encode delivery: %w", err)
+		}
+	}
+	if err := f.Close(); err != nil {
+		return fmt.Errorf("rewrite delivery log: %w", err)
+	}
+	if err := os.Rename(tmp, s.cfg.Path); err != nil {
+		return fmt.Errorf("rewrite delivery log: %w", err)
+	}
+	return nil
+}
+
+func (s *Store) appendLocked(rec Record) error {
+	if s.cfg.Path == "" {
+		return nil
+	}
+	if s.file == nil {
+		f, err := os.OpenFile(s.cfg.Path, os.O_CREATE|os.O_APPEND|os.O_WRONLY, 0o600)
+		if err != nil {
+			return fmt.Errorf("open delivery log: %w", err)
+		}
+		s.file = f
+	}
+	blob, err := json.Marshal(rec)
+	if err != nil {
+		return fmt.Errorf("marshal delivery: %w", err)
+	}
+	if _, err := s.file.Write(append(blob, '\n')); err != nil {
+		return fmt.Errorf("write delivery: %w", err)
+	}
+	return nil
+}
+
+// Close releases the backing file.
+func (s *Store) Close() error {
+	if s == nil {
+		return nil
+	}
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	if s.file == nil {
+		return nil
+	}
+	err := s.file.Close()
+	s.file = nil
+	return err
+}
@@ -0,0 +1,161 @@
+package delivery
+
+import (
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+)
+
+func withClock(t *testing.T, start time.Time) *time.Time {
+	t.Helper()
+	current := start
+	orig := now
+	now = func() time.Time { return current }
+	t.Cleanup(func() { now = orig })
+	return &current
+}
+
+func TestBeginRejectsReplay(t *testing.T) {
+	withClock(t, time.Date(2025, 10, 1, 12, 0, 0, 0, time.UTC))
+	s, err := New(Config{})
+	if err != nil {
+		t.Fatalf("New error: %v", err)
+	}
+
+	rec, ok := s.Begin("a", "issue_comment")
+	if !ok || rec.Outcome != OutcomePending {
+		t.Fatalf("first Begin = %+v, %t", rec, ok)
+	}
+	if _, ok := s.Begin("a", "issue_comment"); ok {
+		t.Fatal("second Begin should report a replay")
+	}
+	if err := s.Complete(Record{ID: "a", Event: "issue_comment", Status: 202, Outcome: "Task queued"}); err != nil {
+		t.Fatalf("Complete error: %v", err)
+	}
+	rec, ok = s.Begin("a", "issue_comment")
+	if ok || rec.Replays != 2 || rec.Outcome != "Task queued" || rec.LastReplayAt == nil {
+		t.Fatalf("replay record = %+v, %t", rec, ok)
+	}
+	if _, ok := s.Begin("", "ping"); !ok {
+		t.Fatal("empty IDs are never tracked")
+	}
+}
+
+func TestTTLExpiry(t *testing.T) {
+	clock := withClock(t, time.Date(2025, 10, 1, 12, 0, 0, 0, time.UTC))
+	s, _ := New(Config{TTL: time.Hour})
+
+	s.Begin("old", "ping")
+	*clock = clock.Add(2 * time.Hour)
+	if _, ok := s.Get("old"); ok {
+		t.Fatal("expired delivery still visible")
+	}
+	if len(s.List(Filter{})) != 0 {
+		t.Fatal("expired delivery still listed")
+	}
+	if _, ok := s.Begin("old", "ping"); !ok {
+		t.Fatal("expired GUID should be accepted again")
+	}
+}
+
+func TestListFilters(t *testing.T) {
+	clock := withClock(t, time.Date(2025, 10, 1, 12, 0, 0, 0, time.UTC))
+	s, _ := New(Config{})
+	for _, r := range []Record{
+		{ID: "1", Event: "issue_comment", Repo: "acme/api", Outcome: "Task queued"},
+		{ID: "2", Event: "ping", Outcome: "Event ignored"},
+		{ID: "3", Event: "issue_comment", Repo: "acme/web", Outcome: "Permission denied"},
+	} {
+		*clock = clock.Add(time.Minute)
+		s.Begin(r.ID, r.Event)
+		_ = s.Complete(r)
+	}
+
+	all := s.List(Filter{})
+	if len(all) != 3 || all[0].ID != "3" || all[2].ID != "1" {
+		t.Fatalf("List should be newest first: %+v", all)
+	}
+	if got := s.List(Filter{Event: "issue_comment", Limit: 1}); len(got) != 1 || got[0].ID != "3" {
+		t.Fatalf("event+limit filter = %+v", got)
+	}
+	if got := s.List(Filter{Repo: "ACME/API"}); len(got) != 1 || got[0].ID != "1" {
+		t.Fatalf("repo filter = %+v", got)
+	}
+	if got := s.List(Filter{Outcome: "permission denied"}); len(got) != 1 || got[0].ID != "3" {
+		t.Fatalf("outcome filter = %+v", got)
+	}
+}
+
+func TestPersistenceAcrossRestart(t *testing.T) {
+	clock := withClock(t, time.Date(2025, 10, 1, 12, 0, 0, 0, time.UTC))
+	path := filepath.Join(t.TempDir(), "nested", "deliveries.jsonl")
+
+	s, err := New(Config{Path: path, TTL: 24 * time.Hour})
+	if err != nil {
+		t.Fatalf("New error: %v", err)
+	}
+	s.Begin("old", "ping")
+	_ = s.Complete(Record{ID: "old", Status: 200, Outcome: "Event ignored"})
+	*clock = clock.Add(20 * time.Hour)
+	s.Begin("new", "issue_comment")
+	_ = s.Complete(Record{ID: "new", Status: 202, Outcome: "Task queued", TaskID: "t-1"})
+	s.Begin("new", "issue_comment")
+	_ = s.Close()
+
+	*clock = clock.Add(5 * time.Hour) // "old" is now past its TTL
+	reopened, err := New(Config{Path: path, TTL: 24 * time.Hour})
+	if err != nil {
+		t.Fatalf("reopen error: %v", err)
+	}
+	defer func() { _ = reopened.Close() }()
+
+	if _, ok := reopened.Get("old"); ok {
+		t.Fatal("expired delivery survived restart")
+	}
+	rec, ok := reopened.Begin("new", "issue_comment")
+	if ok {
+		t.Fatal("delivery seen before restart must be rejected")
+	}
+	if rec.TaskID != "t-1" || rec.Replays != 2 || rec.Event != "issue_comment" {
+		t.Fatalf("reloaded record = %+v", rec)
+	}
+
+	data, err := os.ReadFile(path)
+	if err != nil {
+		t.Fatalf("read log: %v", err)
+	}
+	if strings.Contains(string(data), `"id":"old"`) {
+		t.Fatalf("compaction should drop expired lines: %s", data)
+	}
+}
+
+func TestLoadSkipsCorruptLines(t *testing.T) {
+	withClock(t, time.Date(2025, 10, 1, 12, 0, 0, 0, time.UTC))
+	path := filepath.Join(t.TempDir(), "deliveries.jsonl")
+	content := "not json\n{\"id\":\"\"}\n{\"id\":\"ok\",\"event\":\"ping\",\"received_at\":\"2025-10-01T11:00:00Z\",\"outcome\":\"Event ignored\"}\n"
+	if err := os.WriteFile(path, []byte(content), 0o600); err != nil {
+		t.Fatalf("write: %v", err)
+	}
+	s, err := New(Config{Path: path})
+	if err != nil {
+		t.Fatalf("New error: %v", err)
+	}
+	if got := s.List(Filter{}); len(got) != 1 || got[0].ID != "ok" {
+		t.Fatalf("List = %+v", got)
+	}
+}
+
+func TestNilStore(t *testing.T) {
+	var s *Store
+	if _, ok := s.Begin("x", "ping"); !ok {
+		t.Fatal("nil store should accept every delivery")
+	}
+	if err := s.Complete(Record{ID: "x"}); err != nil {
+		t.Fatalf("Complete error: %v", err)
+	}
+	if s.List(Filter{}) != nil || s.Close() != nil {
+		t.Fatal("nil store should be inert")
+	}
+}
@@ -0,0 +1,186 @@
+package dispatcher
+
+import (
+	"context"
+	"fmt"
+	"log/slog"
+	"strings"
+
+	"github.com/cexll/swe/internal/notify"
+	"github.com/cexll/swe/internal/taskstore"
+	"github.com/cexll/swe/internal/webhook"
+)
+
+// Prerequisites reports how the tasks others depend on ended and records
+// the dependents that will not run; *taskstore.Store implements it.
+type Prerequisites interface {
+	Status(id string) (taskstore.TaskStatus, bool)
+	AddLog(id, level, message string)
+	UpdateStatus(id string, status taskstore.TaskStatus)
+}
+
+// SetPrerequisites sets where the outcome of prerequisites the dispatcher is
+// not running is looked up. Without it such prerequisites count as
+// completed, so only those still queued or running are waited for.
+func (d *Dispatcher) SetPrerequisites(p Prerequisites) {
+	d.depsMu.Lock()
+	defer d.depsMu.Unlock()
+	d.prereqs = p
+}
+
+// blockedTask is a waiting task that will not run, and why.
+type blockedTask struct {
+	task   *webhook.Task
+	reason string
+}
+
+// hold records task as unfinished and keeps it back while the tasks it
+// depends on have not completed. It reports whether the task was kept back,
+// or failed because a prerequisite did.
+func (d *Dispatcher) hold(task *webhook.Task) bool {
+	if task.ID == "" {
+		return false
+	}
+	d.depsMu.Lock()
+	if d.unfinished == nil {
+		d.unfinished = make(map[string]bool)
+	}
+	d.unfinished[task.ID] = true
+	blocked, failure := d.prerequisitesLocked(task, nil)
+	if failure != "" {
+		delete(d.unfinished, task.ID)
+	} else if blocked {
+		d.waiting = append(d.waiting, task)
+	}
+	prereqs := d.prereqs
+	d.depsMu.Unlock()
+
+	switch {
+	case failure != "":
+		d.release(nil, []blockedTask{{task: task, reason: failure}})
+		return true
+	case blocked:
+		slog.InfoContext(task.LogContext(context.Background()), "Task waits for prerequisite tasks", "depends_on", task.DependsOn)
+		if prereqs != nil {
+			prereqs.AddLog(task.ID, "info", "Waiting for prerequisite task(s): "+strings.Join(task.DependsOn, ", "))
+		}
+		return true
+	}
+	return false
+}
+
+// unhold forgets a task that could not be queued.
+func (d *Dispatcher) unhold(task *webhook.Task) {
+	d.depsMu.Lock()
+	defer d.depsMu.Unlock()
+	delete(d.unfinished, task.ID)
+}
+
+// resolve records that task will not be attempted again and starts the
+// tasks that were waiting for it, or fails them when it did not complete.
+func (d *Dispatcher) resolve(task *webhook.Task) {
+	d.forget(task)
+	if task.ID == "" {
+		return
+	}
+	d.depsMu.Lock()
+	delete(d.unfinished, task.ID)
+	var ready []*webhook.Task
+	var failed []blockedTask
+	failing := make(map[string]bool)
+	// failing a task can fail the tasks waiting for it in turn
+	for changed := true; changed; {
+		changed = false
+		kept := d.waiting[:0]
+		for _, t := range d.waiting {
+			blocked, failure := d.prerequisitesLocked(t, failing)
+			switch {
+			case failure != "":
+				delete(d.unfinished, t.ID)
+				failing[t.ID] = true
+				failed = append(failed, blockedTask{task: t, reason: failure})
+				changed = true
+			case blocked:
+				kept = append(kept, t)
+			default:
+				ready = append(ready, t)
+			}
+		}
+		clear(d.waiting[len(kept):])
+		d.waiting = kept
+	}
+	d.depsMu.Unlock()
+	d.release(ready, failed)
+}
+
+// prerequisitesLocked reports whether task still has to wait for a
+// prerequisite, or why it cannot run at all. Tasks in failing count as
+// failed.
+func (d *Dispatcher) prerequisitesLocked(task *webhook.Task, failing map[string]bool) (blocked bool, failure string) {
+	for _, dep := range task.DependsOn {
+		switch {
+		case failing[dep]:
+			return false, fmt.Sprintf("prerequisite task %s failed", dep)
+		case d.unfinished[dep]:
+			blocked = true
+		case d.prereqs == nil:
+		default:
+			status, ok := d.prereqs.Status(dep)
+			switch {
+			case !ok:
+				return false, fmt.Sprintf("prerequisite task %s not found", dep)
+			case status == taskstore.StatusFailed:
+				return false, fmt.Sprintf("prerequisite task %s failed", dep)
+			case status != taskstore.StatusCompleted:
+				return false, fmt.Sprintf("prerequisite task %s did not complete (%s)", dep, status)
+			}
+		}
+	}
+	return blocked, ""
+}
+
+// release queues the tasks whose prerequisites completed and fails those
+// whose prerequisites did not.
+func (d *Dispatcher) release(ready []*webhook.Task, failed []blockedTask) {
+	d.depsMu.Lock()
+	prereqs := d.prereqs
+	d.depsMu.Unlock()
+
+	for _, b := range failed {
+		slog.WarnContext(b.task.LogContext(context.Background()), "Task will not run", "reason", b.reason)
+		d.forget(b.task)
+		if prereqs != nil {
+			prereqs.AddLog(b.task.ID, "error", b.reason)
+			prereqs.UpdateStatus(b.task.ID, taskstore.StatusFailed)
+		}
+		d.notifier.Notify(notify.Event{
+			Type:   notify.EventDeadLettered,
+			TaskID: b.task.ID,
+			Repo:   b.task.Repo,
+			Number: b.task.Number,
+			IsPR:   b.task.IsPR,
+			Actor:  b.task.Username,
+			Error:  b.reason,
+		})
+	}
+	for _, task := range ready {
+		slog.InfoContext(task.LogContext(context.Background()), "Prerequisites completed; queueing task")
+		if prereqs != nil {
+			prereqs.AddLog(task.ID, "info", "Prerequisites completed")
+		}
+		item := &queueItem{task: task, attempt: 1}
+		if !d.push(item) {
+			go d.enqueueRetry(item)
+		}
+	}
+	if len(ready) > 0 {
+		d.reportQueue(nil)
+	}
+}
+
+// waitingTasks returns how many tasks wait for their prerequisites.
+func (d *Dispatcher) waitingTasks() int {
+	d.depsMu.Lock()
+	defer d.depsMu.Unlock()
+	return len(d.waiting)
+}
@@ -0,0 +1,133 @@
+package dispatcher
+
+import (
+	"context"
+	"errors"
+	"slices"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/cexll/swe/internal/taskstore"
+	"github.com/cexll/swe/internal/webhook"
+)
+
+// storeExecutor records the tasks it runs in store as the executor does,
+// running fn for each attempt.
+type storeExecutor struct {
+	store *taskstore.Store
+	fn    func(task *webhook.Task) error
+
+	mu  sync.Mutex
+	ran []string
+}
+
+func (s *storeExecutor) Execute(_ context.Context, task *webhook.Task) error {
+	s.mu.Lock()
+	s.ran = append(s.ran, task.ID)
+	s.mu.Unlock()
+	s.store.UpdateStatus(task.ID, taskstore.StatusRunning)
+	err := s.fn(task)
+	if err != nil {
+		s.store.UpdateStatus(task.ID, taskstore.StatusFailed)
+	} else {
+		s.store.UpdateStatus(task.ID, taskstore.StatusCompleted)
+	}
+	return err
+}
+
+func (s *storeExecutor) runs() []string {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	return append([]string(nil), s.ran...)
+}
+
+func newChainDispatcher(t *testing.T, fn func(task *webhook.Task) error) (*Dispatcher, *storeExecutor) {
+	t.Helper()
+	exec := &storeExecutor{store: taskstore.NewStore(), fn: fn}
+	d := New(exec, Config{Workers: 2, MaxAttempts: 2, InitialBackoff: 5 * time.Millisecond, MaxBackoff: 5 * time.Millisecond})
+	d.SetPrerequisites(exec.store)
+	t.Cleanup(func() { d.Shutdown(context.Background()) })
+	return d, exec
+}
+
+func enqueueChained(t *testing.T, d *Dispatcher, store *taskstore.Store, id string, deps ...string) {
+	t.Helper()
+	store.Create(&taskstore.Task{ID: id, Status: taskstore.StatusPending, DependsOn: deps})
+	if err := d.Enqueue(&webhook.Task{ID: id, Repo: "owner/repo", Number: len(id), DependsOn: deps}); err != nil {
+		t.Fatalf("Enqueue(%s): %v", id, err)
+	}
+}
+
+func drain(t *testing.T, d *Dispatcher) {
+	t.Helper()
+	ctx, cancel := context.WithTimeout(context.Background(), 2*time.Second)
+	defer cancel()
+	if err := d.Drain(ctx); err != nil {
+		t.Fatalf("Drain: %v", err)
+	}
+}
+
+func TestDispatcher_DependentWaitsForPrerequisite(t *testing.T) {
+	release := make(chan struct{})
+	failedOnce := false
+	d, exec := newChainDispatcher(t, func(task *webhook.Task) error {
+		if task.ID != "plan" {
+			return nil
+		}
+		<-release
+		// a failed attempt that is retried does not fail the dependents
+		if !failedOnce {
+			failedOnce = true
+			return errors.New("flaky")
+		}
+		return nil
+	})
+
+	enqueueChained(t, d, exec.store, "plan")
+	enqueueChained(t, d, exec.store, "implement", "plan")
+	enqueueChained(t, d, exec.store, "test", "implement")
+	time.Sleep(30 * time.Millisecond)
+	if runs := exec.runs(); !slices.Equal(runs, []string{"plan"}) {
+		t.Fatalf("ran %v while the plan was running", runs)
+	}
+	if d.idle() {
+		t.Fatal("dispatcher idle with tasks waiting")
+	}
+
+	close(release)
+	drain(t, d)
+	if runs := exec.runs(); !slices.Equal(runs, []string{"plan", "plan", "implement", "test"}) {
+		t.Fatalf("ran %v", runs)
+	}
+	if status, _ := exec.store.Status("test"); status != taskstore.StatusCompleted {
+		t.Fatalf("test task %s", status)
+	}
+}
+
+func TestDispatcher_FailedPrerequisiteFailsDependents(t *testing.T) {
+	d, exec := newChainDispatcher(t, func(task *webhook.Task) error {
+		return errors.New("provider crashed")
+	})
+	exec.store.Create(&taskstore.Task{ID: "done", Status: taskstore.StatusCompleted})
+
+	enqueueChained(t, d, exec.store, "plan", "done")
+	enqueueChained(t, d, exec.store, "implement", "plan")
+	enqueueChained(t, d, exec.store, "test", "implement")
+	enqueueChained(t, d, exec.store, "orphan", "missing")
+	drain(t, d)
+
+	if runs := exec.runs(); !slices.Equal(runs, []string{"plan", "plan"}) {
+		t.Fatalf("ran %v, want only the plan's attempts", runs)
+	}
+	for id, reason := range map[string]string{
+		"implement": "prerequisite task plan failed",
+		"test":      "prerequisite task implement failed",
+		"orphan":    "prerequisite task missing not found",
+	} {
+		task, _ := exec.store.Get(id)
+		if task.Status != taskstore.StatusFailed || task.Logs[len(task.Logs)-1].Message != reason {
+			t.Fatalf("%s: %s %+v, want failed with %q", id, task.Status, task.Logs, reason)
+		}
+	}
+}
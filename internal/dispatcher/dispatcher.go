@@ -4,11 +4,15 @@ import (
 	"context"
 	"errors"
 	"fmt"
-	"log"
+	"log/slog"
 	"sync"
+	"sync/atomic"
 	"time"
 
 	"github.com/cexll/swe/internal/executor"
+	"github.com/cexll/swe/internal/journal"
+	"github.com/cexll/swe/internal/logging"
+	"github.com/cexll/swe/internal/notify"
 	"github.com/cexll/swe/internal/webhook"
 )
 
@@ -25,42 +29,219 @@ type Config struct {
 	InitialBackoff    time.Duration
 	BackoffMultiplier float64
 	MaxBackoff        time.Duration
+	// MaxRunTime is how long a task may run before the reaper cancels it
+	// and fails it with executor.ErrTimedOut (0 lets tasks run forever).
+	MaxRunTime time.Duration
+	// RequeueTimedOut runs a timed-out task once more instead of giving up.
+	RequeueTimedOut bool
 }
 
 // Dispatcher serialises execution per PR and retries failed tasks with backoff
 type Dispatcher struct {
 	executor TaskExecutor
 	cfg      Config
+	cfgMu    sync.RWMutex // guards the retry fields of cfg, which may be reloaded
 
+	// queue holds a slot for every pending task; a worker that receives
+	// one runs the pending task that starts next (see startOrderLocked),
+	// not necessarily the item received
 	queue chan *queueItem
+	// pending holds the tasks waiting for a worker in arrival order; busy
+	// counts workers that picked up a task, runningOrgs those by
+	// organization, and served when each organization and repository last
+	// had a task start, by clock. pendingCond wakes workers waiting for an
+	// organization's cap.
+	pendingMu   sync.Mutex
+	pendingCond *sync.Cond
+	pending     []*queueItem
+	busy        int
+	runningOrgs map[string]int
+	served      map[string]uint64
+	clock       uint64
+	orgLimits   OrgLimits
+	reportMu    sync.Mutex // orders listener calls
+	listener    QueueListener
 
 	keyedLocks *keyedMutex
-
-	stopCh chan struct{}
-	wg     sync.WaitGroup
+	metrics    metrics
+	notifier   *notify.Manager
+
+	// running holds the tasks being executed, for the stuck-task reaper
+	runningMu sync.Mutex
+	running   map[*queueItem]*runningTask
+
+	// waiting holds the tasks whose prerequisites have not completed, in
+	// arrival order; unfinished the IDs of tasks accepted and not yet done
+	depsMu     sync.Mutex
+	prereqs    Prerequisites
+	waiting    []*webhook.Task
+	unfinished map[string]bool
+
+	// journal keeps accepted tasks until they finish (nil keeps none)
+	journal *journal.Journal
+
+	// keys holds the idempotency keys of accepted tasks and when they were
+	// accepted, for journal.KeyTTL
+	keysMu sync.Mutex
+	keys   map[string]time.Time
+	// shared keeps them where other replicas see them (nil keeps them here)
+	shared *sharedKeys
+
+	stopCh   chan struct{}
+	draining atomic.Bool // set by Drain; new tasks are refused
+	wg       sync.WaitGroup
 
 	once sync.Once
 }
 
 type queueItem struct {
-	task    *webhook.Task
-	attempt int
+	task     *webhook.Task
+	attempt  int
+	queuedAt time.Time
+	position int // last position reported to the listener (0 = none)
+	// requeued is set once a timed-out task has been run again
+	requeued bool
+}
+
+// runningTask is a task being executed and how to stop it.
+type runningTask struct {
+	started time.Time
+	cancel  context.CancelCauseFunc
+	reaped  bool
 }
 
 // New creates a dispatcher with the provided configuration
 func New(executor TaskExecutor, cfg Config) *Dispatcher {
 	normalized := normalizeConfig(cfg)
 	d := &Dispatcher{
-		executor:   executor,
-		cfg:        normalized,
-		queue:      make(chan *queueItem, normalized.QueueSize),
-		keyedLocks: newKeyedMutex(),
-		stopCh:     make(chan struct{}),
+		executor:    executor,
+		cfg:         normalized,
+		queue:       make(chan *queueItem, normalized.QueueSize),
+		keyedLocks:  newKeyedMutex(),
+		running:     make(map[*queueItem]*runningTask),
+		runningOrgs: make(map[string]int),
+		served:      make(map[string]uint64),
+		unfinished:  make(map[string]bool),
+		keys:        make(map[string]time.Time),
+		stopCh:      make(chan struct{}),
 	}
+	d.pendingCond = sync.NewCond(&d.pendingMu)
 	d.startWorkers()
+	go d.reaper(reapInterval)
 	return d
 }
 
+// SetRetryPolicy replaces the retry settings (MaxAttempts and backoff) and
+// the run time limit of a running dispatcher. Workers and QueueSize are
+// fixed at construction.
+func (d *Dispatcher) SetRetryPolicy(cfg Config) {
+	normalized := normalizeConfig(cfg)
+	d.cfgMu.Lock()
+	defer d.cfgMu.Unlock()
+	d.cfg.MaxAttempts = normalized.MaxAttempts
+	d.cfg.InitialBackoff = normalized.InitialBackoff
+	d.cfg.BackoffMultiplier = normalized.BackoffMultiplier
+	d.cfg.MaxBackoff = normalized.MaxBackoff
+	d.cfg.MaxRunTime = normalized.MaxRunTime
+	d.cfg.RequeueTimedOut = normalized.RequeueTimedOut
+}
+
+// retryPolicy returns a consistent snapshot of the retry settings.
+func (d *Dispatcher) retryPolicy() Config {
+	d.cfgMu.RLock()
+	defer d.cfgMu.RUnlock()
+	return d.cfg
+}
+
+// SetJournal records each accepted task in j until it finishes, so that
+// the queue can be rebuilt after a restart (nil records none). The
+// idempotency keys of the tasks j saw finish are accepted no more.
+func (d *Dispatcher) SetJournal(j *journal.Journal) {
+	d.journal = j
+	d.AcceptKeys(j.Keys())
+}
+
+// AcceptKeys marks keys as accepted, such as those of the tasks finished by
+// the process a journal was taken over from.
+func (d *Dispatcher) AcceptKeys(keys []string) {
+	d.keysMu.Lock()
+	defer d.keysMu.Unlock()
+	for _, key := range keys {
+		d.keys[key] = time.Now()
+	}
+}
+
+// Accepted reports whether a task with the idempotency key was accepted
+// within journal.KeyTTL, by this process or, with SetSharedKeys, by a
+// replica sharing its storage.
+func (d *Dispatcher) Accepted(key string) bool {
+	if key == "" {
+		return false
+	}
+	d.keysMu.Lock()
+	d.expireKeysLocked()
+	_, ok := d.keys[key]
+	d.keysMu.Unlock()
+	return ok || d.acceptedShared(key)
+}
+
+// claim marks the idempotency key of task as accepted. It returns false when
+// it already was, here or by another replica.
+func (d *Dispatcher) claim(task *webhook.Task) bool {
+	if task.IdempotencyKey == "" {
+		return true
+	}
+	d.keysMu.Lock()
+	d.expireKeysLocked()
+	if _, ok := d.keys[task.IdempotencyKey]; ok {
+		d.keysMu.Unlock()
+		return false
+	}
+	d.keys[task.IdempotencyKey] = time.Now()
+	d.keysMu.Unlock()
+	// a key another replica holds stays claimed here too
+	return d.claimShared(task)
+}
+
+// unclaim releases the key of a task that was refused.
+func (d *Dispatcher) unclaim(task *webhook.Task) {
+	if task.IdempotencyKey == "" {
+		return
+	}
+	d.keysMu.Lock()
+	delete(d.keys, task.IdempotencyKey)
+	d.keysMu.Unlock()
+	d.unclaimShared(task)
+}
+
+func (d *Dispatcher) expireKeysLocked() {
+	cutoff := time.Now().Add(-journal.KeyTTL)
+	for key, at := range d.keys {
+		if at.Before(cutoff) {
+			delete(d.keys, key)
+		}
+	}
+}
+
+// record adds task to the journal before it is queued.
+func (d *Dispatcher) record(task *webhook.Task) {
+	if err := d.journal.Add(task.ID, task.IdempotencyKey, task); err != nil {
+		slog.ErrorContext(task.LogContext(context.Background()), "Failed to journal task", logging.KeyPhase, "queue", "err", err)
+	}
+}
+
+// forget removes a finished task from the journal.
+func (d *Dispatcher) forget(task *webhook.Task) {
+	if err := d.journal.Done(task.ID); err != nil {
+		slog.ErrorContext(task.LogContext(context.Background()), "Failed to journal task", logging.KeyPhase, "queue", "err", err)
+	}
+}
+
+// SetNotifier enables dead-letter notifications for tasks the dispatcher gives up on.
+func (d *Dispatcher) SetNotifier(n *notify.Manager) {
+	d.notifier = n
+}
+
 func normalizeConfig(cfg Config) Config {
 	if cfg.Workers <= 0 {
 		cfg.Workers = 4
@@ -86,11 +267,14 @@ func normalizeConfig(cfg Config) Config {
 func (d *Dispatcher) startWorkers() {
 	for i := 0; i < d.cfg.Workers; i++ {
 		d.wg.Add(1)
-		go d.worker()
+		go d.worker(i)
 	}
 }
 
-// Enqueue queues a new task for execution
+// Enqueue queues a new task for execution. A task that depends on others
+// waits until they complete, and fails without running if one does not.
+// A task whose idempotency key was already accepted is refused with
+// webhook.ErrDuplicateTask.
 func (d *Dispatcher) Enqueue(task *webhook.Task) error {
 	if task == nil {
 		return errors.New("dispatcher enqueue: task is nil")
@@ -101,65 +285,99 @@ func (d *Dispatcher) Enqueue(task *webhook.Task) error {
 		return webhook.ErrQueueClosed
 	default:
 	}
+	if d.draining.Load() {
+		return webhook.ErrQueueClosed
+	}
 
-	select {
-	case d.queue <- &queueItem{task: task, attempt: 1}:
+	if !d.claim(task) {
+		return webhook.ErrDuplicateTask
+	}
+	d.record(task)
+	if d.hold(task) {
 		return nil
-	default:
+	}
+	if !d.push(&queueItem{task: task, attempt: 1}) {
+		d.unhold(task)
+		d.unclaim(task)
+		if err := d.journal.Drop(task.ID); err != nil {
+			slog.ErrorContext(task.LogContext(context.Background()), "Failed to journal task", logging.KeyPhase, "queue", "err", err)
+		}
 		return webhook.ErrQueueFull
 	}
+	d.reportQueue(nil)
+	return nil
 }
 
-func (d *Dispatcher) worker() {
+func (d *Dispatcher) worker(id int) {
 	defer d.wg.Done()
 
 	for {
 		select {
 		case <-d.stopCh:
 			return
-		case item, ok := <-d.queue:
+		case _, ok := <-d.queue:
 			if !ok {
 				return
 			}
-			d.process(item)
+			item := d.dequeue()
+			if item == nil {
+				return
+			}
+			d.process(id, item)
+			d.finished(item)
 		}
 	}
 }
 
-func (d *Dispatcher) process(item *queueItem) {
+func (d *Dispatcher) process(workerID int, item *queueItem) {
 	task := item.task
 	task.Attempt = item.attempt
 
 	key := fmt.Sprintf("%s#%d", task.Repo, task.Number)
 	d.keyedLocks.Lock(key)
 
-	ctx := context.Background()
+	d.metrics.workerStarted(workerID, task, key, item.attempt)
+	start := time.Now()
+
+	ctx := d.started(item)
+	ctx = task.LogContext(ctx)
 	err := d.executor.Execute(ctx, task)
+	d.stopped(item)
 
+	d.metrics.workerFinished(workerID, time.Since(start), err)
 	d.keyedLocks.Unlock(key)
 
 	if err != nil {
-		log.Printf("Task %s attempt %d failed: %v", key, item.attempt, err)
+		slog.WarnContext(ctx, "Task attempt failed", "attempt", item.attempt, "err", err)
+		if errors.Is(err, executor.ErrTimedOut) {
+			d.handleTimeout(item, err)
+			return
+		}
 		if executor.IsNonRetryable(err) {
-			log.Printf("Task %s attempt %d marked non-retryable; no further attempts", key, item.attempt)
+			slog.WarnContext(ctx, "Task attempt marked non-retryable; no further attempts", "attempt", item.attempt)
+			d.deadLetter(item, d.retryPolicy().MaxAttempts, err)
 			return
 		}
 		d.handleRetry(item, err)
 		return
 	}
 
-	log.Printf("Task %s attempt %d succeeded", key, item.attempt)
+	slog.InfoContext(ctx, "Task attempt succeeded", "attempt", item.attempt)
+	d.resolve(task)
 }
 
 func (d *Dispatcher) handleRetry(item *queueItem, execErr error) {
-	if item.attempt >= d.cfg.MaxAttempts {
-		log.Printf("Task %s#%d exceeded max attempts (%d): %v", item.task.Repo, item.task.Number, d.cfg.MaxAttempts, execErr)
+	policy := d.retryPolicy()
+	if item.attempt >= policy.MaxAttempts {
+		slog.ErrorContext(item.task.LogContext(context.Background()), "Task exceeded max attempts", "number", item.task.Number, "max_attempts", policy.MaxAttempts, "err", execErr)
+		d.deadLetter(item, policy.MaxAttempts, execErr)
 		return
 	}
 
 	nextAttempt := item.attempt + 1
-	delay := d.backoffDuration(nextAttempt)
-	log.Printf("Scheduling retry %d for %s#%d in %s", nextAttempt, item.task.Repo, item.task.Number, delay)
+	delay := policy.backoff(nextAttempt)
+	slog.InfoContext(item.task.LogContext(context.Background()), "Scheduling retry", "attempt", nextAttempt, "number", item.task.Number, "delay", delay)
+	d.metrics.retryScheduled(item.task, fmt.Sprintf("%s#%d", item.task.Repo, item.task.Number), nextAttempt, delay, execErr)
 
 	go func() {
 		timer := time.NewTimer(delay)
@@ -168,43 +386,99 @@ func (d *Dispatcher) handleRetry(item *queueItem, execErr error) {
 		select {
 		case <-timer.C:
 			d.enqueueRetry(&queueItem{
-				task:    item.task,
-				attempt: nextAttempt,
+				task:     item.task,
+				attempt:  nextAttempt,
+				requeued: item.requeued,
 			})
 		case <-d.stopCh:
+			d.metrics.retryDropped(item.task)
 			return
 		}
 	}()
 }
 
+// deadLetter reports a task that will not be attempted again.
+func (d *Dispatcher) deadLetter(item *queueItem, maxAttempts int, execErr error) {
+	d.notifier.Notify(notify.Event{
+		Type:   notify.EventDeadLettered,
+		TaskID: item.task.ID,
+		Repo:   item.task.Repo,
+		Number: item.task.Number,
+		IsPR:   item.task.IsPR,
+		Actor:  item.task.Username,
+		Error:  fmt.Sprintf("gave up after attempt %d/%d: %v", item.attempt, maxAttempts, execErr),
+	})
+	d.resolve(item.task)
+}
+
 func (d *Dispatcher) enqueueRetry(item *queueItem) {
 	for {
 		select {
 		case <-d.stopCh:
 			return
-		case d.queue <- item:
-			return
 		default:
-			time.Sleep(100 * time.Millisecond)
 		}
+		if d.push(item) {
+			return
+		}
+		time.Sleep(100 * time.Millisecond)
 	}
 }
 
 func (d *Dispatcher) backoffDuration(attempt int) time.Duration {
-	backoff := float64(d.cfg.InitialBackoff)
+	return d.retryPolicy().backoff(attempt)
+}
+
+func (cfg Config) backoff(attempt int) time.Duration {
+	backoff := float64(cfg.InitialBackoff)
 	for i := 1; i < attempt; i++ {
-		backoff *= d.cfg.BackoffMultiplier
-		if backoff >= float64(d.cfg.MaxBackoff) {
-			return d.cfg.MaxBackoff
+		backoff *= cfg.BackoffMultiplier
+		if backoff >= float64(cfg.MaxBackoff) {
+			return cfg.MaxBackoff
 		}
 	}
 	return time.Duration(backoff)
 }
 
+// Drain refuses new tasks and waits until the queued and running tasks and
+// the retries already scheduled have finished, or ctx is done. Call
+// Shutdown afterwards to stop the workers.
+func (d *Dispatcher) Drain(ctx context.Context) error {
+	d.draining.Store(true)
+	ticker := time.NewTicker(100 * time.Millisecond)
+	defer ticker.Stop()
+	for !d.idle() {
+		select {
+		case <-ctx.Done():
+			return ctx.Err()
+		case <-ticker.C:
+		}
+	}
+	return nil
+}
+
+// idle reports whether no task is queued, running or waiting for a retry
+// or its prerequisites.
+func (d *Dispatcher) idle() bool {
+	if d.waitingTasks() > 0 {
+		return false
+	}
+	d.pendingMu.Lock()
+	working := len(d.pending) > 0 || d.busy > 0
+	d.pendingMu.Unlock()
+	d.metrics.mu.Lock()
+	defer d.metrics.mu.Unlock()
+	return !working && len(d.metrics.retries) == 0
+}
+
 // Shutdown gracefully stops the dispatcher
 func (d *Dispatcher) Shutdown(ctx context.Context) {
 	d.once.Do(func() {
 		close(d.stopCh)
+		// workers waiting for an organization's cap stop too
+		d.pendingMu.Lock()
+		d.wakeLocked()
+		d.pendingMu.Unlock()
 	})
 
 	done := make(chan struct{})
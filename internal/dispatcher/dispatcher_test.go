@@ -5,9 +5,12 @@ import (
 	"errors"
 	"fmt"
 	"sync"
+	"sync/atomic"
 	"testing"
 	"time"
 
+	"github.com/cexll/swe/internal/journal"
+	"github.com/cexll/swe/internal/notify"
 	"github.com/cexll/swe/internal/webhook"
 )
 
@@ -226,6 +229,25 @@ func TestDispatcherBackoffDuration(t *testing.T) {
 	}
 }
 
+func TestDispatcherSetRetryPolicy(t *testing.T) {
+	d := &Dispatcher{cfg: normalizeConfig(Config{Workers: 3, QueueSize: 7})}
+	d.SetRetryPolicy(Config{MaxAttempts: 5, InitialBackoff: 2 * time.Second, BackoffMultiplier: 3, MaxBackoff: time.Minute, Workers: 99})
+
+	policy := d.retryPolicy()
+	if policy.MaxAttempts != 5 || policy.Workers != 3 || policy.QueueSize != 7 {
+		t.Fatalf("policy = %+v; want new retry settings with workers and queue unchanged", policy)
+	}
+	if got := d.backoffDuration(2); got != 6*time.Second {
+		t.Fatalf("backoff attempt 2 = %s, want 6s", got)
+	}
+
+	// zero values fall back to defaults rather than disabling retries
+	d.SetRetryPolicy(Config{})
+	if policy := d.retryPolicy(); policy.MaxAttempts != 3 || policy.InitialBackoff != 15*time.Second {
+		t.Fatalf("defaults not applied: %+v", policy)
+	}
+}
+
 func TestDispatcherHandleRetryMaxAttempts(t *testing.T) {
 	d := &Dispatcher{
 		cfg: Config{MaxAttempts: 1},
@@ -238,6 +260,42 @@ func TestDispatcherHandleRetryMaxAttempts(t *testing.T) {
 	// No panic and no enqueue expected
 }
 
+type recordingNotifier struct {
+	events chan notify.Event
+}
+
+func (r *recordingNotifier) Name() string { return "recording" }
+
+func (r *recordingNotifier) Notify(_ context.Context, ev notify.Event) error {
+	r.events <- ev
+	return nil
+}
+
+func TestDispatcherDeadLetterNotifies(t *testing.T) {
+	rec := &recordingNotifier{events: make(chan notify.Event, 2)}
+	n := notify.NewManager(notify.Route{Notifier: rec})
+
+	d := &Dispatcher{cfg: Config{MaxAttempts: 2}}
+	d.SetNotifier(n)
+	d.handleRetry(&queueItem{
+		task:    &webhook.Task{ID: "t1", Repo: "owner/repo", Number: 7, IsPR: true, Username: "alice"},
+		attempt: 2,
+	}, errors.New("boom"))
+	n.Wait()
+
+	select {
+	case ev := <-rec.events:
+		if ev.Type != notify.EventDeadLettered || ev.TaskID != "t1" || ev.Number != 7 || !ev.IsPR || ev.Actor != "alice" {
+			t.Fatalf("unexpected event: %+v", ev)
+		}
+		if ev.Error != "gave up after attempt 2/2: boom" {
+			t.Fatalf("Error = %q", ev.Error)
+		}
+	default:
+		t.Fatal("expected dead-letter notification")
+	}
+}
+
 func TestDispatcherEnqueueRetryStopsWhenClosed(t *testing.T) {
 	d := &Dispatcher{
 		queue:  make(chan *queueItem, 1),
@@ -246,3 +304,165 @@ func TestDispatcherEnqueueRetryStopsWhenClosed(t *testing.T) {
 	close(d.stopCh)
 	d.enqueueRetry(&queueItem{task: &webhook.Task{}, attempt: 2})
 }
+
+func TestDispatcherDrainWaitsForQueuedTasksAndRetries(t *testing.T) {
+	var mu sync.Mutex
+	var ran []string
+	exec := &mockExecutor{
+		fn: func(ctx context.Context, task *webhook.Task) error {
+			time.Sleep(20 * time.Millisecond)
+			mu.Lock()
+			defer mu.Unlock()
+			ran = append(ran, fmt.Sprintf("%s/%d", task.ID, task.Attempt))
+			if task.ID == "flaky" && task.Attempt == 1 {
+				return errors.New("transient")
+			}
+			return nil
+		},
+	}
+	d := New(exec, Config{
+		Workers:        1,
+		QueueSize:      4,
+		MaxAttempts:    2,
+		InitialBackoff: 30 * time.Millisecond,
+		MaxBackoff:     30 * time.Millisecond,
+	})
+	defer d.Shutdown(context.Background())
+
+	for i, id := range []string{"flaky", "queued"} {
+		if err := d.Enqueue(&webhook.Task{ID: id, Repo: "owner/repo", Number: i + 1}); err != nil {
+			t.Fatalf("Enqueue(%s): %v", id, err)
+		}
+	}
+
+	ctx, cancel := context.WithTimeout(context.Background(), 2*time.Second)
+	defer cancel()
+	if err := d.Drain(ctx); err != nil {
+		t.Fatalf("Drain: %v", err)
+	}
+	mu.Lock()
+	got := fmt.Sprint(ran)
+	mu.Unlock()
+	if got != "[flaky/1 queued/1 flaky/2]" {
+		t.Fatalf("ran %s before Drain returned", got)
+	}
+	if err := d.Enqueue(&webhook.Task{ID: "late"}); !errors.Is(err, webhook.ErrQueueClosed) {
+		t.Fatalf("Enqueue while draining = %v, want ErrQueueClosed", err)
+	}
+}
+
+func TestDispatcherDrainGivesUpWithContext(t *testing.T) {
+	release := make(chan struct{})
+	defer close(release)
+	started := make(chan struct{})
+	d := New(&mockExecutor{fn: func(context.Context, *webhook.Task) error {
+		close(started)
+		<-release
+		return nil
+	}}, Config{Workers: 1, QueueSize: 1, MaxAttempts: 1})
+
+	if err := d.Enqueue(&webhook.Task{ID: "slow"}); err != nil {
+		t.Fatal(err)
+	}
+	<-started
+	ctx, cancel := context.WithTimeout(context.Background(), 50*time.Millisecond)
+	defer cancel()
+	if err := d.Drain(ctx); !errors.Is(err, context.DeadlineExceeded) {
+		t.Fatalf("Drain = %v, want deadline exceeded", err)
+	}
+}
+
+func TestDispatcher_JournalsTasksUntilTheyFinish(t *testing.T) {
+	j, err := journal.Open(t.TempDir() + "/journal.jsonl")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer j.Close()
+	var mu sync.Mutex
+	journaled := map[string]bool{}
+	d := New(&mockExecutor{fn: func(ctx context.Context, task *webhook.Task) error {
+		for _, e := range j.Pending() {
+			mu.Lock()
+			journaled[task.ID] = journaled[task.ID] || e.ID == task.ID
+			mu.Unlock()
+		}
+		if task.ID == "bad" {
+			return errors.New("boom")
+		}
+		return nil
+	}}, Config{Workers: 1, MaxAttempts: 1})
+	defer d.Shutdown(context.Background())
+	d.SetJournal(j)
+
+	for _, id := range []string{"good", "bad"} {
+		if err := d.Enqueue(&webhook.Task{ID: id, Repo: "owner/repo", Number: 1, RawPayload: []byte(`{"action":"created"}`)}); err != nil {
+			t.Fatalf("Enqueue(%s): %v", id, err)
+		}
+	}
+	ctx, cancel := context.WithTimeout(context.Background(), 2*time.Second)
+	defer cancel()
+	if err := d.Drain(ctx); err != nil {
+		t.Fatal(err)
+	}
+	mu.Lock()
+	defer mu.Unlock()
+	if !journaled["good"] || !journaled["bad"] {
+		t.Fatalf("running tasks journaled: %v", journaled)
+	}
+	if pending := j.Pending(); len(pending) != 0 {
+		t.Fatalf("finished tasks left in the journal: %+v", pending)
+	}
+}
+
+func TestDispatcher_RefusesAcceptedIdempotencyKeys(t *testing.T) {
+	path := t.TempDir() + "/journal.jsonl"
+	j, err := journal.Open(path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	var runs atomic.Int32
+	exec := &mockExecutor{fn: func(ctx context.Context, task *webhook.Task) error {
+		runs.Add(1)
+		return nil
+	}}
+	d := New(exec, Config{Workers: 1, MaxAttempts: 1})
+	d.SetJournal(j)
+
+	if err := d.Enqueue(&webhook.Task{ID: "first", Repo: "owner/repo", Number: 1, IdempotencyKey: "guid-1:7"}); err != nil {
+		t.Fatal(err)
+	}
+	err = d.Enqueue(&webhook.Task{ID: "redelivered", Repo: "owner/repo", Number: 1, IdempotencyKey: "guid-1:7"})
+	if !errors.Is(err, webhook.ErrDuplicateTask) {
+		t.Fatalf("Enqueue of a redelivery = %v, want ErrDuplicateTask", err)
+	}
+	if err := d.Enqueue(&webhook.Task{ID: "unkeyed", Repo: "owner/repo", Number: 2}); err != nil {
+		t.Fatal(err)
+	}
+	ctx, cancel := context.WithTimeout(context.Background(), 2*time.Second)
+	defer cancel()
+	if err := d.Drain(ctx); err != nil {
+		t.Fatal(err)
+	}
+	d.Shutdown(context.Background())
+	_ = j.Close()
+	if n := runs.Load(); n != 2 {
+		t.Fatalf("ran %d tasks, want 2", n)
+	}
+
+	// the key outlives a restart
+	j, err = journal.Open(path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer j.Close()
+	d = New(exec, Config{Workers: 1, MaxAttempts: 1})
+	defer d.Shutdown(context.Background())
+	d.SetJournal(j)
+	if !d.Accepted("guid-1:7") || d.Accepted("guid-2:7") {
+		t.Fatal("accepted keys not restored from the journal")
+	}
+	err = d.Enqueue(&webhook.Task{ID: "after-restart", Repo: "owner/repo", Number: 1, IdempotencyKey: "guid-1:7"})
+	if !errors.Is(err, webhook.ErrDuplicateTask) {
+		t.Fatalf("Enqueue after restart = %v, want ErrDuplicateTask", err)
+	}
+}
@@ -0,0 +1,127 @@
+package dispatcher
+
+import (
+	"fmt"
+	"maps"
+	"slices"
+	"strconv"
+	"strings"
+
+	"github.com/cexll/swe/internal/webhook"
+)
+
+// OrgLimits caps how many tasks of one organization (the owner of their
+// repositories) run at once, so that a busy organization leaves workers to
+// the others. A task over the cap waits in the queue; those behind it that
+// are not start first.
+type OrgLimits struct {
+	Default int            // organizations not in Orgs (0: no cap)
+	Orgs    map[string]int // by lower-case organization; 0 lifts the cap
+}
+
+// ParseOrgLimits parses org=N entries.
+func ParseOrgLimits(entries []string) (map[string]int, error) {
+	orgs := make(map[string]int, len(entries))
+	for _, entry := range entries {
+		org, value, ok := strings.Cut(entry, "=")
+		org = strings.ToLower(strings.TrimSpace(org))
+		n, err := strconv.Atoi(strings.TrimSpace(value))
+		if !ok || org == "" || strings.Contains(org, "/") || err != nil || n < 0 {
+			return nil, fmt.Errorf("org limit %q must be org=N", entry)
+		}
+		orgs[org] = n
+	}
+	return orgs, nil
+}
+
+// SetOrgLimits replaces the per-organization caps; tasks waiting for a cap
+// that was raised start at once.
+func (d *Dispatcher) SetOrgLimits(l OrgLimits) {
+	d.pendingMu.Lock()
+	defer d.pendingMu.Unlock()
+	d.orgLimits = OrgLimits{Default: l.Default, Orgs: maps.Clone(l.Orgs)}
+	d.wakeLocked()
+}
+
+// orgOf is the organization a task's repository belongs to.
+func orgOf(task *webhook.Task) string {
+	org, _, _ := strings.Cut(strings.ToLower(task.Repo), "/")
+	return org
+}
+
+// atLimitLocked reports whether org runs as many tasks as it may.
+func (d *Dispatcher) atLimitLocked(org string, running int) bool {
+	limit, ok := d.orgLimits.Orgs[org]
+	if !ok {
+		limit = d.orgLimits.Default
+	}
+	return limit > 0 && running >= limit
+}
+
+// startOrderLocked returns the pending tasks in the order they would start
+// if none came or finished meanwhile: higher priorities first and, within
+// a priority, round-robin across organizations and then their
+// repositories, the least recently served first. Tasks held back by their
+// organization's cap come last, in arrival order; ready counts those before
+// them.
+func (d *Dispatcher) startOrderLocked() (order []*queueItem, ready int) {
+	rest := slices.Clone(d.pending)
+	running := maps.Clone(d.runningOrgs)
+	if running == nil {
+		running = make(map[string]int)
+	}
+	served := maps.Clone(d.served)
+	if served == nil {
+		served = make(map[string]uint64)
+	}
+	clock := d.clock
+	for {
+		best := -1
+		for i, item := range rest {
+			if d.atLimitLocked(orgOf(item.task), running[orgOf(item.task)]) {
+				continue
+			}
+			if best < 0 || startsBefore(item, rest[best], served) {
+				best = i
+			}
+		}
+		if best < 0 {
+			break
+		}
+		item := rest[best]
+		rest = slices.Delete(rest, best, best+1)
+		order = append(order, item)
+		running[orgOf(item.task)]++
+		clock++
+		serve(served, item.task, clock)
+	}
+	return append(order, rest...), len(order)
+}
+
+// startsBefore reports whether a starts before b, which came earlier.
+func startsBefore(a, b *queueItem, served map[string]uint64) bool {
+	if a.task.Priority != b.task.Priority {
+		return a.task.Priority > b.task.Priority
+	}
+	orgA, orgB := orgOf(a.task), orgOf(b.task)
+	if orgA != orgB {
+		return served[orgA] < served[orgB]
+	}
+	return served[strings.ToLower(a.task.Repo)] < served[strings.ToLower(b.task.Repo)]
+}
+
+// serve records that a task of task's organization and repository started
+// at clock. Repositories are keyed owner/name and cannot clash with
+// organizations.
+func serve(served map[string]uint64, task *webhook.Task, clock uint64) {
+	served[orgOf(task)] = clock
+	served[strings.ToLower(task.Repo)] = clock
+}
+
+// wakeLocked wakes the workers waiting in dequeue to look at the queue
+// again.
+func (d *Dispatcher) wakeLocked() {
+	if d.pendingCond != nil {
+		d.pendingCond.Broadcast()
+	}
+}
@@ -0,0 +1,142 @@
+package dispatcher
+
+import (
+	"context"
+	"fmt"
+	"testing"
+	"time"
+
+	"github.com/cexll/swe/internal/webhook"
+)
+
+func TestDispatcherRoundRobinsAcrossOrgsAndRepos(t *testing.T) {
+	release := make(chan struct{})
+	started := make(chan string, 5)
+	exec := &mockExecutor{
+		fn: func(ctx context.Context, task *webhook.Task) error {
+			started <- task.ID
+			<-release
+			return nil
+		},
+	}
+
+	d := New(exec, Config{Workers: 1, QueueSize: 8, MaxAttempts: 1})
+	defer d.Shutdown(context.Background())
+
+	tasks := []*webhook.Task{
+		{ID: "running", Repo: "big/a"},
+		{ID: "a2", Repo: "big/a"},
+		{ID: "a3", Repo: "Big/A"},
+		{ID: "b", Repo: "big/b"},
+		{ID: "x", Repo: "small/x"},
+	}
+	for i, task := range tasks {
+		task.Number = i + 1
+		if err := d.Enqueue(task); err != nil {
+			t.Fatalf("Enqueue(%s): %v", task.ID, err)
+		}
+		if i == 0 {
+			<-started
+		}
+	}
+
+	var order []string
+	for _, q := range d.Queued() {
+		order = append(order, q.TaskID)
+	}
+	if got := fmt.Sprint(order); got != "[x b a2 a3]" {
+		t.Fatalf("Queued() = %s", got)
+	}
+	close(release)
+	for _, want := range order {
+		if id := <-started; id != want {
+			t.Fatalf("started %s, want %s", id, want)
+		}
+	}
+}
+
+func TestDispatcherCapsTasksPerOrg(t *testing.T) {
+	releases := map[string]chan struct{}{"a1": make(chan struct{}), "a2": make(chan struct{}), "x": make(chan struct{})}
+	started := make(chan string, 3)
+	exec := &mockExecutor{
+		fn: func(ctx context.Context, task *webhook.Task) error {
+			started <- task.ID
+			<-releases[task.ID]
+			return nil
+		},
+	}
+
+	d := New(exec, Config{Workers: 3, QueueSize: 8, MaxAttempts: 1})
+	defer d.Shutdown(context.Background())
+	d.SetOrgLimits(OrgLimits{Default: 1, Orgs: map[string]int{"small": 0}})
+
+	for i, task := range []*webhook.Task{
+		{ID: "a1", Repo: "big/a"},
+		{ID: "a2", Repo: "big/b"},
+		{ID: "x", Repo: "small/x"},
+	} {
+		task.Number = i + 1
+		if err := d.Enqueue(task); err != nil {
+			t.Fatalf("Enqueue(%s): %v", task.ID, err)
+		}
+	}
+	for _, want := range []string{"a1", "x"} {
+		if id := <-started; id != want {
+			t.Fatalf("started %s, want %s", id, want)
+		}
+	}
+	if q, ok := d.Position("a2"); !ok || q.Position != 1 {
+		t.Fatalf("Position(a2) = %+v, %t; want waiting for the cap", q, ok)
+	}
+	select {
+	case id := <-started:
+		t.Fatalf("started %s over the org cap", id)
+	case <-time.After(50 * time.Millisecond):
+	}
+
+	close(releases["a1"])
+	if id := <-started; id != "a2" {
+		t.Fatalf("started %s, want a2", id)
+	}
+	close(releases["a2"])
+	close(releases["x"])
+}
+
+func TestDispatcherSetOrgLimitsStartsWaitingTasks(t *testing.T) {
+	release := make(chan struct{})
+	started := make(chan string, 2)
+	exec := &mockExecutor{
+		fn: func(ctx context.Context, task *webhook.Task) error {
+			started <- task.ID
+			<-release
+			return nil
+		},
+	}
+
+	d := New(exec, Config{Workers: 2, QueueSize: 4, MaxAttempts: 1})
+	d.SetOrgLimits(OrgLimits{Orgs: map[string]int{"acme": 1}})
+	for i, id := range []string{"first", "second"} {
+		if err := d.Enqueue(&webhook.Task{ID: id, Repo: "acme/repo", Number: i + 1}); err != nil {
+			t.Fatal(err)
+		}
+	}
+	<-started
+	d.SetOrgLimits(OrgLimits{})
+	if id := <-started; id != "second" {
+		t.Fatalf("started %s, want second", id)
+	}
+	close(release)
+	d.Shutdown(context.Background())
+}
+
+func TestParseOrgLimits(t *testing.T) {
+	got, err := ParseOrgLimits([]string{"Acme=8", " small = 0 "})
+	if err != nil || len(got) != 2 || got["acme"] != 8 || got["small"] != 0 {
+		t.Fatalf("ParseOrgLimits = %v, %v", got, err)
+	}
+	for _, bad := range []string{"acme", "acme=x", "acme=-1", "=2", "acme/repo=2"} {
+		if _, err := ParseOrgLimits([]string{bad}); err == nil {
+			t.Errorf("ParseOrgLimits(%q) accepted", bad)
+		}
+	}
+}
@@ -0,0 +1,190 @@
+package dispatcher
+
+import (
+	"context"
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io/fs"
+	"log/slog"
+	"time"
+
+	"github.com/cexll/swe/internal/journal"
+	"github.com/cexll/swe/internal/logging"
+	"github.com/cexll/swe/internal/storage"
+	"github.com/cexll/swe/internal/webhook"
+)
+
+// sharedKeyTimeout bounds each round trip to the shared key store.
+const sharedKeyTimeout = 10 * time.Second
+
+// keyRecord is the object kept for an accepted idempotency key.
+type keyRecord struct {
+	TaskID   string    `json:"task_id"`
+	Accepted time.Time `json:"accepted"`
+}
+
+// sharedKeys keeps accepted idempotency keys in storage shared by the
+// replicas. A key is claimed by creating its object, which exactly one
+// replica can do, so a delivery retried against another replica is refused
+// there too.
+type sharedKeys struct {
+	backend storage.Backend
+}
+
+// keyObject names the object of key; keys are hashed as they may hold any
+// character.
+func keyObject(key string) string {
+	sum := sha256.Sum256([]byte(key))
+	return hex.EncodeToString(sum[:])
+}
+
+// claim records key as accepted for the task taskID. It returns false when
+// another task claimed it within journal.KeyTTL; the same task claiming its
+// key again, as when it is recovered after a restart, succeeds.
+func (s *sharedKeys) claim(ctx context.Context, key, taskID string) (bool, error) {
+	data, err := json.Marshal(keyRecord{TaskID: taskID, Accepted: time.Now()})
+	if err != nil {
+		return false, err
+	}
+	name := keyObject(key)
+	err = s.backend.PutIf(ctx, name, data, "")
+	if !errors.Is(err, storage.ErrPrecondition) {
+		return err == nil, err
+	}
+	rec, version, err := s.read(ctx, name)
+	if errors.Is(err, fs.ErrNotExist) {
+		// released since; whoever creates it next wins
+		err = s.backend.PutIf(ctx, name, data, "")
+		if errors.Is(err, storage.ErrPrecondition) {
+			return false, nil
+		}
+		return err == nil, err
+	}
+	if err != nil {
+		return false, err
+	}
+	if rec.TaskID == taskID {
+		return true, nil
+	}
+	if time.Since(rec.Accepted) < journal.KeyTTL {
+		return false, nil
+	}
+	// the earlier claim expired: take it over, unless another replica
+	// just did
+	err = s.backend.PutIf(ctx, name, data, version)
+	if errors.Is(err, storage.ErrPrecondition) {
+		return false, nil
+	}
+	return err == nil, err
+}
+
+// claimed reports whether key was claimed within journal.KeyTTL.
+func (s *sharedKeys) claimed(ctx context.Context, key string) (bool, error) {
+	rec, _, err := s.read(ctx, keyObject(key))
+	if errors.Is(err, fs.ErrNotExist) {
+		return false, nil
+	}
+	if err != nil {
+		return false, err
+	}
+	return time.Since(rec.Accepted) < journal.KeyTTL, nil
+}
+
+// release forgets the claim on key of a task that was refused.
+func (s *sharedKeys) release(ctx context.Context, key string) error {
+	return s.backend.Delete(ctx, keyObject(key))
+}
+
+func (s *sharedKeys) read(ctx context.Context, name string) (keyRecord, string, error) {
+	data, version, err := s.backend.GetVersion(ctx, name)
+	if err != nil {
+		return keyRecord{}, "", err
+	}
+	var rec keyRecord
+	if err := json.Unmarshal(data, &rec); err != nil {
+		return keyRecord{}, "", fmt.Errorf("idempotency key %s: %w", name, err)
+	}
+	return rec, version, nil
+}
+
+// SetSharedKeys keeps the idempotency keys of accepted tasks in b as well,
+// so that replicas sharing b refuse each other's duplicates. Call it before
+// tasks are enqueued; nil keeps them in this process only.
+func (d *Dispatcher) SetSharedKeys(b storage.Backend) {
+	if b == nil {
+		d.shared = nil
+		return
+	}
+	d.shared = &sharedKeys{backend: b}
+}
+
+// PruneSharedKeys deletes the shared idempotency keys accepted more than
+// journal.KeyTTL ago and returns how many it deleted.
+func (d *Dispatcher) PruneSharedKeys(ctx context.Context) (int, error) {
+	if d.shared == nil {
+		return 0, nil
+	}
+	objs, err := d.shared.backend.List(ctx, "")
+	if err != nil {
+		return 0, err
+	}
+	cutoff := time.Now().Add(-journal.KeyTTL)
+	n := 0
+	for _, o := range objs {
+		if !o.Modified.Before(cutoff) {
+			continue
+		}
+		if err := d.shared.backend.Delete(ctx, o.Key); err != nil && !errors.Is(err, fs.ErrNotExist) {
+			return n, err
+		}
+		n++
+	}
+	return n, nil
+}
+
+// claimShared claims the key of task in the shared store. A store that
+// cannot be reached accepts the task, as refusing every task while it is
+// down would be worse than a rare duplicate.
+func (d *Dispatcher) claimShared(task *webhook.Task) bool {
+	if d.shared == nil || task.IdempotencyKey == "" {
+		return true
+	}
+	ctx, cancel := context.WithTimeout(context.Background(), sharedKeyTimeout)
+	defer cancel()
+	ok, err := d.shared.claim(ctx, task.IdempotencyKey, task.ID)
+	if err != nil {
+		slog.WarnContext(task.LogContext(ctx), "Failed to claim idempotency key in shared storage", logging.KeyPhase, "queue", "err", err)
+		return true
+	}
+	return ok
+}
+
+// unclaimShared releases the shared key of a task that was refused.
+func (d *Dispatcher) unclaimShared(task *webhook.Task) {
+	if d.shared == nil || task.IdempotencyKey == "" {
+		return
+	}
+	ctx, cancel := context.WithTimeout(context.Background(), sharedKeyTimeout)
+	defer cancel()
+	if err := d.shared.release(ctx, task.IdempotencyKey); err != nil && !errors.Is(err, fs.ErrNotExist) {
+		slog.WarnContext(task.LogContext(ctx), "Failed to release idempotency key in shared storage", logging.KeyPhase, "queue", "err", err)
+	}
+}
+
+// acceptedShared reports whether another replica accepted a task with key.
+func (d *Dispatcher) acceptedShared(key string) bool {
+	if d.shared == nil {
+		return false
+	}
+	ctx, cancel := context.WithTimeout(context.Background(), sharedKeyTimeout)
+	defer cancel()
+	ok, err := d.shared.claimed(ctx, key)
+	if err != nil {
+		slog.Warn("Failed to look up idempotency key in shared storage", logging.KeyPhase, "queue", "err", err)
+		return false
+	}
+	return ok
+}
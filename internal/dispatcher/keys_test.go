@@ -0,0 +1,75 @@
+package dispatcher
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"github.com/cexll/swe/internal/journal"
+	"github.com/cexll/swe/internal/storage"
+	"github.com/cexll/swe/internal/webhook"
+)
+
+func TestDispatcher_SharesIdempotencyKeysAcrossReplicas(t *testing.T) {
+	dir := t.TempDir()
+	shared := storage.WithPrefix(&storage.Local{Dir: dir}, "idempotency")
+	replica := func() *Dispatcher {
+		d := New(&mockExecutor{}, Config{Workers: 1, MaxAttempts: 1})
+		t.Cleanup(func() { d.Shutdown(context.Background()) })
+		d.SetSharedKeys(shared)
+		return d
+	}
+	a, b := replica(), replica()
+
+	if err := a.Enqueue(&webhook.Task{ID: "first", Repo: "owner/repo", Number: 1, IdempotencyKey: "guid-1:7"}); err != nil {
+		t.Fatal(err)
+	}
+	err := b.Enqueue(&webhook.Task{ID: "redelivered", Repo: "owner/repo", Number: 1, IdempotencyKey: "guid-1:7"})
+	if !errors.Is(err, webhook.ErrDuplicateTask) {
+		t.Fatalf("Enqueue of a redelivery on another replica = %v, want ErrDuplicateTask", err)
+	}
+	if !b.Accepted("guid-1:7") || b.Accepted("guid-2:7") {
+		t.Fatal("Accepted does not see the other replica's keys")
+	}
+
+	// the task itself, recovered after a restart, claims its key again
+	if err := replica().Enqueue(&webhook.Task{ID: "first", Repo: "owner/repo", Number: 1, IdempotencyKey: "guid-1:7"}); err != nil {
+		t.Fatalf("Enqueue of a recovered task = %v", err)
+	}
+
+	// an expired claim is taken over
+	old, _ := json.Marshal(keyRecord{TaskID: "old", Accepted: time.Now().Add(-journal.KeyTTL - time.Hour)})
+	if err := shared.Put(context.Background(), keyObject("guid-3:7"), old); err != nil {
+		t.Fatal(err)
+	}
+	if err := b.Enqueue(&webhook.Task{ID: "later", Repo: "owner/repo", Number: 1, IdempotencyKey: "guid-3:7"}); err != nil {
+		t.Fatalf("Enqueue over an expired key = %v", err)
+	}
+}
+
+func TestDispatcher_PruneSharedKeys(t *testing.T) {
+	dir := t.TempDir()
+	d := New(&mockExecutor{}, Config{Workers: 1, MaxAttempts: 1})
+	defer d.Shutdown(context.Background())
+	d.SetSharedKeys(storage.WithPrefix(&storage.Local{Dir: dir}, "idempotency"))
+
+	for i, key := range []string{"old", "new"} {
+		if err := d.Enqueue(&webhook.Task{ID: key, Repo: "owner/repo", Number: i + 1, IdempotencyKey: key}); err != nil {
+			t.Fatal(err)
+		}
+	}
+	stale := time.Now().Add(-journal.KeyTTL - time.Hour)
+	if err := os.Chtimes(filepath.Join(dir, "idempotency", keyObject("old")), stale, stale); err != nil {
+		t.Fatal(err)
+	}
+	if n, err := d.PruneSharedKeys(context.Background()); err != nil || n != 1 {
+		t.Fatalf("PruneSharedKeys = %d, %v, want 1", n, err)
+	}
+	if ok, _ := d.shared.claimed(context.Background(), "new"); !ok {
+		t.Fatal("pruned a key still in use")
+	}
+}
@@ -0,0 +1,186 @@
+package dispatcher
+
+import (
+	"fmt"
+	"slices"
+	"time"
+
+	"github.com/cexll/swe/internal/webhook"
+)
+
+// QueuedTask describes a task waiting for a free worker.
+type QueuedTask struct {
+	TaskID   string        `json:"task_id"`
+	TaskKey  string        `json:"task_key"`
+	Priority string        `json:"priority"` // high, normal or low
+	Attempt  int           `json:"attempt"`
+	Position int           `json:"position"` // 1 starts next
+	QueuedAt time.Time     `json:"queued_at"`
+	ETA      time.Duration `json:"eta_ns"` // estimated wait until it starts; 0 when unknown
+}
+
+// QueueListener is told where a first attempt stands while it waits behind
+// other tasks. Position 0 means a worker has picked the task up; it is only
+// reported for tasks that were told a position before.
+type QueueListener interface {
+	QueuePosition(task *webhook.Task, position int, eta time.Duration)
+}
+
+// SetQueueListener enables queue position reports (nil disables).
+func (d *Dispatcher) SetQueueListener(l QueueListener) {
+	d.reportMu.Lock()
+	defer d.reportMu.Unlock()
+	d.listener = l
+}
+
+// Queued returns the tasks waiting for a worker in the order they will start.
+func (d *Dispatcher) Queued() []QueuedTask {
+	avg := d.avgExecution()
+	d.pendingMu.Lock()
+	defer d.pendingMu.Unlock()
+	order, _ := d.startOrderLocked()
+	out := make([]QueuedTask, 0, len(order))
+	for i, item := range order {
+		out = append(out, QueuedTask{
+			TaskID:   item.task.ID,
+			TaskKey:  fmt.Sprintf("%s#%d", item.task.Repo, item.task.Number),
+			Priority: item.task.Priority.String(),
+			Attempt:  item.attempt,
+			Position: i + 1,
+			QueuedAt: item.queuedAt,
+			ETA:      d.eta(i+1, avg),
+		})
+	}
+	return out
+}
+
+// Position reports where taskID waits in the queue; ok is false when it is
+// not waiting (unknown, running or finished).
+func (d *Dispatcher) Position(taskID string) (QueuedTask, bool) {
+	for _, q := range d.Queued() {
+		if q.TaskID == taskID {
+			return q, true
+		}
+	}
+	return QueuedTask{}, false
+}
+
+// push sends item to the workers without blocking and records it as
+// pending.
+func (d *Dispatcher) push(item *queueItem) bool {
+	d.pendingMu.Lock()
+	defer d.pendingMu.Unlock()
+	if item.queuedAt.IsZero() {
+		item.queuedAt = time.Now()
+	}
+	select {
+	case d.queue <- item:
+		// a worker that already received the slot waits for the lock in
+		// dequeue, and finds item pending
+		d.pending = append(d.pending, item)
+		d.wakeLocked()
+		return true
+	default:
+		return false
+	}
+}
+
+// dequeue takes the pending task that starts next for a worker that
+// received a slot, waiting while every pending task is held back by its
+// organization's cap. It returns nil once the dispatcher stops.
+func (d *Dispatcher) dequeue() *queueItem {
+	d.pendingMu.Lock()
+	for {
+		if order, ready := d.startOrderLocked(); ready > 0 {
+			item := order[0]
+			d.pending = slices.DeleteFunc(d.pending, func(p *queueItem) bool { return p == item })
+			d.busy++
+			org := orgOf(item.task)
+			d.runningOrgs[org]++
+			d.clock++
+			serve(d.served, item.task, d.clock)
+			d.pendingMu.Unlock()
+			d.reportQueue(item)
+			return item
+		}
+		select {
+		case <-d.stopCh:
+			d.pendingMu.Unlock()
+			return nil
+		default:
+		}
+		d.pendingCond.Wait()
+	}
+}
+
+// finished marks the worker that ran item as free again.
+func (d *Dispatcher) finished(item *queueItem) {
+	d.pendingMu.Lock()
+	d.busy--
+	org := orgOf(item.task)
+	if d.runningOrgs[org]--; d.runningOrgs[org] <= 0 {
+		delete(d.runningOrgs, org)
+	}
+	d.wakeLocked()
+	d.pendingMu.Unlock()
+}
+
+// reportQueue tells the listener about every queue position that changed,
+// and that started (when non-nil) left the queue. A task is behind others
+// once more tasks are ahead of it than workers are free, or its
+// organization is at its cap.
+func (d *Dispatcher) reportQueue(started *queueItem) {
+	d.reportMu.Lock()
+	defer d.reportMu.Unlock()
+	if d.listener == nil {
+		return
+	}
+	type report struct {
+		task     *webhook.Task
+		position int
+	}
+	var reports []report
+
+	d.pendingMu.Lock()
+	if started != nil && started.position > 0 {
+		started.position = 0
+		reports = append(reports, report{task: started.task})
+	}
+	free := d.cfg.Workers - d.busy
+	order, ready := d.startOrderLocked()
+	for i, item := range order {
+		pos := i + 1
+		if item.attempt > 1 || pos == item.position || (pos <= min(free, ready) && item.position == 0) {
+			continue
+		}
+		item.position = pos
+		reports = append(reports, report{task: item.task, position: pos})
+	}
+	d.pendingMu.Unlock()
+
+	avg := d.avgExecution()
+	for _, r := range reports {
+		d.listener.QueuePosition(r.task, r.position, d.eta(r.position, avg))
+	}
+}
+
+// eta estimates how long the task at position waits: one average execution
+// for every full round of workers ahead of it.
+func (d *Dispatcher) eta(position int, avg time.Duration) time.Duration {
+	if position <= 0 || avg <= 0 {
+		return 0
+	}
+	workers := max(d.cfg.Workers, 1)
+	rounds := (position + workers - 1) / workers
+	return time.Duration(rounds) * avg
+}
+
+func (d *Dispatcher) avgExecution() time.Duration {
+	m := &d.metrics
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	if m.executions == 0 {
+		return 0
+	}
+	return m.totalDuration / time.Duration(m.executions)
+}
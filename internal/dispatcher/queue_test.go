@@ -0,0 +1,159 @@
+package dispatcher
+
+import (
+	"context"
+	"fmt"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/cexll/swe/internal/webhook"
+)
+
+type recordingListener struct {
+	mu      sync.Mutex
+	reports []string
+}
+
+func (l *recordingListener) QueuePosition(task *webhook.Task, position int, _ time.Duration) {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+	l.reports = append(l.reports, fmt.Sprintf("%s@%d", task.ID, position))
+}
+
+func (l *recordingListener) snapshot() []string {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+	return append([]string(nil), l.reports...)
+}
+
+func TestDispatcherReportsQueuePositions(t *testing.T) {
+	release := make(chan struct{})
+	started := make(chan string, 3)
+	exec := &mockExecutor{
+		fn: func(ctx context.Context, task *webhook.Task) error {
+			started <- task.ID
+			<-release
+			return nil
+		},
+	}
+
+	d := New(exec, Config{Workers: 1, QueueSize: 4, MaxAttempts: 1})
+	defer d.Shutdown(context.Background())
+	listener := &recordingListener{}
+	d.SetQueueListener(listener)
+
+	for i, id := range []string{"a", "b", "c"} {
+		if err := d.Enqueue(&webhook.Task{ID: id, Repo: "owner/repo", Number: i + 1}); err != nil {
+			t.Fatalf("Enqueue(%s): %v", id, err)
+		}
+		if id == "a" {
+			<-started
+		}
+	}
+
+	queued := d.Queued()
+	if len(queued) != 2 || queued[0].TaskID != "b" || queued[1].Position != 2 || queued[1].TaskKey != "owner/repo#3" {
+		t.Fatalf("Queued() = %+v, want b then c", queued)
+	}
+	if q, ok := d.Position("c"); !ok || q.Position != 2 {
+		t.Fatalf("Position(c) = %+v, %t", q, ok)
+	}
+	if _, ok := d.Position("a"); ok {
+		t.Fatal("running task should have no queue position")
+	}
+
+	release <- struct{}{} // a finishes, b starts
+	if id := <-started; id != "b" {
+		t.Fatalf("started %s, want b", id)
+	}
+	close(release)
+	<-started
+
+	want := []string{"b@1", "c@2", "b@0", "c@1", "c@0"}
+	deadline := time.Now().Add(time.Second)
+	for fmt.Sprint(listener.snapshot()) != fmt.Sprint(want) {
+		if time.Now().After(deadline) {
+			t.Fatalf("reports = %v, want %v", listener.snapshot(), want)
+		}
+		time.Sleep(5 * time.Millisecond)
+	}
+}
+
+func TestDispatcherSkipsPositionsWhenWorkersFree(t *testing.T) {
+	done := make(chan struct{}, 2)
+	d := New(&mockExecutor{fn: func(context.Context, *webhook.Task) error {
+		done <- struct{}{}
+		return nil
+	}}, Config{Workers: 2, QueueSize: 4, MaxAttempts: 1})
+	defer d.Shutdown(context.Background())
+	listener := &recordingListener{}
+	d.SetQueueListener(listener)
+
+	for i := 1; i <= 2; i++ {
+		if err := d.Enqueue(&webhook.Task{ID: fmt.Sprint(i), Repo: "owner/repo", Number: i}); err != nil {
+			t.Fatal(err)
+		}
+	}
+	<-done
+	<-done
+	if got := listener.snapshot(); len(got) != 0 {
+		t.Fatalf("reports = %v, want none while workers are free", got)
+	}
+}
+
+func TestDispatcherETA(t *testing.T) {
+	d := &Dispatcher{cfg: Config{Workers: 2}}
+	if got := d.eta(3, 0); got != 0 {
+		t.Fatalf("eta without history = %v, want 0", got)
+	}
+	if got := d.eta(3, time.Minute); got != 2*time.Minute {
+		t.Fatalf("eta(3) = %v, want 2m", got)
+	}
+}
+
+func TestDispatcherStartsHigherPrioritiesFirst(t *testing.T) {
+	release := make(chan struct{})
+	started := make(chan string, 5)
+	exec := &mockExecutor{
+		fn: func(ctx context.Context, task *webhook.Task) error {
+			started <- task.ID
+			<-release
+			return nil
+		},
+	}
+
+	d := New(exec, Config{Workers: 1, QueueSize: 8, MaxAttempts: 1})
+	defer d.Shutdown(context.Background())
+
+	tasks := []*webhook.Task{
+		{ID: "running", Priority: webhook.PriorityLow},
+		{ID: "batch", Priority: webhook.PriorityLow},
+		{ID: "issue", Priority: webhook.PriorityNormal},
+		{ID: "review", Priority: webhook.PriorityHigh},
+		{ID: "issue2", Priority: webhook.PriorityNormal},
+	}
+	for i, task := range tasks {
+		task.Repo, task.Number = "owner/repo", i+1
+		if err := d.Enqueue(task); err != nil {
+			t.Fatalf("Enqueue(%s): %v", task.ID, err)
+		}
+		if i == 0 {
+			<-started
+		}
+	}
+
+	var order []string
+	for _, q := range d.Queued() {
+		order = append(order, q.TaskID+":"+q.Priority)
+	}
+	if got := fmt.Sprint(order); got != "[review:high issue:normal issue2:normal batch:low]" {
+		t.Fatalf("Queued() = %s", got)
+	}
+	close(release)
+	for _, want := range []string{"review", "issue", "issue2", "batch"} {
+		if id := <-started; id != want {
+			t.Fatalf("started %s, want %s", id, want)
+		}
+	}
+}
@@ -0,0 +1,89 @@
+package dispatcher
+
+import (
+	"context"
+	"fmt"
+	"log/slog"
+	"time"
+
+	"github.com/cexll/swe/internal/executor"
+)
+
+// reapInterval is how often running tasks are checked against MaxRunTime.
+const reapInterval = 30 * time.Second
+
+// started registers item as running and returns the context it runs with,
+// which the reaper cancels when the task runs too long.
+func (d *Dispatcher) started(item *queueItem) context.Context {
+	ctx, cancel := context.WithCancelCause(context.Background())
+	d.runningMu.Lock()
+	defer d.runningMu.Unlock()
+	if d.running == nil {
+		d.running = make(map[*queueItem]*runningTask)
+	}
+	d.running[item] = &runningTask{started: time.Now(), cancel: cancel}
+	return ctx
+}
+
+// stopped unregisters item once its execution returned.
+func (d *Dispatcher) stopped(item *queueItem) {
+	d.runningMu.Lock()
+	defer d.runningMu.Unlock()
+	if r, ok := d.running[item]; ok {
+		r.cancel(nil)
+		delete(d.running, item)
+	}
+}
+
+// reaper cancels tasks running longer than MaxRunTime until Shutdown.
+func (d *Dispatcher) reaper(interval time.Duration) {
+	ticker := time.NewTicker(interval)
+	defer ticker.Stop()
+	for {
+		select {
+		case <-d.stopCh:
+			return
+		case now := <-ticker.C:
+			d.reapStuck(now)
+		}
+	}
+}
+
+// reapStuck cancels the tasks that have been running longer than MaxRunTime
+// at now, with executor.ErrTimedOut as the cause, and returns how many. The
+// executor then fails them with the timeout as the reason and says so in
+// their tracking comments.
+func (d *Dispatcher) reapStuck(now time.Time) int {
+	limit := d.retryPolicy().MaxRunTime
+	if limit <= 0 {
+		return 0
+	}
+	d.runningMu.Lock()
+	defer d.runningMu.Unlock()
+	n := 0
+	for item, r := range d.running {
+		if r.reaped || now.Sub(r.started) <= limit {
+			continue
+		}
+		r.reaped = true
+		slog.WarnContext(item.task.LogContext(context.Background()), "Task ran too long; cancelling it", "number", item.task.Number, "running", now.Sub(r.started).Round(time.Second))
+		r.cancel(fmt.Errorf("%w after running for more than %s", executor.ErrTimedOut, limit))
+		n++
+	}
+	return n
+}
+
+// handleTimeout gives up on a task the reaper cancelled, or with
+// RequeueTimedOut runs it once more.
+func (d *Dispatcher) handleTimeout(item *queueItem, execErr error) {
+	policy := d.retryPolicy()
+	if !policy.RequeueTimedOut || item.requeued {
+		d.deadLetter(item, item.attempt, execErr)
+		return
+	}
+	key := fmt.Sprintf("%s#%d", item.task.Repo, item.task.Number)
+	slog.InfoContext(item.task.LogContext(context.Background()), "Requeueing timed-out task once", "number", item.task.Number)
+	next := &queueItem{task: item.task, attempt: item.attempt + 1, requeued: true}
+	d.metrics.retryScheduled(item.task, key, next.attempt, 0, execErr)
+	go d.enqueueRetry(next)
+}
@@ -0,0 +1,116 @@
+package dispatcher
+
+import (
+	"context"
+	"fmt"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/cexll/swe/internal/notify"
+	"github.com/cexll/swe/internal/webhook"
+)
+
+// hangingExecutor runs until its context is cancelled, like a stuck provider.
+func hangingExecutor(attempts chan<- int) *mockExecutor {
+	return &mockExecutor{fn: func(ctx context.Context, task *webhook.Task) error {
+		attempts <- task.Attempt
+		<-ctx.Done()
+		return fmt.Errorf("%w: provider: %v", context.Cause(ctx), ctx.Err())
+	}}
+}
+
+// reapWhenRunning calls reapStuck far in the future until it cancels a task.
+func reapWhenRunning(t *testing.T, d *Dispatcher) {
+	t.Helper()
+	deadline := time.Now().Add(time.Second)
+	for d.reapStuck(time.Now().Add(time.Hour)) == 0 {
+		if time.Now().After(deadline) {
+			t.Fatal("no running task to reap")
+		}
+		time.Sleep(time.Millisecond)
+	}
+}
+
+func TestDispatcherReapsStuckTasks(t *testing.T) {
+	attempts := make(chan int, 4)
+	rec := &recordingNotifier{events: make(chan notify.Event, 2)}
+	d := New(hangingExecutor(attempts), Config{Workers: 1, MaxAttempts: 3, MaxRunTime: time.Minute})
+	defer d.Shutdown(context.Background())
+	d.SetNotifier(notify.NewManager(notify.Route{Notifier: rec}))
+
+	if d.reapStuck(time.Now().Add(time.Hour)) != 0 {
+		t.Fatal("reaped with nothing running")
+	}
+	if err := d.Enqueue(&webhook.Task{ID: "t1", Repo: "owner/repo", Number: 1}); err != nil {
+		t.Fatal(err)
+	}
+	<-attempts
+	if d.reapStuck(time.Now()) != 0 {
+		t.Fatal("reaped a task within its run time")
+	}
+	reapWhenRunning(t, d)
+
+	// timed-out tasks are given up on, not retried
+	select {
+	case ev := <-rec.events:
+		if ev.TaskID != "t1" || !strings.Contains(ev.Error, "task timed out after running for more than 1m0s") {
+			t.Fatalf("dead letter = %+v", ev)
+		}
+	case <-time.After(time.Second):
+		t.Fatal("expected dead-letter notification")
+	}
+	select {
+	case a := <-attempts:
+		t.Fatalf("timed-out task ran again (attempt %d)", a)
+	case <-time.After(50 * time.Millisecond):
+	}
+}
+
+func TestDispatcherRequeuesTimedOutTaskOnce(t *testing.T) {
+	attempts := make(chan int, 4)
+	d := New(hangingExecutor(attempts), Config{Workers: 1, MaxAttempts: 3, MaxRunTime: time.Minute, RequeueTimedOut: true})
+	defer d.Shutdown(context.Background())
+
+	if err := d.Enqueue(&webhook.Task{ID: "t1", Repo: "owner/repo", Number: 1}); err != nil {
+		t.Fatal(err)
+	}
+	if a := <-attempts; a != 1 {
+		t.Fatalf("first attempt = %d", a)
+	}
+	reapWhenRunning(t, d)
+	select {
+	case a := <-attempts:
+		if a != 2 {
+			t.Fatalf("requeued attempt = %d, want 2", a)
+		}
+	case <-time.After(time.Second):
+		t.Fatal("timed-out task was not requeued")
+	}
+	reapWhenRunning(t, d)
+	select {
+	case a := <-attempts:
+		t.Fatalf("task requeued twice (attempt %d)", a)
+	case <-time.After(50 * time.Millisecond):
+	}
+}
+
+func TestDispatcherReaperDisabled(t *testing.T) {
+	release := make(chan struct{})
+	var once sync.Once
+	started := make(chan struct{})
+	d := New(&mockExecutor{fn: func(ctx context.Context, _ *webhook.Task) error {
+		once.Do(func() { close(started) })
+		<-release
+		return nil
+	}}, Config{Workers: 1})
+	defer d.Shutdown(context.Background())
+	defer close(release)
+
+	_ = d.Enqueue(&webhook.Task{ID: "t1", Repo: "owner/repo", Number: 1})
+	<-started
+	if n := d.reapStuck(time.Now().Add(24 * time.Hour)); n != 0 {
+		t.Fatalf("reaped %d tasks without MaxRunTime", n)
+	}
+}
@@ -0,0 +1,178 @@
+package dispatcher
+
+import (
+	"sort"
+	"sync"
+	"time"
+
+	"github.com/cexll/swe/internal/executor"
+	"github.com/cexll/swe/internal/webhook"
+)
+
+// WorkerStatus describes what a single worker is doing right now.
+type WorkerStatus struct {
+	ID        int       `json:"id"`
+	Busy      bool      `json:"busy"`
+	TaskID    string    `json:"task_id,omitempty"`
+	TaskKey   string    `json:"task_key,omitempty"`
+	Attempt   int       `json:"attempt,omitempty"`
+	StartedAt time.Time `json:"started_at,omitempty"`
+}
+
+// RetryStatus describes a task waiting for its next retry attempt.
+type RetryStatus struct {
+	TaskID        string        `json:"task_id"`
+	TaskKey       string        `json:"task_key"`
+	NextAttempt   int           `json:"next_attempt"`
+	Backoff       time.Duration `json:"backoff_ns"`
+	NextAttemptAt time.Time     `json:"next_attempt_at"`
+	LastError     string        `json:"last_error"`
+}
+
+// ProviderStats aggregates provider failures observed by the dispatcher.
+type ProviderStats struct {
+	Name      string  `json:"name"`
+	Errors    int     `json:"errors"`
+	ErrorRate float64 `json:"error_rate"` // errors / total executions
+}
+
+// Stats is a point-in-time snapshot of dispatcher health.
+type Stats struct {
+	QueueDepth     int             `json:"queue_depth"`
+	QueueCapacity  int             `json:"queue_capacity"`
+	Queued         []QueuedTask    `json:"queued"`
+	Workers        int             `json:"workers"`
+	ActiveWorkers  int             `json:"active_workers"`
+	WorkerStatus   []WorkerStatus  `json:"worker_status"`
+	PendingRetries []RetryStatus   `json:"pending_retries"`
+	Executions     int             `json:"executions"`
+	Succeeded      int             `json:"succeeded"`
+	Failed         int             `json:"failed"`
+	AvgExecution   time.Duration   `json:"avg_execution_ns"`
+	Providers      []ProviderStats `json:"providers"`
+}
+
+// metrics tracks worker activity and execution outcomes. The zero value is
+// ready to use so partially constructed dispatchers (tests) stay safe.
+type metrics struct {
+	mu             sync.Mutex
+	workers        map[int]*WorkerStatus
+	retries        map[*webhook.Task]*RetryStatus
+	executions     int
+	failures       int
+	totalDuration  time.Duration
+	providerErrors map[string]int
+}
+
+func (m *metrics) workerStarted(id int, task *webhook.Task, key string, attempt int) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	if m.workers == nil {
+		m.workers = make(map[int]*WorkerStatus)
+	}
+	m.workers[id] = &WorkerStatus{
+		ID:        id,
+		Busy:      true,
+		TaskID:    task.ID,
+		TaskKey:   key,
+		Attempt:   attempt,
+		StartedAt: time.Now(),
+	}
+	delete(m.retries, task)
+}
+
+func (m *metrics) workerFinished(id int, elapsed time.Duration, err error) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	if m.workers != nil {
+		m.workers[id] = &WorkerStatus{ID: id}
+	}
+	m.executions++
+	m.totalDuration += elapsed
+	if err == nil {
+		return
+	}
+	m.failures++
+	if name := executor.ProviderName(err); name != "" {
+		if m.providerErrors == nil {
+			m.providerErrors = make(map[string]int)
+		}
+		m.providerErrors[name]++
+	}
+}
+
+func (m *metrics) retryScheduled(task *webhook.Task, key string, attempt int, delay time.Duration, err error) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	if m.retries == nil {
+		m.retries = make(map[*webhook.Task]*RetryStatus)
+	}
+	status := &RetryStatus{
+		TaskID:        task.ID,
+		TaskKey:       key,
+		NextAttempt:   attempt,
+		Backoff:       delay,
+		NextAttemptAt: time.Now().Add(delay),
+	}
+	if err != nil {
+		status.LastError = err.Error()
+	}
+	m.retries[task] = status
+}
+
+func (m *metrics) retryDropped(task *webhook.Task) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	delete(m.retries, task)
+}
+
+// Stats returns a snapshot of the queue, worker activity, pending retries
+// and execution outcomes.
+func (d *Dispatcher) Stats() Stats {
+	s := Stats{
+		QueueDepth:    len(d.queue),
+		QueueCapacity: cap(d.queue),
+		Queued:        d.Queued(),
+		Workers:       d.cfg.Workers,
+	}
+
+	m := &d.metrics
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	for i := 0; i < d.cfg.Workers; i++ {
+		ws := WorkerStatus{ID: i}
+		if cur, ok := m.workers[i]; ok {
+			ws = *cur
+		}
+		if ws.Busy {
+			s.ActiveWorkers++
+		}
+		s.WorkerStatus = append(s.WorkerStatus, ws)
+	}
+
+	for _, r := range m.retries {
+		s.PendingRetries = append(s.PendingRetries, *r)
+	}
+	sort.Slice(s.PendingRetries, func(i, j int) bool {
+		return s.PendingRetries[i].NextAttemptAt.Before(s.PendingRetries[j].NextAttemptAt)
+	})
+
+	s.Executions = m.executions
+	s.Failed = m.failures
+	s.Succeeded = m.executions - m.failures
+	if m.executions > 0 {
+		s.AvgExecution = m.totalDuration / time.Duration(m.executions)
+	}
+
+	for name, n := range m.providerErrors {
+		s.Providers = append(s.Providers, ProviderStats{
+			Name:      name,
+			Errors:    n,
+			ErrorRate: float64(n) / float64(m.executions),
+		})
+	}
+	sort.Slice(s.Providers, func(i, j int) bool { return s.Providers[i].Name < s.Providers[j].Name })
+
+	return s
+}
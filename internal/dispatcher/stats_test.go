@@ -0,0 +1,102 @@
+package dispatcher
+
+import (
+	"context"
+	"errors"
+	"testing"
+	"time"
+
+	"github.com/cexll/swe/internal/executor"
+	"github.com/cexll/swe/internal/webhook"
+)
+
+func TestDispatcherStats_TracksWorkersAndOutcomes(t *testing.T) {
+	started := make(chan struct{})
+	release := make(chan struct{})
+	done := make(chan struct{}, 2)
+
+	exec := &mockExecutor{
+		fn: func(ctx context.Context, task *webhook.Task) error {
+			defer func() { done <- struct{}{} }()
+			if task.ID == "slow" {
+				close(started)
+				<-release
+				return nil
+			}
+			return &executor.ProviderError{Provider: "claude", Err: errors.New("boom")}
+		},
+	}
+
+	d := New(exec, Config{
+		Workers:        1,
+		QueueSize:      4,
+		MaxAttempts:    2,
+		InitialBackoff: time.Hour,
+		MaxBackoff:     time.Hour,
+	})
+	defer d.Shutdown(context.Background())
+
+	if err := d.Enqueue(&webhook.Task{ID: "slow", Repo: "owner/repo", Number: 1}); err != nil {
+		t.Fatalf("Enqueue returned error: %v", err)
+	}
+	<-started
+
+	stats := d.Stats()
+	if stats.Workers != 1 || stats.ActiveWorkers != 1 {
+		t.Fatalf("workers = %d active = %d, want 1/1", stats.Workers, stats.ActiveWorkers)
+	}
+	if ws := stats.WorkerStatus[0]; !ws.Busy || ws.TaskID != "slow" || ws.TaskKey != "owner/repo#1" || ws.Attempt != 1 {
+		t.Fatalf("unexpected worker status: %+v", ws)
+	}
+
+	if err := d.Enqueue(&webhook.Task{ID: "failing", Repo: "owner/repo", Number: 2}); err != nil {
+		t.Fatalf("Enqueue returned error: %v", err)
+	}
+	if got := d.Stats().QueueDepth; got != 1 {
+		t.Fatalf("QueueDepth = %d, want 1", got)
+	}
+
+	close(release)
+	for i := 0; i < 2; i++ {
+		select {
+		case <-done:
+		case <-time.After(time.Second):
+			t.Fatal("timed out waiting for executions")
+		}
+	}
+
+	// workerFinished runs after Execute returns; poll briefly for the final state
+	deadline := time.Now().Add(time.Second)
+	for d.Stats().Executions < 2 && time.Now().Before(deadline) {
+		time.Sleep(5 * time.Millisecond)
+	}
+
+	stats = d.Stats()
+	if stats.Executions != 2 || stats.Succeeded != 1 || stats.Failed != 1 {
+		t.Fatalf("executions=%d succeeded=%d failed=%d, want 2/1/1", stats.Executions, stats.Succeeded, stats.Failed)
+	}
+	if len(stats.Providers) != 1 || stats.Providers[0].Name != "claude" || stats.Providers[0].ErrorRate != 0.5 {
+		t.Fatalf("unexpected provider stats: %+v", stats.Providers)
+	}
+	if len(stats.PendingRetries) != 1 {
+		t.Fatalf("PendingRetries = %d, want 1", len(stats.PendingRetries))
+	}
+	retry := stats.PendingRetries[0]
+	if retry.TaskID != "failing" || retry.NextAttempt != 2 || retry.Backoff != time.Hour || retry.LastError == "" {
+		t.Fatalf("unexpected retry status: %+v", retry)
+	}
+	if stats.ActiveWorkers != 0 {
+		t.Fatalf("ActiveWorkers = %d, want 0", stats.ActiveWorkers)
+	}
+}
+
+func TestDispatcherStats_ZeroValue(t *testing.T) {
+	d := &Dispatcher{cfg: Config{Workers: 2}}
+	stats := d.Stats()
+	if stats.Workers != 2 || len(stats.WorkerStatus) != 2 || stats.ActiveWorkers != 0 {
+		t.Fatalf("unexpected stats for idle dispatcher: %+v", stats)
+	}
+	if stats.AvgExecution != 0 {
+		t.Fatalf("AvgExecution = %s, want 0", stats.AvgExecution)
+	}
+}
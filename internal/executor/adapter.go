@@ -11,6 +11,7 @@ import (
 // It converts webhook.Task into github.Context and forwards execution.
 type Adapter struct {
 	inner *Executor
+	held  func(*webhook.Task) // see SetHoldHandler
 }
 
 // NewAdapter creates a new adapter for the given Executor.
@@ -18,6 +19,13 @@ func NewAdapter(inner *Executor) *Adapter {
 	return &Adapter{inner: inner}
 }
 
+// SetHoldHandler sets fn to be called with each task whose pushes a policy
+// rule held once its commits are kept for the hold command, so the webhook
+// handler can register it for apply.
+func (a *Adapter) SetHoldHandler(fn func(*webhook.Task)) {
+	a.held = fn
+}
+
 // Execute implements dispatcher.TaskExecutor by translating a webhook.Task into
 // a github.Context using the raw webhook payload and event type.
 func (a *Adapter) Execute(ctx context.Context, task *webhook.Task) error {
@@ -40,7 +48,33 @@ func (a *Adapter) Execute(ctx context.Context, task *webhook.Task) error {
 	if task.CommentID != 0 {
 		ghCtx.PreparedCommentID = task.CommentID
 	}
+	ghCtx.PreparedRelease = task.Release
+	ghCtx.PreparedTriage = task.Triage
+	ghCtx.PreparedApprovalCommand = task.ApprovalCommand
+	ghCtx.PreparedApprovedPlan = task.ApprovedPlan
+	ghCtx.PreparedApprovedBy = task.ApprovedBy
+	ghCtx.PreparedApplyCommand = task.ApplyCommand
+	ghCtx.PreparedApplyTaskID = task.Applies
+	ghCtx.PreparedAddressReviews = task.AddressReviews
+	ghCtx.PreparedBackport = task.BackportTo
+	ghCtx.PreparedUpdateDeps = task.UpdateDeps
+	ghCtx.PreparedTimeout = task.Timeout
+	ghCtx.PreparedDebug = task.Debug
+	ghCtx.PreparedLang = task.Lang
+	ghCtx.PreparedCommentHistory = task.CommentHistory
+	ghCtx.PreparedPolicyInput = task.PolicyInput
+	ghCtx.PreparedHoldCommand = task.HoldCommand
+	ghCtx.PreparedConfirmCommand = task.ConfirmCommand
+	ghCtx.TaskID = task.ID
+	ghCtx.DeliveryID = task.DeliveryID
 
 	// Delegate to the real executor
-	return a.inner.Execute(ctx, ghCtx)
+	if err := a.inner.Execute(ctx, ghCtx); err != nil {
+		return err
+	}
+	// a held task finishes as a dry run awaiting its hold command
+	if a.held != nil && task.ApplyCommand == "" && ghCtx.PreparedApplyCommand != "" {
+		a.held(task)
+	}
+	return nil
 }
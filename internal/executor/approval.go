@@ -0,0 +1,53 @@
+package executor
+
+import (
+	"fmt"
+	"strings"
+
+	"github.com/cexll/swe/internal/github"
+	"github.com/cexll/swe/internal/github/comment"
+)
+
+// planOnly reports whether ctx's task only posts a plan (approval mode).
+func planOnly(ctx *github.Context) bool {
+	return ctx.PreparedApprovalCommand != ""
+}
+
+// approvalPromptSection tells the provider to plan without changing
+// anything, or which approved plan to carry out; "" for other tasks.
+func approvalPromptSection(ctx *github.Context) string {
+	if planOnly(ctx) {
+		return fmt.Sprintf(`<approval_required>
+This repository requires a second person's approval before code changes are pushed. In this run, do not commit, push or create branches, pull requests or issues; pushes are rejected.
+Investigate the request and reply with a concrete plan: the files you would change, how, and how you would test it. Post the plan in the tracking comment.
+Once another user with write access comments %q, a new run carries out the plan.
+</approval_required>`, ctx.PreparedApprovalCommand)
+	}
+	if ctx.PreparedApprovedBy != "" {
+		plan := strings.TrimSpace(ctx.PreparedApprovedPlan)
+		if plan == "" {
+			plan = "(the plan posted in the tracking comment)"
+		}
+		return fmt.Sprintf(`<approved_plan approved_by="%s">
+%s
+</approved_plan>
+Carry out this approved plan. Keep to what it describes; if it turns out to be wrong, say where you deviated and why.`, ctx.PreparedApprovedBy, plan)
+	}
+	return ""
+}
+
+// awaitApproval records the plan in the task store and tells the thread how
+// to approve it.
+func (e *Executor) awaitApproval(ctx *github.Context, plan string) {
+	if e.store != nil && ctx.TaskID != "" {
+		e.store.AwaitApproval(ctx.TaskID, plan)
+		e.store.AddLog(ctx.TaskID, "info", "Plan posted, awaiting approval")
+	}
+	prependNotice(ctx, "> [!IMPORTANT]\n> "+commentText(ctx, comment.MsgAwaitingApproval, ctx.PreparedApprovalCommand))
+}
+
+// reportApproved notes on the tracking comment who approved the plan the
+// task carried out.
+func reportApproved(ctx *github.Context) {
+	prependNotice(ctx, "> [!NOTE]\n> "+commentText(ctx, comment.MsgPlanApproved, ctx.PreparedApprovedBy))
+}
@@ -0,0 +1,116 @@
+package executor
+
+import (
+	"context"
+	"os"
+	"os/exec"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"github.com/cexll/swe/internal/audit"
+	"github.com/cexll/swe/internal/github"
+	ghdata "github.com/cexll/swe/internal/github/data"
+	"github.com/cexll/swe/internal/provider"
+	"github.com/cexll/swe/internal/taskstore"
+)
+
+// approvalExecutor runs tasks in workdir, whose origin is a local remote,
+// with a provider that commits and tries to push; it returns the prompt
+// and push output the provider saw.
+func approvalExecutor(t *testing.T, workdir string) (*Executor, *string, *string) {
+	t.Helper()
+	stubProtected(t)
+	origClone, origRun := cloneRepo, runCmd
+	t.Cleanup(func() { cloneRepo, runCmd = origClone, origRun })
+	cloneRepo = func(_ context.Context, repo, branch, token string) (string, func(), error) {
+		return workdir, func() {}, nil
+	}
+	runCmd = func(name string, args ...string) error {
+		if len(args) > 3 && args[2] == "remote" && args[3] == "set-url" {
+			return nil // keep the local remote
+		}
+		return run(name, args...)
+	}
+
+	var prompt, pushed string
+	e := New(&mockProvider{generateFunc: func(_ context.Context, req *provider.CodeRequest) (*provider.CodeResponse, error) {
+		prompt = req.Prompt
+		if err := os.WriteFile(filepath.Join(workdir, "README.md"), []byte("agent change\n"), 0o644); err != nil {
+			return nil, err
+		}
+		gitIn(t, workdir, "commit", "-q", "-am", "agent change")
+		cmd := exec.Command("git", "-C", workdir, "push", "origin", "HEAD")
+		cmd.Env = append(os.Environ(), req.Env...)
+		out, _ := cmd.CombinedOutput()
+		pushed = string(out)
+		return &provider.CodeResponse{Summary: "1. rewrite README"}, nil
+	}}, &mockAuthProvider{})
+	e.fetcher = &mockFetcher{fetchFunc: func(context.Context, *github.Context) (*ghdata.FetchResult, error) {
+		return &ghdata.FetchResult{ContextData: ghdata.Issue{Title: "t", Author: ghdata.Author{Login: "u"}}}, nil
+	}}
+	return e, &prompt, &pushed
+}
+
+func TestExecute_PlanOnlyHoldsPushes(t *testing.T) {
+	workdir, remote := initPushRepo(t)
+	updated := stubComments(t, "Here is the plan.")
+	e, prompt, pushed := approvalExecutor(t, workdir)
+	store := taskstore.NewStore()
+	store.Create(&taskstore.Task{ID: "task-1"})
+	e.SetTaskStore(store)
+	log, _ := audit.New(audit.Config{})
+	e.SetAuditLog(log)
+
+	ctx := buildTestCtx(false)
+	ctx.TaskID = "task-1"
+	ctx.PreparedCommentID = 7
+	ctx.PreparedApprovalCommand = "/code approve"
+	if err := e.Execute(context.Background(), ctx); err != nil {
+		t.Fatalf("Execute: %v", err)
+	}
+
+	if !strings.Contains(*prompt, "<approval_required>") || !strings.Contains(*prompt, `"push": false`) {
+		t.Fatalf("prompt lacks the plan-only instructions:\n%s", *prompt)
+	}
+	if !strings.Contains(*pushed, "pushes are not allowed in this task (plan awaiting approval)") {
+		t.Fatalf("push should be held: %q", *pushed)
+	}
+	if out := gitIn(t, remote, "branch", "--list", "swe-agent/*"); out != "" {
+		t.Fatalf("remote gained branches: %q", out)
+	}
+	if got, _ := store.Get("task-1"); got.Approval != taskstore.ApprovalAwaiting || got.Plan != "1. rewrite README" {
+		t.Fatalf("task = %+v", got)
+	}
+	if !strings.Contains(*updated, "**Awaiting approval.**") || !strings.Contains(*updated, "comment `/code approve`") ||
+		!strings.HasSuffix(*updated, "Here is the plan.") {
+		t.Fatalf("tracking comment = %q", *updated)
+	}
+	if events := log.List(audit.Filter{Action: audit.ActionGitBlocked}); len(events) != 1 || events[0].Detail != "plan awaiting approval: git push origin" {
+		t.Fatalf("blocked push audit = %+v", events)
+	}
+}
+
+func TestExecute_ApprovedPlanPushes(t *testing.T) {
+	workdir, remote := initPushRepo(t)
+	updated := stubComments(t, "Done.")
+	e, prompt, pushed := approvalExecutor(t, workdir)
+
+	ctx := buildTestCtx(false)
+	ctx.PreparedCommentID = 7
+	ctx.PreparedApprovedPlan = "1. rewrite README"
+	ctx.PreparedApprovedBy = "bob"
+	if err := e.Execute(context.Background(), ctx); err != nil {
+		t.Fatalf("Execute: %v", err)
+	}
+
+	if !strings.Contains(*prompt, "<approved_plan approved_by=\"bob\">\n1. rewrite README\n</approved_plan>") || strings.Contains(*prompt, "<approval_required>") {
+		t.Fatalf("prompt lacks the approved plan:\n%s", *prompt)
+	}
+	if gitIn(t, remote, "show", ctx.PreparedBranch+":README.md") != "agent change" {
+		t.Fatalf("approved run was not pushed: %q", *pushed)
+	}
+	if !strings.HasPrefix(*updated, "> [!NOTE]\n> Plan approved by @bob.") || !strings.HasSuffix(*updated, "Done.") {
+		t.Fatalf("tracking comment = %q", *updated)
+	}
+}
@@ -0,0 +1,316 @@
+package executor
+
+import (
+	"bufio"
+	"encoding/json"
+	"encoding/xml"
+	"fmt"
+	"io"
+	"os"
+	"path"
+	"path/filepath"
+	"strings"
+	"time"
+
+	"github.com/cexll/swe/internal/github"
+)
+
+const (
+	// maxReportedFailures caps the failing tests listed in the tracking comment.
+	maxReportedFailures = 5
+	// maxArtifacts and maxArtifactSize bound what one failed run saves.
+	maxArtifacts    = 50
+	maxArtifactSize = 20 << 20
+	// VerifyLogArtifact holds the full output of the failed verify command.
+	VerifyLogArtifact = "verify.log"
+)
+
+// SetArtifactDir keeps the artifacts of failed verification runs under dir,
+// one subdirectory per task ("" keeps none).
+func (e *Executor) SetArtifactDir(dir string) {
+	e.mu.Lock()
+	defer e.mu.Unlock()
+	e.artifactDir = dir
+}
+
+// CheckFailure is a check command that ran and failed, with its full output.
+type CheckFailure struct {
+	Command string
+	Output  string
+	Err     error
+}
+
+func (f *CheckFailure) Error() string {
+	return fmt.Sprintf("%q failed: %v\n%s", f.Command, f.Err, tail(f.Output, verifyOutputLimit))
+}
+
+func (f *CheckFailure) Unwrap() error { return f.Err }
+
+// testFailure is one failing test found in the verification artifacts.
+type testFailure struct {
+	Name    string
+	Source  string // "go test", "junit"
+	Message string
+}
+
+// failureArtifacts describes what a failed verification run left behind.
+type failureArtifacts struct {
+	Failures []testFailure
+	Files    []string // repository-relative paths of JUnit reports and screenshots
+	Saved    []string // artifact names under Dir
+	Dir      string
+	TaskID   string
+}
+
+// collectFailureArtifacts parses go test -json output and the JUnit reports
+// and Playwright screenshots the verify command wrote into workdir.
+func collectFailureArtifacts(workdir, output string) *failureArtifacts {
+	arts := &failureArtifacts{Failures: parseGoTestJSON(output)}
+	out, err := gitOutput(workdir, "ls-files", "--others", "-z")
+	if err != nil {
+		return arts
+	}
+	for _, f := range gitPaths(out) {
+		if strings.Contains(f, "node_modules/") || len(arts.Files) >= maxArtifacts {
+			continue
+		}
+		full := filepath.Join(workdir, filepath.FromSlash(f))
+		if info, err := os.Stat(full); err != nil || info.Size() > maxArtifactSize {
+			continue
+		}
+		switch ext := strings.ToLower(path.Ext(f)); {
+		case ext == ".xml":
+			failures, ok := parseJUnitFile(full)
+			if ok {
+				arts.Failures = append(arts.Failures, failures...)
+				arts.Files = append(arts.Files, f)
+			}
+		case isPlaywrightArtifact(f, ext):
+			arts.Files = append(arts.Files, f)
+		}
+	}
+	return arts
+}
+
+// isPlaywrightArtifact matches the screenshots, videos and traces Playwright
+// writes into test-results/.
+func isPlaywrightArtifact(f, ext string) bool {
+	if !strings.HasPrefix(f, "test-results/") && !strings.Contains(f, "/test-results/") {
+		return false
+	}
+	switch ext {
+	case ".png", ".jpg", ".jpeg", ".webm", ".zip":
+		return true
+	}
+	return false
+}
+
+// save copies the collected files and the command output into
+// dir/<task>/, scrubbing token from the output.
+func (a *failureArtifacts) save(dir string, ctx *github.Context, workdir, output string) error {
+	a.TaskID = ctx.TaskID
+	if a.TaskID == "" {
+		a.TaskID = fmt.Sprintf("%s-%s-%d-%d", ctx.GetRepositoryOwner(), ctx.GetRepositoryName(), ctx.GetIssueNumber(), time.Now().Unix())
+	}
+	a.Dir = filepath.Join(dir, a.TaskID)
+	if err := os.MkdirAll(a.Dir, 0o755); err != nil {
+		return fmt.Errorf("create artifact dir: %w", err)
+	}
+	if ctx.Token != "" {
+		output = strings.ReplaceAll(output, ctx.Token, "***")
+	}
+	if err := os.WriteFile(filepath.Join(a.Dir, VerifyLogArtifact), []byte(output), 0o644); err != nil {
+		return fmt.Errorf("save verify log: %w", err)
+	}
+	a.Saved = append(a.Saved, VerifyLogArtifact)
+	for _, f := range a.Files {
+		name := strings.ReplaceAll(f, "/", "_")
+		if err := copyFile(filepath.Join(workdir, filepath.FromSlash(f)), filepath.Join(a.Dir, name)); err != nil {
+			return fmt.Errorf("save artifact %s: %w", f, err)
+		}
+		a.Saved = append(a.Saved, name)
+	}
+	return nil
+}
+
+func copyFile(src, dst string) error {
+	in, err := os.Open(src)
+	if err != nil {
+		return err
+	}
+	defer func() { _ = in.Close() }()
+	out, err := os.Create(dst)
+	if err != nil {
+		return err
+	}
+	if _, err := io.Copy(out, in); err != nil {
+		_ = out.Close()
+		return err
+	}
+	return out.Close()
+}
+
+// summary renders the top failures and saved artifacts for the tracking
+// comment; "" when nothing structured was found.
+func (a *failureArtifacts) summary(token string) string {
+	if a == nil || (len(a.Failures) == 0 && len(a.Files) == 0) {
+		return ""
+	}
+	var b strings.Builder
+	if len(a.Failures) > 0 {
+		fmt.Fprintf(&b, "#### Failing tests (%d)\n\n", len(a.Failures))
+		for i, f := range a.Failures {
+			if i == maxReportedFailures {
+				fmt.Fprintf(&b, "...and %d more.\n\n", len(a.Failures)-maxReportedFailures)
+				break
+			}
+			fmt.Fprintf(&b, "- `%s` (%s)\n", f.Name, f.Source)
+			if f.Message != "" {
+				msg := f.Message
+				if token != "" {
+					msg = strings.ReplaceAll(msg, token, "***")
+				}
+				fmt.Fprintf(&b, "  ```\n%s\n  ```\n", indent(msg, "  "))
+			}
+		}
+		b.WriteString("\n")
+	}
+	switch {
+	case len(a.Saved) > 0:
+		fmt.Fprintf(&b, "Artifacts of task `%s`: %s\n", a.TaskID, "`"+strings.Join(a.Saved, "`, `")+"`")
+	case len(a.Files) > 0:
+		fmt.Fprintf(&b, "Artifacts (not kept): %s\n", "`"+strings.Join(a.Files, "`, `")+"`")
+	}
+	return strings.TrimSpace(b.String())
+}
+
+func indent(s, prefix string) string {
+	return prefix + strings.ReplaceAll(s, "\n", "\n"+prefix)
+}
+
+// failureMessage keeps the first lines of a failure's output.
+func failureMessage(lines []string) string {
+	const maxLines, maxLine = 6, 200
+	var kept []string
+	for _, l := range lines {
+		l = strings.TrimRight(l, " \t\r\n")
+		if strings.TrimSpace(l) == "" {
+			continue
+		}
+		if len(l) > maxLine {
+			l = l[:maxLine] + "..."
+		}
+		kept = append(kept, l)
+		if len(kept) == maxLines {
+			break
+		}
+	}
+	return strings.Join(kept, "\n")
+}
+
+// goTestEvent is one line of go test -json (test2json) output.
+type goTestEvent struct {
+	Action  string
+	Package string
+	Test    string
+	Output  string
+}
+
+// parseGoTestJSON returns the failing tests in go test -json output, and
+// packages that failed without a failing test (build errors).
+func parseGoTestJSON(output string) []testFailure {
+	type key struct{ pkg, test string }
+	outputs := make(map[key][]string)
+	failedTests := make(map[string]bool)
+	var failures []testFailure
+	sc := bufio.NewScanner(strings.NewReader(output))
+	sc.Buffer(make([]byte, 0, 64*1024), 1<<20)
+	for sc.Scan() {
+		line := strings.TrimSpace(sc.Text())
+		if !strings.HasPrefix(line, "{") {
+			continue
+		}
+		var ev goTestEvent
+		if json.Unmarshal([]byte(line), &ev) != nil || ev.Action == "" {
+			continue
+		}
+		k := key{ev.Package, ev.Test}
+		switch ev.Action {
+		case "output":
+			out := strings.TrimSpace(ev.Output)
+			if strings.HasPrefix(out, "=== ") || strings.HasPrefix(out, "--- ") || out == "FAIL" || strings.HasPrefix(out, "FAIL\t") {
+				continue
+			}
+			outputs[k] = append(outputs[k], ev.Output)
+		case "fail":
+			if ev.Test != "" {
+				failedTests[ev.Package] = true
+				failures = append(failures, testFailure{Name: ev.Test + " (" + ev.Package + ")", Source: "go test", Message: failureMessage(outputs[k])})
+			} else if !failedTests[ev.Package] {
+				failures = append(failures, testFailure{Name: ev.Package, Source: "go test", Message: failureMessage(outputs[k])})
+			}
+		}
+	}
+	return failures
+}
+
+type junitSuite struct {
+	Suites []junitSuite `xml:"testsuite"`
+	Cases  []junitCase  `xml:"testcase"`
+}
+
+type junitCase struct {
+	Name      string       `xml:"name,attr"`
+	Classname string       `xml:"classname,attr"`
+	Failure   *junitResult `xml:"failure"`
+	Error     *junitResult `xml:"error"`
+}
+
+type junitResult struct {
+	Message string `xml:"message,attr"`
+	Body    string `xml:",chardata"`
+}
+
+// parseJUnitFile returns the failing test cases of a JUnit XML report; ok is
+// false when the file is not one.
+func parseJUnitFile(file string) (failures []testFailure, ok bool) {
+	data, err := os.ReadFile(file)
+	if err != nil {
+		return nil, false
+	}
+	var root struct {
+		XMLName xml.Name
+		junitSuite
+	}
+	if xml.Unmarshal(data, &root) != nil || (root.XMLName.Local != "testsuites" && root.XMLName.Local != "testsuite") {
+		return nil, false
+	}
+	var walk func(s junitSuite)
+	walk = func(s junitSuite) {
+		for _, c := range s.Cases {
+			res := c.Failure
+			if res == nil {
+				res = c.Error
+			}
+			if res == nil {
+				continue
+			}
+			name := c.Name
+			if c.Classname != "" {
+				name = c.Classname + " › " + c.Name
+			}
+			lines := []string{res.Message}
+			if res.Message == "" || !strings.Contains(res.Body, res.Message) {
+				lines = append(lines, strings.Split(res.Body, "\n")...)
+			} else {
+				lines = strings.Split(res.Body, "\n")
+			}
+			failures = append(failures, testFailure{Name: name, Source: "junit", Message: failureMessage(lines)})
+		}
+		for _, child := range s.Suites {
+			walk(child)
+		}
+	}
+	walk(root.junitSuite)
+	return failures, true
+}
@@ -0,0 +1,106 @@
+package executor
+
+import (
+	"context"
+	"errors"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+)
+
+const goTestJSONFailure = `{"Action":"run","Package":"example.com/m/calc","Test":"TestAdd"}
+{"Action":"output","Package":"example.com/m/calc","Test":"TestAdd","Output":"=== RUN   TestAdd\n"}
+{"Action":"output","Package":"example.com/m/calc","Test":"TestAdd","Output":"    calc_test.go:9: Add(1, 2) = 4, want 3\n"}
+{"Action":"output","Package":"example.com/m/calc","Test":"TestAdd","Output":"--- FAIL: TestAdd (0.00s)\n"}
+{"Action":"fail","Package":"example.com/m/calc","Test":"TestAdd"}
+{"Action":"pass","Package":"example.com/m/calc","Test":"TestSub"}
+{"Action":"fail","Package":"example.com/m/calc"}
+{"Action":"output","Package":"example.com/m/broken","Output":"broken/x.go:3:1: syntax error\n"}
+{"Action":"fail","Package":"example.com/m/broken"}
+`
+
+func TestParseGoTestJSON(t *testing.T) {
+	failures := parseGoTestJSON("plain text before\n" + goTestJSONFailure)
+	if len(failures) != 2 {
+		t.Fatalf("failures = %+v", failures)
+	}
+	if failures[0].Name != "TestAdd (example.com/m/calc)" || failures[0].Message != "    calc_test.go:9: Add(1, 2) = 4, want 3" {
+		t.Fatalf("test failure = %+v", failures[0])
+	}
+	if failures[1].Name != "example.com/m/broken" || !strings.Contains(failures[1].Message, "syntax error") {
+		t.Fatalf("package failure = %+v", failures[1])
+	}
+}
+
+func TestParseJUnitFile(t *testing.T) {
+	file := filepath.Join(t.TempDir(), "results.xml")
+	report := `<?xml version="1.0"?>
+<testsuites>
+  <testsuite name="login">
+    <testcase classname="login.spec.ts" name="shows error"><failure message="expected visible">at login.spec.ts:12</failure></testcase>
+    <testcase classname="login.spec.ts" name="logs in"/>
+  </testsuite>
+</testsuites>`
+	if err := os.WriteFile(file, []byte(report), 0o644); err != nil {
+		t.Fatal(err)
+	}
+	failures, ok := parseJUnitFile(file)
+	if !ok || len(failures) != 1 {
+		t.Fatalf("failures = %+v, ok = %v", failures, ok)
+	}
+	if failures[0].Name != "login.spec.ts › shows error" || failures[0].Message != "expected visible\nat login.spec.ts:12" {
+		t.Fatalf("failure = %+v", failures[0])
+	}
+
+	_ = os.WriteFile(file, []byte(`<project><name>x</name></project>`), 0o644)
+	if _, ok := parseJUnitFile(file); ok {
+		t.Fatal("non-JUnit XML must be ignored")
+	}
+}
+
+// artifactCheck fails after writing a JUnit report and a screenshot, the way
+// a Playwright run would.
+type artifactCheck struct{}
+
+func (artifactCheck) Name() string { return "verify command" }
+
+func (artifactCheck) Check(_ context.Context, workdir, _ string) error {
+	shots := filepath.Join(workdir, "test-results", "login")
+	_ = os.MkdirAll(shots, 0o755)
+	_ = os.WriteFile(filepath.Join(shots, "failure.png"), []byte("png"), 0o644)
+	_ = os.WriteFile(filepath.Join(workdir, "junit.xml"), []byte(`<testsuite><testcase name="logs in"><failure message="timeout"/></testcase></testsuite>`), 0o644)
+	return &CheckFailure{Command: "pnpm test", Output: "log line with tok\n", Err: errors.New("exit status 1")}
+}
+
+func TestVerifyPushed_SavesFailureArtifacts(t *testing.T) {
+	workdir, _ := initPushRepo(t)
+	updated := stubComments(t, "Done.")
+	e, _ := newVerifyExecutor(t, artifactCheck{})
+	dir := t.TempDir()
+	e.SetArtifactDir(dir)
+
+	gitIn(t, workdir, "checkout", "-q", "-b", "swe-agent/1-1")
+	commitAndPush(t, workdir, "swe-agent/1-1", "broken\n")
+
+	ctx := buildTestCtx(false)
+	ctx.Token = "tok"
+	ctx.TaskID = "task-1"
+	ctx.PreparedCommentID = 99
+	if err := e.verifyPushed(context.Background(), ctx, workdir, "swe-agent/1-1", "main", ""); err == nil {
+		t.Fatal("expected withdrawal")
+	}
+
+	for _, name := range []string{VerifyLogArtifact, "junit.xml", "test-results_login_failure.png"} {
+		if _, err := os.Stat(filepath.Join(dir, "task-1", name)); err != nil {
+			t.Fatalf("artifact %s not saved: %v", name, err)
+		}
+	}
+	if log, _ := os.ReadFile(filepath.Join(dir, "task-1", VerifyLogArtifact)); strings.Contains(string(log), "tok") {
+		t.Fatalf("token leaked into saved log: %q", log)
+	}
+	if !strings.Contains(*updated, "#### Failing tests (1)\n\n- `logs in` (junit)") ||
+		!strings.Contains(*updated, "Artifacts of task `task-1`:") {
+		t.Fatalf("comment lacks failure summary:\n%s", *updated)
+	}
+}
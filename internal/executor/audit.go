@@ -0,0 +1,59 @@
+package executor
+
+import (
+	"github.com/cexll/swe/internal/audit"
+	"github.com/cexll/swe/internal/github"
+	"github.com/cexll/swe/internal/logging"
+	"github.com/cexll/swe/internal/provider"
+)
+
+// SetAuditLog enables audit records for executions (nil disables).
+func (e *Executor) SetAuditLog(l *audit.Log) {
+	e.audit = l
+}
+
+// auditEvent fills the fields shared by all executor audit events.
+func (e *Executor) auditEvent(ctx *github.Context, action audit.Action) audit.Event {
+	ev := audit.Event{
+		Action:            action,
+		Actor:             ctx.GetTriggerUser(),
+		Repo:              ctx.GetRepositoryFullName(),
+		Number:            ctx.GetIssueNumber(),
+		TaskID:            ctx.TaskID,
+		Branch:            ctx.GetPreparedBranch(),
+		TrackingCommentID: ctx.PreparedCommentID,
+		Provider:          e.provider.Name(),
+	}
+	if ctx.IsPRContext() {
+		ev.PRNumber = ctx.GetPRNumber()
+	}
+	if ctx.TriggerComment != nil {
+		ev.TriggerCommentID = ctx.TriggerComment.ID
+	}
+	if mr, ok := e.provider.(provider.ModelReporter); ok {
+		ev.Model = mr.Model()
+	}
+	return ev
+}
+
+func (e *Executor) recordAudit(ev audit.Event) {
+	if e.audit == nil {
+		return
+	}
+	if err := e.audit.Record(ev); err != nil {
+		logging.Logger(logging.KeyTaskID, ev.TaskID, logging.KeyRepo, ev.Repo, logging.KeyPhase, "audit").Warn("Audit record failed", "err", err)
+	}
+}
+
+// recordPushedBranch records a branch_pushed event when the task branch exists
+// on the remote after the provider finished.
+func (e *Executor) recordPushedBranch(ctx *github.Context, workdir string) {
+	if e.audit == nil || ctx.GetPreparedBranch() == "" {
+		return
+	}
+	refs, err := gitLsRemoteHeads(workdir, ctx.GetPreparedBranch())
+	if err != nil || len(refs) == 0 {
+		return
+	}
+	e.recordAudit(e.auditEvent(ctx, audit.ActionBranchPushed))
+}
@@ -0,0 +1,223 @@
+package executor
+
+import (
+	"context"
+	"fmt"
+	"os"
+	"path/filepath"
+	"strings"
+
+	"github.com/cexll/swe/internal/audit"
+	"github.com/cexll/swe/internal/github"
+	ghdata "github.com/cexll/swe/internal/github/data"
+	"github.com/cexll/swe/internal/provider"
+	"github.com/cexll/swe/internal/taskstore"
+)
+
+// allow tests to stub opening the backport pull request
+var createPullRequest = github.CreatePullRequest
+
+// backportLabel is added to every backport pull request.
+const backportLabel = "backport"
+
+// backportBranchName returns the branch a backport of pull request number to
+// target is pushed to.
+func backportBranchName(number int, target string) string {
+	return fmt.Sprintf("backport/%d-to-%s", number, target)
+}
+
+// backportResolvePrompt asks the provider to resolve the conflicts a
+// cherry-pick of commit left in files.
+func backportResolvePrompt(repo string, number int, target string, commit ghdata.Commit, files []string) string {
+	subject, _, _ := strings.Cut(commit.Message, "\n")
+	return fmt.Sprintf(`Pull request #%d of %s is being backported to %s. Cherry-picking commit %s (%s) conflicted in:
+
+- %s
+
+Resolve the conflicts in these files only, keeping what the commit changes and adapting it to the code on %s. Remove every conflict marker.
+Only resolve trivial conflicts. If a conflict needs real design decisions or changes beyond these files, leave its markers in place and say why.
+Do not stage, commit, push or run git cherry-pick.`, number, repo, target, shortSHA(commit.OID), github.SanitizeContent(subject),
+		strings.Join(files, "\n- "), target)
+}
+
+// conflictedFiles lists the files with unresolved conflicts.
+func conflictedFiles(workdir string) ([]string, error) {
+	out, err := gitOutput(workdir, "diff", "--name-only", "-z", "--diff-filter=U")
+	if err != nil {
+		return nil, err
+	}
+	return gitPaths(out), nil
+}
+
+// filesWithConflictMarkers returns the files that still hold conflict
+// markers.
+func filesWithConflictMarkers(workdir string, files []string) []string {
+	var left []string
+	for _, f := range files {
+		data, err := os.ReadFile(filepath.Join(workdir, filepath.FromSlash(f)))
+		if err != nil {
+			continue // deleted while resolving
+		}
+		for _, line := range strings.Split(string(data), "\n") {
+			if strings.HasPrefix(line, "<<<<<<< ") || strings.HasPrefix(line, ">>>>>>> ") {
+				left = append(left, f)
+				break
+			}
+		}
+	}
+	return left
+}
+
+// executeBackport cherry-picks the commits of the merged pull request onto
+// the target branch checked out in workdir, has the provider resolve trivial
+// conflicts, pushes a backport branch and opens a pull request for it.
+func (e *Executor) executeBackport(ctx context.Context, ghCtx *github.Context, fetched *ghdata.FetchResult, workdir, repo, token string) (summary string, costUSD float64, err error) {
+	target := ghCtx.PreparedBackport
+	defer func() {
+		if err != nil {
+			msg := strings.ReplaceAll(err.Error(), token, "***")
+			e.reportOutcome(ghCtx, fmt.Sprintf("### Backport failed\n\nNo backport pull request was opened.\n\n```\n%s\n```", tail(msg, verifyOutputLimit)))
+		}
+	}()
+	pr, ok := fetched.ContextData.(ghdata.PullRequest)
+	if !ok {
+		return "", 0, &NonRetryableError{msg: "backport: only pull requests can be backported"}
+	}
+	number := ghCtx.GetPRNumber()
+	if number == 0 {
+		number = ghCtx.GetIssueNumber()
+	}
+	switch {
+	case pr.State != "MERGED":
+		return "", 0, &NonRetryableError{msg: fmt.Sprintf("backport: #%d is not merged", number)}
+	case pr.BaseRefName == target:
+		return "", 0, &NonRetryableError{msg: fmt.Sprintf("backport: #%d was merged into %s already", number, target)}
+	case len(pr.Commits.Nodes) == 0:
+		return "", 0, &NonRetryableError{msg: fmt.Sprintf("backport: #%d has no commits", number)}
+	case pr.Commits.TotalCount > len(pr.Commits.Nodes):
+		return "", 0, &NonRetryableError{msg: fmt.Sprintf("backport: #%d has %d commits, more than can be backported", number, pr.Commits.TotalCount)}
+	}
+	owner, name := ghCtx.GetRepositoryOwner(), ghCtx.GetRepositoryName()
+
+	branch := backportBranchName(number, target)
+	if refs, _ := gitLsRemoteHeads(workdir, branch); len(refs) > 0 {
+		return "", 0, &NonRetryableError{msg: fmt.Sprintf("backport: branch %s already exists", branch)}
+	}
+
+	// The pull request's commits, and the parent of the first
+	prRef := fmt.Sprintf("refs/remotes/origin/pull/%d", number)
+	if err := runCmd("git", "-C", workdir, "fetch", "-q", fmt.Sprintf("--depth=%d", len(pr.Commits.Nodes)+1), "origin",
+		fmt.Sprintf("+refs/pull/%d/head:%s", number, prRef)); err != nil {
+		return "", 0, fmt.Errorf("fetch pull request commits: %w", err)
+	}
+	if err := runCmd("git", "-C", workdir, "checkout", "-q", "-b", branch); err != nil {
+		return "", 0, fmt.Errorf("create backport branch: %w", err)
+	}
+
+	var picked, skipped, resolved []string
+	for _, node := range pr.Commits.Nodes {
+		commit := node.Commit
+		if parents, _ := gitOutput(workdir, "rev-list", "--parents", "-n", "1", commit.OID); len(strings.Fields(parents)) > 2 {
+			continue // merges of the base branch bring nothing to backport
+		}
+		subject, _, _ := strings.Cut(commit.Message, "\n")
+		entry := fmt.Sprintf("- %s %s", shortSHA(commit.OID), github.SanitizeContent(subject))
+		if pickErr := runCmd("git", "-C", workdir, "cherry-pick", "-x", commit.OID); pickErr != nil {
+			files, err := conflictedFiles(workdir)
+			if err != nil {
+				return "", costUSD, fmt.Errorf("list conflicts: %w", err)
+			}
+			if len(files) == 0 {
+				// the change is on the target branch already
+				if err := runCmd("git", "-C", workdir, "cherry-pick", "--skip"); err != nil {
+					return "", costUSD, fmt.Errorf("cherry-pick %s: %w", shortSHA(commit.OID), pickErr)
+				}
+				skipped = append(skipped, entry)
+				continue
+			}
+
+			e.phase(ghCtx, taskstore.PhaseProvider)
+			cost, err := e.resolveBackportConflicts(ctx, workdir, repo, number, target, commit, files, token)
+			costUSD += cost
+			if err != nil {
+				return "", costUSD, err
+			}
+			if left := filesWithConflictMarkers(workdir, files); len(left) > 0 {
+				_ = runCmd("git", "-C", workdir, "cherry-pick", "--abort")
+				return "", costUSD, &NonRetryableError{msg: fmt.Sprintf("backport: cherry-picking %s left conflicts that need a person in %s",
+					shortSHA(commit.OID), strings.Join(left, ", "))}
+			}
+			if err := runCmd("git", append([]string{"-C", workdir, "add", "-A", "--"}, files...)...); err != nil {
+				return "", costUSD, fmt.Errorf("stage resolved files: %w", err)
+			}
+			if err := runCmd("git", "-C", workdir, "-c", "core.editor=true", "cherry-pick", "--continue"); err != nil {
+				return "", costUSD, fmt.Errorf("continue cherry-pick of %s: %w", shortSHA(commit.OID), err)
+			}
+			resolved = append(resolved, fmt.Sprintf("%s (%s)", entry, strings.Join(files, ", ")))
+		}
+		picked = append(picked, entry)
+	}
+	if len(picked) == 0 {
+		return "", costUSD, &NonRetryableError{msg: fmt.Sprintf("backport: every commit of #%d is on %s already", number, target)}
+	}
+
+	e.phase(ghCtx, taskstore.PhasePush)
+	if err := runCmd("git", "-C", workdir, "push", "-q", "origin", "HEAD:refs/heads/"+branch); err != nil {
+		return "", costUSD, fmt.Errorf("push backport branch: %w", err)
+	}
+
+	var body strings.Builder
+	fmt.Fprintf(&body, "Backport of #%d to `%s`.\n\n**Commits:**\n%s\n", number, target, strings.Join(picked, "\n"))
+	if len(resolved) > 0 {
+		fmt.Fprintf(&body, "\n**Conflicts resolved automatically** (review these closely):\n%s\n", strings.Join(resolved, "\n"))
+	}
+	if len(skipped) > 0 {
+		fmt.Fprintf(&body, "\n**Already on `%s`:**\n%s\n", target, strings.Join(skipped, "\n"))
+	}
+	title := fmt.Sprintf("[Backport %s] %s", target, pr.Title)
+	created, url, err := createPullRequest(owner, name, title, branch, target, body.String(), token)
+	if err != nil {
+		return "", costUSD, fmt.Errorf("open backport pull request: %w", err)
+	}
+	if err := addLabels(owner, name, created, []string{backportLabel}, token); err != nil {
+		taskLog(ghCtx, "backport").Warn("Labeling backport pull request failed", "pull_request", created, "err", err)
+	}
+	taskLog(ghCtx, "backport").Info("Backport opened", "number", number, "target", target, "pull_request", created)
+
+	ev := e.auditEvent(ghCtx, audit.ActionBackportOpened)
+	ev.Branch = branch
+	ev.Detail = url
+	e.recordAudit(ev)
+
+	summary = fmt.Sprintf("### Backported #%d to `%s`\n\n[#%d](%s) cherry-picks %d commit(s) onto `%s`.", number, target, created, url, len(picked), target)
+	if len(resolved) > 0 {
+		summary += fmt.Sprintf("\n\n> [!WARNING]\n> Conflicts were resolved automatically in %d commit(s); review them closely.", len(resolved))
+	}
+	e.reportOutcome(ghCtx, summary)
+	return summary, costUSD, nil
+}
+
+// resolveBackportConflicts has the provider edit the conflicted files; it
+// may not touch git.
+func (e *Executor) resolveBackportConflicts(ctx context.Context, workdir, repo string, number int, target string, commit ghdata.Commit, files []string, token string) (float64, error) {
+	var env []string
+	if guard, err := installGitGuard(e.secretRuleSet(), e.blockedPaths); err == nil {
+		defer guard.remove()
+		env = guard.env()
+	}
+	resp, err := e.provider.GenerateCode(ctx, &provider.CodeRequest{
+		Prompt:          backportResolvePrompt(repo, number, target, commit, files),
+		RepoPath:        workdir,
+		Context:         map[string]string{"github_token": token, "repository": repo},
+		AllowedTools:    []string{"Read", "Edit", "Grep", "Glob", "Bash(git diff)", "Bash(git show)", "Bash(git log)"},
+		DisallowedTools: []string{"Bash(git add)", "Bash(git commit)", "Bash(git push)", "Bash(git cherry-pick)", "Bash(git checkout)", "Bash(git reset)"},
+		Env:             env,
+	})
+	if err != nil {
+		return 0, fmt.Errorf("resolve conflicts: %w", err)
+	}
+	if resp == nil {
+		return 0, nil
+	}
+	return resp.CostUSD, nil
+}
@@ -0,0 +1,181 @@
+package executor
+
+import (
+	"context"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"github.com/cexll/swe/internal/audit"
+	"github.com/cexll/swe/internal/github"
+	ghdata "github.com/cexll/swe/internal/github/data"
+	"github.com/cexll/swe/internal/provider"
+)
+
+// initBackportRepo returns a remote with a release-1.2 branch whose README
+// differs from main's, and pull request #2 merged into main: a commit adding
+// feature.txt and one changing the README line release-1.2 changed too.
+func initBackportRepo(t *testing.T) (remote string, pr ghdata.PullRequest) {
+	t.Helper()
+	workdir, remote := initPushRepo(t)
+	gitIn(t, workdir, "checkout", "-q", "-b", "release-1.2")
+	if err := os.WriteFile(filepath.Join(workdir, "README.md"), []byte("hello from 1.2\n"), 0o644); err != nil {
+		t.Fatal(err)
+	}
+	gitIn(t, workdir, "commit", "-q", "-am", "release notes")
+	gitIn(t, workdir, "push", "-q", "origin", "release-1.2")
+
+	gitIn(t, workdir, "checkout", "-q", "main")
+	pr = ghdata.PullRequest{Title: "Add the feature", State: "MERGED", BaseRefName: "main"}
+	for _, change := range []struct{ file, content, message string }{
+		{"feature.txt", "feature\n", "Add feature.txt\n\nWith a body."},
+		{"README.md", "hello, feature\n", "Mention the feature"},
+	} {
+		if err := os.WriteFile(filepath.Join(workdir, change.file), []byte(change.content), 0o644); err != nil {
+			t.Fatal(err)
+		}
+		gitIn(t, workdir, "add", change.file)
+		gitIn(t, workdir, "commit", "-q", "-m", change.message)
+		var node struct {
+			Commit ghdata.Commit `json:"commit"`
+		}
+		node.Commit = ghdata.Commit{OID: gitIn(t, workdir, "rev-parse", "HEAD"), Message: change.message}
+		pr.Commits.Nodes = append(pr.Commits.Nodes, node)
+	}
+	pr.Commits.TotalCount = len(pr.Commits.Nodes)
+	gitIn(t, workdir, "push", "-q", "origin", "HEAD:refs/pull/2/head", "HEAD:refs/heads/main")
+	return remote, pr
+}
+
+func newBackportExecutor(t *testing.T, remote string, pr ghdata.PullRequest, resolve func(workdir string)) (*Executor, *string, *[]string) {
+	t.Helper()
+	origClone, origRun, origCreate, origAdd := cloneRepo, runCmd, createPullRequest, addLabels
+	t.Cleanup(func() { cloneRepo, runCmd, createPullRequest, addLabels = origClone, origRun, origCreate, origAdd })
+	var workdir string
+	cloneRepo = func(_ context.Context, repo, branch, token string) (string, func(), error) {
+		workdir = filepath.Join(t.TempDir(), "clone")
+		gitIn(t, filepath.Dir(workdir), "clone", "-q", "--depth=1", "--single-branch", "--branch", branch, "file://"+remote, workdir)
+		gitIn(t, workdir, "config", "user.email", "bot@example.com")
+		gitIn(t, workdir, "config", "user.name", "bot")
+		return workdir, func() {}, nil
+	}
+	runCmd = func(name string, args ...string) error {
+		if len(args) > 3 && args[2] == "remote" && args[3] == "set-url" {
+			return nil // keep the local remote
+		}
+		return run(name, args...)
+	}
+	var opened []string
+	createPullRequest = func(_, _, title, head, base, body, _ string) (int, string, error) {
+		opened = append(opened, title, head, base, body)
+		return 7, "https://github.com/owner/repo/pull/7", nil
+	}
+	addLabels = func(_, _ string, number int, labels []string, _ string) error {
+		if number != 7 || strings.Join(labels, ",") != backportLabel {
+			t.Errorf("labeled #%d with %v", number, labels)
+		}
+		return nil
+	}
+
+	e := New(&mockProvider{generateFunc: func(_ context.Context, req *provider.CodeRequest) (*provider.CodeResponse, error) {
+		if !strings.Contains(req.Prompt, "- README.md") {
+			t.Errorf("prompt:\n%s", req.Prompt)
+		}
+		resolve(workdir)
+		return &provider.CodeResponse{Summary: "resolved", CostUSD: 0.02}, nil
+	}}, &mockAuthProvider{})
+	e.fetcher = &mockFetcher{fetchFunc: func(context.Context, *github.Context) (*ghdata.FetchResult, error) {
+		return &ghdata.FetchResult{ContextData: pr}, nil
+	}}
+	updated := stubComments(t, "")
+	return e, updated, &opened
+}
+
+func backportCtx() *github.Context {
+	ctx := buildTestCtx(true)
+	ctx.PreparedBackport = "release-1.2"
+	ctx.PreparedCommentID = 5
+	return ctx
+}
+
+func TestBackportBranchName(t *testing.T) {
+	if got := backportBranchName(12, "release-1.2"); got != "backport/12-to-release-1.2" {
+		t.Fatalf("backportBranchName = %q", got)
+	}
+}
+
+func TestExecute_BackportResolvesConflicts(t *testing.T) {
+	remote, pr := initBackportRepo(t)
+	e, updated, opened := newBackportExecutor(t, remote, pr, func(workdir string) {
+		if err := os.WriteFile(filepath.Join(workdir, "README.md"), []byte("hello from 1.2, feature\n"), 0o644); err != nil {
+			t.Fatal(err)
+		}
+	})
+	log, _ := audit.New(audit.Config{})
+	e.SetAuditLog(log)
+
+	if err := e.Execute(context.Background(), backportCtx()); err != nil {
+		t.Fatalf("Execute: %v", err)
+	}
+
+	if len(*opened) != 4 || (*opened)[0] != "[Backport release-1.2] Add the feature" || (*opened)[1] != "backport/2-to-release-1.2" || (*opened)[2] != "release-1.2" {
+		t.Fatalf("opened = %q", *opened)
+	}
+	body := (*opened)[3]
+	if !strings.Contains(body, "Backport of #2 to `release-1.2`") || !strings.Contains(body, "Add feature.txt") ||
+		!strings.Contains(body, "Mention the feature (README.md)") {
+		t.Fatalf("pull request body:\n%s", body)
+	}
+
+	check := filepath.Join(t.TempDir(), "check")
+	gitIn(t, filepath.Dir(check), "clone", "-q", "--branch", "backport/2-to-release-1.2", remote, check)
+	if readme, _ := os.ReadFile(filepath.Join(check, "README.md")); string(readme) != "hello from 1.2, feature\n" {
+		t.Fatalf("README.md = %q", readme)
+	}
+	if msg := gitIn(t, check, "log", "-1", "--format=%B"); !strings.Contains(msg, "(cherry picked from commit "+pr.Commits.Nodes[1].Commit.OID+")") {
+		t.Fatalf("commit message = %q", msg)
+	}
+	if n := gitIn(t, check, "rev-list", "--count", "origin/release-1.2..HEAD"); n != "2" {
+		t.Fatalf("%s commits backported", n)
+	}
+
+	if !strings.HasPrefix(*updated, "### Backported #2 to `release-1.2`") || !strings.Contains(*updated, "resolved automatically") {
+		t.Fatalf("tracking comment:\n%s", *updated)
+	}
+	if events := log.List(audit.Filter{Action: audit.ActionBackportOpened}); len(events) != 1 || events[0].Branch != "backport/2-to-release-1.2" {
+		t.Fatalf("audit events = %+v", events)
+	}
+}
+
+func TestExecute_BackportStopsOnUnresolvedConflicts(t *testing.T) {
+	remote, pr := initBackportRepo(t)
+	e, updated, opened := newBackportExecutor(t, remote, pr, func(string) {})
+
+	err := e.Execute(context.Background(), backportCtx())
+	if err == nil || !IsNonRetryable(err) || !strings.Contains(err.Error(), "README.md") {
+		t.Fatalf("Execute = %v", err)
+	}
+	if len(*opened) != 0 {
+		t.Fatalf("opened a pull request: %q", *opened)
+	}
+	if !strings.HasPrefix(*updated, "### Backport failed") {
+		t.Fatalf("tracking comment:\n%s", *updated)
+	}
+	if out := gitIn(t, remote, "for-each-ref", "refs/heads/backport"); out != "" {
+		t.Fatalf("pushed %s", out)
+	}
+}
+
+func TestExecute_BackportNeedsMergedPR(t *testing.T) {
+	remote, pr := initBackportRepo(t)
+	pr.State = "OPEN"
+	e, updated, _ := newBackportExecutor(t, remote, pr, func(string) {})
+
+	if err := e.Execute(context.Background(), backportCtx()); err == nil || !strings.Contains(err.Error(), "not merged") {
+		t.Fatalf("Execute = %v", err)
+	}
+	if !strings.HasPrefix(*updated, "### Backport failed") {
+		t.Fatalf("tracking comment:\n%s", *updated)
+	}
+}
@@ -0,0 +1,203 @@
+package executor
+
+import (
+	"bytes"
+	"context"
+	"fmt"
+	"os"
+	"os/exec"
+	"path/filepath"
+	"strings"
+	"time"
+
+	"github.com/cexll/swe/internal/github"
+	"github.com/cexll/swe/internal/taskstore"
+)
+
+// allow tests to stub toolchain lookups and setup commands
+var (
+	lookPath     = exec.LookPath
+	runSetupStep = runShellStep
+)
+
+// BootstrapConfig prepares the build tooling of a checkout before the
+// provider runs, so that its builds and tests find their dependencies.
+type BootstrapConfig struct {
+	Enabled bool
+	// Commands replace the built-in setup of a toolchain ("go", "node",
+	// "python"); "" skips it
+	Commands map[string]string
+	Timeout  time.Duration // per command; 0 uses DefaultVerifyTimeout
+}
+
+// SetBootstrap configures the environment bootstrap of subsequent tasks.
+func (e *Executor) SetBootstrap(c BootstrapConfig) {
+	e.mu.Lock()
+	defer e.mu.Unlock()
+	e.bootstrap = c
+}
+
+// toolchain is a language toolchain detected in a checkout.
+type toolchain struct {
+	name   string // go, node or python, as in BootstrapConfig.Commands
+	label  string // for people
+	marker string // the file that revealed it
+	binary string // what must be on PATH
+	setup  string // the built-in setup command
+}
+
+// detectToolchains inspects the root of the checkout for the manifests of
+// the toolchains it knows. The setup follows the lockfile: npm ci for
+// package-lock.json, pnpm or yarn for theirs, uv or poetry for Python.
+func detectToolchains(workdir string) []toolchain {
+	exists := func(name string) bool {
+		_, err := os.Stat(filepath.Join(workdir, name))
+		return err == nil
+	}
+	var found []toolchain
+	if exists("go.mod") {
+		found = append(found, toolchain{name: "go", label: "Go", marker: "go.mod", binary: "go", setup: "go mod download"})
+	}
+	if exists("package.json") {
+		node := toolchain{name: "node", label: "Node.js", marker: "package.json", binary: "npm", setup: "npm install"}
+		switch {
+		case exists("pnpm-lock.yaml"):
+			node.binary, node.setup = "pnpm", "pnpm install --frozen-lockfile"
+		case exists("yarn.lock"):
+			node.binary, node.setup = "yarn", "yarn install --frozen-lockfile"
+		case exists("package-lock.json"):
+			node.setup = "npm ci"
+		}
+		found = append(found, node)
+	}
+	python := toolchain{name: "python", label: "Python", binary: "python3"}
+	switch {
+	case exists("uv.lock"):
+		python.marker, python.binary, python.setup = "uv.lock", "uv", "uv sync"
+	case exists("poetry.lock"):
+		python.marker, python.binary, python.setup = "poetry.lock", "poetry", "poetry install"
+	case exists("requirements.txt"):
+		python.marker, python.setup = "requirements.txt", "python3 -m pip install -r requirements.txt"
+	case exists("pyproject.toml"):
+		python.marker, python.setup = "pyproject.toml", "python3 -m pip install -e ."
+	}
+	if python.marker != "" {
+		found = append(found, python)
+	}
+	return found
+}
+
+// setupStep is one setup command and how it went.
+type setupStep struct {
+	command string
+	output  string
+	err     error
+}
+
+// bootstrapResult is what the bootstrap of a checkout found and ran.
+type bootstrapResult struct {
+	toolchains []toolchain
+	missing    []string // toolchains whose binary is not installed
+	steps      []setupStep
+}
+
+// bootstrapEnvironment runs the setup of the repository's toolchains in
+// workdir: the repository's own setup commands when it has some, otherwise
+// the detected toolchains' when the bootstrap is enabled. Failures do not
+// stop the task; the provider is told about them instead. It returns nil
+// when the bootstrap is off for the repository.
+func (e *Executor) bootstrapEnvironment(ctx context.Context, ghCtx *github.Context, workdir string, repoSetup []string) *bootstrapResult {
+	if !e.bootstrap.Enabled && len(repoSetup) == 0 {
+		return nil
+	}
+	res := &bootstrapResult{toolchains: detectToolchains(workdir)}
+	commands := repoSetup
+	if len(commands) == 0 {
+		for _, tc := range res.toolchains {
+			command := tc.setup
+			if custom, ok := e.bootstrap.Commands[tc.name]; ok {
+				command = custom
+			}
+			if command == "" {
+				continue
+			}
+			if _, err := lookPath(tc.binary); err != nil && command == tc.setup {
+				res.missing = append(res.missing, fmt.Sprintf("%s (`%s` is not installed)", tc.label, tc.binary))
+				continue
+			}
+			commands = append(commands, command)
+		}
+	}
+	if len(commands) == 0 && len(res.missing) == 0 {
+		return res
+	}
+
+	e.phase(ghCtx, taskstore.PhaseSetup)
+	timeout := e.bootstrap.Timeout
+	if timeout <= 0 {
+		timeout = DefaultVerifyTimeout
+	}
+	for _, command := range commands {
+		out, err := runSetupStep(ctx, workdir, command, timeout)
+		res.steps = append(res.steps, setupStep{command: command, output: out, err: err})
+		if e.store != nil && ghCtx.TaskID != "" {
+			if err != nil {
+				e.store.AddLog(ghCtx.TaskID, "warn", fmt.Sprintf("Setup %q failed: %v\n%s", command, err, tail(out, verifyOutputLimit)))
+			} else {
+				e.store.AddLog(ghCtx.TaskID, "info", fmt.Sprintf("Setup %q done", command))
+			}
+		}
+	}
+	for _, m := range res.missing {
+		taskLog(ghCtx, "setup").Warn("Toolchain missing", "toolchain", m)
+	}
+	return res
+}
+
+// runShellStep runs a setup command in workdir without the GitHub tokens.
+func runShellStep(ctx context.Context, workdir, command string, timeout time.Duration) (string, error) {
+	ctx, cancel := context.WithTimeout(ctx, timeout)
+	defer cancel()
+	cmd := shellCommand(ctx, command)
+	cmd.Dir = workdir
+	cmd.Env = append(scrubbedEnv(), cacheEnvFrom(ctx)...)
+	cmd.WaitDelay = 5 * time.Second
+	var out bytes.Buffer
+	cmd.Stdout = &out
+	cmd.Stderr = &out
+	err := cmd.Run()
+	if ctx.Err() == context.DeadlineExceeded {
+		return out.String(), fmt.Errorf("timed out after %s", timeout)
+	}
+	return out.String(), err
+}
+
+// promptSection tells the provider which toolchains were set up and which
+// are missing or failed, so that it does not chase build errors they cause.
+func (r *bootstrapResult) promptSection() string {
+	if r == nil || (len(r.toolchains) == 0 && len(r.steps) == 0) {
+		return ""
+	}
+	var b strings.Builder
+	b.WriteString("<environment>\n")
+	if len(r.toolchains) > 0 {
+		var labels []string
+		for _, tc := range r.toolchains {
+			labels = append(labels, fmt.Sprintf("%s (%s)", tc.label, tc.marker))
+		}
+		fmt.Fprintf(&b, "Toolchains detected: %s.\n", strings.Join(labels, ", "))
+	}
+	for _, s := range r.steps {
+		if s.err == nil {
+			fmt.Fprintf(&b, "Setup done: `%s`.\n", s.command)
+			continue
+		}
+		fmt.Fprintf(&b, "Setup failed: `%s` (%v). Builds and tests that need it may fail for that reason:\n%s\n",
+			s.command, s.err, tail(s.output, 500))
+	}
+	for _, m := range r.missing {
+		fmt.Fprintf(&b, "Not installed on this runner: %s. Do not try to install it; say so if the task needs it.\n", m)
+	}
+	b.WriteString("</environment>")
+	return b.String()
+}
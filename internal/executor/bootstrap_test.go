@@ -0,0 +1,168 @@
+package executor
+
+import (
+	"context"
+	"errors"
+	"slices"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/cexll/swe/internal/github"
+	ghdata "github.com/cexll/swe/internal/github/data"
+	"github.com/cexll/swe/internal/provider"
+	"github.com/cexll/swe/internal/reposettings"
+)
+
+// touchFiles creates the named manifests in dir.
+func touchFiles(t *testing.T, dir string, names ...string) {
+	t.Helper()
+	files := make(map[string]string, len(names))
+	for _, name := range names {
+		files[name] = "{}"
+	}
+	writeFiles(t, dir, files)
+}
+
+func TestDetectToolchains(t *testing.T) {
+	tests := []struct {
+		files []string
+		want  []string
+	}{
+		{nil, nil},
+		{[]string{"go.mod"}, []string{"go mod download"}},
+		{[]string{"package.json"}, []string{"npm install"}},
+		{[]string{"package.json", "package-lock.json"}, []string{"npm ci"}},
+		{[]string{"package.json", "pnpm-lock.yaml"}, []string{"pnpm install --frozen-lockfile"}},
+		{[]string{"package.json", "yarn.lock"}, []string{"yarn install --frozen-lockfile"}},
+		{[]string{"pyproject.toml", "uv.lock"}, []string{"uv sync"}},
+		{[]string{"pyproject.toml", "poetry.lock"}, []string{"poetry install"}},
+		{[]string{"requirements.txt"}, []string{"python3 -m pip install -r requirements.txt"}},
+		{[]string{"pyproject.toml"}, []string{"python3 -m pip install -e ."}},
+		{[]string{"go.mod", "package.json", "requirements.txt"}, []string{"go mod download", "npm install", "python3 -m pip install -r requirements.txt"}},
+	}
+	for _, tt := range tests {
+		dir := t.TempDir()
+		touchFiles(t, dir, tt.files...)
+		var got []string
+		for _, tc := range detectToolchains(dir) {
+			got = append(got, tc.setup)
+		}
+		if !slices.Equal(got, tt.want) {
+			t.Errorf("detectToolchains(%v) = %v, want %v", tt.files, got, tt.want)
+		}
+	}
+}
+
+// stubSetup records the setup commands run and fails the listed ones;
+// binaries in missing are reported as not installed.
+func stubSetup(t *testing.T, failing []string, missing ...string) *[]string {
+	t.Helper()
+	origLook, origStep := lookPath, runSetupStep
+	t.Cleanup(func() { lookPath, runSetupStep = origLook, origStep })
+	lookPath = func(file string) (string, error) {
+		if slices.Contains(missing, file) {
+			return "", errors.New("not found")
+		}
+		return "/usr/bin/" + file, nil
+	}
+	var ran []string
+	runSetupStep = func(_ context.Context, _ string, command string, _ time.Duration) (string, error) {
+		ran = append(ran, command)
+		if slices.Contains(failing, command) {
+			return "npm ERR! network unreachable", errors.New("exit status 1")
+		}
+		return "ok", nil
+	}
+	return &ran
+}
+
+func TestBootstrapEnvironment(t *testing.T) {
+	ran := stubSetup(t, nil, "pnpm")
+	dir := t.TempDir()
+	touchFiles(t, dir, "go.mod", "package.json", "pnpm-lock.yaml", "requirements.txt")
+
+	e := New(&mockProvider{}, &mockAuthProvider{})
+	if res := e.bootstrapEnvironment(context.Background(), buildTestCtx(false), dir, nil); res != nil {
+		t.Fatalf("disabled bootstrap ran: %+v", res)
+	}
+
+	e.SetBootstrap(BootstrapConfig{Enabled: true, Commands: map[string]string{"python": "", "go": "make deps"}})
+	res := e.bootstrapEnvironment(context.Background(), buildTestCtx(false), dir, nil)
+	if !slices.Equal(*ran, []string{"make deps"}) {
+		t.Fatalf("ran %v, want the custom go setup only", *ran)
+	}
+	if len(res.missing) != 1 || !strings.Contains(res.missing[0], "pnpm") {
+		t.Fatalf("missing = %v", res.missing)
+	}
+
+	*ran = nil
+	res = e.bootstrapEnvironment(context.Background(), buildTestCtx(false), dir, []string{"make setup"})
+	if !slices.Equal(*ran, []string{"make setup"}) || len(res.missing) != 0 {
+		t.Fatalf("repository setup: ran %v, missing %v", *ran, res.missing)
+	}
+}
+
+func TestBootstrapResult_PromptSection(t *testing.T) {
+	var none *bootstrapResult
+	if none.promptSection() != "" {
+		t.Fatal("nil result has a prompt section")
+	}
+	res := &bootstrapResult{
+		toolchains: []toolchain{{label: "Go", marker: "go.mod"}, {label: "Node.js", marker: "package.json"}},
+		missing:    []string{"Python (`uv` is not installed)"},
+		steps: []setupStep{
+			{command: "go mod download"},
+			{command: "npm ci", output: "npm ERR! network unreachable", err: errors.New("exit status 1")},
+		},
+	}
+	got := res.promptSection()
+	for _, want := range []string{
+		"<environment>",
+		"Toolchains detected: Go (go.mod), Node.js (package.json).",
+		"Setup done: `go mod download`.",
+		"Setup failed: `npm ci` (exit status 1)",
+		"npm ERR! network unreachable",
+		"Not installed on this runner: Python (`uv` is not installed).",
+	} {
+		if !strings.Contains(got, want) {
+			t.Errorf("prompt section lacks %q:\n%s", want, got)
+		}
+	}
+}
+
+func TestExecute_RepoSetupReachesPrompt(t *testing.T) {
+	origClone, origRun := cloneRepo, runCmd
+	t.Cleanup(func() { cloneRepo, runCmd = origClone, origRun })
+	dir := t.TempDir()
+	touchFiles(t, dir, "package.json")
+	cloneRepo = func(_ context.Context, repo, branch, token string) (string, func(), error) {
+		return dir, func() {}, nil
+	}
+	runCmd = func(name string, args ...string) error { return nil }
+	ran := stubSetup(t, []string{"npm ci"})
+
+	var got *provider.CodeRequest
+	e := New(&mockProvider{generateFunc: func(_ context.Context, req *provider.CodeRequest) (*provider.CodeResponse, error) {
+		got = req
+		return &provider.CodeResponse{Summary: "ok"}, nil
+	}}, &mockAuthProvider{})
+	e.fetcher = &mockFetcher{fetchFunc: func(context.Context, *github.Context) (*ghdata.FetchResult, error) {
+		return &ghdata.FetchResult{ContextData: ghdata.Issue{Title: "t", Author: ghdata.Author{Login: "u"}}}, nil
+	}}
+	settings, err := reposettings.Parse([]byte(`{"owner/repo": {"setup": ["npm ci"]}}`))
+	if err != nil {
+		t.Fatal(err)
+	}
+	e.SetRepoSettings(settings)
+
+	if err := e.Execute(context.Background(), buildTestCtx(false)); err != nil {
+		t.Fatalf("Execute: %v", err)
+	}
+	if !slices.Equal(*ran, []string{"npm ci"}) {
+		t.Fatalf("ran %v", *ran)
+	}
+	if !strings.Contains(got.Prompt, "Setup failed: `npm ci`") || !strings.Contains(got.Prompt, "Node.js (package.json)") {
+		t.Fatalf("prompt lacks the environment section:\n%s", got.Prompt)
+	}
+}
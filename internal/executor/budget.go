@@ -0,0 +1,37 @@
+package executor
+
+import (
+	"github.com/cexll/swe/internal/budget"
+	"github.com/cexll/swe/internal/github"
+	"github.com/cexll/swe/internal/github/comment"
+)
+
+// SetBudgets charges the cost of each task to its organization's monthly
+// spending in t (nil charges nothing).
+func (e *Executor) SetBudgets(t *budget.Tracker) {
+	e.budgets = t
+}
+
+// chargeBudget adds what a task cost to its organization's spending. Once
+// that reaches the warning share of the organization's budget, the top of
+// the tracking comment says how much is left.
+func (e *Executor) chargeBudget(ctx *github.Context, costUSD float64) {
+	if e.budgets == nil || costUSD <= 0 {
+		return
+	}
+	st, crossed, err := e.budgets.Add(budget.Org(ctx.GetRepositoryOwner()), costUSD)
+	if err != nil {
+		taskLog(ctx, "budget").Warn("Saving budget spending failed", "err", err)
+	}
+	if crossed {
+		taskLog(ctx, "budget").Warn("Organization budget running low", "org", st.Org, "spent_usd", st.SpentUSD, "budget_usd", st.BudgetUSD)
+	}
+	if !st.Warning {
+		return
+	}
+	msg, kind := comment.MsgBudgetWarning, "WARNING"
+	if st.Exhausted {
+		msg, kind = comment.MsgBudgetExhausted, "CAUTION"
+	}
+	prependNotice(ctx, "> [!"+kind+"]\n> "+commentText(ctx, msg, st.Org, st.SpentUSD, st.BudgetUSD, st.Month, st.Percent()))
+}
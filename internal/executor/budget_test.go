@@ -0,0 +1,46 @@
+package executor
+
+import (
+	"strings"
+	"testing"
+
+	"github.com/cexll/swe/internal/budget"
+	"github.com/cexll/swe/internal/github"
+)
+
+func TestChargeBudget_WarnsInTheTrackingComment(t *testing.T) {
+	origGet, origUpdate := getComment, updateComment
+	t.Cleanup(func() { getComment, updateComment = origGet, origUpdate })
+	body := "### Done"
+	getComment = func(_, _ string, _ int64, _ string) (string, error) { return body, nil }
+	updateComment = func(_, _ string, _ int64, b, _ string) error {
+		body = b
+		return nil
+	}
+
+	tracker, err := budget.Open("")
+	if err != nil {
+		t.Fatal(err)
+	}
+	tracker.SetLimits(budget.Limits{Orgs: map[string]float64{"acme": 10}, HardCapPercent: 100})
+	e := New(&mockProvider{}, &mockAuthProvider{})
+	e.SetBudgets(tracker)
+	ctx := &github.Context{PreparedCommentID: 7, Token: "installation-token", Repository: github.Repository{Owner: "Acme", Name: "api"}}
+
+	e.chargeBudget(ctx, 5)
+	if body != "### Done" {
+		t.Fatalf("warned at half the budget: %q", body)
+	}
+	e.chargeBudget(ctx, 3.5)
+	if !strings.HasPrefix(body, "> [!WARNING]\n> **Budget:** `acme` has spent $8.50 of its $10.00 budget") || !strings.Contains(body, "(85%)") {
+		t.Fatalf("body = %q", body)
+	}
+	body = "### Done"
+	e.chargeBudget(ctx, 2)
+	if !strings.HasPrefix(body, "> [!CAUTION]\n> **Budget exhausted:**") {
+		t.Fatalf("body = %q", body)
+	}
+	if st := tracker.Status("acme"); st.SpentUSD != 10.5 || !st.Exhausted {
+		t.Fatalf("status = %+v", st)
+	}
+}
@@ -0,0 +1,283 @@
+package executor
+
+import (
+	"context"
+	"crypto/sha256"
+	"encoding/hex"
+	"fmt"
+	"io/fs"
+	"os"
+	"path/filepath"
+	"sort"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/cexll/swe/internal/logging"
+)
+
+// CacheConfig keeps the dependency caches of each repository between tasks:
+// the Go module and build caches, the npm, pnpm, yarn, pip and uv caches,
+// and node_modules.
+type CacheConfig struct {
+	Dir string // "" disables the caches
+	// MaxBytes bounds the caches of all repositories together; the least
+	// recently used are evicted past it (0 keeps everything)
+	MaxBytes int64
+}
+
+// SetCache configures the dependency caches of subsequent tasks.
+func (e *Executor) SetCache(c CacheConfig) {
+	e.caches.configure(c)
+}
+
+// lastUsedFile is touched whenever a task uses a repository's cache, so
+// that eviction can tell which caches are stale.
+const lastUsedFile = ".last-used"
+
+// nodeModulesKeyFile records the lockfile node_modules was installed from.
+const nodeModulesKeyFile = "node_modules.key"
+
+// repoCaches hands out the cache directory of each repository and evicts
+// the least recently used once they grow past the limit. A cache in use by
+// a running task is never evicted.
+type repoCaches struct {
+	mu     sync.Mutex
+	config CacheConfig
+	inUse  map[string]int // cache directory -> running tasks
+}
+
+func newRepoCaches() *repoCaches {
+	return &repoCaches{inUse: make(map[string]int)}
+}
+
+func (c *repoCaches) configure(config CacheConfig) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	c.config = config
+}
+
+// repoCache is one repository's cache, held by a running task.
+type repoCache struct {
+	caches *repoCaches
+	dir    string
+}
+
+// open returns repo's cache, or nil when caching is off or its directory
+// cannot be created. The caller releases it when the task is done.
+func (c *repoCaches) open(repo string) *repoCache {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	if c.config.Dir == "" {
+		return nil
+	}
+	owner, name, _ := strings.Cut(strings.ToLower(repo), "/")
+	if owner == "" || name == "" {
+		return nil
+	}
+	dir := filepath.Join(c.config.Dir, owner, name)
+	if err := os.MkdirAll(dir, 0o755); err != nil {
+		phaseLog("cache").Warn("Opening cache failed", logging.KeyRepo, repo, "err", err)
+		return nil
+	}
+	_ = os.WriteFile(filepath.Join(dir, lastUsedFile), nil, 0o644)
+	c.inUse[dir]++
+	return &repoCache{caches: c, dir: dir}
+}
+
+// env points the package managers at the cache.
+func (r *repoCache) env() []string {
+	if r == nil {
+		return nil
+	}
+	return []string{
+		"GOMODCACHE=" + filepath.Join(r.dir, "go", "mod"),
+		"GOCACHE=" + filepath.Join(r.dir, "go", "build"),
+		"npm_config_cache=" + filepath.Join(r.dir, "npm"),
+		"npm_config_store_dir=" + filepath.Join(r.dir, "pnpm"),
+		"YARN_CACHE_FOLDER=" + filepath.Join(r.dir, "yarn"),
+		"PIP_CACHE_DIR=" + filepath.Join(r.dir, "pip"),
+		"UV_CACHE_DIR=" + filepath.Join(r.dir, "uv"),
+	}
+}
+
+// restoreNodeModules moves the cached node_modules into workdir when it was
+// installed from the lockfile workdir has. It is moved, not copied, so the
+// cache has to be on the same filesystem as the checkouts; otherwise the
+// package managers' own caches still apply.
+func (r *repoCache) restoreNodeModules(workdir string) bool {
+	if r == nil {
+		return false
+	}
+	r.caches.mu.Lock()
+	defer r.caches.mu.Unlock()
+	cached := filepath.Join(r.dir, "node_modules")
+	key, err := os.ReadFile(filepath.Join(r.dir, nodeModulesKeyFile))
+	if err != nil || string(key) != nodeLockKey(workdir) {
+		return false
+	}
+	target := filepath.Join(workdir, "node_modules")
+	if _, err := os.Lstat(target); err == nil {
+		return false
+	}
+	if err := os.Rename(cached, target); err != nil {
+		if !os.IsNotExist(err) {
+			phaseLog("cache").Warn("Restoring node_modules failed", "err", err)
+		}
+		return false
+	}
+	_ = os.Remove(filepath.Join(r.dir, nodeModulesKeyFile))
+	return true
+}
+
+// saveNodeModules moves workdir's node_modules into the cache, replacing
+// the one there, keyed by workdir's lockfile. The caller holds the lock.
+func (r *repoCache) saveNodeModules(workdir string) {
+	source := filepath.Join(workdir, "node_modules")
+	if info, err := os.Lstat(source); err != nil || !info.IsDir() {
+		return
+	}
+	key := nodeLockKey(workdir)
+	if key == "" {
+		return
+	}
+	cached := filepath.Join(r.dir, "node_modules")
+	_ = os.Remove(filepath.Join(r.dir, nodeModulesKeyFile))
+	if err := removeCacheDir(cached); err != nil {
+		phaseLog("cache").Warn("Saving node_modules failed", "err", err)
+		return
+	}
+	if err := os.Rename(source, cached); err != nil {
+		phaseLog("cache").Warn("Saving node_modules failed", "err", err)
+		return
+	}
+	_ = os.WriteFile(filepath.Join(r.dir, nodeModulesKeyFile), []byte(key), 0o644)
+}
+
+// nodeLockKey identifies the dependencies a node_modules was installed
+// from: a hash of package.json and the lockfile ("" without package.json).
+func nodeLockKey(workdir string) string {
+	h := sha256.New()
+	found := false
+	for _, name := range []string{"package.json", "package-lock.json", "pnpm-lock.yaml", "yarn.lock"} {
+		data, err := os.ReadFile(filepath.Join(workdir, name))
+		if err != nil {
+			continue
+		}
+		found = found || name == "package.json"
+		_, _ = fmt.Fprintf(h, "%s %d\n", name, len(data))
+		h.Write(data)
+	}
+	if !found {
+		return ""
+	}
+	return hex.EncodeToString(h.Sum(nil))
+}
+
+// release gives the cache back, saving workdir's node_modules, and evicts
+// caches past the size limit.
+func (r *repoCache) release(workdir string) {
+	if r == nil {
+		return
+	}
+	c := r.caches
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	r.saveNodeModules(workdir)
+	if c.inUse[r.dir]--; c.inUse[r.dir] <= 0 {
+		delete(c.inUse, r.dir)
+	}
+	c.evictLocked()
+}
+
+// evictLocked removes the least recently used caches not in use until the
+// caches fit in MaxBytes.
+func (c *repoCaches) evictLocked() {
+	if c.config.Dir == "" || c.config.MaxBytes <= 0 {
+		return
+	}
+	type entry struct {
+		dir      string
+		size     int64
+		lastUsed time.Time
+	}
+	var entries []entry
+	var total int64
+	owners, _ := os.ReadDir(c.config.Dir)
+	for _, owner := range owners {
+		if !owner.IsDir() {
+			continue
+		}
+		repos, _ := os.ReadDir(filepath.Join(c.config.Dir, owner.Name()))
+		for _, repo := range repos {
+			if !repo.IsDir() {
+				continue
+			}
+			dir := filepath.Join(c.config.Dir, owner.Name(), repo.Name())
+			e := entry{dir: dir, size: dirSize(dir)}
+			if info, err := os.Stat(filepath.Join(dir, lastUsedFile)); err == nil {
+				e.lastUsed = info.ModTime()
+			}
+			entries = append(entries, e)
+			total += e.size
+		}
+	}
+	sort.Slice(entries, func(i, j int) bool { return entries[i].lastUsed.Before(entries[j].lastUsed) })
+	for _, e := range entries {
+		if total <= c.config.MaxBytes {
+			return
+		}
+		if c.inUse[e.dir] > 0 {
+			continue
+		}
+		if err := removeCacheDir(e.dir); err != nil {
+			phaseLog("cache").Warn("Evicting cache failed", "dir", e.dir, "err", err)
+			continue
+		}
+		phaseLog("cache").Info("Evicted cache", "dir", e.dir, "size_mb", e.size>>20)
+		total -= e.size
+	}
+}
+
+// dirSize is the size of the files under dir.
+func dirSize(dir string) int64 {
+	var size int64
+	_ = filepath.WalkDir(dir, func(_ string, d fs.DirEntry, err error) error {
+		if err != nil {
+			return nil
+		}
+		if d.Type().IsRegular() {
+			if info, err := d.Info(); err == nil {
+				size += info.Size()
+			}
+		}
+		return nil
+	})
+	return size
+}
+
+// removeCacheDir removes dir. The Go module cache is read-only, so its
+// directories are made writable first.
+func removeCacheDir(dir string) error {
+	_ = filepath.WalkDir(dir, func(path string, d fs.DirEntry, err error) error {
+		if err == nil && d.IsDir() {
+			_ = os.Chmod(path, 0o755)
+		}
+		return nil
+	})
+	return os.RemoveAll(dir)
+}
+
+type cacheEnvKey struct{}
+
+// withCacheEnv makes the setup, provider and verify commands of a task use
+// the environment of its repository's cache.
+func withCacheEnv(ctx context.Context, env []string) context.Context {
+	return context.WithValue(ctx, cacheEnvKey{}, env)
+}
+
+// cacheEnvFrom returns the cache environment of ctx, or nil.
+func cacheEnvFrom(ctx context.Context) []string {
+	env, _ := ctx.Value(cacheEnvKey{}).([]string)
+	return env
+}
@@ -0,0 +1,153 @@
+package executor
+
+import (
+	"context"
+	"os"
+	"path/filepath"
+	"slices"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/cexll/swe/internal/github"
+	ghdata "github.com/cexll/swe/internal/github/data"
+	"github.com/cexll/swe/internal/provider"
+)
+
+func TestRepoCaches_Disabled(t *testing.T) {
+	c := newRepoCaches()
+	if cache := c.open("owner/repo"); cache != nil {
+		t.Fatalf("open without a directory = %+v", cache)
+	}
+	var none *repoCache
+	if none.env() != nil || none.restoreNodeModules(t.TempDir()) {
+		t.Fatal("a nil cache does nothing")
+	}
+	none.release(t.TempDir())
+}
+
+func TestRepoCache_NodeModulesRoundTrip(t *testing.T) {
+	c := newRepoCaches()
+	c.configure(CacheConfig{Dir: t.TempDir()})
+
+	first := t.TempDir()
+	writeFiles(t, first, map[string]string{
+		"package.json":             `{"name": "app"}`,
+		"package-lock.json":        `{"lockfileVersion": 3}`,
+		"node_modules/left-pad/ix": "module.exports = 1",
+	})
+	cache := c.open("Owner/Repo")
+	if !slices.Contains(cache.env(), "GOMODCACHE="+filepath.Join(cache.dir, "go", "mod")) {
+		t.Fatalf("env = %v", cache.env())
+	}
+	cache.release(first)
+	if _, err := os.Stat(filepath.Join(first, "node_modules")); !os.IsNotExist(err) {
+		t.Fatalf("node_modules left in the checkout: %v", err)
+	}
+
+	// same lockfile: restored
+	second := t.TempDir()
+	writeFiles(t, second, map[string]string{"package.json": `{"name": "app"}`, "package-lock.json": `{"lockfileVersion": 3}`})
+	cache = c.open("owner/repo")
+	if !cache.restoreNodeModules(second) {
+		t.Fatal("node_modules not restored")
+	}
+	if _, err := os.Stat(filepath.Join(second, "node_modules", "left-pad", "ix")); err != nil {
+		t.Fatalf("restored node_modules: %v", err)
+	}
+	cache.release(second)
+
+	// changed lockfile: left for the package manager to install
+	third := t.TempDir()
+	writeFiles(t, third, map[string]string{"package.json": `{"name": "app"}`, "package-lock.json": `{"lockfileVersion": 3, "packages": {}}`})
+	cache = c.open("owner/repo")
+	if cache.restoreNodeModules(third) {
+		t.Fatal("node_modules restored for another lockfile")
+	}
+	cache.release(third)
+}
+
+func TestRepoCaches_EvictsLeastRecentlyUsed(t *testing.T) {
+	root := t.TempDir()
+	c := newRepoCaches()
+	c.configure(CacheConfig{Dir: root, MaxBytes: 2500})
+
+	fill := func(repo string, age time.Duration) *repoCache {
+		cache := c.open(repo)
+		writeFiles(t, cache.dir, map[string]string{"go/mod/cache": strings.Repeat("x", 1000)})
+		used := time.Now().Add(-age)
+		if err := os.Chtimes(filepath.Join(cache.dir, lastUsedFile), used, used); err != nil {
+			t.Fatal(err)
+		}
+		return cache
+	}
+	busy := fill("acme/busy", 3*time.Hour)
+	old := fill("acme/old", 2*time.Hour)
+	old.release(t.TempDir())
+	recent := fill("acme/recent", time.Hour)
+	recent.release(t.TempDir())
+
+	// busy is the oldest but in use, so old goes
+	if _, err := os.Stat(busy.dir); err != nil {
+		t.Fatalf("cache in use evicted: %v", err)
+	}
+	if _, err := os.Stat(old.dir); !os.IsNotExist(err) {
+		t.Fatalf("least recently used cache kept: %v", err)
+	}
+	if _, err := os.Stat(recent.dir); err != nil {
+		t.Fatalf("recent cache evicted: %v", err)
+	}
+
+	// read-only Go module cache directories are removed too
+	if err := os.Chmod(filepath.Join(busy.dir, "go", "mod"), 0o555); err != nil {
+		t.Fatal(err)
+	}
+	c.configure(CacheConfig{Dir: root, MaxBytes: 1500})
+	busy.release(t.TempDir())
+	if _, err := os.Stat(busy.dir); !os.IsNotExist(err) {
+		t.Fatalf("released cache kept past the limit: %v", err)
+	}
+}
+
+func TestExecute_UsesRepositoryCache(t *testing.T) {
+	origClone, origRun := cloneRepo, runCmd
+	t.Cleanup(func() { cloneRepo, runCmd = origClone, origRun })
+	dir := t.TempDir()
+	writeFiles(t, dir, map[string]string{"go.mod": "module example.com/app\n"})
+	cloneRepo = func(_ context.Context, repo, branch, token string) (string, func(), error) {
+		return dir, func() {}, nil
+	}
+	runCmd = func(name string, args ...string) error { return nil }
+	stubSetup(t, nil)
+	var setupEnv []string
+	runSetupStep = func(ctx context.Context, _ string, _ string, _ time.Duration) (string, error) {
+		setupEnv = cacheEnvFrom(ctx)
+		return "", nil
+	}
+
+	var got *provider.CodeRequest
+	e := New(&mockProvider{generateFunc: func(_ context.Context, req *provider.CodeRequest) (*provider.CodeResponse, error) {
+		got = req
+		return &provider.CodeResponse{Summary: "ok"}, nil
+	}}, &mockAuthProvider{})
+	e.fetcher = &mockFetcher{fetchFunc: func(context.Context, *github.Context) (*ghdata.FetchResult, error) {
+		return &ghdata.FetchResult{ContextData: ghdata.Issue{Title: "t", Author: ghdata.Author{Login: "u"}}}, nil
+	}}
+	cacheDir := t.TempDir()
+	e.SetBootstrap(BootstrapConfig{Enabled: true})
+	e.SetCache(CacheConfig{Dir: cacheDir})
+
+	if err := e.Execute(context.Background(), buildTestCtx(false)); err != nil {
+		t.Fatalf("Execute: %v", err)
+	}
+	want := "GOMODCACHE=" + filepath.Join(cacheDir, "owner", "repo", "go", "mod")
+	if !slices.Contains(setupEnv, want) {
+		t.Fatalf("setup env = %v, want %s", setupEnv, want)
+	}
+	if !slices.Contains(got.Env, want) {
+		t.Fatalf("provider env = %v, want %s", got.Env, want)
+	}
+	if len(e.caches.inUse) != 0 {
+		t.Fatalf("cache not released: %v", e.caches.inUse)
+	}
+}
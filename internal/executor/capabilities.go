@@ -0,0 +1,93 @@
+package executor
+
+import (
+	"encoding/json"
+	"fmt"
+)
+
+// capabilities is the machine-readable description of what a task may do,
+// generated from the settings in effect so the prompt never contradicts
+// what the server enforces.
+type capabilities struct {
+	Tools    toolCapabilities   `json:"tools"`
+	Git      gitCapabilities    `json:"git"`
+	Policies policyCapabilities `json:"policies"`
+}
+
+type toolCapabilities struct {
+	Allowed    []string `json:"allowed"`
+	Disallowed []string `json:"disallowed"`
+}
+
+type gitCapabilities struct {
+	// Strategy is how commits reach GitHub: "git-cli" (git commit + git push).
+	Strategy          string   `json:"strategy"`
+	Push              bool     `json:"push"` // false for plans awaiting approval and dry runs
+	SignedCommits     bool     `json:"signed_commits"`
+	Branch            string   `json:"branch"`
+	BaseBranch        string   `json:"base_branch"`
+	ProtectedBranches []string `json:"protected_branches"`
+	ForcePush         bool     `json:"force_push"`
+	DeleteRemoteRefs  bool     `json:"delete_remote_refs"`
+	SkipPushHooks     bool     `json:"skip_push_hooks"`
+	RewritePushed     bool     `json:"rewrite_pushed_history"`
+}
+
+type policyCapabilities struct {
+	BlockedPaths      []string `json:"blocked_paths"`
+	SecretScan        []string `json:"secret_scan_rules"`
+	PostPushChecks    []string `json:"post_push_checks"`
+	WithdrawOnFailure bool     `json:"withdraw_on_failed_check"`
+	WikiEditing       bool     `json:"wiki_editing"`
+}
+
+// taskCapabilities collects the capabilities of one task run.
+func (e *Executor) taskCapabilities(allowed, disallowed []string, branch, base string, protected []string, wiki bool) capabilities {
+	c := capabilities{
+		Tools: toolCapabilities{Allowed: nonNil(allowed), Disallowed: nonNil(disallowed)},
+		Git: gitCapabilities{
+			// USE_COMMIT_SIGNING has no commit path of its own yet; commits
+			// are made and pushed with the git CLI either way
+			Strategy:          "git-cli",
+			Push:              true,
+			Branch:            branch,
+			BaseBranch:        base,
+			ProtectedBranches: nonNil(protected),
+		},
+		Policies: policyCapabilities{
+			BlockedPaths:      nonNil(e.blockedPaths),
+			SecretScan:        []string{},
+			PostPushChecks:    []string{},
+			WithdrawOnFailure: len(e.checks) > 0,
+			WikiEditing:       wiki,
+		},
+	}
+	for _, r := range e.secretRuleSet() {
+		c.Policies.SecretScan = append(c.Policies.SecretScan, r.ID)
+	}
+	for _, check := range e.checks {
+		name := check.Name()
+		if cc, ok := check.(CommandCheck); ok {
+			name = fmt.Sprintf("%s: %s", name, cc.Command)
+		}
+		c.Policies.PostPushChecks = append(c.Policies.PostPushChecks, name)
+	}
+	return c
+}
+
+func nonNil(s []string) []string {
+	if s == nil {
+		return []string{}
+	}
+	return s
+}
+
+// capabilitiesPromptSection renders c for the prompt.
+func capabilitiesPromptSection(c capabilities) string {
+	blob, _ := json.MarshalIndent(c, "", "  ")
+	return fmt.Sprintf(`<capabilities>
+The server enforces these settings for this task; where other instructions disagree, these win.
+Pushes that force, delete remote refs, skip hooks, change blocked_paths or add secrets are rejected.
+`+"```json\n%s\n```"+`
+</capabilities>`, blob)
+}
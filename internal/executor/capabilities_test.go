@@ -0,0 +1,31 @@
+package executor
+
+import (
+	"encoding/json"
+	"strings"
+	"testing"
+)
+
+func TestCapabilitiesPromptSection(t *testing.T) {
+	e := New(&mockProvider{}, &mockAuthProvider{})
+	e.SetBlockedPaths(DefaultBlockedPaths)
+	e.SetPostPushChecks(CommandCheck{Command: "make test"})
+	section := capabilitiesPromptSection(e.taskCapabilities([]string{"Read"}, nil, "swe-agent/1-1", "main", []string{"main"}, true))
+
+	body := strings.TrimSuffix(strings.SplitN(section, "```json\n", 2)[1], "\n```\n</capabilities>")
+	var got capabilities
+	if err := json.Unmarshal([]byte(body), &got); err != nil {
+		t.Fatalf("capabilities block is not JSON: %v\n%s", err, section)
+	}
+	if got.Git.Strategy != "git-cli" || !got.Git.Push || got.Git.ForcePush || got.Git.Branch != "swe-agent/1-1" || got.Git.ProtectedBranches[0] != "main" {
+		t.Fatalf("git = %+v", got.Git)
+	}
+	if strings.Join(got.Tools.Allowed, ",") != "Read" || got.Tools.Disallowed == nil {
+		t.Fatalf("tools = %+v", got.Tools)
+	}
+	p := got.Policies
+	if p.BlockedPaths[0] != ".github/workflows" || p.PostPushChecks[0] != "verify command: make test" || !p.WithdrawOnFailure ||
+		!p.WikiEditing || len(p.SecretScan) != len(DefaultSecretRules) {
+		t.Fatalf("policies = %+v", p)
+	}
+}
@@ -0,0 +1,113 @@
+package executor
+
+import (
+	"errors"
+	"fmt"
+	"slices"
+	"strings"
+
+	"github.com/cexll/swe/internal/github"
+)
+
+// DefaultCommitStatusContext names the commit status of tasks on GitHub.
+const DefaultCommitStatusContext = "swe-agent"
+
+// CommitStatusConfig reports tasks as commit statuses on the commits of
+// their branch: pending while a task runs on a pull request, then success or
+// failure on the commit it pushed. Statuses need only statuses:write, for
+// installations that cannot create check runs.
+type CommitStatusConfig struct {
+	Enabled bool
+	Context string // the status's name ("" uses DefaultCommitStatusContext)
+	// BaseURL is where this server is reachable; statuses link to the task
+	// page under it ("" adds no link)
+	BaseURL string
+}
+
+// allow tests to stub the GitHub API
+var createCommitStatus = github.CreateCommitStatus
+
+// SetCommitStatus sets how subsequent tasks report commit statuses.
+func (e *Executor) SetCommitStatus(c CommitStatusConfig) {
+	e.mu.Lock()
+	defer e.mu.Unlock()
+	e.commitStatus = c
+}
+
+// commitStatuses are the statuses of one task: the commits given one so far,
+// which get its final state too.
+type commitStatuses struct {
+	e     *Executor
+	ctx   *github.Context
+	token string
+	shas  []string
+}
+
+// startCommitStatus returns the statuses of ctx's task, marking sha (the head
+// of the branch the task works on, "" for a new branch) pending. It returns
+// nil when commit statuses are disabled.
+func (e *Executor) startCommitStatus(ctx *github.Context, token, sha string) *commitStatuses {
+	if !e.commitStatus.Enabled {
+		return nil
+	}
+	s := &commitStatuses{e: e, ctx: ctx, token: token}
+	if sha != "" {
+		s.set(sha, github.StatusPending, "Working on "+taskSubject(ctx))
+	}
+	return s
+}
+
+// finish gives the commits marked so far and head, the branch's commit after
+// the task, the final state for err.
+func (s *commitStatuses) finish(head string, err error) {
+	if s == nil {
+		return
+	}
+	state, description := github.StatusSuccess, "Finished "+taskSubject(s.ctx)
+	if err != nil {
+		state, description = github.StatusFailure, "Failed: "+lastLine(err.Error())
+		if errors.Is(err, ErrTimedOut) {
+			state = github.StatusError
+		}
+		if s.ctx.Token != "" {
+			description = strings.ReplaceAll(description, s.ctx.Token, "***")
+		}
+	}
+	marked := append([]string{}, s.shas...)
+	if head != "" && !slices.Contains(marked, head) {
+		marked = append(marked, head)
+	}
+	for _, sha := range marked {
+		s.set(sha, state, description)
+	}
+}
+
+// set posts one status; failures are logged, never fail the task.
+func (s *commitStatuses) set(sha, state, description string) {
+	status := github.CommitStatus{
+		State:       state,
+		Context:     s.e.commitStatus.Context,
+		Description: description,
+	}
+	if status.Context == "" {
+		status.Context = DefaultCommitStatusContext
+	}
+	if base := strings.TrimRight(s.e.commitStatus.BaseURL, "/"); base != "" && s.ctx.TaskID != "" {
+		status.TargetURL = base + "/tasks/" + s.ctx.TaskID
+	}
+	if err := createCommitStatus(s.ctx.GetRepositoryOwner(), s.ctx.GetRepositoryName(), sha, s.token, status); err != nil {
+		taskLog(s.ctx, "commit_status").Warn("Setting commit status failed", "state", state, "sha", sha, "err", err)
+		return
+	}
+	if !slices.Contains(s.shas, sha) {
+		s.shas = append(s.shas, sha)
+	}
+}
+
+// taskSubject names what ctx's task works on ("#12").
+func taskSubject(ctx *github.Context) string {
+	if ctx.IsPRContext() {
+		return fmt.Sprintf("#%d", ctx.GetPRNumber())
+	}
+	return fmt.Sprintf("#%d", ctx.GetIssueNumber())
+}
@@ -0,0 +1,75 @@
+package executor
+
+import (
+	"errors"
+	"fmt"
+	"strings"
+	"testing"
+
+	"github.com/cexll/swe/internal/github"
+)
+
+func stubCommitStatus(t *testing.T) *[]string {
+	t.Helper()
+	orig := createCommitStatus
+	t.Cleanup(func() { createCommitStatus = orig })
+	var posted []string
+	createCommitStatus = func(owner, repo, sha, token string, status github.CommitStatus) error {
+		if sha == "broken" {
+			return errors.New("403 Resource not accessible by integration")
+		}
+		posted = append(posted, fmt.Sprintf("%s/%s@%s %s %s %q %s", owner, repo, sha, status.Context, status.State, status.Description, status.TargetURL))
+		return nil
+	}
+	return &posted
+}
+
+func TestCommitStatus_PendingThenFinalState(t *testing.T) {
+	posted := stubCommitStatus(t)
+	e := New(&mockProvider{}, &mockAuthProvider{})
+	ctx := buildTestCtx(true)
+	ctx.TaskID = "task-1"
+
+	if s := e.startCommitStatus(ctx, "test-token", "abc"); s != nil || len(*posted) != 0 {
+		t.Fatalf("disabled: statuses %v posted %v", s, *posted)
+	}
+
+	e.SetCommitStatus(CommitStatusConfig{Enabled: true, BaseURL: "https://swe.example.com/"})
+	s := e.startCommitStatus(ctx, "test-token", "abc")
+	s.finish("def", nil)
+	want := []string{
+		`owner/repo@abc swe-agent pending "Working on #2" https://swe.example.com/tasks/task-1`,
+		`owner/repo@abc swe-agent success "Finished #2" https://swe.example.com/tasks/task-1`,
+		`owner/repo@def swe-agent success "Finished #2" https://swe.example.com/tasks/task-1`,
+	}
+	if strings.Join(*posted, "\n") != strings.Join(want, "\n") {
+		t.Fatalf("posted:\n%s\nwant:\n%s", strings.Join(*posted, "\n"), strings.Join(want, "\n"))
+	}
+}
+
+func TestCommitStatus_Failures(t *testing.T) {
+	posted := stubCommitStatus(t)
+	e := New(&mockProvider{}, &mockAuthProvider{})
+	e.SetCommitStatus(CommitStatusConfig{Enabled: true, Context: "ai/swe"})
+	ctx := buildTestCtx(false)
+	ctx.Token = "ghs_secret"
+
+	// a new branch has nothing to mark pending; a refused status is skipped
+	s := e.startCommitStatus(ctx, "test-token", "")
+	s.finish("broken", errors.New("push rejected\nremote: ghs_secret denied"))
+	if len(*posted) != 0 {
+		t.Fatalf("posted %v", *posted)
+	}
+	e.startCommitStatus(ctx, "test-token", "").finish("abc", fmt.Errorf("%w after 30m0s", ErrTimedOut))
+	e.startCommitStatus(ctx, "test-token", "").finish("def", errors.New("clone failed for ghs_secret"))
+	want := []string{
+		`owner/repo@abc ai/swe error "Failed: task timed out after 30m0s" `,
+		`owner/repo@def ai/swe failure "Failed: clone failed for ***" `,
+	}
+	if strings.Join(*posted, "\n") != strings.Join(want, "\n") {
+		t.Fatalf("posted:\n%s\nwant:\n%s", strings.Join(*posted, "\n"), strings.Join(want, "\n"))
+	}
+
+	var none *commitStatuses
+	none.finish("abc", nil)
+}
@@ -0,0 +1,65 @@
+package executor
+
+import (
+	"errors"
+	"fmt"
+
+	"github.com/cexll/swe/internal/github"
+	"github.com/cexll/swe/internal/github/comment"
+	"github.com/cexll/swe/internal/prompt"
+)
+
+// DefaultCostPerMTokUSD is the default estimated cost of a run per million
+// prompt tokens. The provider reads the prompt again on every turn, so it is
+// several times a model's input price.
+const DefaultCostPerMTokUSD = 15.0
+
+// errAwaitingConfirmation stops a task whose estimated cost needs
+// confirming. The task is neither done nor failed: it waits, pending, for the
+// confirm command, which queues a new run.
+var errAwaitingConfirmation = errors.New("awaiting cost confirmation")
+
+// CostEstimate prices a task from the size of its prompt before the
+// provider runs.
+type CostEstimate struct {
+	PerMTokUSD float64 // estimated cost of a run per million prompt tokens
+	// ConfirmUSD is the estimate from which a task with a confirm command
+	// stops and waits for it (0: never)
+	ConfirmUSD float64
+}
+
+// estimate returns the tokens of fullPrompt and what a run on it likely
+// costs.
+func (c CostEstimate) estimate(fullPrompt string) (int, float64) {
+	tokens := prompt.EstimateTokens(fullPrompt)
+	return tokens, float64(tokens) / 1e6 * c.PerMTokUSD
+}
+
+// SetCostEstimate sets how subsequent tasks estimate their cost and from
+// which estimate they wait for confirmation.
+func (e *Executor) SetCostEstimate(c CostEstimate) {
+	e.mu.Lock()
+	defer e.mu.Unlock()
+	e.costEstimate = c
+}
+
+// awaitCostConfirmation reports whether the task must stop before the
+// provider runs on fullPrompt: its estimated cost reaches the confirmation
+// threshold and it has a confirm command. The estimate is then recorded in
+// the task store and posted at the top of the tracking comment.
+func (e *Executor) awaitCostConfirmation(ctx *github.Context, fullPrompt string) bool {
+	if ctx.PreparedConfirmCommand == "" || e.costEstimate.ConfirmUSD <= 0 {
+		return false
+	}
+	tokens, usd := e.costEstimate.estimate(fullPrompt)
+	if usd < e.costEstimate.ConfirmUSD {
+		return false
+	}
+	taskLog(ctx, "estimate").Info("Estimated cost needs confirming", "prompt_tokens", tokens, "estimate_usd", usd, "confirm_usd", e.costEstimate.ConfirmUSD)
+	if e.store != nil && ctx.TaskID != "" {
+		e.store.AwaitConfirmation(ctx.TaskID, usd)
+		e.store.AddLog(ctx.TaskID, "info", fmt.Sprintf("Estimated cost $%.2f (%d prompt tokens), awaiting confirmation", usd, tokens))
+	}
+	prependNotice(ctx, "> [!IMPORTANT]\n> "+commentText(ctx, comment.MsgConfirmCost, tokens, usd, e.costEstimate.ConfirmUSD, ctx.PreparedConfirmCommand))
+	return true
+}
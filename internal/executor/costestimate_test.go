@@ -0,0 +1,121 @@
+package executor
+
+import (
+	"context"
+	"strings"
+	"sync"
+	"testing"
+
+	"github.com/cexll/swe/internal/audit"
+	"github.com/cexll/swe/internal/github"
+	"github.com/cexll/swe/internal/notify"
+	"github.com/cexll/swe/internal/taskstore"
+)
+
+// recordingNotifier keeps the types of the events it is sent.
+type recordingNotifier struct {
+	mu     sync.Mutex
+	events []notify.EventType
+}
+
+func (n *recordingNotifier) Name() string { return "recording" }
+
+func (n *recordingNotifier) Notify(_ context.Context, ev notify.Event) error {
+	n.mu.Lock()
+	defer n.mu.Unlock()
+	n.events = append(n.events, ev.Type)
+	return nil
+}
+
+func TestAwaitCostConfirmation(t *testing.T) {
+	origGet, origUpdate := getComment, updateComment
+	t.Cleanup(func() { getComment, updateComment = origGet, origUpdate })
+	body := "Working on your request..."
+	getComment = func(_, _ string, _ int64, _ string) (string, error) { return body, nil }
+	updateComment = func(_, _ string, _ int64, b, _ string) error {
+		body = b
+		return nil
+	}
+
+	store := taskstore.NewStore()
+	store.Create(&taskstore.Task{ID: "t1"})
+	e := New(&mockProvider{}, &mockAuthProvider{})
+	e.SetTaskStore(store)
+	e.SetCostEstimate(CostEstimate{PerMTokUSD: 10, ConfirmUSD: 2})
+	ctx := &github.Context{TaskID: "t1", PreparedCommentID: 7, Token: "installation-token", Repository: github.Repository{Owner: "acme", Name: "api"}}
+	large := strings.Repeat("x", 4*200000) // 200k tokens, $2.00
+
+	if e.awaitCostConfirmation(ctx, large) {
+		t.Fatal("stopped without a confirm command")
+	}
+	ctx.PreparedConfirmCommand = "/code confirm"
+	if e.awaitCostConfirmation(ctx, large[:4*199999]) {
+		t.Fatal("stopped below the threshold")
+	}
+	if !e.awaitCostConfirmation(ctx, large) {
+		t.Fatal("did not stop at the threshold")
+	}
+	if !strings.HasPrefix(body, "> [!IMPORTANT]\n> **Confirm the cost.** Nothing has run yet. The prompt is about 200000 tokens, so this task is estimated at $2.00") ||
+		!strings.Contains(body, "Comment `/code confirm` to run it.") || !strings.HasSuffix(body, "Working on your request...") {
+		t.Fatalf("body = %q", body)
+	}
+	if got, _ := store.Get("t1"); got.Confirmation != taskstore.ConfirmationAwaiting || got.EstimatedCostUSD != 2 {
+		t.Fatalf("task = %+v", got)
+	}
+
+	e.SetCostEstimate(CostEstimate{PerMTokUSD: 10})
+	if e.awaitCostConfirmation(ctx, large) {
+		t.Fatal("stopped with confirmation disabled")
+	}
+}
+
+func TestExecute_StopsForCostConfirmation(t *testing.T) {
+	workdir, _ := initPushRepo(t)
+	updated := stubComments(t, "Working on your request...")
+	e, prompt, _ := approvalExecutor(t, workdir)
+	e.SetCostEstimate(CostEstimate{PerMTokUSD: 1e6, ConfirmUSD: 1})
+	store := taskstore.NewStore()
+	store.Create(&taskstore.Task{ID: "task-1", Status: taskstore.StatusPending})
+	e.SetTaskStore(store)
+	log, _ := audit.New(audit.Config{})
+	e.SetAuditLog(log)
+	recorder := &recordingNotifier{}
+	n := notify.NewManager(notify.Route{Notifier: recorder})
+	e.SetNotifier(n)
+
+	ctx := buildTestCtx(false)
+	ctx.TaskID = "task-1"
+	ctx.PreparedCommentID = 7
+	ctx.PreparedCommentHistory = true
+	ctx.PreparedConfirmCommand = "/code confirm"
+	if err := e.Execute(context.Background(), ctx); err != nil {
+		t.Fatalf("Execute: %v", err)
+	}
+	n.Wait()
+
+	if *prompt != "" {
+		t.Fatal("the provider ran")
+	}
+	if got, _ := store.Get("task-1"); got.Status != taskstore.StatusPending || got.Confirmation != taskstore.ConfirmationAwaiting {
+		t.Fatalf("task = %+v", got)
+	}
+	if len(recorder.events) != 0 {
+		t.Fatalf("notified %v", recorder.events)
+	}
+	if strings.Contains(*updated, "swe-agent:outcome") || !strings.Contains(*updated, "**Confirm the cost.**") {
+		t.Fatalf("tracking comment = %q", *updated)
+	}
+	if events := log.List(audit.Filter{Action: audit.ActionExecutionDone}); len(events) != 0 {
+		t.Fatalf("audited as done: %+v", events)
+	}
+	if events := log.List(audit.Filter{Action: audit.ActionExecutionFailed}); len(events) != 0 {
+		t.Fatalf("audited as failed: %+v", events)
+	}
+
+	if !store.Confirm("task-1", "installer") {
+		t.Fatal("the stopped task cannot be confirmed")
+	}
+	if got, _ := store.Get("task-1"); got.Status != taskstore.StatusCompleted {
+		t.Fatalf("confirmed task status = %s", got.Status)
+	}
+}
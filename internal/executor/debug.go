@@ -0,0 +1,119 @@
+package executor
+
+import (
+	"context"
+	"fmt"
+	"log/slog"
+	"path/filepath"
+	"strings"
+	"sync"
+
+	"github.com/cexll/swe/internal/artifacts"
+	"github.com/cexll/swe/internal/github"
+	"github.com/cexll/swe/internal/logging"
+)
+
+// maxDebugLogSize caps the debug log kept for a task.
+const maxDebugLogSize = 20 << 20
+
+// SetDebugLogLevel sets the level a task run with --debug logs from (debug
+// unless configured otherwise), whatever the server's level.
+func (e *Executor) SetDebugLogLevel(lvl slog.Level) {
+	e.mu.Lock()
+	defer e.mu.Unlock()
+	e.debugLevel = lvl
+}
+
+// debugRun is the troubleshooting state of a task run with --debug: its
+// lines are logged from the debug level, and the provider CLI's stderr and
+// every git command run in its checkout, with the output, are kept as the
+// task's debug log artifact.
+type debugRun struct {
+	mu  sync.Mutex
+	log cappedBuffer
+	dir string // the checkout whose git commands are captured
+}
+
+// startDebug raises the log level of ctx for a task run with --debug and,
+// when the task keeps artifacts, returns the capture of its debug log
+// (nil otherwise).
+func (e *Executor) startDebug(ctx context.Context, ghCtx *github.Context) (context.Context, *debugRun) {
+	if !ghCtx.PreparedDebug {
+		return ctx, nil
+	}
+	ctx = logging.WithLevel(ctx, e.debugLevel)
+	taskLog(ghCtx, "debug").InfoContext(ctx, "Debug run", "level", e.debugLevel.String(), "capture", e.wantsArtifacts(ghCtx))
+	if !e.wantsArtifacts(ghCtx) {
+		return ctx, nil
+	}
+	return ctx, &debugRun{log: cappedBuffer{max: maxDebugLogSize}}
+}
+
+// Write appends provider output to the debug log; it is safe for
+// concurrent use.
+func (d *debugRun) Write(p []byte) (int, error) {
+	d.mu.Lock()
+	defer d.mu.Unlock()
+	return d.log.Write(p)
+}
+
+// capture records the git commands run in dir until the returned function
+// is called.
+func (d *debugRun) capture(dir string) func() {
+	d.dir = filepath.Clean(dir)
+	debugRuns.Store(d.dir, d)
+	return func() { debugRuns.Delete(d.dir) }
+}
+
+// command appends a command, its output and its error to the debug log.
+func (d *debugRun) command(name string, args []string, out []byte, err error) {
+	var b strings.Builder
+	fmt.Fprintf(&b, "$ %s %s\n", name, strings.Join(args, " "))
+	b.Write(out)
+	if len(out) > 0 && out[len(out)-1] != '\n' {
+		b.WriteByte('\n')
+	}
+	if err != nil {
+		fmt.Fprintf(&b, "[%v]\n", err)
+	}
+	_, _ = d.Write([]byte(b.String()))
+}
+
+// saveDebugLog keeps the debug log as the task's artifact.
+func (e *Executor) saveDebugLog(ctx context.Context, ghCtx *github.Context, d *debugRun) {
+	d.mu.Lock()
+	defer d.mu.Unlock()
+	if d.log.dropped > 0 {
+		fmt.Fprintf(&d.log.buf, "[debug log truncated: %d bytes dropped]\n", d.log.dropped)
+	}
+	e.saveArtifact(ctx, ghCtx, artifacts.DebugLog, d.log.buf.Bytes())
+}
+
+// debugRuns maps the checkout of each task run with --debug to its
+// capture; git commands name their checkout with -C.
+var debugRuns sync.Map
+
+// recordCommand adds a git command and its output to the debug log of the
+// checkout it ran in, if a debug run captures it.
+func recordCommand(name string, args []string, out []byte, err error) {
+	if name != "git" {
+		return
+	}
+	if d := debugRunFor(args); d != nil {
+		d.command(name, args, out, err)
+	}
+}
+
+// debugRunFor returns the debug run capturing the checkout args name with
+// -C, or nil.
+func debugRunFor(args []string) *debugRun {
+	for i := 0; i+1 < len(args); i++ {
+		if args[i] == "-C" {
+			if d, ok := debugRuns.Load(filepath.Clean(args[i+1])); ok {
+				return d.(*debugRun)
+			}
+			return nil
+		}
+	}
+	return nil
+}
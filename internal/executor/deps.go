@@ -0,0 +1,432 @@
+package executor
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"os"
+	"os/exec"
+	"path/filepath"
+	"regexp"
+	"sort"
+	"strings"
+	"time"
+
+	"github.com/cexll/swe/internal/audit"
+	"github.com/cexll/swe/internal/github"
+	"github.com/cexll/swe/internal/taskstore"
+)
+
+// allow tests to stub the package manager commands
+var runInDir = runCommandInDir
+
+// depsLabel is added to every dependency update pull request.
+const depsLabel = "dependencies"
+
+// npmDefaultTest is the test script `npm init` writes, which always fails.
+const npmDefaultTest = `echo "Error: no test specified" && exit 1`
+
+// pseudoVersion matches Go pseudo-versions (v0.0.0-20240101000000-abcdef123456),
+// which have no release notes to link.
+var pseudoVersion = regexp.MustCompile(`\d{14}-[0-9a-f]{12}$`)
+
+// majorSuffix matches the /vN suffix of a Go module path.
+var majorSuffix = regexp.MustCompile(`^v\d+$`)
+
+// depsEcosystem is a package manager /code update-deps drives in the root of
+// the repository.
+type depsEcosystem struct {
+	name   string
+	files  []string   // what the update may change; only these are committed
+	update [][]string // commands, in order
+	test   []string   // nil when the repository defines no tests
+	// versions returns the direct dependencies and their versions
+	versions func(workdir string) map[string]string
+	// changelog links a dependency's changes between two versions ("" for none)
+	changelog func(workdir, name, from, to string) string
+}
+
+// depsBump is one dependency that changed version.
+type depsBump struct {
+	ecosystem, name, from, to, changelog string
+}
+
+// detectEcosystems returns the package managers of the repository checked
+// out in workdir, and the ones found but not supported.
+func detectEcosystems(workdir string) (found []depsEcosystem, unsupported []string) {
+	exists := func(name string) bool {
+		_, err := os.Stat(filepath.Join(workdir, name))
+		return err == nil
+	}
+	if exists("go.mod") {
+		found = append(found, depsEcosystem{
+			name:      "Go modules",
+			files:     []string{"go.mod", "go.sum"},
+			update:    [][]string{{"go", "get", "-u", "./..."}, {"go", "mod", "tidy"}},
+			test:      []string{"go", "test", "./..."},
+			versions:  goModRequires,
+			changelog: goChangelog,
+		})
+	}
+	if exists("package.json") {
+		switch {
+		case exists("pnpm-lock.yaml"):
+			unsupported = append(unsupported, "pnpm")
+		case exists("yarn.lock"):
+			unsupported = append(unsupported, "yarn")
+		default:
+			eco := depsEcosystem{
+				name:      "npm",
+				files:     []string{"package.json", "package-lock.json"},
+				update:    [][]string{{"npm", "update"}},
+				versions:  npmLockVersions,
+				changelog: npmChangelog,
+			}
+			if npmHasTests(workdir) {
+				eco.test = []string{"npm", "test"}
+			}
+			found = append(found, eco)
+		}
+	}
+	return found, unsupported
+}
+
+// goModRequires returns the direct requirements of go.mod.
+func goModRequires(workdir string) map[string]string {
+	data, err := os.ReadFile(filepath.Join(workdir, "go.mod"))
+	if err != nil {
+		return nil
+	}
+	versions := make(map[string]string)
+	inBlock := false
+	for _, line := range strings.Split(string(data), "\n") {
+		line = strings.TrimSpace(line)
+		switch {
+		case strings.HasPrefix(line, "require") && strings.HasSuffix(line, "("):
+			inBlock = true
+			continue
+		case inBlock && line == ")":
+			inBlock = false
+			continue
+		case strings.HasPrefix(line, "require "):
+			line = strings.TrimPrefix(line, "require ")
+		case !inBlock:
+			continue
+		}
+		if strings.Contains(line, "// indirect") {
+			continue
+		}
+		if fields := strings.Fields(line); len(fields) >= 2 {
+			versions[fields[0]] = fields[1]
+		}
+	}
+	return versions
+}
+
+// goChangelog links the GitHub comparison of two released versions of a
+// module hosted at the root of a GitHub repository.
+func goChangelog(_, module, from, to string) string {
+	if pseudoVersion.MatchString(from) || pseudoVersion.MatchString(to) {
+		return ""
+	}
+	parts := strings.Split(module, "/")
+	if len(parts) < 3 || parts[0] != "github.com" || len(parts) > 4 || (len(parts) == 4 && !majorSuffix.MatchString(parts[3])) {
+		return "" // modules in subdirectories tag with a prefix
+	}
+	return fmt.Sprintf("https://github.com/%s/%s/compare/%s...%s", parts[1], parts[2],
+		strings.TrimSuffix(from, "+incompatible"), strings.TrimSuffix(to, "+incompatible"))
+}
+
+// packageJSON holds the parts of package.json update-deps reads.
+type packageJSON struct {
+	Dependencies    map[string]string `json:"dependencies"`
+	DevDependencies map[string]string `json:"devDependencies"`
+	Scripts         map[string]string `json:"scripts"`
+	Repository      json.RawMessage   `json:"repository"`
+}
+
+func readPackageJSON(path string) (packageJSON, error) {
+	var pkg packageJSON
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return pkg, err
+	}
+	return pkg, json.Unmarshal(data, &pkg)
+}
+
+func npmHasTests(workdir string) bool {
+	pkg, err := readPackageJSON(filepath.Join(workdir, "package.json"))
+	test := strings.TrimSpace(pkg.Scripts["test"])
+	return err == nil && test != "" && test != npmDefaultTest
+}
+
+// npmLockVersions returns the installed versions of the direct dependencies
+// recorded in package-lock.json.
+func npmLockVersions(workdir string) map[string]string {
+	pkg, err := readPackageJSON(filepath.Join(workdir, "package.json"))
+	if err != nil {
+		return nil
+	}
+	data, err := os.ReadFile(filepath.Join(workdir, "package-lock.json"))
+	if err != nil {
+		return nil
+	}
+	var lock struct {
+		Packages map[string]struct {
+			Version string `json:"version"`
+		} `json:"packages"`
+	}
+	if err := json.Unmarshal(data, &lock); err != nil {
+		return nil
+	}
+	versions := make(map[string]string)
+	for _, deps := range []map[string]string{pkg.Dependencies, pkg.DevDependencies} {
+		for name := range deps {
+			if p, ok := lock.Packages["node_modules/"+name]; ok && p.Version != "" {
+				versions[name] = p.Version
+			}
+		}
+	}
+	return versions
+}
+
+// npmChangelog links the releases of an installed package whose repository
+// is on GitHub.
+func npmChangelog(workdir, name, _, _ string) string {
+	pkg, err := readPackageJSON(filepath.Join(workdir, "node_modules", name, "package.json"))
+	if err != nil || len(pkg.Repository) == 0 {
+		return ""
+	}
+	var url string
+	if json.Unmarshal(pkg.Repository, &url) != nil {
+		var repo struct {
+			URL string `json:"url"`
+		}
+		_ = json.Unmarshal(pkg.Repository, &repo)
+		url = repo.URL
+	}
+	if slug := githubSlug(url); slug != "" {
+		return "https://github.com/" + slug + "/releases"
+	}
+	return ""
+}
+
+// githubSlug returns owner/name of a repository field pointing at GitHub:
+// "github:owner/name", "owner/name" or a github.com URL.
+func githubSlug(url string) string {
+	url = strings.TrimSuffix(strings.TrimSpace(url), ".git")
+	switch {
+	case strings.HasPrefix(url, "github:"):
+		url = strings.TrimPrefix(url, "github:")
+	case strings.Contains(url, "github.com/"):
+		_, url, _ = strings.Cut(url, "github.com/")
+	case strings.Contains(url, "github.com:"):
+		_, url, _ = strings.Cut(url, "github.com:")
+	case strings.Contains(url, ":"):
+		return "" // another host
+	}
+	parts := strings.Split(url, "/")
+	if len(parts) != 2 || parts[0] == "" || parts[1] == "" {
+		return ""
+	}
+	return url
+}
+
+// diffVersions returns the dependencies whose version changed, by name.
+func diffVersions(eco depsEcosystem, workdir string, before, after map[string]string) []depsBump {
+	var bumps []depsBump
+	for name, to := range after {
+		if from, ok := before[name]; ok && from != to {
+			bumps = append(bumps, depsBump{ecosystem: eco.name, name: name, from: from, to: to, changelog: eco.changelog(workdir, name, from, to)})
+		}
+	}
+	sort.Slice(bumps, func(i, j int) bool { return bumps[i].name < bumps[j].name })
+	return bumps
+}
+
+// runCommandInDir runs a package manager command in dir without the GitHub
+// tokens and returns its combined output.
+func runCommandInDir(ctx context.Context, dir, name string, args ...string) (string, error) {
+	cmd := exec.CommandContext(ctx, name, args...)
+	cmd.Dir = dir
+	cmd.Env = scrubbedEnv()
+	cmd.WaitDelay = 5 * time.Second
+	out, err := cmd.CombinedOutput()
+	if err != nil {
+		return string(out), fmt.Errorf("%s %s: %w", name, strings.Join(args, " "), err)
+	}
+	return string(out), nil
+}
+
+// depsTests runs the verify command when one is configured, otherwise each
+// package manager's tests. It returns what ran and the failure, if any.
+func (e *Executor) depsTests(ctx context.Context, workdir, branch string, ecosystems []depsEcosystem) (ran []string, failure error) {
+	if len(e.checks) > 0 {
+		for _, check := range e.checks {
+			ran = append(ran, check.Name())
+			if err := check.Check(ctx, workdir, branch); err != nil {
+				return ran, err
+			}
+		}
+		return ran, nil
+	}
+	for _, eco := range ecosystems {
+		if eco.test == nil {
+			continue
+		}
+		command := strings.Join(eco.test, " ")
+		ran = append(ran, "`"+command+"`")
+		if out, err := runInDir(ctx, workdir, eco.test[0], eco.test[1:]...); err != nil {
+			return ran, &CheckFailure{Command: command, Output: out, Err: err}
+		}
+	}
+	return ran, nil
+}
+
+// executeUpdateDeps updates the dependencies with the package managers of
+// the repository checked out in workdir, runs the tests and opens a pull
+// request listing the bumps.
+func (e *Executor) executeUpdateDeps(ctx context.Context, ghCtx *github.Context, workdir, repo, base, token string) (summary string, err error) {
+	defer func() {
+		if err != nil {
+			msg := strings.ReplaceAll(err.Error(), token, "***")
+			e.reportOutcome(ghCtx, fmt.Sprintf("### Dependency update failed\n\nNo pull request was opened.\n\n```\n%s\n```", tail(msg, verifyOutputLimit)))
+		}
+	}()
+	ecosystems, unsupported := detectEcosystems(workdir)
+	if len(ecosystems) == 0 {
+		msg := "update-deps: no supported package manager found (go.mod, or package.json with npm)"
+		if len(unsupported) > 0 {
+			msg += "; " + strings.Join(unsupported, " and ") + " is not supported yet"
+		}
+		return "", &NonRetryableError{msg: msg}
+	}
+
+	e.phase(ghCtx, taskstore.PhaseUpdate)
+	var bumps []depsBump
+	var files []string
+	for _, eco := range ecosystems {
+		before := eco.versions(workdir)
+		for _, args := range eco.update {
+			if out, err := runInDir(ctx, workdir, args[0], args[1:]...); err != nil {
+				return "", fmt.Errorf("%s update: %w\n%s", eco.name, err, tail(out, verifyOutputLimit))
+			}
+		}
+		bumps = append(bumps, diffVersions(eco, workdir, before, eco.versions(workdir))...)
+		for _, f := range eco.files {
+			if _, err := os.Stat(filepath.Join(workdir, f)); err == nil {
+				files = append(files, f)
+			}
+		}
+	}
+	changed, err := gitOutput(workdir, append([]string{"status", "--porcelain", "--"}, files...)...)
+	if err != nil {
+		return "", fmt.Errorf("list changed files: %w", err)
+	}
+	if strings.TrimSpace(changed) == "" {
+		summary = fmt.Sprintf("### Dependencies are up to date\n\nNothing to update on `%s`.", base)
+		e.reportOutcome(ghCtx, summary)
+		return summary, nil
+	}
+
+	branch := fmt.Sprintf("swe-agent/update-deps-%d", time.Now().Unix())
+	e.phase(ghCtx, taskstore.PhaseTests)
+	ran, testErr := e.depsTests(ctx, workdir, branch, ecosystems)
+
+	owner, name := ghCtx.GetRepositoryOwner(), ghCtx.GetRepositoryName()
+	title := "Update dependencies"
+	if len(bumps) > 0 {
+		title = fmt.Sprintf("Update %d dependencies", len(bumps))
+	}
+	message := title + "\n\n"
+	for _, b := range bumps {
+		message += fmt.Sprintf("- %s %s -> %s\n", b.name, b.from, b.to)
+	}
+	if err := runCmd("git", "-C", workdir, "checkout", "-q", "-b", branch); err != nil {
+		return "", fmt.Errorf("create update branch: %w", err)
+	}
+	if err := runCmd("git", append([]string{"-C", workdir, "add", "--"}, files...)...); err != nil {
+		return "", fmt.Errorf("stage updated files: %w", err)
+	}
+	if err := runCmd("git", "-C", workdir, "commit", "-q", "-m", strings.TrimSpace(message)); err != nil {
+		return "", fmt.Errorf("commit update: %w", err)
+	}
+	e.phase(ghCtx, taskstore.PhasePush)
+	if err := runCmd("git", "-C", workdir, "push", "-q", "origin", "HEAD:refs/heads/"+branch); err != nil {
+		return "", fmt.Errorf("push update branch: %w", err)
+	}
+
+	body := depsPullRequestBody(ghCtx, bumps, ran, testErr, unsupported)
+	created, url, err := createPullRequest(owner, name, title, branch, base, body, token)
+	if err != nil {
+		return "", fmt.Errorf("open dependency update pull request: %w", err)
+	}
+	if err := addLabels(owner, name, created, []string{depsLabel}, token); err != nil {
+		taskLog(ghCtx, "update_deps").Warn("Labeling dependency update pull request failed", "pull_request", created, "err", err)
+	}
+	taskLog(ghCtx, "update_deps").Info("Dependency updates opened", "bumps", len(bumps), "pull_request", created)
+
+	ev := e.auditEvent(ghCtx, audit.ActionDepsUpdated)
+	ev.Branch = branch
+	ev.Detail = url
+	e.recordAudit(ev)
+
+	summary = fmt.Sprintf("### %s\n\n[#%d](%s) updates %s on `%s`.", title, created, url, depsCount(len(bumps)), base)
+	if testErr != nil {
+		summary += "\n\n> [!WARNING]\n> The tests failed after the update; the pull request has the details."
+	}
+	e.reportOutcome(ghCtx, summary)
+	return summary, nil
+}
+
+func depsCount(n int) string {
+	switch n {
+	case 0:
+		return "indirect dependencies"
+	case 1:
+		return "1 dependency"
+	}
+	return fmt.Sprintf("%d dependencies", n)
+}
+
+// depsPullRequestBody lists the bumps of a dependency update and how its
+// tests went.
+func depsPullRequestBody(ghCtx *github.Context, bumps []depsBump, ran []string, testErr error, unsupported []string) string {
+	var b strings.Builder
+	number := ghCtx.GetIssueNumber()
+	if ghCtx.IsPRContext() && ghCtx.GetPRNumber() != 0 {
+		number = ghCtx.GetPRNumber()
+	}
+	fmt.Fprintf(&b, "Dependency update requested in #%d.\n\n", number)
+	if len(bumps) == 0 {
+		b.WriteString("Only indirect dependencies changed.\n")
+	} else {
+		b.WriteString("| Package | From | To | Changes |\n| --- | --- | --- | --- |\n")
+		for _, bump := range bumps {
+			changes := "—"
+			if bump.changelog != "" {
+				changes = fmt.Sprintf("[changelog](%s)", bump.changelog)
+			}
+			fmt.Fprintf(&b, "| `%s` (%s) | %s | %s | %s |\n", bump.name, bump.ecosystem, bump.from, bump.to, changes)
+		}
+	}
+	switch {
+	case len(ran) == 0:
+		b.WriteString("\n**Tests:** none found to run.\n")
+	case testErr != nil:
+		output := testErr.Error()
+		var failure *CheckFailure
+		if errors.As(testErr, &failure) {
+			output = failure.Output
+		}
+		fmt.Fprintf(&b, "\n**Tests failed** (%s); the update needs changes before it can be merged:\n\n```\n%s\n```\n",
+			strings.Join(ran, ", "), tail(output, verifyOutputLimit))
+	default:
+		fmt.Fprintf(&b, "\n**Tests passed:** %s.\n", strings.Join(ran, ", "))
+	}
+	if len(unsupported) > 0 {
+		fmt.Fprintf(&b, "\nNot updated: %s is not supported yet.\n", strings.Join(unsupported, ", "))
+	}
+	return b.String()
+}
@@ -0,0 +1,224 @@
+package executor
+
+import (
+	"context"
+	"errors"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"github.com/cexll/swe/internal/audit"
+	"github.com/cexll/swe/internal/github"
+	ghdata "github.com/cexll/swe/internal/github/data"
+	"github.com/cexll/swe/internal/provider"
+)
+
+const depsGoMod = `module example.com/app
+
+go 1.22
+
+require (
+	github.com/acme/lib v1.2.0
+	github.com/acme/api/v2 v2.0.1
+	golang.org/x/text v0.14.0
+	github.com/acme/tool v0.0.0-20240101000000-abcdef123456 // indirect
+)
+
+require github.com/acme/cli v1.0.0
+`
+
+// newDepsExecutor returns an executor running /code update-deps against a
+// remote whose main branch has go.mod, with the package manager commands
+// stubbed by commands.
+func newDepsExecutor(t *testing.T, commands func(workdir string, args []string) (string, error)) (e *Executor, remote string, updated *string, opened *[]string) {
+	t.Helper()
+	workdir, remote := initPushRepo(t)
+	if err := os.WriteFile(filepath.Join(workdir, "go.mod"), []byte(depsGoMod), 0o644); err != nil {
+		t.Fatal(err)
+	}
+	gitIn(t, workdir, "add", "go.mod")
+	gitIn(t, workdir, "commit", "-q", "-m", "add go.mod")
+	gitIn(t, workdir, "push", "-q", "origin", "main")
+
+	origClone, origRun, origInDir, origCreate, origAdd := cloneRepo, runCmd, runInDir, createPullRequest, addLabels
+	t.Cleanup(func() {
+		cloneRepo, runCmd, runInDir, createPullRequest, addLabels = origClone, origRun, origInDir, origCreate, origAdd
+	})
+	cloneRepo = func(_ context.Context, repo, branch, token string) (string, func(), error) {
+		dir := filepath.Join(t.TempDir(), "clone")
+		gitIn(t, filepath.Dir(dir), "clone", "-q", "--branch", branch, remote, dir)
+		gitIn(t, dir, "config", "user.email", "bot@example.com")
+		gitIn(t, dir, "config", "user.name", "bot")
+		return dir, func() {}, nil
+	}
+	runCmd = func(name string, args ...string) error {
+		if len(args) > 3 && args[2] == "remote" && args[3] == "set-url" {
+			return nil // keep the local remote
+		}
+		return run(name, args...)
+	}
+	runInDir = func(_ context.Context, dir, name string, args ...string) (string, error) {
+		return commands(dir, append([]string{name}, args...))
+	}
+	var pulls []string
+	createPullRequest = func(_, _, title, head, base, body, _ string) (int, string, error) {
+		pulls = append(pulls, title, head, base, body)
+		return 8, "https://github.com/owner/repo/pull/8", nil
+	}
+	addLabels = func(_, _ string, number int, labels []string, _ string) error {
+		if number != 8 || strings.Join(labels, ",") != depsLabel {
+			t.Errorf("labeled #%d with %v", number, labels)
+		}
+		return nil
+	}
+
+	e = New(&mockProvider{generateFunc: func(context.Context, *provider.CodeRequest) (*provider.CodeResponse, error) {
+		t.Error("the provider ran")
+		return &provider.CodeResponse{}, nil
+	}}, &mockAuthProvider{})
+	e.fetcher = &mockFetcher{fetchFunc: func(context.Context, *github.Context) (*ghdata.FetchResult, error) {
+		return &ghdata.FetchResult{ContextData: ghdata.Issue{Title: "Update the dependencies"}}, nil
+	}}
+	return e, remote, stubComments(t, ""), &pulls
+}
+
+func depsCtx() *github.Context {
+	ctx := buildTestCtx(false)
+	ctx.PreparedUpdateDeps = true
+	ctx.PreparedCommentID = 5
+	return ctx
+}
+
+// bumpGoMod is a `go get -u` that bumps lib, the major version module and
+// the indirect dependency.
+func bumpGoMod(workdir string, args []string) (string, error) {
+	if strings.Join(args, " ") != "go get -u ./..." {
+		return "", nil
+	}
+	data, _ := os.ReadFile(filepath.Join(workdir, "go.mod"))
+	mod := strings.NewReplacer("lib v1.2.0", "lib v1.3.1", "v2 v2.0.1", "v2 v2.1.0",
+		"v0.0.0-20240101000000-abcdef123456", "v0.0.0-20250101000000-123456abcdef").Replace(string(data))
+	return "", os.WriteFile(filepath.Join(workdir, "go.mod"), []byte(mod), 0o644)
+}
+
+func TestExecute_UpdateDepsOpensPullRequest(t *testing.T) {
+	var ran []string
+	e, remote, updated, opened := newDepsExecutor(t, func(workdir string, args []string) (string, error) {
+		ran = append(ran, strings.Join(args, " "))
+		return bumpGoMod(workdir, args)
+	})
+	log, _ := audit.New(audit.Config{})
+	e.SetAuditLog(log)
+
+	if err := e.Execute(context.Background(), depsCtx()); err != nil {
+		t.Fatalf("Execute: %v", err)
+	}
+	if strings.Join(ran, ", ") != "go get -u ./..., go mod tidy, go test ./..." {
+		t.Fatalf("ran %q", ran)
+	}
+	if len(*opened) != 4 || (*opened)[0] != "Update 2 dependencies" || !strings.HasPrefix((*opened)[1], "swe-agent/update-deps-") || (*opened)[2] != "main" {
+		t.Fatalf("opened = %q", *opened)
+	}
+	body := (*opened)[3]
+	for _, want := range []string{
+		"| `github.com/acme/lib` (Go modules) | v1.2.0 | v1.3.1 | [changelog](https://github.com/acme/lib/compare/v1.2.0...v1.3.1) |",
+		"| `github.com/acme/api/v2` (Go modules) | v2.0.1 | v2.1.0 | [changelog](https://github.com/acme/api/compare/v2.0.1...v2.1.0) |",
+		"**Tests passed:** `go test ./...`",
+	} {
+		if !strings.Contains(body, want) {
+			t.Fatalf("pull request body lacks %q:\n%s", want, body)
+		}
+	}
+	if strings.Contains(body, "acme/tool") {
+		t.Fatalf("indirect dependency listed:\n%s", body)
+	}
+
+	if msg := gitIn(t, remote, "log", "-1", "--format=%B", (*opened)[1]); !strings.Contains(msg, "- github.com/acme/lib v1.2.0 -> v1.3.1") {
+		t.Fatalf("commit message = %q", msg)
+	}
+	if !strings.HasPrefix(*updated, "### Update 2 dependencies") || !strings.Contains(*updated, "[#8]") {
+		t.Fatalf("tracking comment:\n%s", *updated)
+	}
+	if events := log.List(audit.Filter{Action: audit.ActionDepsUpdated}); len(events) != 1 || events[0].Branch != (*opened)[1] {
+		t.Fatalf("audit events = %+v", events)
+	}
+}
+
+func TestExecute_UpdateDepsReportsFailingTests(t *testing.T) {
+	e, _, updated, opened := newDepsExecutor(t, func(workdir string, args []string) (string, error) {
+		if args[1] == "test" {
+			return "--- FAIL: TestParse\nFAIL example.com/app", errors.New("exit status 1")
+		}
+		return bumpGoMod(workdir, args)
+	})
+
+	if err := e.Execute(context.Background(), depsCtx()); err != nil {
+		t.Fatalf("Execute: %v", err)
+	}
+	if len(*opened) != 4 || !strings.Contains((*opened)[3], "**Tests failed** (`go test ./...`)") || !strings.Contains((*opened)[3], "--- FAIL: TestParse") {
+		t.Fatalf("opened = %q", *opened)
+	}
+	if !strings.Contains(*updated, "The tests failed after the update") {
+		t.Fatalf("tracking comment:\n%s", *updated)
+	}
+}
+
+func TestExecute_UpdateDepsUpToDate(t *testing.T) {
+	e, remote, updated, opened := newDepsExecutor(t, func(string, []string) (string, error) { return "", nil })
+
+	if err := e.Execute(context.Background(), depsCtx()); err != nil {
+		t.Fatalf("Execute: %v", err)
+	}
+	if len(*opened) != 0 || !strings.HasPrefix(*updated, "### Dependencies are up to date") {
+		t.Fatalf("opened %q, tracking comment:\n%s", *opened, *updated)
+	}
+	if out := gitIn(t, remote, "for-each-ref", "refs/heads/swe-agent"); out != "" {
+		t.Fatalf("pushed %s", out)
+	}
+}
+
+func TestDetectEcosystems(t *testing.T) {
+	dir := t.TempDir()
+	write := func(name, content string) {
+		if err := os.WriteFile(filepath.Join(dir, name), []byte(content), 0o644); err != nil {
+			t.Fatal(err)
+		}
+	}
+	write("package.json", `{"dependencies": {"left-pad": "^1.0.0"}, "devDependencies": {"jest": "^29.0.0"}, "scripts": {"test": "jest"}}`)
+	write("package-lock.json", `{"packages": {"": {}, "node_modules/left-pad": {"version": "1.1.0"}, "node_modules/jest": {"version": "29.1.0"}, "node_modules/jest/node_modules/x": {"version": "1.0.0"}}}`)
+	if err := os.MkdirAll(filepath.Join(dir, "node_modules", "jest"), 0o755); err != nil {
+		t.Fatal(err)
+	}
+	write("node_modules/jest/package.json", `{"repository": {"type": "git", "url": "git+https://github.com/jestjs/jest.git"}}`)
+
+	found, unsupported := detectEcosystems(dir)
+	if len(found) != 1 || found[0].name != "npm" || strings.Join(found[0].test, " ") != "npm test" || len(unsupported) != 0 {
+		t.Fatalf("found %+v, unsupported %v", found, unsupported)
+	}
+	if v := found[0].versions(dir); len(v) != 2 || v["left-pad"] != "1.1.0" || v["jest"] != "29.1.0" {
+		t.Fatalf("versions = %v", v)
+	}
+	if link := found[0].changelog(dir, "jest", "29.1.0", "29.2.0"); link != "https://github.com/jestjs/jest/releases" {
+		t.Fatalf("changelog = %q", link)
+	}
+
+	write("yarn.lock", "")
+	if found, unsupported := detectEcosystems(dir); len(found) != 0 || strings.Join(unsupported, ",") != "yarn" {
+		t.Fatalf("found %+v, unsupported %v", found, unsupported)
+	}
+}
+
+func TestGoChangelog(t *testing.T) {
+	for _, tc := range []struct{ module, from, to, want string }{
+		{"github.com/acme/lib", "v1.0.0", "v1.1.0", "https://github.com/acme/lib/compare/v1.0.0...v1.1.0"},
+		{"github.com/acme/lib/v3", "v3.0.0+incompatible", "v3.1.0", "https://github.com/acme/lib/compare/v3.0.0...v3.1.0"},
+		{"github.com/acme/lib/sub", "v1.0.0", "v1.1.0", ""},
+		{"github.com/acme/lib", "v0.0.0-20240101000000-abcdef123456", "v0.1.0", ""},
+		{"golang.org/x/text", "v0.14.0", "v0.15.0", ""},
+	} {
+		if got := goChangelog("", tc.module, tc.from, tc.to); got != tc.want {
+			t.Errorf("goChangelog(%s, %s, %s) = %q", tc.module, tc.from, tc.to, got)
+		}
+	}
+}
@@ -0,0 +1,214 @@
+package executor
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"os"
+	"os/exec"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/cexll/swe/internal/github"
+	"github.com/cexll/swe/internal/github/comment"
+	"github.com/cexll/swe/internal/taskstore"
+)
+
+// dryRunTTL is how long a dry run's workspace is kept for the apply command.
+const dryRunTTL = 24 * time.Hour
+
+// maxDryRunWorkspaces bounds the workspaces kept; the oldest goes first.
+const maxDryRunWorkspaces = 16
+
+// dryRunPatchLines bounds the patch shown on the tracking comment.
+const dryRunPatchLines = 200
+
+// dryRun reports whether ctx's task is a dry run (/code --dry-run).
+func dryRun(ctx *github.Context) bool {
+	return ctx.PreparedApplyCommand != ""
+}
+
+// holdsPushes reports whether ctx's task may not push: a plan awaiting
+// approval or a dry run.
+func holdsPushes(ctx *github.Context) bool {
+	return planOnly(ctx) || dryRun(ctx)
+}
+
+// dryRunWorkspace is the checkout of a finished dry run, committed and ready
+// to push.
+type dryRunWorkspace struct {
+	dir     string
+	branch  string
+	base    string
+	remove  func()
+	expires time.Time
+}
+
+// dryRunWorkspaces keeps dry run checkouts by task ID until they are applied
+// or expire.
+type dryRunWorkspaces struct {
+	mu    sync.Mutex
+	saved map[string]dryRunWorkspace
+	now   func() time.Time // allow tests to stub the clock
+}
+
+func newDryRunWorkspaces() *dryRunWorkspaces {
+	return &dryRunWorkspaces{saved: make(map[string]dryRunWorkspace), now: time.Now}
+}
+
+// put keeps ws for taskID, removing expired workspaces and, beyond
+// maxDryRunWorkspaces, the oldest.
+func (d *dryRunWorkspaces) put(taskID string, ws dryRunWorkspace) {
+	d.mu.Lock()
+	defer d.mu.Unlock()
+	now := d.now()
+	for id, old := range d.saved {
+		if now.After(old.expires) {
+			old.remove()
+			delete(d.saved, id)
+		}
+	}
+	for len(d.saved) >= maxDryRunWorkspaces {
+		oldest := ""
+		for id, old := range d.saved {
+			if oldest == "" || old.expires.Before(d.saved[oldest].expires) {
+				oldest = id
+			}
+		}
+		d.saved[oldest].remove()
+		delete(d.saved, oldest)
+	}
+	ws.expires = now.Add(dryRunTTL)
+	d.saved[taskID] = ws
+}
+
+// take hands over the unexpired workspace of taskID; the caller removes it.
+func (d *dryRunWorkspaces) take(taskID string) (dryRunWorkspace, bool) {
+	d.mu.Lock()
+	defer d.mu.Unlock()
+	ws, ok := d.saved[taskID]
+	delete(d.saved, taskID)
+	if ok && d.now().After(ws.expires) {
+		ws.remove()
+		return dryRunWorkspace{}, false
+	}
+	return ws, ok
+}
+
+// dryRunPromptSection tells the provider its commits stay local until applied.
+func dryRunPromptSection(ctx *github.Context, branch string) string {
+	return fmt.Sprintf(`<dry_run>
+This is a dry run. Make and commit the changes as usual, but do not push, and do not create pull requests or issues; pushes are rejected.
+Your commits are shown for review, and pushed to %s as they are once someone comments %q.
+</dry_run>`, branch, ctx.PreparedApplyCommand)
+}
+
+// finishDryRun commits what the provider left uncommitted, saves the patch
+// for the apply command (or, without artifact storage, keeps the workspace)
+// and shows the diff on the tracking comment. It reports whether the
+// workspace was kept, in which case the caller must not remove it.
+func (e *Executor) finishDryRun(ctx context.Context, ghCtx *github.Context, workdir, branch, base, startSHA string, remove func()) bool {
+	if status, err := gitOutput(workdir, "status", "--porcelain"); err == nil && strings.TrimSpace(status) != "" {
+		if err := runCmd("git", "-C", workdir, "add", "-A"); err == nil {
+			if err := runCmd("git", "-C", workdir, "commit", "-q", "-m", "Changes from swe-agent dry run"); err != nil {
+				taskLog(ghCtx, "dry_run").Warn("Committing dry run changes failed", "err", err)
+			}
+		}
+	}
+	stat, diff, err := localDiff(workdir, startSHA)
+	if err != nil {
+		taskLog(ghCtx, "dry_run").Warn("Collecting dry run diff failed", "err", err)
+	}
+	if strings.TrimSpace(diff) == "" {
+		prependNotice(ghCtx, "> [!NOTE]\n> "+commentText(ghCtx, comment.MsgDryRunNoChanges))
+		return false
+	}
+
+	saved := e.saveDryRunPatch(ctx, ghCtx, workdir, branch, base, startSHA)
+	kept := !saved && ghCtx.TaskID != "" && e.dryRuns != nil
+	if kept {
+		e.dryRuns.put(ghCtx.TaskID, dryRunWorkspace{dir: workdir, branch: branch, base: base, remove: remove})
+	}
+	if e.store != nil && (saved || kept) {
+		how := "patch saved"
+		if kept {
+			how = "workspace kept"
+		}
+		e.store.AddLog(ghCtx.TaskID, "info", "Dry run finished, "+how+" for apply")
+	}
+
+	diff = redactSecrets(diff, e.secretRuleSet())
+	lines := strings.Split(strings.TrimRight(diff, "\n"), "\n")
+	more := ""
+	if len(lines) > dryRunPatchLines {
+		more = fmt.Sprintf("\n\n… %d more lines", len(lines)-dryRunPatchLines)
+		if e.wantsArtifacts(ghCtx) {
+			more += " in the task's diff artifact"
+		}
+		lines = lines[:dryRunPatchLines]
+	}
+	statLines := strings.Split(strings.TrimSpace(stat), "\n")
+	headline := "**Dry run: nothing was pushed.**"
+	if saved || kept {
+		headline += fmt.Sprintf(" Comment `%s` within %d hours to push these changes to `%s`.", ghCtx.PreparedApplyCommand, int(dryRunTTL/time.Hour), branch)
+	}
+	prependNotice(ghCtx, fmt.Sprintf("> [!NOTE]\n> %s\n\n<details><summary>Diff: %s</summary>\n\n```diff\n%s\n```%s\n</details>",
+		headline, strings.TrimSpace(statLines[len(statLines)-1]), strings.Join(lines, "\n"), more))
+	return kept
+}
+
+// applyDryRun pushes the changes of the dry run ghCtx applies, from its saved
+// workspace or by replaying its patch on a fresh clone, through the git
+// guard so the usual push checks run, then verifies them like any other
+// push.
+func (e *Executor) applyDryRun(ctx context.Context, ghCtx *github.Context, repo, token string) (string, error) {
+	ws, ok := e.dryRuns.take(ghCtx.PreparedApplyTaskID)
+	if !ok {
+		var err error
+		ws, err = e.replayDryRun(ctx, ghCtx, repo, token)
+		var conflict *replayConflictError
+		switch {
+		case errors.Is(err, errDryRunGone):
+			prependNotice(ghCtx, "> [!WARNING]\n> **Nothing was applied.** The dry run's changes are gone (they expired, nothing was changed, or the server restarted without artifact storage). Run the task again.")
+			return "", &NonRetryableError{msg: fmt.Sprintf("dry run %s: %v", ghCtx.PreparedApplyTaskID, err)}
+		case errors.As(err, &conflict):
+			files := "its changes"
+			if len(conflict.Files) > 0 {
+				files = "`" + strings.Join(conflict.Files, "`, `") + "`"
+			}
+			prependNotice(ghCtx, fmt.Sprintf("> [!WARNING]\n> **Nothing was applied.** `%s` changed since the dry run, and %s no longer apply cleanly. Run the task again.", conflict.Branch, files))
+			return "", &NonRetryableError{msg: err.Error()}
+		case err != nil:
+			return "", err
+		}
+	}
+	defer ws.remove()
+	ghCtx.PreparedBranch = ws.branch
+
+	remoteURL := fmt.Sprintf("https://x-access-token:%s@github.com/%s.git", token, repo)
+	if err := runCmd("git", "-C", ws.dir, "remote", "set-url", "origin", remoteURL); err != nil {
+		return "", fmt.Errorf("configure git remote with token: %w", err)
+	}
+	guard, err := installGitGuard(e.secretRuleSet(), e.blockedPaths)
+	if err != nil {
+		return "", err
+	}
+	defer guard.remove()
+
+	e.phase(ghCtx, taskstore.PhasePush)
+	before := fetchRemoteHead(ws.dir, ws.branch)
+	cmd := exec.CommandContext(ctx, "git", "-C", ws.dir, "push", "origin", "HEAD:refs/heads/"+ws.branch)
+	cmd.Env = append(os.Environ(), guard.env()...)
+	out, pushErr := cmd.CombinedOutput()
+	e.recordBlockedGit(ghCtx, guard)
+	e.reportSecrets(ghCtx, guard)
+	e.reportBlockedPaths(ghCtx, guard)
+	if pushErr != nil {
+		msg := strings.ReplaceAll(strings.TrimSpace(string(out)), token, "***")
+		return "", errors.New("push dry run changes: " + msg)
+	}
+	e.recordPushedBranch(ghCtx, ws.dir)
+	prependNotice(ghCtx, "> [!NOTE]\n> "+commentText(ghCtx, comment.MsgDryRunApplied, ws.branch))
+	return fmt.Sprintf("Applied dry run %s to %s", ghCtx.PreparedApplyTaskID, ws.branch), e.verifyPushed(ctx, ghCtx, ws.dir, ws.branch, ws.base, before)
+}
@@ -0,0 +1,90 @@
+package executor
+
+import (
+	"context"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/cexll/swe/internal/taskstore"
+)
+
+func TestExecute_DryRunThenApply(t *testing.T) {
+	workdir, remote := initPushRepo(t)
+	updated := stubComments(t, "Rewrote the README.")
+	e, prompt, pushed := approvalExecutor(t, workdir)
+	store := taskstore.NewStore()
+	store.Create(&taskstore.Task{ID: "task-1"})
+	store.Create(&taskstore.Task{ID: "task-2"})
+	e.SetTaskStore(store)
+
+	ctx := buildTestCtx(false)
+	ctx.TaskID = "task-1"
+	ctx.PreparedCommentID = 7
+	ctx.PreparedApplyCommand = "/code apply"
+	if err := e.Execute(context.Background(), ctx); err != nil {
+		t.Fatalf("Execute: %v", err)
+	}
+
+	if !strings.Contains(*prompt, "<dry_run>") || !strings.Contains(*prompt, `"push": false`) {
+		t.Fatalf("prompt lacks the dry run instructions:\n%s", *prompt)
+	}
+	if !strings.Contains(*pushed, "pushes are not allowed in this task (dry run)") {
+		t.Fatalf("push should be held: %q", *pushed)
+	}
+	if out := gitIn(t, remote, "branch", "--list", "swe-agent/*"); out != "" {
+		t.Fatalf("remote gained branches: %q", out)
+	}
+	if !strings.Contains(*updated, "**Dry run: nothing was pushed.** Comment `/code apply` within 24 hours") ||
+		!strings.Contains(*updated, "<details><summary>Diff: 1 file changed") || !strings.Contains(*updated, "+agent change") {
+		t.Fatalf("tracking comment = %q", *updated)
+	}
+
+	apply := buildTestCtx(false)
+	apply.TaskID = "task-2"
+	apply.PreparedCommentID = 7
+	apply.PreparedApplyTaskID = "task-1"
+	if err := e.Execute(context.Background(), apply); err != nil {
+		t.Fatalf("apply: %v", err)
+	}
+	if apply.PreparedBranch != ctx.PreparedBranch || gitIn(t, remote, "show", apply.PreparedBranch+":README.md") != "agent change" {
+		t.Fatalf("dry run was not applied to %q", ctx.PreparedBranch)
+	}
+	if !strings.Contains(*updated, "Dry run applied: the changes were pushed to `"+ctx.PreparedBranch+"`.") {
+		t.Fatalf("tracking comment = %q", *updated)
+	}
+
+	if err := e.Execute(context.Background(), apply); err == nil || !strings.Contains(*updated, "**Nothing was applied.**") {
+		t.Fatalf("second apply = %v, comment %q", err, *updated)
+	}
+}
+
+func TestDryRunWorkspaces_ExpireAndEvict(t *testing.T) {
+	d := newDryRunWorkspaces()
+	now := time.Now()
+	d.now = func() time.Time { return now }
+	removed := map[string]bool{}
+	ws := func(id string) dryRunWorkspace {
+		return dryRunWorkspace{dir: id, remove: func() { removed[id] = true }}
+	}
+
+	d.put("old", ws("old"))
+	now = now.Add(dryRunTTL + time.Minute)
+	if _, ok := d.take("old"); ok || !removed["old"] {
+		t.Fatal("an expired workspace should be removed, not handed over")
+	}
+
+	for i := 0; i <= maxDryRunWorkspaces; i++ {
+		now = now.Add(time.Second)
+		d.put(string(rune('a'+i)), ws(string(rune('a'+i))))
+	}
+	if !removed["a"] || removed["b"] || len(d.saved) != maxDryRunWorkspaces {
+		t.Fatalf("the oldest workspace should be evicted: removed %v, kept %d", removed, len(d.saved))
+	}
+	if got, ok := d.take("b"); !ok || got.dir != "b" {
+		t.Fatalf("take(b) = %+v, %t", got, ok)
+	}
+	if _, ok := d.take("b"); ok {
+		t.Fatal("a workspace can be taken only once")
+	}
+}
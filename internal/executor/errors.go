@@ -1,6 +1,9 @@
 package executor
 
-import "errors"
+import (
+	"errors"
+	"fmt"
+)
 
 // NonRetryableError marks task failures that should not be retried by the dispatcher.
 type NonRetryableError struct {
@@ -18,5 +21,29 @@ func IsNonRetryable(err error) bool {
 	}
 
 	var target *NonRetryableError
-	return errors.As(err, &target)
+	var withdrawn *WithdrawnError
+	return errors.As(err, &target) || errors.As(err, &withdrawn)
+}
+
+// ProviderError marks failures returned by the AI provider so callers can
+// attribute errors to the provider (e.g., for error-rate metrics).
+type ProviderError struct {
+	Provider string
+	Err      error
+}
+
+func (e *ProviderError) Error() string {
+	return fmt.Sprintf("provider %s: %v", e.Provider, e.Err)
+}
+
+func (e *ProviderError) Unwrap() error { return e.Err }
+
+// ProviderName returns the provider responsible for err, or "" when the
+// error did not originate from a provider call.
+func ProviderName(err error) string {
+	var target *ProviderError
+	if errors.As(err, &target) {
+		return target.Provider
+	}
+	return ""
 }
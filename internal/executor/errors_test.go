@@ -34,3 +34,21 @@ func TestIsNonRetryable(t *testing.T) {
 		}
 	})
 }
+
+func TestProviderError(t *testing.T) {
+	inner := errors.New("rate limited")
+	err := fmt.Errorf("outer: %w", &ProviderError{Provider: "claude", Err: inner})
+
+	if got := ProviderName(err); got != "claude" {
+		t.Fatalf("ProviderName = %q, want claude", got)
+	}
+	if !errors.Is(err, inner) {
+		t.Fatal("ProviderError should unwrap to the inner error")
+	}
+	if got := err.Error(); got != "outer: provider claude: rate limited" {
+		t.Fatalf("Error() = %q", got)
+	}
+	if ProviderName(errors.New("boom")) != "" {
+		t.Fatal("non-provider errors should not report a provider")
+	}
+}
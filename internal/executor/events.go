@@ -0,0 +1,125 @@
+package executor
+
+import (
+	"context"
+	"fmt"
+	"strings"
+	"sync/atomic"
+
+	"github.com/cexll/swe/internal/artifacts"
+	"github.com/cexll/swe/internal/github"
+	"github.com/cexll/swe/internal/provider"
+	"github.com/cexll/swe/internal/taskstore"
+)
+
+// maxEventMessage caps the text kept for one provider event in the task log.
+const maxEventMessage = 2000
+
+// SetTaskStore records each task's status, phases and provider events (tool
+// calls, commands, messages) in its task log (nil records none).
+func (e *Executor) SetTaskStore(s *taskstore.Store) {
+	e.store = s
+}
+
+// SetLogStorage keeps the full text of task log messages too long for the
+// task log in s, linked from the shortened entry (nil drops the rest).
+func (e *Executor) SetLogStorage(s *artifacts.Store) {
+	e.logs = s
+}
+
+// fitMessage shortens msg to maxEventMessage for ctx's task log. The full
+// text of a longer message is saved in log storage as name, which is
+// returned for the entry's Offloaded field ("" when nothing was saved).
+func (e *Executor) fitMessage(ctx *github.Context, name, msg string) (string, string) {
+	if len(msg) <= maxEventMessage {
+		return msg, ""
+	}
+	short := strings.ToValidUTF8(msg[:maxEventMessage], "") + "..."
+	if e.logs == nil {
+		return short, ""
+	}
+	if err := e.logs.Save(context.Background(), ctx.TaskID, name, []byte(msg)); err != nil {
+		taskLog(ctx, "logs").Warn("Offloading event message failed", "name", name, "err", err)
+		return short, ""
+	}
+	return short, name
+}
+
+// taskEvents returns the Events callback that logs ctx's provider events to
+// the task store, or nil when there is nowhere to log them. Commands and
+// messages may echo tokens, so they are redacted first.
+func (e *Executor) taskEvents(ctx *github.Context) func(provider.Event) {
+	if e.store == nil || ctx.TaskID == "" {
+		return nil
+	}
+	rules := e.secretRuleSet()
+	var seq atomic.Int64
+	return func(ev provider.Event) {
+		msg := redactSecrets(ev.Message, rules)
+		if ctx.Token != "" {
+			msg = strings.ReplaceAll(msg, ctx.Token, "***")
+		}
+		msg, offloaded := e.fitMessage(ctx, fmt.Sprintf("event-%d.log", seq.Add(1)), msg)
+		e.store.AddEntry(ctx.TaskID, taskstore.LogEntry{
+			Timestamp: ev.Time,
+			Level:     ev.Level,
+			Message:   msg,
+			Type:      ev.Type,
+			Tool:      ev.Tool,
+			Duration:  ev.Duration,
+			Offloaded: offloaded,
+		})
+	}
+}
+
+// phase records that ctx's task entered phase (a taskstore.Phase* name), for
+// the task timeline.
+func (e *Executor) phase(ctx *github.Context, phase string) {
+	if e.store == nil || ctx.TaskID == "" {
+		return
+	}
+	e.store.AddEntry(ctx.TaskID, taskstore.LogEntry{Level: "info", Type: taskstore.TypePhase, Message: phase})
+}
+
+// startTask marks ctx's task running; its first phase authenticates and
+// fetches the issue or pull request.
+func (e *Executor) startTask(ctx *github.Context) {
+	if e.store == nil || ctx.TaskID == "" {
+		return
+	}
+	e.store.UpdateStatus(ctx.TaskID, taskstore.StatusRunning)
+	e.phase(ctx, taskstore.PhaseFetch)
+}
+
+// awaitTask puts ctx's task back to pending once it stopped for its cost to
+// be confirmed.
+func (e *Executor) awaitTask(ctx *github.Context) {
+	if e.store == nil || ctx.TaskID == "" {
+		return
+	}
+	e.store.UpdateStatus(ctx.TaskID, taskstore.StatusPending)
+}
+
+// finishTask ends ctx's task run in the store: the timeline's done phase,
+// the error if any, and the final status.
+func (e *Executor) finishTask(ctx *github.Context, err error) {
+	if e.store == nil || ctx.TaskID == "" {
+		return
+	}
+	level, status := "success", taskstore.StatusCompleted
+	if err != nil {
+		level, status = "error", taskstore.StatusFailed
+		msg := err.Error()
+		if ctx.Token != "" {
+			msg = strings.ReplaceAll(msg, ctx.Token, "***")
+		}
+		entry := taskstore.LogEntry{Level: "error", Message: msg}
+		if e.logs != nil {
+			// the full error (e.g. test output) moves to log storage
+			entry.Message, entry.Offloaded = e.fitMessage(ctx, "error.log", msg)
+		}
+		e.store.AddEntry(ctx.TaskID, entry)
+	}
+	e.store.AddEntry(ctx.TaskID, taskstore.LogEntry{Level: level, Type: taskstore.TypePhase, Message: taskstore.PhaseDone})
+	e.store.UpdateStatus(ctx.TaskID, status)
+}
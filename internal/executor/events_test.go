@@ -0,0 +1,149 @@
+package executor
+
+import (
+	"context"
+	"errors"
+	"io"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/cexll/swe/internal/artifacts"
+	"github.com/cexll/swe/internal/github"
+	ghdata "github.com/cexll/swe/internal/github/data"
+	"github.com/cexll/swe/internal/provider"
+	"github.com/cexll/swe/internal/storage"
+	"github.com/cexll/swe/internal/taskstore"
+)
+
+func TestExecute_LogsProviderEvents(t *testing.T) {
+	origClone, origRun := cloneRepo, runCmd
+	t.Cleanup(func() { cloneRepo, runCmd = origClone, origRun })
+	cloneRepo = func(_ context.Context, repo, branch, token string) (string, func(), error) {
+		return t.TempDir(), func() {}, nil
+	}
+	runCmd = func(name string, args ...string) error { return nil }
+
+	store := taskstore.NewStore()
+	store.Create(&taskstore.Task{ID: "task-1"})
+	e := New(&mockProvider{generateFunc: func(_ context.Context, req *provider.CodeRequest) (*provider.CodeResponse, error) {
+		if req.Events == nil {
+			t.Fatal("no Events callback for a stored task")
+		}
+		req.Events(provider.Event{Level: "info", Type: provider.EventCommand, Tool: "curl", Message: "curl -H 'Authorization: token test-token' api", Duration: time.Second})
+		return &provider.CodeResponse{Summary: "ok"}, nil
+	}}, &mockAuthProvider{})
+	e.fetcher = &mockFetcher{fetchFunc: func(context.Context, *github.Context) (*ghdata.FetchResult, error) {
+		return &ghdata.FetchResult{ContextData: ghdata.Issue{Title: "t", Author: ghdata.Author{Login: "u"}}}, nil
+	}}
+	e.SetTaskStore(store)
+
+	ctx := buildTestCtx(false)
+	ctx.TaskID = "task-1"
+	ctx.Token = "test-token"
+	if err := e.Execute(context.Background(), ctx); err != nil {
+		t.Fatalf("Execute: %v", err)
+	}
+	task, _ := store.Get("task-1")
+	var phases []string
+	var entry taskstore.LogEntry
+	for _, l := range task.Logs {
+		if l.Type == taskstore.TypePhase {
+			phases = append(phases, l.Message)
+		} else if l.Type == provider.EventCommand {
+			entry = l
+		}
+	}
+	want := []string{taskstore.PhaseFetch, taskstore.PhaseClone, taskstore.PhaseProvider, taskstore.PhasePush, taskstore.PhaseDone}
+	if strings.Join(phases, ",") != strings.Join(want, ",") {
+		t.Fatalf("phases = %q, want %q", phases, want)
+	}
+	if task.Status != taskstore.StatusCompleted {
+		t.Fatalf("status = %s, want completed", task.Status)
+	}
+	if entry.Type != provider.EventCommand || entry.Tool != "curl" || entry.Duration != time.Second || entry.Timestamp.IsZero() {
+		t.Fatalf("entry = %+v", entry)
+	}
+	if entry.Message != "curl -H 'Authorization: token ***' api" {
+		t.Fatalf("message not redacted: %q", entry.Message)
+	}
+}
+
+func TestTaskEvents_NeedsStoreAndTaskID(t *testing.T) {
+	e := &Executor{}
+	if e.taskEvents(&github.Context{TaskID: "task-1"}) != nil {
+		t.Fatal("no store, no callback")
+	}
+	e.SetTaskStore(taskstore.NewStore())
+	if e.taskEvents(&github.Context{}) != nil {
+		t.Fatal("no task ID, no callback")
+	}
+}
+
+func TestExecute_FailureEndsTaskRun(t *testing.T) {
+	store := taskstore.NewStore()
+	store.Create(&taskstore.Task{ID: "task-1"})
+	e := New(&mockProvider{}, &mockAuthProvider{})
+	e.fetcher = &mockFetcher{fetchFunc: func(context.Context, *github.Context) (*ghdata.FetchResult, error) {
+		return nil, errors.New("graphql: rate limited")
+	}}
+	e.SetTaskStore(store)
+
+	ctx := buildTestCtx(false)
+	ctx.TaskID = "task-1"
+	if err := e.Execute(context.Background(), ctx); err == nil {
+		t.Fatal("Execute should fail")
+	}
+	task, _ := store.Get("task-1")
+	if task.Status != taskstore.StatusFailed {
+		t.Fatalf("status = %s, want failed", task.Status)
+	}
+	tl, _ := store.Timeline("task-1", time.Now())
+	last := tl.Phases[len(tl.Phases)-1]
+	if last.Name != taskstore.PhaseFetch || !last.Failed || last.Running {
+		t.Fatalf("last phase = %+v", last)
+	}
+	if n := len(last.Steps); n != 1 || !strings.Contains(last.Steps[0].Message, "rate limited") {
+		t.Fatalf("steps of the failed phase = %+v", last.Steps)
+	}
+}
+
+func TestTaskEvents_OffloadsLongMessages(t *testing.T) {
+	store := taskstore.NewStore()
+	store.Create(&taskstore.Task{ID: "task-1"})
+	logs := artifacts.New(&storage.Local{Dir: t.TempDir()}, 0)
+	e := &Executor{}
+	e.SetTaskStore(store)
+	ctx := &github.Context{TaskID: "task-1", Token: "test-token"}
+	long := strings.Repeat("x", maxEventMessage) + " test-token tail"
+
+	e.taskEvents(ctx)(provider.Event{Level: "info", Type: provider.EventCommand, Message: long})
+	e.SetLogStorage(logs)
+	events := e.taskEvents(ctx)
+	events(provider.Event{Level: "info", Type: provider.EventCommand, Message: "short"})
+	events(provider.Event{Level: "info", Type: provider.EventCommand, Message: long})
+
+	task, _ := store.Get("task-1")
+	if len(task.Logs) != 3 {
+		t.Fatalf("logs = %+v", task.Logs)
+	}
+	if l := task.Logs[0]; l.Offloaded != "" || len(l.Message) != maxEventMessage+3 {
+		t.Fatalf("without log storage the message is only cut: %+v", l)
+	}
+	if task.Logs[1].Offloaded != "" {
+		t.Fatalf("short message offloaded: %+v", task.Logs[1])
+	}
+	l := task.Logs[2]
+	if l.Offloaded != "event-2.log" || !strings.HasSuffix(l.Message, "...") {
+		t.Fatalf("entry = %+v", l)
+	}
+	rc, err := logs.Open(context.Background(), "task-1", l.Offloaded)
+	if err != nil {
+		t.Fatal(err)
+	}
+	full, _ := io.ReadAll(rc)
+	_ = rc.Close()
+	if !strings.HasSuffix(string(full), " *** tail") || len(full) != maxEventMessage+len(" *** tail") {
+		t.Fatalf("offloaded text = %q...", full[len(full)-20:])
+	}
+}
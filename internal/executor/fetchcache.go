@@ -0,0 +1,26 @@
+package executor
+
+import "time"
+
+// fetchCacher is a fetcher that reuses data fetched for an unchanged issue
+// or pull request.
+type fetchCacher interface {
+	SetCache(ttl time.Duration)
+	Invalidate(repo string, number int)
+}
+
+// SetFetchCache keeps GitHub data fetched for tasks for up to ttl, reused
+// while the issue or pull request is unchanged (ttl <= 0 disables it).
+func (e *Executor) SetFetchCache(ttl time.Duration) {
+	if c, ok := e.fetcher.(fetchCacher); ok {
+		c.SetCache(ttl)
+	}
+}
+
+// InvalidateFetch drops the data cached for issue or pull request number
+// of repo ("owner/repo"), so the next task on it fetches everything again.
+func (e *Executor) InvalidateFetch(repo string, number int) {
+	if c, ok := e.fetcher.(fetchCacher); ok {
+		c.Invalidate(repo, number)
+	}
+}
@@ -0,0 +1,221 @@
+package executor
+
+import (
+	"bufio"
+	"fmt"
+	"os"
+	"os/exec"
+	"path/filepath"
+	"strings"
+
+	"github.com/cexll/swe/internal/audit"
+	"github.com/cexll/swe/internal/github"
+)
+
+// gitGuard keeps the provider from force pushing, rewriting pushed history or
+// deleting remote refs. It puts a git wrapper first on the provider's PATH
+// and, through environment config that outranks the repository's, a
+// pre-push hook that rejects deletions and non-fast-forward updates however
+// the push was spelled. The hook also rejects pushed commits that change
+// blocked paths or contain secrets, and pushes the policy's push rules deny
+// or hold. Blocked attempts are appended to a log the executor turns into
+// audit events.
+type gitGuard struct {
+	dir  string
+	log  string
+	hold string // while this file exists every push is refused
+}
+
+// installGitGuard writes the wrapper, hook, secret rules and blocked paths
+// into a new temporary directory.
+func installGitGuard(rules []SecretRule, blockedPaths []string) (*gitGuard, error) {
+	realGit, err := exec.LookPath("git")
+	if err != nil {
+		return nil, fmt.Errorf("git guard: %w", err)
+	}
+	self, err := os.Executable()
+	if err != nil {
+		return nil, fmt.Errorf("git guard: %w", err)
+	}
+	dir, err := os.MkdirTemp("", "swe-git-guard-")
+	if err != nil {
+		return nil, fmt.Errorf("git guard: %w", err)
+	}
+	g := &gitGuard{dir: dir, log: filepath.Join(dir, "blocked.log"), hold: filepath.Join(dir, "hold")}
+	ruleFile, pathsFile := filepath.Join(dir, "secret-rules.tsv"), filepath.Join(dir, "blocked-paths")
+	if err := writeRuleFile(ruleFile, rules); err != nil {
+		g.remove()
+		return nil, fmt.Errorf("git guard: %w", err)
+	}
+	if err := writeLines(pathsFile, blockedPaths); err != nil {
+		g.remove()
+		return nil, fmt.Errorf("git guard: %w", err)
+	}
+	check := strings.Join([]string{shellPath(self), PushCheckCommand, shellPath(ruleFile), shellPath(pathsFile), shellPath(g.log),
+		shellPath(filepath.Join(dir, policyFileName)), shellPath(filepath.Join(dir, policyInputName)), shellPath(filepath.Join(dir, policyDecisionName))}, " ")
+	wrapper := filepath.Join(dir, "bin", "git")
+	files := map[string]string{
+		wrapper:                                 fmt.Sprintf(gitWrapperScript, shellPath(realGit), shellPath(g.log)),
+		filepath.Join(dir, "hooks", "pre-push"): fmt.Sprintf(guardPrePushScript, shellPath(g.log), shellPath(g.hold), check),
+	}
+	for path, shim := range gitWrapperShims(wrapper) {
+		files[path] = shim
+	}
+	for path, script := range files {
+		if err := os.MkdirAll(filepath.Dir(path), 0o755); err != nil {
+			g.remove()
+			return nil, fmt.Errorf("git guard: %w", err)
+		}
+		if err := os.WriteFile(path, []byte(script), 0o755); err != nil {
+			g.remove()
+			return nil, fmt.Errorf("git guard: %w", err)
+		}
+	}
+	return g, nil
+}
+
+// env returns the provider environment that activates the guard.
+func (g *gitGuard) env() []string {
+	return []string{
+		"PATH=" + filepath.Join(g.dir, "bin") + string(os.PathListSeparator) + os.Getenv("PATH"),
+		"GIT_CONFIG_COUNT=1",
+		"GIT_CONFIG_KEY_0=core.hooksPath",
+		"GIT_CONFIG_VALUE_0=" + filepath.Join(g.dir, "hooks"),
+	}
+}
+
+// blocked returns the attempts the guard refused, one "reason: command" per entry.
+func (g *gitGuard) blocked() []string {
+	f, err := os.Open(g.log)
+	if err != nil {
+		return nil
+	}
+	defer func() { _ = f.Close() }()
+	var out []string
+	sc := bufio.NewScanner(f)
+	for sc.Scan() {
+		if reason, cmd, ok := strings.Cut(sc.Text(), "\t"); ok {
+			out = append(out, reason+": "+cmd)
+		}
+	}
+	return out
+}
+
+// holdPushes makes the guard refuse every push, logging reason as the cause.
+func (g *gitGuard) holdPushes(reason string) error {
+	if err := os.WriteFile(g.hold, []byte(reason+"\n"), 0o644); err != nil {
+		return fmt.Errorf("git guard: %w", err)
+	}
+	return nil
+}
+
+func (g *gitGuard) remove() { _ = os.RemoveAll(g.dir) }
+
+// recordBlockedGit records a git_blocked audit event per refused attempt.
+func (e *Executor) recordBlockedGit(ctx *github.Context, g *gitGuard) {
+	for _, attempt := range g.blocked() {
+		if ctx.Token != "" {
+			attempt = strings.ReplaceAll(attempt, ctx.Token, "***")
+		}
+		taskLog(ctx, "git_guard").Warn("Blocked git command", "command", attempt)
+		ev := e.auditEvent(ctx, audit.ActionGitBlocked)
+		ev.Decision = audit.DecisionDenied
+		ev.Detail = attempt
+		e.recordAudit(ev)
+	}
+}
+
+// gitWrapperScript refuses destructive git invocations and runs the real git
+// for everything else. Arguments: real git path, blocked log path.
+const gitWrapperScript = `#!/bin/sh
+# Installed by swe-agent: force pushes, history rewrites and remote deletions are not allowed.
+real=%s
+log=%s
+block() {
+	printf '%%s\tgit %%s\n' "$1" "$args" >> "$log"
+	echo "swe-agent: blocked git command ($1). Push new commits to a branch instead." >&2
+	exit 1
+}
+args="$*"
+sub=""
+expect_value=""
+for arg in "$@"; do
+	if [ -n "$expect_value" ]; then
+		case "$expect_value:$(printf '%%s' "$arg" | tr 'A-Z' 'a-z')" in
+		config:core.hookspath*) block "override git hooks" ;;
+		esac
+		expect_value=""
+		continue
+	fi
+	if [ -z "$sub" ]; then
+		case "$arg" in
+		-c) expect_value=config ;;
+		-C|--git-dir|--work-tree|--namespace|--exec-path|--config-env) expect_value=other ;;
+		--config-env=*|-c*) block "override git config" ;;
+		-*) ;;
+		*) sub="$arg" ;;
+		esac
+		continue
+	fi
+	case "$sub:$arg" in
+	push:--force|push:--force=*|push:--force-with-lease*|push:--force-if-includes|push:+*)
+		block "force push" ;;
+	push:--delete|push:--prune|push:--mirror|push::*)
+		block "delete remote refs" ;;
+	push:--no-verify)
+		block "skip push checks" ;;
+	push:--*) ;;
+	push:-*f*) block "force push" ;;
+	push:-*d*) block "delete remote refs" ;;
+	config:*)
+		case "$(printf '%%s' "$arg" | tr 'A-Z' 'a-z')" in
+		core.hookspath*) block "override git hooks" ;;
+		esac ;;
+	esac
+done
+case "$sub" in
+filter-branch|filter-repo) block "rewrite history" ;;
+esac
+exec "$real" "$@"
+`
+
+// guardPrePushScript rejects every push while pushes are held, deletions
+// and non-fast-forward updates of any remote ref and pushes the push check
+// rejects, then runs the repository's own pre-push hook. Arguments: blocked
+// log path, hold file path, push check command.
+const guardPrePushScript = `#!/bin/sh
+# Installed by swe-agent: pushes may only fast-forward remote refs.
+log=%s
+hold=%s
+if [ -f "$hold" ]; then
+	reason=$(cat "$hold")
+	printf '%%s\tgit push %%s\n' "$reason" "$1" >> "$log"
+	echo "swe-agent: pushes are not allowed in this task ($reason)" >&2
+	exit 1
+fi
+input=$(cat)
+printf '%%s\n' "$input" | while read -r local_ref local_sha remote_ref remote_sha; do
+	[ -n "$remote_ref" ] || continue
+	case "$local_sha" in
+	*[!0]*) ;;
+	*)
+		printf 'delete remote refs\tgit push (delete %%s)\n' "$remote_ref" >> "$log"
+		echo "swe-agent: deleting $remote_ref is not allowed" >&2
+		exit 1 ;;
+	esac
+	case "$remote_sha" in
+	*[!0]*)
+		if ! git merge-base --is-ancestor "$remote_sha" "$local_sha" 2>/dev/null; then
+			printf 'force push\tgit push (non-fast-forward update of %%s)\n' "$remote_ref" >> "$log"
+			echo "swe-agent: $remote_ref would lose commits; push new commits on top instead" >&2
+			exit 1
+		fi ;;
+	esac
+done || exit 1
+printf '%%s\n' "$input" | %s || exit 1
+hook="$(git rev-parse --absolute-git-dir)/hooks/pre-push"
+if [ -x "$hook" ]; then
+	printf '%%s\n' "$input" | "$hook" "$@" || exit 1
+fi
+exit 0
+`
@@ -0,0 +1,161 @@
+package executor
+
+import (
+	"context"
+	"os"
+	"os/exec"
+	"strings"
+	"testing"
+
+	"github.com/cexll/swe/internal/audit"
+	"github.com/cexll/swe/internal/github"
+	ghdata "github.com/cexll/swe/internal/github/data"
+	"github.com/cexll/swe/internal/provider"
+)
+
+// guardedGit runs git in workdir the way the provider would: name is "git"
+// (resolved through the guarded PATH) or an absolute path to the real git.
+func guardedGit(t *testing.T, g *gitGuard, workdir, name string, args ...string) (string, error) {
+	t.Helper()
+	env := append(os.Environ(), g.env()...)
+	path := name
+	if name == "git" {
+		for _, kv := range g.env() {
+			if p, ok := strings.CutPrefix(kv, "PATH="); ok {
+				path = strings.Split(p, string(os.PathListSeparator))[0] + "/git"
+			}
+		}
+	}
+	cmd := exec.Command(path, append([]string{"-C", workdir}, args...)...)
+	cmd.Env = env
+	out, err := cmd.CombinedOutput()
+	return string(out), err
+}
+
+func TestGitGuard(t *testing.T) {
+	workdir, remote := initPushRepo(t)
+	realGit, err := exec.LookPath("git")
+	if err != nil {
+		t.Skip("git not installed")
+	}
+	commitAndPush(t, workdir, "feature", "feature\n")
+	mainHead := gitIn(t, remote, "rev-parse", "main")
+
+	g, err := installGitGuard(DefaultSecretRules, nil)
+	if err != nil {
+		t.Fatalf("installGitGuard: %v", err)
+	}
+	t.Cleanup(g.remove)
+
+	gitIn(t, workdir, "commit", "-q", "--amend", "-m", "rewritten")
+	for _, tc := range []struct {
+		name string
+		args []string
+		want string
+	}{
+		{"git", []string{"push", "--force", "origin", "HEAD:feature"}, "force push"},
+		{"git", []string{"push", "-uf", "origin", "HEAD:feature"}, "force push"},
+		{"git", []string{"push", "origin", "+HEAD:feature"}, "force push"},
+		{"git", []string{"push", "origin", ":feature"}, "delete remote refs"},
+		{"git", []string{"push", "--no-verify", "origin", "HEAD:feature"}, "skip push checks"},
+		{"git", []string{"-c", "core.hooksPath=/dev/null", "push", "origin", "HEAD:feature"}, "override git hooks"},
+		{"git", []string{"config", "core.hooksPath", "/dev/null"}, "override git hooks"},
+		{"git", []string{"filter-branch", "HEAD"}, "rewrite history"},
+		// the real git still runs the guard's pre-push hook
+		{realGit, []string{"push", "-f", "origin", "HEAD:feature"}, "would lose commits"},
+		{realGit, []string{"push", "origin", "--delete", "main"}, "deleting refs/heads/main is not allowed"},
+	} {
+		out, err := guardedGit(t, g, workdir, tc.name, tc.args...)
+		if err == nil || !strings.Contains(out, tc.want) {
+			t.Errorf("%s %v: expected %q rejection, got %v\n%s", tc.name, tc.args, tc.want, err, out)
+		}
+	}
+	if gitIn(t, remote, "rev-parse", "main") != mainHead || gitIn(t, remote, "branch", "--list", "feature") == "" {
+		t.Fatal("remote refs changed")
+	}
+
+	// ordinary pushes go through, and the repository's own hook still runs
+	if out, err := guardedGit(t, g, workdir, "git", "push", "-q", "origin", "HEAD:refs/heads/swe-agent/1-1"); err != nil {
+		t.Fatalf("plain push: %v\n%s", err, out)
+	}
+	if err := installPushGuard(workdir, []string{"main"}, "swe-agent/1-1"); err != nil {
+		t.Fatal(err)
+	}
+	gitIn(t, workdir, "reset", "-q", "--hard", "origin/main")
+	gitIn(t, workdir, "commit", "-q", "--allow-empty", "-m", "on main")
+	if out, err := guardedGit(t, g, workdir, "git", "push", "origin", "HEAD:main"); err == nil || !strings.Contains(out, "push to swe-agent/1-1 instead") {
+		t.Fatalf("protected branch hook should still run: %v\n%s", err, out)
+	}
+
+	log, _ := audit.New(audit.Config{})
+	e := New(&mockProvider{}, &mockAuthProvider{})
+	e.SetAuditLog(log)
+	e.recordBlockedGit(buildTestCtx(false), g)
+	events := log.List(audit.Filter{Action: audit.ActionGitBlocked})
+	if len(events) != 10 || !strings.HasSuffix(events[9].Detail, "push --force origin HEAD:feature") || events[9].Decision != audit.DecisionDenied ||
+		events[0].Detail != "delete remote refs: git push (delete refs/heads/main)" {
+		t.Fatalf("audit events (%d) = %+v", len(events), events)
+	}
+}
+
+func TestExecute_ProviderRunsBehindGitGuard(t *testing.T) {
+	origClone, origRun := cloneRepo, runCmd
+	t.Cleanup(func() { cloneRepo, runCmd = origClone, origRun })
+	cloneRepo = func(_ context.Context, repo, branch, token string) (string, func(), error) {
+		return t.TempDir(), func() {}, nil
+	}
+	runCmd = func(name string, args ...string) error { return nil }
+
+	var env []string
+	e := New(&mockProvider{generateFunc: func(_ context.Context, req *provider.CodeRequest) (*provider.CodeResponse, error) {
+		env = req.Env
+		return &provider.CodeResponse{Summary: "ok"}, nil
+	}}, &mockAuthProvider{})
+	e.fetcher = &mockFetcher{fetchFunc: func(context.Context, *github.Context) (*ghdata.FetchResult, error) {
+		return &ghdata.FetchResult{ContextData: ghdata.Issue{Title: "t", Author: ghdata.Author{Login: "u"}}}, nil
+	}}
+	if err := e.Execute(context.Background(), buildTestCtx(false)); err != nil {
+		t.Fatalf("Execute: %v", err)
+	}
+	joined := strings.Join(env, "\n")
+	if !strings.Contains(joined, "PATH=") || !strings.Contains(joined, "swe-git-guard-") || !strings.Contains(joined, "GIT_CONFIG_KEY_0=core.hooksPath") {
+		t.Fatalf("provider env lacks the git guard: %q", env)
+	}
+}
+
+// prewarmProvider records the request Prewarm saw.
+type prewarmProvider struct {
+	mockProvider
+	prewarmed *provider.CodeRequest
+	prompt    string // Prompt at Prewarm time
+}
+
+func (p *prewarmProvider) Prewarm(req *provider.CodeRequest) {
+	p.prewarmed, p.prompt = req, req.Prompt
+}
+
+func TestExecute_PrewarmsProviderBeforePrompt(t *testing.T) {
+	origClone, origRun := cloneRepo, runCmd
+	t.Cleanup(func() { cloneRepo, runCmd = origClone, origRun })
+	cloneRepo = func(_ context.Context, repo, branch, token string) (string, func(), error) {
+		return t.TempDir(), func() {}, nil
+	}
+	runCmd = func(name string, args ...string) error { return nil }
+
+	p := &prewarmProvider{}
+	var generated *provider.CodeRequest
+	p.generateFunc = func(_ context.Context, req *provider.CodeRequest) (*provider.CodeResponse, error) {
+		generated = req
+		return &provider.CodeResponse{Summary: "ok"}, nil
+	}
+	e := New(p, &mockAuthProvider{})
+	e.fetcher = &mockFetcher{fetchFunc: func(context.Context, *github.Context) (*ghdata.FetchResult, error) {
+		return &ghdata.FetchResult{ContextData: ghdata.Issue{Title: "t", Author: ghdata.Author{Login: "u"}}}, nil
+	}}
+	if err := e.Execute(context.Background(), buildTestCtx(false)); err != nil {
+		t.Fatalf("Execute: %v", err)
+	}
+	if p.prewarmed == nil || p.prompt != "" || p.prewarmed != generated || !strings.Contains(generated.Prompt, "<capabilities>") {
+		t.Fatalf("prewarmed %+v (prompt %q), generated %+v", p.prewarmed, p.prompt, generated)
+	}
+}
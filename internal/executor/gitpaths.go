@@ -0,0 +1,36 @@
+package executor
+
+import (
+	"os"
+	"path/filepath"
+	"strings"
+)
+
+// gitPaths splits the paths git prints with -z. Unlike its default output,
+// they are neither quoted nor escaped, so names with spaces, quotes or
+// non-ASCII characters come through as they are.
+func gitPaths(out string) []string {
+	var paths []string
+	for _, p := range strings.Split(out, "\x00") {
+		if p = strings.TrimPrefix(p, "\n"); p != "" {
+			paths = append(paths, p)
+		}
+	}
+	return paths
+}
+
+// gitPath is the path of name (e.g. "hooks/pre-push") in the git directory
+// of the checkout at workdir. A worktree of a shared mirror has a .git file
+// instead of a directory; git resolves the path then, to the worktree's own
+// directory or, for files every worktree shares such as info/exclude, to
+// the mirror's.
+func gitPath(workdir, name string) (string, error) {
+	if info, err := os.Stat(filepath.Join(workdir, ".git")); err != nil || info.IsDir() {
+		return filepath.Join(workdir, ".git", filepath.FromSlash(name)), nil
+	}
+	out, err := gitOutput(workdir, "rev-parse", "--path-format=absolute", "--git-path", name)
+	if err != nil {
+		return "", err
+	}
+	return filepath.FromSlash(strings.TrimSpace(out)), nil
+}
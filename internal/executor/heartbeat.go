@@ -0,0 +1,135 @@
+package executor
+
+import (
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/cexll/swe/internal/github"
+	"github.com/cexll/swe/internal/github/comment"
+)
+
+// Heartbeat blocks are delimited so later beats replace earlier ones.
+const (
+	heartbeatStart = "<!-- swe-agent:heartbeat -->"
+	heartbeatEnd   = "<!-- /swe-agent:heartbeat -->"
+)
+
+// DefaultHeartbeatInterval is how often a running task's tracking comment is
+// refreshed when no interval is configured.
+const DefaultHeartbeatInterval = 5 * time.Minute
+
+// SetHeartbeatInterval sets how often the tracking comment of a running task
+// shows the elapsed time and the provider's latest step (<= 0 disables).
+func (e *Executor) SetHeartbeatInterval(d time.Duration) {
+	e.mu.Lock()
+	defer e.mu.Unlock()
+	e.heartbeat = d
+}
+
+// heartbeat keeps a long provider run from looking frozen: every interval it
+// puts the elapsed time and the provider's latest step above the tracking
+// comment, replacing the previous beat.
+type heartbeat struct {
+	ctx     *github.Context
+	rules   []SecretRule // steps are redacted with these
+	start   time.Time
+	stopped chan struct{}
+	done    chan struct{}
+
+	mu   sync.Mutex
+	step string
+	beat bool // a beat was written
+}
+
+// startHeartbeat starts beating for ctx's tracking comment; call stop once
+// the provider returns. It returns nil when disabled or without a comment.
+func (e *Executor) startHeartbeat(ctx *github.Context) *heartbeat {
+	interval := e.heartbeat
+	if interval <= 0 || ctx.PreparedCommentID <= 0 || ctx.Token == "" {
+		return nil
+	}
+	h := &heartbeat{ctx: ctx, rules: e.secretRuleSet(), start: time.Now(), stopped: make(chan struct{}), done: make(chan struct{})}
+	go func() {
+		defer close(h.done)
+		ticker := time.NewTicker(interval)
+		defer ticker.Stop()
+		for {
+			select {
+			case <-h.stopped:
+				return
+			case <-ticker.C:
+				h.write(true)
+			}
+		}
+	}()
+	return h
+}
+
+// progress records the provider's latest step; commands may echo tokens, so
+// it is redacted before it can reach the comment.
+func (h *heartbeat) progress(step string) {
+	step = strings.ReplaceAll(redactSecrets(step, h.rules), h.ctx.Token, "***")
+	h.mu.Lock()
+	defer h.mu.Unlock()
+	h.step = step
+}
+
+// stop ends the heartbeat and removes the last beat from the comment.
+func (h *heartbeat) stop() {
+	if h == nil {
+		return
+	}
+	close(h.stopped)
+	<-h.done
+	h.mu.Lock()
+	beat := h.beat
+	h.mu.Unlock()
+	if beat {
+		h.write(false)
+	}
+}
+
+// write replaces the beat in the tracking comment, or removes it.
+func (h *heartbeat) write(show bool) {
+	owner, repo := h.ctx.GetRepositoryOwner(), h.ctx.GetRepositoryName()
+	body, err := getComment(owner, repo, h.ctx.PreparedCommentID, h.ctx.Token)
+	if err != nil {
+		taskLog(h.ctx, "heartbeat").Warn("Reading tracking comment failed", "err", err)
+		return
+	}
+	body = stripHeartbeat(body)
+	if show {
+		h.mu.Lock()
+		block := heartbeatBlock(comment.Lang(h.ctx.PreparedLang), time.Since(h.start), h.step)
+		h.beat = true
+		h.mu.Unlock()
+		body = block + "\n\n" + body
+	}
+	if err := updateComment(owner, repo, h.ctx.PreparedCommentID, body, h.ctx.Token); err != nil {
+		taskLog(h.ctx, "heartbeat").Warn("Updating tracking comment failed", "err", err)
+	}
+}
+
+// heartbeatBlock renders one beat.
+func heartbeatBlock(lang comment.Lang, elapsed time.Duration, step string) string {
+	text := comment.Text(lang, comment.MsgStillWorking, int(elapsed.Round(time.Minute)/time.Minute))
+	if step != "" {
+		text += comment.Text(lang, comment.MsgLatestStep, strings.ReplaceAll(step, "`", "'"))
+	}
+	return heartbeatStart + "\n" + text + "\n" + heartbeatEnd
+}
+
+// stripHeartbeat removes a beat written by heartbeatBlock from body.
+func stripHeartbeat(body string) string {
+	i := strings.Index(body, heartbeatStart)
+	if i < 0 {
+		return body
+	}
+	j := strings.Index(body[i:], heartbeatEnd)
+	if j < 0 {
+		return body
+	}
+	rest := strings.TrimLeft(body[i+j+len(heartbeatEnd):], "\n")
+	return body[:i] + rest
+}
@@ -0,0 +1,80 @@
+package executor
+
+import (
+	"strings"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/cexll/swe/internal/github"
+)
+
+func TestHeartbeat_UpdatesAndRestoresTrackingComment(t *testing.T) {
+	origGet, origUpdate := getComment, updateComment
+	t.Cleanup(func() { getComment, updateComment = origGet, origUpdate })
+	var mu sync.Mutex
+	body := "### Progress\n- [x] read the code"
+	var writes []string
+	getComment = func(_, _ string, _ int64, _ string) (string, error) {
+		mu.Lock()
+		defer mu.Unlock()
+		return body, nil
+	}
+	updateComment = func(_, _ string, _ int64, b, _ string) error {
+		mu.Lock()
+		defer mu.Unlock()
+		body = b
+		writes = append(writes, b)
+		return nil
+	}
+
+	e := New(&mockProvider{}, &mockAuthProvider{})
+	e.SetHeartbeatInterval(10 * time.Millisecond)
+	ctx := &github.Context{PreparedCommentID: 7, Token: "installation-token"}
+	h := e.startHeartbeat(ctx)
+	if h == nil {
+		t.Fatal("heartbeat not started")
+	}
+	h.progress("Bash: curl -H 'Authorization: installation-token' api")
+
+	deadline := time.Now().Add(time.Second)
+	for {
+		mu.Lock()
+		n := len(writes)
+		mu.Unlock()
+		if n >= 2 {
+			break
+		}
+		if time.Now().After(deadline) {
+			t.Fatal("heartbeat did not update the comment")
+		}
+		time.Sleep(5 * time.Millisecond)
+	}
+	h.stop()
+
+	mu.Lock()
+	defer mu.Unlock()
+	beat := writes[len(writes)-2]
+	if !strings.Contains(beat, "Still working: 0 min elapsed") || !strings.Contains(beat, "latest step: `Bash: curl -H 'Authorization: ***' api`") {
+		t.Fatalf("beat = %q", beat)
+	}
+	if strings.Count(beat, heartbeatStart) != 1 || !strings.HasSuffix(beat, "### Progress\n- [x] read the code") {
+		t.Fatalf("beat should replace the previous one above the body: %q", beat)
+	}
+	if body != "### Progress\n- [x] read the code" {
+		t.Fatalf("body after stop = %q, want the beat removed", body)
+	}
+}
+
+func TestHeartbeat_DisabledWithoutComment(t *testing.T) {
+	e := New(&mockProvider{}, &mockAuthProvider{})
+	if h := e.startHeartbeat(&github.Context{Token: "tok"}); h != nil {
+		t.Fatal("heartbeat started without a tracking comment")
+	}
+	e.SetHeartbeatInterval(0)
+	if h := e.startHeartbeat(&github.Context{PreparedCommentID: 1, Token: "tok"}); h != nil {
+		t.Fatal("heartbeat started while disabled")
+	}
+	var h *heartbeat
+	h.stop() // nil-safe
+}
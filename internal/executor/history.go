@@ -0,0 +1,31 @@
+package executor
+
+import (
+	"time"
+
+	"github.com/cexll/swe/internal/github"
+	"github.com/cexll/swe/internal/github/comment"
+)
+
+// finishCommentHistory records the outcome of the run in a tracking comment
+// reused across runs, so the next run on the issue takes the comment over
+// and folds this run into its history.
+func finishCommentHistory(ctx *github.Context, err error) {
+	if !ctx.PreparedCommentHistory || ctx.PreparedCommentID <= 0 || ctx.Token == "" {
+		return
+	}
+	outcome := comment.OutcomeSucceeded
+	if err != nil {
+		outcome = comment.OutcomeFailed
+	}
+	owner, repo := ctx.GetRepositoryOwner(), ctx.GetRepositoryName()
+	body, gerr := getComment(owner, repo, ctx.PreparedCommentID, ctx.Token)
+	if gerr != nil {
+		taskLog(ctx, "comment").Warn("Reading tracking comment failed", "err", gerr)
+		return
+	}
+	body = comment.WithOutcome(body, outcome, time.Now())
+	if err := updateComment(owner, repo, ctx.PreparedCommentID, body, ctx.Token); err != nil {
+		taskLog(ctx, "comment").Warn("Updating tracking comment failed", "err", err)
+	}
+}
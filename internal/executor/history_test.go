@@ -0,0 +1,36 @@
+package executor
+
+import (
+	"errors"
+	"strings"
+	"testing"
+)
+
+func TestFinishCommentHistory(t *testing.T) {
+	origGet, origUpdate := getComment, updateComment
+	t.Cleanup(func() { getComment, updateComment = origGet, origUpdate })
+	comment := "Done."
+	getComment = func(_, _ string, _ int64, _ string) (string, error) { return comment, nil }
+	updateComment = func(_, _ string, _ int64, body, _ string) error {
+		comment = body
+		return nil
+	}
+
+	ctx := buildTestCtx(false)
+	ctx.PreparedCommentID = 42
+	ctx.Token = "ghs_test"
+	finishCommentHistory(ctx, nil)
+	if comment != "Done." {
+		t.Fatalf("a comment that is not reused got an outcome: %q", comment)
+	}
+
+	ctx.PreparedCommentHistory = true
+	finishCommentHistory(ctx, nil)
+	if !strings.HasPrefix(comment, "Done.\n<!-- swe-agent:outcome succeeded ") {
+		t.Fatalf("tracking comment = %q", comment)
+	}
+	finishCommentHistory(ctx, errors.New("tests failed"))
+	if strings.Count(comment, "swe-agent:outcome") != 1 || !strings.Contains(comment, "swe-agent:outcome failed ") {
+		t.Fatalf("outcome not replaced: %q", comment)
+	}
+}
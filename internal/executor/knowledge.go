@@ -0,0 +1,78 @@
+package executor
+
+import (
+	"strings"
+
+	"github.com/cexll/swe/internal/github"
+	ghdata "github.com/cexll/swe/internal/github/data"
+	"github.com/cexll/swe/internal/knowledge"
+)
+
+// SetKnowledge remembers the summary of each successful task in s and adds
+// up to promptEntries related earlier tasks of the same repository to the
+// prompt (nil remembers nothing).
+func (e *Executor) SetKnowledge(s *knowledge.Store, promptEntries int) {
+	e.knowledge = s
+	e.knowledgeEntries = promptEntries
+}
+
+// subjectText returns the title and body of the fetched issue or pull request.
+func subjectText(fetched *ghdata.FetchResult) (title, body string) {
+	if fetched == nil {
+		return "", ""
+	}
+	switch d := fetched.ContextData.(type) {
+	case ghdata.Issue:
+		return d.Title, d.Body
+	case ghdata.PullRequest:
+		return d.Title, d.Body
+	}
+	return "", ""
+}
+
+// priorWorkSection returns the prompt section listing the earlier tasks of
+// ctx's repository related to this one, or "".
+func (e *Executor) priorWorkSection(ctx *github.Context, repo string, fetched *ghdata.FetchResult) string {
+	if e.knowledge == nil || e.knowledgeEntries <= 0 {
+		return ""
+	}
+	title, body := subjectText(fetched)
+	entries, err := e.knowledge.Relevant(repo, title+"\n"+body+"\n"+ctx.GetTriggerCommentBody(), e.knowledgeEntries)
+	if err != nil {
+		taskLog(ctx, "knowledge").Warn("Knowledge lookup failed", "err", err)
+		return ""
+	}
+	if len(entries) > 0 {
+		taskLog(ctx, "knowledge").Info("Earlier tasks added to the prompt", "entries", len(entries))
+	}
+	return knowledge.PromptSection(entries)
+}
+
+// rememberTask records what ctx's finished task did, with secrets redacted.
+func (e *Executor) rememberTask(ctx *github.Context, repo, title, summary string) {
+	if e.knowledge == nil || summary == "" {
+		return
+	}
+	redact := func(s string) string {
+		if ctx.Token != "" {
+			s = strings.ReplaceAll(s, ctx.Token, "***")
+		}
+		return redactSecrets(s, e.secretRuleSet())
+	}
+	number := ctx.GetIssueNumber()
+	if ctx.IsPRContext() && ctx.GetPRNumber() != 0 {
+		number = ctx.GetPRNumber()
+	}
+	err := e.knowledge.Add(repo, knowledge.Entry{
+		TaskID:  ctx.TaskID,
+		Number:  number,
+		IsPR:    ctx.IsPRContext(),
+		Title:   title,
+		Request: redact(ctx.GetTriggerCommentBody()),
+		Summary: redact(summary),
+		Branch:  ctx.GetPreparedBranch(),
+	})
+	if err != nil {
+		taskLog(ctx, "knowledge").Warn("Recording task failed", "err", err)
+	}
+}
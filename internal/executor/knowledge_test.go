@@ -0,0 +1,54 @@
+package executor
+
+import (
+	"context"
+	"strings"
+	"testing"
+
+	"github.com/cexll/swe/internal/github"
+	ghdata "github.com/cexll/swe/internal/github/data"
+	"github.com/cexll/swe/internal/knowledge"
+	"github.com/cexll/swe/internal/provider"
+)
+
+func TestExecute_RemembersTasksForLaterPrompts(t *testing.T) {
+	origClone, origRun := cloneRepo, runCmd
+	t.Cleanup(func() { cloneRepo, runCmd = origClone, origRun })
+	cloneRepo = func(_ context.Context, repo, branch, token string) (string, func(), error) {
+		return t.TempDir(), func() {}, nil
+	}
+	runCmd = func(name string, args ...string) error { return nil }
+
+	var prompts []string
+	mp := &mockProvider{generateFunc: func(ctx context.Context, req *provider.CodeRequest) (*provider.CodeResponse, error) {
+		prompts = append(prompts, req.Prompt)
+		return &provider.CodeResponse{Summary: "Moved invoice rendering into billing/render.go using test-token"}, nil
+	}}
+	ex := New(mp, &mockAuthProvider{})
+	ex.SetHeartbeatInterval(0)
+	titles := []string{"Refactor invoice rendering", "Invoice totals are wrong"}
+	ex.fetcher = &mockFetcher{fetchFunc: func(ctx context.Context, gctx *github.Context) (*ghdata.FetchResult, error) {
+		return &ghdata.FetchResult{ContextData: ghdata.Issue{Title: titles[len(prompts)], State: "open"}}, nil
+	}}
+	store, err := knowledge.Open(t.TempDir(), 0)
+	if err != nil {
+		t.Fatal(err)
+	}
+	ex.SetKnowledge(store, 3)
+
+	for i := 0; i < 2; i++ {
+		if err := ex.Execute(context.Background(), buildTestCtx(false)); err != nil {
+			t.Fatalf("Execute #%d: %v", i+1, err)
+		}
+	}
+	if strings.Contains(prompts[0], "<prior_work>") {
+		t.Fatal("first task has no earlier work")
+	}
+	if !strings.Contains(prompts[1], "<prior_work>") || !strings.Contains(prompts[1], `issue #1 "Refactor invoice rendering"`) {
+		t.Fatalf("second prompt does not mention the first task:\n%s", prompts[1])
+	}
+	entries, _ := store.Relevant("owner/repo", "invoice", 5)
+	if len(entries) != 2 || strings.Contains(entries[0].Summary, "test-token") {
+		t.Fatalf("entries = %+v", entries)
+	}
+}
@@ -0,0 +1,22 @@
+package executor
+
+import (
+	"github.com/cexll/swe/internal/github"
+	"github.com/cexll/swe/internal/github/comment"
+)
+
+// commentText is comment.Text in the language of ctx's tracking comment.
+func commentText(ctx *github.Context, key string, args ...any) string {
+	return comment.Text(comment.Lang(ctx.PreparedLang), key, args...)
+}
+
+// languagePromptSection asks the provider to write the tracking comment in
+// the task's language; "" for English.
+func languagePromptSection(ctx *github.Context) string {
+	if comment.Lang(ctx.PreparedLang) != comment.Chinese {
+		return ""
+	}
+	return `<comment_language>
+The requester writes in Chinese. Write the tracking comment (headings, plan, status and summary) and any replies in Simplified Chinese (简体中文). Keep code, identifiers, file paths, commit messages and pull request titles in English.
+</comment_language>`
+}
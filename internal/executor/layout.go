@@ -0,0 +1,87 @@
+package executor
+
+import (
+	"fmt"
+
+	"github.com/cexll/swe/internal/github"
+	"github.com/cexll/swe/internal/github/comment"
+	buildinfo "github.com/cexll/swe/internal/version"
+)
+
+// maxLayoutFiles bounds the changed files listed in a tracking comment; the
+// rest are only counted.
+const maxLayoutFiles = 50
+
+// SetCommentTemplate sets the file of tracking comment layout templates used
+// by subsequent tasks ("" uses the built-in layout).
+func (e *Executor) SetCommentTemplate(path string) {
+	e.mu.Lock()
+	defer e.mu.Unlock()
+	e.commentTemplate = path
+}
+
+// commentLayout reads the tracking comment layout; nil is the built-in one,
+// also when the file fails to load.
+func commentLayout(path string) *comment.Layout {
+	l, err := comment.LoadLayout(path)
+	if err != nil {
+		phaseLog("comment").Warn("Ignoring layout template", "err", err)
+		return nil
+	}
+	return l
+}
+
+// layoutData describes ctx's tracking comment, before anything is pushed.
+func layoutData(ctx *github.Context) comment.LayoutData {
+	return comment.LayoutData{
+		Repo:    ctx.GetRepositoryFullName(),
+		Number:  ctx.GetIssueNumber(),
+		TaskID:  ctx.TaskID,
+		Version: buildinfo.Short(),
+		Lang:    comment.Lang(ctx.PreparedLang),
+	}
+}
+
+// finishCommentLayout renders the tracking comment once more with links to
+// the branch the task pushed and the files it changed. The built-in layout
+// has no such sections, so it only runs with a layout template.
+func (e *Executor) finishCommentLayout(ctx *github.Context, workdir, branch, base, before string) {
+	if e.commentTemplate == "" || ctx.PreparedCommentID <= 0 || ctx.Token == "" || branch == "" {
+		return
+	}
+	layout := commentLayout(e.commentTemplate)
+	if layout == nil {
+		return
+	}
+	pushed := fetchRemoteHead(workdir, branch)
+	if pushed == "" || pushed == before {
+		return
+	}
+	data := layoutData(ctx)
+	data.Branch = branch
+	repoURL := "https://github.com/" + data.Repo
+	data.Links = []comment.Link{{Title: "Branch", URL: repoURL + "/tree/" + branch}}
+	if base != "" && base != branch {
+		data.Links = append(data.Links, comment.Link{Title: "Compare", URL: fmt.Sprintf("%s/compare/%s...%s", repoURL, base, branch)})
+		if files, err := changedFiles(workdir, base, pushed); err != nil {
+			taskLog(ctx, "comment").Warn("Listing changed files for the tracking comment failed", "err", err)
+		} else {
+			if len(files) > maxLayoutFiles {
+				data.MoreFiles = len(files) - maxLayoutFiles
+				files = files[:maxLayoutFiles]
+			}
+			data.Files = files
+		}
+	}
+
+	owner, repo := ctx.GetRepositoryOwner(), ctx.GetRepositoryName()
+	body, err := getComment(owner, repo, ctx.PreparedCommentID, ctx.Token)
+	if err != nil {
+		taskLog(ctx, "comment").Warn("Reading tracking comment failed", "err", err)
+		return
+	}
+	body = layout.Render(body, data)
+	if err := updateComment(owner, repo, ctx.PreparedCommentID, body, ctx.Token); err != nil {
+		taskLog(ctx, "comment").Warn("Updating tracking comment failed", "err", err)
+	}
+}
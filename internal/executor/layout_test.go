@@ -0,0 +1,41 @@
+package executor
+
+import (
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+)
+
+func TestFinishCommentLayout(t *testing.T) {
+	workdir, _ := initPushRepo(t)
+	updated := stubComments(t, "Fixed the parser.\n\n<!-- swe-agent:version -->\n<sub>swe-agent v1</sub>")
+	layout := filepath.Join(t.TempDir(), "comment.tmpl")
+	if err := os.WriteFile(layout, []byte(`{{define "links"}}{{range .Links}}[{{.Title}}]({{.URL}}) {{end}}{{end}}
+{{define "files"}}Changed: {{range .Files}}{{.}} {{end}}{{end}}`), 0o644); err != nil {
+		t.Fatal(err)
+	}
+	e := New(&mockProvider{}, &mockAuthProvider{})
+	e.SetCommentTemplate(layout)
+
+	gitIn(t, workdir, "checkout", "-q", "-b", "swe-agent/1-1")
+	before := remoteHead(workdir, "swe-agent/1-1")
+	ctx := buildTestCtx(false)
+	ctx.Token = "tok"
+	ctx.PreparedCommentID = 99
+
+	// nothing pushed, nothing to add
+	e.finishCommentLayout(ctx, workdir, "swe-agent/1-1", "main", before)
+	if *updated != "" {
+		t.Fatalf("updated without a push: %q", *updated)
+	}
+
+	commitAndPush(t, workdir, "swe-agent/1-1", "fixed\n")
+	e.finishCommentLayout(ctx, workdir, "swe-agent/1-1", "main", before)
+	repoURL := "https://github.com/" + ctx.GetRepositoryFullName()
+	if !strings.HasPrefix(*updated, "Fixed the parser.\n\n<!-- swe-agent:sections -->\n") ||
+		!strings.Contains(*updated, "[Branch]("+repoURL+"/tree/swe-agent/1-1) [Compare]("+repoURL+"/compare/main...swe-agent/1-1)") ||
+		!strings.Contains(*updated, "Changed: README.md") || !strings.Contains(*updated, "<sub>swe-agent ") {
+		t.Fatalf("comment = %q", *updated)
+	}
+}
@@ -0,0 +1,187 @@
+package executor
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"os/exec"
+	"path/filepath"
+	"strings"
+
+	"github.com/cexll/swe/internal/github"
+	"github.com/cexll/swe/internal/prompt"
+	"github.com/cexll/swe/internal/provider"
+	"github.com/cexll/swe/internal/toolconfig"
+)
+
+// LocalRequest describes a task run against an existing checkout, without GitHub.
+type LocalRequest struct {
+	RepoPath string
+	Prompt   string
+	// Repo is the owner/name shown to the model; derived from the origin remote when empty.
+	Repo string
+	// Branch, when set, is created (or checked out) before the provider runs.
+	Branch string
+	// User is recorded as the trigger user in the prompt.
+	User string
+	// PromptDir holds the operator's prompt template overrides, used when
+	// the checkout has none of its own.
+	PromptDir string
+}
+
+// LocalResult is the outcome of a local run.
+type LocalResult struct {
+	Prompt   string
+	Summary  string
+	CostUSD  float64
+	BaseSHA  string
+	DiffStat string
+	Diff     string
+}
+
+// allow tests to stub git output capture
+var gitOutput = defaultGitOutput
+
+// RunLocal runs the executor pipeline (prompt build, provider invocation,
+// diff summary) against a local checkout. Nothing is pushed or posted.
+func RunLocal(ctx context.Context, p provider.Provider, req LocalRequest) (*LocalResult, error) {
+	if strings.TrimSpace(req.Prompt) == "" {
+		return nil, errors.New("prompt is required")
+	}
+	repoPath, err := filepath.Abs(req.RepoPath)
+	if err != nil {
+		return nil, fmt.Errorf("resolve repo path: %w", err)
+	}
+	top, err := gitOutput(repoPath, "rev-parse", "--show-toplevel")
+	if err != nil {
+		return nil, fmt.Errorf("%s is not a git checkout: %w", repoPath, err)
+	}
+	repoPath = strings.TrimSpace(top)
+
+	baseSHA, err := gitOutput(repoPath, "rev-parse", "HEAD")
+	if err != nil {
+		return nil, fmt.Errorf("resolve HEAD: %w", err)
+	}
+	baseSHA = strings.TrimSpace(baseSHA)
+
+	current, _ := gitOutput(repoPath, "rev-parse", "--abbrev-ref", "HEAD")
+	base := strings.TrimSpace(current)
+	branch := base
+	if req.Branch != "" && req.Branch != base {
+		if err := runCmd("git", "-C", repoPath, "checkout", "-B", req.Branch); err != nil {
+			return nil, fmt.Errorf("create branch: %w", err)
+		}
+		branch = req.Branch
+	}
+
+	repo := req.Repo
+	if repo == "" {
+		repo = repoFromRemote(repoPath)
+	}
+	owner, name := "", ""
+	if parts := strings.SplitN(repo, "/", 2); len(parts) == 2 {
+		owner, name = parts[0], parts[1]
+	}
+
+	ghCtx := &github.Context{
+		EventName:      github.EventIssueComment,
+		EventAction:    "created",
+		Repository:     github.Repository{Owner: owner, Name: name, FullName: repo, DefaultBranch: base},
+		Actor:          req.User,
+		TriggerUser:    req.User,
+		TriggerComment: &github.Comment{Body: req.Prompt, User: req.User},
+		BaseBranch:     base,
+		PreparedBranch: branch,
+	}
+	fullPrompt := prompt.BuildLocalPromptWith(ghCtx, prompt.Options{
+		RepoPath: repoPath,
+		Template: promptTemplate(repoPath, req.PromptDir, prompt.ModeLocal),
+	})
+
+	// Optional GitHub MCP tools stay off; without a comment ID the comment
+	// updater server is never configured.
+	toolOpts := toolconfig.Options{}
+	resp, err := p.GenerateCode(ctx, &provider.CodeRequest{
+		Prompt:   fullPrompt,
+		RepoPath: repoPath,
+		Context: map[string]string{
+			"repository":  repo,
+			"base_branch": base,
+			"head_branch": branch,
+		},
+		AllowedTools:    toolconfig.BuildAllowedTools(toolOpts),
+		DisallowedTools: toolconfig.BuildDisallowedTools(toolOpts),
+	})
+	if err != nil {
+		return nil, &ProviderError{Provider: p.Name(), Err: err}
+	}
+
+	result := &LocalResult{Prompt: fullPrompt, BaseSHA: baseSHA}
+	if resp != nil {
+		result.Summary = resp.Summary
+		result.CostUSD = resp.CostUSD
+	}
+	result.DiffStat, result.Diff, err = localDiff(repoPath, baseSHA)
+	if err != nil {
+		return result, fmt.Errorf("collect diff: %w", err)
+	}
+	return result, nil
+}
+
+// localDiff returns the stat and patch of everything changed since baseSHA,
+// including commits made by the provider and untracked files.
+func localDiff(repoPath, baseSHA string) (string, string, error) {
+	stat, err := gitOutput(repoPath, "diff", "--stat", baseSHA)
+	if err != nil {
+		return "", "", err
+	}
+	diff, err := gitOutput(repoPath, "diff", baseSHA)
+	if err != nil {
+		return "", "", err
+	}
+
+	untracked, err := gitOutput(repoPath, "ls-files", "--others", "--exclude-standard", "-z")
+	if err != nil {
+		return "", "", err
+	}
+	var statB, diffB strings.Builder
+	statB.WriteString(stat)
+	diffB.WriteString(diff)
+	for _, file := range gitPaths(untracked) {
+		fmt.Fprintf(&statB, " %s (new, untracked)\n", file)
+		// --no-index exits 1 when the files differ, which is always the case
+		// here; git reads /dev/null as the empty side on every platform
+		// (os.DevNull is NUL on Windows)
+		patch, _ := gitOutput(repoPath, "diff", "--no-index", "--", "/dev/null", file)
+		diffB.WriteString(patch)
+	}
+	return statB.String(), diffB.String(), nil
+}
+
+// repoFromRemote derives owner/name from the origin remote URL, if any.
+func repoFromRemote(repoPath string) string {
+	url, err := gitOutput(repoPath, "remote", "get-url", "origin")
+	if err != nil {
+		return filepath.Base(repoPath)
+	}
+	url = strings.TrimSuffix(strings.TrimSpace(url), ".git")
+	if i := strings.Index(url, "github.com"); i >= 0 {
+		return strings.TrimLeft(url[i+len("github.com"):], ":/")
+	}
+	return filepath.Base(repoPath)
+}
+
+func defaultGitOutput(dir string, args ...string) (string, error) {
+	cmd := exec.Command("git", append([]string{"-C", dir}, args...)...)
+	out, err := cmd.Output()
+	if err != nil {
+		var exitErr *exec.ExitError
+		if errors.As(err, &exitErr) && len(exitErr.Stderr) > 0 {
+			err = fmt.Errorf("git %s: %v: %s", strings.Join(args, " "), err, strings.TrimSpace(string(exitErr.Stderr)))
+		} else {
+			err = fmt.Errorf("git %s: %w", strings.Join(args, " "), err)
+		}
+	}
+	recordCommand("git", cmd.Args[1:], out, err)
+	return string(out), err
+}
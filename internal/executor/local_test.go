@@ -0,0 +1,197 @@
+package executor
+
+import (
+	"context"
+	"errors"
+	"os"
+	"os/exec"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"github.com/cexll/swe/internal/provider"
+	"github.com/cexll/swe/internal/provider/cassette"
+	"github.com/cexll/swe/internal/provider/claude"
+)
+
+func initLocalRepo(t *testing.T) string {
+	t.Helper()
+	if _, err := exec.LookPath("git"); err != nil {
+		t.Skip("git not installed")
+	}
+	dir := t.TempDir()
+	for _, args := range [][]string{
+		{"init", "-q", "-b", "main"},
+		{"config", "user.email", "test@example.com"},
+		{"config", "user.name", "test"},
+		{"remote", "add", "origin", "git@github.com:acme/widgets.git"},
+	} {
+		if out, err := exec.Command("git", append([]string{"-C", dir}, args...)...).CombinedOutput(); err != nil {
+			t.Fatalf("git %v: %v\n%s", args, err, out)
+		}
+	}
+	if err := os.WriteFile(filepath.Join(dir, "README.md"), []byte("hello\n"), 0o644); err != nil {
+		t.Fatal(err)
+	}
+	for _, args := range [][]string{{"add", "."}, {"commit", "-q", "-m", "init"}} {
+		if out, err := exec.Command("git", append([]string{"-C", dir}, args...)...).CombinedOutput(); err != nil {
+			t.Fatalf("git %v: %v\n%s", args, err, out)
+		}
+	}
+	return dir
+}
+
+func TestRunLocal_PipelineAndDiff(t *testing.T) {
+	dir := initLocalRepo(t)
+
+	var got *provider.CodeRequest
+	p := &mockProvider{name: "mock", generateFunc: func(_ context.Context, req *provider.CodeRequest) (*provider.CodeResponse, error) {
+		got = req
+		// simulate the agent editing a tracked file and adding a new one
+		_ = os.WriteFile(filepath.Join(req.RepoPath, "README.md"), []byte("hello world\n"), 0o644)
+		_ = os.WriteFile(filepath.Join(req.RepoPath, "NEW.txt"), []byte("brand new\n"), 0o644)
+		return &provider.CodeResponse{Summary: "Updated greeting", CostUSD: 0.25}, nil
+	}}
+
+	res, err := RunLocal(context.Background(), p, LocalRequest{RepoPath: dir, Prompt: "make the greeting friendlier", Branch: "swe-agent/local", User: "dev"})
+	if err != nil {
+		t.Fatalf("RunLocal error: %v", err)
+	}
+
+	if got == nil {
+		t.Fatal("provider not called")
+	}
+	if !strings.Contains(got.Prompt, "make the greeting friendlier") || !strings.Contains(got.Prompt, "LOCAL RUN OVERRIDES") {
+		t.Fatalf("prompt missing instruction or local overrides")
+	}
+	if !strings.Contains(got.Prompt, "acme/widgets") || !strings.Contains(got.Prompt, "swe-agent/local") {
+		t.Fatalf("prompt missing repository or branch")
+	}
+	if got.Context["repository"] != "acme/widgets" || got.Context["base_branch"] != "main" || got.Context["github_token"] != "" {
+		t.Fatalf("unexpected context: %v", got.Context)
+	}
+	if len(got.AllowedTools) == 0 {
+		t.Fatal("expected the default tool allowlist")
+	}
+
+	if res.Summary != "Updated greeting" || res.CostUSD != 0.25 || len(res.BaseSHA) != 40 {
+		t.Fatalf("unexpected result: %+v", res)
+	}
+	if !strings.Contains(res.DiffStat, "README.md") || !strings.Contains(res.DiffStat, "NEW.txt (new, untracked)") {
+		t.Fatalf("diff stat = %q", res.DiffStat)
+	}
+	if !strings.Contains(res.Diff, "+hello world") || !strings.Contains(res.Diff, "+brand new") {
+		t.Fatalf("diff = %q", res.Diff)
+	}
+
+	branch, _ := gitOutput(dir, "rev-parse", "--abbrev-ref", "HEAD")
+	if strings.TrimSpace(branch) != "swe-agent/local" {
+		t.Fatalf("branch = %q, want swe-agent/local", branch)
+	}
+}
+
+func TestRunLocal_IncludesCommittedChanges(t *testing.T) {
+	dir := initLocalRepo(t)
+	p := &mockProvider{name: "mock", generateFunc: func(_ context.Context, req *provider.CodeRequest) (*provider.CodeResponse, error) {
+		_ = os.WriteFile(filepath.Join(req.RepoPath, "README.md"), []byte("committed\n"), 0o644)
+		_ = exec.Command("git", "-C", req.RepoPath, "commit", "-qam", "agent commit").Run()
+		return &provider.CodeResponse{Summary: "done"}, nil
+	}}
+
+	res, err := RunLocal(context.Background(), p, LocalRequest{RepoPath: dir, Prompt: "commit something", Repo: "me/explicit"})
+	if err != nil {
+		t.Fatalf("RunLocal error: %v", err)
+	}
+	if !strings.Contains(res.Diff, "+committed") {
+		t.Fatalf("diff should include commits since start: %q", res.Diff)
+	}
+	if !strings.Contains(res.Prompt, "me/explicit") {
+		t.Fatal("explicit repo should override the remote")
+	}
+}
+
+func TestLocalDiff_UntrackedNamesAsIs(t *testing.T) {
+	dir := initLocalRepo(t)
+	name := "naïve notes.md"
+	if err := os.WriteFile(filepath.Join(dir, name), []byte("spaced out\n"), 0o644); err != nil {
+		t.Fatal(err)
+	}
+	head, _ := gitOutput(dir, "rev-parse", "HEAD")
+	stat, diff, err := localDiff(dir, strings.TrimSpace(head))
+	if err != nil {
+		t.Fatalf("localDiff: %v", err)
+	}
+	if !strings.Contains(stat, name+" (new, untracked)") || !strings.Contains(diff, "+spaced out") {
+		t.Fatalf("stat = %q, diff = %q", stat, diff)
+	}
+}
+
+func TestRunLocal_Errors(t *testing.T) {
+	p := &mockProvider{name: "mock"}
+	if _, err := RunLocal(context.Background(), p, LocalRequest{RepoPath: t.TempDir(), Prompt: " "}); err == nil {
+		t.Fatal("expected error for empty prompt")
+	}
+	if _, err := exec.LookPath("git"); err == nil {
+		if _, err := RunLocal(context.Background(), p, LocalRequest{RepoPath: t.TempDir(), Prompt: "x"}); err == nil || !strings.Contains(err.Error(), "not a git checkout") {
+			t.Fatalf("expected not a git checkout error, got %v", err)
+		}
+	}
+
+	dir := initLocalRepo(t)
+	failing := &mockProvider{name: "mock", generateFunc: func(context.Context, *provider.CodeRequest) (*provider.CodeResponse, error) {
+		return nil, errors.New("cli crashed")
+	}}
+	_, err := RunLocal(context.Background(), failing, LocalRequest{RepoPath: dir, Prompt: "x"})
+	var perr *ProviderError
+	if !errors.As(err, &perr) {
+		t.Fatalf("expected ProviderError, got %v", err)
+	}
+}
+
+func TestRepoFromRemote(t *testing.T) {
+	orig := gitOutput
+	defer func() { gitOutput = orig }()
+
+	cases := map[string]string{
+		"https://github.com/acme/api.git\n": "acme/api",
+		"git@github.com:acme/web.git":       "acme/web",
+		"https://gitlab.com/acme/other.git": "checkout",
+	}
+	for url, want := range cases {
+		gitOutput = func(string, ...string) (string, error) { return url, nil }
+		if got := repoFromRemote("/tmp/checkout"); got != want {
+			t.Errorf("repoFromRemote(%q) = %q, want %q", url, got, want)
+		}
+	}
+	gitOutput = func(string, ...string) (string, error) { return "", errors.New("no remote") }
+	if got := repoFromRemote("/tmp/checkout"); got != "checkout" {
+		t.Errorf("no remote: got %q", got)
+	}
+}
+
+// TestRunLocal_ReplaysCassette runs the local pipeline on a recorded
+// provider run. Re-record with SWE_RECORD_CASSETTES=true and ANTHROPIC_API_KEY.
+func TestRunLocal_ReplaysCassette(t *testing.T) {
+	dir := initLocalRepo(t)
+	p, err := cassette.Open("testdata/cassettes/local-greeting.json", func() (provider.Provider, error) {
+		return claude.NewProvider(os.Getenv("ANTHROPIC_API_KEY"), "claude-sonnet-4-5-20250929"), nil
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if rp, ok := p.(*cassette.Replayer); ok {
+		// the prompt template evolves; the cassette pins only the outcome
+		rp.Strict = false
+	}
+
+	res, err := RunLocal(context.Background(), p, LocalRequest{RepoPath: dir, Prompt: "make the README greeting friendlier and note it in a changelog", Branch: "swe-agent/greeting", User: "dev"})
+	if err != nil {
+		t.Fatalf("RunLocal error: %v", err)
+	}
+	if res.Summary == "" || !strings.Contains(res.Diff, "+hello, friend") || !strings.Contains(res.DiffStat, "CHANGELOG.md (new, untracked)") {
+		t.Fatalf("unexpected result: %+v", res)
+	}
+	if log, _ := gitOutput(dir, "log", "--format=%s", "main..HEAD"); strings.TrimSpace(log) == "" {
+		t.Fatal("the recorded commit was not replayed on the task branch")
+	}
+}
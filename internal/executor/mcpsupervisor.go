@@ -0,0 +1,267 @@
+package executor
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"io"
+	"os"
+	"os/exec"
+	"os/signal"
+	"path/filepath"
+	"sort"
+	"strings"
+	"sync"
+	"sync/atomic"
+	"syscall"
+	"time"
+
+	"github.com/cexll/swe/internal/github"
+)
+
+// SuperviseMCPCommand is the hidden subcommand the provider CLIs start MCP
+// servers through (see RunSuperviseMCP).
+const SuperviseMCPCommand = "supervise-mcp"
+
+const (
+	// mcpStderrTail is how much of a server's stderr goes into the task log
+	// and into the error of a task whose server failed.
+	mcpStderrTail = 4 << 10
+	// mcpFailedSuffix marks the file a supervisor writes when its server fails.
+	mcpFailedSuffix = ".failed"
+)
+
+// allow tests to poll faster
+var mcpWatchInterval = 500 * time.Millisecond
+
+// MCPServerError is the error of a task whose MCP server exited while the
+// provider was running.
+type MCPServerError struct {
+	Server string
+	Status string // how the server ended ("exit status 1")
+	Stderr string // the end of its stderr
+}
+
+func (e *MCPServerError) Error() string {
+	msg := fmt.Sprintf("MCP server %s exited early (%s)", e.Server, e.Status)
+	if e.Stderr != "" {
+		msg += ": " + lastLine(e.Stderr)
+	}
+	return msg
+}
+
+// mcpSupervisor collects what the supervised MCP servers of one task leave
+// in its directory: NAME.log holds a server's stderr and NAME.failed, written
+// when the server failed, how it ended.
+type mcpSupervisor struct {
+	dir      string
+	launcher []string
+}
+
+// startMCPSupervision creates the directory of a task's MCP servers and the
+// launcher that runs them through this binary's supervise-mcp subcommand.
+func startMCPSupervision() (*mcpSupervisor, error) {
+	self, err := os.Executable()
+	if err != nil {
+		return nil, fmt.Errorf("mcp supervision: %w", err)
+	}
+	dir, err := os.MkdirTemp("", "swe-mcp-")
+	if err != nil {
+		return nil, fmt.Errorf("mcp supervision: %w", err)
+	}
+	return &mcpSupervisor{dir: dir, launcher: []string{self, SuperviseMCPCommand, dir}}, nil
+}
+
+// watch cancels ctx with an *MCPServerError as soon as a server fails; call
+// the returned function once the provider has returned.
+func (s *mcpSupervisor) watch(ctx context.Context, cancel context.CancelCauseFunc) (stop func()) {
+	done := make(chan struct{})
+	var wg sync.WaitGroup
+	wg.Add(1)
+	go func() {
+		defer wg.Done()
+		ticker := time.NewTicker(mcpWatchInterval)
+		defer ticker.Stop()
+		for {
+			if failed := s.failures(); len(failed) > 0 {
+				cancel(failed[0])
+				return
+			}
+			select {
+			case <-done:
+				return
+			case <-ctx.Done():
+				return
+			case <-ticker.C:
+			}
+		}
+	}()
+	return func() {
+		close(done)
+		wg.Wait()
+	}
+}
+
+// failures returns the servers that failed so far, by name.
+func (s *mcpSupervisor) failures() []*MCPServerError {
+	matches, _ := filepath.Glob(filepath.Join(s.dir, "*"+mcpFailedSuffix))
+	sort.Strings(matches)
+	var out []*MCPServerError
+	for _, path := range matches {
+		status, err := os.ReadFile(path)
+		if err != nil {
+			continue
+		}
+		name := strings.TrimSuffix(filepath.Base(path), mcpFailedSuffix)
+		out = append(out, &MCPServerError{
+			Server: name,
+			Status: strings.TrimSpace(string(status)),
+			Stderr: s.stderr(name),
+		})
+	}
+	return out
+}
+
+// stderr returns the end of a server's stderr.
+func (s *mcpSupervisor) stderr(name string) string {
+	data, err := os.ReadFile(filepath.Join(s.dir, name+".log"))
+	if err != nil {
+		return ""
+	}
+	if len(data) > mcpStderrTail {
+		data = data[len(data)-mcpStderrTail:]
+		if i := strings.IndexByte(string(data), '\n'); i >= 0 {
+			data = data[i+1:]
+		}
+	}
+	return strings.TrimSpace(string(data))
+}
+
+// servers lists the servers that were started, by name.
+func (s *mcpSupervisor) servers() []string {
+	matches, _ := filepath.Glob(filepath.Join(s.dir, "*.log"))
+	names := make([]string, 0, len(matches))
+	for _, path := range matches {
+		names = append(names, strings.TrimSuffix(filepath.Base(path), ".log"))
+	}
+	sort.Strings(names)
+	return names
+}
+
+func (s *mcpSupervisor) remove() { _ = os.RemoveAll(s.dir) }
+
+// recordMCPServers adds the stderr of each MCP server of the task to its
+// log, as a warning for the servers that failed, with secrets redacted.
+func (e *Executor) recordMCPServers(ctx *github.Context, s *mcpSupervisor) {
+	if s == nil || e.store == nil || ctx.TaskID == "" {
+		return
+	}
+	failed := make(map[string]*MCPServerError)
+	for _, f := range s.failures() {
+		failed[f.Server] = f
+	}
+	for _, name := range s.servers() {
+		out := s.stderr(name)
+		level, msg := "info", fmt.Sprintf("MCP server %s stderr:\n%s", name, out)
+		if f := failed[name]; f != nil {
+			level, msg = "warn", fmt.Sprintf("MCP server %s exited early (%s); stderr:\n%s", name, f.Status, out)
+		} else if out == "" {
+			continue
+		}
+		e.store.AddLog(ctx.TaskID, level, e.redactMCPOutput(ctx, msg))
+	}
+}
+
+// redactMCPOutput hides the installation token and secrets in server output.
+func (e *Executor) redactMCPOutput(ctx *github.Context, s string) string {
+	if ctx.Token != "" {
+		s = strings.ReplaceAll(s, ctx.Token, "***")
+	}
+	return redactSecrets(s, e.secretRuleSet())
+}
+
+// mcpError returns the *MCPServerError ctx was cancelled with, if any, so a
+// task whose MCP server died fails with that rather than the provider's
+// reaction to it.
+func mcpError(ctx context.Context) *MCPServerError {
+	var serverErr *MCPServerError
+	if errors.As(context.Cause(ctx), &serverErr) {
+		return serverErr
+	}
+	return nil
+}
+
+// RunSuperviseMCP implements the supervise-mcp subcommand: args are the
+// task's supervision directory, the server name, "--" and the server
+// command. The server gets stdin and stdout, which carry the MCP protocol;
+// its stderr also goes to NAME.log. When the server fails to start or exits
+// with an error that the provider did not ask for by signalling, NAME.failed
+// records how it ended. The exit status is the server's.
+func RunSuperviseMCP(args []string, stdin io.Reader, stdout, stderr io.Writer) int {
+	if len(args) < 4 || args[2] != "--" {
+		_, _ = fmt.Fprintf(stderr, "usage: %s DIR NAME -- COMMAND [ARGS...]\n", SuperviseMCPCommand)
+		return 2
+	}
+	dir, name, command := args[0], args[1], args[3:]
+
+	logFile, err := os.OpenFile(filepath.Join(dir, name+".log"), os.O_CREATE|os.O_WRONLY|os.O_APPEND, 0o600)
+	if err != nil {
+		_, _ = fmt.Fprintf(stderr, "swe-agent: supervise %s: %v\n", name, err)
+		return 1
+	}
+	defer func() { _ = logFile.Close() }()
+	failed := func(status string) {
+		if err := os.WriteFile(filepath.Join(dir, name+mcpFailedSuffix), []byte(status+"\n"), 0o600); err != nil {
+			phaseLog("mcp").Warn("Recording MCP server failure failed", "server", name, "err", err)
+		}
+	}
+
+	cmd := exec.Command(command[0], command[1:]...)
+	cmd.Stdin, cmd.Stdout = stdin, stdout
+	cmd.Stderr = io.MultiWriter(stderr, logFile)
+	if err := cmd.Start(); err != nil {
+		_, _ = fmt.Fprintf(cmd.Stderr, "swe-agent: start %s: %v\n", name, err)
+		failed(fmt.Sprintf("failed to start: %v", err))
+		return 1
+	}
+
+	// the provider stops its servers with a signal; pass it on and do not
+	// count the exit that follows as a failure
+	var signalled atomic.Bool
+	sigs := make(chan os.Signal, 1)
+	signal.Notify(sigs, syscall.SIGTERM, syscall.SIGINT, syscall.SIGHUP)
+	defer func() {
+		signal.Stop(sigs)
+		close(sigs)
+	}()
+	go func() {
+		for sig := range sigs {
+			signalled.Store(true)
+			if err := cmd.Process.Signal(sig); err != nil {
+				// Windows delivers no signals but kill
+				_ = cmd.Process.Kill()
+			}
+		}
+	}()
+
+	err = cmd.Wait()
+	if err == nil {
+		return 0
+	}
+	if !signalled.Load() {
+		failed(err.Error())
+	}
+	var exitErr *exec.ExitError
+	if errors.As(err, &exitErr) && exitErr.ExitCode() > 0 {
+		return exitErr.ExitCode()
+	}
+	return 1
+}
+
+// lastLine returns the last line of s.
+func lastLine(s string) string {
+	if i := strings.LastIndexByte(s, '\n'); i >= 0 {
+		return s[i+1:]
+	}
+	return s
+}
@@ -0,0 +1,121 @@
+package executor
+
+import (
+	"bytes"
+	"context"
+	"errors"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/cexll/swe/internal/github"
+	ghdata "github.com/cexll/swe/internal/github/data"
+	"github.com/cexll/swe/internal/provider"
+	"github.com/cexll/swe/internal/taskstore"
+)
+
+func TestRunSuperviseMCP(t *testing.T) {
+	dir := t.TempDir()
+
+	// stdin and stdout carry the protocol; a clean exit is not a failure
+	var stdout, stderr bytes.Buffer
+	if code := RunSuperviseMCP([]string{dir, "echo", "--", "cat"}, strings.NewReader("ping\n"), &stdout, &stderr); code != 0 {
+		t.Fatalf("exit = %d, stderr %s", code, stderr.String())
+	}
+	if stdout.String() != "ping\n" {
+		t.Fatalf("stdout = %q", stdout.String())
+	}
+
+	stderr.Reset()
+	code := RunSuperviseMCP([]string{dir, "crash", "--", "sh", "-c", "echo starting >&2; echo 'missing API key' >&2; exit 3"}, strings.NewReader(""), &stdout, &stderr)
+	if code != 3 {
+		t.Fatalf("exit = %d, want the server's", code)
+	}
+	if !strings.Contains(stderr.String(), "missing API key") {
+		t.Fatalf("stderr not passed on: %q", stderr.String())
+	}
+
+	code = RunSuperviseMCP([]string{dir, "absent", "--", filepath.Join(dir, "no-such-server")}, strings.NewReader(""), &stdout, &stderr)
+	if code != 1 {
+		t.Fatalf("exit = %d for a missing command", code)
+	}
+
+	s := &mcpSupervisor{dir: dir}
+	failed := s.failures()
+	if len(failed) != 2 || failed[0].Server != "absent" || failed[1].Server != "crash" {
+		t.Fatalf("failures = %+v", failed)
+	}
+	if !strings.HasPrefix(failed[0].Status, "failed to start") {
+		t.Fatalf("absent status = %q", failed[0].Status)
+	}
+	crash := failed[1]
+	if crash.Status != "exit status 3" || crash.Stderr != "starting\nmissing API key" {
+		t.Fatalf("crash = %+v", crash)
+	}
+	if got := crash.Error(); got != "MCP server crash exited early (exit status 3): missing API key" {
+		t.Fatalf("Error() = %q", got)
+	}
+	if got := strings.Join(s.servers(), ","); got != "absent,crash,echo" {
+		t.Fatalf("servers = %s", got)
+	}
+
+	if code := RunSuperviseMCP([]string{dir, "x"}, nil, &stdout, &stderr); code != 2 {
+		t.Fatalf("usage exit = %d", code)
+	}
+}
+
+func TestExecute_MCPServerExitFailsTask(t *testing.T) {
+	origClone, origRun, origInterval := cloneRepo, runCmd, mcpWatchInterval
+	t.Cleanup(func() { cloneRepo, runCmd, mcpWatchInterval = origClone, origRun, origInterval })
+	cloneRepo = func(_ context.Context, repo, branch, token string) (string, func(), error) {
+		return t.TempDir(), func() {}, nil
+	}
+	runCmd = func(name string, args ...string) error { return nil }
+	mcpWatchInterval = 10 * time.Millisecond
+
+	mp := &mockProvider{generateFunc: func(ctx context.Context, req *provider.CodeRequest) (*provider.CodeResponse, error) {
+		if len(req.MCPLauncher) != 3 || req.MCPLauncher[1] != SuperviseMCPCommand {
+			t.Errorf("MCPLauncher = %v", req.MCPLauncher)
+			return nil, errors.New("no launcher")
+		}
+		// what the supervisor leaves behind when the server dies at startup
+		dir := req.MCPLauncher[2]
+		_ = os.WriteFile(filepath.Join(dir, "fetch.log"), []byte("error: token test-token rejected\n"), 0o600)
+		_ = os.WriteFile(filepath.Join(dir, "fetch.failed"), []byte("exit status 1\n"), 0o600)
+		select {
+		case <-ctx.Done():
+			return nil, ctx.Err()
+		case <-time.After(5 * time.Second):
+			return nil, errors.New("run was not stopped")
+		}
+	}}
+	ex := New(mp, &mockAuthProvider{})
+	ex.SetHeartbeatInterval(0)
+	ex.fetcher = &mockFetcher{fetchFunc: func(ctx context.Context, gctx *github.Context) (*ghdata.FetchResult, error) {
+		return &ghdata.FetchResult{ContextData: ghdata.Issue{Title: "Test Issue", State: "open"}}, nil
+	}}
+	store := taskstore.NewStore()
+	store.Create(&taskstore.Task{ID: "task-1"})
+	ex.SetTaskStore(store)
+
+	gctx := buildTestCtx(false)
+	gctx.TaskID = "task-1"
+	err := ex.Execute(context.Background(), gctx)
+	var serverErr *MCPServerError
+	if !errors.As(err, &serverErr) || serverErr.Server != "fetch" || strings.Contains(err.Error(), "test-token") {
+		t.Fatalf("Execute() err = %v, want the MCP server's", err)
+	}
+
+	task, _ := store.Get("task-1")
+	var logged string
+	for _, entry := range task.Logs {
+		if strings.HasPrefix(entry.Message, "MCP server fetch") {
+			logged = entry.Level + ": " + entry.Message
+		}
+	}
+	if logged != "warn: MCP server fetch exited early (exit status 1); stderr:\nerror: token *** rejected" {
+		t.Fatalf("task log = %q", logged)
+	}
+}
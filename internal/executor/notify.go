@@ -0,0 +1,32 @@
+package executor
+
+import (
+	"github.com/cexll/swe/internal/github"
+	"github.com/cexll/swe/internal/notify"
+)
+
+// SetNotifier enables completed/failed lifecycle notifications (nil disables).
+func (e *Executor) SetNotifier(n *notify.Manager) {
+	e.notifier = n
+}
+
+func (e *Executor) notifyResult(ctx *github.Context, summary string, costUSD float64, detail string, failed bool) {
+	if e.notifier == nil {
+		return
+	}
+	ev := notify.Event{
+		Type:    notify.EventCompleted,
+		TaskID:  ctx.TaskID,
+		Repo:    ctx.GetRepositoryFullName(),
+		Number:  ctx.GetIssueNumber(),
+		IsPR:    ctx.IsPRContext(),
+		Actor:   ctx.GetTriggerUser(),
+		Summary: summary,
+		CostUSD: costUSD,
+	}
+	if failed {
+		ev.Type = notify.EventFailed
+		ev.Error = detail
+	}
+	e.notifier.Notify(ev)
+}
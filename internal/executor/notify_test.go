@@ -0,0 +1,44 @@
+package executor
+
+import (
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"github.com/cexll/swe/internal/notify"
+)
+
+func TestExecutorNotifyResult(t *testing.T) {
+	received := make(chan map[string]interface{}, 2)
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		var body map[string]interface{}
+		_ = json.NewDecoder(r.Body).Decode(&body)
+		received <- body
+	}))
+	defer srv.Close()
+
+	n := notify.NewManager(notify.Route{Notifier: &notify.WebhookNotifier{URL: srv.URL}})
+	e := &Executor{}
+	e.SetNotifier(n)
+
+	ctx := buildTestCtx(true)
+	ctx.TaskID = "task-1"
+	e.notifyResult(ctx, "", 0, "provider claude: boom", true)
+	n.Wait()
+
+	body := <-received
+	if body["type"] != "failed" || body["error"] != "provider claude: boom" || body["task_id"] != "task-1" {
+		t.Fatalf("failed payload = %v", body)
+	}
+	if body["url"] != "https://github.com/owner/repo/pull/2" {
+		t.Fatalf("url = %v", body["url"])
+	}
+
+	e.notifyResult(buildTestCtx(false), "done", 1.5, "", false)
+	n.Wait()
+	body = <-received
+	if body["type"] != "completed" || body["summary"] != "done" || body["cost_usd"] != 1.5 {
+		t.Fatalf("completed payload = %v", body)
+	}
+}
@@ -0,0 +1,52 @@
+package executor
+
+import (
+	"context"
+
+	"github.com/cexll/swe/internal/github"
+	"github.com/cexll/swe/internal/github/comment"
+	ghdata "github.com/cexll/swe/internal/github/data"
+)
+
+// commentsIface lists and minimizes issue and pull request comments
+// (*ghdata.Client).
+type commentsIface interface {
+	RecentComments(ctx context.Context, repo string, number int) ([]ghdata.IssueComment, error)
+	MinimizeComment(ctx context.Context, repo, nodeID string) error
+}
+
+// SetMinimizeOutdated makes subsequent tasks hide the earlier tracking
+// comments on their issue or pull request as outdated.
+func (e *Executor) SetMinimizeOutdated(enabled bool) {
+	e.mu.Lock()
+	defer e.mu.Unlock()
+	e.minimizeOutdated = enabled
+}
+
+// minimizeOutdatedComments hides the tracking comments of earlier tasks on
+// ctx's issue or pull request, leaving the starting task's the only one
+// shown. Comments by others and the app's other replies stay as they are.
+func (e *Executor) minimizeOutdatedComments(ctx context.Context, ghCtx *github.Context, repo string) {
+	if !e.minimizeOutdated || e.comments == nil || ghCtx.PreparedCommentID <= 0 {
+		return
+	}
+	comments, err := e.comments.RecentComments(ctx, repo, ghCtx.GetIssueNumber())
+	if err != nil {
+		taskLog(ghCtx, "comment").Warn("Listing comments to minimize failed", "err", err)
+		return
+	}
+	n := 0
+	for _, c := range comments {
+		if !c.ViewerDidAuthor || c.IsMinimized || c.DatabaseID == ghCtx.PreparedCommentID || !comment.IsTracking(c.Body) {
+			continue
+		}
+		if err := e.comments.MinimizeComment(ctx, repo, c.ID); err != nil {
+			taskLog(ghCtx, "comment").Warn("Minimizing comment failed", "err", err)
+			continue
+		}
+		n++
+	}
+	if n > 0 {
+		taskLog(ghCtx, "comment").Info("Minimized outdated tracking comments", "count", n, "number", ghCtx.GetIssueNumber())
+	}
+}
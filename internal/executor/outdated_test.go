@@ -0,0 +1,57 @@
+package executor
+
+import (
+	"context"
+	"errors"
+	"strings"
+	"testing"
+
+	"github.com/cexll/swe/internal/github/comment"
+	ghdata "github.com/cexll/swe/internal/github/data"
+)
+
+type mockComments struct {
+	comments  []ghdata.IssueComment
+	listErr   error
+	minimized []string
+}
+
+func (m *mockComments) RecentComments(_ context.Context, _ string, _ int) ([]ghdata.IssueComment, error) {
+	return m.comments, m.listErr
+}
+
+func (m *mockComments) MinimizeComment(_ context.Context, _, nodeID string) error {
+	m.minimized = append(m.minimized, nodeID)
+	return nil
+}
+
+func TestMinimizeOutdatedComments(t *testing.T) {
+	tracking := comment.WithFooter("Done.", "v1.0.0")
+	comments := &mockComments{comments: []ghdata.IssueComment{
+		{ID: "IC_old", DatabaseID: 1, Body: tracking, ViewerDidAuthor: true},
+		{ID: "IC_user", DatabaseID: 2, Body: "/code again " + tracking},
+		{ID: "IC_reply", DatabaseID: 3, Body: "Unknown flag `--dryrun`.", ViewerDidAuthor: true},
+		{ID: "IC_hidden", DatabaseID: 4, Body: tracking, ViewerDidAuthor: true, IsMinimized: true},
+		{ID: "IC_current", DatabaseID: 5, Body: comment.InitialBody(comment.English), ViewerDidAuthor: true},
+	}}
+	e := New(&mockProvider{}, &mockAuthProvider{})
+	e.comments = comments
+	ctx := buildTestCtx(false)
+	ctx.PreparedCommentID = 5
+
+	e.minimizeOutdatedComments(context.Background(), ctx, "owner/repo")
+	if len(comments.minimized) != 0 {
+		t.Fatalf("minimized while disabled: %v", comments.minimized)
+	}
+	e.SetMinimizeOutdated(true)
+	e.minimizeOutdatedComments(context.Background(), ctx, "owner/repo")
+	if strings.Join(comments.minimized, ",") != "IC_old" {
+		t.Fatalf("minimized = %v, want only the earlier tracking comment", comments.minimized)
+	}
+
+	comments.minimized, comments.listErr = nil, errors.New("boom")
+	e.minimizeOutdatedComments(context.Background(), ctx, "owner/repo")
+	if len(comments.minimized) != 0 {
+		t.Fatalf("minimized after a failed lookup: %v", comments.minimized)
+	}
+}
@@ -0,0 +1,44 @@
+package executor
+
+import (
+	"github.com/cexll/swe/internal/github"
+	ghdata "github.com/cexll/swe/internal/github/data"
+)
+
+// allow tests to stub the pull request file listing
+var listPullRequestFiles = github.ListPullRequestFiles
+
+// SetPRDiffLimit embeds the diff of pull requests changing at most lines
+// lines in subsequent tasks' prompts (0 embeds none).
+func (e *Executor) SetPRDiffLimit(lines int) {
+	e.mu.Lock()
+	defer e.mu.Unlock()
+	e.prDiffMaxLines = lines
+}
+
+// attachPRDiff adds the diff hunks of ctx's pull request to fetched when
+// it changes at most e.prDiffMaxLines lines, so a review does not start by
+// reading every changed file. Failures are logged: the prompt still lists
+// the changed files.
+func (e *Executor) attachPRDiff(ctx *github.Context, fetched *ghdata.FetchResult) {
+	if e.prDiffMaxLines <= 0 || fetched == nil || !ctx.IsPRContext() {
+		return
+	}
+	pr, ok := fetched.ContextData.(ghdata.PullRequest)
+	if !ok || pr.Additions+pr.Deletions > e.prDiffMaxLines {
+		return
+	}
+	files, err := listPullRequestFiles(ctx.Repository.Owner, ctx.Repository.Name, ctx.GetPRNumber(), ctx.Token)
+	if err != nil {
+		taskLog(ctx, "prompt").Warn("Fetching pull request diff failed", "number", ctx.GetPRNumber(), "err", err)
+		return
+	}
+	patches := make(map[string]string, len(files))
+	for _, f := range files {
+		if f.Patch != "" {
+			patches[f.Filename] = f.Patch
+		}
+	}
+	fetched.Patches = patches
+	taskLog(ctx, "prompt").Info("Embedding pull request diff", "number", ctx.GetPRNumber(), "files", len(patches), "lines_changed", pr.Additions+pr.Deletions)
+}
@@ -0,0 +1,57 @@
+package executor
+
+import (
+	"context"
+	"strings"
+	"testing"
+
+	"github.com/cexll/swe/internal/github"
+	ghdata "github.com/cexll/swe/internal/github/data"
+	"github.com/cexll/swe/internal/provider"
+)
+
+func TestExecute_EmbedsDiffOfSmallPRs(t *testing.T) {
+	origClone, origRun, origList := cloneRepo, runCmd, listPullRequestFiles
+	t.Cleanup(func() { cloneRepo, runCmd, listPullRequestFiles = origClone, origRun, origList })
+	cloneRepo = func(_ context.Context, repo, branch, token string) (string, func(), error) {
+		return t.TempDir(), func() {}, nil
+	}
+	runCmd = func(name string, args ...string) error { return nil }
+	var listed []int
+	listPullRequestFiles = func(owner, repo string, number int, token string) ([]github.PullRequestFile, error) {
+		listed = append(listed, number)
+		return []github.PullRequestFile{
+			{Filename: "parser.go", Status: "modified", Additions: 1, Deletions: 1, Patch: "@@ -1 +1 @@\n-old\n+new"},
+			{Filename: "logo.png", Status: "added"},
+		}, nil
+	}
+
+	var prompts []string
+	mp := &mockProvider{generateFunc: func(ctx context.Context, req *provider.CodeRequest) (*provider.CodeResponse, error) {
+		prompts = append(prompts, req.Prompt)
+		return &provider.CodeResponse{Summary: "done"}, nil
+	}}
+	ex := New(mp, &mockAuthProvider{})
+	ex.SetHeartbeatInterval(0)
+	changedLines := 2
+	ex.fetcher = &mockFetcher{fetchFunc: func(ctx context.Context, gctx *github.Context) (*ghdata.FetchResult, error) {
+		return &ghdata.FetchResult{ContextData: ghdata.PullRequest{Title: "Fix parser", Additions: changedLines / 2, Deletions: changedLines / 2}}, nil
+	}}
+	ex.SetPRDiffLimit(100)
+
+	for _, lines := range []int{2, 400} {
+		changedLines = lines
+		if err := ex.Execute(context.Background(), buildTestCtx(true)); err != nil {
+			t.Fatal(err)
+		}
+	}
+	if len(listed) != 1 || listed[0] != 2 {
+		t.Fatalf("listed the files of PRs %v, want only the small one", listed)
+	}
+	if !strings.Contains(prompts[0], "<changed_files_diff>\ndiff --git a/parser.go b/parser.go\n@@ -1 +1 @@\n-old\n+new\n</changed_files_diff>") {
+		t.Fatalf("diff missing:\n%s", prompts[0])
+	}
+	if strings.Contains(prompts[0], "logo.png") || strings.Contains(prompts[1], "<changed_files_diff>") {
+		t.Fatal("diff of a binary file or a large PR embedded")
+	}
+}
@@ -0,0 +1,171 @@
+package executor
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"path/filepath"
+	"strings"
+
+	"github.com/cexll/swe/internal/artifacts"
+	"github.com/cexll/swe/internal/github"
+	ghdata "github.com/cexll/swe/internal/github/data"
+	"github.com/cexll/swe/internal/prompt"
+	"github.com/cexll/swe/internal/reposettings"
+)
+
+// builtinTemplate names the built-in prompt template in a PromptRecord.
+const builtinTemplate = "built-in"
+
+// SetPromptTemplateDir sets the directory of prompt template overrides used
+// by subsequent tasks whose repository has none of its own ("" uses the
+// built-in prompt).
+func (e *Executor) SetPromptTemplateDir(dir string) {
+	e.mu.Lock()
+	defer e.mu.Unlock()
+	e.promptDir = dir
+}
+
+// SetContextBudget bounds the GitHub context of subsequent tasks' prompts
+// to about tokens, leaving out the oldest comments and the longest file
+// lists first (0 keeps it whole).
+func (e *Executor) SetContextBudget(tokens int) {
+	e.mu.Lock()
+	defer e.mu.Unlock()
+	e.contextTokens = tokens
+}
+
+// promptTemplate returns the prompt template override for a task in mode:
+// the repository's own (prompt.RepoTemplateDir in workdir) before the
+// operator's in dir. nil uses the built-in prompt.
+func promptTemplate(workdir, dir, mode string) *prompt.Template {
+	t, problems := loadPromptTemplate(workdir, dir, mode)
+	for _, err := range problems {
+		phaseLog("prompt").Warn("Ignoring template override", "err", err)
+	}
+	if t != nil {
+		phaseLog("prompt").Info("Using prompt template", "path", t.Path)
+	}
+	return t
+}
+
+// loadPromptTemplate is promptTemplate returning the overrides it skipped
+// instead of logging them.
+func loadPromptTemplate(workdir, dir, mode string) (*prompt.Template, []error) {
+	var repoDir string
+	if workdir != "" {
+		repoDir = filepath.Join(workdir, filepath.FromSlash(prompt.RepoTemplateDir))
+	}
+	return prompt.LoadTemplate(mode, repoDir, dir)
+}
+
+// templateName names t for a PromptRecord: relative to workdir when the
+// repository provides it.
+func templateName(workdir string, t *prompt.Template) string {
+	if t == nil {
+		return builtinTemplate
+	}
+	if workdir != "" {
+		if rel, err := filepath.Rel(workdir, t.Path); err == nil && !strings.HasPrefix(rel, "..") {
+			return filepath.ToSlash(rel)
+		}
+	}
+	return t.Path
+}
+
+// PromptRecord is a task's prompt as handed to the provider, kept as its
+// artifacts.Prompt artifact.
+type PromptRecord struct {
+	TaskID   string `json:"task_id,omitempty"`
+	Provider string `json:"provider,omitempty"`
+	// Template is the prompt template override used, or "built-in"
+	Template string `json:"template"`
+	// System is the whole prompt: the rendered template with the GitHub
+	// context and the sections added after it. The providers get it as one
+	// text; there is no separate system message.
+	System string `json:"system"`
+	// User is the request within it, the trigger comment
+	User string `json:"user"`
+}
+
+// savePromptRecord keeps the prompt of ctx's task for operators.
+func (e *Executor) savePromptRecord(ctx context.Context, ghCtx *github.Context, template, text string) {
+	if !e.wantsArtifacts(ghCtx) {
+		return
+	}
+	var buf bytes.Buffer
+	enc := json.NewEncoder(&buf)
+	enc.SetEscapeHTML(false)
+	enc.SetIndent("", "  ")
+	err := enc.Encode(PromptRecord{
+		TaskID:   ghCtx.TaskID,
+		Provider: e.provider.Name(),
+		Template: template,
+		System:   text,
+		User:     ghCtx.GetTriggerCommentBody(),
+	})
+	if err != nil {
+		taskLog(ghCtx, "artifacts").Warn("Encoding prompt failed", "err", err)
+		return
+	}
+	e.saveArtifact(ctx, ghCtx, artifacts.Prompt, buf.Bytes())
+}
+
+// PromptPreview is what RenderPrompt renders a prompt for.
+type PromptPreview struct {
+	Context *github.Context
+	// Fetched is the issue or pull request (nil renders an empty one)
+	Fetched *ghdata.FetchResult
+	// Checkout is the repository's checkout, for its prompt templates and
+	// issue and pull request templates ("" has none)
+	Checkout string
+	// PromptDir holds the operator's prompt template overrides
+	PromptDir string
+	// Settings add the repository's instructions (nil adds none)
+	Settings *reposettings.Set
+	// MaxContextTokens bounds the GitHub context (0 keeps it whole)
+	MaxContextTokens int
+	// MaxRepoFiles bounds the file list of Checkout (0 lists none)
+	MaxRepoFiles int
+}
+
+// RenderPrompt renders the prompt a task for p would start with: the prompt
+// template with the checkout's file list, and the sections for the
+// repository's issue and pull request templates and instructions. The
+// sections that depend on the run itself (branch protection, wiki, related
+// earlier tasks, capabilities) are left out. Template overrides that failed
+// validation, and were skipped, and a failure to list the checkout's files
+// are returned as errors.
+func RenderPrompt(p PromptPreview) (*PromptRecord, []error) {
+	fetched := p.Fetched
+	if fetched == nil {
+		fetched = &ghdata.FetchResult{ContextData: ghdata.Issue{}}
+		if p.Context.IsPRContext() {
+			fetched.ContextData = ghdata.PullRequest{}
+		}
+	}
+	settings := p.Settings.For(p.Context.Repository.FullName)
+	tmpl, problems := loadPromptTemplate(p.Checkout, p.PromptDir, prompt.ModeOf(p.Context))
+	opts := prompt.Options{RepoPath: p.Checkout, Template: tmpl, MaxContextTokens: p.MaxContextTokens}
+	if p.Checkout != "" {
+		files, omitted, err := ListRepoFiles(p.Checkout, settings.FileListExclude, fetchedPaths(fetched), p.MaxRepoFiles)
+		if err != nil {
+			problems = append(problems, err)
+		}
+		opts.RepoFiles, opts.RepoFilesOmitted = files, omitted
+	}
+	text := prompt.BuildPromptWith(p.Context, fetched, opts)
+	if p.Checkout != "" {
+		if section := templatesPromptSection(findRepoTemplates(p.Checkout)); section != "" {
+			text += "\n\n" + section
+		}
+	}
+	if section := reposettings.PromptSection(settings.Instructions); section != "" {
+		text += "\n\n" + section
+	}
+	return &PromptRecord{
+		Template: templateName(p.Checkout, tmpl),
+		System:   text,
+		User:     p.Context.GetTriggerCommentBody(),
+	}, problems
+}
@@ -0,0 +1,117 @@
+package executor
+
+import (
+	"context"
+	"encoding/json"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"github.com/cexll/swe/internal/artifacts"
+	"github.com/cexll/swe/internal/github"
+	ghdata "github.com/cexll/swe/internal/github/data"
+	"github.com/cexll/swe/internal/provider"
+	"github.com/cexll/swe/internal/storage"
+)
+
+func TestExecute_PromptTemplateOverrides(t *testing.T) {
+	origClone, origRun := cloneRepo, runCmd
+	t.Cleanup(func() { cloneRepo, runCmd = origClone, origRun })
+	var repoTemplate string
+	cloneRepo = func(_ context.Context, repo, branch, token string) (string, func(), error) {
+		dir := t.TempDir()
+		if repoTemplate != "" {
+			prompts := filepath.Join(dir, ".swe-agent", "prompts")
+			if err := os.MkdirAll(prompts, 0o755); err != nil {
+				return "", nil, err
+			}
+			if err := os.WriteFile(filepath.Join(prompts, "issue.tmpl"), []byte(repoTemplate), 0o644); err != nil {
+				return "", nil, err
+			}
+		}
+		return dir, func() {}, nil
+	}
+	runCmd = func(name string, args ...string) error { return nil }
+
+	var prompts []string
+	mp := &mockProvider{generateFunc: func(ctx context.Context, req *provider.CodeRequest) (*provider.CodeResponse, error) {
+		prompts = append(prompts, req.Prompt)
+		return &provider.CodeResponse{Summary: "done"}, nil
+	}}
+	ex := New(mp, &mockAuthProvider{})
+	ex.SetHeartbeatInterval(0)
+	ex.fetcher = &mockFetcher{fetchFunc: func(ctx context.Context, gctx *github.Context) (*ghdata.FetchResult, error) {
+		return &ghdata.FetchResult{ContextData: ghdata.Issue{Title: "Parser crash", State: "open"}}, nil
+	}}
+	server := t.TempDir()
+	if err := os.WriteFile(filepath.Join(server, "system.tmpl"), []byte("Operator prompt for {{.Repository}}#{{.Number}}\n{{.GitHubContext}}"), 0o644); err != nil {
+		t.Fatal(err)
+	}
+	ex.SetPromptTemplateDir(server)
+	store := artifacts.New(&storage.Local{Dir: t.TempDir()}, 0)
+	ex.SetArtifacts(store)
+
+	for i, tmpl := range []string{"", "Repository prompt ({{.Mode}})\n{{.GitHubContext}}", "{{.Broken"} {
+		repoTemplate = tmpl
+		ctx := buildTestCtx(false)
+		ctx.TaskID = "task-" + string(rune('1'+i))
+		if err := ex.Execute(context.Background(), ctx); err != nil {
+			t.Fatal(err)
+		}
+	}
+	if !strings.HasPrefix(prompts[0], "Operator prompt for owner/repo#1\n") {
+		t.Fatalf("operator template not used:\n%s", prompts[0])
+	}
+	if !strings.HasPrefix(prompts[1], "Repository prompt (issue)\n") || !strings.Contains(prompts[1], "Parser crash") {
+		t.Fatalf("repository template not used:\n%s", prompts[1])
+	}
+	// an invalid repository template falls back to the operator's
+	if !strings.HasPrefix(prompts[2], "Operator prompt for owner/repo#1\n") {
+		t.Fatalf("invalid repository template not skipped:\n%s", prompts[2])
+	}
+	// sections added after the template are kept
+	if !strings.Contains(prompts[1], "<capabilities>") {
+		t.Fatalf("capabilities section missing:\n%s", prompts[1])
+	}
+
+	// the prompt each task got is kept for operators
+	rc, err := store.Open(context.Background(), "task-2", artifacts.Prompt)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer rc.Close()
+	var record PromptRecord
+	if err := json.NewDecoder(rc).Decode(&record); err != nil {
+		t.Fatal(err)
+	}
+	if record.TaskID != "task-2" || record.Template != ".swe-agent/prompts/issue.tmpl" || record.System != prompts[1] || record.User != "/code do it" {
+		t.Fatalf("record = %+v", record)
+	}
+}
+
+func TestRenderPrompt(t *testing.T) {
+	checkout := t.TempDir()
+	dir := filepath.Join(checkout, ".swe-agent", "prompts")
+	if err := os.MkdirAll(dir, 0o755); err != nil {
+		t.Fatal(err)
+	}
+	if err := os.WriteFile(filepath.Join(dir, "pr.tmpl"), []byte("Review {{.Repository}}#{{.Number}} on {{.CurrentBranch}}\n{{.GitHubContext}}"), 0o644); err != nil {
+		t.Fatal(err)
+	}
+
+	ctx := buildTestCtx(true)
+	record, problems := RenderPrompt(PromptPreview{Context: ctx, Checkout: checkout})
+	if len(problems) != 0 {
+		t.Fatalf("problems = %v", problems)
+	}
+	if record.Template != ".swe-agent/prompts/pr.tmpl" || !strings.HasPrefix(record.System, "Review owner/repo#2 on ") {
+		t.Fatalf("record = %+v", record)
+	}
+
+	// issues have no override here
+	record, _ = RenderPrompt(PromptPreview{Context: buildTestCtx(false), Checkout: checkout})
+	if record.Template != builtinTemplate || !strings.Contains(record.System, "<formatted_context>") {
+		t.Fatalf("record = %+v", record)
+	}
+}
@@ -0,0 +1,118 @@
+package executor
+
+import (
+	"fmt"
+	"os"
+	"path/filepath"
+	"strings"
+
+	"github.com/cexll/swe/internal/github"
+	"github.com/cexll/swe/internal/github/comment"
+	"github.com/cexll/swe/internal/logging"
+)
+
+// allow tests to stub branch protection lookups
+var branchProtected = github.IsBranchProtected
+
+// protectedBranches returns those of branches that have protection rules.
+// Lookup failures are logged and the branch treated as unprotected; GitHub
+// still enforces its rules when the push arrives.
+func protectedBranches(owner, repo, token string, branches ...string) []string {
+	var out []string
+	seen := make(map[string]bool)
+	for _, b := range branches {
+		if b == "" || seen[b] {
+			continue
+		}
+		seen[b] = true
+		ok, err := branchProtected(owner, repo, b, token)
+		if err != nil {
+			phaseLog("protect").Warn("Branch protection lookup failed", logging.KeyRepo, owner+"/"+repo, "branch", b, "err", err)
+			continue
+		}
+		if ok {
+			out = append(out, b)
+		}
+	}
+	return out
+}
+
+// installPushGuard writes a pre-push hook into workdir that rejects pushes to
+// the protected branches and points at target instead.
+func installPushGuard(workdir string, protected []string, target string) error {
+	refs := make([]string, len(protected))
+	for i, b := range protected {
+		refs[i] = shellQuote("refs/heads/" + b)
+	}
+	hook := fmt.Sprintf(`#!/bin/sh
+# Installed by swe-agent: protected branches only change through pull requests.
+target=%s
+while read -r local_ref local_sha remote_ref remote_sha; do
+	case "$remote_ref" in
+	%s)
+		echo "swe-agent: $remote_ref is protected; push to $target instead" >&2
+		exit 1
+		;;
+	esac
+done
+exit 0
+`, shellQuote(target), strings.Join(refs, "|"))
+
+	path, err := gitPath(workdir, "hooks/pre-push")
+	if err != nil {
+		return fmt.Errorf("install push guard: %w", err)
+	}
+	if err := os.MkdirAll(filepath.Dir(path), 0o755); err != nil {
+		return fmt.Errorf("install push guard: %w", err)
+	}
+	if err := os.WriteFile(path, []byte(hook), 0o755); err != nil {
+		return fmt.Errorf("install push guard: %w", err)
+	}
+	return nil
+}
+
+// shellQuote single-quotes s for /bin/sh.
+func shellQuote(s string) string {
+	return "'" + strings.ReplaceAll(s, "'", `'\''`) + "'"
+}
+
+// shellPath quotes the file path p for /bin/sh, with forward slashes, which
+// the sh of Git for Windows takes as well.
+func shellPath(p string) string {
+	return shellQuote(filepath.ToSlash(p))
+}
+
+// protectionPromptSection tells the provider why it works on a new branch.
+func protectionPromptSection(from, to string) string {
+	return fmt.Sprintf(`<branch_protection>
+%[1]s is a protected branch, so this task works on the new branch %[2]s instead (already checked out).
+Push only to %[2]s (git push origin HEAD); pushes to protected branches are rejected. To land the changes in %[1]s, open a pull request from %[2]s into %[1]s.
+</branch_protection>`, from, to)
+}
+
+// reportRedirect prepends a note to the tracking comment when the changes
+// were pushed to a new branch because the requested one is protected.
+func (e *Executor) reportRedirect(ctx *github.Context, workdir, from, to string) {
+	if refs, err := gitLsRemoteHeads(workdir, to); err != nil || len(refs) == 0 {
+		return
+	}
+	taskLog(ctx, "protect").Info("Branch is protected; changes were pushed to another branch", "branch", from, "pushed_to", to)
+	prependNotice(ctx, "> [!NOTE]\n> "+commentText(ctx, comment.MsgProtectedBranch, from, to))
+}
+
+// prependNotice puts notice above the current body of the tracking comment.
+func prependNotice(ctx *github.Context, notice string) {
+	if ctx.PreparedCommentID <= 0 || ctx.Token == "" {
+		return
+	}
+	owner, repo := ctx.GetRepositoryOwner(), ctx.GetRepositoryName()
+	body, err := getComment(owner, repo, ctx.PreparedCommentID, ctx.Token)
+	if err != nil {
+		taskLog(ctx, "comment").Warn("Reading tracking comment failed", "err", err)
+	} else if body != "" {
+		notice += "\n\n---\n\n" + body
+	}
+	if err := updateComment(owner, repo, ctx.PreparedCommentID, notice, ctx.Token); err != nil {
+		taskLog(ctx, "comment").Warn("Updating tracking comment failed", "err", err)
+	}
+}
@@ -0,0 +1,130 @@
+package executor
+
+import (
+	"context"
+	"errors"
+	"os"
+	"os/exec"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"github.com/cexll/swe/internal/github"
+	ghdata "github.com/cexll/swe/internal/github/data"
+	"github.com/cexll/swe/internal/provider"
+)
+
+func stubProtected(t *testing.T, branches ...string) {
+	t.Helper()
+	orig := branchProtected
+	t.Cleanup(func() { branchProtected = orig })
+	branchProtected = func(_, _, branch, _ string) (bool, error) {
+		if branch == "flaky" {
+			return false, errors.New("api unavailable")
+		}
+		for _, b := range branches {
+			if b == branch {
+				return true, nil
+			}
+		}
+		return false, nil
+	}
+}
+
+func TestProtectedBranches(t *testing.T) {
+	stubProtected(t, "main", "release")
+	got := protectedBranches("o", "r", "tok", "release", "flaky", "main", "main", "", "dev")
+	if strings.Join(got, ",") != "release,main" {
+		t.Fatalf("protectedBranches = %v", got)
+	}
+}
+
+func TestInstallPushGuard(t *testing.T) {
+	workdir, remote := initPushRepo(t)
+	if err := installPushGuard(workdir, []string{"main", "it's"}, "swe-agent/1-1"); err != nil {
+		t.Fatalf("installPushGuard: %v", err)
+	}
+	mainBefore := gitIn(t, remote, "rev-parse", "main")
+	commitAndPush(t, workdir, "swe-agent/1-1", "change\n")
+
+	for _, ref := range []string{"main", "it's"} {
+		out, err := exec.Command("git", "-C", workdir, "push", "origin", "HEAD:refs/heads/"+ref).CombinedOutput()
+		if err == nil || !strings.Contains(string(out), "push to swe-agent/1-1 instead") {
+			t.Fatalf("push to %s should be rejected: %v\n%s", ref, err, out)
+		}
+	}
+	if gitIn(t, remote, "rev-parse", "main") != mainBefore {
+		t.Fatal("protected branch changed")
+	}
+}
+
+func TestExecute_RedirectsProtectedBranch(t *testing.T) {
+	workdir, remote := initPushRepo(t)
+	gitIn(t, workdir, "checkout", "-q", "-b", "release")
+	commitAndPush(t, workdir, "release", "release content\n")
+	gitIn(t, workdir, "checkout", "-q", "main")
+	releaseHead := gitIn(t, remote, "rev-parse", "release")
+
+	stubProtected(t, "release")
+	updated := stubComments(t, "Done.")
+	origClone, origRun := cloneRepo, runCmd
+	t.Cleanup(func() { cloneRepo, runCmd = origClone, origRun })
+	cloneRepo = func(_ context.Context, repo, branch, token string) (string, func(), error) {
+		return workdir, func() {}, nil
+	}
+	runCmd = func(name string, args ...string) error {
+		if len(args) > 3 && args[2] == "remote" && args[3] == "set-url" {
+			return nil // keep the local remote
+		}
+		return run(name, args...)
+	}
+
+	var prompt, readme, rejected string
+	e := New(&mockProvider{generateFunc: func(_ context.Context, req *provider.CodeRequest) (*provider.CodeResponse, error) {
+		prompt = req.Prompt
+		data, _ := os.ReadFile(filepath.Join(workdir, "README.md"))
+		readme = string(data)
+		if err := os.WriteFile(filepath.Join(workdir, "README.md"), []byte("agent change\n"), 0o644); err != nil {
+			return nil, err
+		}
+		if out, err := exec.Command("git", "-C", workdir, "commit", "-q", "-am", "agent change").CombinedOutput(); err != nil {
+			return nil, errors.New(string(out))
+		}
+		out, _ := exec.Command("git", "-C", workdir, "push", "origin", "HEAD:release").CombinedOutput()
+		rejected = string(out)
+		if out, err := exec.Command("git", "-C", workdir, "push", "-q", "origin", "HEAD").CombinedOutput(); err != nil {
+			return nil, errors.New(string(out))
+		}
+		return &provider.CodeResponse{Summary: "ok"}, nil
+	}}, &mockAuthProvider{})
+	e.fetcher = &mockFetcher{fetchFunc: func(context.Context, *github.Context) (*ghdata.FetchResult, error) {
+		return &ghdata.FetchResult{ContextData: ghdata.PullRequest{HeadRefName: "release", BaseRefName: "main"}}, nil
+	}}
+
+	ctx := buildTestCtx(true)
+	ctx.PreparedCommentID = 7
+	if err := e.Execute(context.Background(), ctx); err != nil {
+		t.Fatalf("Execute: %v", err)
+	}
+
+	branch := ctx.PreparedBranch
+	if !strings.HasPrefix(branch, "swe-agent/2-") {
+		t.Fatalf("PreparedBranch = %q, want a new agent branch", branch)
+	}
+	if readme != "release content\n" {
+		t.Fatalf("new branch should start from the protected branch, README = %q", readme)
+	}
+	if !strings.Contains(prompt, "<branch_protection>") || !strings.Contains(prompt, "pull request from "+branch+" into release") {
+		t.Fatalf("prompt lacks branch protection note:\n%s", prompt)
+	}
+	if !strings.Contains(rejected, "is protected") || gitIn(t, remote, "rev-parse", "release") != releaseHead {
+		t.Fatalf("push to the protected branch should be rejected: %q", rejected)
+	}
+	if gitIn(t, remote, "show", branch+":README.md") != "agent change" {
+		t.Fatal("agent branch was not pushed")
+	}
+	if !strings.HasPrefix(*updated, "> [!NOTE]\n> `release` is a protected branch, so the changes were pushed to `"+branch+"` instead.") ||
+		!strings.HasSuffix(*updated, "Done.") {
+		t.Fatalf("tracking comment = %q", *updated)
+	}
+}
@@ -0,0 +1,228 @@
+package executor
+
+import (
+	"bufio"
+	"bytes"
+	"fmt"
+	"io"
+	"os"
+	"os/exec"
+	"path"
+	"strings"
+
+	"github.com/cexll/swe/internal/github"
+)
+
+// PushCheckCommand is the hidden subcommand the git guard's pre-push hook
+// runs (as os.Executable) to check the commits being pushed.
+const PushCheckCommand = "check-push"
+
+// blockedPathReason is the git guard log reason for pushes that change a
+// blocked path.
+const blockedPathReason = "blocked path"
+
+// DefaultBlockedPaths are the paths the agent may not change unless
+// configured otherwise.
+var DefaultBlockedPaths = []string{".github/workflows"}
+
+// SetBlockedPaths configures the repository paths (directories, files or
+// path.Match globs) pushes may not change; none allows every path.
+func (e *Executor) SetBlockedPaths(paths []string) {
+	e.mu.Lock()
+	defer e.mu.Unlock()
+	e.blockedPaths = paths
+}
+
+// isBlockedPath reports whether file is, or is under, one of paths.
+func isBlockedPath(file string, paths []string) bool {
+	for _, p := range paths {
+		p = strings.Trim(p, "/")
+		if p == "" {
+			continue
+		}
+		if file == p || strings.HasPrefix(file, p+"/") {
+			return true
+		}
+		if ok, _ := path.Match(p, file); ok {
+			return true
+		}
+	}
+	return false
+}
+
+// pushRevisions turns the ref updates git passes to a pre-push hook (one
+// "local_ref local_sha remote_ref remote_sha" per line) into git log
+// revision arguments selecting the commits each update sends.
+func pushRevisions(updates io.Reader) ([][]string, error) {
+	var out [][]string
+	sc := bufio.NewScanner(updates)
+	for sc.Scan() {
+		fields := strings.Fields(sc.Text())
+		if len(fields) != 4 || strings.Trim(fields[1], "0") == "" {
+			continue // deletions push no content
+		}
+		if strings.Trim(fields[3], "0") != "" {
+			out = append(out, []string{fields[1], "^" + fields[3]})
+		} else {
+			out = append(out, []string{fields[1], "--not", "--remotes"})
+		}
+	}
+	return out, sc.Err()
+}
+
+// changedPaths lists the files the commits revs select touch.
+func changedPaths(workdir string, revs []string) ([]string, error) {
+	args := append([]string{"-C", workdir, "log", "--no-renames", "--name-only", "-z", "--format="}, revs...)
+	out, err := gitCapture(args...)
+	if err != nil {
+		return nil, err
+	}
+	return gitPaths(out.String()), nil
+}
+
+func gitCapture(args ...string) (bytes.Buffer, error) {
+	var out, stderr bytes.Buffer
+	cmd := exec.Command("git", args...)
+	cmd.Stdout, cmd.Stderr = &out, &stderr
+	if err := cmd.Run(); err != nil {
+		return out, fmt.Errorf("git %s: %v\n%s", strings.Join(args, " "), err, stderr.String())
+	}
+	return out, nil
+}
+
+// RunPushCheck implements the check-push subcommand: args are the secret
+// rules and blocked paths files written by the git guard, the guard's
+// blocked log and, optionally, its push policy, policy input and held
+// decision files. It reads the pre-push updates from stdin and exits 1 when
+// the commits change a blocked path or contain secrets, or when a push rule
+// denies or holds them.
+func RunPushCheck(args []string, stdin io.Reader, stderr io.Writer) int {
+	if len(args) != 3 && len(args) != 6 {
+		_, _ = fmt.Fprintf(stderr, "usage: %s RULES_FILE PATHS_FILE LOG_FILE [POLICY_FILE INPUT_FILE HOLD_FILE]\n", PushCheckCommand)
+		return 2
+	}
+	rules, err := readRuleFile(args[0])
+	if err != nil {
+		_, _ = fmt.Fprintf(stderr, "swe-agent: push check: %v\n", err)
+		return 1
+	}
+	blocked, err := readLines(args[1])
+	if err != nil {
+		_, _ = fmt.Fprintf(stderr, "swe-agent: push check: %v\n", err)
+		return 1
+	}
+	ranges, err := pushRevisions(stdin)
+	if err != nil {
+		_, _ = fmt.Fprintf(stderr, "swe-agent: push check: %v\n", err)
+		return 1
+	}
+
+	var entries, paths []string
+	var findings []secretFinding
+	seen := make(map[string]bool)
+	for _, revs := range ranges {
+		if len(blocked) > 0 {
+			files, err := changedPaths(".", revs)
+			if err != nil {
+				_, _ = fmt.Fprintf(stderr, "swe-agent: push check: %v\n", err)
+				return 1
+			}
+			for _, f := range files {
+				if isBlockedPath(f, blocked) && !seen[f] {
+					seen[f] = true
+					paths = append(paths, f)
+					entries = append(entries, fmt.Sprintf("%s\tgit push (%s)", blockedPathReason, f))
+				}
+			}
+		}
+		found, err := scanCommits(".", revs, rules)
+		if err != nil {
+			_, _ = fmt.Fprintf(stderr, "swe-agent: secret scan: %v\n", err)
+			return 1
+		}
+		for _, f := range found {
+			findings = append(findings, f)
+			entries = append(entries, fmt.Sprintf("%s\tgit push (%s)", blockedSecret, f))
+		}
+	}
+	if len(entries) == 0 && len(args) == 6 {
+		// the push is clean; the policy decides whether it goes out now
+		refused, err := checkPushPolicy(args[3], args[4], args[5], ranges, stderr)
+		if err != nil {
+			_, _ = fmt.Fprintf(stderr, "swe-agent: push policy: %v\n", err)
+			return 1
+		}
+		if len(refused) > 0 {
+			appendGuardLog(args[2], refused)
+			return 1
+		}
+	}
+	if len(entries) == 0 {
+		return 0
+	}
+	appendGuardLog(args[2], entries)
+	if len(paths) > 0 {
+		_, _ = fmt.Fprintln(stderr, "swe-agent: push blocked, the commits change files the agent may not modify:")
+		for _, f := range paths {
+			_, _ = fmt.Fprintf(stderr, "  %s\n", f)
+		}
+	}
+	if len(findings) > 0 {
+		_, _ = fmt.Fprintln(stderr, "swe-agent: push blocked, the commits contain possible secrets:")
+		for _, f := range findings {
+			_, _ = fmt.Fprintf(stderr, "  %s\n", f)
+		}
+	}
+	_, _ = fmt.Fprintln(stderr, "Remove these changes from every unpushed commit (e.g. amend or rebase the local commits), push again and mention the skipped changes in your summary.")
+	return 1
+}
+
+// appendGuardLog appends entries ("reason<TAB>command") to the guard's
+// blocked log.
+func appendGuardLog(path string, entries []string) {
+	if log, err := os.OpenFile(path, os.O_APPEND|os.O_CREATE|os.O_WRONLY, 0o644); err == nil {
+		_, _ = fmt.Fprintln(log, strings.Join(entries, "\n"))
+		_ = log.Close()
+	}
+}
+
+// writeLines stores one entry per line.
+func writeLines(path string, lines []string) error {
+	data := strings.Join(lines, "\n")
+	if data != "" {
+		data += "\n"
+	}
+	return os.WriteFile(path, []byte(data), 0o644)
+}
+
+func readLines(path string) ([]string, error) {
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return nil, err
+	}
+	var lines []string
+	for _, l := range strings.Split(string(data), "\n") {
+		if l = strings.TrimSpace(l); l != "" {
+			lines = append(lines, l)
+		}
+	}
+	return lines, nil
+}
+
+// reportBlockedPaths prepends the blocked-path changes the guard refused to
+// the tracking comment.
+func (e *Executor) reportBlockedPaths(ctx *github.Context, g *gitGuard) {
+	var files []string
+	seen := make(map[string]bool)
+	for _, attempt := range g.blocked() {
+		if f, ok := strings.CutPrefix(attempt, blockedPathReason+": git push ("); ok && !seen[f] {
+			seen[f] = true
+			files = append(files, "> - `"+strings.TrimSuffix(f, ")")+"`")
+		}
+	}
+	if len(files) == 0 {
+		return
+	}
+	prependNotice(ctx, "> [!NOTE]\n> **Skipped changes to protected files.** The agent may not modify these paths, "+
+		"so pushes changing them were rejected:\n"+strings.Join(files, "\n"))
+}
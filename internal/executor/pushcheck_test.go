@@ -0,0 +1,71 @@
+package executor
+
+import (
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+)
+
+func TestIsBlockedPath(t *testing.T) {
+	paths := []string{".github/workflows/", "deploy/*.yaml", "Makefile"}
+	for file, want := range map[string]bool{
+		".github/workflows/ci.yml":  true,
+		".github/workflows":         true,
+		".github/dependabot.yml":    false,
+		".github/workflows-extra/a": false,
+		"deploy/prod.yaml":          true,
+		"deploy/nested/prod.yaml":   false,
+		"Makefile":                  true,
+		"src/Makefile":              false,
+	} {
+		if got := isBlockedPath(file, paths); got != want {
+			t.Errorf("isBlockedPath(%q) = %v, want %v", file, got, want)
+		}
+	}
+	if isBlockedPath(".github/workflows/ci.yml", nil) {
+		t.Error("no blocked paths must allow everything")
+	}
+}
+
+func TestGitGuard_BlocksProtectedPaths(t *testing.T) {
+	workdir, remote := initPushRepo(t)
+	g, err := installGitGuard(DefaultSecretRules, DefaultBlockedPaths)
+	if err != nil {
+		t.Fatalf("installGitGuard: %v", err)
+	}
+	t.Cleanup(g.remove)
+
+	gitIn(t, workdir, "checkout", "-q", "-b", "swe-agent/1-1")
+	wf := filepath.Join(workdir, ".github", "workflows")
+	_ = os.MkdirAll(wf, 0o755)
+	_ = os.WriteFile(filepath.Join(wf, "ci.yml"), []byte("on: push\n"), 0o644)
+	_ = os.WriteFile(filepath.Join(workdir, "README.md"), []byte("docs\n"), 0o644)
+	gitIn(t, workdir, "add", ".")
+	gitIn(t, workdir, "commit", "-q", "-m", "docs and ci")
+
+	out, err := guardedGit(t, g, workdir, "git", "push", "origin", "HEAD:refs/heads/swe-agent/1-1")
+	if err == nil || !strings.Contains(out, "files the agent may not modify") || !strings.Contains(out, ".github/workflows/ci.yml") {
+		t.Fatalf("expected blocked path rejection, got %v\n%s", err, out)
+	}
+	if gitIn(t, remote, "branch", "--list", "swe-agent/*") != "" {
+		t.Fatal("workflow change reached the remote")
+	}
+
+	// dropping the workflow from the commit lets the rest through
+	gitIn(t, workdir, "rm", "-q", "--cached", ".github/workflows/ci.yml")
+	gitIn(t, workdir, "commit", "-q", "--amend", "-m", "docs")
+	if out, err := guardedGit(t, g, workdir, "git", "push", "-q", "origin", "HEAD:refs/heads/swe-agent/1-1"); err != nil {
+		t.Fatalf("push without workflow: %v\n%s", err, out)
+	}
+
+	updated := stubComments(t, "Updated the docs.")
+	ctx := buildTestCtx(false)
+	ctx.Token = "installation-token"
+	ctx.PreparedCommentID = 7
+	New(&mockProvider{}, &mockAuthProvider{}).reportBlockedPaths(ctx, g)
+	if !strings.HasPrefix(*updated, "> [!NOTE]\n> **Skipped changes to protected files.**") ||
+		!strings.Contains(*updated, "> - `.github/workflows/ci.yml`") || !strings.HasSuffix(*updated, "Updated the docs.") {
+		t.Fatalf("unexpected comment:\n%s", *updated)
+	}
+}
@@ -0,0 +1,219 @@
+package executor
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"io/fs"
+	"os"
+	"path/filepath"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/cexll/swe/internal/github"
+	"github.com/cexll/swe/internal/policy"
+)
+
+// Git guard log reasons for pushes the policy's push rules refused.
+const (
+	policyDeniedReason = "policy denied"
+	policyHoldReason   = "policy hold"
+)
+
+// Files the git guard keeps the push policy in: the policy document, the
+// event it is evaluated for and the decision of a rule that held a push.
+const (
+	policyFileName     = "policy.json"
+	policyInputName    = "policy-input.json"
+	policyDecisionName = "policy-hold.json"
+)
+
+// SetPolicy makes the push rules of p decide whether the pushes of
+// subsequent tasks go through; nil or a policy without push rules lets
+// every push through the other checks.
+func (e *Executor) SetPolicy(p *policy.Policy) {
+	e.mu.Lock()
+	defer e.mu.Unlock()
+	e.policy = p
+}
+
+// pushPolicyInput is the event the push rules are evaluated for: the one
+// the policy authorized the task for, else one built from ctx.
+func pushPolicyInput(ctx *github.Context) policy.Input {
+	if ctx.PreparedPolicyInput != nil {
+		return *ctx.PreparedPolicyInput
+	}
+	return policy.Input{
+		User:    ctx.TriggerUser,
+		Repo:    ctx.GetRepositoryFullName(),
+		Command: "code",
+		Flags:   policy.ParseFlags(ctx.GetTriggerCommentBody()),
+		Event:   string(ctx.EventName),
+		IsPR:    ctx.IsPRContext(),
+	}
+}
+
+// setPolicy makes the guard's push check evaluate the push rules of p for
+// the event in.
+func (g *gitGuard) setPolicy(p *policy.Policy, in policy.Input) error {
+	data, err := json.Marshal(in)
+	if err == nil {
+		err = os.WriteFile(filepath.Join(g.dir, policyInputName), data, 0o644)
+	}
+	if err == nil {
+		err = os.WriteFile(filepath.Join(g.dir, policyFileName), []byte(p.Source()), 0o644)
+	}
+	if err != nil {
+		return fmt.Errorf("git guard: %w", err)
+	}
+	return nil
+}
+
+// policyHold returns the decision of the push rule that held the task's
+// pushes, if one did.
+func (g *gitGuard) policyHold() (policy.Decision, bool) {
+	var d policy.Decision
+	data, err := os.ReadFile(filepath.Join(g.dir, policyDecisionName))
+	if err != nil || json.Unmarshal(data, &d) != nil {
+		return policy.Decision{}, false
+	}
+	return d, true
+}
+
+// checkPushPolicy evaluates the push rules in policyFile for the commits
+// ranges select and the event in inputFile. A rule that denies refuses this
+// push; one that holds refuses it and, through heldFile, every later push of
+// the task. It returns the guard log entries of a refused push.
+func checkPushPolicy(policyFile, inputFile, heldFile string, ranges [][]string, stderr io.Writer) ([]string, error) {
+	if data, err := os.ReadFile(heldFile); err == nil {
+		var d policy.Decision
+		_ = json.Unmarshal(data, &d)
+		_, _ = fmt.Fprintf(stderr, "swe-agent: push held for review by policy rule %q. Your commits are kept; do not push again, and finish with a summary of the changes.\n", d.Rule)
+		return []string{fmt.Sprintf("%s\tgit push (rule %q)", policyHoldReason, d.Rule)}, nil
+	}
+	src, err := os.ReadFile(policyFile)
+	if errors.Is(err, fs.ErrNotExist) {
+		return nil, nil
+	} else if err != nil {
+		return nil, err
+	}
+	p, err := policy.Parse(src)
+	if err != nil {
+		return nil, fmt.Errorf("policy: %w", err)
+	}
+	var in policy.Input
+	if data, err := os.ReadFile(inputFile); err != nil {
+		return nil, err
+	} else if err := json.Unmarshal(data, &in); err != nil {
+		return nil, fmt.Errorf("policy input: %w", err)
+	}
+
+	seen := make(map[string]bool)
+	in.Paths, in.ChangedFiles, in.ChangedLines = nil, 0, 0
+	for _, revs := range ranges {
+		files, lines, err := changeSize(".", revs)
+		if err != nil {
+			return nil, err
+		}
+		for _, f := range files {
+			if !seen[f] {
+				seen[f] = true
+				in.Paths = append(in.Paths, f)
+			}
+		}
+		in.ChangedLines += lines
+	}
+	in.ChangedFiles = len(in.Paths)
+	in.Time = time.Now()
+
+	d := p.EvaluatePush(in)
+	switch d.Effect {
+	case policy.Allow:
+		return nil, nil
+	case policy.Deny:
+		_, _ = fmt.Fprintf(stderr, "swe-agent: push denied by policy rule %q (%d files, %d lines changed).\n", d.Rule, in.ChangedFiles, in.ChangedLines)
+		if d.Message != "" {
+			_, _ = fmt.Fprintf(stderr, "  %s\n", d.Message)
+		}
+		_, _ = fmt.Fprintln(stderr, "Change the unpushed commits so the rule no longer applies and push again, or stop and explain in your summary.")
+		detail := fmt.Sprintf("rule %q", d.Rule)
+		if d.Message != "" {
+			detail += ": " + d.Message
+		}
+		return []string{fmt.Sprintf("%s\tgit push (%s)", policyDeniedReason, detail)}, nil
+	}
+	data, err := json.Marshal(d)
+	if err != nil {
+		return nil, err
+	}
+	if err := os.WriteFile(heldFile, data, 0o644); err != nil {
+		return nil, err
+	}
+	_, _ = fmt.Fprintf(stderr, "swe-agent: push held for review by policy rule %q (%d files, %d lines changed). Your commits are kept and pushed once someone applies them; do not push again, and finish with a summary of the changes.\n", d.Rule, in.ChangedFiles, in.ChangedLines)
+	return []string{fmt.Sprintf("%s\tgit push (rule %q)", policyHoldReason, d.Rule)}, nil
+}
+
+// changeSize lists the files the commits revs select touch and counts the
+// lines they add and remove (binary files count none).
+func changeSize(workdir string, revs []string) ([]string, int, error) {
+	args := append([]string{"-C", workdir, "log", "--no-renames", "--numstat", "--format="}, revs...)
+	out, err := gitCapture(args...)
+	if err != nil {
+		return nil, 0, err
+	}
+	var files []string
+	lines := 0
+	for _, l := range strings.Split(out.String(), "\n") {
+		fields := strings.SplitN(l, "\t", 3)
+		if len(fields) != 3 {
+			continue
+		}
+		added, _ := strconv.Atoi(fields[0])
+		removed, _ := strconv.Atoi(fields[1])
+		lines += added + removed
+		files = append(files, fields[2])
+	}
+	return files, lines, nil
+}
+
+// reportPolicyDenied prepends the pushes the policy's push rules denied to
+// the tracking comment.
+func (e *Executor) reportPolicyDenied(ctx *github.Context, g *gitGuard) {
+	var rules []string
+	seen := make(map[string]bool)
+	for _, attempt := range g.blocked() {
+		if detail, ok := strings.CutPrefix(attempt, policyDeniedReason+": git push ("); ok && !seen[detail] {
+			seen[detail] = true
+			rules = append(rules, "> - "+strings.TrimSuffix(detail, ")"))
+		}
+	}
+	if len(rules) == 0 {
+		return
+	}
+	prependNotice(ctx, "> [!WARNING]\n> **Push denied by policy.** These rules refused the changes:\n"+strings.Join(rules, "\n"))
+}
+
+// finishPolicyHold turns a task whose pushes a push rule held into a dry
+// run awaiting the hold command: its commits since the last push are kept
+// like a dry run's. It reports whether the workspace was kept, in which case
+// the caller must not remove it.
+func (e *Executor) finishPolicyHold(ctx context.Context, ghCtx *github.Context, workdir, branch, base, startSHA string, remove func(), d policy.Decision) bool {
+	notice := fmt.Sprintf("> [!IMPORTANT]\n> **Changes held for review.** Policy rule %q holds pushes like this one", d.Rule)
+	if d.Message != "" {
+		notice += ": " + d.Message
+	}
+	if ghCtx.PreparedHoldCommand == "" {
+		prependNotice(ghCtx, notice+"\n>\n> Nothing was pushed, and the changes cannot be applied from here. Run the task again once the rule allows it.")
+		return false
+	}
+	if pushed := fetchRemoteHead(workdir, branch); pushed != "" && runCmd("git", "-C", workdir, "merge-base", "--is-ancestor", pushed, "HEAD") == nil {
+		startSHA = pushed // what the allowed pushes sent is on the branch already
+	}
+	ghCtx.PreparedApplyCommand = ghCtx.PreparedHoldCommand
+	kept := e.finishDryRun(ctx, ghCtx, workdir, branch, base, startSHA, remove)
+	prependNotice(ghCtx, notice+fmt.Sprintf("\n>\n> Someone other than @%s with write access can push them with `%s`.", ghCtx.TriggerUser, ghCtx.PreparedHoldCommand))
+	return kept
+}
@@ -0,0 +1,107 @@
+package executor
+
+import (
+	"context"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"github.com/cexll/swe/internal/policy"
+)
+
+const pushRules = `{
+  "default": "allow",
+  "rules": [
+    {"name": "no-secrets-dir", "stage": "push", "effect": "deny", "when": "paths.startsWith('secrets/')", "message": "Leave secrets/ alone."},
+    {"name": "big", "stage": "push", "effect": "dry_run", "when": "changed_lines > 5 && user == 'dev'"}
+  ]
+}`
+
+func TestGitGuard_PushPolicy(t *testing.T) {
+	workdir, remote := initPushRepo(t)
+	p, err := policy.Parse([]byte(pushRules))
+	if err != nil {
+		t.Fatalf("policy: %v", err)
+	}
+	g, err := installGitGuard(DefaultSecretRules, nil)
+	if err != nil {
+		t.Fatalf("installGitGuard: %v", err)
+	}
+	t.Cleanup(g.remove)
+	if err := g.setPolicy(p, policy.Input{User: "dev", Repo: "o/r", Command: "code"}); err != nil {
+		t.Fatalf("setPolicy: %v", err)
+	}
+	write := func(name, content string) {
+		path := filepath.Join(workdir, name)
+		_ = os.MkdirAll(filepath.Dir(path), 0o755)
+		_ = os.WriteFile(path, []byte(content), 0o644)
+		gitIn(t, workdir, "add", ".")
+		gitIn(t, workdir, "commit", "-q", "-m", "change "+name)
+	}
+	push := func() (string, error) {
+		return guardedGit(t, g, workdir, "git", "push", "-q", "origin", "HEAD:refs/heads/swe-agent/1-1")
+	}
+
+	gitIn(t, workdir, "checkout", "-q", "-b", "swe-agent/1-1")
+	write("README.md", "small\n")
+	if out, err := push(); err != nil {
+		t.Fatalf("small push: %v\n%s", err, out)
+	}
+
+	// a deny rule refuses the push; reworked commits go through
+	write("secrets/key.txt", "k\n")
+	if out, err := push(); err == nil || !strings.Contains(out, `denied by policy rule "no-secrets-dir"`) || !strings.Contains(out, "Leave secrets/ alone.") {
+		t.Fatalf("expected a policy denial, got %v\n%s", err, out)
+	}
+	gitIn(t, workdir, "reset", "-q", "--hard", "HEAD~1")
+
+	// a dry_run rule holds this push and every later one
+	write("main.go", "package main\n\nfunc main() {\n\tprintln(1)\n}\n\n// end\n")
+	if out, err := push(); err == nil || !strings.Contains(out, `held for review by policy rule "big"`) {
+		t.Fatalf("expected a policy hold, got %v\n%s", err, out)
+	}
+	write("notes.txt", "n\n")
+	if out, err := push(); err == nil || !strings.Contains(out, "held for review") {
+		t.Fatalf("push after a hold: %v\n%s", err, out)
+	}
+	d, ok := g.policyHold()
+	if !ok || d.Rule != "big" || d.Effect != policy.DryRun {
+		t.Fatalf("policyHold = %+v, %t", d, ok)
+	}
+	if tip := gitIn(t, remote, "rev-parse", "swe-agent/1-1"); tip != gitIn(t, workdir, "rev-parse", "HEAD~2") {
+		t.Fatal("held commits reached the remote")
+	}
+
+	// the comment lists the denial, and the held commits since the last
+	// push are kept for apply
+	body := "Refactored main."
+	origGet, origUpdate := getComment, updateComment
+	t.Cleanup(func() { getComment, updateComment = origGet, origUpdate })
+	getComment = func(_, _ string, _ int64, _ string) (string, error) { return body, nil }
+	updateComment = func(_, _ string, _ int64, b, _ string) error { body = b; return nil }
+	ctx := buildTestCtx(false)
+	ctx.Token = "installation-token"
+	ctx.PreparedCommentID = 7
+	ctx.PreparedHoldCommand = "/code apply"
+	ctx.TaskID = "held-task"
+	ctx.TriggerUser = "dev"
+	e := New(&mockProvider{}, &mockAuthProvider{})
+	e.reportPolicyDenied(ctx, g)
+	start := gitIn(t, workdir, "rev-parse", "HEAD~3")
+	if !e.finishPolicyHold(context.Background(), ctx, workdir, "swe-agent/1-1", "main", start, func() {}, d) {
+		t.Fatal("the workspace should be kept for apply")
+	}
+	if ctx.PreparedApplyCommand != "/code apply" {
+		t.Fatalf("PreparedApplyCommand = %q", ctx.PreparedApplyCommand)
+	}
+	if !strings.HasPrefix(body, "> [!IMPORTANT]\n> **Changes held for review.** Policy rule \"big\"") ||
+		!strings.Contains(body, "Someone other than @dev with write access can push them with `/code apply`.") ||
+		!strings.Contains(body, "**Dry run: nothing was pushed.**") || !strings.Contains(body, "+func main() {") ||
+		strings.Contains(body, "+small") || !strings.Contains(body, `**Push denied by policy.** These rules refused the changes:`+"\n"+`> - rule "no-secrets-dir": Leave secrets/ alone.`) {
+		t.Fatalf("unexpected comment:\n%s", body)
+	}
+	if ws, ok := e.dryRuns.take("held-task"); !ok || ws.dir != workdir {
+		t.Fatalf("kept workspace = %+v, %t", ws, ok)
+	}
+}
@@ -0,0 +1,113 @@
+package executor
+
+import (
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/cexll/swe/internal/github/comment"
+	"github.com/cexll/swe/internal/logging"
+	buildinfo "github.com/cexll/swe/internal/version"
+	"github.com/cexll/swe/internal/webhook"
+)
+
+// QueuePosition implements dispatcher.QueueListener by showing the task's
+// queue position in its tracking comment.
+func (a *Adapter) QueuePosition(task *webhook.Task, position int, eta time.Duration) {
+	data := comment.LayoutData{
+		Repo:    task.Repo,
+		Number:  task.Number,
+		TaskID:  task.ID,
+		Version: buildinfo.Short(),
+		Lang:    comment.Lang(task.Lang),
+		History: task.CommentHistory,
+	}
+	a.inner.ReportQueuePosition(data, task.CommentID, position, eta)
+}
+
+// ReportQueuePosition shows "position #N in queue" in the tracking comment
+// data describes, or puts the initial body back once the task starts
+// (position 0).
+// A comment that keeps a history of earlier runs keeps it.
+// Updates are written in the background except for position 0, which
+// returns only once the comment is no longer queued so the run cannot race
+// with it.
+func (e *Executor) ReportQueuePosition(data comment.LayoutData, commentID int64, position int, eta time.Duration) {
+	repo := data.Repo
+	owner, name, ok := strings.Cut(repo, "/")
+	if e.queued == nil || commentID <= 0 || !ok {
+		return
+	}
+	body := comment.InitialBody(data.Lang)
+	if position > 0 {
+		body = comment.QueuedBody(data.Lang, position, eta)
+	}
+	e.mu.RLock()
+	layoutFile := e.commentTemplate
+	e.mu.RUnlock()
+	if layoutFile != "" {
+		body = commentLayout(layoutFile).Render(body, data)
+	}
+	done := e.queued.post(commentID, body, func(body string) {
+		token, err := e.auth.GetInstallationToken(repo)
+		if err != nil {
+			phaseLog("queue").Warn("Authenticating GitHub app for the queue position failed", logging.KeyRepo, repo, "err", err)
+			return
+		}
+		if data.History {
+			old, err := getComment(owner, name, commentID, token.Token)
+			if err != nil {
+				phaseLog("queue").Warn("Reading tracking comment failed", logging.KeyRepo, repo, "err", err)
+				return
+			}
+			body = comment.CarryHistory(body, old)
+		}
+		if err := updateComment(owner, name, commentID, body, token.Token); err != nil {
+			phaseLog("queue").Warn("Updating tracking comment failed", logging.KeyRepo, repo, "err", err)
+		}
+	})
+	if position == 0 {
+		<-done
+	}
+}
+
+// queueNotices writes queue positions to tracking comments, one writer per
+// comment; positions that change faster than GitHub is updated are skipped.
+type queueNotices struct {
+	mu      sync.Mutex
+	latest  map[int64]string        // body still to write, by comment
+	writers map[int64]chan struct{} // closed when the comment's writer is done
+}
+
+func newQueueNotices() *queueNotices {
+	return &queueNotices{latest: make(map[int64]string), writers: make(map[int64]chan struct{})}
+}
+
+// post queues body for comment id and returns a channel closed once it (or
+// a later body) has been written.
+func (q *queueNotices) post(id int64, body string, write func(body string)) <-chan struct{} {
+	q.mu.Lock()
+	defer q.mu.Unlock()
+	q.latest[id] = body
+	if done, ok := q.writers[id]; ok {
+		return done
+	}
+	done := make(chan struct{})
+	q.writers[id] = done
+	go func() {
+		for {
+			q.mu.Lock()
+			body, ok := q.latest[id]
+			delete(q.latest, id)
+			if !ok {
+				delete(q.writers, id)
+				q.mu.Unlock()
+				close(done)
+				return
+			}
+			q.mu.Unlock()
+			write(body)
+		}
+	}()
+	return done
+}
@@ -0,0 +1,67 @@
+package executor
+
+import (
+	"strings"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/cexll/swe/internal/github/comment"
+	"github.com/cexll/swe/internal/webhook"
+)
+
+func TestAdapter_QueuePositionUpdatesTrackingComment(t *testing.T) {
+	origUpdate := updateComment
+	t.Cleanup(func() { updateComment = origUpdate })
+	var mu sync.Mutex
+	var writes []string
+	updateComment = func(owner, repo string, id int64, body, token string) error {
+		if owner != "owner" || repo != "repo" || id != 42 || token != "test-token" {
+			t.Errorf("update %s/%s #%d with %q", owner, repo, id, token)
+		}
+		mu.Lock()
+		writes = append(writes, body)
+		mu.Unlock()
+		return nil
+	}
+
+	a := NewAdapter(New(&mockProvider{}, &mockAuthProvider{}))
+	task := &webhook.Task{Repo: "owner/repo", CommentID: 42}
+	a.QueuePosition(task, 2, 5*time.Minute)
+	deadline := time.Now().Add(time.Second)
+	for {
+		mu.Lock()
+		n := len(writes)
+		mu.Unlock()
+		if n > 0 {
+			break
+		}
+		if time.Now().After(deadline) {
+			t.Fatal("queue position was not written")
+		}
+		time.Sleep(5 * time.Millisecond)
+	}
+	// positions that change before the comment is written are coalesced,
+	// but starting always waits for the initial body
+	a.QueuePosition(task, 1, 0)
+	a.QueuePosition(task, 0, 0)
+
+	mu.Lock()
+	defer mu.Unlock()
+	if !strings.Contains(writes[0], "position #2 in queue, starting in about 5 min") {
+		t.Fatalf("first write = %q, want the queue position", writes[0])
+	}
+	if writes[len(writes)-1] != comment.InitialBody(comment.English) {
+		t.Fatalf("writes = %q, want the initial body last", writes)
+	}
+}
+
+func TestExecutor_ReportQueuePositionSkipsUntrackedTasks(t *testing.T) {
+	updated := stubComments(t, "")
+	e := New(&mockProvider{}, &mockAuthProvider{})
+	e.ReportQueuePosition(comment.LayoutData{Repo: "owner/repo"}, 0, 0, 0)
+	e.ReportQueuePosition(comment.LayoutData{Repo: "no-slash"}, 7, 0, 0)
+	if *updated != "" {
+		t.Fatalf("updated = %q, want no update", *updated)
+	}
+}
@@ -0,0 +1,317 @@
+package executor
+
+import (
+	"context"
+	"fmt"
+	"os"
+	"path/filepath"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/cexll/swe/internal/audit"
+	"github.com/cexll/swe/internal/github"
+	"github.com/cexll/swe/internal/github/comment"
+	"github.com/cexll/swe/internal/provider"
+	buildinfo "github.com/cexll/swe/internal/version"
+)
+
+// ReleaseConfig controls how release tasks (/release) version, tag and
+// publish the default branch.
+type ReleaseConfig struct {
+	Scheme        string   // "semver" (default) or "calver" (YYYY.M.MICRO)
+	TagPrefix     string   // prepended to versions in tag names, e.g. "v"
+	VersionFiles  []string // files whose current version string is bumped
+	Changelog     string   // changelog updated with each release ("" skips)
+	GitHubRelease bool     // also publish a GitHub Release with drafted notes
+}
+
+// allow tests to stub release publishing and the clock
+var createRelease = github.CreateRelease
+var releaseNow = time.Now
+
+// SetReleaseConfig configures release tasks.
+func (e *Executor) SetReleaseConfig(cfg ReleaseConfig) {
+	e.mu.Lock()
+	defer e.mu.Unlock()
+	e.release = cfg
+}
+
+// version is a three-part numeric version (semver MAJOR.MINOR.PATCH or
+// calver YEAR.MONTH.MICRO).
+type version struct{ major, minor, patch int }
+
+func (v version) String() string { return fmt.Sprintf("%d.%d.%d", v.major, v.minor, v.patch) }
+
+func (v version) less(o version) bool {
+	if v.major != o.major {
+		return v.major < o.major
+	}
+	if v.minor != o.minor {
+		return v.minor < o.minor
+	}
+	return v.patch < o.patch
+}
+
+// parseVersion parses "1.2.3"; pre-release and build suffixes are rejected.
+func parseVersion(s string) (version, bool) {
+	parts := strings.Split(s, ".")
+	if len(parts) != 3 {
+		return version{}, false
+	}
+	var n [3]int
+	for i, p := range parts {
+		v, err := strconv.Atoi(p)
+		if err != nil || v < 0 {
+			return version{}, false
+		}
+		n[i] = v
+	}
+	return version{n[0], n[1], n[2]}, true
+}
+
+// nextVersion applies bump ("patch", "minor" or "major") to current. Calver
+// versions follow the release date and only count up within a month, so the
+// bump level does not apply to them.
+func nextVersion(scheme string, current version, bump string, now time.Time) (version, error) {
+	switch scheme {
+	case "", "semver":
+		switch bump {
+		case "major":
+			return version{current.major + 1, 0, 0}, nil
+		case "minor":
+			return version{current.major, current.minor + 1, 0}, nil
+		case "patch":
+			return version{current.major, current.minor, current.patch + 1}, nil
+		}
+		return version{}, fmt.Errorf("unknown version bump %q", bump)
+	case "calver":
+		year, month := now.Year(), int(now.Month())
+		if current.major == year && current.minor == month {
+			return version{year, month, current.patch + 1}, nil
+		}
+		return version{year, month, 0}, nil
+	}
+	return version{}, fmt.Errorf("unknown version scheme %q", scheme)
+}
+
+// latestVersion returns the highest version tagged with prefix and its tag,
+// or the zero version and "" when there is none.
+func latestVersion(workdir, prefix string) (version, string, error) {
+	out, err := gitOutput(workdir, "tag", "--list", prefix+"*")
+	if err != nil {
+		return version{}, "", fmt.Errorf("list tags: %w", err)
+	}
+	var best version
+	var tag string
+	for _, t := range strings.Fields(out) {
+		v, ok := parseVersion(strings.TrimPrefix(t, prefix))
+		if ok && (tag == "" || best.less(v)) {
+			best, tag = v, t
+		}
+	}
+	return best, tag, nil
+}
+
+// bumpVersionFiles replaces the first occurrence of from with to in each file.
+func bumpVersionFiles(workdir string, files []string, from, to string) error {
+	for _, f := range files {
+		path := filepath.Join(workdir, f)
+		data, err := os.ReadFile(path)
+		if err != nil {
+			return fmt.Errorf("read version file: %w", err)
+		}
+		if !strings.Contains(string(data), from) {
+			return fmt.Errorf("version file %s does not contain the current version %s", f, from)
+		}
+		updated := strings.Replace(string(data), from, to, 1)
+		if err := os.WriteFile(path, []byte(updated), 0o644); err != nil {
+			return fmt.Errorf("write version file: %w", err)
+		}
+	}
+	return nil
+}
+
+// updateChangelog adds a section for the release above the previous ones,
+// below the file's title when it has one.
+func updateChangelog(path, tag string, date time.Time, entries string) error {
+	section := fmt.Sprintf("## %s - %s\n\n%s\n", tag, date.Format("2006-01-02"), strings.TrimSpace(entries))
+	data, err := os.ReadFile(path)
+	if err != nil && !os.IsNotExist(err) {
+		return fmt.Errorf("read changelog: %w", err)
+	}
+	existing := string(data)
+	var updated string
+	switch {
+	case existing == "":
+		updated = "# Changelog\n\n" + section
+	case strings.HasPrefix(existing, "# "):
+		title, rest, _ := strings.Cut(existing, "\n")
+		updated = title + "\n\n" + section + "\n" + strings.TrimLeft(rest, "\n")
+	default:
+		updated = section + "\n" + existing
+	}
+	if err := os.WriteFile(path, []byte(updated), 0o644); err != nil {
+		return fmt.Errorf("write changelog: %w", err)
+	}
+	return nil
+}
+
+// executeRelease bumps the version, updates the changelog, tags the commit
+// and pushes both to base, then optionally publishes a GitHub Release with
+// provider-drafted notes. It returns the tracking comment summary and cost.
+func (e *Executor) executeRelease(ctx context.Context, ghCtx *github.Context, workdir, repo, base, token string) (summary string, costUSD float64, err error) {
+	defer func() {
+		if err != nil {
+			msg := strings.ReplaceAll(err.Error(), token, "***")
+			e.reportOutcome(ghCtx, fmt.Sprintf("### Release failed\n\nNo tag was pushed.\n\n```\n%s\n```", tail(msg, verifyOutputLimit)))
+		}
+	}()
+	cfg := e.release
+	owner, name := ghCtx.GetRepositoryOwner(), ghCtx.GetRepositoryName()
+
+	if protected := protectedBranches(owner, name, token, base); len(protected) > 0 {
+		return "", 0, &NonRetryableError{msg: fmt.Sprintf("release: %s is a protected branch; the version bump cannot be pushed to it directly", base)}
+	}
+
+	// Releases need the tags and the history since the last one
+	if shallow, _ := gitOutput(workdir, "rev-parse", "--is-shallow-repository"); strings.TrimSpace(shallow) == "true" {
+		if err := runCmd("git", "-C", workdir, "fetch", "-q", "--unshallow", "--tags", "origin"); err != nil {
+			return "", 0, fmt.Errorf("fetch release history: %w", err)
+		}
+	} else if err := runCmd("git", "-C", workdir, "fetch", "-q", "--tags", "origin"); err != nil {
+		return "", 0, fmt.Errorf("fetch tags: %w", err)
+	}
+
+	current, prevTag, err := latestVersion(workdir, cfg.TagPrefix)
+	if err != nil {
+		return "", 0, err
+	}
+	now := releaseNow()
+	next, err := nextVersion(cfg.Scheme, current, ghCtx.PreparedRelease, now)
+	if err != nil {
+		return "", 0, &NonRetryableError{msg: "release: " + err.Error()}
+	}
+	tag := cfg.TagPrefix + next.String()
+	if existing, _ := gitOutput(workdir, "tag", "--list", tag); strings.TrimSpace(existing) != "" {
+		return "", 0, &NonRetryableError{msg: fmt.Sprintf("release: tag %s already exists", tag)}
+	}
+
+	logArgs := []string{"log", "--no-merges", "--format=- %s (%h)"}
+	if prevTag != "" {
+		logArgs = append(logArgs, prevTag+"..HEAD")
+	}
+	entries, err := gitOutput(workdir, logArgs...)
+	if err != nil {
+		return "", 0, fmt.Errorf("collect changes: %w", err)
+	}
+	entries = strings.TrimSpace(entries)
+	if entries == "" {
+		return "", 0, &NonRetryableError{msg: fmt.Sprintf("release: no changes since %s", prevTag)}
+	}
+
+	// Bump, commit, tag and push atomically
+	if err := bumpVersionFiles(workdir, cfg.VersionFiles, current.String(), next.String()); err != nil {
+		return "", 0, &NonRetryableError{msg: "release: " + err.Error()}
+	}
+	changed := append([]string{}, cfg.VersionFiles...)
+	if cfg.Changelog != "" {
+		if err := updateChangelog(filepath.Join(workdir, cfg.Changelog), tag, now, entries); err != nil {
+			return "", 0, err
+		}
+		changed = append(changed, cfg.Changelog)
+	}
+	if len(changed) > 0 {
+		if err := runCmd("git", append([]string{"-C", workdir, "add", "--"}, changed...)...); err != nil {
+			return "", 0, fmt.Errorf("stage release files: %w", err)
+		}
+		if err := runCmd("git", "-C", workdir, "commit", "-q", "-m", "chore(release): "+tag); err != nil {
+			return "", 0, fmt.Errorf("commit release: %w", err)
+		}
+	}
+	if err := runCmd("git", "-C", workdir, "tag", "-a", tag, "-m", "Release "+tag); err != nil {
+		return "", 0, fmt.Errorf("tag release: %w", err)
+	}
+	if err := runCmd("git", "-C", workdir, "push", "-q", "--atomic", "origin", "HEAD:refs/heads/"+base, "refs/tags/"+tag); err != nil {
+		return "", 0, fmt.Errorf("push release: %w", err)
+	}
+	sha, _ := gitOutput(workdir, "rev-parse", "HEAD")
+	taskLog(ghCtx, "release").Info("Release tagged", "tag", tag, "sha", shortSHA(strings.TrimSpace(sha)))
+
+	from := "the first release"
+	if prevTag != "" {
+		from = "`" + prevTag + "`"
+	}
+	summary = fmt.Sprintf("### Released %s\n\n**%s** bump from %s, tagged on `%s` (`%s`).", tag, ghCtx.PreparedRelease, from, base, shortSHA(strings.TrimSpace(sha)))
+
+	detail := tag
+	if cfg.GitHubRelease {
+		var notes string
+		notes, costUSD = e.draftReleaseNotes(ctx, workdir, repo, tag, prevTag, entries, token)
+		url, err := createRelease(owner, name, tag, tag, notes, token)
+		if err != nil {
+			// the tag is already pushed; report instead of failing the task
+			taskLog(ghCtx, "release").Warn("Creating GitHub release failed", "tag", tag, "err", err)
+			summary += "\n\n> [!WARNING]\n> The tag was pushed, but creating the GitHub Release failed. Create it manually from the tag."
+		} else {
+			summary += fmt.Sprintf("\n\n[GitHub Release](%s)", url)
+			detail += " " + url
+		}
+	}
+	summary += "\n\n<details><summary>Changes</summary>\n\n" + entries + "\n</details>"
+
+	ev := e.auditEvent(ghCtx, audit.ActionReleasePublished)
+	ev.Branch = base
+	ev.Detail = detail
+	e.recordAudit(ev)
+	e.reportOutcome(ghCtx, summary)
+	return summary, costUSD, nil
+}
+
+// draftReleaseNotes asks the provider for release notes, falling back to the
+// commit list when it fails or returns nothing.
+func (e *Executor) draftReleaseNotes(ctx context.Context, workdir, repo, tag, prevTag, entries, token string) (string, float64) {
+	fallback := "## Changes\n\n" + entries
+	since := "the beginning of the project"
+	if prevTag != "" {
+		since = prevTag
+	}
+	var env []string
+	if guard, err := installGitGuard(e.secretRuleSet(), e.blockedPaths); err == nil {
+		defer guard.remove()
+		env = guard.env()
+	}
+	resp, err := e.provider.GenerateCode(ctx, &provider.CodeRequest{
+		Prompt: fmt.Sprintf(`Draft the GitHub Release notes for %s %s. These are the commits since %s:
+
+%s
+
+Read the repository (git log, git show) as needed to understand the changes, but do not modify files, commit or push.
+Group the changes for users (features, fixes, breaking changes) and reply with the release notes in Markdown only.`, repo, tag, since, entries),
+		RepoPath:        workdir,
+		Context:         map[string]string{"github_token": token, "repository": repo},
+		AllowedTools:    []string{"Read", "Grep", "Glob", "Bash(git log)", "Bash(git show)", "Bash(git diff)"},
+		DisallowedTools: []string{"Edit", "Write", "Bash(git commit)", "Bash(git push)", "Bash(git tag)"},
+		Env:             env,
+	})
+	if err != nil {
+		phaseLog("release").WarnContext(ctx, "Drafting release notes failed", "err", err)
+		return fallback, 0
+	}
+	if resp == nil || strings.TrimSpace(resp.Summary) == "" {
+		return fallback, 0
+	}
+	return strings.TrimSpace(resp.Summary), resp.CostUSD
+}
+
+// reportOutcome replaces the tracking comment with the outcome of a task
+// that changes no code (a release or a triage).
+func (e *Executor) reportOutcome(ctx *github.Context, body string) {
+	if ctx.PreparedCommentID <= 0 || ctx.Token == "" {
+		return
+	}
+	body = comment.WithFooter(body, buildinfo.Short())
+	if err := updateComment(ctx.GetRepositoryOwner(), ctx.GetRepositoryName(), ctx.PreparedCommentID, body, ctx.Token); err != nil {
+		taskLog(ctx, "comment").Warn("Updating tracking comment failed", "err", err)
+	}
+}
@@ -0,0 +1,142 @@
+package executor
+
+import (
+	"context"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/cexll/swe/internal/audit"
+	"github.com/cexll/swe/internal/github"
+	ghdata "github.com/cexll/swe/internal/github/data"
+	"github.com/cexll/swe/internal/provider"
+)
+
+func TestNextVersion(t *testing.T) {
+	now := time.Date(2026, time.October, 16, 0, 0, 0, 0, time.UTC)
+	tests := []struct {
+		scheme, bump string
+		current      version
+		want         string
+	}{
+		{"semver", "patch", version{1, 2, 3}, "1.2.4"},
+		{"", "minor", version{1, 2, 3}, "1.3.0"},
+		{"semver", "major", version{1, 2, 3}, "2.0.0"},
+		{"calver", "patch", version{2026, 10, 2}, "2026.10.3"},
+		{"calver", "major", version{2026, 9, 4}, "2026.10.0"},
+	}
+	for _, tt := range tests {
+		got, err := nextVersion(tt.scheme, tt.current, tt.bump, now)
+		if err != nil || got.String() != tt.want {
+			t.Errorf("nextVersion(%s, %s, %s) = %s, %v; want %s", tt.scheme, tt.current, tt.bump, got, err, tt.want)
+		}
+	}
+	if _, err := nextVersion("semver", version{}, "huge", now); err == nil {
+		t.Error("unknown bump should fail")
+	}
+	if _, err := nextVersion("romver", version{}, "patch", now); err == nil {
+		t.Error("unknown scheme should fail")
+	}
+}
+
+func TestUpdateChangelog(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "CHANGELOG.md")
+	date := time.Date(2026, time.October, 16, 0, 0, 0, 0, time.UTC)
+	if err := updateChangelog(path, "v1.0.0", date, "- first (abc)"); err != nil {
+		t.Fatal(err)
+	}
+	if err := updateChangelog(path, "v1.0.1", date, "- second (def)\n"); err != nil {
+		t.Fatal(err)
+	}
+	data, _ := os.ReadFile(path)
+	want := "# Changelog\n\n## v1.0.1 - 2026-10-16\n\n- second (def)\n\n## v1.0.0 - 2026-10-16\n\n- first (abc)\n"
+	if string(data) != want {
+		t.Fatalf("changelog =\n%q\nwant\n%q", data, want)
+	}
+}
+
+func TestExecute_Release(t *testing.T) {
+	workdir, remote := initPushRepo(t)
+	if err := os.WriteFile(filepath.Join(workdir, "VERSION"), []byte("1.2.3\n"), 0o644); err != nil {
+		t.Fatal(err)
+	}
+	gitIn(t, workdir, "add", "VERSION")
+	gitIn(t, workdir, "commit", "-q", "-m", "add version")
+	gitIn(t, workdir, "tag", "v1.2.3")
+	gitIn(t, workdir, "tag", "v1.10.0-rc1")
+	commitAndPush(t, workdir, "main", "fixed\n")
+	gitIn(t, workdir, "push", "-q", "origin", "--tags")
+
+	updated := stubComments(t, "")
+	origClone, origRun, origCreate, origNow := cloneRepo, runCmd, createRelease, releaseNow
+	t.Cleanup(func() { cloneRepo, runCmd, createRelease, releaseNow = origClone, origRun, origCreate, origNow })
+	cloneRepo = func(_ context.Context, repo, branch, token string) (string, func(), error) {
+		return workdir, func() {}, nil
+	}
+	runCmd = func(name string, args ...string) error {
+		if len(args) > 3 && args[2] == "remote" && args[3] == "set-url" {
+			return nil // keep the local remote
+		}
+		return run(name, args...)
+	}
+	releaseNow = func() time.Time { return time.Date(2026, time.October, 16, 0, 0, 0, 0, time.UTC) }
+	var releasedTag, releasedNotes string
+	createRelease = func(owner, repo, tag, name, body, token string) (string, error) {
+		releasedTag, releasedNotes = tag, body
+		return "https://github.com/owner/repo/releases/tag/" + tag, nil
+	}
+
+	var notesPrompt string
+	e := New(&mockProvider{generateFunc: func(_ context.Context, req *provider.CodeRequest) (*provider.CodeResponse, error) {
+		notesPrompt = req.Prompt
+		return &provider.CodeResponse{Summary: "## Fixes\n\n- README fixed", CostUSD: 0.02}, nil
+	}}, &mockAuthProvider{})
+	e.fetcher = &mockFetcher{fetchFunc: func(context.Context, *github.Context) (*ghdata.FetchResult, error) {
+		return &ghdata.FetchResult{ContextData: ghdata.Issue{Title: "t", Author: ghdata.Author{Login: "u"}}}, nil
+	}}
+	log, _ := audit.New(audit.Config{})
+	e.SetAuditLog(log)
+	e.SetReleaseConfig(ReleaseConfig{TagPrefix: "v", VersionFiles: []string{"VERSION"}, Changelog: "CHANGELOG.md", GitHubRelease: true})
+
+	ctx := buildTestCtx(false)
+	ctx.PreparedRelease = "patch"
+	ctx.PreparedCommentID = 5
+	if err := e.Execute(context.Background(), ctx); err != nil {
+		t.Fatalf("Execute: %v", err)
+	}
+
+	if got := gitIn(t, remote, "show", "v1.2.4:VERSION"); got != "1.2.4" {
+		t.Fatalf("tagged VERSION = %q", got)
+	}
+	if gitIn(t, remote, "rev-parse", "main") != gitIn(t, remote, "rev-parse", "v1.2.4^{commit}") {
+		t.Fatal("main should point at the release commit")
+	}
+	changelog := gitIn(t, remote, "show", "main:CHANGELOG.md")
+	if !strings.Contains(changelog, "## v1.2.4 - 2026-10-16") || !strings.Contains(changelog, "- agent change (") || strings.Contains(changelog, "add version") {
+		t.Fatalf("unexpected changelog:\n%s", changelog)
+	}
+	if !strings.Contains(notesPrompt, "since v1.2.3") || releasedTag != "v1.2.4" || releasedNotes != "## Fixes\n\n- README fixed" {
+		t.Fatalf("release %q with notes %q (prompt %q)", releasedTag, releasedNotes, notesPrompt)
+	}
+	if !strings.HasPrefix(*updated, "### Released v1.2.4\n\n**patch** bump from `v1.2.3`") || !strings.Contains(*updated, "releases/tag/v1.2.4") {
+		t.Fatalf("tracking comment = %q", *updated)
+	}
+	events := log.List(audit.Filter{Action: audit.ActionReleasePublished})
+	if len(events) != 1 || !strings.HasPrefix(events[0].Detail, "v1.2.4 https://") {
+		t.Fatalf("audit events = %+v", events)
+	}
+
+	// releasing again without new commits is refused and reported
+	ctx = buildTestCtx(false)
+	ctx.PreparedRelease = "patch"
+	ctx.PreparedCommentID = 5
+	err := e.Execute(context.Background(), ctx)
+	if err == nil || !IsNonRetryable(err) || !strings.Contains(err.Error(), "no changes since v1.2.4") {
+		t.Fatalf("expected non-retryable no-changes error, got %v", err)
+	}
+	if !strings.HasPrefix(*updated, "### Release failed") {
+		t.Fatalf("tracking comment = %q", *updated)
+	}
+}
@@ -0,0 +1,187 @@
+package executor
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"io/fs"
+	"os"
+	"strings"
+
+	"github.com/cexll/swe/internal/artifacts"
+	"github.com/cexll/swe/internal/github"
+	"github.com/cexll/swe/internal/taskstore"
+)
+
+// dryRunTarget records where a dry run's patch applies.
+type dryRunTarget struct {
+	Branch   string `json:"branch"`
+	Base     string `json:"base"`
+	StartSHA string `json:"start_sha"` // the commit the dry run started from
+}
+
+// errDryRunGone reports a dry run with neither a saved workspace nor a
+// saved patch.
+var errDryRunGone = errors.New("no saved workspace or patch")
+
+// replayConflictError reports a dry run patch that no longer applies to its
+// branch.
+type replayConflictError struct {
+	Branch string
+	Files  []string // conflicting files, when git names them
+	Output string
+}
+
+func (e *replayConflictError) Error() string {
+	if len(e.Files) == 0 {
+		return fmt.Sprintf("dry run patch does not apply to %s: %s", e.Branch, tail(e.Output, 500))
+	}
+	return fmt.Sprintf("dry run patch does not apply to %s: conflicts in %s", e.Branch, strings.Join(e.Files, ", "))
+}
+
+// saveDryRunPatch keeps what a dry run committed since startSHA as artifacts,
+// so applying it needs no workspace. The commits are kept as git
+// format-patch output; history with merges, which format-patch skips, as
+// one plain diff. It reports whether the patch and its target were saved.
+func (e *Executor) saveDryRunPatch(ctx context.Context, ghCtx *github.Context, workdir, branch, base, startSHA string) bool {
+	if !e.wantsArtifacts(ghCtx) || startSHA == "" {
+		return false
+	}
+	args := []string{"format-patch", "--stdout", "--binary", startSHA + "..HEAD"}
+	if merges, err := gitOutput(workdir, "rev-list", "--merges", startSHA+"..HEAD"); err != nil || strings.TrimSpace(merges) != "" {
+		args = []string{"diff", "--binary", startSHA, "HEAD"}
+	}
+	patch, err := gitOutput(workdir, args...)
+	if err != nil || strings.TrimSpace(patch) == "" {
+		taskLog(ghCtx, "dry_run").Warn("Collecting dry run patch failed", "err", err)
+		return false
+	}
+	target, err := json.Marshal(dryRunTarget{Branch: branch, Base: base, StartSHA: startSHA})
+	if err != nil {
+		return false
+	}
+	return e.saveArtifact(ctx, ghCtx, artifacts.DryRunPatch, []byte(patch)) &&
+		e.saveArtifact(ctx, ghCtx, artifacts.DryRunTarget, target)
+}
+
+// loadDryRunPatch reads the saved patch of dry run taskID and where it
+// applies; errDryRunGone when there is none.
+func (e *Executor) loadDryRunPatch(ctx context.Context, taskID string) (dryRunTarget, []byte, error) {
+	var target dryRunTarget
+	if e.artifacts == nil || taskID == "" {
+		return target, nil, errDryRunGone
+	}
+	read := func(name string) ([]byte, error) {
+		rc, err := e.artifacts.Open(ctx, taskID, name)
+		if errors.Is(err, fs.ErrNotExist) {
+			return nil, errDryRunGone
+		}
+		if err != nil {
+			return nil, fmt.Errorf("open %s of dry run %s: %w", name, taskID, err)
+		}
+		defer func() { _ = rc.Close() }()
+		return io.ReadAll(rc)
+	}
+	data, err := read(artifacts.DryRunTarget)
+	if err != nil {
+		return target, nil, err
+	}
+	if err := json.Unmarshal(data, &target); err != nil || target.Branch == "" {
+		return target, nil, fmt.Errorf("dry run %s: invalid %s", taskID, artifacts.DryRunTarget)
+	}
+	patch, err := read(artifacts.DryRunPatch)
+	return target, patch, err
+}
+
+// replayDryRun clones repo again and applies the saved patch of the dry run
+// ghCtx applies, for when its workspace is gone.
+func (e *Executor) replayDryRun(ctx context.Context, ghCtx *github.Context, repo, token string) (dryRunWorkspace, error) {
+	target, patch, err := e.loadDryRunPatch(ctx, ghCtx.PreparedApplyTaskID)
+	if err != nil {
+		return dryRunWorkspace{}, err
+	}
+	e.phase(ghCtx, taskstore.PhaseClone)
+	workdir, cleanup, err := e.workspaces.clone(ctx, repo, target.Base, token)
+	if err != nil {
+		return dryRunWorkspace{}, fmt.Errorf("clone repository: %w", err)
+	}
+	if err := replayPatch(workdir, target, patch); err != nil {
+		cleanup()
+		return dryRunWorkspace{}, err
+	}
+	if e.store != nil && ghCtx.TaskID != "" {
+		e.store.AddLog(ghCtx.TaskID, "info", "Dry run patch replayed on a fresh clone")
+	}
+	return dryRunWorkspace{dir: workdir, branch: target.Branch, base: target.Base, remove: cleanup}, nil
+}
+
+// replayPatch checks out target.Branch in workdir and applies patch to it:
+// on the branch's remote head when it exists, else on the commit the dry run
+// started from. git am falls back to a three-way merge when the branch
+// moved; what still conflicts is a *replayConflictError.
+func replayPatch(workdir string, target dryRunTarget, patch []byte) error {
+	// shallow clones may lack the start commit, which the three-way merge needs
+	haveStart := target.StartSHA != "" && runCmd("git", "-C", workdir, "fetch", "-q", "--depth=1", "origin", target.StartSHA) == nil
+	if target.Branch != target.Base {
+		if refs, err := gitLsRemoteHeads(workdir, target.Branch); err == nil && len(refs) > 0 {
+			refspec := fmt.Sprintf("+refs/heads/%s:refs/remotes/origin/%s", target.Branch, target.Branch)
+			if err := runCmd("git", "-C", workdir, "fetch", "origin", refspec); err != nil {
+				return fmt.Errorf("fetch remote branch: %w", err)
+			}
+			if err := checkoutRemoteBranch(workdir, target.Branch); err != nil {
+				return err
+			}
+		} else {
+			from := "HEAD"
+			if haveStart {
+				from = target.StartSHA
+			}
+			if err := runCmd("git", "-C", workdir, "checkout", "-q", "-b", target.Branch, from); err != nil {
+				return fmt.Errorf("create feature branch: %w", err)
+			}
+		}
+	}
+
+	file, err := gitPath(workdir, "swe-agent-dry-run.patch")
+	if err != nil {
+		return fmt.Errorf("write dry run patch: %w", err)
+	}
+	if err := os.WriteFile(file, patch, 0o600); err != nil {
+		return fmt.Errorf("write dry run patch: %w", err)
+	}
+	if strings.HasPrefix(string(patch), "From ") {
+		if _, err := gitOutput(workdir, "am", "-3", "-q", file); err != nil {
+			conflict := replayConflict(workdir, target.Branch, err)
+			_, _ = gitOutput(workdir, "am", "--abort")
+			return conflict
+		}
+		return nil
+	}
+	// a plain diff: apply and commit it as one change
+	if _, err := gitOutput(workdir, "apply", "-3", "--index", file); err != nil {
+		conflict := replayConflict(workdir, target.Branch, err)
+		_, _ = gitOutput(workdir, "reset", "-q", "--hard")
+		return conflict
+	}
+	return runCmd("git", "-C", workdir, "commit", "-q", "-m", "Changes from swe-agent dry run")
+}
+
+// replayConflict names the files a failed git am or git apply left in
+// conflict, or that it reported as not applying.
+func replayConflict(workdir, branch string, applyErr error) *replayConflictError {
+	conflict := &replayConflictError{Branch: branch, Output: applyErr.Error()}
+	if out, err := gitOutput(workdir, "diff", "--name-only", "-z", "--diff-filter=U"); err == nil {
+		conflict.Files = gitPaths(out)
+	}
+	if len(conflict.Files) == 0 {
+		for _, line := range strings.Split(conflict.Output, "\n") {
+			if _, rest, ok := strings.Cut(line, "patch failed: "); ok {
+				file, _, _ := strings.Cut(rest, ":")
+				conflict.Files = append(conflict.Files, strings.TrimSpace(file))
+			}
+		}
+	}
+	return conflict
+}
@@ -0,0 +1,134 @@
+package executor
+
+import (
+	"context"
+	"errors"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"github.com/cexll/swe/internal/artifacts"
+	"github.com/cexll/swe/internal/storage"
+	"github.com/cexll/swe/internal/taskstore"
+)
+
+// cloneOf returns a fresh clone of remote, as cloneRepo would.
+func cloneOf(t *testing.T, remote, branch string) string {
+	t.Helper()
+	dir := filepath.Join(t.TempDir(), "clone")
+	gitIn(t, remote, "clone", "-q", "-b", branch, remote, dir)
+	gitIn(t, dir, "config", "user.email", "test@example.com")
+	gitIn(t, dir, "config", "user.name", "test")
+	return dir
+}
+
+// dryRunPatch commits content as README.md in workdir and returns the
+// commit it started from and the format-patch output of the change, leaving
+// workdir at the start commit.
+func dryRunPatch(t *testing.T, workdir, content string) (string, []byte) {
+	t.Helper()
+	start := gitIn(t, workdir, "rev-parse", "HEAD")
+	if err := os.WriteFile(filepath.Join(workdir, "README.md"), []byte(content), 0o644); err != nil {
+		t.Fatal(err)
+	}
+	gitIn(t, workdir, "commit", "-q", "-am", "dry run change")
+	patch := gitIn(t, workdir, "format-patch", "--stdout", start+"..HEAD")
+	gitIn(t, workdir, "reset", "-q", "--hard", start)
+	return start, []byte(patch + "\n")
+}
+
+func TestReplayPatch(t *testing.T) {
+	workdir, remote := initPushRepo(t)
+	start, patch := dryRunPatch(t, workdir, "dry run\n")
+	target := dryRunTarget{Branch: "feature", Base: "main", StartSHA: start}
+
+	// a new branch starts from the commit the dry run started from
+	clone := cloneOf(t, remote, "main")
+	if err := replayPatch(clone, target, patch); err != nil {
+		t.Fatalf("replay on a new branch: %v", err)
+	}
+	if got := gitIn(t, clone, "show", "feature:README.md"); got != "dry run" {
+		t.Fatalf("README.md = %q", got)
+	}
+
+	// unrelated changes on the branch are kept
+	if err := os.WriteFile(filepath.Join(workdir, "NOTES.md"), []byte("notes\n"), 0o644); err != nil {
+		t.Fatal(err)
+	}
+	gitIn(t, workdir, "add", "NOTES.md")
+	gitIn(t, workdir, "commit", "-q", "-m", "notes")
+	gitIn(t, workdir, "push", "-q", "origin", "HEAD:refs/heads/feature")
+	clone = cloneOf(t, remote, "main")
+	if err := replayPatch(clone, target, patch); err != nil {
+		t.Fatalf("replay on a moved branch: %v", err)
+	}
+	if gitIn(t, clone, "show", "HEAD:README.md") != "dry run" || gitIn(t, clone, "show", "HEAD:NOTES.md") != "notes" {
+		t.Fatalf("replay lost changes: %s", gitIn(t, clone, "log", "--oneline"))
+	}
+
+	// conflicting changes are reported and nothing is applied
+	gitIn(t, workdir, "reset", "-q", "--hard", start)
+	if err := os.WriteFile(filepath.Join(workdir, "README.md"), []byte("someone else\n"), 0o644); err != nil {
+		t.Fatal(err)
+	}
+	gitIn(t, workdir, "commit", "-q", "-am", "conflicting change")
+	gitIn(t, workdir, "push", "-q", "-f", "origin", "HEAD:refs/heads/feature")
+	clone = cloneOf(t, remote, "main")
+	err := replayPatch(clone, target, patch)
+	var conflict *replayConflictError
+	if !errors.As(err, &conflict) || strings.Join(conflict.Files, ",") != "README.md" {
+		t.Fatalf("replay over a conflicting change = %v", err)
+	}
+	if got := gitIn(t, clone, "status", "--porcelain"); got != "" || gitIn(t, clone, "show", "HEAD:README.md") != "someone else" {
+		t.Fatalf("a conflicting replay must leave the branch as it was: %q", got)
+	}
+}
+
+func TestExecute_DryRunReplaysPatch(t *testing.T) {
+	workdir, remote := initPushRepo(t)
+	updated := stubComments(t, "Rewrote the README.")
+	e, _, _ := approvalExecutor(t, workdir)
+	e.SetArtifacts(artifacts.New(&storage.Local{Dir: t.TempDir()}, 0))
+	store := taskstore.NewStore()
+	store.Create(&taskstore.Task{ID: "task-1"})
+	store.Create(&taskstore.Task{ID: "task-2"})
+	e.SetTaskStore(store)
+
+	removed := false
+	cloneRepo = func(_ context.Context, repo, branch, token string) (string, func(), error) {
+		return workdir, func() { removed = true }, nil
+	}
+	ctx := buildTestCtx(false)
+	ctx.TaskID = "task-1"
+	ctx.PreparedCommentID = 7
+	ctx.PreparedApplyCommand = "/code apply"
+	if err := e.Execute(context.Background(), ctx); err != nil {
+		t.Fatalf("Execute: %v", err)
+	}
+	if !removed || len(e.dryRuns.saved) != 0 {
+		t.Fatal("with its patch saved, the dry run's workspace should be removed")
+	}
+	if !strings.Contains(*updated, "Comment `/code apply` within 24 hours") {
+		t.Fatalf("tracking comment = %q", *updated)
+	}
+
+	cloneRepo = func(_ context.Context, repo, branch, token string) (string, func(), error) {
+		return cloneOf(t, remote, branch), func() {}, nil
+	}
+	apply := buildTestCtx(false)
+	apply.TaskID = "task-2"
+	apply.PreparedCommentID = 7
+	apply.PreparedApplyTaskID = "task-1"
+	if err := e.Execute(context.Background(), apply); err != nil {
+		t.Fatalf("apply: %v", err)
+	}
+	if apply.PreparedBranch != ctx.PreparedBranch || gitIn(t, remote, "show", ctx.PreparedBranch+":README.md") != "agent change" {
+		t.Fatalf("dry run was not replayed onto %q", ctx.PreparedBranch)
+	}
+
+	apply.PreparedApplyTaskID = "task-unknown"
+	if err := e.Execute(context.Background(), apply); !IsNonRetryable(err) || !strings.Contains(*updated, "changes are gone") {
+		t.Fatalf("apply without a patch = %v, comment %q", err, *updated)
+	}
+}
@@ -0,0 +1,158 @@
+package executor
+
+import (
+	"fmt"
+	"path"
+	"sort"
+	"strings"
+
+	ghdata "github.com/cexll/swe/internal/github/data"
+)
+
+// defaultFileListExclude keeps vendored and generated trees out of the
+// repository file list; repositories add their own patterns.
+var defaultFileListExclude = []string{"vendor/", "node_modules/", "third_party/", "*.min.js", "*.min.css"}
+
+// SetRepoFileList lists up to max files of the checkout in subsequent
+// tasks' prompts (0 lists none).
+func (e *Executor) SetRepoFileList(max int) {
+	e.mu.Lock()
+	defer e.mu.Unlock()
+	e.repoFileListMax = max
+}
+
+// ListRepoFiles returns up to max entries listing the files git knows in
+// workdir: tracked files and untracked ones .gitignore does not hide, minus
+// those matching the default and exclude patterns. Beyond max, the
+// top-level files and a count per top-level directory come first, then the
+// files next to related (the paths a pull request changes), then the rest,
+// shallowest first. omitted counts the files left out.
+//
+// A pattern ending in "/" excludes a directory wherever it appears; any
+// other is matched against the path and against the file name.
+func ListRepoFiles(workdir string, exclude, related []string, max int) (entries []string, omitted int, err error) {
+	if max <= 0 {
+		return nil, 0, nil
+	}
+	out, err := gitOutput(workdir, "ls-files", "--cached", "--others", "--exclude-standard", "-z")
+	if err != nil {
+		return nil, 0, err
+	}
+	patterns := append(append([]string(nil), defaultFileListExclude...), exclude...)
+	seen := make(map[string]bool)
+	var files []string
+	for _, f := range strings.Split(out, "\x00") {
+		if f == "" || seen[f] || excludedFile(f, patterns) {
+			continue
+		}
+		seen[f] = true
+		files = append(files, f)
+	}
+	sort.Strings(files)
+	if len(files) <= max {
+		return files, 0, nil
+	}
+	entries, listed := prioritizeFiles(files, related, max)
+	return entries, len(files) - listed, nil
+}
+
+// prioritizeFiles picks max entries of sorted files, as ListRepoFiles
+// describes; listed counts the files among them.
+func prioritizeFiles(files, related []string, max int) (entries []string, listed int) {
+	var top []string
+	dirCount := make(map[string]int)
+	for _, f := range files {
+		if dir, _, ok := strings.Cut(f, "/"); ok {
+			dirCount[dir]++
+		} else {
+			top = append(top, f)
+		}
+	}
+	rootFiles := len(top)
+	dirs := make([]string, 0, len(dirCount))
+	for dir := range dirCount {
+		dirs = append(dirs, dir)
+	}
+	sort.Strings(dirs)
+	for _, dir := range dirs {
+		top = append(top, fmt.Sprintf("%s/ (%d files)", dir, dirCount[dir]))
+	}
+	if len(top) >= max {
+		return top[:max], min(rootFiles, max)
+	}
+
+	relatedDirs := make(map[string]bool)
+	for _, p := range related {
+		relatedDirs[path.Dir(p)] = true
+	}
+	var near, rest []string
+	for _, f := range files {
+		switch dir := path.Dir(f); {
+		case dir == ".":
+			// listed with the top level
+		case relatedDirs[dir]:
+			near = append(near, f)
+		default:
+			rest = append(rest, f)
+		}
+	}
+	sort.SliceStable(rest, func(i, j int) bool {
+		return strings.Count(rest[i], "/") < strings.Count(rest[j], "/")
+	})
+
+	entries, listed = top, rootFiles
+	for _, group := range [][]string{near, rest} {
+		room := max - len(entries)
+		if room <= 0 {
+			break
+		}
+		if len(group) > room {
+			group = group[:room]
+		}
+		entries = append(entries, group...)
+		listed += len(group)
+	}
+	return entries, listed
+}
+
+// excludedFile reports whether file matches one of patterns.
+func excludedFile(file string, patterns []string) bool {
+	for _, p := range patterns {
+		if dir, ok := strings.CutSuffix(p, "/"); ok {
+			if strings.HasPrefix(file, dir+"/") || strings.Contains(file, "/"+dir+"/") {
+				return true
+			}
+			continue
+		}
+		if ok, _ := path.Match(p, file); ok {
+			return true
+		}
+		if ok, _ := path.Match(p, path.Base(file)); ok {
+			return true
+		}
+	}
+	return false
+}
+
+// repoFileList lists the files of workdir for a task's prompt, logging
+// failures: the prompt does without the list.
+func repoFileList(workdir string, exclude []string, fetched *ghdata.FetchResult, max int) ([]string, int) {
+	entries, omitted, err := ListRepoFiles(workdir, exclude, fetchedPaths(fetched), max)
+	if err != nil {
+		phaseLog("prompt").Warn("Listing repository files failed", "err", err)
+		return nil, 0
+	}
+	return entries, omitted
+}
+
+// fetchedPaths returns the paths the fetched pull request changes.
+func fetchedPaths(fetched *ghdata.FetchResult) []string {
+	if fetched == nil {
+		return nil
+	}
+	paths := make([]string, 0, len(fetched.ChangedSHA))
+	for _, f := range fetched.ChangedSHA {
+		paths = append(paths, f.Path)
+	}
+	return paths
+}
@@ -0,0 +1,84 @@
+package executor
+
+import (
+	"os"
+	"path/filepath"
+	"reflect"
+	"testing"
+)
+
+func TestListRepoFiles_GitignoreAndExcludes(t *testing.T) {
+	dir := initLocalRepo(t)
+	for name, content := range map[string]string{
+		".gitignore":            "build/\n",
+		"main.go":               "package main",
+		"build/out.bin":         "x",
+		"vendor/lib/lib.go":     "package lib",
+		"web/node_modules/a.js": "x",
+		"web/app.min.js":        "x",
+		"fixtures/big.json":     "{}",
+		"internal/api/api.go":   "package api",
+	} {
+		path := filepath.Join(dir, name)
+		if err := os.MkdirAll(filepath.Dir(path), 0o755); err != nil {
+			t.Fatal(err)
+		}
+		if err := os.WriteFile(path, []byte(content), 0o644); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	files, omitted, err := ListRepoFiles(dir, []string{"fixtures/"}, nil, 100)
+	if err != nil {
+		t.Fatal(err)
+	}
+	want := []string{".gitignore", "README.md", "internal/api/api.go", "main.go"}
+	if !reflect.DeepEqual(files, want) || omitted != 0 {
+		t.Fatalf("ListRepoFiles = %v, %d; want %v", files, omitted, want)
+	}
+	if files, _, _ := ListRepoFiles(dir, nil, nil, 0); files != nil {
+		t.Fatalf("max 0 listed %v", files)
+	}
+}
+
+func TestPrioritizeFiles(t *testing.T) {
+	files := []string{
+		"Makefile",
+		"cmd/a/main.go",
+		"cmd/b/main.go",
+		"docs/guide/intro.md",
+		"docs/index.md",
+		"internal/parser/lexer.go",
+		"internal/parser/parser.go",
+		"internal/store/store.go",
+	}
+	got, listed := prioritizeFiles(files, []string{"internal/parser/parser.go"}, 7)
+	want := []string{
+		"Makefile",
+		"cmd/ (2 files)",
+		"docs/ (2 files)",
+		"internal/ (3 files)",
+		"internal/parser/lexer.go", // next to the change
+		"internal/parser/parser.go",
+		"docs/index.md", // shallowest of the rest
+	}
+	if !reflect.DeepEqual(got, want) || listed != 4 {
+		t.Fatalf("prioritizeFiles = %v, %d", got, listed)
+	}
+}
+
+func TestExcludedFile(t *testing.T) {
+	patterns := []string{"vendor/", "*.snap", "docs/*.md"}
+	for file, want := range map[string]bool{
+		"vendor/a.go":             true,
+		"pkg/vendor/a.go":         true,
+		"vendorish/a.go":          false,
+		"ui/__snapshots__/x.snap": true,
+		"docs/index.md":           true,
+		"docs/guide/intro.md":     false,
+	} {
+		if got := excludedFile(file, patterns); got != want {
+			t.Errorf("excludedFile(%q) = %t, want %t", file, got, want)
+		}
+	}
+}
@@ -0,0 +1,117 @@
+package executor
+
+import (
+	"context"
+	"fmt"
+	"regexp"
+	"sort"
+	"strconv"
+	"strings"
+
+	"github.com/cexll/swe/internal/github"
+	ghdata "github.com/cexll/swe/internal/github/data"
+)
+
+// reviewThreadsIface reads, answers and resolves pull request review
+// threads (*ghdata.Client).
+type reviewThreadsIface interface {
+	UnresolvedReviewThreads(ctx context.Context, repo string, number int) ([]ghdata.ReviewThread, error)
+	ReplyToReviewThread(ctx context.Context, repo, threadID, body string) error
+	ResolveReviewThread(ctx context.Context, repo, threadID string) error
+}
+
+// reviewThreadTrailer is the commit trailer naming the review threads a
+// commit addresses, by their number in the prompt: "Review-Thread: 2, 5".
+var reviewThreadTrailer = regexp.MustCompile(`(?mi)^Review-Thread:[ \t]*(.+)$`)
+
+// addressReviewsPromptSection lists the review threads to address and how
+// to mark which commit addresses which.
+func addressReviewsPromptSection(threads []ghdata.ReviewThread) string {
+	return fmt.Sprintf(`<review_threads>
+Address the unresolved review threads of this pull request, listed below as a checklist. For each thread you address, make the fix in its own commit and end the commit message with the trailer line "Review-Thread: <number>" (several comma-separated numbers only when one change addresses several threads), then push as usual.
+Once the push is verified, every thread named in a pushed commit's trailer gets a reply naming the commit and is resolved. Leave a thread alone when it needs no change, you disagree, or you cannot address it, and say why in your summary. Outdated threads refer to code that has changed since; check whether they still apply.
+
+%s
+</review_threads>`, ghdata.FormatReviewThreads(threads))
+}
+
+// addressedThreads maps the threads (numbered from 1) named in the
+// Review-Thread trailers of the commits pushed to branch since startSHA to
+// the first commit naming each.
+func addressedThreads(workdir, branch, startSHA string, count int) map[int]string {
+	pushed := fetchRemoteHead(workdir, branch)
+	if pushed == "" || startSHA == "" {
+		return nil
+	}
+	out, err := gitOutput(workdir, "log", "--reverse", "--format=%H%x00%B%x1e", startSHA+".."+pushed)
+	if err != nil {
+		phaseLog("reviews").Warn("Reading pushed commits failed", "err", err)
+		return nil
+	}
+	addressed := make(map[int]string)
+	for _, record := range strings.Split(out, "\x1e") {
+		sha, message, ok := strings.Cut(strings.TrimSpace(record), "\x00")
+		if !ok {
+			continue
+		}
+		for _, m := range reviewThreadTrailer.FindAllStringSubmatch(message, -1) {
+			for _, field := range strings.FieldsFunc(m[1], func(r rune) bool { return r == ',' || r == ' ' || r == '\t' }) {
+				n, err := strconv.Atoi(strings.TrimPrefix(field, "#"))
+				if err != nil || n < 1 || n > count {
+					continue
+				}
+				if _, seen := addressed[n]; !seen {
+					addressed[n] = sha
+				}
+			}
+		}
+	}
+	return addressed
+}
+
+// resolveAddressedThreads replies to and resolves each review thread a
+// pushed commit names as addressed, and reports on the tracking comment
+// which threads were addressed and which were not.
+func (e *Executor) resolveAddressedThreads(ctx context.Context, ghCtx *github.Context, repo, workdir, branch, startSHA string, threads []ghdata.ReviewThread) {
+	addressed := addressedThreads(workdir, branch, startSHA, len(threads))
+	numbers := make([]int, 0, len(addressed))
+	for n := range addressed {
+		numbers = append(numbers, n)
+	}
+	sort.Ints(numbers)
+
+	var done, failed, open []string
+	for _, n := range numbers {
+		t, sha := threads[n-1], addressed[n]
+		err := e.reviews.ReplyToReviewThread(ctx, repo, t.ID, fmt.Sprintf("Addressed in %s.", sha))
+		if err == nil {
+			err = e.reviews.ResolveReviewThread(ctx, repo, t.ID)
+		}
+		if err != nil {
+			taskLog(ghCtx, "reviews").Warn("Resolving review thread failed", "thread", t.Location(), "err", err)
+			failed = append(failed, "`"+t.Location()+"`")
+			continue
+		}
+		done = append(done, fmt.Sprintf("`%s` in %s", t.Location(), sha))
+	}
+	for i, t := range threads {
+		if _, ok := addressed[i+1]; !ok {
+			open = append(open, "`"+t.Location()+"`")
+		}
+	}
+	if e.store != nil && ghCtx.TaskID != "" {
+		e.store.AddLog(ghCtx.TaskID, "info", fmt.Sprintf("Resolved %d of %d review threads", len(done), len(threads)))
+	}
+
+	notice := fmt.Sprintf("> [!NOTE]\n> **Review threads:** %d of %d addressed and resolved.", len(done), len(threads))
+	for _, d := range done {
+		notice += "\n> - " + d
+	}
+	if len(failed) > 0 {
+		notice += "\n>\n> Addressed, but the reply or resolve failed: " + strings.Join(failed, ", ") + "."
+	}
+	if len(open) > 0 {
+		notice += "\n>\n> Not addressed: " + strings.Join(open, ", ") + "."
+	}
+	prependNotice(ghCtx, notice)
+}
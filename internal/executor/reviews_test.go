@@ -0,0 +1,119 @@
+package executor
+
+import (
+	"context"
+	"errors"
+	"os"
+	"os/exec"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"github.com/cexll/swe/internal/github"
+	ghdata "github.com/cexll/swe/internal/github/data"
+	"github.com/cexll/swe/internal/provider"
+	"github.com/cexll/swe/internal/provider/mcpconfig"
+)
+
+type mockReviews struct {
+	threads []ghdata.ReviewThread
+	calls   []string
+	failOn  string // thread whose resolve fails
+}
+
+func (m *mockReviews) UnresolvedReviewThreads(context.Context, string, int) ([]ghdata.ReviewThread, error) {
+	return m.threads, nil
+}
+
+func (m *mockReviews) ReplyToReviewThread(_ context.Context, _, threadID, body string) error {
+	m.calls = append(m.calls, "reply "+threadID+": "+body)
+	return nil
+}
+
+func (m *mockReviews) ResolveReviewThread(_ context.Context, _, threadID string) error {
+	if threadID == m.failOn {
+		return errors.New("forbidden")
+	}
+	m.calls = append(m.calls, "resolve "+threadID)
+	return nil
+}
+
+func reviewThread(id, path string) ghdata.ReviewThread {
+	var t ghdata.ReviewThread
+	t.ID, t.Path = id, path
+	t.Comments.Nodes = []ghdata.Comment{{Body: "please fix " + path, Author: ghdata.Author{Login: "rev"}}}
+	return t
+}
+
+func TestExecute_AddressReviews(t *testing.T) {
+	workdir, remote := initPushRepo(t)
+	gitIn(t, workdir, "push", "-q", "origin", "main:refs/heads/feature")
+	updated := stubComments(t, "Addressed the review.")
+	e, _, _ := approvalExecutor(t, workdir)
+	reviews := &mockReviews{threads: []ghdata.ReviewThread{reviewThread("T1", "a.go"), reviewThread("T2", "b.go"), reviewThread("T3", "c.go")}, failOn: "T3"}
+	e.reviews = reviews
+	e.fetcher = &mockFetcher{fetchFunc: func(context.Context, *github.Context) (*ghdata.FetchResult, error) {
+		return &ghdata.FetchResult{ContextData: ghdata.PullRequest{Title: "t", HeadRefName: "feature", BaseRefName: "main"}}, nil
+	}}
+
+	var prompt string
+	e.provider = &mockProvider{generateFunc: func(_ context.Context, req *provider.CodeRequest) (*provider.CodeResponse, error) {
+		prompt = req.Prompt
+		if on, ok := req.MCPServers[mcpconfig.ReviewServer]; !ok || on {
+			t.Errorf("the review thread MCP server should be off while the executor resolves threads: %v", req.MCPServers)
+		}
+		for _, c := range []struct{ file, msg string }{
+			{"a.go", "Rename a\n\nReview-Thread: 1"},
+			{"c.go", "Fix c\n\nReview-Thread: #3, 9"},
+		} {
+			if err := os.WriteFile(filepath.Join(workdir, c.file), []byte("package x\n"), 0o644); err != nil {
+				return nil, err
+			}
+			gitIn(t, workdir, "add", c.file)
+			gitIn(t, workdir, "commit", "-q", "-m", c.msg)
+		}
+		cmd := exec.Command("git", "-C", workdir, "push", "-q", "origin", "HEAD:refs/heads/feature")
+		cmd.Env = append(os.Environ(), req.Env...)
+		if out, err := cmd.CombinedOutput(); err != nil {
+			t.Fatalf("push: %v\n%s", err, out)
+		}
+		return &provider.CodeResponse{Summary: "done"}, nil
+	}}
+
+	ctx := buildTestCtx(true)
+	ctx.PreparedCommentID = 7
+	ctx.PreparedAddressReviews = true
+	if err := e.Execute(context.Background(), ctx); err != nil {
+		t.Fatalf("Execute: %v", err)
+	}
+
+	if !strings.Contains(prompt, "<review_threads>") || !strings.Contains(prompt, "1. [ ] a.go\n   [rev at ]: please fix a.go") ||
+		!strings.Contains(prompt, `"Review-Thread: <number>"`) {
+		t.Fatalf("prompt lacks the review threads:\n%s", prompt)
+	}
+	first := gitIn(t, remote, "rev-parse", "feature~1")
+	if strings.Join(reviews.calls, "; ") != "reply T1: Addressed in "+first+".; resolve T1; reply T3: Addressed in "+gitIn(t, remote, "rev-parse", "feature")+"." {
+		t.Fatalf("calls = %v", reviews.calls)
+	}
+	if !strings.Contains(*updated, "**Review threads:** 1 of 3 addressed and resolved.\n> - `a.go` in "+first) ||
+		!strings.Contains(*updated, "the reply or resolve failed: `c.go`.") || !strings.Contains(*updated, "Not addressed: `b.go`.") {
+		t.Fatalf("tracking comment = %q", *updated)
+	}
+}
+
+func TestExecute_AddressReviewsWithoutThreads(t *testing.T) {
+	workdir, _ := initPushRepo(t)
+	updated := stubComments(t, "Working on it.")
+	e, prompt, _ := approvalExecutor(t, workdir)
+	e.reviews = &mockReviews{}
+
+	ctx := buildTestCtx(true)
+	ctx.PreparedCommentID = 7
+	ctx.PreparedAddressReviews = true
+	if err := e.Execute(context.Background(), ctx); err != nil {
+		t.Fatalf("Execute: %v", err)
+	}
+	if *prompt != "" || !strings.Contains(*updated, "no unresolved review threads") {
+		t.Fatalf("provider ran or comment = %q", *updated)
+	}
+}
@@ -0,0 +1,383 @@
+package executor
+
+import (
+	"bufio"
+	"context"
+	"encoding/json"
+	"fmt"
+	"io/fs"
+	"os"
+	"os/exec"
+	"path"
+	"path/filepath"
+	"sort"
+	"strings"
+)
+
+// packageScope lists the packages a pull request affects: those containing
+// changed files plus everything that depends on them.
+type packageScope struct {
+	Go   []string // Go import paths
+	Pnpm []string // pnpm workspace package names
+}
+
+func (s *packageScope) empty() bool { return len(s.Go) == 0 && len(s.Pnpm) == 0 }
+
+// env exposes the scope to a scoped verify command.
+func (s *packageScope) env() []string {
+	filters := make([]string, len(s.Pnpm))
+	for i, name := range s.Pnpm {
+		filters[i] = "--filter=" + name
+	}
+	return []string{
+		"SWE_AFFECTED_GO_PACKAGES=" + strings.Join(s.Go, " "),
+		"SWE_AFFECTED_PNPM_PACKAGES=" + strings.Join(s.Pnpm, " "),
+		"SWE_AFFECTED_PNPM_FILTERS=" + strings.Join(filters, " "),
+	}
+}
+
+type packageScopeKey struct{}
+
+func withPackageScope(ctx context.Context, s *packageScope) context.Context {
+	return context.WithValue(ctx, packageScopeKey{}, s)
+}
+
+// packageScopeFrom returns the scope verification runs under, or nil when
+// the whole repository has to be verified.
+func packageScopeFrom(ctx context.Context) *packageScope {
+	s, _ := ctx.Value(packageScopeKey{}).(*packageScope)
+	return s
+}
+
+// wantsScope reports whether any check can run scoped to affected packages.
+func wantsScope(checks []PostPushCheck) bool {
+	for _, c := range checks {
+		if cc, ok := c.(CommandCheck); ok && cc.ScopedCommand != "" {
+			return true
+		}
+	}
+	return false
+}
+
+// scopeVerification attaches the packages affected by the pull request's
+// changes (base...pushed) to ctx. When they cannot be determined the
+// context is returned unchanged and the full verification runs.
+func scopeVerification(ctx context.Context, workdir, base, pushed string) context.Context {
+	files, err := changedFiles(workdir, base, pushed)
+	var scope *packageScope
+	if err == nil {
+		scope, err = affectedPackages(ctx, workdir, files)
+	}
+	if err != nil {
+		phaseLog("verify").InfoContext(ctx, "Running full verification", "err", err)
+		return ctx
+	}
+	phaseLog("verify").InfoContext(ctx, "Scoped verification", "files", len(files), "go_packages", len(scope.Go), "pnpm_packages", len(scope.Pnpm))
+	return withPackageScope(ctx, scope)
+}
+
+// changedFiles lists the files changed between base and pushed. Clones are
+// shallow, so the history back to the merge base is fetched first.
+func changedFiles(workdir, base, pushed string) ([]string, error) {
+	refspec := fmt.Sprintf("+refs/heads/%s:refs/remotes/origin/%s", base, base)
+	args := []string{"-C", workdir, "fetch", "-q"}
+	if shallow, _ := gitOutput(workdir, "rev-parse", "--is-shallow-repository"); strings.TrimSpace(shallow) == "true" {
+		args = append(args, "--unshallow")
+	}
+	if err := runCmd("git", append(args, "origin", refspec)...); err != nil {
+		return nil, fmt.Errorf("fetch %s: %w", base, err)
+	}
+	out, err := gitOutput(workdir, "diff", "--name-only", "-z", "--no-renames", "origin/"+base+"..."+pushed)
+	if err != nil {
+		return nil, fmt.Errorf("diff against %s: %w", base, err)
+	}
+	return gitPaths(out), nil
+}
+
+// affectedPackages maps changed files to Go packages and pnpm workspace
+// packages and adds their dependents. A change outside every package
+// (other than documentation) affects the whole repository and is an error.
+func affectedPackages(ctx context.Context, workdir string, files []string) (*packageScope, error) {
+	goPkgs, err := listGoPackages(ctx, workdir)
+	if err != nil {
+		return nil, err
+	}
+	pnpmPkgs, err := listPnpmPackages(workdir)
+	if err != nil {
+		return nil, err
+	}
+
+	goByDir := make(map[string]*goPackage)
+	modules := make(map[string][]string) // module dir -> import paths
+	for i := range goPkgs {
+		p := &goPkgs[i]
+		goByDir[p.dir] = p
+		modules[p.module] = append(modules[p.module], p.importPath)
+	}
+	pnpmByDir := make(map[string]*pnpmPackage)
+	for i := range pnpmPkgs {
+		pnpmByDir[pnpmPkgs[i].dir] = &pnpmPkgs[i]
+	}
+
+	goChanged := make(map[string]bool)
+	pnpmChanged := make(map[string]bool)
+	for _, f := range files {
+		f = filepath.ToSlash(f)
+		dir := path.Dir(f)
+		if base := path.Base(f); base == "go.mod" || base == "go.sum" {
+			if pkgs, ok := modules[dir]; ok {
+				for _, p := range pkgs {
+					goChanged[p] = true
+				}
+				continue
+			}
+		}
+		matched := false
+		for d := dir; ; d = path.Dir(d) {
+			// only Go files make the root package affected; other root
+			// files (Makefile, CI config) concern the whole repository
+			if p, ok := goByDir[d]; ok && !matched && (d != "." || strings.HasSuffix(f, ".go")) {
+				goChanged[p.importPath] = true
+				matched = true
+			}
+			if p, ok := pnpmByDir[d]; ok && d != "." {
+				pnpmChanged[p.name] = true
+				matched = true
+				break
+			}
+			if d == "." {
+				break
+			}
+		}
+		if !matched && !isDocumentation(f) {
+			return nil, fmt.Errorf("changes to %s affect the whole repository", f)
+		}
+	}
+
+	goDependents := make(map[string][]string)
+	for _, p := range goPkgs {
+		for _, imp := range p.imports {
+			goDependents[imp] = append(goDependents[imp], p.importPath)
+		}
+	}
+	pnpmDependents := make(map[string][]string)
+	for _, p := range pnpmPkgs {
+		for _, dep := range p.deps {
+			pnpmDependents[dep] = append(pnpmDependents[dep], p.name)
+		}
+	}
+	return &packageScope{
+		Go:   withDependents(goChanged, goDependents),
+		Pnpm: withDependents(pnpmChanged, pnpmDependents),
+	}, nil
+}
+
+// withDependents returns changed plus everything that transitively depends
+// on it, sorted.
+func withDependents(changed map[string]bool, dependents map[string][]string) []string {
+	queue := make([]string, 0, len(changed))
+	for p := range changed {
+		queue = append(queue, p)
+	}
+	for len(queue) > 0 {
+		p := queue[0]
+		queue = queue[1:]
+		for _, d := range dependents[p] {
+			if !changed[d] {
+				changed[d] = true
+				queue = append(queue, d)
+			}
+		}
+	}
+	out := make([]string, 0, len(changed))
+	for p := range changed {
+		out = append(out, p)
+	}
+	sort.Strings(out)
+	return out
+}
+
+// isDocumentation reports whether a change to f cannot affect tests.
+func isDocumentation(f string) bool {
+	return strings.HasSuffix(strings.ToLower(f), ".md") || strings.HasPrefix(f, "docs/")
+}
+
+type goPackage struct {
+	importPath string
+	dir        string // relative to the repository root
+	module     string // directory of the enclosing go.mod
+	imports    []string
+}
+
+// listGoPackages runs go list in every module of the repository.
+func listGoPackages(ctx context.Context, workdir string) ([]goPackage, error) {
+	var moduleDirs []string
+	err := filepath.WalkDir(workdir, func(p string, d fs.DirEntry, err error) error {
+		if err != nil {
+			return err
+		}
+		if d.IsDir() {
+			name := d.Name()
+			if p != workdir && (strings.HasPrefix(name, ".") || strings.HasPrefix(name, "_") ||
+				name == "node_modules" || name == "vendor" || name == "testdata") {
+				return filepath.SkipDir
+			}
+			return nil
+		}
+		if d.Name() == "go.mod" {
+			moduleDirs = append(moduleDirs, filepath.Dir(p))
+		}
+		return nil
+	})
+	if err != nil {
+		return nil, fmt.Errorf("find Go modules: %w", err)
+	}
+
+	// go list reports resolved directories
+	root := workdir
+	if resolved, err := filepath.EvalSymlinks(workdir); err == nil {
+		root = resolved
+	}
+	var pkgs []goPackage
+	for _, modDir := range moduleDirs {
+		cmd := exec.CommandContext(ctx, "go", "list", "-e", "-f",
+			"{{.ImportPath}}\t{{.Dir}}\t{{join .Imports \" \"}} {{join .TestImports \" \"}} {{join .XTestImports \" \"}}", "./...")
+		cmd.Dir = modDir
+		cmd.Env = scrubbedEnv()
+		out, err := cmd.Output()
+		if err != nil {
+			return nil, fmt.Errorf("go list in %s: %w", modDir, err)
+		}
+		module := relSlash(workdir, modDir)
+		sc := bufio.NewScanner(strings.NewReader(string(out)))
+		for sc.Scan() {
+			fields := strings.SplitN(sc.Text(), "\t", 3)
+			if len(fields) != 3 {
+				continue
+			}
+			pkgs = append(pkgs, goPackage{
+				importPath: fields[0],
+				dir:        relSlash(root, fields[1]),
+				module:     module,
+				imports:    strings.Fields(fields[2]),
+			})
+		}
+	}
+	return pkgs, nil
+}
+
+type pnpmPackage struct {
+	name string
+	dir  string // relative to the repository root
+	deps []string
+}
+
+// listPnpmPackages reads the workspace packages named in pnpm-workspace.yaml.
+// Negated patterns are ignored, which only widens the scope.
+func listPnpmPackages(workdir string) ([]pnpmPackage, error) {
+	data, err := os.ReadFile(filepath.Join(workdir, "pnpm-workspace.yaml"))
+	if os.IsNotExist(err) {
+		return nil, nil
+	}
+	if err != nil {
+		return nil, fmt.Errorf("read pnpm workspace: %w", err)
+	}
+
+	var dirs []string
+	seen := make(map[string]bool)
+	add := func(dir string) {
+		rel := relSlash(workdir, dir)
+		if !seen[rel] && rel != "." {
+			if _, err := os.Stat(filepath.Join(dir, "package.json")); err == nil {
+				seen[rel] = true
+				dirs = append(dirs, rel)
+			}
+		}
+	}
+	for _, pattern := range pnpmWorkspacePatterns(string(data)) {
+		if strings.HasPrefix(pattern, "!") {
+			continue
+		}
+		if root, ok := strings.CutSuffix(pattern, "/**"); ok {
+			_ = filepath.WalkDir(filepath.Join(workdir, root), func(p string, d fs.DirEntry, err error) error {
+				if err != nil || !d.IsDir() {
+					return nil
+				}
+				if d.Name() == "node_modules" || strings.HasPrefix(d.Name(), ".") {
+					return filepath.SkipDir
+				}
+				add(p)
+				return nil
+			})
+			continue
+		}
+		matches, err := filepath.Glob(filepath.Join(workdir, filepath.FromSlash(pattern)))
+		if err != nil {
+			return nil, fmt.Errorf("pnpm workspace pattern %q: %w", pattern, err)
+		}
+		for _, m := range matches {
+			add(m)
+		}
+	}
+
+	pkgs := make([]pnpmPackage, 0, len(dirs))
+	for _, dir := range dirs {
+		var manifest struct {
+			Name                 string            `json:"name"`
+			Dependencies         map[string]string `json:"dependencies"`
+			DevDependencies      map[string]string `json:"devDependencies"`
+			PeerDependencies     map[string]string `json:"peerDependencies"`
+			OptionalDependencies map[string]string `json:"optionalDependencies"`
+		}
+		raw, err := os.ReadFile(filepath.Join(workdir, filepath.FromSlash(dir), "package.json"))
+		if err != nil {
+			return nil, fmt.Errorf("read package.json: %w", err)
+		}
+		if err := json.Unmarshal(raw, &manifest); err != nil {
+			return nil, fmt.Errorf("parse %s/package.json: %w", dir, err)
+		}
+		if manifest.Name == "" {
+			return nil, fmt.Errorf("%s/package.json has no name", dir)
+		}
+		p := pnpmPackage{name: manifest.Name, dir: dir}
+		for _, deps := range []map[string]string{manifest.Dependencies, manifest.DevDependencies, manifest.PeerDependencies, manifest.OptionalDependencies} {
+			for name := range deps {
+				p.deps = append(p.deps, name)
+			}
+		}
+		pkgs = append(pkgs, p)
+	}
+	return pkgs, nil
+}
+
+// pnpmWorkspacePatterns extracts the "packages:" list from
+// pnpm-workspace.yaml without a full YAML parser.
+func pnpmWorkspacePatterns(doc string) []string {
+	var patterns []string
+	inPackages := false
+	for _, line := range strings.Split(doc, "\n") {
+		if i := strings.Index(line, " #"); i >= 0 {
+			line = line[:i]
+		}
+		trimmed := strings.TrimSpace(line)
+		if trimmed == "" || strings.HasPrefix(trimmed, "#") {
+			continue
+		}
+		if !strings.HasPrefix(line, " ") && !strings.HasPrefix(line, "\t") && !strings.HasPrefix(line, "-") {
+			inPackages = trimmed == "packages:"
+			continue
+		}
+		if item, ok := strings.CutPrefix(trimmed, "- "); ok && inPackages {
+			patterns = append(patterns, strings.Trim(strings.TrimSpace(item), `'"`))
+		}
+	}
+	return patterns
+}
+
+func relSlash(root, p string) string {
+	rel, err := filepath.Rel(root, p)
+	if err != nil {
+		return filepath.ToSlash(p)
+	}
+	return filepath.ToSlash(rel)
+}
@@ -0,0 +1,157 @@
+package executor
+
+import (
+	"context"
+	"os"
+	"path/filepath"
+	"reflect"
+	"strings"
+	"testing"
+)
+
+func writeFiles(t *testing.T, root string, files map[string]string) {
+	t.Helper()
+	for name, content := range files {
+		path := filepath.Join(root, filepath.FromSlash(name))
+		if err := os.MkdirAll(filepath.Dir(path), 0o755); err != nil {
+			t.Fatal(err)
+		}
+		if err := os.WriteFile(path, []byte(content), 0o644); err != nil {
+			t.Fatal(err)
+		}
+	}
+}
+
+// initMonorepo returns a repository with a Go module (api imports store) and
+// a pnpm workspace (web depends on ui).
+func initMonorepo(t *testing.T) string {
+	t.Helper()
+	root := t.TempDir()
+	writeFiles(t, root, map[string]string{
+		"services/go.mod":               "module example.com/services\n\ngo 1.21\n",
+		"services/store/store.go":       "package store\n\nfunc Get() string { return \"\" }\n",
+		"services/store/testdata/a.txt": "fixture\n",
+		"services/api/api.go":           "package api\n\nimport \"example.com/services/store\"\n\nvar _ = store.Get\n",
+		"services/cli/main.go":          "package main\n\nfunc main() {}\n",
+		"pnpm-workspace.yaml":           "# workspace\npackages:\n  - 'packages/*'\n  - \"apps/**\"\n  - '!**/test/**'\n",
+		"package.json":                  `{"name": "root", "private": true}`,
+		"packages/ui/package.json":      `{"name": "@acme/ui"}`,
+		"packages/ui/src/button.ts":     "export {}\n",
+		"apps/site/web/package.json":    `{"name": "web", "dependencies": {"@acme/ui": "workspace:*"}}`,
+		"apps/docs/package.json":        `{"name": "docs"}`,
+	})
+	return root
+}
+
+func TestPnpmWorkspacePatterns(t *testing.T) {
+	doc := "packages:\n  - 'packages/*' # libraries\n  - \"apps/**\"\n  - '!**/test/**'\ncatalog:\n  - react\n"
+	want := []string{"packages/*", "apps/**", "!**/test/**"}
+	if got := pnpmWorkspacePatterns(doc); !reflect.DeepEqual(got, want) {
+		t.Fatalf("patterns = %q, want %q", got, want)
+	}
+}
+
+func TestAffectedPackages(t *testing.T) {
+	root := initMonorepo(t)
+	tests := []struct {
+		name  string
+		files []string
+		want  *packageScope
+		err   string
+	}{
+		{"go package with dependents", []string{"services/store/store.go"},
+			&packageScope{Go: []string{"example.com/services/api", "example.com/services/store"}, Pnpm: []string{}}, ""},
+		{"test fixture", []string{"services/store/testdata/a.txt"},
+			&packageScope{Go: []string{"example.com/services/api", "example.com/services/store"}, Pnpm: []string{}}, ""},
+		{"go.mod affects the module", []string{"services/go.mod"},
+			&packageScope{Go: []string{"example.com/services/api", "example.com/services/cli", "example.com/services/store"}, Pnpm: []string{}}, ""},
+		{"pnpm package with dependents", []string{"packages/ui/src/button.ts"},
+			&packageScope{Go: []string{}, Pnpm: []string{"@acme/ui", "web"}}, ""},
+		{"documentation only", []string{"README.md", "docs/setup.txt"},
+			&packageScope{Go: []string{}, Pnpm: []string{}}, ""},
+		{"repository-wide file", []string{"services/api/api.go", "pnpm-lock.yaml"}, nil, "pnpm-lock.yaml affect the whole repository"},
+		{"root manifest", []string{"package.json"}, nil, "package.json affect the whole repository"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got, err := affectedPackages(context.Background(), root, tt.files)
+			if tt.err != "" {
+				if err == nil || !strings.Contains(err.Error(), tt.err) {
+					t.Fatalf("expected error %q, got %v", tt.err, err)
+				}
+				return
+			}
+			if err != nil {
+				t.Fatalf("affectedPackages: %v", err)
+			}
+			if !reflect.DeepEqual(got, tt.want) {
+				t.Fatalf("scope = %+v, want %+v", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestVerifyPushed_ScopesPullRequests(t *testing.T) {
+	workdir, _ := initPushRepo(t)
+	stubComments(t, "")
+	writeFiles(t, workdir, map[string]string{
+		"mod/go.mod":   "module example.com/mod\n\ngo 1.21\n",
+		"mod/a/a.go":   "package a\n",
+		"mod/b/b.go":   "package b\n",
+		"mod/b/b.json": "{}\n",
+	})
+	gitIn(t, workdir, "add", "-A")
+	gitIn(t, workdir, "commit", "-q", "-m", "add module")
+	gitIn(t, workdir, "push", "-q", "origin", "HEAD:main")
+	gitIn(t, workdir, "checkout", "-q", "-b", "feature")
+	before := gitIn(t, workdir, "rev-parse", "HEAD")
+	gitIn(t, workdir, "push", "-q", "origin", "HEAD:refs/heads/feature")
+
+	out := filepath.Join(t.TempDir(), "scope")
+	check := CommandCheck{
+		Command:       "echo full > " + out,
+		ScopedCommand: `echo "$SWE_AFFECTED_GO_PACKAGES" > ` + out,
+	}
+	e, _ := newVerifyExecutor(t, check)
+	push := func(file string) string {
+		t.Helper()
+		writeFiles(t, workdir, map[string]string{file: "changed\n"})
+		gitIn(t, workdir, "checkout", "-q", "feature")
+		gitIn(t, workdir, "add", "-A")
+		gitIn(t, workdir, "commit", "-q", "-m", "change "+file)
+		gitIn(t, workdir, "push", "-q", "origin", "HEAD:refs/heads/feature")
+		_ = os.Remove(out)
+		if err := e.verifyPushed(context.Background(), buildTestCtx(true), workdir, "feature", "main", before); err != nil {
+			t.Fatalf("verifyPushed: %v", err)
+		}
+		before = gitIn(t, workdir, "rev-parse", "origin/feature")
+		data, _ := os.ReadFile(out)
+		return strings.TrimSpace(string(data))
+	}
+
+	if got := push("mod/b/b.json"); got != "example.com/mod/b" {
+		t.Fatalf("scoped run saw %q", got)
+	}
+	if got := push("CHANGES.md"); got != "example.com/mod/b" {
+		// the PR diff still contains mod/b/b.json
+		t.Fatalf("scoped run saw %q", got)
+	}
+	if got := push("Makefile"); got != "full" {
+		t.Fatalf("repository-wide change should run the full command, got %q", got)
+	}
+}
+
+func TestCommandCheck_Scoped(t *testing.T) {
+	dir := t.TempDir()
+	check := CommandCheck{Command: "exit 1", ScopedCommand: `test "$SWE_AFFECTED_PNPM_FILTERS" = "--filter=@acme/ui --filter=web"`}
+	ctx := withPackageScope(context.Background(), &packageScope{Pnpm: []string{"@acme/ui", "web"}})
+	if err := check.Check(ctx, dir, "b"); err != nil {
+		t.Fatalf("scoped command: %v", err)
+	}
+	if err := check.Check(withPackageScope(context.Background(), &packageScope{}), dir, "b"); err != nil {
+		t.Fatalf("nothing affected should skip verification: %v", err)
+	}
+	if err := check.Check(context.Background(), dir, "b"); err == nil {
+		t.Fatal("without a scope the full command runs")
+	}
+}
@@ -0,0 +1,262 @@
+package executor
+
+import (
+	"bufio"
+	"errors"
+	"fmt"
+	"io"
+	"os"
+	"regexp"
+	"strconv"
+	"strings"
+
+	"github.com/cexll/swe/internal/github"
+)
+
+// blockedSecret is the git guard log reason for pushes stopped by the scan.
+const blockedSecret = "secret detected"
+
+// SecretRule is a regular expression for one kind of credential. When the
+// expression has a capture group, the first group is the secret itself.
+type SecretRule struct {
+	ID    string
+	Regex *regexp.Regexp
+}
+
+// DefaultSecretRules detect the common key and token formats.
+var DefaultSecretRules = []SecretRule{
+	{"private-key", regexp.MustCompile(`-----BEGIN[A-Z ]*PRIVATE KEY( BLOCK)?-----`)},
+	{"aws-access-key-id", regexp.MustCompile(`\b((?:AKIA|ASIA|ABIA|ACCA)[0-9A-Z]{16})\b`)},
+	{"aws-secret-access-key", regexp.MustCompile(`(?i)aws_?secret_?access_?key["']?\s*[:=]\s*["']?([A-Za-z0-9/+=]{40})\b`)},
+	{"github-token", regexp.MustCompile(`\b((?:ghp|gho|ghu|ghs|ghr)_[A-Za-z0-9]{36})\b`)},
+	{"github-fine-grained-token", regexp.MustCompile(`\b(github_pat_[A-Za-z0-9_]{82})\b`)},
+	{"gitlab-token", regexp.MustCompile(`\b(glpat-[A-Za-z0-9_-]{20})\b`)},
+	{"slack-token", regexp.MustCompile(`\b(xox[baprs]-[A-Za-z0-9-]{10,})\b`)},
+	{"slack-webhook", regexp.MustCompile(`(https://hooks\.slack\.com/services/[A-Za-z0-9+/]{40,})`)},
+	{"google-api-key", regexp.MustCompile(`\b(AIza[0-9A-Za-z_-]{35})\b`)},
+	{"stripe-key", regexp.MustCompile(`\b((?:sk|rk)_live_[0-9A-Za-z]{24,})\b`)},
+	{"anthropic-api-key", regexp.MustCompile(`\b(sk-ant-[A-Za-z0-9_-]{32,})\b`)},
+	{"openai-api-key", regexp.MustCompile(`\b(sk-(?:proj-)?[A-Za-z0-9_-]{20}T3BlbkFJ[A-Za-z0-9_-]{20})\b`)},
+	{"npm-token", regexp.MustCompile(`\b(npm_[A-Za-z0-9]{36})\b`)},
+	{"jwt", regexp.MustCompile(`\b(eyJ[A-Za-z0-9_-]{10,}\.eyJ[A-Za-z0-9_-]{10,}\.[A-Za-z0-9_-]{10,})\b`)},
+}
+
+// SetSecretRules replaces the rules pushes are scanned with (nil restores
+// DefaultSecretRules).
+func (e *Executor) SetSecretRules(rules []SecretRule) {
+	e.mu.Lock()
+	defer e.mu.Unlock()
+	e.secretRules = rules
+}
+
+func (e *Executor) secretRuleSet() []SecretRule {
+	if e.secretRules == nil {
+		return DefaultSecretRules
+	}
+	return e.secretRules
+}
+
+// LoadSecretRules returns DefaultSecretRules followed by the rules in file,
+// a gitleaks configuration: [[rules]] tables with id and regex keys. Other
+// keys (allowlists, entropy, keywords) are ignored.
+func LoadSecretRules(file string) ([]SecretRule, error) {
+	rules := append([]SecretRule(nil), DefaultSecretRules...)
+	if file == "" {
+		return rules, nil
+	}
+	data, err := os.ReadFile(file)
+	if err != nil {
+		return nil, fmt.Errorf("secret rules: %w", err)
+	}
+	var id, expr string
+	inRule := false
+	flush := func() error {
+		if !inRule || expr == "" {
+			return nil
+		}
+		re, err := regexp.Compile(expr)
+		if err != nil {
+			return fmt.Errorf("secret rules: rule %q: %w", id, err)
+		}
+		if id == "" {
+			id = fmt.Sprintf("custom-%d", len(rules)-len(DefaultSecretRules)+1)
+		}
+		rules = append(rules, SecretRule{ID: id, Regex: re})
+		return nil
+	}
+	for n, line := range strings.Split(string(data), "\n") {
+		line = strings.TrimSpace(line)
+		if strings.HasPrefix(line, "[") {
+			if err := flush(); err != nil {
+				return nil, err
+			}
+			inRule, id, expr = line == "[[rules]]", "", ""
+			continue
+		}
+		key, value, ok := strings.Cut(line, "=")
+		if !inRule || !ok {
+			continue
+		}
+		switch strings.TrimSpace(key) {
+		case "id":
+			id, err = tomlString(strings.TrimSpace(value))
+		case "regex":
+			expr, err = tomlString(strings.TrimSpace(value))
+		}
+		if err != nil {
+			return nil, fmt.Errorf("secret rules: line %d: %w", n+1, err)
+		}
+	}
+	if err := flush(); err != nil {
+		return nil, err
+	}
+	return rules, nil
+}
+
+// tomlString decodes a single-line TOML string: a multi-line literal
+// written on one line, a literal or a basic string.
+func tomlString(v string) (string, error) {
+	switch {
+	case len(v) >= 6 && strings.HasPrefix(v, "'''") && strings.HasSuffix(v, "'''"):
+		return v[3 : len(v)-3], nil
+	case len(v) >= 2 && v[0] == '\'' && v[len(v)-1] == '\'':
+		return v[1 : len(v)-1], nil
+	case len(v) >= 2 && v[0] == '"':
+		return strconv.Unquote(v)
+	}
+	return "", fmt.Errorf("unsupported value %q", v)
+}
+
+// secretFinding is one match in the pushed commits; Secret is already redacted.
+type secretFinding struct {
+	Rule   string
+	File   string
+	Line   int
+	Secret string
+}
+
+func (f secretFinding) String() string {
+	return fmt.Sprintf("%s in %s:%d (%s)", f.Rule, f.File, f.Line, f.Secret)
+}
+
+// redactSecret keeps the first four characters of a secret.
+func redactSecret(s string) string {
+	if len(s) <= 8 {
+		return "***"
+	}
+	return s[:4] + "***"
+}
+
+// matchSecrets returns the secrets rules find in s.
+func matchSecrets(s string, rules []SecretRule) []struct{ rule, secret string } {
+	var out []struct{ rule, secret string }
+	for _, r := range rules {
+		for _, m := range r.Regex.FindAllStringSubmatch(s, -1) {
+			secret := m[0]
+			if len(m) > 1 && m[1] != "" {
+				secret = m[1]
+			}
+			out = append(out, struct{ rule, secret string }{r.ID, secret})
+		}
+	}
+	return out
+}
+
+// redactSecrets masks everything rules match in s.
+func redactSecrets(s string, rules []SecretRule) string {
+	for _, m := range matchSecrets(s, rules) {
+		s = strings.ReplaceAll(s, m.secret, redactSecret(m.secret))
+	}
+	return s
+}
+
+// scanPatch returns the secrets on the added lines of a unified diff (git
+// log -p --unified=0 output).
+func scanPatch(r io.Reader, rules []SecretRule) []secretFinding {
+	var findings []secretFinding
+	seen := make(map[secretFinding]bool)
+	file, line := "", 0
+	sc := bufio.NewScanner(r)
+	sc.Buffer(make([]byte, 0, 64*1024), 4<<20)
+	for sc.Scan() {
+		text := sc.Text()
+		switch {
+		case strings.HasPrefix(text, "+++ "):
+			file = strings.TrimPrefix(strings.TrimPrefix(text, "+++ "), "b/")
+		case strings.HasPrefix(text, "@@ "):
+			// @@ -a,b +c,d @@
+			if _, after, ok := strings.Cut(text, " +"); ok {
+				start, _, _ := strings.Cut(strings.Fields(after)[0], ",")
+				line, _ = strconv.Atoi(start)
+			}
+		case strings.HasPrefix(text, "+"):
+			for _, m := range matchSecrets(text[1:], rules) {
+				f := secretFinding{Rule: m.rule, File: file, Line: line, Secret: redactSecret(m.secret)}
+				if !seen[f] {
+					seen[f] = true
+					findings = append(findings, f)
+				}
+			}
+			line++
+		}
+	}
+	return findings
+}
+
+// scanCommits returns the secrets added by the commits revs select.
+func scanCommits(workdir string, revs []string, rules []SecretRule) ([]secretFinding, error) {
+	args := append([]string{"-C", workdir, "log", "-p", "--no-color", "--no-ext-diff", "--unified=0", "--format="}, revs...)
+	out, err := gitCapture(args...)
+	if err != nil {
+		return nil, err
+	}
+	return scanPatch(&out, rules), nil
+}
+
+// writeRuleFile stores rules one "id<TAB>regex" per line for RunPushCheck.
+func writeRuleFile(path string, rules []SecretRule) error {
+	var b strings.Builder
+	for _, r := range rules {
+		fmt.Fprintf(&b, "%s\t%s\n", r.ID, r.Regex.String())
+	}
+	return os.WriteFile(path, []byte(b.String()), 0o644)
+}
+
+func readRuleFile(path string) ([]SecretRule, error) {
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return nil, err
+	}
+	var rules []SecretRule
+	for _, line := range strings.Split(string(data), "\n") {
+		id, expr, ok := strings.Cut(line, "\t")
+		if !ok {
+			continue
+		}
+		re, err := regexp.Compile(expr)
+		if err != nil {
+			return nil, fmt.Errorf("rule %q: %w", id, err)
+		}
+		rules = append(rules, SecretRule{ID: id, Regex: re})
+	}
+	if len(rules) == 0 {
+		return nil, errors.New("no rules")
+	}
+	return rules, nil
+}
+
+// reportSecrets prepends the pushes the secret scan blocked to the tracking
+// comment.
+func (e *Executor) reportSecrets(ctx *github.Context, g *gitGuard) {
+	var findings []string
+	for _, attempt := range g.blocked() {
+		if detail, ok := strings.CutPrefix(attempt, blockedSecret+": git push ("); ok {
+			findings = append(findings, "> - "+strings.TrimSuffix(detail, ")"))
+		}
+	}
+	if len(findings) == 0 {
+		return
+	}
+	prependNotice(ctx, "> [!CAUTION]\n> **Push blocked: possible secrets in the changes.** "+
+		"The values are redacted here; remove them from the commits before pushing.\n"+strings.Join(findings, "\n"))
+}
@@ -0,0 +1,126 @@
+package executor
+
+import (
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"github.com/cexll/swe/internal/audit"
+)
+
+// fakeGitHubToken is assembled so this file does not trip secret scanners.
+var fakeGitHubToken = "ghp" + "_" + strings.Repeat("a1B2", 9)
+
+func TestScanPatch(t *testing.T) {
+	patch := `diff --git a/config.go b/config.go
+--- a/config.go
++++ b/config.go
+@@ -3,0 +4,2 @@ package config
++const region = "us-east-1"
++const key = "AKIA` + "ABCDEFGHIJKLMNOP" + `"
+@@ -10 +12 @@ func f() {
+-old := 1
++token := "` + fakeGitHubToken + `"
+`
+	findings := scanPatch(strings.NewReader(patch), DefaultSecretRules)
+	if len(findings) != 2 {
+		t.Fatalf("findings = %+v", findings)
+	}
+	if got := findings[0].String(); got != "aws-access-key-id in config.go:5 (AKIA***)" {
+		t.Fatalf("first finding = %q", got)
+	}
+	if got := findings[1].String(); got != "github-token in config.go:12 (ghp_***)" {
+		t.Fatalf("second finding = %q", got)
+	}
+}
+
+func TestRedactSecrets(t *testing.T) {
+	got := redactSecrets("pushed with "+fakeGitHubToken+" in .env", DefaultSecretRules)
+	if got != "pushed with ghp_*** in .env" {
+		t.Fatalf("redactSecrets = %q", got)
+	}
+}
+
+func TestLoadSecretRules(t *testing.T) {
+	file := filepath.Join(t.TempDir(), "gitleaks.toml")
+	config := `title = "custom"
+
+[[rules]]
+id = "acme-key"
+description = "ACME API key"
+regex = '''acme_[a-z0-9]{16}'''
+keywords = ["acme_"]
+
+[allowlist]
+regex = '''ignored'''
+`
+	if err := os.WriteFile(file, []byte(config), 0o644); err != nil {
+		t.Fatal(err)
+	}
+	rules, err := LoadSecretRules(file)
+	if err != nil {
+		t.Fatalf("LoadSecretRules: %v", err)
+	}
+	if len(rules) != len(DefaultSecretRules)+1 || rules[len(rules)-1].ID != "acme-key" {
+		t.Fatalf("rules = %+v", rules)
+	}
+
+	_ = os.WriteFile(file, []byte("[[rules]]\nid = \"bad\"\nregex = '''(['''\n"), 0o644)
+	if _, err := LoadSecretRules(file); err == nil || !strings.Contains(err.Error(), `rule "bad"`) {
+		t.Fatalf("expected invalid regex error, got %v", err)
+	}
+}
+
+func TestGitGuard_BlocksSecrets(t *testing.T) {
+	workdir, remote := initPushRepo(t)
+	g, err := installGitGuard(DefaultSecretRules, nil)
+	if err != nil {
+		t.Fatalf("installGitGuard: %v", err)
+	}
+	t.Cleanup(g.remove)
+
+	// a secret added and removed again is still in the pushed history
+	gitIn(t, workdir, "checkout", "-q", "-b", "swe-agent/1-1")
+	_ = os.WriteFile(filepath.Join(workdir, ".env"), []byte("GITHUB_TOKEN="+fakeGitHubToken+"\n"), 0o644)
+	gitIn(t, workdir, "add", ".env")
+	gitIn(t, workdir, "commit", "-q", "-m", "add env")
+	gitIn(t, workdir, "rm", "-q", ".env")
+	gitIn(t, workdir, "commit", "-q", "-m", "remove env")
+
+	out, err := guardedGit(t, g, workdir, "git", "push", "origin", "HEAD:refs/heads/swe-agent/1-1")
+	if err == nil || !strings.Contains(out, "possible secrets") || !strings.Contains(out, "github-token in .env:1 (ghp_***)") {
+		t.Fatalf("expected secret rejection, got %v\n%s", err, out)
+	}
+	if strings.Contains(out, fakeGitHubToken) {
+		t.Fatalf("hook output leaks the secret:\n%s", out)
+	}
+	if gitIn(t, remote, "branch", "--list", "swe-agent/*") != "" {
+		t.Fatal("branch with a secret reached the remote")
+	}
+
+	updated := stubComments(t, "Working on it.")
+	log, _ := audit.New(audit.Config{})
+	e := New(&mockProvider{}, &mockAuthProvider{})
+	e.SetAuditLog(log)
+	ctx := buildTestCtx(false)
+	ctx.Token = "installation-token"
+	ctx.PreparedCommentID = 7
+	e.recordBlockedGit(ctx, g)
+	e.reportSecrets(ctx, g)
+	events := log.List(audit.Filter{Action: audit.ActionGitBlocked})
+	if len(events) != 1 || events[0].Detail != "secret detected: git push (github-token in .env:1 (ghp_***))" {
+		t.Fatalf("audit events = %+v", events)
+	}
+	if !strings.HasPrefix(*updated, "> [!CAUTION]\n> **Push blocked: possible secrets in the changes.**") ||
+		!strings.Contains(*updated, "> - github-token in .env:1 (ghp_***)") {
+		t.Fatalf("unexpected comment:\n%s", *updated)
+	}
+
+	// once the secret is gone from the history the push goes through
+	gitIn(t, workdir, "reset", "-q", "--hard", "origin/main")
+	gitIn(t, workdir, "commit", "-q", "--allow-empty", "-m", "clean")
+	if out, err := guardedGit(t, g, workdir, "git", "push", "-q", "origin", "HEAD:refs/heads/swe-agent/1-1"); err != nil {
+		t.Fatalf("clean push: %v\n%s", err, out)
+	}
+}
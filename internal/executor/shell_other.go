@@ -0,0 +1,20 @@
+//go:build !windows
+
+package executor
+
+import (
+	"context"
+	"os/exec"
+)
+
+// shellCommand runs command with /bin/sh.
+func shellCommand(ctx context.Context, command string) *exec.Cmd {
+	return exec.CommandContext(ctx, "sh", "-c", command)
+}
+
+// gitWrapperShims returns the files, by path, that make programs find the
+// git wrapper script at wrapper; on POSIX systems PATH finds the script
+// itself.
+func gitWrapperShims(wrapper string) map[string]string {
+	return nil
+}
@@ -0,0 +1,19 @@
+package executor
+
+import (
+	"context"
+	"strings"
+	"testing"
+)
+
+func TestShellCommand(t *testing.T) {
+	cmd := shellCommand(context.Background(), "echo one && echo two")
+	cmd.Dir = t.TempDir()
+	out, err := cmd.CombinedOutput()
+	if err != nil {
+		t.Fatalf("run: %v\n%s", err, out)
+	}
+	if got := strings.Fields(string(out)); strings.Join(got, " ") != "one two" {
+		t.Fatalf("output = %q", out)
+	}
+}
@@ -0,0 +1,58 @@
+package executor
+
+import (
+	"context"
+	"fmt"
+	"os"
+	"os/exec"
+	"path/filepath"
+	"syscall"
+)
+
+// shellCommand runs command with the sh of Git for Windows when there is
+// one, so that commands written for /bin/sh keep working, and with cmd.exe
+// otherwise. cmd.exe gets the command line as written: Go's quoting of
+// arguments would escape the quotes in it.
+func shellCommand(ctx context.Context, command string) *exec.Cmd {
+	if sh := gitShell(); sh != "" {
+		return exec.CommandContext(ctx, sh, "-c", command)
+	}
+	cmd := exec.CommandContext(ctx, "cmd.exe")
+	cmd.SysProcAttr = &syscall.SysProcAttr{CmdLine: `cmd.exe /d /s /c "` + command + `"`}
+	return cmd
+}
+
+// gitWrapperShims returns a git.cmd beside the git wrapper script at
+// wrapper: programs looking git up through PATHEXT skip the script, which
+// has no extension, and run the shim, which hands the arguments to the
+// script in the sh of Git for Windows. Without that sh there is no shim, and
+// only the pre-push hook guards pushes.
+func gitWrapperShims(wrapper string) map[string]string {
+	sh := gitShell()
+	if sh == "" {
+		return nil
+	}
+	return map[string]string{wrapper + ".cmd": fmt.Sprintf("@\"%s\" \"%s\" %%*\r\n", sh, wrapper)}
+}
+
+// gitShell returns the sh of Git for Windows: on PATH, or at bin\sh.exe of
+// the installation git.exe comes from, or "".
+func gitShell() string {
+	if sh, err := exec.LookPath("sh"); err == nil {
+		return sh
+	}
+	git, err := exec.LookPath("git")
+	if err != nil {
+		return ""
+	}
+	// <root>\cmd\git.exe or <root>\mingw64\bin\git.exe
+	for root := filepath.Dir(filepath.Dir(git)); ; root = filepath.Dir(root) {
+		sh := filepath.Join(root, "bin", "sh.exe")
+		if _, err := os.Stat(sh); err == nil {
+			return sh
+		}
+		if parent := filepath.Dir(root); parent == root {
+			return ""
+		}
+	}
+}
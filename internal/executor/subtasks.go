@@ -0,0 +1,429 @@
+package executor
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"os"
+	"os/exec"
+	"path/filepath"
+	"strconv"
+	"strings"
+	"sync"
+
+	"github.com/cexll/swe/internal/audit"
+	"github.com/cexll/swe/internal/github"
+	"github.com/cexll/swe/internal/provider"
+	"github.com/cexll/swe/internal/provider/mcpconfig"
+	"github.com/cexll/swe/internal/taskstore"
+)
+
+// DefaultMaxSubtasks bounds the sub-tasks of one task when SubtaskConfig.Max
+// is not set.
+const DefaultMaxSubtasks = 6
+
+// subtasksFile is where the provider lists the sub-tasks it splits off,
+// inside the checkout's .git directory so that it is never committed.
+const subtasksFile = "swe-agent-subtasks.json"
+
+// SubtaskConfig lets the provider split a large task into sub-tasks that
+// run as parallel provider invocations, each in its own worktree of the
+// task's checkout.
+type SubtaskConfig struct {
+	Parallel int // sub-tasks running at once; 0 disables sub-tasks
+	Max      int // sub-tasks per task; 0 uses DefaultMaxSubtasks
+}
+
+// SetSubtasks configures the sub-tasks of subsequent tasks.
+func (e *Executor) SetSubtasks(c SubtaskConfig) {
+	e.mu.Lock()
+	defer e.mu.Unlock()
+	e.subtasks = c
+}
+
+func (c SubtaskConfig) max() int {
+	if c.Max > 0 {
+		return c.Max
+	}
+	return DefaultMaxSubtasks
+}
+
+// subtask is one part of a task the provider handed off.
+type subtask struct {
+	Title  string `json:"title"`
+	Prompt string `json:"prompt"`
+}
+
+// subtasksPromptSection tells the provider how to hand off independent
+// parts of a large task, writing them to file.
+func subtasksPromptSection(c SubtaskConfig, file string) string {
+	return fmt.Sprintf(`<subtasks>
+This task may be large enough to split. If it has independent parts that touch separate files (for example "update the tests in pkg/a" and "update the docs"), you may hand up to %d of them off to agents that run in parallel once you finish. To do so, write them to %s as:
+
+{"subtasks": [{"title": "Update the tests in pkg/a", "prompt": "..."}]}
+
+Each prompt must stand on its own: the agent that runs it sees only the repository and that prompt, not this conversation. Do the remaining work yourself and commit it before you finish, as usual. Each sub-task starts from your last commit in its own worktree; its commits are merged into your branch and pushed after you finish, and the outcome is added to the tracking comment. Do not split work that is small, or whose parts change the same files.
+</subtasks>`, c.max(), file)
+}
+
+// subtasksPath is the sub-tasks file of the checkout at workdir as the
+// prompt names it: relative to the checkout unless it is outside, as for a
+// worktree of a shared mirror.
+func subtasksPath(workdir string) string {
+	path, err := gitPath(workdir, subtasksFile)
+	if err != nil {
+		return ".git/" + subtasksFile
+	}
+	if rel, err := filepath.Rel(workdir, path); err == nil && !strings.HasPrefix(rel, "..") {
+		return filepath.ToSlash(rel)
+	}
+	return path
+}
+
+// readSubtasks reads and removes the sub-tasks the provider listed. Entries
+// without a prompt are dropped, and only the first max are kept.
+func readSubtasks(workdir string, max int) ([]subtask, error) {
+	path, err := gitPath(workdir, subtasksFile)
+	if err != nil {
+		return nil, err
+	}
+	data, err := os.ReadFile(path)
+	if errors.Is(err, os.ErrNotExist) {
+		return nil, nil
+	}
+	if err != nil {
+		return nil, err
+	}
+	_ = os.Remove(path)
+	var doc struct {
+		Subtasks []subtask `json:"subtasks"`
+	}
+	if err := json.Unmarshal(data, &doc); err != nil {
+		return nil, fmt.Errorf("%s: %w", subtasksFile, err)
+	}
+	var tasks []subtask
+	for _, t := range doc.Subtasks {
+		t.Title, t.Prompt = strings.TrimSpace(t.Title), strings.TrimSpace(t.Prompt)
+		if t.Prompt == "" {
+			continue
+		}
+		if t.Title == "" {
+			t.Title, _, _ = strings.Cut(t.Prompt, "\n")
+		}
+		if len(tasks) == max {
+			phaseLog("subtasks").Warn("Dropping sub-task over the limit", "title", t.Title, "max", max)
+			continue
+		}
+		tasks = append(tasks, t)
+	}
+	return tasks, nil
+}
+
+// subtaskResult is how one sub-task went.
+type subtaskResult struct {
+	subtask
+	branch   string
+	dir      string
+	commits  int
+	cost     float64
+	err      error
+	resolved []string // files whose merge conflicts the provider resolved
+	blocked  *gitGuard
+}
+
+// subtaskPrompt is the prompt of one sub-task's provider run.
+func subtaskPrompt(repo string, t subtask) string {
+	return fmt.Sprintf(`You are one of several agents working in parallel on %s, each in its own worktree. Your part:
+
+<subtask title=%q>
+%s
+</subtask>
+
+Change only what your part needs; the other parts are being handled elsewhere. Commit your changes with git commit when you are done. Do not push, open pull requests or comment on GitHub: your commits are merged and pushed for you.`, repo, t.Title, t.Prompt)
+}
+
+// runSubtasks runs the sub-tasks the provider listed, in parallel worktrees
+// branching from workdir's HEAD, merges their commits into workdir with the
+// provider resolving conflicts, and pushes branch. It returns the cost of
+// the runs. A failing sub-task is reported, not fatal; only a failed push is.
+func (e *Executor) runSubtasks(ctx context.Context, ghCtx *github.Context, workdir, branch string, parent *provider.CodeRequest) (float64, error) {
+	tasks, err := readSubtasks(workdir, e.subtasks.max())
+	if err != nil {
+		taskLog(ghCtx, "subtasks").Warn("Sub-tasks ignored", "err", err)
+		e.logTask(ghCtx, "warn", fmt.Sprintf("Sub-tasks ignored: %v", err))
+		return 0, nil
+	}
+	if len(tasks) == 0 {
+		return 0, nil
+	}
+	e.phase(ghCtx, taskstore.PhaseSubtasks)
+	repo := ghCtx.GetRepositoryFullName()
+	head, err := gitOutput(workdir, "rev-parse", "HEAD")
+	if err != nil {
+		return 0, fmt.Errorf("sub-tasks: %w", err)
+	}
+	head = strings.TrimSpace(head)
+
+	// checkouts that are worktrees of one mirror share its branches
+	results := make([]*subtaskResult, len(tasks))
+	for i, t := range tasks {
+		results[i] = &subtaskResult{subtask: t, branch: fmt.Sprintf("swe-agent-subtask/%s-%d", filepath.Base(workdir), i+1)}
+		if dir, err := os.MkdirTemp("", "swe-subtask-"); err != nil {
+			results[i].err = err
+		} else if err := runCmd("git", "-C", workdir, "worktree", "add", "-q", "-b", results[i].branch, dir, head); err != nil {
+			_ = os.RemoveAll(dir)
+			results[i].err = fmt.Errorf("create worktree: %w", err)
+		} else {
+			results[i].dir = dir
+		}
+	}
+	defer func() {
+		for _, res := range results {
+			if res.dir != "" {
+				_ = runCmd("git", "-C", workdir, "worktree", "remove", "--force", res.dir)
+				_ = os.RemoveAll(res.dir)
+			}
+			_ = runCmd("git", "-C", workdir, "branch", "-D", res.branch)
+		}
+	}()
+
+	sem := make(chan struct{}, e.subtasks.Parallel)
+	var wg sync.WaitGroup
+	for _, res := range results {
+		if res.err != nil {
+			continue
+		}
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			sem <- struct{}{}
+			defer func() { <-sem }()
+			e.runSubtask(ctx, ghCtx, head, parent, res)
+		}()
+	}
+	wg.Wait()
+
+	var cost float64
+	merged := 0
+	for _, res := range results {
+		cost += res.cost
+		if res.blocked != nil {
+			e.recordBlockedGit(ghCtx, res.blocked)
+			res.blocked.remove()
+		}
+		if res.err != nil || res.commits == 0 {
+			continue
+		}
+		c, err := e.mergeSubtask(ctx, workdir, repo, res)
+		cost += c
+		if err != nil {
+			res.err = err
+			continue
+		}
+		merged++
+	}
+	for _, res := range results {
+		e.logTask(ghCtx, subtaskLevel(res), fmt.Sprintf("Sub-task %q: %s", res.Title, subtaskOutcome(res)))
+	}
+
+	if merged > 0 {
+		e.phase(ghCtx, taskstore.PhasePush)
+		guard, err := installGitGuard(e.secretRuleSet(), e.blockedPaths)
+		if err != nil {
+			return cost, err
+		}
+		defer guard.remove()
+		cmd := exec.CommandContext(ctx, "git", "-C", workdir, "push", "-q", "origin", "HEAD:refs/heads/"+branch)
+		cmd.Env = append(os.Environ(), guard.env()...)
+		out, err := cmd.CombinedOutput()
+		e.recordBlockedGit(ghCtx, guard)
+		e.reportSecrets(ghCtx, guard)
+		e.reportBlockedPaths(ghCtx, guard)
+		if err != nil {
+			msg := strings.TrimSpace(string(out))
+			if ghCtx.Token != "" {
+				msg = strings.ReplaceAll(msg, ghCtx.Token, "***")
+			}
+			return cost, errors.New("push sub-task changes: " + msg)
+		}
+	}
+	ev := e.auditEvent(ghCtx, audit.ActionSubtasksMerged)
+	ev.Branch = branch
+	ev.Detail = fmt.Sprintf("%d of %d sub-tasks merged", merged, len(results))
+	e.recordAudit(ev)
+	prependNotice(ghCtx, subtasksNotice(branch, results))
+	return cost, nil
+}
+
+// runSubtask runs one sub-task's provider in its worktree and commits
+// whatever it left uncommitted.
+func (e *Executor) runSubtask(ctx context.Context, ghCtx *github.Context, head string, parent *provider.CodeRequest, res *subtaskResult) {
+	dir := res.dir
+	req := *parent
+	req.RepoPath = dir
+	req.Prompt = subtaskPrompt(ghCtx.GetRepositoryFullName(), res.subtask)
+	req.Progress, req.Events, req.Transcript = nil, nil, nil
+	// the tracking comment and review threads belong to the parent run
+	req.MCPServers = make(map[string]bool, len(parent.MCPServers)+2)
+	for name, on := range parent.MCPServers {
+		req.MCPServers[name] = on
+	}
+	req.MCPServers[mcpconfig.CommentServer] = false
+	req.MCPServers[mcpconfig.ReviewServer] = false
+	guard, err := installGitGuard(e.secretRuleSet(), e.blockedPaths)
+	if err != nil {
+		res.err = err
+		return
+	}
+	res.blocked = guard
+	if err := guard.holdPushes("sub-task: merged and pushed by the agent"); err != nil {
+		res.err = err
+		return
+	}
+	req.Env = append(guard.env(), cacheEnvFrom(ctx)...)
+
+	resp, err := e.provider.GenerateCode(ctx, &req)
+	if resp != nil {
+		res.cost = resp.CostUSD
+	}
+	if err != nil {
+		res.err = &ProviderError{Provider: e.provider.Name(), Err: err}
+		return
+	}
+	if status, _ := gitOutput(dir, "status", "--porcelain"); strings.TrimSpace(status) != "" {
+		if err := runCmd("git", "-C", dir, "add", "-A"); err == nil {
+			_ = runCmd("git", "-C", dir, "commit", "-q", "-m", res.Title)
+		}
+	}
+	out, err := gitOutput(dir, "rev-list", "--count", head+"..HEAD")
+	if err != nil {
+		res.err = fmt.Errorf("count commits: %w", err)
+		return
+	}
+	res.commits, _ = strconv.Atoi(strings.TrimSpace(out))
+}
+
+// mergeSubtask merges a sub-task's branch into workdir, having the provider
+// resolve conflicts. Conflicts it leaves abort the merge.
+func (e *Executor) mergeSubtask(ctx context.Context, workdir, repo string, res *subtaskResult) (float64, error) {
+	message := "Merge sub-task: " + res.Title
+	mergeErr := runCmd("git", "-C", workdir, "merge", "--no-ff", "-q", "-m", message, res.branch)
+	if mergeErr == nil {
+		return 0, nil
+	}
+	files, err := conflictedFiles(workdir)
+	if err != nil || len(files) == 0 {
+		_ = runCmd("git", "-C", workdir, "merge", "--abort")
+		return 0, fmt.Errorf("merge: %w", mergeErr)
+	}
+	cost, err := e.resolveSubtaskConflicts(ctx, workdir, repo, res, files)
+	if err != nil {
+		_ = runCmd("git", "-C", workdir, "merge", "--abort")
+		return cost, err
+	}
+	if left := filesWithConflictMarkers(workdir, files); len(left) > 0 {
+		_ = runCmd("git", "-C", workdir, "merge", "--abort")
+		return cost, fmt.Errorf("conflicts left in %s", strings.Join(left, ", "))
+	}
+	if err := runCmd("git", append([]string{"-C", workdir, "add", "-A", "--"}, files...)...); err != nil {
+		_ = runCmd("git", "-C", workdir, "merge", "--abort")
+		return cost, fmt.Errorf("stage resolved files: %w", err)
+	}
+	if err := runCmd("git", "-C", workdir, "commit", "-q", "-m", message); err != nil {
+		_ = runCmd("git", "-C", workdir, "merge", "--abort")
+		return cost, fmt.Errorf("commit merge: %w", err)
+	}
+	res.resolved = files
+	return cost, nil
+}
+
+// subtaskResolvePrompt asks the provider to resolve the conflicts merging
+// a sub-task left in files.
+func subtaskResolvePrompt(repo string, t subtask, files []string) string {
+	return fmt.Sprintf(`Several agents worked on %s in parallel. Merging the work of the one that did %q conflicted in:
+
+- %s
+
+Resolve the conflicts in these files only, keeping the changes of both sides. Remove every conflict marker.
+If a conflict needs real design decisions, leave its markers in place and say why.
+Do not stage, commit, push or run git merge.`, repo, t.Title, strings.Join(files, "\n- "))
+}
+
+// resolveSubtaskConflicts has the provider edit the conflicted files; it
+// may not touch git.
+func (e *Executor) resolveSubtaskConflicts(ctx context.Context, workdir, repo string, res *subtaskResult, files []string) (float64, error) {
+	var env []string
+	if guard, err := installGitGuard(e.secretRuleSet(), e.blockedPaths); err == nil {
+		defer guard.remove()
+		env = guard.env()
+	}
+	resp, err := e.provider.GenerateCode(ctx, &provider.CodeRequest{
+		Prompt:          subtaskResolvePrompt(repo, res.subtask, files),
+		RepoPath:        workdir,
+		Context:         map[string]string{"repository": repo},
+		AllowedTools:    []string{"Read", "Edit", "Grep", "Glob", "Bash(git diff)", "Bash(git show)", "Bash(git log)"},
+		DisallowedTools: []string{"Bash(git add)", "Bash(git commit)", "Bash(git push)", "Bash(git merge)", "Bash(git checkout)", "Bash(git reset)"},
+		Env:             env,
+	})
+	if err != nil {
+		return 0, fmt.Errorf("resolve conflicts: %w", err)
+	}
+	if resp == nil {
+		return 0, nil
+	}
+	return resp.CostUSD, nil
+}
+
+func subtaskOutcome(res *subtaskResult) string {
+	switch {
+	case res.err != nil:
+		msg, _, _ := strings.Cut(res.err.Error(), "\n")
+		return "failed: " + msg
+	case res.commits == 0:
+		return "no changes"
+	case len(res.resolved) > 0:
+		return fmt.Sprintf("%d commit(s) merged; conflicts resolved in `%s`", res.commits, strings.Join(res.resolved, "`, `"))
+	default:
+		return fmt.Sprintf("%d commit(s) merged", res.commits)
+	}
+}
+
+func subtaskLevel(res *subtaskResult) string {
+	if res.err != nil {
+		return "warn"
+	}
+	return "info"
+}
+
+// subtasksNotice is the tracking comment notice listing the sub-tasks.
+func subtasksNotice(branch string, results []*subtaskResult) string {
+	var b strings.Builder
+	kind := "NOTE"
+	for _, res := range results {
+		if res.err != nil || len(res.resolved) > 0 {
+			kind = "WARNING"
+		}
+	}
+	fmt.Fprintf(&b, "> [!%s]\n> **Sub-tasks** run in parallel and merged into `%s`:\n>\n", kind, branch)
+	for _, res := range results {
+		mark := "✅"
+		switch {
+		case res.err != nil:
+			mark = "❌"
+		case res.commits == 0:
+			mark = "➖"
+		case len(res.resolved) > 0:
+			mark = "⚠️"
+		}
+		fmt.Fprintf(&b, "> - %s %s: %s\n", mark, github.SanitizeContent(res.Title), subtaskOutcome(res))
+	}
+	return strings.TrimRight(b.String(), "\n")
+}
+
+// logTask adds a message to the task's log in the task store.
+func (e *Executor) logTask(ghCtx *github.Context, level, msg string) {
+	if e.store != nil && ghCtx.TaskID != "" {
+		e.store.AddLog(ghCtx.TaskID, level, msg)
+	}
+}
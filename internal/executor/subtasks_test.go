@@ -0,0 +1,171 @@
+package executor
+
+import (
+	"context"
+	"errors"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"github.com/cexll/swe/internal/audit"
+	"github.com/cexll/swe/internal/provider"
+)
+
+func writeSubtasks(t *testing.T, workdir, doc string) {
+	t.Helper()
+	if err := os.WriteFile(filepath.Join(workdir, ".git", subtasksFile), []byte(doc), 0o644); err != nil {
+		t.Fatal(err)
+	}
+}
+
+func TestReadSubtasks(t *testing.T) {
+	workdir := initLocalRepo(t)
+	if tasks, err := readSubtasks(workdir, 2); err != nil || tasks != nil {
+		t.Fatalf("no file: %v, %v", tasks, err)
+	}
+
+	writeSubtasks(t, workdir, `{"subtasks": [
+  {"title": "Docs", "prompt": "Update the docs."},
+  {"prompt": " "},
+  {"prompt": "Update the tests in pkg/a.\nKeep them table-driven."},
+  {"title": "Extra", "prompt": "One too many."}
+]}`)
+	tasks, err := readSubtasks(workdir, 2)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(tasks) != 2 || tasks[0].Title != "Docs" || tasks[1].Title != "Update the tests in pkg/a." {
+		t.Fatalf("tasks = %+v", tasks)
+	}
+	if _, err := os.Stat(filepath.Join(workdir, ".git", subtasksFile)); !os.IsNotExist(err) {
+		t.Fatalf("sub-tasks file left behind: %v", err)
+	}
+
+	writeSubtasks(t, workdir, `[not json`)
+	if _, err := readSubtasks(workdir, 2); err == nil || !strings.Contains(err.Error(), subtasksFile) {
+		t.Fatalf("malformed file: %v", err)
+	}
+}
+
+func TestRunSubtasks_MergesAndPushes(t *testing.T) {
+	workdir, remote := initPushRepo(t)
+	const branch = "swe-agent/1-1"
+	gitIn(t, workdir, "checkout", "-q", "-b", branch)
+	commitAndPush(t, workdir, branch, "primary\n")
+	writeSubtasks(t, workdir, `{"subtasks": [
+  {"title": "Docs", "prompt": "Write docs.md."},
+  {"title": "Intro", "prompt": "Rewrite the README intro."},
+  {"title": "Outro", "prompt": "Rewrite the README outro."},
+  {"title": "Broken", "prompt": "Fail."}
+]}`)
+	updated := stubComments(t, "Implemented the feature.")
+
+	write := func(dir, name, content string) {
+		if err := os.WriteFile(filepath.Join(dir, name), []byte(content), 0o644); err != nil {
+			t.Error(err)
+		}
+	}
+	e := New(&mockProvider{name: "mock", generateFunc: func(_ context.Context, req *provider.CodeRequest) (*provider.CodeResponse, error) {
+		switch {
+		case strings.Contains(req.Prompt, "Merging the work"):
+			if req.RepoPath != workdir || !strings.Contains(req.Prompt, "- README.md") {
+				t.Errorf("resolve request in %s:\n%s", req.RepoPath, req.Prompt)
+			}
+			write(req.RepoPath, "README.md", "intro\noutro\n")
+		case req.RepoPath == workdir:
+			t.Errorf("sub-task ran in the task's checkout")
+		case strings.Contains(req.Prompt, "Write docs.md."):
+			write(req.RepoPath, "docs.md", "docs\n")
+		case strings.Contains(req.Prompt, "README intro"):
+			write(req.RepoPath, "README.md", "intro\n")
+		case strings.Contains(req.Prompt, "README outro"):
+			write(req.RepoPath, "README.md", "outro\n")
+		default:
+			return nil, errors.New("provider crashed")
+		}
+		if req.MCPServers["comment_updater"] {
+			t.Errorf("sub-task may update the tracking comment")
+		}
+		return &provider.CodeResponse{CostUSD: 0.5}, nil
+	}}, &mockAuthProvider{})
+	log, _ := audit.New(audit.Config{})
+	e.SetAuditLog(log)
+	e.SetSubtasks(SubtaskConfig{Parallel: 2})
+
+	ghCtx := buildTestCtx(false)
+	ghCtx.Token, ghCtx.PreparedCommentID = "tok", 5
+	cost, err := e.runSubtasks(context.Background(), ghCtx, workdir, branch, &provider.CodeRequest{RepoPath: workdir})
+	if err != nil {
+		t.Fatalf("runSubtasks: %v", err)
+	}
+	if cost != 2 {
+		t.Fatalf("cost = %v, want 2 (three sub-tasks and a resolution)", cost)
+	}
+
+	if got := gitIn(t, remote, "show", branch+":README.md"); got != "intro\noutro" {
+		t.Fatalf("pushed README.md = %q", got)
+	}
+	if got := gitIn(t, remote, "show", branch+":docs.md"); got != "docs" {
+		t.Fatalf("pushed docs.md = %q", got)
+	}
+	if merges := gitIn(t, remote, "log", "--merges", "--format=%s", branch); merges != "Merge sub-task: Outro\nMerge sub-task: Intro\nMerge sub-task: Docs" {
+		t.Fatalf("merges = %q", merges)
+	}
+	if out := gitIn(t, workdir, "worktree", "list"); strings.Count(out, "\n") != 0 {
+		t.Fatalf("worktrees left behind:\n%s", out)
+	}
+	for _, want := range []string{
+		"> [!WARNING]",
+		"✅ Docs: 1 commit(s) merged",
+		"⚠️ Outro: 1 commit(s) merged; conflicts resolved in `README.md`",
+		"❌ Broken: failed: provider mock: provider crashed",
+		"Implemented the feature.",
+	} {
+		if !strings.Contains(*updated, want) {
+			t.Errorf("tracking comment lacks %q:\n%s", want, *updated)
+		}
+	}
+	if events := log.List(audit.Filter{Action: audit.ActionSubtasksMerged}); len(events) != 1 || events[0].Detail != "3 of 4 sub-tasks merged" {
+		t.Fatalf("audit events = %+v", events)
+	}
+}
+
+func TestRunSubtasks_UnresolvedConflictIsNotMerged(t *testing.T) {
+	workdir, remote := initPushRepo(t)
+	const branch = "swe-agent/1-1"
+	gitIn(t, workdir, "checkout", "-q", "-b", branch)
+	before := commitAndPush(t, workdir, branch, "primary\n")
+	writeSubtasks(t, workdir, `{"subtasks": [{"title": "A", "prompt": "a"}, {"title": "B", "prompt": "b"}]}`)
+	updated := stubComments(t, "")
+
+	e := New(&mockProvider{name: "mock", generateFunc: func(_ context.Context, req *provider.CodeRequest) (*provider.CodeResponse, error) {
+		if strings.Contains(req.Prompt, "Merging the work") {
+			return &provider.CodeResponse{}, nil // leaves the markers
+		}
+		content := "a\n"
+		if strings.Contains(req.Prompt, `title="B"`) {
+			content = "b\n"
+		}
+		return &provider.CodeResponse{}, os.WriteFile(filepath.Join(req.RepoPath, "README.md"), []byte(content), 0o644)
+	}}, &mockAuthProvider{})
+	e.SetSubtasks(SubtaskConfig{Parallel: 1})
+
+	ghCtx := buildTestCtx(false)
+	ghCtx.Token, ghCtx.PreparedCommentID = "tok", 5
+	if _, err := e.runSubtasks(context.Background(), ghCtx, workdir, branch, &provider.CodeRequest{}); err != nil {
+		t.Fatalf("runSubtasks: %v", err)
+	}
+	if got := gitIn(t, remote, "show", branch+":README.md"); got != "a" {
+		t.Fatalf("pushed README.md = %q", got)
+	}
+	if gitIn(t, remote, "rev-parse", branch) == before {
+		t.Fatal("merged sub-task not pushed")
+	}
+	if !strings.Contains(*updated, "❌ B: failed: conflicts left in README.md") {
+		t.Fatalf("tracking comment:\n%s", *updated)
+	}
+	if status := gitIn(t, workdir, "status", "--porcelain"); status != "" {
+		t.Fatalf("merge not aborted:\n%s", status)
+	}
+}
@@ -3,18 +3,33 @@ package executor
 
 import (
 	"context"
+	"errors"
 	"fmt"
+	"log/slog"
+	"maps"
 	"os"
 	"os/exec"
 	"strconv"
 	"strings"
+	"sync"
 	"time"
 
+	"github.com/cexll/swe/internal/artifacts"
+	"github.com/cexll/swe/internal/audit"
+	"github.com/cexll/swe/internal/budget"
 	"github.com/cexll/swe/internal/github"
+	"github.com/cexll/swe/internal/github/comment"
 	ghdata "github.com/cexll/swe/internal/github/data"
 	operations "github.com/cexll/swe/internal/github/operations/git"
+	"github.com/cexll/swe/internal/knowledge"
+	"github.com/cexll/swe/internal/logging"
+	"github.com/cexll/swe/internal/notify"
+	"github.com/cexll/swe/internal/policy"
 	"github.com/cexll/swe/internal/prompt"
 	"github.com/cexll/swe/internal/provider"
+	"github.com/cexll/swe/internal/provider/mcpconfig"
+	"github.com/cexll/swe/internal/reposettings"
+	"github.com/cexll/swe/internal/taskstore"
 	"github.com/cexll/swe/internal/toolconfig"
 )
 
@@ -23,13 +38,81 @@ type fetcherIface interface {
 }
 
 type Executor struct {
+	mu       sync.RWMutex // guards provider, wiki and release against config reloads
 	provider provider.Provider
 	auth     github.AuthProvider
 	fetcher  fetcherIface
+	reviews  reviewThreadsIface
+	comments commentsIface
+	audit    *audit.Log
+	notifier *notify.Manager
+	store    *taskstore.Store
+	checks   []PostPushCheck
+	wiki     bool
+	release  ReleaseConfig
+	// secretRules are what pushes are scanned for (nil uses the defaults)
+	secretRules []SecretRule
+	// blockedPaths may not be changed by pushes (none allows every path)
+	blockedPaths []string
+	// policy's push rules decide whether a push goes through (nil has none)
+	policy *policy.Policy
+	// artifactDir keeps artifacts of failed verification runs ("" keeps none)
+	artifactDir string
+	// heartbeat is how often a running task's tracking comment shows its
+	// progress (0 disables)
+	heartbeat time.Duration
+	// debugLevel is the level tasks run with --debug log from
+	debugLevel slog.Level
+	// queued writes queue positions to tracking comments
+	queued *queueNotices
+	// repoSettings adds per-repository tools and instructions (nil adds none)
+	repoSettings *reposettings.Set
+	// artifacts keeps transcripts, diffs and test output (nil keeps none)
+	artifacts *artifacts.Store
+	// logs keeps the full text of long task log messages (nil keeps none)
+	logs *artifacts.Store
+	// timeouts bound how long a task may run
+	timeouts TaskTimeouts
+	// commitStatus reports tasks as commit statuses (disabled when zero)
+	commitStatus CommitStatusConfig
+	// knowledge remembers finished tasks; knowledgeEntries of them that
+	// relate to a new task go into its prompt (nil remembers nothing)
+	knowledge        *knowledge.Store
+	knowledgeEntries int
+	// promptDir holds the operator's prompt template overrides ("" has none)
+	promptDir string
+	// commentTemplate is the file of tracking comment layout templates (""
+	// uses the built-in layout)
+	commentTemplate string
+	// minimizeOutdated hides the earlier tracking comments on an issue or
+	// pull request when a task starts there
+	minimizeOutdated bool
+	// contextTokens bounds the GitHub context of a prompt (0 keeps it whole)
+	contextTokens int
+	// repoFileListMax bounds the repository file list of a prompt (0 lists
+	// no files)
+	repoFileListMax int
+	// prDiffMaxLines is the largest pull request, in changed lines, whose
+	// diff goes into the prompt (0 embeds none)
+	prDiffMaxLines int
+	// bootstrap sets up the toolchains of a checkout before the provider
+	bootstrap BootstrapConfig
+	// subtasks lets the provider hand parts of a task to parallel runs
+	subtasks SubtaskConfig
+	// dryRuns keeps the workspaces of dry runs until they are applied
+	dryRuns *dryRunWorkspaces
+	// caches keeps each repository's dependency caches between tasks
+	caches *repoCaches
+	// workspaces tracks the checkouts of tasks until they are removed
+	workspaces *workspaces
+	// budgets adds up what each organization spends (nil tracks nothing)
+	budgets *budget.Tracker
+	// costEstimate prices tasks before the provider runs
+	costEstimate CostEstimate
 }
 
 // allow tests to stub cloning and command execution
-var cloneRepo = github.Clone
+var cloneRepo = github.CloneContext
 var runCmd = run
 var gitLsRemoteHeads = defaultLsRemoteHeads
 
@@ -39,14 +122,123 @@ func New(p provider.Provider, auth github.AuthProvider) *Executor {
 		provider: p,
 		auth:     auth,
 		fetcher:  ghdata.NewFetcher(client),
+		reviews:  client,
+		comments: client,
+
+		heartbeat:  DefaultHeartbeatInterval,
+		debugLevel: slog.LevelDebug,
+		queued:     newQueueNotices(),
+		dryRuns:    newDryRunWorkspaces(),
+		caches:     newRepoCaches(),
+		workspaces: newWorkspaces(),
+
+		costEstimate: CostEstimate{PerMTokUSD: DefaultCostPerMTokUSD},
 	}
 }
 
+// SetProvider replaces the provider used by subsequent tasks. Tasks already
+// running keep the provider they started with.
+func (e *Executor) SetProvider(p provider.Provider) {
+	e.mu.Lock()
+	defer e.mu.Unlock()
+	e.provider = p
+}
+
+// SetRepoSettings sets the per-repository tools and instructions used by
+// subsequent tasks (nil removes them).
+func (e *Executor) SetRepoSettings(s *reposettings.Set) {
+	e.mu.Lock()
+	defer e.mu.Unlock()
+	e.repoSettings = s
+}
+
+// Execute runs one task, pinned to the settings current when it starts.
 func (e *Executor) Execute(ctx context.Context, webhookCtx *github.Context) error {
+	e.mu.RLock()
+	run := &Executor{
+		provider: e.provider,
+		auth:     e.auth,
+		fetcher:  e.fetcher,
+		reviews:  e.reviews,
+		comments: e.comments,
+		audit:    e.audit,
+		notifier: e.notifier,
+		store:    e.store,
+		checks:   e.checks,
+		wiki:     e.wiki,
+		release:  e.release,
+
+		secretRules:  e.secretRules,
+		blockedPaths: e.blockedPaths,
+		policy:       e.policy,
+		artifactDir:  e.artifactDir,
+		heartbeat:    e.heartbeat,
+		debugLevel:   e.debugLevel,
+		repoSettings: e.repoSettings,
+		artifacts:    e.artifacts,
+		logs:         e.logs,
+		timeouts:     e.timeouts,
+		commitStatus: e.commitStatus,
+
+		knowledge:        e.knowledge,
+		knowledgeEntries: e.knowledgeEntries,
+		promptDir:        e.promptDir,
+		commentTemplate:  e.commentTemplate,
+		minimizeOutdated: e.minimizeOutdated,
+		contextTokens:    e.contextTokens,
+		repoFileListMax:  e.repoFileListMax,
+		prDiffMaxLines:   e.prDiffMaxLines,
+		bootstrap:        e.bootstrap,
+		subtasks:         e.subtasks,
+		dryRuns:          e.dryRuns,
+		caches:           e.caches,
+		workspaces:       e.workspaces,
+		budgets:          e.budgets,
+		costEstimate:     e.costEstimate,
+	}
+	e.mu.RUnlock()
+	return run.execute(ctx, webhookCtx)
+}
+
+func (e *Executor) execute(ctx context.Context, webhookCtx *github.Context) (retErr error) {
+	ctx = logging.With(ctx, logging.KeyTaskID, webhookCtx.TaskID, logging.KeyRepo, webhookCtx.GetRepositoryFullName(), logging.KeyDeliveryID, webhookCtx.DeliveryID)
+	ctx, debug := e.startDebug(ctx, webhookCtx)
+	ctx, cancel := e.withDeadline(ctx, webhookCtx)
+	defer cancel()
+	var costUSD float64
+	var summary string
+	e.recordAudit(e.auditEvent(webhookCtx, audit.ActionExecutionStarted))
+	e.startTask(webhookCtx)
+	defer func() {
+		if errors.Is(retErr, errAwaitingConfirmation) {
+			e.awaitTask(webhookCtx)
+			retErr = nil
+			return
+		}
+		if retErr = timeoutError(ctx, retErr); errors.Is(retErr, ErrTimedOut) {
+			reportTimeout(webhookCtx, context.Cause(ctx))
+		}
+		ev := e.auditEvent(webhookCtx, audit.ActionExecutionDone)
+		ev.CostUSD = costUSD
+		if retErr != nil {
+			ev.Action = audit.ActionExecutionFailed
+			ev.Detail = retErr.Error()
+			if webhookCtx.Token != "" {
+				// git errors may echo the authenticated remote URL
+				ev.Detail = strings.ReplaceAll(ev.Detail, webhookCtx.Token, "***")
+			}
+		}
+		e.recordAudit(ev)
+		e.chargeBudget(webhookCtx, costUSD)
+		e.notifyResult(webhookCtx, summary, costUSD, ev.Detail, retErr != nil)
+		e.finishTask(webhookCtx, retErr)
+		finishCommentHistory(webhookCtx, retErr)
+	}()
+
 	// 0) Configure Git identity (best-effort)
 	if err := operations.ConfigureGitForApp(0, "swe-agent"); err != nil {
 		// non-fatal; downstream git commands may still work
-		fmt.Printf("[Warn] Configure git failed: %v\n", err)
+		taskLog(webhookCtx, "setup").Warn("Configuring git failed", "err", err)
 	}
 
 	// 1) Authenticate (GitHub App → installation token)
@@ -62,11 +254,26 @@ func (e *Executor) Execute(ctx context.Context, webhookCtx *github.Context) erro
 	// Surface token in context for optional MCP clients
 	webhookCtx.Token = token.Token
 
+	// 1.2) Earlier tracking comments on the issue or PR are outdated now
+	e.minimizeOutdatedComments(ctx, webhookCtx, repo)
+
+	// 1.5) Applying a dry run pushes its saved workspace; nothing else runs
+	if webhookCtx.PreparedApplyTaskID != "" {
+		summary, err = e.applyDryRun(ctx, webhookCtx, repo, token.Token)
+		return err
+	}
+
 	// 2) Fetch GitHub data via data layer
 	fetched, err := e.fetcher.Fetch(ctx, webhookCtx)
 	if err != nil {
 		return fmt.Errorf("fetch GitHub data: %w", err)
 	}
+	defer func() {
+		if retErr == nil && webhookCtx.PreparedRelease == "" && !webhookCtx.PreparedTriage && webhookCtx.PreparedBackport == "" && !webhookCtx.PreparedUpdateDeps && !holdsPushes(webhookCtx) {
+			title, _ := subjectText(fetched)
+			e.rememberTask(webhookCtx, repo, title, summary)
+		}
+	}()
 
 	// 2.5) Fix PR context: If PreparedBranch is empty but we fetched PR data,
 	//      extract head branch from GraphQL data (issue_comment webhooks don't provide it)
@@ -80,19 +287,51 @@ func (e *Executor) Execute(ctx context.Context, webhookCtx *github.Context) erro
 		}
 	}
 
-	// 3) Clone repository (prefer prepared base branch)
-	base := webhookCtx.PreparedBaseBranch
+	// 2.6) Small PRs: embed the diff itself, not just the changed files
+	if webhookCtx.PreparedPrompt == "" {
+		e.attachPRDiff(webhookCtx, fetched)
+	}
+
+	// 2.7) /code address-reviews works through the unresolved review threads
+	var threads []ghdata.ReviewThread
+	if webhookCtx.PreparedAddressReviews && webhookCtx.IsPRContext() {
+		number := webhookCtx.GetPRNumber()
+		if number == 0 {
+			number = webhookCtx.GetIssueNumber()
+		}
+		threads, err = e.reviews.UnresolvedReviewThreads(ctx, repo, number)
+		if err != nil {
+			return err
+		}
+		if len(threads) == 0 {
+			summary = "No unresolved review threads to address"
+			prependNotice(webhookCtx, "> [!NOTE]\n> "+commentText(webhookCtx, comment.MsgNoReviewThreads))
+			return nil
+		}
+	}
+
+	// 3) Clone repository (prefer prepared base branch; a backport starts
+	//    from its target)
+	base := webhookCtx.PreparedBackport
+	if base == "" {
+		base = webhookCtx.PreparedBaseBranch
+	}
 	if base == "" {
 		base = webhookCtx.GetBaseBranch()
 	}
 	if base == "" {
 		base = "main"
 	}
-	workdir, cleanup, err := cloneRepo(repo, base, token.Token)
+	e.phase(webhookCtx, taskstore.PhaseClone)
+	workdir, cleanup, err := e.workspaces.clone(ctx, repo, base, token.Token)
 	if err != nil {
 		return fmt.Errorf("clone repository: %w", err)
 	}
-	defer cleanup()
+	defer func() { cleanup() }() // a dry run hands its workspace over
+	if debug != nil {
+		defer e.saveDebugLog(ctx, webhookCtx, debug)
+		defer debug.capture(workdir)()
+	}
 
 	// Configure git credential helper to use installation token for push authentication
 	// This allows AI to execute "git push" without manual intervention
@@ -101,11 +340,39 @@ func (e *Executor) Execute(ctx context.Context, webhookCtx *github.Context) erro
 		return fmt.Errorf("configure git remote with token: %w", err)
 	}
 
+	// 3.5) Confirmed /release tasks tag and publish instead of changing code
+	if webhookCtx.PreparedRelease != "" {
+		e.phase(webhookCtx, taskstore.PhaseRelease)
+		summary, costUSD, err = e.executeRelease(ctx, webhookCtx, workdir, repo, base, token.Token)
+		return err
+	}
+
+	// 3.6) /triage labels the issue; the checkout is only read
+	if webhookCtx.PreparedTriage {
+		e.phase(webhookCtx, taskstore.PhaseProvider)
+		summary, costUSD, err = e.executeTriage(ctx, webhookCtx, fetched, workdir, repo, token.Token)
+		return err
+	}
+
+	// 3.7) /code backport cherry-picks the merged pull request onto the
+	//      target branch and opens a backport pull request
+	if webhookCtx.PreparedBackport != "" {
+		summary, costUSD, err = e.executeBackport(ctx, webhookCtx, fetched, workdir, repo, token.Token)
+		return err
+	}
+
+	// 3.8) /code update-deps runs the package managers' updates and opens a
+	//      pull request with the bumps; the provider is not involved
+	if webhookCtx.PreparedUpdateDeps {
+		summary, err = e.executeUpdateDeps(ctx, webhookCtx, workdir, repo, base, token.Token)
+		return err
+	}
+
 	// 4) Checkout task branch
 	branch := webhookCtx.PreparedBranch
 	if branch == "" && !webhookCtx.IsPRContext() {
 		if existing, detectErr := findExistingIssueBranch(webhookCtx, workdir); detectErr != nil {
-			fmt.Printf("[Warn] detect existing branch failed: %v\n", detectErr)
+			taskLog(webhookCtx, "checkout").Warn("Detecting existing branch failed", "err", detectErr)
 		} else if existing != "" {
 			branch = existing
 			webhookCtx.PreparedBranch = branch
@@ -118,14 +385,35 @@ func (e *Executor) Execute(ctx context.Context, webhookCtx *github.Context) erro
 		webhookCtx.PreparedBranch = branch
 	}
 
+	// 4.1) Never push directly to a protected branch (e.g. a PR whose head is
+	//      main): work on a new branch instead and guard the protected ones
+	protected := protectedBranches(webhookCtx.GetRepositoryOwner(), webhookCtx.GetRepositoryName(), token.Token, branch, base)
+	redirectedFrom := ""
+	for _, b := range protected {
+		if b == branch {
+			redirectedFrom = branch
+			branch = featureBranchName(webhookCtx)
+			webhookCtx.PreparedBranch = branch
+			break
+		}
+	}
+
 	// 如果 branch == base，说明已经在目标分支上（clone 时已 checkout），跳过
 	if branch != base {
 		// 检查远程分支是否存在（PR 场景会存在）
 		refs, lsErr := gitLsRemoteHeads(workdir, branch)
-		// 如果 ls-remote 成功且有输出，说明远程分支存在（PR 场景）
-		if lsErr == nil && len(refs) > 0 {
+		if redirectedFrom != "" && redirectedFrom != base {
+			// start the new branch from the protected branch's content
+			if err := runCmd("git", "-C", workdir, "fetch", "origin", redirectedFrom); err != nil {
+				return fmt.Errorf("fetch protected branch: %w", err)
+			}
+			if err := runCmd("git", "-C", workdir, "checkout", "-b", branch, "FETCH_HEAD"); err != nil {
+				return fmt.Errorf("create feature branch: %w", err)
+			}
+		} else if lsErr == nil && len(refs) > 0 {
+			// 如果 ls-remote 成功且有输出，说明远程分支存在（PR 场景）
 			// 远程分支存在：强制 fetch 该分支到本地 tracking ref
-			refspec := fmt.Sprintf("refs/heads/%s:refs/remotes/origin/%s", branch, branch)
+			refspec := fmt.Sprintf("+refs/heads/%s:refs/remotes/origin/%s", branch, branch)
 			if err := runCmd("git", "-C", workdir, "fetch", "origin", refspec); err != nil {
 				return fmt.Errorf("fetch remote branch: %w", err)
 			}
@@ -134,7 +422,7 @@ func (e *Executor) Execute(ctx context.Context, webhookCtx *github.Context) erro
 			}
 		} else {
 			if lsErr != nil {
-				fmt.Printf("[Warn] git ls-remote failed: %v\n", lsErr)
+				taskLog(webhookCtx, "checkout").Warn("git ls-remote failed", "err", lsErr)
 			}
 			// 远程分支不存在或 ls-remote 失败：创建新分支（Issue 场景）
 			if err := runCmd("git", "-C", workdir, "checkout", "-b", branch); err != nil {
@@ -143,14 +431,43 @@ func (e *Executor) Execute(ctx context.Context, webhookCtx *github.Context) erro
 		}
 	}
 
-	// 5) Build or use prepared prompt (system + GitHub XML)
-	fullPrompt := webhookCtx.PreparedPrompt
-	if fullPrompt == "" {
-		fullPrompt = prompt.BuildPrompt(webhookCtx, fetched)
+	if len(protected) > 0 {
+		if err := installPushGuard(workdir, protected, branch); err != nil {
+			return err
+		}
 	}
 
-	// 6) Call provider.GenerateCode (pass token via context + env for MCP)
-	// 6) Inject MCP-friendly environment variables
+	// 4.2) The changed files' blob SHAs come from the checkout of the PR
+	if webhookCtx.IsPRContext() && len(fetched.Changed) > 0 {
+		fetched.ChangedSHA = ghdata.ChangedFilesWithSHA(workdir, fetched.Changed)
+	}
+
+	// Report the task as a commit status on its branch
+	if statuses := e.startCommitStatus(webhookCtx, token.Token, remoteHead(workdir, branch)); statuses != nil {
+		defer func() { statuses.finish(fetchRemoteHead(workdir, branch), timeoutError(ctx, retErr)) }()
+	}
+
+	// Remember the remote head so post-push checks can tell what was pushed
+	var remoteBefore string
+	if len(e.checks) > 0 {
+		remoteBefore = remoteHead(workdir, branch)
+	}
+
+	// 4.2) Reuse the dependency caches of earlier tasks on the repository
+	if cache := e.caches.open(repo); cache != nil {
+		defer cache.release(workdir)
+		ctx = withCacheEnv(ctx, cache.env())
+		if cache.restoreNodeModules(workdir) {
+			taskLog(webhookCtx, "cache").Info("Restored node_modules")
+		}
+	}
+
+	// 4.3) Install the dependencies and tooling the repository's builds and
+	//      tests need before the provider runs them
+	environment := e.bootstrapEnvironment(ctx, webhookCtx, workdir, e.repoSettings.For(repo).Setup)
+
+	// 5) Prepare the provider run (pass token via context + env for MCP)
+	// Inject MCP-friendly environment variables
 	// Set env for child tools (best-effort; provider also sets from req.Context)
 	_ = os.Setenv("GITHUB_PERSONAL_ACCESS_TOKEN", token.Token)
 	_ = os.Setenv("GITHUB_TOKEN", token.Token)
@@ -173,44 +490,288 @@ func (e *Executor) Execute(ctx context.Context, webhookCtx *github.Context) erro
 		if webhookCtx.EventName != "" {
 			ctxMap["event_name"] = string(webhookCtx.EventName)
 		}
+		if e.commentTemplate != "" {
+			// swe-mcp keeps the layout on the comments the provider writes
+			ctxMap["comment_template"] = e.commentTemplate
+			ctxMap["comment_lang"] = webhookCtx.PreparedLang
+			ctxMap["task_id"] = webhookCtx.TaskID
+		}
+		if webhookCtx.PreparedCommentHistory {
+			// swe-mcp keeps the history of earlier runs below the provider's body
+			ctxMap["comment_history"] = "true"
+		}
 	}
 	if webhookCtx.IsPRContext() {
 		if n := webhookCtx.GetPRNumber(); n != 0 {
+			// the review thread server is scoped to this pull request
 			ctxMap["pr_number"] = fmt.Sprintf("%d", n)
+			ctxMap["repo_owner"] = webhookCtx.GetRepositoryOwner()
+			ctxMap["repo_name"] = webhookCtx.GetRepositoryName()
 		}
 	} else if n := webhookCtx.GetIssueNumber(); n != 0 {
 		ctxMap["issue_number"] = fmt.Sprintf("%d", n)
 	}
 
-	// Build tool configuration
+	// Build tool configuration, with the repository's own additions
+	overrides := e.repoSettings.For(repo)
+	mcpServers := overrides.MCPServers
+	if holdsPushes(webhookCtx) || webhookCtx.PreparedAddressReviews {
+		// Nothing is pushed yet, so no review thread can be resolved as
+		// fixed; /code address-reviews resolves its threads itself once the
+		// push is verified.
+		mcpServers = maps.Clone(mcpServers)
+		if mcpServers == nil {
+			mcpServers = make(map[string]bool)
+		}
+		mcpServers[mcpconfig.ReviewServer] = false
+	}
 	toolOpts := toolconfig.Options{
 		UseCommitSigning:       getEnvBool("USE_COMMIT_SIGNING", false),
 		EnableGitHubCommentMCP: true, // default enable comment MCP for coordinator
-		EnableGitHubFileOpsMCP: getEnvBool("ENABLE_GITHUB_MCP_FILES", false),
+		EnableGitHubFileOpsMCP: getEnvBool("ENABLE_GITHUB_MCP_FILES", false) && !holdsPushes(webhookCtx),
 		EnableGitHubCIMCP:      getEnvBool("ENABLE_GITHUB_MCP_CI", false),
+		CustomAllowedTools:     append(mcpconfig.AllowedTools(mcpServers), overrides.AllowedTools...),
+		CustomDisallowedTools:  overrides.DisallowedTools,
 	}
 	allowedTools := toolconfig.BuildAllowedTools(toolOpts)
 	disallowedTools := toolconfig.BuildDisallowedTools(toolOpts)
 
 	// Log tool configuration for debugging
 	if len(allowedTools) > 0 {
-		fmt.Printf("[Tools] Allowed (%d): %s\n", len(allowedTools), joinCSV(allowedTools))
+		taskLog(webhookCtx, "tools").DebugContext(ctx, "Allowed tools", "count", len(allowedTools), "tools", joinCSV(allowedTools))
 	}
 	if len(disallowedTools) > 0 {
-		fmt.Printf("[Tools] Disallowed (%d): %s\n", len(disallowedTools), joinCSV(disallowedTools))
+		taskLog(webhookCtx, "tools").DebugContext(ctx, "Disallowed tools", "count", len(disallowedTools), "tools", joinCSV(disallowedTools))
 	}
 
-	_, err = e.provider.GenerateCode(ctx, &provider.CodeRequest{
-		Prompt:          fullPrompt,
+	// Destructive git commands and pushes of secrets are refused however the
+	// provider runs git
+	guard, err := installGitGuard(e.secretRuleSet(), e.blockedPaths)
+	if err != nil {
+		return err
+	}
+	defer guard.remove()
+	if planOnly(webhookCtx) {
+		if err := guard.holdPushes("plan awaiting approval"); err != nil {
+			return err
+		}
+	} else if dryRun(webhookCtx) {
+		if err := guard.holdPushes("dry run"); err != nil {
+			return err
+		}
+	} else if e.policy.HasPushRules() {
+		if err := guard.setPolicy(e.policy, pushPolicyInput(webhookCtx)); err != nil {
+			return err
+		}
+	}
+
+	req := &provider.CodeRequest{
 		RepoPath:        workdir,
 		Context:         ctxMap,
 		AllowedTools:    allowedTools,
 		DisallowedTools: disallowedTools,
-	})
+		MCPServers:      mcpServers,
+		Env:             append(guard.env(), cacheEnvFrom(ctx)...),
+	}
+	if debug != nil {
+		req.Stderr = debug
+	}
+	// MCP servers run supervised, so one that dies fails the task at once
+	mcp, err := startMCPSupervision()
 	if err != nil {
-		return fmt.Errorf("provider %s: %w", e.provider.Name(), err)
+		taskLog(webhookCtx, "mcp").Warn("MCP servers run unsupervised", "err", err)
+	} else {
+		defer mcp.remove()
+		req.MCPLauncher = mcp.launcher
+	}
+	// Start the provider CLI while the prompt is assembled
+	if w, ok := e.provider.(provider.Prewarmer); ok {
+		w.Prewarm(req)
 	}
 
+	// 6) Build or use prepared prompt (system + GitHub XML)
+	fullPrompt, usedTemplate := webhookCtx.PreparedPrompt, "prepared"
+	if fullPrompt == "" {
+		tmpl := promptTemplate(workdir, e.promptDir, prompt.ModeOf(webhookCtx))
+		files, omitted := repoFileList(workdir, overrides.FileListExclude, fetched, e.repoFileListMax)
+		fullPrompt = prompt.BuildPromptWith(webhookCtx, fetched, prompt.Options{
+			RepoPath:         workdir,
+			Template:         tmpl,
+			RepoFiles:        files,
+			RepoFilesOmitted: omitted,
+			MaxContextTokens: e.contextTokens,
+		})
+		usedTemplate = templateName(workdir, tmpl)
+	}
+
+	if redirectedFrom != "" {
+		fullPrompt += "\n\n" + protectionPromptSection(redirectedFrom, branch)
+	}
+
+	// 6.5) Check out the wiki when the trigger asks for wiki changes
+	wikiReady, wikiBefore := false, ""
+	if e.wiki && !holdsPushes(webhookCtx) && wantsWiki(webhookCtx) {
+		section, head, err := prepareWiki(workdir, repo, token.Token)
+		if err != nil {
+			taskLog(webhookCtx, "wiki").Warn("Wiki unavailable", "err", err)
+		} else {
+			fullPrompt += "\n\n" + section
+			wikiReady, wikiBefore = true, head
+		}
+	}
+
+	// 6.6) Have issues and PRs the agent creates follow the repository's templates
+	if section := templatesPromptSection(findRepoTemplates(workdir)); section != "" {
+		fullPrompt += "\n\n" + section
+	}
+
+	// 6.64) Say which toolchains are ready, missing or failed to set up
+	if section := environment.promptSection(); section != "" {
+		fullPrompt += "\n\n" + section
+	}
+
+	// 6.65) Add the repository's custom instructions
+	if section := reposettings.PromptSection(overrides.Instructions); section != "" {
+		fullPrompt += "\n\n" + section
+	}
+
+	// 6.66) Mention related earlier tasks in the repository
+	if section := e.priorWorkSection(webhookCtx, repo, fetched); section != "" {
+		fullPrompt += "\n\n" + section
+	}
+
+	// 6.665) Write the tracking comment in the requester's language
+	if section := languagePromptSection(webhookCtx); section != "" {
+		fullPrompt += "\n\n" + section
+	}
+
+	// 6.68) Plan only, or carry out an approved plan (approval mode)
+	if section := approvalPromptSection(webhookCtx); section != "" {
+		fullPrompt += "\n\n" + section
+	}
+	if dryRun(webhookCtx) {
+		fullPrompt += "\n\n" + dryRunPromptSection(webhookCtx, branch)
+	}
+
+	// 6.685) Let large tasks be split into parallel sub-tasks
+	if e.subtasks.Parallel > 0 && !holdsPushes(webhookCtx) {
+		fullPrompt += "\n\n" + subtasksPromptSection(e.subtasks, subtasksPath(workdir))
+	}
+
+	// 6.69) The review threads to address, one commit each
+	if len(threads) > 0 {
+		fullPrompt += "\n\n" + addressReviewsPromptSection(threads)
+	}
+
+	// 6.7) Advertise the tools and policies actually in effect
+	caps := e.taskCapabilities(allowedTools, disallowedTools, branch, base, protected, wikiReady)
+	caps.Git.Push = !holdsPushes(webhookCtx)
+	fullPrompt += "\n\n" + capabilitiesPromptSection(caps)
+
+	// 6.8) Stop before the provider when the estimated cost needs confirming
+	if e.awaitCostConfirmation(webhookCtx, fullPrompt) {
+		return errAwaitingConfirmation
+	}
+
+	// 7) Call provider.GenerateCode, showing progress while it runs long
+	req.Prompt = fullPrompt
+	e.savePromptRecord(ctx, webhookCtx, usedTemplate, fullPrompt)
+	beat := e.startHeartbeat(webhookCtx)
+	if beat != nil {
+		req.Progress = beat.progress
+	}
+	req.Events = e.taskEvents(webhookCtx)
+	var transcript *cappedBuffer
+	var startSHA string
+	if e.wantsArtifacts(webhookCtx) {
+		transcript = &cappedBuffer{max: maxTranscriptSize}
+		req.Transcript = transcript
+	}
+	if transcript != nil || dryRun(webhookCtx) || len(threads) > 0 || e.policy.HasPushRules() {
+		if out, err := gitOutput(workdir, "rev-parse", "HEAD"); err == nil {
+			startSHA = strings.TrimSpace(out)
+		}
+	}
+	e.phase(webhookCtx, taskstore.PhaseProvider)
+	runCtx, cancelRun := context.WithCancelCause(ctx)
+	stopWatch := func() {}
+	if mcp != nil {
+		stopWatch = mcp.watch(runCtx, cancelRun)
+	}
+	resp, err := e.provider.GenerateCode(runCtx, req)
+	stopWatch()
+	if serverErr := mcpError(runCtx); err != nil && serverErr != nil {
+		serverErr.Stderr = e.redactMCPOutput(webhookCtx, serverErr.Stderr)
+		err = serverErr
+	}
+	cancelRun(nil)
+	beat.stop()
+	e.recordMCPServers(webhookCtx, mcp)
+	if transcript != nil {
+		e.saveRunArtifacts(ctx, webhookCtx, workdir, startSHA, transcript)
+	}
+	e.recordBlockedGit(webhookCtx, guard)
+	e.reportSecrets(webhookCtx, guard)
+	e.reportBlockedPaths(webhookCtx, guard)
+	e.reportPolicyDenied(webhookCtx, guard)
+	if err != nil {
+		return &ProviderError{Provider: e.provider.Name(), Err: err}
+	}
+	if resp != nil {
+		costUSD = resp.CostUSD
+		summary = github.FilterOutput(redactSecrets(resp.Summary, e.secretRuleSet()), webhookCtx.Token)
+		e.saveArtifact(ctx, webhookCtx, artifacts.Summary, []byte(summary))
+	}
+	if planOnly(webhookCtx) {
+		e.awaitApproval(webhookCtx, summary)
+		return nil
+	}
+	if dryRun(webhookCtx) {
+		if e.finishDryRun(ctx, webhookCtx, workdir, branch, base, startSHA, cleanup) {
+			cleanup = func() {}
+		}
+		return nil
+	}
+	if held, ok := guard.policyHold(); ok {
+		if e.finishPolicyHold(ctx, webhookCtx, workdir, branch, base, startSHA, cleanup, held) {
+			cleanup = func() {}
+		}
+		return nil
+	}
+
+	// 7.5) Run the sub-tasks the provider handed off and push their merged
+	//      commits
+	if e.subtasks.Parallel > 0 {
+		cost, err := e.runSubtasks(ctx, webhookCtx, workdir, branch, req)
+		costUSD += cost
+		if err != nil {
+			return err
+		}
+	}
+	e.phase(webhookCtx, taskstore.PhasePush)
+	e.recordPushedBranch(webhookCtx, workdir)
+	if wikiReady {
+		e.recordWikiPush(webhookCtx, workdir, wikiBefore)
+	}
+	if redirectedFrom != "" {
+		e.reportRedirect(webhookCtx, workdir, redirectedFrom, branch)
+	}
+	if webhookCtx.PreparedApprovedBy != "" {
+		reportApproved(webhookCtx)
+	}
+
+	// 8) Verify the pushed branch; failures withdraw the change
+	if err := e.verifyPushed(ctx, webhookCtx, workdir, branch, base, remoteBefore); err != nil {
+		return err
+	}
+
+	// 8.5) Add the layout's links and changed files to the tracking comment
+	e.finishCommentLayout(webhookCtx, workdir, branch, base, remoteBefore)
+
+	// 9) Answer and resolve the review threads the pushed commits address
+	if len(threads) > 0 {
+		e.resolveAddressedThreads(ctx, webhookCtx, repo, workdir, branch, startSHA, threads)
+	}
 	return nil
 }
 
@@ -228,6 +789,7 @@ func featureBranchName(ctx *github.Context) string {
 func run(name string, args ...string) error {
 	cmd := exec.Command(name, args...)
 	out, err := cmd.CombinedOutput()
+	recordCommand(name, args, out, err)
 	if err != nil {
 		return fmt.Errorf("%s %v failed: %v\n%s", name, args, err, string(out))
 	}
@@ -4,13 +4,18 @@ import (
 	"context"
 	"errors"
 	"fmt"
+	"os"
+	"path/filepath"
+	"slices"
 	"strings"
 	"testing"
 	"time"
 
+	"github.com/cexll/swe/internal/audit"
 	"github.com/cexll/swe/internal/github"
 	ghdata "github.com/cexll/swe/internal/github/data"
 	"github.com/cexll/swe/internal/provider"
+	"github.com/cexll/swe/internal/reposettings"
 )
 
 // mockProvider is a mock implementation of provider.Provider
@@ -110,7 +115,7 @@ func runCtxMapTest(t *testing.T, ghCtx *github.Context, assert func(map[string]s
 	})
 
 	tmpDir := t.TempDir()
-	cloneRepo = func(repo, branch, token string) (string, func(), error) {
+	cloneRepo = func(_ context.Context, repo, branch, token string) (string, func(), error) {
 		return tmpDir, func() {}, nil
 	}
 	runCmd = func(name string, args ...string) error { return nil }
@@ -328,7 +333,7 @@ func TestExecute_Success(t *testing.T) {
 	defer func() { cloneRepo = origClone; runCmd = origRun }()
 
 	// Mock cloneRepo to create a temp workdir and no error
-	cloneRepo = func(repo, branch, token string) (string, func(), error) {
+	cloneRepo = func(_ context.Context, repo, branch, token string) (string, func(), error) {
 		dir := t.TempDir()
 		return dir, func() {}, nil
 	}
@@ -363,13 +368,49 @@ func TestExecute_Success(t *testing.T) {
 	}
 }
 
+func TestExecute_SetProviderLeavesRunningTaskPinned(t *testing.T) {
+	origClone, origRun := cloneRepo, runCmd
+	defer func() { cloneRepo, runCmd = origClone, origRun }()
+	cloneRepo = func(_ context.Context, repo, branch, token string) (string, func(), error) {
+		return t.TempDir(), func() {}, nil
+	}
+	runCmd = func(name string, args ...string) error { return nil }
+
+	second := &mockProvider{name: "second"}
+	var ex *Executor
+	first := &mockProvider{name: "first", generateFunc: func(ctx context.Context, req *provider.CodeRequest) (*provider.CodeResponse, error) {
+		ex.SetProvider(second) // a reload while the task runs
+		return &provider.CodeResponse{Summary: "ok"}, nil
+	}}
+	ex = New(first, &mockAuthProvider{})
+	log, _ := audit.New(audit.Config{})
+	ex.SetAuditLog(log)
+	ex.fetcher = &mockFetcher{fetchFunc: func(ctx context.Context, gctx *github.Context) (*ghdata.FetchResult, error) {
+		return &ghdata.FetchResult{ContextData: ghdata.Issue{Title: "t", Author: ghdata.Author{Login: "u"}}}, nil
+	}}
+
+	for i := 0; i < 2; i++ {
+		if err := ex.Execute(context.Background(), buildTestCtx(false)); err != nil {
+			t.Fatalf("Execute() error = %v", err)
+		}
+	}
+	var providers []string
+	for _, ev := range log.List(audit.Filter{Action: audit.ActionExecutionDone}) {
+		providers = append(providers, ev.Provider)
+	}
+	// newest first
+	if strings.Join(providers, ",") != "second,first" {
+		t.Fatalf("providers = %v, want the first task on first and the next on second", providers)
+	}
+}
+
 func TestExecute_AuthFailure(t *testing.T) {
 	origClone := cloneRepo
 	origRun := runCmd
 	defer func() { cloneRepo = origClone; runCmd = origRun }()
 
 	// No cloning should occur, but keep safe defaults
-	cloneRepo = func(repo, branch, token string) (string, func(), error) {
+	cloneRepo = func(_ context.Context, repo, branch, token string) (string, func(), error) {
 		return t.TempDir(), func() {}, nil
 	}
 	runCmd = func(name string, args ...string) error { return nil }
@@ -392,7 +433,7 @@ func TestExecute_FetchFailure(t *testing.T) {
 	origRun := runCmd
 	defer func() { cloneRepo = origClone; runCmd = origRun }()
 
-	cloneRepo = func(repo, branch, token string) (string, func(), error) {
+	cloneRepo = func(_ context.Context, repo, branch, token string) (string, func(), error) {
 		return t.TempDir(), func() {}, nil
 	}
 	runCmd = func(name string, args ...string) error { return nil }
@@ -415,7 +456,7 @@ func TestExecute_CloneFailure(t *testing.T) {
 	origRun := runCmd
 	defer func() { cloneRepo = origClone; runCmd = origRun }()
 
-	cloneRepo = func(repo, branch, token string) (string, func(), error) {
+	cloneRepo = func(_ context.Context, repo, branch, token string) (string, func(), error) {
 		return "", nil, errors.New("clone fail")
 	}
 	runCmd = func(name string, args ...string) error { return nil }
@@ -445,7 +486,7 @@ func TestExecute_BranchCreationFailure(t *testing.T) {
 	origRun := runCmd
 	defer func() { cloneRepo = origClone; runCmd = origRun }()
 
-	cloneRepo = func(repo, branch, token string) (string, func(), error) {
+	cloneRepo = func(_ context.Context, repo, branch, token string) (string, func(), error) {
 		return t.TempDir(), func() {}, nil
 	}
 	runCmd = func(name string, args ...string) error {
@@ -484,7 +525,7 @@ func TestExecute_ProviderFailure(t *testing.T) {
 	origRun := runCmd
 	defer func() { cloneRepo = origClone; runCmd = origRun }()
 
-	cloneRepo = func(repo, branch, token string) (string, func(), error) {
+	cloneRepo = func(_ context.Context, repo, branch, token string) (string, func(), error) {
 		return t.TempDir(), func() {}, nil
 	}
 	runCmd = func(name string, args ...string) error { return nil }
@@ -517,7 +558,7 @@ func TestExecute_EmptyRepoFallback(t *testing.T) {
 	defer func() { cloneRepo = origClone; runCmd = origRun }()
 
 	var cloneRepoArg string
-	cloneRepo = func(repo, branch, token string) (string, func(), error) {
+	cloneRepo = func(_ context.Context, repo, branch, token string) (string, func(), error) {
 		cloneRepoArg = repo
 		return t.TempDir(), func() {}, nil
 	}
@@ -560,7 +601,7 @@ func TestExecute_GitRemoteConfiguration(t *testing.T) {
 	origRun := runCmd
 	defer func() { cloneRepo = origClone; runCmd = origRun }()
 
-	cloneRepo = func(repo, branch, token string) (string, func(), error) {
+	cloneRepo = func(_ context.Context, repo, branch, token string) (string, func(), error) {
 		return t.TempDir(), func() {}, nil
 	}
 
@@ -617,7 +658,7 @@ func TestExecute_GitRemoteConfigurationFailure(t *testing.T) {
 	origRun := runCmd
 	defer func() { cloneRepo = origClone; runCmd = origRun }()
 
-	cloneRepo = func(repo, branch, token string) (string, func(), error) {
+	cloneRepo = func(_ context.Context, repo, branch, token string) (string, func(), error) {
 		return t.TempDir(), func() {}, nil
 	}
 
@@ -673,7 +714,7 @@ func TestExecute_PRContext_UsesHeadBranchFromFetchedData(t *testing.T) {
 	}()
 
 	// Mock cloneRepo
-	cloneRepo = func(repo, branch, token string) (string, func(), error) {
+	cloneRepo = func(_ context.Context, repo, branch, token string) (string, func(), error) {
 		return t.TempDir(), func() {}, nil
 	}
 
@@ -762,7 +803,7 @@ func TestExecute_IssueContext_ReusesExistingBranch(t *testing.T) {
 	}()
 
 	tempDir := t.TempDir()
-	cloneRepo = func(repo, branch, token string) (string, func(), error) {
+	cloneRepo = func(_ context.Context, repo, branch, token string) (string, func(), error) {
 		return tempDir, func() {}, nil
 	}
 
@@ -827,7 +868,7 @@ func TestExecute_IssueContext_CreatesBranchWhenNoneExists(t *testing.T) {
 	}()
 
 	tempDir := t.TempDir()
-	cloneRepo = func(repo, branch, token string) (string, func(), error) {
+	cloneRepo = func(_ context.Context, repo, branch, token string) (string, func(), error) {
 		return tempDir, func() {}, nil
 	}
 
@@ -882,7 +923,7 @@ func TestExecute_IssueContext_CreatesBranchWhenLsRemoteFails(t *testing.T) {
 	}()
 
 	tempDir := t.TempDir()
-	cloneRepo = func(repo, branch, token string) (string, func(), error) {
+	cloneRepo = func(_ context.Context, repo, branch, token string) (string, func(), error) {
 		return tempDir, func() {}, nil
 	}
 
@@ -1008,3 +1049,70 @@ func TestCheckoutRemoteBranch_FallbackFailure(t *testing.T) {
 		t.Fatalf("expected checkout error, got %v", err)
 	}
 }
+
+func TestExecute_RepoSettingsAddToolsAndInstructions(t *testing.T) {
+	origClone, origRun := cloneRepo, runCmd
+	t.Cleanup(func() { cloneRepo, runCmd = origClone, origRun })
+	cloneRepo = func(_ context.Context, repo, branch, token string) (string, func(), error) {
+		return t.TempDir(), func() {}, nil
+	}
+	runCmd = func(name string, args ...string) error { return nil }
+
+	var got *provider.CodeRequest
+	e := New(&mockProvider{generateFunc: func(_ context.Context, req *provider.CodeRequest) (*provider.CodeResponse, error) {
+		got = req
+		return &provider.CodeResponse{Summary: "ok"}, nil
+	}}, &mockAuthProvider{})
+	e.fetcher = &mockFetcher{fetchFunc: func(context.Context, *github.Context) (*ghdata.FetchResult, error) {
+		return &ghdata.FetchResult{ContextData: ghdata.Issue{Title: "t", Author: ghdata.Author{Login: "u"}}}, nil
+	}}
+	settings, err := reposettings.Parse([]byte(`{"Owner/Repo": {"allowed_tools": ["Bash(make lint)"], "disallowed_tools": ["WebFetch"], "instructions": "Run make lint."}}`))
+	if err != nil {
+		t.Fatal(err)
+	}
+	e.SetRepoSettings(settings)
+
+	if err := e.Execute(context.Background(), buildTestCtx(false)); err != nil {
+		t.Fatalf("Execute: %v", err)
+	}
+	if !slices.Contains(got.AllowedTools, "Bash(make lint)") || !slices.Contains(got.DisallowedTools, "WebFetch") {
+		t.Fatalf("tools: allowed=%v disallowed=%v", got.AllowedTools, got.DisallowedTools)
+	}
+	if !strings.Contains(got.Prompt, "<custom_instructions>") || !strings.Contains(got.Prompt, "Run make lint.") {
+		t.Fatalf("prompt lacks the repository's instructions:\n%s", got.Prompt)
+	}
+}
+
+func TestExecute_ChangedFileSHAsFromCheckout(t *testing.T) {
+	origClone, origRun := cloneRepo, runCmd
+	t.Cleanup(func() { cloneRepo, runCmd = origClone, origRun })
+	cloneRepo = func(_ context.Context, repo, branch, token string) (string, func(), error) {
+		dir := t.TempDir()
+		if err := os.WriteFile(filepath.Join(dir, "x.txt"), []byte("hello\n"), 0o644); err != nil {
+			return "", nil, err
+		}
+		return dir, func() {}, nil
+	}
+	runCmd = func(name string, args ...string) error { return nil }
+
+	var prompt string
+	ex := New(&mockProvider{generateFunc: func(ctx context.Context, req *provider.CodeRequest) (*provider.CodeResponse, error) {
+		prompt = req.Prompt
+		return &provider.CodeResponse{Summary: "done"}, nil
+	}}, &mockAuthProvider{})
+	ex.SetHeartbeatInterval(0)
+	ex.fetcher = &mockFetcher{fetchFunc: func(ctx context.Context, gctx *github.Context) (*ghdata.FetchResult, error) {
+		changed := []ghdata.File{{Path: "x.txt", ChangeType: "MODIFIED"}}
+		return &ghdata.FetchResult{
+			ContextData: ghdata.PullRequest{Title: "t"},
+			Changed:     changed,
+			ChangedSHA:  ghdata.ChangedFilesWithSHA("", changed),
+		}, nil
+	}}
+	if err := ex.Execute(context.Background(), buildTestCtx(true)); err != nil {
+		t.Fatal(err)
+	}
+	if !strings.Contains(prompt, "- x.txt (MODIFIED) +0/-0 SHA: ce013625030ba8dba906f756967f9e9ca394464a") {
+		t.Fatalf("changed file SHA not computed in the checkout:\n%s", prompt)
+	}
+}
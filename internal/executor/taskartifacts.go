@@ -0,0 +1,91 @@
+package executor
+
+import (
+	"bytes"
+	"context"
+	"fmt"
+	"io"
+	"strings"
+
+	"github.com/cexll/swe/internal/artifacts"
+	"github.com/cexll/swe/internal/github"
+)
+
+// maxTranscriptSize caps the provider output kept as a task's transcript.
+const maxTranscriptSize = 50 << 20
+
+// SetArtifacts keeps each task's provider transcript, final diff and test
+// output in s, for download from the task page (nil keeps none).
+func (e *Executor) SetArtifacts(s *artifacts.Store) {
+	e.artifacts = s
+}
+
+// wantsArtifacts reports whether ctx's task has somewhere to keep artifacts.
+func (e *Executor) wantsArtifacts(ctx *github.Context) bool {
+	return e.artifacts != nil && ctx.TaskID != ""
+}
+
+// saveArtifact stores data as artifact name of ctx's task, with secrets and
+// the installation token redacted, and reports whether it did. Failures are
+// logged, never fatal; the save outlives cancellation of the task itself.
+func (e *Executor) saveArtifact(ctx context.Context, ghCtx *github.Context, name string, data []byte) bool {
+	if !e.wantsArtifacts(ghCtx) || len(data) == 0 {
+		return false
+	}
+	text := redactSecrets(string(data), e.secretRuleSet())
+	if ghCtx.Token != "" {
+		text = strings.ReplaceAll(text, ghCtx.Token, "***")
+	}
+	if err := e.artifacts.Save(context.WithoutCancel(ctx), ghCtx.TaskID, name, []byte(text)); err != nil {
+		taskLog(ghCtx, "artifacts").Warn("Saving artifact failed", "name", name, "err", err)
+		return false
+	}
+	return true
+}
+
+// saveRunArtifacts keeps the provider transcript and the diff of everything
+// the run changed since startSHA (commits, edits and untracked files).
+func (e *Executor) saveRunArtifacts(ctx context.Context, ghCtx *github.Context, workdir, startSHA string, transcript *cappedBuffer) {
+	if transcript != nil {
+		if transcript.dropped > 0 {
+			fmt.Fprintf(&transcript.buf, "[transcript truncated: %d bytes dropped]\n", transcript.dropped)
+		}
+		e.saveArtifact(ctx, ghCtx, artifacts.Transcript, transcript.buf.Bytes())
+	}
+	if startSHA == "" {
+		return
+	}
+	_, diff, err := localDiff(workdir, startSHA)
+	if err != nil {
+		taskLog(ghCtx, "artifacts").Warn("Collecting diff failed", "err", err)
+		return
+	}
+	e.saveArtifact(ctx, ghCtx, artifacts.Diff, []byte(diff))
+}
+
+// cappedBuffer keeps the first max bytes written to it and counts the rest.
+type cappedBuffer struct {
+	buf     bytes.Buffer
+	max     int
+	dropped int
+}
+
+func (b *cappedBuffer) Write(p []byte) (int, error) {
+	keep := min(len(p), max(b.max-b.buf.Len(), 0))
+	b.buf.Write(p[:keep])
+	b.dropped += len(p) - keep
+	return len(p), nil
+}
+
+type checkOutputKey struct{}
+
+// withCheckOutput makes command checks run under ctx copy their output to w.
+func withCheckOutput(ctx context.Context, w io.Writer) context.Context {
+	return context.WithValue(ctx, checkOutputKey{}, w)
+}
+
+// checkOutputFrom returns where command checks copy their output, or nil.
+func checkOutputFrom(ctx context.Context) io.Writer {
+	w, _ := ctx.Value(checkOutputKey{}).(io.Writer)
+	return w
+}
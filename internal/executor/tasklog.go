@@ -0,0 +1,21 @@
+package executor
+
+import (
+	"log/slog"
+
+	"github.com/cexll/swe/internal/github"
+	"github.com/cexll/swe/internal/logging"
+)
+
+// taskLog returns the logger for lines about the task of ctx in phase: they
+// carry the task ID, repository and delivery.
+func taskLog(ctx *github.Context, phase string) *slog.Logger {
+	return logging.Logger(logging.KeyTaskID, ctx.TaskID, logging.KeyRepo, ctx.GetRepositoryFullName(),
+		logging.KeyDeliveryID, ctx.DeliveryID, logging.KeyPhase, phase)
+}
+
+// phaseLog returns the logger for lines in phase that no task context is at
+// hand for; logged with a context, they carry the fields attached to it.
+func phaseLog(phase string) *slog.Logger {
+	return logging.Logger(logging.KeyPhase, phase)
+}
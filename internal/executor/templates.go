@@ -0,0 +1,131 @@
+package executor
+
+import (
+	"fmt"
+	"os"
+	"path/filepath"
+	"sort"
+	"strings"
+)
+
+const (
+	// maxTemplateSize and maxTemplatesSize bound the templates quoted in the prompt.
+	maxTemplateSize  = 4 << 10
+	maxTemplatesSize = 16 << 10
+)
+
+// repoTemplate is an issue or pull request template of the repository.
+type repoTemplate struct {
+	Kind    string // "issue" or "pull request"
+	Path    string // relative to the repository root
+	Content string
+}
+
+// prTemplateLocations are where GitHub looks for pull request templates,
+// single files first.
+var prTemplateLocations = []string{
+	".github/pull_request_template.md",
+	".github/PULL_REQUEST_TEMPLATE.md",
+	"pull_request_template.md",
+	"PULL_REQUEST_TEMPLATE.md",
+	"docs/pull_request_template.md",
+	"docs/PULL_REQUEST_TEMPLATE.md",
+	".github/PULL_REQUEST_TEMPLATE",
+	"PULL_REQUEST_TEMPLATE",
+	"docs/PULL_REQUEST_TEMPLATE",
+}
+
+// issueTemplateLocations hold issue templates (Markdown) and issue forms (YAML).
+var issueTemplateLocations = []string{
+	".github/ISSUE_TEMPLATE",
+	"ISSUE_TEMPLATE",
+	"docs/ISSUE_TEMPLATE",
+	".github/issue_template.md",
+	".github/ISSUE_TEMPLATE.md",
+	"issue_template.md",
+	"ISSUE_TEMPLATE.md",
+}
+
+// findRepoTemplates returns the issue and pull request templates in workdir.
+// GitHub matches the locations case-insensitively on the default branch; a
+// checked-out tree is close enough for filling them in.
+func findRepoTemplates(workdir string) []repoTemplate {
+	var out []repoTemplate
+	seen := make(map[string]bool)
+	add := func(kind string, locations []string, exts ...string) {
+		for _, loc := range locations {
+			for _, rel := range templateFiles(workdir, loc, exts) {
+				if seen[strings.ToLower(rel)] {
+					continue
+				}
+				seen[strings.ToLower(rel)] = true
+				data, err := os.ReadFile(filepath.Join(workdir, filepath.FromSlash(rel)))
+				if err != nil || len(strings.TrimSpace(string(data))) == 0 {
+					continue
+				}
+				out = append(out, repoTemplate{Kind: kind, Path: rel, Content: string(data)})
+			}
+		}
+	}
+	add("pull request", prTemplateLocations, ".md")
+	add("issue", issueTemplateLocations, ".md", ".yml", ".yaml")
+	return out
+}
+
+// templateFiles lists loc itself when it is a file, or the template files
+// directly inside it when it is a directory (config.yml excluded).
+func templateFiles(workdir, loc string, exts []string) []string {
+	info, err := os.Stat(filepath.Join(workdir, filepath.FromSlash(loc)))
+	if err != nil {
+		return nil
+	}
+	if !info.IsDir() {
+		return []string{loc}
+	}
+	entries, err := os.ReadDir(filepath.Join(workdir, filepath.FromSlash(loc)))
+	if err != nil {
+		return nil
+	}
+	var files []string
+	for _, e := range entries {
+		name := e.Name()
+		if e.IsDir() || strings.EqualFold(strings.TrimSuffix(name, filepath.Ext(name)), "config") {
+			continue
+		}
+		for _, ext := range exts {
+			if strings.EqualFold(filepath.Ext(name), ext) {
+				files = append(files, loc+"/"+name)
+				break
+			}
+		}
+	}
+	sort.Strings(files)
+	return files
+}
+
+// templatesPromptSection tells the provider to fill the repository's
+// templates when it creates issues or pull requests ("" without templates).
+func templatesPromptSection(templates []repoTemplate) string {
+	if len(templates) == 0 {
+		return ""
+	}
+	var b strings.Builder
+	b.WriteString(`<repository_templates>
+This repository defines templates for issues and pull requests. Whenever you create an issue (gh issue create, e.g. when splitting or triaging work) or a pull request (gh pr create, or the body of a PR creation link), fill in the matching template: keep its headings and checklists in order, replace the placeholder text and HTML comments with real content, and write "N/A" for sections that do not apply. For YAML issue forms, write each field's label as a Markdown heading followed by your answer, and apply the form's labels when it defines any. When several templates exist, pick the one that fits the issue's kind.
+`)
+	budget := maxTemplatesSize
+	for _, t := range templates {
+		content := strings.TrimSpace(t.Content)
+		if len(content) > maxTemplateSize {
+			content = content[:maxTemplateSize] + "\n... (truncated)"
+		}
+		if len(content) > budget {
+			fmt.Fprintf(&b, "\n%s template %s: not shown (read it from the repository)\n", t.Kind, t.Path)
+			continue
+		}
+		budget -= len(content)
+		fmt.Fprintf(&b, "\n<template kind=%q path=%q>\n%s\n</template>\n", t.Kind, t.Path, content)
+	}
+	b.WriteString("</repository_templates>")
+	return b.String()
+}
@@ -0,0 +1,49 @@
+package executor
+
+import (
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+)
+
+func writeRepoFile(t *testing.T, root, rel, content string) {
+	t.Helper()
+	path := filepath.Join(root, filepath.FromSlash(rel))
+	if err := os.MkdirAll(filepath.Dir(path), 0o755); err != nil {
+		t.Fatal(err)
+	}
+	if err := os.WriteFile(path, []byte(content), 0o644); err != nil {
+		t.Fatal(err)
+	}
+}
+
+func TestFindRepoTemplates(t *testing.T) {
+	dir := t.TempDir()
+	if templatesPromptSection(findRepoTemplates(dir)) != "" {
+		t.Fatal("a repository without templates needs no prompt section")
+	}
+
+	writeRepoFile(t, dir, ".github/pull_request_template.md", "## Summary\n\n## Test plan\n")
+	writeRepoFile(t, dir, ".github/ISSUE_TEMPLATE/bug_report.yml", "name: Bug\nlabels: [bug]\nbody:\n  - type: textarea\n    attributes:\n      label: Steps\n")
+	writeRepoFile(t, dir, ".github/ISSUE_TEMPLATE/feature.md", "## Motivation\n")
+	writeRepoFile(t, dir, ".github/ISSUE_TEMPLATE/config.yml", "blank_issues_enabled: false\n")
+	writeRepoFile(t, dir, "docs/PULL_REQUEST_TEMPLATE.md", "   \n")
+
+	templates := findRepoTemplates(dir)
+	var got []string
+	for _, tpl := range templates {
+		got = append(got, tpl.Kind+":"+tpl.Path)
+	}
+	want := "pull request:.github/pull_request_template.md|issue:.github/ISSUE_TEMPLATE/bug_report.yml|issue:.github/ISSUE_TEMPLATE/feature.md"
+	if strings.Join(got, "|") != want {
+		t.Fatalf("templates = %v, want %s", got, want)
+	}
+
+	section := templatesPromptSection(templates)
+	if !strings.HasPrefix(section, "<repository_templates>") ||
+		!strings.Contains(section, "<template kind=\"pull request\" path=\".github/pull_request_template.md\">\n## Summary\n\n## Test plan\n</template>") ||
+		!strings.Contains(section, "label: Steps") {
+		t.Fatalf("unexpected section:\n%s", section)
+	}
+}
@@ -0,0 +1,16 @@
+package executor
+
+import (
+	"os"
+	"testing"
+)
+
+// TestMain treats every branch as unprotected so tests never query GitHub.
+// The git guard's pre-push hook runs the test binary as the push check.
+func TestMain(m *testing.M) {
+	if len(os.Args) > 1 && os.Args[1] == PushCheckCommand {
+		os.Exit(RunPushCheck(os.Args[2:], os.Stdin, os.Stderr))
+	}
+	branchProtected = func(_, _, _, _ string) (bool, error) { return false, nil }
+	os.Exit(m.Run())
+}
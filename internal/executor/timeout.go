@@ -0,0 +1,78 @@
+package executor
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"time"
+
+	"github.com/cexll/swe/internal/github"
+	"github.com/cexll/swe/internal/github/comment"
+)
+
+// ErrTimedOut is the cancellation cause of a task that ran longer than it
+// was allowed to; the task then fails with an error wrapping it.
+var ErrTimedOut = errors.New("task timed out")
+
+// TaskTimeouts bound how long a task may run, from authentication through
+// clone and provider run to the verify command.
+type TaskTimeouts struct {
+	Default time.Duration // for tasks that do not ask for one (0: no limit)
+	Max     time.Duration // caps what tasks ask for with /code --timeout (0: no cap)
+}
+
+// SetTaskTimeouts sets the timeouts of subsequent tasks.
+func (e *Executor) SetTaskTimeouts(t TaskTimeouts) {
+	e.mu.Lock()
+	defer e.mu.Unlock()
+	e.timeouts = t
+}
+
+// limit returns how long a task asking for requested (0: nothing) may run;
+// 0 means no limit.
+func (t TaskTimeouts) limit(requested time.Duration) time.Duration {
+	d := t.Default
+	if requested > 0 {
+		d = requested
+	}
+	if t.Max > 0 && (d <= 0 || d > t.Max) {
+		d = t.Max
+	}
+	return d
+}
+
+// withDeadline returns ctx bounded by the timeout of ctx's task, cancelled
+// with ErrTimedOut as the cause when it runs out.
+func (e *Executor) withDeadline(ctx context.Context, gctx *github.Context) (context.Context, context.CancelFunc) {
+	d := e.timeouts.limit(gctx.PreparedTimeout)
+	if d <= 0 {
+		return context.WithCancel(ctx)
+	}
+	if gctx.PreparedTimeout > d {
+		msg := fmt.Sprintf("Requested timeout %s capped at %s", gctx.PreparedTimeout, d)
+		taskLog(gctx, "execute").Info(msg)
+		if e.store != nil && gctx.TaskID != "" {
+			e.store.AddLog(gctx.TaskID, "info", msg)
+		}
+	}
+	return context.WithTimeoutCause(ctx, d, fmt.Errorf("%w after %s", ErrTimedOut, d))
+}
+
+// timeoutError returns err wrapped in the cause ctx was cancelled with when
+// that cause is ErrTimedOut, so a task stopped for running too long fails
+// with the timeout as its reason rather than a bare "context canceled".
+func timeoutError(ctx context.Context, err error) error {
+	if err == nil || errors.Is(err, ErrTimedOut) {
+		return err
+	}
+	if cause := context.Cause(ctx); errors.Is(cause, ErrTimedOut) {
+		return fmt.Errorf("%w: %v", cause, err)
+	}
+	return err
+}
+
+// reportTimeout puts a notice above the tracking comment that the task was
+// stopped, and why (the cause its context was cancelled with).
+func reportTimeout(ctx *github.Context, cause error) {
+	prependNotice(ctx, "> [!WARNING]\n> "+commentText(ctx, comment.MsgStopped, cause))
+}
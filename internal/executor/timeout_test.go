@@ -0,0 +1,134 @@
+package executor
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/cexll/swe/internal/github"
+	ghdata "github.com/cexll/swe/internal/github/data"
+	"github.com/cexll/swe/internal/provider"
+	"github.com/cexll/swe/internal/taskstore"
+)
+
+func TestExecute_TimedOutTaskFailsWithReason(t *testing.T) {
+	origClone, origRun := cloneRepo, runCmd
+	origGet, origUpdate := getComment, updateComment
+	t.Cleanup(func() {
+		cloneRepo, runCmd = origClone, origRun
+		getComment, updateComment = origGet, origUpdate
+	})
+	cloneRepo = func(_ context.Context, repo, branch, token string) (string, func(), error) {
+		return t.TempDir(), func() {}, nil
+	}
+	runCmd = func(name string, args ...string) error { return nil }
+	comment := "### Working on it"
+	getComment = func(_, _ string, _ int64, _ string) (string, error) { return comment, nil }
+	updateComment = func(_, _ string, _ int64, body, _ string) error {
+		comment = body
+		return nil
+	}
+
+	ctx, cancel := context.WithCancelCause(context.Background())
+	mp := &mockProvider{generateFunc: func(ctx context.Context, req *provider.CodeRequest) (*provider.CodeResponse, error) {
+		// a stuck provider, stopped by the reaper
+		cancel(fmt.Errorf("%w after running for more than %s", ErrTimedOut, time.Hour))
+		<-ctx.Done()
+		return nil, ctx.Err()
+	}}
+	ex := New(mp, &mockAuthProvider{})
+	ex.SetHeartbeatInterval(0)
+	ex.fetcher = &mockFetcher{fetchFunc: func(ctx context.Context, gctx *github.Context) (*ghdata.FetchResult, error) {
+		return &ghdata.FetchResult{ContextData: ghdata.Issue{Title: "Test Issue", State: "open"}}, nil
+	}}
+	store := taskstore.NewStore()
+	store.Create(&taskstore.Task{ID: "task-1"})
+	ex.SetTaskStore(store)
+
+	gctx := buildTestCtx(false)
+	gctx.TaskID = "task-1"
+	gctx.PreparedCommentID = 42
+	err := ex.Execute(ctx, gctx)
+	if !errors.Is(err, ErrTimedOut) || !strings.Contains(err.Error(), "more than 1h0m0s") {
+		t.Fatalf("Execute() err = %v, want the timeout", err)
+	}
+	if !strings.HasPrefix(comment, "> [!WARNING]\n> This task was stopped and marked failed: task timed out after running for more than 1h0m0s.") ||
+		!strings.HasSuffix(comment, "### Working on it") {
+		t.Fatalf("tracking comment = %q", comment)
+	}
+	task, _ := store.Get("task-1")
+	if task.Status != taskstore.StatusFailed {
+		t.Fatalf("status = %s, want failed", task.Status)
+	}
+}
+
+func TestTimeoutError_KeepsOtherErrors(t *testing.T) {
+	plain := errors.New("boom")
+	if got := timeoutError(context.Background(), plain); got != plain {
+		t.Fatalf("timeoutError = %v", got)
+	}
+	ctx, cancel := context.WithCancel(context.Background())
+	cancel()
+	if got := timeoutError(ctx, plain); got != plain {
+		t.Fatalf("cancelled without a timeout: %v", got)
+	}
+	if timeoutError(ctx, nil) != nil {
+		t.Fatal("nil error wrapped")
+	}
+}
+
+func TestTaskTimeouts_Limit(t *testing.T) {
+	tests := []struct {
+		timeouts  TaskTimeouts
+		requested time.Duration
+		want      time.Duration
+	}{
+		{TaskTimeouts{}, 0, 0},
+		{TaskTimeouts{Default: 30 * time.Minute, Max: 2 * time.Hour}, 0, 30 * time.Minute},
+		{TaskTimeouts{Default: 30 * time.Minute, Max: 2 * time.Hour}, 45 * time.Minute, 45 * time.Minute},
+		{TaskTimeouts{Default: 30 * time.Minute, Max: 2 * time.Hour}, 5 * time.Hour, 2 * time.Hour},
+		{TaskTimeouts{Default: 30 * time.Minute}, 5 * time.Hour, 5 * time.Hour},
+		{TaskTimeouts{Max: time.Hour}, 0, time.Hour},
+	}
+	for _, tt := range tests {
+		if got := tt.timeouts.limit(tt.requested); got != tt.want {
+			t.Errorf("%+v.limit(%v) = %v, want %v", tt.timeouts, tt.requested, got, tt.want)
+		}
+	}
+}
+
+func TestExecute_DeadlineReachesCloneAndProvider(t *testing.T) {
+	origClone, origRun := cloneRepo, runCmd
+	t.Cleanup(func() { cloneRepo, runCmd = origClone, origRun })
+	var cloneDeadline time.Time
+	cloneRepo = func(ctx context.Context, repo, branch, token string) (string, func(), error) {
+		cloneDeadline, _ = ctx.Deadline()
+		return t.TempDir(), func() {}, nil
+	}
+	runCmd = func(name string, args ...string) error { return nil }
+
+	mp := &mockProvider{generateFunc: func(ctx context.Context, req *provider.CodeRequest) (*provider.CodeResponse, error) {
+		<-ctx.Done()
+		return nil, ctx.Err()
+	}}
+	ex := New(mp, &mockAuthProvider{})
+	ex.SetHeartbeatInterval(0)
+	ex.SetTaskTimeouts(TaskTimeouts{Default: time.Hour, Max: 50 * time.Millisecond})
+	ex.fetcher = &mockFetcher{fetchFunc: func(ctx context.Context, gctx *github.Context) (*ghdata.FetchResult, error) {
+		return &ghdata.FetchResult{ContextData: ghdata.Issue{Title: "Test Issue", State: "open"}}, nil
+	}}
+
+	gctx := buildTestCtx(false)
+	gctx.PreparedTimeout = 45 * time.Minute // capped at Max
+	start := time.Now()
+	err := ex.Execute(context.Background(), gctx)
+	if !errors.Is(err, ErrTimedOut) || !strings.Contains(err.Error(), "after 50ms") {
+		t.Fatalf("Execute() err = %v, want the capped timeout", err)
+	}
+	if cloneDeadline.IsZero() || cloneDeadline.After(start.Add(time.Second)) {
+		t.Fatalf("clone deadline = %v, want the task deadline", cloneDeadline)
+	}
+}
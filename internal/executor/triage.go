@@ -0,0 +1,251 @@
+package executor
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"sort"
+	"strings"
+	"unicode"
+
+	"github.com/cexll/swe/internal/audit"
+	"github.com/cexll/swe/internal/github"
+	ghdata "github.com/cexll/swe/internal/github/data"
+	"github.com/cexll/swe/internal/provider"
+)
+
+// allow tests to stub the label and search APIs
+var (
+	listLabels   = github.ListLabels
+	searchIssues = github.SearchIssues
+	addLabels    = github.AddLabels
+)
+
+// triageCandidates bounds the possible duplicates shown to the provider.
+const triageCandidates = 8
+
+// triagePriorities are the priorities a triage may assign, highest first.
+var triagePriorities = []string{"critical", "high", "medium", "low"}
+
+// triageReply is the provider's triage decision.
+type triageReply struct {
+	Labels      []string `json:"labels"`
+	Priority    string   `json:"priority"`
+	DuplicateOf int      `json:"duplicate_of"`
+	Reasoning   string   `json:"reasoning"`
+}
+
+// triageStopWords are left out of the duplicate search.
+var triageStopWords = map[string]bool{
+	"the": true, "and": true, "for": true, "with": true, "that": true, "this": true,
+	"from": true, "into": true, "are": true, "was": true, "not": true, "but": true,
+	"when": true, "can": true, "does": true, "doesn": true, "should": true, "after": true,
+	"bug": true, "issue": true, "error": true, "feature": true, "request": true,
+}
+
+// triageSearchTerms returns the search terms for issues like title: its five
+// longest distinct words, any of which may match.
+func triageSearchTerms(title string) string {
+	seen := make(map[string]bool)
+	var terms []string
+	for _, w := range strings.FieldsFunc(strings.ToLower(title), func(r rune) bool {
+		return !unicode.IsLetter(r) && !unicode.IsDigit(r) && r != '_' && r != '-'
+	}) {
+		if len(w) >= 3 && !triageStopWords[w] && !seen[w] {
+			seen[w] = true
+			terms = append(terms, w)
+		}
+	}
+	// longer words are more specific
+	sort.SliceStable(terms, func(i, j int) bool { return len(terms[i]) > len(terms[j]) })
+	if len(terms) > 5 {
+		terms = terms[:5]
+	}
+	return strings.Join(terms, " OR ")
+}
+
+// triagePrompt asks the provider to triage issue number of repo.
+func triagePrompt(repo string, number int, issue ghdata.Issue, labels []github.Label, candidates []github.IssueSearchResult) string {
+	var b strings.Builder
+	fmt.Fprintf(&b, "Triage issue #%d of %s.\n\n<issue>\nTitle: %s\n\n%s\n</issue>\n\n", number, repo,
+		github.SanitizeContent(issue.Title), github.SanitizeContent(strings.TrimSpace(issue.Body)))
+
+	b.WriteString("<labels>\n")
+	if len(labels) == 0 {
+		b.WriteString("The repository has no labels.\n")
+	}
+	for _, l := range labels {
+		if l.Description != "" {
+			fmt.Fprintf(&b, "- %s: %s\n", l.Name, github.SanitizeContent(l.Description))
+		} else {
+			fmt.Fprintf(&b, "- %s\n", l.Name)
+		}
+	}
+	b.WriteString("</labels>\n\n<possible_duplicates>\n")
+	if len(candidates) == 0 {
+		b.WriteString("The search found no similar issues.\n")
+	}
+	for _, c := range candidates {
+		fmt.Fprintf(&b, "- #%d (%s): %s\n", c.Number, c.State, github.SanitizeContent(c.Title))
+	}
+	b.WriteString("</possible_duplicates>\n\n")
+
+	fmt.Fprintf(&b, `Read the repository as needed to understand what the issue is about, but do not modify files, commit, push, or comment.
+Choose the labels that fit the issue from the list above only, including a priority label if the repository has them. Pick a priority (%s). If one of the possible duplicates reports the same problem, name it; otherwise use 0.
+Reply with only this JSON object:
+{"labels": ["..."], "priority": "...", "duplicate_of": 0, "reasoning": "a few sentences on why, for the maintainers"}`, strings.Join(triagePriorities, ", "))
+	return b.String()
+}
+
+// parseTriageReply reads the JSON object in the provider's reply.
+func parseTriageReply(text string) (triageReply, error) {
+	start, end := strings.Index(text, "{"), strings.LastIndex(text, "}")
+	if start < 0 || end < start {
+		return triageReply{}, fmt.Errorf("no triage decision in the reply")
+	}
+	var reply triageReply
+	if err := json.Unmarshal([]byte(text[start:end+1]), &reply); err != nil {
+		return triageReply{}, fmt.Errorf("decode triage decision: %w", err)
+	}
+	reply.Priority = strings.ToLower(strings.TrimSpace(reply.Priority))
+	known := false
+	for _, p := range triagePriorities {
+		known = known || p == reply.Priority
+	}
+	if !known {
+		reply.Priority = ""
+	}
+	return reply, nil
+}
+
+// executeTriage has the provider triage the issue read-only, applies the
+// labels it chose that exist in the repository and reports the decision on
+// the tracking comment. Nothing is committed or pushed.
+func (e *Executor) executeTriage(ctx context.Context, ghCtx *github.Context, fetched *ghdata.FetchResult, workdir, repo, token string) (summary string, costUSD float64, err error) {
+	defer func() {
+		if err != nil {
+			msg := strings.ReplaceAll(err.Error(), token, "***")
+			e.reportOutcome(ghCtx, fmt.Sprintf("### Triage failed\n\nNo labels were applied.\n\n```\n%s\n```", tail(msg, verifyOutputLimit)))
+		}
+	}()
+	issue, ok := fetched.ContextData.(ghdata.Issue)
+	if !ok {
+		return "", 0, &NonRetryableError{msg: "triage: only issues can be triaged"}
+	}
+	owner, name, number := ghCtx.GetRepositoryOwner(), ghCtx.GetRepositoryName(), ghCtx.GetIssueNumber()
+
+	labels, err := listLabels(owner, name, token)
+	if err != nil {
+		return "", 0, fmt.Errorf("list labels: %w", err)
+	}
+	var candidates []github.IssueSearchResult
+	if terms := triageSearchTerms(issue.Title); terms != "" {
+		found, err := searchIssues(owner, name, terms, triageCandidates+1, token)
+		if err != nil {
+			taskLog(ghCtx, "triage").Warn("Searching for duplicates failed", "number", number, "err", err)
+		}
+		for _, c := range found {
+			if c.Number != number && len(candidates) < triageCandidates {
+				candidates = append(candidates, c)
+			}
+		}
+	}
+
+	var env []string
+	if guard, err := installGitGuard(e.secretRuleSet(), e.blockedPaths); err == nil {
+		defer guard.remove()
+		env = guard.env()
+	}
+	resp, err := e.provider.GenerateCode(ctx, &provider.CodeRequest{
+		Prompt:          triagePrompt(repo, number, issue, labels, candidates),
+		RepoPath:        workdir,
+		Context:         map[string]string{"github_token": token, "repository": repo},
+		AllowedTools:    []string{"Read", "Grep", "Glob", "Bash(git log)", "Bash(git show)"},
+		DisallowedTools: []string{"Edit", "Write", "Bash(git commit)", "Bash(git push)", "Bash(gh issue)", "Bash(gh api)"},
+		Env:             env,
+	})
+	if err != nil {
+		return "", 0, fmt.Errorf("triage: %w", err)
+	}
+	if resp != nil {
+		costUSD = resp.CostUSD
+	}
+	if resp == nil || strings.TrimSpace(resp.Summary) == "" {
+		return "", costUSD, fmt.Errorf("triage: the provider returned no decision")
+	}
+	reply, err := parseTriageReply(resp.Summary)
+	if err != nil {
+		return "", costUSD, fmt.Errorf("triage: %w", err)
+	}
+
+	// Only labels the repository has are applied, under their own names
+	byName := make(map[string]string, len(labels))
+	for _, l := range labels {
+		byName[strings.ToLower(l.Name)] = l.Name
+	}
+	var apply, unknown []string
+	applied := make(map[string]bool)
+	for _, l := range reply.Labels {
+		label, ok := byName[strings.ToLower(strings.TrimSpace(l))]
+		switch {
+		case !ok:
+			unknown = append(unknown, "`"+github.SanitizeContent(l)+"`")
+		case !applied[label]:
+			applied[label] = true
+			apply = append(apply, label)
+		}
+	}
+	var duplicate *github.IssueSearchResult
+	for i := range candidates {
+		if candidates[i].Number == reply.DuplicateOf {
+			duplicate = &candidates[i]
+		}
+	}
+	if label, ok := byName["duplicate"]; ok && duplicate != nil && !applied[label] {
+		apply = append(apply, label)
+	}
+
+	if len(apply) > 0 {
+		if err := addLabels(owner, name, number, apply, token); err != nil {
+			return "", costUSD, fmt.Errorf("apply labels: %w", err)
+		}
+		ev := e.auditEvent(ghCtx, audit.ActionLabelsApplied)
+		ev.Detail = strings.Join(apply, ", ")
+		e.recordAudit(ev)
+	}
+	taskLog(ghCtx, "triage").Info("Issue triaged", "number", number, "labels", apply, "priority", reply.Priority, "duplicate_of", reply.DuplicateOf)
+
+	var b strings.Builder
+	fmt.Fprintf(&b, "### Triaged #%d\n\n", number)
+	if len(apply) > 0 {
+		quoted := make([]string, len(apply))
+		for i, l := range apply {
+			quoted[i] = "`" + l + "`"
+		}
+		fmt.Fprintf(&b, "**Labels applied:** %s\n", strings.Join(quoted, ", "))
+	} else {
+		b.WriteString("**Labels applied:** none\n")
+	}
+	if reply.Priority != "" {
+		fmt.Fprintf(&b, "**Priority:** %s\n", reply.Priority)
+	}
+	if duplicate != nil {
+		fmt.Fprintf(&b, "**Possible duplicate of:** #%d (%s)\n", duplicate.Number, github.SanitizeContent(duplicate.Title))
+	}
+	if len(unknown) > 0 {
+		fmt.Fprintf(&b, "**Suggested, but not labels of this repository:** %s\n", strings.Join(unknown, ", "))
+	}
+	if reasoning := strings.TrimSpace(reply.Reasoning); reasoning != "" {
+		fmt.Fprintf(&b, "\n**Reasoning:** %s\n", github.SanitizeContent(reasoning))
+	}
+	if len(candidates) > 0 {
+		b.WriteString("\n<details><summary>Similar issues checked</summary>\n\n")
+		for _, c := range candidates {
+			fmt.Fprintf(&b, "- #%d (%s) %s\n", c.Number, c.State, github.SanitizeContent(c.Title))
+		}
+		b.WriteString("</details>")
+	}
+	summary = strings.TrimSpace(b.String())
+	e.reportOutcome(ghCtx, summary)
+	return summary, costUSD, nil
+}
@@ -0,0 +1,111 @@
+package executor
+
+import (
+	"context"
+	"strings"
+	"testing"
+
+	"github.com/cexll/swe/internal/audit"
+	"github.com/cexll/swe/internal/github"
+	ghdata "github.com/cexll/swe/internal/github/data"
+	"github.com/cexll/swe/internal/provider"
+)
+
+func TestTriageSearchTerms(t *testing.T) {
+	tests := map[string]string{
+		"Crash when parsing YAML config with anchors": "parsing OR anchors OR config OR crash OR yaml",
+		"Bug: the UI is slow":                         "slow",
+		"":                                            "",
+	}
+	for title, want := range tests {
+		if got := triageSearchTerms(title); got != want {
+			t.Errorf("triageSearchTerms(%q) = %q, want %q", title, got, want)
+		}
+	}
+}
+
+func TestParseTriageReply(t *testing.T) {
+	reply, err := parseTriageReply("Here is my decision:\n```json\n{\"labels\": [\"bug\"], \"priority\": \"High\", \"duplicate_of\": 4, \"reasoning\": \"crash\"}\n```")
+	if err != nil || len(reply.Labels) != 1 || reply.Priority != "high" || reply.DuplicateOf != 4 || reply.Reasoning != "crash" {
+		t.Fatalf("parseTriageReply = %+v, %v", reply, err)
+	}
+	if reply, _ := parseTriageReply(`{"priority": "urgent"}`); reply.Priority != "" {
+		t.Fatalf("unknown priority kept: %+v", reply)
+	}
+	if _, err := parseTriageReply("I could not decide."); err == nil {
+		t.Fatal("a reply without JSON should fail")
+	}
+}
+
+func TestExecute_Triage(t *testing.T) {
+	workdir, _ := initPushRepo(t)
+	updated := stubComments(t, "")
+	origClone, origRun, origList, origSearch, origAdd := cloneRepo, runCmd, listLabels, searchIssues, addLabels
+	t.Cleanup(func() {
+		cloneRepo, runCmd, listLabels, searchIssues, addLabels = origClone, origRun, origList, origSearch, origAdd
+	})
+	cloneRepo = func(_ context.Context, repo, branch, token string) (string, func(), error) {
+		return workdir, func() {}, nil
+	}
+	runCmd = func(name string, args ...string) error { return nil }
+	listLabels = func(_, _, _ string) ([]github.Label, error) {
+		return []github.Label{{Name: "Bug", Description: "Something is broken"}, {Name: "area/config"}, {Name: "P1"}, {Name: "duplicate"}}, nil
+	}
+	var searched string
+	searchIssues = func(_, _, terms string, _ int, _ string) ([]github.IssueSearchResult, error) {
+		searched = terms
+		return []github.IssueSearchResult{{Number: 1, Title: "Crash on YAML anchors", State: "open"}, {Number: 4, Title: "YAML anchors crash the parser", State: "closed"}}, nil
+	}
+	var labeled []string
+	addLabels = func(_, _ string, number int, labels []string, _ string) error {
+		if number != 1 {
+			t.Errorf("labeled issue #%d", number)
+		}
+		labeled = labels
+		return nil
+	}
+
+	var req *provider.CodeRequest
+	e := New(&mockProvider{generateFunc: func(_ context.Context, r *provider.CodeRequest) (*provider.CodeResponse, error) {
+		req = r
+		return &provider.CodeResponse{Summary: `{"labels": ["bug", "area/config", "P1", "crash"], "priority": "high", "duplicate_of": 4, "reasoning": "Same stack trace as #4."}`, CostUSD: 0.01}, nil
+	}}, &mockAuthProvider{})
+	e.fetcher = &mockFetcher{fetchFunc: func(context.Context, *github.Context) (*ghdata.FetchResult, error) {
+		return &ghdata.FetchResult{ContextData: ghdata.Issue{Title: "Crash when parsing YAML config with anchors", Body: "panic: ..."}}, nil
+	}}
+	log, _ := audit.New(audit.Config{})
+	e.SetAuditLog(log)
+
+	ctx := buildTestCtx(false)
+	ctx.PreparedTriage = true
+	ctx.PreparedCommentID = 5
+	if err := e.Execute(context.Background(), ctx); err != nil {
+		t.Fatalf("Execute: %v", err)
+	}
+
+	if searched == "" || !strings.Contains(req.Prompt, "- Bug: Something is broken") || !strings.Contains(req.Prompt, "- #4 (closed): YAML anchors crash the parser") ||
+		strings.Contains(req.Prompt, "- #1 ") {
+		t.Fatalf("search %q, prompt:\n%s", searched, req.Prompt)
+	}
+	if strings.Join(labeled, ",") != "Bug,area/config,P1,duplicate" {
+		t.Fatalf("labels = %v", labeled)
+	}
+	for _, want := range []string{"### Triaged #1", "**Labels applied:** `Bug`, `area/config`, `P1`, `duplicate`", "**Priority:** high",
+		"**Possible duplicate of:** #4 (YAML anchors crash the parser)", "not labels of this repository:** `crash`", "Same stack trace as #4."} {
+		if !strings.Contains(*updated, want) {
+			t.Fatalf("tracking comment lacks %q:\n%s", want, *updated)
+		}
+	}
+	if events := log.List(audit.Filter{Action: audit.ActionLabelsApplied}); len(events) != 1 || events[0].Detail != "Bug, area/config, P1, duplicate" {
+		t.Fatalf("audit events = %+v", events)
+	}
+
+	// a reply without a decision applies nothing and is reported
+	labeled = nil
+	e.provider = &mockProvider{generateFunc: func(context.Context, *provider.CodeRequest) (*provider.CodeResponse, error) {
+		return &provider.CodeResponse{Summary: "Looks like a bug."}, nil
+	}}
+	if err := e.Execute(context.Background(), ctx); err == nil || labeled != nil || !strings.HasPrefix(*updated, "### Triage failed") {
+		t.Fatalf("undecided triage = %v, labels %v, comment %q", err, labeled, *updated)
+	}
+}
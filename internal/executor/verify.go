@@ -0,0 +1,277 @@
+package executor
+
+import (
+	"bytes"
+	"context"
+	"errors"
+	"fmt"
+	"io"
+	"os"
+	"strings"
+	"time"
+
+	"github.com/cexll/swe/internal/artifacts"
+	"github.com/cexll/swe/internal/audit"
+	"github.com/cexll/swe/internal/github"
+	"github.com/cexll/swe/internal/taskstore"
+)
+
+// PostPushCheck inspects the agent branch after the provider pushed it.
+// A non-nil error withdraws the change.
+type PostPushCheck interface {
+	Name() string
+	Check(ctx context.Context, workdir, branch string) error
+}
+
+// SetPostPushChecks configures checks run against the pushed branch (none disables).
+func (e *Executor) SetPostPushChecks(checks ...PostPushCheck) {
+	e.checks = checks
+}
+
+// allow tests to stub comment updates
+var (
+	getComment    = github.GetComment
+	updateComment = github.UpdateComment
+)
+
+// DefaultVerifyTimeout bounds a CommandCheck without an explicit timeout.
+const DefaultVerifyTimeout = 10 * time.Minute
+
+// verifyOutputLimit caps the command output quoted in errors and comments.
+const verifyOutputLimit = 2000
+
+// CommandCheck runs a shell command (e.g. `make test`) in the checked-out
+// branch. GitHub tokens are removed from its environment.
+//
+// For pull requests whose affected packages could be determined,
+// ScopedCommand (when set) runs instead, with the packages in
+// SWE_AFFECTED_GO_PACKAGES, SWE_AFFECTED_PNPM_PACKAGES and
+// SWE_AFFECTED_PNPM_FILTERS; it is skipped when no package is affected.
+type CommandCheck struct {
+	Command       string
+	ScopedCommand string
+	Timeout       time.Duration
+}
+
+func (c CommandCheck) Name() string { return "verify command" }
+
+func (c CommandCheck) Check(ctx context.Context, workdir, _ string) error {
+	timeout := c.Timeout
+	if timeout <= 0 {
+		timeout = DefaultVerifyTimeout
+	}
+	command, env := c.Command, append(scrubbedEnv(), cacheEnvFrom(ctx)...)
+	if scope := packageScopeFrom(ctx); scope != nil && c.ScopedCommand != "" {
+		if scope.empty() {
+			phaseLog("verify").InfoContext(ctx, "No packages affected; skipping verify command")
+			return nil
+		}
+		command, env = c.ScopedCommand, append(env, scope.env()...)
+	}
+	ctx, cancel := context.WithTimeout(ctx, timeout)
+	defer cancel()
+
+	cmd := shellCommand(ctx, command)
+	cmd.Dir = workdir
+	cmd.Env = env
+	// don't wait forever on pipes held open by orphaned children
+	cmd.WaitDelay = 5 * time.Second
+	var out bytes.Buffer
+	var sink io.Writer = &out
+	if w := checkOutputFrom(ctx); w != nil {
+		_, _ = fmt.Fprintf(w, "$ %s\n", command)
+		sink = io.MultiWriter(&out, w)
+	}
+	cmd.Stdout = sink
+	cmd.Stderr = sink
+	err := cmd.Run()
+	if ctx.Err() == context.DeadlineExceeded {
+		return fmt.Errorf("%q timed out after %s", command, timeout)
+	}
+	if err != nil {
+		return &CheckFailure{Command: command, Output: out.String(), Err: err}
+	}
+	return nil
+}
+
+func scrubbedEnv() []string {
+	var env []string
+	for _, kv := range os.Environ() {
+		switch strings.SplitN(kv, "=", 2)[0] {
+		case "GITHUB_TOKEN", "GH_TOKEN", "GITHUB_PERSONAL_ACCESS_TOKEN":
+			continue
+		}
+		env = append(env, kv)
+	}
+	return env
+}
+
+func tail(s string, n int) string {
+	s = strings.TrimSpace(s)
+	if len(s) <= n {
+		return s
+	}
+	return "..." + s[len(s)-n:]
+}
+
+// WithdrawnError reports a pushed change that failed a post-push check and
+// was rolled back. It is not retried.
+type WithdrawnError struct {
+	Branch string
+	Method string // how the change was withdrawn, e.g. "branch deleted"
+	Err    error
+}
+
+func (e *WithdrawnError) Error() string {
+	return fmt.Sprintf("change on %s withdrawn (%s): %v", e.Branch, e.Method, e.Err)
+}
+
+func (e *WithdrawnError) Unwrap() error { return e.Err }
+
+// remoteHead returns the commit origin/<branch> points at, or "" when unknown.
+func remoteHead(workdir, branch string) string {
+	out, err := gitOutput(workdir, "rev-parse", "--verify", "--quiet", "refs/remotes/origin/"+branch)
+	if err != nil {
+		return ""
+	}
+	return strings.TrimSpace(out)
+}
+
+// fetchRemoteHead fetches branch and returns its commit on the remote ("" when
+// it does not exist there).
+func fetchRemoteHead(workdir, branch string) string {
+	refspec := fmt.Sprintf("+refs/heads/%s:refs/remotes/origin/%s", branch, branch)
+	if err := runCmd("git", "-C", workdir, "fetch", "origin", refspec); err != nil {
+		return ""
+	}
+	return remoteHead(workdir, branch)
+}
+
+// verifyPushed runs the post-push checks when the provider pushed new commits
+// to branch, and withdraws the change if any check fails. before is the remote
+// head captured before the provider ran ("" when the branch was new).
+func (e *Executor) verifyPushed(ctx context.Context, ghCtx *github.Context, workdir, branch, base, before string) error {
+	if len(e.checks) == 0 || branch == "" {
+		return nil
+	}
+	refspec := fmt.Sprintf("+refs/heads/%s:refs/remotes/origin/%s", branch, branch)
+	if err := runCmd("git", "-C", workdir, "fetch", "origin", refspec); err != nil {
+		// nothing was pushed (or the remote is unreachable)
+		return nil
+	}
+	pushed := remoteHead(workdir, branch)
+	if pushed == "" || pushed == before {
+		return nil
+	}
+	e.phase(ghCtx, taskstore.PhaseTests)
+
+	// Check exactly what was pushed, not whatever the provider left behind.
+	if err := runCmd("git", "-C", workdir, "checkout", "-f", "--detach", pushed); err != nil {
+		return fmt.Errorf("checkout pushed branch for verification: %w", err)
+	}
+	_ = runCmd("git", "-C", workdir, "clean", "-fdq")
+	if ghCtx.IsPRContext() && wantsScope(e.checks) {
+		ctx = scopeVerification(ctx, workdir, base, pushed)
+	}
+
+	var testOutput bytes.Buffer
+	if e.wantsArtifacts(ghCtx) {
+		ctx = withCheckOutput(ctx, &testOutput)
+	}
+	var failure error
+	for _, check := range e.checks {
+		if err := check.Check(ctx, workdir, branch); err != nil {
+			failure = fmt.Errorf("%s: %w", check.Name(), err)
+			break
+		}
+	}
+	e.saveArtifact(ctx, ghCtx, artifacts.TestOutput, testOutput.Bytes())
+	if failure == nil {
+		return nil
+	}
+
+	var arts *failureArtifacts
+	var cf *CheckFailure
+	if errors.As(failure, &cf) {
+		arts = collectFailureArtifacts(workdir, cf.Output)
+		if e.artifactDir != "" {
+			if err := arts.save(e.artifactDir, ghCtx, workdir, cf.Output); err != nil {
+				taskLog(ghCtx, "verify").Warn("Saving verify artifacts failed", "err", err)
+			}
+		}
+	}
+
+	method, rollbackErr := withdrawBranch(workdir, branch, base, before, pushed)
+	ev := e.auditEvent(ghCtx, audit.ActionBranchWithdrawn)
+	ev.Detail = failure.Error()
+	if rollbackErr != nil {
+		ev.Detail = fmt.Sprintf("%s; rollback failed: %v", failure, rollbackErr)
+	}
+	e.recordAudit(ev)
+	e.reportWithdrawal(ghCtx, branch, method, failure, rollbackErr, arts)
+
+	if rollbackErr != nil {
+		return &NonRetryableError{msg: fmt.Sprintf("post-push check failed on %s (%v) and rollback failed: %v", branch, failure, rollbackErr)}
+	}
+	return &WithdrawnError{Branch: branch, Method: method, Err: failure}
+}
+
+// withdrawBranch deletes a branch the agent created, or pushes a commit
+// restoring the pre-run tree of a branch that already existed.
+func withdrawBranch(workdir, branch, base, before, pushed string) (string, error) {
+	if before == "" && branch != base {
+		if err := runCmd("git", "-C", workdir, "push", "origin", "--delete", branch); err != nil {
+			return "", fmt.Errorf("delete remote branch: %w", err)
+		}
+		return "branch deleted", nil
+	}
+	if before == "" {
+		return "", errors.New("no pre-run commit to restore")
+	}
+
+	msg := fmt.Sprintf("Revert swe-agent changes on %s\n\nPost-push verification failed; restoring %s.", branch, shortSHA(before))
+	out, err := gitOutput(workdir, "commit-tree", before+"^{tree}", "-p", pushed, "-m", msg)
+	if err != nil {
+		return "", fmt.Errorf("create revert commit: %w", err)
+	}
+	revert := strings.TrimSpace(out)
+	if err := runCmd("git", "-C", workdir, "push", "origin", revert+":refs/heads/"+branch); err != nil {
+		return "", fmt.Errorf("push revert commit: %w", err)
+	}
+	return "reverted in " + shortSHA(revert), nil
+}
+
+func shortSHA(sha string) string {
+	if len(sha) > 7 {
+		return sha[:7]
+	}
+	return sha
+}
+
+// reportWithdrawal prepends a notice to the tracking comment so the withdrawn
+// change is not mistaken for a finished one.
+// Structured test failures, when found, replace most of the raw output.
+func (e *Executor) reportWithdrawal(ctx *github.Context, branch, method string, failure, rollbackErr error, arts *failureArtifacts) {
+	if ctx.PreparedCommentID <= 0 || ctx.Token == "" {
+		return
+	}
+	var notice string
+	if rollbackErr != nil {
+		notice = fmt.Sprintf("> [!CAUTION]\n> **Verification failed and automatic rollback did not complete.** "+
+			"Branch `%s` may still contain the failing change; do not merge it. Remove or revert it manually.", branch)
+	} else {
+		notice = fmt.Sprintf("> [!WARNING]\n> **Change withdrawn.** Post-push verification failed, so the change on `%s` "+
+			"was rolled back (%s). The work described below is not in effect.", branch, method)
+	}
+	limit := verifyOutputLimit
+	if summary := arts.summary(ctx.Token); summary != "" {
+		notice += "\n\n" + summary
+		if len(arts.Failures) > 0 {
+			limit = verifyOutputLimit / 4
+		}
+	}
+	notice += "\n\n<details><summary>Verification output</summary>\n\n```\n" +
+		strings.ReplaceAll(tail(failure.Error(), limit), ctx.Token, "***") + "\n```\n</details>"
+
+	prependNotice(ctx, notice)
+}
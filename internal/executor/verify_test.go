@@ -0,0 +1,222 @@
+package executor
+
+import (
+	"context"
+	"errors"
+	"os"
+	"os/exec"
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/cexll/swe/internal/audit"
+)
+
+type stubCheck struct {
+	err   error
+	calls int
+	seen  string // README.md contents when the check ran
+}
+
+func (c *stubCheck) Name() string { return "stub" }
+
+func (c *stubCheck) Check(_ context.Context, workdir, _ string) error {
+	c.calls++
+	data, _ := os.ReadFile(filepath.Join(workdir, "README.md"))
+	c.seen = string(data)
+	return c.err
+}
+
+func gitIn(t *testing.T, dir string, args ...string) string {
+	t.Helper()
+	out, err := exec.Command("git", append([]string{"-C", dir}, args...)...).CombinedOutput()
+	if err != nil {
+		t.Fatalf("git %v: %v\n%s", args, err, out)
+	}
+	return strings.TrimSpace(string(out))
+}
+
+// initPushRepo returns a checkout whose origin is a local bare repository.
+func initPushRepo(t *testing.T) (workdir, remote string) {
+	t.Helper()
+	workdir = initLocalRepo(t)
+	remote = filepath.Join(t.TempDir(), "remote.git")
+	gitIn(t, workdir, "init", "-q", "--bare", "-b", "main", remote)
+	gitIn(t, workdir, "remote", "set-url", "origin", remote)
+	gitIn(t, workdir, "push", "-q", "origin", "main")
+	gitIn(t, workdir, "fetch", "-q", "origin")
+	return workdir, remote
+}
+
+// commitAndPush simulates the provider committing README.md and pushing branch.
+func commitAndPush(t *testing.T, workdir, branch, content string) string {
+	t.Helper()
+	if err := os.WriteFile(filepath.Join(workdir, "README.md"), []byte(content), 0o644); err != nil {
+		t.Fatal(err)
+	}
+	gitIn(t, workdir, "commit", "-q", "-am", "agent change")
+	gitIn(t, workdir, "push", "-q", "origin", "HEAD:refs/heads/"+branch)
+	return gitIn(t, workdir, "rev-parse", "HEAD")
+}
+
+func stubComments(t *testing.T, existing string) *string {
+	t.Helper()
+	origGet, origUpdate := getComment, updateComment
+	t.Cleanup(func() { getComment, updateComment = origGet, origUpdate })
+	var updated string
+	getComment = func(_, _ string, _ int64, _ string) (string, error) { return existing, nil }
+	updateComment = func(_, _ string, _ int64, body, _ string) error {
+		updated = body
+		return nil
+	}
+	return &updated
+}
+
+func newVerifyExecutor(t *testing.T, checks ...PostPushCheck) (*Executor, *audit.Log) {
+	t.Helper()
+	log, _ := audit.New(audit.Config{})
+	e := New(&mockProvider{name: "mock"}, &mockAuthProvider{})
+	e.SetAuditLog(log)
+	e.SetPostPushChecks(checks...)
+	return e, log
+}
+
+func TestVerifyPushed_DeletesNewBranchOnFailure(t *testing.T) {
+	workdir, remote := initPushRepo(t)
+	updated := stubComments(t, "Implemented the feature in `swe-agent/1-1`.")
+	check := &stubCheck{err: errors.New("tests failed")}
+	e, log := newVerifyExecutor(t, check)
+
+	gitIn(t, workdir, "checkout", "-q", "-b", "swe-agent/1-1")
+	before := remoteHead(workdir, "swe-agent/1-1")
+	commitAndPush(t, workdir, "swe-agent/1-1", "broken\n")
+	// the provider may leave uncommitted junk behind; checks see the pushed tree
+	_ = os.WriteFile(filepath.Join(workdir, "README.md"), []byte("dirty\n"), 0o644)
+
+	ctx := buildTestCtx(false)
+	ctx.Token = "tok"
+	ctx.PreparedCommentID = 99
+	ctx.PreparedBranch = "swe-agent/1-1"
+	err := e.verifyPushed(context.Background(), ctx, workdir, "swe-agent/1-1", "main", before)
+
+	var withdrawn *WithdrawnError
+	if !errors.As(err, &withdrawn) || withdrawn.Method != "branch deleted" || !IsNonRetryable(err) {
+		t.Fatalf("expected non-retryable WithdrawnError, got %v", err)
+	}
+	if check.calls != 1 || check.seen != "broken\n" {
+		t.Fatalf("check calls = %d, saw %q", check.calls, check.seen)
+	}
+	if heads := gitIn(t, remote, "branch", "--list", "swe-agent/*"); heads != "" {
+		t.Fatalf("agent branch still on remote: %q", heads)
+	}
+	if !strings.HasPrefix(*updated, "> [!WARNING]\n> **Change withdrawn.**") ||
+		!strings.Contains(*updated, "tests failed") ||
+		!strings.HasSuffix(*updated, "---\n\nImplemented the feature in `swe-agent/1-1`.") {
+		t.Fatalf("unexpected comment:\n%s", *updated)
+	}
+	events := log.List(audit.Filter{Action: audit.ActionBranchWithdrawn})
+	if len(events) != 1 || events[0].Branch != "swe-agent/1-1" || !strings.Contains(events[0].Detail, "tests failed") {
+		t.Fatalf("audit events = %+v", events)
+	}
+}
+
+func TestVerifyPushed_RevertsExistingBranch(t *testing.T) {
+	workdir, remote := initPushRepo(t)
+	stubComments(t, "")
+	e, _ := newVerifyExecutor(t, &stubCheck{err: errors.New("lint failed")})
+
+	gitIn(t, workdir, "checkout", "-q", "-b", "feature")
+	original := commitAndPush(t, workdir, "feature", "reviewed work\n")
+	gitIn(t, workdir, "fetch", "-q", "origin")
+	before := remoteHead(workdir, "feature")
+	if before != original {
+		t.Fatalf("remote head = %q, want %q", before, original)
+	}
+	pushed := commitAndPush(t, workdir, "feature", "agent breakage\n")
+
+	err := e.verifyPushed(context.Background(), buildTestCtx(true), workdir, "feature", "main", before)
+	var withdrawn *WithdrawnError
+	if !errors.As(err, &withdrawn) || !strings.HasPrefix(withdrawn.Method, "reverted in ") {
+		t.Fatalf("expected revert, got %v", err)
+	}
+
+	head := gitIn(t, remote, "rev-parse", "feature")
+	if parent := gitIn(t, remote, "rev-parse", head+"^"); parent != pushed {
+		t.Fatalf("revert parent = %s, want pushed commit %s", parent, pushed)
+	}
+	if got, want := gitIn(t, remote, "rev-parse", head+"^{tree}"), gitIn(t, remote, "rev-parse", original+"^{tree}"); got != want {
+		t.Fatalf("reverted tree = %s, want pre-run tree %s", got, want)
+	}
+}
+
+func TestVerifyPushed_PassingOrNothingPushed(t *testing.T) {
+	workdir, remote := initPushRepo(t)
+	updated := stubComments(t, "")
+	check := &stubCheck{}
+	e, _ := newVerifyExecutor(t, check)
+
+	// nothing pushed: the check is skipped
+	if err := e.verifyPushed(context.Background(), buildTestCtx(false), workdir, "swe-agent/2-2", "main", ""); err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	if check.calls != 0 {
+		t.Fatalf("check ran without a push")
+	}
+
+	gitIn(t, workdir, "checkout", "-q", "-b", "swe-agent/2-2")
+	commitAndPush(t, workdir, "swe-agent/2-2", "good\n")
+	if err := e.verifyPushed(context.Background(), buildTestCtx(false), workdir, "swe-agent/2-2", "main", ""); err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	if check.calls != 1 || *updated != "" {
+		t.Fatalf("calls = %d, comment = %q", check.calls, *updated)
+	}
+	if gitIn(t, remote, "branch", "--list", "swe-agent/2-2") == "" {
+		t.Fatal("passing branch should remain")
+	}
+
+	// without checks nothing runs at all
+	e.SetPostPushChecks()
+	if err := e.verifyPushed(context.Background(), buildTestCtx(false), workdir, "swe-agent/2-2", "main", ""); err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+}
+
+func TestVerifyPushed_NeverDeletesBaseBranch(t *testing.T) {
+	workdir, remote := initPushRepo(t)
+	updated := stubComments(t, "")
+	e, _ := newVerifyExecutor(t, &stubCheck{err: errors.New("boom")})
+
+	commitAndPush(t, workdir, "main", "pushed to main\n")
+	ctx := buildTestCtx(false)
+	ctx.Token = "tok"
+	ctx.PreparedCommentID = 5
+	err := e.verifyPushed(context.Background(), ctx, workdir, "main", "main", "")
+	if err == nil || !IsNonRetryable(err) || !strings.Contains(err.Error(), "rollback failed") {
+		t.Fatalf("expected rollback failure, got %v", err)
+	}
+	if gitIn(t, remote, "branch", "--list", "main") == "" {
+		t.Fatal("base branch must never be deleted")
+	}
+	if !strings.Contains(*updated, "automatic rollback did not complete") {
+		t.Fatalf("comment should warn about failed rollback:\n%s", *updated)
+	}
+}
+
+func TestCommandCheck(t *testing.T) {
+	dir := t.TempDir()
+	t.Setenv("GH_TOKEN", "secret-token")
+
+	if err := (CommandCheck{Command: "test -z \"$GH_TOKEN\""}).Check(context.Background(), dir, "b"); err != nil {
+		t.Fatalf("tokens should be scrubbed from the environment: %v", err)
+	}
+	err := (CommandCheck{Command: "echo boom; exit 3"}).Check(context.Background(), dir, "b")
+	if err == nil || !strings.Contains(err.Error(), "boom") {
+		t.Fatalf("expected failure with output, got %v", err)
+	}
+	err = (CommandCheck{Command: "exec sleep 5", Timeout: 50 * time.Millisecond}).Check(context.Background(), dir, "b")
+	if err == nil || !strings.Contains(err.Error(), "timed out") {
+		t.Fatalf("expected timeout, got %v", err)
+	}
+}
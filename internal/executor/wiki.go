@@ -0,0 +1,107 @@
+package executor
+
+import (
+	"fmt"
+	"os"
+	"os/exec"
+	"path/filepath"
+	"regexp"
+	"strings"
+
+	"github.com/cexll/swe/internal/audit"
+	"github.com/cexll/swe/internal/github"
+)
+
+// WikiDir is where the repository wiki is checked out, relative to the
+// repository working tree. It is excluded from the main repository.
+const WikiDir = ".wiki"
+
+// wikiMention matches trigger comments that ask for wiki changes.
+var wikiMention = regexp.MustCompile(`(?i)\bwiki\b`)
+
+// allow tests to stub wiki cloning
+var cloneWiki = func(url, dir string) error {
+	return runCmd("git", "clone", "-q", "--depth", "1", url, dir)
+}
+
+// SetWikiEditing lets tasks whose trigger mentions the wiki edit and push
+// the repository's wiki (repo.wiki.git) alongside the main repository.
+func (e *Executor) SetWikiEditing(enabled bool) {
+	e.mu.Lock()
+	defer e.mu.Unlock()
+	e.wiki = enabled
+}
+
+// wantsWiki reports whether the trigger comment asks for wiki changes.
+func wantsWiki(ctx *github.Context) bool {
+	return wikiMention.MatchString(ctx.GetTriggerCommentBody())
+}
+
+// prepareWiki checks out the wiki of repo into workdir/WikiDir and returns
+// the prompt section describing it and the wiki's remote head. The wiki git
+// repository only exists once a first page has been created, so a failed
+// clone is returned as an error for the caller to log, not fail on.
+func prepareWiki(workdir, repo, token string) (section, head string, err error) {
+	dir := filepath.Join(workdir, WikiDir)
+	url := fmt.Sprintf("https://x-access-token:%s@github.com/%s.wiki.git", token, repo)
+	if err := cloneWiki(url, dir); err != nil {
+		return "", "", fmt.Errorf("clone wiki: %s", strings.ReplaceAll(err.Error(), token, "***"))
+	}
+	if err := excludeFromRepo(workdir, "/"+WikiDir+"/"); err != nil {
+		return "", "", err
+	}
+	head = wikiRemoteHead(dir)
+
+	section = fmt.Sprintf(`<wiki>
+The GitHub wiki of %[1]s is checked out in the %[2]s directory (its own git repository).
+To update wiki pages, edit or add Markdown files there (the file name is the page title, e.g. "Getting-Started.md"), then commit and push from inside it: cd %[2]s && git add -A && git commit -m "..." && git push origin HEAD.
+Wiki changes are live as soon as they are pushed; there is no pull request. Never copy %[2]s into the main repository.
+</wiki>`, repo, WikiDir)
+	return section, head, nil
+}
+
+// excludeFromRepo adds pattern to the repository's local exclude file.
+func excludeFromRepo(workdir, pattern string) error {
+	path, err := gitPath(workdir, "info/exclude")
+	if err != nil {
+		return fmt.Errorf("exclude %s: %w", pattern, err)
+	}
+	if err := os.MkdirAll(filepath.Dir(path), 0o755); err != nil {
+		return fmt.Errorf("exclude %s: %w", pattern, err)
+	}
+	f, err := os.OpenFile(path, os.O_APPEND|os.O_CREATE|os.O_WRONLY, 0o644)
+	if err != nil {
+		return fmt.Errorf("exclude %s: %w", pattern, err)
+	}
+	defer func() { _ = f.Close() }()
+	if _, err := fmt.Fprintln(f, pattern); err != nil {
+		return fmt.Errorf("exclude %s: %w", pattern, err)
+	}
+	return nil
+}
+
+// wikiRemoteHead returns the commit the wiki remote's HEAD points to, or "".
+func wikiRemoteHead(dir string) string {
+	out, err := exec.Command("git", "-C", dir, "ls-remote", "origin", "HEAD").Output()
+	if err != nil {
+		return ""
+	}
+	fields := strings.Fields(string(out))
+	if len(fields) == 0 {
+		return ""
+	}
+	return fields[0]
+}
+
+// recordWikiPush records a wiki_updated event when the provider pushed to
+// the wiki during the run.
+func (e *Executor) recordWikiPush(ctx *github.Context, workdir, before string) {
+	after := wikiRemoteHead(filepath.Join(workdir, WikiDir))
+	if after == "" || after == before {
+		return
+	}
+	taskLog(ctx, "wiki").Info("Wiki updated", "sha", after)
+	ev := e.auditEvent(ctx, audit.ActionWikiUpdated)
+	ev.Detail = "wiki head " + after
+	e.recordAudit(ev)
+}
@@ -0,0 +1,254 @@
+package executor
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"os"
+	"path/filepath"
+	"strings"
+	"sync"
+
+	"github.com/cexll/swe/internal/filelock"
+	"github.com/cexll/swe/internal/github"
+)
+
+// WorkspaceConfig places and bounds the checkouts tasks work in.
+type WorkspaceConfig struct {
+	// Dir holds the checkouts and nothing else. Each process checks out
+	// into a subdirectory of its own, locked while it runs: what is left in
+	// Dir by a process that is gone is removed, except the mirrors of
+	// Worktrees. "" uses swe-workspaces in the system temp directory.
+	Dir string
+	// MaxBytes bounds the checkouts together; a task does not clone
+	// while they are past it (0: no limit)
+	MaxBytes int64
+	// Worktrees checks tasks out as git worktrees of a mirror of each
+	// repository kept in Dir, instead of cloning for every task: a task
+	// fetches only what changed, and still gets its own working tree,
+	// index and HEAD
+	Worktrees bool
+}
+
+// WorkspaceUsage is a point-in-time view of the checkouts on disk.
+type WorkspaceUsage struct {
+	Dir        string `json:"dir"`
+	Workspaces int    `json:"workspaces"`
+	Bytes      int64  `json:"bytes"`
+	MaxBytes   int64  `json:"max_bytes,omitempty"`
+}
+
+// ErrWorkspaceQuota fails a task that would clone while the checkouts are
+// past WorkspaceConfig.MaxBytes; the dispatcher retries it later.
+var ErrWorkspaceQuota = errors.New("workspace disk quota exceeded")
+
+// SetWorkspaces configures where subsequent tasks clone and how much disk
+// their checkouts may use.
+func (e *Executor) SetWorkspaces(c WorkspaceConfig) error {
+	return e.workspaces.configure(c)
+}
+
+// RemoveStaleWorkspaces removes what is in the workspace directory but not
+// in use, such as the checkouts of tasks cut short by a crash. The checkouts
+// of another running process sharing the directory, such as a version that
+// drains after handing over its port, are in use. It returns how many were
+// removed.
+func (e *Executor) RemoveStaleWorkspaces() (int, error) {
+	return e.workspaces.removeStale()
+}
+
+// WorkspaceUsage reports the checkouts of running tasks (and of dry runs
+// waiting to be applied) and the disk they use.
+func (e *Executor) WorkspaceUsage() WorkspaceUsage {
+	return e.workspaces.usage()
+}
+
+// runPrefix starts the name of the subdirectory of the workspace directory
+// a process checks out into; "<name>.lock" is locked while it runs.
+const runPrefix = "run-"
+
+// workspaces tracks every checkout from its clone until it is removed, so
+// that none outlives its task and their disk usage can be bounded.
+type workspaces struct {
+	mu      sync.Mutex
+	config  WorkspaceConfig
+	run     string         // this process's subdirectory of config.Dir
+	lock    *filelock.Lock // held on run + ".lock"
+	dirs    map[string]bool
+	mirrors map[string]*sync.Mutex
+}
+
+func newWorkspaces() *workspaces {
+	return &workspaces{dirs: make(map[string]bool), mirrors: make(map[string]*sync.Mutex)}
+}
+
+func (w *workspaces) configure(c WorkspaceConfig) error {
+	if c.Dir == "" {
+		c.Dir = filepath.Join(os.TempDir(), "swe-workspaces")
+	}
+	c.Dir = filepath.Clean(c.Dir)
+	if err := os.MkdirAll(c.Dir, 0o755); err != nil {
+		return fmt.Errorf("create workspace dir: %w", err)
+	}
+	w.mu.Lock()
+	defer w.mu.Unlock()
+	if w.run == "" || filepath.Dir(w.run) != c.Dir {
+		run, lock, err := newRunDir(c.Dir)
+		if err != nil {
+			return err
+		}
+		_ = w.lock.Close()
+		w.run, w.lock = run, lock
+	}
+	w.config = c
+	github.SetCloneDir(w.run)
+	return nil
+}
+
+// newRunDir creates and locks a subdirectory of root for the checkouts of
+// this process.
+func newRunDir(root string) (string, *filelock.Lock, error) {
+	run, err := os.MkdirTemp(root, runPrefix)
+	if err != nil {
+		return "", nil, fmt.Errorf("create workspace dir: %w", err)
+	}
+	lock, err := filelock.TryLock(run + ".lock")
+	if err != nil {
+		_ = os.Remove(run)
+		return "", nil, fmt.Errorf("lock workspace dir: %w", err)
+	}
+	return run, lock, nil
+}
+
+// clone checks out repo at branch into a tracked workspace. The returned
+// cleanup removes it; it is safe to call more than once.
+func (w *workspaces) clone(ctx context.Context, repo, branch, token string) (string, func(), error) {
+	w.mu.Lock()
+	config, run := w.config, w.run
+	used := w.sizeLocked()
+	w.mu.Unlock()
+	if limit := config.MaxBytes; limit > 0 && used >= limit {
+		return "", nil, fmt.Errorf("%w: checkouts use %d MB of %d MB", ErrWorkspaceQuota, used>>20, limit>>20)
+	}
+
+	var workdir string
+	var remove func()
+	var err error
+	if config.Worktrees && config.Dir != "" {
+		workdir, remove, err = w.addWorktree(ctx, config.Dir, run, repo, branch, token)
+	} else {
+		workdir, remove, err = cloneRepo(ctx, repo, branch, token)
+	}
+	if err != nil {
+		return "", nil, err
+	}
+	w.mu.Lock()
+	w.dirs[workdir] = true
+	owned := w.run != "" && filepath.Dir(workdir) == w.run
+	w.mu.Unlock()
+
+	var once sync.Once
+	cleanup := func() {
+		once.Do(func() {
+			remove()
+			// the Go module cache of a task leaves read-only directories
+			// that a plain removal fails on
+			if _, err := os.Lstat(workdir); err == nil && owned {
+				if err := removeCacheDir(workdir); err != nil {
+					phaseLog("workspace").WarnContext(ctx, "Removing workspace failed", "dir", workdir, "err", err)
+				}
+			}
+			w.mu.Lock()
+			delete(w.dirs, workdir)
+			w.mu.Unlock()
+		})
+	}
+	return workdir, cleanup, nil
+}
+
+// sizeLocked is the disk used by the tracked checkouts and the mirrors
+// their worktrees share.
+func (w *workspaces) sizeLocked() int64 {
+	var size int64
+	if w.config.Dir != "" {
+		size += dirSize(filepath.Join(w.config.Dir, mirrorsDir))
+	}
+	for dir := range w.dirs {
+		size += dirSize(dir)
+	}
+	return size
+}
+
+func (w *workspaces) usage() WorkspaceUsage {
+	w.mu.Lock()
+	defer w.mu.Unlock()
+	return WorkspaceUsage{
+		Dir:        w.config.Dir,
+		Workspaces: len(w.dirs),
+		Bytes:      w.sizeLocked(),
+		MaxBytes:   w.config.MaxBytes,
+	}
+}
+
+func (w *workspaces) removeStale() (int, error) {
+	w.mu.Lock()
+	defer w.mu.Unlock()
+	if w.config.Dir == "" {
+		return 0, nil
+	}
+	entries, err := os.ReadDir(w.config.Dir)
+	if err != nil {
+		return 0, fmt.Errorf("read workspace dir: %w", err)
+	}
+	var failed []string
+	removed := 0
+	for _, entry := range entries {
+		name := entry.Name()
+		dir := filepath.Join(w.config.Dir, name)
+		if w.dirs[dir] || name == mirrorsDir || dir == w.run || dir == w.run+".lock" {
+			continue
+		}
+		if strings.HasPrefix(name, runPrefix) {
+			ok, err := removeRunDir(strings.TrimSuffix(dir, ".lock"))
+			if err != nil {
+				failed = append(failed, name)
+			} else if ok && !strings.HasSuffix(name, ".lock") {
+				removed++
+			}
+			continue
+		}
+		// checked out by a version without run directories
+		if err := removeCacheDir(dir); err != nil {
+			failed = append(failed, name)
+			continue
+		}
+		removed++
+	}
+	pruneMirrors(w.config.Dir)
+	if len(failed) > 0 {
+		return removed, fmt.Errorf("remove stale workspaces: %v", failed)
+	}
+	return removed, nil
+}
+
+// removeRunDir removes the subdirectory run of another process, and its
+// lock file, once that process is gone: its lock can be taken. It reports
+// whether they were removed.
+func removeRunDir(run string) (bool, error) {
+	lock, err := filelock.TryLock(run + ".lock")
+	if errors.Is(err, filelock.ErrLocked) {
+		return false, nil
+	}
+	if err != nil {
+		return false, err
+	}
+	err = removeCacheDir(run)
+	_ = lock.Close()
+	if err != nil {
+		return false, err
+	}
+	if err := os.Remove(run + ".lock"); err != nil && !os.IsNotExist(err) {
+		return false, err
+	}
+	return true, nil
+}
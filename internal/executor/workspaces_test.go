@@ -0,0 +1,121 @@
+package executor
+
+import (
+	"context"
+	"errors"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"github.com/cexll/swe/internal/filelock"
+	"github.com/cexll/swe/internal/github"
+)
+
+func TestWorkspaces_QuotaAndCleanup(t *testing.T) {
+	origClone := cloneRepo
+	t.Cleanup(func() {
+		cloneRepo = origClone
+		github.SetCloneDir("")
+	})
+	root := filepath.Join(t.TempDir(), "workspaces")
+	w := newWorkspaces()
+	if err := w.configure(WorkspaceConfig{Dir: root, MaxBytes: 1024}); err != nil {
+		t.Fatal(err)
+	}
+
+	// left behind by a crash
+	writeFiles(t, filepath.Join(root, "owner-repo-issue-1-1"), map[string]string{"README.md": "stale"})
+	if n, err := w.removeStale(); err != nil || n != 1 {
+		t.Fatalf("removeStale = %d, %v", n, err)
+	}
+
+	// the clone's own cleanup fails on the read-only module cache
+	clones := 0
+	cloneRepo = func(_ context.Context, repo, branch, token string) (string, func(), error) {
+		clones++
+		dir := filepath.Join(w.run, "owner-repo-branch-main-"+strings.Repeat("x", clones))
+		writeFiles(t, dir, map[string]string{
+			"big.bin":                  strings.Repeat("a", 2048),
+			"gomod/example.com/m/x.go": "package x",
+		})
+		if err := os.Chmod(filepath.Join(dir, "gomod", "example.com"), 0o555); err != nil {
+			t.Fatal(err)
+		}
+		return dir, func() { _ = os.RemoveAll(dir) }, nil
+	}
+	workdir, cleanup, err := w.clone(context.Background(), "owner/repo", "main", "token")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if u := w.usage(); u.Dir != root || u.Workspaces != 1 || u.Bytes < 2048 || u.MaxBytes != 1024 {
+		t.Fatalf("usage = %+v", u)
+	}
+	// in use checkouts are not stale
+	if n, _ := w.removeStale(); n != 0 {
+		t.Fatalf("removed %d checkouts in use", n)
+	}
+
+	if _, _, err := w.clone(context.Background(), "owner/repo", "main", "token"); !errors.Is(err, ErrWorkspaceQuota) {
+		t.Fatalf("clone past the quota = %v", err)
+	}
+	if clones != 1 {
+		t.Fatalf("cloned %d times past the quota", clones)
+	}
+
+	cleanup()
+	cleanup()
+	if _, err := os.Stat(workdir); !os.IsNotExist(err) {
+		t.Fatalf("workspace left behind: %v", err)
+	}
+	if u := w.usage(); u.Workspaces != 0 || u.Bytes != 0 {
+		t.Fatalf("usage after cleanup = %+v", u)
+	}
+	if _, cleanup, err := w.clone(context.Background(), "owner/repo", "main", "token"); err != nil {
+		t.Fatalf("clone under the quota: %v", err)
+	} else {
+		cleanup()
+	}
+}
+
+func TestWorkspaces_KeepsAnotherRunningProcesssCheckouts(t *testing.T) {
+	t.Cleanup(func() { github.SetCloneDir("") })
+	root := filepath.Join(t.TempDir(), "workspaces")
+	w := newWorkspaces()
+	if err := w.configure(WorkspaceConfig{Dir: root}); err != nil {
+		t.Fatal(err)
+	}
+	t.Cleanup(func() { _ = w.lock.Close() })
+
+	// draining after a port handoff, and gone
+	draining := filepath.Join(root, runPrefix+"1")
+	writeFiles(t, filepath.Join(draining, "owner-repo-1"), map[string]string{"README.md": "in use"})
+	lock, err := filelock.TryLock(draining + ".lock")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer lock.Close()
+	gone := filepath.Join(root, runPrefix+"2")
+	writeFiles(t, filepath.Join(gone, "owner-repo-2"), map[string]string{"README.md": "stale"})
+	writeFiles(t, root, map[string]string{runPrefix + "2.lock": "", runPrefix + "3.lock": ""})
+
+	if n, err := w.removeStale(); err != nil || n != 1 {
+		t.Fatalf("removeStale = %d, %v", n, err)
+	}
+	if _, err := os.Stat(filepath.Join(draining, "owner-repo-1")); err != nil {
+		t.Fatalf("removed a running process's checkout: %v", err)
+	}
+	for _, name := range []string{runPrefix + "2", runPrefix + "2.lock", runPrefix + "3.lock"} {
+		if _, err := os.Stat(filepath.Join(root, name)); !os.IsNotExist(err) {
+			t.Errorf("%s left behind: %v", name, err)
+		}
+	}
+	if _, err := os.Stat(w.run); err != nil {
+		t.Fatalf("removed its own run directory: %v", err)
+	}
+
+	lock.Close()
+	if n, err := w.removeStale(); err != nil || n != 1 {
+		t.Fatalf("removeStale after the drain = %d, %v", n, err)
+	}
+}
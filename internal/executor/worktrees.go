@@ -0,0 +1,173 @@
+package executor
+
+import (
+	"context"
+	"encoding/base64"
+	"fmt"
+	"os"
+	"os/exec"
+	"path/filepath"
+	"strings"
+	"sync"
+)
+
+// mirrorsDir holds, in the workspace directory, the mirror of each
+// repository that worktree checkouts are added from.
+const mirrorsDir = ".mirrors"
+
+// mirrorURL is where the mirror of repo fetches from.
+var mirrorURL = func(repo string) string {
+	return "https://github.com/" + repo + ".git"
+}
+
+// mirrorLock serializes the updates of one mirror: fetching into it and
+// adding or removing its worktrees.
+func (w *workspaces) mirrorLock(mirror string) *sync.Mutex {
+	w.mu.Lock()
+	defer w.mu.Unlock()
+	mu := w.mirrors[mirror]
+	if mu == nil {
+		mu = new(sync.Mutex)
+		w.mirrors[mirror] = mu
+	}
+	return mu
+}
+
+// addWorktree checks repo out at branch into dir as a worktree of its
+// mirror in root, creating the mirror the first time and otherwise fetching
+// only what changed on branch. The worktree starts detached, so tasks on the
+// same base do not contend for its branch, and has its own index, HEAD and
+// hooks. The returned cleanup removes it with the branch it was left on.
+func (w *workspaces) addWorktree(ctx context.Context, root, dir, repo, branch, token string) (string, func(), error) {
+	mirror := filepath.Join(root, mirrorsDir, filepath.FromSlash(repo)+".git")
+	mu := w.mirrorLock(mirror)
+	mu.Lock()
+	defer mu.Unlock()
+
+	if _, err := os.Stat(filepath.Join(mirror, "HEAD")); err != nil {
+		if err := initMirror(mirror, repo); err != nil {
+			_ = os.RemoveAll(mirror)
+			return "", nil, fmt.Errorf("create mirror of %s: %w", repo, err)
+		}
+	}
+	ref := "refs/remotes/origin/" + branch
+	if err := fetchMirror(ctx, mirror, repo, token, "+refs/heads/"+branch+":"+ref); err != nil {
+		return "", nil, fmt.Errorf("update mirror of %s: %w", repo, err)
+	}
+
+	dir, err := os.MkdirTemp(dir, strings.ReplaceAll(repo, "/", "-")+"-")
+	if err != nil {
+		return "", nil, fmt.Errorf("create worktree: %w", err)
+	}
+	if _, err := gitOutput(mirror, "worktree", "add", "-q", "--detach", dir, ref); err != nil {
+		_ = os.RemoveAll(dir)
+		_, _ = gitOutput(mirror, "worktree", "prune")
+		return "", nil, fmt.Errorf("create worktree: %w", err)
+	}
+	// hooks are shared by the worktrees unless each points at its own
+	gitDir, err := gitOutput(dir, "rev-parse", "--absolute-git-dir")
+	if err == nil {
+		_, err = gitOutput(dir, "config", "--worktree", "core.hooksPath", filepath.Join(strings.TrimSpace(gitDir), "hooks"))
+	}
+	if err != nil {
+		removeWorktree(mirror, dir)
+		return "", nil, fmt.Errorf("create worktree: %w", err)
+	}
+
+	return dir, func() {
+		mu.Lock()
+		defer mu.Unlock()
+		removeWorktree(mirror, dir)
+		// the last task's remote URL holds its installation token
+		if out, err := gitOutput(mirror, "worktree", "list", "--porcelain"); err == nil && strings.Count(out, "worktree ") == 1 {
+			_, _ = gitOutput(mirror, "remote", "set-url", "origin", mirrorURL(repo))
+		}
+	}, nil
+}
+
+// initMirror creates the bare mirror of repo. Remote branches go to
+// refs/remotes/origin, leaving refs/heads to the worktrees' own branches.
+func initMirror(mirror, repo string) error {
+	if err := os.MkdirAll(mirror, 0o755); err != nil {
+		return err
+	}
+	for _, args := range [][]string{
+		{"init", "-q", "--bare"},
+		// per-worktree settings need worktreeConfig, which in turn needs
+		// core.bare out of the shared configuration
+		{"config", "core.repositoryformatversion", "1"},
+		{"config", "extensions.worktreeConfig", "true"},
+		{"config", "--unset", "core.bare"},
+		{"config", "--worktree", "core.bare", "true"},
+		{"remote", "add", "origin", mirrorURL(repo)},
+	} {
+		if _, err := gitOutput(mirror, args...); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// fetchMirror fetches refspec into mirror. The token goes in a header set
+// through the environment, so it is neither on the command line nor saved
+// in the mirror's configuration.
+func fetchMirror(ctx context.Context, mirror, repo, token, refspec string) error {
+	cmd := exec.CommandContext(ctx, "git", "-C", mirror, "fetch", "-q", mirrorURL(repo), refspec)
+	cmd.Env = append(os.Environ(), "GIT_TERMINAL_PROMPT=0")
+	if token != "" {
+		auth := base64.StdEncoding.EncodeToString([]byte("x-access-token:" + token))
+		cmd.Env = append(cmd.Env,
+			"GIT_CONFIG_COUNT=1",
+			"GIT_CONFIG_KEY_0=http.extraHeader",
+			"GIT_CONFIG_VALUE_0=Authorization: basic "+auth,
+		)
+	}
+	if out, err := cmd.CombinedOutput(); err != nil {
+		return fmt.Errorf("git fetch: %w: %s", err, strings.TrimSpace(string(out)))
+	}
+	return nil
+}
+
+// removeWorktree removes the worktree at dir from mirror, and the branch it
+// was on, which no other worktree can have checked out.
+func removeWorktree(mirror, dir string) {
+	branch, _ := gitOutput(dir, "symbolic-ref", "-q", "--short", "HEAD")
+	if err := removeCacheDir(dir); err != nil {
+		phaseLog("workspace").Warn("Removing worktree failed", "dir", dir, "err", err)
+	}
+	_, _ = gitOutput(mirror, "worktree", "prune")
+	if branch = strings.TrimSpace(branch); branch != "" {
+		_, _ = gitOutput(mirror, "branch", "-D", branch)
+	}
+}
+
+// pruneMirrors drops from the mirrors in root the worktrees that no longer
+// exist, and the branches only they had checked out.
+func pruneMirrors(root string) {
+	mirrors, _ := filepath.Glob(filepath.Join(root, mirrorsDir, "*", "*.git"))
+	for _, mirror := range mirrors {
+		if _, err := gitOutput(mirror, "worktree", "prune"); err != nil {
+			phaseLog("workspace").Warn("Pruning mirror failed", "mirror", mirror, "err", err)
+			continue
+		}
+		list, err := gitOutput(mirror, "worktree", "list", "--porcelain")
+		if err != nil {
+			continue
+		}
+		checkedOut := make(map[string]bool)
+		for _, line := range strings.Split(list, "\n") {
+			if ref, ok := strings.CutPrefix(line, "branch "); ok {
+				checkedOut[ref] = true
+			}
+		}
+		refs, err := gitOutput(mirror, "for-each-ref", "--format=%(refname)", "refs/heads")
+		if err != nil {
+			continue
+		}
+		for _, ref := range strings.Fields(refs) {
+			if !checkedOut[ref] {
+				_, _ = gitOutput(mirror, "update-ref", "-d", ref)
+			}
+		}
+	}
+}
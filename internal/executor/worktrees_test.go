@@ -0,0 +1,93 @@
+package executor
+
+import (
+	"context"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"github.com/cexll/swe/internal/github"
+)
+
+func TestWorkspaces_WorktreesShareAMirror(t *testing.T) {
+	source, remote := initPushRepo(t)
+	gitIn(t, source, "push", "-q", "origin", "main:refs/heads/dev")
+	origURL, origClone := mirrorURL, cloneRepo
+	t.Cleanup(func() {
+		mirrorURL, cloneRepo = origURL, origClone
+		github.SetCloneDir("")
+	})
+	mirrorURL = func(string) string { return remote }
+	cloneRepo = func(context.Context, string, string, string) (string, func(), error) {
+		t.Fatal("cloned instead of adding a worktree")
+		return "", nil, nil
+	}
+
+	root := filepath.Join(t.TempDir(), "workspaces")
+	w := newWorkspaces()
+	if err := w.configure(WorkspaceConfig{Dir: root, Worktrees: true}); err != nil {
+		t.Fatal(err)
+	}
+	main, cleanupMain, err := w.clone(context.Background(), "owner/repo", "main", "token")
+	if err != nil {
+		t.Fatal(err)
+	}
+	dev, cleanupDev, err := w.clone(context.Background(), "owner/repo", "dev", "token")
+	if err != nil {
+		t.Fatal(err)
+	}
+	mirror := filepath.Join(root, mirrorsDir, "owner", "repo.git")
+	if common := gitIn(t, main, "rev-parse", "--path-format=absolute", "--git-common-dir"); common != gitIn(t, dev, "rev-parse", "--path-format=absolute", "--git-common-dir") || common != mirror {
+		t.Fatalf("worktrees do not share the mirror: %s", common)
+	}
+
+	// each has its own branch, index and hooks
+	gitIn(t, main, "checkout", "-q", "-b", "swe-agent/1-fix")
+	if err := os.WriteFile(filepath.Join(main, "README.md"), []byte("fix\n"), 0o644); err != nil {
+		t.Fatal(err)
+	}
+	gitIn(t, main, "add", "README.md")
+	if staged := gitIn(t, dev, "diff", "--cached", "--name-only"); staged != "" {
+		t.Fatalf("staged in the other worktree: %q", staged)
+	}
+	gitIn(t, main, "-c", "user.name=test", "-c", "user.email=test@example.com", "commit", "-q", "-m", "fix")
+	if err := installPushGuard(main, []string{"main"}, "swe-agent/1-fix"); err != nil {
+		t.Fatal(err)
+	}
+	if _, err := os.Stat(filepath.Join(mirror, "hooks", "pre-push")); !os.IsNotExist(err) {
+		t.Fatalf("push guard shared with every worktree: %v", err)
+	}
+	if _, err := gitOutput(main, "push", "-q", "origin", "HEAD:refs/heads/main"); err == nil {
+		t.Fatal("push guard of the worktree not run")
+	}
+	if _, err := gitOutput(dev, "push", "-q", "origin", "HEAD:refs/heads/main"); err != nil {
+		t.Fatalf("push guard ran in the other worktree: %v", err)
+	}
+
+	cleanupMain()
+	if _, err := os.Stat(main); !os.IsNotExist(err) {
+		t.Fatalf("worktree left behind: %v", err)
+	}
+	if branches := gitIn(t, mirror, "branch", "--list"); strings.Contains(branches, "swe-agent/1-fix") {
+		t.Fatalf("branch of the removed worktree kept: %s", branches)
+	}
+	if n, err := w.removeStale(); err != nil || n != 0 {
+		t.Fatalf("removeStale = %d, %v", n, err)
+	}
+
+	// the next checkout fetches what changed into the same mirror
+	head := commitAndPush(t, source, "main", "v2\n")
+	again, cleanupAgain, err := w.clone(context.Background(), "owner/repo", "main", "token")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if got := gitIn(t, again, "rev-parse", "HEAD"); got != head {
+		t.Fatalf("HEAD = %s, want %s", got, head)
+	}
+	cleanupAgain()
+	cleanupDev()
+	if u := w.usage(); u.Workspaces != 0 || u.Bytes == 0 {
+		t.Fatalf("usage = %+v, want the mirror only", u)
+	}
+}
@@ -0,0 +1,38 @@
+// Package filelock holds exclusive locks on files across processes. A lock
+// is released when its holder closes it or exits, however it exits, so a
+// lock that can be taken means its previous holder is gone.
+package filelock
+
+import (
+	"errors"
+	"os"
+)
+
+// ErrLocked is returned by TryLock when another holder has the lock.
+var ErrLocked = errors.New("file is locked by another process")
+
+// Lock is a held lock.
+type Lock struct {
+	f *os.File
+}
+
+// TryLock takes the lock on path, creating the file if needed, without
+// waiting: it returns ErrLocked when the lock is held, also by another Lock
+// of this process.
+func TryLock(path string) (*Lock, error) {
+	f, err := tryLock(path)
+	if err != nil {
+		return nil, err
+	}
+	return &Lock{f: f}, nil
+}
+
+// Close releases the lock. The file is left in place.
+func (l *Lock) Close() error {
+	if l == nil || l.f == nil {
+		return nil
+	}
+	err := l.f.Close()
+	l.f = nil
+	return err
+}
@@ -0,0 +1,11 @@
+//go:build !(linux || darwin || freebsd || netbsd || openbsd || dragonfly || windows)
+
+package filelock
+
+import "os"
+
+// tryLock only creates path: these platforms have no lock this package
+// uses, so the lock is always taken.
+func tryLock(path string) (*os.File, error) {
+	return os.OpenFile(path, os.O_CREATE|os.O_RDWR, 0o600)
+}
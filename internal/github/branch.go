@@ -0,0 +1,54 @@
+package github
+
+import (
+	"encoding/json"
+	"fmt"
+	"io"
+	"net/http"
+	"net/url"
+)
+
+// IsBranchProtected reports whether branch has protection rules using GitHub REST API
+// GET /repos/{owner}/{repo}/branches/{branch}
+// A branch that does not exist yet is not protected.
+func IsBranchProtected(owner, repo, branch, token string) (bool, error) {
+	if token == "" {
+		return false, fmt.Errorf("github token is required")
+	}
+	if branch == "" {
+		return false, fmt.Errorf("branch is required")
+	}
+
+	apiURL := fmt.Sprintf("https://api.github.com/repos/%s/%s/branches/%s", owner, repo, url.PathEscape(branch))
+	req, err := http.NewRequest("GET", apiURL, nil)
+	if err != nil {
+		return false, fmt.Errorf("create request: %w", err)
+	}
+
+	req.Header.Set("Authorization", "Bearer "+token)
+	req.Header.Set("Accept", "application/vnd.github+json")
+	req.Header.Set("X-GitHub-Api-Version", "2022-11-28")
+
+	client := &http.Client{}
+	resp, err := client.Do(req)
+	if err != nil {
+		return false, fmt.Errorf("execute request: %w", err)
+	}
+	defer func() { _ = resp.Body.Close() }()
+
+	bodyBytes, _ := io.ReadAll(resp.Body)
+	if resp.StatusCode == http.StatusNotFound {
+		return false, nil
+	}
+	if resp.StatusCode != http.StatusOK {
+		return false, fmt.Errorf("github API error (status %d): %s", resp.StatusCode, string(bodyBytes))
+	}
+
+	var b struct {
+		Protected bool `json:"protected"`
+	}
+	if err := json.Unmarshal(bodyBytes, &b); err != nil {
+		return false, fmt.Errorf("decode branch: %w", err)
+	}
+	return b.Protected, nil
+}
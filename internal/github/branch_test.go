@@ -0,0 +1,12 @@
+package github
+
+import "testing"
+
+func TestIsBranchProtected_Validation(t *testing.T) {
+	if _, err := IsBranchProtected("owner", "repo", "main", ""); err == nil || err.Error() != "github token is required" {
+		t.Errorf("missing token: got %v", err)
+	}
+	if _, err := IsBranchProtected("owner", "repo", "", "token"); err == nil || err.Error() != "branch is required" {
+		t.Errorf("missing branch: got %v", err)
+	}
+}
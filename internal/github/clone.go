@@ -1,18 +1,23 @@
 package github
 
 import (
+	"context"
 	"fmt"
+	"log/slog"
 	"os"
 	"os/exec"
 	"path/filepath"
 	"regexp"
 	"strings"
+	"sync"
 	"time"
+
+	"github.com/cexll/swe/internal/logging"
 )
 
-var runRepoClone = func(repo, branch, token, dest string) error {
+var runRepoClone = func(ctx context.Context, repo, branch, token, dest string) error {
 	// Pass through to underlying git clone with shallow/single-branch options for stability/perf
-	cmd := exec.Command("gh", "repo", "clone", repo, dest, "--", "-b", branch, "--depth=1", "--single-branch")
+	cmd := exec.CommandContext(ctx, "gh", "repo", "clone", repo, dest, "--", "-b", branch, "--depth=1", "--single-branch")
 	if token != "" {
 		// Set both GITHUB_TOKEN and GH_TOKEN for maximum compatibility with gh CLI
 		cmd.Env = append(os.Environ(),
@@ -27,6 +32,28 @@ var runRepoClone = func(repo, branch, token, dest string) error {
 	return nil
 }
 
+var (
+	cloneDirMu sync.RWMutex
+	cloneDir   string // "" clones into the system temp directory
+)
+
+// SetCloneDir makes subsequent clones go into dir ("" uses the system temp
+// directory).
+func SetCloneDir(dir string) {
+	cloneDirMu.Lock()
+	defer cloneDirMu.Unlock()
+	cloneDir = dir
+}
+
+func cloneRoot() string {
+	cloneDirMu.RLock()
+	defer cloneDirMu.RUnlock()
+	if cloneDir != "" {
+		return cloneDir
+	}
+	return os.TempDir()
+}
+
 var (
 	nowFunc            = time.Now
 	issueNumberPattern = regexp.MustCompile(`(?i)issue[-_/](\d+)`)
@@ -69,27 +96,34 @@ func buildCloneWorkdir(repo, branch string, ts time.Time) string {
 	context, detail := extractBranchContext(branch)
 
 	dirName := fmt.Sprintf("%s-%s-%s-%s-%d", ownerSegment, repoSegment, context, detail, ts.UnixNano())
-	return filepath.Join(os.TempDir(), dirName)
+	return filepath.Join(cloneRoot(), dirName)
 }
 
 // Clone clones a GitHub repository to a temporary directory with retry logic.
 // Returns: workdir path, cleanup function, error.
 func Clone(repo, branch, token string) (string, func(), error) {
+	return CloneContext(context.Background(), repo, branch, token)
+}
+
+// CloneContext is Clone, killing the clone when ctx is done.
+func CloneContext(ctx context.Context, repo, branch, token string) (string, func(), error) {
 	// Create temporary directory name that avoids collisions across concurrent clones.
 	tmpDir := buildCloneWorkdir(repo, branch, nowFunc())
 
 	// Execute gh repo clone (single attempt)
 	// Note: git flags must be passed after '--' separator
-	err := runRepoClone(repo, branch, token, tmpDir)
+	err := runRepoClone(ctx, repo, branch, token, tmpDir)
 
 	if err != nil {
+		// a clone cut short leaves a partial checkout behind
+		_ = os.RemoveAll(tmpDir)
 		return "", nil, err
 	}
 
 	// Cleanup function to remove temporary directory
 	cleanup := func() {
 		if err := os.RemoveAll(tmpDir); err != nil {
-			fmt.Printf("Warning: failed to cleanup %s: %v\n", tmpDir, err)
+			slog.Warn("Cleaning up clone directory failed", logging.KeyPhase, "clone", "dir", tmpDir, "err", err)
 		}
 	}
 
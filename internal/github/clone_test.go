@@ -23,7 +23,7 @@ func TestClone_UsesRunRepoCloneSuccess(t *testing.T) {
 	const expectedToken = "token-123"
 
 	callCount := 0
-	runRepoClone = func(repo, branch, token, dest string) error {
+	runRepoClone = func(_ context.Context, repo, branch, token, dest string) error {
 		callCount++
 		if repo != "owner/repo" {
 			return fmt.Errorf("unexpected repo %s", repo)
@@ -68,7 +68,7 @@ func TestClone_ReturnsErrorOnFailure(t *testing.T) {
 	orig := runRepoClone
 	defer func() { runRepoClone = orig }()
 
-	runRepoClone = func(repo, branch, token, dest string) error {
+	runRepoClone = func(_ context.Context, repo, branch, token, dest string) error {
 		return fmt.Errorf("fatal: cannot clone %s", repo)
 	}
 
@@ -87,6 +87,30 @@ func TestClone_ReturnsErrorOnFailure(t *testing.T) {
 	}
 }
 
+func TestClone_IntoCloneDirRemovesPartialClone(t *testing.T) {
+	orig := runRepoClone
+	defer func() { runRepoClone = orig }()
+	root := t.TempDir()
+	SetCloneDir(root)
+	defer SetCloneDir("")
+
+	var dest string
+	runRepoClone = func(_ context.Context, repo, branch, token, d string) error {
+		dest = d
+		_ = os.MkdirAll(filepath.Join(d, ".git"), 0o755)
+		return fmt.Errorf("fatal: early EOF")
+	}
+	if _, _, err := Clone("owner/repo", "main", "token"); err == nil {
+		t.Fatal("Clone() error = nil, want failure")
+	}
+	if filepath.Dir(dest) != root {
+		t.Fatalf("cloned into %s, want a directory of %s", dest, root)
+	}
+	if _, err := os.Stat(dest); !os.IsNotExist(err) {
+		t.Fatalf("partial clone left behind: %v", err)
+	}
+}
+
 func TestClone_IssueBranchWorkdirNaming(t *testing.T) {
 	orig := runRepoClone
 	defer func() { runRepoClone = orig }()
@@ -96,7 +120,7 @@ func TestClone_IssueBranchWorkdirNaming(t *testing.T) {
 	fixedNow := time.Unix(24680, 0)
 	nowFunc = func() time.Time { return fixedNow }
 
-	runRepoClone = func(repo, branch, token, dest string) error {
+	runRepoClone = func(_ context.Context, repo, branch, token, dest string) error {
 		if repo != "owner/repo" {
 			return fmt.Errorf("unexpected repo %s", repo)
 		}
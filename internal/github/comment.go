@@ -15,6 +15,7 @@ type UpdateCommentRequest struct {
 
 // UpdateComment updates an existing issue or PR comment using GitHub REST API
 // PATCH /repos/{owner}/{repo}/issues/comments/{comment_id}
+// The body goes through FilterOutput first.
 func UpdateComment(owner, repo string, commentID int64, body, token string) error {
 	if token == "" {
 		return fmt.Errorf("github token is required")
@@ -25,7 +26,7 @@ func UpdateComment(owner, repo string, commentID int64, body, token string) erro
 
 	url := fmt.Sprintf("https://api.github.com/repos/%s/%s/issues/comments/%d", owner, repo, commentID)
 
-	reqBody := UpdateCommentRequest{Body: body}
+	reqBody := UpdateCommentRequest{Body: FilterOutput(body, token)}
 	jsonData, err := json.Marshal(reqBody)
 	if err != nil {
 		return fmt.Errorf("marshal request body: %w", err)
@@ -55,3 +56,90 @@ func UpdateComment(owner, repo string, commentID int64, body, token string) erro
 
 	return nil
 }
+
+// GetComment returns the body of an issue or PR comment using GitHub REST API
+// GET /repos/{owner}/{repo}/issues/comments/{comment_id}
+func GetComment(owner, repo string, commentID int64, token string) (string, error) {
+	if token == "" {
+		return "", fmt.Errorf("github token is required")
+	}
+	if commentID <= 0 {
+		return "", fmt.Errorf("invalid comment ID: %d", commentID)
+	}
+
+	url := fmt.Sprintf("https://api.github.com/repos/%s/%s/issues/comments/%d", owner, repo, commentID)
+	req, err := http.NewRequest("GET", url, nil)
+	if err != nil {
+		return "", fmt.Errorf("create request: %w", err)
+	}
+
+	req.Header.Set("Authorization", "Bearer "+token)
+	req.Header.Set("Accept", "application/vnd.github+json")
+	req.Header.Set("X-GitHub-Api-Version", "2022-11-28")
+
+	client := &http.Client{}
+	resp, err := client.Do(req)
+	if err != nil {
+		return "", fmt.Errorf("execute request: %w", err)
+	}
+	defer func() { _ = resp.Body.Close() }()
+
+	bodyBytes, _ := io.ReadAll(resp.Body)
+	if resp.StatusCode != http.StatusOK {
+		return "", fmt.Errorf("github API error (status %d): %s", resp.StatusCode, string(bodyBytes))
+	}
+
+	var comment UpdateCommentRequest
+	if err := json.Unmarshal(bodyBytes, &comment); err != nil {
+		return "", fmt.Errorf("decode comment: %w", err)
+	}
+	return comment.Body, nil
+}
+
+// CreateComment posts a new comment on an issue or PR and returns its ID
+// POST /repos/{owner}/{repo}/issues/{issue_number}/comments
+// The body goes through FilterOutput first.
+func CreateComment(owner, repo string, number int, body, token string) (int64, error) {
+	if token == "" {
+		return 0, fmt.Errorf("github token is required")
+	}
+	if number <= 0 {
+		return 0, fmt.Errorf("invalid issue number: %d", number)
+	}
+
+	url := fmt.Sprintf("https://api.github.com/repos/%s/%s/issues/%d/comments", owner, repo, number)
+	jsonData, err := json.Marshal(UpdateCommentRequest{Body: FilterOutput(body, token)})
+	if err != nil {
+		return 0, fmt.Errorf("marshal request body: %w", err)
+	}
+
+	req, err := http.NewRequest("POST", url, bytes.NewBuffer(jsonData))
+	if err != nil {
+		return 0, fmt.Errorf("create request: %w", err)
+	}
+
+	req.Header.Set("Authorization", "Bearer "+token)
+	req.Header.Set("Accept", "application/vnd.github+json")
+	req.Header.Set("Content-Type", "application/json")
+	req.Header.Set("X-GitHub-Api-Version", "2022-11-28")
+
+	client := &http.Client{}
+	resp, err := client.Do(req)
+	if err != nil {
+		return 0, fmt.Errorf("execute request: %w", err)
+	}
+	defer func() { _ = resp.Body.Close() }()
+
+	bodyBytes, _ := io.ReadAll(resp.Body)
+	if resp.StatusCode != http.StatusCreated {
+		return 0, fmt.Errorf("github API error (status %d): %s", resp.StatusCode, string(bodyBytes))
+	}
+
+	var created struct {
+		ID int64 `json:"id"`
+	}
+	if err := json.Unmarshal(bodyBytes, &created); err != nil {
+		return 0, fmt.Errorf("decode comment: %w", err)
+	}
+	return created.ID, nil
+}
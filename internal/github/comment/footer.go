@@ -0,0 +1,33 @@
+package comment
+
+import "strings"
+
+// footerStart 标记版本页脚，再次写入时据此替换而不是重复追加
+const footerStart = "<!-- swe-agent:version -->"
+
+// Footer 返回显示运行版本的评论页脚，便于排查版本不一致的问题
+func Footer(version string) string {
+	return footerStart + "\n<sub>swe-agent " + version + "</sub>"
+}
+
+// IsTracking 报告 body 是否为协调评论：带有服务端写入的页脚、版式或运行记录标记
+func IsTracking(body string) bool {
+	for _, marker := range []string{footerStart, headerStart, sectionsStart, historyStart} {
+		if strings.Contains(body, marker) {
+			return true
+		}
+	}
+	return false
+}
+
+// WithFooter 去掉 body 中已有的版本页脚，再在末尾追加 version 的页脚
+// （version 为空时只去掉）
+func WithFooter(body, version string) string {
+	if i := strings.Index(body, footerStart); i >= 0 {
+		body = strings.TrimRight(body[:i], "\n")
+	}
+	if version == "" {
+		return body
+	}
+	return body + "\n\n" + Footer(version)
+}
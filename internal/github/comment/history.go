@@ -0,0 +1,163 @@
+package comment
+
+import (
+	"strings"
+	"time"
+)
+
+// 运行记录的标记：复用的协调评论据此找到运行记录、每次运行和运行结果
+const (
+	historyStart = "<!-- swe-agent:history -->"
+	runStart     = "<!-- swe-agent:run -->"
+	outcomeStart = "<!-- swe-agent:outcome "
+)
+
+// 运行记录的上限：保留的次数、每次运行正文的长度和整段的长度
+// （GitHub 评论最长 65536 个字符）
+const (
+	maxHistoryRuns = 10
+	maxRunSize     = 16 << 10
+	maxHistorySize = 40 << 10
+)
+
+// 运行结果，见 WithOutcome
+const (
+	OutcomeSucceeded = "succeeded"
+	OutcomeFailed    = "failed"
+)
+
+// WithOutcome 在 body 末尾记下运行结果和结束时间（替换已有的记录）。
+// 记下了结果的协调评论才会被下一次运行复用
+func WithOutcome(body, outcome string, at time.Time) string {
+	return stripOutcome(body) + "\n" + outcomeStart + outcome + " " + at.UTC().Format(time.RFC3339) + " -->"
+}
+
+// parseOutcome 读取 WithOutcome 记下的结果；ok 为 false 表示运行还没有结束
+func parseOutcome(body string) (outcome string, at time.Time, ok bool) {
+	i := strings.Index(body, outcomeStart)
+	if i < 0 {
+		return "", time.Time{}, false
+	}
+	rest, _, found := strings.Cut(body[i+len(outcomeStart):], " -->")
+	if !found {
+		return "", time.Time{}, false
+	}
+	outcome, stamp, _ := strings.Cut(rest, " ")
+	at, _ = time.Parse(time.RFC3339, stamp)
+	return outcome, at, outcome != ""
+}
+
+// stripOutcome 去掉 WithOutcome 记下的结果
+func stripOutcome(body string) string {
+	if i := strings.Index(body, outcomeStart); i >= 0 {
+		return strings.TrimRight(body[:i], "\n")
+	}
+	return body
+}
+
+// History 返回 body 中的运行记录，没有时为 ""
+func History(body string) string {
+	i := strings.Index(body, historyStart)
+	if i < 0 {
+		return ""
+	}
+	h := body[i:]
+	return strings.TrimSpace(h[:tailStart(h)])
+}
+
+// WithHistory 去掉 body 中已有的运行记录，再把 history 放在正文之后、
+// 版式区块和页脚之前
+func WithHistory(body, history string) string {
+	body = stripHistory(body)
+	if history == "" {
+		return body
+	}
+	i := tailStart(body)
+	return joinTail(strings.TrimRight(body[:i], "\n")+"\n\n"+history, body[i:])
+}
+
+// CarryHistory 把 old（评论当前的内容）中的运行记录带到整体改写的 body 中
+func CarryHistory(body, old string) string {
+	if h := History(old); h != "" {
+		return WithHistory(body, h)
+	}
+	return body
+}
+
+// stripHistory 去掉 body 中的运行记录
+func stripHistory(body string) string {
+	i := strings.Index(body, historyStart)
+	if i < 0 {
+		return body
+	}
+	return joinTail(strings.TrimRight(body[:i], "\n"), body[i+tailStart(body[i:]):])
+}
+
+// tailStart 返回 s 中版式区块、页脚或运行结果（最先出现的）的位置，没有时为 len(s)
+func tailStart(s string) int {
+	end := len(s)
+	for _, marker := range []string{sectionsStart, footerStart, outcomeStart} {
+		if i := strings.Index(s, marker); i >= 0 && i < end {
+			end = i
+		}
+	}
+	return end
+}
+
+// joinTail 在 head 之后接上 tail（版式区块、页脚或运行结果）
+func joinTail(head, tail string) string {
+	if tail == "" {
+		return head
+	}
+	if strings.HasPrefix(tail, outcomeStart) {
+		return head + "\n" + tail
+	}
+	return head + "\n\n" + tail
+}
+
+// archive 返回复用 prev（上一次运行结束时的协调评论）时的运行记录：
+// 上一次运行的正文和结果折叠后放在最前面，超出上限的旧记录被丢弃
+func archive(prev string, lang Lang) string {
+	outcome, at, _ := parseOutcome(prev)
+	current := strings.TrimSpace(stripHistory(stripLayout(stripOutcome(prev))))
+	if len(current) > maxRunSize {
+		current = strings.ToValidUTF8(current[:maxRunSize], "") + "\n\n…"
+	}
+	entry := runStart + "\n<details><summary>" + runSummary(lang, outcome, at) + "</summary>\n\n" + current + "\n\n</details>"
+
+	runs := append([]string{entry}, historyRuns(History(prev))...)
+	if len(runs) > maxHistoryRuns {
+		runs = runs[:maxHistoryRuns]
+	}
+	for len(runs) > 1 && len(strings.Join(runs, "\n\n")) > maxHistorySize {
+		runs = runs[:len(runs)-1]
+	}
+	return historyStart + "\n" + Text(lang, MsgPreviousRuns) + "\n\n" + strings.Join(runs, "\n\n")
+}
+
+// historyRuns 拆出运行记录中的每次运行，最近的在前
+func historyRuns(history string) []string {
+	parts := strings.Split(history, runStart)
+	var runs []string
+	for _, p := range parts[1:] {
+		runs = append(runs, runStart+"\n"+strings.TrimSpace(p))
+	}
+	return runs
+}
+
+// runSummary 是一次运行折叠后显示的一行：结果和结束时间
+func runSummary(lang Lang, outcome string, at time.Time) string {
+	var s string
+	switch outcome {
+	case OutcomeSucceeded:
+		s = Text(lang, MsgRunSucceeded)
+	case OutcomeFailed:
+		s = Text(lang, MsgRunFailed)
+	default:
+		s = outcome
+	}
+	if !at.IsZero() {
+		s += " · " + at.UTC().Format("2006-01-02 15:04 UTC")
+	}
+	return s
+}
@@ -0,0 +1,120 @@
+package comment
+
+import (
+	"context"
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"net/url"
+	"strings"
+	"testing"
+	"time"
+
+	gh "github.com/google/go-github/v66/github"
+)
+
+func TestHistory_Archive(t *testing.T) {
+	at := time.Date(2026, 10, 16, 9, 30, 0, 0, time.UTC)
+	first := WithOutcome(WithHistory(WithFooter("Fixed the parser.", "v1.0.0"), historyStart), OutcomeSucceeded, at)
+	if outcome, got, ok := parseOutcome(first); !ok || outcome != OutcomeSucceeded || !got.Equal(at) {
+		t.Fatalf("parseOutcome = %q %v %t", outcome, got, ok)
+	}
+
+	history := archive(first, English)
+	if !strings.HasPrefix(history, historyStart+"\n**Previous runs**") || strings.Count(history, runStart) != 1 ||
+		!strings.Contains(history, "<summary>✅ Succeeded · 2026-10-16 09:30 UTC</summary>\n\nFixed the parser.\n\n</details>") {
+		t.Fatalf("archive = %q", history)
+	}
+	if strings.Contains(history, footerStart) || strings.Contains(history, outcomeStart) {
+		t.Fatalf("archived run kept the footer or outcome: %q", history)
+	}
+
+	// the next run folds in on top, newest first
+	second := WithOutcome(WithHistory(WithFooter("Tests still fail.", "v1.0.0"), history), OutcomeFailed, at.Add(time.Hour))
+	history = archive(second, Chinese)
+	runs := historyRuns(History(history))
+	if len(runs) != 2 || !strings.Contains(runs[0], "❌ 失败 · 2026-10-16 10:30 UTC") || !strings.Contains(runs[1], "Fixed the parser.") {
+		t.Fatalf("runs = %q", runs)
+	}
+
+	for i := 0; i < maxHistoryRuns+2; i++ {
+		history = archive(WithOutcome(WithHistory("run", history), OutcomeSucceeded, at), English)
+	}
+	if n := strings.Count(history, runStart); n != maxHistoryRuns {
+		t.Fatalf("history keeps %d runs, want %d", n, maxHistoryRuns)
+	}
+	big := WithOutcome(strings.Repeat("x", 3*maxRunSize), OutcomeSucceeded, at)
+	for i := 0; i < 4; i++ {
+		history = archive(WithOutcome(WithHistory(big, history), OutcomeSucceeded, at), English)
+	}
+	if len(history) > maxHistorySize+200 {
+		t.Fatalf("history is %d bytes", len(history))
+	}
+}
+
+func TestHistory_Carry(t *testing.T) {
+	history := historyStart + "\n**Previous runs**\n\n" + runStart + "\n<details><summary>✅ Succeeded</summary>\n\nold\n\n</details>"
+	old := WithFooter(WithHistory("Working...", history), "v1.0.0")
+	if !strings.HasPrefix(old, "Working...\n\n"+historyStart) || !strings.HasSuffix(old, footerStart+"\n<sub>swe-agent v1.0.0</sub>") {
+		t.Fatalf("WithHistory = %q", old)
+	}
+	// the provider rewrites the comment; the history stays above the footer
+	body := CarryHistory(WithFooter("Done.", "v1.0.1"), old)
+	if body != "Done.\n\n"+history+"\n\n"+footerStart+"\n<sub>swe-agent v1.0.1</sub>" {
+		t.Fatalf("CarryHistory = %q", body)
+	}
+	if CarryHistory("Done.", "no history") != "Done." {
+		t.Fatal("a comment without history changed the body")
+	}
+	if got := WithHistory(body, ""); got != WithFooter("Done.", "v1.0.1") {
+		t.Fatalf("WithHistory(\"\") = %q", got)
+	}
+}
+
+func TestTracker_ReusesFinishedComment(t *testing.T) {
+	finished := WithOutcome(WithHistory("Fixed it.", historyStart), OutcomeSucceeded, time.Now())
+	comments := []map[string]any{
+		{"id": 5, "body": "/code fix it", "user": map[string]any{"type": "User"}},
+		{"id": 7, "body": finished, "user": map[string]any{"type": "Bot"}},
+		{"id": 8, "body": "/code and the docs", "user": map[string]any{"type": "User"}},
+	}
+	var edited, created string
+	mux := http.NewServeMux()
+	mux.HandleFunc("/repos/o/r/issues/9/comments", func(w http.ResponseWriter, r *http.Request) {
+		if r.Method == http.MethodPost {
+			var c gh.IssueComment
+			_ = json.NewDecoder(r.Body).Decode(&c)
+			created = c.GetBody()
+			_ = json.NewEncoder(w).Encode(map[string]any{"id": 10})
+			return
+		}
+		_ = json.NewEncoder(w).Encode(comments)
+	})
+	mux.HandleFunc("/repos/o/r/issues/comments/7", func(w http.ResponseWriter, r *http.Request) {
+		var c gh.IssueComment
+		_ = json.NewDecoder(r.Body).Decode(&c)
+		edited = c.GetBody()
+		_ = json.NewEncoder(w).Encode(map[string]any{"id": 7})
+	})
+	srv := httptest.NewServer(mux)
+	defer srv.Close()
+	client := gh.NewClient(srv.Client())
+	client.BaseURL, _ = url.Parse(srv.URL + "/")
+
+	tr := NewTracker(client, "o", "r", 9)
+	tr.SetHistory(true)
+	id, err := tr.CreateInitial(context.Background())
+	if err != nil || id != 7 || created != "" {
+		t.Fatalf("CreateInitial = %d, %v (created %q)", id, err, created)
+	}
+	if !strings.HasPrefix(edited, spinner) || !strings.Contains(edited, "Fixed it.") || strings.Contains(edited, outcomeStart) {
+		t.Fatalf("reused comment = %q", edited)
+	}
+
+	// a run still in progress keeps its comment; the new run gets its own
+	comments[1]["body"] = WithHistory("Working...", historyStart)
+	edited = ""
+	if id, err := tr.CreateInitial(context.Background()); err != nil || id != 10 || edited != "" || History(created) != historyStart {
+		t.Fatalf("CreateInitial = %d, %v (edited %q, created %q)", id, err, edited, created)
+	}
+}
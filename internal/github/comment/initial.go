@@ -2,17 +2,17 @@ package comment
 
 import (
 	"context"
+	"time"
 
 	"github.com/google/go-github/v66/github"
+
+	"github.com/cexll/swe/internal/version"
 )
 
-// createInitialComment 创建初始评论（内部函数）
+// createInitialComment 以 body 创建初始评论（内部函数）
 // 返回评论 ID
-func createInitialComment(ctx context.Context, client *github.Client, owner, repo string, number int) (int64, error) {
-	// 1. 生成初始 body（带 spinner + checklist）
-	body := formatInitialBody()
-
-	// 2. 调用 GitHub API 创建评论
+func createInitialComment(ctx context.Context, client *github.Client, owner, repo string, number int, body string) (int64, error) {
+	// 调用 GitHub API 创建评论
 	comment, _, err := client.Issues.CreateComment(ctx, owner, repo, number, &github.IssueComment{
 		Body: &body,
 	})
@@ -25,7 +25,26 @@ func createInitialComment(ctx context.Context, client *github.Client, owner, rep
 	return *comment.ID, nil
 }
 
-// formatInitialBody 格式化初始评论内容
-func formatInitialBody() string {
-	return `<img src="https://github.com/user-attachments/assets/5ac382c7-e004-429b-8e35-7feb3e8f9c6f" width="14px" /> Working on your request...`
+// spinner 是评论中表示进行中的动画图标
+const spinner = `<img src="https://github.com/user-attachments/assets/5ac382c7-e004-429b-8e35-7feb3e8f9c6f" width="14px" />`
+
+// formatInitialBody 格式化 lang 语言的初始评论内容（带版本页脚）
+func formatInitialBody(lang Lang) string {
+	return WithFooter(spinner+" "+Text(lang, MsgWorking), version.Short())
+}
+
+// InitialBody 返回初始评论内容（排队的任务开始执行时恢复）
+func InitialBody(lang Lang) string { return formatInitialBody(lang) }
+
+// QueuedBody 返回排队中的评论内容：队列位置，以及 eta > 0 时的预计等待时间
+func QueuedBody(lang Lang, position int, eta time.Duration) string {
+	body := spinner + " " + Text(lang, MsgQueued, position)
+	switch {
+	case eta <= 0:
+	case eta < time.Minute:
+		body += Text(lang, MsgQueuedSoon)
+	default:
+		body += Text(lang, MsgQueuedETA, int(eta.Round(time.Minute)/time.Minute))
+	}
+	return body + "..."
 }
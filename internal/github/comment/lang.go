@@ -0,0 +1,141 @@
+package comment
+
+import (
+	"fmt"
+	"strings"
+	"unicode"
+)
+
+// Lang 是协调评论中由服务端写入的文字所用的语言
+type Lang string
+
+const (
+	English Lang = "en"
+	Chinese Lang = "zh"
+)
+
+// ParseLang 解析语言设置："en"、"zh"，或 "auto"/""（按触发评论自动识别，返回 ""）
+func ParseLang(s string) (Lang, error) {
+	switch l := Lang(strings.ToLower(strings.TrimSpace(s))); l {
+	case English, Chinese:
+		return l, nil
+	case "", "auto":
+		return "", nil
+	default:
+		return "", fmt.Errorf("unknown language %q (want en, zh or auto)", s)
+	}
+}
+
+// DetectLang 根据文字识别语言：汉字不少于两个且不少于英文单词数时为中文，
+// 否则为英文
+func DetectLang(text string) Lang {
+	han, words := 0, 0
+	inWord := false
+	for _, r := range text {
+		switch {
+		case unicode.Is(unicode.Han, r):
+			han++
+			inWord = false
+		case r < unicode.MaxASCII && unicode.IsLetter(r):
+			if !inWord {
+				words++
+			}
+			inWord = true
+		default:
+			inWord = false
+		}
+	}
+	if han >= 2 && han >= words {
+		return Chinese
+	}
+	return English
+}
+
+// ResolveLang 返回协调评论的语言：setting 有效时用它，否则识别 text（触发评论）
+func ResolveLang(setting, text string) Lang {
+	if l, err := ParseLang(setting); err == nil && l != "" {
+		return l
+	}
+	return DetectLang(text)
+}
+
+// 消息键，见 messages
+const (
+	MsgWorking          = "working"
+	MsgQueued           = "queued"
+	MsgQueuedSoon       = "queued_soon"
+	MsgQueuedETA        = "queued_eta"
+	MsgStillWorking     = "still_working"
+	MsgLatestStep       = "latest_step"
+	MsgAwaitingApproval = "awaiting_approval"
+	MsgPlanApproved     = "plan_approved"
+	MsgDryRunNoChanges  = "dry_run_no_changes"
+	MsgDryRunApplied    = "dry_run_applied"
+	MsgProtectedBranch  = "protected_branch"
+	MsgNoReviewThreads  = "no_review_threads"
+	MsgStopped          = "stopped"
+	MsgPreviousRuns     = "previous_runs"
+	MsgRunSucceeded     = "run_succeeded"
+	MsgRunFailed        = "run_failed"
+	MsgBudgetWarning    = "budget_warning"
+	MsgBudgetExhausted  = "budget_exhausted"
+	MsgConfirmCost      = "confirm_cost"
+)
+
+// messages 按语言列出服务端写入协调评论的文字（fmt 格式）；缺少的键使用英文
+var messages = map[Lang]map[string]string{
+	English: {
+		MsgWorking:          "Working on your request...",
+		MsgQueued:           "Queued: position #%d in queue",
+		MsgQueuedSoon:       ", starting in less than a minute",
+		MsgQueuedETA:        ", starting in about %d min",
+		MsgStillWorking:     "⏱️ Still working: %d min elapsed",
+		MsgLatestStep:       " · latest step: `%s`",
+		MsgAwaitingApproval: "**Awaiting approval.** Nothing has been pushed. Another user with write access can comment `%s` to carry out this plan.",
+		MsgPlanApproved:     "Plan approved by @%s.",
+		MsgDryRunNoChanges:  "**Dry run:** nothing was changed, so there is nothing to apply.",
+		MsgDryRunApplied:    "Dry run applied: the changes were pushed to `%s`.",
+		MsgProtectedBranch:  "`%s` is a protected branch, so the changes were pushed to `%s` instead.",
+		MsgNoReviewThreads:  "There are no unresolved review threads to address.",
+		MsgStopped:          "This task was stopped and marked failed: %v.",
+		MsgPreviousRuns:     "**Previous runs**",
+		MsgRunSucceeded:     "✅ Succeeded",
+		MsgRunFailed:        "❌ Failed",
+		MsgBudgetWarning:    "**Budget:** `%s` has spent $%.2f of its $%.2f budget for %s (%d%%).",
+		MsgBudgetExhausted:  "**Budget exhausted:** `%s` has spent $%.2f of its $%.2f budget for %s (%d%%). New tasks are refused until next month or until an operator resets it.",
+		MsgConfirmCost:      "**Confirm the cost.** Nothing has run yet. The prompt is about %d tokens, so this task is estimated at $%.2f, which reaches the $%.2f that needs confirming. Comment `%s` to run it.",
+	},
+	Chinese: {
+		MsgWorking:          "正在处理你的请求...",
+		MsgQueued:           "排队中：队列第 %d 位",
+		MsgQueuedSoon:       "，预计不到一分钟后开始",
+		MsgQueuedETA:        "，预计约 %d 分钟后开始",
+		MsgStillWorking:     "⏱️ 仍在处理：已用时 %d 分钟",
+		MsgLatestStep:       " · 最新步骤：`%s`",
+		MsgAwaitingApproval: "**等待批准。** 尚未推送任何内容。其他有写权限的用户评论 `%s` 后将执行此计划。",
+		MsgPlanApproved:     "计划已由 @%s 批准。",
+		MsgDryRunNoChanges:  "**试运行：** 没有任何改动，无需应用。",
+		MsgDryRunApplied:    "试运行已应用：改动已推送到 `%s`。",
+		MsgProtectedBranch:  "`%s` 是受保护分支，改动已改为推送到 `%s`。",
+		MsgNoReviewThreads:  "没有需要处理的未解决评审讨论。",
+		MsgStopped:          "任务已停止并标记为失败：%v。",
+		MsgPreviousRuns:     "**历次运行**",
+		MsgRunSucceeded:     "✅ 成功",
+		MsgRunFailed:        "❌ 失败",
+		MsgBudgetWarning:    "**预算：** `%[1]s` 在 %[4]s 已花费 $%.2[2]f，预算为 $%.2[3]f（%[5]d%%）。",
+		MsgBudgetExhausted:  "**预算已用尽：** `%[1]s` 在 %[4]s 已花费 $%.2[2]f，预算为 $%.2[3]f（%[5]d%%）。下个月或运维人员重置之前，新任务将被拒绝。",
+		MsgConfirmCost:      "**请确认费用。** 尚未执行任何操作。提示约 %[1]d 个 token，此任务预计花费 $%.2[2]f，达到需要确认的 $%.2[3]f。评论 `%[4]s` 后开始执行。",
+	},
+}
+
+// Text 返回 key 在 lang 下的文字，以 args 格式化
+func Text(lang Lang, key string, args ...any) string {
+	format, ok := messages[lang][key]
+	if !ok {
+		format = messages[English][key]
+	}
+	if len(args) == 0 {
+		return format
+	}
+	return fmt.Sprintf(format, args...)
+}
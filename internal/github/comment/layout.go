@@ -0,0 +1,172 @@
+package comment
+
+import (
+	"bytes"
+	"fmt"
+	"os"
+	"strings"
+	"text/template"
+)
+
+// 版式区块的标记：再次渲染时据此替换而不是重复追加
+const (
+	headerStart   = "<!-- swe-agent:header -->"
+	headerEnd     = "<!-- /swe-agent:header -->"
+	sectionsStart = "<!-- swe-agent:sections -->"
+)
+
+// 版式模板可以定义的区块
+const (
+	BlockHeader = "header" // 评论顶部
+	BlockLinks  = "links"  // 正文之后：分支、对比等链接
+	BlockFiles  = "files"  // 链接之后：改动的文件
+	BlockFooter = "footer" // 评论底部
+)
+
+// maxLayoutSize 限制版式模板文件的大小
+const maxLayoutSize = 64 << 10
+
+// defaultLayout 是内置版式：只有版本页脚
+const defaultLayout = `{{define "header"}}{{end}}{{define "links"}}{{end}}{{define "files"}}{{end}}` +
+	`{{define "footer"}}<sub>swe-agent {{.Version}}</sub>{{end}}`
+
+// Link 是链接区块中的一个链接
+type Link struct {
+	Title string
+	URL   string
+}
+
+// LayoutData 是渲染版式模板的数据；Branch、Links 和 Files 只在任务推送了改动后才有
+type LayoutData struct {
+	Repo    string // owner/name
+	Number  int    // Issue/PR 编号
+	TaskID  string
+	Version string
+	Lang    Lang
+	Branch  string
+	Links   []Link
+	Files   []string
+	// MoreFiles 是 Files 之外还改动了的文件数
+	MoreFiles int
+	// History 表示协调评论在各次运行间复用，保留着之前运行的记录
+	History bool
+}
+
+// Layout 是协调评论的版式：页眉、链接、文件列表和页脚的模板。nil 为内置版式
+type Layout struct {
+	Path string // 读取的文件
+	tmpl *template.Template
+}
+
+// builtin 是内置版式的模板
+var builtin = template.Must(template.New("layout").Parse(defaultLayout))
+
+// ParseLayout 解析版式模板：用 {{define "header"}} 等定义要覆盖的区块（可以定义为空），
+// 未定义的区块保持内置内容。模板须能以示例数据渲染
+func ParseLayout(text string) (*Layout, error) {
+	tmpl, err := template.New("layout").Parse(text)
+	if err != nil {
+		return nil, err
+	}
+	for _, t := range tmpl.Templates() {
+		switch t.Name() {
+		case "layout", BlockHeader, BlockLinks, BlockFiles, BlockFooter:
+		default:
+			return nil, fmt.Errorf("unknown block %q (want %s, %s, %s or %s)", t.Name(), BlockHeader, BlockLinks, BlockFiles, BlockFooter)
+		}
+	}
+	l := &Layout{tmpl: tmpl}
+	sample := LayoutData{
+		Repo: "owner/repo", Number: 1, TaskID: "owner-repo-1-1", Version: "v1.0.0", Lang: English,
+		Branch: "swe-agent/1-1", Links: []Link{{"Branch", "https://github.com/owner/repo/tree/swe-agent/1-1"}},
+		Files: []string{"main.go"}, MoreFiles: 1,
+	}
+	for _, block := range []string{BlockHeader, BlockLinks, BlockFiles, BlockFooter} {
+		if _, err := l.block(block, sample); err != nil {
+			return nil, err
+		}
+	}
+	return l, nil
+}
+
+// LoadLayout 读取并解析 path 处的版式模板；path 为空时返回 nil（内置版式）
+func LoadLayout(path string) (*Layout, error) {
+	if path == "" {
+		return nil, nil
+	}
+	info, err := os.Stat(path)
+	if err != nil {
+		return nil, err
+	}
+	if !info.Mode().IsRegular() {
+		return nil, fmt.Errorf("%s: not a regular file", path)
+	}
+	if info.Size() > maxLayoutSize {
+		return nil, fmt.Errorf("%s: larger than %d KB", path, maxLayoutSize>>10)
+	}
+	src, err := os.ReadFile(path)
+	if err != nil {
+		return nil, err
+	}
+	l, err := ParseLayout(string(src))
+	if err != nil {
+		return nil, fmt.Errorf("%s: %w", path, err)
+	}
+	l.Path = path
+	return l, nil
+}
+
+// Render 去掉 body 中已有的版式区块，再按版式加上页眉、链接、文件列表和页脚。
+// 渲染失败的区块留空
+func (l *Layout) Render(body string, data LayoutData) string {
+	body = stripLayout(body)
+	header, _ := l.block(BlockHeader, data)
+	links, _ := l.block(BlockLinks, data)
+	files, _ := l.block(BlockFiles, data)
+	footer, _ := l.block(BlockFooter, data)
+
+	if header != "" {
+		body = headerStart + "\n" + header + "\n" + headerEnd + "\n\n" + body
+	}
+	var sections []string
+	for _, s := range []string{links, files} {
+		if s != "" {
+			sections = append(sections, s)
+		}
+	}
+	if len(sections) > 0 {
+		body += "\n\n" + sectionsStart + "\n" + strings.Join(sections, "\n\n")
+	}
+	if footer != "" {
+		body += "\n\n" + footerStart + "\n" + footer
+	}
+	return body
+}
+
+// block 渲染版式的一个区块，去掉首尾空白
+func (l *Layout) block(name string, data LayoutData) (string, error) {
+	tmpl := builtin
+	if l != nil && l.tmpl.Lookup(name) != nil {
+		tmpl = l.tmpl
+	}
+	var buf bytes.Buffer
+	if err := tmpl.ExecuteTemplate(&buf, name, data); err != nil {
+		return "", err
+	}
+	return strings.TrimSpace(buf.String()), nil
+}
+
+// stripLayout 去掉 Render 加上的页眉、链接、文件列表和页脚
+func stripLayout(body string) string {
+	if strings.HasPrefix(body, headerStart) {
+		if i := strings.Index(body, headerEnd); i >= 0 {
+			body = strings.TrimLeft(body[i+len(headerEnd):], "\n")
+		}
+	}
+	for _, marker := range []string{sectionsStart, footerStart} {
+		if i := strings.Index(body, marker); i >= 0 {
+			body = strings.TrimRight(body[:i], "\n")
+		}
+	}
+	return body
+}
@@ -0,0 +1,81 @@
+package comment
+
+import (
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+)
+
+func TestLayout_Render(t *testing.T) {
+	l, err := ParseLayout(`{{define "header"}}**Acme bot** on {{.Repo}}#{{.Number}}{{end}}
+{{define "links"}}{{range .Links}}[{{.Title}}]({{.URL}}) {{end}}{{end}}
+{{define "files"}}{{range .Files}}- {{.}}
+{{end}}{{if .MoreFiles}}and {{.MoreFiles}} more{{end}}{{end}}
+{{define "footer"}}Reviewed by humans. swe-agent {{.Version}}{{end}}`)
+	if err != nil {
+		t.Fatalf("ParseLayout: %v", err)
+	}
+	data := LayoutData{Repo: "acme/api", Number: 7, Version: "v1.2.3"}
+	body := l.Render("Working...", data)
+	want := headerStart + "\n**Acme bot** on acme/api#7\n" + headerEnd + "\n\nWorking...\n\n" + footerStart + "\nReviewed by humans. swe-agent v1.2.3"
+	if body != want {
+		t.Fatalf("Render = %q, want %q", body, want)
+	}
+
+	// rendering again replaces the layout, also around an edited body
+	data.Links = []Link{{"Branch", "https://github.com/acme/api/tree/b"}}
+	data.Files = []string{"a.go", "b.go"}
+	data.MoreFiles = 3
+	body = l.Render(strings.Replace(body, "Working...", "Done.", 1), data)
+	if strings.Count(body, "Acme bot") != 1 || strings.Count(body, "Reviewed by humans") != 1 ||
+		!strings.Contains(body, "Done.\n\n"+sectionsStart+"\n[Branch](https://github.com/acme/api/tree/b)\n\n- a.go\n- b.go\nand 3 more\n\n"+footerStart) {
+		t.Fatalf("second Render = %q", body)
+	}
+	if again := l.Render(body, LayoutData{Repo: "acme/api", Number: 7, Version: "v1.2.3"}); again != strings.Replace(want, "Working...", "Done.", 1) {
+		t.Fatalf("sections are not removed: %q", again)
+	}
+
+	// blocks left undefined are built in; defined empty, they are dropped
+	l, err = ParseLayout(`{{define "footer"}}{{end}}`)
+	if err != nil {
+		t.Fatalf("ParseLayout: %v", err)
+	}
+	if body := l.Render("Done.\n\n"+Footer("v1"), data); body != "Done." {
+		t.Fatalf("empty footer: %q", body)
+	}
+	var builtin *Layout
+	if body := builtin.Render("Done.", data); body != WithFooter("Done.", "v1.2.3") {
+		t.Fatalf("built-in layout: %q", body)
+	}
+}
+
+func TestLoadLayout(t *testing.T) {
+	if l, err := LoadLayout(""); l != nil || err != nil {
+		t.Fatalf("LoadLayout(\"\") = %v, %v", l, err)
+	}
+	dir := t.TempDir()
+	for name, text := range map[string]string{
+		"ok.tmpl":      `{{define "header"}}Hi{{end}}`,
+		"syntax.tmpl":  `{{define "header"}}{{.Repo}`,
+		"unknown.tmpl": `{{define "heder"}}Hi{{end}}`,
+		"field.tmpl":   `{{define "files"}}{{.Commits}}{{end}}`,
+	} {
+		if err := os.WriteFile(filepath.Join(dir, name), []byte(text), 0o644); err != nil {
+			t.Fatal(err)
+		}
+	}
+	if l, err := LoadLayout(filepath.Join(dir, "ok.tmpl")); err != nil || l.Path != filepath.Join(dir, "ok.tmpl") {
+		t.Fatalf("LoadLayout(ok) = %v, %v", l, err)
+	}
+	for name, want := range map[string]string{
+		"syntax.tmpl":  "template:",
+		"unknown.tmpl": `unknown block "heder"`,
+		"field.tmpl":   "can't evaluate field Commits",
+		"missing.tmpl": "no such file",
+	} {
+		if _, err := LoadLayout(filepath.Join(dir, name)); err == nil || !strings.Contains(err.Error(), want) {
+			t.Errorf("LoadLayout(%s) = %v, want %q", name, err, want)
+		}
+	}
+}
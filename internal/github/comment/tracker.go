@@ -3,6 +3,10 @@ package comment
 import (
 	"context"
 	"fmt"
+	"log/slog"
+	"strings"
+
+	"github.com/cexll/swe/internal/logging"
 
 	"github.com/google/go-github/v66/github"
 )
@@ -13,9 +17,14 @@ type Tracker struct {
 	owner     string
 	repo      string
 	number    int
+	lang      Lang
+	history   bool
 	commentID int64
 }
 
+// maxScanPages 限制查找可复用的协调评论时从后往前读取的评论页数（每页 100 条）
+const maxScanPages = 5
+
 // NewTracker 创建评论追踪器
 func NewTracker(client *github.Client, owner, repo string, number int) *Tracker {
 	return &Tracker{
@@ -26,12 +35,33 @@ func NewTracker(client *github.Client, owner, repo string, number int) *Tracker
 	}
 }
 
-// CreateInitial 创建初始协调评论（带 spinner）
+// SetLang 设置初始评论的语言（默认英文）
+func (t *Tracker) SetLang(lang Lang) { t.lang = lang }
+
+// SetHistory 让 CreateInitial 复用 Issue/PR 上已结束的协调评论：上一次运行
+// 折叠进评论的运行记录，评论改回初始内容
+func (t *Tracker) SetHistory(on bool) { t.history = on }
+
+// CreateInitial 创建初始协调评论（带 spinner）；复用评论时没有可复用的
+// 评论（或查找失败）才新建
 func (t *Tracker) CreateInitial(ctx context.Context) (int64, error) {
 	if t == nil || t.client == nil {
 		return 0, fmt.Errorf("nil tracker or client")
 	}
-	id, err := createInitialComment(ctx, t.client, t.owner, t.repo, t.number)
+	body := formatInitialBody(t.lang)
+	if t.history {
+		id, err := t.reuse(ctx)
+		if err != nil {
+			slog.Warn("Reusing tracking comment failed", logging.KeyRepo, t.owner+"/"+t.repo, logging.KeyPhase, "comment", "number", t.number, "err", err)
+		}
+		if id != 0 {
+			t.commentID = id
+			return id, nil
+		}
+		// 新的协调评论从空的运行记录开始，下一次运行据此找到它
+		body = WithHistory(body, historyStart)
+	}
+	id, err := createInitialComment(ctx, t.client, t.owner, t.repo, t.number, body)
 	if err != nil {
 		return 0, err
 	}
@@ -53,3 +83,53 @@ func (t *Tracker) Update(ctx context.Context, body string) error {
 
 // GetCommentID 获取当前评论 ID
 func (t *Tracker) GetCommentID() int64 { return t.commentID }
+
+// reuse 找到 Issue/PR 上最近的协调评论；它的运行已结束时把运行移入运行记录、
+// 评论改回初始内容并返回其 ID。最近的评论仍在运行时返回 0
+func (t *Tracker) reuse(ctx context.Context) (int64, error) {
+	prev, err := t.latestTracking(ctx)
+	if err != nil || prev == nil {
+		return 0, err
+	}
+	if _, _, done := parseOutcome(prev.GetBody()); !done {
+		return 0, nil
+	}
+	body := WithHistory(formatInitialBody(t.lang), archive(prev.GetBody(), t.lang))
+	if _, _, err := t.client.Issues.EditComment(ctx, t.owner, t.repo, prev.GetID(), &github.IssueComment{Body: &body}); err != nil {
+		return 0, err
+	}
+	return prev.GetID(), nil
+}
+
+// latestTracking 返回 Issue/PR 上最近一条带运行记录的机器人评论，没有时为 nil。
+// 评论按时间先后分页，所以从最后一页往前找
+func (t *Tracker) latestTracking(ctx context.Context) (*github.IssueComment, error) {
+	list := func(page int) ([]*github.IssueComment, *github.Response, error) {
+		return t.client.Issues.ListComments(ctx, t.owner, t.repo, t.number, &github.IssueListCommentsOptions{
+			ListOptions: github.ListOptions{Page: page, PerPage: 100},
+		})
+	}
+	first, resp, err := list(1)
+	if err != nil {
+		return nil, err
+	}
+	last := 1
+	if resp != nil && resp.LastPage > 1 {
+		last = resp.LastPage
+	}
+	for page := last; page >= 1 && page > last-maxScanPages; page-- {
+		comments := first
+		if page != 1 {
+			if comments, _, err = list(page); err != nil {
+				return nil, err
+			}
+		}
+		for i := len(comments) - 1; i >= 0; i-- {
+			c := comments[i]
+			if c.GetUser().GetType() == "Bot" && strings.Contains(c.GetBody(), historyStart) {
+				return c, nil
+			}
+		}
+	}
+	return nil, nil
+}
@@ -4,10 +4,11 @@ import (
 	"context"
 	"strings"
 	"testing"
+	"time"
 )
 
 func TestFormatInitialBody(t *testing.T) {
-	body := formatInitialBody()
+	body := formatInitialBody(English)
 
 	if !strings.Contains(body, "img src=") {
 		t.Error("Initial body should contain spinner image")
@@ -18,4 +19,67 @@ func TestFormatInitialBody(t *testing.T) {
 	}
 }
 
+func TestQueuedBody(t *testing.T) {
+	tests := []struct {
+		eta  time.Duration
+		want string
+	}{
+		{0, "Queued: position #3 in queue..."},
+		{20 * time.Second, "Queued: position #3 in queue, starting in less than a minute..."},
+		{9*time.Minute + 40*time.Second, "Queued: position #3 in queue, starting in about 10 min..."},
+	}
+	for _, tt := range tests {
+		body := QueuedBody(English, 3, tt.eta)
+		if !strings.HasPrefix(body, "<img src=") || !strings.HasSuffix(body, tt.want) {
+			t.Errorf("QueuedBody(3, %v) = %q, want spinner + %q", tt.eta, body, tt.want)
+		}
+	}
+}
+
 var _ = context.TODO
+
+func TestWithFooter(t *testing.T) {
+	body := WithFooter("Done.", "v1.2.3 (abc1234)")
+	if body != "Done.\n\n"+footerStart+"\n<sub>swe-agent v1.2.3 (abc1234)</sub>" {
+		t.Fatalf("WithFooter = %q", body)
+	}
+	if again := WithFooter(body, "v1.2.4"); strings.Count(again, footerStart) != 1 || !strings.HasSuffix(again, "v1.2.4</sub>") {
+		t.Fatalf("footer not replaced: %q", again)
+	}
+	if WithFooter(body, "") != "Done." {
+		t.Fatal("an empty version removes the footer")
+	}
+	if !strings.Contains(formatInitialBody(English), footerStart) {
+		t.Fatal("initial body has no version footer")
+	}
+}
+
+func TestLang(t *testing.T) {
+	for text, want := range map[string]Lang{
+		"/code fix the flaky test":         English,
+		"/code 修复 bug":                     Chinese,
+		"/code 修复 auth.go 中的空指针":           Chinese,
+		"/code fix 张三's bug in the parser": English,
+		"":                                 English,
+	} {
+		if got := DetectLang(text); got != want {
+			t.Errorf("DetectLang(%q) = %q, want %q", text, got, want)
+		}
+	}
+	if got := ResolveLang("en", "修复这个问题"); got != English {
+		t.Errorf("the setting overrides detection, got %q", got)
+	}
+	if got := ResolveLang("auto", "修复这个问题"); got != Chinese {
+		t.Errorf("auto detects, got %q", got)
+	}
+	if _, err := ParseLang("fr"); err == nil {
+		t.Error("ParseLang accepted fr")
+	}
+
+	if body := QueuedBody(Chinese, 2, 5*time.Minute); !strings.HasSuffix(body, "排队中：队列第 2 位，预计约 5 分钟后开始...") {
+		t.Errorf("QueuedBody(zh) = %q", body)
+	}
+	if !strings.Contains(InitialBody(Chinese), "正在处理你的请求") || !strings.Contains(InitialBody(""), "Working on your request") {
+		t.Error("InitialBody is not localized")
+	}
+}
@@ -135,3 +135,21 @@ func TestUpdateComment_HTTPErrors(t *testing.T) {
 		})
 	}
 }
+
+func TestGetComment_Validation(t *testing.T) {
+	if _, err := GetComment("owner", "repo", 1, ""); err == nil || err.Error() != "github token is required" {
+		t.Errorf("missing token: got %v", err)
+	}
+	if _, err := GetComment("owner", "repo", 0, "token"); err == nil || err.Error() != "invalid comment ID: 0" {
+		t.Errorf("invalid id: got %v", err)
+	}
+}
+
+func TestCreateComment_Validation(t *testing.T) {
+	if _, err := CreateComment("owner", "repo", 1, "hi", ""); err == nil || err.Error() != "github token is required" {
+		t.Errorf("missing token: got %v", err)
+	}
+	if _, err := CreateComment("owner", "repo", 0, "hi", "token"); err == nil || err.Error() != "invalid issue number: 0" {
+		t.Errorf("invalid number: got %v", err)
+	}
+}
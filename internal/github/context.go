@@ -7,6 +7,7 @@ import (
 	"strings"
 	"time"
 
+	"github.com/cexll/swe/internal/policy"
 	gh "github.com/google/go-github/v66/github"
 )
 
@@ -68,6 +69,60 @@ type Context struct {
 	PreparedBranch     string
 	PreparedBaseBranch string
 	PreparedCommentID  int64
+	// PreparedRelease is the confirmed version bump ("patch", "minor" or
+	// "major") when the task is a release rather than a code change
+	PreparedRelease string
+	// PreparedTriage makes the task triage the issue (/triage): suggest and
+	// apply labels, look for duplicates and assign a priority
+	PreparedTriage bool
+	// PreparedApprovalCommand makes the task plan-only: nothing is pushed
+	// until a second user comments this command ("/code approve")
+	PreparedApprovalCommand string
+	// PreparedApprovedPlan is the approved plan the task carries out, and
+	// PreparedApprovedBy the user who approved it
+	PreparedApprovedPlan string
+	PreparedApprovedBy   string
+	// PreparedApplyCommand makes the task a dry run: nothing is pushed and
+	// this command ("/code apply") pushes the changes later
+	PreparedApplyCommand string
+	// PreparedApplyTaskID is the dry run task whose changes the task pushes
+	PreparedApplyTaskID string
+	// PreparedAddressReviews makes the task address the pull request's
+	// unresolved review threads (/code address-reviews)
+	PreparedAddressReviews bool
+	// PreparedBackport is the branch the task backports the merged pull
+	// request to (/code backport to release-1.2)
+	PreparedBackport string
+	// PreparedUpdateDeps makes the task update the dependencies with the
+	// repository's package managers and open a pull request (/code update-deps)
+	PreparedUpdateDeps bool
+	// PreparedTimeout is the run time the task asked for (/code --timeout);
+	// 0 uses the configured default.
+	PreparedTimeout time.Duration
+	// PreparedDebug raises the task's log level and keeps the provider's
+	// stderr and the git output as a debug log artifact (/code --debug)
+	PreparedDebug bool
+	// PreparedLang is the language of the text the server writes to the
+	// tracking comment ("en" or "zh"; empty is English)
+	PreparedLang string
+	// PreparedCommentHistory makes the tracking comment one reused across
+	// the runs on the issue, keeping a history of the earlier ones
+	PreparedCommentHistory bool
+	// PreparedPolicyInput is the event the policy authorized the task for;
+	// the push rules are evaluated against it (nil: built from the event)
+	PreparedPolicyInput *policy.Input
+	// PreparedHoldCommand is the comment that applies the commits a push
+	// rule held, e.g. "/code apply"; empty when nothing can apply them
+	PreparedHoldCommand string
+	// PreparedConfirmCommand makes the task stop before the provider runs
+	// when its estimated cost needs confirming: the comment that confirms
+	// it, e.g. "/code confirm"; empty runs it whatever the estimate
+	PreparedConfirmCommand string
+
+	// TaskID identifies the dispatcher task driving this execution (optional)
+	TaskID string
+	// DeliveryID is the webhook delivery that created the task (optional)
+	DeliveryID string
 
 	// Token (optional): provider/executor may populate for MCP tools
 	Token string
@@ -0,0 +1,163 @@
+package data
+
+import (
+	"context"
+	"fmt"
+	"strings"
+	"sync"
+	"time"
+)
+
+// maxCachedFetches bounds the fetch cache; the oldest entry goes first.
+const maxCachedFetches = 256
+
+// fetchCache keeps the last FetchResult per issue or pull request, with
+// the updatedAt GitHub reported for it, so a task on an unchanged thread
+// reuses it after a single cheap query instead of paging through
+// everything again.
+type fetchCache struct {
+	mu      sync.Mutex
+	ttl     time.Duration // 0 disables the cache
+	entries map[string]cachedFetch
+	now     func() time.Time // allow tests to stub the clock
+}
+
+type cachedFetch struct {
+	updatedAt   string
+	result      *FetchResult
+	triggerUser string
+	fetchedAt   time.Time
+}
+
+func newFetchCache(ttl time.Duration) *fetchCache {
+	return &fetchCache{ttl: ttl, entries: make(map[string]cachedFetch), now: time.Now}
+}
+
+func fetchKey(repo string, number int) string {
+	return fmt.Sprintf("%s#%d", strings.ToLower(repo), number)
+}
+
+func (c *fetchCache) setTTL(ttl time.Duration) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	c.ttl = ttl
+	if ttl <= 0 {
+		c.entries = make(map[string]cachedFetch)
+	}
+}
+
+// get returns the entry for repo#number while it is younger than the TTL.
+func (c *fetchCache) get(repo string, number int) (cachedFetch, bool) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	key := fetchKey(repo, number)
+	e, ok := c.entries[key]
+	if !ok || c.ttl <= 0 {
+		return cachedFetch{}, false
+	}
+	if c.now().Sub(e.fetchedAt) >= c.ttl {
+		delete(c.entries, key)
+		return cachedFetch{}, false
+	}
+	return e, true
+}
+
+func (c *fetchCache) put(repo string, number int, e cachedFetch) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	if c.ttl <= 0 || e.updatedAt == "" {
+		return
+	}
+	e.fetchedAt = c.now()
+	key := fetchKey(repo, number)
+	if _, ok := c.entries[key]; !ok && len(c.entries) >= maxCachedFetches {
+		oldest := ""
+		for k, v := range c.entries {
+			if oldest == "" || v.fetchedAt.Before(c.entries[oldest].fetchedAt) {
+				oldest = k
+			}
+		}
+		delete(c.entries, oldest)
+	}
+	c.entries[key] = e
+}
+
+func (c *fetchCache) invalidate(repo string, number int) bool {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	key := fetchKey(repo, number)
+	_, ok := c.entries[key]
+	delete(c.entries, key)
+	return ok
+}
+
+// fetchedUpdatedAt returns the updatedAt of the issue or pull request r
+// holds, or "".
+func fetchedUpdatedAt(r *FetchResult) string {
+	switch d := r.ContextData.(type) {
+	case PullRequest:
+		return d.UpdatedAt
+	case Issue:
+		return d.UpdatedAt
+	}
+	return ""
+}
+
+// hasComment reports whether r holds the comment or review comment with
+// the given database ID.
+func hasComment(r *FetchResult, id int64) bool {
+	for _, c := range r.Comments {
+		if int64(c.DatabaseID) == id {
+			return true
+		}
+	}
+	if r.Reviews != nil {
+		for _, rv := range r.Reviews.Nodes {
+			for _, c := range rv.Comments.Nodes {
+				if int64(c.DatabaseID) == id {
+					return true
+				}
+			}
+		}
+	}
+	return false
+}
+
+type updatedAtQueryResponse struct {
+	Repository struct {
+		IssueOrPullRequest struct {
+			UpdatedAt string `json:"updatedAt"`
+		} `json:"issueOrPullRequest"`
+	} `json:"repository"`
+}
+
+// fetchUpdatedAt asks GitHub when the issue or pull request last changed.
+func fetchUpdatedAt(ctx context.Context, c *Client, repository string, number int) (string, error) {
+	owner, repo, err := splitRepo(repository)
+	if err != nil {
+		return "", err
+	}
+	var resp updatedAtQueryResponse
+	if err := c.Do(ctx, repository, updatedAtQuery, map[string]interface{}{
+		"owner":  owner,
+		"repo":   repo,
+		"number": number,
+	}, &resp); err != nil {
+		return "", err
+	}
+	return resp.Repository.IssueOrPullRequest.UpdatedAt, nil
+}
+
+const updatedAtQuery = `query UpdatedAt($owner: String!, $repo: String!, $number: Int!) {
+  rateLimit {
+    cost
+    remaining
+    resetAt
+  }
+  repository(owner: $owner, name: $repo) {
+    issueOrPullRequest(number: $number) {
+      ... on Issue { updatedAt }
+      ... on PullRequest { updatedAt }
+    }
+  }
+}`
@@ -0,0 +1,128 @@
+package data
+
+import (
+	"context"
+	"strings"
+	"testing"
+	"time"
+
+	gh "github.com/cexll/swe/internal/github"
+)
+
+func TestFetcher_CachesUntilChanged(t *testing.T) {
+	updatedAt := "2026-01-01T00:00:00Z"
+	var full, probes int
+	ts := newGraphQLServer(t, func(query string, vars map[string]any) (int, any) {
+		switch {
+		case strings.Contains(query, "query UpdatedAt"):
+			probes++
+			return 200, map[string]any{"data": map[string]any{"repository": map[string]any{
+				"issueOrPullRequest": map[string]any{"updatedAt": updatedAt},
+			}}}
+		case strings.Contains(query, "query Issue"):
+			full++
+			return 200, map[string]any{"data": map[string]any{"repository": map[string]any{"issue": map[string]any{
+				"title":     "Bug",
+				"updatedAt": updatedAt,
+				"comments": map[string]any{"nodes": []any{
+					map[string]any{"id": "c", "databaseId": 7, "body": "/code fix", "author": map[string]any{"login": "u"}},
+				}},
+			}}}}
+		case strings.Contains(query, "User("):
+			return 200, map[string]any{"data": map[string]any{"user": map[string]any{"name": "Name of " + vars["login"].(string)}}}
+		}
+		t.Fatalf("unexpected query: %s", query)
+		return 200, nil
+	})
+	defer ts.Close()
+	c := NewClient(fakeAuth2{})
+	c.endpoint = ts.URL
+	f := NewFetcher(c)
+	f.SetCache(time.Hour)
+
+	gctx := &gh.Context{
+		Repository:     gh.Repository{FullName: "O/R"},
+		IssueNumber:    3,
+		TriggerUser:    "u",
+		TriggerComment: &gh.Comment{ID: 7},
+	}
+	fetch := func() *FetchResult {
+		t.Helper()
+		res, err := f.Fetch(context.Background(), gctx)
+		if err != nil {
+			t.Fatalf("Fetch: %v", err)
+		}
+		return res
+	}
+
+	first := fetch()
+	first.Patches = map[string]string{"x": "y"} // callers' changes stay theirs
+	second := fetch()
+	if full != 1 || probes != 1 || second == first || second.Patches != nil || len(second.Comments) != 1 {
+		t.Fatalf("unchanged issue: full=%d probes=%d", full, probes)
+	}
+	if *second.TriggerName != "Name of u" {
+		t.Fatalf("TriggerName = %q", *second.TriggerName)
+	}
+
+	// another trigger user gets their own display name
+	gctx.TriggerUser = "v"
+	if res := fetch(); full != 1 || *res.TriggerName != "Name of v" {
+		t.Fatalf("other user: full=%d name=%q", full, *res.TriggerName)
+	}
+
+	// an update is fetched again
+	updatedAt = "2026-01-02T00:00:00Z"
+	fetch()
+	if full != 2 || probes != 3 {
+		t.Fatalf("updated issue: full=%d probes=%d", full, probes)
+	}
+
+	// a trigger comment the cached data lacks is fetched without probing
+	gctx.TriggerComment = &gh.Comment{ID: 8}
+	fetch()
+	if full != 3 || probes != 3 {
+		t.Fatalf("new comment: full=%d probes=%d", full, probes)
+	}
+
+	// so is invalidated data
+	gctx.TriggerComment = &gh.Comment{ID: 7}
+	f.Invalidate("o/r", 3)
+	fetch()
+	if full != 4 || probes != 3 {
+		t.Fatalf("invalidated: full=%d probes=%d", full, probes)
+	}
+
+	// and expired data
+	f.cache.now = func() time.Time { return time.Now().Add(2 * time.Hour) }
+	fetch()
+	if full != 5 || probes != 3 {
+		t.Fatalf("expired: full=%d probes=%d", full, probes)
+	}
+
+	// without a cache every task fetches everything
+	f.SetCache(0)
+	fetch()
+	fetch()
+	if full != 7 || probes != 3 {
+		t.Fatalf("disabled: full=%d probes=%d", full, probes)
+	}
+}
+
+func TestFetchCache_EvictsOldest(t *testing.T) {
+	c := newFetchCache(time.Hour)
+	now := time.Now()
+	c.now = func() time.Time { now = now.Add(time.Second); return now }
+	for i := 0; i <= maxCachedFetches; i++ {
+		c.put("o/r", i, cachedFetch{updatedAt: "t", result: &FetchResult{}})
+	}
+	if len(c.entries) != maxCachedFetches {
+		t.Fatalf("entries = %d", len(c.entries))
+	}
+	if _, ok := c.get("o/r", 0); ok {
+		t.Fatal("oldest entry kept")
+	}
+	if _, ok := c.get("o/r", maxCachedFetches); !ok {
+		t.Fatal("newest entry dropped")
+	}
+}
@@ -6,10 +6,13 @@ import (
 	"encoding/json"
 	"fmt"
 	"io"
+	"log/slog"
 	"net/http"
+	"strings"
 	"time"
 
 	gh "github.com/cexll/swe/internal/github"
+	"github.com/cexll/swe/internal/logging"
 )
 
 // Client is a thin GitHub GraphQL client that acquires
@@ -19,6 +22,11 @@ type Client struct {
 	httpClient   *http.Client
 	endpoint     string
 	authProvider gh.AuthProvider
+
+	limits rateLimits // rate-limit budget per owner
+	// allow tests to stub the clock
+	now   func() time.Time
+	sleep func(context.Context, time.Duration) error
 }
 
 // NewClient creates a GraphQL client using the provided auth provider.
@@ -27,6 +35,8 @@ func NewClient(auth gh.AuthProvider) *Client {
 		httpClient:   &http.Client{Timeout: 20 * time.Second},
 		endpoint:     "https://api.github.com/graphql",
 		authProvider: auth,
+		now:          time.Now,
+		sleep:        sleepContext,
 	}
 }
 
@@ -39,10 +49,16 @@ type GraphQLRequest struct {
 // Do executes a GraphQL POST to GitHub's API using an installation token
 // for the given repository ("owner/repo"). The response body is decoded
 // into out; if GitHub returns errors, an error is produced with details.
+//
+// Network errors, server errors and rate limits are retried up to
+// maxAttempts times, waiting as long as Retry-After or the rate-limit reset
+// say, else backing off exponentially. While the owner's remaining budget
+// is below minRemaining, requests wait for it to reset.
 func (c *Client) Do(ctx context.Context, repo, query string, variables map[string]interface{}, out interface{}) error {
 	if repo == "" {
 		return fmt.Errorf("repo is required (owner/repo)")
 	}
+	owner, _, _ := strings.Cut(repo, "/")
 
 	token, err := c.authProvider.GetInstallationToken(repo)
 	if err != nil {
@@ -50,58 +66,90 @@ func (c *Client) Do(ctx context.Context, repo, query string, variables map[strin
 	}
 
 	reqBody := GraphQLRequest{Query: query, Variables: variables}
-	buf := &bytes.Buffer{}
-	if err := json.NewEncoder(buf).Encode(reqBody); err != nil {
+	payload, err := json.Marshal(reqBody)
+	if err != nil {
 		return fmt.Errorf("encode graphql request: %w", err)
 	}
 
-	req, err := http.NewRequestWithContext(ctx, http.MethodPost, c.endpoint, buf)
+	for attempt := 1; ; attempt++ {
+		if err := c.waitForBudget(ctx, owner); err != nil {
+			return err
+		}
+		data, header, err := c.post(ctx, owner, token.Token, payload)
+		if err == nil {
+			if out != nil {
+				if err := json.Unmarshal(data, out); err != nil {
+					return fmt.Errorf("decode graphql data: %w", err)
+				}
+			}
+			return nil
+		}
+		if !isRetryable(err) || attempt >= maxAttempts || ctx.Err() != nil {
+			return err
+		}
+		delay := c.retryDelay(attempt, header)
+		if delay > maxPause {
+			return fmt.Errorf("%w (retry in %v)", err, delay.Round(time.Second))
+		}
+		slog.Warn("GraphQL attempt failed; retrying", logging.KeyRepo, repo, logging.KeyPhase, "graphql", "attempt", attempt, "max_attempts", maxAttempts, "delay", delay, "err", err)
+		if err := c.sleep(ctx, delay); err != nil {
+			return err
+		}
+	}
+}
+
+// post sends one GraphQL request and returns its data. Failures worth
+// retrying are retryableErrors.
+func (c *Client) post(ctx context.Context, owner, token string, payload []byte) (json.RawMessage, http.Header, error) {
+	req, err := http.NewRequestWithContext(ctx, http.MethodPost, c.endpoint, bytes.NewReader(payload))
 	if err != nil {
-		return fmt.Errorf("create request: %w", err)
+		return nil, nil, fmt.Errorf("create request: %w", err)
 	}
-	req.Header.Set("Authorization", "Bearer "+token.Token)
+	req.Header.Set("Authorization", "Bearer "+token)
 	req.Header.Set("Accept", "application/vnd.github+json")
 	req.Header.Set("Content-Type", "application/json")
 	req.Header.Set("X-GitHub-Api-Version", "2022-11-28")
 
 	resp, err := c.httpClient.Do(req)
 	if err != nil {
-		return fmt.Errorf("graphql http error: %w", err)
+		return nil, nil, retryableError{fmt.Errorf("graphql http error: %w", err)}
 	}
 	defer func() { _ = resp.Body.Close() }()
 
 	body, err := io.ReadAll(resp.Body)
 	if err != nil {
-		return fmt.Errorf("read response: %w", err)
+		return nil, resp.Header, retryableError{fmt.Errorf("read response: %w", err)}
 	}
 	if resp.StatusCode != http.StatusOK {
-		return fmt.Errorf("graphql status %d: %s", resp.StatusCode, string(body))
+		c.recordRateLimit(owner, resp.Header, nil)
+		if retryableStatus(resp.StatusCode, resp.Header, body) {
+			return nil, resp.Header, retryableError{fmt.Errorf("graphql status %d: %s", resp.StatusCode, string(body))}
+		}
+		return nil, resp.Header, fmt.Errorf("graphql status %d: %s", resp.StatusCode, string(body))
 	}
 
 	var wrapper struct {
 		Data   json.RawMessage `json:"data"`
 		Errors []struct {
+			Type    string `json:"type"`
 			Message string `json:"message"`
 		} `json:"errors"`
 	}
 	if err := json.Unmarshal(body, &wrapper); err != nil {
-		return fmt.Errorf("decode graphql envelope: %w", err)
+		return nil, resp.Header, fmt.Errorf("decode graphql envelope: %w", err)
 	}
+	c.recordRateLimit(owner, resp.Header, wrapper.Data)
 	if len(wrapper.Errors) > 0 {
 		// return the first error message to keep it simple
-		return fmt.Errorf("graphql error: %s", wrapper.Errors[0].Message)
+		if wrapper.Errors[0].Type == "RATE_LIMITED" {
+			return nil, resp.Header, retryableError{fmt.Errorf("graphql error: %s", wrapper.Errors[0].Message)}
+		}
+		return nil, resp.Header, fmt.Errorf("graphql error: %s", wrapper.Errors[0].Message)
 	}
 	if len(wrapper.Data) == 0 {
 		// Some queries legitimately have null data. We still try to decode.
 		// If a "data" field is absent, decode against JSON null to avoid EOF.
-		if out != nil {
-			wrapper.Data = json.RawMessage("null")
-		}
-	}
-	if out != nil {
-		if err := json.Unmarshal(wrapper.Data, out); err != nil {
-			return fmt.Errorf("decode graphql data: %w", err)
-		}
+		wrapper.Data = json.RawMessage("null")
 	}
-	return nil
+	return wrapper.Data, resp.Header, nil
 }
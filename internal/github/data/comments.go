@@ -0,0 +1,74 @@
+package data
+
+import (
+	"context"
+	"fmt"
+)
+
+// IssueComment is a comment on an issue or pull request conversation.
+type IssueComment struct {
+	ID          string `json:"id"` // GraphQL node ID
+	DatabaseID  int64  `json:"databaseId"`
+	Body        string `json:"body"`
+	IsMinimized bool   `json:"isMinimized"`
+	// ViewerDidAuthor is set on the comments the app wrote
+	ViewerDidAuthor bool `json:"viewerDidAuthor"`
+}
+
+type recentCommentsResponse struct {
+	Repository struct {
+		IssueOrPullRequest struct {
+			Comments struct {
+				Nodes []IssueComment `json:"nodes"`
+			} `json:"comments"`
+		} `json:"issueOrPullRequest"`
+	} `json:"repository"`
+}
+
+// RecentComments returns the last 100 comments on issue or pull request
+// number of repo ("owner/repo"), oldest first.
+func (c *Client) RecentComments(ctx context.Context, repo string, number int) ([]IssueComment, error) {
+	owner, name, err := splitRepo(repo)
+	if err != nil {
+		return nil, err
+	}
+	var resp recentCommentsResponse
+	if err := c.Do(ctx, repo, recentCommentsQuery, map[string]interface{}{
+		"owner":  owner,
+		"repo":   name,
+		"number": number,
+	}, &resp); err != nil {
+		return nil, fmt.Errorf("fetch comments: %w", err)
+	}
+	return resp.Repository.IssueOrPullRequest.Comments.Nodes, nil
+}
+
+// MinimizeComment hides comment nodeID of repo as outdated; it stays one
+// click away.
+func (c *Client) MinimizeComment(ctx context.Context, repo, nodeID string) error {
+	if err := c.Do(ctx, repo, minimizeCommentMutation, map[string]interface{}{
+		"id": nodeID,
+	}, nil); err != nil {
+		return fmt.Errorf("minimize comment %s: %w", nodeID, err)
+	}
+	return nil
+}
+
+const recentCommentsQuery = `query RecentComments($owner: String!, $repo: String!, $number: Int!) {
+  repository(owner: $owner, name: $repo) {
+    issueOrPullRequest(number: $number) {
+      ... on Issue {
+        comments(last: 100) { nodes { id databaseId body isMinimized viewerDidAuthor } }
+      }
+      ... on PullRequest {
+        comments(last: 100) { nodes { id databaseId body isMinimized viewerDidAuthor } }
+      }
+    }
+  }
+}`
+
+const minimizeCommentMutation = `mutation MinimizeComment($id: ID!) {
+  minimizeComment(input: {subjectId: $id, classifier: OUTDATED}) {
+    minimizedComment { isMinimized }
+  }
+}`
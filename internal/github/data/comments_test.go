@@ -0,0 +1,46 @@
+package data
+
+import (
+	"context"
+	"strings"
+	"testing"
+)
+
+func TestRecentCommentsAndMinimize(t *testing.T) {
+	var minimized []string
+	ts := newGraphQLServer(t, func(query string, vars map[string]any) (int, any) {
+		switch {
+		case strings.Contains(query, "minimizeComment"):
+			if !strings.Contains(query, "classifier: OUTDATED") {
+				t.Fatalf("unexpected classifier in %q", query)
+			}
+			minimized = append(minimized, vars["id"].(string))
+			return 200, map[string]any{"data": map[string]any{}}
+		case strings.Contains(query, "issueOrPullRequest"):
+			if vars["owner"] != "o" || vars["repo"] != "r" || vars["number"] != float64(7) {
+				t.Fatalf("unexpected variables %v", vars)
+			}
+			nodes := []any{
+				map[string]any{"id": "IC_1", "databaseId": 11, "body": "old", "isMinimized": false, "viewerDidAuthor": true},
+				map[string]any{"id": "IC_2", "databaseId": 12, "body": "/code", "isMinimized": false, "viewerDidAuthor": false},
+			}
+			return 200, map[string]any{"data": map[string]any{"repository": map[string]any{"issueOrPullRequest": map[string]any{"comments": map[string]any{"nodes": nodes}}}}}
+		}
+		t.Fatalf("unexpected query %q", query)
+		return 0, nil
+	})
+	defer ts.Close()
+	client := NewClient(fakeAuth2{})
+	client.endpoint = ts.URL
+
+	comments, err := client.RecentComments(context.Background(), "o/r", 7)
+	if err != nil || len(comments) != 2 || comments[0].DatabaseID != 11 || !comments[0].ViewerDidAuthor || comments[1].ViewerDidAuthor {
+		t.Fatalf("RecentComments = %+v, %v", comments, err)
+	}
+	if err := client.MinimizeComment(context.Background(), "o/r", "IC_1"); err != nil {
+		t.Fatal(err)
+	}
+	if strings.Join(minimized, ",") != "IC_1" {
+		t.Fatalf("minimized = %v", minimized)
+	}
+}
@@ -86,6 +86,18 @@ type Review struct {
 	Comments     ReviewCommentsConnection `json:"comments"`
 }
 
+// LinkedIssue is an issue a pull request closes when merged ("Fixes #123").
+type LinkedIssue struct {
+	Number     int    `json:"number"`
+	Title      string `json:"title"`
+	Body       string `json:"body"`
+	State      string `json:"state"`
+	URL        string `json:"url"`
+	Repository struct {
+		NameWithOwner string `json:"nameWithOwner"`
+	} `json:"repository"`
+}
+
 type PullRequest struct {
 	Title       string `json:"title"`
 	Body        string `json:"body"`
@@ -94,6 +106,7 @@ type PullRequest struct {
 	HeadRefName string `json:"headRefName"`
 	HeadRefOID  string `json:"headRefOid"`
 	CreatedAt   string `json:"createdAt"`
+	UpdatedAt   string `json:"updatedAt,omitempty"`
 	Additions   int    `json:"additions"`
 	Deletions   int    `json:"deletions"`
 	State       string `json:"state"`
@@ -106,6 +119,11 @@ type PullRequest struct {
 	Files    FilesConnection    `json:"files"`
 	Comments CommentsConnection `json:"comments"`
 	Reviews  ReviewsConnection  `json:"reviews"`
+	// ClosingIssuesReferences are the issues the pull request closes, by
+	// closing keywords in its body or linked by hand
+	ClosingIssuesReferences struct {
+		Nodes []LinkedIssue `json:"nodes"`
+	} `json:"closingIssuesReferences"`
 }
 
 type Issue struct {
@@ -113,6 +131,7 @@ type Issue struct {
 	Body      string             `json:"body"`
 	Author    Author             `json:"author"`
 	CreatedAt string             `json:"createdAt"`
+	UpdatedAt string             `json:"updatedAt,omitempty"`
 	State     string             `json:"state"`
 	Comments  CommentsConnection `json:"comments"`
 }
@@ -225,6 +244,10 @@ type FetchParams struct {
 	IsPR            bool
 	TriggerUsername string
 	TriggerTime     string // RFC3339, optional
+	// Workdir is a checkout of the pull request's head the blob SHAs of the
+	// changed files are computed in; "" marks them "unknown" (compute them
+	// with ChangedFilesWithSHA once there is a checkout)
+	Workdir string
 }
 
 type FetchResult struct {
@@ -232,6 +255,8 @@ type FetchResult struct {
 	Comments    []Comment                 // Issue/PR comments
 	Changed     []File                    // Changed files (PR only)
 	ChangedSHA  []GitHubFileWithSHA       // Changed files with SHA (PR only)
+	Patches     map[string]string         // Diff hunks by path (small PRs only)
+	Linked      []LinkedIssue             // Issues the PR closes (PR only)
 	Reviews     *struct{ Nodes []Review } // May be nil if not PR
 	ImageURLMap map[string]string         // Placeholder: no downloads in Go path
 	TriggerName *string                   // Display name if available
@@ -249,6 +274,7 @@ func FetchGitHubData(ctx context.Context, p FetchParams) (*FetchResult, error) {
 		comments []Comment
 		files    []File
 		reviews  *struct{ Nodes []Review }
+		linked   []LinkedIssue
 	)
 
 	if p.IsPR {
@@ -263,6 +289,7 @@ func FetchGitHubData(ctx context.Context, p FetchParams) (*FetchResult, error) {
 		}
 		pr := prResp.Repository.PullRequest
 		ctxData = pr
+		linked = pr.ClosingIssuesReferences.Nodes
 
 		// Fetch files with pagination
 		files = pr.Files.Nodes
@@ -336,18 +363,7 @@ func FetchGitHubData(ctx context.Context, p FetchParams) (*FetchResult, error) {
 	// Compute SHAs for changed files on PRs
 	var withSHA []GitHubFileWithSHA
 	if p.IsPR {
-		for _, f := range files {
-			if strings.EqualFold(f.ChangeType, "DELETED") {
-				withSHA = append(withSHA, GitHubFileWithSHA{File: f, SHA: "deleted"})
-				continue
-			}
-			sha, err := gitHashObject(f.Path)
-			if err != nil {
-				withSHA = append(withSHA, GitHubFileWithSHA{File: f, SHA: "unknown"})
-				continue
-			}
-			withSHA = append(withSHA, GitHubFileWithSHA{File: f, SHA: sha})
-		}
+		withSHA = ChangedFilesWithSHA(p.Workdir, files)
 	}
 
 	// Try obtain display name for trigger user if provided
@@ -364,6 +380,7 @@ func FetchGitHubData(ctx context.Context, p FetchParams) (*FetchResult, error) {
 		Comments:    comments,
 		Changed:     files,
 		ChangedSHA:  withSHA,
+		Linked:      linked,
 		Reviews:     reviews,
 		ImageURLMap: map[string]string{},
 		TriggerName: triggerName,
@@ -378,9 +395,29 @@ func splitRepo(repository string) (string, string, error) {
 	return parts[0], parts[1], nil
 }
 
-func gitHashObject(path string) (string, error) {
+// ChangedFilesWithSHA pairs files with their blob SHAs in the checkout at
+// workdir: "deleted" for deleted files, "unknown" for files missing from it
+// or without a checkout ("").
+func ChangedFilesWithSHA(workdir string, files []File) []GitHubFileWithSHA {
+	out := make([]GitHubFileWithSHA, 0, len(files))
+	for _, f := range files {
+		sha := "unknown"
+		if strings.EqualFold(f.ChangeType, "DELETED") {
+			sha = "deleted"
+		} else if workdir != "" {
+			if s, err := gitHashObject(workdir, f.Path); err == nil {
+				sha = s
+			}
+		}
+		out = append(out, GitHubFileWithSHA{File: f, SHA: sha})
+	}
+	return out
+}
+
+// gitHashObject returns the blob SHA of path, relative to workdir.
+func gitHashObject(workdir, path string) (string, error) {
 	// Shells out to `git hash-object <path>` to match TS logic.
-	out, err := exec.Command("git", "hash-object", path).CombinedOutput()
+	out, err := exec.Command("git", "-C", workdir, "hash-object", "--", path).CombinedOutput()
 	if err != nil {
 		return "", fmt.Errorf("git hash-object failed: %v, output: %s", err, string(out))
 	}
@@ -574,12 +611,18 @@ func fetchAllReviewComments(ctx context.Context, c *Client, repo, reviewID, curs
 // GraphQL queries
 
 const issueQuery = `query Issue($owner: String!, $repo: String!, $number: Int!) {
+  rateLimit {
+    cost
+    remaining
+    resetAt
+  }
   repository(owner: $owner, name: $repo) {
     issue(number: $number) {
       title
       body
       author { login }
       createdAt
+      updatedAt
       state
       comments(first: 100) {
         pageInfo { hasNextPage endCursor }
@@ -599,6 +642,11 @@ const issueQuery = `query Issue($owner: String!, $repo: String!, $number: Int!)
 }`
 
 const prQuery = `query PullRequest($owner: String!, $repo: String!, $number: Int!) {
+  rateLimit {
+    cost
+    remaining
+    resetAt
+  }
   repository(owner: $owner, name: $repo) {
     pullRequest(number: $number) {
       title
@@ -608,6 +656,7 @@ const prQuery = `query PullRequest($owner: String!, $repo: String!, $number: Int
       headRefName
       headRefOid
       createdAt
+      updatedAt
       additions
       deletions
       state
@@ -619,6 +668,16 @@ const prQuery = `query PullRequest($owner: String!, $repo: String!, $number: Int
         pageInfo { hasNextPage endCursor }
         nodes { path additions deletions changeType }
       }
+      closingIssuesReferences(first: 10) {
+        nodes {
+          number
+          title
+          body
+          state
+          url
+          repository { nameWithOwner }
+        }
+      }
       comments(first: 100) {
         pageInfo { hasNextPage endCursor }
         nodes {
@@ -1393,10 +1393,13 @@ func TestFetchGitHubData_PR(t *testing.T) {
 				"baseRefName": "main", "headRefName": "f", "headRefOid": "deadbeef", "createdAt": "t", "additions": 1, "deletions": 0, "state": "OPEN",
 				"commits": map[string]any{"totalCount": 1, "nodes": []any{map[string]any{"commit": map[string]any{"oid": "c", "message": "m", "author": map[string]any{"name": "n", "email": "e"}}}}},
 				"files": map[string]any{"nodes": []any{
-					map[string]any{"path": fpath, "additions": 1, "deletions": 0, "changeType": "MODIFIED"},
+					map[string]any{"path": "file.go", "additions": 1, "deletions": 0, "changeType": "MODIFIED"},
 					map[string]any{"path": "deleted.txt", "additions": 0, "deletions": 0, "changeType": "DELETED"},
 				}},
 				"comments": map[string]any{"nodes": []any{}},
+				"closingIssuesReferences": map[string]any{"nodes": []any{
+					map[string]any{"number": 5, "title": "Crash", "body": "it crashes", "state": "OPEN", "repository": map[string]any{"nameWithOwner": "o/r"}},
+				}},
 				"reviews": map[string]any{"nodes": []any{map[string]any{"id": "r1", "databaseId": 1, "author": map[string]any{"login": "rev"}, "body": "ok", "state": "COMMENTED", "submittedAt": "t",
 					"comments": map[string]any{"nodes": []any{map[string]any{"id": "c1", "databaseId": 2, "body": "inl", "author": map[string]any{"login": "u"}, "createdAt": "t", "isMinimized": false, "path": "p.go", "line": 10}}},
 				}}},
@@ -1412,7 +1415,7 @@ func TestFetchGitHubData_PR(t *testing.T) {
 
 	c := NewClient(fakeAuth2{})
 	c.endpoint = ts.URL
-	res, err := FetchGitHubData(context.Background(), FetchParams{Client: c, Repository: "o/r", Number: 2, IsPR: true, TriggerUsername: "alice"})
+	res, err := FetchGitHubData(context.Background(), FetchParams{Client: c, Repository: "o/r", Number: 2, IsPR: true, TriggerUsername: "alice", Workdir: tmp})
 	if err != nil {
 		t.Fatalf("FetchGitHubData pr: %v", err)
 	}
@@ -1439,7 +1442,7 @@ func TestFetchGitHubData_PR(t *testing.T) {
 	reHex := regexp.MustCompile(`^[a-f0-9]{7,64}$`)
 	oksha := false
 	for _, f := range res.ChangedSHA {
-		if f.Path == fpath {
+		if f.Path == "file.go" {
 			oksha = f.SHA != "" && f.SHA != "unknown" && reHex.MatchString(f.SHA)
 		}
 	}
@@ -1449,6 +1452,9 @@ func TestFetchGitHubData_PR(t *testing.T) {
 	if res.TriggerName == nil || *res.TriggerName != "Alice" {
 		t.Fatalf("bad trigger name: %+v", res.TriggerName)
 	}
+	if len(res.Linked) != 1 || res.Linked[0].Number != 5 || res.Linked[0].Repository.NameWithOwner != "o/r" {
+		t.Fatalf("bad linked issues: %+v", res.Linked)
+	}
 }
 
 func TestFetchGitHubData_GraphQLError(t *testing.T) {
@@ -1503,11 +1509,11 @@ func TestGitHashObject(t *testing.T) {
 	if err := os.WriteFile(p, []byte("hello\n"), 0o644); err != nil {
 		t.Fatal(err)
 	}
-	sha, err := gitHashObject(p)
-	if err != nil || sha == "" {
+	sha, err := gitHashObject(tmp, "x.txt")
+	if err != nil || sha != "ce013625030ba8dba906f756967f9e9ca394464a" {
 		t.Fatalf("unexpected: %v %q", err, sha)
 	}
-	if _, err = gitHashObject(filepath.Join(tmp, "nope.txt")); err == nil {
+	if _, err = gitHashObject(tmp, "nope.txt"); err == nil {
 		t.Fatalf("expected error for missing file")
 	}
 }
@@ -1798,3 +1804,29 @@ func ternary[T any](cond bool, a, b T) T {
 	}
 	return b
 }
+
+func TestChangedFilesWithSHA(t *testing.T) {
+	tmp := t.TempDir()
+	if err := os.MkdirAll(filepath.Join(tmp, "pkg"), 0o755); err != nil {
+		t.Fatal(err)
+	}
+	if err := os.WriteFile(filepath.Join(tmp, "pkg", "a.go"), []byte("hello\n"), 0o644); err != nil {
+		t.Fatal(err)
+	}
+	files := []File{
+		{Path: "pkg/a.go", ChangeType: "MODIFIED"},
+		{Path: "gone.go", ChangeType: "DELETED"},
+		{Path: "missing.go", ChangeType: "ADDED"},
+	}
+	got := ChangedFilesWithSHA(tmp, files)
+	want := []string{"ce013625030ba8dba906f756967f9e9ca394464a", "deleted", "unknown"}
+	for i, f := range got {
+		if f.SHA != want[i] {
+			t.Errorf("%s: SHA = %q, want %q", f.Path, f.SHA, want[i])
+		}
+	}
+	// relative to the checkout, not the process's working directory
+	if got := ChangedFilesWithSHA("", files); got[0].SHA != "unknown" {
+		t.Errorf("without a checkout: %q", got[0].SHA)
+	}
+}
@@ -2,25 +2,69 @@ package data
 
 import (
 	"context"
+	"log/slog"
+	"time"
 
 	gh "github.com/cexll/swe/internal/github"
+	"github.com/cexll/swe/internal/logging"
 )
 
 // Fetcher is a thin wrapper providing a stable entrypoint for executors.
 type Fetcher struct {
 	client *Client
+	cache  *fetchCache
 }
 
-// NewFetcher constructs a new Fetcher using the given GraphQL client.
-func NewFetcher(c *Client) *Fetcher { return &Fetcher{client: c} }
+// NewFetcher constructs a new Fetcher using the given GraphQL client. Its
+// cache is off until SetCache enables it.
+func NewFetcher(c *Client) *Fetcher { return &Fetcher{client: c, cache: newFetchCache(0)} }
+
+// SetCache keeps fetched data for up to ttl and reuses it while the issue
+// or pull request is unchanged (ttl <= 0 disables the cache); safe to call
+// while tasks run.
+func (f *Fetcher) SetCache(ttl time.Duration) {
+	f.cache.setTTL(ttl)
+}
+
+// Invalidate drops the cached data of issue or pull request number of repo
+// ("owner/repo"), as new comments on it should.
+func (f *Fetcher) Invalidate(repo string, number int) {
+	if f.cache.invalidate(repo, number) {
+		slog.Debug("GraphQL fetch cache invalidated", logging.KeyRepo, repo, logging.KeyPhase, "graphql", "number", number)
+	}
+}
 
 // Fetch collects GitHub data for the provided webhook context.
+//
+// With the cache on, data fetched for the same issue or pull request is
+// reused when it already holds the trigger comment and GitHub reports the
+// same updatedAt as when it was fetched; otherwise everything is fetched
+// again.
 func (f *Fetcher) Fetch(ctx context.Context, gctx *gh.Context) (*FetchResult, error) {
 	repo := gctx.GetRepositoryFullName()
 	number := gctx.GetIssueNumber()
 	if gctx.IsPRContext() && gctx.GetPRNumber() != 0 {
 		number = gctx.GetPRNumber()
 	}
+
+	if cached, ok := f.cache.get(repo, number); ok && f.reusable(gctx, cached) {
+		updatedAt, err := fetchUpdatedAt(ctx, f.client, repo, number)
+		if err == nil && updatedAt == cached.updatedAt {
+			slog.Debug("Reusing cached GraphQL data", logging.KeyRepo, repo, logging.KeyPhase, "graphql", "number", number, "updated_at", updatedAt)
+			// a copy: callers fill in fields of their own
+			result := *cached.result
+			if user := gctx.GetTriggerUser(); user != cached.triggerUser {
+				result.TriggerName = nil
+				if user != "" {
+					if name, err := FetchUserDisplayName(ctx, f.client, repo, user); err == nil {
+						result.TriggerName = name
+					}
+				}
+			}
+			return &result, nil
+		}
+	}
+
 	params := FetchParams{
 		Client:          f.client,
 		Repository:      repo,
@@ -29,5 +73,27 @@ func (f *Fetcher) Fetch(ctx context.Context, gctx *gh.Context) (*FetchResult, er
 		TriggerUsername: gctx.GetTriggerUser(),
 		// TriggerTime left empty; filtering is best-effort and optional here
 	}
-	return FetchGitHubData(ctx, params)
+	result, err := FetchGitHubData(ctx, params)
+	if err != nil {
+		return nil, err
+	}
+	stored := *result
+	f.cache.put(repo, number, cachedFetch{
+		updatedAt:   fetchedUpdatedAt(result),
+		result:      &stored,
+		triggerUser: gctx.GetTriggerUser(),
+	})
+	return result, nil
+}
+
+// reusable reports whether cached data can serve gctx: it is of the same
+// kind and holds the trigger comment, if any.
+func (f *Fetcher) reusable(gctx *gh.Context, cached cachedFetch) bool {
+	if _, isPR := cached.result.ContextData.(PullRequest); isPR != gctx.IsPRContext() {
+		return false
+	}
+	if c := gctx.TriggerComment; c != nil && c.ID != 0 {
+		return hasComment(cached.result, c.ID)
+	}
+	return true
 }
@@ -2,6 +2,7 @@ package data
 
 import (
 	"fmt"
+	"sort"
 	"strings"
 
 	gh "github.com/cexll/swe/internal/github"
@@ -40,8 +41,9 @@ func formatBody(body string, imageURLMap map[string]string) string {
 	return gh.SanitizeContent(processed)
 }
 
-// formatComments renders comments as author/timestamp+sanitized body pairs.
-func formatComments(comments []Comment, imageURLMap map[string]string) string {
+// formatComments renders comments as author/timestamp+sanitized body pairs;
+// bodies by anyone but triggerUser are wrapped as untrusted.
+func formatComments(comments []Comment, imageURLMap map[string]string, triggerUser string) string {
 	var out []string
 	for _, c := range comments {
 		if c.IsMinimized {
@@ -51,14 +53,15 @@ func formatComments(comments []Comment, imageURLMap map[string]string) string {
 		for orig, local := range imageURLMap {
 			body = strings.ReplaceAll(body, orig, local)
 		}
-		body = gh.SanitizeContent(body)
+		body = untrustedBody(c.Author.Login, gh.SanitizeContent(body), triggerUser)
 		out = append(out, fmt.Sprintf("[%s at %s]: %s", c.Author.Login, c.CreatedAt, body))
 	}
 	return strings.Join(out, "\n\n")
 }
 
-// formatReviewComments renders review summaries and inline comments.
-func formatReviewComments(reviews *struct{ Nodes []Review }, imageURLMap map[string]string) string {
+// formatReviewComments renders review summaries and inline comments;
+// those by anyone but triggerUser are wrapped as untrusted.
+func formatReviewComments(reviews *struct{ Nodes []Review }, imageURLMap map[string]string, triggerUser string) string {
 	if reviews == nil || len(reviews.Nodes) == 0 {
 		return ""
 	}
@@ -73,7 +76,7 @@ func formatReviewComments(reviews *struct{ Nodes []Review }, imageURLMap map[str
 				body = strings.ReplaceAll(body, orig, local)
 			}
 			b.WriteString("\n")
-			b.WriteString(gh.SanitizeContent(body))
+			b.WriteString(untrustedBody(r.Author.Login, gh.SanitizeContent(body), triggerUser))
 		}
 		if len(r.Comments.Nodes) > 0 {
 			for _, c := range r.Comments.Nodes {
@@ -84,7 +87,7 @@ func formatReviewComments(reviews *struct{ Nodes []Review }, imageURLMap map[str
 				for orig, local := range imageURLMap {
 					body = strings.ReplaceAll(body, orig, local)
 				}
-				body = gh.SanitizeContent(body)
+				body = untrustedBody(c.Author.Login, gh.SanitizeContent(body), triggerUser)
 				line := "?"
 				if c.Line != nil {
 					line = fmt.Sprintf("%d", *c.Line)
@@ -107,6 +110,64 @@ func formatChangedFilesWithSHA(files []GitHubFileWithSHA) string {
 	return strings.Join(out, "\n")
 }
 
+// formatPatches renders diff hunks by path, sorted by path.
+func formatPatches(patches map[string]string) string {
+	paths := make([]string, 0, len(patches))
+	for p := range patches {
+		paths = append(paths, p)
+	}
+	sort.Strings(paths)
+	out := make([]string, 0, len(paths))
+	for _, p := range paths {
+		out = append(out, fmt.Sprintf("diff --git a/%s b/%s\n%s", p, p, strings.TrimRight(patches[p], "\n")))
+	}
+	return strings.Join(out, "\n")
+}
+
+// MaxLinkedIssueBody bounds the body of a linked issue in the prompt, in
+// bytes: the pull request is the subject, its issues the background.
+const MaxLinkedIssueBody = 2000
+
+// formatLinkedIssues renders the issues a pull request closes, their
+// bodies trimmed to MaxLinkedIssueBody; issues of repo go by number alone.
+func formatLinkedIssues(issues []LinkedIssue, repo string, imageURLMap map[string]string) string {
+	out := make([]string, 0, len(issues))
+	for _, is := range issues {
+		ref := fmt.Sprintf("#%d", is.Number)
+		if name := is.Repository.NameWithOwner; name != "" && !strings.EqualFold(name, repo) {
+			ref = name + ref
+		}
+		body := "No description provided"
+		if strings.TrimSpace(is.Body) != "" {
+			body = formatBody(strings.TrimSpace(is.Body), imageURLMap)
+			if len(body) > MaxLinkedIssueBody {
+				body = strings.ToValidUTF8(body[:MaxLinkedIssueBody], "") + "\n[... truncated ...]"
+			}
+		}
+		out = append(out, fmt.Sprintf("[%s: %s] (%s)\n%s", ref, gh.SanitizeContent(is.Title), is.State, body))
+	}
+	return strings.Join(out, "\n\n")
+}
+
+// omittedMarker says n items of kind were left out to fit the prompt budget.
+func omittedMarker(n int, kind string) string {
+	if n != 1 {
+		kind += "s"
+	}
+	return fmt.Sprintf("[... %d %s omitted to fit the context budget ...]", n, kind)
+}
+
+// joinNonEmpty joins a and b with sep, skipping an empty one.
+func joinNonEmpty(a, b, sep string) string {
+	if a == "" {
+		return b
+	}
+	if b == "" {
+		return a
+	}
+	return a + sep + b
+}
+
 // GenerateXMLParams controls XML prompt generation analogous
 type GenerateXMLParams struct {
 	Repository         string
@@ -126,28 +187,61 @@ type GenerateXMLParams struct {
 	Comments            []Comment
 	ReviewData          *struct{ Nodes []Review }
 	ChangedFilesWithSHA []GitHubFileWithSHA
+	ChangedFilePatches  map[string]string // diff hunks by path (PR only)
+	LinkedIssues        []LinkedIssue     // issues the PR closes (PR only)
 	ImageURLMap         map[string]string
+
+	// Omitted* count what was left out of the data above to fit the prompt
+	// budget; each section says so instead of silently ending early
+	OmittedComments     int // oldest comments
+	OmittedReviews      int // oldest reviews
+	OmittedChangedFiles int // last changed files
+	OmittedPatches      int // largest diffs
+	OmittedLinkedIssues int // last linked issues
 }
 
 // GenerateXML builds the XML-tagged prompt sections similar to create-prompt/index.ts.
 func GenerateXML(p GenerateXMLParams) string {
 	formattedContext := formatContext(p.ContextData, p.IsPR)
-	formattedComments := formatComments(p.Comments, p.ImageURLMap)
+	formattedComments := formatComments(p.Comments, p.ImageURLMap, p.TriggerUsername)
 	formattedReview := ""
 	formattedChanged := ""
 	if p.IsPR {
-		formattedReview = formatReviewComments(p.ReviewData, p.ImageURLMap)
+		formattedReview = formatReviewComments(p.ReviewData, p.ImageURLMap, p.TriggerUsername)
 		formattedChanged = formatChangedFilesWithSHA(p.ChangedFilesWithSHA)
 	}
+	if p.OmittedComments > 0 {
+		formattedComments = joinNonEmpty(omittedMarker(p.OmittedComments, "older comment"), formattedComments, "\n\n")
+	}
+	if p.OmittedReviews > 0 {
+		formattedReview = joinNonEmpty(omittedMarker(p.OmittedReviews, "older review"), formattedReview, "\n\n")
+	}
+	formattedPatches := ""
+	if p.IsPR {
+		formattedPatches = formatPatches(p.ChangedFilePatches)
+	}
+	if p.OmittedPatches > 0 {
+		formattedPatches = joinNonEmpty(formattedPatches, omittedMarker(p.OmittedPatches, "more diff"), "\n")
+	}
+	formattedLinked := ""
+	if p.IsPR {
+		formattedLinked = formatLinkedIssues(p.LinkedIssues, p.Repository, p.ImageURLMap)
+	}
+	if p.OmittedLinkedIssues > 0 {
+		formattedLinked = joinNonEmpty(formattedLinked, omittedMarker(p.OmittedLinkedIssues, "more linked issue"), "\n\n")
+	}
+	if p.OmittedChangedFiles > 0 {
+		formattedChanged = joinNonEmpty(formattedChanged, omittedMarker(p.OmittedChangedFiles, "more changed file"), "\n")
+	}
 	bodyText := "No description provided"
 	switch v := p.ContextData.(type) {
 	case PullRequest:
 		if strings.TrimSpace(v.Body) != "" {
-			bodyText = formatBody(v.Body, p.ImageURLMap)
+			bodyText = untrustedBody(v.Author.Login, formatBody(v.Body, p.ImageURLMap), p.TriggerUsername)
 		}
 	case Issue:
 		if strings.TrimSpace(v.Body) != "" {
-			bodyText = formatBody(v.Body, p.ImageURLMap)
+			bodyText = untrustedBody(v.Author.Login, formatBody(v.Body, p.ImageURLMap), p.TriggerUsername)
 		}
 	}
 
@@ -160,6 +254,12 @@ func GenerateXML(p GenerateXMLParams) string {
 	b.WriteString(bodyText)
 	b.WriteString("\n</pr_or_issue_body>\n\n")
 
+	if formattedLinked != "" {
+		b.WriteString("<linked_issues>\n")
+		b.WriteString(formattedLinked)
+		b.WriteString("\n</linked_issues>\n\n")
+	}
+
 	b.WriteString("<comments>\n")
 	if formattedComments != "" {
 		b.WriteString(formattedComments)
@@ -184,6 +284,12 @@ func GenerateXML(p GenerateXMLParams) string {
 			b.WriteString("No files changed")
 		}
 		b.WriteString("\n</changed_files>\n\n")
+
+		if formattedPatches != "" {
+			b.WriteString("<changed_files_diff>\n")
+			b.WriteString(formattedPatches)
+			b.WriteString("\n</changed_files_diff>\n\n")
+		}
 	}
 
 	b.WriteString(fmt.Sprintf("<event_type>%s</event_type>\n", p.EventType))
@@ -59,7 +59,7 @@ func TestFormatBody_WithImageMap(t *testing.T) {
 
 func TestFormatComments_Cases(t *testing.T) {
 	// empty
-	if s := formatComments(nil, nil); s != "" {
+	if s := formatComments(nil, nil, ""); s != "" {
 		t.Fatalf("expected empty, got %q", s)
 	}
 	// multiple + skip minimized + sanitize + replacements
@@ -68,7 +68,7 @@ func TestFormatComments_Cases(t *testing.T) {
 		{Body: "skip", Author: Author{Login: "u2"}, CreatedAt: "t2", IsMinimized: true},
 		{Body: "next", Author: Author{Login: "u3"}, CreatedAt: "t3"},
 	}
-	s := formatComments(comments, map[string]string{"http://u/img.png": "/l/i.png"})
+	s := formatComments(comments, map[string]string{"http://u/img.png": "/l/i.png"}, "")
 	if strings.Contains(s, "<!--") {
 		t.Fatalf("not sanitized: %q", s)
 	}
@@ -78,7 +78,7 @@ func TestFormatComments_Cases(t *testing.T) {
 	if strings.Contains(s, "skip") {
 		t.Fatalf("minimized not skipped: %q", s)
 	}
-	if !strings.Contains(s, "[u1 at t1]: hi") || !strings.Contains(s, "[u3 at t3]: next") {
+	if !strings.Contains(s, "[u1 at t1]: <untrusted_content author=\"u1\">\nhi") || !strings.Contains(s, "[u3 at t3]: <untrusted_content author=\"u3\">\nnext\n</untrusted_content>") {
 		t.Fatalf("bad format: %q", s)
 	}
 }
@@ -87,11 +87,11 @@ type ReviewsWrap struct{ Nodes []Review }
 type ReviewCommentsWrap struct{ Nodes []ReviewComment }
 
 func TestFormatReviewComments_Variants(t *testing.T) {
-	if s := formatReviewComments(nil, nil); s != "" {
+	if s := formatReviewComments(nil, nil, ""); s != "" {
 		t.Fatalf("nil reviews should be empty")
 	}
 	empty := &ReviewsWrap{Nodes: nil}
-	if s := formatReviewComments((*struct{ Nodes []Review })(empty), nil); s != "" {
+	if s := formatReviewComments((*struct{ Nodes []Review })(empty), nil, ""); s != "" {
 		t.Fatalf("empty nodes should be empty")
 	}
 
@@ -108,7 +108,7 @@ func TestFormatReviewComments_Variants(t *testing.T) {
 	}
 	reviews := &ReviewsWrap{Nodes: []Review{rv}}
 
-	s := formatReviewComments((*struct{ Nodes []Review })(reviews), map[string]string{"http://u/i.png": "/l/i.png"})
+	s := formatReviewComments((*struct{ Nodes []Review })(reviews), map[string]string{"http://u/i.png": "/l/i.png"}, "")
 	if !strings.Contains(s, "[Review by rv1 at t0]: APPROVED") {
 		t.Fatalf("missing header: %q", s)
 	}
@@ -121,7 +121,7 @@ func TestFormatReviewComments_Variants(t *testing.T) {
 	if strings.Contains(s, "hide") {
 		t.Fatalf("minimized inline not skipped: %q", s)
 	}
-	if !strings.Contains(s, "  [Comment on a.go:42]: inline1") {
+	if !strings.Contains(s, "  [Comment on a.go:42]: <untrusted_content author=\"\">\ninline1\n</untrusted_content>") {
 		t.Fatalf("missing inline: %q", s)
 	}
 }
@@ -192,3 +192,44 @@ func mustContain(t *testing.T, s, sub string) {
 		t.Fatalf("missing %q in %q", sub, s)
 	}
 }
+
+func TestGenerateXML_PatchesAndOmissions(t *testing.T) {
+	xml := GenerateXML(GenerateXMLParams{
+		IsPR:                true,
+		TriggerUsername:     "bob",
+		ContextData:         PullRequest{Title: "t"},
+		Comments:            []Comment{{Author: Author{Login: "bob"}, Body: "newest"}},
+		ChangedFilesWithSHA: []GitHubFileWithSHA{{File: File{Path: "b.go", ChangeType: "MODIFIED"}, SHA: "s"}},
+		ChangedFilePatches:  map[string]string{"b.go": "@@ -1 +1 @@\n-x\n+y\n", "a.go": "@@ -0,0 +1 @@\n+z"},
+		OmittedComments:     3,
+		OmittedChangedFiles: 1,
+		OmittedPatches:      2,
+	})
+	mustContain(t, xml, "<comments>\n[... 3 older comments omitted to fit the context budget ...]\n\n[bob at ]: newest\n</comments>")
+	mustContain(t, xml, "SHA: s\n[... 1 more changed file omitted to fit the context budget ...]\n</changed_files>")
+	mustContain(t, xml, "<changed_files_diff>\ndiff --git a/a.go b/a.go\n@@ -0,0 +1 @@\n+z\ndiff --git a/b.go b/b.go\n@@ -1 +1 @@\n-x\n+y\n[... 2 more diffs omitted to fit the context budget ...]\n</changed_files_diff>")
+
+	if strings.Contains(GenerateXML(GenerateXMLParams{IsPR: true, ContextData: PullRequest{}}), "<changed_files_diff>") {
+		t.Fatal("diff section without diffs")
+	}
+}
+
+func TestGenerateXML_LinkedIssues(t *testing.T) {
+	other := LinkedIssue{Number: 9, Title: "Upstream", State: "CLOSED"}
+	other.Repository.NameWithOwner = "up/stream"
+	same := LinkedIssue{Number: 5, Title: "Crash", Body: strings.Repeat("x", MaxLinkedIssueBody+10), State: "OPEN"}
+	same.Repository.NameWithOwner = "O/R"
+	xml := GenerateXML(GenerateXMLParams{
+		Repository:          "o/r",
+		IsPR:                true,
+		ContextData:         PullRequest{Title: "t"},
+		LinkedIssues:        []LinkedIssue{same, other},
+		OmittedLinkedIssues: 1,
+	})
+	mustContain(t, xml, "</pr_or_issue_body>\n\n<linked_issues>\n[#5: Crash] (OPEN)\n"+strings.Repeat("x", MaxLinkedIssueBody)+"\n[... truncated ...]\n\n")
+	mustContain(t, xml, "[up/stream#9: Upstream] (CLOSED)\nNo description provided\n\n[... 1 more linked issue omitted to fit the context budget ...]\n</linked_issues>")
+
+	if strings.Contains(GenerateXML(GenerateXMLParams{ContextData: Issue{}, LinkedIssues: []LinkedIssue{same}}), "<linked_issues>") {
+		t.Fatal("linked issues section for an issue")
+	}
+}
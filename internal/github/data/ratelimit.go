@@ -0,0 +1,158 @@
+package data
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"log/slog"
+	"net/http"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/cexll/swe/internal/logging"
+)
+
+// Retry and rate-limit policy of Client.Do.
+const (
+	maxAttempts = 4                // per request, the first included
+	baseBackoff = time.Second      // doubled after each failed attempt
+	maxBackoff  = 30 * time.Second // longest wait between attempts
+	// minRemaining is the rate-limit budget, in GraphQL points, below which
+	// requests for the same owner wait for the budget to reset
+	minRemaining = 100
+	// maxPause is the longest such a wait may be; past it requests fail at
+	// once instead of holding a task up
+	maxPause = 5 * time.Minute
+)
+
+// retryableError marks a failed attempt worth repeating.
+type retryableError struct{ error }
+
+func (e retryableError) Unwrap() error { return e.error }
+
+// isRetryable reports whether err is worth another attempt.
+func isRetryable(err error) bool {
+	var r retryableError
+	return errors.As(err, &r)
+}
+
+// rateLimit is what GitHub last said about an owner's rate-limit budget.
+type rateLimit struct {
+	remaining int
+	resetAt   time.Time
+}
+
+// rateLimits tracks the rate-limit budget per owner: installation tokens,
+// and so their budgets, belong to an account.
+type rateLimits struct {
+	mu     sync.Mutex
+	owners map[string]rateLimit
+}
+
+func (r *rateLimits) get(owner string) (rateLimit, bool) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	l, ok := r.owners[owner]
+	return l, ok
+}
+
+func (r *rateLimits) set(owner string, l rateLimit) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	if r.owners == nil {
+		r.owners = make(map[string]rateLimit)
+	}
+	r.owners[owner] = l
+}
+
+// waitForBudget holds a request for owner while its rate-limit budget is
+// below minRemaining, up to maxPause; a longer wait fails at once.
+func (c *Client) waitForBudget(ctx context.Context, owner string) error {
+	l, ok := c.limits.get(owner)
+	if !ok || l.remaining >= minRemaining {
+		return nil
+	}
+	wait := l.resetAt.Sub(c.now())
+	if wait <= 0 {
+		return nil
+	}
+	if wait > maxPause {
+		return fmt.Errorf("graphql rate limit: %d points left for %s until %s", l.remaining, owner, l.resetAt.Format(time.RFC3339))
+	}
+	slog.Info("GraphQL budget low; pausing until it resets", logging.KeyPhase, "graphql", "owner", owner, "remaining", l.remaining, "wait", wait.Round(time.Second))
+	return c.sleep(ctx, wait)
+}
+
+// recordRateLimit keeps the budget GitHub reported for owner: the rateLimit
+// field of data when the query asked for it, else the X-RateLimit headers.
+func (c *Client) recordRateLimit(owner string, header http.Header, data json.RawMessage) {
+	var body struct {
+		RateLimit *struct {
+			Cost      int    `json:"cost"`
+			Remaining int    `json:"remaining"`
+			ResetAt   string `json:"resetAt"`
+		} `json:"rateLimit"`
+	}
+	if len(data) > 0 && json.Unmarshal(data, &body) == nil && body.RateLimit != nil {
+		if reset, err := time.Parse(time.RFC3339, body.RateLimit.ResetAt); err == nil {
+			c.limits.set(owner, rateLimit{remaining: body.RateLimit.Remaining, resetAt: reset})
+			return
+		}
+	}
+	remaining, err1 := strconv.Atoi(header.Get("X-RateLimit-Remaining"))
+	reset, err2 := strconv.ParseInt(header.Get("X-RateLimit-Reset"), 10, 64)
+	if err1 == nil && err2 == nil {
+		c.limits.set(owner, rateLimit{remaining: remaining, resetAt: time.Unix(reset, 0)})
+	}
+}
+
+// retryDelay is how long to wait before attempt+1 after a retryable
+// failure: Retry-After when GitHub sent it, the rate-limit reset when the
+// budget is spent, else exponential backoff.
+func (c *Client) retryDelay(attempt int, header http.Header) time.Duration {
+	if header != nil {
+		if secs, err := strconv.Atoi(header.Get("Retry-After")); err == nil && secs >= 0 {
+			return time.Duration(secs) * time.Second
+		}
+		if header.Get("X-RateLimit-Remaining") == "0" {
+			if reset, err := strconv.ParseInt(header.Get("X-RateLimit-Reset"), 10, 64); err == nil {
+				if wait := time.Unix(reset, 0).Sub(c.now()); wait > 0 {
+					return wait
+				}
+			}
+		}
+	}
+	d := baseBackoff << (attempt - 1)
+	if d > maxBackoff {
+		d = maxBackoff
+	}
+	return d
+}
+
+// retryableStatus reports whether an HTTP status is worth retrying: server
+// errors, and the rate limits GitHub answers with 403 or 429.
+func retryableStatus(status int, header http.Header, body []byte) bool {
+	switch {
+	case status >= 500, status == http.StatusTooManyRequests:
+		return true
+	case status == http.StatusForbidden:
+		return header.Get("Retry-After") != "" || header.Get("X-RateLimit-Remaining") == "0" ||
+			strings.Contains(strings.ToLower(string(body)), "rate limit")
+	}
+	return false
+}
+
+// sleepContext waits for d, or until ctx is done.
+func sleepContext(ctx context.Context, d time.Duration) error {
+	t := time.NewTimer(d)
+	defer t.Stop()
+	select {
+	case <-t.C:
+		return nil
+	case <-ctx.Done():
+		return ctx.Err()
+	}
+}
@@ -0,0 +1,117 @@
+package data
+
+import (
+	"context"
+	"net/http"
+	"net/http/httptest"
+	"strconv"
+	"strings"
+	"testing"
+	"time"
+)
+
+// stubClock makes c sleep instantly, recording the waits.
+func stubClock(c *Client, now time.Time) *[]time.Duration {
+	var waits []time.Duration
+	c.now = func() time.Time { return now }
+	c.sleep = func(_ context.Context, d time.Duration) error {
+		waits = append(waits, d)
+		return nil
+	}
+	return &waits
+}
+
+func TestClientDo_RetriesTransientErrors(t *testing.T) {
+	calls := 0
+	ts := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		calls++
+		switch calls {
+		case 1:
+			w.WriteHeader(http.StatusBadGateway)
+		case 2:
+			w.Header().Set("Retry-After", "7")
+			w.WriteHeader(http.StatusForbidden)
+			_, _ = w.Write([]byte(`{"message":"You have exceeded a secondary rate limit"}`))
+		case 3:
+			_, _ = w.Write([]byte(`{"errors":[{"type":"RATE_LIMITED","message":"API rate limit exceeded"}]}`))
+		default:
+			_, _ = w.Write([]byte(`{"data":{"ok":true}}`))
+		}
+	}))
+	defer ts.Close()
+	c := NewClient(fakeAuth{})
+	c.endpoint = ts.URL
+	waits := stubClock(c, time.Now())
+
+	var out struct{ Ok bool }
+	if err := c.Do(context.Background(), "o/r", "q", nil, &out); err != nil || !out.Ok {
+		t.Fatalf("Do = %v, %+v", err, out)
+	}
+	want := []time.Duration{time.Second, 7 * time.Second, 4 * time.Second}
+	if calls != 4 || len(*waits) != 3 || (*waits)[0] != want[0] || (*waits)[1] != want[1] || (*waits)[2] != want[2] {
+		t.Fatalf("calls = %d, waits = %v, want %v", calls, *waits, want)
+	}
+}
+
+func TestClientDo_GivesUp(t *testing.T) {
+	calls := 0
+	ts := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		calls++
+		w.WriteHeader(http.StatusServiceUnavailable)
+		_, _ = w.Write([]byte("unavailable"))
+	}))
+	defer ts.Close()
+	c := NewClient(fakeAuth{})
+	c.endpoint = ts.URL
+	stubClock(c, time.Now())
+
+	err := c.Do(context.Background(), "o/r", "q", nil, nil)
+	if err == nil || err.Error() != "graphql status 503: unavailable" || calls != maxAttempts {
+		t.Fatalf("Do = %v after %d calls", err, calls)
+	}
+
+	// a rate limit resetting too late fails at once
+	calls = 0
+	ts.Config.Handler = http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		calls++
+		w.Header().Set("Retry-After", "3600")
+		w.WriteHeader(http.StatusTooManyRequests)
+	})
+	if err := c.Do(context.Background(), "o/r", "q", nil, nil); err == nil || !strings.Contains(err.Error(), "retry in 1h0m0s") || calls != 1 {
+		t.Fatalf("Do = %v after %d calls", err, calls)
+	}
+}
+
+func TestClientDo_PausesWhenBudgetIsLow(t *testing.T) {
+	now := time.Date(2026, 10, 16, 12, 0, 0, 0, time.UTC)
+	reset := now.Add(time.Minute)
+	ts := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.Header().Set("X-RateLimit-Remaining", "4000")
+		w.Header().Set("X-RateLimit-Reset", strconv.FormatInt(reset.Unix(), 10))
+		_, _ = w.Write([]byte(`{"data":{"rateLimit":{"cost":1,"remaining":50,"resetAt":"` + reset.Format(time.RFC3339) + `"}}}`))
+	}))
+	defer ts.Close()
+	c := NewClient(fakeAuth{})
+	c.endpoint = ts.URL
+	waits := stubClock(c, now)
+
+	// the rateLimit field wins over the headers
+	for i := 0; i < 2; i++ {
+		if err := c.Do(context.Background(), "acme/app", "q", nil, nil); err != nil {
+			t.Fatal(err)
+		}
+	}
+	if len(*waits) != 1 || (*waits)[0] != time.Minute {
+		t.Fatalf("waits = %v, want one pause of 1m", *waits)
+	}
+	// other owners have their own budget
+	if err := c.Do(context.Background(), "other/app", "q", nil, nil); err != nil || len(*waits) != 1 {
+		t.Fatalf("Do = %v, waits = %v", err, *waits)
+	}
+
+	// a budget resetting after maxPause fails the request without sending it
+	c.limits.set("acme", rateLimit{remaining: 10, resetAt: now.Add(time.Hour)})
+	if err := c.Do(context.Background(), "acme/app", "q", nil, nil); err == nil || !strings.Contains(err.Error(), "10 points left for acme") {
+		t.Fatalf("Do = %v", err)
+	}
+}
@@ -0,0 +1,216 @@
+package data
+
+import (
+	"context"
+	"fmt"
+	"strings"
+
+	gh "github.com/cexll/swe/internal/github"
+)
+
+// ReviewThread is a pull request review thread: inline review comments on
+// one place in the diff and their replies.
+type ReviewThread struct {
+	ID         string `json:"id"`
+	IsResolved bool   `json:"isResolved"`
+	IsOutdated bool   `json:"isOutdated"`
+	Path       string `json:"path"`
+	Line       *int   `json:"line"`
+	Comments   struct {
+		Nodes []Comment `json:"nodes"`
+	} `json:"comments"`
+}
+
+// Location renders where the thread is, e.g. "internal/app.go:42".
+func (t ReviewThread) Location() string {
+	if t.Line == nil {
+		return t.Path
+	}
+	return fmt.Sprintf("%s:%d", t.Path, *t.Line)
+}
+
+type reviewThreadsResponse struct {
+	Repository struct {
+		PullRequest struct {
+			ReviewThreads struct {
+				PageInfo PageInfo       `json:"pageInfo"`
+				Nodes    []ReviewThread `json:"nodes"`
+			} `json:"reviewThreads"`
+		} `json:"pullRequest"`
+	} `json:"repository"`
+}
+
+// UnresolvedReviewThreads returns the unresolved review threads of pull
+// request number of repo ("owner/repo"), oldest first.
+func (c *Client) UnresolvedReviewThreads(ctx context.Context, repo string, number int) ([]ReviewThread, error) {
+	owner, name, err := splitRepo(repo)
+	if err != nil {
+		return nil, err
+	}
+	var threads []ReviewThread
+	var cursor *string
+	for {
+		var resp reviewThreadsResponse
+		if err := c.Do(ctx, repo, reviewThreadsQuery, map[string]interface{}{
+			"owner":  owner,
+			"repo":   name,
+			"number": number,
+			"cursor": cursor,
+		}, &resp); err != nil {
+			return nil, fmt.Errorf("fetch review threads: %w", err)
+		}
+		page := resp.Repository.PullRequest.ReviewThreads
+		for _, t := range page.Nodes {
+			if !t.IsResolved {
+				threads = append(threads, t)
+			}
+		}
+		if !page.PageInfo.HasNextPage {
+			return threads, nil
+		}
+		next := page.PageInfo.EndCursor
+		cursor = &next
+	}
+}
+
+// ReplyToReviewThread posts body as a reply in review thread threadID of a
+// pull request of repo.
+func (c *Client) ReplyToReviewThread(ctx context.Context, repo, threadID, body string) error {
+	if err := c.Do(ctx, repo, replyToReviewThreadMutation, map[string]interface{}{
+		"thread": threadID,
+		"body":   body,
+	}, nil); err != nil {
+		return fmt.Errorf("reply to review thread %s: %w", threadID, err)
+	}
+	return nil
+}
+
+// ResolveReviewThread marks review thread threadID of a pull request of
+// repo resolved.
+func (c *Client) ResolveReviewThread(ctx context.Context, repo, threadID string) error {
+	if err := c.Do(ctx, repo, resolveReviewThreadMutation, map[string]interface{}{
+		"thread": threadID,
+	}, nil); err != nil {
+		return fmt.Errorf("resolve review thread %s: %w", threadID, err)
+	}
+	return nil
+}
+
+// UnresolveReviewThread reopens resolved review thread threadID of a pull
+// request of repo.
+func (c *Client) UnresolveReviewThread(ctx context.Context, repo, threadID string) error {
+	if err := c.Do(ctx, repo, unresolveReviewThreadMutation, map[string]interface{}{
+		"thread": threadID,
+	}, nil); err != nil {
+		return fmt.Errorf("unresolve review thread %s: %w", threadID, err)
+	}
+	return nil
+}
+
+// ReviewThreadPullRequest returns the repository ("owner/repo") and number
+// of the pull request review thread threadID belongs to, so callers can
+// check a thread ID they were handed before acting on it.
+func (c *Client) ReviewThreadPullRequest(ctx context.Context, repo, threadID string) (string, int, error) {
+	var resp struct {
+		Node *struct {
+			PullRequest struct {
+				Number     int `json:"number"`
+				Repository struct {
+					NameWithOwner string `json:"nameWithOwner"`
+				} `json:"repository"`
+			} `json:"pullRequest"`
+		} `json:"node"`
+	}
+	if err := c.Do(ctx, repo, reviewThreadPullRequestQuery, map[string]interface{}{
+		"thread": threadID,
+	}, &resp); err != nil {
+		return "", 0, fmt.Errorf("look up review thread %s: %w", threadID, err)
+	}
+	if resp.Node == nil || resp.Node.PullRequest.Number == 0 {
+		return "", 0, fmt.Errorf("review thread %s not found", threadID)
+	}
+	return resp.Node.PullRequest.Repository.NameWithOwner, resp.Node.PullRequest.Number, nil
+}
+
+// FormatReviewThreads renders threads as a numbered checklist, each with its
+// location and comments; minimized comments are skipped.
+func FormatReviewThreads(threads []ReviewThread) string {
+	blocks := make([]string, 0, len(threads))
+	for i, t := range threads {
+		var b strings.Builder
+		fmt.Fprintf(&b, "%d. [ ] %s", i+1, t.Location())
+		if t.IsOutdated {
+			b.WriteString(" (outdated: the code has changed since)")
+		}
+		for _, c := range t.Comments.Nodes {
+			if c.IsMinimized {
+				continue
+			}
+			body := strings.ReplaceAll(gh.SanitizeContent(strings.TrimSpace(c.Body)), "\n", "\n   ")
+			fmt.Fprintf(&b, "\n   [%s at %s]: %s", c.Author.Login, c.CreatedAt, body)
+		}
+		blocks = append(blocks, b.String())
+	}
+	return strings.Join(blocks, "\n\n")
+}
+
+const reviewThreadsQuery = `query ReviewThreads($owner: String!, $repo: String!, $number: Int!, $cursor: String) {
+  rateLimit {
+    cost
+    remaining
+    resetAt
+  }
+  repository(owner: $owner, name: $repo) {
+    pullRequest(number: $number) {
+      reviewThreads(first: 100, after: $cursor) {
+        pageInfo { hasNextPage endCursor }
+        nodes {
+          id
+          isResolved
+          isOutdated
+          path
+          line
+          comments(first: 50) {
+            nodes {
+              id
+              databaseId
+              body
+              author { login }
+              createdAt
+              isMinimized
+            }
+          }
+        }
+      }
+    }
+  }
+}`
+
+const replyToReviewThreadMutation = `mutation ReplyToReviewThread($thread: ID!, $body: String!) {
+  addPullRequestReviewThreadReply(input: {pullRequestReviewThreadId: $thread, body: $body}) {
+    comment { id }
+  }
+}`
+
+const resolveReviewThreadMutation = `mutation ResolveReviewThread($thread: ID!) {
+  resolveReviewThread(input: {threadId: $thread}) {
+    thread { id isResolved }
+  }
+}`
+
+const unresolveReviewThreadMutation = `mutation UnresolveReviewThread($thread: ID!) {
+  unresolveReviewThread(input: {threadId: $thread}) {
+    thread { id isResolved }
+  }
+}`
+
+const reviewThreadPullRequestQuery = `query ReviewThreadPullRequest($thread: ID!) {
+  node(id: $thread) {
+    ... on PullRequestReviewThread {
+      pullRequest {
+        number
+        repository { nameWithOwner }
+      }
+    }
+  }
+}`
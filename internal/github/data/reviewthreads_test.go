@@ -0,0 +1,116 @@
+package data
+
+import (
+	"context"
+	"strings"
+	"testing"
+)
+
+func TestUnresolvedReviewThreads(t *testing.T) {
+	thread := func(id string, resolved bool, line any) map[string]any {
+		return map[string]any{"id": id, "isResolved": resolved, "isOutdated": false, "path": "app.go", "line": line,
+			"comments": map[string]any{"nodes": []any{map[string]any{"body": "nit", "author": map[string]any{"login": "rev"}}}}}
+	}
+	var cursors []any
+	ts := newGraphQLServer(t, func(query string, vars map[string]any) (int, any) {
+		if !strings.Contains(query, "reviewThreads(") || vars["number"] != float64(7) {
+			t.Fatalf("unexpected query %q %v", query, vars)
+		}
+		cursors = append(cursors, vars["cursor"])
+		page := map[string]any{"pageInfo": map[string]any{"hasNextPage": true, "endCursor": "c1"},
+			"nodes": []any{thread("T1", false, 10), thread("T2", true, 11)}}
+		if vars["cursor"] == "c1" {
+			page = map[string]any{"pageInfo": map[string]any{"hasNextPage": false},
+				"nodes": []any{thread("T3", false, nil)}}
+		}
+		return 200, map[string]any{"data": map[string]any{"repository": map[string]any{"pullRequest": map[string]any{"reviewThreads": page}}}}
+	})
+	defer ts.Close()
+	client := NewClient(fakeAuth2{})
+	client.endpoint = ts.URL
+
+	threads, err := client.UnresolvedReviewThreads(context.Background(), "o/r", 7)
+	if err != nil {
+		t.Fatalf("UnresolvedReviewThreads: %v", err)
+	}
+	if len(threads) != 2 || threads[0].ID != "T1" || threads[1].ID != "T3" {
+		t.Fatalf("threads = %+v", threads)
+	}
+	if len(cursors) != 2 || cursors[0] != nil || cursors[1] != "c1" {
+		t.Fatalf("cursors = %v", cursors)
+	}
+	if threads[0].Location() != "app.go:10" || threads[1].Location() != "app.go" {
+		t.Fatalf("locations = %q, %q", threads[0].Location(), threads[1].Location())
+	}
+}
+
+func TestReplyAndResolveReviewThread(t *testing.T) {
+	var calls []string
+	ts := newGraphQLServer(t, func(query string, vars map[string]any) (int, any) {
+		switch {
+		case strings.Contains(query, "addPullRequestReviewThreadReply"):
+			calls = append(calls, "reply "+vars["thread"].(string)+": "+vars["body"].(string))
+		case strings.Contains(query, "unresolveReviewThread"):
+			calls = append(calls, "unresolve "+vars["thread"].(string))
+		case strings.Contains(query, "resolveReviewThread"):
+			calls = append(calls, "resolve "+vars["thread"].(string))
+		default:
+			t.Fatalf("unexpected query %q", query)
+		}
+		return 200, map[string]any{"data": map[string]any{}}
+	})
+	defer ts.Close()
+	client := NewClient(fakeAuth2{})
+	client.endpoint = ts.URL
+
+	if err := client.ReplyToReviewThread(context.Background(), "o/r", "T1", "Addressed in abc."); err != nil {
+		t.Fatal(err)
+	}
+	if err := client.ResolveReviewThread(context.Background(), "o/r", "T1"); err != nil {
+		t.Fatal(err)
+	}
+	if err := client.UnresolveReviewThread(context.Background(), "o/r", "T1"); err != nil {
+		t.Fatal(err)
+	}
+	if strings.Join(calls, "; ") != "reply T1: Addressed in abc.; resolve T1; unresolve T1" {
+		t.Fatalf("calls = %v", calls)
+	}
+}
+
+func TestReviewThreadPullRequest(t *testing.T) {
+	ts := newGraphQLServer(t, func(query string, vars map[string]any) (int, any) {
+		if vars["thread"] == "T1" {
+			return 200, map[string]any{"data": map[string]any{"node": map[string]any{"pullRequest": map[string]any{
+				"number": 7, "repository": map[string]any{"nameWithOwner": "o/r"}}}}}
+		}
+		return 200, map[string]any{"data": map[string]any{"node": nil}}
+	})
+	defer ts.Close()
+	client := NewClient(fakeAuth2{})
+	client.endpoint = ts.URL
+
+	repo, number, err := client.ReviewThreadPullRequest(context.Background(), "o/r", "T1")
+	if err != nil || repo != "o/r" || number != 7 {
+		t.Fatalf("ReviewThreadPullRequest = %q, %d, %v", repo, number, err)
+	}
+	if _, _, err := client.ReviewThreadPullRequest(context.Background(), "o/r", "IC_1"); err == nil || !strings.Contains(err.Error(), "not found") {
+		t.Fatalf("expected not found error, got %v", err)
+	}
+}
+
+func TestFormatReviewThreads(t *testing.T) {
+	line := 3
+	var a, b ReviewThread
+	a.Path, a.Line = "a.go", &line
+	a.Comments.Nodes = []Comment{
+		{Body: "rename this\nplease", Author: Author{Login: "rev"}, CreatedAt: "t1"},
+		{Body: "spam", IsMinimized: true},
+	}
+	b.Path, b.IsOutdated = "b.go", true
+
+	got := FormatReviewThreads([]ReviewThread{a, b})
+	want := "1. [ ] a.go:3\n   [rev at t1]: rename this\n   please\n\n2. [ ] b.go (outdated: the code has changed since)"
+	if got != want {
+		t.Fatalf("FormatReviewThreads =\n%s\nwant\n%s", got, want)
+	}
+}
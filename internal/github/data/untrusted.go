@@ -0,0 +1,136 @@
+package data
+
+import (
+	"fmt"
+	"regexp"
+	"strings"
+)
+
+// UntrustedTag encloses text in the prompt written by someone other than
+// the user who triggered the task: the model reads it as data, not as
+// instructions.
+const UntrustedTag = "untrusted_content"
+
+var (
+	// HTML elements GitHub renders; other tag-like text such as <empty> or
+	// <T> is left alone
+	reHTMLTag = regexp.MustCompile(`(?i)</?(?:a|abbr|b|big|blockquote|br|center|code|dd|del|details|div|dl|dt|em|embed|font|form|h[1-6]|hr|i|iframe|img|input|ins|kbd|li|link|mark|meta|object|ol|p|picture|pre|q|s|samp|script|small|source|span|strike|strong|style|sub|summary|sup|svg|table|tbody|td|tfoot|th|thead|tr|tt|u|ul|var|video)(?:\s[^<>]*)?/?>`)
+	// a closing delimiter inside the text would end the untrusted block
+	reUntrustedDelim = regexp.MustCompile(`(?i)<(/?)\s*` + UntrustedTag)
+)
+
+// injectionPatterns are phrasings that try to give the model new
+// instructions from inside the context.
+var injectionPatterns = []struct {
+	name string
+	re   *regexp.Regexp
+}{
+	{"ignore previous instructions", regexp.MustCompile(`(?i)\b(?:ignore|disregard|forget|override|skip)\b[^.\n]{0,40}\b(?:previous|prior|above|earlier|preceding|all|your|system)\b[^.\n]{0,20}\b(?:instructions?|prompts?|rules|directions|guidelines|context)\b`)},
+	{"role reassignment", regexp.MustCompile(`(?i)\b(?:you are now|from now on,? you (?:are|will)|pretend (?:to be|you are)|act as (?:an?|the) (?:new|different|unrestricted))\b`)},
+	{"new instructions", regexp.MustCompile(`(?i)\b(?:new|updated|real|actual) (?:system )?instructions?\s*:`)},
+	{"fake role tag", regexp.MustCompile(`(?im)(?:<\s*/?\s*(?:system|assistant|instructions?)\s*>|^\s*(?:system|assistant)\s*:)`)},
+	{"secret exfiltration", regexp.MustCompile(`(?i)\b(?:reveal|print|show|output|leak|send|post|echo)\b[^.\n]{0,40}\b(?:system prompt|your instructions|github_token|api[_ ]key|access token|secrets?|env(?:ironment)? variables?)\b`)},
+}
+
+// DetectInjection returns the names of the prompt injection patterns s
+// matches, or nil.
+func DetectInjection(s string) []string {
+	var found []string
+	for _, p := range injectionPatterns {
+		if p.re.MatchString(s) {
+			found = append(found, p.name)
+		}
+	}
+	return found
+}
+
+// Injection is text in a prompt's context that matches a prompt injection
+// pattern.
+type Injection struct {
+	Author   string
+	Where    string // "body", "comment" or "review"
+	Patterns []string
+}
+
+// DetectInjections scans the untrusted text GenerateXML would render from
+// p, after trimming, for prompt injection patterns.
+func DetectInjections(p GenerateXMLParams) []Injection {
+	var found []Injection
+	check := func(author, where, body string) {
+		if isTrustedAuthor(author, p.TriggerUsername) {
+			return
+		}
+		if patterns := DetectInjection(body); len(patterns) > 0 {
+			found = append(found, Injection{Author: author, Where: where, Patterns: patterns})
+		}
+	}
+	switch v := p.ContextData.(type) {
+	case PullRequest:
+		check(v.Author.Login, "body", v.Body)
+	case Issue:
+		check(v.Author.Login, "body", v.Body)
+	}
+	for _, c := range p.Comments {
+		if !c.IsMinimized {
+			check(c.Author.Login, "comment", c.Body)
+		}
+	}
+	if p.IsPR && p.ReviewData != nil {
+		for _, r := range p.ReviewData.Nodes {
+			check(r.Author.Login, "review", r.Body)
+			for _, c := range r.Comments.Nodes {
+				if !c.IsMinimized {
+					check(c.Author.Login, "review", c.Body)
+				}
+			}
+		}
+	}
+	return found
+}
+
+// isTrustedAuthor reports whether text by author is the triggering user's
+// own; everything else is third-party.
+func isTrustedAuthor(author, triggerUser string) bool {
+	return triggerUser != "" && strings.EqualFold(author, triggerUser)
+}
+
+// wrapUntrusted encloses sanitized third-party text in UntrustedTag
+// delimiters, with its HTML tags stripped and any delimiter inside it
+// defused. Text matching an injection pattern is marked suspicious.
+func wrapUntrusted(author, body string) string {
+	attrs := fmt.Sprintf(" author=%q", author)
+	if len(DetectInjection(body)) > 0 {
+		attrs += ` suspicious="true"`
+	}
+	body = reUntrustedDelim.ReplaceAllString(stripHTMLTags(body), "&lt;${1}"+UntrustedTag)
+	return fmt.Sprintf("<%s%s>\n%s\n</%s>", UntrustedTag, attrs, body, UntrustedTag)
+}
+
+// stripHTMLTags removes HTML tags outside code, where they can hide text
+// from a reader of the rendered comment; code spans and blocks keep theirs.
+func stripHTMLTags(s string) string {
+	blocks := strings.Split(s, "```")
+	for i := 0; i < len(blocks); i += 2 {
+		spans := strings.Split(blocks[i], "`")
+		for j := 0; j < len(spans); j += 2 {
+			spans[j] = reHTMLTag.ReplaceAllString(spans[j], "")
+		}
+		blocks[i] = strings.Join(spans, "`")
+	}
+	return strings.Join(blocks, "```")
+}
+
+// UntrustedOverhead is how many bytes wrapping a body by author as
+// untrusted adds to it.
+func UntrustedOverhead(author string) int {
+	return len(wrapUntrusted(author, ""))
+}
+
+// untrustedBody returns body as is when triggerUser wrote it and wrapped as
+// untrusted otherwise.
+func untrustedBody(author, body, triggerUser string) string {
+	if isTrustedAuthor(author, triggerUser) {
+		return body
+	}
+	return wrapUntrusted(author, body)
+}
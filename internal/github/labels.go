@@ -0,0 +1,181 @@
+package github
+
+import (
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"io"
+	"net/http"
+	"net/url"
+)
+
+// maxLabelPages bounds ListLabels at 1000 labels, 100 per page.
+const maxLabelPages = 10
+
+// Label is a repository's issue label.
+type Label struct {
+	Name        string `json:"name"`
+	Description string `json:"description"`
+}
+
+// IssueSearchResult is an issue found by SearchIssues.
+type IssueSearchResult struct {
+	Number int    `json:"number"`
+	Title  string `json:"title"`
+	State  string `json:"state"`
+	URL    string `json:"html_url"`
+}
+
+// ListLabels lists the repository's issue labels using GitHub REST API
+// GET /repos/{owner}/{repo}/labels
+func ListLabels(owner, repo, token string) ([]Label, error) {
+	if token == "" {
+		return nil, fmt.Errorf("github token is required")
+	}
+	var labels []Label
+	for page := 1; page <= maxLabelPages; page++ {
+		apiURL := fmt.Sprintf("https://api.github.com/repos/%s/%s/labels?per_page=100&page=%d", owner, repo, page)
+		var batch []Label
+		if err := getJSON(apiURL, token, &batch); err != nil {
+			return nil, err
+		}
+		labels = append(labels, batch...)
+		if len(batch) < 100 {
+			break
+		}
+	}
+	return labels, nil
+}
+
+// SearchIssues returns up to limit issues (not pull requests) of the
+// repository matching the search terms, best match first, using GitHub REST
+// API GET /search/issues
+func SearchIssues(owner, repo, terms string, limit int, token string) ([]IssueSearchResult, error) {
+	if token == "" {
+		return nil, fmt.Errorf("github token is required")
+	}
+	q := fmt.Sprintf("repo:%s/%s is:issue %s", owner, repo, terms)
+	apiURL := fmt.Sprintf("https://api.github.com/search/issues?q=%s&per_page=%d", url.QueryEscape(q), limit)
+	var found struct {
+		Items []IssueSearchResult `json:"items"`
+	}
+	if err := getJSON(apiURL, token, &found); err != nil {
+		return nil, err
+	}
+	return found.Items, nil
+}
+
+// AddLabels adds labels to issue or pull request number using GitHub REST
+// API POST /repos/{owner}/{repo}/issues/{number}/labels
+func AddLabels(owner, repo string, number int, labels []string, token string) error {
+	if token == "" {
+		return fmt.Errorf("github token is required")
+	}
+	if len(labels) == 0 {
+		return fmt.Errorf("labels are required")
+	}
+
+	apiURL := fmt.Sprintf("https://api.github.com/repos/%s/%s/issues/%d/labels", owner, repo, number)
+	jsonData, err := json.Marshal(map[string][]string{"labels": labels})
+	if err != nil {
+		return fmt.Errorf("marshal request body: %w", err)
+	}
+
+	req, err := http.NewRequest("POST", apiURL, bytes.NewBuffer(jsonData))
+	if err != nil {
+		return fmt.Errorf("create request: %w", err)
+	}
+	req.Header.Set("Authorization", "Bearer "+token)
+	req.Header.Set("Accept", "application/vnd.github+json")
+	req.Header.Set("Content-Type", "application/json")
+	req.Header.Set("X-GitHub-Api-Version", "2022-11-28")
+
+	client := &http.Client{}
+	resp, err := client.Do(req)
+	if err != nil {
+		return fmt.Errorf("execute request: %w", err)
+	}
+	defer func() { _ = resp.Body.Close() }()
+
+	if resp.StatusCode != http.StatusOK {
+		body, _ := io.ReadAll(resp.Body)
+		return fmt.Errorf("github API error (status %d): %s", resp.StatusCode, string(body))
+	}
+	return nil
+}
+
+// getJSON decodes the response to an authenticated GET of apiURL into out.
+func getJSON(apiURL, token string, out interface{}) error {
+	req, err := http.NewRequest("GET", apiURL, nil)
+	if err != nil {
+		return fmt.Errorf("create request: %w", err)
+	}
+	req.Header.Set("Authorization", "Bearer "+token)
+	req.Header.Set("Accept", "application/vnd.github+json")
+	req.Header.Set("X-GitHub-Api-Version", "2022-11-28")
+
+	client := &http.Client{}
+	resp, err := client.Do(req)
+	if err != nil {
+		return fmt.Errorf("execute request: %w", err)
+	}
+	defer func() { _ = resp.Body.Close() }()
+
+	body, _ := io.ReadAll(resp.Body)
+	if resp.StatusCode != http.StatusOK {
+		return fmt.Errorf("github API error (status %d): %s", resp.StatusCode, string(body))
+	}
+	if err := json.Unmarshal(body, out); err != nil {
+		return fmt.Errorf("decode response: %w", err)
+	}
+	return nil
+}
+
+// CreateIssue opens an issue, labeled with labels when given, and returns its
+// number using GitHub REST API POST /repos/{owner}/{repo}/issues
+func CreateIssue(owner, repo, title, body string, labels []string, token string) (int, error) {
+	if token == "" {
+		return 0, fmt.Errorf("github token is required")
+	}
+	if title == "" {
+		return 0, fmt.Errorf("title is required")
+	}
+
+	apiURL := fmt.Sprintf("https://api.github.com/repos/%s/%s/issues", owner, repo)
+	jsonData, err := json.Marshal(struct {
+		Title  string   `json:"title"`
+		Body   string   `json:"body"`
+		Labels []string `json:"labels,omitempty"`
+	}{title, body, labels})
+	if err != nil {
+		return 0, fmt.Errorf("marshal request body: %w", err)
+	}
+
+	req, err := http.NewRequest("POST", apiURL, bytes.NewBuffer(jsonData))
+	if err != nil {
+		return 0, fmt.Errorf("create request: %w", err)
+	}
+	req.Header.Set("Authorization", "Bearer "+token)
+	req.Header.Set("Accept", "application/vnd.github+json")
+	req.Header.Set("Content-Type", "application/json")
+	req.Header.Set("X-GitHub-Api-Version", "2022-11-28")
+
+	client := &http.Client{}
+	resp, err := client.Do(req)
+	if err != nil {
+		return 0, fmt.Errorf("execute request: %w", err)
+	}
+	defer func() { _ = resp.Body.Close() }()
+
+	bodyBytes, _ := io.ReadAll(resp.Body)
+	if resp.StatusCode != http.StatusCreated {
+		return 0, fmt.Errorf("github API error (status %d): %s", resp.StatusCode, string(bodyBytes))
+	}
+	var created struct {
+		Number int `json:"number"`
+	}
+	if err := json.Unmarshal(bodyBytes, &created); err != nil {
+		return 0, fmt.Errorf("decode issue: %w", err)
+	}
+	return created.Number, nil
+}
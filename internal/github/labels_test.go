@@ -0,0 +1,24 @@
+package github
+
+import "testing"
+
+func TestLabels_Validation(t *testing.T) {
+	if _, err := ListLabels("owner", "repo", ""); err == nil || err.Error() != "github token is required" {
+		t.Errorf("ListLabels without token: got %v", err)
+	}
+	if _, err := SearchIssues("owner", "repo", "crash", 5, ""); err == nil || err.Error() != "github token is required" {
+		t.Errorf("SearchIssues without token: got %v", err)
+	}
+	if err := AddLabels("owner", "repo", 1, []string{"bug"}, ""); err == nil || err.Error() != "github token is required" {
+		t.Errorf("AddLabels without token: got %v", err)
+	}
+	if err := AddLabels("owner", "repo", 1, nil, "token"); err == nil || err.Error() != "labels are required" {
+		t.Errorf("AddLabels without labels: got %v", err)
+	}
+	if _, err := CreateIssue("owner", "repo", "Weekly audit", "", nil, ""); err == nil || err.Error() != "github token is required" {
+		t.Errorf("CreateIssue without token: got %v", err)
+	}
+	if _, err := CreateIssue("owner", "repo", "", "body", nil, "token"); err == nil || err.Error() != "title is required" {
+		t.Errorf("CreateIssue without title: got %v", err)
+	}
+}
@@ -0,0 +1,155 @@
+package github
+
+import (
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"io"
+	"net/http"
+)
+
+// maxTeamPages bounds ListUserTeams at 1000 teams.
+const maxTeamPages = 10
+
+// GetOrgRole returns the user's role in organization org ("admin" or
+// "member"), or "" when they are not an active member or org is a user
+// account, using GitHub REST API
+// GET /orgs/{org}/memberships/{username}
+// The installation needs the organization members read permission.
+func GetOrgRole(org, username, token string) (string, error) {
+	if token == "" {
+		return "", fmt.Errorf("github token is required")
+	}
+	if username == "" {
+		return "", fmt.Errorf("username is required")
+	}
+
+	url := fmt.Sprintf("https://api.github.com/orgs/%s/memberships/%s", org, username)
+	req, err := http.NewRequest("GET", url, nil)
+	if err != nil {
+		return "", fmt.Errorf("create request: %w", err)
+	}
+
+	req.Header.Set("Authorization", "Bearer "+token)
+	req.Header.Set("Accept", "application/vnd.github+json")
+	req.Header.Set("X-GitHub-Api-Version", "2022-11-28")
+
+	client := &http.Client{}
+	resp, err := client.Do(req)
+	if err != nil {
+		return "", fmt.Errorf("execute request: %w", err)
+	}
+	defer func() { _ = resp.Body.Close() }()
+
+	bodyBytes, _ := io.ReadAll(resp.Body)
+	if resp.StatusCode == http.StatusNotFound {
+		return "", nil
+	}
+	if resp.StatusCode != http.StatusOK {
+		return "", fmt.Errorf("github API error (status %d): %s", resp.StatusCode, string(bodyBytes))
+	}
+
+	var membership struct {
+		Role  string `json:"role"`
+		State string `json:"state"`
+	}
+	if err := json.Unmarshal(bodyBytes, &membership); err != nil {
+		return "", fmt.Errorf("decode membership: %w", err)
+	}
+	if membership.State != "active" {
+		return "", nil
+	}
+	return membership.Role, nil
+}
+
+const userTeamsQuery = `query UserTeams($org: String!, $login: String!, $cursor: String) {
+  organization(login: $org) {
+    teams(first: 100, userLogins: [$login], after: $cursor) {
+      pageInfo { hasNextPage endCursor }
+      nodes { slug }
+    }
+  }
+}`
+
+// ListUserTeams returns the slugs of the teams of organization org the user
+// belongs to, directly or through a child team, using the GitHub GraphQL
+// API organization teams connection; a user account has none. The
+// installation needs the organization members read permission.
+func ListUserTeams(org, username, token string) ([]string, error) {
+	if token == "" {
+		return nil, fmt.Errorf("github token is required")
+	}
+	if username == "" {
+		return nil, fmt.Errorf("username is required")
+	}
+
+	var teams []string
+	var cursor *string
+	for page := 0; page < maxTeamPages; page++ {
+		payload, err := json.Marshal(map[string]any{
+			"query":     userTeamsQuery,
+			"variables": map[string]any{"org": org, "login": username, "cursor": cursor},
+		})
+		if err != nil {
+			return nil, fmt.Errorf("marshal request body: %w", err)
+		}
+		req, err := http.NewRequest("POST", "https://api.github.com/graphql", bytes.NewReader(payload))
+		if err != nil {
+			return nil, fmt.Errorf("create request: %w", err)
+		}
+		req.Header.Set("Authorization", "Bearer "+token)
+		req.Header.Set("Content-Type", "application/json")
+
+		client := &http.Client{}
+		resp, err := client.Do(req)
+		if err != nil {
+			return nil, fmt.Errorf("execute request: %w", err)
+		}
+		bodyBytes, _ := io.ReadAll(resp.Body)
+		_ = resp.Body.Close()
+		if resp.StatusCode != http.StatusOK {
+			return nil, fmt.Errorf("github API error (status %d): %s", resp.StatusCode, string(bodyBytes))
+		}
+
+		var body struct {
+			Data struct {
+				Organization *struct {
+					Teams struct {
+						PageInfo struct {
+							HasNextPage bool   `json:"hasNextPage"`
+							EndCursor   string `json:"endCursor"`
+						} `json:"pageInfo"`
+						Nodes []struct {
+							Slug string `json:"slug"`
+						} `json:"nodes"`
+					} `json:"teams"`
+				} `json:"organization"`
+			} `json:"data"`
+			Errors []struct {
+				Type    string `json:"type"`
+				Message string `json:"message"`
+			} `json:"errors"`
+		}
+		if err := json.Unmarshal(bodyBytes, &body); err != nil {
+			return nil, fmt.Errorf("decode teams: %w", err)
+		}
+		if body.Data.Organization == nil {
+			// a user account, reported as a NOT_FOUND error
+			if len(body.Errors) == 0 || body.Errors[0].Type == "NOT_FOUND" {
+				return nil, nil
+			}
+		}
+		if len(body.Errors) > 0 {
+			return nil, fmt.Errorf("github graphql error: %s", body.Errors[0].Message)
+		}
+		connection := body.Data.Organization.Teams
+		for _, t := range connection.Nodes {
+			teams = append(teams, t.Slug)
+		}
+		if !connection.PageInfo.HasNextPage {
+			break
+		}
+		cursor = &connection.PageInfo.EndCursor
+	}
+	return teams, nil
+}
@@ -0,0 +1,21 @@
+package github
+
+import "testing"
+
+func TestGetOrgRole_Validation(t *testing.T) {
+	if _, err := GetOrgRole("acme", "alice", ""); err == nil || err.Error() != "github token is required" {
+		t.Errorf("missing token: got %v", err)
+	}
+	if _, err := GetOrgRole("acme", "", "token"); err == nil || err.Error() != "username is required" {
+		t.Errorf("missing username: got %v", err)
+	}
+}
+
+func TestListUserTeams_Validation(t *testing.T) {
+	if _, err := ListUserTeams("acme", "alice", ""); err == nil || err.Error() != "github token is required" {
+		t.Errorf("missing token: got %v", err)
+	}
+	if _, err := ListUserTeams("acme", "", "token"); err == nil || err.Error() != "username is required" {
+		t.Errorf("missing username: got %v", err)
+	}
+}
@@ -0,0 +1,118 @@
+package github
+
+import (
+	"fmt"
+	"os"
+	"path/filepath"
+	"strings"
+	"unicode/utf8"
+)
+
+// MaxCommentLength is the longest body GitHub accepts for a comment.
+const MaxCommentLength = 65536
+
+// Markers FilterOutput leaves in place of what it removes.
+const (
+	RedactedTokenMarker = "[REDACTED_GITHUB_TOKEN]"
+	WorkspaceMarker     = "[WORKSPACE]"
+	InternalPathMarker  = "[INTERNAL_PATH]"
+)
+
+// minTokenLength keeps a placeholder token such as "x" from redacting
+// every occurrence of a letter.
+const minTokenLength = 8
+
+// FilterOutput makes provider output safe to post on GitHub or keep as a
+// task summary. It redacts token (the installation token the task ran
+// with) and anything shaped like a GitHub token, rewrites paths inside a
+// checkout as [WORKSPACE]/<path in the repository>, hides other paths on
+// the server and truncates what GitHub would refuse as too long.
+func FilterOutput(s, token string) string {
+	if s == "" {
+		return s
+	}
+	if len(token) >= minTokenLength {
+		s = strings.ReplaceAll(s, token, RedactedTokenMarker)
+	}
+	s = RedactGitHubTokens(s)
+	s = redactPaths(s)
+	return truncateOutput(s, MaxCommentLength)
+}
+
+// redactPaths rewrites absolute paths under the clone directory, the temp
+// directory and the home directory of the server.
+func redactPaths(s string) string {
+	s = replacePaths(s, cloneRoot(), func(rest string) string {
+		// the first segment names the checkout; the rest is in the repository
+		if _, inRepo, ok := strings.Cut(rest, "/"); ok && inRepo != "" {
+			return WorkspaceMarker + "/" + inRepo
+		}
+		return InternalPathMarker
+	})
+	internal := func(string) string { return InternalPathMarker }
+	s = replacePaths(s, os.TempDir(), internal)
+	if home, err := os.UserHomeDir(); err == nil {
+		s = replacePaths(s, home, internal)
+	}
+	return s
+}
+
+// replacePaths replaces each path that is root or below it with
+// replace(the part below root).
+func replacePaths(s, root string, replace func(rest string) string) string {
+	root = filepath.ToSlash(filepath.Clean(root))
+	if root == "" || root == "." || root == "/" {
+		return s
+	}
+	var b strings.Builder
+	for {
+		i := strings.Index(s, root)
+		if i < 0 {
+			b.WriteString(s)
+			return b.String()
+		}
+		end := i + len(root)
+		// "/tmpfoo" is not under "/tmp", and "/a/tmp" not under "/tmp"
+		if (end < len(s) && s[end] != '/' && isPathChar(s[end])) || (i > 0 && isPathChar(s[i-1])) {
+			b.WriteString(s[:end])
+			s = s[end:]
+			continue
+		}
+		for end < len(s) && isPathChar(s[end]) {
+			end++
+		}
+		// sentence punctuation after a path is not part of it
+		for end > i+len(root) && strings.ContainsRune(".:", rune(s[end-1])) {
+			end--
+		}
+		b.WriteString(s[:i])
+		b.WriteString(replace(strings.TrimPrefix(s[i+len(root):end], "/")))
+		s = s[end:]
+	}
+}
+
+func isPathChar(c byte) bool {
+	switch {
+	case c >= 'a' && c <= 'z', c >= 'A' && c <= 'Z', c >= '0' && c <= '9':
+		return true
+	}
+	return strings.IndexByte("/._-+@~:", c) >= 0
+}
+
+// truncateOutput cuts s to at most max bytes on a rune boundary, closing a
+// code block left open and saying how much was cut.
+func truncateOutput(s string, max int) string {
+	if len(s) <= max {
+		return s
+	}
+	const reserve = 128 // room for the fence and the marker
+	cut := max - reserve
+	for cut > 0 && !utf8.RuneStart(s[cut]) {
+		cut--
+	}
+	kept := s[:cut]
+	if strings.Count(kept, "```")%2 == 1 {
+		kept += "\n```"
+	}
+	return kept + fmt.Sprintf("\n\n[TRUNCATED: %d more bytes]", len(s)-cut)
+}
@@ -0,0 +1,63 @@
+package github
+
+import (
+	"strings"
+	"testing"
+	"unicode/utf8"
+)
+
+func TestFilterOutput_Tokens(t *testing.T) {
+	token := "v1.0123456789abcdef0123456789abcdef01234567"
+	in := "pushed with https://x-access-token:" + token + "@github.com/o/r and ghp_" + strings.Repeat("a", 36)
+	got := FilterOutput(in, token)
+	want := "pushed with https://x-access-token:" + RedactedTokenMarker + "@github.com/o/r and " + RedactedTokenMarker
+	if got != want {
+		t.Fatalf("got %q, want %q", got, want)
+	}
+	// too short to tell from ordinary text
+	if got := FilterOutput("the fix", "x"); got != "the fix" {
+		t.Fatalf("short token redacted: %q", got)
+	}
+}
+
+func TestFilterOutput_Paths(t *testing.T) {
+	t.Cleanup(func() { SetCloneDir("") })
+	SetCloneDir("/srv/swe-workspaces")
+	t.Setenv("TMPDIR", "/var/swe-tmp")
+	t.Setenv("HOME", "/home/swe")
+
+	cases := []struct{ in, want string }{
+		{
+			"Fixed /srv/swe-workspaces/owner-repo-issue-1-99/internal/a.go:12.",
+			"Fixed [WORKSPACE]/internal/a.go:12.",
+		},
+		{"Cloned into `/srv/swe-workspaces/owner-repo-issue-1-99`", "Cloned into `[INTERNAL_PATH]`"},
+		{"log at /var/swe-tmp/run.log, config in /home/swe/.config/swe", "log at [INTERNAL_PATH], config in [INTERNAL_PATH]"},
+		{"/var/swe-tmpfile and /usr/home/swe/x are elsewhere", "/var/swe-tmpfile and /usr/home/swe/x are elsewhere"},
+		{"see internal/a.go", "see internal/a.go"},
+	}
+	for _, c := range cases {
+		if got := FilterOutput(c.in, ""); got != c.want {
+			t.Errorf("FilterOutput(%q) = %q, want %q", c.in, got, c.want)
+		}
+	}
+}
+
+func TestFilterOutput_Truncates(t *testing.T) {
+	short := strings.Repeat("a", MaxCommentLength)
+	if got := FilterOutput(short, ""); got != short {
+		t.Fatal("output within the limit changed")
+	}
+
+	in := "```go\n" + strings.Repeat("é", MaxCommentLength)
+	got := FilterOutput(in, "")
+	if len(got) > MaxCommentLength {
+		t.Fatalf("filtered output is %d bytes", len(got))
+	}
+	if !utf8.ValidString(got) {
+		t.Fatal("cut inside a character")
+	}
+	if !strings.Contains(got, "\n```\n\n[TRUNCATED: ") || !strings.HasSuffix(got, " more bytes]") {
+		t.Fatalf("no closing fence or marker: %q", got[len(got)-60:])
+	}
+}
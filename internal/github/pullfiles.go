@@ -0,0 +1,111 @@
+package github
+
+import (
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"io"
+	"net/http"
+)
+
+// maxPullFilePages bounds ListPullRequestPatches: GitHub lists at most
+// 3000 files of a pull request, 100 per page.
+const maxPullFilePages = 30
+
+// PullRequestFile is a file a pull request changes, with its diff.
+type PullRequestFile struct {
+	Filename  string `json:"filename"`
+	Status    string `json:"status"` // added, removed, modified, renamed, ...
+	Additions int    `json:"additions"`
+	Deletions int    `json:"deletions"`
+	// Patch is the file's diff hunks; GitHub leaves it empty for binary
+	// files and diffs too large to show
+	Patch string `json:"patch,omitempty"`
+}
+
+// ListPullRequestFiles lists the files pull request number changes, with
+// their diffs, using GitHub REST API
+// GET /repos/{owner}/{repo}/pulls/{number}/files
+func ListPullRequestFiles(owner, repo string, number int, token string) ([]PullRequestFile, error) {
+	if token == "" {
+		return nil, fmt.Errorf("github token is required")
+	}
+	var files []PullRequestFile
+	for page := 1; page <= maxPullFilePages; page++ {
+		apiURL := fmt.Sprintf("https://api.github.com/repos/%s/%s/pulls/%d/files?per_page=100&page=%d", owner, repo, number, page)
+		req, err := http.NewRequest("GET", apiURL, nil)
+		if err != nil {
+			return nil, fmt.Errorf("create request: %w", err)
+		}
+		req.Header.Set("Authorization", "Bearer "+token)
+		req.Header.Set("Accept", "application/vnd.github+json")
+		req.Header.Set("X-GitHub-Api-Version", "2022-11-28")
+
+		client := &http.Client{}
+		resp, err := client.Do(req)
+		if err != nil {
+			return nil, fmt.Errorf("execute request: %w", err)
+		}
+		body, _ := io.ReadAll(resp.Body)
+		_ = resp.Body.Close()
+		if resp.StatusCode != http.StatusOK {
+			return nil, fmt.Errorf("github API error (status %d): %s", resp.StatusCode, string(body))
+		}
+		var batch []PullRequestFile
+		if err := json.Unmarshal(body, &batch); err != nil {
+			return nil, fmt.Errorf("decode response: %w", err)
+		}
+		files = append(files, batch...)
+		if len(batch) < 100 {
+			break
+		}
+	}
+	return files, nil
+}
+
+// CreatePullRequest opens a pull request merging head into base using
+// GitHub REST API POST /repos/{owner}/{repo}/pulls
+// Returns the new pull request's number and HTML URL.
+func CreatePullRequest(owner, repo, title, head, base, body, token string) (int, string, error) {
+	if token == "" {
+		return 0, "", fmt.Errorf("github token is required")
+	}
+	if head == "" || base == "" {
+		return 0, "", fmt.Errorf("head and base branches are required")
+	}
+
+	apiURL := fmt.Sprintf("https://api.github.com/repos/%s/%s/pulls", owner, repo)
+	jsonData, err := json.Marshal(map[string]string{"title": title, "head": head, "base": base, "body": body})
+	if err != nil {
+		return 0, "", fmt.Errorf("marshal request body: %w", err)
+	}
+
+	req, err := http.NewRequest("POST", apiURL, bytes.NewBuffer(jsonData))
+	if err != nil {
+		return 0, "", fmt.Errorf("create request: %w", err)
+	}
+	req.Header.Set("Authorization", "Bearer "+token)
+	req.Header.Set("Accept", "application/vnd.github+json")
+	req.Header.Set("Content-Type", "application/json")
+	req.Header.Set("X-GitHub-Api-Version", "2022-11-28")
+
+	client := &http.Client{}
+	resp, err := client.Do(req)
+	if err != nil {
+		return 0, "", fmt.Errorf("execute request: %w", err)
+	}
+	defer func() { _ = resp.Body.Close() }()
+
+	bodyBytes, _ := io.ReadAll(resp.Body)
+	if resp.StatusCode != http.StatusCreated {
+		return 0, "", fmt.Errorf("github API error (status %d): %s", resp.StatusCode, string(bodyBytes))
+	}
+	var created struct {
+		Number  int    `json:"number"`
+		HTMLURL string `json:"html_url"`
+	}
+	if err := json.Unmarshal(bodyBytes, &created); err != nil {
+		return 0, "", fmt.Errorf("decode pull request: %w", err)
+	}
+	return created.Number, created.HTMLURL, nil
+}
@@ -0,0 +1,18 @@
+package github
+
+import "testing"
+
+func TestListPullRequestFiles_Validation(t *testing.T) {
+	if _, err := ListPullRequestFiles("owner", "repo", 1, ""); err == nil || err.Error() != "github token is required" {
+		t.Errorf("missing token: got %v", err)
+	}
+}
+
+func TestCreatePullRequest_Validation(t *testing.T) {
+	if _, _, err := CreatePullRequest("owner", "repo", "title", "head", "main", "", ""); err == nil || err.Error() != "github token is required" {
+		t.Errorf("missing token: got %v", err)
+	}
+	if _, _, err := CreatePullRequest("owner", "repo", "title", "", "main", "", "token"); err == nil || err.Error() != "head and base branches are required" {
+		t.Errorf("missing head: got %v", err)
+	}
+}
@@ -0,0 +1,106 @@
+package github
+
+import (
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"io"
+	"net/http"
+)
+
+// CreateRelease publishes a GitHub Release for an existing tag using GitHub REST API
+// POST /repos/{owner}/{repo}/releases
+// Returns the release's HTML URL.
+func CreateRelease(owner, repo, tag, name, body, token string) (string, error) {
+	if token == "" {
+		return "", fmt.Errorf("github token is required")
+	}
+	if tag == "" {
+		return "", fmt.Errorf("tag is required")
+	}
+
+	url := fmt.Sprintf("https://api.github.com/repos/%s/%s/releases", owner, repo)
+	jsonData, err := json.Marshal(map[string]string{"tag_name": tag, "name": name, "body": body})
+	if err != nil {
+		return "", fmt.Errorf("marshal request body: %w", err)
+	}
+
+	req, err := http.NewRequest("POST", url, bytes.NewBuffer(jsonData))
+	if err != nil {
+		return "", fmt.Errorf("create request: %w", err)
+	}
+
+	req.Header.Set("Authorization", "Bearer "+token)
+	req.Header.Set("Accept", "application/vnd.github+json")
+	req.Header.Set("Content-Type", "application/json")
+	req.Header.Set("X-GitHub-Api-Version", "2022-11-28")
+
+	client := &http.Client{}
+	resp, err := client.Do(req)
+	if err != nil {
+		return "", fmt.Errorf("execute request: %w", err)
+	}
+	defer func() { _ = resp.Body.Close() }()
+
+	bodyBytes, _ := io.ReadAll(resp.Body)
+	if resp.StatusCode != http.StatusCreated {
+		return "", fmt.Errorf("github API error (status %d): %s", resp.StatusCode, string(bodyBytes))
+	}
+
+	var created struct {
+		HTMLURL string `json:"html_url"`
+	}
+	if err := json.Unmarshal(bodyBytes, &created); err != nil {
+		return "", fmt.Errorf("decode release: %w", err)
+	}
+	return created.HTMLURL, nil
+}
+
+// GetCollaboratorRole returns the user's role in the repository ("admin",
+// "maintain", "write", "triage", "read" or "none") using GitHub REST API
+// GET /repos/{owner}/{repo}/collaborators/{username}/permission
+func GetCollaboratorRole(owner, repo, username, token string) (string, error) {
+	if token == "" {
+		return "", fmt.Errorf("github token is required")
+	}
+	if username == "" {
+		return "", fmt.Errorf("username is required")
+	}
+
+	url := fmt.Sprintf("https://api.github.com/repos/%s/%s/collaborators/%s/permission", owner, repo, username)
+	req, err := http.NewRequest("GET", url, nil)
+	if err != nil {
+		return "", fmt.Errorf("create request: %w", err)
+	}
+
+	req.Header.Set("Authorization", "Bearer "+token)
+	req.Header.Set("Accept", "application/vnd.github+json")
+	req.Header.Set("X-GitHub-Api-Version", "2022-11-28")
+
+	client := &http.Client{}
+	resp, err := client.Do(req)
+	if err != nil {
+		return "", fmt.Errorf("execute request: %w", err)
+	}
+	defer func() { _ = resp.Body.Close() }()
+
+	bodyBytes, _ := io.ReadAll(resp.Body)
+	if resp.StatusCode == http.StatusNotFound {
+		return "none", nil
+	}
+	if resp.StatusCode != http.StatusOK {
+		return "", fmt.Errorf("github API error (status %d): %s", resp.StatusCode, string(bodyBytes))
+	}
+
+	var level struct {
+		Permission string `json:"permission"`
+		RoleName   string `json:"role_name"`
+	}
+	if err := json.Unmarshal(bodyBytes, &level); err != nil {
+		return "", fmt.Errorf("decode permission: %w", err)
+	}
+	if level.RoleName != "" {
+		return level.RoleName, nil
+	}
+	return level.Permission, nil
+}
@@ -0,0 +1,21 @@
+package github
+
+import "testing"
+
+func TestCreateRelease_Validation(t *testing.T) {
+	if _, err := CreateRelease("owner", "repo", "v1.0.0", "v1.0.0", "notes", ""); err == nil || err.Error() != "github token is required" {
+		t.Errorf("missing token: got %v", err)
+	}
+	if _, err := CreateRelease("owner", "repo", "", "", "notes", "token"); err == nil || err.Error() != "tag is required" {
+		t.Errorf("missing tag: got %v", err)
+	}
+}
+
+func TestGetCollaboratorRole_Validation(t *testing.T) {
+	if _, err := GetCollaboratorRole("owner", "repo", "alice", ""); err == nil || err.Error() != "github token is required" {
+		t.Errorf("missing token: got %v", err)
+	}
+	if _, err := GetCollaboratorRole("owner", "repo", "", "token"); err == nil || err.Error() != "username is required" {
+		t.Errorf("missing username: got %v", err)
+	}
+}
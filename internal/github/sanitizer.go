@@ -6,7 +6,7 @@ import (
 )
 
 var (
-	reInvisible            = regexp.MustCompile("[\u200B\u200C\u200D\uFEFF]")
+	reInvisible            = regexp.MustCompile("[\u200B\u200C\u200D\uFEFF\u2060-\u2064\u180E\U000E0000-\U000E007F]")
 	reControl              = regexp.MustCompile("[\u0000-\u0008\u000B\u000C\u000E-\u001F\u007F-\u009F]")
 	reSoftHyphen           = regexp.MustCompile("\u00AD")
 	reBidi                 = regexp.MustCompile("[\u202A-\u202E\u2066-\u2069]")
@@ -127,11 +127,11 @@ func NormalizeHTMLEntities(s string) string {
 
 // RedactGitHubTokens censors GitHub token-like strings.
 func RedactGitHubTokens(s string) string {
-	s = reGitHubPATClassic.ReplaceAllString(s, "[REDACTED_GITHUB_TOKEN]")
-	s = reGitHubOAuth.ReplaceAllString(s, "[REDACTED_GITHUB_TOKEN]")
-	s = reGitHubInstallation.ReplaceAllString(s, "[REDACTED_GITHUB_TOKEN]")
-	s = reGitHubRefresh.ReplaceAllString(s, "[REDACTED_GITHUB_TOKEN]")
-	s = reGitHubFineGrained.ReplaceAllString(s, "[REDACTED_GITHUB_TOKEN]")
+	s = reGitHubPATClassic.ReplaceAllString(s, RedactedTokenMarker)
+	s = reGitHubOAuth.ReplaceAllString(s, RedactedTokenMarker)
+	s = reGitHubInstallation.ReplaceAllString(s, RedactedTokenMarker)
+	s = reGitHubRefresh.ReplaceAllString(s, RedactedTokenMarker)
+	s = reGitHubFineGrained.ReplaceAllString(s, RedactedTokenMarker)
 	return s
 }
 
@@ -0,0 +1,69 @@
+package github
+
+import (
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"io"
+	"net/http"
+)
+
+// Commit status states accepted by CreateCommitStatus.
+const (
+	StatusPending = "pending"
+	StatusSuccess = "success"
+	StatusFailure = "failure"
+	StatusError   = "error"
+)
+
+// CommitStatus is one status of a commit, shown on pull requests next to
+// check runs. Creating statuses needs the statuses:write permission only.
+type CommitStatus struct {
+	State       string `json:"state"`
+	Context     string `json:"context"`
+	Description string `json:"description,omitempty"` // at most 140 characters
+	TargetURL   string `json:"target_url,omitempty"`
+}
+
+// CreateCommitStatus sets the status of sha using GitHub REST API
+// POST /repos/{owner}/{repo}/statuses/{sha}
+// A later status with the same context replaces the earlier one.
+func CreateCommitStatus(owner, repo, sha, token string, status CommitStatus) error {
+	if token == "" {
+		return fmt.Errorf("github token is required")
+	}
+	if sha == "" {
+		return fmt.Errorf("commit SHA is required")
+	}
+	if len(status.Description) > 140 {
+		status.Description = status.Description[:137] + "..."
+	}
+	body, err := json.Marshal(status)
+	if err != nil {
+		return fmt.Errorf("encode status: %w", err)
+	}
+
+	apiURL := fmt.Sprintf("https://api.github.com/repos/%s/%s/statuses/%s", owner, repo, sha)
+	req, err := http.NewRequest("POST", apiURL, bytes.NewReader(body))
+	if err != nil {
+		return fmt.Errorf("create request: %w", err)
+	}
+
+	req.Header.Set("Authorization", "Bearer "+token)
+	req.Header.Set("Accept", "application/vnd.github+json")
+	req.Header.Set("X-GitHub-Api-Version", "2022-11-28")
+	req.Header.Set("Content-Type", "application/json")
+
+	client := &http.Client{}
+	resp, err := client.Do(req)
+	if err != nil {
+		return fmt.Errorf("execute request: %w", err)
+	}
+	defer func() { _ = resp.Body.Close() }()
+
+	if resp.StatusCode != http.StatusCreated {
+		bodyBytes, _ := io.ReadAll(resp.Body)
+		return fmt.Errorf("github API error (status %d): %s", resp.StatusCode, string(bodyBytes))
+	}
+	return nil
+}
@@ -0,0 +1,12 @@
+package github
+
+import "testing"
+
+func TestCreateCommitStatus_Validation(t *testing.T) {
+	if err := CreateCommitStatus("owner", "repo", "abc", "", CommitStatus{State: StatusPending}); err == nil || err.Error() != "github token is required" {
+		t.Errorf("missing token: got %v", err)
+	}
+	if err := CreateCommitStatus("owner", "repo", "", "token", CommitStatus{State: StatusPending}); err == nil || err.Error() != "commit SHA is required" {
+		t.Errorf("missing sha: got %v", err)
+	}
+}
@@ -0,0 +1,36 @@
+// Package hmacsig checks the hex HMAC-SHA256 signatures webhook senders put
+// on the requests they deliver.
+package hmacsig
+
+import (
+	"crypto/hmac"
+	"crypto/sha256"
+	"encoding/hex"
+	"strings"
+)
+
+// Prefix starts a signature in the "sha256=<hex>" form GitHub and Jira send.
+const Prefix = "sha256="
+
+// Sign returns the hex HMAC-SHA256 of payload keyed with secret.
+func Sign(payload []byte, secret string) string {
+	mac := hmac.New(sha256.New, []byte(secret))
+	mac.Write(payload)
+	return hex.EncodeToString(mac.Sum(nil))
+}
+
+// Valid reports whether signature is the hex HMAC-SHA256 of payload keyed
+// with secret, comparing in constant time. Nothing is valid without a
+// secret.
+func Valid(payload []byte, signature, secret string) bool {
+	if signature == "" || secret == "" {
+		return false
+	}
+	return hmac.Equal([]byte(strings.ToLower(signature)), []byte(Sign(payload, secret)))
+}
+
+// ValidPrefixed is Valid for a signature in the "sha256=<hex>" form.
+func ValidPrefixed(payload []byte, signature, secret string) bool {
+	got, ok := strings.CutPrefix(signature, Prefix)
+	return ok && Valid(payload, got, secret)
+}
@@ -0,0 +1,48 @@
+package hmacsig
+
+import "testing"
+
+func TestValid(t *testing.T) {
+	payload := []byte(`{"action":"opened"}`)
+	// echo -n '{"action":"opened"}' | openssl dgst -sha256 -hmac secret
+	const sig = "d42142b53efbc7cf5cd20b6e074eb33707e0de3b368f698e6d6f6c824ffb8d37"
+
+	if got := Sign(payload, "secret"); got != sig {
+		t.Fatalf("Sign() = %s", got)
+	}
+	tests := []struct {
+		name      string
+		payload   []byte
+		signature string
+		secret    string
+		want      bool
+	}{
+		{"valid", payload, sig, "secret", true},
+		{"upper case hex", payload, "D42142B53EFBC7CF5CD20B6E074EB33707E0DE3B368F698E6D6F6C824FFB8D37", "secret", true},
+		{"wrong secret", payload, sig, "other", false},
+		{"other payload", []byte(`{}`), sig, "secret", false},
+		{"one character off", payload, sig[:len(sig)-1] + "6", "secret", false},
+		{"empty signature", payload, "", "secret", false},
+		{"no secret", payload, Sign(payload, ""), "", false},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if got := Valid(tt.payload, tt.signature, tt.secret); got != tt.want {
+				t.Fatalf("Valid() = %t, want %t", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestValidPrefixed(t *testing.T) {
+	payload := []byte("payload")
+	sig := Sign(payload, "secret")
+	if !ValidPrefixed(payload, "sha256="+sig, "secret") {
+		t.Fatal("prefixed signature rejected")
+	}
+	for _, signature := range []string{sig, "sha1=" + sig, "sha256="} {
+		if ValidPrefixed(payload, signature, "secret") {
+			t.Fatalf("accepted %q", signature)
+		}
+	}
+}
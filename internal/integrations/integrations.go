@@ -0,0 +1,99 @@
+// Package integrations holds what the chat and issue tracker integrations
+// share: who their users are on GitHub, the repositories they may work in,
+// the GitHub issue a task started from them is opened as, and how their
+// endpoints answer.
+package integrations
+
+import (
+	"fmt"
+	"net/http"
+	"regexp"
+	"strings"
+	"unicode/utf8"
+)
+
+// MaxTitleLen bounds the title of an issue opened for a prompt.
+const MaxTitleLen = 80
+
+// repoPattern matches owner/repo.
+var repoPattern = regexp.MustCompile(`^[A-Za-z0-9_.-]+/[A-Za-z0-9_.-]+$`)
+
+// ParseUsers parses ID=github-login entries, mapping the users of an
+// integration to the GitHub logins their tasks run as. id names the ID in
+// errors, such as SLACKID.
+func ParseUsers(entries []string, id string) (map[string]string, error) {
+	users := make(map[string]string, len(entries))
+	for _, entry := range entries {
+		user, login, ok := strings.Cut(entry, "=")
+		user, login = strings.TrimSpace(user), strings.TrimSpace(login)
+		if !ok || user == "" || login == "" || strings.ContainsAny(login, " /") {
+			return nil, fmt.Errorf("user %q must be %s=github-login", entry, id)
+		}
+		users[user] = login
+	}
+	return users, nil
+}
+
+// ParseRepos parses KEY=owner/repo entries into the repositories each key,
+// such as a project, may work in, by upper-cased key. A key listed more
+// than once may work in each of its repositories; the first is its
+// default. kind names the key in errors, such as PROJECT.
+func ParseRepos(entries []string, kind string) (map[string][]string, error) {
+	repos := make(map[string][]string, len(entries))
+	for _, entry := range entries {
+		key, repo, ok := strings.Cut(entry, "=")
+		key, repo = strings.ToUpper(strings.TrimSpace(key)), strings.TrimSpace(repo)
+		if !ok || key == "" || !repoPattern.MatchString(repo) {
+			return nil, fmt.Errorf("%s %q must be %s=owner/repo", strings.ToLower(kind), entry, kind)
+		}
+		repos[key] = append(repos[key], repo)
+	}
+	return repos, nil
+}
+
+// Allows reports whether repo is one of repos, ignoring case as GitHub
+// does.
+func Allows(repos []string, repo string) bool {
+	for _, r := range repos {
+		if strings.EqualFold(r, repo) {
+			return true
+		}
+	}
+	return false
+}
+
+// IssueTitle is the title of the issue opened for prompt: its first line,
+// shortened to MaxTitleLen runes.
+func IssueTitle(prompt string) string {
+	title, _, _ := strings.Cut(prompt, "\n")
+	title = strings.TrimSpace(title)
+	if utf8.RuneCountInString(title) > MaxTitleLen {
+		title = string([]rune(title)[:MaxTitleLen-1]) + "…"
+	}
+	return title
+}
+
+// IssueBody is the body of an issue opened from an integration: the intro
+// line, then text quoted under heading, unless text is blank.
+func IssueBody(intro, heading, text string) string {
+	var b strings.Builder
+	b.WriteString(intro + "\n")
+	if text = strings.TrimSpace(text); text != "" {
+		fmt.Fprintf(&b, "\n**%s:**\n\n", heading)
+		for _, line := range strings.Split(text, "\n") {
+			b.WriteString(strings.TrimRight("> "+line, " ") + "\n")
+		}
+	}
+	return b.String()
+}
+
+// IssueURL is the address of issue number of repo ("owner/repo").
+func IssueURL(repo string, number int) string {
+	return fmt.Sprintf("https://github.com/%s/issues/%d", repo, number)
+}
+
+// WriteText answers a webhook with status and a plain text msg.
+func WriteText(w http.ResponseWriter, status int, msg string) {
+	w.WriteHeader(status)
+	_, _ = w.Write([]byte(msg))
+}
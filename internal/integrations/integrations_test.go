@@ -0,0 +1,41 @@
+package integrations
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"unicode/utf8"
+)
+
+func TestIssueTitle(t *testing.T) {
+	if got := IssueTitle("  fix the login page  \nit 500s on submit"); got != "fix the login page" {
+		t.Fatalf("IssueTitle() = %q", got)
+	}
+	long := IssueTitle(strings.Repeat("修", 100))
+	if utf8.RuneCountInString(long) != MaxTitleLen || !utf8.ValidString(long) || !strings.HasSuffix(long, "…") {
+		t.Fatalf("long title = %q", long)
+	}
+}
+
+func TestIssueBody(t *testing.T) {
+	got := IssueBody("Opened from chat.", "Task", "fix the login page\n\nit 500s  ")
+	want := "Opened from chat.\n\n**Task:**\n\n> fix the login page\n>\n> it 500s\n"
+	if got != want {
+		t.Fatalf("IssueBody() = %q, want %q", got, want)
+	}
+	if got := IssueBody("Opened for PROJ-1.", "Description", " \n"); got != "Opened for PROJ-1.\n" {
+		t.Fatalf("IssueBody() without text = %q", got)
+	}
+}
+
+func TestIssueURLAndWriteText(t *testing.T) {
+	if got := IssueURL("acme/api", 7); got != "https://github.com/acme/api/issues/7" {
+		t.Fatalf("IssueURL() = %q", got)
+	}
+	w := httptest.NewRecorder()
+	WriteText(w, http.StatusAccepted, "Task queued")
+	if w.Code != http.StatusAccepted || w.Body.String() != "Task queued" {
+		t.Fatalf("WriteText() = %d %q", w.Code, w.Body.String())
+	}
+}
@@ -0,0 +1,62 @@
+package jira
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"fmt"
+	"io"
+	"net/http"
+	"net/url"
+	"time"
+)
+
+// requestTimeout bounds one call to the Jira REST API.
+const requestTimeout = 15 * time.Second
+
+// client posts to the Jira REST API (v2) with basic auth.
+type client struct {
+	baseURL string
+	email   string
+	token   string
+	http    *http.Client
+}
+
+// addComment comments body on the issue key.
+func (c *client) addComment(ctx context.Context, key, body string) error {
+	return c.do(ctx, http.MethodPost, "/rest/api/2/issue/"+url.PathEscape(key)+"/comment", map[string]string{"body": body})
+}
+
+// addLabel adds label to the issue key.
+func (c *client) addLabel(ctx context.Context, key, label string) error {
+	update := map[string]any{"update": map[string]any{"labels": []map[string]string{{"add": label}}}}
+	return c.do(ctx, http.MethodPut, "/rest/api/2/issue/"+url.PathEscape(key), update)
+}
+
+func (c *client) do(ctx context.Context, method, path string, body any) error {
+	data, err := json.Marshal(body)
+	if err != nil {
+		return fmt.Errorf("marshal request body: %w", err)
+	}
+	req, err := http.NewRequestWithContext(ctx, method, c.baseURL+path, bytes.NewReader(data))
+	if err != nil {
+		return fmt.Errorf("create request: %w", err)
+	}
+	req.SetBasicAuth(c.email, c.token)
+	req.Header.Set("Accept", "application/json")
+	req.Header.Set("Content-Type", "application/json")
+	hc := c.http
+	if hc == nil {
+		hc = &http.Client{Timeout: requestTimeout}
+	}
+	resp, err := hc.Do(req)
+	if err != nil {
+		return fmt.Errorf("%s %s: %w", method, path, err)
+	}
+	defer resp.Body.Close()
+	if resp.StatusCode/100 != 2 {
+		msg, _ := io.ReadAll(io.LimitReader(resp.Body, 512))
+		return fmt.Errorf("%s %s: status %d: %s", method, path, resp.StatusCode, bytes.TrimSpace(msg))
+	}
+	return nil
+}
@@ -0,0 +1,331 @@
+// Package jira starts tasks from Jira issue comments and reports their
+// progress back to the Jira issue.
+//
+// A comment containing the trigger keyword on a Jira issue linked to a
+// GitHub repository queues the text after the keyword as a task. The link
+// is a "github:owner/repo" or "github:owner/repo#123" label on the issue,
+// or else the repository configured for the issue's project. A label may
+// only name a repository configured for the issue's project. Without an
+// issue number the task works on a new GitHub issue mirroring the Jira
+// issue, which is then labelled so later comments reuse it. The task runs
+// as the GitHub user the commenter's Jira account is mapped to.
+package jira
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"log/slog"
+	"net/http"
+	"regexp"
+	"strconv"
+	"strings"
+	"sync"
+
+	"github.com/cexll/swe/internal/hmacsig"
+	"github.com/cexll/swe/internal/integrations"
+	"github.com/cexll/swe/internal/logging"
+	"github.com/cexll/swe/internal/notify"
+)
+
+// Marker starts every comment the integration posts, so they never trigger
+// a task themselves.
+const Marker = "[swe-agent]"
+
+// labelPrefix marks the issue label linking a Jira issue to GitHub.
+const labelPrefix = "github:"
+
+// maxSummaryLen bounds the task summary quoted in a Jira comment.
+const maxSummaryLen = 4000
+
+// linkPattern matches the GitHub side of a link: owner/repo or
+// owner/repo#123.
+var linkPattern = regexp.MustCompile(`^([A-Za-z0-9_.-]+/[A-Za-z0-9_.-]+)(?:#(\d+))?$`)
+
+// Config connects the integration to a Jira site.
+type Config struct {
+	// BaseURL is the site, such as https://example.atlassian.net
+	BaseURL string
+	// Email and APIToken authenticate the comments and labels posted back
+	Email    string
+	APIToken string
+	// WebhookSecret signs the webhook deliveries (X-Hub-Signature)
+	WebhookSecret string
+	// Projects lists by Jira project key the owner/repo its issues may
+	// work in; the first is used when they have no github: label
+	Projects map[string][]string
+	// Users maps Jira account IDs to the GitHub logins tasks run as;
+	// users not listed cannot launch tasks
+	Users map[string]string
+}
+
+// ParseProjects parses PROJECT=owner/repo entries.
+func ParseProjects(entries []string) (map[string][]string, error) {
+	return integrations.ParseRepos(entries, "PROJECT")
+}
+
+// ParseUsers parses ACCOUNTID=github-login entries.
+func ParseUsers(entries []string) (map[string]string, error) {
+	return integrations.ParseUsers(entries, "ACCOUNTID")
+}
+
+// TaskRequest is a task asked for in a Jira comment.
+type TaskRequest struct {
+	Repo string // owner/name
+	// Number is the GitHub issue or pull request to work on; 0 opens an
+	// issue with Title and Body
+	Number int
+	Title  string
+	Body   string
+	Prompt string
+	// Actor is the GitHub login the task runs as; Summary is the task's
+	// prompt summary, naming the commenter
+	Actor   string
+	Summary string
+	// IdempotencyKey identifies the comment, so a redelivery runs nothing
+	IdempotencyKey string
+}
+
+// ErrDuplicate is returned by a Launch for a comment whose task was already
+// accepted.
+var ErrDuplicate = errors.New("comment already handled")
+
+// Launch queues req's task. It returns the task ID and the GitHub issue or
+// pull request it works on, which is set even when queueing fails after an
+// issue was opened.
+type Launch func(ctx context.Context, req TaskRequest) (taskID string, number int, err error)
+
+// Receiver serves the Jira webhook and follows the tasks it started.
+type Receiver struct {
+	config Config
+	launch Launch
+	client *client
+
+	mu      sync.Mutex
+	trigger string
+	tasks   map[string]string // task ID -> Jira issue key
+}
+
+// New returns a Receiver posting to the Jira site of c and starting tasks
+// with launch. trigger is the keyword starting a task.
+func New(c Config, trigger string, launch Launch) *Receiver {
+	c.BaseURL = strings.TrimRight(c.BaseURL, "/")
+	return &Receiver{
+		config:  c,
+		launch:  launch,
+		client:  &client{baseURL: c.BaseURL, email: c.Email, token: c.APIToken},
+		trigger: trigger,
+		tasks:   make(map[string]string),
+	}
+}
+
+// SetTriggerKeyword changes the keyword that starts a task.
+func (r *Receiver) SetTriggerKeyword(keyword string) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	r.trigger = keyword
+}
+
+func (r *Receiver) triggerKeyword() string {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	return r.trigger
+}
+
+// event is the part of a Jira comment_created delivery the receiver uses.
+type event struct {
+	WebhookEvent string `json:"webhookEvent"`
+	Comment      struct {
+		ID     string `json:"id"`
+		Body   string `json:"body"`
+		Author struct {
+			AccountID   string `json:"accountId"`
+			DisplayName string `json:"displayName"`
+		} `json:"author"`
+	} `json:"comment"`
+	Issue struct {
+		Key    string `json:"key"`
+		Fields struct {
+			Summary     string   `json:"summary"`
+			Description string   `json:"description"`
+			Labels      []string `json:"labels"`
+			Project     struct {
+				Key string `json:"key"`
+			} `json:"project"`
+		} `json:"fields"`
+	} `json:"issue"`
+}
+
+// Handle serves POST /webhook/jira.
+func (r *Receiver) Handle(w http.ResponseWriter, req *http.Request) {
+	payload, err := io.ReadAll(http.MaxBytesReader(w, req.Body, 1<<20))
+	if err != nil {
+		http.Error(w, "Error reading payload", http.StatusBadRequest)
+		return
+	}
+	if !hmacsig.ValidPrefixed(payload, req.Header.Get("X-Hub-Signature"), r.config.WebhookSecret) {
+		slog.WarnContext(req.Context(), "Jira signature verification failed", logging.KeyPhase, "jira")
+		http.Error(w, "Invalid signature", http.StatusUnauthorized)
+		return
+	}
+	var ev event
+	if err := json.Unmarshal(payload, &ev); err != nil {
+		http.Error(w, "invalid JSON body", http.StatusBadRequest)
+		return
+	}
+	if ev.WebhookEvent != "comment_created" || ev.Issue.Key == "" {
+		integrations.WriteText(w, http.StatusOK, "Event ignored")
+		return
+	}
+	body := strings.TrimSpace(ev.Comment.Body)
+	trigger := r.triggerKeyword()
+	_, prompt, found := strings.Cut(body, trigger)
+	if strings.HasPrefix(body, Marker) || !found {
+		integrations.WriteText(w, http.StatusOK, "No trigger found")
+		return
+	}
+	key := ev.Issue.Key
+	prompt = strings.TrimSpace(prompt)
+	if prompt == "" {
+		r.comment(key, fmt.Sprintf("%s Say what to do after %s.", Marker, trigger))
+		integrations.WriteText(w, http.StatusOK, "Empty prompt")
+		return
+	}
+	repo, number, project := r.link(ev)
+	if repo == "" {
+		r.comment(key, fmt.Sprintf("%s This issue is not linked to a GitHub repository. Add a %sowner/repo label and comment again.", Marker, labelPrefix))
+		integrations.WriteText(w, http.StatusOK, "No linked repository")
+		return
+	}
+	if !integrations.Allows(r.config.Projects[project], repo) {
+		r.comment(key, fmt.Sprintf("%s Project %s may not work in %s. Ask an operator to add it to JIRA_PROJECTS.", Marker, project, repo))
+		integrations.WriteText(w, http.StatusOK, "Repository not allowed")
+		return
+	}
+	login, ok := r.config.Users[ev.Comment.Author.AccountID]
+	if !ok {
+		r.comment(key, fmt.Sprintf("%s Your Jira account is not linked to a GitHub user. Ask an operator to add it to JIRA_USERS.", Marker))
+		integrations.WriteText(w, http.StatusOK, "Unknown user")
+		return
+	}
+
+	who := ev.Comment.Author.DisplayName
+	if who == "" {
+		who = "a Jira user"
+	}
+	taskReq := TaskRequest{
+		Repo:    repo,
+		Number:  number,
+		Title:   fmt.Sprintf("[%s] %s", key, strings.TrimSpace(ev.Issue.Fields.Summary)),
+		Body:    r.issueBody(ev),
+		Prompt:  prompt,
+		Actor:   login,
+		Summary: fmt.Sprintf("**Jira:** %s, asked by %s", key, who),
+	}
+	if ev.Comment.ID != "" {
+		taskReq.IdempotencyKey = "jira:" + ev.Comment.ID
+	}
+	taskID, number, err := r.launch(req.Context(), taskReq)
+	if errors.Is(err, ErrDuplicate) {
+		integrations.WriteText(w, http.StatusOK, "Duplicate comment ignored")
+		return
+	}
+	if number > 0 && taskReq.Number == 0 {
+		r.linkIssue(key, repo, number)
+	}
+	if err != nil {
+		slog.WarnContext(req.Context(), "Jira task not started", logging.KeyPhase, "jira", "issue", key, "err", err)
+		r.comment(key, fmt.Sprintf("%s The task could not be started: %v", Marker, err))
+		integrations.WriteText(w, http.StatusOK, "Task not started")
+		return
+	}
+	r.mu.Lock()
+	r.tasks[taskID] = key
+	r.mu.Unlock()
+	slog.InfoContext(req.Context(), "Jira task queued", logging.KeyTaskID, taskID, logging.KeyRepo, repo, logging.KeyPhase, "jira", "issue", key, "number", number)
+	r.comment(key, fmt.Sprintf("%s Task %s queued on %s.", Marker, taskID, integrations.IssueURL(repo, number)))
+	integrations.WriteText(w, http.StatusAccepted, "Task queued")
+}
+
+// link returns the repository and the issue or pull request (0: none yet)
+// the Jira issue is linked to, and the issue's project.
+func (r *Receiver) link(ev event) (string, int, string) {
+	project := ev.Issue.Fields.Project.Key
+	if project == "" {
+		project, _, _ = strings.Cut(ev.Issue.Key, "-")
+	}
+	project = strings.ToUpper(project)
+	for _, label := range ev.Issue.Fields.Labels {
+		m := linkPattern.FindStringSubmatch(strings.TrimPrefix(label, labelPrefix))
+		if !strings.HasPrefix(label, labelPrefix) || m == nil {
+			continue
+		}
+		number, _ := strconv.Atoi(m[2])
+		return m[1], number, project
+	}
+	if repos := r.config.Projects[project]; len(repos) > 0 {
+		return repos[0], 0, project
+	}
+	return "", 0, project
+}
+
+// linkIssue labels the Jira issue with the GitHub issue opened for it.
+func (r *Receiver) linkIssue(key, repo string, number int) {
+	ctx, cancel := context.WithTimeout(context.Background(), requestTimeout)
+	defer cancel()
+	if err := r.client.addLabel(ctx, key, fmt.Sprintf("%s%s#%d", labelPrefix, repo, number)); err != nil {
+		slog.Warn("Linking Jira issue failed", logging.KeyRepo, repo, logging.KeyPhase, "jira", "issue", key, "number", number, "err", err)
+	}
+}
+
+// issueBody is the body of the GitHub issue opened for a Jira issue.
+func (r *Receiver) issueBody(ev event) string {
+	intro := fmt.Sprintf("Opened for Jira issue [%s](%s/browse/%s). Progress is reported there and below.", ev.Issue.Key, r.config.BaseURL, ev.Issue.Key)
+	return integrations.IssueBody(intro, "Description", ev.Issue.Fields.Description)
+}
+
+// Name implements notify.Notifier.
+func (r *Receiver) Name() string { return "jira" }
+
+// Notify reports how a task the receiver started ended on its Jira issue.
+// It implements notify.Notifier; register it with notify.Manager.Observe.
+func (r *Receiver) Notify(ctx context.Context, ev notify.Event) error {
+	r.mu.Lock()
+	key, ok := r.tasks[ev.TaskID]
+	if ok && (ev.Type == notify.EventCompleted || ev.Type == notify.EventDeadLettered) {
+		delete(r.tasks, ev.TaskID)
+	}
+	r.mu.Unlock()
+	if !ok {
+		return nil
+	}
+	var msg string
+	switch ev.Type {
+	case notify.EventCompleted:
+		msg = fmt.Sprintf("%s Task %s completed on %s.", Marker, ev.TaskID, integrations.IssueURL(ev.Repo, ev.Number))
+		if s := strings.TrimSpace(ev.Summary); s != "" {
+			if len(s) > maxSummaryLen {
+				s = strings.ToValidUTF8(s[:maxSummaryLen], "") + "\n[... truncated ...]"
+			}
+			msg += "\n\n{noformat}\n" + s + "\n{noformat}"
+		}
+	case notify.EventFailed:
+		msg = fmt.Sprintf("%s Task %s failed: %s. It is retried if attempts remain.", Marker, ev.TaskID, ev.Error)
+	case notify.EventDeadLettered:
+		msg = fmt.Sprintf("%s Task %s gave up: %s.", Marker, ev.TaskID, ev.Error)
+	default:
+		return nil
+	}
+	return r.client.addComment(ctx, key, msg)
+}
+
+// comment posts msg on the Jira issue key, logging a failure.
+func (r *Receiver) comment(key, msg string) {
+	ctx, cancel := context.WithTimeout(context.Background(), requestTimeout)
+	defer cancel()
+	if err := r.client.addComment(ctx, key, msg); err != nil {
+		slog.Warn("Commenting on Jira issue failed", logging.KeyPhase, "jira", "issue", key, "err", err)
+	}
+}
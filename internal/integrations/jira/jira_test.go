@@ -0,0 +1,218 @@
+package jira
+
+import (
+	"bytes"
+	"context"
+	"crypto/hmac"
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"sync"
+	"testing"
+
+	"github.com/cexll/swe/internal/notify"
+)
+
+// fakeJira records the comments and labels posted to it.
+type fakeJira struct {
+	mu       sync.Mutex
+	comments []string
+	labels   []string
+}
+
+func (f *fakeJira) serve(t *testing.T) *httptest.Server {
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if user, pass, ok := r.BasicAuth(); !ok || user != "bot@example.com" || pass != "api-token" {
+			t.Errorf("auth = %q, %q", user, pass)
+		}
+		data, _ := io.ReadAll(r.Body)
+		f.mu.Lock()
+		defer f.mu.Unlock()
+		switch {
+		case r.Method == http.MethodPost && r.URL.Path == "/rest/api/2/issue/OPS-7/comment":
+			var body struct{ Body string }
+			_ = json.Unmarshal(data, &body)
+			f.comments = append(f.comments, body.Body)
+			w.WriteHeader(http.StatusCreated)
+		case r.Method == http.MethodPut && r.URL.Path == "/rest/api/2/issue/OPS-7":
+			f.labels = append(f.labels, string(data))
+			w.WriteHeader(http.StatusNoContent)
+		default:
+			t.Errorf("unexpected %s %s", r.Method, r.URL.Path)
+			w.WriteHeader(http.StatusNotFound)
+		}
+	}))
+	t.Cleanup(srv.Close)
+	return srv
+}
+
+func (f *fakeJira) posted() ([]string, []string) {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	return append([]string(nil), f.comments...), append([]string(nil), f.labels...)
+}
+
+func delivery(t *testing.T, secret, comment string, labels ...string) *http.Request {
+	t.Helper()
+	ev := map[string]any{
+		"webhookEvent": "comment_created",
+		"comment":      map[string]any{"id": "10001", "body": comment, "author": map[string]any{"accountId": "5b10ac8d", "displayName": "Dana"}},
+		"issue": map[string]any{"key": "OPS-7", "fields": map[string]any{
+			"summary": "Login times out", "description": "Steps:\n1. log in", "labels": labels,
+			"project": map[string]any{"key": "OPS"},
+		}},
+	}
+	body, _ := json.Marshal(ev)
+	req := httptest.NewRequest(http.MethodPost, "/webhook/jira", bytes.NewReader(body))
+	mac := hmac.New(sha256.New, []byte(secret))
+	mac.Write(body)
+	req.Header.Set("X-Hub-Signature", "sha256="+hex.EncodeToString(mac.Sum(nil)))
+	return req
+}
+
+func newReceiver(t *testing.T, launch Launch) (*Receiver, *fakeJira) {
+	fake := &fakeJira{}
+	srv := fake.serve(t)
+	r := New(Config{
+		BaseURL:       srv.URL + "/",
+		Email:         "bot@example.com",
+		APIToken:      "api-token",
+		WebhookSecret: "jira-secret",
+		Projects:      map[string][]string{"OPS": {"acme/infra", "acme/api"}},
+		Users:         map[string]string{"5b10ac8d": "dana"},
+	}, "/code", launch)
+	return r, fake
+}
+
+func TestParseProjects(t *testing.T) {
+	got, err := ParseProjects([]string{"ops = acme/infra", "WEB=acme/web", "OPS=acme/api"})
+	if err != nil || strings.Join(got["OPS"], ",") != "acme/infra,acme/api" || strings.Join(got["WEB"], ",") != "acme/web" {
+		t.Fatalf("ParseProjects = %v, %v", got, err)
+	}
+	for _, bad := range []string{"OPS", "=acme/infra", "OPS=infra", "OPS=acme/infra#1"} {
+		if _, err := ParseProjects([]string{bad}); err == nil {
+			t.Errorf("ParseProjects(%q) accepted", bad)
+		}
+	}
+}
+
+func TestHandle_Ignores(t *testing.T) {
+	launched := 0
+	r, fake := newReceiver(t, func(context.Context, TaskRequest) (string, int, error) {
+		launched++
+		return "t1", 1, nil
+	})
+	cases := []struct {
+		name string
+		req  *http.Request
+		want int
+	}{
+		{"bad signature", delivery(t, "other", "/code fix it"), http.StatusUnauthorized},
+		{"no trigger", delivery(t, "jira-secret", "looks good"), http.StatusOK},
+		{"own comment", delivery(t, "jira-secret", Marker+" Say what to do after /code."), http.StatusOK},
+	}
+	for _, c := range cases {
+		w := httptest.NewRecorder()
+		r.Handle(w, c.req)
+		if w.Code != c.want {
+			t.Errorf("%s: status = %d, want %d", c.name, w.Code, c.want)
+		}
+	}
+	if comments, _ := fake.posted(); launched != 0 || len(comments) != 0 {
+		t.Fatalf("launched %d tasks, commented %q", launched, comments)
+	}
+
+	// a label naming a repository the project may not work in
+	w := httptest.NewRecorder()
+	r.Handle(w, delivery(t, "jira-secret", "/code fix it", "github:other/secrets"))
+	if comments, _ := fake.posted(); launched != 0 || len(comments) != 1 || !strings.Contains(comments[0], "may not work in other/secrets") {
+		t.Fatalf("repository not allowed: launched %d tasks, commented %q", launched, comments)
+	}
+
+	// a commenter not mapped to a GitHub user
+	r.config.Users = nil
+	w = httptest.NewRecorder()
+	r.Handle(w, delivery(t, "jira-secret", "/code fix it"))
+	if comments, _ := fake.posted(); launched != 0 || len(comments) != 2 || !strings.Contains(comments[1], "JIRA_USERS") {
+		t.Fatalf("unknown user: launched %d tasks, commented %q", launched, comments)
+	}
+
+	r.config.Projects = nil
+	w = httptest.NewRecorder()
+	r.Handle(w, delivery(t, "jira-secret", "/code fix it"))
+	if comments, _ := fake.posted(); launched != 0 || len(comments) != 3 || !strings.Contains(comments[2], "not linked") {
+		t.Fatalf("unlinked issue: launched %d tasks, commented %q", launched, comments)
+	}
+}
+
+func TestHandle_NewIssue(t *testing.T) {
+	var got TaskRequest
+	r, fake := newReceiver(t, func(_ context.Context, req TaskRequest) (string, int, error) {
+		got = req
+		return "task-1", 12, nil
+	})
+	w := httptest.NewRecorder()
+	r.Handle(w, delivery(t, "jira-secret", "Please /code fix the login timeout"))
+	if w.Code != http.StatusAccepted {
+		t.Fatalf("status = %d: %s", w.Code, w.Body.String())
+	}
+	if got.Repo != "acme/infra" || got.Number != 0 || got.Prompt != "fix the login timeout" || got.Actor != "dana" ||
+		got.Title != "[OPS-7] Login times out" || got.IdempotencyKey != "jira:10001" || !strings.Contains(got.Summary, "Dana") {
+		t.Fatalf("task request = %+v", got)
+	}
+	if !strings.Contains(got.Body, "/browse/OPS-7") || !strings.Contains(got.Body, "> 1. log in") {
+		t.Fatalf("issue body = %q", got.Body)
+	}
+	comments, labels := fake.posted()
+	if len(labels) != 1 || !strings.Contains(labels[0], `"add":"github:acme/infra#12"`) {
+		t.Fatalf("labels = %q", labels)
+	}
+	if len(comments) != 1 || !strings.Contains(comments[0], "task-1 queued on https://github.com/acme/infra/issues/12") {
+		t.Fatalf("comments = %q", comments)
+	}
+
+	// progress reaches the Jira issue until the task ends
+	for _, ev := range []notify.Event{
+		{Type: notify.EventFailed, TaskID: "task-1", Error: "clone failed"},
+		{Type: notify.EventCompleted, TaskID: "task-1", Repo: "acme/infra", Number: 12, Summary: "Raised the timeout"},
+		{Type: notify.EventCompleted, TaskID: "task-1", Repo: "acme/infra", Number: 12},
+		{Type: notify.EventCompleted, TaskID: "other", Repo: "acme/infra", Number: 3},
+	} {
+		if err := r.Notify(context.Background(), ev); err != nil {
+			t.Fatalf("Notify(%s): %v", ev.Type, err)
+		}
+	}
+	comments, _ = fake.posted()
+	if len(comments) != 3 || !strings.Contains(comments[1], "failed: clone failed") ||
+		!strings.Contains(comments[2], "completed") || !strings.Contains(comments[2], "{noformat}\nRaised the timeout\n{noformat}") {
+		t.Fatalf("comments = %q", comments)
+	}
+}
+
+func TestHandle_LinkedIssue(t *testing.T) {
+	var got TaskRequest
+	r, fake := newReceiver(t, func(_ context.Context, req TaskRequest) (string, int, error) {
+		got = req
+		return "task-2", req.Number, nil
+	})
+	w := httptest.NewRecorder()
+	r.Handle(w, delivery(t, "jira-secret", "/code add a retry", "backend", "github:acme/api#5"))
+	if w.Code != http.StatusAccepted || got.Repo != "acme/api" || got.Number != 5 {
+		t.Fatalf("status = %d, request = %+v", w.Code, got)
+	}
+	if _, labels := fake.posted(); len(labels) != 0 {
+		t.Fatalf("linked issue relabelled: %q", labels)
+	}
+
+	// the same comment delivered again
+	r.launch = func(context.Context, TaskRequest) (string, int, error) { return "", 5, ErrDuplicate }
+	w = httptest.NewRecorder()
+	r.Handle(w, delivery(t, "jira-secret", "/code add a retry", "github:acme/api#5"))
+	if comments, _ := fake.posted(); w.Code != http.StatusOK || len(comments) != 1 {
+		t.Fatalf("duplicate: status = %d, comments = %q", w.Code, comments)
+	}
+}
@@ -0,0 +1,155 @@
+package linear
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"net/http"
+	"strings"
+	"time"
+)
+
+// defaultAPIURL is Linear's GraphQL endpoint.
+const defaultAPIURL = "https://api.linear.app/graphql"
+
+// requestTimeout bounds one call to the Linear API.
+const requestTimeout = 15 * time.Second
+
+// client calls the Linear GraphQL API with an API key.
+type client struct {
+	url  string
+	key  string
+	http *http.Client
+}
+
+// issue is the part of a Linear issue the receiver uses.
+type issue struct {
+	Identifier  string `json:"identifier"`
+	Title       string `json:"title"`
+	Description string `json:"description"`
+	URL         string `json:"url"`
+	Team        struct {
+		Key string `json:"key"`
+	} `json:"team"`
+	Labels struct {
+		Nodes []struct {
+			Name string `json:"name"`
+		} `json:"nodes"`
+	} `json:"labels"`
+	Attachments struct {
+		Nodes []struct {
+			URL string `json:"url"`
+		} `json:"nodes"`
+	} `json:"attachments"`
+}
+
+func (i *issue) labels() []string {
+	var names []string
+	for _, n := range i.Labels.Nodes {
+		names = append(names, n.Name)
+	}
+	return names
+}
+
+func (i *issue) attachmentURLs() []string {
+	var urls []string
+	for _, n := range i.Attachments.Nodes {
+		urls = append(urls, n.URL)
+	}
+	return urls
+}
+
+const issueQuery = `query($id: String!) {
+  issue(id: $id) {
+    identifier title description url
+    team { key }
+    labels { nodes { name } }
+    attachments { nodes { url } }
+  }
+}`
+
+// issue reads the issue id.
+func (c *client) issue(ctx context.Context, id string) (*issue, error) {
+	var data struct {
+		Issue *issue `json:"issue"`
+	}
+	if err := c.do(ctx, issueQuery, map[string]any{"id": id}, &data); err != nil {
+		return nil, err
+	}
+	if data.Issue == nil {
+		return nil, fmt.Errorf("issue %s not found", id)
+	}
+	return data.Issue, nil
+}
+
+const commentMutation = `mutation($issueId: String!, $body: String!) {
+  commentCreate(input: {issueId: $issueId, body: $body}) { success }
+}`
+
+// comment comments body on the issue issueID.
+func (c *client) comment(ctx context.Context, issueID, body string) error {
+	return c.do(ctx, commentMutation, map[string]any{"issueId": issueID, "body": body}, nil)
+}
+
+const attachMutation = `mutation($issueId: String!, $title: String!, $subtitle: String, $url: String!) {
+  attachmentCreate(input: {issueId: $issueId, title: $title, subtitle: $subtitle, url: $url}) { success }
+}`
+
+// attach attaches url to the issue issueID; attaching a URL again updates
+// its title and subtitle.
+func (c *client) attach(ctx context.Context, issueID, title, subtitle, url string) error {
+	return c.do(ctx, attachMutation, map[string]any{"issueId": issueID, "title": title, "subtitle": subtitle, "url": url}, nil)
+}
+
+// do runs a GraphQL query, decoding its data into out unless out is nil.
+func (c *client) do(ctx context.Context, query string, vars map[string]any, out any) error {
+	body, err := json.Marshal(map[string]any{"query": query, "variables": vars})
+	if err != nil {
+		return fmt.Errorf("marshal request body: %w", err)
+	}
+	req, err := http.NewRequestWithContext(ctx, http.MethodPost, c.url, bytes.NewReader(body))
+	if err != nil {
+		return fmt.Errorf("create request: %w", err)
+	}
+	req.Header.Set("Authorization", c.key)
+	req.Header.Set("Content-Type", "application/json")
+	hc := c.http
+	if hc == nil {
+		hc = &http.Client{Timeout: requestTimeout}
+	}
+	resp, err := hc.Do(req)
+	if err != nil {
+		return fmt.Errorf("linear API: %w", err)
+	}
+	defer resp.Body.Close()
+	data, err := io.ReadAll(io.LimitReader(resp.Body, 1<<20))
+	if err != nil {
+		return fmt.Errorf("read response: %w", err)
+	}
+	if resp.StatusCode/100 != 2 {
+		return fmt.Errorf("linear API: status %d: %s", resp.StatusCode, bytes.TrimSpace(data[:min(len(data), 512)]))
+	}
+	var result struct {
+		Data   json.RawMessage `json:"data"`
+		Errors []struct {
+			Message string `json:"message"`
+		} `json:"errors"`
+	}
+	if err := json.Unmarshal(data, &result); err != nil {
+		return fmt.Errorf("decode response: %w", err)
+	}
+	if len(result.Errors) > 0 {
+		var msgs []string
+		for _, e := range result.Errors {
+			msgs = append(msgs, e.Message)
+		}
+		return errors.New("linear API: " + strings.Join(msgs, "; "))
+	}
+	if out == nil {
+		return nil
+	}
+	return json.Unmarshal(result.Data, out)
+}
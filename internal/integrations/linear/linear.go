@@ -0,0 +1,404 @@
+// Package linear starts tasks from Linear issue comments and keeps the
+// Linear issue in sync with them.
+//
+// A comment containing the trigger keyword on a Linear issue linked to a
+// GitHub repository queues the text after the keyword as a task. The link
+// is, in order, a "github:owner/repo" or "github:owner/repo#123" label, a
+// GitHub issue or pull request attached to the issue (as Linear's GitHub
+// integration does), or the repository configured for the issue's team. A
+// label or attachment may only name a repository configured for the
+// issue's team. Without an issue number the task works on a new GitHub
+// issue mirroring the Linear issue, which is then attached so later
+// comments reuse it. The task runs as the GitHub user the commenter's
+// Linear account is mapped to.
+//
+// The GitHub issue or pull request attachment shows the task's status, and
+// pull requests named in the task's summary are attached too.
+package linear
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"log/slog"
+	"net/http"
+	"regexp"
+	"strconv"
+	"strings"
+	"sync"
+
+	"github.com/cexll/swe/internal/hmacsig"
+	"github.com/cexll/swe/internal/integrations"
+	"github.com/cexll/swe/internal/logging"
+	"github.com/cexll/swe/internal/notify"
+)
+
+// Marker starts every comment the integration posts, so they never trigger
+// a task themselves.
+const Marker = "**[swe-agent]**"
+
+// SignatureHeader carries the hex HMAC-SHA256 of a webhook body.
+const SignatureHeader = "Linear-Signature"
+
+// labelPrefix marks the issue label linking a Linear issue to GitHub.
+const labelPrefix = "github:"
+
+// maxSummaryLen bounds the task summary quoted in a Linear comment.
+const maxSummaryLen = 4000
+
+var (
+	// linkPattern matches the GitHub side of a label: owner/repo or
+	// owner/repo#123
+	linkPattern = regexp.MustCompile(`^([A-Za-z0-9_.-]+/[A-Za-z0-9_.-]+)(?:#(\d+))?$`)
+	// githubURLPattern matches a GitHub issue or pull request URL
+	githubURLPattern = regexp.MustCompile(`https://github\.com/([A-Za-z0-9_.-]+/[A-Za-z0-9_.-]+)/(issues|pull)/(\d+)`)
+)
+
+// Config connects the integration to a Linear workspace.
+type Config struct {
+	// APIKey authenticates the comments and attachments posted back
+	APIKey string
+	// WebhookSecret signs the webhook deliveries (Linear-Signature)
+	WebhookSecret string
+	// Teams lists by Linear team key the owner/repo its issues may work
+	// in; the first is used when they are not linked otherwise
+	Teams map[string][]string
+	// Users maps Linear user IDs to the GitHub logins tasks run as; users
+	// not listed cannot launch tasks
+	Users map[string]string
+	// APIURL is the GraphQL endpoint; empty uses Linear's
+	APIURL string
+}
+
+// ParseTeams parses TEAM=owner/repo entries.
+func ParseTeams(entries []string) (map[string][]string, error) {
+	return integrations.ParseRepos(entries, "TEAM")
+}
+
+// ParseUsers parses USERID=github-login entries.
+func ParseUsers(entries []string) (map[string]string, error) {
+	return integrations.ParseUsers(entries, "USERID")
+}
+
+// TaskRequest is a task asked for in a Linear comment.
+type TaskRequest struct {
+	Repo string // owner/name
+	// Number is the GitHub issue or pull request to work on; 0 opens an
+	// issue with Title and Body
+	Number int
+	IsPR   bool
+	Title  string
+	Body   string
+	Prompt string
+	// Actor is the GitHub login the task runs as; Summary is the task's
+	// prompt summary, naming the commenter
+	Actor   string
+	Summary string
+	// IdempotencyKey identifies the comment, so a redelivery runs nothing
+	IdempotencyKey string
+}
+
+// ErrDuplicate is returned by a Launch for a comment whose task was already
+// accepted.
+var ErrDuplicate = errors.New("comment already handled")
+
+// Launch queues req's task. It returns the task ID and the GitHub issue or
+// pull request it works on, which is set even when queueing fails after an
+// issue was opened.
+type Launch func(ctx context.Context, req TaskRequest) (taskID string, number int, err error)
+
+// tracked is a task the receiver started.
+type tracked struct {
+	issueID string // Linear issue
+	repo    string
+	number  int
+	isPR    bool
+}
+
+// Receiver serves the Linear webhook and follows the tasks it started.
+type Receiver struct {
+	config Config
+	launch Launch
+	client *client
+
+	mu      sync.Mutex
+	trigger string
+	tasks   map[string]tracked // by task ID
+}
+
+// New returns a Receiver posting to the Linear workspace of c and starting
+// tasks with launch. trigger is the keyword starting a task.
+func New(c Config, trigger string, launch Launch) *Receiver {
+	if c.APIURL == "" {
+		c.APIURL = defaultAPIURL
+	}
+	return &Receiver{
+		config:  c,
+		launch:  launch,
+		client:  &client{url: c.APIURL, key: c.APIKey},
+		trigger: trigger,
+		tasks:   make(map[string]tracked),
+	}
+}
+
+// SetTriggerKeyword changes the keyword that starts a task.
+func (r *Receiver) SetTriggerKeyword(keyword string) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	r.trigger = keyword
+}
+
+func (r *Receiver) triggerKeyword() string {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	return r.trigger
+}
+
+// event is the part of a Linear webhook delivery the receiver uses.
+type event struct {
+	Action string `json:"action"`
+	Type   string `json:"type"`
+	Actor  struct {
+		ID   string `json:"id"`
+		Name string `json:"name"`
+	} `json:"actor"`
+	Data struct {
+		ID      string `json:"id"`
+		Body    string `json:"body"`
+		IssueID string `json:"issueId"`
+		UserID  string `json:"userId"`
+		User    struct {
+			Name string `json:"name"`
+		} `json:"user"`
+	} `json:"data"`
+}
+
+// Handle serves POST /webhook/linear.
+func (r *Receiver) Handle(w http.ResponseWriter, req *http.Request) {
+	payload, err := io.ReadAll(http.MaxBytesReader(w, req.Body, 1<<20))
+	if err != nil {
+		http.Error(w, "Error reading payload", http.StatusBadRequest)
+		return
+	}
+	if !hmacsig.Valid(payload, req.Header.Get(SignatureHeader), r.config.WebhookSecret) {
+		slog.WarnContext(req.Context(), "Linear signature verification failed", logging.KeyPhase, "linear")
+		http.Error(w, "Invalid signature", http.StatusUnauthorized)
+		return
+	}
+	var ev event
+	if err := json.Unmarshal(payload, &ev); err != nil {
+		http.Error(w, "invalid JSON body", http.StatusBadRequest)
+		return
+	}
+	if ev.Type != "Comment" || ev.Action != "create" || ev.Data.IssueID == "" {
+		integrations.WriteText(w, http.StatusOK, "Event ignored")
+		return
+	}
+	body := strings.TrimSpace(ev.Data.Body)
+	trigger := r.triggerKeyword()
+	_, prompt, found := strings.Cut(body, trigger)
+	if strings.HasPrefix(body, Marker) || !found {
+		integrations.WriteText(w, http.StatusOK, "No trigger found")
+		return
+	}
+	issueID := ev.Data.IssueID
+	prompt = strings.TrimSpace(prompt)
+	if prompt == "" {
+		r.comment(issueID, fmt.Sprintf("%s Say what to do after `%s`.", Marker, trigger))
+		integrations.WriteText(w, http.StatusOK, "Empty prompt")
+		return
+	}
+	issue, err := r.client.issue(req.Context(), issueID)
+	if err != nil {
+		slog.WarnContext(req.Context(), "Reading Linear issue failed", logging.KeyPhase, "linear", "issue", issueID, "err", err)
+		http.Error(w, "Failed to read the Linear issue", http.StatusBadGateway)
+		return
+	}
+	repo, number, isPR := r.link(issue)
+	if repo == "" {
+		r.comment(issueID, fmt.Sprintf("%s This issue is not linked to a GitHub repository. Add a `%sowner/repo` label and comment again.", Marker, labelPrefix))
+		integrations.WriteText(w, http.StatusOK, "No linked repository")
+		return
+	}
+	if team := strings.ToUpper(issue.Team.Key); !integrations.Allows(r.config.Teams[team], repo) {
+		r.comment(issueID, fmt.Sprintf("%s Team %s may not work in %s. Ask an operator to add it to `LINEAR_TEAMS`.", Marker, team, repo))
+		integrations.WriteText(w, http.StatusOK, "Repository not allowed")
+		return
+	}
+	userID := ev.Data.UserID
+	if userID == "" {
+		userID = ev.Actor.ID
+	}
+	login, ok := r.config.Users[userID]
+	if !ok {
+		r.comment(issueID, fmt.Sprintf("%s Your Linear account is not linked to a GitHub user. Ask an operator to add it to `LINEAR_USERS`.", Marker))
+		integrations.WriteText(w, http.StatusOK, "Unknown user")
+		return
+	}
+
+	who := ev.Actor.Name
+	if who == "" {
+		who = ev.Data.User.Name
+	}
+	if who == "" {
+		who = "a Linear user"
+	}
+	taskReq := TaskRequest{
+		Repo:    repo,
+		Number:  number,
+		IsPR:    isPR,
+		Title:   fmt.Sprintf("[%s] %s", issue.Identifier, strings.TrimSpace(issue.Title)),
+		Body:    issueBody(issue),
+		Prompt:  prompt,
+		Actor:   login,
+		Summary: fmt.Sprintf("**Linear:** %s, asked by %s", issue.Identifier, who),
+	}
+	if ev.Data.ID != "" {
+		taskReq.IdempotencyKey = "linear:" + ev.Data.ID
+	}
+	taskID, number, err := r.launch(req.Context(), taskReq)
+	if errors.Is(err, ErrDuplicate) {
+		integrations.WriteText(w, http.StatusOK, "Duplicate comment ignored")
+		return
+	}
+	t := tracked{issueID: issueID, repo: repo, number: number, isPR: isPR}
+	if err != nil {
+		slog.WarnContext(req.Context(), "Linear task not started", logging.KeyPhase, "linear", "issue", issue.Identifier, "err", err)
+		if number > 0 {
+			r.attach(t, "Not started")
+		}
+		r.comment(issueID, fmt.Sprintf("%s The task could not be started: %v", Marker, err))
+		integrations.WriteText(w, http.StatusOK, "Task not started")
+		return
+	}
+	r.mu.Lock()
+	r.tasks[taskID] = t
+	r.mu.Unlock()
+	slog.InfoContext(req.Context(), "Linear task queued", logging.KeyTaskID, taskID, logging.KeyRepo, repo, logging.KeyPhase, "linear", "issue", issue.Identifier, "number", number)
+	r.attach(t, "Queued")
+	r.comment(issueID, fmt.Sprintf("%s Task `%s` queued on %s.", Marker, taskID, t.url()))
+	integrations.WriteText(w, http.StatusAccepted, "Task queued")
+}
+
+// link returns the repository and the issue or pull request (0: none yet)
+// the Linear issue is linked to.
+func (r *Receiver) link(issue *issue) (string, int, bool) {
+	for _, label := range issue.labels() {
+		m := linkPattern.FindStringSubmatch(strings.TrimPrefix(label, labelPrefix))
+		if !strings.HasPrefix(label, labelPrefix) || m == nil {
+			continue
+		}
+		number, _ := strconv.Atoi(m[2])
+		return m[1], number, false
+	}
+	for _, url := range issue.attachmentURLs() {
+		if m := githubURLPattern.FindStringSubmatch(url); m != nil && m[0] == url {
+			number, _ := strconv.Atoi(m[3])
+			return m[1], number, m[2] == "pull"
+		}
+	}
+	if repos := r.config.Teams[strings.ToUpper(issue.Team.Key)]; len(repos) > 0 {
+		return repos[0], 0, false
+	}
+	return "", 0, false
+}
+
+// issueBody is the body of the GitHub issue opened for a Linear issue.
+func issueBody(issue *issue) string {
+	intro := fmt.Sprintf("Opened for Linear issue [%s](%s). Progress is reported there and below.", issue.Identifier, issue.URL)
+	return integrations.IssueBody(intro, "Description", issue.Description)
+}
+
+// Name implements notify.Notifier.
+func (r *Receiver) Name() string { return "linear" }
+
+// Notify reports the progress of a task the receiver started on its Linear
+// issue: the status of the GitHub attachment, a comment when the task ends
+// or fails, and the pull requests its summary links to. It implements
+// notify.Notifier; register it with notify.Manager.Observe.
+func (r *Receiver) Notify(ctx context.Context, ev notify.Event) error {
+	r.mu.Lock()
+	t, ok := r.tasks[ev.TaskID]
+	if ok && (ev.Type == notify.EventCompleted || ev.Type == notify.EventDeadLettered) {
+		delete(r.tasks, ev.TaskID)
+	}
+	r.mu.Unlock()
+	if !ok {
+		return nil
+	}
+	var status, msg string
+	switch ev.Type {
+	case notify.EventCompleted:
+		status = "Completed"
+		msg = fmt.Sprintf("%s Task `%s` completed on %s.", Marker, ev.TaskID, t.url())
+		if s := strings.TrimSpace(ev.Summary); s != "" {
+			if len(s) > maxSummaryLen {
+				s = strings.ToValidUTF8(s[:maxSummaryLen], "") + "\n\n[... truncated ...]"
+			}
+			msg += "\n\n" + s
+		}
+	case notify.EventFailed:
+		status = "Failed, retrying if attempts remain"
+		msg = fmt.Sprintf("%s Task `%s` failed: %s. It is retried if attempts remain.", Marker, ev.TaskID, ev.Error)
+	case notify.EventDeadLettered:
+		status = "Failed"
+		msg = fmt.Sprintf("%s Task `%s` gave up: %s.", Marker, ev.TaskID, ev.Error)
+	default:
+		return nil
+	}
+	var errs []error
+	errs = append(errs, r.client.attach(ctx, t.issueID, t.title(), status, t.url()))
+	if ev.Type == notify.EventCompleted {
+		for _, pr := range pullRequests(ev.Summary, t) {
+			errs = append(errs, r.client.attach(ctx, t.issueID, fmt.Sprintf("GitHub %s#%s", pr[1], pr[3]), "Pull request", pr[0]))
+		}
+	}
+	errs = append(errs, r.client.comment(ctx, t.issueID, msg))
+	return errors.Join(errs...)
+}
+
+// pullRequests lists the pull requests named in summary other than the one
+// t works on, as githubURLPattern matches.
+func pullRequests(summary string, t tracked) [][]string {
+	var prs [][]string
+	seen := map[string]bool{t.url(): true}
+	for _, m := range githubURLPattern.FindAllStringSubmatch(summary, -1) {
+		if m[2] == "pull" && !seen[m[0]] {
+			seen[m[0]] = true
+			prs = append(prs, m)
+		}
+	}
+	return prs
+}
+
+// attach shows status on the GitHub attachment of t, logging a failure.
+func (r *Receiver) attach(t tracked, status string) {
+	ctx, cancel := context.WithTimeout(context.Background(), requestTimeout)
+	defer cancel()
+	if err := r.client.attach(ctx, t.issueID, t.title(), status, t.url()); err != nil {
+		slog.Warn("Attaching task to Linear issue failed", logging.KeyPhase, "linear", "issue", t.issueID, "url", t.url(), "err", err)
+	}
+}
+
+// comment posts msg on the Linear issue issueID, logging a failure.
+func (r *Receiver) comment(issueID, msg string) {
+	ctx, cancel := context.WithTimeout(context.Background(), requestTimeout)
+	defer cancel()
+	if err := r.client.comment(ctx, issueID, msg); err != nil {
+		slog.Warn("Commenting on Linear issue failed", logging.KeyPhase, "linear", "issue", issueID, "err", err)
+	}
+}
+
+func (t tracked) url() string {
+	kind := "issues"
+	if t.isPR {
+		kind = "pull"
+	}
+	return fmt.Sprintf("https://github.com/%s/%s/%d", t.repo, kind, t.number)
+}
+
+func (t tracked) title() string {
+	return fmt.Sprintf("GitHub %s#%d", t.repo, t.number)
+}
@@ -0,0 +1,233 @@
+package linear
+
+import (
+	"bytes"
+	"context"
+	"crypto/hmac"
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"sync"
+	"testing"
+
+	"github.com/cexll/swe/internal/notify"
+)
+
+// fakeLinear answers issue queries with issue and records the comments and
+// attachments posted to it.
+type fakeLinear struct {
+	mu          sync.Mutex
+	issue       map[string]any
+	comments    []string
+	attachments []map[string]any
+}
+
+func (f *fakeLinear) serve(t *testing.T) *httptest.Server {
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if got := r.Header.Get("Authorization"); got != "lin_api_key" {
+			t.Errorf("Authorization = %q", got)
+		}
+		var req struct {
+			Query     string         `json:"query"`
+			Variables map[string]any `json:"variables"`
+		}
+		if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+			t.Errorf("decode: %v", err)
+		}
+		f.mu.Lock()
+		defer f.mu.Unlock()
+		switch {
+		case strings.Contains(req.Query, "issue(id"):
+			_ = json.NewEncoder(w).Encode(map[string]any{"data": map[string]any{"issue": f.issue}})
+			return
+		case strings.Contains(req.Query, "commentCreate"):
+			f.comments = append(f.comments, req.Variables["body"].(string))
+		case strings.Contains(req.Query, "attachmentCreate"):
+			f.attachments = append(f.attachments, req.Variables)
+		default:
+			t.Errorf("unexpected query %s", req.Query)
+		}
+		_, _ = w.Write([]byte(`{"data":{"x":{"success":true}}}`))
+	}))
+	t.Cleanup(srv.Close)
+	return srv
+}
+
+func (f *fakeLinear) posted() ([]string, []map[string]any) {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	return append([]string(nil), f.comments...), append([]map[string]any(nil), f.attachments...)
+}
+
+func linearIssue(labels []string, attachments ...string) map[string]any {
+	var labelNodes, attachmentNodes []map[string]any
+	for _, l := range labels {
+		labelNodes = append(labelNodes, map[string]any{"name": l})
+	}
+	for _, a := range attachments {
+		attachmentNodes = append(attachmentNodes, map[string]any{"url": a})
+	}
+	return map[string]any{
+		"identifier": "ENG-42", "title": "Login is slow", "description": "p95 is 3s",
+		"url": "https://linear.app/acme/issue/ENG-42", "team": map[string]any{"key": "ENG"},
+		"labels": map[string]any{"nodes": labelNodes}, "attachments": map[string]any{"nodes": attachmentNodes},
+	}
+}
+
+func delivery(secret, comment string) *http.Request {
+	body, _ := json.Marshal(map[string]any{
+		"action": "create", "type": "Comment", "actor": map[string]any{"id": "user-sam", "name": "Sam"},
+		"data": map[string]any{"id": "c-1", "body": comment, "issueId": "issue-uuid", "userId": "user-sam"},
+	})
+	req := httptest.NewRequest(http.MethodPost, "/webhook/linear", bytes.NewReader(body))
+	mac := hmac.New(sha256.New, []byte(secret))
+	mac.Write(body)
+	req.Header.Set(SignatureHeader, hex.EncodeToString(mac.Sum(nil)))
+	return req
+}
+
+func newReceiver(t *testing.T, issue map[string]any, launch Launch) (*Receiver, *fakeLinear) {
+	fake := &fakeLinear{issue: issue}
+	srv := fake.serve(t)
+	r := New(Config{
+		APIKey:        "lin_api_key",
+		WebhookSecret: "linear-secret",
+		Teams:         map[string][]string{"ENG": {"acme/api", "acme/web"}},
+		Users:         map[string]string{"user-sam": "sam"},
+		APIURL:        srv.URL,
+	}, "/code", launch)
+	return r, fake
+}
+
+func TestParseTeams(t *testing.T) {
+	got, err := ParseTeams([]string{"eng = acme/api", "ENG=acme/web"})
+	if err != nil || strings.Join(got["ENG"], ",") != "acme/api,acme/web" {
+		t.Fatalf("ParseTeams = %v, %v", got, err)
+	}
+	for _, bad := range []string{"ENG", "=acme/api", "ENG=api", "ENG=acme/api#1"} {
+		if _, err := ParseTeams([]string{bad}); err == nil {
+			t.Errorf("ParseTeams(%q) accepted", bad)
+		}
+	}
+}
+
+func TestHandle_Ignores(t *testing.T) {
+	launched := 0
+	r, fake := newReceiver(t, linearIssue(nil), func(context.Context, TaskRequest) (string, int, error) {
+		launched++
+		return "t1", 1, nil
+	})
+	cases := []struct {
+		name string
+		req  *http.Request
+		want int
+	}{
+		{"bad signature", delivery("other", "/code fix it"), http.StatusUnauthorized},
+		{"no trigger", delivery("linear-secret", "looks good"), http.StatusOK},
+		{"own comment", delivery("linear-secret", Marker+" Say what to do after `/code`."), http.StatusOK},
+	}
+	for _, c := range cases {
+		w := httptest.NewRecorder()
+		r.Handle(w, c.req)
+		if w.Code != c.want {
+			t.Errorf("%s: status = %d, want %d", c.name, w.Code, c.want)
+		}
+	}
+	if comments, _ := fake.posted(); launched != 0 || len(comments) != 0 {
+		t.Fatalf("launched %d tasks, commented %q", launched, comments)
+	}
+
+	// an attachment naming a repository the team may not work in
+	fake.issue = linearIssue(nil, "https://github.com/other/secrets/issues/1")
+	w := httptest.NewRecorder()
+	r.Handle(w, delivery("linear-secret", "/code fix it"))
+	if comments, _ := fake.posted(); launched != 0 || len(comments) != 1 || !strings.Contains(comments[0], "may not work in other/secrets") {
+		t.Fatalf("repository not allowed: launched %d tasks, commented %q", launched, comments)
+	}
+
+	// a commenter not mapped to a GitHub user
+	fake.issue = linearIssue(nil)
+	r.config.Users = nil
+	w = httptest.NewRecorder()
+	r.Handle(w, delivery("linear-secret", "/code fix it"))
+	if comments, _ := fake.posted(); launched != 0 || len(comments) != 2 || !strings.Contains(comments[1], "LINEAR_USERS") {
+		t.Fatalf("unknown user: launched %d tasks, commented %q", launched, comments)
+	}
+
+	r.config.Teams = nil
+	w = httptest.NewRecorder()
+	r.Handle(w, delivery("linear-secret", "/code fix it"))
+	if comments, _ := fake.posted(); launched != 0 || len(comments) != 3 || !strings.Contains(comments[2], "not linked") {
+		t.Fatalf("unlinked issue: launched %d tasks, commented %q", launched, comments)
+	}
+}
+
+func TestHandle_NewIssueAndStatusSync(t *testing.T) {
+	var got TaskRequest
+	r, fake := newReceiver(t, linearIssue([]string{"bug"}), func(_ context.Context, req TaskRequest) (string, int, error) {
+		got = req
+		return "task-1", 8, nil
+	})
+	w := httptest.NewRecorder()
+	r.Handle(w, delivery("linear-secret", "/code speed up the login query"))
+	if w.Code != http.StatusAccepted {
+		t.Fatalf("status = %d: %s", w.Code, w.Body.String())
+	}
+	if got.Repo != "acme/api" || got.Number != 0 || got.Prompt != "speed up the login query" || got.Actor != "sam" ||
+		got.Title != "[ENG-42] Login is slow" || got.IdempotencyKey != "linear:c-1" || !strings.Contains(got.Summary, "Sam") ||
+		!strings.Contains(got.Body, "(https://linear.app/acme/issue/ENG-42)") || !strings.Contains(got.Body, "> p95 is 3s") {
+		t.Fatalf("task request = %+v", got)
+	}
+	comments, attachments := fake.posted()
+	if len(attachments) != 1 || attachments[0]["url"] != "https://github.com/acme/api/issues/8" || attachments[0]["subtitle"] != "Queued" {
+		t.Fatalf("attachments = %v", attachments)
+	}
+	if len(comments) != 1 || !strings.Contains(comments[0], "`task-1` queued") {
+		t.Fatalf("comments = %q", comments)
+	}
+
+	summary := "Opened https://github.com/acme/api/pull/9 and https://github.com/acme/api/pull/9 again"
+	for _, ev := range []notify.Event{
+		{Type: notify.EventQueued, TaskID: "task-1"},
+		{Type: notify.EventCompleted, TaskID: "task-1", Summary: summary},
+		{Type: notify.EventFailed, TaskID: "task-1", Error: "late"},
+	} {
+		if err := r.Notify(context.Background(), ev); err != nil {
+			t.Fatalf("Notify(%s): %v", ev.Type, err)
+		}
+	}
+	comments, attachments = fake.posted()
+	if len(attachments) != 3 || attachments[1]["subtitle"] != "Completed" ||
+		attachments[2]["url"] != "https://github.com/acme/api/pull/9" || attachments[2]["title"] != "GitHub acme/api#9" {
+		t.Fatalf("attachments = %v", attachments)
+	}
+	if len(comments) != 2 || !strings.Contains(comments[1], "completed") || !strings.Contains(comments[1], summary) {
+		t.Fatalf("comments = %q", comments)
+	}
+}
+
+func TestLink(t *testing.T) {
+	r := New(Config{Teams: map[string][]string{"ENG": {"acme/api"}}}, "/code", nil)
+	cases := []struct {
+		issue  map[string]any
+		repo   string
+		number int
+		isPR   bool
+	}{
+		{linearIssue([]string{"github:acme/web#3"}, "https://github.com/acme/api/pull/9"), "acme/web", 3, false},
+		{linearIssue(nil, "https://example.com/doc", "https://github.com/acme/api/pull/9"), "acme/api", 9, true},
+		{linearIssue(nil, "https://github.com/acme/api/pull/9/files"), "acme/api", 0, false},
+		{linearIssue([]string{"github:bad"}), "acme/api", 0, false},
+	}
+	for i, c := range cases {
+		data, _ := json.Marshal(c.issue)
+		var is issue
+		_ = json.Unmarshal(data, &is)
+		if repo, number, isPR := r.link(&is); repo != c.repo || number != c.number || isPR != c.isPR {
+			t.Errorf("case %d: link = %s, %d, %v", i, repo, number, isPR)
+		}
+	}
+}
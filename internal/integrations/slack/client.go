@@ -0,0 +1,86 @@
+package slack
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"fmt"
+	"io"
+	"net/http"
+	"time"
+)
+
+// defaultAPIURL is the base of Slack's Web API.
+const defaultAPIURL = "https://slack.com/api"
+
+// requestTimeout bounds one call to Slack.
+const requestTimeout = 15 * time.Second
+
+// client calls the Slack Web API with a bot token.
+type client struct {
+	baseURL string
+	token   string
+	http    *http.Client
+}
+
+// postMessage posts text in channel, in the thread of threadTS unless it
+// is empty, and returns the message's timestamp.
+func (c *client) postMessage(ctx context.Context, channel, threadTS, text string) (string, error) {
+	body := map[string]string{"channel": channel, "text": text}
+	if threadTS != "" {
+		body["thread_ts"] = threadTS
+	}
+	var resp struct {
+		OK    bool   `json:"ok"`
+		Error string `json:"error"`
+		TS    string `json:"ts"`
+	}
+	if err := c.post(ctx, c.baseURL+"/chat.postMessage", "Bearer "+c.token, body, &resp); err != nil {
+		return "", err
+	}
+	if !resp.OK {
+		return "", fmt.Errorf("chat.postMessage: %s", resp.Error)
+	}
+	return resp.TS, nil
+}
+
+// respond posts text to the response_url of a slash command, for its user
+// only.
+func (c *client) respond(ctx context.Context, responseURL, text string) error {
+	if responseURL == "" {
+		return fmt.Errorf("no response_url")
+	}
+	return c.post(ctx, responseURL, "", map[string]string{"response_type": "ephemeral", "text": text}, nil)
+}
+
+func (c *client) post(ctx context.Context, url, auth string, body, out any) error {
+	data, err := json.Marshal(body)
+	if err != nil {
+		return fmt.Errorf("marshal request body: %w", err)
+	}
+	req, err := http.NewRequestWithContext(ctx, http.MethodPost, url, bytes.NewReader(data))
+	if err != nil {
+		return fmt.Errorf("create request: %w", err)
+	}
+	if auth != "" {
+		req.Header.Set("Authorization", auth)
+	}
+	req.Header.Set("Content-Type", "application/json; charset=utf-8")
+	hc := c.http
+	if hc == nil {
+		hc = &http.Client{Timeout: requestTimeout}
+	}
+	resp, err := hc.Do(req)
+	if err != nil {
+		return fmt.Errorf("slack API: %w", err)
+	}
+	defer resp.Body.Close()
+	if resp.StatusCode/100 != 2 {
+		msg, _ := io.ReadAll(io.LimitReader(resp.Body, 512))
+		return fmt.Errorf("slack API: status %d: %s", resp.StatusCode, bytes.TrimSpace(msg))
+	}
+	if out == nil {
+		return nil
+	}
+	return json.NewDecoder(resp.Body).Decode(out)
+}
@@ -0,0 +1,312 @@
+// Package slack launches and follows tasks from a Slack slash command.
+//
+//	/swe code owner/repo#123 fix the flaky test
+//	/swe code owner/repo add a health check endpoint
+//	/swe status <task-id>
+//
+// The Slack user must be mapped to a GitHub login, which the task runs as.
+// A launched task is announced in the channel, and its progress is posted
+// in the thread of that message. Without an issue number the task works
+// on a new GitHub issue.
+package slack
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"io"
+	"log/slog"
+	"net/http"
+	"net/url"
+	"regexp"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/cexll/swe/internal/hmacsig"
+	"github.com/cexll/swe/internal/integrations"
+	"github.com/cexll/swe/internal/logging"
+	"github.com/cexll/swe/internal/notify"
+)
+
+// Headers of a signed Slack request.
+const (
+	SignatureHeader = "X-Slack-Signature"
+	TimestampHeader = "X-Slack-Request-Timestamp"
+)
+
+// maxClockSkew is how old a signed request may be; older ones are replays.
+const maxClockSkew = 5 * time.Minute
+
+// launchTimeout bounds launching a task after the command was answered.
+const launchTimeout = 2 * time.Minute
+
+// maxSummaryLen bounds the task summary posted in a thread.
+const maxSummaryLen = 3000
+
+const usage = "Usage:\n" +
+	"• `/swe code owner/repo#123 <instruction>` runs a task on an issue or pull request\n" +
+	"• `/swe code owner/repo <instruction>` runs a task on a new issue\n" +
+	"• `/swe status <task-id>` shows a task's status"
+
+// targetPattern matches owner/repo or owner/repo#123.
+var targetPattern = regexp.MustCompile(`^([A-Za-z0-9_.-]+/[A-Za-z0-9_.-]+)(?:#(\d+))?$`)
+
+// Config connects the integration to a Slack app.
+type Config struct {
+	// SigningSecret verifies the slash command requests
+	SigningSecret string
+	// BotToken posts the task threads (chat:write)
+	BotToken string
+	// Users maps Slack user IDs to the GitHub logins tasks run as; users
+	// not listed cannot launch tasks
+	Users map[string]string
+	// APIURL is the Web API base; empty uses Slack's
+	APIURL string
+}
+
+// ParseUsers parses SLACKID=github-login entries.
+func ParseUsers(entries []string) (map[string]string, error) {
+	return integrations.ParseUsers(entries, "SLACKID")
+}
+
+// TaskRequest is a task launched with the slash command.
+type TaskRequest struct {
+	Repo string // owner/name
+	// Number is the GitHub issue or pull request to work on; 0 opens an
+	// issue with Title and Body
+	Number int
+	Title  string
+	Body   string
+	Prompt string
+	// Actor is the GitHub login of the Slack user, checked for permission
+	// on Repo and recorded as the trigger user
+	Actor   string
+	Summary string
+}
+
+// Launch queues req's task. It returns the task ID and the GitHub issue or
+// pull request it works on.
+type Launch func(ctx context.Context, req TaskRequest) (taskID string, number int, err error)
+
+// Status returns the status of the task id, or false when it is unknown.
+type Status func(id string) (status string, ok bool)
+
+// thread is the Slack thread following a task.
+type thread struct {
+	channel string
+	ts      string
+}
+
+// Receiver serves the slash command and posts the progress of the tasks
+// it launched.
+type Receiver struct {
+	config Config
+	launch Launch
+	status Status
+	client *client
+
+	mu      sync.Mutex
+	threads map[string]thread // by task ID
+	wg      sync.WaitGroup    // launches in flight
+}
+
+// New returns a Receiver for the Slack app of c, launching tasks with
+// launch and looking them up with status.
+func New(c Config, launch Launch, status Status) *Receiver {
+	if c.APIURL == "" {
+		c.APIURL = defaultAPIURL
+	}
+	return &Receiver{
+		config:  c,
+		launch:  launch,
+		status:  status,
+		client:  &client{baseURL: strings.TrimRight(c.APIURL, "/"), token: c.BotToken},
+		threads: make(map[string]thread),
+	}
+}
+
+// command is the part of a slash command request the receiver uses.
+type command struct {
+	userID      string
+	channelID   string
+	text        string
+	responseURL string
+}
+
+// Handle serves POST /webhook/slack, the slash command's request URL.
+// Slack waits three seconds for the answer, so tasks are launched after
+// answering.
+func (r *Receiver) Handle(w http.ResponseWriter, req *http.Request) {
+	payload, err := io.ReadAll(http.MaxBytesReader(w, req.Body, 1<<20))
+	if err != nil {
+		http.Error(w, "Error reading payload", http.StatusBadRequest)
+		return
+	}
+	if !validSignature(payload, req.Header.Get(TimestampHeader), req.Header.Get(SignatureHeader), r.config.SigningSecret, time.Now()) {
+		slog.WarnContext(req.Context(), "Slack signature verification failed", logging.KeyPhase, "slack")
+		http.Error(w, "Invalid signature", http.StatusUnauthorized)
+		return
+	}
+	form, err := url.ParseQuery(string(payload))
+	if err != nil {
+		http.Error(w, "invalid form body", http.StatusBadRequest)
+		return
+	}
+	cmd := command{
+		userID:      form.Get("user_id"),
+		channelID:   form.Get("channel_id"),
+		text:        strings.TrimSpace(form.Get("text")),
+		responseURL: form.Get("response_url"),
+	}
+
+	sub, rest, _ := strings.Cut(cmd.text, " ")
+	rest = strings.TrimSpace(rest)
+	if sub != "code" && sub != "status" {
+		reply(w, usage)
+		return
+	}
+	login, ok := r.config.Users[cmd.userID]
+	if !ok {
+		reply(w, "Your Slack account is not linked to a GitHub user. Ask an operator to add it to SLACK_USERS.")
+		return
+	}
+	if sub == "status" {
+		r.replyStatus(w, rest)
+		return
+	}
+
+	target, prompt, _ := strings.Cut(rest, " ")
+	prompt = strings.TrimSpace(prompt)
+	m := targetPattern.FindStringSubmatch(target)
+	if m == nil || prompt == "" {
+		reply(w, usage)
+		return
+	}
+	number, _ := strconv.Atoi(m[2])
+	intro := fmt.Sprintf("Opened from Slack by @%s. Progress is reported in the Slack thread and below.", login)
+	taskReq := TaskRequest{
+		Repo:    m[1],
+		Number:  number,
+		Title:   integrations.IssueTitle(prompt),
+		Body:    integrations.IssueBody(intro, "Task", prompt),
+		Prompt:  prompt,
+		Actor:   login,
+		Summary: fmt.Sprintf("**Slack:** asked by @%s", login),
+	}
+	r.wg.Add(1)
+	go func() {
+		defer r.wg.Done()
+		r.start(cmd, taskReq)
+	}()
+	reply(w, fmt.Sprintf("Starting a task on %s…", target))
+}
+
+// start launches req and announces it in the command's channel.
+func (r *Receiver) start(cmd command, req TaskRequest) {
+	ctx, cancel := context.WithTimeout(context.Background(), launchTimeout)
+	defer cancel()
+	taskID, number, err := r.launch(ctx, req)
+	if err != nil {
+		slog.Warn("Slack task not started", logging.KeyRepo, req.Repo, logging.KeyPhase, "slack", "user", req.Actor, "err", err)
+		r.respond(ctx, cmd.responseURL, fmt.Sprintf("The task could not be started: %v", err))
+		return
+	}
+	link := fmt.Sprintf("<%s|%s#%d>", integrations.IssueURL(req.Repo, number), req.Repo, number)
+	text := fmt.Sprintf(":rocket: <@%s> queued task `%s` on %s:\n> %s", cmd.userID, taskID, link, strings.ReplaceAll(req.Prompt, "\n", "\n> "))
+	ts, err := r.client.postMessage(ctx, cmd.channelID, "", text)
+	if err != nil {
+		// the bot may not be in the channel; the user still learns the ID
+		slog.Warn("Announcing Slack task failed", logging.KeyTaskID, taskID, logging.KeyRepo, req.Repo, logging.KeyPhase, "slack", "channel", cmd.channelID, "err", err)
+		r.respond(ctx, cmd.responseURL, fmt.Sprintf("Task `%s` queued on %s. Invite the app to this channel for status updates.", taskID, link))
+		return
+	}
+	r.mu.Lock()
+	r.threads[taskID] = thread{channel: cmd.channelID, ts: ts}
+	r.mu.Unlock()
+	slog.Info("Slack task queued", logging.KeyTaskID, taskID, logging.KeyRepo, req.Repo, logging.KeyPhase, "slack", "user", req.Actor, "number", number)
+}
+
+// replyStatus answers /swe status.
+func (r *Receiver) replyStatus(w http.ResponseWriter, id string) {
+	if id == "" {
+		reply(w, usage)
+		return
+	}
+	status, ok := "", false
+	if r.status != nil {
+		status, ok = r.status(id)
+	}
+	if !ok {
+		reply(w, fmt.Sprintf("No task `%s`.", id))
+		return
+	}
+	reply(w, fmt.Sprintf("Task `%s` is %s.", id, status))
+}
+
+// Name implements notify.Notifier.
+func (r *Receiver) Name() string { return "slack" }
+
+// Notify posts the progress of a task the receiver launched in its thread.
+// It implements notify.Notifier; register it with notify.Manager.Observe.
+func (r *Receiver) Notify(ctx context.Context, ev notify.Event) error {
+	r.mu.Lock()
+	t, ok := r.threads[ev.TaskID]
+	if ok && (ev.Type == notify.EventCompleted || ev.Type == notify.EventDeadLettered) {
+		delete(r.threads, ev.TaskID)
+	}
+	r.mu.Unlock()
+	if !ok {
+		return nil
+	}
+	var text string
+	switch ev.Type {
+	case notify.EventCompleted:
+		text = ":white_check_mark: Completed."
+		if s := strings.TrimSpace(ev.Summary); s != "" {
+			if len(s) > maxSummaryLen {
+				s = strings.ToValidUTF8(s[:maxSummaryLen], "") + "\n[... truncated ...]"
+			}
+			text += "\n" + s
+		}
+	case notify.EventFailed:
+		text = fmt.Sprintf(":warning: Failed: %s. It is retried if attempts remain.", ev.Error)
+	case notify.EventDeadLettered:
+		text = fmt.Sprintf(":x: Gave up: %s.", ev.Error)
+	default:
+		return nil
+	}
+	_, err := r.client.postMessage(ctx, t.channel, t.ts, text)
+	return err
+}
+
+// respond posts text to the user who ran a command, logging a failure.
+func (r *Receiver) respond(ctx context.Context, responseURL, text string) {
+	if err := r.client.respond(ctx, responseURL, text); err != nil {
+		slog.WarnContext(ctx, "Answering the Slack command failed", logging.KeyPhase, "slack", "err", err)
+	}
+}
+
+// reply answers a command with a message only its user sees.
+func reply(w http.ResponseWriter, text string) {
+	w.Header().Set("Content-Type", "application/json")
+	_ = json.NewEncoder(w).Encode(map[string]string{"response_type": "ephemeral", "text": text})
+}
+
+// validSignature checks Slack's "v0=<hex>" HMAC-SHA256 of
+// "v0:<timestamp>:<body>" keyed with secret, and that the request is recent.
+func validSignature(payload []byte, timestamp, signature, secret string, now time.Time) bool {
+	got, ok := strings.CutPrefix(signature, "v0=")
+	if !ok {
+		return false
+	}
+	sec, err := strconv.ParseInt(timestamp, 10, 64)
+	if err != nil {
+		return false
+	}
+	if skew := now.Sub(time.Unix(sec, 0)); skew > maxClockSkew || skew < -maxClockSkew {
+		return false
+	}
+	return hmacsig.Valid(append([]byte("v0:"+timestamp+":"), payload...), got, secret)
+}
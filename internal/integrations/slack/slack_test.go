@@ -0,0 +1,211 @@
+package slack
+
+import (
+	"context"
+	"crypto/hmac"
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"net/url"
+	"strconv"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/cexll/swe/internal/notify"
+)
+
+// fakeSlack records the messages posted to chat.postMessage and to the
+// response URL.
+type fakeSlack struct {
+	mu        sync.Mutex
+	messages  []map[string]string
+	responses []string
+	postErr   string
+}
+
+func (f *fakeSlack) serve(t *testing.T) *httptest.Server {
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		var body map[string]string
+		_ = json.NewDecoder(r.Body).Decode(&body)
+		f.mu.Lock()
+		defer f.mu.Unlock()
+		switch r.URL.Path {
+		case "/api/chat.postMessage":
+			if got := r.Header.Get("Authorization"); got != "Bearer xoxb-test" {
+				t.Errorf("Authorization = %q", got)
+			}
+			if f.postErr != "" {
+				fmt.Fprintf(w, `{"ok":false,"error":%q}`, f.postErr)
+				return
+			}
+			f.messages = append(f.messages, body)
+			fmt.Fprintf(w, `{"ok":true,"ts":"1700000000.%06d"}`, len(f.messages))
+		case "/respond":
+			f.responses = append(f.responses, body["text"])
+		default:
+			t.Errorf("unexpected %s", r.URL.Path)
+		}
+	}))
+	t.Cleanup(srv.Close)
+	return srv
+}
+
+func (f *fakeSlack) posted() ([]map[string]string, []string) {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	return append([]map[string]string(nil), f.messages...), append([]string(nil), f.responses...)
+}
+
+func newReceiver(t *testing.T, launch Launch) (*Receiver, *fakeSlack, string) {
+	fake := &fakeSlack{}
+	srv := fake.serve(t)
+	r := New(Config{
+		SigningSecret: "signing-secret",
+		BotToken:      "xoxb-test",
+		Users:         map[string]string{"U1": "octocat"},
+		APIURL:        srv.URL + "/api",
+	}, launch, func(id string) (string, bool) { return "running", id == "task-1" })
+	return r, fake, srv.URL + "/respond"
+}
+
+func slashCommand(secret, user, text, responseURL string, at time.Time) *http.Request {
+	body := url.Values{"user_id": {user}, "channel_id": {"C1"}, "command": {"/swe"}, "text": {text}, "response_url": {responseURL}}.Encode()
+	req := httptest.NewRequest(http.MethodPost, "/webhook/slack", strings.NewReader(body))
+	ts := strconv.FormatInt(at.Unix(), 10)
+	mac := hmac.New(sha256.New, []byte(secret))
+	fmt.Fprintf(mac, "v0:%s:%s", ts, body)
+	req.Header.Set(TimestampHeader, ts)
+	req.Header.Set(SignatureHeader, "v0="+hex.EncodeToString(mac.Sum(nil)))
+	return req
+}
+
+func answer(t *testing.T, w *httptest.ResponseRecorder) string {
+	t.Helper()
+	var resp struct{ ResponseType, Text string }
+	if err := json.Unmarshal(w.Body.Bytes(), &resp); err != nil {
+		t.Fatalf("answer %q: %v", w.Body.String(), err)
+	}
+	return resp.Text
+}
+
+func TestParseUsers(t *testing.T) {
+	got, err := ParseUsers([]string{"U1 = octocat"})
+	if err != nil || got["U1"] != "octocat" {
+		t.Fatalf("ParseUsers = %v, %v", got, err)
+	}
+	for _, bad := range []string{"U1", "=octocat", "U1=", "U1=octo/cat"} {
+		if _, err := ParseUsers([]string{bad}); err == nil {
+			t.Errorf("ParseUsers(%q) accepted", bad)
+		}
+	}
+}
+
+func TestHandle_Rejects(t *testing.T) {
+	launched := 0
+	r, _, respond := newReceiver(t, func(context.Context, TaskRequest) (string, int, error) {
+		launched++
+		return "t", 1, nil
+	})
+	now := time.Now()
+	for name, req := range map[string]*http.Request{
+		"bad signature": slashCommand("other", "U1", "code o/r#1 x", respond, now),
+		"replayed":      slashCommand("signing-secret", "U1", "code o/r#1 x", respond, now.Add(-10*time.Minute)),
+	} {
+		w := httptest.NewRecorder()
+		r.Handle(w, req)
+		if w.Code != http.StatusUnauthorized {
+			t.Errorf("%s: status = %d", name, w.Code)
+		}
+	}
+	answers := map[string]string{
+		"":                   "Usage",
+		"code o/r#1":         "Usage",
+		"code not-a-repo go": "Usage",
+		"status":             "Usage",
+		"status task-1":      "Task `task-1` is running.",
+		"status task-2":      "No task `task-2`.",
+	}
+	for text, want := range answers {
+		w := httptest.NewRecorder()
+		r.Handle(w, slashCommand("signing-secret", "U1", text, respond, now))
+		if got := answer(t, w); !strings.Contains(got, want) {
+			t.Errorf("%q: answer = %q, want %q", text, got, want)
+		}
+	}
+	w := httptest.NewRecorder()
+	r.Handle(w, slashCommand("signing-secret", "U2", "code o/r#1 fix it", respond, now))
+	if got := answer(t, w); !strings.Contains(got, "not linked to a GitHub user") {
+		t.Errorf("unmapped user: answer = %q", got)
+	}
+	r.wg.Wait()
+	if launched != 0 {
+		t.Fatalf("launched %d tasks", launched)
+	}
+}
+
+func TestHandle_LaunchesAndFollowsTask(t *testing.T) {
+	var got TaskRequest
+	r, fake, respond := newReceiver(t, func(_ context.Context, req TaskRequest) (string, int, error) {
+		got = req
+		return "task-1", 31, nil
+	})
+	w := httptest.NewRecorder()
+	r.Handle(w, slashCommand("signing-secret", "U1", "code acme/api add a health check\nreturning 200", respond, time.Now()))
+	if text := answer(t, w); !strings.Contains(text, "Starting a task on acme/api") {
+		t.Fatalf("answer = %q", text)
+	}
+	r.wg.Wait()
+	if got.Repo != "acme/api" || got.Number != 0 || got.Actor != "octocat" || got.Prompt != "add a health check\nreturning 200" ||
+		got.Title != "add a health check" || !strings.Contains(got.Body, "> returning 200") {
+		t.Fatalf("task request = %+v", got)
+	}
+	messages, _ := fake.posted()
+	if len(messages) != 1 || messages[0]["channel"] != "C1" || messages[0]["thread_ts"] != "" ||
+		!strings.Contains(messages[0]["text"], "<@U1> queued task `task-1` on <https://github.com/acme/api/issues/31|acme/api#31>") {
+		t.Fatalf("messages = %v", messages)
+	}
+
+	for _, ev := range []notify.Event{
+		{Type: notify.EventFailed, TaskID: "task-1", Error: "clone failed"},
+		{Type: notify.EventCompleted, TaskID: "task-1", Summary: "Added /healthz"},
+		{Type: notify.EventCompleted, TaskID: "task-1"},
+	} {
+		if err := r.Notify(context.Background(), ev); err != nil {
+			t.Fatalf("Notify(%s): %v", ev.Type, err)
+		}
+	}
+	messages, _ = fake.posted()
+	if len(messages) != 3 || messages[1]["thread_ts"] != "1700000000.000001" || !strings.Contains(messages[1]["text"], "clone failed") ||
+		messages[2]["thread_ts"] != "1700000000.000001" || !strings.Contains(messages[2]["text"], "Completed.\nAdded /healthz") {
+		t.Fatalf("messages = %v", messages)
+	}
+}
+
+func TestHandle_ReportsToTheUser(t *testing.T) {
+	r, fake, respond := newReceiver(t, func(context.Context, TaskRequest) (string, int, error) {
+		return "", 5, errors.New("octocat may not trigger tasks in acme/api")
+	})
+	r.Handle(httptest.NewRecorder(), slashCommand("signing-secret", "U1", "code acme/api#5 fix it", respond, time.Now()))
+	r.wg.Wait()
+
+	// the bot is not in the channel
+	r.launch = func(context.Context, TaskRequest) (string, int, error) { return "task-9", 5, nil }
+	fake.postErr = "not_in_channel"
+	r.Handle(httptest.NewRecorder(), slashCommand("signing-secret", "U1", "code acme/api#5 fix it", respond, time.Now()))
+	r.wg.Wait()
+
+	_, responses := fake.posted()
+	if len(responses) != 2 || !strings.Contains(responses[0], "could not be started: octocat may not") ||
+		!strings.Contains(responses[1], "Task `task-9` queued") {
+		t.Fatalf("responses = %q", responses)
+	}
+	if err := r.Notify(context.Background(), notify.Event{Type: notify.EventCompleted, TaskID: "task-9"}); err != nil {
+		t.Fatalf("Notify for an unannounced task: %v", err)
+	}
+}
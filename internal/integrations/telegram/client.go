@@ -0,0 +1,94 @@
+package telegram
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"net/http"
+	"net/url"
+	"time"
+)
+
+// defaultAPIURL is the base of the Telegram Bot API.
+const defaultAPIURL = "https://api.telegram.org"
+
+// pollTimeout is how long getUpdates waits for an update.
+const pollTimeout = 25 * time.Second
+
+// PollInterval is how often to run Receiver.Poll; a poll waiting for
+// updates delays the next one.
+const PollInterval = time.Second
+
+// requestTimeout bounds one call to the Bot API other than polling.
+const requestTimeout = 15 * time.Second
+
+// client calls the Bot API; baseURL ends with /bot<token>.
+type client struct {
+	baseURL string
+	http    *http.Client
+}
+
+// sendMessage sends text to chat as plain text.
+func (c *client) sendMessage(ctx context.Context, chat int64, text string) error {
+	return c.call(ctx, "sendMessage", map[string]any{"chat_id": chat, "text": text}, requestTimeout, nil)
+}
+
+// getUpdates long-polls for the updates from offset on.
+func (c *client) getUpdates(ctx context.Context, offset int64) ([]update, error) {
+	var updates []update
+	params := map[string]any{"offset": offset, "timeout": int(pollTimeout / time.Second), "allowed_updates": []string{"message"}}
+	err := c.call(ctx, "getUpdates", params, pollTimeout+requestTimeout, &updates)
+	return updates, err
+}
+
+// call runs a Bot API method, decoding its result into out unless out is
+// nil.
+func (c *client) call(ctx context.Context, method string, params any, timeout time.Duration, out any) error {
+	body, err := json.Marshal(params)
+	if err != nil {
+		return fmt.Errorf("marshal request body: %w", err)
+	}
+	ctx, cancel := context.WithTimeout(ctx, timeout)
+	defer cancel()
+	req, err := http.NewRequestWithContext(ctx, http.MethodPost, c.baseURL+"/"+method, bytes.NewReader(body))
+	if err != nil {
+		return fmt.Errorf("create request: %w", err)
+	}
+	req.Header.Set("Content-Type", "application/json")
+	hc := c.http
+	if hc == nil {
+		hc = http.DefaultClient
+	}
+	resp, err := hc.Do(req)
+	if err != nil {
+		// a url.Error names the URL, which holds the bot token
+		var uerr *url.Error
+		if errors.As(err, &uerr) {
+			err = uerr.Err
+		}
+		return fmt.Errorf("%s: %w", method, err)
+	}
+	defer resp.Body.Close()
+	data, err := io.ReadAll(io.LimitReader(resp.Body, 4<<20))
+	if err != nil {
+		return fmt.Errorf("%s: read response: %w", method, err)
+	}
+	var result struct {
+		OK          bool            `json:"ok"`
+		Description string          `json:"description"`
+		Result      json.RawMessage `json:"result"`
+	}
+	if err := json.Unmarshal(data, &result); err != nil {
+		return fmt.Errorf("%s: status %d", method, resp.StatusCode)
+	}
+	if !result.OK {
+		return fmt.Errorf("%s: %s", method, result.Description)
+	}
+	if out == nil {
+		return nil
+	}
+	return json.Unmarshal(result.Result, out)
+}
@@ -0,0 +1,355 @@
+// Package telegram launches and follows tasks from a Telegram bot, a
+// lighter alternative to Slack for personal deployments.
+//
+//	/code owner/repo#123 fix the flaky test
+//	/code owner/repo add a health check endpoint
+//	/code #123 fix the flaky test      (with a single configured repository)
+//	/status <task-id>
+//
+// Only allowlisted chats are listened to, and only configured repositories
+// can be worked on. The bot answers in the chat when the task is queued and
+// again when it completes or fails, with a link to the GitHub issue.
+//
+// Updates come from a webhook (POST /webhook/telegram, verified with the
+// secret token given to setWebhook) or, without one, from long polling.
+package telegram
+
+import (
+	"context"
+	"crypto/subtle"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"log/slog"
+	"net/http"
+	"regexp"
+	"strconv"
+	"strings"
+	"sync"
+
+	"github.com/cexll/swe/internal/integrations"
+	"github.com/cexll/swe/internal/logging"
+	"github.com/cexll/swe/internal/notify"
+)
+
+// SecretHeader carries the secret token of a webhook update.
+const SecretHeader = "X-Telegram-Bot-Api-Secret-Token"
+
+// maxSummaryLen bounds the task summary sent to a chat; Telegram messages
+// hold 4096 characters.
+const maxSummaryLen = 3000
+
+const usage = "Usage:\n" +
+	"/code owner/repo#123 <instruction> runs a task on an issue or pull request\n" +
+	"/code owner/repo <instruction> runs a task on a new issue\n" +
+	"/status <task-id> shows a task's status"
+
+// targetPattern matches owner/repo, owner/repo#123 or #123.
+var targetPattern = regexp.MustCompile(`^([A-Za-z0-9_.-]+/[A-Za-z0-9_.-]+)?(?:#(\d+))?$`)
+
+// Config connects the integration to a Telegram bot.
+type Config struct {
+	BotToken string
+	// WebhookSecret verifies webhook updates; empty means long polling
+	WebhookSecret string
+	// Chats are the chat IDs the bot listens to
+	Chats []int64
+	// Repos are the owner/repo the bot may work on; with one, commands may
+	// leave it out
+	Repos []string
+	// APIURL is the Bot API base; empty uses Telegram's
+	APIURL string
+}
+
+// ParseChats parses chat IDs.
+func ParseChats(entries []string) ([]int64, error) {
+	chats := make([]int64, 0, len(entries))
+	for _, entry := range entries {
+		id, err := strconv.ParseInt(strings.TrimSpace(entry), 10, 64)
+		if err != nil {
+			return nil, fmt.Errorf("chat ID %q is not a number", entry)
+		}
+		chats = append(chats, id)
+	}
+	return chats, nil
+}
+
+// CheckRepos reports an error for an entry that is not owner/repo.
+func CheckRepos(repos []string) error {
+	for _, repo := range repos {
+		if m := targetPattern.FindStringSubmatch(repo); m == nil || m[1] == "" || m[2] != "" {
+			return fmt.Errorf("repository %q must be owner/repo", repo)
+		}
+	}
+	return nil
+}
+
+// TaskRequest is a task launched from a chat.
+type TaskRequest struct {
+	Repo string // owner/name
+	// Number is the GitHub issue or pull request to work on; 0 opens an
+	// issue with Title and Body
+	Number int
+	Title  string
+	Body   string
+	Prompt string
+	// Actor is recorded as the trigger user; Summary is the task's prompt
+	// summary, naming the chat
+	Actor   string
+	Summary string
+	// IdempotencyKey identifies the update, so a redelivery runs nothing
+	IdempotencyKey string
+}
+
+// ErrDuplicate is returned by a Launch for an update whose task was already
+// accepted.
+var ErrDuplicate = errors.New("update already handled")
+
+// Launch queues req's task. It returns the task ID and the GitHub issue or
+// pull request it works on.
+type Launch func(ctx context.Context, req TaskRequest) (taskID string, number int, err error)
+
+// Status returns the status of the task id, or false when it is unknown.
+type Status func(id string) (status string, ok bool)
+
+// Receiver handles the bot's updates and tells chats how the tasks they
+// launched end.
+type Receiver struct {
+	config Config
+	launch Launch
+	status Status
+	client *client
+
+	mu     sync.Mutex
+	chats  map[string]int64 // by task ID
+	offset int64            // next update to poll
+}
+
+// New returns a Receiver for the bot of c, launching tasks with launch and
+// looking them up with status.
+func New(c Config, launch Launch, status Status) *Receiver {
+	if c.APIURL == "" {
+		c.APIURL = defaultAPIURL
+	}
+	return &Receiver{
+		config: c,
+		launch: launch,
+		status: status,
+		client: &client{baseURL: strings.TrimRight(c.APIURL, "/") + "/bot" + c.BotToken},
+		chats:  make(map[string]int64),
+	}
+}
+
+// update is the part of a Telegram update the receiver uses.
+type update struct {
+	UpdateID int64 `json:"update_id"`
+	Message  *struct {
+		MessageID int64  `json:"message_id"`
+		Text      string `json:"text"`
+		Chat      struct {
+			ID int64 `json:"id"`
+		} `json:"chat"`
+		From struct {
+			Username  string `json:"username"`
+			FirstName string `json:"first_name"`
+		} `json:"from"`
+	} `json:"message"`
+}
+
+// Handle serves POST /webhook/telegram.
+func (r *Receiver) Handle(w http.ResponseWriter, req *http.Request) {
+	got := req.Header.Get(SecretHeader)
+	if r.config.WebhookSecret == "" || subtle.ConstantTimeCompare([]byte(got), []byte(r.config.WebhookSecret)) != 1 {
+		slog.WarnContext(req.Context(), "Telegram secret token verification failed", logging.KeyPhase, "telegram")
+		http.Error(w, "Invalid secret token", http.StatusUnauthorized)
+		return
+	}
+	payload, err := io.ReadAll(http.MaxBytesReader(w, req.Body, 1<<20))
+	if err != nil {
+		http.Error(w, "Error reading payload", http.StatusBadRequest)
+		return
+	}
+	var u update
+	if err := json.Unmarshal(payload, &u); err != nil {
+		http.Error(w, "invalid JSON body", http.StatusBadRequest)
+		return
+	}
+	r.handleUpdate(req.Context(), u)
+	w.WriteHeader(http.StatusOK)
+}
+
+// Poll fetches the pending updates, waiting for some up to the long
+// polling timeout, and handles them. Run it in a loop on one replica.
+func (r *Receiver) Poll(ctx context.Context) {
+	r.mu.Lock()
+	offset := r.offset
+	r.mu.Unlock()
+	updates, err := r.client.getUpdates(ctx, offset)
+	if err != nil {
+		if ctx.Err() == nil {
+			slog.WarnContext(ctx, "Polling Telegram for updates failed", logging.KeyPhase, "telegram", "err", err)
+		}
+		return
+	}
+	for _, u := range updates {
+		r.mu.Lock()
+		r.offset = max(r.offset, u.UpdateID+1)
+		r.mu.Unlock()
+		r.handleUpdate(ctx, u)
+	}
+}
+
+// handleUpdate answers a command sent in an allowlisted chat; anything
+// else is ignored.
+func (r *Receiver) handleUpdate(ctx context.Context, u update) {
+	msg := u.Message
+	if msg == nil || !r.allowed(msg.Chat.ID) {
+		if msg != nil {
+			slog.InfoContext(ctx, "Ignoring a message from a chat that is not allowlisted", logging.KeyPhase, "telegram", "chat", msg.Chat.ID)
+		}
+		return
+	}
+	chat := msg.Chat.ID
+	cmd, rest, _ := strings.Cut(strings.TrimSpace(msg.Text), " ")
+	cmd, _, _ = strings.Cut(cmd, "@") // /code@my_bot in groups
+	rest = strings.TrimSpace(rest)
+	switch cmd {
+	case "/code":
+	case "/status":
+		r.reply(ctx, chat, r.statusText(rest))
+		return
+	case "/start", "/help":
+		r.reply(ctx, chat, usage)
+		return
+	default:
+		return
+	}
+
+	repo, number, prompt, ok := r.parseTarget(rest)
+	if !ok {
+		r.reply(ctx, chat, usage)
+		return
+	}
+	who := msg.From.Username
+	if who == "" {
+		who = msg.From.FirstName
+	}
+	req := TaskRequest{
+		Repo:           repo,
+		Number:         number,
+		Title:          integrations.IssueTitle(prompt),
+		Body:           integrations.IssueBody("Opened from Telegram. Progress is reported in the chat and below.", "Task", prompt),
+		Prompt:         prompt,
+		Actor:          "telegram",
+		Summary:        fmt.Sprintf("**Telegram:** asked by %s", who),
+		IdempotencyKey: fmt.Sprintf("telegram:%d", u.UpdateID),
+	}
+	taskID, number, err := r.launch(ctx, req)
+	if errors.Is(err, ErrDuplicate) {
+		return
+	}
+	if err != nil {
+		slog.WarnContext(ctx, "Telegram task not started", logging.KeyRepo, repo, logging.KeyPhase, "telegram", "err", err)
+		r.reply(ctx, chat, fmt.Sprintf("The task could not be started: %v", err))
+		return
+	}
+	r.mu.Lock()
+	r.chats[taskID] = chat
+	r.mu.Unlock()
+	slog.InfoContext(ctx, "Telegram task queued", logging.KeyTaskID, taskID, logging.KeyRepo, repo, logging.KeyPhase, "telegram", "chat", chat, "number", number)
+	r.reply(ctx, chat, fmt.Sprintf("Task %s queued on %s", taskID, integrations.IssueURL(repo, number)))
+}
+
+// parseTarget splits "[owner/repo][#123] <instruction>". The repository
+// may be left out when a single one is configured; it must be configured.
+func (r *Receiver) parseTarget(s string) (repo string, number int, prompt string, ok bool) {
+	target, prompt, _ := strings.Cut(s, " ")
+	m := targetPattern.FindStringSubmatch(target)
+	if m == nil || target == "" {
+		// no target: the whole text is the instruction
+		m, prompt = []string{"", "", ""}, s
+	}
+	prompt = strings.TrimSpace(prompt)
+	repo = m[1]
+	if repo == "" && len(r.config.Repos) == 1 {
+		repo = r.config.Repos[0]
+	}
+	if prompt == "" || !r.configured(repo) {
+		return "", 0, "", false
+	}
+	number, _ = strconv.Atoi(m[2])
+	return repo, number, prompt, true
+}
+
+func (r *Receiver) statusText(id string) string {
+	if id == "" {
+		return usage
+	}
+	if r.status != nil {
+		if status, ok := r.status(id); ok {
+			return fmt.Sprintf("Task %s is %s.", id, status)
+		}
+	}
+	return fmt.Sprintf("No task %s.", id)
+}
+
+func (r *Receiver) allowed(chat int64) bool {
+	for _, id := range r.config.Chats {
+		if id == chat {
+			return true
+		}
+	}
+	return false
+}
+
+func (r *Receiver) configured(repo string) bool {
+	for _, c := range r.config.Repos {
+		if repo != "" && strings.EqualFold(c, repo) {
+			return true
+		}
+	}
+	return false
+}
+
+// Name implements notify.Notifier.
+func (r *Receiver) Name() string { return "telegram" }
+
+// Notify tells the chat that launched a task how it ended. It implements
+// notify.Notifier; register it with notify.Manager.Observe.
+func (r *Receiver) Notify(ctx context.Context, ev notify.Event) error {
+	r.mu.Lock()
+	chat, ok := r.chats[ev.TaskID]
+	if ok && (ev.Type == notify.EventCompleted || ev.Type == notify.EventDeadLettered) {
+		delete(r.chats, ev.TaskID)
+	}
+	r.mu.Unlock()
+	if !ok {
+		return nil
+	}
+	var text string
+	switch ev.Type {
+	case notify.EventCompleted:
+		text = fmt.Sprintf("✅ Task %s completed: %s", ev.TaskID, ev.URL())
+		if s := strings.TrimSpace(ev.Summary); s != "" {
+			if len(s) > maxSummaryLen {
+				s = strings.ToValidUTF8(s[:maxSummaryLen], "") + "\n[... truncated ...]"
+			}
+			text += "\n\n" + s
+		}
+	case notify.EventFailed:
+		text = fmt.Sprintf("⚠️ Task %s failed: %s. It is retried if attempts remain. %s", ev.TaskID, ev.Error, ev.URL())
+	case notify.EventDeadLettered:
+		text = fmt.Sprintf("❌ Task %s gave up: %s. %s", ev.TaskID, ev.Error, ev.URL())
+	default:
+		return nil
+	}
+	return r.client.sendMessage(ctx, chat, strings.TrimSpace(text))
+}
+
+// reply sends text to chat, logging a failure.
+func (r *Receiver) reply(ctx context.Context, chat int64, text string) {
+	if err := r.client.sendMessage(ctx, chat, text); err != nil {
+		slog.WarnContext(ctx, "Answering Telegram chat failed", logging.KeyPhase, "telegram", "chat", chat, "err", err)
+	}
+}
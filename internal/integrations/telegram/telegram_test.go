@@ -0,0 +1,275 @@
+package telegram
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"sync"
+	"testing"
+
+	"github.com/cexll/swe/internal/notify"
+)
+
+// botAPI stands in for the Bot API at the transport: getUpdates hands out
+// one queued batch per call and remembers the offset asked for, and
+// sendMessage records what each chat was told.
+type botAPI struct {
+	mu      sync.Mutex
+	batches []string // getUpdates results, as JSON
+	offsets []int64
+	sent    map[int64][]string
+}
+
+func (b *botAPI) RoundTrip(req *http.Request) (*http.Response, error) {
+	var params struct {
+		ChatID int64  `json:"chat_id"`
+		Text   string `json:"text"`
+		Offset int64  `json:"offset"`
+	}
+	_ = json.NewDecoder(req.Body).Decode(&params)
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	result := `{}`
+	switch req.URL.Path {
+	case "/botTOKEN/getUpdates":
+		b.offsets = append(b.offsets, params.Offset)
+		if len(b.batches) == 0 {
+			result = `[]`
+			break
+		}
+		result, b.batches = b.batches[0], b.batches[1:]
+		if result == "" {
+			return reply(`{"ok":false,"description":"Conflict: terminated by other getUpdates request"}`), nil
+		}
+	case "/botTOKEN/sendMessage":
+		b.sent[params.ChatID] = append(b.sent[params.ChatID], params.Text)
+	default:
+		return reply(`{"ok":false,"description":"Not Found"}`), nil
+	}
+	return reply(`{"ok":true,"result":` + result + `}`), nil
+}
+
+func reply(body string) *http.Response {
+	return &http.Response{StatusCode: http.StatusOK, Body: io.NopCloser(strings.NewReader(body)), Header: http.Header{}}
+}
+
+func (b *botAPI) told(chat int64) []string {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	return append([]string(nil), b.sent[chat]...)
+}
+
+// bot returns a Receiver listening to chats and working on repos, talking
+// to a stub Bot API.
+func bot(chats []int64, repos []string, launch Launch) (*Receiver, *botAPI) {
+	api := &botAPI{sent: make(map[int64][]string)}
+	r := New(Config{BotToken: "TOKEN", WebhookSecret: "hook-secret", Chats: chats, Repos: repos, APIURL: "https://bot.invalid"},
+		launch, func(id string) (string, bool) { return "running", id == "task-1" })
+	r.client.http = &http.Client{Transport: api}
+	return r, api
+}
+
+// text is an update carrying a message from chat.
+func text(updateID, chat int64, body string) update {
+	var u update
+	raw := fmt.Sprintf(`{"update_id":%d,"message":{"message_id":%d,"text":%q,"chat":{"id":%d},"from":{"username":"kim"}}}`, updateID, updateID, body, chat)
+	if err := json.Unmarshal([]byte(raw), &u); err != nil {
+		panic(err)
+	}
+	return u
+}
+
+// launches records the requests it is given and hands out task IDs.
+type launches struct {
+	mu   sync.Mutex
+	reqs []TaskRequest
+}
+
+func (l *launches) launch(_ context.Context, req TaskRequest) (string, int, error) {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+	l.reqs = append(l.reqs, req)
+	if req.Number == 0 {
+		req.Number = 50
+	}
+	return fmt.Sprintf("task-%d", len(l.reqs)), req.Number, nil
+}
+
+func TestHandleUpdate_OnlyAllowlistedChats(t *testing.T) {
+	const group, otherGroup, stranger = -100123, -100999, 77
+	var l launches
+	r, api := bot([]int64{group}, []string{"acme/api"}, l.launch)
+
+	r.handleUpdate(context.Background(), text(1, otherGroup, "/code #7 fix the flaky test"))
+	r.handleUpdate(context.Background(), text(2, stranger, "/status task-1"))
+	if len(l.reqs) != 0 || len(api.told(otherGroup)) != 0 || len(api.told(stranger)) != 0 {
+		t.Fatalf("answered a chat that is not allowlisted: launched %+v, told %q and %q", l.reqs, api.told(otherGroup), api.told(stranger))
+	}
+
+	r.handleUpdate(context.Background(), text(3, group, "/code #7 fix the flaky test"))
+	if len(l.reqs) != 1 || !strings.Contains(l.reqs[0].Summary, "kim") {
+		t.Fatalf("allowlisted group: launched %+v", l.reqs)
+	}
+	if told := api.told(group); len(told) != 1 || told[0] != "Task task-1 queued on https://github.com/acme/api/issues/7" {
+		t.Fatalf("allowlisted group told %q", told)
+	}
+}
+
+func TestHandleUpdate_Commands(t *testing.T) {
+	tests := []struct {
+		text   string
+		repos  []string
+		launch *TaskRequest // nil: nothing launched
+		told   string       // prefix of the answer; "" for none
+	}{
+		{"/code@swe_bot #7 fix the flaky test", []string{"acme/api"},
+			&TaskRequest{Repo: "acme/api", Number: 7, Prompt: "fix the flaky test"}, "Task task-1 queued on https://github.com/acme/api/issues/7"},
+		{"/code@swe_bot acme/web#3 go", []string{"acme/api", "acme/web"},
+			&TaskRequest{Repo: "acme/web", Number: 3, Prompt: "go"}, "Task task-1 queued"},
+		{"/code add a health check\nreturning 200", []string{"acme/api"},
+			&TaskRequest{Repo: "acme/api", Prompt: "add a health check\nreturning 200", Title: "add a health check"}, "Task task-1 queued on https://github.com/acme/api/issues/50"},
+		{"/code #3 go", []string{"acme/api", "acme/web"}, nil, "Usage:"}, // which repository?
+		{"/code other/repo#1 go", []string{"acme/api"}, nil, "Usage:"},   // not configured
+		{"/code@swe_bot", []string{"acme/api"}, nil, "Usage:"},           // no instruction
+		{"/status@swe_bot task-1", []string{"acme/api"}, nil, "Task task-1 is running."},
+		{"/status task-9", []string{"acme/api"}, nil, "No task task-9."},
+		{"/help", []string{"acme/api"}, nil, "Usage:"},
+		{"/codex #7 go", []string{"acme/api"}, nil, ""},
+		{"please /code #7 go", []string{"acme/api"}, nil, ""},
+	}
+	for _, tt := range tests {
+		t.Run(tt.text, func(t *testing.T) {
+			var l launches
+			r, api := bot([]int64{42}, tt.repos, l.launch)
+			r.handleUpdate(context.Background(), text(5, 42, tt.text))
+
+			switch {
+			case tt.launch == nil && len(l.reqs) != 0:
+				t.Fatalf("launched %+v", l.reqs)
+			case tt.launch != nil:
+				if len(l.reqs) != 1 {
+					t.Fatalf("launched %+v", l.reqs)
+				}
+				got, want := l.reqs[0], tt.launch
+				if got.Repo != want.Repo || got.Number != want.Number || got.Prompt != want.Prompt || got.Actor != "telegram" || got.IdempotencyKey != "telegram:5" {
+					t.Fatalf("launched %+v, want %+v", got, want)
+				}
+				if want.Title != "" && (got.Title != want.Title || !strings.Contains(got.Body, "> returning 200")) {
+					t.Fatalf("new issue %q: %q", got.Title, got.Body)
+				}
+			}
+			told := api.told(42)
+			if tt.told == "" {
+				if len(told) != 0 {
+					t.Fatalf("told %q", told)
+				}
+				return
+			}
+			if len(told) != 1 || !strings.HasPrefix(told[0], tt.told) {
+				t.Fatalf("told %q, want %q", told, tt.told)
+			}
+		})
+	}
+}
+
+func TestHandle_RedeliveredUpdate(t *testing.T) {
+	// the launcher is keyed by update, like the one cmd wires in
+	seen := make(map[string]bool)
+	var l launches
+	r, api := bot([]int64{42}, []string{"acme/api"}, func(ctx context.Context, req TaskRequest) (string, int, error) {
+		if seen[req.IdempotencyKey] {
+			return "", 0, ErrDuplicate
+		}
+		seen[req.IdempotencyKey] = true
+		return l.launch(ctx, req)
+	})
+	body, _ := json.Marshal(map[string]any{"update_id": 9, "message": map[string]any{
+		"message_id": 9, "text": "/code #7 go", "chat": map[string]any{"id": 42}, "from": map[string]any{"username": "kim"},
+	}})
+	deliver := func(secret string) int {
+		req := httptest.NewRequest(http.MethodPost, "/webhook/telegram", strings.NewReader(string(body)))
+		req.Header.Set(SecretHeader, secret)
+		w := httptest.NewRecorder()
+		r.Handle(w, req)
+		return w.Code
+	}
+
+	if code := deliver("wrong"); code != http.StatusUnauthorized {
+		t.Fatalf("wrong secret: status = %d", code)
+	}
+	for i := 0; i < 2; i++ {
+		if code := deliver("hook-secret"); code != http.StatusOK {
+			t.Fatalf("delivery %d: status = %d", i, code)
+		}
+	}
+	if len(l.reqs) != 1 {
+		t.Fatalf("launched %+v", l.reqs)
+	}
+	if told := api.told(42); len(told) != 1 {
+		t.Fatalf("the redelivery was answered: %q", told)
+	}
+}
+
+func TestPoll_AdvancesOffset(t *testing.T) {
+	var l launches
+	r, api := bot([]int64{42}, []string{"acme/api"}, l.launch)
+	batch, _ := json.Marshal([]update{text(700, 42, "/code #1 one"), text(703, 99, "/code #2 ignored"), text(704, 42, "hello")})
+	api.batches = []string{string(batch), "", `[]`}
+
+	for i := 0; i < 4; i++ {
+		r.Poll(context.Background())
+	}
+	// a skipped update still moves the offset on; a failed or empty poll
+	// keeps it
+	if want := []int64{0, 705, 705, 705}; fmt.Sprint(api.offsets) != fmt.Sprint(want) {
+		t.Fatalf("offsets = %v, want %v", api.offsets, want)
+	}
+	if len(l.reqs) != 1 || l.reqs[0].Number != 1 {
+		t.Fatalf("launched %+v", l.reqs)
+	}
+}
+
+func TestNotify_TellsTheLaunchingChat(t *testing.T) {
+	var l launches
+	r, api := bot([]int64{42, 43}, []string{"acme/api"}, l.launch)
+	r.handleUpdate(context.Background(), text(1, 43, "/code #7 fix it"))
+
+	ctx := context.Background()
+	_ = r.Notify(ctx, notify.Event{Type: notify.EventFailed, TaskID: "task-1", Repo: "acme/api", Number: 7, Error: "timeout"})
+	_ = r.Notify(ctx, notify.Event{Type: notify.EventCompleted, TaskID: "task-1", Repo: "acme/api", Number: 7, Summary: "a" + strings.Repeat("界", maxSummaryLen)})
+	_ = r.Notify(ctx, notify.Event{Type: notify.EventCompleted, TaskID: "task-1", Repo: "acme/api", Number: 7})
+
+	told := api.told(43)
+	if len(told) != 3 || len(api.told(42)) != 0 {
+		t.Fatalf("told 43 %q, 42 %q", told, api.told(42))
+	}
+	if !strings.HasPrefix(told[1], "⚠️ Task task-1 failed: timeout. It is retried") {
+		t.Fatalf("failure = %q", told[1])
+	}
+	done := told[2]
+	if !strings.HasPrefix(done, "✅ Task task-1 completed: https://github.com/acme/api/issues/7\n\na界") ||
+		!strings.HasSuffix(done, "[... truncated ...]") || strings.ContainsRune(done, '�') {
+		t.Fatalf("completion = %q", done)
+	}
+}
+
+func TestConfig(t *testing.T) {
+	if chats, err := ParseChats([]string{"42", " -100123 "}); err != nil || len(chats) != 2 || chats[1] != -100123 {
+		t.Fatalf("ParseChats = %v, %v", chats, err)
+	}
+	if _, err := ParseChats([]string{"@me"}); err == nil {
+		t.Fatal("ParseChats accepted a username")
+	}
+	if err := CheckRepos([]string{"acme/api"}); err != nil {
+		t.Fatalf("CheckRepos: %v", err)
+	}
+	for _, bad := range []string{"api", "acme/api#1", "#1"} {
+		if err := CheckRepos([]string{bad}); err == nil {
+			t.Errorf("CheckRepos(%q) accepted", bad)
+		}
+	}
+}
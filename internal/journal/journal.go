@@ -0,0 +1,387 @@
+// Package journal records the tasks accepted for execution until they
+// finish, so the dispatcher queue can be rebuilt after a crash or a restart
+// that did not drain it.
+package journal
+
+import (
+	"bufio"
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"os"
+	"path/filepath"
+	"slices"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/cexll/swe/internal/filelock"
+)
+
+// KeyTTL is how long the idempotency key of a finished task is kept; it
+// matches how long GitHub allows a delivery to be redelivered.
+const KeyTTL = 72 * time.Hour
+
+// Entry is an accepted task that has not finished.
+type Entry struct {
+	ID         string          `json:"id"`
+	Key        string          `json:"key,omitempty"` // idempotency key
+	AcceptedAt time.Time       `json:"accepted_at"`
+	Task       json.RawMessage `json:"task,omitempty"`
+	// Done marks the line that removes the entry; it is never returned.
+	// Done lines keep the key of the finished task until KeyTTL passes.
+	Done       bool      `json:"done,omitempty"`
+	FinishedAt time.Time `json:"finished_at,omitempty"`
+}
+
+// Journal keeps the unfinished entries in a JSON lines file: each accepted
+// task is appended, and so is a done line once it finishes. The file is
+// rewritten with the unfinished entries, and the keys of recently finished
+// ones, when opened and once the lines of finished tasks outnumber them.
+//
+// One process at a time owns the file, through an exclusive lock on
+// "<path>.lock". A process started while another still owns it, such as a
+// new version taking over the port from one that drains, keeps its entries
+// in memory until Acquire takes the file over.
+type Journal struct {
+	mu       sync.Mutex
+	path     string
+	lock     *filelock.Lock // nil until j owns the file
+	leftover []Entry        // unfinished entries found in the file, for Acquire
+	entries  map[string]*Entry
+	order    []string // entry IDs by acceptance
+	finished []Entry  // done lines of keyed tasks, by finish time
+	file     *os.File
+	stale    int // lines of finished or replaced entries since the last rewrite
+}
+
+// allow tests to control time
+var now = time.Now
+
+// compactAfter is how many stale lines the file may hold before it is
+// rewritten, at least.
+const compactAfter = 256
+
+// lockRetryInterval is how often Acquire tries to take the file over (a
+// variable so tests can shorten it).
+var lockRetryInterval = time.Second
+
+// Open opens (or creates) the journal at path. It returns nil when path is
+// empty; the methods of a nil Journal do nothing. When another process owns
+// the file, the journal starts empty and records in memory until Acquire.
+func Open(path string) (*Journal, error) {
+	if path == "" {
+		return nil, nil
+	}
+	if err := os.MkdirAll(filepath.Dir(path), 0o755); err != nil {
+		return nil, fmt.Errorf("create journal dir: %w", err)
+	}
+	j := &Journal{path: path, entries: make(map[string]*Entry)}
+	lock, err := filelock.TryLock(path + ".lock")
+	if errors.Is(err, filelock.ErrLocked) {
+		return j, nil
+	}
+	if err != nil {
+		return nil, fmt.Errorf("lock journal: %w", err)
+	}
+	leftover, err := j.takeOver(lock)
+	if err != nil {
+		return nil, err
+	}
+	j.leftover = leftover
+	return j, nil
+}
+
+// Owned reports whether j owns its file; a journal that does not waits for
+// Acquire.
+func (j *Journal) Owned() bool {
+	if j == nil {
+		return false
+	}
+	j.mu.Lock()
+	defer j.mu.Unlock()
+	return j.lock != nil
+}
+
+// Acquire waits until j owns its file, which the process that owned it
+// before releases when it exits, and returns the entries that process left
+// unfinished, in the order they were accepted: the tasks to queue again.
+// What j recorded meanwhile is written to the file along with them. Once
+// j owns the file, Acquire returns the entries found when it was opened,
+// then none.
+func (j *Journal) Acquire(ctx context.Context) ([]Entry, error) {
+	if j == nil {
+		return nil, nil
+	}
+	for {
+		j.mu.Lock()
+		if j.lock != nil {
+			leftover := j.leftover
+			j.leftover = nil
+			j.mu.Unlock()
+			return leftover, nil
+		}
+		j.mu.Unlock()
+
+		lock, err := filelock.TryLock(j.path + ".lock")
+		if err == nil {
+			return j.takeOver(lock)
+		}
+		if !errors.Is(err, filelock.ErrLocked) {
+			return nil, fmt.Errorf("lock journal: %w", err)
+		}
+		select {
+		case <-ctx.Done():
+			return nil, ctx.Err()
+		case <-time.After(lockRetryInterval):
+		}
+	}
+}
+
+// takeOver makes j the owner of the file it holds lock on: the entries in
+// the file come before those j recorded in memory, and the file is
+// rewritten with both. It returns the unfinished entries of the file.
+func (j *Journal) takeOver(lock *filelock.Lock) ([]Entry, error) {
+	prev := &Journal{path: j.path, entries: make(map[string]*Entry)}
+	if err := prev.load(); err != nil {
+		_ = lock.Close()
+		return nil, err
+	}
+
+	j.mu.Lock()
+	defer j.mu.Unlock()
+	var leftover []Entry
+	order := make([]string, 0, len(prev.order)+len(j.order))
+	for _, id := range prev.order {
+		if _, ok := j.entries[id]; ok {
+			continue
+		}
+		j.entries[id] = prev.entries[id]
+		order = append(order, id)
+		leftover = append(leftover, *prev.entries[id])
+	}
+	j.order = append(order, j.order...)
+	j.finished = append(prev.finished, j.finished...)
+	j.lock = lock
+	if err := j.compactLocked(); err != nil {
+		_ = lock.Close()
+		j.lock = nil
+		return nil, err
+	}
+	return leftover, nil
+}
+
+func (j *Journal) load() error {
+	f, err := os.Open(j.path)
+	if os.IsNotExist(err) {
+		return nil
+	}
+	if err != nil {
+		return fmt.Errorf("open journal: %w", err)
+	}
+	defer func() { _ = f.Close() }()
+
+	scanner := bufio.NewScanner(f)
+	// tasks carry their raw webhook payload
+	scanner.Buffer(make([]byte, 0, 64*1024), 32*1024*1024)
+	for scanner.Scan() {
+		line := strings.TrimSpace(scanner.Text())
+		if line == "" {
+			continue
+		}
+		var e Entry
+		if err := json.Unmarshal([]byte(line), &e); err != nil || e.ID == "" {
+			// skip corrupt lines, such as one cut short by a crash
+			continue
+		}
+		if e.Done {
+			delete(j.entries, e.ID)
+			j.order = slices.DeleteFunc(j.order, func(id string) bool { return id == e.ID })
+			if e.Key != "" {
+				j.finished = append(j.finished, e)
+			}
+			continue
+		}
+		if _, ok := j.entries[e.ID]; !ok {
+			j.order = append(j.order, e.ID)
+		}
+		entry := e
+		j.entries[e.ID] = &entry
+	}
+	return scanner.Err()
+}
+
+// Add records task, marshalled to JSON, as accepted under id with the
+// idempotency key (which may be empty). Adding an ID again replaces its task.
+func (j *Journal) Add(id, key string, task any) error {
+	if j == nil || id == "" {
+		return nil
+	}
+	blob, err := json.Marshal(task)
+	if err != nil {
+		return fmt.Errorf("marshal journal task: %w", err)
+	}
+	j.mu.Lock()
+	defer j.mu.Unlock()
+	e := Entry{ID: id, Key: key, AcceptedAt: now(), Task: blob}
+	if existing, ok := j.entries[id]; ok {
+		e.AcceptedAt = existing.AcceptedAt
+		j.stale++
+	} else {
+		j.order = append(j.order, id)
+	}
+	j.entries[id] = &e
+	return j.appendLocked(e)
+}
+
+// Done removes the entry of a finished task, keeping its key for KeyTTL.
+func (j *Journal) Done(id string) error {
+	return j.remove(id, true)
+}
+
+// Drop removes the entry of a task that was refused, key included.
+func (j *Journal) Drop(id string) error {
+	return j.remove(id, false)
+}
+
+func (j *Journal) remove(id string, keepKey bool) error {
+	if j == nil || id == "" {
+		return nil
+	}
+	j.mu.Lock()
+	defer j.mu.Unlock()
+	e, ok := j.entries[id]
+	if !ok {
+		return nil
+	}
+	delete(j.entries, id)
+	j.order = slices.DeleteFunc(j.order, func(o string) bool { return o == id })
+	done := Entry{ID: id, Done: true, FinishedAt: now()}
+	if keepKey && e.Key != "" {
+		done.Key = e.Key
+		j.finished = append(j.finished, done)
+	}
+	j.stale += 2
+	if j.stale > compactAfter && j.stale > len(j.entries)+len(j.finished) {
+		return j.compactLocked()
+	}
+	return j.appendLocked(done)
+}
+
+// Pending returns the unfinished entries in the order they were accepted.
+func (j *Journal) Pending() []Entry {
+	if j == nil {
+		return nil
+	}
+	j.mu.Lock()
+	defer j.mu.Unlock()
+	out := make([]Entry, 0, len(j.order))
+	for _, id := range j.order {
+		out = append(out, *j.entries[id])
+	}
+	return out
+}
+
+// Keys returns the idempotency keys of the tasks that finished within
+// KeyTTL. The keys of pending entries are not included: they are claimed
+// again when their tasks are requeued.
+func (j *Journal) Keys() []string {
+	if j == nil {
+		return nil
+	}
+	j.mu.Lock()
+	defer j.mu.Unlock()
+	j.expireLocked()
+	keys := make([]string, 0, len(j.finished))
+	for _, e := range j.finished {
+		keys = append(keys, e.Key)
+	}
+	return keys
+}
+
+// expireLocked forgets the keys of tasks that finished more than KeyTTL ago.
+func (j *Journal) expireLocked() {
+	cutoff := now().Add(-KeyTTL)
+	j.finished = slices.DeleteFunc(j.finished, func(e Entry) bool { return e.FinishedAt.Before(cutoff) })
+}
+
+// compactLocked rewrites the file with the unfinished entries and the done
+// lines whose keys are still kept, if j owns it.
+func (j *Journal) compactLocked() error {
+	j.stale = 0
+	j.expireLocked()
+	if j.lock == nil {
+		return nil
+	}
+	if j.file != nil {
+		_ = j.file.Close()
+		j.file = nil
+	}
+	tmp := j.path + ".tmp"
+	f, err := os.OpenFile(tmp, os.O_CREATE|os.O_TRUNC|os.O_WRONLY, 0o600)
+	if err != nil {
+		return fmt.Errorf("rewrite journal: %w", err)
+	}
+	enc := json.NewEncoder(f)
+	for _, e := range j.finished {
+		if err := enc.Encode(e); err != nil {
+			_ = f.Close()
+			return fmt.Errorf("encode journal entry: %w", err)
+		}
+	}
+	for _, id := range j.order {
+		if err := enc.Encode(j.entries[id]); err != nil {
+			_ = f.Close()
+			return fmt.Errorf("encode journal entry: %w", err)
+		}
+	}
+	if err := f.Close(); err != nil {
+		return fmt.Errorf("rewrite journal: %w", err)
+	}
+	if err := os.Rename(tmp, j.path); err != nil {
+		return fmt.Errorf("rewrite journal: %w", err)
+	}
+	return nil
+}
+
+func (j *Journal) appendLocked(e Entry) error {
+	if j.lock == nil {
+		// kept in memory until Acquire writes it
+		return nil
+	}
+	if j.file == nil {
+		f, err := os.OpenFile(j.path, os.O_CREATE|os.O_APPEND|os.O_WRONLY, 0o600)
+		if err != nil {
+			return fmt.Errorf("open journal: %w", err)
+		}
+		j.file = f
+	}
+	blob, err := json.Marshal(e)
+	if err != nil {
+		return fmt.Errorf("marshal journal entry: %w", err)
+	}
+	if _, err := j.file.Write(append(blob, '\n')); err != nil {
+		return fmt.Errorf("write journal: %w", err)
+	}
+	return nil
+}
+
+// Close releases the backing file and its lock.
+func (j *Journal) Close() error {
+	if j == nil {
+		return nil
+	}
+	j.mu.Lock()
+	defer j.mu.Unlock()
+	var err error
+	if j.file != nil {
+		err = j.file.Close()
+		j.file = nil
+	}
+	if j.lock != nil {
+		_ = j.lock.Close()
+		j.lock = nil
+	}
+	return err
+}
@@ -0,0 +1,198 @@
+package journal
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"os"
+	"path/filepath"
+	"slices"
+	"strings"
+	"testing"
+	"time"
+)
+
+type task struct {
+	ID     string
+	Prompt string
+}
+
+func pendingIDs(j *Journal) []string {
+	var ids []string
+	for _, e := range j.Pending() {
+		ids = append(ids, e.ID)
+	}
+	return ids
+}
+
+func TestJournal_KeepsUnfinishedAcrossRestarts(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "state", "journal.jsonl")
+	j, err := Open(path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	for _, id := range []string{"a", "b", "c"} {
+		if err := j.Add(id, "", task{ID: id, Prompt: "fix " + id}); err != nil {
+			t.Fatal(err)
+		}
+	}
+	_ = j.Add("a", "", task{ID: "a", Prompt: "fix a again"})
+	_ = j.Done("b")
+	_ = j.Done("unknown")
+	// a crash can cut the last line short
+	_ = j.Close()
+	f, _ := os.OpenFile(path, os.O_APPEND|os.O_WRONLY, 0o600)
+	_, _ = f.WriteString(`{"id":"d","task":{"ID":`)
+	_ = f.Close()
+
+	j, err = Open(path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer j.Close()
+	if ids := strings.Join(pendingIDs(j), ","); ids != "a,c" {
+		t.Fatalf("pending = %s, want a,c", ids)
+	}
+	var got task
+	if err := json.Unmarshal(j.Pending()[0].Task, &got); err != nil || got.Prompt != "fix a again" {
+		t.Fatalf("task a = %+v, %v", got, err)
+	}
+	data, _ := os.ReadFile(path)
+	if lines := strings.Count(string(data), "\n"); lines != 2 {
+		t.Fatalf("journal not compacted on open: %d lines\n%s", lines, data)
+	}
+}
+
+func TestJournal_CompactsFinishedEntries(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "journal.jsonl")
+	j, err := Open(path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer j.Close()
+	_ = j.Add("kept", "", task{ID: "kept"})
+	for i := 0; i <= compactAfter; i++ {
+		_ = j.Add("done", "", task{ID: "done"})
+		_ = j.Done("done")
+	}
+	data, _ := os.ReadFile(path)
+	if lines := strings.Count(string(data), "\n"); lines > compactAfter+1 {
+		t.Fatalf("journal holds %d lines for one unfinished task", lines)
+	}
+	if ids := pendingIDs(j); len(ids) != 1 || ids[0] != "kept" {
+		t.Fatalf("pending = %v", ids)
+	}
+}
+
+func TestJournal_Disabled(t *testing.T) {
+	j, err := Open("")
+	if err != nil || j != nil {
+		t.Fatalf("Open(\"\") = %v, %v", j, err)
+	}
+	if j.Add("a", "", task{}) != nil || j.Done("a") != nil || j.Drop("a") != nil || j.Pending() != nil || j.Keys() != nil || j.Close() != nil {
+		t.Fatal("a nil journal does nothing")
+	}
+}
+
+func TestJournal_KeepsKeysOfFinishedTasks(t *testing.T) {
+	orig := now
+	t.Cleanup(func() { now = orig })
+	clock := time.Date(2024, 1, 1, 0, 0, 0, 0, time.UTC)
+	now = func() time.Time { return clock }
+
+	path := filepath.Join(t.TempDir(), "journal.jsonl")
+	j, err := Open(path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	_ = j.Add("old", "delivery-1:1", task{ID: "old"})
+	_ = j.Done("old")
+	clock = clock.Add(KeyTTL / 2)
+	_ = j.Add("new", "delivery-2:2", task{ID: "new"})
+	_ = j.Done("new")
+	_ = j.Add("running", "delivery-3:3", task{ID: "running"})
+	_ = j.Add("unkeyed", "", task{ID: "unkeyed"})
+	_ = j.Done("unkeyed")
+	_ = j.Add("refused", "delivery-4:4", task{ID: "refused"})
+	_ = j.Drop("refused")
+	_ = j.Close()
+
+	clock = clock.Add(KeyTTL/2 + time.Minute)
+	j, err = Open(path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer j.Close()
+	if keys := j.Keys(); !slices.Equal(keys, []string{"delivery-2:2"}) {
+		t.Fatalf("keys = %v, want the key finished within the TTL", keys)
+	}
+	if ids := pendingIDs(j); !slices.Equal(ids, []string{"running"}) || j.Pending()[0].Key != "delivery-3:3" {
+		t.Fatalf("pending = %+v", j.Pending())
+	}
+}
+
+func TestJournal_WaitsForThePreviousOwner(t *testing.T) {
+	orig := lockRetryInterval
+	t.Cleanup(func() { lockRetryInterval = orig })
+	lockRetryInterval = time.Millisecond
+
+	path := filepath.Join(t.TempDir(), "journal.jsonl")
+	old, err := Open(path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	_ = old.Add("running", "key-running", task{ID: "running"})
+	_ = old.Add("queued", "", task{ID: "queued"})
+
+	// the new version starts while the old one drains
+	j, err := Open(path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer j.Close()
+	if j.Owned() || len(j.Pending()) != 0 {
+		t.Fatalf("took the old version's entries: owned %t, pending %v", j.Owned(), pendingIDs(j))
+	}
+	_ = j.Add("new", "key-new", task{ID: "new"})
+	ctx, cancel := context.WithTimeout(context.Background(), 10*time.Millisecond)
+	defer cancel()
+	if _, err := j.Acquire(ctx); !errors.Is(err, context.DeadlineExceeded) {
+		t.Fatalf("Acquire while the old version runs = %v", err)
+	}
+
+	// the old version finishes its running task, compacts, and exits
+	// with one task left
+	_ = old.Done("running")
+	old.mu.Lock()
+	_ = old.compactLocked()
+	old.mu.Unlock()
+	_ = old.Close()
+
+	leftover, err := j.Acquire(context.Background())
+	if err != nil {
+		t.Fatalf("Acquire: %v", err)
+	}
+	if len(leftover) != 1 || leftover[0].ID != "queued" {
+		t.Fatalf("leftover = %+v", leftover)
+	}
+	if got := pendingIDs(j); !slices.Equal(got, []string{"queued", "new"}) {
+		t.Fatalf("pending = %v", got)
+	}
+	if keys := j.Keys(); !slices.Equal(keys, []string{"key-running"}) {
+		t.Fatalf("keys = %v", keys)
+	}
+	if again, _ := j.Acquire(context.Background()); len(again) != 0 {
+		t.Fatalf("second Acquire = %+v", again)
+	}
+
+	// what was kept in memory is in the file now
+	_ = j.Close()
+	reopened, err := Open(path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer reopened.Close()
+	if got := pendingIDs(reopened); !slices.Equal(got, []string{"queued", "new"}) {
+		t.Fatalf("pending after restart = %v", got)
+	}
+}
@@ -0,0 +1,318 @@
+// Package knowledge remembers what finished tasks did in each repository
+// (the request, the agent's summary, the branch) and finds the entries
+// relevant to a new task in the same repository, so that its prompt can
+// mention earlier work ("previously the agent refactored X"). Entries are
+// kept as one JSON lines file per repository and matched with a keyword
+// index weighted by how rare each word is.
+package knowledge
+
+import (
+	"bufio"
+	"encoding/json"
+	"fmt"
+	"math"
+	"os"
+	"path/filepath"
+	"sort"
+	"strings"
+	"sync"
+	"time"
+	"unicode"
+)
+
+// DefaultMaxEntries is how many entries a repository keeps by default.
+const DefaultMaxEntries = 200
+
+// maxField caps each text field of a stored entry.
+const maxField = 2000
+
+// Entry is what one task did.
+type Entry struct {
+	TaskID  string    `json:"task_id,omitempty"`
+	Number  int       `json:"number,omitempty"` // issue or pull request
+	IsPR    bool      `json:"is_pr,omitempty"`
+	Title   string    `json:"title,omitempty"`
+	Request string    `json:"request,omitempty"` // the trigger comment
+	Summary string    `json:"summary"`
+	Branch  string    `json:"branch,omitempty"`
+	Time    time.Time `json:"time"`
+}
+
+// Store keeps the entries of every repository under one directory; a nil
+// Store remembers nothing.
+type Store struct {
+	dir string
+	max int
+
+	mu    sync.Mutex
+	repos map[string][]Entry // loaded repositories, oldest entry first
+}
+
+// allow tests to control time
+var now = time.Now
+
+// Open returns the store kept in dir, holding up to maxEntries per
+// repository (0 uses DefaultMaxEntries); an empty dir returns nil.
+func Open(dir string, maxEntries int) (*Store, error) {
+	if dir == "" {
+		return nil, nil
+	}
+	if maxEntries <= 0 {
+		maxEntries = DefaultMaxEntries
+	}
+	if err := os.MkdirAll(dir, 0o755); err != nil {
+		return nil, fmt.Errorf("knowledge store: %w", err)
+	}
+	return &Store{dir: dir, max: maxEntries, repos: make(map[string][]Entry)}, nil
+}
+
+// Add remembers e for repo (owner/name), dropping the oldest entries beyond
+// the store's limit.
+func (s *Store) Add(repo string, e Entry) error {
+	if s == nil {
+		return nil
+	}
+	if strings.TrimSpace(e.Summary) == "" {
+		return nil
+	}
+	if e.Time.IsZero() {
+		e.Time = now()
+	}
+	e.Title, e.Request, e.Summary = clip(e.Title), clip(e.Request), clip(e.Summary)
+
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	entries, err := s.loadLocked(repo)
+	if err != nil {
+		return err
+	}
+	entries = append(entries, e)
+	path := s.path(repo)
+	if len(entries) > s.max {
+		entries = entries[len(entries)-s.max:]
+		err = rewrite(path, entries)
+	} else {
+		err = appendEntry(path, e)
+	}
+	s.repos[key(repo)] = entries
+	return err
+}
+
+// Relevant returns up to n entries of repo that share words with query,
+// best match first; among equal matches the newer entry wins.
+func (s *Store) Relevant(repo, query string, n int) ([]Entry, error) {
+	if s == nil || n <= 0 {
+		return nil, nil
+	}
+	s.mu.Lock()
+	entries, err := s.loadLocked(repo)
+	s.mu.Unlock()
+	if err != nil || len(entries) == 0 {
+		return nil, err
+	}
+
+	docs := make([]map[string]bool, len(entries))
+	df := make(map[string]int)
+	for i, e := range entries {
+		docs[i] = words(e.Title + " " + e.Request + " " + e.Summary)
+		for w := range docs[i] {
+			df[w]++
+		}
+	}
+	type scored struct {
+		entry Entry
+		score float64
+	}
+	var matches []scored
+	for i, e := range entries {
+		var score float64
+		for w := range words(query) {
+			if docs[i][w] {
+				score += math.Log(1 + float64(len(entries))/float64(df[w]))
+			}
+		}
+		if score > 0 {
+			matches = append(matches, scored{e, score})
+		}
+	}
+	sort.SliceStable(matches, func(i, j int) bool {
+		if matches[i].score != matches[j].score {
+			return matches[i].score > matches[j].score
+		}
+		return matches[i].entry.Time.After(matches[j].entry.Time)
+	})
+	if len(matches) > n {
+		matches = matches[:n]
+	}
+	out := make([]Entry, len(matches))
+	for i, m := range matches {
+		out[i] = m.entry
+	}
+	return out, nil
+}
+
+// loadLocked returns the entries of repo, reading its file the first time.
+func (s *Store) loadLocked(repo string) ([]Entry, error) {
+	if entries, ok := s.repos[key(repo)]; ok {
+		return entries, nil
+	}
+	f, err := os.Open(s.path(repo))
+	if os.IsNotExist(err) {
+		s.repos[key(repo)] = nil
+		return nil, nil
+	}
+	if err != nil {
+		return nil, fmt.Errorf("knowledge store: %w", err)
+	}
+	defer func() { _ = f.Close() }()
+
+	var entries []Entry
+	scanner := bufio.NewScanner(f)
+	scanner.Buffer(make([]byte, 0, 64*1024), 1024*1024)
+	for scanner.Scan() {
+		var e Entry
+		if err := json.Unmarshal(scanner.Bytes(), &e); err != nil || e.Summary == "" {
+			// skip corrupt lines rather than losing the rest
+			continue
+		}
+		entries = append(entries, e)
+	}
+	if err := scanner.Err(); err != nil {
+		return nil, fmt.Errorf("knowledge store: %w", err)
+	}
+	if len(entries) > s.max {
+		entries = entries[len(entries)-s.max:]
+	}
+	s.repos[key(repo)] = entries
+	return entries, nil
+}
+
+// path is the file of repo: <dir>/<owner>/<name>.jsonl.
+func (s *Store) path(repo string) string {
+	owner, name, _ := strings.Cut(key(repo), "/")
+	return filepath.Join(s.dir, safeName(owner), safeName(name)+".jsonl")
+}
+
+func key(repo string) string { return strings.ToLower(strings.TrimSpace(repo)) }
+
+// safeName keeps a path element inside the store's directory.
+func safeName(s string) string {
+	s = strings.Map(func(r rune) rune {
+		if r == '/' || r == '\\' || r == 0 {
+			return '_'
+		}
+		return r
+	}, s)
+	if s == "" || s == "." || s == ".." {
+		return "_" + s
+	}
+	return s
+}
+
+func appendEntry(path string, e Entry) error {
+	line, err := json.Marshal(e)
+	if err != nil {
+		return fmt.Errorf("knowledge store: %w", err)
+	}
+	if err := os.MkdirAll(filepath.Dir(path), 0o755); err != nil {
+		return fmt.Errorf("knowledge store: %w", err)
+	}
+	f, err := os.OpenFile(path, os.O_CREATE|os.O_WRONLY|os.O_APPEND, 0o644)
+	if err != nil {
+		return fmt.Errorf("knowledge store: %w", err)
+	}
+	defer func() { _ = f.Close() }()
+	if _, err := f.Write(append(line, '\n')); err != nil {
+		return fmt.Errorf("knowledge store: %w", err)
+	}
+	return nil
+}
+
+// rewrite replaces the file at path with entries.
+func rewrite(path string, entries []Entry) error {
+	var sb strings.Builder
+	for _, e := range entries {
+		line, err := json.Marshal(e)
+		if err != nil {
+			return fmt.Errorf("knowledge store: %w", err)
+		}
+		sb.Write(line)
+		sb.WriteByte('\n')
+	}
+	tmp := path + ".tmp"
+	if err := os.WriteFile(tmp, []byte(sb.String()), 0o644); err != nil {
+		return fmt.Errorf("knowledge store: %w", err)
+	}
+	if err := os.Rename(tmp, path); err != nil {
+		return fmt.Errorf("knowledge store: %w", err)
+	}
+	return nil
+}
+
+// stopWords are too common in task text to tell tasks apart.
+var stopWords = map[string]bool{
+	"the": true, "and": true, "for": true, "with": true, "this": true, "that": true,
+	"from": true, "into": true, "are": true, "was": true, "were": true, "has": true,
+	"have": true, "not": true, "but": true, "you": true, "can": true, "please": true,
+	"code": true, "should": true, "would": true, "will": true, "all": true, "now": true,
+	"add": true, "added": true, "fix": true, "fixed": true, "use": true, "when": true,
+}
+
+// words returns the distinct lowercase words of s worth matching on.
+func words(s string) map[string]bool {
+	out := make(map[string]bool)
+	for _, w := range strings.FieldsFunc(strings.ToLower(s), func(r rune) bool {
+		return !unicode.IsLetter(r) && !unicode.IsDigit(r) && r != '_'
+	}) {
+		if len(w) >= 3 && !stopWords[w] {
+			out[w] = true
+		}
+	}
+	return out
+}
+
+func clip(s string) string {
+	s = strings.TrimSpace(s)
+	if len(s) <= maxField {
+		return s
+	}
+	return strings.ToValidUTF8(s[:maxField], "") + "..."
+}
+
+// PromptSection renders entries for the prompt of a new task, or "".
+func PromptSection(entries []Entry) string {
+	if len(entries) == 0 {
+		return ""
+	}
+	var sb strings.Builder
+	sb.WriteString("<prior_work>\nEarlier tasks in this repository that look related. Build on their decisions, and do not undo their changes unless asked to:\n")
+	for _, e := range entries {
+		kind := "issue"
+		if e.IsPR {
+			kind = "PR"
+		}
+		fmt.Fprintf(&sb, "\n- %s, %s #%d", e.Time.UTC().Format("2006-01-02"), kind, e.Number)
+		if e.Title != "" {
+			fmt.Fprintf(&sb, " %q", e.Title)
+		}
+		if e.Branch != "" {
+			fmt.Fprintf(&sb, " (branch %s)", e.Branch)
+		}
+		sb.WriteString("\n")
+		if e.Request != "" {
+			fmt.Fprintf(&sb, "  Request: %s\n", oneLine(e.Request, 300))
+		}
+		fmt.Fprintf(&sb, "  Outcome: %s\n", oneLine(e.Summary, 600))
+	}
+	sb.WriteString("</prior_work>")
+	return sb.String()
+}
+
+// oneLine joins the lines of s and shortens it to max bytes.
+func oneLine(s string, max int) string {
+	s = strings.Join(strings.Fields(s), " ")
+	if len(s) > max {
+		s = strings.ToValidUTF8(s[:max], "") + "..."
+	}
+	return s
+}
@@ -0,0 +1,109 @@
+package knowledge
+
+import (
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+)
+
+func TestStore_RelevantRanksByRareSharedWords(t *testing.T) {
+	dir := t.TempDir()
+	s, err := Open(dir, 0)
+	if err != nil {
+		t.Fatal(err)
+	}
+	day := time.Date(2026, 10, 1, 0, 0, 0, 0, time.UTC)
+	for i, e := range []Entry{
+		{Number: 1, Title: "Refactor the billing invoices module", Summary: "Moved invoice rendering into billing/render.go"},
+		{Number: 2, Title: "Fix login redirect", Summary: "The login handler now keeps the return URL"},
+		{Number: 3, Title: "Speed up invoices export", Summary: "Invoice export streams rows instead of buffering"},
+		{Number: 4, Title: "Nothing to remember"},
+	} {
+		e.Time = day.Add(time.Duration(i) * time.Hour)
+		if err := s.Add("Acme/App", e); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	got, err := s.Relevant("acme/app", "Invoices render slowly in the billing page", 5)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(got) != 2 || got[0].Number != 1 || got[1].Number != 3 {
+		t.Fatalf("Relevant = %+v", got)
+	}
+	if got, _ := s.Relevant("acme/app", "the and for", 5); len(got) != 0 {
+		t.Fatalf("stop words matched %+v", got)
+	}
+	if got, _ := s.Relevant("acme/other", "invoices", 5); len(got) != 0 {
+		t.Fatalf("other repository matched %+v", got)
+	}
+
+	// entries survive a restart
+	reopened, _ := Open(dir, 0)
+	if got, _ := reopened.Relevant("acme/app", "login", 1); len(got) != 1 || got[0].Number != 2 {
+		t.Fatalf("after reopening: %+v", got)
+	}
+	if _, err := os.Stat(filepath.Join(dir, "acme", "app.jsonl")); err != nil {
+		t.Fatal(err)
+	}
+}
+
+func TestStore_KeepsNewestEntries(t *testing.T) {
+	dir := t.TempDir()
+	s, _ := Open(dir, 2)
+	for i := 1; i <= 3; i++ {
+		if err := s.Add("acme/app", Entry{Number: i, Summary: "changed the parser"}); err != nil {
+			t.Fatal(err)
+		}
+	}
+	got, _ := s.Relevant("acme/app", "parser", 5)
+	if len(got) != 2 || got[0].Number != 3 || got[1].Number != 2 {
+		t.Fatalf("Relevant = %+v", got)
+	}
+	data, _ := os.ReadFile(filepath.Join(dir, "acme", "app.jsonl"))
+	if n := strings.Count(string(data), "\n"); n != 2 {
+		t.Fatalf("file keeps %d entries, want 2", n)
+	}
+}
+
+func TestStore_Nil(t *testing.T) {
+	s, err := Open("", 0)
+	if s != nil || err != nil {
+		t.Fatalf("Open(\"\") = %v, %v", s, err)
+	}
+	if err := s.Add("a/b", Entry{Summary: "x"}); err != nil {
+		t.Fatal(err)
+	}
+	if got, err := s.Relevant("a/b", "x", 3); got != nil || err != nil {
+		t.Fatalf("Relevant = %v, %v", got, err)
+	}
+}
+
+func TestSafeName(t *testing.T) {
+	s, _ := Open(t.TempDir(), 0)
+	if p := s.path("../.."); !strings.HasPrefix(p, s.dir+string(filepath.Separator)) {
+		t.Fatalf("path escapes the store: %s", p)
+	}
+}
+
+func TestPromptSection(t *testing.T) {
+	if PromptSection(nil) != "" {
+		t.Fatal("no entries, no section")
+	}
+	got := PromptSection([]Entry{{
+		Number:  7,
+		IsPR:    true,
+		Title:   "Refactor billing",
+		Request: "/code split\nthe module",
+		Summary: "Split billing into render and export",
+		Branch:  "swe-agent/7-1",
+		Time:    time.Date(2026, 10, 1, 9, 0, 0, 0, time.UTC),
+	}})
+	want := "- 2026-10-01, PR #7 \"Refactor billing\" (branch swe-agent/7-1)\n  Request: /code split the module\n  Outcome: Split billing into render and export\n</prior_work>"
+	if !strings.HasPrefix(got, "<prior_work>\n") || !strings.HasSuffix(got, want) {
+		t.Fatalf("PromptSection = %q", got)
+	}
+}
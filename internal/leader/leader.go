@@ -0,0 +1,226 @@
+// Package leader elects one of several replicas to run periodic background
+// jobs — artifact cleanup, the stuck-task reaper, budget resets — through a
+// lease kept in the shared storage backend.
+//
+// The lease is an object naming its holder and when it expires. A replica
+// takes an expired lease by writing its own record over the version it
+// read, a write the backend makes conditional: when two replicas race, the
+// backend lets exactly one of them write. The holder renews the lease
+// every third of its TTL, so a replica that stops renewing (crashed,
+// partitioned) hands over within one TTL. Jobs run behind a lease should
+// tolerate the rare overlap of two leaders around a handover, as when the
+// clocks of replicas disagree.
+package leader
+
+import (
+	"bytes"
+	"context"
+	"crypto/rand"
+	"encoding/hex"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io/fs"
+	"log/slog"
+	"os"
+	"sync"
+	"time"
+
+	"github.com/cexll/swe/internal/logging"
+	"github.com/cexll/swe/internal/storage"
+)
+
+// LeaseKey is where the lease is kept in the backend.
+const LeaseKey = "leader/lease.json"
+
+// DefaultTTL is how long a lease lasts without renewal.
+const DefaultTTL = 30 * time.Second
+
+// allow tests to control time
+var now = time.Now
+
+type lease struct {
+	Holder  string    `json:"holder"`
+	Expires time.Time `json:"expires"`
+}
+
+// Elector holds or waits for the lease on behalf of this replica. An
+// Elector without a backend — a single node — is always the leader.
+type Elector struct {
+	backend storage.Backend
+	id      string
+	ttl     time.Duration
+
+	mu      sync.Mutex
+	expires time.Time // leadership is valid until then
+}
+
+// New returns an elector competing for the lease in backend (nil: always
+// leader) with leases of ttl (0: DefaultTTL).
+func New(backend storage.Backend, ttl time.Duration) *Elector {
+	if ttl <= 0 {
+		ttl = DefaultTTL
+	}
+	return &Elector{backend: backend, id: instanceID(), ttl: ttl}
+}
+
+// instanceID names this replica in the lease: its host, pid and a random
+// suffix, so restarts never inherit a lease they did not take.
+func instanceID() string {
+	host, _ := os.Hostname()
+	var b [4]byte
+	_, _ = rand.Read(b[:])
+	return fmt.Sprintf("%s-%d-%s", host, os.Getpid(), hex.EncodeToString(b[:]))
+}
+
+// ID returns the name this replica writes into the lease.
+func (e *Elector) ID() string {
+	return e.id
+}
+
+// IsLeader reports whether this replica holds an unexpired lease.
+func (e *Elector) IsLeader() bool {
+	if e.backend == nil {
+		return true
+	}
+	e.mu.Lock()
+	defer e.mu.Unlock()
+	return now().Before(e.expires)
+}
+
+// Campaign takes or renews the lease when it can and reports whether this
+// replica is the leader afterwards.
+func (e *Elector) Campaign(ctx context.Context) (bool, error) {
+	if e.backend == nil {
+		return true, nil
+	}
+	cur, version, err := e.read(ctx)
+	if err != nil {
+		return e.IsLeader(), err
+	}
+	t := now()
+	if cur.Holder != e.id && t.Before(cur.Expires) {
+		e.setExpires(time.Time{})
+		return false, nil
+	}
+	err = e.write(ctx, lease{Holder: e.id, Expires: t.Add(e.ttl)}, version)
+	if errors.Is(err, storage.ErrPrecondition) {
+		// another replica wrote the lease since it was read
+		e.setExpires(time.Time{})
+		return false, nil
+	}
+	if err != nil {
+		return e.IsLeader(), err
+	}
+	// count from before the write, as other replicas do
+	e.setExpires(t.Add(e.ttl))
+	return true, nil
+}
+
+// Resign gives the lease up so another replica can take over at once, as
+// when draining.
+func (e *Elector) Resign(ctx context.Context) error {
+	if e.backend == nil || !e.IsLeader() {
+		return nil
+	}
+	e.setExpires(time.Time{})
+	cur, version, err := e.read(ctx)
+	if err != nil || cur.Holder != e.id {
+		return err
+	}
+	// an expired lease, unless another replica has taken it meanwhile
+	err = e.write(ctx, lease{}, version)
+	if errors.Is(err, storage.ErrPrecondition) {
+		return nil
+	}
+	return err
+}
+
+// Run campaigns every third of the lease TTL until ctx is done, then
+// resigns. It logs when leadership changes.
+func (e *Elector) Run(ctx context.Context) {
+	if e.backend == nil {
+		return
+	}
+	ticker := time.NewTicker(e.ttl / 3)
+	defer ticker.Stop()
+	was := false
+	for {
+		is, err := e.Campaign(ctx)
+		if err != nil && ctx.Err() == nil {
+			slog.WarnContext(ctx, "Leader election failed", logging.KeyPhase, "leader", "err", err)
+		}
+		if is != was {
+			if is {
+				slog.InfoContext(ctx, "Leader election: now the leader", logging.KeyPhase, "leader", "instance", e.id)
+			} else {
+				slog.InfoContext(ctx, "Leader election: no longer the leader", logging.KeyPhase, "leader", "instance", e.id)
+			}
+			was = is
+		}
+		select {
+		case <-ticker.C:
+		case <-ctx.Done():
+			if err := e.Resign(context.WithoutCancel(ctx)); err != nil {
+				slog.Warn("Leader election: resigning failed", logging.KeyPhase, "leader", "err", err)
+			}
+			return
+		}
+	}
+}
+
+// Every runs job every interval while this replica is the leader, until ctx
+// is done. Followers skip their turns.
+func (e *Elector) Every(ctx context.Context, interval time.Duration, job func(context.Context)) {
+	ticker := time.NewTicker(interval)
+	defer ticker.Stop()
+	for {
+		select {
+		case <-ticker.C:
+			if e.IsLeader() {
+				job(ctx)
+			}
+		case <-ctx.Done():
+			return
+		}
+	}
+}
+
+func (e *Elector) setExpires(t time.Time) {
+	e.mu.Lock()
+	e.expires = t
+	e.mu.Unlock()
+}
+
+// read returns the current lease and its version; a missing one is the
+// zero lease at version "".
+func (e *Elector) read(ctx context.Context) (lease, string, error) {
+	var l lease
+	data, version, err := e.backend.GetVersion(ctx, LeaseKey)
+	if errors.Is(err, fs.ErrNotExist) {
+		return l, "", nil
+	}
+	if err != nil {
+		return l, "", fmt.Errorf("read lease: %w", err)
+	}
+	if len(bytes.TrimSpace(data)) == 0 {
+		return l, version, nil
+	}
+	if err := json.Unmarshal(data, &l); err != nil {
+		// a foreign object: treat it as expired and overwrite it
+		return lease{}, version, nil
+	}
+	return l, version, nil
+}
+
+// write replaces the lease at version with l.
+func (e *Elector) write(ctx context.Context, l lease, version string) error {
+	data, err := json.Marshal(l)
+	if err != nil {
+		return err
+	}
+	if err := e.backend.PutIf(ctx, LeaseKey, data, version); err != nil {
+		return fmt.Errorf("write lease: %w", err)
+	}
+	return nil
+}
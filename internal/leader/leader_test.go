@@ -0,0 +1,159 @@
+package leader
+
+import (
+	"context"
+	"fmt"
+	"sync"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"github.com/cexll/swe/internal/storage"
+)
+
+func newTestElector(backend storage.Backend) *Elector {
+	return New(backend, 30*time.Second)
+}
+
+func TestElector_OneLeaderAtATime(t *testing.T) {
+	ctx := context.Background()
+	backend := &storage.Local{Dir: t.TempDir()}
+	a, b := newTestElector(backend), newTestElector(backend)
+
+	if ok, err := a.Campaign(ctx); !ok || err != nil {
+		t.Fatalf("a.Campaign = %v, %v; want leader", ok, err)
+	}
+	if ok, err := b.Campaign(ctx); ok || err != nil {
+		t.Fatalf("b.Campaign = %v, %v; want follower", ok, err)
+	}
+	// renewing keeps the lease
+	if ok, _ := a.Campaign(ctx); !ok || !a.IsLeader() || b.IsLeader() {
+		t.Fatalf("after renewal: a=%v b=%v", a.IsLeader(), b.IsLeader())
+	}
+
+	if err := a.Resign(ctx); err != nil {
+		t.Fatal(err)
+	}
+	if a.IsLeader() {
+		t.Fatal("a still leader after resigning")
+	}
+	if ok, _ := b.Campaign(ctx); !ok {
+		t.Fatal("b did not take the resigned lease")
+	}
+	if ok, _ := a.Campaign(ctx); ok {
+		t.Fatal("a took b's lease")
+	}
+}
+
+func TestElector_ExpiredLeaseIsTakenOver(t *testing.T) {
+	ctx := context.Background()
+	backend := &storage.Local{Dir: t.TempDir()}
+	a, b := newTestElector(backend), newTestElector(backend)
+	start := time.Date(2026, 10, 16, 9, 0, 0, 0, time.UTC)
+	clock := start
+	now = func() time.Time { return clock }
+	defer func() { now = time.Now }()
+
+	if ok, _ := a.Campaign(ctx); !ok {
+		t.Fatal("a not leader")
+	}
+	// a stops renewing
+	clock = start.Add(31 * time.Second)
+	if a.IsLeader() {
+		t.Fatal("a still leader after its lease expired")
+	}
+	if ok, _ := b.Campaign(ctx); !ok {
+		t.Fatal("b did not take the expired lease")
+	}
+	if ok, _ := a.Campaign(ctx); ok {
+		t.Fatal("a took the lease back")
+	}
+}
+
+// racer has a rival take the lease between the elector reading and
+// writing it.
+type racer struct {
+	storage.Backend
+	rival string
+	raced bool
+}
+
+func (r *racer) PutIf(ctx context.Context, key string, data []byte, version string) error {
+	if !r.raced {
+		r.raced = true
+		rival := &Elector{backend: r.Backend, id: r.rival, ttl: time.Minute}
+		if ok, err := rival.Campaign(ctx); !ok || err != nil {
+			return fmt.Errorf("rival campaign = %v, %v", ok, err)
+		}
+	}
+	return r.Backend.PutIf(ctx, key, data, version)
+}
+
+func TestElector_LosesARace(t *testing.T) {
+	backend := &racer{Backend: &storage.Local{Dir: t.TempDir()}, rival: "other"}
+	e := newTestElector(backend)
+	if ok, err := e.Campaign(context.Background()); ok || err != nil {
+		t.Fatalf("Campaign = %v, %v; want to lose the race", ok, err)
+	}
+	if e.IsLeader() {
+		t.Fatal("IsLeader after losing the race")
+	}
+	if cur, _, _ := e.read(context.Background()); cur.Holder != "other" {
+		t.Fatalf("lease held by %q, want the rival", cur.Holder)
+	}
+}
+
+func TestElector_ExactlyOneWinsAtOnce(t *testing.T) {
+	backend := &storage.Local{Dir: t.TempDir()}
+	var leaders atomic.Int32
+	var wg sync.WaitGroup
+	for i := 0; i < 8; i++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			ok, err := newTestElector(backend).Campaign(context.Background())
+			if err != nil {
+				t.Errorf("Campaign: %v", err)
+			}
+			if ok {
+				leaders.Add(1)
+			}
+		}()
+	}
+	wg.Wait()
+	if leaders.Load() != 1 {
+		t.Fatalf("%d leaders", leaders.Load())
+	}
+}
+
+func TestElector_SingleNodeIsAlwaysLeader(t *testing.T) {
+	e := New(nil, 0)
+	if !e.IsLeader() {
+		t.Fatal("elector without a backend is not the leader")
+	}
+	if ok, err := e.Campaign(context.Background()); !ok || err != nil {
+		t.Fatalf("Campaign = %v, %v", ok, err)
+	}
+}
+
+func TestElector_EveryRunsOnlyOnTheLeader(t *testing.T) {
+	backend := &storage.Local{Dir: t.TempDir()}
+	a, b := newTestElector(backend), newTestElector(backend)
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+	if ok, _ := a.Campaign(ctx); !ok {
+		t.Fatal("a not leader")
+	}
+	_, _ = b.Campaign(ctx)
+
+	var ranA, ranB atomic.Int32
+	go a.Every(ctx, time.Millisecond, func(context.Context) { ranA.Add(1) })
+	go b.Every(ctx, time.Millisecond, func(context.Context) { ranB.Add(1) })
+	deadline := time.Now().Add(time.Second)
+	for ranA.Load() < 3 && time.Now().Before(deadline) {
+		time.Sleep(time.Millisecond)
+	}
+	if ranA.Load() < 3 || ranB.Load() != 0 {
+		t.Fatalf("job runs: leader %d, follower %d", ranA.Load(), ranB.Load())
+	}
+}
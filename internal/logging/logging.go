@@ -0,0 +1,188 @@
+// Package logging sets up the structured logger of the server.
+//
+// Lines are written by log/slog, as text or as JSON for log aggregation
+// systems. Fields attached to a context with With (task ID, repository,
+// delivery ID, phase, request ID) are added to every line logged with that
+// context, and the standard library's log package is routed through the
+// same handler, so lines not yet converted keep the format and level.
+package logging
+
+import (
+	"context"
+	"fmt"
+	"io"
+	"log/slog"
+	"strings"
+)
+
+// The field names used across the server.
+const (
+	KeyTaskID     = "task_id"
+	KeyRepo       = "repo"
+	KeyDeliveryID = "delivery_id"
+	KeyPhase      = "phase"
+	KeyRequestID  = "request_id"
+)
+
+// Formats of the log output.
+const (
+	FormatText = "text"
+	FormatJSON = "json"
+)
+
+// level is the minimum level logged; SetLevel changes it at run time.
+var level = new(slog.LevelVar)
+
+// ParseLevel parses "debug", "info", "warn" or "error" (any case; empty is
+// info).
+func ParseLevel(s string) (slog.Level, error) {
+	switch strings.ToLower(strings.TrimSpace(s)) {
+	case "debug":
+		return slog.LevelDebug, nil
+	case "", "info":
+		return slog.LevelInfo, nil
+	case "warn", "warning":
+		return slog.LevelWarn, nil
+	case "error":
+		return slog.LevelError, nil
+	}
+	return 0, fmt.Errorf("unknown log level %q (want debug, info, warn or error)", s)
+}
+
+// ValidFormat reports whether format is "text" or "json".
+func ValidFormat(format string) bool {
+	return format == FormatText || format == FormatJSON
+}
+
+// NewHandler returns a handler writing lines of the given format to w at
+// the level set by SetLevel, with the fields of the logging context.
+func NewHandler(w io.Writer, format string) slog.Handler {
+	opts := &slog.HandlerOptions{Level: level}
+	if format == FormatJSON {
+		return contextHandler{slog.NewJSONHandler(w, opts)}
+	}
+	return contextHandler{slog.NewTextHandler(w, opts)}
+}
+
+// Setup makes a handler for w the default logger, for slog and for the log
+// package, logging from lvl up.
+func Setup(w io.Writer, lvl slog.Level, format string) {
+	level.Set(lvl)
+	slog.SetDefault(slog.New(NewHandler(w, format)))
+}
+
+// SetLevel changes the minimum level logged, e.g. on a configuration reload.
+func SetLevel(lvl slog.Level) {
+	level.Set(lvl)
+}
+
+// Level returns the minimum level logged.
+func Level() slog.Level {
+	return level.Level()
+}
+
+type fieldsKey struct{}
+
+type levelKey struct{}
+
+// WithLevel returns a copy of ctx whose lines are logged from lvl up, even
+// when the server logs less; a task run with --debug logs more this way.
+func WithLevel(ctx context.Context, lvl slog.Level) context.Context {
+	return context.WithValue(ctx, levelKey{}, lvl)
+}
+
+// With returns a copy of ctx whose log lines carry the given key-value
+// pairs, after those ctx already carries. A key set again replaces the
+// earlier value; pairs with an empty string value are left out.
+func With(ctx context.Context, args ...any) context.Context {
+	added := nonEmpty(args)
+	if len(added) == 0 {
+		return ctx
+	}
+	old := fields(ctx)
+	merged := make([]slog.Attr, 0, len(old)+len(added))
+	for _, a := range old {
+		if !hasKey(added, a.Key) {
+			merged = append(merged, a)
+		}
+	}
+	return context.WithValue(ctx, fieldsKey{}, append(merged, added...))
+}
+
+// Logger returns the default logger with the given key-value pairs, those
+// with an empty string value left out.
+func Logger(args ...any) *slog.Logger {
+	l := slog.Default()
+	for _, a := range nonEmpty(args) {
+		l = l.With(a)
+	}
+	return l
+}
+
+// nonEmpty turns args into attributes, dropping empty strings.
+func nonEmpty(args []any) []slog.Attr {
+	var attrs []slog.Attr
+	for _, a := range slog.Group("", args...).Value.Group() {
+		if a.Value.Kind() != slog.KindString || a.Value.String() != "" {
+			attrs = append(attrs, a)
+		}
+	}
+	return attrs
+}
+
+// Value returns the value of the field key attached to ctx, or "".
+func Value(ctx context.Context, key string) string {
+	for _, a := range fields(ctx) {
+		if a.Key == key {
+			return a.Value.String()
+		}
+	}
+	return ""
+}
+
+func fields(ctx context.Context) []slog.Attr {
+	if ctx == nil {
+		return nil
+	}
+	attrs, _ := ctx.Value(fieldsKey{}).([]slog.Attr)
+	return attrs
+}
+
+func hasKey(attrs []slog.Attr, key string) bool {
+	for _, a := range attrs {
+		if a.Key == key {
+			return true
+		}
+	}
+	return false
+}
+
+// contextHandler adds the fields of the logging context to each record.
+type contextHandler struct {
+	slog.Handler
+}
+
+func (h contextHandler) Enabled(ctx context.Context, lvl slog.Level) bool {
+	if ctx != nil {
+		if min, ok := ctx.Value(levelKey{}).(slog.Level); ok && lvl >= min {
+			return true
+		}
+	}
+	return h.Handler.Enabled(ctx, lvl)
+}
+
+func (h contextHandler) Handle(ctx context.Context, r slog.Record) error {
+	if attrs := fields(ctx); len(attrs) > 0 {
+		r = r.Clone()
+		r.AddAttrs(attrs...)
+	}
+	return h.Handler.Handle(ctx, r)
+}
+
+func (h contextHandler) WithAttrs(attrs []slog.Attr) slog.Handler {
+	return contextHandler{h.Handler.WithAttrs(attrs)}
+}
+
+func (h contextHandler) WithGroup(name string) slog.Handler {
+	return contextHandler{h.Handler.WithGroup(name)}
+}
@@ -0,0 +1,108 @@
+package logging
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"log"
+	"log/slog"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"strings"
+	"testing"
+)
+
+func setupTest(t *testing.T, format string, lvl slog.Level) *bytes.Buffer {
+	t.Helper()
+	prev, prevLevel := slog.Default(), Level()
+	t.Cleanup(func() {
+		slog.SetDefault(prev)
+		SetLevel(prevLevel)
+		log.SetOutput(os.Stderr)
+		log.SetFlags(log.LstdFlags)
+	})
+	var buf bytes.Buffer
+	Setup(&buf, lvl, format)
+	return &buf
+}
+
+func TestJSONCarriesContextFields(t *testing.T) {
+	buf := setupTest(t, FormatJSON, slog.LevelInfo)
+	ctx := With(context.Background(), KeyTaskID, "t-1", KeyRepo, "acme/widgets")
+	ctx = With(ctx, KeyPhase, "clone", KeyRepo, "acme/gadgets")
+	slog.InfoContext(ctx, "cloned", "files", 3)
+
+	var line map[string]any
+	if err := json.Unmarshal(buf.Bytes(), &line); err != nil {
+		t.Fatalf("not JSON: %v\n%s", err, buf)
+	}
+	want := map[string]any{"msg": "cloned", "level": "INFO", "task_id": "t-1", "repo": "acme/gadgets", "phase": "clone", "files": float64(3)}
+	for k, v := range want {
+		if line[k] != v {
+			t.Errorf("%s = %v, want %v", k, line[k], v)
+		}
+	}
+	if Value(ctx, KeyRepo) != "acme/gadgets" || Value(ctx, KeyDeliveryID) != "" {
+		t.Fatalf("Value: repo %q", Value(ctx, KeyRepo))
+	}
+}
+
+func TestLevelAndLogBridge(t *testing.T) {
+	buf := setupTest(t, FormatText, slog.LevelWarn)
+	slog.Info("hidden")
+	log.Printf("[Legacy] also hidden")
+	SetLevel(slog.LevelInfo)
+	log.Printf("[Legacy] shown")
+	slog.Debug("hidden too")
+
+	out := buf.String()
+	if strings.Contains(out, "hidden") || !strings.Contains(out, `level=INFO msg="[Legacy] shown"`) {
+		t.Fatalf("output:\n%s", out)
+	}
+}
+
+func TestWithLevel(t *testing.T) {
+	buf := setupTest(t, FormatText, slog.LevelInfo)
+	slog.DebugContext(context.Background(), "hidden")
+	ctx := WithLevel(With(context.Background(), KeyTaskID, "t-1"), slog.LevelDebug)
+	slog.DebugContext(ctx, "shown")
+
+	out := buf.String()
+	if strings.Contains(out, "hidden") || !strings.Contains(out, `level=DEBUG msg=shown task_id=t-1`) {
+		t.Fatalf("output:\n%s", out)
+	}
+}
+
+func TestParseLevel(t *testing.T) {
+	for in, want := range map[string]slog.Level{"": slog.LevelInfo, "DEBUG": slog.LevelDebug, "warning": slog.LevelWarn, "error": slog.LevelError} {
+		if got, err := ParseLevel(in); err != nil || got != want {
+			t.Errorf("ParseLevel(%q) = %v, %v", in, got, err)
+		}
+	}
+	if _, err := ParseLevel("verbose"); err == nil {
+		t.Error("verbose should be rejected")
+	}
+}
+
+func TestMiddleware(t *testing.T) {
+	var got context.Context
+	h := Middleware(http.HandlerFunc(func(_ http.ResponseWriter, r *http.Request) { got = r.Context() }))
+
+	r := httptest.NewRequest(http.MethodPost, "/webhook", nil)
+	r.Header.Set(RequestIDHeader, "req-42")
+	r.Header.Set("X-GitHub-Delivery", "d-7")
+	w := httptest.NewRecorder()
+	h.ServeHTTP(w, r)
+	if Value(got, KeyRequestID) != "req-42" || Value(got, KeyDeliveryID) != "d-7" || w.Header().Get(RequestIDHeader) != "req-42" {
+		t.Fatalf("fields: request %q, delivery %q", Value(got, KeyRequestID), Value(got, KeyDeliveryID))
+	}
+
+	r = httptest.NewRequest(http.MethodGet, "/tasks", nil)
+	r.Header.Set(RequestIDHeader, "bad id\n")
+	w = httptest.NewRecorder()
+	h.ServeHTTP(w, r)
+	if id := Value(got, KeyRequestID); len(id) != 16 || id != w.Header().Get(RequestIDHeader) {
+		t.Fatalf("generated request ID %q", id)
+	}
+}
@@ -0,0 +1,39 @@
+package logging
+
+import (
+	"crypto/rand"
+	"encoding/hex"
+	"net/http"
+	"regexp"
+)
+
+// RequestIDHeader carries the request ID, taken from the caller when valid
+// and echoed in the response.
+const RequestIDHeader = "X-Request-ID"
+
+// validRequestID keeps IDs from callers short and printable.
+var validRequestID = regexp.MustCompile(`^[A-Za-z0-9._:-]{1,128}$`)
+
+// Middleware attaches a request ID and, for GitHub deliveries, the delivery
+// ID to the context of each request, so every line logged while handling it
+// carries them.
+func Middleware(next http.Handler) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		id := r.Header.Get(RequestIDHeader)
+		if !validRequestID.MatchString(id) {
+			id = newRequestID()
+		}
+		w.Header().Set(RequestIDHeader, id)
+		args := []any{KeyRequestID, id}
+		if delivery := r.Header.Get("X-GitHub-Delivery"); delivery != "" {
+			args = append(args, KeyDeliveryID, delivery)
+		}
+		next.ServeHTTP(w, r.WithContext(With(r.Context(), args...)))
+	})
+}
+
+func newRequestID() string {
+	b := make([]byte, 8)
+	_, _ = rand.Read(b)
+	return hex.EncodeToString(b)
+}
@@ -0,0 +1,379 @@
+// Package miniyaml parses the subset of YAML found in config files and
+// GitHub Actions workflows, without a dependency: block mappings and
+// sequences (including "- key: value" items and sequences indented level
+// with their key), plain and quoted scalars that may span lines, `|`/`>`
+// block scalars, flow collections, and comments. Anchors, tags and multiple
+// documents are not interpreted.
+package miniyaml
+
+import (
+	"fmt"
+	"strconv"
+	"strings"
+)
+
+// Kind is what a Node holds.
+type Kind int
+
+const (
+	// Scalar is a plain, quoted or block scalar; Value holds it unquoted.
+	// An empty value, null and ~ read as "".
+	Scalar Kind = iota
+	// Mapping holds Pairs in the order written.
+	Mapping
+	// Sequence holds Items.
+	Sequence
+	// Flow is a `[...]` or `{...}` flow collection; Value holds its text,
+	// for the caller to decode.
+	Flow
+)
+
+// Node is a parsed value. Line is the 1-based line it starts on; for the
+// value of a key, the line of the key.
+type Node struct {
+	Kind  Kind
+	Value string
+	Pairs []Pair
+	Items []*Node
+	Line  int
+}
+
+// Pair is one key of a Mapping and its value.
+type Pair struct {
+	Key   string
+	Value *Node
+}
+
+// Get returns the value of key in a Mapping, or nil.
+func (n *Node) Get(key string) *Node {
+	if n == nil || n.Kind != Mapping {
+		return nil
+	}
+	for _, p := range n.Pairs {
+		if p.Key == key {
+			return p.Value
+		}
+	}
+	return nil
+}
+
+// Parse parses data. An empty document is an empty Mapping. Errors name
+// the line they were found on.
+func Parse(data []byte) (*Node, error) {
+	p := &parser{lines: strings.Split(strings.ReplaceAll(string(data), "\r\n", "\n"), "\n")}
+	indent, _, ok, err := p.peek()
+	if err != nil {
+		return nil, err
+	}
+	if !ok {
+		return &Node{Kind: Mapping, Line: 1}, nil
+	}
+	root, err := p.node(indent)
+	if err != nil {
+		return nil, err
+	}
+	if _, _, ok, err := p.peek(); err != nil {
+		return nil, err
+	} else if ok {
+		return nil, fmt.Errorf("line %d: unexpected indentation", p.i+1)
+	}
+	return root, nil
+}
+
+type parser struct {
+	lines []string
+	i     int // the next line to read
+}
+
+// peek skips blank and comment lines and document markers, and returns the
+// indentation and content of the next line without its comment.
+func (p *parser) peek() (indent int, content string, ok bool, err error) {
+	for ; p.i < len(p.lines); p.i++ {
+		text := stripComment(p.lines[p.i])
+		content = strings.TrimLeft(text, " ")
+		if strings.TrimSpace(content) == "" {
+			continue
+		}
+		if content[0] == '\t' {
+			return 0, "", false, fmt.Errorf("line %d: tabs are not allowed for indentation", p.i+1)
+		}
+		indent = len(text) - len(content)
+		if indent == 0 && content == "---" {
+			continue
+		}
+		return indent, content, true, nil
+	}
+	return 0, "", false, nil
+}
+
+// node parses the block mapping or sequence whose lines start at indent.
+func (p *parser) node(indent int) (*Node, error) {
+	_, content, _, _ := p.peek()
+	if isItem(content) {
+		return p.sequence(indent)
+	}
+	return p.mapping(indent)
+}
+
+func (p *parser) mapping(indent int) (*Node, error) {
+	n := &Node{Kind: Mapping, Line: p.i + 1}
+	seen := make(map[string]bool)
+	for {
+		ind, content, ok, err := p.peek()
+		if err != nil {
+			return nil, err
+		}
+		if !ok || ind < indent {
+			return n, nil
+		}
+		line := p.i + 1
+		switch {
+		case ind > indent:
+			return nil, fmt.Errorf("line %d: unexpected indentation", line)
+		case isItem(content):
+			if len(n.Pairs) == 0 {
+				return nil, fmt.Errorf("line %d: list item without a key", line)
+			}
+			return nil, fmt.Errorf("line %d: mapping mixed with list items", line)
+		}
+		key, rest, ok := splitKey(content)
+		if !ok {
+			return nil, fmt.Errorf("line %d: expected \"key: value\"", line)
+		}
+		if seen[key] {
+			return nil, fmt.Errorf("line %d: duplicate key %q", line, key)
+		}
+		seen[key] = true
+		p.i++
+		value, err := p.value(indent, rest, line, true)
+		if err != nil {
+			return nil, err
+		}
+		n.Pairs = append(n.Pairs, Pair{Key: key, Value: value})
+	}
+}
+
+func (p *parser) sequence(indent int) (*Node, error) {
+	n := &Node{Kind: Sequence, Line: p.i + 1}
+	for {
+		ind, content, ok, err := p.peek()
+		if err != nil {
+			return nil, err
+		}
+		if !ok || ind != indent || !isItem(content) {
+			return n, nil
+		}
+		line := p.i + 1
+		rest := strings.TrimLeft(content[1:], " ")
+		// an item holding a mapping or sequence reads as if its dash were
+		// indentation
+		column := indent + len(content) - len(rest)
+		if _, _, isKey := splitKey(rest); isKey || isItem(rest) {
+			raw := p.lines[p.i]
+			p.lines[p.i] = raw[:indent] + " " + raw[indent+1:]
+			item, err := p.node(column)
+			if err != nil {
+				return nil, err
+			}
+			n.Items = append(n.Items, item)
+			continue
+		}
+		p.i++
+		item, err := p.value(indent, rest, line, false)
+		if err != nil {
+			return nil, err
+		}
+		n.Items = append(n.Items, item)
+	}
+}
+
+// value parses what follows a key, or a dash, written at column owner:
+// rest of the line and any lines indented deeper. compact lets a sequence
+// start level with a key.
+func (p *parser) value(owner int, rest string, line int, compact bool) (*Node, error) {
+	switch {
+	case rest == "":
+		ind, content, ok, err := p.peek()
+		if err != nil {
+			return nil, err
+		}
+		var n *Node
+		switch {
+		case ok && ind > owner:
+			n, err = p.node(ind)
+		case ok && compact && ind == owner && isItem(content):
+			n, err = p.sequence(ind)
+		default:
+			n = &Node{Kind: Scalar}
+		}
+		if err != nil {
+			return nil, err
+		}
+		n.Line = line
+		return n, nil
+	case rest[0] == '|' || rest[0] == '>':
+		return &Node{Kind: Scalar, Value: p.blockScalar(owner, rest), Line: line}, nil
+	}
+
+	text, err := p.continued(owner, rest)
+	if err != nil {
+		return nil, err
+	}
+	if text[0] == '[' || text[0] == '{' {
+		return &Node{Kind: Flow, Value: text, Line: line}, nil
+	}
+	value, err := unquote(text)
+	if err != nil {
+		return nil, fmt.Errorf("line %d: %w", line, err)
+	}
+	return &Node{Kind: Scalar, Value: value, Line: line}, nil
+}
+
+// continued joins rest with the lines indented deeper than owner that
+// continue it, with spaces. A plain scalar cannot continue with a key.
+func (p *parser) continued(owner int, rest string) (string, error) {
+	parts := []string{rest}
+	plain := !isQuoted(rest) && rest[0] != '[' && rest[0] != '{'
+	for {
+		ind, content, ok, err := p.peek()
+		if err != nil {
+			return "", err
+		}
+		if !ok || ind <= owner {
+			return strings.Join(parts, " "), nil
+		}
+		if _, _, isKey := splitKey(content); plain && isKey {
+			return "", fmt.Errorf("line %d: unexpected indentation", p.i+1)
+		}
+		parts = append(parts, strings.TrimSpace(content))
+		p.i++
+	}
+}
+
+// blockScalar reads the lines of a `|` or `>` block scalar whose key or dash
+// is at column owner. Comments inside it are part of the text.
+func (p *parser) blockScalar(owner int, header string) string {
+	var (
+		body        []string
+		blockIndent = -1
+	)
+	for ; p.i < len(p.lines); p.i++ {
+		line := p.lines[p.i]
+		trimmed := strings.TrimLeft(line, " ")
+		if strings.TrimSpace(line) == "" {
+			body = append(body, "")
+			continue
+		}
+		indent := len(line) - len(trimmed)
+		if indent <= owner {
+			break
+		}
+		if blockIndent < 0 {
+			blockIndent = indent
+		}
+		if indent >= blockIndent {
+			line = line[blockIndent:]
+		} else {
+			line = trimmed
+		}
+		body = append(body, line)
+	}
+	for len(body) > 0 && body[len(body)-1] == "" {
+		body = body[:len(body)-1]
+	}
+
+	sep := "\n"
+	if header[0] == '>' {
+		sep = " "
+	}
+	value := strings.Join(body, sep)
+	if value != "" && !strings.HasSuffix(header, "-") {
+		value += "\n"
+	}
+	return value
+}
+
+func isItem(content string) bool {
+	return content == "-" || strings.HasPrefix(content, "- ")
+}
+
+// splitKey splits "key: value" (or "key:") into its parts. The key may be
+// quoted.
+func splitKey(content string) (key, rest string, ok bool) {
+	after := ""
+	if isQuoted(content) {
+		end := strings.IndexByte(content[1:], content[0]) + 1
+		if end == 0 {
+			return "", "", false
+		}
+		k, err := unquote(content[:end+1])
+		if err != nil {
+			return "", "", false
+		}
+		key, after = k, content[end+1:]
+	} else {
+		i := strings.Index(content, ": ")
+		switch {
+		case i >= 0:
+			key, after = content[:i], content[i:]
+		case strings.HasSuffix(content, ":"):
+			key, after = content[:len(content)-1], ":"
+		default:
+			return "", "", false
+		}
+		key = strings.TrimSpace(key)
+		if key == "" || strings.ContainsAny(key, "\t\"'") || key[0] == '[' || key[0] == '{' {
+			return "", "", false
+		}
+	}
+	if after != ":" && !strings.HasPrefix(after, ": ") {
+		return "", "", false
+	}
+	return key, strings.TrimSpace(after[1:]), true
+}
+
+func isQuoted(s string) bool {
+	return strings.HasPrefix(s, "\"") || strings.HasPrefix(s, "'")
+}
+
+// unquote returns the value of a plain or quoted scalar; null and ~ read
+// as "".
+func unquote(s string) (string, error) {
+	switch {
+	case strings.HasPrefix(s, "\""):
+		v, err := strconv.Unquote(s)
+		if err != nil {
+			return "", fmt.Errorf("invalid double-quoted string %s", s)
+		}
+		return v, nil
+	case strings.HasPrefix(s, "'"):
+		if len(s) < 2 || !strings.HasSuffix(s, "'") {
+			return "", fmt.Errorf("unterminated single-quoted string %s", s)
+		}
+		return strings.ReplaceAll(s[1:len(s)-1], "''", "'"), nil
+	case s == "~" || s == "null":
+		return "", nil
+	}
+	return s, nil
+}
+
+// stripComment removes a trailing `# comment` outside of quotes.
+func stripComment(line string) string {
+	var quote byte
+	for i := 0; i < len(line); i++ {
+		c := line[i]
+		switch {
+		case quote != 0:
+			if c == '\\' && quote == '"' {
+				i++
+			} else if c == quote {
+				quote = 0
+			}
+		case (c == '"' || c == '\'') && (i == 0 || line[i-1] == ' '):
+			quote = c
+		case c == '#' && (i == 0 || line[i-1] == ' ' || line[i-1] == '\t'):
+			return strings.TrimRight(line[:i], " \t")
+		}
+	}
+	return strings.TrimRight(line, " \t")
+}
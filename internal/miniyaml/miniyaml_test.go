@@ -0,0 +1,93 @@
+package miniyaml
+
+import (
+	"strings"
+	"testing"
+)
+
+func TestParse(t *testing.T) {
+	doc, err := Parse([]byte(`# a workflow
+"on": [push]
+jobs:
+  test:
+    if: contains(github.event.comment.body, '/claude')
+    steps:
+    - uses: actions/checkout@v4
+    - name: Run
+      with:
+        prompt: |
+          Keep going.
+          # not a comment
+        args: >-
+          --one
+          --two
+        title: a long title
+          continued here
+        quoted: 'it''s' # a comment
+        empty: ~
+      uses: "acme/action@v1"
+    needs:
+      - build
+      - - nested
+`))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if on := doc.Get("on"); on == nil || on.Kind != Flow || on.Value != "[push]" || on.Line != 2 {
+		t.Fatalf("on = %+v", on)
+	}
+	job := doc.Get("jobs").Get("test")
+	if got := job.Get("if").Value; got != "contains(github.event.comment.body, '/claude')" {
+		t.Fatalf("if = %q", got)
+	}
+	steps := job.Get("steps")
+	if steps.Kind != Sequence || len(steps.Items) != 2 || steps.Items[0].Get("uses").Value != "actions/checkout@v4" {
+		t.Fatalf("steps = %+v", steps)
+	}
+	step := steps.Items[1]
+	if step.Get("uses").Value != "acme/action@v1" {
+		t.Fatalf("step = %+v", step)
+	}
+	with := step.Get("with")
+	for key, want := range map[string]string{
+		"prompt": "Keep going.\n# not a comment\n",
+		"args":   "--one --two",
+		"title":  "a long title continued here",
+		"quoted": "it's",
+		"empty":  "",
+	} {
+		if v := with.Get(key); v == nil || v.Kind != Scalar || v.Value != want {
+			t.Errorf("%s = %+v, want %q", key, v, want)
+		}
+	}
+	if v := with.Get("title"); v.Line != 16 {
+		t.Errorf("title on line %d, want 16", v.Line)
+	}
+	needs := job.Get("needs")
+	if len(needs.Items) != 2 || needs.Items[0].Value != "build" || needs.Items[1].Kind != Sequence || needs.Items[1].Items[0].Value != "nested" {
+		t.Fatalf("needs = %+v", needs)
+	}
+
+	if doc, err := Parse([]byte("# nothing\n")); err != nil || doc.Kind != Mapping || len(doc.Pairs) != 0 {
+		t.Fatalf("empty document = %+v, %v", doc, err)
+	}
+}
+
+func TestParse_Errors(t *testing.T) {
+	tests := map[string]string{
+		"tab indent":     "github:\n\tapp_id: 1\n",
+		"duplicate key":  "port: 1\nport: 2\n",
+		"missing colon":  "port 8000\n",
+		"mixed":          "a:\n  - x\n  b: y\n",
+		"orphan item":    "a:\n  b: 1\n  - x\n",
+		"deeper":         "a: 1\nb:\n  c: 1\n    d: 2\n",
+		"bad quoted str": "api_token: \"abc\n",
+	}
+	for name, content := range tests {
+		t.Run(name, func(t *testing.T) {
+			if _, err := Parse([]byte(content)); err == nil || !strings.HasPrefix(err.Error(), "line ") {
+				t.Fatalf("expected a line-numbered error, got %v", err)
+			}
+		})
+	}
+}
@@ -28,6 +28,8 @@ func (m *Mode) Prepare(ctx context.Context, ghCtx *ghpkg.Context) (*modes.Prepar
 
 	// 2. 创建简单的初始协调评论（即时反馈）
 	tracker := comment.NewTracker(client, ghCtx.Repository.Owner, ghCtx.Repository.Name, ghCtx.IssueNumber)
+	tracker.SetLang(comment.Lang(ghCtx.PreparedLang))
+	tracker.SetHistory(ghCtx.PreparedCommentHistory)
 	commentID, err := tracker.CreateInitial(ctx)
 	if err != nil {
 		return nil, fmt.Errorf("failed to create initial comment: %w", err)
@@ -41,6 +41,18 @@ func GetCommandMode() Mode {
 	return mode
 }
 
+// GetReleaseMode 获取 Release 模式（便捷方法）
+func GetReleaseMode() Mode {
+	mode, _ := Get("release")
+	return mode
+}
+
+// GetTriageMode 获取 Triage 模式（便捷方法）
+func GetTriageMode() Mode {
+	mode, _ := Get("triage")
+	return mode
+}
+
 // init 初始化，注册默认模式
 func init() {
 	// Command 模式在 command 包中自动注册
@@ -0,0 +1,48 @@
+package release
+
+import (
+	"context"
+	"fmt"
+	"strings"
+
+	ghpkg "github.com/cexll/swe/internal/github"
+	"github.com/cexll/swe/internal/github/comment"
+	"github.com/cexll/swe/internal/modes"
+)
+
+// Mode 实现 Release 模式（/release 命令触发，确认后执行）
+type Mode struct{}
+
+// Name 返回模式名称
+func (m *Mode) Name() string { return "release" }
+
+// ShouldTrigger 检测是否包含 /release 命令
+func (m *Mode) ShouldTrigger(ctx *ghpkg.Context) bool {
+	return strings.Contains(strings.ToLower(ctx.GetTriggerCommentBody()), "/release")
+}
+
+// Prepare creates the tracking comment; releases are always cut from the
+// repository's default branch.
+func (m *Mode) Prepare(ctx context.Context, ghCtx *ghpkg.Context) (*modes.PrepareResult, error) {
+	client := ghCtx.NewGitHubClient()
+	tracker := comment.NewTracker(client, ghCtx.Repository.Owner, ghCtx.Repository.Name, ghCtx.IssueNumber)
+	tracker.SetLang(comment.Lang(ghCtx.PreparedLang))
+	commentID, err := tracker.CreateInitial(ctx)
+	if err != nil {
+		return nil, fmt.Errorf("failed to create initial comment: %w", err)
+	}
+
+	base := ghCtx.GetRepositoryDefaultBranch()
+	if strings.TrimSpace(base) == "" {
+		base = ghCtx.GetBaseBranch()
+	}
+	return &modes.PrepareResult{
+		CommentID:  commentID,
+		BaseBranch: base,
+	}, nil
+}
+
+// init 自动注册 Release 模式
+func init() {
+	modes.Register(&Mode{})
+}
@@ -0,0 +1,48 @@
+package triage
+
+import (
+	"context"
+	"fmt"
+	"strings"
+
+	ghpkg "github.com/cexll/swe/internal/github"
+	"github.com/cexll/swe/internal/github/comment"
+	"github.com/cexll/swe/internal/modes"
+)
+
+// Mode 实现 Triage 模式（/triage 命令触发）
+type Mode struct{}
+
+// Name 返回模式名称
+func (m *Mode) Name() string { return "triage" }
+
+// ShouldTrigger 检测是否包含 /triage 命令
+func (m *Mode) ShouldTrigger(ctx *ghpkg.Context) bool {
+	return strings.Contains(strings.ToLower(ctx.GetTriggerCommentBody()), "/triage")
+}
+
+// Prepare creates the tracking comment; triage reads the default branch and
+// pushes nothing.
+func (m *Mode) Prepare(ctx context.Context, ghCtx *ghpkg.Context) (*modes.PrepareResult, error) {
+	client := ghCtx.NewGitHubClient()
+	tracker := comment.NewTracker(client, ghCtx.Repository.Owner, ghCtx.Repository.Name, ghCtx.IssueNumber)
+	tracker.SetLang(comment.Lang(ghCtx.PreparedLang))
+	commentID, err := tracker.CreateInitial(ctx)
+	if err != nil {
+		return nil, fmt.Errorf("failed to create initial comment: %w", err)
+	}
+
+	base := ghCtx.GetRepositoryDefaultBranch()
+	if strings.TrimSpace(base) == "" {
+		base = ghCtx.GetBaseBranch()
+	}
+	return &modes.PrepareResult{
+		CommentID:  commentID,
+		BaseBranch: base,
+	}, nil
+}
+
+// init 自动注册 Triage 模式
+func init() {
+	modes.Register(&Mode{})
+}
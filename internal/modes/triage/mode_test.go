@@ -0,0 +1,45 @@
+package triage
+
+import (
+	"context"
+	"testing"
+
+	gh "github.com/google/go-github/v66/github"
+
+	ghpkg "github.com/cexll/swe/internal/github"
+	ghtesting "github.com/cexll/swe/internal/github/testing"
+	"github.com/cexll/swe/internal/modes"
+)
+
+func TestRegisteredAndTrigger(t *testing.T) {
+	if m := modes.GetTriageMode(); m == nil || m.Name() != "triage" {
+		t.Fatalf("triage mode not registered: %v", m)
+	}
+	m := &Mode{}
+	if !m.ShouldTrigger(&ghpkg.Context{TriggerComment: &ghpkg.Comment{Body: "/Triage please"}}) {
+		t.Fatal("ShouldTrigger should detect /triage")
+	}
+	if m.ShouldTrigger(&ghpkg.Context{TriggerComment: &ghpkg.Comment{Body: "/code fix"}}) {
+		t.Fatal("ShouldTrigger should ignore other commands")
+	}
+}
+
+func TestPrepare_UsesDefaultBranch(t *testing.T) {
+	client, cleanup := ghtesting.NewMockGitHubClient()
+	defer cleanup()
+	ghpkg.SetGitHubClientFactory(func(string) *gh.Client { return client })
+	defer ghpkg.SetGitHubClientFactory(nil)
+
+	ctx := &ghpkg.Context{
+		Repository:  ghpkg.Repository{Owner: "owner", Name: "repo", FullName: "owner/repo", DefaultBranch: "trunk"},
+		IssueNumber: 3,
+		BaseBranch:  "main",
+	}
+	res, err := (&Mode{}).Prepare(context.Background(), ctx)
+	if err != nil {
+		t.Fatalf("Prepare: %v", err)
+	}
+	if res.CommentID != 123456 || res.BaseBranch != "trunk" || res.Branch != "" {
+		t.Fatalf("unexpected result: %+v", res)
+	}
+}
@@ -0,0 +1,161 @@
+package notify
+
+import (
+	"encoding/json"
+	"fmt"
+	"strings"
+)
+
+// Endpoint is the configuration form of a Route.
+type Endpoint struct {
+	Type   string      `json:"type"` // slack, discord or webhook
+	URL    string      `json:"url"`
+	Events []EventType `json:"events,omitempty"`
+}
+
+// Config lists global endpoints and per-repository overrides (keyed by owner/repo).
+type Config struct {
+	Global []Endpoint
+	Repos  map[string][]Endpoint
+	// Email is a global SMTP channel; nil disables it.
+	Email *EmailConfig
+}
+
+// New builds a Manager from cfg. It returns nil when nothing is configured.
+func New(cfg Config) (*Manager, error) {
+	if len(cfg.Global) == 0 && len(cfg.Repos) == 0 && cfg.Email == nil {
+		return nil, nil
+	}
+	m := NewManager()
+	if err := m.Update(cfg); err != nil {
+		return nil, err
+	}
+	return m, nil
+}
+
+// Update atomically replaces all routes with those built from cfg. On error
+// the existing routes are kept. Deliveries already in flight are unaffected;
+// batching notifiers that are replaced are flushed.
+func (m *Manager) Update(cfg Config) error {
+	global, err := routes(cfg.Global)
+	if err != nil {
+		return err
+	}
+	var email *Route
+	if cfg.Email != nil {
+		if err := cfg.Email.validate(); err != nil {
+			return err
+		}
+		email = &Route{Notifier: NewEmailNotifier(*cfg.Email), Events: cfg.Email.Events}
+		global = append(global, *email)
+	}
+	perRepo := make(map[string][]Route, len(cfg.Repos))
+	for repo, endpoints := range cfg.Repos {
+		r, err := routes(endpoints)
+		if err != nil {
+			return fmt.Errorf("repo %s: %w", repo, err)
+		}
+		// Failure emails go to operators regardless of per-repo chat routing
+		if email != nil {
+			r = append(r, *email)
+		}
+		perRepo[strings.ToLower(repo)] = r
+	}
+
+	m.mu.Lock()
+	old := m.allRoutes()
+	m.global, m.perRepo = global, perRepo
+	m.mu.Unlock()
+
+	flushRoutes(old)
+	return nil
+}
+
+// ParseRepoEndpoints decodes the per-repository JSON form:
+//
+//	{"owner/repo": [{"type": "slack", "url": "https://...", "events": ["failed"]}]}
+func ParseRepoEndpoints(raw string) (map[string][]Endpoint, error) {
+	if strings.TrimSpace(raw) == "" {
+		return nil, nil
+	}
+	var repos map[string][]Endpoint
+	if err := json.Unmarshal([]byte(raw), &repos); err != nil {
+		return nil, fmt.Errorf("parse repository endpoints: %w", err)
+	}
+	for repo, endpoints := range repos {
+		if _, err := routes(endpoints); err != nil {
+			return nil, fmt.Errorf("repo %s: %w", repo, err)
+		}
+	}
+	return repos, nil
+}
+
+// ParseEvents parses a comma-separated event list. Empty means all events.
+func ParseEvents(raw string) ([]EventType, error) {
+	var events []EventType
+	for _, part := range strings.Split(raw, ",") {
+		part = strings.ToLower(strings.TrimSpace(part))
+		if part == "" {
+			continue
+		}
+		ev := EventType(part)
+		if !validEvent(ev) {
+			return nil, fmt.Errorf("unknown notification event: %s", part)
+		}
+		events = append(events, ev)
+	}
+	return events, nil
+}
+
+func (c *EmailConfig) validate() error {
+	if c.Host == "" {
+		return fmt.Errorf("email notifier requires an SMTP host")
+	}
+	if c.From == "" {
+		return fmt.Errorf("email notifier requires a from address")
+	}
+	if len(c.To) == 0 {
+		return fmt.Errorf("email notifier requires at least one recipient")
+	}
+	for _, ev := range c.Events {
+		if !validEvent(ev) {
+			return fmt.Errorf("unknown notification event: %s", ev)
+		}
+	}
+	return nil
+}
+
+func validEvent(ev EventType) bool {
+	switch ev {
+	case EventQueued, EventCompleted, EventFailed, EventDeadLettered:
+		return true
+	}
+	return false
+}
+
+func routes(endpoints []Endpoint) ([]Route, error) {
+	out := make([]Route, 0, len(endpoints))
+	for _, ep := range endpoints {
+		if ep.URL == "" {
+			return nil, fmt.Errorf("%s endpoint is missing url", ep.Type)
+		}
+		for _, ev := range ep.Events {
+			if !validEvent(ev) {
+				return nil, fmt.Errorf("unknown notification event: %s", ev)
+			}
+		}
+		var n Notifier
+		switch strings.ToLower(ep.Type) {
+		case "slack":
+			n = &SlackNotifier{URL: ep.URL}
+		case "discord":
+			n = &DiscordNotifier{URL: ep.URL}
+		case "webhook":
+			n = &WebhookNotifier{URL: ep.URL}
+		default:
+			return nil, fmt.Errorf("unknown notifier type: %q (must be slack, discord or webhook)", ep.Type)
+		}
+		out = append(out, Route{Notifier: n, Events: ep.Events})
+	}
+	return out, nil
+}
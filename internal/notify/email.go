@@ -0,0 +1,135 @@
+package notify
+
+import (
+	"bytes"
+	"context"
+	"fmt"
+	"net"
+	"net/smtp"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+)
+
+// EmailConfig configures the SMTP notifier.
+type EmailConfig struct {
+	Host     string
+	Port     int
+	Username string
+	Password string
+	From     string
+	To       []string
+	Events   []EventType
+	// Digest batches events into one email per interval. Zero sends immediately.
+	Digest time.Duration
+}
+
+// EmailNotifier emails events over SMTP, optionally batching them into a digest.
+type EmailNotifier struct {
+	cfg EmailConfig
+	// allow tests to stub delivery
+	send func(addr string, a smtp.Auth, from string, to []string, msg []byte) error
+
+	mu      sync.Mutex
+	pending []Event
+	timer   *time.Timer
+}
+
+// NewEmailNotifier creates an SMTP notifier.
+func NewEmailNotifier(cfg EmailConfig) *EmailNotifier {
+	if cfg.Port == 0 {
+		cfg.Port = 587
+	}
+	return &EmailNotifier{cfg: cfg, send: smtp.SendMail}
+}
+
+func (e *EmailNotifier) Name() string { return "email" }
+
+func (e *EmailNotifier) Notify(_ context.Context, ev Event) error {
+	if e.cfg.Digest <= 0 {
+		return e.deliver([]Event{ev})
+	}
+
+	e.mu.Lock()
+	defer e.mu.Unlock()
+	e.pending = append(e.pending, ev)
+	if e.timer == nil {
+		e.timer = time.AfterFunc(e.cfg.Digest, func() { _ = e.Flush() })
+	}
+	return nil
+}
+
+// Flush sends any batched events now.
+func (e *EmailNotifier) Flush() error {
+	e.mu.Lock()
+	events := e.pending
+	e.pending = nil
+	if e.timer != nil {
+		e.timer.Stop()
+		e.timer = nil
+	}
+	e.mu.Unlock()
+
+	if len(events) == 0 {
+		return nil
+	}
+	return e.deliver(events)
+}
+
+func (e *EmailNotifier) deliver(events []Event) error {
+	var auth smtp.Auth
+	if e.cfg.Username != "" {
+		auth = smtp.PlainAuth("", e.cfg.Username, e.cfg.Password, e.cfg.Host)
+	}
+	addr := net.JoinHostPort(e.cfg.Host, strconv.Itoa(e.cfg.Port))
+	if err := e.send(addr, auth, e.cfg.From, e.cfg.To, e.message(events)); err != nil {
+		return fmt.Errorf("send email: %w", err)
+	}
+	return nil
+}
+
+func (e *EmailNotifier) message(events []Event) []byte {
+	var subject string
+	if len(events) == 1 {
+		ev := events[0]
+		subject = fmt.Sprintf("[swe-agent] Task %s: %s#%d", strings.ReplaceAll(string(ev.Type), "_", " "), ev.Repo, ev.Number)
+	} else {
+		subject = fmt.Sprintf("[swe-agent] %d task failures in the last %s", len(events), e.cfg.Digest)
+	}
+
+	var body bytes.Buffer
+	for i, ev := range events {
+		if i > 0 {
+			body.WriteString("\r\n----\r\n\r\n")
+		}
+		fmt.Fprintf(&body, "Event:  %s\r\n", ev.Type)
+		fmt.Fprintf(&body, "Repo:   %s#%d\r\n", ev.Repo, ev.Number)
+		if u := ev.URL(); u != "" {
+			fmt.Fprintf(&body, "Link:   %s\r\n", u)
+		}
+		if ev.TaskID != "" {
+			fmt.Fprintf(&body, "Task:   %s\r\n", ev.TaskID)
+		}
+		if ev.Actor != "" {
+			fmt.Fprintf(&body, "Actor:  @%s\r\n", ev.Actor)
+		}
+		fmt.Fprintf(&body, "Time:   %s\r\n", ev.Timestamp.UTC().Format(time.RFC3339))
+		if ev.CostUSD > 0 {
+			fmt.Fprintf(&body, "Cost:   $%.4f\r\n", ev.CostUSD)
+		}
+		if ev.Error != "" {
+			fmt.Fprintf(&body, "\r\n%s\r\n", strings.ReplaceAll(ev.Error, "\n", "\r\n"))
+		}
+	}
+
+	var msg bytes.Buffer
+	fmt.Fprintf(&msg, "From: %s\r\n", e.cfg.From)
+	fmt.Fprintf(&msg, "To: %s\r\n", strings.Join(e.cfg.To, ", "))
+	fmt.Fprintf(&msg, "Subject: %s\r\n", subject)
+	fmt.Fprintf(&msg, "Date: %s\r\n", time.Now().Format(time.RFC1123Z))
+	msg.WriteString("MIME-Version: 1.0\r\n")
+	msg.WriteString("Content-Type: text/plain; charset=UTF-8\r\n\r\n")
+	msg.Write(body.Bytes())
+	return msg.Bytes()
+}
@@ -0,0 +1,123 @@
+package notify
+
+import (
+	"context"
+	"errors"
+	"net/smtp"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+)
+
+type sentMail struct {
+	addr string
+	from string
+	to   []string
+	msg  string
+}
+
+func stubSend(n *EmailNotifier) *[]sentMail {
+	var mu sync.Mutex
+	var sent []sentMail
+	n.send = func(addr string, _ smtp.Auth, from string, to []string, msg []byte) error {
+		mu.Lock()
+		defer mu.Unlock()
+		sent = append(sent, sentMail{addr, from, to, string(msg)})
+		return nil
+	}
+	return &sent
+}
+
+func TestEmailNotifier_Immediate(t *testing.T) {
+	n := NewEmailNotifier(EmailConfig{Host: "smtp.test", From: "bot@test", To: []string{"ops@test", "dev@test"}})
+	sent := stubSend(n)
+
+	ev := Event{Type: EventFailed, Repo: "o/r", Number: 3, TaskID: "t1", Error: "provider claude: boom", Timestamp: time.Now()}
+	if err := n.Notify(context.Background(), ev); err != nil {
+		t.Fatalf("Notify error: %v", err)
+	}
+	if len(*sent) != 1 {
+		t.Fatalf("sent %d emails, want 1", len(*sent))
+	}
+	mail := (*sent)[0]
+	if mail.addr != "smtp.test:587" || mail.from != "bot@test" || len(mail.to) != 2 {
+		t.Fatalf("unexpected envelope: %+v", mail)
+	}
+	for _, want := range []string{
+		"Subject: [swe-agent] Task failed: o/r#3\r\n",
+		"To: ops@test, dev@test\r\n",
+		"Link:   https://github.com/o/r/issues/3",
+		"provider claude: boom",
+	} {
+		if !strings.Contains(mail.msg, want) {
+			t.Fatalf("message missing %q:\n%s", want, mail.msg)
+		}
+	}
+}
+
+func TestEmailNotifier_Digest(t *testing.T) {
+	n := NewEmailNotifier(EmailConfig{Host: "smtp.test", From: "bot@test", To: []string{"ops@test"}, Digest: time.Hour})
+	sent := stubSend(n)
+
+	for i := 1; i <= 3; i++ {
+		_ = n.Notify(context.Background(), Event{Type: EventDeadLettered, Repo: "o/r", Number: i})
+	}
+	if len(*sent) != 0 {
+		t.Fatalf("digest should batch, sent %d", len(*sent))
+	}
+
+	m := NewManager(Route{Notifier: n})
+	m.Close()
+	if len(*sent) != 1 {
+		t.Fatalf("Close should flush one digest, sent %d", len(*sent))
+	}
+	msg := (*sent)[0].msg
+	if !strings.Contains(msg, "Subject: [swe-agent] 3 task failures in the last 1h0m0s") || strings.Count(msg, "Event:  dead_lettered") != 3 {
+		t.Fatalf("unexpected digest:\n%s", msg)
+	}
+
+	if err := n.Flush(); err != nil || len(*sent) != 1 {
+		t.Fatalf("empty flush should be a no-op, sent %d, err %v", len(*sent), err)
+	}
+}
+
+func TestEmailNotifier_DigestTimer(t *testing.T) {
+	n := NewEmailNotifier(EmailConfig{Host: "smtp.test", From: "bot@test", To: []string{"ops@test"}, Digest: 10 * time.Millisecond})
+	done := make(chan struct{})
+	n.send = func(string, smtp.Auth, string, []string, []byte) error {
+		close(done)
+		return nil
+	}
+	_ = n.Notify(context.Background(), Event{Type: EventFailed, Repo: "o/r", Number: 1})
+	select {
+	case <-done:
+	case <-time.After(2 * time.Second):
+		t.Fatal("digest timer did not fire")
+	}
+}
+
+func TestEmailNotifier_SendError(t *testing.T) {
+	n := NewEmailNotifier(EmailConfig{Host: "smtp.test", From: "bot@test", To: []string{"ops@test"}})
+	n.send = func(string, smtp.Auth, string, []string, []byte) error { return errors.New("refused") }
+	if err := n.Notify(context.Background(), Event{Type: EventFailed}); err == nil || !strings.Contains(err.Error(), "refused") {
+		t.Fatalf("expected send error, got %v", err)
+	}
+}
+
+func TestNew_EmailConfig(t *testing.T) {
+	m, err := New(Config{
+		Email: &EmailConfig{Host: "smtp.test", From: "bot@test", To: []string{"ops@test"}},
+		Repos: map[string][]Endpoint{"o/r": {}},
+	})
+	if err != nil || m == nil {
+		t.Fatalf("New error: %v", err)
+	}
+	if len(m.global) != 1 || len(m.perRepo["o/r"]) != 1 {
+		t.Fatalf("email route should be global and kept for repo overrides: %+v", m)
+	}
+
+	if _, err := New(Config{Email: &EmailConfig{Host: "smtp.test", From: "bot@test"}}); err == nil {
+		t.Fatal("expected error without recipients")
+	}
+}
@@ -0,0 +1,102 @@
+package notify
+
+import (
+	"context"
+	"fmt"
+	"net/http"
+	"strings"
+	"unicode/utf8"
+)
+
+// maxSummaryLen keeps chat messages readable (Discord caps content at 2000 chars).
+const maxSummaryLen = 1500
+
+// SlackNotifier posts to a Slack incoming webhook.
+type SlackNotifier struct {
+	URL    string
+	Client *http.Client
+}
+
+func (s *SlackNotifier) Name() string { return "slack" }
+
+func (s *SlackNotifier) Notify(ctx context.Context, ev Event) error {
+	target := fmt.Sprintf("%s#%d", ev.Repo, ev.Number)
+	if u := ev.URL(); u != "" {
+		target = fmt.Sprintf("<%s|%s>", u, target)
+	}
+	return postJSON(ctx, s.Client, s.URL, map[string]string{
+		"text": message(ev, "*"+string(ev.Type)+"*", target),
+	})
+}
+
+// DiscordNotifier posts to a Discord channel webhook.
+type DiscordNotifier struct {
+	URL    string
+	Client *http.Client
+}
+
+func (d *DiscordNotifier) Name() string { return "discord" }
+
+func (d *DiscordNotifier) Notify(ctx context.Context, ev Event) error {
+	target := fmt.Sprintf("%s#%d", ev.Repo, ev.Number)
+	if u := ev.URL(); u != "" {
+		target = fmt.Sprintf("[%s](<%s>)", target, u)
+	}
+	return postJSON(ctx, d.Client, d.URL, map[string]string{
+		"content": message(ev, "**"+string(ev.Type)+"**", target),
+	})
+}
+
+// WebhookNotifier posts the raw event JSON (plus the issue URL) to any endpoint.
+type WebhookNotifier struct {
+	URL    string
+	Client *http.Client
+}
+
+func (w *WebhookNotifier) Name() string { return "webhook" }
+
+func (w *WebhookNotifier) Notify(ctx context.Context, ev Event) error {
+	return postJSON(ctx, w.Client, w.URL, struct {
+		Event
+		URL string `json:"url,omitempty"`
+	}{ev, ev.URL()})
+}
+
+// message renders the shared chat text; status and target are pre-formatted
+// for the destination's markup.
+func message(ev Event, status, target string) string {
+	var sb strings.Builder
+	switch ev.Type {
+	case EventCompleted:
+		sb.WriteString("✅ ")
+	case EventFailed, EventDeadLettered:
+		sb.WriteString("❌ ")
+	default:
+		sb.WriteString("⏳ ")
+	}
+	fmt.Fprintf(&sb, "Task %s for %s", status, target)
+	if ev.Actor != "" {
+		fmt.Fprintf(&sb, " (triggered by @%s)", ev.Actor)
+	}
+	if ev.CostUSD > 0 {
+		fmt.Fprintf(&sb, " · cost $%.4f", ev.CostUSD)
+	}
+	if ev.Summary != "" {
+		sb.WriteString("\n")
+		sb.WriteString(truncate(ev.Summary, maxSummaryLen))
+	}
+	if ev.Error != "" {
+		sb.WriteString("\nError: ")
+		sb.WriteString(truncate(ev.Error, maxSummaryLen))
+	}
+	return sb.String()
+}
+
+// truncate shortens s to n runes, so a multi-byte character is never cut.
+func truncate(s string, n int) string {
+	s = strings.TrimSpace(s)
+	if utf8.RuneCountInString(s) <= n {
+		return s
+	}
+	return string([]rune(s)[:n]) + "..."
+}
@@ -0,0 +1,228 @@
+// Package notify delivers task lifecycle notifications to chat and webhook
+// endpoints (Slack, Discord, generic JSON webhook).
+package notify
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"fmt"
+	"io"
+	"log/slog"
+	"net/http"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/cexll/swe/internal/logging"
+)
+
+// EventType identifies a task lifecycle transition.
+type EventType string
+
+const (
+	EventQueued    EventType = "queued"
+	EventCompleted EventType = "completed"
+	EventFailed    EventType = "failed"
+	// EventDeadLettered fires once the dispatcher gives up on a task.
+	EventDeadLettered EventType = "dead_lettered"
+)
+
+// Event describes a task lifecycle transition.
+type Event struct {
+	Type      EventType `json:"type"`
+	TaskID    string    `json:"task_id,omitempty"`
+	Repo      string    `json:"repo"`
+	Number    int       `json:"number,omitempty"`
+	IsPR      bool      `json:"is_pr,omitempty"`
+	Actor     string    `json:"actor,omitempty"`
+	Summary   string    `json:"summary,omitempty"`
+	Error     string    `json:"error,omitempty"`
+	CostUSD   float64   `json:"cost_usd,omitempty"`
+	Timestamp time.Time `json:"timestamp"`
+}
+
+// URL links to the issue or pull request the task belongs to.
+func (e Event) URL() string {
+	if e.Repo == "" || e.Number <= 0 {
+		return ""
+	}
+	kind := "issues"
+	if e.IsPR {
+		kind = "pull"
+	}
+	return fmt.Sprintf("https://github.com/%s/%s/%d", e.Repo, kind, e.Number)
+}
+
+// Notifier sends a single event to one endpoint.
+type Notifier interface {
+	Name() string
+	Notify(ctx context.Context, ev Event) error
+}
+
+// Route pairs a notifier with the events it wants. Empty Events means all.
+type Route struct {
+	Notifier Notifier
+	Events   []EventType
+}
+
+func (r Route) wants(t EventType) bool {
+	if len(r.Events) == 0 {
+		return true
+	}
+	for _, e := range r.Events {
+		if e == t {
+			return true
+		}
+	}
+	return false
+}
+
+// Manager fans events out to the routes configured globally or for the
+// event's repository. Delivery is asynchronous and best-effort.
+type Manager struct {
+	mu      sync.RWMutex // guards global and perRepo, which Update replaces
+	global  []Route
+	perRepo map[string][]Route // lower-cased owner/repo
+	// observers get every event whatever the routes of its repository;
+	// Update keeps them
+	observers []Notifier
+	timeout   time.Duration
+	wg        sync.WaitGroup
+}
+
+// NewManager creates a manager with global routes.
+func NewManager(global ...Route) *Manager {
+	return &Manager{
+		global:  global,
+		perRepo: make(map[string][]Route),
+		timeout: 10 * time.Second,
+	}
+}
+
+// SetRepoRoutes overrides the global routes for one repository.
+// An empty slice disables notifications for that repository.
+func (m *Manager) SetRepoRoutes(repo string, routes []Route) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	m.perRepo[strings.ToLower(repo)] = routes
+}
+
+// Observe delivers every event to n from now on, in addition to the routes
+// of its repository. It is for integrations that follow tasks they started.
+func (m *Manager) Observe(n Notifier) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	m.observers = append(m.observers, n)
+}
+
+// Notify delivers ev to every matching route in the background.
+func (m *Manager) Notify(ev Event) {
+	if m == nil {
+		return
+	}
+	if ev.Timestamp.IsZero() {
+		ev.Timestamp = time.Now()
+	}
+
+	m.mu.RLock()
+	routes, ok := m.perRepo[strings.ToLower(ev.Repo)]
+	if !ok {
+		routes = m.global
+	}
+	for _, n := range m.observers {
+		routes = append(routes[:len(routes):len(routes)], Route{Notifier: n})
+	}
+	m.mu.RUnlock()
+	for _, route := range routes {
+		if !route.wants(ev.Type) {
+			continue
+		}
+		n := route.Notifier
+		m.wg.Add(1)
+		go func() {
+			defer m.wg.Done()
+			ctx, cancel := context.WithTimeout(context.Background(), m.timeout)
+			defer cancel()
+			if err := n.Notify(ctx, ev); err != nil {
+				slog.Warn("Notification delivery failed", logging.KeyTaskID, ev.TaskID, logging.KeyRepo, ev.Repo, logging.KeyPhase, "notify", "notifier", n.Name(), "event", ev.Type, "err", err)
+			}
+		}()
+	}
+}
+
+// Wait blocks until in-flight deliveries finish.
+func (m *Manager) Wait() {
+	if m == nil {
+		return
+	}
+	m.wg.Wait()
+}
+
+// flusher is implemented by notifiers that batch events (e.g. email digests).
+type flusher interface {
+	Flush() error
+}
+
+// Close waits for in-flight deliveries and flushes batched notifiers.
+func (m *Manager) Close() {
+	if m == nil {
+		return
+	}
+	m.Wait()
+
+	m.mu.RLock()
+	all := m.allRoutes()
+	m.mu.RUnlock()
+	flushRoutes(all)
+}
+
+// allRoutes lists global and per-repository routes; callers hold m.mu.
+func (m *Manager) allRoutes() []Route {
+	all := append([]Route{}, m.global...)
+	for _, routes := range m.perRepo {
+		all = append(all, routes...)
+	}
+	return all
+}
+
+func flushRoutes(routes []Route) {
+	seen := make(map[Notifier]bool)
+	for _, route := range routes {
+		f, ok := route.Notifier.(flusher)
+		if !ok || seen[route.Notifier] {
+			continue
+		}
+		seen[route.Notifier] = true
+		if err := f.Flush(); err != nil {
+			slog.Warn("Notification flush failed", logging.KeyPhase, "notify", "notifier", route.Notifier.Name(), "err", err)
+		}
+	}
+}
+
+// postJSON posts body as JSON and treats non-2xx responses as errors.
+func postJSON(ctx context.Context, client *http.Client, url string, body interface{}) error {
+	blob, err := json.Marshal(body)
+	if err != nil {
+		return fmt.Errorf("marshal payload: %w", err)
+	}
+	req, err := http.NewRequestWithContext(ctx, http.MethodPost, url, bytes.NewReader(blob))
+	if err != nil {
+		return fmt.Errorf("build request: %w", err)
+	}
+	req.Header.Set("Content-Type", "application/json")
+
+	if client == nil {
+		client = http.DefaultClient
+	}
+	resp, err := client.Do(req)
+	if err != nil {
+		return err
+	}
+	defer func() { _ = resp.Body.Close() }()
+	if resp.StatusCode < 200 || resp.StatusCode >= 300 {
+		snippet, _ := io.ReadAll(io.LimitReader(resp.Body, 512))
+		return fmt.Errorf("unexpected status %d: %s", resp.StatusCode, strings.TrimSpace(string(snippet)))
+	}
+	return nil
+}
@@ -0,0 +1,224 @@
+package notify
+
+import (
+	"context"
+	"encoding/json"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"sync"
+	"testing"
+	"unicode/utf8"
+)
+
+type recordingNotifier struct {
+	mu     sync.Mutex
+	events []Event
+}
+
+func (r *recordingNotifier) Name() string { return "recording" }
+
+func (r *recordingNotifier) Notify(_ context.Context, ev Event) error {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	r.events = append(r.events, ev)
+	return nil
+}
+
+func (r *recordingNotifier) types() []EventType {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	var out []EventType
+	for _, ev := range r.events {
+		out = append(out, ev.Type)
+	}
+	return out
+}
+
+func TestManager_Routing(t *testing.T) {
+	global := &recordingNotifier{}
+	failuresOnly := &recordingNotifier{}
+	repo := &recordingNotifier{}
+	observer := &recordingNotifier{}
+
+	m := NewManager(
+		Route{Notifier: global},
+		Route{Notifier: failuresOnly, Events: []EventType{EventFailed}},
+	)
+	m.SetRepoRoutes("Acme/API", []Route{{Notifier: repo}})
+	m.SetRepoRoutes("acme/quiet", nil)
+	m.Observe(observer)
+
+	m.Notify(Event{Type: EventQueued, Repo: "octo/demo"})
+	m.Notify(Event{Type: EventFailed, Repo: "octo/demo"})
+	m.Notify(Event{Type: EventCompleted, Repo: "acme/api"})
+	m.Notify(Event{Type: EventFailed, Repo: "acme/quiet"})
+	m.Wait()
+
+	if got := global.types(); len(got) != 2 {
+		t.Fatalf("global got %v, want queued+failed", got)
+	}
+	if got := failuresOnly.types(); len(got) != 1 || got[0] != EventFailed {
+		t.Fatalf("failures-only route got %v", got)
+	}
+	if got := repo.types(); len(got) != 1 || got[0] != EventCompleted {
+		t.Fatalf("repo override got %v", got)
+	}
+	if got := observer.types(); len(got) != 4 {
+		t.Fatalf("observer got %v, want every event", got)
+	}
+}
+
+func TestManager_NilSafe(t *testing.T) {
+	var m *Manager
+	m.Notify(Event{Type: EventQueued})
+	m.Wait()
+}
+
+func TestEventURL(t *testing.T) {
+	if got := (Event{Repo: "o/r", Number: 3}).URL(); got != "https://github.com/o/r/issues/3" {
+		t.Fatalf("issue URL = %s", got)
+	}
+	if got := (Event{Repo: "o/r", Number: 4, IsPR: true}).URL(); got != "https://github.com/o/r/pull/4" {
+		t.Fatalf("PR URL = %s", got)
+	}
+	if got := (Event{Repo: "o/r"}).URL(); got != "" {
+		t.Fatalf("URL without number = %s", got)
+	}
+}
+
+func TestNotifiers_Payloads(t *testing.T) {
+	var mu sync.Mutex
+	bodies := map[string]map[string]interface{}{}
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		raw, _ := io.ReadAll(r.Body)
+		var body map[string]interface{}
+		_ = json.Unmarshal(raw, &body)
+		mu.Lock()
+		bodies[r.URL.Path] = body
+		mu.Unlock()
+		if r.URL.Path == "/broken" {
+			http.Error(w, "nope", http.StatusBadRequest)
+		}
+	}))
+	defer srv.Close()
+
+	ev := Event{Type: EventCompleted, Repo: "o/r", Number: 9, Actor: "alice", Summary: "Fixed it", CostUSD: 0.25}
+	ctx := context.Background()
+
+	if err := (&SlackNotifier{URL: srv.URL + "/slack"}).Notify(ctx, ev); err != nil {
+		t.Fatalf("slack: %v", err)
+	}
+	if err := (&DiscordNotifier{URL: srv.URL + "/discord"}).Notify(ctx, ev); err != nil {
+		t.Fatalf("discord: %v", err)
+	}
+	if err := (&WebhookNotifier{URL: srv.URL + "/hook"}).Notify(ctx, ev); err != nil {
+		t.Fatalf("webhook: %v", err)
+	}
+	if err := (&WebhookNotifier{URL: srv.URL + "/broken"}).Notify(ctx, ev); err == nil || !strings.Contains(err.Error(), "400") {
+		t.Fatalf("expected status error, got %v", err)
+	}
+
+	slack, _ := bodies["/slack"]["text"].(string)
+	for _, want := range []string{"<https://github.com/o/r/issues/9|o/r#9>", "@alice", "$0.2500", "Fixed it"} {
+		if !strings.Contains(slack, want) {
+			t.Fatalf("slack text missing %q: %s", want, slack)
+		}
+	}
+	discord, _ := bodies["/discord"]["content"].(string)
+	if !strings.Contains(discord, "[o/r#9](<https://github.com/o/r/issues/9>)") {
+		t.Fatalf("discord content = %s", discord)
+	}
+	hook := bodies["/hook"]
+	if hook["type"] != "completed" || hook["url"] != "https://github.com/o/r/issues/9" || hook["cost_usd"] != 0.25 {
+		t.Fatalf("webhook body = %v", hook)
+	}
+}
+
+func TestMessage_TruncatesOnRunes(t *testing.T) {
+	summary := "修复登录超时：" + strings.Repeat("会话令牌在刷新前过期。", 200)
+	text := message(Event{Type: EventCompleted, Summary: summary}, "completed", "o/r#9")
+	if !utf8.ValidString(text) {
+		t.Fatalf("message is not valid UTF-8: %q", text[len(text)-20:])
+	}
+	_, got, _ := strings.Cut(text, "\n")
+	if !strings.HasSuffix(got, "...") || utf8.RuneCountInString(strings.TrimSuffix(got, "...")) != maxSummaryLen || !strings.HasPrefix(got, "修复登录超时：") {
+		t.Fatalf("summary = %q", got)
+	}
+	if short := truncate(" 已修复 ", maxSummaryLen); short != "已修复" {
+		t.Fatalf("truncate() = %q", short)
+	}
+}
+
+func TestNew(t *testing.T) {
+	m, err := New(Config{})
+	if err != nil || m != nil {
+		t.Fatalf("empty config should yield nil manager, got %v, %v", m, err)
+	}
+
+	m, err = New(Config{
+		Global: []Endpoint{{Type: "slack", URL: "https://x"}},
+		Repos:  map[string][]Endpoint{"o/r": {{Type: "webhook", URL: "https://y", Events: []EventType{EventFailed}}}},
+	})
+	if err != nil || m == nil {
+		t.Fatalf("New error: %v", err)
+	}
+	if len(m.global) != 1 || len(m.perRepo["o/r"]) != 1 {
+		t.Fatalf("routes not built: %+v", m)
+	}
+
+	if _, err := New(Config{Global: []Endpoint{{Type: "slack"}}}); err == nil {
+		t.Fatal("expected error for missing url")
+	}
+	if _, err := New(Config{Global: []Endpoint{{Type: "slack", URL: "https://x", Events: []EventType{"bogus"}}}}); err == nil {
+		t.Fatal("expected error for unknown event")
+	}
+}
+
+type flushCounter struct {
+	recordingNotifier
+	flushes int
+}
+
+func (f *flushCounter) Flush() error {
+	f.flushes++
+	return nil
+}
+
+func TestManager_Update(t *testing.T) {
+	old := &flushCounter{}
+	m := NewManager(Route{Notifier: old})
+	m.SetRepoRoutes("o/r", nil)
+
+	if err := m.Update(Config{Global: []Endpoint{{Type: "slack"}}}); err == nil {
+		t.Fatal("expected error for missing url")
+	}
+	if len(m.global) != 1 || m.global[0].Notifier != old || old.flushes != 0 {
+		t.Fatalf("failed update should keep existing routes: %+v", m.global)
+	}
+
+	err := m.Update(Config{
+		Global: []Endpoint{{Type: "slack", URL: "https://x"}, {Type: "webhook", URL: "https://y"}},
+		Repos:  map[string][]Endpoint{"O/R": {{Type: "discord", URL: "https://z"}}},
+	})
+	if err != nil {
+		t.Fatalf("Update: %v", err)
+	}
+	if len(m.global) != 2 || len(m.perRepo) != 1 || len(m.perRepo["o/r"]) != 1 {
+		t.Fatalf("routes not replaced: global=%d perRepo=%v", len(m.global), m.perRepo)
+	}
+	if old.flushes != 1 {
+		t.Fatalf("replaced notifier flushed %d times, want 1", old.flushes)
+	}
+}
+
+func TestParseEvents(t *testing.T) {
+	events, err := ParseEvents(" Queued,failed ,")
+	if err != nil || len(events) != 2 || events[0] != EventQueued || events[1] != EventFailed {
+		t.Fatalf("ParseEvents = %v, %v", events, err)
+	}
+	if events, err := ParseEvents(""); err != nil || events != nil {
+		t.Fatalf("empty ParseEvents = %v, %v", events, err)
+	}
+}
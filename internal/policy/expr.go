@@ -0,0 +1,576 @@
+package policy
+
+import (
+	"fmt"
+	"regexp"
+	"strconv"
+	"strings"
+	"unicode"
+)
+
+// Expressions are a small CEL-like language evaluated against an Input:
+//
+//	"intern" in roles && command == "review" && !repo.endsWith("-prod")
+//
+// Literals are strings ("..." or '...'), integers, true, false and lists
+// ([..]). Operators are ! && || == != < <= > >= and in (list membership or
+// substring). Strings have the methods startsWith, endsWith, contains and
+// matches (regular expression); on a list of strings they hold when any
+// element does, as in paths.startsWith("deploy/"). size(x) is the length of
+// a string or list.
+
+// value is a string, int, bool or []value.
+type value = any
+
+type node interface {
+	eval(vars map[string]value) (value, error)
+}
+
+// Variables lists the names an expression may use, with a description.
+var Variables = map[string]string{
+	"user":     "login of the commenter",
+	"roles":    "roles of the commenter: policy roles, installer, and the GitHub repository role (admin, maintain, write, triage, read)",
+	"teams":    "slugs of the teams of the repository owner's organization the commenter is in",
+	"org_role": `role of the commenter in the repository owner's organization: "admin", "member", or "" for outsiders`,
+	"repo":     "repository, owner/name",
+	"owner":    "repository owner",
+	"command":  `command without the slash, e.g. "code", "release" or "triage"`,
+	"flags":    `--flags in the comment, without dashes or values`,
+	"event":    "webhook event, e.g. issue_comment",
+	"is_pr":    "whether the comment is on a pull request",
+	"hour":     "hour of day in the policy time zone, 0-23",
+	"weekday":  `day of week in the policy time zone, e.g. "Saturday"`,
+	"date":     `date in the policy time zone, "2006-01-02"`,
+	"time":     `time of day in the policy time zone, "15:04"`,
+
+	// known when the agent pushes; push rules only
+	"paths":         "files the pushed commits change (push rules only)",
+	"changed_files": "number of files the pushed commits change (push rules only)",
+	"changed_lines": "lines the pushed commits add and remove (push rules only)",
+}
+
+// pushVariables are the variables only push rules may use.
+var pushVariables = []string{"paths", "changed_files", "changed_lines"}
+
+// compile parses src and records the variables it uses in used.
+func compile(src string, used map[string]bool) (node, error) {
+	toks, err := lex(src)
+	if err != nil {
+		return nil, err
+	}
+	p := &parser{toks: toks, used: used}
+	n, err := p.or()
+	if err != nil {
+		return nil, err
+	}
+	if t := p.peek(); t.kind != tokEOF {
+		return nil, fmt.Errorf("unexpected %s at offset %d", t, t.pos)
+	}
+	return n, nil
+}
+
+type tokKind int
+
+const (
+	tokEOF tokKind = iota
+	tokIdent
+	tokString
+	tokInt
+	tokOp
+)
+
+type token struct {
+	kind tokKind
+	text string
+	pos  int
+}
+
+func (t token) String() string {
+	if t.kind == tokEOF {
+		return "end of expression"
+	}
+	return strconv.Quote(t.text)
+}
+
+var operators = []string{"&&", "||", "==", "!=", "<=", ">=", "<", ">", "!", "(", ")", "[", "]", ",", "."}
+
+func lex(src string) ([]token, error) {
+	var toks []token
+	for i := 0; i < len(src); {
+		c := rune(src[i])
+		switch {
+		case unicode.IsSpace(c):
+			i++
+		case c == '"' || c == '\'':
+			j := i + 1
+			for j < len(src) && src[j] != src[i] {
+				if src[j] == '\\' {
+					j++
+				}
+				j++
+			}
+			if j >= len(src) {
+				return nil, fmt.Errorf("unterminated string at offset %d", i)
+			}
+			text := src[i+1 : j]
+			if c == '"' {
+				s, err := strconv.Unquote(src[i : j+1])
+				if err != nil {
+					return nil, fmt.Errorf("invalid string at offset %d", i)
+				}
+				text = s
+			}
+			toks = append(toks, token{tokString, text, i})
+			i = j + 1
+		case c >= '0' && c <= '9':
+			j := i
+			for j < len(src) && src[j] >= '0' && src[j] <= '9' {
+				j++
+			}
+			toks = append(toks, token{tokInt, src[i:j], i})
+			i = j
+		case c == '_' || unicode.IsLetter(c):
+			j := i
+			for j < len(src) && (src[j] == '_' || unicode.IsLetter(rune(src[j])) || unicode.IsDigit(rune(src[j]))) {
+				j++
+			}
+			toks = append(toks, token{tokIdent, src[i:j], i})
+			i = j
+		default:
+			op := ""
+			for _, o := range operators {
+				if strings.HasPrefix(src[i:], o) {
+					op = o
+					break
+				}
+			}
+			if op == "" {
+				return nil, fmt.Errorf("unexpected %q at offset %d", c, i)
+			}
+			toks = append(toks, token{tokOp, op, i})
+			i += len(op)
+		}
+	}
+	return append(toks, token{kind: tokEOF, pos: len(src)}), nil
+}
+
+type parser struct {
+	toks []token
+	i    int
+	used map[string]bool
+}
+
+func (p *parser) peek() token { return p.toks[p.i] }
+
+func (p *parser) next() token {
+	t := p.toks[p.i]
+	if t.kind != tokEOF {
+		p.i++
+	}
+	return t
+}
+
+// accept consumes the operator or keyword text if it comes next.
+func (p *parser) accept(text string) bool {
+	if t := p.peek(); (t.kind == tokOp || t.kind == tokIdent) && t.text == text {
+		p.i++
+		return true
+	}
+	return false
+}
+
+func (p *parser) expect(text string) error {
+	if !p.accept(text) {
+		t := p.peek()
+		return fmt.Errorf("expected %q, found %s at offset %d", text, t, t.pos)
+	}
+	return nil
+}
+
+func (p *parser) or() (node, error) {
+	left, err := p.and()
+	for err == nil && p.accept("||") {
+		var right node
+		if right, err = p.and(); err == nil {
+			left = logical{op: "||", left: left, right: right}
+		}
+	}
+	return left, err
+}
+
+func (p *parser) and() (node, error) {
+	left, err := p.comparison()
+	for err == nil && p.accept("&&") {
+		var right node
+		if right, err = p.comparison(); err == nil {
+			left = logical{op: "&&", left: left, right: right}
+		}
+	}
+	return left, err
+}
+
+func (p *parser) comparison() (node, error) {
+	left, err := p.unary()
+	if err != nil {
+		return nil, err
+	}
+	for _, op := range []string{"==", "!=", "<=", ">=", "<", ">", "in"} {
+		if p.accept(op) {
+			right, err := p.unary()
+			if err != nil {
+				return nil, err
+			}
+			return compare{op: op, left: left, right: right}, nil
+		}
+	}
+	return left, nil
+}
+
+func (p *parser) unary() (node, error) {
+	if p.accept("!") {
+		n, err := p.unary()
+		if err != nil {
+			return nil, err
+		}
+		return not{n}, nil
+	}
+	return p.postfix()
+}
+
+func (p *parser) postfix() (node, error) {
+	n, err := p.primary()
+	for err == nil && p.accept(".") {
+		name := p.next()
+		if name.kind != tokIdent {
+			return nil, fmt.Errorf("expected method name at offset %d", name.pos)
+		}
+		var args []node
+		if args, err = p.args(); err != nil {
+			return nil, err
+		}
+		n, err = newMethod(name.text, n, args)
+	}
+	return n, err
+}
+
+func (p *parser) args() ([]node, error) {
+	if err := p.expect("("); err != nil {
+		return nil, err
+	}
+	return p.list(")")
+}
+
+// list parses comma-separated expressions up to the closing text.
+func (p *parser) list(closing string) ([]node, error) {
+	var out []node
+	if p.accept(closing) {
+		return out, nil
+	}
+	for {
+		n, err := p.or()
+		if err != nil {
+			return nil, err
+		}
+		out = append(out, n)
+		if p.accept(closing) {
+			return out, nil
+		}
+		if err := p.expect(","); err != nil {
+			return nil, err
+		}
+	}
+}
+
+func (p *parser) primary() (node, error) {
+	t := p.next()
+	switch t.kind {
+	case tokString:
+		return literal{t.text}, nil
+	case tokInt:
+		n, err := strconv.Atoi(t.text)
+		if err != nil {
+			return nil, fmt.Errorf("invalid number %s", t.text)
+		}
+		return literal{n}, nil
+	case tokIdent:
+		switch t.text {
+		case "true", "false":
+			return literal{t.text == "true"}, nil
+		case "size":
+			args, err := p.args()
+			if err != nil {
+				return nil, err
+			}
+			if len(args) != 1 {
+				return nil, fmt.Errorf("size takes one argument")
+			}
+			return size{args[0]}, nil
+		}
+		if _, ok := Variables[t.text]; !ok {
+			return nil, fmt.Errorf("unknown variable %q at offset %d", t.text, t.pos)
+		}
+		p.used[t.text] = true
+		return variable(t.text), nil
+	case tokOp:
+		switch t.text {
+		case "(":
+			n, err := p.or()
+			if err != nil {
+				return nil, err
+			}
+			return n, p.expect(")")
+		case "[":
+			items, err := p.list("]")
+			if err != nil {
+				return nil, err
+			}
+			return list(items), nil
+		}
+	}
+	return nil, fmt.Errorf("unexpected %s at offset %d", t, t.pos)
+}
+
+type literal struct{ v value }
+
+func (n literal) eval(map[string]value) (value, error) { return n.v, nil }
+
+type variable string
+
+func (n variable) eval(vars map[string]value) (value, error) { return vars[string(n)], nil }
+
+type list []node
+
+func (n list) eval(vars map[string]value) (value, error) {
+	out := make([]value, 0, len(n))
+	for _, item := range n {
+		v, err := item.eval(vars)
+		if err != nil {
+			return nil, err
+		}
+		out = append(out, v)
+	}
+	return out, nil
+}
+
+type not struct{ n node }
+
+func (n not) eval(vars map[string]value) (value, error) {
+	b, err := evalBool(n.n, vars)
+	return !b, err
+}
+
+type logical struct {
+	op          string
+	left, right node
+}
+
+func (n logical) eval(vars map[string]value) (value, error) {
+	l, err := evalBool(n.left, vars)
+	if err != nil || (n.op == "&&" && !l) || (n.op == "||" && l) {
+		return l, err
+	}
+	return evalBool(n.right, vars)
+}
+
+func evalBool(n node, vars map[string]value) (bool, error) {
+	v, err := n.eval(vars)
+	if err != nil {
+		return false, err
+	}
+	b, ok := v.(bool)
+	if !ok {
+		return false, fmt.Errorf("expected a boolean, got %s", typeName(v))
+	}
+	return b, nil
+}
+
+type compare struct {
+	op          string
+	left, right node
+}
+
+func (n compare) eval(vars map[string]value) (value, error) {
+	l, err := n.left.eval(vars)
+	if err != nil {
+		return nil, err
+	}
+	r, err := n.right.eval(vars)
+	if err != nil {
+		return nil, err
+	}
+	switch n.op {
+	case "==":
+		return equal(l, r), nil
+	case "!=":
+		return !equal(l, r), nil
+	case "in":
+		switch r := r.(type) {
+		case []value:
+			for _, item := range r {
+				if equal(l, item) {
+					return true, nil
+				}
+			}
+			return false, nil
+		case string:
+			if l, ok := l.(string); ok {
+				return strings.Contains(r, l), nil
+			}
+		}
+		return nil, fmt.Errorf("cannot test %s in %s", typeName(l), typeName(r))
+	}
+	var c int
+	switch l := l.(type) {
+	case int:
+		ri, ok := r.(int)
+		if !ok {
+			return nil, fmt.Errorf("cannot compare int with %s", typeName(r))
+		}
+		c = l - ri
+	case string:
+		rs, ok := r.(string)
+		if !ok {
+			return nil, fmt.Errorf("cannot compare string with %s", typeName(r))
+		}
+		c = strings.Compare(l, rs)
+	default:
+		return nil, fmt.Errorf("cannot order %s", typeName(l))
+	}
+	switch n.op {
+	case "<":
+		return c < 0, nil
+	case "<=":
+		return c <= 0, nil
+	case ">":
+		return c > 0, nil
+	default:
+		return c >= 0, nil
+	}
+}
+
+func equal(a, b value) bool {
+	al, aok := a.([]value)
+	bl, bok := b.([]value)
+	if aok || bok {
+		if !aok || !bok || len(al) != len(bl) {
+			return false
+		}
+		for i := range al {
+			if !equal(al[i], bl[i]) {
+				return false
+			}
+		}
+		return true
+	}
+	return a == b
+}
+
+type size struct{ n node }
+
+func (n size) eval(vars map[string]value) (value, error) {
+	v, err := n.n.eval(vars)
+	if err != nil {
+		return nil, err
+	}
+	switch v := v.(type) {
+	case string:
+		return len(v), nil
+	case []value:
+		return len(v), nil
+	}
+	return nil, fmt.Errorf("size of %s", typeName(v))
+}
+
+type method struct {
+	name string
+	recv node
+	arg  node
+	re   *regexp.Regexp // matches with a literal pattern, compiled once
+}
+
+func newMethod(name string, recv node, args []node) (node, error) {
+	switch name {
+	case "startsWith", "endsWith", "contains", "matches":
+	default:
+		return nil, fmt.Errorf("unknown method %q", name)
+	}
+	if len(args) != 1 {
+		return nil, fmt.Errorf("%s takes one argument", name)
+	}
+	m := method{name: name, recv: recv, arg: args[0]}
+	if lit, ok := args[0].(literal); ok && name == "matches" {
+		pattern, ok := lit.v.(string)
+		if !ok {
+			return nil, fmt.Errorf("matches takes a string")
+		}
+		re, err := regexp.Compile(pattern)
+		if err != nil {
+			return nil, fmt.Errorf("matches: %w", err)
+		}
+		m.re = re
+	}
+	return m, nil
+}
+
+func (n method) eval(vars map[string]value) (value, error) {
+	rv, err := n.recv.eval(vars)
+	if err != nil {
+		return nil, err
+	}
+	av, err := n.arg.eval(vars)
+	if err != nil {
+		return nil, err
+	}
+	a, aok := av.(string)
+	if items, ok := rv.([]value); ok && aok {
+		for _, item := range items {
+			match, err := n.apply(item, a)
+			if err != nil || match {
+				return match, err
+			}
+		}
+		return false, nil
+	}
+	if !aok {
+		return nil, fmt.Errorf("%s needs strings, got %s and %s", n.name, typeName(rv), typeName(av))
+	}
+	return n.apply(rv, a)
+}
+
+// apply calls the method on one string.
+func (n method) apply(rv value, a string) (bool, error) {
+	s, ok := rv.(string)
+	if !ok {
+		return false, fmt.Errorf("%s needs strings, got %s", n.name, typeName(rv))
+	}
+	switch n.name {
+	case "startsWith":
+		return strings.HasPrefix(s, a), nil
+	case "endsWith":
+		return strings.HasSuffix(s, a), nil
+	case "contains":
+		return strings.Contains(s, a), nil
+	}
+	re := n.re
+	if re == nil {
+		var err error
+		if re, err = regexp.Compile(a); err != nil {
+			return false, fmt.Errorf("matches: %w", err)
+		}
+	}
+	return re.MatchString(s), nil
+}
+
+func typeName(v value) string {
+	switch v.(type) {
+	case string:
+		return "string"
+	case int:
+		return "int"
+	case bool:
+		return "bool"
+	case []value:
+		return "list"
+	}
+	return fmt.Sprintf("%T", v)
+}
@@ -0,0 +1,339 @@
+// Package policy decides who may trigger the agent and what it may push. A
+// policy is an ordered list of rules whose conditions are expressions over
+// the event (user, roles, repository, command, flags, time) and, for push
+// rules, the change being pushed (paths, size); the first rule that matches
+// decides. Organizations express rules such as "interns may only run
+// /review on non-production repositories" or "changes to deploy/ need
+// approval" in the policy file instead of code.
+package policy
+
+import (
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"os"
+	"sort"
+	"strings"
+	"time"
+)
+
+// Effects of a rule. RequireApproval and DryRun allow the task but hold its
+// changes: a trigger rule makes it a plan awaiting approval or a dry run, a
+// push rule keeps the commits it would push for someone to apply.
+const (
+	Allow           = "allow"
+	Deny            = "deny"
+	RequireApproval = "require_approval"
+	DryRun          = "dry_run"
+)
+
+// Stages a rule applies at.
+const (
+	StageTrigger = "trigger" // when a command arrives, before the task is queued
+	StagePush    = "push"    // when the agent pushes, with the paths and size of the change
+)
+
+// Input describes one event to authorize.
+type Input struct {
+	User    string
+	Roles   []string // from GitHub; the policy file's roles are added by Evaluate
+	Teams   []string // slugs of the repository owner's teams the user is in
+	OrgRole string   // in the repository owner's organization: admin, member or ""
+	Repo    string   // owner/name
+	Command string   // without the slash
+	Flags   []string // without dashes or values
+	Event   string
+	IsPR    bool
+	Time    time.Time
+
+	// set at the push stage only
+	Paths        []string // files the pushed commits change
+	ChangedFiles int
+	ChangedLines int // added plus removed
+}
+
+// Decision is the outcome of Evaluate.
+type Decision struct {
+	Allowed bool   // false only for deny
+	Effect  string // one of the effects
+	Rule    string // rule that decided; empty for the default
+	Reason  string // for logs, audit records and the simulation endpoint
+	Message string // the rule's message for the commenter, if any
+}
+
+// Rule is one entry of the policy file.
+type Rule struct {
+	Name    string `json:"name"`
+	Effect  string `json:"effect"`            // allow, deny, require_approval or dry_run
+	Stage   string `json:"stage,omitempty"`   // trigger (the default) or push
+	When    string `json:"when,omitempty"`    // expression; empty always matches
+	Message string `json:"message,omitempty"` // posted as a reply when the rule denies or holds
+
+	cond node
+}
+
+// file is the JSON policy document.
+type file struct {
+	Default  string              `json:"default,omitempty"`  // effect when no rule matches; deny by default
+	Timezone string              `json:"timezone,omitempty"` // for hour, weekday, date and time; UTC by default
+	Roles    map[string][]string `json:"roles,omitempty"`    // role name -> logins
+	Rules    []Rule              `json:"rules"`
+}
+
+// Policy is a parsed policy file; a nil Policy is not configured.
+type Policy struct {
+	source   string
+	fallback string
+	loc      *time.Location
+	roles    map[string][]string // login (lowercase) -> policy roles
+	rules    []Rule
+	used     map[string]bool
+}
+
+// Load reads a policy file; an empty path returns nil.
+func Load(path string) (*Policy, error) {
+	if path == "" {
+		return nil, nil
+	}
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return nil, fmt.Errorf("policy: %w", err)
+	}
+	p, err := Parse(data)
+	if err != nil {
+		return nil, fmt.Errorf("policy %s: %w", path, err)
+	}
+	return p, nil
+}
+
+// Parse parses and checks a JSON policy document.
+func Parse(data []byte) (*Policy, error) {
+	var f file
+	dec := json.NewDecoder(bytes.NewReader(data))
+	dec.DisallowUnknownFields()
+	if err := dec.Decode(&f); err != nil {
+		return nil, err
+	}
+	p := &Policy{source: string(data), fallback: Deny, loc: time.UTC, roles: make(map[string][]string), used: make(map[string]bool)}
+	switch strings.ToLower(f.Default) {
+	case "", Deny:
+	case Allow:
+		p.fallback = Allow
+	default:
+		return nil, fmt.Errorf("default must be allow or deny, not %q", f.Default)
+	}
+	if f.Timezone != "" {
+		loc, err := time.LoadLocation(f.Timezone)
+		if err != nil {
+			return nil, fmt.Errorf("timezone: %w", err)
+		}
+		p.loc = loc
+	}
+	names := make([]string, 0, len(f.Roles))
+	for name := range f.Roles {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+	for _, name := range names {
+		for _, login := range f.Roles[name] {
+			login = strings.ToLower(strings.TrimSpace(login))
+			p.roles[login] = append(p.roles[login], name)
+		}
+	}
+
+	sample := p.vars(Input{Time: time.Now()})
+	for i, r := range f.Rules {
+		if r.Name == "" {
+			r.Name = fmt.Sprintf("rule %d", i+1)
+		}
+		r.Effect = strings.ToLower(r.Effect)
+		switch r.Effect {
+		case Allow, Deny, RequireApproval, DryRun:
+		default:
+			return nil, fmt.Errorf("%s: effect must be allow, deny, require_approval or dry_run, not %q", r.Name, r.Effect)
+		}
+		r.Stage = strings.ToLower(r.Stage)
+		switch r.Stage {
+		case "":
+			r.Stage = StageTrigger
+		case StageTrigger, StagePush:
+		default:
+			return nil, fmt.Errorf("%s: stage must be trigger or push, not %q", r.Name, r.Stage)
+		}
+		if strings.TrimSpace(r.When) == "" {
+			r.When = "true"
+		}
+		used := make(map[string]bool)
+		cond, err := compile(r.When, used)
+		if err != nil {
+			return nil, fmt.Errorf("%s: %w", r.Name, err)
+		}
+		for _, v := range pushVariables {
+			if used[v] && r.Stage != StagePush {
+				return nil, fmt.Errorf("%s: %s is only known when the agent pushes; set \"stage\": \"push\"", r.Name, v)
+			}
+		}
+		for v := range used {
+			p.used[v] = true
+		}
+		// catch type errors now rather than on the first event
+		if _, err := evalBool(cond, sample); err != nil {
+			return nil, fmt.Errorf("%s: %w", r.Name, err)
+		}
+		r.cond = cond
+		p.rules = append(p.rules, r)
+	}
+	return p, nil
+}
+
+// Uses reports whether a rule reads the variable, so callers can skip
+// lookups the policy does not need (roles cost GitHub API calls).
+func (p *Policy) Uses(variable string) bool {
+	return p != nil && p.used[variable]
+}
+
+// HasPushRules reports whether any rule applies when the agent pushes.
+func (p *Policy) HasPushRules() bool {
+	if p == nil {
+		return false
+	}
+	for _, r := range p.rules {
+		if r.Stage == StagePush {
+			return true
+		}
+	}
+	return false
+}
+
+// HasEffect reports whether any rule has effect.
+func (p *Policy) HasEffect(effect string) bool {
+	if p == nil {
+		return false
+	}
+	for _, r := range p.rules {
+		if r.Effect == effect {
+			return true
+		}
+	}
+	return false
+}
+
+// Source returns the document p was parsed from.
+func (p *Policy) Source() string {
+	if p == nil {
+		return ""
+	}
+	return p.source
+}
+
+// Equal reports whether p and o were parsed from the same document.
+func (p *Policy) Equal(o *Policy) bool {
+	if p == nil || o == nil {
+		return p == o
+	}
+	return p.source == o.source
+}
+
+// Roles returns the roles the policy file gives login.
+func (p *Policy) Roles(login string) []string {
+	if p == nil {
+		return nil
+	}
+	return p.roles[strings.ToLower(login)]
+}
+
+// Evaluate applies the trigger rules in order. A rule whose condition cannot
+// be evaluated denies: a broken policy must not open access.
+func (p *Policy) Evaluate(in Input) Decision {
+	if d, ok := p.evaluate(StageTrigger, in); ok {
+		return d
+	}
+	return Decision{Allowed: p.fallback == Allow, Effect: p.fallback, Reason: "policy default: " + p.fallback}
+}
+
+// EvaluatePush applies the push rules in order to a push described by in;
+// a push no rule matches is allowed, the command having been authorized
+// already.
+func (p *Policy) EvaluatePush(in Input) Decision {
+	if d, ok := p.evaluate(StagePush, in); ok {
+		return d
+	}
+	return Decision{Allowed: true, Effect: Allow, Reason: "no push rule matched"}
+}
+
+// evaluate returns the decision of the first rule of stage that matches.
+func (p *Policy) evaluate(stage string, in Input) (Decision, bool) {
+	if p == nil {
+		return Decision{}, false
+	}
+	vars := p.vars(in)
+	for _, r := range p.rules {
+		if r.Stage != stage {
+			continue
+		}
+		match, err := evalBool(r.cond, vars)
+		if err != nil {
+			return Decision{Effect: Deny, Rule: r.Name, Reason: fmt.Sprintf("policy rule %q failed: %v", r.Name, err)}, true
+		}
+		if !match {
+			continue
+		}
+		d := Decision{Allowed: r.Effect != Deny, Effect: r.Effect, Rule: r.Name, Message: r.Message}
+		d.Reason = fmt.Sprintf("policy rule %q: %s", r.Name, r.Effect)
+		return d, true
+	}
+	return Decision{}, false
+}
+
+func (p *Policy) vars(in Input) map[string]value {
+	now := in.Time
+	if now.IsZero() {
+		now = time.Now()
+	}
+	now = now.In(p.loc)
+	owner, _, _ := strings.Cut(in.Repo, "/")
+	return map[string]value{
+		"user":     in.User,
+		"roles":    stringValues(append(append([]string(nil), in.Roles...), p.Roles(in.User)...)),
+		"teams":    stringValues(in.Teams),
+		"org_role": in.OrgRole,
+		"repo":     in.Repo,
+		"owner":    owner,
+		"command":  in.Command,
+		"flags":    stringValues(in.Flags),
+		"event":    in.Event,
+		"is_pr":    in.IsPR,
+		"hour":     now.Hour(),
+		"weekday":  now.Weekday().String(),
+		"date":     now.Format("2006-01-02"),
+		"time":     now.Format("15:04"),
+
+		"paths":         stringValues(in.Paths),
+		"changed_files": in.ChangedFiles,
+		"changed_lines": in.ChangedLines,
+	}
+}
+
+func stringValues(s []string) []value {
+	out := make([]value, 0, len(s))
+	for _, v := range s {
+		out = append(out, v)
+	}
+	return out
+}
+
+// ParseFlags returns the --flags in a comment, without dashes or values.
+func ParseFlags(text string) []string {
+	var flags []string
+	for _, word := range strings.Fields(text) {
+		name, ok := strings.CutPrefix(word, "--")
+		if !ok {
+			continue
+		}
+		name, _, _ = strings.Cut(name, "=")
+		if name != "" {
+			flags = append(flags, strings.ToLower(name))
+		}
+	}
+	return flags
+}
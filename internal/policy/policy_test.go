@@ -0,0 +1,203 @@
+package policy
+
+import (
+	"reflect"
+	"strings"
+	"testing"
+	"time"
+)
+
+const internPolicy = `{
+  "default": "deny",
+  "timezone": "UTC",
+  "roles": {"intern": ["Alice", "bob"]},
+  "rules": [
+    {"name": "interns-review-non-prod", "effect": "deny",
+     "when": "'intern' in roles && (command != 'review' || repo.endsWith('-prod'))",
+     "message": "Interns may only run /review on non-production repositories."},
+    {"name": "no-friday-deploys", "effect": "deny", "when": "weekday == \"Friday\" && hour >= 15 && 'deploy' in flags"},
+    {"name": "members", "effect": "allow", "when": "owner == 'acme' && ('write' in roles || 'intern' in roles)"}
+  ]
+}`
+
+func TestEvaluate(t *testing.T) {
+	p, err := Parse([]byte(internPolicy))
+	if err != nil {
+		t.Fatalf("Parse: %v", err)
+	}
+	thursday := time.Date(2026, 10, 15, 16, 0, 0, 0, time.UTC)
+	friday := thursday.AddDate(0, 0, 1)
+
+	tests := []struct {
+		name  string
+		in    Input
+		allow bool
+		rule  string
+	}{
+		{"intern review", Input{User: "alice", Repo: "acme/web", Command: "review", Time: thursday}, true, "members"},
+		{"intern code", Input{User: "bob", Repo: "acme/web", Command: "code", Time: thursday}, false, "interns-review-non-prod"},
+		{"intern review prod", Input{User: "ALICE", Repo: "acme/web-prod", Command: "review", Time: thursday}, false, "interns-review-non-prod"},
+		{"writer", Input{User: "carol", Roles: []string{"write"}, Repo: "acme/web-prod", Command: "code", Time: thursday}, true, "members"},
+		{"friday deploy", Input{User: "carol", Roles: []string{"write"}, Repo: "acme/web", Command: "code", Flags: []string{"deploy"}, Time: friday}, false, "no-friday-deploys"},
+		{"friday morning deploy", Input{User: "carol", Roles: []string{"write"}, Repo: "acme/web", Command: "code", Flags: []string{"deploy"}, Time: friday.Add(-8 * time.Hour)}, true, "members"},
+		{"outsider", Input{User: "mallory", Repo: "acme/web", Command: "code", Time: thursday}, false, ""},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			d := p.Evaluate(tt.in)
+			if d.Allowed != tt.allow || d.Rule != tt.rule {
+				t.Fatalf("Evaluate = %+v, want allowed=%t rule=%q", d, tt.allow, tt.rule)
+			}
+		})
+	}
+	if d := p.Evaluate(tests[1].in); !strings.HasPrefix(d.Message, "Interns may only") {
+		t.Fatalf("message = %q", d.Message)
+	}
+	if !p.Uses("roles") || !p.Uses("flags") || p.Uses("event") {
+		t.Fatalf("used = %v", p.used)
+	}
+}
+
+func TestExpressions(t *testing.T) {
+	in := Input{User: "dev", Repo: "acme/api", Command: "code", Event: "issue_comment", IsPR: true, Flags: []string{"force"},
+		Teams: []string{"backend", "oncall"}, OrgRole: "member",
+		Time: time.Date(2026, 10, 16, 9, 30, 0, 0, time.UTC)}
+	tests := map[string]bool{
+		`true`:                                            true,
+		`!is_pr`:                                          false,
+		`repo == "acme/api" && user != "root"`:            true,
+		`command in ["code", "review"]`:                   true,
+		`"cm" in "acme"`:                                  true,
+		`size(flags) == 1 && size(user) > 2`:              true,
+		`repo.matches("^acme/(api|web)$")`:                true,
+		`repo.startsWith("acme/") || false`:               true,
+		`user.contains("x")`:                              false,
+		`date == "2026-10-16" && time < "10:00"`:          true,
+		`hour >= 9 && hour <= 17 && weekday != "Sunday"`:  true,
+		`event == 'issue_comment' && !('force' in flags)`: false,
+		`[1, 2] == [1, 2]`:                                true,
+		`"oncall" in teams && org_role == "member"`:       true,
+		`"frontend" in teams || org_role == "admin"`:      false,
+	}
+	for expr, want := range tests {
+		p, err := Parse([]byte(`{"rules": [{"effect": "allow", "when": ` + quote(expr) + `}]}`))
+		if err != nil {
+			t.Errorf("%s: %v", expr, err)
+			continue
+		}
+		if got := p.Evaluate(in).Allowed; got != want {
+			t.Errorf("%s = %t, want %t", expr, got, want)
+		}
+	}
+}
+
+func quote(s string) string {
+	return `"` + strings.NewReplacer(`\`, `\\`, `"`, `\"`).Replace(s) + `"`
+}
+
+func TestParseErrors(t *testing.T) {
+	tests := map[string]string{
+		`{"rules": [{"effect": "maybe"}]}`:                              "effect must be allow, deny, require_approval or dry_run",
+		`{"rules": [{"effect": "deny", "stage": "merge"}]}`:             "stage must be trigger or push",
+		`{"rules": [{"effect": "deny", "when": "changed_lines > 9"}]}`:  `changed_lines is only known when the agent pushes`,
+		`{"default": "open", "rules": []}`:                              "default must be allow or deny",
+		`{"rules": [{"effect": "allow", "when": "team == 'a'"}]}`:       `unknown variable "team"`,
+		`{"rules": [{"effect": "allow", "when": "user =="}]}`:           "unexpected end of expression",
+		`{"rules": [{"effect": "allow", "when": "user"}]}`:              "expected a boolean, got string",
+		`{"rules": [{"effect": "allow", "when": "hour < 'x'"}]}`:        "cannot compare int with string",
+		`{"rules": [{"effect": "allow", "when": "repo.glob('x')"}]}`:    `unknown method "glob"`,
+		`{"rules": [{"effect": "allow", "when": "repo.matches('(')"}]}`: "matches:",
+		`{"rules": [], "timezone": "Mars/Base"}`:                        "timezone",
+		`{"rules": [], "extra": 1}`:                                     "unknown field",
+	}
+	for doc, want := range tests {
+		if _, err := Parse([]byte(doc)); err == nil || !strings.Contains(err.Error(), want) {
+			t.Errorf("Parse(%s) = %v, want error containing %q", doc, err, want)
+		}
+	}
+}
+
+func TestDefaultAndEqual(t *testing.T) {
+	allow, err := Parse([]byte(`{"default": "allow", "rules": []}`))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if d := allow.Evaluate(Input{User: "x"}); !d.Allowed || d.Reason != "policy default: allow" {
+		t.Fatalf("default = %+v", d)
+	}
+	deny, _ := Parse([]byte(`{"rules": []}`))
+	if deny.Evaluate(Input{}).Allowed {
+		t.Fatal("policies deny by default")
+	}
+	if allow.Equal(deny) || !allow.Equal(allow) || (*Policy)(nil).Equal(deny) || !(*Policy)(nil).Equal(nil) {
+		t.Fatal("Equal compares the documents")
+	}
+	if p, err := Load(""); p != nil || err != nil {
+		t.Fatalf("Load(\"\") = %v, %v", p, err)
+	}
+}
+
+const pushPolicy = `{
+  "default": "allow",
+  "rules": [
+    {"name": "weekend-dry-runs", "effect": "dry_run", "when": "weekday == 'Saturday' || weekday == 'Sunday'"},
+    {"name": "outsiders-plan", "effect": "require_approval", "when": "org_role == ''"},
+    {"name": "no-secrets-dir", "stage": "push", "effect": "deny", "when": "paths.startsWith('secrets/')", "message": "Leave secrets/ alone."},
+    {"name": "big-or-deploy", "stage": "push", "effect": "dry_run", "when": "changed_lines > 500 || paths.matches('^deploy/')"},
+    {"name": "many-files", "stage": "push", "effect": "require_approval", "when": "changed_files >= 20 && !('lead' in roles)"}
+  ]
+}`
+
+func TestEvaluateStages(t *testing.T) {
+	p, err := Parse([]byte(pushPolicy))
+	if err != nil {
+		t.Fatalf("Parse: %v", err)
+	}
+	if !p.HasPushRules() || !p.HasEffect(RequireApproval) || p.HasEffect("other") || (*Policy)(nil).HasPushRules() {
+		t.Fatal("HasPushRules/HasEffect")
+	}
+	thursday := time.Date(2026, 10, 15, 16, 0, 0, 0, time.UTC)
+	member := Input{User: "dev", OrgRole: "member", Repo: "acme/api", Command: "code", Time: thursday}
+
+	// the trigger rules ignore the push rules and hold without denying
+	if d := p.Evaluate(member); !d.Allowed || d.Effect != Allow || d.Rule != "" {
+		t.Fatalf("member = %+v", d)
+	}
+	if d := p.Evaluate(Input{User: "x", Time: thursday}); !d.Allowed || d.Effect != RequireApproval || d.Rule != "outsiders-plan" {
+		t.Fatalf("outsider = %+v", d)
+	}
+	if d := p.Evaluate(Input{User: "dev", OrgRole: "member", Time: thursday.AddDate(0, 0, 2)}); !d.Allowed || d.Effect != DryRun {
+		t.Fatalf("weekend = %+v", d)
+	}
+
+	tests := []struct {
+		name   string
+		paths  []string
+		lines  int
+		effect string
+		rule   string
+	}{
+		{"small", []string{"main.go", "main_test.go"}, 40, Allow, ""},
+		{"secrets", []string{"README.md", "secrets/key.txt"}, 2, Deny, "no-secrets-dir"},
+		{"big", []string{"main.go"}, 501, DryRun, "big-or-deploy"},
+		{"deploy", []string{"deploy/prod.yaml"}, 1, DryRun, "big-or-deploy"},
+		{"many files", make([]string, 20), 20, RequireApproval, "many-files"},
+	}
+	for _, tt := range tests {
+		in := member
+		in.Paths, in.ChangedFiles, in.ChangedLines = tt.paths, len(tt.paths), tt.lines
+		if d := p.EvaluatePush(in); d.Effect != tt.effect || d.Rule != tt.rule || d.Allowed != (tt.effect != Deny) {
+			t.Errorf("%s: EvaluatePush = %+v, want %s by %q", tt.name, d, tt.effect, tt.rule)
+		}
+	}
+	if d := (*Policy)(nil).EvaluatePush(member); !d.Allowed {
+		t.Fatalf("nil policy push = %+v", d)
+	}
+}
+
+func TestParseFlags(t *testing.T) {
+	got := ParseFlags("/code --Force fix it --model=opus -x --")
+	if want := []string{"force", "model"}; !reflect.DeepEqual(got, want) {
+		t.Fatalf("ParseFlags = %v, want %v", got, want)
+	}
+}
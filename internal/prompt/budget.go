@@ -0,0 +1,182 @@
+package prompt
+
+import (
+	"fmt"
+	"sort"
+	"strings"
+
+	ghdata "github.com/cexll/swe/internal/github/data"
+)
+
+// DefaultMaxContextTokens is the default budget of a prompt's GitHub
+// context: a long pull request fits next to the system prompt and leaves
+// the model room to work.
+const DefaultMaxContextTokens = 60000
+
+// DefaultRepoFileListMax is the default number of entries in a prompt's
+// repository file list.
+const DefaultRepoFileListMax = 300
+
+// Floors of the first trimming pass: file lists and the conversation are
+// cut down to these before anything is cut further.
+const (
+	minListedFiles = 50 // per file list
+	minComments    = 5  // newest comments
+	minReviews     = 2  // newest reviews
+)
+
+// EstimateTokens approximates the tokens s costs a model: about four bytes
+// per token, which holds for English prose and source code.
+func EstimateTokens(s string) int {
+	return (len(s) + 3) / 4
+}
+
+// promptContext is the GitHub context of a prompt, in the pieces the
+// budget trims.
+type promptContext struct {
+	xml              ghdata.GenerateXMLParams
+	repoFiles        []string
+	omittedRepoFiles int
+}
+
+// render formats c as the prompt's GitHubContext.
+func (c *promptContext) render() string {
+	return ghdata.GenerateXML(c.xml) + repoFilesSection(c.repoFiles, c.omittedRepoFiles)
+}
+
+// repoFilesSection lists the checkout's files and says how many more there
+// are, or returns "" without any.
+func repoFilesSection(files []string, omitted int) string {
+	if len(files) == 0 && omitted == 0 {
+		return ""
+	}
+	var b strings.Builder
+	b.WriteString("<repository_files>\n")
+	for _, f := range files {
+		b.WriteString(f)
+		b.WriteString("\n")
+	}
+	if omitted > 0 {
+		fmt.Fprintf(&b, "[... %d more files not listed ...]\n", omitted)
+	}
+	b.WriteString("</repository_files>\n")
+	return b.String()
+}
+
+// fit trims c to about maxTokens (0 leaves it whole). The largest file
+// list loses its last entries first, then the oldest comments and reviews
+// go, down to the min* floors; then the largest diffs go, then the linked
+// issues, last first, and finally the file lists, comments and reviews go
+// entirely. The issue or pull request itself and the trigger comment are
+// kept.
+func (c *promptContext) fit(maxTokens int) {
+	if maxTokens <= 0 {
+		return
+	}
+	over := EstimateTokens(c.render()) - maxTokens
+	if over <= 0 {
+		return
+	}
+
+	// work on copies, oldest first, so the fetched data is left alone
+	comments := append([]ghdata.Comment(nil), c.xml.Comments...)
+	sort.SliceStable(comments, func(i, j int) bool { return comments[i].CreatedAt < comments[j].CreatedAt })
+	var reviews []ghdata.Review
+	if c.xml.ReviewData != nil {
+		reviews = append(reviews, c.xml.ReviewData.Nodes...)
+		sort.SliceStable(reviews, func(i, j int) bool { return reviews[i].SubmittedAt < reviews[j].SubmittedAt })
+	}
+	changed := append([]ghdata.GitHubFileWithSHA(nil), c.xml.ChangedFilesWithSHA...)
+	repoFiles := append([]string(nil), c.repoFiles...)
+	patches := make(map[string]string, len(c.xml.ChangedFilePatches))
+	for path, patch := range c.xml.ChangedFilePatches {
+		patches[path] = patch
+	}
+
+	trimFiles := func(floor int) {
+		for over > 0 && (len(changed) > floor || len(repoFiles) > floor) {
+			if len(changed) >= len(repoFiles) {
+				f := changed[len(changed)-1]
+				changed = changed[:len(changed)-1]
+				c.xml.OmittedChangedFiles++
+				over -= EstimateTokens(f.Path+f.ChangeType+f.SHA) + 8
+			} else {
+				over -= EstimateTokens(repoFiles[len(repoFiles)-1]) + 1
+				repoFiles = repoFiles[:len(repoFiles)-1]
+				c.omittedRepoFiles++
+			}
+		}
+	}
+	trimConversation := func(minComments, minReviews int) {
+		for over > 0 && len(comments) > minComments {
+			if !comments[0].IsMinimized {
+				over -= commentTokens(comments[0])
+				c.xml.OmittedComments++
+			}
+			comments = comments[1:]
+		}
+		for over > 0 && len(reviews) > minReviews {
+			over -= reviewTokens(reviews[0])
+			reviews = reviews[1:]
+			c.xml.OmittedReviews++
+		}
+	}
+
+	trimFiles(minListedFiles)
+	trimConversation(minComments, minReviews)
+	for over > 0 && len(patches) > 0 {
+		largest := ""
+		for path, patch := range patches {
+			if largest == "" || len(patch) > len(patches[largest]) || (len(patch) == len(patches[largest]) && path < largest) {
+				largest = path
+			}
+		}
+		over -= EstimateTokens(largest+largest+patches[largest]) + 6
+		delete(patches, largest)
+		c.xml.OmittedPatches++
+	}
+	linked := c.xml.LinkedIssues
+	for over > 0 && len(linked) > 0 {
+		is := linked[len(linked)-1]
+		over -= EstimateTokens(is.Title+is.State+is.Repository.NameWithOwner+is.Body[:min(len(is.Body), ghdata.MaxLinkedIssueBody)]) + 8
+		linked = linked[:len(linked)-1]
+		c.xml.OmittedLinkedIssues++
+	}
+	trimFiles(0)
+	trimConversation(0, 0)
+
+	c.xml.Comments = comments
+	if c.xml.ReviewData != nil {
+		c.xml.ReviewData = &struct{ Nodes []ghdata.Review }{Nodes: reviews}
+	}
+	c.xml.ChangedFilesWithSHA = changed
+	c.xml.LinkedIssues = linked
+	if c.xml.ChangedFilePatches != nil {
+		c.xml.ChangedFilePatches = patches
+	}
+	c.repoFiles = repoFiles
+}
+
+// commentTokens estimates what c costs in the comments section, taking it
+// for a third party's.
+func commentTokens(c ghdata.Comment) int {
+	return EstimateTokens(c.Author.Login+c.CreatedAt+c.Body) + untrustedTokens(c.Author.Login) + 4
+}
+
+// reviewTokens estimates what r and its inline comments cost in the review
+// comments section.
+func reviewTokens(r ghdata.Review) int {
+	n := EstimateTokens(r.Author.Login+r.SubmittedAt+r.State+r.Body) + untrustedTokens(r.Author.Login) + 6
+	for _, c := range r.Comments.Nodes {
+		if !c.IsMinimized {
+			n += EstimateTokens(c.Path+c.Body) + untrustedTokens(c.Author.Login) + 6
+		}
+	}
+	return n
+}
+
+// untrustedTokens estimates what wrapping a body by author as untrusted
+// costs.
+func untrustedTokens(author string) int {
+	return (ghdata.UntrustedOverhead(author) + 3) / 4
+}
@@ -0,0 +1,97 @@
+package prompt
+
+import (
+	"fmt"
+	"strings"
+	"testing"
+
+	ghdata "github.com/cexll/swe/internal/github/data"
+)
+
+func TestBuildPromptWith_TrimsContextToBudget(t *testing.T) {
+	fetched := &ghdata.FetchResult{
+		ContextData: ghdata.PullRequest{Title: "Rewrite the parser", Body: "Keeps the grammar"},
+		Reviews:     &struct{ Nodes []ghdata.Review }{},
+	}
+	for i := 0; i < 200; i++ {
+		fetched.Comments = append(fetched.Comments, ghdata.Comment{
+			Author:    ghdata.Author{Login: "bob"},
+			CreatedAt: fmt.Sprintf("2026-10-01T%02d:%02d:00Z", i/60, i%60),
+			Body:      fmt.Sprintf("comment %03d %s", i, strings.Repeat("x", 400)),
+		})
+	}
+	for i := 0; i < 400; i++ {
+		fetched.ChangedSHA = append(fetched.ChangedSHA, ghdata.GitHubFileWithSHA{
+			File: ghdata.File{Path: fmt.Sprintf("pkg/file%03d.go", i), ChangeType: "MODIFIED"},
+			SHA:  strings.Repeat("a", 40),
+		})
+	}
+	var repoFiles []string
+	for i := 0; i < 100; i++ {
+		repoFiles = append(repoFiles, fmt.Sprintf("src/%03d.go", i))
+	}
+
+	whole := BuildPromptWith(testContext{isPR: true}, fetched, Options{RepoFiles: repoFiles})
+	got := BuildPromptWith(testContext{isPR: true}, fetched, Options{RepoFiles: repoFiles, MaxContextTokens: 8000})
+	if EstimateTokens(got) >= EstimateTokens(whole)/2 {
+		t.Fatalf("prompt not trimmed: %d of %d tokens", EstimateTokens(got), EstimateTokens(whole))
+	}
+	for _, want := range []string{
+		"comment 199 ",         // newest comment kept
+		"Rewrite the parser",   // the pull request itself
+		"/code fix the parser", // and the trigger
+		"pkg/file049.go",       // file lists keep their floor
+		"more changed files omitted to fit the context budget",
+		"older comments omitted to fit the context budget",
+	} {
+		if !strings.Contains(got, want) {
+			t.Errorf("trimmed prompt lacks %q", want)
+		}
+	}
+	if strings.Contains(got, "comment 000 ") || strings.Contains(got, "pkg/file399.go") {
+		t.Error("oldest comment or last changed file kept")
+	}
+	// the longer list goes first, until both are down to the floor
+	if !strings.Contains(got, "[... 50 more files not listed") || !strings.Contains(got, "[... 350 more changed files omitted") {
+		t.Error("file lists not trimmed longest first")
+	}
+	if len(fetched.Comments) != 200 || len(fetched.ChangedSHA) != 400 {
+		t.Fatal("fetched data modified")
+	}
+
+	// diffs go, largest first, once the conversation is down to its floor
+	fetched.Patches = map[string]string{"small.go": "+a", "large.go": strings.Repeat("+b\n", 20000)}
+	got = BuildPromptWith(testContext{isPR: true}, fetched, Options{MaxContextTokens: 6000})
+	if !strings.Contains(got, "diff --git a/small.go") || strings.Contains(got, "diff --git a/large.go") || !strings.Contains(got, "[... 1 more diff omitted") {
+		t.Fatalf("diffs not trimmed largest first:\n%s", got)
+	}
+	if len(fetched.Patches) != 2 {
+		t.Fatal("fetched diffs modified")
+	}
+	fetched.Patches = nil
+
+	// linked issues go next, last first
+	for i := 1; i <= 3; i++ {
+		fetched.Linked = append(fetched.Linked, ghdata.LinkedIssue{Number: i, Title: fmt.Sprintf("linked %d", i), Body: strings.Repeat("y", 4000)})
+	}
+	got = BuildPromptWith(testContext{isPR: true}, fetched, Options{MaxContextTokens: 1500})
+	if !strings.Contains(got, "[#1: linked 1]") || strings.Contains(got, "[#3: linked 3]") || !strings.Contains(got, "more linked issue") {
+		t.Fatalf("linked issues not trimmed last first:\n%s", got)
+	}
+	fetched.Linked = nil
+
+	// within budget, nothing is left out
+	if got := BuildPromptWith(testContext{isPR: true}, fetched, Options{RepoFiles: repoFiles, MaxContextTokens: 1 << 20}); got != whole {
+		t.Fatal("prompt within budget changed")
+	}
+}
+
+func TestRepoFilesSection(t *testing.T) {
+	if repoFilesSection(nil, 0) != "" {
+		t.Fatal("no files, no section")
+	}
+	want := "<repository_files>\na.go\n[... 3 more files not listed ...]\n</repository_files>\n"
+	if got := repoFilesSection([]string{"a.go"}, 3); got != want {
+		t.Fatalf("repoFilesSection = %q", got)
+	}
+}
@@ -3,10 +3,12 @@ package prompt
 import (
 	"bytes"
 	"fmt"
+	"log/slog"
 	"strings"
 	"text/template"
 
 	ghdata "github.com/cexll/swe/internal/github/data"
+	"github.com/cexll/swe/internal/logging"
 )
 
 // DefaultTriggerPhrase is used when no explicit trigger phrase is available.
@@ -18,6 +20,78 @@ const DefaultTriggerPhrase = "@assistant"
 // It handles both PR and Issue events and includes key metadata tags
 // (repository, issue/pr number, event type, trigger comment, etc.).
 func BuildPrompt(ctx GitHubContext, fetched *ghdata.FetchResult) string {
+	return BuildPromptWith(ctx, fetched, Options{})
+}
+
+// Options are what BuildPromptWith knows beyond the GitHub context.
+type Options struct {
+	// RepoPath is the task's checkout
+	RepoPath string
+	// Template replaces SystemPromptTemplate (nil keeps it); one that fails
+	// to render falls back to SystemPromptTemplate
+	Template *Template
+	// RepoFiles lists the checkout's files in the GitHub context (nil lists
+	// none); RepoFilesOmitted counts those the list leaves out
+	RepoFiles        []string
+	RepoFilesOmitted int
+	// MaxContextTokens bounds the GitHub context, as EstimateTokens counts
+	// it, by leaving out the oldest comments and the longest file lists
+	// first (0 keeps it whole)
+	MaxContextTokens int
+}
+
+// BuildPromptWith is BuildPrompt with opts.
+func BuildPromptWith(ctx GitHubContext, fetched *ghdata.FetchResult, opts Options) string {
+	return renderPrompt(templateData(ctx, fetched, opts), opts.Template)
+}
+
+// renderPrompt renders data with override, or SystemPromptTemplate.
+func renderPrompt(data promptTemplateData, override *Template) string {
+	if override != nil {
+		out, err := override.render(data)
+		if err == nil {
+			return out
+		}
+		slog.Warn("Prompt template override unusable; using the built-in template", logging.KeyPhase, "prompt", "path", override.Path, "err", err)
+	}
+
+	// Parse and execute template
+	tmpl, err := template.New("system-prompt").Parse(SystemPromptTemplate)
+	if err != nil {
+		// Fallback to basic prompt if template parsing fails
+		return fmt.Sprintf("Error parsing template: %v\n\n%s", err, data.GitHubContext)
+	}
+
+	// Execute template
+	var buf bytes.Buffer
+	if err := tmpl.Execute(&buf, data); err != nil {
+		// Fallback to basic prompt if template execution fails
+		return fmt.Sprintf("Error executing template: %v\n\n%s", err, data.GitHubContext)
+	}
+
+	return buf.String()
+}
+
+// promptTemplateData is what the system prompt template and its overrides
+// are rendered with.
+type promptTemplateData struct {
+	// GitHubContext is the issue or pull request, its comments and the
+	// trigger, formatted as XML
+	GitHubContext string
+	// CurrentBranch is the branch the task works on
+	CurrentBranch string
+	BaseBranch    string
+	Repository    string // owner/name
+	Number        int    // issue or pull request
+	IssueNumber   int    // as GitHub numbers the issue behind a pull request
+	IsPR          bool
+	RepoPath      string // the task's checkout
+	Mode          string // ModeIssue, ModePR or ModeLocal
+}
+
+// templateData gathers the template data of ctx, with the GitHub context
+// trimmed to opts.MaxContextTokens.
+func templateData(ctx GitHubContext, fetched *ghdata.FetchResult, opts Options) promptTemplateData {
 	// Derive event type and human-readable trigger context.
 	eventType, triggerCtx := eventTypeAndTriggerContext(ctx)
 
@@ -48,7 +122,7 @@ func BuildPrompt(ctx GitHubContext, fetched *ghdata.FetchResult) string {
 	triggerComment := ctx.GetTriggerCommentBody()
 
 	// Build XML using the shared formatter.
-	xml := ghdata.GenerateXML(ghdata.GenerateXMLParams{
+	gc := promptContext{repoFiles: opts.RepoFiles, omittedRepoFiles: opts.RepoFilesOmitted, xml: ghdata.GenerateXMLParams{
 		Repository:         repoFull,
 		IsPR:               ctx.IsPRContext(),
 		Number:             number,
@@ -65,14 +139,13 @@ func BuildPrompt(ctx GitHubContext, fetched *ghdata.FetchResult) string {
 		Comments:            fetchedComments(fetched),
 		ReviewData:          fetchedReviews(fetched),
 		ChangedFilesWithSHA: fetchedChangedWithSHA(fetched),
+		ChangedFilePatches:  fetchedPatches(fetched),
+		LinkedIssues:        fetchedLinked(fetched),
 		ImageURLMap:         fetchedImageMap(fetched),
-	})
-
-	// Parse and execute template
-	tmpl, err := template.New("system-prompt").Parse(SystemPromptTemplate)
-	if err != nil {
-		// Fallback to basic prompt if template parsing fails
-		return fmt.Sprintf("Error parsing template: %v\n\n%s", err, xml)
+	}}
+	gc.fit(opts.MaxContextTokens)
+	for _, in := range ghdata.DetectInjections(gc.xml) {
+		slog.Warn("Possible prompt injection", logging.KeyRepo, repoFull, logging.KeyPhase, "prompt", "number", number, "where", in.Where, "author", in.Author, "patterns", strings.Join(in.Patterns, ", "))
 	}
 
 	// Determine current branch (executor creates branch before calling AI)
@@ -84,20 +157,17 @@ func BuildPrompt(ctx GitHubContext, fetched *ghdata.FetchResult) string {
 		currentBranch = "main"
 	}
 
-	// Prepare template data
-	data := map[string]interface{}{
-		"GitHubContext": xml,
-		"CurrentBranch": currentBranch,
-	}
-
-	// Execute template
-	var buf bytes.Buffer
-	if err := tmpl.Execute(&buf, data); err != nil {
-		// Fallback to basic prompt if template execution fails
-		return fmt.Sprintf("Error executing template: %v\n\n%s", err, xml)
+	return promptTemplateData{
+		GitHubContext: gc.render(),
+		CurrentBranch: currentBranch,
+		BaseBranch:    ctx.GetBaseBranch(),
+		Repository:    repoFull,
+		Number:        number,
+		IssueNumber:   ctx.GetIssueNumber(),
+		IsPR:          ctx.IsPRContext(),
+		RepoPath:      opts.RepoPath,
+		Mode:          ModeOf(ctx),
 	}
-
-	return buf.String()
 }
 
 // fetchedContextData safely returns the ContextData or a zero value to satisfy
@@ -130,6 +200,20 @@ func fetchedChangedWithSHA(fr *ghdata.FetchResult) []ghdata.GitHubFileWithSHA {
 	return fr.ChangedSHA
 }
 
+func fetchedPatches(fr *ghdata.FetchResult) map[string]string {
+	if fr == nil {
+		return nil
+	}
+	return fr.Patches
+}
+
+func fetchedLinked(fr *ghdata.FetchResult) []ghdata.LinkedIssue {
+	if fr == nil {
+		return nil
+	}
+	return fr.Linked
+}
+
 func fetchedImageMap(fr *ghdata.FetchResult) map[string]string {
 	if fr == nil {
 		return nil
@@ -0,0 +1,45 @@
+package prompt
+
+import ghdata "github.com/cexll/swe/internal/github/data"
+
+// LocalRunInstructions is appended to the system prompt for local runs
+// (`swe-agent run`), where there is no GitHub issue, coordinating comment or
+// remote to push to. Being last, it overrides the GitHub workflow above.
+const LocalRunInstructions = `
+
+---
+
+<local_run>
+## LOCAL RUN OVERRIDES (take precedence over everything above)
+
+This task runs against a local checkout, not a GitHub issue or PR:
+- There is no coordinating comment: do NOT call ` + "`mcp__comment_updater__update_claude_comment`" + `
+- Do NOT push, open pull requests, or use ` + "`gh`" + ` commands
+- Do NOT commit; leave your changes in the working tree so the operator can review the diff
+- Run the project's build and tests when possible
+- Finish with a concise summary of what you changed and why
+</local_run>
+`
+
+// BuildLocalPrompt builds the prompt for a local run from the same template
+// as GitHub-triggered tasks. With no issue to fetch, a placeholder issue
+// carrying the instruction stands in for the GitHub data.
+func BuildLocalPrompt(ctx GitHubContext) string {
+	return BuildLocalPromptWith(ctx, Options{})
+}
+
+// BuildLocalPromptWith is BuildLocalPrompt with opts. The local run
+// instructions are appended whatever opts.Template says.
+func BuildLocalPromptWith(ctx GitHubContext, opts Options) string {
+	fetched := &ghdata.FetchResult{
+		ContextData: ghdata.Issue{
+			Title:  "Local task",
+			Body:   ctx.GetTriggerCommentBody(),
+			Author: ghdata.Author{Login: ctx.GetTriggerUser()},
+			State:  "OPEN",
+		},
+	}
+	data := templateData(ctx, fetched, opts)
+	data.Mode = ModeLocal
+	return renderPrompt(data, opts.Template) + LocalRunInstructions
+}
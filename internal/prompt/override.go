@@ -0,0 +1,165 @@
+package prompt
+
+import (
+	"bytes"
+	"errors"
+	"fmt"
+	"os"
+	"path/filepath"
+	"sort"
+	"strings"
+	"text/template"
+)
+
+// RepoTemplateDir is where a repository keeps its prompt template overrides,
+// relative to its root.
+const RepoTemplateDir = ".swe-agent/prompts"
+
+// Prompt template modes. A directory of overrides holds <mode>.tmpl for the
+// modes it changes and system.tmpl for all the others.
+const (
+	ModeIssue = "issue" // tasks triggered on an issue
+	ModePR    = "pr"    // tasks triggered on a pull request
+	ModeLocal = "local" // `swe-agent run`
+)
+
+// defaultTemplateName is the override used by modes without their own.
+const defaultTemplateName = "system"
+
+// templateExt is the file extension of prompt template overrides.
+const templateExt = ".tmpl"
+
+// maxTemplateSize bounds an override file.
+const maxTemplateSize = 256 << 10
+
+// contextMarker stands in for the GitHub context when overrides are
+// validated: a template that drops it would leave the agent without its task.
+const contextMarker = "<swe_agent_github_context/>"
+
+// Template is a prompt template override, replacing SystemPromptTemplate.
+type Template struct {
+	Path string // the file it was read from
+	tmpl *template.Template
+}
+
+// ModeOf returns the template mode of ctx: ModePR or ModeIssue.
+func ModeOf(ctx GitHubContext) string {
+	if ctx.IsPRContext() {
+		return ModePR
+	}
+	return ModeIssue
+}
+
+// LoadTemplate returns the override for mode from the first of dirs that has
+// one, <mode>.tmpl before system.tmpl; nil means SystemPromptTemplate
+// applies. Overrides that fail validation are skipped, so the next one (or
+// SystemPromptTemplate) applies, and returned as errors. Empty and missing
+// directories are skipped.
+func LoadTemplate(mode string, dirs ...string) (*Template, []error) {
+	var problems []error
+	for _, dir := range dirs {
+		if dir == "" {
+			continue
+		}
+		for _, name := range []string{mode, defaultTemplateName} {
+			t, err := ParseTemplateFile(filepath.Join(dir, name+templateExt))
+			if errors.Is(err, os.ErrNotExist) {
+				continue
+			}
+			if err != nil {
+				problems = append(problems, err)
+				continue
+			}
+			return t, problems
+		}
+	}
+	return nil, problems
+}
+
+// CheckTemplateDir validates every override in dir: each *.tmpl must be
+// named after a mode or system and render with sample data. An empty dir
+// has nothing to check.
+func CheckTemplateDir(dir string) error {
+	if dir == "" {
+		return nil
+	}
+	if info, err := os.Stat(dir); err != nil {
+		return err
+	} else if !info.IsDir() {
+		return fmt.Errorf("%s: not a directory", dir)
+	}
+	paths, err := filepath.Glob(filepath.Join(dir, "*"+templateExt))
+	if err != nil {
+		return err
+	}
+	sort.Strings(paths)
+	var problems []string
+	for _, path := range paths {
+		switch strings.TrimSuffix(filepath.Base(path), templateExt) {
+		case defaultTemplateName, ModeIssue, ModePR, ModeLocal:
+		default:
+			problems = append(problems, fmt.Sprintf("%s: not a prompt mode (want %s, %s, %s or %s%s)", path, ModeIssue, ModePR, ModeLocal, defaultTemplateName, templateExt))
+			continue
+		}
+		if _, err := ParseTemplateFile(path); err != nil {
+			problems = append(problems, err.Error())
+		}
+	}
+	if len(problems) > 0 {
+		return fmt.Errorf("invalid prompt templates:\n  %s", strings.Join(problems, "\n  "))
+	}
+	return nil
+}
+
+// ParseTemplateFile reads and validates the override at path: it must parse,
+// render with sample data and include {{.GitHubContext}}.
+func ParseTemplateFile(path string) (*Template, error) {
+	info, err := os.Stat(path)
+	if err != nil {
+		return nil, err
+	}
+	if !info.Mode().IsRegular() {
+		return nil, fmt.Errorf("%s: not a regular file", path)
+	}
+	if info.Size() > maxTemplateSize {
+		return nil, fmt.Errorf("%s: larger than %d KB", path, maxTemplateSize>>10)
+	}
+	src, err := os.ReadFile(path)
+	if err != nil {
+		return nil, err
+	}
+	tmpl, err := template.New(filepath.Base(path)).Parse(string(src))
+	if err != nil {
+		return nil, fmt.Errorf("%s: %w", path, err)
+	}
+	t := &Template{Path: path, tmpl: tmpl}
+	out, err := t.render(promptTemplateData{
+		GitHubContext: contextMarker,
+		CurrentBranch: "swe-agent/1-1",
+		BaseBranch:    "main",
+		Repository:    "owner/repo",
+		Number:        1,
+		IssueNumber:   1,
+		RepoPath:      "/tmp/repo",
+		Mode:          ModeIssue,
+	})
+	if err != nil {
+		return nil, fmt.Errorf("%s: %w", path, err)
+	}
+	if !strings.Contains(out, contextMarker) {
+		return nil, fmt.Errorf("%s: does not include {{.GitHubContext}}", path)
+	}
+	return t, nil
+}
+
+// render executes t with data.
+func (t *Template) render(data promptTemplateData) (string, error) {
+	var buf bytes.Buffer
+	if err := t.tmpl.Execute(&buf, data); err != nil {
+		return "", err
+	}
+	if strings.TrimSpace(buf.String()) == "" {
+		return "", errors.New("renders an empty prompt")
+	}
+	return buf.String(), nil
+}
@@ -0,0 +1,129 @@
+package prompt
+
+import (
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	ghdata "github.com/cexll/swe/internal/github/data"
+)
+
+var issueData = &ghdata.FetchResult{ContextData: ghdata.Issue{Title: "Parser"}}
+
+type testContext struct {
+	isPR bool
+}
+
+func (c testContext) GetEventName() string          { return "issue_comment" }
+func (c testContext) GetEventAction() string        { return "created" }
+func (c testContext) GetRepositoryFullName() string { return "acme/app" }
+func (c testContext) GetRepositoryOwner() string    { return "acme" }
+func (c testContext) GetRepositoryName() string     { return "app" }
+func (c testContext) IsPRContext() bool             { return c.isPR }
+func (c testContext) GetIssueNumber() int           { return 7 }
+func (c testContext) GetPRNumber() int              { return 8 }
+func (c testContext) GetBaseBranch() string         { return "main" }
+func (c testContext) GetHeadBranch() string         { return "" }
+func (c testContext) GetTriggerUser() string        { return "alice" }
+func (c testContext) GetActor() string              { return "alice" }
+func (c testContext) GetTriggerCommentBody() string { return "/code fix the parser" }
+func (c testContext) GetPreparedBranch() string     { return "swe-agent/7-1" }
+
+func writeTemplates(t *testing.T, files map[string]string) string {
+	t.Helper()
+	dir := t.TempDir()
+	for name, content := range files {
+		if err := os.WriteFile(filepath.Join(dir, name), []byte(content), 0o644); err != nil {
+			t.Fatal(err)
+		}
+	}
+	return dir
+}
+
+func TestLoadTemplate_ModeThenSystemThenNextDir(t *testing.T) {
+	repo := writeTemplates(t, map[string]string{
+		"pr.tmpl": "repo pr {{.Repository}}#{{.Number}} on {{.CurrentBranch}}\n{{.GitHubContext}}",
+	})
+	server := writeTemplates(t, map[string]string{
+		"system.tmpl": "server {{.Mode}}\n{{.GitHubContext}}",
+	})
+
+	pr, problems := LoadTemplate(ModePR, repo, server)
+	if pr == nil || len(problems) != 0 || pr.Path != filepath.Join(repo, "pr.tmpl") {
+		t.Fatalf("pr: %+v %v", pr, problems)
+	}
+	got := BuildPromptWith(testContext{isPR: true}, &ghdata.FetchResult{ContextData: ghdata.PullRequest{Title: "Parser"}}, Options{Template: pr})
+	if !strings.HasPrefix(got, "repo pr acme/app#8 on swe-agent/7-1\n") || !strings.Contains(got, "fix the parser") {
+		t.Fatalf("pr prompt:\n%s", got)
+	}
+
+	issue, _ := LoadTemplate(ModeIssue, "", repo, server)
+	if got := BuildPromptWith(testContext{}, issueData, Options{Template: issue}); !strings.HasPrefix(got, "server issue\n") {
+		t.Fatalf("issue prompt:\n%s", got)
+	}
+	if got := BuildLocalPromptWith(testContext{}, Options{Template: issue}); !strings.HasPrefix(got, "server local\n") || !strings.HasSuffix(got, LocalRunInstructions) {
+		t.Fatalf("local prompt:\n%s", got)
+	}
+
+	if none, problems := LoadTemplate(ModeIssue, t.TempDir(), filepath.Join(repo, "missing")); none != nil || problems != nil {
+		t.Fatalf("no overrides: %+v %v", none, problems)
+	}
+	if got := BuildPromptWith(testContext{}, issueData, Options{RepoPath: "/work/app"}); !strings.HasPrefix(got, "# SWE Agent System Prompt") || strings.Contains(got, "<no value>") || !strings.Contains(got, "cd /work/app") || !strings.Contains(got, "swe-agent/issue-7") {
+		t.Fatalf("built-in prompt:\n%s", got)
+	}
+}
+
+func TestLoadTemplate_SkipsInvalidOverrides(t *testing.T) {
+	repo := writeTemplates(t, map[string]string{
+		"issue.tmpl":  "{{.GitHubContext}} {{if}}",
+		"system.tmpl": "no context here",
+	})
+	server := writeTemplates(t, map[string]string{
+		"issue.tmpl":  "{{.Secrets}}{{.GitHubContext}}",
+		"system.tmpl": "server\n{{.GitHubContext}}",
+	})
+	tmpl, problems := LoadTemplate(ModeIssue, repo, server)
+	if tmpl == nil || tmpl.Path != filepath.Join(server, "system.tmpl") {
+		t.Fatalf("template = %+v", tmpl)
+	}
+	var msgs []string
+	for _, err := range problems {
+		msgs = append(msgs, err.Error())
+	}
+	joined := strings.Join(msgs, "\n")
+	if len(problems) != 3 || !strings.Contains(joined, "missing value for if") || !strings.Contains(joined, "does not include {{.GitHubContext}}") || !strings.Contains(joined, "can't evaluate field Secrets") {
+		t.Fatalf("problems:\n%s", joined)
+	}
+}
+
+func TestBuildPromptWith_FallsBackWhenRenderFails(t *testing.T) {
+	dir := writeTemplates(t, map[string]string{
+		"pr.tmpl": `{{.GitHubContext}}{{if eq .Mode "pr"}}{{index .Repository 99}}{{end}}`,
+	})
+	tmpl, problems := LoadTemplate(ModePR, dir)
+	if tmpl == nil || problems != nil {
+		t.Fatalf("%+v %v", tmpl, problems)
+	}
+	if got := BuildPromptWith(testContext{isPR: true}, &ghdata.FetchResult{ContextData: ghdata.PullRequest{}}, Options{Template: tmpl}); !strings.HasPrefix(got, "# SWE Agent System Prompt") {
+		t.Fatalf("prompt:\n%s", got)
+	}
+}
+
+func TestCheckTemplateDir(t *testing.T) {
+	if err := CheckTemplateDir(""); err != nil {
+		t.Fatal(err)
+	}
+	if err := CheckTemplateDir(filepath.Join(t.TempDir(), "missing")); err == nil {
+		t.Fatal("a missing directory passes")
+	}
+	good := writeTemplates(t, map[string]string{"system.tmpl": "{{.GitHubContext}}", "README.md": "notes"})
+	if err := CheckTemplateDir(good); err != nil {
+		t.Fatal(err)
+	}
+	bad := writeTemplates(t, map[string]string{"review.tmpl": "{{.GitHubContext}}", "pr.tmpl": "{{.Nope}}"})
+	err := CheckTemplateDir(bad)
+	if err == nil || !strings.Contains(err.Error(), "review.tmpl: not a prompt mode") || !strings.Contains(err.Error(), "pr.tmpl") {
+		t.Fatalf("err = %v", err)
+	}
+}
@@ -0,0 +1,171 @@
+// Package cassette records provider runs to fixture files and replays them,
+// so tests can exercise the executor end to end without a provider CLI or
+// API keys.
+//
+// A Recorder wraps a real provider and saves, for each GenerateCode call,
+// the sanitized request, the response, the progress and events reported and
+// the changes left in the repository (commits and working tree edits). A
+// Replayer serves the saved calls in order: it reapplies the changes to the
+// request's repository and reports the same steps and response.
+package cassette
+
+import (
+	"encoding/json"
+	"fmt"
+	"os"
+	"path/filepath"
+	"regexp"
+	"strings"
+	"time"
+
+	"github.com/cexll/swe/internal/provider"
+)
+
+// RecordEnv, set to "true", makes Open record cassettes from the live
+// provider instead of replaying them.
+const RecordEnv = "SWE_RECORD_CASSETTES"
+
+// repoPlaceholder stands for the request's repository path in recordings.
+const repoPlaceholder = "$REPO"
+
+// formatVersion is the version of the cassette file format.
+const formatVersion = 1
+
+// Cassette is the content of a fixture file.
+type Cassette struct {
+	Version      int           `json:"version"`
+	Provider     string        `json:"provider"`
+	RecordedAt   time.Time     `json:"recorded_at"`
+	Interactions []Interaction `json:"interactions"`
+}
+
+// Interaction is one recorded GenerateCode call.
+type Interaction struct {
+	Request Request `json:"request"`
+	Summary string  `json:"summary,omitempty"`
+	CostUSD float64 `json:"cost_usd,omitempty"`
+	// Error is the provider's error, if the call failed
+	Error      string           `json:"error,omitempty"`
+	Steps      []string         `json:"steps,omitempty"`
+	Events     []provider.Event `json:"events,omitempty"`
+	Transcript string           `json:"transcript,omitempty"`
+	// Commits are the commits the call made (git format-patch output) and
+	// Changes the edits it left uncommitted (a binary git diff)
+	Commits string `json:"commits,omitempty"`
+	Changes string `json:"changes,omitempty"`
+}
+
+// Request is the recorded part of a provider.CodeRequest.
+type Request struct {
+	Prompt          string            `json:"prompt"`
+	Context         map[string]string `json:"context,omitempty"`
+	AllowedTools    []string          `json:"allowed_tools,omitempty"`
+	DisallowedTools []string          `json:"disallowed_tools,omitempty"`
+}
+
+// Load reads the cassette in path.
+func Load(path string) (*Cassette, error) {
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return nil, err
+	}
+	var c Cassette
+	if err := json.Unmarshal(data, &c); err != nil {
+		return nil, fmt.Errorf("cassette %s: %w", path, err)
+	}
+	if c.Version != formatVersion {
+		return nil, fmt.Errorf("cassette %s: unsupported version %d", path, c.Version)
+	}
+	return &c, nil
+}
+
+// save writes c to path, creating its directory.
+func (c *Cassette) save(path string) error {
+	data, err := json.MarshalIndent(c, "", "  ")
+	if err != nil {
+		return err
+	}
+	if err := os.MkdirAll(filepath.Dir(path), 0o755); err != nil {
+		return err
+	}
+	return os.WriteFile(path, append(data, '\n'), 0o644)
+}
+
+// Open returns the provider a test runs against the cassette in path: with
+// RecordEnv set, a Recorder around the provider live builds, which then
+// overwrites the cassette; otherwise a Replayer of it.
+func Open(path string, live func() (provider.Provider, error)) (provider.Provider, error) {
+	if os.Getenv(RecordEnv) != "true" {
+		return NewReplayer(path)
+	}
+	p, err := live()
+	if err != nil {
+		return nil, fmt.Errorf("record %s: %w", path, err)
+	}
+	return NewRecorder(p, path), nil
+}
+
+// tokenPattern matches the credential formats scrubbed from recordings.
+var tokenPattern = regexp.MustCompile(`\b(?:(?:ghp|gho|ghu|ghs|ghr)_[A-Za-z0-9]{36}|github_pat_[A-Za-z0-9_]{82}|sk-ant-[A-Za-z0-9_-]{32,}|sk-(?:proj-)?[A-Za-z0-9_-]{20,}|xox[baprs]-[A-Za-z0-9-]{10,}|glpat-[A-Za-z0-9_-]{20}|(?:AKIA|ASIA)[0-9A-Z]{16}|eyJ[A-Za-z0-9_-]{10,}\.eyJ[A-Za-z0-9_-]{10,}\.[A-Za-z0-9_-]{10,})\b`)
+
+// sensitiveKey reports whether a context key or environment variable holds
+// a credential.
+func sensitiveKey(key string) bool {
+	key = strings.ToLower(key)
+	for _, word := range []string{"token", "key", "secret", "password"} {
+		if strings.Contains(key, word) {
+			return true
+		}
+	}
+	return false
+}
+
+// sanitizer scrubs one request's credentials and repository path from the
+// text recorded for it.
+type sanitizer struct {
+	replacer *strings.Replacer
+}
+
+func newSanitizer(req *provider.CodeRequest) sanitizer {
+	var pairs []string
+	for k, v := range req.Context {
+		if v != "" && sensitiveKey(k) {
+			pairs = append(pairs, v, "***")
+		}
+	}
+	for _, kv := range req.Env {
+		if k, v, ok := strings.Cut(kv, "="); ok && v != "" && sensitiveKey(k) {
+			pairs = append(pairs, v, "***")
+		}
+	}
+	if req.RepoPath != "" {
+		if resolved, err := filepath.EvalSymlinks(req.RepoPath); err == nil && resolved != req.RepoPath {
+			pairs = append(pairs, resolved, repoPlaceholder)
+		}
+		pairs = append(pairs, req.RepoPath, repoPlaceholder)
+	}
+	return sanitizer{replacer: strings.NewReplacer(pairs...)}
+}
+
+func (s sanitizer) clean(text string) string {
+	return tokenPattern.ReplaceAllString(s.replacer.Replace(text), "***")
+}
+
+// request records req.
+func (s sanitizer) request(req *provider.CodeRequest) Request {
+	r := Request{
+		Prompt:          s.clean(req.Prompt),
+		AllowedTools:    req.AllowedTools,
+		DisallowedTools: req.DisallowedTools,
+	}
+	if len(req.Context) > 0 {
+		r.Context = make(map[string]string, len(req.Context))
+		for k, v := range req.Context {
+			if sensitiveKey(k) && v != "" {
+				v = "***"
+			}
+			r.Context[k] = s.clean(v)
+		}
+	}
+	return r
+}
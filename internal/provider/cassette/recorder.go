@@ -0,0 +1,154 @@
+package cassette
+
+import (
+	"bytes"
+	"context"
+	"fmt"
+	"io"
+	"os"
+	"os/exec"
+	"path/filepath"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/cexll/swe/internal/provider"
+)
+
+// Recorder is a provider that runs another one and records its calls to a
+// cassette file, rewritten after each call.
+type Recorder struct {
+	inner provider.Provider
+	path  string
+
+	mu       sync.Mutex
+	cassette Cassette
+}
+
+// NewRecorder records the calls of p to the cassette in path, replacing
+// what it held.
+func NewRecorder(p provider.Provider, path string) *Recorder {
+	return &Recorder{inner: p, path: path, cassette: Cassette{Version: formatVersion, Provider: p.Name()}}
+}
+
+func (r *Recorder) Name() string { return r.inner.Name() }
+
+// GenerateCode runs the wrapped provider and records the call, whether it
+// succeeded or not. A failure to save the cassette fails the call.
+func (r *Recorder) GenerateCode(ctx context.Context, req *provider.CodeRequest) (*provider.CodeResponse, error) {
+	san := newSanitizer(req)
+	in := Interaction{Request: san.request(req)}
+	start := gitHead(req.RepoPath)
+
+	var mu sync.Mutex // steps and events may arrive from the provider's readers
+	wrapped := *req
+	if req.Progress != nil {
+		wrapped.Progress = func(step string) {
+			mu.Lock()
+			in.Steps = append(in.Steps, san.clean(step))
+			mu.Unlock()
+			req.Progress(step)
+		}
+	}
+	if req.Events != nil {
+		wrapped.Events = func(ev provider.Event) {
+			recorded := ev
+			recorded.Message = san.clean(ev.Message)
+			recorded.Tool = san.clean(ev.Tool)
+			mu.Lock()
+			in.Events = append(in.Events, recorded)
+			mu.Unlock()
+			req.Events(ev)
+		}
+	}
+	var transcript bytes.Buffer
+	if req.Transcript != nil {
+		wrapped.Transcript = io.MultiWriter(req.Transcript, &transcript)
+	} else {
+		wrapped.Transcript = &transcript
+	}
+
+	resp, err := r.inner.GenerateCode(ctx, &wrapped)
+
+	mu.Lock()
+	defer mu.Unlock()
+	in.Transcript = san.clean(transcript.String())
+	if resp != nil {
+		in.Summary = san.clean(resp.Summary)
+		in.CostUSD = resp.CostUSD
+	}
+	if err != nil {
+		in.Error = san.clean(err.Error())
+	}
+	if start != "" {
+		var gerr error
+		if in.Commits, in.Changes, gerr = repoChanges(req.RepoPath, start); gerr != nil {
+			return resp, fmt.Errorf("cassette: record changes: %w", gerr)
+		}
+		in.Commits, in.Changes = san.clean(in.Commits), san.clean(in.Changes)
+	}
+
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	r.cassette.RecordedAt = time.Now().UTC().Truncate(time.Second)
+	r.cassette.Interactions = append(r.cassette.Interactions, in)
+	if serr := r.cassette.save(r.path); serr != nil {
+		return resp, fmt.Errorf("cassette: save %s: %w", r.path, serr)
+	}
+	return resp, err
+}
+
+// gitHead returns the commit checked out in dir, or "" when dir is not a
+// git checkout with commits.
+func gitHead(dir string) string {
+	if dir == "" {
+		return ""
+	}
+	out, err := exec.Command("git", "-C", dir, "rev-parse", "--verify", "-q", "HEAD").Output()
+	if err != nil {
+		return ""
+	}
+	return strings.TrimSpace(string(out))
+}
+
+// repoChanges returns the commits made in dir since start and the edits
+// left on top of them, untracked files included. The real index is left
+// alone: the edits are staged in a copy of it.
+func repoChanges(dir, start string) (commits, changes string, err error) {
+	out, err := exec.Command("git", "-C", dir, "format-patch", "--stdout", "--binary", "--no-signature", start+"..HEAD").Output()
+	if err != nil {
+		return "", "", fmt.Errorf("format-patch: %w", err)
+	}
+	commits = string(out)
+
+	indexPath, err := exec.Command("git", "-C", dir, "rev-parse", "--path-format=absolute", "--git-path", "index").Output()
+	if err != nil {
+		return "", "", fmt.Errorf("locate index: %w", err)
+	}
+	tmp, err := os.CreateTemp("", "cassette-index-")
+	if err != nil {
+		return "", "", err
+	}
+	_ = tmp.Close()
+	defer func() { _ = os.Remove(tmp.Name()) }()
+	if data, err := os.ReadFile(strings.TrimSpace(string(indexPath))); err == nil {
+		if err := os.WriteFile(tmp.Name(), data, 0o600); err != nil {
+			return "", "", err
+		}
+	} else {
+		_ = os.Remove(tmp.Name()) // no index yet: git starts an empty one
+	}
+	env := append(os.Environ(), "GIT_INDEX_FILE="+filepath.Clean(tmp.Name()))
+	add := exec.Command("git", "-C", dir, "add", "-A")
+	add.Env = env
+	if out, err := add.CombinedOutput(); err != nil {
+		return "", "", fmt.Errorf("stage edits: %w: %s", err, out)
+	}
+	diff := exec.Command("git", "-C", dir, "diff", "--cached", "--binary", "HEAD")
+	diff.Env = env
+	out, err = diff.Output()
+	if err != nil {
+		return "", "", fmt.Errorf("diff edits: %w", err)
+	}
+	return commits, string(out), nil
+}
@@ -0,0 +1,129 @@
+package cassette
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"io"
+	"os"
+	"os/exec"
+	"strings"
+	"sync"
+
+	"github.com/cexll/swe/internal/provider"
+)
+
+// Replayer is a provider that serves the calls of a cassette in the order
+// they were recorded.
+type Replayer struct {
+	cassette *Cassette
+	path     string
+	// Strict, the default, fails a call whose prompt differs from the
+	// recorded one
+	Strict bool
+
+	mu   sync.Mutex
+	next int
+}
+
+// NewReplayer replays the cassette in path.
+func NewReplayer(path string) (*Replayer, error) {
+	c, err := Load(path)
+	if err != nil {
+		return nil, err
+	}
+	return &Replayer{cassette: c, path: path, Strict: true}, nil
+}
+
+func (r *Replayer) Name() string { return r.cassette.Provider }
+
+// Remaining returns how many recorded calls have not been served yet.
+func (r *Replayer) Remaining() int {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	return len(r.cassette.Interactions) - r.next
+}
+
+// GenerateCode serves the next recorded call: it applies the recorded
+// commits and edits to req.RepoPath, reports the recorded steps, events and
+// transcript, and returns the recorded response or error.
+func (r *Replayer) GenerateCode(ctx context.Context, req *provider.CodeRequest) (*provider.CodeResponse, error) {
+	if err := ctx.Err(); err != nil {
+		return nil, err
+	}
+	r.mu.Lock()
+	if r.next >= len(r.cassette.Interactions) {
+		r.mu.Unlock()
+		return nil, fmt.Errorf("cassette %s: no recorded call left (%d served)", r.path, r.next)
+	}
+	in := r.cassette.Interactions[r.next]
+	r.next++
+	r.mu.Unlock()
+
+	if r.Strict {
+		if got := newSanitizer(req).clean(req.Prompt); got != in.Request.Prompt {
+			return nil, fmt.Errorf("cassette %s: prompt differs from the recording (re-record with %s=true): %s", r.path, RecordEnv, firstDifference(in.Request.Prompt, got))
+		}
+	}
+
+	restore := strings.NewReplacer(repoPlaceholder, req.RepoPath)
+	if in.Commits != "" {
+		if err := git(req.RepoPath, restore.Replace(in.Commits), "am", "--quiet", "--keep-cr", "--committer-date-is-author-date"); err != nil {
+			return nil, fmt.Errorf("cassette: replay commits: %w", err)
+		}
+	}
+	if in.Changes != "" {
+		if err := git(req.RepoPath, restore.Replace(in.Changes), "apply", "--binary", "--whitespace=nowarn"); err != nil {
+			return nil, fmt.Errorf("cassette: replay edits: %w", err)
+		}
+	}
+	for _, step := range in.Steps {
+		if req.Progress != nil {
+			req.Progress(restore.Replace(step))
+		}
+	}
+	for _, ev := range in.Events {
+		if req.Events != nil {
+			ev.Message = restore.Replace(ev.Message)
+			req.Events(ev)
+		}
+	}
+	if req.Transcript != nil && in.Transcript != "" {
+		_, _ = io.WriteString(req.Transcript, restore.Replace(in.Transcript))
+	}
+
+	if in.Error != "" {
+		return nil, errors.New(restore.Replace(in.Error))
+	}
+	return &provider.CodeResponse{Summary: restore.Replace(in.Summary), CostUSD: in.CostUSD}, nil
+}
+
+// git runs a git command in dir with input on stdin. Replayed commits keep
+// their recorded author and date; the replayer commits them.
+func git(dir, input string, args ...string) error {
+	cmd := exec.Command("git", append([]string{"-C", dir}, args...)...)
+	cmd.Stdin = strings.NewReader(input)
+	cmd.Env = append(os.Environ(), "GIT_COMMITTER_NAME=swe-agent cassette", "GIT_COMMITTER_EMAIL=cassette@swe-agent.invalid")
+	if out, err := cmd.CombinedOutput(); err != nil {
+		return fmt.Errorf("git %s: %w: %s", args[0], err, strings.TrimSpace(string(out)))
+	}
+	return nil
+}
+
+// firstDifference describes the first line where got departs from want.
+func firstDifference(want, got string) string {
+	wl, gl := strings.Split(want, "\n"), strings.Split(got, "\n")
+	for i := 0; i < max(len(wl), len(gl)); i++ {
+		var w, g string
+		if i < len(wl) {
+			w = wl[i]
+		}
+		if i < len(gl) {
+			g = gl[i]
+		}
+		if w != g {
+			return fmt.Sprintf("line %d: recorded %q, got %q", i+1, w, g)
+		}
+	}
+	return "same lines"
+}
@@ -6,13 +6,15 @@ import (
 	"encoding/json"
 	"fmt"
 	"io"
-	"log"
+	"log/slog"
 	"os"
 	"os/exec"
 	"strings"
 	"time"
 
+	"github.com/cexll/swe/internal/logging"
 	"github.com/cexll/swe/internal/provider"
+	"github.com/cexll/swe/internal/provider/mcpconfig"
 	"github.com/cexll/swe/internal/provider/shared"
 )
 
@@ -25,7 +27,8 @@ type CLIResult struct {
 
 // Provider implements the AI provider interface for Claude
 type Provider struct {
-	model string
+	model   string
+	standby *standbyPool
 }
 
 // NewProvider creates a new Claude provider
@@ -38,7 +41,7 @@ func NewProvider(apiKey, model string) *Provider {
 	// Preserve ANTHROPIC_BASE_URL if already set in environment
 	// This allows using custom API endpoints
 	if baseURL := os.Getenv("ANTHROPIC_BASE_URL"); baseURL != "" {
-		log.Printf("[Claude] Using custom API endpoint: %s", baseURL)
+		claudeLog().Info("Using custom API endpoint", "url", baseURL)
 	}
 
 	return &Provider{
@@ -51,139 +54,68 @@ func (p *Provider) Name() string {
 	return "claude"
 }
 
-// buildMCPConfig dynamically generates MCP server configuration JSON with environment variables.
-// This mirrors the approach to avoid conflicts with user's ~/.claude.json.
-func buildMCPConfig(ctx map[string]string) (string, error) {
-	type MCPServerConfig struct {
-		Type    string            `json:"type,omitempty"`
-		URL     string            `json:"url,omitempty"`
-		Headers map[string]string `json:"headers,omitempty"`
-		Command string            `json:"command,omitempty"`
-		Args    []string          `json:"args,omitempty"`
-		Env     map[string]string `json:"env,omitempty"`
-	}
-
-	type MCPConfig struct {
-		MCPServers map[string]MCPServerConfig `json:"mcpServers"`
-	}
-
-	config := MCPConfig{
-		MCPServers: make(map[string]MCPServerConfig),
-	}
-
-	// Note: GitHub MCP and Git MCP removed to match Codex provider approach.
-	// AI will use git/gh CLI via Bash tool with explicit allowedTools list.
-
-	// Add Comment Updater MCP server if comment ID available and binary exists
-	if commentID := ctx["comment_id"]; commentID != "" {
-		owner := ctx["repo_owner"]
-		repo := ctx["repo_name"]
-		githubToken := ctx["github_token"]
-		eventName := ctx["event_name"]
-
-		if owner != "" && repo != "" && githubToken != "" {
-			// Check if mcp-comment-server binary exists in PATH (防御性检查)
-			if _, err := exec.LookPath("mcp-comment-server"); err == nil {
-				config.MCPServers["comment_updater"] = MCPServerConfig{
-					Command: "mcp-comment-server",
-					Env: map[string]string{
-						"GITHUB_TOKEN":      githubToken,
-						"REPO_OWNER":        owner,
-						"REPO_NAME":         repo,
-						"CLAUDE_COMMENT_ID": commentID,
-						"GITHUB_EVENT_NAME": eventName,
-					},
-				}
-				log.Printf("[MCP Config] Added comment_updater server (comment ID: %s)", commentID)
-			} else {
-				log.Printf("[MCP Config] Warning: mcp-comment-server not found in PATH, comment updates via MCP will be unavailable")
-			}
-		}
-	}
-
-	// Add Sequential Thinking MCP server (npx @modelcontextprotocol/server-sequential-thinking)
-	if _, err := exec.LookPath("npx"); err == nil {
-		config.MCPServers["sequential-thinking"] = MCPServerConfig{
-			Command: "npx",
-			Args:    []string{"-y", "@modelcontextprotocol/server-sequential-thinking"},
-		}
-		log.Printf("[MCP Config] Added sequential-thinking server")
-	} else {
-		log.Printf("[MCP Config] Warning: npx not found, sequential-thinking MCP will be unavailable")
-	}
-
-	// Add Fetch MCP server (uvx mcp-server-fetch)
-	if _, err := exec.LookPath("uvx"); err == nil {
-		config.MCPServers["fetch"] = MCPServerConfig{
-			Command: "uvx",
-			Args: []string{
-				"--from",
-				"git+https://github.com/cexll/mcp-server-fetch.git",
-				"mcp-server-fetch",
-			},
-		}
-		log.Printf("[MCP Config] Added fetch server")
-	}
-
-	// Log final MCP server configuration summary
-	serverNames := make([]string, 0, len(config.MCPServers))
-	for name := range config.MCPServers {
-		serverNames = append(serverNames, name)
-	}
-	if len(serverNames) > 0 {
-		log.Printf("[MCP Config] Total MCP servers configured: %d (%v)", len(serverNames), serverNames)
-	} else {
-		log.Printf("[MCP Config] Warning: No MCP servers configured")
-	}
-
-	// Marshal to JSON
-	blob, err := json.MarshalIndent(config, "", "  ")
-	if err != nil {
-		return "", fmt.Errorf("marshal MCP config: %w", err)
-	}
+// Model returns the configured Claude model
+func (p *Provider) Model() string {
+	return p.model
+}
 
-	return string(blob), nil
+// buildMCPConfig generates the per-task MCP server configuration JSON.
+// Passing it via --mcp-config avoids conflicts with the user's ~/.claude.json.
+func buildMCPConfig(req *provider.CodeRequest) (string, error) {
+	return mcpconfig.ClaudeJSON(mcpconfig.Build(req))
 }
 
-// callClaudeCLIWithTools calls the Claude CLI with explicit allowed/disallowed tools.
-// If lists are empty, flags are omitted to preserve CLI defaults.
-func callClaudeCLIWithTools(workDir, prompt, model string, allowedTools, disallowedTools []string, mcpConfig string) (*CLIResult, error) {
-	// Build command arguments
-	args := []string{"-p", "--output-format", "json"}
+// cliArgs returns the Claude CLI flags for model, tools and the MCP config
+// file.
+// If tool lists are empty, flags are omitted to preserve CLI defaults.
+func cliArgs(model string, allowedTools, disallowedTools []string, mcpConfig string) []string {
+	var args []string
 	if model != "" {
 		args = append(args, "--model", model)
 	}
 	if len(allowedTools) > 0 {
 		allowedCSV := strings.Join(allowedTools, ",")
 		args = append(args, "--allowedTools", allowedCSV)
-		log.Printf("[Claude CLI] Allowed tools (%d): %s", len(allowedTools), allowedCSV)
+		claudeLog().Debug("Allowed tools", "count", len(allowedTools), "tools", allowedCSV)
 	}
 	if len(disallowedTools) > 0 {
 		disallowedCSV := strings.Join(disallowedTools, ",")
 		args = append(args, "--disallowedTools", disallowedCSV)
-		log.Printf("[Claude CLI] Disallowed tools (%d): %s", len(disallowedTools), disallowedCSV)
+		claudeLog().Debug("Disallowed tools", "count", len(disallowedTools), "tools", disallowedCSV)
 	}
-	// Add MCP config if provided (dynamically generated)
+	// Add MCP config if provided (dynamically generated); --strict-mcp-config
+	// keeps servers from ~/.claude.json or the repository's .mcp.json out
 	if mcpConfig != "" {
-		args = append(args, "--mcp-config", mcpConfig)
-		log.Printf("[Claude CLI] Using dynamic MCP config (%d bytes)", len(mcpConfig))
+		args = append(args, "--mcp-config", mcpConfig, "--strict-mcp-config")
+		claudeLog().Debug("Using dynamic MCP config", "path", mcpConfig)
 	}
+	return args
+}
+
+// callClaudeCLIWithTools calls the Claude CLI with explicit allowed/disallowed
+// tools. With progress set the CLI streams its steps as stream-json. The raw
+// output is copied to transcript and the standard error to errOut when
+// non-nil. The CLI is killed when ctx is done.
+func callClaudeCLIWithTools(ctx context.Context, workDir, prompt, model string, allowedTools, disallowedTools []string, mcpConfig string, env []string, progress func(string), transcript, errOut io.Writer) (*CLIResult, error) {
+	format := []string{"-p", "--output-format", "json"}
+	if progress != nil {
+		format = []string{"-p", "--output-format", "stream-json", "--verbose"}
+	}
+	args := append(format, cliArgs(model, allowedTools, disallowedTools, mcpConfig)...)
 
 	// Create command
-	cmd := exec.Command("claude", args...)
+	cmd := exec.CommandContext(ctx, "claude", args...)
 	cmd.Dir = workDir // Critical: set working directory to cloned repo
 	cmd.Stdin = strings.NewReader(prompt)
 
 	// Explicitly pass environment variables to ensure Claude CLI gets MCP config
 	// Go's exec.Cmd inherits env by default if cmd.Env is nil, but we set it
 	// explicitly to ensure CLAUDE_CONFIG is passed through
-	cmd.Env = os.Environ()
+	cmd.Env = append(os.Environ(), env...)
 
 	// Enable debug logging if requested
 	if os.Getenv("DEBUG_CLAUDE_PARSING") == "true" {
-		log.Printf("[Claude CLI] Working directory: %s", workDir)
-		log.Printf("[Claude CLI] Command: claude %s", strings.Join(args, " "))
-		log.Printf("[Claude CLI] Prompt length: %d chars", len(prompt))
+		claudeLog().InfoContext(ctx, "Claude CLI command", "dir", workDir, "command", "claude "+strings.Join(args, " "), "prompt_chars", len(prompt))
 	}
 
 	// Create output buffer for later parsing
@@ -191,9 +123,18 @@ func callClaudeCLIWithTools(workDir, prompt, model string, allowedTools, disallo
 
 	// Enable real-time streaming: output to stdout + capture to buffer
 	cmd.Stdout = io.MultiWriter(os.Stdout, &outputBuf)
+	if progress != nil {
+		cmd.Stdout = io.MultiWriter(os.Stdout, &outputBuf, provider.ProgressWriter(progress, describeStreamEvent))
+	}
+	if transcript != nil {
+		cmd.Stdout = io.MultiWriter(cmd.Stdout, transcript)
+	}
 	cmd.Stderr = os.Stderr
+	if errOut != nil {
+		cmd.Stderr = io.MultiWriter(os.Stderr, errOut)
+	}
 
-	log.Printf("[Claude CLI] Execution started, streaming output...")
+	claudeLog().InfoContext(ctx, "Claude CLI started, streaming output")
 
 	// Execute command (non-blocking for output)
 	start := time.Now()
@@ -203,19 +144,20 @@ func callClaudeCLIWithTools(workDir, prompt, model string, allowedTools, disallo
 
 	if err != nil {
 		outputPreview := truncateString(string(output), 1000)
-		log.Printf("[Claude CLI] Command failed after %v: %v", duration, err)
-		log.Printf("[Claude CLI] Output preview: %s", outputPreview)
+		claudeLog().ErrorContext(ctx, "Claude CLI failed", "duration", duration, "err", err, "output_preview", outputPreview)
 		return nil, fmt.Errorf("claude CLI execution failed: %w (output preview: %s)", err, outputPreview)
 	}
 
-	log.Printf("[Claude CLI] Command completed in %v", duration)
+	claudeLog().InfoContext(ctx, "Claude CLI completed", "duration", duration)
+	if progress != nil {
+		return parseStreamOutput(string(output))
+	}
 
 	// Parse JSON response
 	var result CLIResult
 	if err := json.Unmarshal(output, &result); err != nil {
 		outputPreview := truncateString(string(output), 1000)
-		log.Printf("[Claude CLI] Failed to parse JSON response: %v", err)
-		log.Printf("[Claude CLI] Raw output preview: %s", outputPreview)
+		claudeLog().ErrorContext(ctx, "Parsing Claude CLI JSON response failed", "err", err, "output_preview", outputPreview)
 		return nil, fmt.Errorf("failed to parse claude CLI JSON response: %w (output preview: %s)", err, outputPreview)
 	}
 
@@ -227,8 +169,8 @@ func callClaudeCLIWithTools(workDir, prompt, model string, allowedTools, disallo
 }
 
 // GenerateCode generates code changes using Claude Code CLI
-func (p *Provider) GenerateCode(_ context.Context, req *provider.CodeRequest) (*provider.CodeResponse, error) {
-	log.Printf("[Claude] Starting code generation (prompt length: %d chars)", len(req.Prompt))
+func (p *Provider) GenerateCode(ctx context.Context, req *provider.CodeRequest) (*provider.CodeResponse, error) {
+	claudeLog().InfoContext(ctx, "Starting code generation", "prompt_chars", len(req.Prompt))
 
 	// Validate working directory
 	if req.RepoPath == "" {
@@ -241,49 +183,35 @@ func (p *Provider) GenerateCode(_ context.Context, req *provider.CodeRequest) (*
 	// Executor already constructed the full prompt (system + user + GH XML)
 	fullPrompt := req.Prompt
 
-	log.Printf("[Claude] Calling Claude CLI with model: %s in directory: %s", p.model, req.RepoPath)
+	claudeLog().InfoContext(ctx, "Calling Claude CLI", "model", p.model, "dir", req.RepoPath)
 
-	// Gather tools configuration
-	var allowed []string
-	var disallowed []string
-	if len(req.AllowedTools) > 0 {
-		allowed = append(allowed, req.AllowedTools...)
-	}
-	if len(req.DisallowedTools) > 0 {
-		disallowed = append(disallowed, req.DisallowedTools...)
-	}
-	// Back-compat: also allow context-based disallowed tools (comma-separated)
-	if req.Context != nil {
-		if s, ok := req.Context["disallowed_tools"]; ok && strings.TrimSpace(s) != "" {
-			disallowed = append(disallowed, s)
-		}
-	}
+	allowed, disallowed := requestTools(req)
+	mcpConfig, mcpContent := requestMCPConfig(req)
 
-	// Build dynamic MCP configuration with environment variables
-	// This replaces the static ~/.claude.json approach to avoid conflicts with user config
-	mcpConfig, err := buildMCPConfig(req.Context)
-	if err != nil {
-		log.Printf("[Claude] Warning: failed to build MCP config: %v", err)
-		mcpConfig = "" // Continue without dynamic MCP config
-	} else if mcpConfig != "" {
-		log.Printf("[Claude] Dynamic MCP config generated: %d bytes", len(mcpConfig))
-		if os.Getenv("DEBUG_MCP_CONFIG") == "true" {
-			log.Printf("[Claude] MCP config content:\n%s", mcpConfig)
-		}
+	// Attach to a session started by Prewarm, or call Claude CLI with correct
+	// working directory, tool configuration, and dynamic MCP config
+	var result *CLIResult
+	var err error
+	var s *session
+	if req.Stderr == nil { // a standby session's stderr cannot be captured
+		s = p.standby.take(p.sessionKey(req.RepoPath, allowed, disallowed, mcpContent, req.Env))
+	}
+	if s != nil {
+		claudeLog().InfoContext(ctx, "Attaching to standby session", "age", time.Since(s.started).Round(time.Millisecond))
+		result, err = s.run(ctx, fullPrompt, req.Progress, req.Transcript)
+	} else {
+		result, err = callClaudeCLIWithTools(ctx, req.RepoPath, fullPrompt, p.model, allowed, disallowed, mcpConfig, req.Env, req.Progress, req.Transcript, req.Stderr)
 	}
-
-	// Call Claude CLI with correct working directory, tool configuration, and dynamic MCP config
-	result, err := callClaudeCLIWithTools(req.RepoPath, fullPrompt, p.model, allowed, disallowed, mcpConfig)
 	if err != nil {
 		return nil, fmt.Errorf("claude CLI error: %w", err)
 	}
 
 	responseText := result.Result
-	log.Printf("[Claude] Response length: %d characters, cost: $%.4f", len(responseText), result.CostUSD)
+	claudeLog().InfoContext(ctx, "Claude response received", "chars", len(responseText), "cost_usd", result.CostUSD)
 
 	// Debug logging if requested
 	if os.Getenv("DEBUG_CLAUDE_PARSING") == "true" {
-		log.Printf("[Claude] Raw response: %s", responseText)
+		claudeLog().InfoContext(ctx, "Raw Claude response", "response", responseText)
 	}
 
 	// 5. Parse response
@@ -293,16 +221,53 @@ func (p *Provider) GenerateCode(_ context.Context, req *provider.CodeRequest) (*
 	}
 
 	// Return minimal response per new interface
-	log.Printf("[Claude] Response length: %d characters", len(responseText))
-	return &provider.CodeResponse{Summary: parsed.Summary}, nil
+	return &provider.CodeResponse{Summary: parsed.Summary, CostUSD: result.CostUSD}, nil
+}
+
+// requestTools gathers the allowed and disallowed tools of req.
+func requestTools(req *provider.CodeRequest) (allowed, disallowed []string) {
+	if len(req.AllowedTools) > 0 {
+		allowed = append(allowed, req.AllowedTools...)
+	}
+	if len(req.DisallowedTools) > 0 {
+		disallowed = append(disallowed, req.DisallowedTools...)
+	}
+	// Back-compat: also allow context-based disallowed tools (comma-separated)
+	if req.Context != nil {
+		if s, ok := req.Context["disallowed_tools"]; ok && strings.TrimSpace(s) != "" {
+			disallowed = append(disallowed, s)
+		}
+	}
+	return allowed, disallowed
+}
+
+// requestMCPConfig builds the dynamic MCP configuration of req and writes it
+// into the working directory, returning the file's path and its content (""
+// when it cannot be built). This replaces the static ~/.claude.json approach
+// to avoid conflicts with user config.
+func requestMCPConfig(req *provider.CodeRequest) (path, content string) {
+	mcpConfig, err := buildMCPConfig(req)
+	if err != nil {
+		claudeLog().Warn("Building MCP config failed", "err", err)
+		return "", "" // Continue without dynamic MCP config
+	}
+	path, err = mcpconfig.WriteClaudeConfig(req.RepoPath, mcpConfig)
+	if err != nil {
+		claudeLog().Warn("Writing MCP config failed", "err", err)
+		return "", ""
+	}
+	claudeLog().Info("Dynamic MCP config written", "path", path, "bytes", len(mcpConfig))
+	if os.Getenv("DEBUG_MCP_CONFIG") == "true" {
+		claudeLog().Info("MCP config content", "config", mcpConfig)
+	}
+	return path, mcpConfig
 }
 
 // parseCodeResponse extracts file changes and summary from Claude's response
 // Enhanced with multiple format support and debugging
 func parseCodeResponse(response string) (*provider.CodeResponse, error) {
 	if os.Getenv("DEBUG_CLAUDE_PARSING") == "true" {
-		log.Printf("[Parse] Parsing response of %d characters", len(response))
-		log.Printf("[Parse] Response preview: %s...", truncateString(response, 200))
+		claudeLog().Info("Parsing response", "chars", len(response), "preview", truncateString(response, 200))
 	}
 
 	parsed, err := shared.ParseResponse("Claude", response)
@@ -311,7 +276,7 @@ func parseCodeResponse(response string) (*provider.CodeResponse, error) {
 	}
 	result := &provider.CodeResponse{Summary: parsed.Summary}
 	if os.Getenv("DEBUG_CLAUDE_PARSING") == "true" {
-		log.Printf("[Parse] Summary: %s", truncateString(result.Summary, 100))
+		claudeLog().Info("Parsed summary", "summary", truncateString(result.Summary, 100))
 	}
 	return result, nil
 }
@@ -323,3 +288,9 @@ func truncateString(s string, maxLen int) string {
 	}
 	return s[:maxLen] + "..."
 }
+
+// claudeLog returns the logger for lines about Claude runs; logged with a
+// task's context, they carry its fields.
+func claudeLog() *slog.Logger {
+	return logging.Logger(logging.KeyPhase, "provider", "provider", "claude")
+}
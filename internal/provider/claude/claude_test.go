@@ -5,6 +5,8 @@ import (
 	"os"
 	"path/filepath"
 	"testing"
+
+	"github.com/cexll/swe/internal/provider"
 )
 
 func TestNewProvider(t *testing.T) {
@@ -84,7 +86,7 @@ func setUVXAvailability(t *testing.T, available bool) {
 	}
 }
 
-// setMCPCommentServerAvailability sets or unsets mcp-comment-server in PATH for testing
+// setMCPCommentServerAvailability sets or unsets swe-mcp in PATH for testing
 func setMCPCommentServerAvailability(t *testing.T, available bool) {
 	t.Helper()
 
@@ -97,9 +99,9 @@ func setMCPCommentServerAvailability(t *testing.T, available bool) {
 
 	if available {
 		dir := t.TempDir()
-		mcpPath := filepath.Join(dir, "mcp-comment-server")
+		mcpPath := filepath.Join(dir, "swe-mcp")
 		if err := os.WriteFile(mcpPath, []byte("#!/bin/sh\nexit 0\n"), 0o755); err != nil {
-			t.Fatalf("create mcp-comment-server stub: %v", err)
+			t.Fatalf("create swe-mcp stub: %v", err)
 		}
 		newPath := dir
 		if originalPath != "" {
@@ -143,7 +145,7 @@ func TestBuildMCPConfig_FullContext(t *testing.T) {
 			setUVXAvailability(t, true)
 			setMCPCommentServerAvailability(t, true)
 
-			raw, err := buildMCPConfig(tc.ctx)
+			raw, err := buildMCPConfig(&provider.CodeRequest{Context: tc.ctx})
 			if err != nil {
 				t.Fatalf("buildMCPConfig error: %v", err)
 			}
@@ -158,9 +160,12 @@ func TestBuildMCPConfig_FullContext(t *testing.T) {
 			if !ok {
 				t.Fatalf("comment_updater MCP server missing")
 			}
-			if comment.Command != "mcp-comment-server" {
+			if comment.Command != "swe-mcp" {
 				t.Fatalf("comment_updater command mismatch: %s", comment.Command)
 			}
+			if len(comment.Args) != 1 || comment.Args[0] != "comment" {
+				t.Fatalf("comment_updater args mismatch: %v", comment.Args)
+			}
 			env := comment.Env
 			wantEnv := map[string]string{
 				"GITHUB_TOKEN":      tc.ctx["github_token"],
@@ -195,7 +200,7 @@ func TestBuildMCPConfig_GitHubOnly(t *testing.T) {
 		t.Run(tc.name, func(t *testing.T) {
 			setUVXAvailability(t, tc.uvx)
 
-			raw, err := buildMCPConfig(ctx)
+			raw, err := buildMCPConfig(&provider.CodeRequest{Context: ctx})
 			if err != nil {
 				t.Fatalf("buildMCPConfig error: %v", err)
 			}
@@ -232,7 +237,7 @@ func TestBuildMCPConfig_EmptyContext(t *testing.T) {
 		t.Run(tc.name, func(t *testing.T) {
 			setUVXAvailability(t, tc.uvx)
 
-			raw, err := buildMCPConfig(ctx)
+			raw, err := buildMCPConfig(&provider.CodeRequest{Context: ctx})
 			if err != nil {
 				t.Fatalf("buildMCPConfig error: %v", err)
 			}
@@ -273,7 +278,7 @@ func TestBuildMCPConfig_PartialCommentContext(t *testing.T) {
 		t.Run(tc.name, func(t *testing.T) {
 			setUVXAvailability(t, tc.uvx)
 
-			raw, err := buildMCPConfig(ctx)
+			raw, err := buildMCPConfig(&provider.CodeRequest{Context: ctx})
 			if err != nil {
 				t.Fatalf("buildMCPConfig error: %v", err)
 			}
@@ -322,7 +327,7 @@ func TestBuildMCPConfig_JSONFormat(t *testing.T) {
 		t.Run(tc.name, func(t *testing.T) {
 			setUVXAvailability(t, tc.uvx)
 
-			raw, err := buildMCPConfig(tc.ctx)
+			raw, err := buildMCPConfig(&provider.CodeRequest{Context: tc.ctx})
 			if err != nil {
 				t.Fatalf("buildMCPConfig error: %v", err)
 			}
@@ -0,0 +1,49 @@
+package claude
+
+import (
+	"encoding/json"
+	"fmt"
+)
+
+// streamEvent is the part of a stream-json message that describes progress.
+type streamEvent struct {
+	Type    string `json:"type"`
+	Message struct {
+		Content []struct {
+			Type  string                 `json:"type"`
+			Text  string                 `json:"text"`
+			Name  string                 `json:"name"`
+			Input map[string]interface{} `json:"input"`
+		} `json:"content"`
+	} `json:"message"`
+}
+
+// toolInputKeys are the tool input fields that best summarise a call.
+var toolInputKeys = []string{"command", "file_path", "path", "pattern", "url", "query", "description"}
+
+// describeStreamEvent summarises a stream-json line as "Tool: argument" for
+// tool calls or the assistant's text; other lines describe nothing.
+func describeStreamEvent(line string) string {
+	var ev streamEvent
+	if json.Unmarshal([]byte(line), &ev) != nil || ev.Type != "assistant" {
+		return ""
+	}
+	step := ""
+	for _, c := range ev.Message.Content {
+		switch c.Type {
+		case "tool_use":
+			step = c.Name
+			for _, key := range toolInputKeys {
+				if v, ok := c.Input[key].(string); ok && v != "" {
+					step = fmt.Sprintf("%s: %s", c.Name, v)
+					break
+				}
+			}
+		case "text":
+			if c.Text != "" {
+				step = c.Text
+			}
+		}
+	}
+	return step
+}
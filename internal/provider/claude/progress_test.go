@@ -0,0 +1,68 @@
+package claude
+
+import (
+	"context"
+	"strings"
+	"testing"
+
+	prov "github.com/cexll/swe/internal/provider"
+)
+
+func TestDescribeStreamEvent(t *testing.T) {
+	tests := []struct {
+		line string
+		want string
+	}{
+		{`{"type":"assistant","message":{"content":[{"type":"tool_use","name":"Bash","input":{"command":"go test ./..."}}]}}`, "Bash: go test ./..."},
+		{`{"type":"assistant","message":{"content":[{"type":"tool_use","name":"Edit","input":{"file_path":"main.go","old_string":"a"}}]}}`, "Edit: main.go"},
+		{`{"type":"assistant","message":{"content":[{"type":"tool_use","name":"TodoWrite","input":{"todos":[]}}]}}`, "TodoWrite"},
+		{`{"type":"assistant","message":{"content":[{"type":"text","text":"Reading the handler"}]}}`, "Reading the handler"},
+		{`{"type":"user","message":{"content":[{"type":"tool_result","content":"ok"}]}}`, ""},
+		{`not json`, ""},
+	}
+	for _, tt := range tests {
+		if got := describeStreamEvent(tt.line); got != tt.want {
+			t.Errorf("describeStreamEvent(%s) = %q, want %q", tt.line, got, tt.want)
+		}
+	}
+}
+
+// progressCLI streams a tool call before its result when asked for stream-json.
+const progressCLI = `#!/bin/sh
+cat >/dev/null
+case "$*" in
+*stream-json*)
+	echo '{"type":"system","subtype":"init"}'
+	echo '{"type":"assistant","message":{"content":[{"type":"tool_use","name":"Bash","input":{"command":"make test"}}]}}'
+	echo '{"type":"result","subtype":"success","is_error":false,"result":"<summary>streamed</summary>","total_cost_usd":0.1}'
+	;;
+*)
+	echo '{"result":"<summary>plain</summary>","isError":false,"costUSD":0.5}'
+	;;
+esac
+`
+
+func TestProvider_GenerateCodeReportsProgress(t *testing.T) {
+	cliDir := t.TempDir()
+	writeExecutable(t, cliDir, "claude", progressCLI)
+	t.Cleanup(withPatchedPATH(t, cliDir))
+
+	p := NewProvider("fake", "claude-3")
+	var steps []string
+	req := &prov.CodeRequest{Prompt: "fix it", RepoPath: t.TempDir(), Progress: func(step string) { steps = append(steps, step) }}
+	resp, err := p.GenerateCode(context.Background(), req)
+	if err != nil {
+		t.Fatalf("GenerateCode: %v", err)
+	}
+	if resp.Summary != "streamed" || resp.CostUSD != 0.1 {
+		t.Fatalf("response = %+v, want the stream-json result", resp)
+	}
+	if strings.Join(steps, "|") != "Bash: make test" {
+		t.Fatalf("steps = %q", steps)
+	}
+
+	req.Progress = nil
+	if resp, err := p.GenerateCode(context.Background(), req); err != nil || resp.Summary != "plain" {
+		t.Fatalf("GenerateCode without progress = %+v, %v", resp, err)
+	}
+}
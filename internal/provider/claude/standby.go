@@ -0,0 +1,233 @@
+package claude
+
+import (
+	"bufio"
+	"bytes"
+	"context"
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"fmt"
+	"io"
+	"os"
+	"os/exec"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/cexll/swe/internal/provider"
+)
+
+// standbyIdleTimeout stops a pre-started session nobody attached to.
+var standbyIdleTimeout = 2 * time.Minute
+
+// SetStandby lets up to n pre-started CLI sessions wait for their prompt
+// (0 disables Prewarm).
+func (p *Provider) SetStandby(n int) {
+	if n <= 0 {
+		p.standby = nil
+		return
+	}
+	p.standby = &standbyPool{max: n, sessions: make(map[string]*session)}
+}
+
+// Prewarm starts the Claude CLI for req before its prompt is known. The CLI
+// binds the working directory, tools, MCP servers and environment when it
+// starts, so only a GenerateCode call with the same settings attaches to it.
+// A request capturing the CLI's stderr starts its own CLI.
+func (p *Provider) Prewarm(req *provider.CodeRequest) {
+	if p.standby == nil || req.RepoPath == "" || req.Stderr != nil {
+		return
+	}
+	allowed, disallowed := requestTools(req)
+	mcpConfig, mcpContent := requestMCPConfig(req)
+	key := p.sessionKey(req.RepoPath, allowed, disallowed, mcpContent, req.Env)
+	p.standby.start(key, func() (*session, error) {
+		return startSession(req.RepoPath, cliArgs(p.model, allowed, disallowed, mcpConfig), req.Env)
+	})
+}
+
+// sessionKey identifies the CLI settings a session was started with.
+func (p *Provider) sessionKey(workDir string, allowed, disallowed []string, mcpConfig string, env []string) string {
+	blob, _ := json.Marshal([]any{workDir, p.model, allowed, disallowed, mcpConfig, env})
+	sum := sha256.Sum256(blob)
+	return hex.EncodeToString(sum[:])
+}
+
+// standbyPool holds the sessions started by Prewarm until a task attaches.
+type standbyPool struct {
+	mu       sync.Mutex
+	max      int
+	sessions map[string]*session
+}
+
+func (sp *standbyPool) start(key string, launch func() (*session, error)) {
+	sp.mu.Lock()
+	defer sp.mu.Unlock()
+	if _, ok := sp.sessions[key]; ok || len(sp.sessions) >= sp.max {
+		return
+	}
+	s, err := launch()
+	if err != nil {
+		claudeLog().Warn("Standby session not started", "err", err)
+		return
+	}
+	sp.sessions[key] = s
+	s.idle = time.AfterFunc(standbyIdleTimeout, func() {
+		sp.mu.Lock()
+		if sp.sessions[key] == s {
+			delete(sp.sessions, key)
+		}
+		sp.mu.Unlock()
+		claudeLog().Info("Stopping unused standby session", "idle", standbyIdleTimeout)
+		s.stop()
+	})
+}
+
+// take removes and returns the live session started for key, or nil.
+func (sp *standbyPool) take(key string) *session {
+	if sp == nil {
+		return nil
+	}
+	sp.mu.Lock()
+	s := sp.sessions[key]
+	delete(sp.sessions, key)
+	sp.mu.Unlock()
+	if s == nil || !s.idle.Stop() {
+		return nil
+	}
+	select {
+	case <-s.done:
+		return nil // exited while waiting
+	default:
+		return s
+	}
+}
+
+// size reports the sessions waiting for a prompt.
+func (sp *standbyPool) size() int {
+	sp.mu.Lock()
+	defer sp.mu.Unlock()
+	return len(sp.sessions)
+}
+
+// session is a Claude CLI process reading its prompt as stream-json from stdin.
+type session struct {
+	cmd     *exec.Cmd
+	stdin   io.WriteCloser
+	output  bytes.Buffer
+	done    chan struct{}
+	err     error // set before done closes
+	started time.Time
+	idle    *time.Timer
+
+	progressMu sync.Mutex
+	progress   func(string) // set by run
+}
+
+func startSession(workDir string, args, env []string) (*session, error) {
+	args = append([]string{"-p", "--input-format", "stream-json", "--output-format", "stream-json", "--verbose"}, args...)
+	cmd := exec.Command("claude", args...)
+	cmd.Dir = workDir
+	cmd.Env = append(os.Environ(), env...)
+	cmd.Stderr = os.Stderr
+	s := &session{cmd: cmd, done: make(chan struct{}), started: time.Now()}
+	cmd.Stdout = io.MultiWriter(os.Stdout, &s.output, provider.ProgressWriter(s.report, describeStreamEvent))
+	stdin, err := cmd.StdinPipe()
+	if err != nil {
+		return nil, err
+	}
+	s.stdin = stdin
+	if err := cmd.Start(); err != nil {
+		return nil, err
+	}
+	go func() {
+		s.err = cmd.Wait()
+		close(s.done)
+	}()
+	claudeLog().Info("Standby session started", "dir", workDir)
+	return s, nil
+}
+
+func (s *session) stop() {
+	_ = s.stdin.Close()
+	_ = s.cmd.Process.Kill()
+	<-s.done
+}
+
+// report passes a progress step to the attached task, if it wants them.
+func (s *session) report(step string) {
+	s.progressMu.Lock()
+	defer s.progressMu.Unlock()
+	if s.progress != nil {
+		s.progress(step)
+	}
+}
+
+// streamResult is the final "result" message of stream-json output.
+type streamResult struct {
+	Type         string  `json:"type"`
+	Result       string  `json:"result"`
+	IsError      bool    `json:"is_error"`
+	TotalCostUSD float64 `json:"total_cost_usd"`
+}
+
+// run sends prompt as the session's only user message and waits for the
+// result, reporting the session's steps to progress and copying its output
+// to transcript (each when non-nil). The session is killed when ctx is done.
+func (s *session) run(ctx context.Context, prompt string, progress func(string), transcript io.Writer) (*CLIResult, error) {
+	s.progressMu.Lock()
+	s.progress = progress
+	s.progressMu.Unlock()
+	msg, _ := json.Marshal(map[string]any{
+		"type":    "user",
+		"message": map[string]string{"role": "user", "content": prompt},
+	})
+	start := time.Now()
+	_, werr := s.stdin.Write(append(msg, '\n'))
+	_ = s.stdin.Close()
+	select {
+	case <-s.done:
+	case <-ctx.Done():
+		_ = s.cmd.Process.Kill()
+		<-s.done
+	}
+	claudeLog().InfoContext(ctx, "Standby session completed", "duration", time.Since(start))
+
+	output := s.output.String()
+	if transcript != nil {
+		_, _ = io.WriteString(transcript, output)
+	}
+	if werr != nil || s.err != nil {
+		err := s.err
+		if err == nil {
+			err = werr
+		}
+		if ctx.Err() != nil {
+			err = fmt.Errorf("%w: %v", ctx.Err(), err)
+		}
+		preview := truncateString(output, 1000)
+		return nil, fmt.Errorf("claude CLI execution failed: %w (output preview: %s)", err, preview)
+	}
+	return parseStreamOutput(output)
+}
+
+// parseStreamOutput returns the final result of stream-json output.
+func parseStreamOutput(output string) (*CLIResult, error) {
+	var result *streamResult
+	sc := bufio.NewScanner(strings.NewReader(output))
+	sc.Buffer(make([]byte, 0, 64*1024), 16<<20)
+	for sc.Scan() {
+		var m streamResult
+		if json.Unmarshal(sc.Bytes(), &m) == nil && m.Type == "result" {
+			result = &m
+		}
+	}
+	if result == nil {
+		return nil, fmt.Errorf("claude CLI stream ended without a result (output preview: %s)", truncateString(output, 1000))
+	}
+	if result.IsError {
+		return nil, fmt.Errorf("claude CLI error: %s", result.Result)
+	}
+	return &CLIResult{Result: result.Result, CostUSD: result.TotalCostUSD}, nil
+}
@@ -0,0 +1,91 @@
+package claude
+
+import (
+	"context"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+
+	prov "github.com/cexll/swe/internal/provider"
+)
+
+// standbyCLI answers stream-json sessions with the prompt it read and -p
+// json calls with a fixed result, logging each start to $CLAUDE_LOG.
+const standbyCLI = `#!/bin/sh
+echo start >> "$CLAUDE_LOG"
+case "$*" in
+*stream-json*)
+	prompt=$(cat | sed 's/.*"content":"\([^"]*\)".*/\1/')
+	echo '{"type":"system","subtype":"init"}'
+	echo '{"type":"result","subtype":"success","is_error":false,"result":"<summary>warm '"$prompt"'</summary>","total_cost_usd":0.25}'
+	;;
+*)
+	cat >/dev/null
+	echo '{"result":"<summary>cold</summary>","isError":false,"costUSD":0.5}'
+	;;
+esac
+`
+
+func TestProvider_PrewarmAttachesStandbySession(t *testing.T) {
+	cliDir := t.TempDir()
+	writeExecutable(t, cliDir, "claude", standbyCLI)
+	t.Cleanup(withPatchedPATH(t, cliDir))
+	logFile := filepath.Join(t.TempDir(), "starts.log")
+	t.Setenv("CLAUDE_LOG", logFile)
+
+	p := NewProvider("fake", "claude-3")
+	p.SetStandby(1)
+	req := &prov.CodeRequest{RepoPath: t.TempDir(), AllowedTools: []string{"Read"}, Env: []string{"SWE_TASK=1"}}
+	p.Prewarm(req)
+	p.Prewarm(req) // already waiting
+	if p.standby.size() != 1 {
+		t.Fatalf("standby sessions = %d, want 1", p.standby.size())
+	}
+
+	req.Prompt = "fix it"
+	resp, err := p.GenerateCode(context.Background(), req)
+	if err != nil {
+		t.Fatalf("GenerateCode: %v", err)
+	}
+	if resp.Summary != "warm fix it" || resp.CostUSD != 0.25 {
+		t.Fatalf("response = %+v, want the standby session's result", resp)
+	}
+
+	// different settings start a fresh CLI
+	other := &prov.CodeRequest{Prompt: "again", RepoPath: req.RepoPath}
+	if resp, err := p.GenerateCode(context.Background(), other); err != nil || resp.Summary != "cold" {
+		t.Fatalf("cold GenerateCode = %+v, %v", resp, err)
+	}
+	data, _ := os.ReadFile(logFile)
+	if starts := strings.Count(string(data), "\n"); starts != 2 {
+		t.Fatalf("CLI started %d times:\n%s", starts, data)
+	}
+}
+
+func TestProvider_StandbySessionExpires(t *testing.T) {
+	cliDir := t.TempDir()
+	writeExecutable(t, cliDir, "claude", standbyCLI)
+	t.Cleanup(withPatchedPATH(t, cliDir))
+	t.Setenv("CLAUDE_LOG", filepath.Join(t.TempDir(), "starts.log"))
+	orig := standbyIdleTimeout
+	standbyIdleTimeout = 20 * time.Millisecond
+	t.Cleanup(func() { standbyIdleTimeout = orig })
+
+	p := NewProvider("fake", "claude-3")
+	p.SetStandby(2)
+	req := &prov.CodeRequest{RepoPath: t.TempDir()}
+	p.Prewarm(req)
+	deadline := time.Now().Add(2 * time.Second)
+	for p.standby.size() != 0 {
+		if time.Now().After(deadline) {
+			t.Fatal("idle standby session was not stopped")
+		}
+		time.Sleep(5 * time.Millisecond)
+	}
+	req.Prompt = "late"
+	if resp, err := p.GenerateCode(context.Background(), req); err != nil || resp.Summary != "cold" {
+		t.Fatalf("GenerateCode after expiry = %+v, %v", resp, err)
+	}
+}
@@ -7,13 +7,16 @@ import (
 	"encoding/json"
 	"fmt"
 	"io"
-	"log"
+	"log/slog"
 	"os"
 	"os/exec"
+	"path/filepath"
 	"strings"
 	"time"
 
+	"github.com/cexll/swe/internal/logging"
 	"github.com/cexll/swe/internal/provider"
+	"github.com/cexll/swe/internal/provider/mcpconfig"
 )
 
 const (
@@ -56,69 +59,85 @@ func (p *Provider) Name() string {
 	return "codex"
 }
 
+// Model returns the configured Codex model
+func (p *Provider) Model() string {
+	return p.model
+}
+
 // GenerateCode generates code changes using Codex MCP CLI
 func (p *Provider) GenerateCode(ctx context.Context, req *provider.CodeRequest) (*provider.CodeResponse, error) {
-	log.Printf("[Codex] Starting code generation (prompt length: %d chars)", len(req.Prompt))
+	codexLog().InfoContext(ctx, "Starting code generation", "prompt_chars", len(req.Prompt))
 
-	// Build dynamic MCP configuration (writes to ~/.codex/config.toml)
-	if err := buildCodexMCPConfig(req.Context); err != nil {
-		log.Printf("[Codex] Warning: failed to build MCP config: %v", err)
+	// Build per-task MCP configuration in a private CODEX_HOME so task-scoped
+	// tokens and comment IDs never leak into the shared ~/.codex/config.toml
+	codexHome, err := buildCodexMCPConfig(req)
+	if err != nil {
+		codexLog().WarnContext(ctx, "Building MCP config failed", "err", err)
 		// Continue without dynamic MCP config
 	} else {
-		log.Printf("[Codex] Dynamic MCP config written to ~/.codex/config.toml")
+		defer func() { _ = os.RemoveAll(codexHome) }()
+		codexLog().InfoContext(ctx, "Dynamic MCP config written", "path", codexHome)
 		if os.Getenv("DEBUG_MCP_CONFIG") == "true" {
-			if home, err := os.UserHomeDir(); err == nil {
-				configPath := home + string(os.PathSeparator) + ".codex" + string(os.PathSeparator) + "config.toml"
-				if content, err := os.ReadFile(configPath); err == nil {
-					log.Printf("[Codex] MCP config content:\n%s", string(content))
-				}
+			if content, err := os.ReadFile(filepath.Join(codexHome, "config.toml")); err == nil {
+				codexLog().InfoContext(ctx, "MCP config content", "config", string(content))
 			}
 		}
 	}
 
-	// Provide GitHub token to MCP tools via env (backup method)
-	if req.Context != nil {
-		if tok, ok := req.Context["github_token"]; ok && tok != "" {
-			_ = os.Setenv("GITHUB_TOKEN", tok)
-			_ = os.Setenv("GH_TOKEN", tok)
-		}
+	// Task-scoped environment for the Codex process (and the MCP tools it spawns)
+	var taskEnv []string
+	if codexHome != "" {
+		taskEnv = append(taskEnv, "CODEX_HOME="+codexHome)
+	}
+	if tok := req.Context["github_token"]; tok != "" {
+		taskEnv = append(taskEnv, "GITHUB_TOKEN="+tok, "GH_TOKEN="+tok)
 	}
-	// Ensure sandbox runs with full access per instruction
-	_ = os.Setenv("SANDBOX_MODE", "danger-full-access")
+	taskEnv = append(taskEnv, req.Env...)
 
 	// Executor already constructed the full prompt (system + user + GH XML)
 	fullPrompt := executionPrefix + req.Prompt
 
-	responseText, err := p.invokeCodex(ctx, fullPrompt, req.RepoPath)
+	responseText, err := p.invokeCodex(ctx, fullPrompt, req.RepoPath, req.Progress, req.Events, req.Transcript, req.Stderr, taskEnv...)
 	if err != nil {
 		return nil, err
 	}
 
 	// We only need to return a summary for bookkeeping.
-	log.Printf("[Codex] Response length: %d characters", len(responseText))
+	codexLog().InfoContext(ctx, "Codex response received", "chars", len(responseText))
 	return &provider.CodeResponse{Summary: truncateLogString(responseText, 2000)}, nil
 }
 
-func (p *Provider) invokeCodex(ctx context.Context, prompt, repoPath string) (string, error) {
-	ctx, cancel := ensureCodexTimeout(ctx)
-	defer cancel()
-
-	cmd, stdout, stderr := p.buildCodexCommand(ctx, repoPath, prompt)
+// invokeCodex runs codex exec, reporting its steps to progress, its
+// structured events to events, its raw output to transcript and its
+// standard error to errOut (each when non-nil).
+func (p *Provider) invokeCodex(ctx context.Context, prompt, repoPath string, progress func(string), events func(provider.Event), transcript, errOut io.Writer, taskEnv ...string) (string, error) {
+	cmd, stdout, stderr := p.buildCodexCommand(ctx, repoPath, prompt, taskEnv)
+	if errOut != nil {
+		cmd.Stderr = io.MultiWriter(cmd.Stderr, errOut)
+	}
+	if progress != nil {
+		cmd.Stdout = io.MultiWriter(cmd.Stdout, provider.ProgressWriter(progress, describeCodexEvent))
+	}
+	if events != nil {
+		cmd.Stdout = io.MultiWriter(cmd.Stdout, provider.NewLineWriter(newCodexEvents(events).line))
+	}
+	if transcript != nil {
+		cmd.Stdout = io.MultiWriter(cmd.Stdout, transcript)
+	}
 
-	log.Printf("[Codex] Executing: codex exec -m %s -c model_reasoning_effort=\"high\" --dangerously-bypass-approvals-and-sandbox -C %s (streaming output...)", p.model, repoPath)
-	log.Printf("[Codex] Prompt length: %d characters", len(prompt))
+	codexLog().InfoContext(ctx, "Executing codex exec, streaming output", "model", p.model, "dir", repoPath, "prompt_chars", len(prompt))
 
 	startTime := time.Now()
 	if err := cmd.Run(); err != nil {
 		duration := time.Since(startTime)
-		log.Printf("[Codex] Command failed after %v", duration)
+		codexLog().ErrorContext(ctx, "Codex CLI failed", "duration", duration)
 
 		stderrPreview := summarizeCodexError(err, stdout, stderr)
 		if ctx.Err() == context.DeadlineExceeded {
 			return "", fmt.Errorf("codex CLI timeout after %v: %s", duration, stderrPreview)
 		}
 
-		log.Printf("[Codex] Error: %s", stderrPreview)
+		codexLog().ErrorContext(ctx, "Codex CLI error", "stderr_preview", stderrPreview)
 		return "", fmt.Errorf("codex CLI error: %s", stderrPreview)
 	}
 
@@ -129,7 +148,7 @@ func (p *Provider) invokeCodex(ctx context.Context, prompt, repoPath string) (st
 		parsedOutput = strings.TrimSpace(output)
 	}
 
-	log.Printf("[Codex] Command completed in %v, output length: %d bytes", duration, len(output))
+	codexLog().InfoContext(ctx, "Codex CLI completed", "duration", duration, "bytes", len(output))
 
 	return parsedOutput, nil
 }
@@ -200,7 +219,7 @@ func aggregateCodexOutput(output string) string {
 	}
 
 	if err := scanner.Err(); err != nil {
-		log.Printf("[Codex] Warning: failed to scan JSON output: %v", err)
+		codexLog().Warn("Scanning JSON output failed", "err", err)
 	}
 
 	if len(sections) == 0 {
@@ -230,6 +249,24 @@ func extractMessageFromJSONLine(line string) (string, bool) {
 	return "", true
 }
 
+// describeCodexEvent summarises a --json event line: the command being run,
+// or the text of a message; other lines describe nothing.
+func describeCodexEvent(line string) string {
+	var envelope map[string]interface{}
+	if err := json.Unmarshal([]byte(line), &envelope); err != nil {
+		return ""
+	}
+	item, ok := envelope["item"].(map[string]interface{})
+	if !ok {
+		msg, _ := getString(envelope, "message")
+		return msg
+	}
+	if cmd, ok := getString(item, "command"); ok && cmd != "" {
+		return "command: " + cmd
+	}
+	return extractTextFromItem(item)
+}
+
 func extractTextFromItem(item interface{}) string {
 	itemMap, ok := item.(map[string]interface{})
 	if !ok {
@@ -269,14 +306,7 @@ func getString(m map[string]interface{}, key string) (string, bool) {
 	return "", false
 }
 
-func ensureCodexTimeout(ctx context.Context) (context.Context, context.CancelFunc) {
-	if _, hasDeadline := ctx.Deadline(); hasDeadline {
-		return ctx, func() {}
-	}
-	return context.WithTimeout(ctx, 10*time.Minute)
-}
-
-func (p *Provider) buildCodexCommand(ctx context.Context, repoPath, prompt string) (*exec.Cmd, *bytes.Buffer, *bytes.Buffer) {
+func (p *Provider) buildCodexCommand(ctx context.Context, repoPath, prompt string, taskEnv []string) (*exec.Cmd, *bytes.Buffer, *bytes.Buffer) {
 	args := []string{
 		"exec",
 		"-m", p.model,
@@ -296,15 +326,9 @@ func (p *Provider) buildCodexCommand(ctx context.Context, repoPath, prompt strin
 	if p.baseURL != "" {
 		env = append(env, "OPENAI_BASE_URL="+p.baseURL)
 	}
-	// Pass through GitHub token for MCP tools
-	if gh := os.Getenv("GITHUB_TOKEN"); gh != "" {
-		env = append(env, "GITHUB_TOKEN="+gh, "GH_TOKEN="+gh)
-	}
-	// Prefer request-scoped token if provided in context
-	// Note: executor should set this env before invoking provider, but we also
-	// propagate if present in req.Context to be explicit.
-	// (We cannot read req here, so ensure executor sets process env.)
 	env = append(env, "SANDBOX_MODE=danger-full-access")
+	// Task-scoped values come last so they override the host environment
+	env = append(env, taskEnv...)
 	cmd.Env = env
 
 	var stdout bytes.Buffer
@@ -336,23 +360,15 @@ func summarizeCodexError(runErr error, stdout, stderr *bytes.Buffer) string {
 	return truncateLogString(stderrText, 1000)
 }
 
-// buildCodexMCPConfig dynamically generates Codex MCP configuration TOML file.
-// This writes to ~/.codex/config.toml to configure MCP servers with runtime context.
-func buildCodexMCPConfig(ctx map[string]string) error {
-	home, err := os.UserHomeDir()
+// buildCodexMCPConfig writes a per-task Codex home containing config.toml with
+// the task's MCP servers and returns its path. Callers point CODEX_HOME at it
+// and remove it once the task completes.
+func buildCodexMCPConfig(req *provider.CodeRequest) (string, error) {
+	codexHome, err := os.MkdirTemp("", "swe-codex-")
 	if err != nil {
-		return fmt.Errorf("get home dir: %w", err)
-	}
-
-	configDir := home + string(os.PathSeparator) + ".codex"
-	configPath := configDir + string(os.PathSeparator) + "config.toml"
-
-	// Ensure config directory exists
-	if err := os.MkdirAll(configDir, 0o700); err != nil {
-		return fmt.Errorf("create codex config dir: %w", err)
+		return "", fmt.Errorf("create codex home: %w", err)
 	}
 
-	// Build TOML configuration
 	var sb strings.Builder
 	sb.WriteString("# Dynamically generated Codex configuration\n")
 	sb.WriteString("model = \"gpt-5-codex\"\n")
@@ -362,53 +378,48 @@ func buildCodexMCPConfig(ctx map[string]string) error {
 	sb.WriteString("sandbox_mode = \"danger-full-access\"\n")
 	sb.WriteString("disable_response_storage = true\n")
 	sb.WriteString("network_access = true\n\n")
+	sb.WriteString(mcpconfig.CodexTOML(mcpconfig.Build(req)))
 
-	// Note: GitHub MCP and Git MCP removed to avoid Codex TOML 'command' field issues.
-	// AI will use git/gh CLI via Bash tool with explicit allowedTools list.
-
-	// Add Comment Updater MCP server if comment ID available
-	if commentID := ctx["comment_id"]; commentID != "" {
-		owner := ctx["repo_owner"]
-		repo := ctx["repo_name"]
-		githubToken := ctx["github_token"]
-		eventName := ctx["event_name"]
-
-		if owner != "" && repo != "" && githubToken != "" {
-			sb.WriteString("[mcp_servers.comment_updater]\n")
-			sb.WriteString("command = \"mcp-comment-server\"\n\n")
-			sb.WriteString("[mcp_servers.comment_updater.env]\n")
-			sb.WriteString(fmt.Sprintf("GITHUB_TOKEN = \"%s\"\n", githubToken))
-			sb.WriteString(fmt.Sprintf("REPO_OWNER = \"%s\"\n", owner))
-			sb.WriteString(fmt.Sprintf("REPO_NAME = \"%s\"\n", repo))
-			sb.WriteString(fmt.Sprintf("CLAUDE_COMMENT_ID = \"%s\"\n", commentID))
-			if eventName != "" {
-				sb.WriteString(fmt.Sprintf("GITHUB_EVENT_NAME = \"%s\"\n", eventName))
-			}
-			sb.WriteString("\n")
-		}
+	configPath := filepath.Join(codexHome, "config.toml")
+	if err := os.WriteFile(configPath, []byte(sb.String()), 0o600); err != nil {
+		_ = os.RemoveAll(codexHome)
+		return "", fmt.Errorf("write codex config: %w", err)
 	}
 
-	// Add Sequential Thinking MCP server (npx @modelcontextprotocol/server-sequential-thinking)
-	if _, err := exec.LookPath("npx"); err == nil {
-		sb.WriteString("[mcp_servers.sequential_thinking]\n")
-		sb.WriteString("command = \"npx\"\n")
-		sb.WriteString("args = [\"-y\", \"@modelcontextprotocol/server-sequential-thinking\"]\n\n")
-		log.Printf("[Codex MCP] Added sequential-thinking server")
+	// Keep `codex login` credentials working with the private home
+	if err := copyCodexAuth(codexHome); err != nil {
+		codexLog().Warn("Copying codex credentials failed", "err", err)
 	}
 
-	// Add Fetch MCP server (uvx mcp-server-fetch)
-	if _, err := exec.LookPath("uvx"); err == nil {
-		sb.WriteString("[mcp_servers.fetch]\n")
-		sb.WriteString("command = \"uvx\"\n")
-		sb.WriteString("args = [\"--from\", \"git+https://github.com/cexll/mcp-server-fetch.git\", \"mcp-server-fetch\"]\n\n")
-		log.Printf("[Codex MCP] Added fetch server")
-	}
+	codexLog().Debug("MCP config written", "path", configPath)
+	return codexHome, nil
+}
 
-	// Write configuration file
-	if err := os.WriteFile(configPath, []byte(sb.String()), 0o600); err != nil {
-		return fmt.Errorf("write codex config: %w", err)
+// copyCodexAuth copies auth.json from the host Codex home, if present.
+func copyCodexAuth(codexHome string) error {
+	hostHome := os.Getenv("CODEX_HOME")
+	if hostHome == "" {
+		home, err := os.UserHomeDir()
+		if err != nil {
+			return nil
+		}
+		hostHome = filepath.Join(home, ".codex")
+	}
+	data, err := os.ReadFile(filepath.Join(hostHome, "auth.json"))
+	if os.IsNotExist(err) {
+		return nil
+	}
+	if err != nil {
+		return fmt.Errorf("read codex auth: %w", err)
+	}
+	if err := os.WriteFile(filepath.Join(codexHome, "auth.json"), data, 0o600); err != nil {
+		return fmt.Errorf("copy codex auth: %w", err)
 	}
-
-	log.Printf("[Codex] MCP config written to: %s", configPath)
 	return nil
 }
+
+// codexLog returns the logger for lines about Codex runs; logged with a
+// task's context, they carry its fields.
+func codexLog() *slog.Logger {
+	return logging.Logger(logging.KeyPhase, "provider", "provider", "codex")
+}
@@ -60,7 +60,7 @@ func TestInvokeCodex_CommandConstruction(t *testing.T) {
 
 	// Call invokeCodex
 	ctx := context.Background()
-	_, _ = provider.invokeCodex(ctx, "test prompt", "/tmp/test")
+	_, _ = provider.invokeCodex(ctx, "test prompt", "/tmp/test", nil, nil, nil, nil)
 
 	// Verify command structure
 	expectedArgs := []string{
@@ -107,7 +107,7 @@ func TestInvokeCodex_Timeout(t *testing.T) {
 	defer cancel()
 
 	start := time.Now()
-	_, err := provider.invokeCodex(ctx, "test prompt", "/tmp/test")
+	_, err := provider.invokeCodex(ctx, "test prompt", "/tmp/test", nil, nil, nil, nil)
 	duration := time.Since(start)
 
 	if err == nil {
@@ -227,6 +227,34 @@ func TestAggregateCodexOutputFallback(t *testing.T) {
 	}
 }
 
+func TestGenerateCode_ReportsProgress(t *testing.T) {
+	provider := NewProvider("", "", "gpt-5-codex")
+	lines := strings.Join([]string{
+		`{"type":"thread.started","thread_id":"t1"}`,
+		`{"type":"item.started","item":{"type":"command_execution","command":"go test ./...","status":"in_progress"}}`,
+		`{"type":"item.completed","item":{"type":"agent_message","text":"Tests pass.\nDone."}}`,
+	}, "\n")
+
+	originalExec := execCommandContext
+	defer func() { execCommandContext = originalExec }()
+	execCommandContext = func(ctx context.Context, name string, args ...string) *exec.Cmd {
+		return exec.Command("bash", "-c", fmt.Sprintf("cat <<'EOF'\n%s\nEOF", lines))
+	}
+
+	var steps []string
+	req := &prov.CodeRequest{
+		Prompt:   "Test prompt",
+		RepoPath: t.TempDir(),
+		Progress: func(step string) { steps = append(steps, step) },
+	}
+	if _, err := provider.GenerateCode(context.Background(), req); err != nil {
+		t.Fatalf("GenerateCode() error = %v", err)
+	}
+	if want := []string{"command: go test ./...", "Tests pass."}; strings.Join(steps, "|") != strings.Join(want, "|") {
+		t.Fatalf("steps = %q, want %q", steps, want)
+	}
+}
+
 func TestGenerateCode_JSONOutputFeedsComment(t *testing.T) {
 	provider := NewProvider("", "", "gpt-5-codex")
 
@@ -288,6 +316,8 @@ func TestBuildCodexMCPConfig_FullContext(t *testing.T) {
 				"model = \"gpt-5-codex\"",
 				// GitHub MCP and Git MCP removed - AI uses git/gh CLI via Bash
 				"[mcp_servers.comment_updater]",
+				"command = \"swe-mcp\"",
+				"args = [\"comment\"]",
 				"[mcp_servers.comment_updater.env]",
 				"GITHUB_TOKEN = \"tok_123\"",
 				"REPO_OWNER = \"linux\"",
@@ -303,14 +333,17 @@ func TestBuildCodexMCPConfig_FullContext(t *testing.T) {
 	for _, tc := range cases {
 		tc := tc
 		t.Run(tc.name, func(t *testing.T) {
-			home := setupTempHome(t)
+			setupTempHome(t)
 			ensureUVXAvailability(t, true)
+			installMCPServerStub(t)
 
-			if err := buildCodexMCPConfig(tc.ctx); err != nil {
+			codexHome, err := buildCodexMCPConfig(&prov.CodeRequest{Context: tc.ctx})
+			if err != nil {
 				t.Fatalf("buildCodexMCPConfig error: %v", err)
 			}
+			t.Cleanup(func() { _ = os.RemoveAll(codexHome) })
 
-			content := readConfigFile(t, home)
+			content := readConfigFile(t, codexHome)
 
 			for _, want := range tc.wantLines {
 				if !strings.Contains(content, want) {
@@ -324,65 +357,81 @@ func TestBuildCodexMCPConfig_FullContext(t *testing.T) {
 }
 
 func TestBuildCodexMCPConfig_FileWritten(t *testing.T) {
-	cases := []struct {
-		name       string
-		precreate  bool
-		wantMode   os.FileMode
-		wantDirMod os.FileMode
-	}{
-		{
-			name:       "creates directory and file",
-			precreate:  false,
-			wantMode:   0o600,
-			wantDirMod: 0o700,
-		},
-		{
-			name:       "reuses existing directory",
-			precreate:  true,
-			wantMode:   0o600,
-			wantDirMod: 0o700,
-		},
+	home := setupTempHome(t)
+
+	codexHome, err := buildCodexMCPConfig(&prov.CodeRequest{})
+	if err != nil {
+		t.Fatalf("buildCodexMCPConfig error: %v", err)
 	}
+	t.Cleanup(func() { _ = os.RemoveAll(codexHome) })
 
-	for _, tc := range cases {
-		tc := tc
-		t.Run(tc.name, func(t *testing.T) {
-			home := setupTempHome(t)
-			configDir := filepath.Join(home, ".codex")
-			configPath := filepath.Join(configDir, "config.toml")
+	dirInfo, err := os.Stat(codexHome)
+	if err != nil {
+		t.Fatalf("stat codex home: %v", err)
+	}
+	if !dirInfo.IsDir() {
+		t.Fatalf("codex home is not directory")
+	}
+	if dirInfo.Mode().Perm() != 0o700 {
+		t.Fatalf("codex home permissions = %o, want 700", dirInfo.Mode().Perm())
+	}
 
-			if tc.precreate {
-				if err := os.MkdirAll(configDir, 0o755); err != nil {
-					t.Fatalf("precreate dir: %v", err)
-				}
-			}
+	fileInfo, err := os.Stat(filepath.Join(codexHome, "config.toml"))
+	if err != nil {
+		t.Fatalf("stat config file: %v", err)
+	}
+	if fileInfo.Mode().Perm() != 0o600 {
+		t.Fatalf("config file permissions = %o, want 600", fileInfo.Mode().Perm())
+	}
 
-			if err := buildCodexMCPConfig(map[string]string{}); err != nil {
-				t.Fatalf("buildCodexMCPConfig error: %v", err)
-			}
+	// The shared host config must not be touched
+	if _, err := os.Stat(filepath.Join(home, ".codex", "config.toml")); !os.IsNotExist(err) {
+		t.Fatalf("host ~/.codex/config.toml should not be written, stat err = %v", err)
+	}
+}
 
-			dirInfo, err := os.Stat(configDir)
-			if err != nil {
-				t.Fatalf("stat config dir: %v", err)
-			}
-			if !dirInfo.IsDir() {
-				t.Fatalf("config path is not directory")
-			}
+func TestBuildCodexMCPConfig_PerTaskIsolation(t *testing.T) {
+	setupTempHome(t)
+	ensureUVXAvailability(t, false)
+	installMCPServerStub(t)
 
-			fileInfo, err := os.Stat(configPath)
-			if err != nil {
-				t.Fatalf("stat config file: %v", err)
-			}
+	first, err := buildCodexMCPConfig(&prov.CodeRequest{Context: map[string]string{
+		"github_token": "tok_a", "comment_id": "1", "repo_owner": "o", "repo_name": "a",
+	}})
+	if err != nil {
+		t.Fatalf("buildCodexMCPConfig error: %v", err)
+	}
+	t.Cleanup(func() { _ = os.RemoveAll(first) })
+	second, err := buildCodexMCPConfig(&prov.CodeRequest{})
+	if err != nil {
+		t.Fatalf("buildCodexMCPConfig error: %v", err)
+	}
+	t.Cleanup(func() { _ = os.RemoveAll(second) })
 
-			if fileInfo.Mode().Perm() != tc.wantMode {
-				t.Fatalf("config file permissions = %o, want %o", fileInfo.Mode().Perm(), tc.wantMode)
-			}
+	if first == second {
+		t.Fatalf("each task should get its own codex home, both got %s", first)
+	}
+	if content := readConfigFile(t, second); strings.Contains(content, "tok_a") {
+		t.Fatalf("second task config leaked first task token:\n%s", content)
+	}
+}
 
-			// Directory may already exist; ensure at least execute for user.
-			if dirInfo.Mode()&0o700 != tc.wantDirMod {
-				t.Fatalf("config dir permissions = %o, want prefix %o", dirInfo.Mode()&0o777, tc.wantDirMod)
-			}
-		})
+func TestBuildCodexMCPConfig_CopiesAuth(t *testing.T) {
+	hostHome := t.TempDir()
+	t.Setenv("CODEX_HOME", hostHome)
+	if err := os.WriteFile(filepath.Join(hostHome, "auth.json"), []byte(`{"token":"x"}`), 0o600); err != nil {
+		t.Fatalf("write auth.json: %v", err)
+	}
+
+	codexHome, err := buildCodexMCPConfig(&prov.CodeRequest{})
+	if err != nil {
+		t.Fatalf("buildCodexMCPConfig error: %v", err)
+	}
+	t.Cleanup(func() { _ = os.RemoveAll(codexHome) })
+
+	data, err := os.ReadFile(filepath.Join(codexHome, "auth.json"))
+	if err != nil || string(data) != `{"token":"x"}` {
+		t.Fatalf("auth.json not copied: %q, %v", data, err)
 	}
 }
 
@@ -430,14 +479,17 @@ func TestBuildCodexMCPConfig_CommentUpdater(t *testing.T) {
 	for _, tc := range cases {
 		tc := tc
 		t.Run(tc.name, func(t *testing.T) {
-			home := setupTempHome(t)
+			setupTempHome(t)
 			ensureUVXAvailability(t, false)
+			installMCPServerStub(t)
 
-			if err := buildCodexMCPConfig(tc.ctx); err != nil {
+			codexHome, err := buildCodexMCPConfig(&prov.CodeRequest{Context: tc.ctx})
+			if err != nil {
 				t.Fatalf("buildCodexMCPConfig error: %v", err)
 			}
+			t.Cleanup(func() { _ = os.RemoveAll(codexHome) })
 
-			content := readConfigFile(t, home)
+			content := readConfigFile(t, codexHome)
 			hasSection := strings.Contains(content, "[mcp_servers.comment_updater]")
 
 			if tc.expectPresent != hasSection {
@@ -494,14 +546,17 @@ func TestBuildCodexMCPConfig_PartialContext(t *testing.T) {
 	for _, tc := range cases {
 		tc := tc
 		t.Run(tc.name, func(t *testing.T) {
-			home := setupTempHome(t)
+			setupTempHome(t)
 			ensureUVXAvailability(t, false)
+			installMCPServerStub(t)
 
-			if err := buildCodexMCPConfig(tc.ctx); err != nil {
+			codexHome, err := buildCodexMCPConfig(&prov.CodeRequest{Context: tc.ctx})
+			if err != nil {
 				t.Fatalf("buildCodexMCPConfig error: %v", err)
 			}
+			t.Cleanup(func() { _ = os.RemoveAll(codexHome) })
 
-			content := readConfigFile(t, home)
+			content := readConfigFile(t, codexHome)
 			for _, line := range tc.wantPresent {
 				if !strings.Contains(content, line) {
 					t.Fatalf("expected config to contain %q\nconfig:\n%s", line, content)
@@ -535,10 +590,11 @@ func TestGenerateCode_MCPConfigCalled(t *testing.T) {
 			name: "config failure logs warning",
 			prepare: func(t *testing.T, home string) {
 				t.Helper()
-				badPath := filepath.Join(home, ".codex")
+				badPath := filepath.Join(home, "tmp")
 				if err := os.WriteFile(badPath, []byte("not a dir"), 0o600); err != nil {
 					t.Fatalf("write blocking file: %v", err)
 				}
+				t.Setenv("TMPDIR", badPath)
 			},
 			wantConfig:     false,
 			wantWarnLogged: true,
@@ -551,13 +607,19 @@ func TestGenerateCode_MCPConfigCalled(t *testing.T) {
 			provider := NewProvider("", "", "gpt-5-codex")
 
 			home := setupTempHome(t)
+			repoPath := t.TempDir()
 			tc.prepare(t, home)
 
 			jsonOutput := `{"type":"item.completed","item":{"type":"agent_message","text":"<summary>OK</summary>"}}`
 			originalExec := execCommandContext
 			defer func() { execCommandContext = originalExec }()
+
+			// The mock records the environment Codex would see, then prints its output
+			envFile := filepath.Join(t.TempDir(), "env")
+			script := `printf '%s\n%s\n' "$CODEX_HOME" "$GITHUB_TOKEN" > "$0"; ` +
+				`if [ -f "$CODEX_HOME/config.toml" ]; then echo config >> "$0"; fi; echo "$1"`
 			execCommandContext = func(ctx context.Context, name string, args ...string) *exec.Cmd {
-				return exec.Command("echo", jsonOutput)
+				return exec.Command("sh", "-c", script, envFile, jsonOutput)
 			}
 
 			var logBuf bytes.Buffer
@@ -567,7 +629,7 @@ func TestGenerateCode_MCPConfigCalled(t *testing.T) {
 
 			req := &prov.CodeRequest{
 				Prompt:   "Test prompt",
-				RepoPath: t.TempDir(),
+				RepoPath: repoPath,
 				Context: map[string]string{
 					"github_token": "tok",
 				},
@@ -581,16 +643,27 @@ func TestGenerateCode_MCPConfigCalled(t *testing.T) {
 				t.Fatalf("expected summary to be populated")
 			}
 
-			configPath := filepath.Join(home, ".codex", "config.toml")
-			_, statErr := os.Stat(configPath)
-			if tc.wantConfig && statErr != nil {
-				t.Fatalf("expected config file: %v", statErr)
+			recorded, err := os.ReadFile(envFile)
+			if err != nil {
+				t.Fatalf("read recorded env: %v", err)
 			}
-			if !tc.wantConfig && statErr == nil {
-				t.Fatalf("config file should not exist at %s", configPath)
+			lines := strings.Split(strings.TrimRight(string(recorded), "\n"), "\n")
+			codexHome, ghToken := lines[0], lines[1]
+			configSeen := len(lines) > 2 && lines[2] == "config"
+
+			if configSeen != tc.wantConfig {
+				t.Fatalf("config visible to codex = %t, want %t", configSeen, tc.wantConfig)
+			}
+			if ghToken != "tok" {
+				t.Fatalf("GITHUB_TOKEN = %q, want request-scoped token", ghToken)
+			}
+			if codexHome != "" {
+				if _, err := os.Stat(codexHome); !os.IsNotExist(err) {
+					t.Fatalf("per-task codex home should be removed after the task, stat err = %v", err)
+				}
 			}
 
-			warnLogged := strings.Contains(logBuf.String(), "Warning: failed to build MCP config")
+			warnLogged := strings.Contains(logBuf.String(), "Building MCP config failed")
 			if warnLogged != tc.wantWarnLogged {
 				t.Fatalf("warning logged = %t, want %t\nlogs:\n%s", warnLogged, tc.wantWarnLogged, logBuf.String())
 			}
@@ -648,9 +721,21 @@ func ensureUVXAvailability(t *testing.T, present bool) {
 	t.Setenv("PATH", dir)
 }
 
-func readConfigFile(t *testing.T, home string) string {
+func installMCPServerStub(t *testing.T) {
+	t.Helper()
+	if runtime.GOOS == "windows" {
+		t.Skip("shell stub not supported on windows")
+	}
+	dir := t.TempDir()
+	if err := os.WriteFile(filepath.Join(dir, prov.MCPServerBinary), []byte("#!/bin/sh\nexit 0\n"), 0o755); err != nil {
+		t.Fatalf("write mock %s: %v", prov.MCPServerBinary, err)
+	}
+	t.Setenv("PATH", dir+string(os.PathListSeparator)+os.Getenv("PATH"))
+}
+
+func readConfigFile(t *testing.T, codexHome string) string {
 	t.Helper()
-	configPath := filepath.Join(home, ".codex", "config.toml")
+	configPath := filepath.Join(codexHome, "config.toml")
 	data, err := os.ReadFile(configPath)
 	if err != nil {
 		t.Fatalf("read config file: %v", err)
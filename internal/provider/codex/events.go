@@ -0,0 +1,163 @@
+package codex
+
+import (
+	"encoding/json"
+	"fmt"
+	"strings"
+	"time"
+
+	"github.com/cexll/swe/internal/provider"
+)
+
+// codexLine is one line of `codex exec --json` output.
+type codexLine struct {
+	Type    string        `json:"type"`
+	Message string        `json:"message"`
+	Error   *codexMessage `json:"error"`
+	Item    *codexItem    `json:"item"`
+}
+
+type codexMessage struct {
+	Message string `json:"message"`
+}
+
+// codexItem is a thread item; older CLIs name its kind item_type.
+type codexItem struct {
+	ID       string        `json:"id"`
+	Type     string        `json:"type"`
+	ItemType string        `json:"item_type"`
+	Status   string        `json:"status"`
+	Text     string        `json:"text"`
+	Message  string        `json:"message"`
+	Command  string        `json:"command"`
+	ExitCode *int          `json:"exit_code"`
+	Server   string        `json:"server"`
+	Tool     string        `json:"tool"`
+	Query    string        `json:"query"`
+	Error    *codexMessage `json:"error"`
+	Changes  []struct {
+		Path string `json:"path"`
+		Kind string `json:"kind"`
+	} `json:"changes"`
+}
+
+func (it *codexItem) kind() string {
+	if it.Type != "" {
+		return it.Type
+	}
+	return it.ItemType
+}
+
+// codexEvents turns the --json event stream into provider events: one per
+// completed item, timed from its item.started, plus failed turns and stream
+// errors. Lines are fed in order by a single LineWriter.
+type codexEvents struct {
+	emit    func(provider.Event)
+	now     func() time.Time
+	started map[string]time.Time // item ID -> item.started time
+}
+
+func newCodexEvents(emit func(provider.Event)) *codexEvents {
+	return &codexEvents{emit: emit, now: time.Now, started: make(map[string]time.Time)}
+}
+
+func (c *codexEvents) line(raw string) {
+	var l codexLine
+	if err := json.Unmarshal([]byte(raw), &l); err != nil {
+		return
+	}
+	now := c.now()
+	switch l.Type {
+	case "item.started":
+		if l.Item != nil && l.Item.ID != "" {
+			c.started[l.Item.ID] = now
+		}
+	case "item.completed":
+		if l.Item == nil {
+			return
+		}
+		ev, ok := itemEvent(l.Item)
+		if !ok {
+			return
+		}
+		ev.Time = now
+		if start, ok := c.started[l.Item.ID]; ok {
+			ev.Duration = now.Sub(start)
+			delete(c.started, l.Item.ID)
+		}
+		c.emit(ev)
+	case "turn.failed", "error":
+		msg := l.Message
+		if l.Error != nil && l.Error.Message != "" {
+			msg = l.Error.Message
+		}
+		if msg == "" {
+			msg = l.Type
+		}
+		c.emit(provider.Event{Time: now, Level: "error", Type: provider.EventError, Message: msg})
+	}
+}
+
+// itemEvent describes a completed item; todo lists and unknown items are not
+// reported.
+func itemEvent(it *codexItem) (provider.Event, bool) {
+	ev := provider.Event{Level: "info"}
+	if it.Status == "failed" {
+		ev.Level = "error"
+	}
+	switch it.kind() {
+	case "command_execution":
+		ev.Type = provider.EventCommand
+		ev.Tool = commandTool(it.Command)
+		ev.Message = it.Command
+		if it.ExitCode != nil && *it.ExitCode != 0 {
+			ev.Level = "error"
+			ev.Message += fmt.Sprintf(" (exit %d)", *it.ExitCode)
+		}
+	case "mcp_tool_call":
+		ev.Type = provider.EventToolCall
+		ev.Tool = "mcp__" + it.Server + "__" + it.Tool
+		ev.Message = it.Server + " " + it.Tool
+		if it.Error != nil && it.Error.Message != "" {
+			ev.Level = "error"
+			ev.Message += ": " + it.Error.Message
+		}
+	case "file_change":
+		ev.Type = provider.EventFileChange
+		var changes []string
+		for _, ch := range it.Changes {
+			changes = append(changes, ch.Kind+" "+ch.Path)
+		}
+		ev.Message = strings.Join(changes, ", ")
+	case "web_search":
+		ev.Type = provider.EventWebSearch
+		ev.Tool = "web_search"
+		ev.Message = it.Query
+	case "agent_message", "assistant_message":
+		ev.Type = provider.EventMessage
+		ev.Message = it.Text
+	case "reasoning":
+		ev.Type = provider.EventReasoning
+		ev.Message = it.Text
+	case "error":
+		ev.Type = provider.EventError
+		ev.Level = "error"
+		ev.Message = it.Message
+	default:
+		return provider.Event{}, false
+	}
+	return ev, true
+}
+
+// commandTool names the program a command runs, looking through the
+// `bash -lc '...'` wrapper codex uses.
+func commandTool(command string) string {
+	fields := strings.Fields(command)
+	if len(fields) >= 3 && (fields[0] == "bash" || fields[0] == "sh" || fields[0] == "zsh" || strings.HasSuffix(fields[0], "/bash")) && strings.HasPrefix(fields[1], "-") {
+		fields = strings.Fields(strings.Trim(strings.Join(fields[2:], " "), `'"`))
+	}
+	if len(fields) == 0 {
+		return ""
+	}
+	return fields[0]
+}
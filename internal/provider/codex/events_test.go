@@ -0,0 +1,105 @@
+package codex
+
+import (
+	"context"
+	"fmt"
+	"os/exec"
+	"strings"
+	"testing"
+	"time"
+
+	prov "github.com/cexll/swe/internal/provider"
+)
+
+func TestCodexEvents(t *testing.T) {
+	var got []prov.Event
+	c := newCodexEvents(func(ev prov.Event) { got = append(got, ev) })
+	clock := time.Date(2026, 10, 16, 9, 0, 0, 0, time.UTC)
+	c.now = func() time.Time {
+		clock = clock.Add(time.Second)
+		return clock
+	}
+
+	for _, line := range []string{
+		`{"type":"thread.started","thread_id":"t1"}`,
+		`not json`,
+		`{"type":"item.started","item":{"id":"item_0","type":"command_execution","command":"bash -lc 'go test ./...'","status":"in_progress"}}`,
+		`{"type":"item.started","item":{"id":"item_1","type":"mcp_tool_call","server":"github","tool":"create_issue","status":"in_progress"}}`,
+		`{"type":"item.completed","item":{"id":"item_0","type":"command_execution","command":"bash -lc 'go test ./...'","aggregated_output":"FAIL","exit_code":1,"status":"failed"}}`,
+		`{"type":"item.completed","item":{"id":"item_1","type":"mcp_tool_call","server":"github","tool":"create_issue","status":"completed"}}`,
+		`{"type":"item.completed","item":{"id":"item_2","item_type":"file_change","changes":[{"path":"a.go","kind":"update"},{"path":"b.go","kind":"add"}],"status":"completed"}}`,
+		`{"type":"item.updated","item":{"id":"item_3","type":"todo_list","items":[]}}`,
+		`{"type":"item.completed","item":{"id":"item_3","type":"todo_list","items":[]}}`,
+		`{"type":"item.completed","item":{"id":"item_4","type":"agent_message","text":"Done."}}`,
+		`{"type":"turn.failed","error":{"message":"stream disconnected"}}`,
+	} {
+		c.line(line)
+	}
+
+	want := []prov.Event{
+		{Level: "error", Type: prov.EventCommand, Tool: "go", Message: "bash -lc 'go test ./...' (exit 1)", Duration: 2 * time.Second},
+		{Level: "info", Type: prov.EventToolCall, Tool: "mcp__github__create_issue", Message: "github create_issue", Duration: 2 * time.Second},
+		{Level: "info", Type: prov.EventFileChange, Message: "update a.go, add b.go"},
+		{Level: "info", Type: prov.EventMessage, Message: "Done."},
+		{Level: "error", Type: prov.EventError, Message: "stream disconnected"},
+	}
+	if len(got) != len(want) {
+		t.Fatalf("events = %+v", got)
+	}
+	for i := range want {
+		g := got[i]
+		if g.Time.IsZero() {
+			t.Errorf("event %d has no time", i)
+		}
+		g.Time = time.Time{}
+		if g != want[i] {
+			t.Errorf("event %d = %+v, want %+v", i, g, want[i])
+		}
+	}
+	if len(c.started) != 0 {
+		t.Fatalf("completed items are still tracked: %v", c.started)
+	}
+}
+
+func TestCommandTool(t *testing.T) {
+	for command, want := range map[string]string{
+		"bash -lc 'rg -n foo'":      "rg",
+		`/bin/bash -c "npm test"`:   "npm",
+		"git status":                "git",
+		"bash -lc ''":               "",
+		"":                          "",
+		"sh -c 'cd web && pnpm i'":  "cd",
+		"python3 scripts/gen.py -v": "python3",
+	} {
+		if got := commandTool(command); got != want {
+			t.Errorf("commandTool(%q) = %q, want %q", command, got, want)
+		}
+	}
+}
+
+func TestGenerateCode_ReportsEvents(t *testing.T) {
+	provider := NewProvider("", "", "gpt-5-codex")
+	lines := strings.Join([]string{
+		`{"type":"item.started","item":{"id":"item_0","type":"command_execution","command":"ls","status":"in_progress"}}`,
+		`{"type":"item.completed","item":{"id":"item_0","type":"command_execution","command":"ls","exit_code":0,"status":"completed"}}`,
+	}, "\n")
+
+	originalExec := execCommandContext
+	defer func() { execCommandContext = originalExec }()
+	execCommandContext = func(ctx context.Context, name string, args ...string) *exec.Cmd {
+		return exec.Command("bash", "-c", fmt.Sprintf("cat <<'EOF'\n%s\nEOF", lines))
+	}
+
+	var events []prov.Event
+	req := &prov.CodeRequest{
+		Prompt:   "Test prompt",
+		RepoPath: t.TempDir(),
+		Events:   func(ev prov.Event) { events = append(events, ev) },
+	}
+	if _, err := provider.GenerateCode(context.Background(), req); err != nil {
+		t.Fatalf("GenerateCode() error = %v", err)
+	}
+	if len(events) != 1 || events[0].Type != prov.EventCommand || events[0].Tool != "ls" || events[0].Level != "info" {
+		t.Fatalf("events = %+v", events)
+	}
+}
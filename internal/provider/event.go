@@ -0,0 +1,26 @@
+package provider
+
+import "time"
+
+// Event types reported by providers.
+const (
+	EventCommand    = "command"     // shell command run by the agent
+	EventToolCall   = "tool_call"   // MCP or built-in tool call
+	EventFileChange = "file_change" // files edited by the agent
+	EventWebSearch  = "web_search"
+	EventMessage    = "message"   // agent message
+	EventReasoning  = "reasoning" // reasoning summary
+	EventError      = "error"
+)
+
+// Event is one structured step of a provider run, such as a tool call that
+// completed. Providers that parse their CLI's event stream report these in
+// addition to the one-line Progress steps.
+type Event struct {
+	Time     time.Time
+	Level    string // info or error
+	Type     string // one of the Event* constants
+	Tool     string // tool or command name, when Type is a tool call or command
+	Message  string
+	Duration time.Duration // from start to completion, when known
+}
@@ -0,0 +1,377 @@
+// Package mcpconfig generates per-task MCP server configuration for the
+// provider CLIs. Servers are described once and rendered into each CLI's
+// format (Claude --mcp-config JSON, Codex config.toml), so task-scoped values
+// such as the coordinating comment ID and installation token never live in
+// host-level config files shared between tasks.
+package mcpconfig
+
+import (
+	"encoding/json"
+	"fmt"
+	"log/slog"
+	"os"
+	"os/exec"
+	"path/filepath"
+	"sort"
+	"strings"
+
+	"github.com/cexll/swe/internal/logging"
+	"github.com/cexll/swe/internal/provider"
+	"github.com/cexll/swe/internal/version"
+)
+
+// Server is a single stdio MCP server entry.
+type Server struct {
+	Name    string
+	Command string
+	Args    []string
+	Env     map[string]string
+}
+
+// Names of the servers Build can configure.
+const (
+	CommentServer            = "comment_updater"
+	ReviewServer             = "review_threads"
+	SequentialThinkingServer = "sequential-thinking"
+	FetchServer              = "fetch"
+	GitServer                = "git"
+	GitHubServer             = "github"
+	FileOpsServer            = "file_ops"
+)
+
+// defaults are the servers a task gets unless its repository disables them;
+// the others are started only for repositories that enable them.
+var defaults = map[string]bool{
+	CommentServer:            true,
+	ReviewServer:             true,
+	SequentialThinkingServer: true,
+	FetchServer:              true,
+	GitServer:                false,
+	GitHubServer:             false,
+	FileOpsServer:            false,
+}
+
+// Known reports whether name is a server Build can configure.
+func Known(name string) bool {
+	_, ok := defaults[name]
+	return ok
+}
+
+// Names lists the servers Build can configure, sorted.
+func Names() []string {
+	names := make([]string, 0, len(defaults))
+	for name := range defaults {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+	return names
+}
+
+// Enabled reports whether the server name runs under the per-repository
+// toggles enable (nil keeps the defaults).
+func Enabled(name string, enable map[string]bool) bool {
+	if on, ok := enable[name]; ok {
+		return on
+	}
+	return defaults[name]
+}
+
+// AllowedTools returns the tool permissions ("mcp__<server>") for the
+// optional servers enable turns on, whose tools are not in the default
+// allowed list.
+func AllowedTools(enable map[string]bool) []string {
+	var tools []string
+	for _, name := range Names() {
+		if !defaults[name] && Enabled(name, enable) {
+			tools = append(tools, "mcp__"+name)
+		}
+	}
+	return tools
+}
+
+// allow tests to control binary discovery
+var lookPath = exec.LookPath
+
+// Build returns the MCP servers for one task. req.Context carries the
+// task-scoped values (github_token, comment_id, repo_owner, repo_name,
+// event_name, pr_number, issue_number, comment_template, comment_lang and
+// task_id for a tracking comment layout, and comment_history for a tracking
+// comment reused across runs), req.RepoPath the working directory the git and file_ops
+// servers are confined to, and req.MCPServers the repository's toggles.
+// Servers whose command is not on PATH are skipped, since a missing command
+// makes the CLI fail MCP startup. With req.MCPLauncher set, every server is
+// started through it.
+func Build(req *provider.CodeRequest) []Server {
+	var servers []Server
+	ctx := req.Context
+	on := func(name string) bool { return Enabled(name, req.MCPServers) }
+	githubToken := ctx["github_token"]
+
+	if commentID := ctx["comment_id"]; commentID != "" && on(CommentServer) {
+		owner := ctx["repo_owner"]
+		repo := ctx["repo_name"]
+
+		if owner != "" && repo != "" && githubToken != "" {
+			env := map[string]string{
+				"GITHUB_TOKEN":      githubToken,
+				"REPO_OWNER":        owner,
+				"REPO_NAME":         repo,
+				"CLAUDE_COMMENT_ID": commentID,
+				// shown in the comment footer, next to swe-mcp's own
+				"SWE_AGENT_VERSION": version.Short(),
+			}
+			if eventName := ctx["event_name"]; eventName != "" {
+				env["GITHUB_EVENT_NAME"] = eventName
+			}
+			if layout := ctx["comment_template"]; layout != "" {
+				env["SWE_COMMENT_TEMPLATE"] = layout
+				env["SWE_COMMENT_LANG"] = ctx["comment_lang"]
+				env["SWE_TASK_ID"] = ctx["task_id"]
+				env["SWE_ISSUE_NUMBER"] = ctx["pr_number"] + ctx["issue_number"] // one is set
+			}
+			if ctx["comment_history"] != "" {
+				env["SWE_COMMENT_HISTORY"] = "true"
+			}
+			servers = addIfInstalled(servers, Server{
+				Name:    CommentServer,
+				Command: provider.MCPServerBinary,
+				Args:    []string{provider.MCPCommentSubcommand},
+				Env:     env,
+			})
+		}
+	}
+
+	// Pull request tasks may answer and resolve the review threads of their
+	// own pull request; swe-mcp checks every thread against PR_NUMBER.
+	if prNumber := ctx["pr_number"]; prNumber != "" && on(ReviewServer) {
+		owner := ctx["repo_owner"]
+		repo := ctx["repo_name"]
+
+		if owner != "" && repo != "" && githubToken != "" {
+			servers = addIfInstalled(servers, Server{
+				Name:    ReviewServer,
+				Command: provider.MCPServerBinary,
+				Args:    []string{provider.MCPReviewSubcommand},
+				Env: map[string]string{
+					"GITHUB_TOKEN": githubToken,
+					"REPO_OWNER":   owner,
+					"REPO_NAME":    repo,
+					"PR_NUMBER":    prNumber,
+				},
+			})
+		}
+	}
+
+	if on(SequentialThinkingServer) {
+		servers = addIfInstalled(servers, Server{
+			Name:    SequentialThinkingServer,
+			Command: "npx",
+			Args:    []string{"-y", "@modelcontextprotocol/server-sequential-thinking"},
+		})
+	}
+
+	if on(FetchServer) {
+		servers = addIfInstalled(servers, Server{
+			Name:    FetchServer,
+			Command: "uvx",
+			Args: []string{
+				"--from",
+				"git+https://github.com/cexll/mcp-server-fetch.git",
+				"mcp-server-fetch",
+			},
+		})
+	}
+
+	// The optional servers duplicate what the git and gh CLIs do through
+	// the Bash tool; repositories whose prompts expect them opt in.
+	if on(GitServer) && req.RepoPath != "" {
+		servers = addIfInstalled(servers, Server{
+			Name:    GitServer,
+			Command: "uvx",
+			Args:    []string{"mcp-server-git", "--repository", req.RepoPath},
+		})
+	}
+
+	if on(GitHubServer) && githubToken != "" {
+		env := map[string]string{"GITHUB_PERSONAL_ACCESS_TOKEN": githubToken}
+		servers = addIfInstalled(servers, Server{
+			Name:    GitHubServer,
+			Command: "github-mcp-server",
+			Args:    []string{"stdio"},
+			Env:     env,
+		})
+	}
+
+	if on(FileOpsServer) && req.RepoPath != "" {
+		servers = addIfInstalled(servers, Server{
+			Name:    FileOpsServer,
+			Command: "npx",
+			Args:    []string{"-y", "@modelcontextprotocol/server-filesystem", req.RepoPath},
+		})
+	}
+
+	names := make([]string, 0, len(servers))
+	for i, s := range servers {
+		names = append(names, s.Name)
+		if len(req.MCPLauncher) > 0 {
+			servers[i] = launch(req.MCPLauncher, s)
+		}
+	}
+	if len(names) > 0 {
+		mcpLog().Info("MCP servers configured", "count", len(names), "servers", names)
+	} else {
+		mcpLog().Warn("No MCP servers configured")
+	}
+	return servers
+}
+
+// launch returns s started through launcher.
+func launch(launcher []string, s Server) Server {
+	args := append([]string{}, launcher[1:]...)
+	args = append(args, s.Name, "--", s.Command)
+	s.Command, s.Args = launcher[0], append(args, s.Args...)
+	return s
+}
+
+func addIfInstalled(servers []Server, s Server) []Server {
+	if _, err := lookPath(s.Command); err != nil {
+		mcpLog().Warn("MCP server command not found in PATH; server unavailable", "command", s.Command, "server", s.Name)
+		return servers
+	}
+	mcpLog().Debug("Added MCP server", "server", s.Name)
+	return append(servers, s)
+}
+
+// ClaudeJSON renders servers as the JSON document accepted by `claude --mcp-config`.
+func ClaudeJSON(servers []Server) (string, error) {
+	type serverConfig struct {
+		Command string            `json:"command"`
+		Args    []string          `json:"args,omitempty"`
+		Env     map[string]string `json:"env,omitempty"`
+	}
+	config := struct {
+		MCPServers map[string]serverConfig `json:"mcpServers"`
+	}{MCPServers: make(map[string]serverConfig, len(servers))}
+
+	for _, s := range servers {
+		config.MCPServers[s.Name] = serverConfig{Command: s.Command, Args: s.Args, Env: s.Env}
+	}
+
+	blob, err := json.MarshalIndent(config, "", "  ")
+	if err != nil {
+		return "", fmt.Errorf("marshal MCP config: %w", err)
+	}
+	return string(blob), nil
+}
+
+// ClaudeConfigFile is the name of the file WriteClaudeConfig writes.
+const ClaudeConfigFile = "swe-agent-mcp.json"
+
+// WriteClaudeConfig writes config (see ClaudeJSON) into the task's working
+// directory and returns its path for `claude --mcp-config`. The file holds
+// the installation token, so it goes inside .git, where the agent's commits
+// cannot pick it up, and is readable by the owner only. It is removed with
+// the working directory.
+func WriteClaudeConfig(workdir, config string) (string, error) {
+	dir := gitDir(workdir)
+	path := filepath.Join(dir, ClaudeConfigFile)
+	if err := os.WriteFile(path, []byte(config), 0o600); err != nil {
+		return "", fmt.Errorf("write MCP config: %w", err)
+	}
+	return path, nil
+}
+
+// gitDir is the git directory of workdir: its .git directory, or the one a
+// worktree's .git file points to. Without either it is workdir itself.
+func gitDir(workdir string) string {
+	path := filepath.Join(workdir, ".git")
+	info, err := os.Stat(path)
+	if err != nil {
+		return workdir
+	}
+	if info.IsDir() {
+		return path
+	}
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return workdir
+	}
+	dir, ok := strings.CutPrefix(strings.TrimSpace(string(data)), "gitdir: ")
+	if !ok {
+		return workdir
+	}
+	if !filepath.IsAbs(dir) {
+		dir = filepath.Join(workdir, dir)
+	}
+	return dir
+}
+
+// CodexTOML renders servers as [mcp_servers.*] tables for Codex config.toml.
+func CodexTOML(servers []Server) string {
+	var sb strings.Builder
+	for _, s := range servers {
+		key := codexKey(s.Name)
+		fmt.Fprintf(&sb, "[mcp_servers.%s]\n", key)
+		fmt.Fprintf(&sb, "command = %s\n", tomlString(s.Command))
+		if len(s.Args) > 0 {
+			quoted := make([]string, len(s.Args))
+			for i, arg := range s.Args {
+				quoted[i] = tomlString(arg)
+			}
+			fmt.Fprintf(&sb, "args = [%s]\n", strings.Join(quoted, ", "))
+		}
+		sb.WriteString("\n")
+
+		if len(s.Env) > 0 {
+			fmt.Fprintf(&sb, "[mcp_servers.%s.env]\n", key)
+			keys := make([]string, 0, len(s.Env))
+			for k := range s.Env {
+				keys = append(keys, k)
+			}
+			sort.Strings(keys)
+			for _, k := range keys {
+				fmt.Fprintf(&sb, "%s = %s\n", k, tomlString(s.Env[k]))
+			}
+			sb.WriteString("\n")
+		}
+	}
+	return sb.String()
+}
+
+// codexKey keeps the historical underscore table names Codex configs use.
+func codexKey(name string) string {
+	return strings.ReplaceAll(name, "-", "_")
+}
+
+// tomlString quotes s as a TOML basic string.
+func tomlString(s string) string {
+	var sb strings.Builder
+	sb.WriteByte('"')
+	for _, r := range s {
+		switch r {
+		case '"':
+			sb.WriteString(`\"`)
+		case '\\':
+			sb.WriteString(`\\`)
+		case '\n':
+			sb.WriteString(`\n`)
+		case '\r':
+			sb.WriteString(`\r`)
+		case '\t':
+			sb.WriteString(`\t`)
+		default:
+			if r < 0x20 || r == 0x7f {
+				fmt.Fprintf(&sb, `\u%04X`, r)
+				continue
+			}
+			sb.WriteRune(r)
+		}
+	}
+	sb.WriteByte('"')
+	return sb.String()
+}
+
+// mcpLog returns the logger for lines about the MCP configuration.
+func mcpLog() *slog.Logger {
+	return logging.Logger(logging.KeyPhase, "provider")
+}
@@ -0,0 +1,268 @@
+package mcpconfig
+
+import (
+	"encoding/json"
+	"errors"
+	"maps"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"github.com/cexll/swe/internal/provider"
+)
+
+func stubLookPath(t *testing.T, installed ...string) {
+	t.Helper()
+	orig := lookPath
+	t.Cleanup(func() { lookPath = orig })
+	lookPath = func(name string) (string, error) {
+		for _, n := range installed {
+			if n == name {
+				return "/usr/bin/" + name, nil
+			}
+		}
+		return "", errors.New("not found")
+	}
+}
+
+var fullCtx = map[string]string{
+	"github_token": "ghs_task",
+	"comment_id":   "42",
+	"repo_owner":   "octo",
+	"repo_name":    "demo",
+	"event_name":   "issue_comment",
+}
+
+func TestBuild(t *testing.T) {
+	cases := []struct {
+		name      string
+		ctx       map[string]string
+		installed []string
+		want      []string
+	}{
+		{"all installed", fullCtx, []string{"swe-mcp", "npx", "uvx"}, []string{"comment_updater", "sequential-thinking", "fetch"}},
+		{"comment binary missing", fullCtx, []string{"npx"}, []string{"sequential-thinking"}},
+		{"no comment context", map[string]string{"github_token": "x"}, []string{"swe-mcp", "uvx"}, []string{"fetch"}},
+		{"missing owner", map[string]string{"github_token": "x", "comment_id": "1", "repo_name": "r"}, []string{"swe-mcp"}, nil},
+	}
+	for _, tc := range cases {
+		t.Run(tc.name, func(t *testing.T) {
+			stubLookPath(t, tc.installed...)
+			var got []string
+			for _, s := range Build(&provider.CodeRequest{Context: tc.ctx}) {
+				got = append(got, s.Name)
+			}
+			if strings.Join(got, ",") != strings.Join(tc.want, ",") {
+				t.Fatalf("servers = %v, want %v", got, tc.want)
+			}
+		})
+	}
+}
+
+func TestBuild_TaskScopedEnv(t *testing.T) {
+	stubLookPath(t, "swe-mcp")
+	servers := Build(&provider.CodeRequest{Context: fullCtx})
+	if len(servers) != 1 {
+		t.Fatalf("servers = %+v", servers)
+	}
+	s := servers[0]
+	if s.Command != "swe-mcp" || strings.Join(s.Args, " ") != "comment" {
+		t.Fatalf("command = %s %v", s.Command, s.Args)
+	}
+	want := map[string]string{
+		"GITHUB_TOKEN":      "ghs_task",
+		"REPO_OWNER":        "octo",
+		"REPO_NAME":         "demo",
+		"CLAUDE_COMMENT_ID": "42",
+		"GITHUB_EVENT_NAME": "issue_comment",
+	}
+	for k, v := range want {
+		if s.Env[k] != v {
+			t.Fatalf("env %s = %q, want %q", k, s.Env[k], v)
+		}
+	}
+	if _, ok := s.Env["SWE_COMMENT_TEMPLATE"]; ok {
+		t.Fatalf("layout env without a template: %v", s.Env)
+	}
+
+	ctx := maps.Clone(fullCtx)
+	ctx["comment_template"], ctx["comment_lang"], ctx["task_id"], ctx["issue_number"] = "/etc/swe-agent/comment.tmpl", "zh", "octo-demo-3-1", "3"
+	env := Build(&provider.CodeRequest{Context: ctx})[0].Env
+	if env["SWE_COMMENT_TEMPLATE"] != "/etc/swe-agent/comment.tmpl" || env["SWE_COMMENT_LANG"] != "zh" || env["SWE_TASK_ID"] != "octo-demo-3-1" || env["SWE_ISSUE_NUMBER"] != "3" {
+		t.Fatalf("layout env = %v", env)
+	}
+	if _, ok := env["SWE_COMMENT_HISTORY"]; ok {
+		t.Fatalf("history env for a comment that is not reused: %v", env)
+	}
+	ctx["comment_history"] = "true"
+	if env := Build(&provider.CodeRequest{Context: ctx})[0].Env; env["SWE_COMMENT_HISTORY"] != "true" {
+		t.Fatalf("history env = %v", env)
+	}
+}
+
+func TestBuild_ReviewServer(t *testing.T) {
+	stubLookPath(t, "swe-mcp")
+	ctx := map[string]string{"github_token": "ghs_task", "repo_owner": "octo", "repo_name": "demo", "pr_number": "7"}
+	servers := Build(&provider.CodeRequest{Context: ctx})
+	if len(servers) != 1 || servers[0].Name != ReviewServer || strings.Join(servers[0].Args, " ") != "review" {
+		t.Fatalf("servers = %+v", servers)
+	}
+	if env := servers[0].Env; env["PR_NUMBER"] != "7" || env["REPO_OWNER"] != "octo" || env["REPO_NAME"] != "demo" || env["GITHUB_TOKEN"] != "ghs_task" {
+		t.Fatalf("env = %v", env)
+	}
+
+	if servers := Build(&provider.CodeRequest{Context: ctx, MCPServers: map[string]bool{ReviewServer: false}}); len(servers) != 0 {
+		t.Fatalf("disabled review server started: %+v", servers)
+	}
+	if servers := Build(&provider.CodeRequest{Context: fullCtx}); len(servers) != 1 || servers[0].Name != CommentServer {
+		t.Fatalf("issue task servers = %+v", servers)
+	}
+}
+
+func TestBuild_RepositoryToggles(t *testing.T) {
+	stubLookPath(t, "swe-mcp", "npx", "uvx", "github-mcp-server")
+	req := &provider.CodeRequest{
+		Context:  fullCtx,
+		RepoPath: "/tmp/work",
+		MCPServers: map[string]bool{
+			GitServer: true, GitHubServer: true, FileOpsServer: true,
+			FetchServer: false, SequentialThinkingServer: false,
+		},
+	}
+	byName := map[string]Server{}
+	var got []string
+	for _, s := range Build(req) {
+		byName[s.Name] = s
+		got = append(got, s.Name)
+	}
+	if want := "comment_updater,git,github,file_ops"; strings.Join(got, ",") != want {
+		t.Fatalf("servers = %v, want %s", got, want)
+	}
+	if args := strings.Join(byName[GitServer].Args, " "); args != "mcp-server-git --repository /tmp/work" {
+		t.Fatalf("git args = %s", args)
+	}
+	if byName[GitHubServer].Env["GITHUB_PERSONAL_ACCESS_TOKEN"] != "ghs_task" {
+		t.Fatalf("github env = %v", byName[GitHubServer].Env)
+	}
+	if args := byName[FileOpsServer].Args; args[len(args)-1] != "/tmp/work" {
+		t.Fatalf("file_ops args = %v", args)
+	}
+
+	// servers confined to the working directory need one
+	req.RepoPath = ""
+	req.MCPServers = map[string]bool{GitServer: true, FileOpsServer: true, CommentServer: false}
+	got = nil
+	for _, s := range Build(req) {
+		got = append(got, s.Name)
+	}
+	if want := "sequential-thinking,fetch"; strings.Join(got, ",") != want {
+		t.Fatalf("servers = %v, want %s", got, want)
+	}
+}
+
+func TestBuild_Launcher(t *testing.T) {
+	stubLookPath(t, "npx")
+	servers := Build(&provider.CodeRequest{MCPLauncher: []string{"/bin/swe-agent", "supervise-mcp", "/tmp/mcp"}})
+	if len(servers) != 1 {
+		t.Fatalf("servers = %+v", servers)
+	}
+	s := servers[0]
+	if got := s.Command + " " + strings.Join(s.Args, " "); got != "/bin/swe-agent supervise-mcp /tmp/mcp sequential-thinking -- npx -y @modelcontextprotocol/server-sequential-thinking" {
+		t.Fatalf("command = %s", got)
+	}
+}
+
+func TestAllowedTools(t *testing.T) {
+	if got := AllowedTools(nil); got != nil {
+		t.Fatalf("AllowedTools(nil) = %v", got)
+	}
+	got := AllowedTools(map[string]bool{GitServer: true, FileOpsServer: true, FetchServer: true, GitHubServer: false})
+	if want := "mcp__file_ops,mcp__git"; strings.Join(got, ",") != want {
+		t.Fatalf("AllowedTools = %v, want %s", got, want)
+	}
+	if !Known("github") || Known("gitlab") {
+		t.Fatal("Known")
+	}
+}
+
+func TestWriteClaudeConfig(t *testing.T) {
+	work := t.TempDir()
+	if err := os.Mkdir(filepath.Join(work, ".git"), 0o755); err != nil {
+		t.Fatal(err)
+	}
+	path, err := WriteClaudeConfig(work, `{"mcpServers": {}}`)
+	if err != nil {
+		t.Fatalf("WriteClaudeConfig: %v", err)
+	}
+	if path != filepath.Join(work, ".git", ClaudeConfigFile) {
+		t.Fatalf("path = %s, want it inside .git", path)
+	}
+	info, err := os.Stat(path)
+	if err != nil || info.Mode().Perm() != 0o600 {
+		t.Fatalf("stat = %v, %v; want mode 0600", info, err)
+	}
+
+	plain := t.TempDir()
+	if path, _ := WriteClaudeConfig(plain, "{}"); path != filepath.Join(plain, ClaudeConfigFile) {
+		t.Fatalf("path without .git = %s", path)
+	}
+
+	// a worktree's .git file points at its git directory
+	worktree, gitdir := t.TempDir(), t.TempDir()
+	if err := os.WriteFile(filepath.Join(worktree, ".git"), []byte("gitdir: "+gitdir+"\n"), 0o644); err != nil {
+		t.Fatal(err)
+	}
+	if path, _ := WriteClaudeConfig(worktree, "{}"); path != filepath.Join(gitdir, ClaudeConfigFile) {
+		t.Fatalf("path in a worktree = %s", path)
+	}
+}
+
+func TestClaudeJSON(t *testing.T) {
+	raw, err := ClaudeJSON([]Server{{Name: "fetch", Command: "uvx", Args: []string{"a"}, Env: map[string]string{"K": "v"}}})
+	if err != nil {
+		t.Fatalf("ClaudeJSON error: %v", err)
+	}
+	var cfg struct {
+		MCPServers map[string]struct {
+			Command string            `json:"command"`
+			Args    []string          `json:"args"`
+			Env     map[string]string `json:"env"`
+		} `json:"mcpServers"`
+	}
+	if err := json.Unmarshal([]byte(raw), &cfg); err != nil {
+		t.Fatalf("unmarshal: %v", err)
+	}
+	if s := cfg.MCPServers["fetch"]; s.Command != "uvx" || s.Args[0] != "a" || s.Env["K"] != "v" {
+		t.Fatalf("unexpected config: %s", raw)
+	}
+
+	raw, _ = ClaudeJSON(nil)
+	if !strings.Contains(raw, `"mcpServers": {}`) {
+		t.Fatalf("empty config should keep mcpServers object: %s", raw)
+	}
+}
+
+func TestCodexTOML(t *testing.T) {
+	out := CodexTOML([]Server{
+		{Name: "sequential-thinking", Command: "npx", Args: []string{"-y", "pkg"}},
+		{Name: "comment_updater", Command: "swe-mcp", Args: []string{"comment"}, Env: map[string]string{
+			"REPO_NAME":    "demo",
+			"GITHUB_TOKEN": `to"k\en`,
+		}},
+	})
+	for _, want := range []string{
+		"[mcp_servers.sequential_thinking]\ncommand = \"npx\"\nargs = [\"-y\", \"pkg\"]\n",
+		"[mcp_servers.comment_updater.env]\nGITHUB_TOKEN = \"to\\\"k\\\\en\"\nREPO_NAME = \"demo\"\n",
+	} {
+		if !strings.Contains(out, want) {
+			t.Fatalf("CodexTOML missing %q\n%s", want, out)
+		}
+	}
+}
+
+func TestTOMLString(t *testing.T) {
+	if got := tomlString("a\nb\x01"); got != `"a\nb\u0001"` {
+		t.Fatalf("tomlString = %s", got)
+	}
+}
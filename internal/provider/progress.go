@@ -0,0 +1,64 @@
+package provider
+
+import (
+	"bytes"
+	"strings"
+	"sync"
+)
+
+// maxProgressLine caps the length of a reported progress step.
+const maxProgressLine = 200
+
+// ProgressWriter returns a writer that passes each complete line of
+// provider output through describe and reports the non-empty results to
+// progress.
+func ProgressWriter(progress func(string), describe func(line string) string) *LineWriter {
+	return &LineWriter{fn: func(line string) {
+		if step := ShortStep(describe(line)); step != "" {
+			progress(step)
+		}
+	}}
+}
+
+// NewLineWriter returns a writer that calls fn with every complete line of
+// provider output.
+func NewLineWriter(fn func(line string)) *LineWriter {
+	return &LineWriter{fn: fn}
+}
+
+// LineWriter calls fn for every complete line written to it.
+type LineWriter struct {
+	mu  sync.Mutex
+	buf []byte
+	fn  func(line string)
+}
+
+func (w *LineWriter) Write(p []byte) (int, error) {
+	w.mu.Lock()
+	defer w.mu.Unlock()
+	w.buf = append(w.buf, p...)
+	for {
+		i := bytes.IndexByte(w.buf, '\n')
+		if i < 0 {
+			break
+		}
+		line := string(w.buf[:i])
+		w.buf = w.buf[i+1:]
+		w.fn(line)
+	}
+	return len(p), nil
+}
+
+// ShortStep reduces s to its first non-empty line, at most maxProgressLine
+// bytes long.
+func ShortStep(s string) string {
+	for _, line := range strings.Split(s, "\n") {
+		if line = strings.TrimSpace(line); line != "" {
+			if len(line) > maxProgressLine {
+				line = strings.ToValidUTF8(line[:maxProgressLine], "") + "..."
+			}
+			return line
+		}
+	}
+	return ""
+}
@@ -1,6 +1,19 @@
 package provider
 
-import "context"
+import (
+	"context"
+	"io"
+)
+
+// MCPServerBinary is the unified MCP server binary spawned by provider CLIs.
+// Each MCP server it hosts is selected by subcommand (e.g. "swe-mcp comment").
+const MCPServerBinary = "swe-mcp"
+
+// MCPCommentSubcommand selects the coordinating comment updater server.
+const MCPCommentSubcommand = "comment"
+
+// MCPReviewSubcommand selects the pull request review thread server.
+const MCPReviewSubcommand = "review"
 
 // Provider is the interface that all AI providers must implement
 type Provider interface {
@@ -24,9 +37,53 @@ type CodeRequest struct {
 	// back to their defaults to preserve backwards compatibility.
 	AllowedTools    []string
 	DisallowedTools []string
+
+	// MCPServers turns MCP servers on or off by name for this task's
+	// repository (see mcpconfig.Names); nil keeps the default servers.
+	MCPServers map[string]bool
+
+	// MCPLauncher, when set, prefixes every MCP server command, which then
+	// runs as `MCPLauncher... NAME -- COMMAND ARGS...` so that it can be
+	// supervised.
+	MCPLauncher []string
+
+	// Env is added to the provider process environment ("KEY=value"); it
+	// reaches every command the provider runs, including MCP servers.
+	Env []string
+
+	// Progress, when set, receives a one-line description of each step the
+	// provider reports while it runs (tool calls, messages).
+	Progress func(step string)
+
+	// Events, when set, receives the structured steps of the run (tool
+	// calls, commands, messages) from providers that report them.
+	Events func(Event)
+
+	// Transcript, when set, receives the provider CLI's raw output as it
+	// runs, for keeping alongside the task.
+	Transcript io.Writer
+
+	// Stderr, when set, receives the provider CLI's standard error as it
+	// runs (tasks run with --debug).
+	Stderr io.Writer
 }
 
 // CodeResponse is the minimal response; AI handles changes via MCP
 type CodeResponse struct {
 	Summary string
+	// CostUSD is the reported execution cost when the provider exposes it (0 otherwise).
+	CostUSD float64
+}
+
+// Prewarmer is implemented by providers that can start their CLI before the
+// prompt is ready. Prewarm receives the request without its Prompt; a later
+// GenerateCode with otherwise identical settings attaches to the started
+// process instead of paying the cold start.
+type Prewarmer interface {
+	Prewarm(req *CodeRequest)
+}
+
+// ModelReporter is implemented by providers that can report the model they run.
+type ModelReporter interface {
+	Model() string
 }
@@ -2,9 +2,10 @@ package shared
 
 import (
 	"fmt"
-	"log"
 	"regexp"
 	"strings"
+
+	"github.com/cexll/swe/internal/logging"
 )
 
 // FileChange captures a single file edit extracted from a provider response.
@@ -239,9 +240,5 @@ func IsPlaceholderSummary(summary string) bool {
 }
 
 func logPlaceholder(providerLabel, format string, args ...interface{}) {
-	if providerLabel == "" {
-		log.Printf(format, args...)
-		return
-	}
-	log.Printf("["+providerLabel+"] "+format, args...)
+	logging.Logger(logging.KeyPhase, "provider", "provider", strings.ToLower(providerLabel)).Warn(fmt.Sprintf(format, args...))
 }
@@ -0,0 +1,256 @@
+package reposettings
+
+import (
+	"bytes"
+	"fmt"
+	"sort"
+	"strings"
+
+	"github.com/cexll/swe/internal/miniyaml"
+)
+
+// ActionDefaultTrigger is claude-code-action's trigger phrase when a workflow
+// does not set one.
+const ActionDefaultTrigger = "@claude"
+
+// Import is what FromWorkflow found in a claude-code-action workflow.
+type Import struct {
+	Settings Settings
+	Notes    []string // inputs that could not be carried over, and why
+}
+
+// actionInputs are the claude-code-action inputs without a per-repository
+// equivalent, with what to do instead.
+var actionInputs = map[string]string{
+	"anthropic_api_key":       "credentials are the server's (ANTHROPIC_API_KEY)",
+	"claude_code_oauth_token": "credentials are the server's (ANTHROPIC_API_KEY)",
+	"github_token":            "the server acts as its GitHub App",
+	"use_bedrock":             "the provider is chosen server-wide (PROVIDER)",
+	"use_vertex":              "the provider is chosen server-wide (PROVIDER)",
+	"model":                   "set CLAUDE_MODEL server-wide",
+	"anthropic_model":         "set CLAUDE_MODEL server-wide",
+	"fallback_model":          "set CLAUDE_MODEL server-wide",
+	"use_commit_signing":      "set USE_COMMIT_SIGNING server-wide",
+	"mcp_config":              "custom MCP servers are not supported; mcp_servers toggles the built-in ones",
+	"timeout_minutes":         "timeouts are server-wide",
+	"base_branch":             "tasks branch from the repository's default branch",
+	"branch_prefix":           "agent branches are named swe-agent/<number>-<time>",
+	"assignee_trigger":        "only comments trigger tasks",
+	"label_trigger":           "only comments trigger tasks",
+	"direct_prompt":           "runs a fixed prompt without a comment; only comments trigger tasks",
+	"override_prompt":         "replaces the whole prompt; use instructions to add to it",
+	"prompt":                  "runs a fixed prompt without a comment; only comments trigger tasks",
+	"claude_env":              "task environment variables are not configurable per repository",
+	"settings":                "Claude settings files are not configurable per repository",
+	"additional_permissions":  "the GitHub App's permissions apply",
+}
+
+// FromWorkflow reads the first claude-code-action step of a GitHub Actions
+// workflow and maps its inputs (trigger_phrase, allowed_tools,
+// disallowed_tools, custom_instructions and the matching claude_args flags)
+// to Settings. ok is false when the workflow does not use the action.
+func FromWorkflow(data []byte) (imp Import, ok bool, err error) {
+	if !bytes.Contains(data, []byte("claude-code-action")) {
+		return Import{}, false, nil
+	}
+	doc, err := miniyaml.Parse(data)
+	if err != nil {
+		return Import{}, false, err
+	}
+	inputs, ok, err := actionStepInputs(doc)
+	if err != nil || !ok {
+		return Import{}, ok, err
+	}
+
+	imp.Settings.TriggerKeyword = ActionDefaultTrigger
+	names := make([]string, 0, len(inputs))
+	for name := range inputs {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+	for _, name := range names {
+		value := strings.TrimSpace(inputs[name])
+		switch name {
+		case "trigger_phrase":
+			if value != "" {
+				imp.Settings.TriggerKeyword = value
+			}
+		case "allowed_tools":
+			imp.Settings.AllowedTools = append(imp.Settings.AllowedTools, splitTools(value)...)
+		case "disallowed_tools":
+			imp.Settings.DisallowedTools = append(imp.Settings.DisallowedTools, splitTools(value)...)
+		case "custom_instructions", "append_system_prompt":
+			imp.Settings.Instructions = joinInstructions(imp.Settings.Instructions, value)
+		case "claude_args":
+			imp.Notes = append(imp.Notes, imp.applyClaudeArgs(value)...)
+		default:
+			if why, known := actionInputs[name]; known {
+				imp.Notes = append(imp.Notes, fmt.Sprintf("%s: not imported, %s", name, why))
+			} else {
+				imp.Notes = append(imp.Notes, fmt.Sprintf("%s: not imported, unknown input", name))
+			}
+		}
+	}
+	return imp, true, nil
+}
+
+// applyClaudeArgs maps the Claude CLI flags of claude_args.
+func (imp *Import) applyClaudeArgs(args string) []string {
+	var notes []string
+	words := shellWords(args)
+	for i := 0; i < len(words); i++ {
+		flag := words[i]
+		if !strings.HasPrefix(flag, "--") {
+			continue
+		}
+		name, value, inline := strings.Cut(flag, "=")
+		var values []string
+		if inline {
+			values = []string{value}
+		} else {
+			for i+1 < len(words) && !strings.HasPrefix(words[i+1], "--") {
+				i++
+				values = append(values, words[i])
+			}
+		}
+		switch name {
+		case "--allowedTools", "--allowed-tools":
+			for _, v := range values {
+				imp.Settings.AllowedTools = append(imp.Settings.AllowedTools, splitTools(v)...)
+			}
+		case "--disallowedTools", "--disallowed-tools":
+			for _, v := range values {
+				imp.Settings.DisallowedTools = append(imp.Settings.DisallowedTools, splitTools(v)...)
+			}
+		case "--append-system-prompt":
+			imp.Settings.Instructions = joinInstructions(imp.Settings.Instructions, strings.Join(values, " "))
+		case "--model", "--fallback-model":
+			notes = append(notes, fmt.Sprintf("claude_args %s: not imported, set CLAUDE_MODEL server-wide", name))
+		case "--system-prompt":
+			notes = append(notes, "claude_args --system-prompt: not imported, it replaces the whole prompt; use instructions to add to it")
+		default:
+			notes = append(notes, fmt.Sprintf("claude_args %s: not imported, no per-repository equivalent", name))
+		}
+	}
+	return notes
+}
+
+func joinInstructions(a, b string) string {
+	b = strings.TrimSpace(b)
+	switch {
+	case b == "":
+		return a
+	case a == "":
+		return b
+	}
+	return a + "\n\n" + b
+}
+
+// splitTools splits a tool list on commas and newlines outside parentheses:
+// "Bash(git add, git commit),Edit" is two tools.
+func splitTools(s string) []string {
+	var tools []string
+	depth, start := 0, 0
+	flush := func(end int) {
+		if tool := strings.TrimSpace(s[start:end]); tool != "" {
+			tools = append(tools, tool)
+		}
+		start = end + 1
+	}
+	for i, c := range s {
+		switch {
+		case c == '(':
+			depth++
+		case c == ')' && depth > 0:
+			depth--
+		case (c == ',' || c == '\n') && depth == 0:
+			flush(i)
+		}
+	}
+	flush(len(s))
+	return tools
+}
+
+// shellWords splits a command line the way a POSIX shell would for plain
+// words and single- or double-quoted strings; newlines separate words.
+func shellWords(s string) []string {
+	var words []string
+	var cur strings.Builder
+	inWord := false
+	var quote rune
+	for _, c := range s {
+		switch {
+		case quote != 0:
+			if c == quote {
+				quote = 0
+			} else {
+				cur.WriteRune(c)
+			}
+		case c == '\'' || c == '"':
+			quote, inWord = c, true
+		case c == ' ' || c == '\t' || c == '\n' || c == '\\':
+			if c == '\\' {
+				continue // line continuations
+			}
+			if inWord {
+				words = append(words, cur.String())
+				cur.Reset()
+				inWord = false
+			}
+		default:
+			cur.WriteRune(c)
+			inWord = true
+		}
+	}
+	if inWord {
+		words = append(words, cur.String())
+	}
+	return words
+}
+
+// actionStepInputs finds the first step using claude-code-action and returns
+// the scalars under its with: key.
+func actionStepInputs(doc *miniyaml.Node) (map[string]string, bool, error) {
+	step := actionStep(doc)
+	if step == nil {
+		return nil, false, nil
+	}
+	inputs := make(map[string]string)
+	with := step.Get("with")
+	switch {
+	case with == nil || with.Kind == miniyaml.Scalar && with.Value == "":
+		return inputs, true, nil
+	case with.Kind != miniyaml.Mapping:
+		return nil, true, fmt.Errorf("line %d: with: must be a block mapping", with.Line)
+	}
+	for _, p := range with.Pairs {
+		if p.Value.Kind == miniyaml.Mapping || p.Value.Kind == miniyaml.Sequence {
+			return nil, true, fmt.Errorf("line %d: %s: must be a string", p.Value.Line, p.Key)
+		}
+		inputs[p.Key] = p.Value.Value
+	}
+	return inputs, true, nil
+}
+
+// actionStep returns the first mapping, in document order, whose uses: key
+// names claude-code-action.
+func actionStep(n *miniyaml.Node) *miniyaml.Node {
+	switch n.Kind {
+	case miniyaml.Mapping:
+		if uses := n.Get("uses"); uses != nil && uses.Kind == miniyaml.Scalar && strings.Contains(uses.Value, "claude-code-action") {
+			return n
+		}
+		for _, p := range n.Pairs {
+			if step := actionStep(p.Value); step != nil {
+				return step
+			}
+		}
+	case miniyaml.Sequence:
+		for _, item := range n.Items {
+			if step := actionStep(item); step != nil {
+				return step
+			}
+		}
+	}
+	return nil
+}
@@ -0,0 +1,152 @@
+// Package reposettings holds per-repository overrides of server settings: the
+// trigger keyword, extra allowed and disallowed tools, the MCP servers tasks
+// get, instructions added to every prompt, paths left out of its file list,
+// the commands that set up the checkout and the language of tracking
+// comments and the priority of its tasks. They live in one JSON file keyed by owner/name, which
+// `swe-agent import-action` can generate from claude-code-action workflows.
+package reposettings
+
+import (
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"os"
+	"path"
+	"sort"
+	"strings"
+
+	"github.com/cexll/swe/internal/github/comment"
+	"github.com/cexll/swe/internal/provider/mcpconfig"
+)
+
+// Settings overrides the server configuration for one repository; empty
+// fields keep the server's.
+type Settings struct {
+	TriggerKeyword  string   `json:"trigger_keyword,omitempty"`
+	AllowedTools    []string `json:"allowed_tools,omitempty"`
+	DisallowedTools []string `json:"disallowed_tools,omitempty"`
+	Instructions    string   `json:"instructions,omitempty"`
+	// MCPServers turns MCP servers on (the optional git, github and
+	// file_ops) or off (the defaults) by name
+	MCPServers map[string]bool `json:"mcp_servers,omitempty"`
+	// FileListExclude leaves paths out of the repository file list in the
+	// prompt: "dir/" for a directory anywhere, otherwise a path.Match
+	// pattern for the path or the file name
+	FileListExclude []string `json:"file_list_exclude,omitempty"`
+	// Setup are shell commands run in the checkout before the provider,
+	// replacing the setup of the detected toolchains
+	Setup []string `json:"setup,omitempty"`
+	// Language is the language of the tracking comment: "en", "zh", or
+	// "auto" (the default) for the language of the trigger comment
+	Language string `json:"language,omitempty"`
+	// Priority is where the repository's tasks queue when the command
+	// does not say: "high", "normal" or "low". By default replies to pull
+	// request reviews are high and other tasks normal
+	Priority string `json:"priority,omitempty"`
+}
+
+// Set is the parsed settings file; a nil Set has no overrides.
+type Set struct {
+	source string
+	repos  map[string]Settings // by lowercase owner/name
+}
+
+// Load reads a settings file; an empty path returns nil.
+func Load(path string) (*Set, error) {
+	if path == "" {
+		return nil, nil
+	}
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return nil, fmt.Errorf("repository settings: %w", err)
+	}
+	s, err := Parse(data)
+	if err != nil {
+		return nil, fmt.Errorf("repository settings %s: %w", path, err)
+	}
+	return s, nil
+}
+
+// Parse parses a JSON object mapping owner/name to Settings.
+func Parse(data []byte) (*Set, error) {
+	var repos map[string]Settings
+	dec := json.NewDecoder(bytes.NewReader(data))
+	dec.DisallowUnknownFields()
+	if err := dec.Decode(&repos); err != nil {
+		return nil, err
+	}
+	s := &Set{source: string(data), repos: make(map[string]Settings, len(repos))}
+	for repo, settings := range repos {
+		key := strings.ToLower(strings.TrimSpace(repo))
+		if owner, name, ok := strings.Cut(key, "/"); !ok || owner == "" || name == "" || strings.Contains(name, "/") {
+			return nil, fmt.Errorf("%q must be owner/name", repo)
+		}
+		if _, dup := s.repos[key]; dup {
+			return nil, fmt.Errorf("%q is listed twice", repo)
+		}
+		for server := range settings.MCPServers {
+			if !mcpconfig.Known(server) {
+				return nil, fmt.Errorf("%s: unknown MCP server %q (known: %s)", repo, server, strings.Join(mcpconfig.Names(), ", "))
+			}
+		}
+		for _, pattern := range settings.FileListExclude {
+			if _, err := path.Match(strings.TrimSuffix(pattern, "/"), ""); err != nil || strings.TrimSpace(pattern) == "" {
+				return nil, fmt.Errorf("%s: invalid file_list_exclude pattern %q", repo, pattern)
+			}
+		}
+		for _, command := range settings.Setup {
+			if strings.TrimSpace(command) == "" {
+				return nil, fmt.Errorf("%s: empty setup command", repo)
+			}
+		}
+		if _, err := comment.ParseLang(settings.Language); err != nil {
+			return nil, fmt.Errorf("%s: %w", repo, err)
+		}
+		switch strings.ToLower(strings.TrimSpace(settings.Priority)) {
+		case "", "high", "normal", "low":
+		default:
+			return nil, fmt.Errorf("%s: unknown priority %q (want high, normal or low)", repo, settings.Priority)
+		}
+		settings.TriggerKeyword = strings.TrimSpace(settings.TriggerKeyword)
+		s.repos[key] = settings
+	}
+	return s, nil
+}
+
+// For returns the overrides for repo (owner/name).
+func (s *Set) For(repo string) Settings {
+	if s == nil {
+		return Settings{}
+	}
+	return s.repos[strings.ToLower(repo)]
+}
+
+// Repos lists the repositories with overrides, sorted.
+func (s *Set) Repos() []string {
+	if s == nil {
+		return nil
+	}
+	out := make([]string, 0, len(s.repos))
+	for repo := range s.repos {
+		out = append(out, repo)
+	}
+	sort.Strings(out)
+	return out
+}
+
+// Equal reports whether s and o were parsed from the same document.
+func (s *Set) Equal(o *Set) bool {
+	if s == nil || o == nil {
+		return s == o
+	}
+	return s.source == o.source
+}
+
+// PromptSection renders instructions for the end of the prompt, or "".
+func PromptSection(instructions string) string {
+	instructions = strings.TrimSpace(instructions)
+	if instructions == "" {
+		return ""
+	}
+	return "<custom_instructions>\nThe repository's maintainers ask you to follow these instructions as well:\n" + instructions + "\n</custom_instructions>"
+}
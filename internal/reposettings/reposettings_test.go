@@ -0,0 +1,185 @@
+package reposettings
+
+import (
+	"reflect"
+	"strings"
+	"testing"
+)
+
+func TestParseAndFor(t *testing.T) {
+	s, err := Parse([]byte(`{
+  "Acme/API": {"trigger_keyword": " @claude ", "allowed_tools": ["Bash(npm test)"], "instructions": "Use pnpm."},
+  "acme/web": {"disallowed_tools": ["WebFetch"], "mcp_servers": {"git": true, "fetch": false}}
+}`))
+	if err != nil {
+		t.Fatalf("Parse: %v", err)
+	}
+	if got := s.For("acme/api"); got.TriggerKeyword != "@claude" || got.Instructions != "Use pnpm." || !reflect.DeepEqual(got.AllowedTools, []string{"Bash(npm test)"}) {
+		t.Fatalf("For(acme/api) = %+v", got)
+	}
+	if got := s.For("acme/web").MCPServers; !reflect.DeepEqual(got, map[string]bool{"git": true, "fetch": false}) {
+		t.Fatalf("For(acme/web).MCPServers = %v", got)
+	}
+	if got := s.For("other/repo"); !reflect.DeepEqual(got, Settings{}) {
+		t.Fatalf("For(other/repo) = %+v", got)
+	}
+	if got := s.Repos(); !reflect.DeepEqual(got, []string{"acme/api", "acme/web"}) {
+		t.Fatalf("Repos = %v", got)
+	}
+	var none *Set
+	if none.For("acme/api").TriggerKeyword != "" || none.Repos() != nil || !none.Equal(nil) || none.Equal(s) {
+		t.Fatal("a nil Set has no overrides")
+	}
+
+	for doc, want := range map[string]string{
+		`{"acme": {}}`:                       "must be owner/name",
+		`{"a/b": {}, "A/B": {}}`:             "listed twice",
+		`{"a/b": {"trigger": "@x"}}`:         "unknown field",
+		`{"a/b": {"allowed_tools": "Edit"}}`: "cannot unmarshal",
+	} {
+		if _, err := Parse([]byte(doc)); err == nil || !strings.Contains(err.Error(), want) {
+			t.Errorf("Parse(%s) = %v, want %q", doc, err, want)
+		}
+	}
+	if _, err := Parse([]byte(`{"a/b": {"mcp_servers": {"gitlab": true}}}`)); err == nil || !strings.Contains(err.Error(), `unknown MCP server "gitlab"`) {
+		t.Errorf("Parse with an unknown MCP server = %v", err)
+	}
+	if _, err := Parse([]byte(`{"a/b": {"file_list_exclude": ["fixtures/", "[a-"]}}`)); err == nil || !strings.Contains(err.Error(), `invalid file_list_exclude pattern "[a-"`) {
+		t.Errorf("Parse with a bad exclude pattern = %v", err)
+	}
+	if _, err := Parse([]byte(`{"a/b": {"setup": ["npm ci", " "]}}`)); err == nil || !strings.Contains(err.Error(), "empty setup command") {
+		t.Errorf("Parse with an empty setup command = %v", err)
+	}
+	if _, err := Parse([]byte(`{"a/b": {"language": "fr"}}`)); err == nil || !strings.Contains(err.Error(), `unknown language "fr"`) {
+		t.Errorf("Parse with an unknown language = %v", err)
+	}
+	if _, err := Parse([]byte(`{"a/b": {"priority": "urgent"}}`)); err == nil || !strings.Contains(err.Error(), `unknown priority "urgent"`) {
+		t.Errorf("Parse with an unknown priority = %v", err)
+	}
+}
+
+func TestPromptSection(t *testing.T) {
+	if PromptSection("  ") != "" {
+		t.Fatal("no instructions, no section")
+	}
+	if got := PromptSection("Run make lint.\n"); !strings.HasPrefix(got, "<custom_instructions>\n") || !strings.Contains(got, "\nRun make lint.\n</custom_instructions>") {
+		t.Fatalf("PromptSection = %q", got)
+	}
+}
+
+const betaWorkflow = `name: Claude Code
+
+on:
+  issue_comment:
+    types: [created]
+
+jobs:
+  claude:
+    if: contains(github.event.comment.body, '/claude')
+    runs-on: ubuntu-latest
+    steps:
+      - name: Checkout repository
+        uses: actions/checkout@v4
+        with:
+          fetch-depth: 1
+
+      - name: Run Claude Code
+        id: claude
+        uses: anthropics/claude-code-action@beta # pinned later
+        with:
+          anthropic_api_key: ${{ secrets.ANTHROPIC_API_KEY }}
+          trigger_phrase: "/claude"
+          model: claude-opus-4-1
+          allowed_tools: "Bash(npm install),Bash(npm run test:*),Edit"
+          disallowed_tools: |
+            WebFetch
+            Bash(git push, git tag)
+          custom_instructions: |
+            Follow our coding standards.
+            # Not a comment: part of the instructions.
+            Run the tests before committing.
+      - name: After
+        run: echo done
+`
+
+func TestFromWorkflow_Beta(t *testing.T) {
+	imp, ok, err := FromWorkflow([]byte(betaWorkflow))
+	if err != nil || !ok {
+		t.Fatalf("FromWorkflow = %v, %t", err, ok)
+	}
+	want := Settings{
+		TriggerKeyword:  "/claude",
+		AllowedTools:    []string{"Bash(npm install)", "Bash(npm run test:*)", "Edit"},
+		DisallowedTools: []string{"WebFetch", "Bash(git push, git tag)"},
+		Instructions:    "Follow our coding standards.\n# Not a comment: part of the instructions.\nRun the tests before committing.",
+	}
+	if !reflect.DeepEqual(imp.Settings, want) {
+		t.Fatalf("settings = %#v\nwant %#v", imp.Settings, want)
+	}
+	wantNotes := []string{
+		"anthropic_api_key: not imported, credentials are the server's (ANTHROPIC_API_KEY)",
+		"model: not imported, set CLAUDE_MODEL server-wide",
+	}
+	if !reflect.DeepEqual(imp.Notes, wantNotes) {
+		t.Fatalf("notes = %q", imp.Notes)
+	}
+}
+
+const v1Workflow = `on: [issue_comment, pull_request_review_comment]
+jobs:
+  claude:
+    runs-on: ubuntu-latest
+    steps:
+    - uses: actions/checkout@v4
+    - with:
+        claude_code_oauth_token: ${{ secrets.CLAUDE_CODE_OAUTH_TOKEN }}
+        claude_args: >-
+          --allowedTools "Bash(go test:*),Bash(go vet:*)"
+          --disallowedTools=WebSearch
+          --append-system-prompt 'Prefer table-driven tests.'
+          --max-turns 10
+          --model claude-sonnet-4-5
+        label_trigger: claude
+      uses: 'anthropics/claude-code-action@v1'
+`
+
+func TestFromWorkflow_V1ClaudeArgs(t *testing.T) {
+	imp, ok, err := FromWorkflow([]byte(v1Workflow))
+	if err != nil || !ok {
+		t.Fatalf("FromWorkflow = %v, %t", err, ok)
+	}
+	want := Settings{
+		TriggerKeyword:  ActionDefaultTrigger,
+		AllowedTools:    []string{"Bash(go test:*)", "Bash(go vet:*)"},
+		DisallowedTools: []string{"WebSearch"},
+		Instructions:    "Prefer table-driven tests.",
+	}
+	if !reflect.DeepEqual(imp.Settings, want) {
+		t.Fatalf("settings = %#v\nwant %#v", imp.Settings, want)
+	}
+	notes := strings.Join(imp.Notes, "\n")
+	for _, n := range []string{"claude_args --max-turns", "claude_args --model", "label_trigger: not imported", "claude_code_oauth_token"} {
+		if !strings.Contains(notes, n) {
+			t.Errorf("notes do not mention %q:\n%s", n, notes)
+		}
+	}
+}
+
+func TestFromWorkflow_NotUsed(t *testing.T) {
+	if _, ok, err := FromWorkflow([]byte("jobs:\n  test:\n    steps:\n      - uses: actions/checkout@v4\n")); ok || err != nil {
+		t.Fatalf("FromWorkflow = %t, %v", ok, err)
+	}
+	imp, ok, err := FromWorkflow([]byte("steps:\n  - uses: anthropics/claude-code-action@v1\n"))
+	if !ok || err != nil || imp.Settings.TriggerKeyword != ActionDefaultTrigger {
+		t.Fatalf("without inputs = %+v, %t, %v", imp, ok, err)
+	}
+}
+
+func TestSplitToolsAndShellWords(t *testing.T) {
+	if got := splitTools("Bash(a, b),\nEdit ,, Read"); !reflect.DeepEqual(got, []string{"Bash(a, b)", "Edit", "Read"}) {
+		t.Fatalf("splitTools = %q", got)
+	}
+	if got := shellWords(`--a "x y" 'z "q"' \` + "\n" + `--b=c`); !reflect.DeepEqual(got, []string{"--a", "x y", `z "q"`, "--b=c"}) {
+		t.Fatalf("shellWords = %q", got)
+	}
+}
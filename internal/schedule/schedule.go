@@ -0,0 +1,440 @@
+// Package schedule runs recurring tasks — a weekly dependency audit, a TODO
+// sweep — on cron schedules. Jobs live in one JSON file; each run opens an
+// issue in the job's repository and queues the job's prompt on it, so the
+// findings land in that issue and any fix in a pull request, exactly as if
+// an operator had asked for it.
+package schedule
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"log/slog"
+	"os"
+	"path/filepath"
+	"reflect"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/cexll/swe/internal/logging"
+)
+
+// TickInterval is how often the leader checks for due jobs.
+const TickInterval = time.Minute
+
+// missedAfter is how late a run may start: a run due longer ago (the server
+// was down, or another replica led at the time) is missed, and the catch-up
+// policy decides whether it is made up.
+const missedAfter = 10 * time.Minute
+
+// CatchUp is what the scheduler does about a job's missed runs.
+type CatchUp string
+
+const (
+	CatchUpSkip CatchUp = "skip" // missed runs are skipped (default)
+	CatchUpOnce CatchUp = "once" // the job runs once, late, for all its missed runs
+)
+
+// ParseCatchUp parses a catch-up policy; "" is CatchUpSkip.
+func ParseCatchUp(s string) (CatchUp, error) {
+	switch c := CatchUp(strings.ToLower(strings.TrimSpace(s))); c {
+	case "":
+		return CatchUpSkip, nil
+	case CatchUpSkip, CatchUpOnce:
+		return c, nil
+	}
+	return "", fmt.Errorf("catch-up policy %q must be skip or once", s)
+}
+
+// allow tests to control time
+var now = time.Now
+
+// Job is one recurring task.
+type Job struct {
+	Name     string `json:"name"`
+	Cron     string `json:"cron"`               // five fields or @daily, @weekly, ...
+	Timezone string `json:"timezone,omitempty"` // IANA name; default UTC
+	Repo     string `json:"repo"`               // owner/name
+	Prompt   string `json:"prompt"`             // instruction, as written after the trigger keyword
+	// Title of the issue each run opens; the job name and date by default
+	Title      string   `json:"title,omitempty"`
+	Labels     []string `json:"labels,omitempty"`      // added to the issue
+	BaseBranch string   `json:"base_branch,omitempty"` // defaults to the repository's default branch
+	Disabled   bool     `json:"disabled,omitempty"`    // listed, but only runs when started by hand
+}
+
+// IssueTitle returns the title of the issue for a run at t.
+func (j Job) IssueTitle(t time.Time) string {
+	title := j.Title
+	if title == "" {
+		title = j.Name
+	}
+	return fmt.Sprintf("%s (%s)", title, t.Format("2006-01-02"))
+}
+
+// Load reads a jobs file; an empty path returns no jobs.
+func Load(path string) ([]Job, error) {
+	if path == "" {
+		return nil, nil
+	}
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return nil, fmt.Errorf("schedules: %w", err)
+	}
+	jobs, err := Parse(data)
+	if err != nil {
+		return nil, fmt.Errorf("schedules %s: %w", path, err)
+	}
+	return jobs, nil
+}
+
+// Parse parses a JSON array of jobs and checks each one.
+func Parse(data []byte) ([]Job, error) {
+	var jobs []Job
+	dec := json.NewDecoder(bytes.NewReader(data))
+	dec.DisallowUnknownFields()
+	if err := dec.Decode(&jobs); err != nil {
+		return nil, err
+	}
+	seen := make(map[string]bool, len(jobs))
+	for i := range jobs {
+		j := &jobs[i]
+		j.Name, j.Repo, j.Prompt = strings.TrimSpace(j.Name), strings.TrimSpace(j.Repo), strings.TrimSpace(j.Prompt)
+		if j.Name == "" {
+			return nil, fmt.Errorf("job %d: name is required", i+1)
+		}
+		if seen[j.Name] {
+			return nil, fmt.Errorf("job %q is listed twice", j.Name)
+		}
+		seen[j.Name] = true
+		if owner, name, ok := strings.Cut(j.Repo, "/"); !ok || owner == "" || name == "" || strings.Contains(name, "/") {
+			return nil, fmt.Errorf("job %q: repo must be owner/name", j.Name)
+		}
+		if j.Prompt == "" {
+			return nil, fmt.Errorf("job %q: prompt is required", j.Name)
+		}
+		if _, err := ParseCron(j.Cron); err != nil {
+			return nil, fmt.Errorf("job %q: %w", j.Name, err)
+		}
+		if _, err := time.LoadLocation(j.Timezone); err != nil {
+			return nil, fmt.Errorf("job %q: timezone: %w", j.Name, err)
+		}
+	}
+	return jobs, nil
+}
+
+// Run is one run of a job.
+type Run struct {
+	At       time.Time `json:"at"`
+	Manual   bool      `json:"manual,omitempty"`    // started through the admin API
+	CaughtUp bool      `json:"caught_up,omitempty"` // made up for missed runs (CatchUpOnce)
+	TaskID   string    `json:"task_id,omitempty"`
+	Issue    int       `json:"issue,omitempty"`
+	Error    string    `json:"error,omitempty"`
+}
+
+// Status is a job with its next and last run.
+type Status struct {
+	Job
+	Next    *time.Time `json:"next,omitempty"` // nil when disabled
+	LastRun *Run       `json:"last_run,omitempty"`
+	// Skipped is when the last run skipped under CatchUpSkip was due
+	Skipped *time.Time `json:"skipped,omitempty"`
+}
+
+// Launch starts a run of job: it opens the job's issue and queues the task,
+// returning the task ID and issue number.
+type Launch func(ctx context.Context, job Job) (taskID string, issue int, err error)
+
+// ErrUnknownJob is returned by RunNow for a name no job has.
+var ErrUnknownJob = errors.New("unknown scheduled job")
+
+type entry struct {
+	job     Job
+	cron    *Cron
+	loc     *time.Location
+	checked time.Time // the runs due until then were started or skipped
+	next    time.Time
+	last    *Run
+	skipped *time.Time
+}
+
+// state is what the state file keeps of a job.
+type state struct {
+	Checked time.Time  `json:"checked"`
+	LastRun *Run       `json:"last_run,omitempty"`
+	Skipped *time.Time `json:"skipped,omitempty"`
+}
+
+// Scheduler launches jobs when they are due. Tick is meant to be called
+// every TickInterval by the leader only, so each run starts once however
+// many replicas there are.
+type Scheduler struct {
+	mu      sync.Mutex
+	launch  Launch
+	catchUp CatchUp
+	path    string // state file; "" keeps the state in memory
+	entries []*entry
+}
+
+// New returns a scheduler for jobs as returned by Parse.
+func New(jobs []Job, launch Launch) *Scheduler {
+	s := &Scheduler{launch: launch}
+	s.Replace(jobs)
+	return s
+}
+
+// Replace swaps in a new list of jobs, as after a reload. Unchanged jobs keep
+// their next and last runs.
+func (s *Scheduler) Replace(jobs []Job) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	old := make(map[string]*entry, len(s.entries))
+	for _, e := range s.entries {
+		old[e.job.Name] = e
+	}
+	t := now()
+	entries := make([]*entry, 0, len(jobs))
+	for _, job := range jobs {
+		if e, ok := old[job.Name]; ok && reflect.DeepEqual(e.job, job) {
+			entries = append(entries, e)
+			continue
+		}
+		// Parse has checked both
+		cron, _ := ParseCron(job.Cron)
+		loc, _ := time.LoadLocation(job.Timezone)
+		e := &entry{job: job, cron: cron, loc: loc, checked: t, next: cron.Next(t.In(loc))}
+		if prev, ok := old[job.Name]; ok {
+			e.last, e.skipped = prev.last, prev.skipped
+		}
+		entries = append(entries, e)
+	}
+	s.entries = entries
+}
+
+// SetCatchUp sets what Tick does about missed runs.
+func (s *Scheduler) SetCatchUp(c CatchUp) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.catchUp = c
+}
+
+// LoadState keeps the scheduler's state, each job's last run and how far
+// its schedule was followed, in the JSON file at path, and picks up what
+// it holds. A run that fell due while the server was down is then missed
+// at the next tick rather than forgotten. Replicas should share the file, so
+// that a new leader knows what the previous one started.
+func (s *Scheduler) LoadState(path string) error {
+	if err := os.MkdirAll(filepath.Dir(path), 0o755); err != nil {
+		return fmt.Errorf("create schedules state dir: %w", err)
+	}
+	states, err := readState(path)
+	if err != nil {
+		return err
+	}
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.path = path
+	s.mergeLocked(states, true)
+	return nil
+}
+
+// readState reads the state file at path; a missing file holds nothing.
+func readState(path string) (map[string]state, error) {
+	data, err := os.ReadFile(path)
+	if os.IsNotExist(err) {
+		return nil, nil
+	}
+	if err != nil {
+		return nil, fmt.Errorf("open schedules state: %w", err)
+	}
+	var states map[string]state
+	if err := json.Unmarshal(data, &states); err != nil {
+		return nil, fmt.Errorf("parse schedules state %s: %w", path, err)
+	}
+	return states, nil
+}
+
+// mergeLocked takes the state of each job from states where it is newer
+// than the scheduler's, or wherever there is one with all.
+func (s *Scheduler) mergeLocked(states map[string]state, all bool) {
+	for _, e := range s.entries {
+		st, ok := states[e.job.Name]
+		if !ok {
+			continue
+		}
+		if all || st.Checked.After(e.checked) {
+			e.checked, e.skipped = st.Checked, st.Skipped
+			e.next = e.cron.Next(st.Checked.In(e.loc))
+		}
+		if st.LastRun != nil && (e.last == nil || st.LastRun.At.After(e.last.At)) {
+			e.last = st.LastRun
+		}
+	}
+}
+
+// syncLocked picks up what other replicas wrote to the state file since it
+// was last read. Errors are logged; the scheduler carries on with its own.
+func (s *Scheduler) syncLocked(ctx context.Context) {
+	if s.path == "" {
+		return
+	}
+	states, err := readState(s.path)
+	if err != nil {
+		slog.WarnContext(ctx, "Failed to read schedules state", logging.KeyPhase, "schedule", "err", err)
+		return
+	}
+	s.mergeLocked(states, false)
+}
+
+// saveLocked rewrites the state file. Errors are logged: a lost write only
+// costs a missed run being judged again.
+func (s *Scheduler) saveLocked(ctx context.Context) {
+	if s.path == "" {
+		return
+	}
+	states := make(map[string]state, len(s.entries))
+	for _, e := range s.entries {
+		states[e.job.Name] = state{Checked: e.checked, LastRun: e.last, Skipped: e.skipped}
+	}
+	data, err := json.MarshalIndent(states, "", "  ")
+	if err == nil {
+		tmp := s.path + ".tmp"
+		if err = os.WriteFile(tmp, append(data, '\n'), 0o600); err == nil {
+			err = os.Rename(tmp, s.path)
+		}
+	}
+	if err != nil {
+		slog.WarnContext(ctx, "Failed to save schedules state", logging.KeyPhase, "schedule", "file", s.path, "err", err)
+	}
+}
+
+// Jobs returns the jobs being scheduled.
+func (s *Scheduler) Jobs() []Job {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	jobs := make([]Job, len(s.entries))
+	for i, e := range s.entries {
+		jobs[i] = e.job
+	}
+	return jobs
+}
+
+// Status returns every job with its next and last run, in file order.
+func (s *Scheduler) Status() []Status {
+	t := now()
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	statuses := make([]Status, len(s.entries))
+	for i, e := range s.entries {
+		statuses[i] = Status{Job: e.job}
+		next := e.next
+		if next.Before(t) {
+			// only the leader ticks; elsewhere the next run is worked out
+			next = e.cron.Next(t.In(e.loc))
+		}
+		if !e.job.Disabled && !next.IsZero() {
+			statuses[i].Next = &next
+		}
+		if e.last != nil {
+			last := *e.last
+			statuses[i].LastRun = &last
+		}
+		if e.skipped != nil {
+			skipped := *e.skipped
+			statuses[i].Skipped = &skipped
+		}
+	}
+	return statuses
+}
+
+// Tick launches the jobs that are due. A job whose run is missed, due more
+// than missedAfter ago, runs once or is skipped as the catch-up policy says.
+func (s *Scheduler) Tick(ctx context.Context) {
+	t := now()
+	var due, caughtUp []*entry
+	s.mu.Lock()
+	s.syncLocked(ctx)
+	changed := false
+	for _, e := range s.entries {
+		if e.next.IsZero() || e.next.After(t) {
+			continue
+		}
+		late := t.Sub(e.next)
+		missed := e.lastDue(t)
+		e.checked, e.next, changed = t, e.cron.Next(t.In(e.loc)), true
+		switch {
+		case e.job.Disabled:
+		case late <= missedAfter:
+			due = append(due, e)
+		case s.catchUp == CatchUpOnce:
+			slog.InfoContext(ctx, "Catching up on missed scheduled job", logging.KeyRepo, e.job.Repo, logging.KeyPhase, "schedule", "job", e.job.Name, "late", late.Round(time.Second))
+			caughtUp = append(caughtUp, e)
+		default:
+			e.skipped = &missed
+			slog.InfoContext(ctx, "Skipped missed scheduled job", logging.KeyRepo, e.job.Repo, logging.KeyPhase, "schedule", "job", e.job.Name, "late", late.Round(time.Second))
+		}
+	}
+	if changed {
+		s.saveLocked(ctx)
+	}
+	s.mu.Unlock()
+
+	for _, e := range due {
+		s.start(ctx, e, false, false)
+	}
+	for _, e := range caughtUp {
+		s.start(ctx, e, false, true)
+	}
+}
+
+// lastDue returns the last run of e due by t, from e.next on.
+func (e *entry) lastDue(t time.Time) time.Time {
+	last := e.next
+	for n := e.cron.Next(last.In(e.loc)); !n.IsZero() && !n.After(t); n = e.cron.Next(n.In(e.loc)) {
+		last = n
+	}
+	return last
+}
+
+// RunNow launches the named job at once, disabled or not, and returns the run.
+func (s *Scheduler) RunNow(ctx context.Context, name string) (Run, error) {
+	s.mu.Lock()
+	var found *entry
+	for _, e := range s.entries {
+		if e.job.Name == name {
+			found = e
+		}
+	}
+	s.mu.Unlock()
+	if found == nil {
+		return Run{}, fmt.Errorf("%w: %s", ErrUnknownJob, name)
+	}
+	run := s.start(ctx, found, true, false)
+	if run.Error != "" {
+		return run, errors.New(run.Error)
+	}
+	return run, nil
+}
+
+// start launches e and records the run.
+func (s *Scheduler) start(ctx context.Context, e *entry, manual, caughtUp bool) Run {
+	run := Run{At: now(), Manual: manual, CaughtUp: caughtUp}
+	taskID, issue, err := s.launch(ctx, e.job)
+	if err != nil {
+		run.Error = err.Error()
+		slog.WarnContext(ctx, "Scheduled job failed to start", logging.KeyRepo, e.job.Repo, logging.KeyPhase, "schedule", "job", e.job.Name, "err", err)
+	} else {
+		run.TaskID, run.Issue = taskID, issue
+		slog.InfoContext(ctx, "Started scheduled job", logging.KeyTaskID, taskID, logging.KeyRepo, e.job.Repo, logging.KeyPhase, "schedule", "job", e.job.Name, "number", issue)
+	}
+	s.mu.Lock()
+	s.syncLocked(ctx)
+	e.last = &run
+	s.saveLocked(ctx)
+	s.mu.Unlock()
+	return run
+}
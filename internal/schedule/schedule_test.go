@@ -0,0 +1,243 @@
+package schedule
+
+import (
+	"context"
+	"errors"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+)
+
+func setNow(t *testing.T, at time.Time) *time.Time {
+	t.Helper()
+	orig := now
+	t.Cleanup(func() { now = orig })
+	clock := at
+	now = func() time.Time { return clock }
+	return &clock
+}
+
+const jobsJSON = `[
+  {"name": "deps", "cron": "0 9 * * mon", "repo": "owner/repo", "prompt": "audit the dependencies", "labels": ["chore"]},
+  {"name": "todos", "cron": "@daily", "timezone": "UTC", "repo": "owner/repo", "prompt": "sweep TODOs", "disabled": true}
+]`
+
+func TestParse(t *testing.T) {
+	jobs, err := Parse([]byte(jobsJSON))
+	if err != nil {
+		t.Fatalf("Parse: %v", err)
+	}
+	if len(jobs) != 2 || jobs[0].Name != "deps" || jobs[0].Labels[0] != "chore" || !jobs[1].Disabled {
+		t.Fatalf("jobs = %+v", jobs)
+	}
+
+	bad := map[string]string{
+		"unknown field": `[{"name": "a", "cron": "@daily", "repo": "o/r", "prompt": "x", "when": "now"}]`,
+		"no name":       `[{"cron": "@daily", "repo": "o/r", "prompt": "x"}]`,
+		"duplicate":     `[{"name": "a", "cron": "@daily", "repo": "o/r", "prompt": "x"}, {"name": "a", "cron": "@daily", "repo": "o/r", "prompt": "y"}]`,
+		"repo":          `[{"name": "a", "cron": "@daily", "repo": "o", "prompt": "x"}]`,
+		"prompt":        `[{"name": "a", "cron": "@daily", "repo": "o/r", "prompt": " "}]`,
+		"cron":          `[{"name": "a", "cron": "every day", "repo": "o/r", "prompt": "x"}]`,
+		"timezone":      `[{"name": "a", "cron": "@daily", "timezone": "Mars/Olympus", "repo": "o/r", "prompt": "x"}]`,
+	}
+	for name, data := range bad {
+		if _, err := Parse([]byte(data)); err == nil {
+			t.Errorf("%s: Parse succeeded", name)
+		}
+	}
+}
+
+func TestJob_IssueTitle(t *testing.T) {
+	at := time.Date(2026, time.March, 9, 9, 0, 0, 0, time.UTC)
+	if got := (Job{Name: "deps"}).IssueTitle(at); got != "deps (2026-03-09)" {
+		t.Errorf("IssueTitle = %q", got)
+	}
+	if got := (Job{Name: "deps", Title: "Dependency audit"}).IssueTitle(at); got != "Dependency audit (2026-03-09)" {
+		t.Errorf("IssueTitle = %q", got)
+	}
+}
+
+func TestScheduler_TickLaunchesDueJobs(t *testing.T) {
+	// Sunday evening; deps is due Monday 09:00, todos (disabled) at midnight
+	clock := setNow(t, time.Date(2026, time.March, 8, 20, 0, 0, 0, time.UTC))
+	jobs, _ := Parse([]byte(jobsJSON))
+	var launched []string
+	s := New(jobs, func(_ context.Context, job Job) (string, int, error) {
+		launched = append(launched, job.Name)
+		return "task-1", 42, nil
+	})
+
+	s.Tick(context.Background())
+	if len(launched) != 0 {
+		t.Fatalf("launched %v before anything was due", launched)
+	}
+
+	*clock = time.Date(2026, time.March, 9, 9, 0, 20, 0, time.UTC)
+	s.Tick(context.Background())
+	s.Tick(context.Background())
+	if strings.Join(launched, ",") != "deps" {
+		t.Fatalf("launched %v, want deps once", launched)
+	}
+
+	statuses := s.Status()
+	deps, todos := statuses[0], statuses[1]
+	if deps.LastRun == nil || deps.LastRun.TaskID != "task-1" || deps.LastRun.Issue != 42 || deps.LastRun.Manual {
+		t.Errorf("deps last run = %+v", deps.LastRun)
+	}
+	if deps.Next == nil || !deps.Next.Equal(time.Date(2026, time.March, 16, 9, 0, 0, 0, time.UTC)) {
+		t.Errorf("deps next = %v", deps.Next)
+	}
+	if todos.Next != nil || todos.LastRun != nil {
+		t.Errorf("disabled job: next %v, last %+v", todos.Next, todos.LastRun)
+	}
+}
+
+func TestScheduler_SkipsMissedRuns(t *testing.T) {
+	clock := setNow(t, time.Date(2026, time.March, 8, 20, 0, 0, 0, time.UTC))
+	jobs, _ := Parse([]byte(jobsJSON))
+	launches := 0
+	s := New(jobs, func(context.Context, Job) (string, int, error) {
+		launches++
+		return "t", 1, nil
+	})
+
+	// a replica that was not leading on Monday morning takes over at noon
+	*clock = time.Date(2026, time.March, 9, 12, 0, 0, 0, time.UTC)
+	s.Tick(context.Background())
+	if launches != 0 {
+		t.Fatalf("a run due three hours ago was started")
+	}
+	deps := s.Status()[0]
+	if deps.Next == nil || !deps.Next.Equal(time.Date(2026, time.March, 16, 9, 0, 0, 0, time.UTC)) {
+		t.Fatalf("next = %v", deps.Next)
+	}
+	if deps.Skipped == nil || !deps.Skipped.Equal(time.Date(2026, time.March, 9, 9, 0, 0, 0, time.UTC)) || deps.LastRun != nil {
+		t.Fatalf("skipped = %v, last run = %+v", deps.Skipped, deps.LastRun)
+	}
+}
+
+func TestScheduler_CatchUpOnce(t *testing.T) {
+	clock := setNow(t, time.Date(2026, time.March, 1, 20, 0, 0, 0, time.UTC))
+	jobs, _ := Parse([]byte(jobsJSON))
+	launches := 0
+	s := New(jobs, func(context.Context, Job) (string, int, error) {
+		launches++
+		return "t", 1, nil
+	})
+	s.SetCatchUp(CatchUpOnce)
+
+	// two Monday runs were missed; the job makes up for them with one
+	*clock = time.Date(2026, time.March, 10, 12, 0, 0, 0, time.UTC)
+	s.Tick(context.Background())
+	s.Tick(context.Background())
+	deps := s.Status()[0]
+	if launches != 1 || deps.LastRun == nil || !deps.LastRun.CaughtUp || deps.Skipped != nil {
+		t.Fatalf("launches = %d, last run = %+v, skipped = %v", launches, deps.LastRun, deps.Skipped)
+	}
+	if deps.Next == nil || !deps.Next.Equal(time.Date(2026, time.March, 16, 9, 0, 0, 0, time.UTC)) {
+		t.Fatalf("next = %v", deps.Next)
+	}
+}
+
+func TestParseCatchUp(t *testing.T) {
+	for in, want := range map[string]CatchUp{"": CatchUpSkip, "skip": CatchUpSkip, " Once ": CatchUpOnce} {
+		if got, err := ParseCatchUp(in); err != nil || got != want {
+			t.Errorf("ParseCatchUp(%q) = %q, %v", in, got, err)
+		}
+	}
+	if _, err := ParseCatchUp("all"); err == nil {
+		t.Error("ParseCatchUp(all) succeeded")
+	}
+}
+
+func TestScheduler_StateSurvivesRestart(t *testing.T) {
+	clock := setNow(t, time.Date(2026, time.March, 8, 20, 0, 0, 0, time.UTC))
+	path := filepath.Join(t.TempDir(), "state", "schedules.json")
+	jobs, _ := Parse([]byte(jobsJSON))
+	launches := 0
+	launch := func(context.Context, Job) (string, int, error) {
+		launches++
+		return "task-3", 5, nil
+	}
+	s := New(jobs, launch)
+	if err := s.LoadState(path); err != nil {
+		t.Fatalf("LoadState: %v", err)
+	}
+	if _, err := s.RunNow(context.Background(), "deps"); err != nil {
+		t.Fatal(err)
+	}
+	s.Tick(context.Background())
+
+	// down over Monday morning; the restarted server knows the run was missed
+	*clock = time.Date(2026, time.March, 9, 15, 0, 0, 0, time.UTC)
+	restarted := New(jobs, launch)
+	restarted.SetCatchUp(CatchUpOnce)
+	if err := restarted.LoadState(path); err != nil {
+		t.Fatalf("LoadState: %v", err)
+	}
+	if last := restarted.Status()[0].LastRun; last == nil || last.TaskID != "task-3" || !last.Manual {
+		t.Fatalf("last run after restart = %+v", last)
+	}
+	restarted.Tick(context.Background())
+	if last := restarted.Status()[0].LastRun; launches != 2 || last == nil || !last.CaughtUp {
+		t.Fatalf("launches = %d, last run = %+v", launches, last)
+	}
+
+	if err := os.WriteFile(path, []byte("{"), 0o600); err != nil {
+		t.Fatal(err)
+	}
+	if err := New(jobs, launch).LoadState(path); err == nil {
+		t.Fatal("LoadState accepted a corrupt file")
+	}
+}
+
+func TestScheduler_RunNow(t *testing.T) {
+	setNow(t, time.Date(2026, time.March, 8, 20, 0, 0, 0, time.UTC))
+	jobs, _ := Parse([]byte(jobsJSON))
+	fail := errors.New("repository not enabled")
+	s := New(jobs, func(_ context.Context, job Job) (string, int, error) {
+		if job.Name == "deps" {
+			return "", 0, fail
+		}
+		return "task-2", 7, nil
+	})
+
+	run, err := s.RunNow(context.Background(), "todos")
+	if err != nil || run.TaskID != "task-2" || run.Issue != 7 || !run.Manual {
+		t.Fatalf("RunNow(todos) = %+v, %v", run, err)
+	}
+	if _, err := s.RunNow(context.Background(), "deps"); err == nil || err.Error() != fail.Error() {
+		t.Fatalf("RunNow(deps) err = %v", err)
+	}
+	if last := s.Status()[0].LastRun; last == nil || last.Error != fail.Error() {
+		t.Fatalf("deps last run = %+v", last)
+	}
+	if _, err := s.RunNow(context.Background(), "nope"); !errors.Is(err, ErrUnknownJob) {
+		t.Fatalf("RunNow(nope) err = %v", err)
+	}
+}
+
+func TestScheduler_ReplaceKeepsUnchangedJobs(t *testing.T) {
+	setNow(t, time.Date(2026, time.March, 8, 20, 0, 0, 0, time.UTC))
+	jobs, _ := Parse([]byte(jobsJSON))
+	s := New(jobs, func(context.Context, Job) (string, int, error) { return "t", 1, nil })
+	if _, err := s.RunNow(context.Background(), "deps"); err != nil {
+		t.Fatal(err)
+	}
+
+	updated := []Job{jobs[0], {Name: "lint", Cron: "@hourly", Repo: "o/r", Prompt: "lint"}}
+	updated[0].Cron = "0 10 * * mon"
+	s.Replace(updated)
+	statuses := s.Status()
+	if len(statuses) != 2 || statuses[1].Name != "lint" {
+		t.Fatalf("statuses = %+v", statuses)
+	}
+	if statuses[0].LastRun == nil {
+		t.Error("a changed job lost its last run")
+	}
+	if next := statuses[0].Next; next == nil || next.Hour() != 10 {
+		t.Errorf("deps next = %v, want the new schedule", next)
+	}
+}
@@ -0,0 +1,70 @@
+package share
+
+import (
+	"regexp"
+	"sort"
+	"strings"
+
+	"github.com/cexll/swe/internal/github"
+)
+
+// Redacted replaces every secret removed from shared content.
+const Redacted = "[REDACTED]"
+
+// minSecretLen skips configured values too short to redact without
+// mangling ordinary text.
+const minSecretLen = 8
+
+// secretPatterns match well-known credential formats; repl keeps any
+// non-secret prefix captured by the pattern.
+var secretPatterns = []struct {
+	re   *regexp.Regexp
+	repl string
+}{
+	{regexp.MustCompile(`-----BEGIN [A-Z ]*PRIVATE KEY-----[\s\S]*?(-----END [A-Z ]*PRIVATE KEY-----|$)`), Redacted},
+	{regexp.MustCompile(`\bsk-ant-[A-Za-z0-9_-]{10,}`), Redacted},
+	{regexp.MustCompile(`\bsk-(proj-)?[A-Za-z0-9_-]{20,}`), Redacted},
+	{regexp.MustCompile(`\b(AKIA|ASIA)[A-Z0-9]{16}\b`), Redacted},
+	{regexp.MustCompile(`\bxox[abpr]-[A-Za-z0-9-]{10,}`), Redacted},
+	{regexp.MustCompile(`(?i)(authorization:\s*(?:bearer|token|basic)\s+)[^\s"']+`), "${1}" + Redacted},
+	{regexp.MustCompile(`(://[^/\s:@]+:)[^@\s/]+@`), "${1}" + Redacted + "@"},
+	{regexp.MustCompile(`(?i)(\b[a-z0-9_]*(?:secret|token|password|passwd|api_key|apikey|private_key)[a-z0-9_]*\s*[=:]\s*)(?:"[^"]*"|'[^']*'|[^\s"',;@]+)`), "${1}" + Redacted},
+}
+
+// Redactor removes secrets from text: configured values (API keys, webhook
+// secrets, ...) and well-known credential formats.
+type Redactor struct {
+	secrets []string
+}
+
+// NewRedactor returns a redactor for the given secret values. Empty and very
+// short values are ignored.
+func NewRedactor(secrets ...string) *Redactor {
+	r := &Redactor{}
+	for _, s := range secrets {
+		s = strings.TrimSpace(s)
+		if len(s) >= minSecretLen {
+			r.secrets = append(r.secrets, s)
+		}
+	}
+	// Longest first so a secret containing another is removed whole.
+	sort.Slice(r.secrets, func(i, j int) bool { return len(r.secrets[i]) > len(r.secrets[j]) })
+	return r
+}
+
+// Redact returns s with all known secrets replaced by Redacted.
+func (r *Redactor) Redact(s string) string {
+	if s == "" {
+		return s
+	}
+	if r != nil {
+		for _, secret := range r.secrets {
+			s = strings.ReplaceAll(s, secret, Redacted)
+		}
+	}
+	s = github.RedactGitHubTokens(s)
+	for _, p := range secretPatterns {
+		s = p.re.ReplaceAllString(s, p.repl)
+	}
+	return s
+}
@@ -0,0 +1,122 @@
+// Package share issues time-limited signed links to a single task's
+// transcript and redacts secrets before shared content is rendered.
+package share
+
+import (
+	"crypto/hmac"
+	"crypto/sha256"
+	"encoding/base64"
+	"errors"
+	"fmt"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// DefaultTTL is the link lifetime used when none is requested.
+const DefaultTTL = 24 * time.Hour
+
+// DefaultMaxTTL caps link lifetimes when no maximum is configured.
+const DefaultMaxTTL = 7 * 24 * time.Hour
+
+var (
+	// ErrInvalidToken is returned for malformed or tampered tokens.
+	ErrInvalidToken = errors.New("invalid share token")
+	// ErrExpired is returned for correctly signed tokens past their expiry.
+	ErrExpired = errors.New("share link expired")
+)
+
+// allow tests to control the clock
+var now = time.Now
+
+// Signer creates and verifies share tokens with an HMAC-SHA256 key.
+// A nil Signer means sharing is disabled.
+type Signer struct {
+	key    []byte
+	maxTTL time.Duration
+}
+
+// NewSigner returns a signer for secret, or nil when secret is empty.
+// maxTTL <= 0 uses DefaultMaxTTL.
+func NewSigner(secret string, maxTTL time.Duration) *Signer {
+	if secret == "" {
+		return nil
+	}
+	if maxTTL <= 0 {
+		maxTTL = DefaultMaxTTL
+	}
+	return &Signer{key: []byte(secret), maxTTL: maxTTL}
+}
+
+// MaxTTL reports the longest lifetime a link may have.
+func (s *Signer) MaxTTL() time.Duration {
+	if s == nil {
+		return 0
+	}
+	return s.maxTTL
+}
+
+// Sign returns a token granting read access to taskID until the returned
+// expiry. ttl <= 0 uses DefaultTTL (capped at the maximum).
+func (s *Signer) Sign(taskID string, ttl time.Duration) (string, time.Time, error) {
+	if s == nil {
+		return "", time.Time{}, errors.New("sharing disabled")
+	}
+	if taskID == "" {
+		return "", time.Time{}, errors.New("task id required")
+	}
+	if ttl <= 0 {
+		ttl = DefaultTTL
+	}
+	if ttl > s.maxTTL {
+		return "", time.Time{}, fmt.Errorf("ttl exceeds maximum of %s", s.maxTTL)
+	}
+
+	expires := now().Add(ttl).Truncate(time.Second)
+	payload := taskID + "|" + strconv.FormatInt(expires.Unix(), 10)
+	token := encode([]byte(payload)) + "." + encode(s.mac(payload))
+	return token, expires, nil
+}
+
+// Verify checks a token's signature and expiry and returns the task it grants.
+func (s *Signer) Verify(token string) (string, time.Time, error) {
+	if s == nil {
+		return "", time.Time{}, ErrInvalidToken
+	}
+	rawPayload, rawSig, ok := strings.Cut(token, ".")
+	if !ok {
+		return "", time.Time{}, ErrInvalidToken
+	}
+	payload, err := decode(rawPayload)
+	if err != nil {
+		return "", time.Time{}, ErrInvalidToken
+	}
+	sig, err := decode(rawSig)
+	if err != nil || !hmac.Equal(sig, s.mac(string(payload))) {
+		return "", time.Time{}, ErrInvalidToken
+	}
+
+	i := strings.LastIndexByte(string(payload), '|')
+	if i <= 0 {
+		return "", time.Time{}, ErrInvalidToken
+	}
+	unix, err := strconv.ParseInt(string(payload[i+1:]), 10, 64)
+	if err != nil {
+		return "", time.Time{}, ErrInvalidToken
+	}
+	expires := time.Unix(unix, 0)
+	if !now().Before(expires) {
+		return "", expires, ErrExpired
+	}
+	return string(payload[:i]), expires, nil
+}
+
+func (s *Signer) mac(payload string) []byte {
+	m := hmac.New(sha256.New, s.key)
+	m.Write([]byte(payload))
+	return m.Sum(nil)
+}
+
+func encode(b []byte) string { return base64.RawURLEncoding.EncodeToString(b) }
+
+func decode(s string) ([]byte, error) { return base64.RawURLEncoding.DecodeString(s) }
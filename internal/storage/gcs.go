@@ -0,0 +1,347 @@
+package storage
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"fmt"
+	"io"
+	"io/fs"
+	"net/http"
+	"net/url"
+	"os"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/golang-jwt/jwt/v5"
+)
+
+// gcsScope is the OAuth2 scope GCS requests are authorized for.
+const gcsScope = "https://www.googleapis.com/auth/devstorage.read_write"
+
+// metadataTokenURL serves the access token of a Google Cloud VM's service
+// account.
+const metadataTokenURL = "http://metadata.google.internal/computeMetadata/v1/instance/service-accounts/default/token"
+
+// GCS stores objects in a Google Cloud Storage bucket through its JSON API.
+type GCS struct {
+	Bucket   string
+	Prefix   string // prepended to every key, e.g. "swe-agent/"
+	Endpoint string // empty uses https://storage.googleapis.com
+	Client   *http.Client
+	// Token returns the OAuth2 access token sent with each request; nil
+	// sends none (emulators).
+	Token func(ctx context.Context) (string, error)
+}
+
+func newGCS(cfg Config) (*GCS, error) {
+	if cfg.Bucket == "" {
+		return nil, fmt.Errorf("storage: gcs needs a bucket")
+	}
+	g := &GCS{Bucket: cfg.Bucket, Prefix: cleanPrefix(cfg.Prefix), Endpoint: strings.TrimRight(cfg.Endpoint, "/")}
+	switch path := os.Getenv("GOOGLE_APPLICATION_CREDENTIALS"); {
+	case path != "":
+		src, err := serviceAccountTokens(path)
+		if err != nil {
+			return nil, err
+		}
+		g.Token = src.token
+	case g.Endpoint == "":
+		g.Token = (&tokenCache{fetch: metadataToken}).token
+	}
+	return g, nil
+}
+
+func (g *GCS) client() *http.Client {
+	if g.Client != nil {
+		return g.Client
+	}
+	return http.DefaultClient
+}
+
+func (g *GCS) base() string {
+	if g.Endpoint != "" {
+		return g.Endpoint
+	}
+	return "https://storage.googleapis.com"
+}
+
+// objectURL returns the JSON API URL of key under the API path (e.g.
+// "/storage/v1" or "/upload/storage/v1").
+func (g *GCS) objectURL(api, key string) string {
+	u := g.base() + api + "/b/" + url.PathEscape(g.Bucket) + "/o"
+	if key != "" {
+		u += "/" + url.PathEscape(g.Prefix+key)
+	}
+	return u
+}
+
+func (g *GCS) do(ctx context.Context, method, rawURL string, body []byte) (*http.Response, error) {
+	req, err := http.NewRequestWithContext(ctx, method, rawURL, bytes.NewReader(body))
+	if err != nil {
+		return nil, err
+	}
+	req.ContentLength = int64(len(body))
+	if g.Token != nil {
+		tok, err := g.Token(ctx)
+		if err != nil {
+			return nil, fmt.Errorf("gcs auth: %w", err)
+		}
+		req.Header.Set("Authorization", "Bearer "+tok)
+	}
+	return g.client().Do(req)
+}
+
+func (g *GCS) Put(ctx context.Context, key string, data []byte) error {
+	if err := ValidKey(key); err != nil {
+		return err
+	}
+	u := g.objectURL("/upload/storage/v1", "") + "?" + url.Values{"uploadType": {"media"}, "name": {g.Prefix + key}}.Encode()
+	resp, err := g.do(ctx, http.MethodPost, u, data)
+	if err != nil {
+		return err
+	}
+	return gcsResult(resp, key)
+}
+
+func (g *GCS) Get(ctx context.Context, key string) (io.ReadCloser, error) {
+	resp, err := g.get(ctx, key)
+	if err != nil {
+		return nil, err
+	}
+	return resp.Body, nil
+}
+
+// get returns the successful response to a download of key.
+func (g *GCS) get(ctx context.Context, key string) (*http.Response, error) {
+	if err := ValidKey(key); err != nil {
+		return nil, err
+	}
+	resp, err := g.do(ctx, http.MethodGet, g.objectURL("/storage/v1", key)+"?alt=media", nil)
+	if err != nil {
+		return nil, err
+	}
+	if resp.StatusCode != http.StatusOK {
+		return nil, gcsResult(resp, key)
+	}
+	return resp, nil
+}
+
+// GetVersion returns the object at key and its generation.
+func (g *GCS) GetVersion(ctx context.Context, key string) ([]byte, string, error) {
+	resp, err := g.get(ctx, key)
+	if err != nil {
+		return nil, "", err
+	}
+	defer func() { _ = resp.Body.Close() }()
+	data, err := io.ReadAll(resp.Body)
+	if err != nil {
+		return nil, "", err
+	}
+	return data, resp.Header.Get("X-Goog-Generation"), nil
+}
+
+// PutIf uploads with ifGenerationMatch, which is 0 to create.
+func (g *GCS) PutIf(ctx context.Context, key string, data []byte, version string) error {
+	if err := ValidKey(key); err != nil {
+		return err
+	}
+	if version == "" {
+		version = "0"
+	}
+	q := url.Values{"uploadType": {"media"}, "name": {g.Prefix + key}, "ifGenerationMatch": {version}}
+	resp, err := g.do(ctx, http.MethodPost, g.objectURL("/upload/storage/v1", "")+"?"+q.Encode(), data)
+	if err != nil {
+		return err
+	}
+	return gcsResult(resp, key)
+}
+
+func (g *GCS) Delete(ctx context.Context, key string) error {
+	if err := ValidKey(key); err != nil {
+		return err
+	}
+	resp, err := g.do(ctx, http.MethodDelete, g.objectURL("/storage/v1", key), nil)
+	if err != nil {
+		return err
+	}
+	if resp.StatusCode == http.StatusNotFound {
+		_ = resp.Body.Close()
+		return nil
+	}
+	return gcsResult(resp, key)
+}
+
+type gcsList struct {
+	Items []struct {
+		Name    string    `json:"name"`
+		Size    string    `json:"size"` // int64 as a string
+		Updated time.Time `json:"updated"`
+	} `json:"items"`
+	NextPageToken string `json:"nextPageToken"`
+}
+
+func (g *GCS) List(ctx context.Context, prefix string) ([]Object, error) {
+	var objects []Object
+	token := ""
+	for {
+		q := url.Values{"prefix": {g.Prefix + prefix}, "fields": {"items(name,size,updated),nextPageToken"}}
+		if token != "" {
+			q.Set("pageToken", token)
+		}
+		resp, err := g.do(ctx, http.MethodGet, g.objectURL("/storage/v1", "")+"?"+q.Encode(), nil)
+		if err != nil {
+			return nil, err
+		}
+		if resp.StatusCode != http.StatusOK {
+			return nil, gcsResult(resp, prefix)
+		}
+		var page gcsList
+		err = json.NewDecoder(resp.Body).Decode(&page)
+		_ = resp.Body.Close()
+		if err != nil {
+			return nil, fmt.Errorf("gcs list: %w", err)
+		}
+		for _, it := range page.Items {
+			size, _ := strconv.ParseInt(it.Size, 10, 64)
+			objects = append(objects, Object{Key: strings.TrimPrefix(it.Name, g.Prefix), Size: size, Modified: it.Updated})
+		}
+		if page.NextPageToken == "" {
+			return objects, nil
+		}
+		token = page.NextPageToken
+	}
+}
+
+// gcsResult closes resp and turns an error status into an error.
+func gcsResult(resp *http.Response, key string) error {
+	defer func() { _ = resp.Body.Close() }()
+	if resp.StatusCode >= 200 && resp.StatusCode < 300 {
+		return nil
+	}
+	if resp.StatusCode == http.StatusNotFound {
+		return fmt.Errorf("gcs %s: %w", key, fs.ErrNotExist)
+	}
+	if resp.StatusCode == http.StatusPreconditionFailed {
+		return fmt.Errorf("gcs %s: %w", key, ErrPrecondition)
+	}
+	msg, _ := io.ReadAll(io.LimitReader(resp.Body, 512))
+	return fmt.Errorf("gcs %s: %s: %s", key, resp.Status, strings.TrimSpace(string(msg)))
+}
+
+// tokenCache reuses an access token until shortly before it expires.
+type tokenCache struct {
+	fetch func(ctx context.Context) (string, time.Duration, error)
+
+	mu      sync.Mutex
+	tok     string
+	expires time.Time
+}
+
+func (c *tokenCache) token(ctx context.Context) (string, error) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	if c.tok != "" && time.Until(c.expires) > time.Minute {
+		return c.tok, nil
+	}
+	tok, ttl, err := c.fetch(ctx)
+	if err != nil {
+		return "", err
+	}
+	c.tok, c.expires = tok, time.Now().Add(ttl)
+	return tok, nil
+}
+
+// tokenResponse is an OAuth2 token endpoint reply.
+type tokenResponse struct {
+	AccessToken string `json:"access_token"`
+	ExpiresIn   int    `json:"expires_in"`
+}
+
+func readToken(resp *http.Response) (string, time.Duration, error) {
+	defer func() { _ = resp.Body.Close() }()
+	if resp.StatusCode != http.StatusOK {
+		msg, _ := io.ReadAll(io.LimitReader(resp.Body, 512))
+		return "", 0, fmt.Errorf("token request: %s: %s", resp.Status, strings.TrimSpace(string(msg)))
+	}
+	var t tokenResponse
+	if err := json.NewDecoder(resp.Body).Decode(&t); err != nil {
+		return "", 0, fmt.Errorf("token response: %w", err)
+	}
+	if t.AccessToken == "" {
+		return "", 0, fmt.Errorf("token response has no access_token")
+	}
+	return t.AccessToken, time.Duration(t.ExpiresIn) * time.Second, nil
+}
+
+func metadataToken(ctx context.Context) (string, time.Duration, error) {
+	req, err := http.NewRequestWithContext(ctx, http.MethodGet, metadataTokenURL, nil)
+	if err != nil {
+		return "", 0, err
+	}
+	req.Header.Set("Metadata-Flavor", "Google")
+	resp, err := http.DefaultClient.Do(req)
+	if err != nil {
+		return "", 0, fmt.Errorf("metadata server (set GOOGLE_APPLICATION_CREDENTIALS outside Google Cloud): %w", err)
+	}
+	return readToken(resp)
+}
+
+// serviceAccountKey is the part of a service account JSON key used to
+// obtain access tokens.
+type serviceAccountKey struct {
+	Type        string `json:"type"`
+	ClientEmail string `json:"client_email"`
+	PrivateKey  string `json:"private_key"`
+	TokenURI    string `json:"token_uri"`
+}
+
+// serviceAccountTokens returns a token source exchanging JWTs signed with
+// the service account key at path for access tokens.
+func serviceAccountTokens(path string) (*tokenCache, error) {
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return nil, fmt.Errorf("storage: GOOGLE_APPLICATION_CREDENTIALS: %w", err)
+	}
+	var key serviceAccountKey
+	if err := json.Unmarshal(data, &key); err != nil {
+		return nil, fmt.Errorf("storage: GOOGLE_APPLICATION_CREDENTIALS: %w", err)
+	}
+	if key.Type != "service_account" || key.ClientEmail == "" {
+		return nil, fmt.Errorf("storage: GOOGLE_APPLICATION_CREDENTIALS: not a service account key")
+	}
+	signer, err := jwt.ParseRSAPrivateKeyFromPEM([]byte(key.PrivateKey))
+	if err != nil {
+		return nil, fmt.Errorf("storage: GOOGLE_APPLICATION_CREDENTIALS: %w", err)
+	}
+	if key.TokenURI == "" {
+		key.TokenURI = "https://oauth2.googleapis.com/token"
+	}
+	fetch := func(ctx context.Context) (string, time.Duration, error) {
+		now := time.Now()
+		assertion, err := jwt.NewWithClaims(jwt.SigningMethodRS256, jwt.MapClaims{
+			"iss":   key.ClientEmail,
+			"scope": gcsScope,
+			"aud":   key.TokenURI,
+			"iat":   now.Unix(),
+			"exp":   now.Add(time.Hour).Unix(),
+		}).SignedString(signer)
+		if err != nil {
+			return "", 0, err
+		}
+		form := url.Values{"grant_type": {"urn:ietf:params:oauth:grant-type:jwt-bearer"}, "assertion": {assertion}}
+		req, err := http.NewRequestWithContext(ctx, http.MethodPost, key.TokenURI, strings.NewReader(form.Encode()))
+		if err != nil {
+			return "", 0, err
+		}
+		req.Header.Set("Content-Type", "application/x-www-form-urlencoded")
+		resp, err := http.DefaultClient.Do(req)
+		if err != nil {
+			return "", 0, err
+		}
+		return readToken(resp)
+	}
+	return &tokenCache{fetch: fetch}, nil
+}
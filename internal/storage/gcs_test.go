@@ -0,0 +1,154 @@
+package storage
+
+import (
+	"crypto/rand"
+	"crypto/rsa"
+	"crypto/x509"
+	"encoding/json"
+	"encoding/pem"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"sort"
+	"strconv"
+	"strings"
+	"sync"
+	"testing"
+
+	"github.com/golang-jwt/jwt/v5"
+)
+
+// fakeGCS serves the parts of the JSON API GCS uses, requiring token.
+type fakeGCS struct {
+	token string
+
+	mu          sync.Mutex
+	objects     map[string][]byte
+	generations map[string]int
+	writes      int
+}
+
+func (f *fakeGCS) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	if r.Header.Get("Authorization") != "Bearer "+f.token {
+		http.Error(w, "unauthorized", http.StatusUnauthorized)
+		return
+	}
+	path := r.URL.EscapedPath()
+	switch {
+	case r.Method == http.MethodPost && path == "/upload/storage/v1/b/bucket/o":
+		name := r.URL.Query().Get("name")
+		if match := r.URL.Query().Get("ifGenerationMatch"); match != "" && match != strconv.Itoa(f.generations[name]) {
+			http.Error(w, "conditionNotMet", http.StatusPreconditionFailed)
+			return
+		}
+		data, _ := io.ReadAll(r.Body)
+		f.writes++
+		f.objects[name] = data
+		f.generations[name] = f.writes
+		_, _ = w.Write([]byte(`{}`))
+	case r.Method == http.MethodGet && path == "/storage/v1/b/bucket/o":
+		var res struct {
+			Items []map[string]string `json:"items"`
+		}
+		var names []string
+		for name := range f.objects {
+			if strings.HasPrefix(name, r.URL.Query().Get("prefix")) {
+				names = append(names, name)
+			}
+		}
+		sort.Strings(names)
+		for _, name := range names {
+			res.Items = append(res.Items, map[string]string{"name": name, "size": strconv.Itoa(len(f.objects[name])), "updated": "2026-10-16T09:00:00.000Z"})
+		}
+		_ = json.NewEncoder(w).Encode(res)
+	case strings.HasPrefix(path, "/storage/v1/b/bucket/o/"):
+		// object names arrive escaped as one path segment
+		if strings.Contains(strings.TrimPrefix(path, "/storage/v1/b/bucket/o/"), "/") {
+			http.Error(w, "unescaped object name", http.StatusBadRequest)
+			return
+		}
+		name := strings.TrimPrefix(r.URL.Path, "/storage/v1/b/bucket/o/")
+		data, ok := f.objects[name]
+		if !ok {
+			http.Error(w, "not found", http.StatusNotFound)
+			return
+		}
+		if r.Method == http.MethodDelete {
+			delete(f.objects, name)
+			delete(f.generations, name)
+			w.WriteHeader(http.StatusNoContent)
+			return
+		}
+		w.Header().Set("X-Goog-Generation", strconv.Itoa(f.generations[name]))
+		_, _ = w.Write(data)
+	default:
+		http.Error(w, "unexpected "+r.Method+" "+path, http.StatusBadRequest)
+	}
+}
+
+func TestGCS_ServiceAccountRoundTrip(t *testing.T) {
+	key, err := rsa.GenerateKey(rand.Reader, 2048)
+	if err != nil {
+		t.Fatal(err)
+	}
+	var exchanges int
+	tokenSrv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		exchanges++
+		if r.FormValue("grant_type") != "urn:ietf:params:oauth:grant-type:jwt-bearer" {
+			http.Error(w, "bad grant", http.StatusBadRequest)
+			return
+		}
+		claims := jwt.MapClaims{}
+		if _, err := jwt.ParseWithClaims(r.FormValue("assertion"), claims, func(*jwt.Token) (any, error) { return &key.PublicKey, nil }); err != nil {
+			http.Error(w, err.Error(), http.StatusBadRequest)
+			return
+		}
+		if claims["iss"] != "swe@project.iam.gserviceaccount.com" || claims["scope"] != gcsScope {
+			http.Error(w, "bad claims", http.StatusBadRequest)
+			return
+		}
+		_, _ = w.Write([]byte(`{"access_token":"ya29.test","expires_in":3600}`))
+	}))
+	defer tokenSrv.Close()
+
+	pemKey := pem.EncodeToMemory(&pem.Block{Type: "PRIVATE KEY", Bytes: mustPKCS8(t, key)})
+	creds, _ := json.Marshal(map[string]string{
+		"type":         "service_account",
+		"client_email": "swe@project.iam.gserviceaccount.com",
+		"private_key":  string(pemKey),
+		"token_uri":    tokenSrv.URL,
+	})
+	credsFile := filepath.Join(t.TempDir(), "key.json")
+	if err := os.WriteFile(credsFile, creds, 0o600); err != nil {
+		t.Fatal(err)
+	}
+	t.Setenv("GOOGLE_APPLICATION_CREDENTIALS", credsFile)
+
+	fake := &fakeGCS{token: "ya29.test", objects: map[string][]byte{}, generations: map[string]int{}}
+	srv := httptest.NewServer(fake)
+	defer srv.Close()
+	b, err := Open("gs://bucket/swe?endpoint=" + srv.URL)
+	if err != nil {
+		t.Fatal(err)
+	}
+	testBackend(t, b)
+	if _, ok := fake.objects["swe/task-1/b.txt"]; !ok {
+		t.Fatalf("objects not stored under the prefix: %v", fake.objects)
+	}
+	if exchanges != 1 {
+		t.Fatalf("token exchanged %d times, want it cached", exchanges)
+	}
+}
+
+func mustPKCS8(t *testing.T, key *rsa.PrivateKey) []byte {
+	t.Helper()
+	der, err := x509.MarshalPKCS8PrivateKey(key)
+	if err != nil {
+		t.Fatal(err)
+	}
+	return der
+}
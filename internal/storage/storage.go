@@ -0,0 +1,183 @@
+// Package storage keeps blobs — task artifacts, offloaded logs — on local
+// disk, in an S3-compatible bucket or in Google Cloud Storage, behind one
+// Backend interface.
+package storage
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"io"
+	"net/url"
+	"strings"
+	"time"
+)
+
+// Backend stores objects by slash-separated key. Get and GetVersion of a
+// missing key return an error matching fs.ErrNotExist.
+//
+// GetVersion and PutIf let replicas sharing a backend update an object
+// without losing each other's writes: PutIf writes only over the version
+// read, so of two replicas racing to replace or create an object exactly
+// one succeeds.
+type Backend interface {
+	Put(ctx context.Context, key string, data []byte) error
+	Get(ctx context.Context, key string) (io.ReadCloser, error)
+	Delete(ctx context.Context, key string) error
+	List(ctx context.Context, prefix string) ([]Object, error)
+	// GetVersion returns the object at key and its current version.
+	GetVersion(ctx context.Context, key string) ([]byte, string, error)
+	// PutIf writes data at key if the object there is still at version,
+	// or, when version is "", if there is none. Otherwise it writes
+	// nothing and returns an error matching ErrPrecondition.
+	PutIf(ctx context.Context, key string, data []byte, version string) error
+}
+
+// ErrPrecondition is returned by PutIf when the object is not at the
+// version the write was made for.
+var ErrPrecondition = errors.New("storage: object changed")
+
+// Object is a stored object as listed by a Backend.
+type Object struct {
+	Key      string
+	Size     int64
+	Modified time.Time
+}
+
+// Backend kinds accepted by Config.Backend.
+const (
+	KindLocal = "local"
+	KindS3    = "s3"
+	KindGCS   = "gcs"
+)
+
+// Config describes a backend. Credentials are not part of it: S3 reads
+// AWS_ACCESS_KEY_ID, AWS_SECRET_ACCESS_KEY and AWS_SESSION_TOKEN; GCS reads
+// the service account key in GOOGLE_APPLICATION_CREDENTIALS or, without
+// one, asks the metadata server of the VM it runs on.
+type Config struct {
+	Backend string // local, s3 or gcs
+	Dir     string // local: the root directory
+	Bucket  string // s3, gcs
+	Prefix  string // s3, gcs: prepended to every key, e.g. "swe-agent/"
+	// Endpoint is the service URL of S3-compatible stores (MinIO, R2) or of
+	// a GCS emulator; empty uses AWS or Google.
+	Endpoint  string
+	Region    string // s3: defaults to AWS_REGION, then us-east-1
+	PathStyle bool   // s3: address the bucket in the path (implied by Endpoint)
+}
+
+// New returns the backend described by cfg.
+func New(cfg Config) (Backend, error) {
+	if cfg.Endpoint != "" {
+		if e, err := url.Parse(cfg.Endpoint); err != nil || e.Host == "" {
+			return nil, fmt.Errorf("storage: invalid endpoint %q", cfg.Endpoint)
+		}
+	}
+	switch cfg.Backend {
+	case KindLocal:
+		if cfg.Dir == "" {
+			return nil, fmt.Errorf("storage: local backend needs a directory")
+		}
+		return &Local{Dir: cfg.Dir}, nil
+	case KindS3:
+		return newS3(cfg)
+	case KindGCS:
+		return newGCS(cfg)
+	default:
+		return nil, fmt.Errorf("storage: unknown backend %q (use local, s3 or gcs)", cfg.Backend)
+	}
+}
+
+// Open returns the backend described by spec: a directory (or file:// URL),
+// s3://bucket/prefix with optional endpoint, region and path_style query
+// parameters, or gs://bucket/prefix with an optional endpoint.
+func Open(spec string) (Backend, error) {
+	if !strings.Contains(spec, "://") {
+		return New(Config{Backend: KindLocal, Dir: spec})
+	}
+	u, err := url.Parse(spec)
+	if err != nil {
+		return nil, fmt.Errorf("storage: %w", err)
+	}
+	q := u.Query()
+	cfg := Config{
+		Bucket:    u.Host,
+		Prefix:    u.Path,
+		Endpoint:  q.Get("endpoint"),
+		Region:    q.Get("region"),
+		PathStyle: q.Get("path_style") == "true",
+	}
+	switch u.Scheme {
+	case "file":
+		cfg = Config{Backend: KindLocal, Dir: u.Path}
+	case "s3":
+		cfg.Backend = KindS3
+	case "gs":
+		cfg.Backend = KindGCS
+	default:
+		return nil, fmt.Errorf("storage: unsupported scheme %q (use a directory, s3:// or gs://)", u.Scheme)
+	}
+	return New(cfg)
+}
+
+// ValidKey rejects keys that could escape the storage root.
+func ValidKey(key string) error {
+	if key == "" || strings.HasPrefix(key, "/") {
+		return fmt.Errorf("invalid storage key %q", key)
+	}
+	for _, part := range strings.Split(key, "/") {
+		if part == "" || part == "." || part == ".." {
+			return fmt.Errorf("invalid storage key %q", key)
+		}
+	}
+	return nil
+}
+
+// cleanPrefix turns a configured prefix into "a/b/" form ("" stays empty).
+func cleanPrefix(prefix string) string {
+	prefix = strings.Trim(prefix, "/")
+	if prefix == "" {
+		return ""
+	}
+	return prefix + "/"
+}
+
+// WithPrefix returns a view of b whose keys all live under prefix, so
+// several features can share one bucket.
+func WithPrefix(b Backend, prefix string) Backend {
+	return &prefixed{b: b, prefix: cleanPrefix(prefix)}
+}
+
+type prefixed struct {
+	b      Backend
+	prefix string
+}
+
+func (p *prefixed) Put(ctx context.Context, key string, data []byte) error {
+	return p.b.Put(ctx, p.prefix+key, data)
+}
+
+func (p *prefixed) Get(ctx context.Context, key string) (io.ReadCloser, error) {
+	return p.b.Get(ctx, p.prefix+key)
+}
+
+func (p *prefixed) Delete(ctx context.Context, key string) error {
+	return p.b.Delete(ctx, p.prefix+key)
+}
+
+func (p *prefixed) GetVersion(ctx context.Context, key string) ([]byte, string, error) {
+	return p.b.GetVersion(ctx, p.prefix+key)
+}
+
+func (p *prefixed) PutIf(ctx context.Context, key string, data []byte, version string) error {
+	return p.b.PutIf(ctx, p.prefix+key, data, version)
+}
+
+func (p *prefixed) List(ctx context.Context, prefix string) ([]Object, error) {
+	objects, err := p.b.List(ctx, p.prefix+prefix)
+	for i := range objects {
+		objects[i].Key = strings.TrimPrefix(objects[i].Key, p.prefix)
+	}
+	return objects, err
+}
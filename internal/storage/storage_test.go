@@ -0,0 +1,188 @@
+package storage
+
+import (
+	"context"
+	"errors"
+	"io"
+	"io/fs"
+	"os"
+	"path/filepath"
+	"sort"
+	"sync"
+	"sync/atomic"
+	"testing"
+)
+
+// testBackend runs the operations every Backend supports against b, which
+// must start empty.
+func testBackend(t *testing.T, b Backend) {
+	t.Helper()
+	ctx := context.Background()
+	for key, data := range map[string]string{"task-1/a.txt": "alpha", "task-1/b.txt": "beta", "task-2/a.txt": "other"} {
+		if err := b.Put(ctx, key, []byte(data)); err != nil {
+			t.Fatalf("Put(%s): %v", key, err)
+		}
+	}
+	objects, err := b.List(ctx, "task-1/")
+	if err != nil {
+		t.Fatalf("List: %v", err)
+	}
+	sort.Slice(objects, func(i, j int) bool { return objects[i].Key < objects[j].Key })
+	if len(objects) != 2 || objects[0].Key != "task-1/a.txt" || objects[0].Size != 5 || objects[0].Modified.IsZero() {
+		t.Fatalf("List = %+v", objects)
+	}
+	rc, err := b.Get(ctx, "task-1/b.txt")
+	if err != nil {
+		t.Fatalf("Get: %v", err)
+	}
+	data, _ := io.ReadAll(rc)
+	_ = rc.Close()
+	if string(data) != "beta" {
+		t.Fatalf("Get = %q", data)
+	}
+	if _, err := b.Get(ctx, "task-1/missing.txt"); !errors.Is(err, fs.ErrNotExist) {
+		t.Fatalf("Get missing = %v", err)
+	}
+	if err := b.Delete(ctx, "task-1/a.txt"); err != nil {
+		t.Fatalf("Delete: %v", err)
+	}
+	if err := b.Delete(ctx, "task-1/a.txt"); err != nil {
+		t.Fatalf("Delete again: %v", err)
+	}
+	if objects, _ := b.List(ctx, "task-1/"); len(objects) != 1 {
+		t.Fatalf("List after Delete = %+v", objects)
+	}
+	for _, key := range []string{"", "/abs", "a/../b", "a//b"} {
+		if err := b.Put(ctx, key, nil); err == nil {
+			t.Errorf("Put(%q) accepted", key)
+		}
+	}
+
+	// of two writers racing on the same version, one wins
+	if err := b.PutIf(ctx, "lease.json", []byte("a"), ""); err != nil {
+		t.Fatalf("PutIf create: %v", err)
+	}
+	if err := b.PutIf(ctx, "lease.json", []byte("b"), ""); !errors.Is(err, ErrPrecondition) {
+		t.Fatalf("PutIf create over an object = %v", err)
+	}
+	data, version, err := b.GetVersion(ctx, "lease.json")
+	if err != nil || string(data) != "a" || version == "" {
+		t.Fatalf("GetVersion = %q, %q, %v", data, version, err)
+	}
+	if err := b.PutIf(ctx, "lease.json", []byte("b"), version); err != nil {
+		t.Fatalf("PutIf update: %v", err)
+	}
+	if err := b.PutIf(ctx, "lease.json", []byte("c"), version); !errors.Is(err, ErrPrecondition) {
+		t.Fatalf("PutIf over a stale version = %v", err)
+	}
+	if data, _, _ := b.GetVersion(ctx, "lease.json"); string(data) != "b" {
+		t.Fatalf("after the race: %q", data)
+	}
+	if _, _, err := b.GetVersion(ctx, "missing.json"); !errors.Is(err, fs.ErrNotExist) {
+		t.Fatalf("GetVersion missing = %v", err)
+	}
+	if err := b.Delete(ctx, "lease.json"); err != nil {
+		t.Fatalf("Delete: %v", err)
+	}
+}
+
+func TestLocal(t *testing.T) {
+	dir := t.TempDir()
+	testBackend(t, &Local{Dir: dir})
+	if err := (&Local{Dir: dir}).Delete(context.Background(), "task-2/a.txt"); err != nil {
+		t.Fatal(err)
+	}
+	if _, err := os.Stat(filepath.Join(dir, "task-2")); !errors.Is(err, fs.ErrNotExist) {
+		t.Fatalf("empty directory kept: %v", err)
+	}
+}
+
+func TestLocal_PutIfRace(t *testing.T) {
+	ctx := context.Background()
+	dir := t.TempDir()
+	var wins atomic.Int32
+	var wg sync.WaitGroup
+	for i := 0; i < 8; i++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			// a backend per writer, as replicas sharing a directory have
+			err := (&Local{Dir: dir}).PutIf(ctx, "leader/lease.json", []byte{byte(i)}, "")
+			if err == nil {
+				wins.Add(1)
+			} else if !errors.Is(err, ErrPrecondition) {
+				t.Errorf("PutIf: %v", err)
+			}
+		}()
+	}
+	wg.Wait()
+	if wins.Load() != 1 {
+		t.Fatalf("%d writers created the object", wins.Load())
+	}
+	if objects, _ := (&Local{Dir: dir}).List(ctx, ""); len(objects) != 1 {
+		t.Fatalf("List = %+v", objects)
+	}
+}
+
+func TestWithPrefix(t *testing.T) {
+	local := &Local{Dir: t.TempDir()}
+	testBackend(t, WithPrefix(local, "/logs/"))
+	objects, _ := local.List(context.Background(), "")
+	for _, o := range objects {
+		if filepath.Dir(filepath.Dir(o.Key)) != "logs" {
+			t.Errorf("object %s outside the prefix", o.Key)
+		}
+	}
+}
+
+func TestOpen(t *testing.T) {
+	t.Setenv("AWS_ACCESS_KEY_ID", "AK")
+	t.Setenv("AWS_SECRET_ACCESS_KEY", "SK")
+	t.Setenv("AWS_REGION", "")
+	t.Setenv("GOOGLE_APPLICATION_CREDENTIALS", "")
+	b, err := Open("s3://bucket/swe/artifacts?endpoint=http://minio:9000&region=eu-west-1")
+	if err != nil {
+		t.Fatal(err)
+	}
+	s3 := b.(*S3)
+	if s3.Bucket != "bucket" || s3.Prefix != "swe/artifacts/" || s3.Region != "eu-west-1" || s3.Endpoint != "http://minio:9000" {
+		t.Fatalf("S3 = %+v", s3)
+	}
+	if got := s3.objectURL("t/diff.patch").String(); got != "http://minio:9000/bucket/swe/artifacts/t/diff.patch" {
+		t.Fatalf("objectURL = %s", got)
+	}
+	b, _ = Open("s3://bucket")
+	if got := b.(*S3).objectURL("t/x").String(); got != "https://bucket.s3.us-east-1.amazonaws.com/t/x" {
+		t.Fatalf("objectURL = %s", got)
+	}
+	if b, _ := Open("/data/artifacts"); b.(*Local).Dir != "/data/artifacts" {
+		t.Fatalf("directory spec = %+v", b)
+	}
+	b, err = Open("gs://bucket/swe?endpoint=http://gcs:4443")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if g := b.(*GCS); g.Bucket != "bucket" || g.Prefix != "swe/" || g.Token != nil {
+		t.Fatalf("GCS = %+v", g)
+	}
+	if b, _ := Open("gs://bucket"); b.(*GCS).Token == nil {
+		t.Fatal("GCS without an endpoint must authenticate")
+	}
+
+	for _, spec := range []string{"ftp://bucket", "s3:///prefix", "gs://", "s3://bucket?endpoint=::"} {
+		if _, err := Open(spec); err == nil {
+			t.Errorf("Open(%q) accepted", spec)
+		}
+	}
+	if _, err := New(Config{Backend: KindLocal}); err == nil {
+		t.Error("local backend without a directory accepted")
+	}
+	t.Setenv("AWS_SECRET_ACCESS_KEY", "")
+	if _, err := Open("s3://bucket"); err == nil {
+		t.Error("s3 without credentials accepted")
+	}
+	t.Setenv("GOOGLE_APPLICATION_CREDENTIALS", filepath.Join(t.TempDir(), "missing.json"))
+	if _, err := Open("gs://bucket"); err == nil {
+		t.Error("gcs with a missing key file accepted")
+	}
+}
@@ -0,0 +1,105 @@
+package taskstore
+
+import "sort"
+
+// ChainTask is one task of a dependency chain with its current state.
+type ChainTask struct {
+	ID        string     `json:"id"`
+	Title     string     `json:"title"`
+	Status    TaskStatus `json:"status"`
+	DependsOn []string   `json:"depends_on,omitempty"`
+}
+
+// Chain returns the tasks linked to id through dependencies, in either
+// direction, with every task after the tasks it depends on; nil when id
+// neither depends on a task nor has dependents.
+func (s *Store) Chain(id string) []ChainTask {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+	if _, ok := s.tasks[id]; !ok {
+		return nil
+	}
+	dependents := make(map[string][]string)
+	for _, t := range s.tasks {
+		for _, dep := range t.DependsOn {
+			dependents[dep] = append(dependents[dep], t.ID)
+		}
+	}
+
+	linked := map[string]bool{id: true}
+	for queue := []string{id}; len(queue) > 0; queue = queue[1:] {
+		next := dependents[queue[0]]
+		if t, ok := s.tasks[queue[0]]; ok {
+			next = append(next, t.DependsOn...)
+		}
+		for _, n := range next {
+			if _, ok := s.tasks[n]; ok && !linked[n] {
+				linked[n] = true
+				queue = append(queue, n)
+			}
+		}
+	}
+	if len(linked) == 1 {
+		return nil
+	}
+
+	tasks := make([]*Task, 0, len(linked))
+	for n := range linked {
+		tasks = append(tasks, s.tasks[n])
+	}
+	sort.Slice(tasks, func(i, j int) bool {
+		if !tasks[i].CreatedAt.Equal(tasks[j].CreatedAt) {
+			return tasks[i].CreatedAt.Before(tasks[j].CreatedAt)
+		}
+		return tasks[i].ID < tasks[j].ID
+	})
+	// a task can only depend on tasks that existed before it, so creation
+	// order is nearly topological; place each task after its prerequisites
+	chain := make([]ChainTask, 0, len(tasks))
+	placed := make(map[string]bool, len(tasks))
+	for len(chain) < len(tasks) {
+		progress := false
+		for _, t := range tasks {
+			if placed[t.ID] || !prerequisitesPlaced(t, linked, placed) {
+				continue
+			}
+			placed[t.ID] = true
+			progress = true
+			chain = append(chain, ChainTask{ID: t.ID, Title: t.Title, Status: t.Status, DependsOn: append([]string(nil), t.DependsOn...)})
+		}
+		if !progress {
+			break
+		}
+	}
+	return chain
+}
+
+// prerequisitesPlaced reports whether the prerequisites of t within the
+// chain are placed.
+func prerequisitesPlaced(t *Task, linked, placed map[string]bool) bool {
+	for _, dep := range t.DependsOn {
+		if linked[dep] && !placed[dep] {
+			return false
+		}
+	}
+	return true
+}
+
+// prerequisitesLocked returns the tasks id depends on, directly or through
+// other tasks.
+func (s *Store) prerequisitesLocked(id string) map[string]bool {
+	seen := make(map[string]bool)
+	for queue := []string{id}; len(queue) > 0; queue = queue[1:] {
+		t, ok := s.tasks[queue[0]]
+		if !ok {
+			continue
+		}
+		for _, dep := range t.DependsOn {
+			if !seen[dep] {
+				seen[dep] = true
+				queue = append(queue, dep)
+			}
+		}
+	}
+	return seen
+}
@@ -0,0 +1,54 @@
+package taskstore
+
+import (
+	"testing"
+	"time"
+)
+
+func TestChain(t *testing.T) {
+	s := NewStore()
+	s.Create(&Task{ID: "plan", Title: "Plan", Status: StatusCompleted})
+	s.Create(&Task{ID: "implement", Title: "Implement", Status: StatusRunning, DependsOn: []string{"plan"}})
+	s.Create(&Task{ID: "docs", Title: "Docs", Status: StatusPending, DependsOn: []string{"plan"}})
+	s.Create(&Task{ID: "test", Title: "Test", Status: StatusPending, DependsOn: []string{"implement", "docs"}})
+	s.Create(&Task{ID: "other", Status: StatusPending})
+	// even when created before a prerequisite, a task comes after it
+	plan, _ := s.Get("plan")
+	test, _ := s.Get("test")
+	test.CreatedAt = plan.CreatedAt.Add(-time.Second)
+
+	for _, id := range []string{"plan", "test"} {
+		chain := s.Chain(id)
+		var got []string
+		for _, c := range chain {
+			got = append(got, c.ID)
+		}
+		if len(got) != 4 || got[0] != "plan" || got[3] != "test" {
+			t.Fatalf("Chain(%s) = %v", id, got)
+		}
+		if chain[3].Status != StatusPending || len(chain[3].DependsOn) != 2 {
+			t.Fatalf("Chain(%s)[3] = %+v", id, chain[3])
+		}
+	}
+	if chain := s.Chain("other"); chain != nil {
+		t.Fatalf("unchained task has a chain: %+v", chain)
+	}
+	if status, ok := s.Status("implement"); !ok || status != StatusRunning {
+		t.Fatalf("Status = %q, %v", status, ok)
+	}
+}
+
+func TestSupersedeOlder_KeepsPrerequisites(t *testing.T) {
+	s := NewStore()
+	s.Create(&Task{ID: "plan", Status: StatusPending, RepoOwner: "o", RepoName: "r", IssueNumber: 1})
+	s.Create(&Task{ID: "implement", Status: StatusPending, RepoOwner: "o", RepoName: "r", IssueNumber: 1, DependsOn: []string{"plan"}})
+	s.Create(&Task{ID: "stale", Status: StatusPending, RepoOwner: "o", RepoName: "r", IssueNumber: 1})
+	s.Create(&Task{ID: "test", Status: StatusPending, RepoOwner: "o", RepoName: "r", IssueNumber: 1, DependsOn: []string{"implement"}})
+
+	if n := s.SupersedeOlder("o", "r", 1, "test"); n != 1 {
+		t.Fatalf("superseded %d tasks, want only the one outside the chain", n)
+	}
+	if status, _ := s.Status("plan"); status != StatusPending {
+		t.Fatalf("indirect prerequisite superseded: %s", status)
+	}
+}
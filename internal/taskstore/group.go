@@ -0,0 +1,169 @@
+package taskstore
+
+import (
+	"sort"
+	"time"
+)
+
+// Group is a fan-out: one prompt launched across several repositories as
+// one child task per repository.
+type Group struct {
+	ID     string `json:"id"`
+	Title  string `json:"title"`
+	Prompt string `json:"prompt"`
+	Actor  string `json:"actor"`
+	// Origin is the issue ("owner/repo#123") that gets the combined summary;
+	// empty for none
+	Origin    string       `json:"origin,omitempty"`
+	CreatedAt time.Time    `json:"created_at"`
+	Children  []GroupChild `json:"-"`
+	// Sealed is set once every child has been launched
+	Sealed bool `json:"sealed"`
+	// SummaryCommentID is the combined summary comment on Origin, once posted
+	SummaryCommentID int64 `json:"summary_comment_id,omitempty"`
+}
+
+// GroupChild is one repository of a group: the issue opened there and the
+// task working on it, or why it could not be launched.
+type GroupChild struct {
+	Repo   string `json:"repo"`
+	Issue  int    `json:"issue,omitempty"`
+	TaskID string `json:"task_id,omitempty"`
+	Error  string `json:"error,omitempty"`
+}
+
+// GroupChildStatus is a child with its task's current state.
+type GroupChildStatus struct {
+	GroupChild
+	Status TaskStatus `json:"status"` // StatusFailed for children that were never launched
+	// Detail is the last error logged by a failed task
+	Detail string `json:"detail,omitempty"`
+}
+
+// GroupStatus is a group with the aggregated state of its children.
+type GroupStatus struct {
+	*Group
+	Children []GroupChildStatus `json:"children"`
+	Counts   map[TaskStatus]int `json:"counts"`
+	// Done is set when the group is sealed and every child has finished
+	Done bool `json:"done"`
+}
+
+// CreateGroup records a new group.
+func (s *Store) CreateGroup(g *Group) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	if s.groups == nil {
+		s.groups = make(map[string]*Group)
+	}
+	g.CreatedAt = time.Now()
+	s.groups[g.ID] = g
+}
+
+// AddGroupChild adds a launched (or failed) child to a group and tags its
+// task with the group.
+func (s *Store) AddGroupChild(groupID string, child GroupChild) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	g, ok := s.groups[groupID]
+	if !ok {
+		return
+	}
+	g.Children = append(g.Children, child)
+	if t, ok := s.tasks[child.TaskID]; ok && child.TaskID != "" {
+		t.Group = groupID
+	}
+}
+
+// SealGroup marks every child of the group launched. The done hook runs at
+// once if none is left running, as when every launch failed.
+func (s *Store) SealGroup(groupID string) {
+	s.mu.Lock()
+	g, ok := s.groups[groupID]
+	if ok {
+		g.Sealed = true
+	}
+	done := ok && s.groupDoneLocked(g)
+	hook := s.onGroupDone
+	s.mu.Unlock()
+	if done && hook != nil {
+		hook(groupID)
+	}
+}
+
+// SetGroupSummaryComment records the combined summary comment of a group.
+func (s *Store) SetGroupSummaryComment(groupID string, commentID int64) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	if g, ok := s.groups[groupID]; ok {
+		g.SummaryCommentID = commentID
+	}
+}
+
+// SetGroupDoneHook sets the function called with a group's ID whenever a
+// child finishing leaves no child of the sealed group pending or running.
+// A child retried after a failure can finish it again, so the hook may run
+// more than once per group.
+func (s *Store) SetGroupDoneHook(fn func(groupID string)) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.onGroupDone = fn
+}
+
+// Group returns a group with the state of its children.
+func (s *Store) Group(id string) (GroupStatus, bool) {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+	g, ok := s.groups[id]
+	if !ok {
+		return GroupStatus{}, false
+	}
+	return s.groupStatusLocked(g), true
+}
+
+// Groups returns every group, newest first.
+func (s *Store) Groups() []GroupStatus {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+	groups := make([]GroupStatus, 0, len(s.groups))
+	for _, g := range s.groups {
+		groups = append(groups, s.groupStatusLocked(g))
+	}
+	sort.Slice(groups, func(i, j int) bool {
+		return groups[i].CreatedAt.After(groups[j].CreatedAt)
+	})
+	return groups
+}
+
+func (s *Store) groupStatusLocked(g *Group) GroupStatus {
+	copied := *g
+	copied.Children = append([]GroupChild(nil), g.Children...)
+	st := GroupStatus{Group: &copied, Counts: make(map[TaskStatus]int)}
+	for _, c := range g.Children {
+		cs := GroupChildStatus{GroupChild: c, Status: StatusFailed, Detail: c.Error}
+		if t, ok := s.tasks[c.TaskID]; ok && c.TaskID != "" {
+			cs.Status = t.Status
+			if t.Status == StatusFailed {
+				cs.Detail = lastError(t)
+			}
+		}
+		st.Children = append(st.Children, cs)
+		st.Counts[cs.Status]++
+	}
+	st.Done = g.Sealed && st.Counts[StatusPending] == 0 && st.Counts[StatusRunning] == 0
+	return st
+}
+
+func (s *Store) groupDoneLocked(g *Group) bool {
+	return s.groupStatusLocked(g).Done
+}
+
+// lastError returns the last error logged by t.
+func lastError(t *Task) string {
+	for i := len(t.Logs) - 1; i >= 0; i-- {
+		if t.Logs[i].Level == "error" && t.Logs[i].Type == "" {
+			return t.Logs[i].Message
+		}
+	}
+	return ""
+}
@@ -0,0 +1,68 @@
+package taskstore
+
+import (
+	"testing"
+)
+
+func TestGroup_AggregatesChildren(t *testing.T) {
+	s := NewStore()
+	var done []string
+	s.SetGroupDoneHook(func(id string) { done = append(done, id) })
+
+	s.Create(&Task{ID: "a", Status: StatusPending})
+	s.Create(&Task{ID: "b", Status: StatusPending})
+	s.CreateGroup(&Group{ID: "g", Title: "Bump lib", Origin: "org/meta#1"})
+	s.AddGroupChild("g", GroupChild{Repo: "org/a", Issue: 3, TaskID: "a"})
+	s.AddGroupChild("g", GroupChild{Repo: "org/b", Issue: 4, TaskID: "b"})
+	s.AddGroupChild("g", GroupChild{Repo: "org/c", Error: "repository not enabled"})
+
+	// a child finishing before the group is sealed does not finish it
+	s.UpdateStatus("a", StatusCompleted)
+	s.SealGroup("g")
+	if len(done) != 0 {
+		t.Fatalf("done hook ran with a child pending: %v", done)
+	}
+	if task, _ := s.Get("b"); task.Group != "g" {
+		t.Fatalf("task group = %q", task.Group)
+	}
+
+	s.UpdateStatus("b", StatusRunning)
+	s.AddLog("b", "error", "tests failed")
+	s.UpdateStatus("b", StatusFailed)
+	if len(done) != 1 || done[0] != "g" {
+		t.Fatalf("done hook calls = %v", done)
+	}
+
+	g, ok := s.Group("g")
+	if !ok || !g.Done || g.Counts[StatusCompleted] != 1 || g.Counts[StatusFailed] != 2 {
+		t.Fatalf("group = %+v", g)
+	}
+	if g.Children[1].Detail != "tests failed" || g.Children[2].Detail != "repository not enabled" {
+		t.Fatalf("children = %+v", g.Children)
+	}
+	if res := s.Query(Query{Group: "g"}); res.Total != 2 {
+		t.Fatalf("tasks in group = %d", res.Total)
+	}
+
+	// a retried child finishes the group again
+	s.UpdateStatus("b", StatusRunning)
+	s.UpdateStatus("b", StatusCompleted)
+	if len(done) != 2 {
+		t.Fatalf("done hook calls = %v", done)
+	}
+}
+
+func TestSealGroup_AllLaunchesFailed(t *testing.T) {
+	s := NewStore()
+	var done []string
+	s.SetGroupDoneHook(func(id string) { done = append(done, id) })
+	s.CreateGroup(&Group{ID: "g"})
+	s.AddGroupChild("g", GroupChild{Repo: "org/a", Error: "no installation"})
+	s.SealGroup("g")
+	if len(done) != 1 {
+		t.Fatalf("done hook calls = %v", done)
+	}
+	if groups := s.Groups(); len(groups) != 1 || groups[0].Counts[StatusFailed] != 1 {
+		t.Fatalf("groups = %+v", groups)
+	}
+}
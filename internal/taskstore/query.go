@@ -0,0 +1,168 @@
+package taskstore
+
+import (
+	"sort"
+	"strings"
+	"time"
+)
+
+// Sort fields accepted by Query.SortBy.
+const (
+	SortByCreated = "created"
+	SortByUpdated = "updated"
+	SortByRepo    = "repo"
+	SortByStatus  = "status"
+)
+
+// DefaultPageSize is used when Query.PageSize is not set.
+const DefaultPageSize = 20
+
+// MaxPageSize caps Query.PageSize to keep responses bounded.
+const MaxPageSize = 200
+
+// Query describes filters, sorting and pagination for listing tasks.
+// Zero values disable the corresponding filter.
+type Query struct {
+	Repo   string     // owner/name, case-insensitive exact match
+	Status TaskStatus // exact status match
+	Actor  string     // trigger user, case-insensitive exact match
+	Since  time.Time  // CreatedAt >= Since
+	Until  time.Time  // CreatedAt < Until
+	Search string     // case-insensitive substring over title and prompt summary
+	Group  string     // fan-out group ID, exact match
+
+	SortBy  string // one of the SortBy* constants (default: created)
+	SortAsc bool   // ascending order when true (default: descending)
+
+	Page     int // 1-based page number
+	PageSize int
+}
+
+// QueryResult is a single page of tasks matching a Query.
+type QueryResult struct {
+	Tasks    []*Task
+	Total    int // number of matching tasks across all pages
+	Page     int
+	PageSize int
+}
+
+// TotalPages returns the number of pages available for the result.
+func (r QueryResult) TotalPages() int {
+	if r.PageSize <= 0 || r.Total == 0 {
+		return 1
+	}
+	return (r.Total + r.PageSize - 1) / r.PageSize
+}
+
+// HasPrev reports whether a previous page exists.
+func (r QueryResult) HasPrev() bool { return r.Page > 1 }
+
+// HasNext reports whether a next page exists.
+func (r QueryResult) HasNext() bool { return r.Page < r.TotalPages() }
+
+// Query returns the tasks matching q, sorted and paginated.
+func (s *Store) Query(q Query) QueryResult {
+	q = normalizeQuery(q)
+
+	s.mu.RLock()
+	matched := make([]*Task, 0, len(s.tasks))
+	for _, task := range s.tasks {
+		if q.matches(task) {
+			matched = append(matched, task)
+		}
+	}
+	s.mu.RUnlock()
+
+	sortTasks(matched, q.SortBy, q.SortAsc)
+
+	result := QueryResult{
+		Total:    len(matched),
+		Page:     q.Page,
+		PageSize: q.PageSize,
+	}
+	if pages := result.TotalPages(); result.Page > pages {
+		result.Page = pages
+	}
+
+	start := (result.Page - 1) * result.PageSize
+	end := start + result.PageSize
+	if end > len(matched) {
+		end = len(matched)
+	}
+	result.Tasks = matched[start:end]
+	return result
+}
+
+func normalizeQuery(q Query) Query {
+	q.Repo = strings.TrimSpace(q.Repo)
+	q.Actor = strings.TrimSpace(q.Actor)
+	q.Group = strings.TrimSpace(q.Group)
+	q.Search = strings.ToLower(strings.TrimSpace(q.Search))
+	switch q.SortBy {
+	case SortByCreated, SortByUpdated, SortByRepo, SortByStatus:
+	default:
+		q.SortBy = SortByCreated
+	}
+	if q.Page <= 0 {
+		q.Page = 1
+	}
+	if q.PageSize <= 0 {
+		q.PageSize = DefaultPageSize
+	}
+	if q.PageSize > MaxPageSize {
+		q.PageSize = MaxPageSize
+	}
+	return q
+}
+
+func (q Query) matches(t *Task) bool {
+	if q.Repo != "" && !strings.EqualFold(t.RepoOwner+"/"+t.RepoName, q.Repo) {
+		return false
+	}
+	if q.Group != "" && t.Group != q.Group {
+		return false
+	}
+	if q.Status != "" && t.Status != q.Status {
+		return false
+	}
+	if q.Actor != "" && !strings.EqualFold(t.Actor, q.Actor) {
+		return false
+	}
+	if !q.Since.IsZero() && t.CreatedAt.Before(q.Since) {
+		return false
+	}
+	if !q.Until.IsZero() && !t.CreatedAt.Before(q.Until) {
+		return false
+	}
+	if q.Search != "" &&
+		!strings.Contains(strings.ToLower(t.Title), q.Search) &&
+		!strings.Contains(strings.ToLower(t.PromptSummary), q.Search) {
+		return false
+	}
+	return true
+}
+
+func sortTasks(tasks []*Task, by string, asc bool) {
+	less := func(a, b *Task) bool {
+		switch by {
+		case SortByUpdated:
+			return a.UpdatedAt.Before(b.UpdatedAt)
+		case SortByRepo:
+			ra, rb := a.RepoOwner+"/"+a.RepoName, b.RepoOwner+"/"+b.RepoName
+			if ra != rb {
+				return ra < rb
+			}
+		case SortByStatus:
+			if a.Status != b.Status {
+				return a.Status < b.Status
+			}
+		}
+		return a.CreatedAt.Before(b.CreatedAt)
+	}
+	sort.SliceStable(tasks, func(i, j int) bool {
+		if asc {
+			return less(tasks[i], tasks[j])
+		}
+		return less(tasks[j], tasks[i])
+	})
+}
@@ -0,0 +1,100 @@
+package taskstore
+
+import (
+	"testing"
+	"time"
+)
+
+func seedQueryStore(t *testing.T) *Store {
+	t.Helper()
+	store := NewStore()
+	base := time.Date(2025, 10, 1, 12, 0, 0, 0, time.UTC)
+	tasks := []*Task{
+		{ID: "t1", RepoOwner: "acme", RepoName: "api", Actor: "alice", Status: StatusCompleted, PromptSummary: "Fix login bug"},
+		{ID: "t2", RepoOwner: "acme", RepoName: "web", Actor: "bob", Status: StatusFailed, PromptSummary: "Add dark mode"},
+		{ID: "t3", RepoOwner: "acme", RepoName: "api", Actor: "Alice", Status: StatusPending, Title: "Refactor auth"},
+		{ID: "t4", RepoOwner: "other", RepoName: "tool", Actor: "carol", Status: StatusRunning, PromptSummary: "Update LOGIN docs"},
+	}
+	for i, task := range tasks {
+		store.Create(task)
+		task.CreatedAt = base.Add(time.Duration(i) * 24 * time.Hour)
+		task.UpdatedAt = task.CreatedAt
+	}
+	return store
+}
+
+func ids(tasks []*Task) []string {
+	out := make([]string, 0, len(tasks))
+	for _, task := range tasks {
+		out = append(out, task.ID)
+	}
+	return out
+}
+
+func TestStore_Query_Filters(t *testing.T) {
+	store := seedQueryStore(t)
+	base := time.Date(2025, 10, 1, 0, 0, 0, 0, time.UTC)
+
+	tests := []struct {
+		name  string
+		query Query
+		want  []string
+	}{
+		{"no filters newest first", Query{}, []string{"t4", "t3", "t2", "t1"}},
+		{"repo", Query{Repo: "ACME/api"}, []string{"t3", "t1"}},
+		{"status", Query{Status: StatusFailed}, []string{"t2"}},
+		{"actor case-insensitive", Query{Actor: "alice"}, []string{"t3", "t1"}},
+		{"search summary and title", Query{Search: "login"}, []string{"t4", "t1"}},
+		{"search title", Query{Search: "auth"}, []string{"t3"}},
+		{"date range", Query{Since: base.AddDate(0, 0, 1), Until: base.AddDate(0, 0, 3)}, []string{"t3", "t2"}},
+		{"ascending", Query{SortAsc: true}, []string{"t1", "t2", "t3", "t4"}},
+		{"sort by repo", Query{SortBy: SortByRepo, SortAsc: true}, []string{"t1", "t3", "t2", "t4"}},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got := ids(store.Query(tt.query).Tasks)
+			if len(got) != len(tt.want) {
+				t.Fatalf("Query() = %v, want %v", got, tt.want)
+			}
+			for i := range got {
+				if got[i] != tt.want[i] {
+					t.Fatalf("Query() = %v, want %v", got, tt.want)
+				}
+			}
+		})
+	}
+}
+
+func TestStore_Query_Pagination(t *testing.T) {
+	store := seedQueryStore(t)
+
+	res := store.Query(Query{Page: 2, PageSize: 3})
+	if res.Total != 4 || res.TotalPages() != 2 {
+		t.Fatalf("Total=%d TotalPages=%d, want 4 and 2", res.Total, res.TotalPages())
+	}
+	if got := ids(res.Tasks); len(got) != 1 || got[0] != "t1" {
+		t.Fatalf("page 2 = %v, want [t1]", got)
+	}
+	if !res.HasPrev() || res.HasNext() {
+		t.Fatalf("HasPrev=%v HasNext=%v, want true/false", res.HasPrev(), res.HasNext())
+	}
+
+	// Out-of-range pages clamp to the last page
+	res = store.Query(Query{Page: 10, PageSize: 3})
+	if res.Page != 2 {
+		t.Fatalf("Page = %d, want clamped to 2", res.Page)
+	}
+
+	res = store.Query(Query{PageSize: MaxPageSize + 1})
+	if res.PageSize != MaxPageSize {
+		t.Fatalf("PageSize = %d, want %d", res.PageSize, MaxPageSize)
+	}
+}
+
+func TestStore_Query_Empty(t *testing.T) {
+	res := NewStore().Query(Query{Page: 3})
+	if res.Total != 0 || len(res.Tasks) != 0 || res.Page != 1 || res.TotalPages() != 1 {
+		t.Fatalf("unexpected empty result: %+v", res)
+	}
+}
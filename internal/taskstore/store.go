@@ -15,33 +15,81 @@ const (
 	StatusFailed    TaskStatus = "failed"
 )
 
+// Approval states of tasks in approval mode; tasks that need no approval
+// leave Task.Approval empty.
+const (
+	ApprovalAwaiting = "awaiting" // the plan is posted and waits for approval
+	ApprovalApproved = "approved"
+)
+
+// Confirmation states of tasks whose estimated cost needed confirming;
+// other tasks leave Task.Confirmation empty.
+const (
+	ConfirmationAwaiting  = "awaiting" // the estimate is posted and waits for confirmation
+	ConfirmationConfirmed = "confirmed"
+)
+
 type Task struct {
-	ID          string
-	Title       string
-	Status      TaskStatus
-	RepoOwner   string
-	RepoName    string
-	IssueNumber int
-	Actor       string
-	CreatedAt   time.Time
-	UpdatedAt   time.Time
-	Logs        []LogEntry
+	ID            string
+	Title         string
+	Status        TaskStatus
+	RepoOwner     string
+	RepoName      string
+	IssueNumber   int
+	Actor         string
+	PromptSummary string
+	CreatedAt     time.Time
+	UpdatedAt     time.Time
+	Logs          []LogEntry
+	// Approval is the approval state of a plan-only task, Plan the plan it
+	// posted and ApprovedBy the user who approved it
+	Approval   string
+	Plan       string
+	ApprovedBy string
+	// Confirmation is the confirmation state of a task stopped for its
+	// estimated cost, EstimatedCostUSD the estimate and ConfirmedBy the user
+	// who confirmed it
+	Confirmation     string
+	EstimatedCostUSD float64
+	ConfirmedBy      string
+	// Group is the fan-out group the task belongs to, if any
+	Group string
+	// DependsOn lists the tasks that must complete before this one starts
+	DependsOn []string
+	// Instruction is what the task was asked, as written after the trigger
+	// keyword; IsPR and BaseBranch complete its context so it can be
+	// replayed. ReplayOf is the task this one replays.
+	Instruction string
+	IsPR        bool
+	BaseBranch  string
+	ReplayOf    string
 }
 
 type LogEntry struct {
 	Timestamp time.Time
 	Level     string // info, error, success
 	Message   string
+	// Provider events also carry their type (command, tool_call, ...), the
+	// tool they ran and how long it took; plain log lines leave these empty.
+	Type     string
+	Tool     string
+	Duration time.Duration
+	// Offloaded names the full text of a message too long for the log,
+	// kept in log storage; Message is then a preview.
+	Offloaded string
 }
 
 type Store struct {
-	mu    sync.RWMutex
-	tasks map[string]*Task
+	mu          sync.RWMutex
+	tasks       map[string]*Task
+	groups      map[string]*Group
+	onGroupDone func(groupID string)
 }
 
 func NewStore() *Store {
 	return &Store{
-		tasks: make(map[string]*Task),
+		tasks:  make(map[string]*Task),
+		groups: make(map[string]*Group),
 	}
 }
 
@@ -60,6 +108,17 @@ func (s *Store) Get(id string) (*Task, bool) {
 	return task, ok
 }
 
+// Status returns the status of the task with the given ID.
+func (s *Store) Status(id string) (TaskStatus, bool) {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+	task, ok := s.tasks[id]
+	if !ok {
+		return "", false
+	}
+	return task.Status, true
+}
+
 func (s *Store) List() []*Task {
 	s.mu.RLock()
 	defer s.mu.RUnlock()
@@ -76,10 +135,18 @@ func (s *Store) List() []*Task {
 
 func (s *Store) UpdateStatus(id string, status TaskStatus) {
 	s.mu.Lock()
-	defer s.mu.Unlock()
+	groupID := ""
 	if task, ok := s.tasks[id]; ok {
 		task.Status = status
 		task.UpdatedAt = time.Now()
+		if g, ok := s.groups[task.Group]; ok && (status == StatusCompleted || status == StatusFailed) && s.groupDoneLocked(g) {
+			groupID = g.ID
+		}
+	}
+	hook := s.onGroupDone
+	s.mu.Unlock()
+	if groupID != "" && hook != nil {
+		hook(groupID)
 	}
 }
 
@@ -96,15 +163,98 @@ func (s *Store) AddLog(id string, level, message string) {
 	}
 }
 
+// AddEntry appends a structured entry, such as a provider tool call, to the
+// task's log; a zero Timestamp is set to now.
+func (s *Store) AddEntry(id string, entry LogEntry) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	if task, ok := s.tasks[id]; ok {
+		if entry.Timestamp.IsZero() {
+			entry.Timestamp = time.Now()
+		}
+		task.Logs = append(task.Logs, entry)
+		task.UpdatedAt = time.Now()
+	}
+}
+
+// AwaitApproval records the plan a plan-only task posted, which now waits
+// for approval.
+func (s *Store) AwaitApproval(id, plan string) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	if task, ok := s.tasks[id]; ok {
+		task.Approval = ApprovalAwaiting
+		task.Plan = plan
+		task.UpdatedAt = time.Now()
+	}
+}
+
+// Approve marks the task's plan approved by user and returns the plan; false
+// when the task is not awaiting approval, so a plan is approved only once.
+func (s *Store) Approve(id, user string) (string, bool) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	task, ok := s.tasks[id]
+	if !ok || task.Approval != ApprovalAwaiting {
+		return "", false
+	}
+	task.Approval = ApprovalApproved
+	task.ApprovedBy = user
+	task.UpdatedAt = time.Now()
+	task.Logs = append(task.Logs, LogEntry{
+		Timestamp: time.Now(),
+		Level:     "info",
+		Message:   "Plan approved by " + user,
+	})
+	return task.Plan, true
+}
+
+// AwaitConfirmation records the estimated cost of a task stopped before its
+// provider ran, which now waits for confirmation.
+func (s *Store) AwaitConfirmation(id string, estimateUSD float64) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	if task, ok := s.tasks[id]; ok {
+		task.Confirmation = ConfirmationAwaiting
+		task.EstimatedCostUSD = estimateUSD
+		task.UpdatedAt = time.Now()
+	}
+}
+
+// Confirm marks the task's estimated cost confirmed by user, which completes
+// the task: the confirmed run is a new one. False when the task is not
+// awaiting confirmation, so a task is confirmed only once.
+func (s *Store) Confirm(id, user string) bool {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	task, ok := s.tasks[id]
+	if !ok || task.Confirmation != ConfirmationAwaiting {
+		return false
+	}
+	task.Confirmation = ConfirmationConfirmed
+	task.ConfirmedBy = user
+	task.Status = StatusCompleted
+	task.UpdatedAt = time.Now()
+	task.Logs = append(task.Logs, LogEntry{
+		Timestamp: time.Now(),
+		Level:     "info",
+		Message:   "Estimated cost confirmed by " + user,
+	})
+	return true
+}
+
 // SupersedeOlder marks older tasks for the same repo/issue as failed so that
 // only the newest /code comment drives execution. Returns the number of tasks affected.
+// Tasks exceptID depends on, directly or not, are kept: they run before it
+// rather than being replaced by it.
 // KISS: linear scan is sufficient for webhook loads and keeps code simple.
 func (s *Store) SupersedeOlder(owner, name string, number int, exceptID string) int {
 	s.mu.Lock()
 	defer s.mu.Unlock()
+	prerequisites := s.prerequisitesLocked(exceptID)
 	n := 0
 	for id, t := range s.tasks {
-		if id == exceptID {
+		if id == exceptID || prerequisites[id] {
 			continue
 		}
 		if t.RepoOwner == owner && t.RepoName == name && t.IssueNumber == number {
@@ -122,3 +272,14 @@ func (s *Store) SupersedeOlder(owner, name string, number int, exceptID string)
 	}
 	return n
 }
+
+// CountByStatus returns the number of tasks in each status.
+func (s *Store) CountByStatus() map[TaskStatus]int {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+	counts := make(map[TaskStatus]int)
+	for _, t := range s.tasks {
+		counts[t.Status]++
+	}
+	return counts
+}
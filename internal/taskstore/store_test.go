@@ -62,6 +62,73 @@ func TestStore_UpdateStatusAndAddLog(t *testing.T) {
 	}
 }
 
+func TestStore_AddEntry(t *testing.T) {
+	store := NewStore()
+	store.Create(&Task{ID: "task-1"})
+	at := time.Date(2026, 10, 16, 9, 0, 0, 0, time.UTC)
+
+	store.AddEntry("task-1", LogEntry{Timestamp: at, Level: "info", Type: "command", Tool: "go", Message: "go test ./...", Duration: 3 * time.Second})
+	store.AddEntry("task-1", LogEntry{Level: "error", Type: "error", Message: "turn failed"})
+	store.AddEntry("missing", LogEntry{Message: "dropped"})
+
+	got, _ := store.Get("task-1")
+	if len(got.Logs) != 2 {
+		t.Fatalf("Logs length = %d, want 2", len(got.Logs))
+	}
+	if e := got.Logs[0]; !e.Timestamp.Equal(at) || e.Tool != "go" || e.Duration != 3*time.Second {
+		t.Fatalf("first entry = %+v", e)
+	}
+	if got.Logs[1].Timestamp.IsZero() {
+		t.Fatal("a zero timestamp should be set to now")
+	}
+}
+
+func TestStore_Approval(t *testing.T) {
+	store := NewStore()
+	store.Create(&Task{ID: "task-1"})
+
+	if _, ok := store.Approve("task-1", "bob"); ok {
+		t.Fatal("a task without a plan cannot be approved")
+	}
+	store.AwaitApproval("task-1", "1. change the parser")
+	if got, _ := store.Get("task-1"); got.Approval != ApprovalAwaiting || got.Plan != "1. change the parser" {
+		t.Fatalf("task = %+v", got)
+	}
+	if plan, ok := store.Approve("task-1", "bob"); !ok || plan != "1. change the parser" {
+		t.Fatalf("Approve = %q, %t", plan, ok)
+	}
+	if _, ok := store.Approve("task-1", "carol"); ok {
+		t.Fatal("a plan is approved only once")
+	}
+	got, _ := store.Get("task-1")
+	if got.Approval != ApprovalApproved || got.ApprovedBy != "bob" || got.Logs[0].Message != "Plan approved by bob" {
+		t.Fatalf("task = %+v", got)
+	}
+	if _, ok := store.Approve("missing", "bob"); ok {
+		t.Fatal("unknown task approved")
+	}
+}
+
+func TestStore_Confirmation(t *testing.T) {
+	store := NewStore()
+	store.Create(&Task{ID: "task-1"})
+
+	if store.Confirm("task-1", "bob") {
+		t.Fatal("a task without an estimate cannot be confirmed")
+	}
+	store.AwaitConfirmation("task-1", 12.5)
+	if !store.Confirm("task-1", "bob") {
+		t.Fatal("Confirm = false")
+	}
+	if store.Confirm("task-1", "carol") {
+		t.Fatal("a task is confirmed only once")
+	}
+	got, _ := store.Get("task-1")
+	if got.Confirmation != ConfirmationConfirmed || got.EstimatedCostUSD != 12.5 || got.ConfirmedBy != "bob" || got.Logs[0].Message != "Estimated cost confirmed by bob" {
+		t.Fatalf("task = %+v", got)
+	}
+}
+
 func TestStore_SupersedeOlder_NoMatches(t *testing.T) {
 	store := NewStore()
 	// task in other repo/issue should not be touched
@@ -0,0 +1,132 @@
+package taskstore
+
+import (
+	"strings"
+	"time"
+)
+
+// TypePhase marks a log entry that starts a phase of the task; its Message is
+// the phase name.
+const TypePhase = "phase"
+
+// Phases recorded by the executor, in the order they usually run. A task is
+// PhaseQueued from its creation until the first recorded phase; PhaseDone
+// ends the run.
+const (
+	PhaseQueued   = "queued"
+	PhaseFetch    = "fetch context"
+	PhaseClone    = "clone"
+	PhaseSetup    = "setup"
+	PhaseProvider = "provider run"
+	PhaseSubtasks = "sub-tasks"
+	PhasePush     = "push"
+	PhaseTests    = "tests"
+	PhaseRelease  = "release"
+	PhaseUpdate   = "dependency update"
+	PhaseDone     = "done"
+)
+
+// Milestones flagged among the steps of a phase.
+const (
+	MilestonePush    = "push"
+	MilestoneComment = "comment updated"
+)
+
+// commentTool is the MCP tool that updates the tracking comment.
+const commentTool = "update_claude_comment"
+
+// Phase is one span of a task's timeline with the log entries (tool calls,
+// commands, messages) recorded while it ran.
+type Phase struct {
+	Name     string
+	Start    time.Time
+	Duration time.Duration
+	Running  bool // the phase has not ended yet
+	Failed   bool // the run ended with an error during this phase
+	Steps    []LogEntry
+}
+
+// Timeline is a task's phases in order, with retries following each other.
+type Timeline struct {
+	Phases []Phase
+	Total  time.Duration // from creation to the end of the last phase
+}
+
+// Slowest returns the index of the longest phase, or -1 without phases.
+func (t Timeline) Slowest() int {
+	slowest := -1
+	for i, p := range t.Phases {
+		if slowest < 0 || p.Duration > t.Phases[slowest].Duration {
+			slowest = i
+		}
+	}
+	return slowest
+}
+
+// Milestone names what a provider event achieved for the timeline: a git
+// push or an update of the tracking comment; other entries return "".
+func (e LogEntry) Milestone() string {
+	switch {
+	case e.Type == "command" && e.Level != "error" && strings.Contains(e.Message, "git push"):
+		return MilestonePush
+	case e.Type == "tool_call" && e.Level != "error" && strings.HasSuffix(e.Tool, commentTool):
+		return MilestoneComment
+	}
+	return ""
+}
+
+// Timeline builds the timeline of task id from its phase entries; phases
+// still running are measured up to now, and a task that finished without a
+// PhaseDone entry (superseded, say) ends at its last update.
+func (s *Store) Timeline(id string, now time.Time) (Timeline, bool) {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+	task, ok := s.tasks[id]
+	if !ok {
+		return Timeline{}, false
+	}
+	active := task.Status == StatusPending || task.Status == StatusRunning
+	if !active {
+		now = task.UpdatedAt
+	}
+	return buildTimeline(task.CreatedAt, task.Logs, now, active), true
+}
+
+func buildTimeline(created time.Time, logs []LogEntry, now time.Time, active bool) Timeline {
+	var tl Timeline
+	current := &Phase{Name: PhaseQueued, Start: created}
+	end := now
+	closeCurrent := func(at time.Time) {
+		if current == nil {
+			return
+		}
+		current.Duration = at.Sub(current.Start)
+		tl.Phases = append(tl.Phases, *current)
+		current = nil
+	}
+	for _, entry := range logs {
+		if entry.Type != TypePhase {
+			if current != nil {
+				current.Steps = append(current.Steps, entry)
+			}
+			continue
+		}
+		if entry.Message == PhaseDone {
+			if current != nil {
+				current.Failed = entry.Level == "error"
+			}
+			closeCurrent(entry.Timestamp)
+			end = entry.Timestamp
+			continue
+		}
+		closeCurrent(entry.Timestamp)
+		current = &Phase{Name: entry.Message, Start: entry.Timestamp}
+	}
+	if current != nil {
+		current.Running = active
+		closeCurrent(now)
+		end = now
+	}
+	tl.Total = end.Sub(created)
+	return tl
+}
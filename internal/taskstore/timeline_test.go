@@ -0,0 +1,74 @@
+package taskstore
+
+import (
+	"testing"
+	"time"
+)
+
+func TestBuildTimeline(t *testing.T) {
+	t0 := time.Date(2026, 10, 16, 9, 0, 0, 0, time.UTC)
+	at := func(s int) time.Time { return t0.Add(time.Duration(s) * time.Second) }
+	logs := []LogEntry{
+		{Timestamp: at(0), Level: "info", Message: "Task queued"},
+		{Timestamp: at(5), Type: TypePhase, Message: PhaseFetch},
+		{Timestamp: at(7), Type: TypePhase, Message: PhaseClone},
+		{Timestamp: at(10), Type: TypePhase, Message: PhaseProvider},
+		{Timestamp: at(40), Type: "command", Tool: "git", Message: "bash -lc 'git push origin HEAD'", Duration: 2 * time.Second},
+		{Timestamp: at(50), Type: "tool_call", Tool: "mcp__comment_updater__update_claude_comment"},
+		{Timestamp: at(70), Type: TypePhase, Message: PhasePush},
+		{Timestamp: at(71), Type: TypePhase, Message: PhaseDone, Level: "success"},
+	}
+
+	tl := buildTimeline(t0, logs, at(500), false)
+	want := []struct {
+		name string
+		dur  int
+		n    int
+	}{{PhaseQueued, 5, 1}, {PhaseFetch, 2, 0}, {PhaseClone, 3, 0}, {PhaseProvider, 60, 2}, {PhasePush, 1, 0}}
+	if len(tl.Phases) != len(want) {
+		t.Fatalf("phases = %+v", tl.Phases)
+	}
+	for i, w := range want {
+		p := tl.Phases[i]
+		if p.Name != w.name || p.Duration != time.Duration(w.dur)*time.Second || len(p.Steps) != w.n || p.Running || p.Failed {
+			t.Errorf("phase %d = %s %v (%d steps), want %s %ds (%d steps)", i, p.Name, p.Duration, len(p.Steps), w.name, w.dur, w.n)
+		}
+	}
+	if tl.Total != 71*time.Second || tl.Slowest() != 3 {
+		t.Fatalf("total = %v, slowest = %d", tl.Total, tl.Slowest())
+	}
+	steps := tl.Phases[3].Steps
+	if steps[0].Milestone() != MilestonePush || steps[1].Milestone() != MilestoneComment {
+		t.Fatalf("milestones = %q, %q", steps[0].Milestone(), steps[1].Milestone())
+	}
+
+	// a retry after a failure starts over; the running phase lasts until now
+	logs[len(logs)-1].Level = "error"
+	logs = append(logs, LogEntry{Timestamp: at(90), Type: TypePhase, Message: PhaseFetch})
+	tl = buildTimeline(t0, logs, at(100), true)
+	if p := tl.Phases[4]; !p.Failed {
+		t.Fatalf("the phase that failed = %+v", p)
+	}
+	if p := tl.Phases[5]; p.Name != PhaseFetch || !p.Running || p.Duration != 10*time.Second || tl.Total != 100*time.Second {
+		t.Fatalf("running phase = %+v, total %v", p, tl.Total)
+	}
+}
+
+func TestStore_Timeline(t *testing.T) {
+	store := NewStore()
+	if _, ok := store.Timeline("missing", time.Now()); ok {
+		t.Fatal("Timeline of a missing task")
+	}
+	store.Create(&Task{ID: "pending", Status: StatusPending})
+	tl, _ := store.Timeline("pending", time.Now())
+	if len(tl.Phases) != 1 || tl.Phases[0].Name != PhaseQueued || !tl.Phases[0].Running {
+		t.Fatalf("pending task = %+v", tl.Phases)
+	}
+
+	// superseded before it ran: queued until it was marked failed
+	store.SupersedeOlder("", "", 0, "other")
+	tl, _ = store.Timeline("pending", time.Now().Add(time.Hour))
+	if p := tl.Phases[0]; p.Running || p.Duration > time.Minute {
+		t.Fatalf("superseded task = %+v", p)
+	}
+}
@@ -54,6 +54,10 @@ func BuildAllowedTools(opts Options) []string {
 		"mcp__sequential-thinking__sequentialthinking", // Deep reasoning
 		"mcp__fetch__fetch",                            // Web content fetching
 		"mcp__comment_updater__update_claude_comment",  // Progress tracking (coordinating comment)
+		"mcp__review_threads__list_review_threads",     // Pull request review threads
+		"mcp__review_threads__reply_to_review_thread",
+		"mcp__review_threads__resolve_review_thread",
+		"mcp__review_threads__unresolve_review_thread",
 	)
 
 	// Append any custom tools last
@@ -77,6 +81,7 @@ func BuildDisallowedTools(opts Options) []string {
 		"Bash(git push --force)",
 		"Bash(git push -f)",
 		"Bash(git push --force-with-lease)",
+		"Bash(git push --no-verify)", // Bypasses the protected-branch push guard
 		"Bash(git reset --hard)",
 		"Bash(git clean -fd)",
 		"Bash(git clean -f)",
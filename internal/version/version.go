@@ -0,0 +1,110 @@
+// Package version reports which build of swe-agent is running. Release
+// builds set the variables below with -ldflags, e.g.
+//
+//	-X github.com/cexll/swe/internal/version.Version=v1.2.3
+//	-X github.com/cexll/swe/internal/version.Commit=$(git rev-parse HEAD)
+//	-X github.com/cexll/swe/internal/version.Date=$(date -u +%Y-%m-%dT%H:%M:%SZ)
+//
+// Builds without them fall back to the module version and VCS stamp the Go
+// toolchain embeds, so `go install` and `go build` binaries still identify
+// themselves.
+package version
+
+import (
+	"runtime"
+	"runtime/debug"
+	"strings"
+)
+
+// Set at link time; see the package comment.
+var (
+	Version = "dev"
+	Commit  = ""
+	Date    = ""
+)
+
+// allow tests to replace the embedded build information
+var readBuildInfo = debug.ReadBuildInfo
+
+// Info describes the running build.
+type Info struct {
+	Version   string `json:"version"`
+	Commit    string `json:"commit,omitempty"`
+	Date      string `json:"build_date,omitempty"`
+	GoVersion string `json:"go_version"`
+	Platform  string `json:"platform"`
+}
+
+// Get returns the running build, filling what the linker did not set from
+// the embedded build information.
+func Get() Info {
+	info := Info{
+		Version:   Version,
+		Commit:    Commit,
+		Date:      Date,
+		GoVersion: runtime.Version(),
+		Platform:  runtime.GOOS + "/" + runtime.GOARCH,
+	}
+	bi, ok := readBuildInfo()
+	if !ok {
+		return info
+	}
+	if info.Version == "dev" && bi.Main.Version != "" && bi.Main.Version != "(devel)" {
+		info.Version = bi.Main.Version
+	}
+	dirty := false
+	for _, s := range bi.Settings {
+		switch s.Key {
+		case "vcs.revision":
+			if info.Commit == "" {
+				info.Commit = s.Value
+			}
+		case "vcs.time":
+			if info.Date == "" {
+				info.Date = s.Value
+			}
+		case "vcs.modified":
+			dirty = s.Value == "true"
+		}
+	}
+	if dirty && Commit == "" && info.Commit != "" {
+		info.Commit += "-dirty"
+	}
+	return info
+}
+
+// ShortCommit returns the first 7 characters of the commit ("" if unknown).
+func (i Info) ShortCommit() string {
+	c, dirty := strings.CutSuffix(i.Commit, "-dirty")
+	if len(c) > 7 {
+		c = c[:7]
+	}
+	if dirty {
+		c += "-dirty"
+	}
+	return c
+}
+
+// Short is the version and short commit, e.g. "v1.2.3 (abc1234)", as shown
+// in page and comment footers.
+func (i Info) Short() string {
+	if c := i.ShortCommit(); c != "" {
+		return i.Version + " (" + c + ")"
+	}
+	return i.Version
+}
+
+// String is the one-line description printed by --version.
+func (i Info) String() string {
+	s := "swe-agent " + i.Version
+	if i.Commit != "" {
+		s += " commit " + i.Commit
+	}
+	if i.Date != "" {
+		s += " built " + i.Date
+	}
+	return s + " " + i.GoVersion + " " + i.Platform
+}
+
+// Short returns Get().Short().
+func Short() string { return Get().Short() }
@@ -0,0 +1,58 @@
+package version
+
+import (
+	"runtime/debug"
+	"testing"
+)
+
+func stubBuildInfo(t *testing.T, bi *debug.BuildInfo) {
+	t.Helper()
+	orig, v, c, d := readBuildInfo, Version, Commit, Date
+	t.Cleanup(func() { readBuildInfo, Version, Commit, Date = orig, v, c, d })
+	readBuildInfo = func() (*debug.BuildInfo, bool) { return bi, bi != nil }
+}
+
+func TestGet_LinkerValuesWin(t *testing.T) {
+	stubBuildInfo(t, &debug.BuildInfo{
+		Main:     debug.Module{Version: "v0.9.0"},
+		Settings: []debug.BuildSetting{{Key: "vcs.revision", Value: "ffffffffffff"}, {Key: "vcs.modified", Value: "true"}},
+	})
+	Version, Commit, Date = "v1.2.3", "0123456789abcdef", "2026-10-16T09:00:00Z"
+
+	info := Get()
+	if info.Version != "v1.2.3" || info.Commit != "0123456789abcdef" || info.Date != "2026-10-16T09:00:00Z" {
+		t.Fatalf("Get = %+v", info)
+	}
+	if got := info.Short(); got != "v1.2.3 (0123456)" {
+		t.Fatalf("Short = %q", got)
+	}
+}
+
+func TestGet_FallsBackToBuildInfo(t *testing.T) {
+	stubBuildInfo(t, &debug.BuildInfo{
+		Main: debug.Module{Version: "(devel)"},
+		Settings: []debug.BuildSetting{
+			{Key: "vcs.revision", Value: "abcdef0123456789"},
+			{Key: "vcs.time", Value: "2026-10-01T12:00:00Z"},
+			{Key: "vcs.modified", Value: "true"},
+		},
+	})
+	Version, Commit, Date = "dev", "", ""
+
+	info := Get()
+	if info.Version != "dev" || info.Commit != "abcdef0123456789-dirty" || info.Date != "2026-10-01T12:00:00Z" {
+		t.Fatalf("Get = %+v", info)
+	}
+	if got := info.Short(); got != "dev (abcdef0-dirty)" {
+		t.Fatalf("Short = %q", got)
+	}
+
+	stubBuildInfo(t, &debug.BuildInfo{Main: debug.Module{Version: "v1.4.0"}})
+	if got := Get().Short(); got != "v1.4.0" {
+		t.Fatalf("go install build = %q", got)
+	}
+	stubBuildInfo(t, nil)
+	if got := Get(); got.Version != "dev" || got.Platform == "" || got.GoVersion == "" {
+		t.Fatalf("without build info = %+v", got)
+	}
+}
@@ -0,0 +1,175 @@
+package web
+
+import (
+	"errors"
+	"fmt"
+	"io"
+	"io/fs"
+	"log/slog"
+	"net/http"
+	"path"
+	"time"
+
+	"github.com/gorilla/mux"
+
+	"github.com/cexll/swe/internal/artifacts"
+	"github.com/cexll/swe/internal/logging"
+	"github.com/cexll/swe/internal/webhook"
+)
+
+// artifactLink is one downloadable artifact as rendered by detail.html.
+type artifactLink struct {
+	Name     string
+	Size     string
+	Modified time.Time
+}
+
+// SetArtifacts wires the store of task transcripts, diffs and test output
+// linked from the task detail page.
+func (h *Handler) SetArtifacts(s *artifacts.Store) {
+	h.artifacts = s
+}
+
+// taskArtifacts lists the artifacts kept for a task; listing failures only
+// hide the links.
+func (h *Handler) taskArtifacts(r *http.Request, taskID string) []artifactLink {
+	list, err := h.artifacts.List(r.Context(), taskID)
+	if err != nil {
+		slog.WarnContext(r.Context(), "Listing artifacts failed", logging.KeyTaskID, taskID, logging.KeyPhase, "web", "err", err)
+		return nil
+	}
+	links := make([]artifactLink, 0, len(list))
+	for _, a := range list {
+		if a.Name == artifacts.Prompt {
+			// operators read it through TaskPrompt
+			continue
+		}
+		links = append(links, artifactLink{Name: a.Name, Size: formatSize(a.Size), Modified: a.Modified})
+	}
+	return links
+}
+
+// SetLogStorage wires the store holding the full text of long task log
+// messages.
+func (h *Handler) SetLogStorage(s *artifacts.Store) {
+	h.logs = s
+}
+
+// TaskArtifact downloads one artifact of a task.
+func (h *Handler) TaskArtifact(w http.ResponseWriter, r *http.Request) {
+	if h.artifacts == nil {
+		http.Error(w, "artifact storage unavailable", http.StatusServiceUnavailable)
+		return
+	}
+	if mux.Vars(r)["name"] == artifacts.Prompt {
+		http.NotFound(w, r)
+		return
+	}
+	serveTaskFile(w, r, h.artifacts)
+}
+
+// SetAPIToken enables the operator endpoints for requests sending token as
+// a bearer token ("" disables them).
+func (h *Handler) SetAPIToken(token string) {
+	h.apiToken = token
+}
+
+// operatorOnly lets r through when it carries the operator token. Otherwise
+// it answers 503 when API_TOKEN is unset, naming what is disabled, or 401.
+func (h *Handler) operatorOnly(w http.ResponseWriter, r *http.Request, what string) bool {
+	if h.apiToken == "" {
+		http.Error(w, what+" disabled (API_TOKEN not set)", http.StatusServiceUnavailable)
+		return false
+	}
+	if !webhook.OperatorAuthorized(r, h.apiToken) {
+		w.Header().Set("WWW-Authenticate", `Bearer realm="swe-agent"`)
+		http.Error(w, "unauthorized", http.StatusUnauthorized)
+		return false
+	}
+	return true
+}
+
+// TaskPrompt serves GET /api/v1/tasks/{id}/prompt: the prompt task {id}
+// handed to the provider (an executor.PromptRecord), for operators holding
+// API_TOKEN.
+func (h *Handler) TaskPrompt(w http.ResponseWriter, r *http.Request) {
+	if !h.operatorOnly(w, r, "prompt API") {
+		return
+	}
+	if h.artifacts == nil {
+		http.Error(w, "artifact storage unavailable", http.StatusServiceUnavailable)
+		return
+	}
+	id := mux.Vars(r)["id"]
+	rc, err := h.artifacts.Open(r.Context(), id, artifacts.Prompt)
+	if errors.Is(err, fs.ErrNotExist) || errors.Is(err, fs.ErrInvalid) {
+		http.Error(w, "no prompt recorded for this task (not started yet, or started before prompts were kept)", http.StatusNotFound)
+		return
+	}
+	if err != nil {
+		slog.WarnContext(r.Context(), "Opening prompt failed", logging.KeyTaskID, id, logging.KeyPhase, "web", "err", err)
+		http.Error(w, "prompt unavailable", http.StatusBadGateway)
+		return
+	}
+	defer func() { _ = rc.Close() }()
+
+	w.Header().Set("Content-Type", "application/json")
+	w.Header().Set("Cache-Control", "no-store")
+	_, _ = io.Copy(w, rc)
+}
+
+// TaskLog downloads the full text of a shortened task log message.
+func (h *Handler) TaskLog(w http.ResponseWriter, r *http.Request) {
+	if h.logs == nil {
+		http.Error(w, "log storage unavailable", http.StatusServiceUnavailable)
+		return
+	}
+	serveTaskFile(w, r, h.logs)
+}
+
+// serveTaskFile streams the {name} file of task {id} from store.
+func serveTaskFile(w http.ResponseWriter, r *http.Request, store *artifacts.Store) {
+	vars := mux.Vars(r)
+	id, name := vars["id"], vars["name"]
+	rc, err := store.Open(r.Context(), id, name)
+	if errors.Is(err, fs.ErrNotExist) || errors.Is(err, fs.ErrInvalid) {
+		http.NotFound(w, r)
+		return
+	}
+	if err != nil {
+		slog.WarnContext(r.Context(), "Opening artifact failed", logging.KeyTaskID, id, logging.KeyPhase, "web", "name", name, "err", err)
+		http.Error(w, "file unavailable", http.StatusBadGateway)
+		return
+	}
+	defer func() { _ = rc.Close() }()
+
+	w.Header().Set("Content-Type", artifactContentType(name))
+	w.Header().Set("Content-Disposition", fmt.Sprintf("attachment; filename=%q", id+"-"+name))
+	w.Header().Set("X-Content-Type-Options", "nosniff")
+	_, _ = io.Copy(w, rc)
+}
+
+func artifactContentType(name string) string {
+	switch path.Ext(name) {
+	case ".jsonl":
+		return "application/x-ndjson"
+	case ".patch", ".diff":
+		return "text/x-diff; charset=utf-8"
+	default:
+		return "text/plain; charset=utf-8"
+	}
+}
+
+// formatSize renders a byte count for humans (e.g. "1.5 MB").
+func formatSize(n int64) string {
+	const unit = 1024
+	if n < unit {
+		return fmt.Sprintf("%d B", n)
+	}
+	div, exp := int64(unit), 0
+	for m := n / unit; m >= unit; m /= unit {
+		div *= unit
+		exp++
+	}
+	return fmt.Sprintf("%.1f %cB", float64(n)/float64(div), "KMGTPE"[exp])
+}
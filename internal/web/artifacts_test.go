@@ -0,0 +1,148 @@
+package web
+
+import (
+	"context"
+	"net/http"
+	"net/http/httptest"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"github.com/gorilla/mux"
+
+	"github.com/cexll/swe/internal/artifacts"
+	"github.com/cexll/swe/internal/storage"
+	"github.com/cexll/swe/internal/taskstore"
+)
+
+func TestHandler_TaskArtifacts(t *testing.T) {
+	tmpl, err := ParseTemplates(filepath.Join("..", "..", "templates", "*.html"))
+	if err != nil {
+		t.Fatalf("parse templates: %v", err)
+	}
+	store := taskstore.NewStore()
+	store.Create(&taskstore.Task{ID: "task-1", Title: "demo"})
+	arts := artifacts.New(&storage.Local{Dir: t.TempDir()}, 0)
+	if err := arts.Save(context.Background(), "task-1", artifacts.Diff, []byte("diff --git a/x b/x\n")); err != nil {
+		t.Fatal(err)
+	}
+	handler := &Handler{store: store, templates: tmpl}
+	handler.SetArtifacts(arts)
+
+	req := mux.SetURLVars(httptest.NewRequest(http.MethodGet, "/tasks/task-1", nil), map[string]string{"id": "task-1"})
+	rr := httptest.NewRecorder()
+	handler.TaskDetail(rr, req)
+	if !strings.Contains(rr.Body.String(), `<a href="/tasks/task-1/artifacts/diff.patch">diff.patch</a>`) || !strings.Contains(rr.Body.String(), "19 B") {
+		t.Fatalf("detail page does not link the diff:\n%s", rr.Body.String())
+	}
+
+	req = mux.SetURLVars(httptest.NewRequest(http.MethodGet, "/tasks/task-1/artifacts/diff.patch", nil), map[string]string{"id": "task-1", "name": "diff.patch"})
+	rr = httptest.NewRecorder()
+	handler.TaskArtifact(rr, req)
+	if rr.Code != http.StatusOK || rr.Body.String() != "diff --git a/x b/x\n" {
+		t.Fatalf("download = %d %q", rr.Code, rr.Body.String())
+	}
+	if got := rr.Header().Get("Content-Disposition"); got != `attachment; filename="task-1-diff.patch"` {
+		t.Fatalf("Content-Disposition = %q", got)
+	}
+
+	for _, name := range []string{artifacts.Transcript, "../secret"} {
+		req = mux.SetURLVars(httptest.NewRequest(http.MethodGet, "/", nil), map[string]string{"id": "task-1", "name": name})
+		rr = httptest.NewRecorder()
+		handler.TaskArtifact(rr, req)
+		if rr.Code != http.StatusNotFound {
+			t.Errorf("download of %q = %d, want 404", name, rr.Code)
+		}
+	}
+}
+
+func TestFormatSize(t *testing.T) {
+	for n, want := range map[int64]string{0: "0 B", 1023: "1023 B", 1536: "1.5 KB", 5 << 20: "5.0 MB"} {
+		if got := formatSize(n); got != want {
+			t.Errorf("formatSize(%d) = %q, want %q", n, got, want)
+		}
+	}
+}
+
+func TestHandler_TaskLog(t *testing.T) {
+	tmpl, err := ParseTemplates(filepath.Join("..", "..", "templates", "*.html"))
+	if err != nil {
+		t.Fatalf("parse templates: %v", err)
+	}
+	store := taskstore.NewStore()
+	store.Create(&taskstore.Task{ID: "task-1", Title: "demo"})
+	store.AddEntry("task-1", taskstore.LogEntry{Level: "error", Message: "FAIL...", Offloaded: "error.log"})
+	logs := artifacts.New(&storage.Local{Dir: t.TempDir()}, 0)
+	if err := logs.Save(context.Background(), "task-1", "error.log", []byte("FAIL: everything")); err != nil {
+		t.Fatal(err)
+	}
+	handler := &Handler{store: store, templates: tmpl}
+
+	req := mux.SetURLVars(httptest.NewRequest(http.MethodGet, "/tasks/task-1/logs/error.log", nil), map[string]string{"id": "task-1", "name": "error.log"})
+	rr := httptest.NewRecorder()
+	handler.TaskLog(rr, req)
+	if rr.Code != http.StatusServiceUnavailable {
+		t.Fatalf("without log storage = %d, want 503", rr.Code)
+	}
+
+	handler.SetLogStorage(logs)
+	rr = httptest.NewRecorder()
+	handler.TaskLog(rr, req)
+	if rr.Code != http.StatusOK || rr.Body.String() != "FAIL: everything" {
+		t.Fatalf("download = %d %q", rr.Code, rr.Body.String())
+	}
+
+	req = mux.SetURLVars(httptest.NewRequest(http.MethodGet, "/tasks/task-1", nil), map[string]string{"id": "task-1"})
+	rr = httptest.NewRecorder()
+	handler.TaskDetail(rr, req)
+	if !strings.Contains(rr.Body.String(), `<a href="/tasks/task-1/logs/error.log">full message</a>`) {
+		t.Fatalf("detail page does not link the full message:\n%s", rr.Body.String())
+	}
+}
+
+func TestHandler_TaskPrompt(t *testing.T) {
+	arts := artifacts.New(&storage.Local{Dir: t.TempDir()}, 0)
+	record := `{"task_id":"task-1","template":"built-in","system":"# SWE Agent System Prompt","user":"/code fix"}`
+	if err := arts.Save(context.Background(), "task-1", artifacts.Prompt, []byte(record)); err != nil {
+		t.Fatal(err)
+	}
+	handler := &Handler{store: taskstore.NewStore()}
+	handler.SetArtifacts(arts)
+	get := func(id, token string) *httptest.ResponseRecorder {
+		req := mux.SetURLVars(httptest.NewRequest(http.MethodGet, "/api/v1/tasks/"+id+"/prompt", nil), map[string]string{"id": id})
+		if token != "" {
+			req.Header.Set("Authorization", "Bearer "+token)
+		}
+		rr := httptest.NewRecorder()
+		handler.TaskPrompt(rr, req)
+		return rr
+	}
+
+	if rr := get("task-1", "secret"); rr.Code != http.StatusServiceUnavailable {
+		t.Fatalf("without API_TOKEN = %d, want 503", rr.Code)
+	}
+	handler.SetAPIToken("secret")
+	if rr := get("task-1", ""); rr.Code != http.StatusUnauthorized {
+		t.Fatalf("without token = %d, want 401", rr.Code)
+	}
+	if rr := get("task-1", "wrong"); rr.Code != http.StatusUnauthorized {
+		t.Fatalf("wrong token = %d, want 401", rr.Code)
+	}
+	if rr := get("task-1", "secret"); rr.Code != http.StatusOK || rr.Body.String() != record || rr.Header().Get("Content-Type") != "application/json" {
+		t.Fatalf("prompt = %d %q", rr.Code, rr.Body.String())
+	}
+	if rr := get("task-2", "secret"); rr.Code != http.StatusNotFound {
+		t.Fatalf("unrecorded prompt = %d, want 404", rr.Code)
+	}
+
+	// the prompt is neither linked nor downloadable as a plain artifact
+	req := mux.SetURLVars(httptest.NewRequest(http.MethodGet, "/", nil), map[string]string{"id": "task-1", "name": artifacts.Prompt})
+	rr := httptest.NewRecorder()
+	handler.TaskArtifact(rr, req)
+	if rr.Code != http.StatusNotFound {
+		t.Fatalf("artifact download = %d, want 404", rr.Code)
+	}
+	if links := handler.taskArtifacts(req, "task-1"); len(links) != 0 {
+		t.Fatalf("links = %+v", links)
+	}
+}
@@ -0,0 +1,58 @@
+package web
+
+import (
+	"encoding/json"
+	"fmt"
+	"net/http"
+
+	"github.com/gorilla/mux"
+
+	"github.com/cexll/swe/internal/audit"
+	"github.com/cexll/swe/internal/budget"
+)
+
+// SetBudgets wires the organization spending served by the budgets API.
+func (h *Handler) SetBudgets(t *budget.Tracker) {
+	h.budgets = t
+}
+
+// Budgets lists this month's spending of the organizations with a budget or
+// spending. It needs the operator token.
+func (h *Handler) Budgets(w http.ResponseWriter, r *http.Request) {
+	if !h.operatorOnly(w, r, "budgets API") {
+		return
+	}
+	if h.budgets == nil {
+		http.Error(w, "budget tracking unavailable", http.StatusServiceUnavailable)
+		return
+	}
+	w.Header().Set("Content-Type", "application/json")
+	_ = json.NewEncoder(w).Encode(h.budgets.List())
+}
+
+// ResetBudget forgets what an organization spent this month, lifting its
+// warning and hard cap. It needs the operator token.
+func (h *Handler) ResetBudget(w http.ResponseWriter, r *http.Request) {
+	if !h.operatorOnly(w, r, "budgets API") {
+		return
+	}
+	if h.budgets == nil {
+		http.Error(w, "budget tracking unavailable", http.StatusServiceUnavailable)
+		return
+	}
+	org := mux.Vars(r)["org"]
+	before := h.budgets.Status(org)
+	st, err := h.budgets.Reset(org)
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusInternalServerError)
+		return
+	}
+	if h.audit != nil {
+		_ = h.audit.Record(audit.Event{
+			Action: audit.ActionBudgetReset,
+			Detail: fmt.Sprintf("%s: $%.2f spent in %s forgotten", st.Org, before.SpentUSD, st.Month),
+		})
+	}
+	w.Header().Set("Content-Type", "application/json")
+	_ = json.NewEncoder(w).Encode(st)
+}
@@ -0,0 +1,82 @@
+package web
+
+import (
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"github.com/gorilla/mux"
+
+	"github.com/cexll/swe/internal/audit"
+	"github.com/cexll/swe/internal/budget"
+)
+
+func resetBudget(h *Handler, token, org string) *httptest.ResponseRecorder {
+	req := httptest.NewRequest(http.MethodPost, "/admin/api/budgets/"+org+"/reset", nil)
+	if token != "" {
+		req.Header.Set("Authorization", "Bearer "+token)
+	}
+	req = mux.SetURLVars(req, map[string]string{"org": org})
+	rr := httptest.NewRecorder()
+	h.ResetBudget(rr, req)
+	return rr
+}
+
+func listBudgets(h *Handler, token string) *httptest.ResponseRecorder {
+	req := httptest.NewRequest(http.MethodGet, "/admin/api/budgets", nil)
+	if token != "" {
+		req.Header.Set("Authorization", "Bearer "+token)
+	}
+	rr := httptest.NewRecorder()
+	h.Budgets(rr, req)
+	return rr
+}
+
+func TestHandler_Budgets(t *testing.T) {
+	handler := &Handler{}
+	if rr := listBudgets(handler, "op-token"); rr.Code != http.StatusServiceUnavailable {
+		t.Fatalf("without API_TOKEN: status = %d", rr.Code)
+	}
+	handler.SetAPIToken("op-token")
+	if rr := listBudgets(handler, ""); rr.Code != http.StatusUnauthorized {
+		t.Fatalf("without a token: status = %d", rr.Code)
+	}
+	if rr := listBudgets(handler, "op-token"); rr.Code != http.StatusServiceUnavailable {
+		t.Fatalf("without a tracker: status = %d", rr.Code)
+	}
+
+	tracker, _ := budget.Open("")
+	tracker.SetLimits(budget.Limits{Orgs: map[string]float64{"acme": 10}, HardCapPercent: 100})
+	_, _, _ = tracker.Add("acme", 11)
+	log, _ := audit.New(audit.Config{})
+	handler.SetBudgets(tracker)
+	handler.SetAuditLog(log)
+
+	rr := listBudgets(handler, "op-token")
+	var got []budget.Status
+	if err := json.Unmarshal(rr.Body.Bytes(), &got); err != nil {
+		t.Fatalf("invalid JSON: %v", err)
+	}
+	if len(got) != 1 || got[0].Org != "acme" || got[0].SpentUSD != 11 || !got[0].Exhausted {
+		t.Fatalf("budgets = %+v", got)
+	}
+
+	if rr := resetBudget(&Handler{}, "op-token", "acme"); rr.Code != http.StatusServiceUnavailable {
+		t.Fatalf("reset without API_TOKEN: status = %d", rr.Code)
+	}
+	if rr := resetBudget(handler, "wrong", "acme"); rr.Code != http.StatusUnauthorized {
+		t.Fatalf("wrong token: status = %d", rr.Code)
+	}
+	rr = resetBudget(handler, "op-token", "Acme")
+	var st budget.Status
+	if err := json.Unmarshal(rr.Body.Bytes(), &st); err != nil {
+		t.Fatalf("invalid JSON: %v", err)
+	}
+	if rr.Code != http.StatusOK || st.SpentUSD != 0 || st.Exhausted || tracker.Status("acme").Exhausted {
+		t.Fatalf("status = %d, budget = %+v", rr.Code, st)
+	}
+	if events := log.List(audit.Filter{Action: audit.ActionBudgetReset}); len(events) != 1 || events[0].Detail != "acme: $11.00 spent in "+st.Month+" forgotten" {
+		t.Fatalf("audit events = %+v", events)
+	}
+}
@@ -0,0 +1,271 @@
+package web
+
+import (
+	"context"
+	"errors"
+	"io"
+	"io/fs"
+	"log/slog"
+	"net/http"
+	"strings"
+
+	"github.com/gorilla/mux"
+
+	"github.com/cexll/swe/internal/artifacts"
+	"github.com/cexll/swe/internal/logging"
+	"github.com/cexll/swe/internal/taskstore"
+)
+
+// Limits of the run comparison: artifacts are read up to maxCompareSize,
+// and a file whose versions differ in more than maxCompareEdits lines is
+// shown as replaced rather than diffed line by line.
+const (
+	maxCompareSize  = 1 << 20
+	maxCompareEdits = 2000
+)
+
+// compareRun is one side of a comparison as rendered by compare.html.
+type compareRun struct {
+	Task    *taskstore.Task
+	Summary bool // a summary was recorded
+	Diff    bool // a diff was recorded
+}
+
+// compareRow is one line of a side-by-side comparison. Kind is "same",
+// "changed", "removed" (only on the left) or "added" (only on the right).
+type compareRow struct {
+	Left, Right string
+	Kind        string
+}
+
+// compareFile compares what two runs changed in one file. Status is
+// "identical", "different", "only left" or "only right".
+type compareFile struct {
+	Path   string
+	Status string
+	Rows   []compareRow
+}
+
+// CompareTasks renders the summaries and patches of two runs, typically a
+// task and its replay, side by side: GET /tasks/{id}/compare/{other}.
+func (h *Handler) CompareTasks(w http.ResponseWriter, r *http.Request) {
+	if h.store == nil {
+		http.Error(w, "task store unavailable", http.StatusServiceUnavailable)
+		return
+	}
+	vars := mux.Vars(r)
+	left, ok := h.store.Get(vars["id"])
+	if !ok {
+		http.NotFound(w, r)
+		return
+	}
+	right, ok := h.store.Get(vars["other"])
+	if !ok {
+		http.NotFound(w, r)
+		return
+	}
+
+	leftSummary, rightSummary := h.readArtifact(r.Context(), left.ID, artifacts.Summary), h.readArtifact(r.Context(), right.ID, artifacts.Summary)
+	leftDiff, rightDiff := h.readArtifact(r.Context(), left.ID, artifacts.Diff), h.readArtifact(r.Context(), right.ID, artifacts.Diff)
+	if err := h.templates.ExecuteTemplate(w, "compare.html", map[string]interface{}{
+		"Left":    compareRun{Task: left, Summary: leftSummary != "", Diff: leftDiff != ""},
+		"Right":   compareRun{Task: right, Summary: rightSummary != "", Diff: rightDiff != ""},
+		"Summary": sideBySide(splitLines(leftSummary), splitLines(rightSummary)),
+		"Files":   compareFiles(leftDiff, rightDiff),
+	}); err != nil {
+		http.Error(w, "template rendering error", http.StatusInternalServerError)
+	}
+}
+
+// readArtifact returns up to maxCompareSize bytes of artifact name of task
+// id; a missing artifact or storage reads as empty.
+func (h *Handler) readArtifact(ctx context.Context, id, name string) string {
+	if h.artifacts == nil {
+		return ""
+	}
+	rc, err := h.artifacts.Open(ctx, id, name)
+	if err != nil {
+		if !errors.Is(err, fs.ErrNotExist) && !errors.Is(err, fs.ErrInvalid) {
+			slog.WarnContext(ctx, "Opening artifact failed", logging.KeyTaskID, id, logging.KeyPhase, "web", "name", name, "err", err)
+		}
+		return ""
+	}
+	defer func() { _ = rc.Close() }()
+	data, err := io.ReadAll(io.LimitReader(rc, maxCompareSize))
+	if err != nil {
+		slog.WarnContext(ctx, "Reading artifact failed", logging.KeyTaskID, id, logging.KeyPhase, "web", "name", name, "err", err)
+	}
+	return string(data)
+}
+
+// compareFiles pairs the per-file sections of two patches by path, in the
+// order they appear, and compares each pair.
+func compareFiles(left, right string) []compareFile {
+	leftFiles, leftOrder := splitPatch(left)
+	rightFiles, rightOrder := splitPatch(right)
+	var files []compareFile
+	for _, path := range leftOrder {
+		l := leftFiles[path]
+		r, ok := rightFiles[path]
+		switch {
+		case !ok:
+			files = append(files, compareFile{Path: path, Status: "only left", Rows: sideBySide(l, nil)})
+		case strings.Join(l, "\n") == strings.Join(r, "\n"):
+			files = append(files, compareFile{Path: path, Status: "identical"})
+		default:
+			files = append(files, compareFile{Path: path, Status: "different", Rows: sideBySide(l, r)})
+		}
+	}
+	for _, path := range rightOrder {
+		if _, ok := leftFiles[path]; !ok {
+			files = append(files, compareFile{Path: path, Status: "only right", Rows: sideBySide(nil, rightFiles[path])})
+		}
+	}
+	return files
+}
+
+// splitPatch splits a git patch into the lines of each file's section,
+// keyed by the file's path, and returns the paths in patch order.
+func splitPatch(patch string) (map[string][]string, []string) {
+	files := make(map[string][]string)
+	var order []string
+	path := ""
+	for _, line := range splitLines(patch) {
+		if rest, ok := strings.CutPrefix(line, "diff --git "); ok {
+			path = rest
+			if _, b, ok := strings.Cut(rest, " b/"); ok {
+				path = b
+			}
+			if _, seen := files[path]; !seen {
+				order = append(order, path)
+			}
+		}
+		if path == "" {
+			continue // anything before the first file header
+		}
+		files[path] = append(files[path], line)
+	}
+	return files, order
+}
+
+func splitLines(s string) []string {
+	s = strings.TrimRight(s, "\n")
+	if s == "" {
+		return nil
+	}
+	return strings.Split(s, "\n")
+}
+
+// sideBySide lines up a and b: equal lines share a row, and each run of
+// changes pairs removed lines with added ones.
+func sideBySide(a, b []string) []compareRow {
+	var rows []compareRow
+	var removed, added []string
+	flush := func() {
+		for i := 0; i < max(len(removed), len(added)); i++ {
+			row := compareRow{Kind: "changed"}
+			switch {
+			case i >= len(added):
+				row = compareRow{Left: removed[i], Kind: "removed"}
+			case i >= len(removed):
+				row = compareRow{Right: added[i], Kind: "added"}
+			default:
+				row.Left, row.Right = removed[i], added[i]
+			}
+			rows = append(rows, row)
+		}
+		removed, added = removed[:0], added[:0]
+	}
+	for _, e := range diffLines(a, b) {
+		switch e.op {
+		case '-':
+			removed = append(removed, e.line)
+		case '+':
+			added = append(added, e.line)
+		default:
+			flush()
+			rows = append(rows, compareRow{Left: e.line, Right: e.line, Kind: "same"})
+		}
+	}
+	flush()
+	return rows
+}
+
+// lineEdit is one step of a line diff: op is ' ' (kept), '-' or '+'.
+type lineEdit struct {
+	op   byte
+	line string
+}
+
+// diffLines returns the shortest edit script turning a into b (Myers'
+// algorithm). Past maxCompareEdits edits it gives up and replaces a with b
+// wholesale.
+func diffLines(a, b []string) []lineEdit {
+	n, m := len(a), len(b)
+	offset := n + m + 1
+	v := make([]int, 2*offset+1)
+	var trace [][]int
+	for d := 0; d <= min(n+m, maxCompareEdits); d++ {
+		// the diagonals iteration d reads, k in [-d-1, d+1]
+		trace = append(trace, append([]int(nil), v[offset-d-1:offset+d+2]...))
+		for k := -d; k <= d; k += 2 {
+			var x int
+			if k == -d || (k != d && v[offset+k-1] < v[offset+k+1]) {
+				x = v[offset+k+1]
+			} else {
+				x = v[offset+k-1] + 1
+			}
+			y := x - k
+			for x < n && y < m && a[x] == b[y] {
+				x, y = x+1, y+1
+			}
+			v[offset+k] = x
+			if x >= n && y >= m {
+				return backtrack(a, b, trace)
+			}
+		}
+	}
+	edits := make([]lineEdit, 0, n+m)
+	for _, l := range a {
+		edits = append(edits, lineEdit{'-', l})
+	}
+	for _, l := range b {
+		edits = append(edits, lineEdit{'+', l})
+	}
+	return edits
+}
+
+// backtrack walks the saved diagonals of diffLines back from the end of a
+// and b to recover the edit script.
+func backtrack(a, b []string, trace [][]int) []lineEdit {
+	var edits []lineEdit
+	x, y := len(a), len(b)
+	for d := len(trace) - 1; d >= 0; d-- {
+		at := func(k int) int { return trace[d][k+d+1] }
+		k := x - y
+		prevK := k - 1
+		if k == -d || (k != d && at(k-1) < at(k+1)) {
+			prevK = k + 1
+		}
+		prevX := at(prevK)
+		prevY := prevX - prevK
+		for x > prevX && y > prevY {
+			x, y = x-1, y-1
+			edits = append(edits, lineEdit{' ', a[x]})
+		}
+		if d == 0 {
+			break
+		}
+		if x == prevX {
+			y--
+			edits = append(edits, lineEdit{'+', b[y]})
+		} else {
+			x--
+			edits = append(edits, lineEdit{'-', a[x]})
+		}
+	}
+	for i, j := 0, len(edits)-1; i < j; i, j = i+1, j-1 {
+		edits[i], edits[j] = edits[j], edits[i]
+	}
+	return edits
+}
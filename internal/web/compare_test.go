@@ -0,0 +1,82 @@
+package web
+
+import (
+	"context"
+	"net/http"
+	"net/http/httptest"
+	"path/filepath"
+	"reflect"
+	"strings"
+	"testing"
+
+	"github.com/gorilla/mux"
+
+	"github.com/cexll/swe/internal/artifacts"
+	"github.com/cexll/swe/internal/storage"
+	"github.com/cexll/swe/internal/taskstore"
+)
+
+func TestSideBySide(t *testing.T) {
+	rows := sideBySide(strings.Split("a b c d e", " "), strings.Split("a x c e f", " "))
+	want := []compareRow{
+		{Left: "a", Right: "a", Kind: "same"},
+		{Left: "b", Right: "x", Kind: "changed"},
+		{Left: "c", Right: "c", Kind: "same"},
+		{Left: "d", Kind: "removed"},
+		{Left: "e", Right: "e", Kind: "same"},
+		{Right: "f", Kind: "added"},
+	}
+	if !reflect.DeepEqual(rows, want) {
+		t.Fatalf("rows = %+v", rows)
+	}
+	if rows := sideBySide(nil, nil); len(rows) != 0 {
+		t.Fatalf("rows of nothing = %+v", rows)
+	}
+}
+
+func TestCompareFiles(t *testing.T) {
+	left := "diff --git a/same.go b/same.go\n+x\ndiff --git a/main.go b/main.go\n@@ -1 +1 @@\n-old\n+new\ndiff --git a/gone.txt b/gone.txt\n+g\n"
+	right := "diff --git a/main.go b/main.go\n@@ -1 +1 @@\n-old\n+newer\ndiff --git a/same.go b/same.go\n+x\ndiff --git a/new.txt b/new.txt\n+n\n"
+	var got []string
+	for _, f := range compareFiles(left, right) {
+		got = append(got, f.Path+": "+f.Status)
+	}
+	want := []string{"same.go: identical", "main.go: different", "gone.txt: only left", "new.txt: only right"}
+	if !reflect.DeepEqual(got, want) {
+		t.Fatalf("files = %q", got)
+	}
+}
+
+func TestHandler_CompareTasks(t *testing.T) {
+	tmpl, err := ParseTemplates(filepath.Join("..", "..", "templates", "*.html"))
+	if err != nil {
+		t.Fatalf("parse templates: %v", err)
+	}
+	store := taskstore.NewStore()
+	store.Create(&taskstore.Task{ID: "run-1", Title: "Fix flake", Status: taskstore.StatusFailed, Instruction: "fix the flaky test"})
+	store.Create(&taskstore.Task{ID: "run-2", Title: "Fix flake", Status: taskstore.StatusCompleted, Instruction: "fix it without sleeps", ReplayOf: "run-1"})
+	arts := artifacts.New(&storage.Local{Dir: t.TempDir()}, 0)
+	for id, summary := range map[string]string{"run-1": "Added a sleep.", "run-2": "Waited on the channel."} {
+		_ = arts.Save(context.Background(), id, artifacts.Summary, []byte(summary))
+	}
+	_ = arts.Save(context.Background(), "run-2", artifacts.Diff, []byte("diff --git a/a_test.go b/a_test.go\n+<-done\n"))
+	handler := &Handler{store: store, templates: tmpl}
+	handler.SetArtifacts(arts)
+
+	req := mux.SetURLVars(httptest.NewRequest(http.MethodGet, "/tasks/run-1/compare/run-2", nil), map[string]string{"id": "run-1", "other": "run-2"})
+	rr := httptest.NewRecorder()
+	handler.CompareTasks(rr, req)
+	body := rr.Body.String()
+	if rr.Code != http.StatusOK || !strings.Contains(body, "fix it without sleeps") ||
+		!strings.Contains(body, `<tr class="row-changed"><td class="left">Added a sleep.</td><td class="right">Waited on the channel.</td></tr>`) ||
+		!strings.Contains(body, `a_test.go<span class="file-note">only right</span>`) || !strings.Contains(body, "&#43;&lt;-done") {
+		t.Fatalf("compare page = %d:\n%s", rr.Code, body)
+	}
+
+	req = mux.SetURLVars(httptest.NewRequest(http.MethodGet, "/", nil), map[string]string{"id": "run-1", "other": "missing"})
+	rr = httptest.NewRecorder()
+	handler.CompareTasks(rr, req)
+	if rr.Code != http.StatusNotFound {
+		t.Fatalf("unknown task: status = %d", rr.Code)
+	}
+}
@@ -0,0 +1,40 @@
+package web
+
+import (
+	"encoding/json"
+	"net/http"
+
+	"github.com/gorilla/mux"
+)
+
+// GroupDetail shows a fan-out group: the prompt and every repository's task
+// with its status.
+func (h *Handler) GroupDetail(w http.ResponseWriter, r *http.Request) {
+	if h.store == nil {
+		http.Error(w, "task store unavailable", http.StatusServiceUnavailable)
+		return
+	}
+	group, ok := h.store.Group(mux.Vars(r)["id"])
+	if !ok {
+		http.NotFound(w, r)
+		return
+	}
+	if err := h.templates.ExecuteTemplate(w, "group.html", group); err != nil {
+		http.Error(w, "template rendering error", http.StatusInternalServerError)
+	}
+}
+
+// GroupStatus serves a fan-out group and its children's state as JSON.
+func (h *Handler) GroupStatus(w http.ResponseWriter, r *http.Request) {
+	if h.store == nil {
+		http.Error(w, "task store unavailable", http.StatusServiceUnavailable)
+		return
+	}
+	group, ok := h.store.Group(mux.Vars(r)["id"])
+	if !ok {
+		http.NotFound(w, r)
+		return
+	}
+	w.Header().Set("Content-Type", "application/json")
+	_ = json.NewEncoder(w).Encode(group)
+}
@@ -0,0 +1,62 @@
+package web
+
+import (
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"github.com/gorilla/mux"
+
+	"github.com/cexll/swe/internal/taskstore"
+)
+
+func TestHandler_Groups(t *testing.T) {
+	tmpl, err := ParseTemplates(filepath.Join("..", "..", "templates", "*.html"))
+	if err != nil {
+		t.Fatalf("parse templates: %v", err)
+	}
+	store := taskstore.NewStore()
+	store.Create(&taskstore.Task{ID: "task-1", RepoOwner: "acme", RepoName: "api", Status: taskstore.StatusRunning})
+	store.CreateGroup(&taskstore.Group{ID: "fanout-1", Title: "Bump lib", Prompt: "bump lib to v2", Actor: "ops"})
+	store.AddGroupChild("fanout-1", taskstore.GroupChild{Repo: "acme/api", Issue: 4, TaskID: "task-1"})
+	store.AddGroupChild("fanout-1", taskstore.GroupChild{Repo: "acme/web", Error: "repository not enabled"})
+	store.SealGroup("fanout-1")
+	handler := &Handler{store: store, templates: tmpl}
+
+	req := mux.SetURLVars(httptest.NewRequest(http.MethodGet, "/groups/fanout-1", nil), map[string]string{"id": "fanout-1"})
+	rr := httptest.NewRecorder()
+	handler.GroupDetail(rr, req)
+	page := rr.Body.String()
+	for _, want := range []string{"Bump lib", `<a href="/tasks/task-1">task-1</a>`, `https://github.com/acme/api/issues/4`,
+		"repository not enabled", `http-equiv="refresh"`} {
+		if !strings.Contains(page, want) {
+			t.Fatalf("group page lacks %q:\n%s", want, page)
+		}
+	}
+
+	req = mux.SetURLVars(httptest.NewRequest(http.MethodGet, "/api/v1/groups/fanout-1", nil), map[string]string{"id": "fanout-1"})
+	rr = httptest.NewRecorder()
+	handler.GroupStatus(rr, req)
+	var got taskstore.GroupStatus
+	if err := json.Unmarshal(rr.Body.Bytes(), &got); err != nil {
+		t.Fatalf("decode: %v\n%s", err, rr.Body.String())
+	}
+	if got.ID != "fanout-1" || got.Done || len(got.Children) != 2 || got.Counts[taskstore.StatusRunning] != 1 || got.Counts[taskstore.StatusFailed] != 1 {
+		t.Fatalf("group = %s", rr.Body.String())
+	}
+
+	req = mux.SetURLVars(httptest.NewRequest(http.MethodGet, "/groups/nope", nil), map[string]string{"id": "nope"})
+	rr = httptest.NewRecorder()
+	handler.GroupDetail(rr, req)
+	if rr.Code != http.StatusNotFound {
+		t.Fatalf("unknown group: status = %d", rr.Code)
+	}
+	rr = httptest.NewRecorder()
+	(&Handler{}).GroupStatus(rr, req)
+	if rr.Code != http.StatusServiceUnavailable {
+		t.Fatalf("without a store: status = %d", rr.Code)
+	}
+}
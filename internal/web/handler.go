@@ -1,21 +1,61 @@
 package web
 
 import (
+	"encoding/json"
+	"fmt"
 	"html/template"
 	"net/http"
+	"net/url"
+	"strconv"
+	"strings"
+	"time"
 
 	"github.com/gorilla/mux"
 
+	"github.com/cexll/swe/internal/artifacts"
+	"github.com/cexll/swe/internal/audit"
+	"github.com/cexll/swe/internal/budget"
+	"github.com/cexll/swe/internal/delivery"
+	"github.com/cexll/swe/internal/dispatcher"
+	"github.com/cexll/swe/internal/executor"
+	"github.com/cexll/swe/internal/schedule"
+	"github.com/cexll/swe/internal/share"
 	"github.com/cexll/swe/internal/taskstore"
+	"github.com/cexll/swe/internal/version"
 )
 
+// StatsSource reports dispatcher health for the admin dashboard.
+type StatsSource interface {
+	Stats() dispatcher.Stats
+}
+
+// WorkspaceSource reports the disk used by task checkouts for the admin
+// dashboard.
+type WorkspaceSource interface {
+	WorkspaceUsage() executor.WorkspaceUsage
+}
+
 type Handler struct {
-	store     *taskstore.Store
-	templates *template.Template
+	store      *taskstore.Store
+	templates  *template.Template
+	stats      StatsSource
+	workspaces WorkspaceSource
+	audit      *audit.Log
+	deliveries *delivery.Store
+	signer     *share.Signer
+	redactor   *share.Redactor
+	artifacts  *artifacts.Store
+	logs       *artifacts.Store
+	apiToken   string // guards the operator endpoints ("" disables them)
+	// adminPublic serves the admin dashboard, stats and schedules without
+	// the operator token
+	adminPublic bool
+	scheduler   *schedule.Scheduler
+	budgets     *budget.Tracker
 }
 
 func NewHandler(store *taskstore.Store) (*Handler, error) {
-	tmpl, err := template.ParseGlob("templates/*.html")
+	tmpl, err := ParseTemplates("templates/*.html")
 	if err != nil {
 		return nil, err
 	}
@@ -25,15 +65,61 @@ func NewHandler(store *taskstore.Store) (*Handler, error) {
 	}, nil
 }
 
-func (h *Handler) ListTasks(w http.ResponseWriter, _ *http.Request) {
+// ParseTemplates parses the UI templates matching pattern along with the
+// functions they use, such as the running version shown in page footers.
+func ParseTemplates(pattern string) (*template.Template, error) {
+	return template.New("").Funcs(template.FuncMap{"version": version.Short, "size": formatSize}).ParseGlob(pattern)
+}
+
+// SetStatsSource wires the dispatcher used by the admin dashboard.
+func (h *Handler) SetStatsSource(src StatsSource) {
+	h.stats = src
+}
+
+// SetWorkspaceSource wires the executor whose checkouts the admin dashboard
+// shows.
+func (h *Handler) SetWorkspaceSource(src WorkspaceSource) {
+	h.workspaces = src
+}
+
+// SetAuditLog wires the audit log shown by the audit viewer.
+func (h *Handler) SetAuditLog(l *audit.Log) {
+	h.audit = l
+}
+
+// SetDeliveryStore wires the webhook delivery history served by Deliveries.
+func (h *Handler) SetDeliveryStore(s *delivery.Store) {
+	h.deliveries = s
+}
+
+func (h *Handler) ListTasks(w http.ResponseWriter, r *http.Request) {
 	if h.store == nil {
 		http.Error(w, "task store unavailable", http.StatusServiceUnavailable)
 		return
 	}
-	tasks := h.store.List()
-	if err := h.templates.ExecuteTemplate(w, "list.html", map[string]interface{}{
-		"Tasks": tasks,
-	}); err != nil {
+	params := r.URL.Query()
+	query, err := parseTaskQuery(params)
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusBadRequest)
+		return
+	}
+	result := h.store.Query(query)
+
+	data := map[string]interface{}{
+		"Tasks":      result.Tasks,
+		"Total":      result.Total,
+		"Page":       result.Page,
+		"TotalPages": result.TotalPages(),
+		"Filters":    filterValues(params),
+		"Statuses":   []taskstore.TaskStatus{taskstore.StatusPending, taskstore.StatusRunning, taskstore.StatusCompleted, taskstore.StatusFailed},
+	}
+	if result.HasPrev() {
+		data["PrevURL"] = pageURL(params, result.Page-1)
+	}
+	if result.HasNext() {
+		data["NextURL"] = pageURL(params, result.Page+1)
+	}
+	if err := h.templates.ExecuteTemplate(w, "list.html", data); err != nil {
 		http.Error(w, "template rendering error", http.StatusInternalServerError)
 	}
 }
@@ -53,8 +139,242 @@ func (h *Handler) TaskDetail(w http.ResponseWriter, r *http.Request) {
 	}
 
 	if err := h.templates.ExecuteTemplate(w, "detail.html", map[string]interface{}{
-		"Task": task,
+		"Task":      task,
+		"Artifacts": h.taskArtifacts(r, taskID),
+		"Chain":     h.store.Chain(taskID),
 	}); err != nil {
 		http.Error(w, "template rendering error", http.StatusInternalServerError)
 	}
 }
+
+// dateLayout is the format accepted by the from/to list filters.
+const dateLayout = "2006-01-02"
+
+// parseTaskQuery converts list query parameters into a taskstore.Query.
+// Supported: repo, status, user, from, to (YYYY-MM-DD, inclusive), q, group, sort, order, page, per_page.
+func parseTaskQuery(params url.Values) (taskstore.Query, error) {
+	q := taskstore.Query{
+		Repo:   params.Get("repo"),
+		Status: taskstore.TaskStatus(strings.TrimSpace(params.Get("status"))),
+		Actor:  params.Get("user"),
+		Search: params.Get("q"),
+		Group:  params.Get("group"),
+		SortBy: params.Get("sort"),
+	}
+	q.SortAsc = strings.EqualFold(params.Get("order"), "asc")
+
+	if v := strings.TrimSpace(params.Get("from")); v != "" {
+		t, err := time.ParseInLocation(dateLayout, v, time.Local)
+		if err != nil {
+			return q, errInvalidParam("from")
+		}
+		q.Since = t
+	}
+	if v := strings.TrimSpace(params.Get("to")); v != "" {
+		t, err := time.ParseInLocation(dateLayout, v, time.Local)
+		if err != nil {
+			return q, errInvalidParam("to")
+		}
+		// "to" is inclusive: include the whole day
+		q.Until = t.AddDate(0, 0, 1)
+	}
+	if v := strings.TrimSpace(params.Get("page")); v != "" {
+		n, err := strconv.Atoi(v)
+		if err != nil || n <= 0 {
+			return q, errInvalidParam("page")
+		}
+		q.Page = n
+	}
+	if v := strings.TrimSpace(params.Get("per_page")); v != "" {
+		n, err := strconv.Atoi(v)
+		if err != nil || n <= 0 {
+			return q, errInvalidParam("per_page")
+		}
+		q.PageSize = n
+	}
+	return q, nil
+}
+
+func errInvalidParam(name string) error {
+	return fmt.Errorf("invalid %s parameter", name)
+}
+
+// filterValues echoes the current filters back to the template so the form keeps its state.
+func filterValues(params url.Values) map[string]string {
+	keys := []string{"repo", "status", "user", "from", "to", "q", "group", "sort", "order", "per_page"}
+	values := make(map[string]string, len(keys))
+	for _, k := range keys {
+		values[k] = params.Get(k)
+	}
+	return values
+}
+
+// pageURL returns the list URL for the given page, preserving active filters.
+func pageURL(params url.Values, page int) string {
+	next := url.Values{}
+	for k, v := range params {
+		next[k] = v
+	}
+	next.Set("page", strconv.Itoa(page))
+	return "/tasks?" + next.Encode()
+}
+
+// adminSnapshot is the payload shared by the admin page and its JSON API.
+type adminSnapshot struct {
+	Dispatcher dispatcher.Stats             `json:"dispatcher"`
+	Tasks      map[taskstore.TaskStatus]int `json:"tasks"`
+	Schedules  []schedule.Status            `json:"schedules,omitempty"`
+	Workspaces *executor.WorkspaceUsage     `json:"workspaces,omitempty"`
+}
+
+func (h *Handler) adminSnapshot() adminSnapshot {
+	snap := adminSnapshot{Tasks: map[taskstore.TaskStatus]int{}}
+	if h.stats != nil {
+		snap.Dispatcher = h.stats.Stats()
+	}
+	if h.store != nil {
+		snap.Tasks = h.store.CountByStatus()
+	}
+	if h.workspaces != nil {
+		usage := h.workspaces.WorkspaceUsage()
+		snap.Workspaces = &usage
+	}
+	if h.scheduler != nil {
+		snap.Schedules = h.scheduler.Status()
+	}
+	return snap
+}
+
+// SetAdminPublic serves the admin dashboard, stats and schedules to anyone,
+// not only to requests with the operator token.
+func (h *Handler) SetAdminPublic(public bool) {
+	h.adminPublic = public
+}
+
+// AdminDashboard renders queue depth, worker activity, retries and error
+// rates. It needs the operator token unless the dashboard is public.
+func (h *Handler) AdminDashboard(w http.ResponseWriter, r *http.Request) {
+	if !h.adminPublic && !h.operatorOnly(w, r, "admin dashboard") {
+		return
+	}
+	if h.stats == nil {
+		http.Error(w, "dispatcher stats unavailable", http.StatusServiceUnavailable)
+		return
+	}
+	if err := h.templates.ExecuteTemplate(w, "admin.html", map[string]interface{}{
+		"Snapshot": h.adminSnapshot(),
+	}); err != nil {
+		http.Error(w, "template rendering error", http.StatusInternalServerError)
+	}
+}
+
+// AdminStats returns the admin snapshot as JSON for scripts and monitoring,
+// with the same access as AdminDashboard.
+func (h *Handler) AdminStats(w http.ResponseWriter, r *http.Request) {
+	if !h.adminPublic && !h.operatorOnly(w, r, "admin dashboard") {
+		return
+	}
+	if h.stats == nil {
+		http.Error(w, "dispatcher stats unavailable", http.StatusServiceUnavailable)
+		return
+	}
+	w.Header().Set("Content-Type", "application/json")
+	_ = json.NewEncoder(w).Encode(h.adminSnapshot())
+}
+
+// auditViewLimit caps the number of entries rendered by the audit viewer.
+const auditViewLimit = 500
+
+func auditFilter(params url.Values) audit.Filter {
+	return audit.Filter{
+		Repo:   strings.TrimSpace(params.Get("repo")),
+		Actor:  strings.TrimSpace(params.Get("user")),
+		Action: audit.Action(strings.TrimSpace(params.Get("action"))),
+		TaskID: strings.TrimSpace(params.Get("task")),
+	}
+}
+
+// AuditLog renders the most recent audit entries with optional filters, for
+// operators holding API_TOKEN.
+func (h *Handler) AuditLog(w http.ResponseWriter, r *http.Request) {
+	if !h.operatorOnly(w, r, "audit log") {
+		return
+	}
+	if h.audit == nil {
+		http.Error(w, "audit log unavailable", http.StatusServiceUnavailable)
+		return
+	}
+	params := r.URL.Query()
+	filter := auditFilter(params)
+	filter.Limit = auditViewLimit
+	exportURL := "/audit/export"
+	if enc := params.Encode(); enc != "" {
+		exportURL += "?" + enc
+	}
+	if err := h.templates.ExecuteTemplate(w, "audit.html", map[string]interface{}{
+		"Events":    h.audit.List(filter),
+		"Filters":   map[string]string{"repo": filter.Repo, "user": filter.Actor, "action": string(filter.Action), "task": filter.TaskID},
+		"ExportURL": exportURL,
+	}); err != nil {
+		http.Error(w, "template rendering error", http.StatusInternalServerError)
+	}
+}
+
+// AuditExport streams matching audit entries as JSON lines.
+func (h *Handler) AuditExport(w http.ResponseWriter, r *http.Request) {
+	if !h.operatorOnly(w, r, "audit log") {
+		return
+	}
+	if h.audit == nil {
+		http.Error(w, "audit log unavailable", http.StatusServiceUnavailable)
+		return
+	}
+	w.Header().Set("Content-Type", "application/x-ndjson")
+	w.Header().Set("Content-Disposition", `attachment; filename="audit.jsonl"`)
+	if err := h.audit.Export(w, auditFilter(r.URL.Query())); err != nil {
+		http.Error(w, "audit export failed", http.StatusInternalServerError)
+	}
+}
+
+// Delivery listing limits for the deliveries API.
+const (
+	defaultDeliveryLimit = 100
+	maxDeliveryLimit     = 1000
+)
+
+// Deliveries lists recent webhook deliveries and their outcomes as JSON,
+// for operators holding API_TOKEN. Supports repo, event, outcome and limit
+// query parameters.
+func (h *Handler) Deliveries(w http.ResponseWriter, r *http.Request) {
+	if !h.operatorOnly(w, r, "deliveries API") {
+		return
+	}
+	if h.deliveries == nil {
+		http.Error(w, "delivery tracking unavailable", http.StatusServiceUnavailable)
+		return
+	}
+	params := r.URL.Query()
+	filter := delivery.Filter{
+		Repo:    strings.TrimSpace(params.Get("repo")),
+		Event:   strings.TrimSpace(params.Get("event")),
+		Outcome: strings.TrimSpace(params.Get("outcome")),
+		Limit:   defaultDeliveryLimit,
+	}
+	if raw := strings.TrimSpace(params.Get("limit")); raw != "" {
+		n, err := strconv.Atoi(raw)
+		if err != nil || n <= 0 {
+			http.Error(w, errInvalidParam("limit").Error(), http.StatusBadRequest)
+			return
+		}
+		if n > maxDeliveryLimit {
+			n = maxDeliveryLimit
+		}
+		filter.Limit = n
+	}
+	records := h.deliveries.List(filter)
+	w.Header().Set("Content-Type", "application/json")
+	_ = json.NewEncoder(w).Encode(map[string]interface{}{
+		"deliveries": records,
+		"count":      len(records),
+	})
+}
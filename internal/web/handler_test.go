@@ -1,6 +1,7 @@
 package web
 
 import (
+	"encoding/json"
 	"html/template"
 	"net/http"
 	"net/http/httptest"
@@ -8,9 +9,14 @@ import (
 	"path/filepath"
 	"strings"
 	"testing"
+	"time"
 
 	"github.com/gorilla/mux"
 
+	"github.com/cexll/swe/internal/audit"
+	"github.com/cexll/swe/internal/delivery"
+	"github.com/cexll/swe/internal/dispatcher"
+	"github.com/cexll/swe/internal/executor"
 	"github.com/cexll/swe/internal/taskstore"
 )
 
@@ -189,3 +195,365 @@ func TestHandler_TaskDetail_Success(t *testing.T) {
 		t.Fatalf("body = %q, want task-123", rr.Body.String())
 	}
 }
+
+func TestHandler_TaskDetail_RendersChain(t *testing.T) {
+	tmpl, err := ParseTemplates(filepath.Join("..", "..", "templates", "*.html"))
+	if err != nil {
+		t.Fatalf("parse templates: %v", err)
+	}
+	store := taskstore.NewStore()
+	store.Create(&taskstore.Task{ID: "plan", Title: "Plan it", Status: taskstore.StatusCompleted})
+	store.Create(&taskstore.Task{ID: "implement", Title: "Implement it", Status: taskstore.StatusPending, DependsOn: []string{"plan"}})
+	handler := &Handler{store: store, templates: tmpl}
+
+	req := mux.SetURLVars(httptest.NewRequest(http.MethodGet, "/tasks/plan", nil), map[string]string{"id": "plan"})
+	rr := httptest.NewRecorder()
+	handler.TaskDetail(rr, req)
+
+	if rr.Code != http.StatusOK {
+		t.Fatalf("status = %d, want %d: %s", rr.Code, http.StatusOK, rr.Body.String())
+	}
+	body := rr.Body.String()
+	for _, want := range []string{`<h2>Chain</h2>`, `class="chain-current"`, `<a href="/tasks/implement">Implement it</a>`, `after <a href="/tasks/plan">plan</a>`} {
+		if !strings.Contains(body, want) {
+			t.Fatalf("detail page lacks %q:\n%s", want, body)
+		}
+	}
+}
+
+func TestHandler_ListTasks_FiltersAndPagination(t *testing.T) {
+	store := taskstore.NewStore()
+	store.Create(&taskstore.Task{ID: "task-a", RepoOwner: "acme", RepoName: "api", Status: taskstore.StatusFailed})
+	store.Create(&taskstore.Task{ID: "task-b", RepoOwner: "acme", RepoName: "api", Status: taskstore.StatusFailed})
+	store.Create(&taskstore.Task{ID: "task-c", RepoOwner: "acme", RepoName: "web", Status: taskstore.StatusCompleted})
+
+	handler := &Handler{
+		store:     store,
+		templates: newTemplates("{{range .Tasks}}{{.ID}};{{end}}|{{.Total}}|{{.NextURL}}", "{{.Task.ID}}", t),
+	}
+
+	req := httptest.NewRequest(http.MethodGet, "/tasks?repo=acme/api&status=failed&per_page=1", nil)
+	rr := httptest.NewRecorder()
+
+	handler.ListTasks(rr, req)
+
+	if rr.Code != http.StatusOK {
+		t.Fatalf("status = %d, want %d", rr.Code, http.StatusOK)
+	}
+	body := rr.Body.String()
+	if strings.Contains(body, "task-c") {
+		t.Fatalf("body = %q, should not include filtered task", body)
+	}
+	if !strings.Contains(body, "|2|") {
+		t.Fatalf("body = %q, want total of 2", body)
+	}
+	if !strings.Contains(body, "page=2") || !strings.Contains(body, "repo=acme%2Fapi") {
+		t.Fatalf("body = %q, want next page URL preserving filters", body)
+	}
+}
+
+func TestHandler_ListTasks_InvalidParams(t *testing.T) {
+	handler := &Handler{
+		store:     taskstore.NewStore(),
+		templates: newTemplates("ok", "{{.Task.ID}}", t),
+	}
+
+	for _, rawQuery := range []string{"from=yesterday", "to=2025-13-01", "page=0", "per_page=abc"} {
+		req := httptest.NewRequest(http.MethodGet, "/tasks?"+rawQuery, nil)
+		rr := httptest.NewRecorder()
+
+		handler.ListTasks(rr, req)
+
+		if rr.Code != http.StatusBadRequest {
+			t.Fatalf("%s: status = %d, want %d", rawQuery, rr.Code, http.StatusBadRequest)
+		}
+	}
+}
+
+func TestHandler_ListTasks_RendersRepoTemplate(t *testing.T) {
+	tmpl, err := ParseTemplates(filepath.Join("..", "..", "templates", "*.html"))
+	if err != nil {
+		t.Fatalf("parse templates: %v", err)
+	}
+	store := taskstore.NewStore()
+	store.Create(&taskstore.Task{ID: "task-1", Title: "demo", Status: taskstore.StatusPending})
+	handler := &Handler{store: store, templates: tmpl}
+
+	req := httptest.NewRequest(http.MethodGet, "/tasks?status=pending&sort=updated", nil)
+	rr := httptest.NewRecorder()
+
+	handler.ListTasks(rr, req)
+
+	if rr.Code != http.StatusOK {
+		t.Fatalf("status = %d, want %d: %s", rr.Code, http.StatusOK, rr.Body.String())
+	}
+	if !strings.Contains(rr.Body.String(), "/tasks/task-1") {
+		t.Fatalf("rendered list missing task link")
+	}
+}
+
+type stubStats struct {
+	stats dispatcher.Stats
+}
+
+func (s stubStats) Stats() dispatcher.Stats { return s.stats }
+
+type stubWorkspaces executor.WorkspaceUsage
+
+func (s stubWorkspaces) WorkspaceUsage() executor.WorkspaceUsage { return executor.WorkspaceUsage(s) }
+
+func TestHandler_Admin_NoStatsSource(t *testing.T) {
+	handler := &Handler{
+		store:       taskstore.NewStore(),
+		templates:   newTemplates("ok", "ok", t),
+		adminPublic: true,
+	}
+
+	for _, fn := range []http.HandlerFunc{handler.AdminDashboard, handler.AdminStats} {
+		rr := httptest.NewRecorder()
+		fn(rr, httptest.NewRequest(http.MethodGet, "/admin", nil))
+		if rr.Code != http.StatusServiceUnavailable {
+			t.Fatalf("status = %d, want %d", rr.Code, http.StatusServiceUnavailable)
+		}
+	}
+}
+
+func TestHandler_Admin_RequiresOperatorToken(t *testing.T) {
+	handler := &Handler{store: taskstore.NewStore(), templates: newTemplates("ok", "ok", t)}
+	handler.SetStatsSource(stubStats{})
+
+	for _, fn := range []http.HandlerFunc{handler.AdminDashboard, handler.AdminStats} {
+		rr := httptest.NewRecorder()
+		fn(rr, httptest.NewRequest(http.MethodGet, "/admin", nil))
+		if rr.Code != http.StatusServiceUnavailable {
+			t.Fatalf("without API_TOKEN: status = %d, want 503", rr.Code)
+		}
+	}
+	handler.SetAPIToken("op-token")
+	for _, fn := range []http.HandlerFunc{handler.AdminDashboard, handler.AdminStats} {
+		req := httptest.NewRequest(http.MethodGet, "/admin", nil)
+		req.Header.Set("Authorization", "Bearer wrong")
+		rr := httptest.NewRecorder()
+		fn(rr, req)
+		if rr.Code != http.StatusUnauthorized {
+			t.Fatalf("wrong token: status = %d, want 401", rr.Code)
+		}
+	}
+	handler.SetAdminPublic(true)
+	rr := httptest.NewRecorder()
+	handler.AdminStats(rr, httptest.NewRequest(http.MethodGet, "/admin/api/stats", nil))
+	if rr.Code != http.StatusOK {
+		t.Fatalf("public: status = %d, want 200", rr.Code)
+	}
+}
+
+func TestHandler_AdminStats_JSON(t *testing.T) {
+	store := taskstore.NewStore()
+	store.Create(&taskstore.Task{ID: "a", Status: taskstore.StatusFailed})
+	store.Create(&taskstore.Task{ID: "b", Status: taskstore.StatusFailed})
+
+	handler := &Handler{store: store}
+	handler.SetStatsSource(stubStats{stats: dispatcher.Stats{QueueDepth: 3, Workers: 4, ActiveWorkers: 2}})
+	handler.SetWorkspaceSource(stubWorkspaces{Dir: "/tmp/ws", Workspaces: 2, Bytes: 3 << 20})
+	handler.SetAPIToken("op-token")
+
+	req := httptest.NewRequest(http.MethodGet, "/admin/api/stats", nil)
+	req.Header.Set("Authorization", "Bearer op-token")
+	rr := httptest.NewRecorder()
+	handler.AdminStats(rr, req)
+
+	if rr.Code != http.StatusOK {
+		t.Fatalf("status = %d, want %d", rr.Code, http.StatusOK)
+	}
+	var got struct {
+		Dispatcher dispatcher.Stats `json:"dispatcher"`
+		Tasks      map[string]int   `json:"tasks"`
+		Workspaces struct {
+			Workspaces int   `json:"workspaces"`
+			Bytes      int64 `json:"bytes"`
+		} `json:"workspaces"`
+	}
+	if err := json.Unmarshal(rr.Body.Bytes(), &got); err != nil {
+		t.Fatalf("invalid JSON: %v", err)
+	}
+	if got.Dispatcher.QueueDepth != 3 || got.Dispatcher.ActiveWorkers != 2 {
+		t.Fatalf("unexpected dispatcher stats: %+v", got.Dispatcher)
+	}
+	if got.Tasks["failed"] != 2 {
+		t.Fatalf("tasks = %v, want failed=2", got.Tasks)
+	}
+	if got.Workspaces.Workspaces != 2 || got.Workspaces.Bytes != 3<<20 {
+		t.Fatalf("workspaces = %+v", got.Workspaces)
+	}
+}
+
+func TestHandler_AdminDashboard_RendersRepoTemplate(t *testing.T) {
+	tmpl, err := ParseTemplates(filepath.Join("..", "..", "templates", "*.html"))
+	if err != nil {
+		t.Fatalf("parse templates: %v", err)
+	}
+	handler := &Handler{store: taskstore.NewStore(), templates: tmpl}
+	handler.SetStatsSource(stubStats{stats: dispatcher.Stats{
+		Workers:        1,
+		WorkerStatus:   []dispatcher.WorkerStatus{{ID: 0, Busy: true, TaskID: "task-1", TaskKey: "o/r#1", Attempt: 1, StartedAt: time.Now()}},
+		PendingRetries: []dispatcher.RetryStatus{{TaskID: "task-2", TaskKey: "o/r#2", NextAttempt: 2, LastError: "boom"}},
+		Providers:      []dispatcher.ProviderStats{{Name: "claude", Errors: 1, ErrorRate: 0.5}},
+	}})
+	handler.SetWorkspaceSource(stubWorkspaces{Workspaces: 1, Bytes: 1536 << 20, MaxBytes: 10 << 30})
+	handler.SetAdminPublic(true)
+
+	rr := httptest.NewRecorder()
+	handler.AdminDashboard(rr, httptest.NewRequest(http.MethodGet, "/admin", nil))
+
+	if rr.Code != http.StatusOK {
+		t.Fatalf("status = %d, want %d: %s", rr.Code, http.StatusOK, rr.Body.String())
+	}
+	body := rr.Body.String()
+	for _, want := range []string{"o/r#1", "o/r#2", "boom", "claude", "Workspaces (1)", "1.5 GB / 10.0 GB"} {
+		if !strings.Contains(body, want) {
+			t.Fatalf("rendered dashboard missing %q", want)
+		}
+	}
+}
+
+func TestHandler_Audit_RequiresOperatorToken(t *testing.T) {
+	log, _ := audit.New(audit.Config{})
+	handler := &Handler{templates: newTemplates("ok", "ok", t)}
+	handler.SetAuditLog(log)
+
+	for _, fn := range []http.HandlerFunc{handler.AuditLog, handler.AuditExport} {
+		rr := httptest.NewRecorder()
+		fn(rr, httptest.NewRequest(http.MethodGet, "/audit", nil))
+		if rr.Code != http.StatusServiceUnavailable {
+			t.Fatalf("without API_TOKEN: status = %d, want 503", rr.Code)
+		}
+	}
+	handler.SetAPIToken("op-token")
+	for _, auth := range []string{"", "Bearer wrong"} {
+		for _, fn := range []http.HandlerFunc{handler.AuditLog, handler.AuditExport} {
+			req := httptest.NewRequest(http.MethodGet, "/audit", nil)
+			if auth != "" {
+				req.Header.Set("Authorization", auth)
+			}
+			rr := httptest.NewRecorder()
+			fn(rr, req)
+			if rr.Code != http.StatusUnauthorized || rr.Header().Get("WWW-Authenticate") == "" {
+				t.Fatalf("authorization %q: status = %d, want 401", auth, rr.Code)
+			}
+		}
+	}
+}
+
+func TestHandler_Audit_Unavailable(t *testing.T) {
+	handler := &Handler{templates: newTemplates("ok", "ok", t), apiToken: "op-token"}
+	for _, fn := range []http.HandlerFunc{handler.AuditLog, handler.AuditExport} {
+		req := httptest.NewRequest(http.MethodGet, "/audit", nil)
+		req.Header.Set("Authorization", "Bearer op-token")
+		rr := httptest.NewRecorder()
+		fn(rr, req)
+		if rr.Code != http.StatusServiceUnavailable {
+			t.Fatalf("status = %d, want %d", rr.Code, http.StatusServiceUnavailable)
+		}
+	}
+}
+
+func TestHandler_AuditViewerAndExport(t *testing.T) {
+	tmpl, err := ParseTemplates(filepath.Join("..", "..", "templates", "*.html"))
+	if err != nil {
+		t.Fatalf("parse templates: %v", err)
+	}
+	log, _ := audit.New(audit.Config{})
+	_ = log.Record(audit.Event{Action: audit.ActionPermission, Actor: "alice", Repo: "acme/api", Decision: audit.DecisionDenied})
+	_ = log.Record(audit.Event{Action: audit.ActionTaskQueued, Actor: "bob", Repo: "acme/web", TaskID: "task-9"})
+
+	handler := &Handler{templates: tmpl}
+	handler.SetAuditLog(log)
+	handler.SetAPIToken("op-token")
+	get := func(target string) *http.Request {
+		req := httptest.NewRequest(http.MethodGet, target, nil)
+		req.Header.Set("Authorization", "Bearer op-token")
+		return req
+	}
+
+	rr := httptest.NewRecorder()
+	handler.AuditLog(rr, get("/audit?user=alice"))
+	if rr.Code != http.StatusOK {
+		t.Fatalf("status = %d, want %d: %s", rr.Code, http.StatusOK, rr.Body.String())
+	}
+	body := rr.Body.String()
+	if !strings.Contains(body, "decision-denied") || strings.Contains(body, "task-9") {
+		t.Fatalf("viewer should show only alice's entries")
+	}
+
+	rr = httptest.NewRecorder()
+	handler.AuditExport(rr, get("/audit/export?repo=acme/web"))
+	if rr.Code != http.StatusOK {
+		t.Fatalf("export status = %d", rr.Code)
+	}
+	if got := strings.TrimSpace(rr.Body.String()); strings.Count(got, "\n") != 0 || !strings.Contains(got, `"task_id":"task-9"`) {
+		t.Fatalf("export = %q, want single task-9 line", got)
+	}
+}
+
+func TestHandler_Deliveries(t *testing.T) {
+	handler := &Handler{templates: newTemplates("ok", "ok", t)}
+	get := func(target string) *http.Request {
+		req := httptest.NewRequest(http.MethodGet, target, nil)
+		req.Header.Set("Authorization", "Bearer op-token")
+		return req
+	}
+	rr := httptest.NewRecorder()
+	handler.Deliveries(rr, get("/api/v1/deliveries"))
+	if rr.Code != http.StatusServiceUnavailable || !strings.Contains(rr.Body.String(), "API_TOKEN") {
+		t.Fatalf("without API_TOKEN: status = %d, want 503", rr.Code)
+	}
+	handler.SetAPIToken("op-token")
+	rr = httptest.NewRecorder()
+	handler.Deliveries(rr, httptest.NewRequest(http.MethodGet, "/api/v1/deliveries", nil))
+	if rr.Code != http.StatusUnauthorized {
+		t.Fatalf("without a token: status = %d, want 401", rr.Code)
+	}
+	rr = httptest.NewRecorder()
+	handler.Deliveries(rr, get("/api/v1/deliveries"))
+	if rr.Code != http.StatusServiceUnavailable {
+		t.Fatalf("status = %d, want %d", rr.Code, http.StatusServiceUnavailable)
+	}
+
+	store, _ := delivery.New(delivery.Config{})
+	for _, rec := range []delivery.Record{
+		{ID: "d1", Event: "issue_comment", Repo: "acme/api", Status: 202, Outcome: "Task queued"},
+		{ID: "d2", Event: "issue_comment", Repo: "acme/web", Status: 200, Outcome: "Permission denied"},
+	} {
+		store.Begin(rec.ID, rec.Event)
+		_ = store.Complete(rec)
+	}
+	handler.SetDeliveryStore(store)
+
+	rr = httptest.NewRecorder()
+	handler.Deliveries(rr, get("/api/v1/deliveries?repo=acme/web"))
+	if rr.Code != http.StatusOK {
+		t.Fatalf("status = %d: %s", rr.Code, rr.Body.String())
+	}
+	var resp struct {
+		Deliveries []delivery.Record `json:"deliveries"`
+		Count      int               `json:"count"`
+	}
+	if err := json.Unmarshal(rr.Body.Bytes(), &resp); err != nil {
+		t.Fatalf("decode: %v", err)
+	}
+	if resp.Count != 1 || resp.Deliveries[0].ID != "d2" || resp.Deliveries[0].Outcome != "Permission denied" {
+		t.Fatalf("unexpected response: %+v", resp)
+	}
+
+	rr = httptest.NewRecorder()
+	handler.Deliveries(rr, get("/api/v1/deliveries?limit=1"))
+	if err := json.Unmarshal(rr.Body.Bytes(), &resp); err != nil || resp.Count != 1 || resp.Deliveries[0].ID != "d2" {
+		t.Fatalf("limit=1 response: %+v (%v)", resp, err)
+	}
+
+	rr = httptest.NewRecorder()
+	handler.Deliveries(rr, get("/api/v1/deliveries?limit=abc"))
+	if rr.Code != http.StatusBadRequest {
+		t.Fatalf("invalid limit status = %d", rr.Code)
+	}
+}
@@ -0,0 +1,74 @@
+package web
+
+import (
+	"encoding/json"
+	"errors"
+	"net/http"
+
+	"github.com/gorilla/mux"
+
+	"github.com/cexll/swe/internal/schedule"
+)
+
+// SetScheduler wires the scheduled jobs shown on the admin page and served
+// by the schedules API.
+func (h *Handler) SetScheduler(s *schedule.Scheduler) {
+	h.scheduler = s
+}
+
+// SchedulesPage renders the scheduled jobs with their next and last runs
+// and a button starting each one now, with the same access as
+// AdminDashboard.
+func (h *Handler) SchedulesPage(w http.ResponseWriter, r *http.Request) {
+	if !h.adminPublic && !h.operatorOnly(w, r, "schedules page") {
+		return
+	}
+	if h.scheduler == nil {
+		http.Error(w, "no scheduled jobs (SCHEDULES_FILE not set)", http.StatusServiceUnavailable)
+		return
+	}
+	if err := h.templates.ExecuteTemplate(w, "schedules.html", map[string]interface{}{
+		"Schedules": h.scheduler.Status(),
+	}); err != nil {
+		http.Error(w, "template rendering error", http.StatusInternalServerError)
+	}
+}
+
+// Schedules lists the scheduled jobs with their next and last runs, with
+// the same access as AdminDashboard.
+func (h *Handler) Schedules(w http.ResponseWriter, r *http.Request) {
+	if !h.adminPublic && !h.operatorOnly(w, r, "schedules API") {
+		return
+	}
+	if h.scheduler == nil {
+		http.Error(w, "no scheduled jobs (SCHEDULES_FILE not set)", http.StatusServiceUnavailable)
+		return
+	}
+	w.Header().Set("Content-Type", "application/json")
+	_ = json.NewEncoder(w).Encode(h.scheduler.Status())
+}
+
+// RunSchedule starts a run of the named job at once, whether or not it is
+// disabled. It needs the operator token.
+func (h *Handler) RunSchedule(w http.ResponseWriter, r *http.Request) {
+	if !h.operatorOnly(w, r, "schedules API") {
+		return
+	}
+	if h.scheduler == nil {
+		http.Error(w, "no scheduled jobs (SCHEDULES_FILE not set)", http.StatusServiceUnavailable)
+		return
+	}
+	run, err := h.scheduler.RunNow(r.Context(), mux.Vars(r)["name"])
+	if errors.Is(err, schedule.ErrUnknownJob) {
+		http.Error(w, err.Error(), http.StatusNotFound)
+		return
+	}
+	w.Header().Set("Content-Type", "application/json")
+	if err != nil {
+		w.WriteHeader(http.StatusBadGateway)
+	} else {
+		w.Header().Set("Location", "/tasks/"+run.TaskID)
+		w.WriteHeader(http.StatusAccepted)
+	}
+	_ = json.NewEncoder(w).Encode(run)
+}
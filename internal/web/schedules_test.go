@@ -0,0 +1,166 @@
+package web
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"net/http"
+	"net/http/httptest"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"github.com/gorilla/mux"
+
+	"github.com/cexll/swe/internal/dispatcher"
+	"github.com/cexll/swe/internal/schedule"
+	"github.com/cexll/swe/internal/taskstore"
+)
+
+func testScheduler(t *testing.T) *schedule.Scheduler {
+	t.Helper()
+	jobs, err := schedule.Parse([]byte(`[
+		{"name": "deps", "cron": "0 9 * * mon", "repo": "acme/api", "prompt": "audit the dependencies"},
+		{"name": "broken", "cron": "@daily", "repo": "acme/web", "prompt": "sweep", "disabled": true}
+	]`))
+	if err != nil {
+		t.Fatal(err)
+	}
+	return schedule.New(jobs, func(_ context.Context, job schedule.Job) (string, int, error) {
+		if job.Name == "broken" {
+			return "", 0, errors.New("repository not enabled")
+		}
+		return "task-7", 12, nil
+	})
+}
+
+func runSchedule(h *Handler, token, name string) *httptest.ResponseRecorder {
+	req := httptest.NewRequest(http.MethodPost, "/admin/api/schedules/"+name+"/run", nil)
+	if token != "" {
+		req.Header.Set("Authorization", "Bearer "+token)
+	}
+	req = mux.SetURLVars(req, map[string]string{"name": name})
+	rr := httptest.NewRecorder()
+	h.RunSchedule(rr, req)
+	return rr
+}
+
+func getSchedules(fn http.HandlerFunc, token, target string) *httptest.ResponseRecorder {
+	req := httptest.NewRequest(http.MethodGet, target, nil)
+	if token != "" {
+		req.Header.Set("Authorization", "Bearer "+token)
+	}
+	rr := httptest.NewRecorder()
+	fn(rr, req)
+	return rr
+}
+
+func TestHandler_Schedules(t *testing.T) {
+	handler := &Handler{}
+	if rr := getSchedules(handler.Schedules, "", "/admin/api/schedules"); rr.Code != http.StatusServiceUnavailable || !strings.Contains(rr.Body.String(), "API_TOKEN") {
+		t.Fatalf("without API_TOKEN: status = %d: %s", rr.Code, rr.Body.String())
+	}
+	handler.SetAPIToken("op-token")
+	if rr := getSchedules(handler.Schedules, "wrong", "/admin/api/schedules"); rr.Code != http.StatusUnauthorized {
+		t.Fatalf("wrong token: status = %d", rr.Code)
+	}
+	if rr := getSchedules(handler.Schedules, "op-token", "/admin/api/schedules"); rr.Code != http.StatusServiceUnavailable {
+		t.Fatalf("without a scheduler: status = %d", rr.Code)
+	}
+
+	handler.SetScheduler(testScheduler(t))
+	rr := getSchedules(handler.Schedules, "op-token", "/admin/api/schedules")
+	var got []schedule.Status
+	if err := json.Unmarshal(rr.Body.Bytes(), &got); err != nil {
+		t.Fatalf("invalid JSON: %v", err)
+	}
+	if len(got) != 2 || got[0].Name != "deps" || got[0].Next == nil || got[1].Next != nil {
+		t.Fatalf("schedules = %+v", got)
+	}
+}
+
+func TestHandler_RunSchedule(t *testing.T) {
+	handler := &Handler{}
+	handler.SetScheduler(testScheduler(t))
+	if rr := runSchedule(handler, "op-token", "deps"); rr.Code != http.StatusServiceUnavailable {
+		t.Fatalf("without API_TOKEN: status = %d", rr.Code)
+	}
+
+	handler.SetAPIToken("op-token")
+	if rr := runSchedule(handler, "wrong", "deps"); rr.Code != http.StatusUnauthorized {
+		t.Fatalf("wrong token: status = %d", rr.Code)
+	}
+	if rr := runSchedule(handler, "op-token", "nope"); rr.Code != http.StatusNotFound {
+		t.Fatalf("unknown job: status = %d", rr.Code)
+	}
+
+	rr := runSchedule(handler, "op-token", "deps")
+	var run schedule.Run
+	if err := json.Unmarshal(rr.Body.Bytes(), &run); err != nil {
+		t.Fatalf("invalid JSON: %v", err)
+	}
+	if rr.Code != http.StatusAccepted || rr.Header().Get("Location") != "/tasks/task-7" || run.Issue != 12 || !run.Manual {
+		t.Fatalf("status = %d, run = %+v", rr.Code, run)
+	}
+
+	rr = runSchedule(handler, "op-token", "broken")
+	if rr.Code != http.StatusBadGateway || !strings.Contains(rr.Body.String(), "repository not enabled") {
+		t.Fatalf("failed launch: status = %d: %s", rr.Code, rr.Body.String())
+	}
+}
+
+func TestHandler_SchedulesPage(t *testing.T) {
+	tmpl, err := ParseTemplates(filepath.Join("..", "..", "templates", "*.html"))
+	if err != nil {
+		t.Fatalf("parse templates: %v", err)
+	}
+	handler := &Handler{templates: tmpl}
+	handler.SetScheduler(testScheduler(t))
+	if rr := getSchedules(handler.SchedulesPage, "", "/schedules"); rr.Code != http.StatusServiceUnavailable {
+		t.Fatalf("without API_TOKEN: status = %d", rr.Code)
+	}
+	handler.SetAPIToken("op-token")
+	runSchedule(handler, "op-token", "deps")
+	runSchedule(handler, "op-token", "broken")
+	if rr := getSchedules(handler.SchedulesPage, "", "/schedules"); rr.Code != http.StatusUnauthorized {
+		t.Fatalf("without a token: status = %d", rr.Code)
+	}
+
+	handler.SetAdminPublic(true)
+	rr := getSchedules(handler.SchedulesPage, "", "/schedules")
+	if rr.Code != http.StatusOK {
+		t.Fatalf("status = %d: %s", rr.Code, rr.Body.String())
+	}
+	body := rr.Body.String()
+	for _, want := range []string{"0 9 * * mon", "(manual)", `href="/tasks/task-7"`, "repository not enabled", `data-name="deps"`, `data-name="broken"`, "/admin/api/schedules/"} {
+		if !strings.Contains(body, want) {
+			t.Errorf("page missing %q", want)
+		}
+	}
+}
+
+func TestHandler_AdminDashboard_ShowsSchedules(t *testing.T) {
+	tmpl, err := ParseTemplates(filepath.Join("..", "..", "templates", "*.html"))
+	if err != nil {
+		t.Fatalf("parse templates: %v", err)
+	}
+	handler := &Handler{store: taskstore.NewStore(), templates: tmpl}
+	handler.SetStatsSource(stubStats{stats: dispatcher.Stats{Workers: 1}})
+	handler.SetScheduler(testScheduler(t))
+	handler.SetAPIToken("op-token")
+	runSchedule(handler, "op-token", "deps")
+
+	req := httptest.NewRequest(http.MethodGet, "/admin", nil)
+	req.Header.Set("Authorization", "Bearer op-token")
+	rr := httptest.NewRecorder()
+	handler.AdminDashboard(rr, req)
+	if rr.Code != http.StatusOK {
+		t.Fatalf("status = %d: %s", rr.Code, rr.Body.String())
+	}
+	body := rr.Body.String()
+	for _, want := range []string{"Scheduled jobs", "0 9 * * mon", `href="/tasks/task-7"`, "https://github.com/acme/api/issues/12", "disabled"} {
+		if !strings.Contains(body, want) {
+			t.Errorf("dashboard missing %q", want)
+		}
+	}
+}
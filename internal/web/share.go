@@ -0,0 +1,149 @@
+package web
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"net/http"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/gorilla/mux"
+
+	"github.com/cexll/swe/internal/artifacts"
+	"github.com/cexll/swe/internal/audit"
+	"github.com/cexll/swe/internal/share"
+	"github.com/cexll/swe/internal/taskstore"
+)
+
+// ShareLink is returned when a share link is created.
+type ShareLink struct {
+	TaskID    string    `json:"task_id"`
+	URL       string    `json:"url"`
+	ExpiresAt time.Time `json:"expires_at"`
+}
+
+// sharedTask is the redacted view rendered for share link visitors.
+type sharedTask struct {
+	Title     string
+	Status    taskstore.TaskStatus
+	Repo      string
+	Number    int
+	Actor     string
+	Summary   string
+	Diff      string // the run's diff.patch, if one was kept
+	CreatedAt time.Time
+	UpdatedAt time.Time
+	Logs      []taskstore.LogEntry
+	ExpiresAt time.Time
+}
+
+// SetSharing enables share links signed by signer; shared content is passed
+// through redactor. A nil signer disables sharing.
+func (h *Handler) SetSharing(signer *share.Signer, redactor *share.Redactor) {
+	h.signer = signer
+	h.redactor = redactor
+}
+
+// CreateShareLink issues a time-limited link to one task's transcript and
+// diff, for operators holding API_TOKEN. The optional ttl_hours parameter
+// sets the lifetime (default 24h).
+func (h *Handler) CreateShareLink(w http.ResponseWriter, r *http.Request) {
+	if !h.operatorOnly(w, r, "share links") {
+		return
+	}
+	if h.signer == nil {
+		http.Error(w, "share links disabled (set SHARE_LINK_SECRET)", http.StatusServiceUnavailable)
+		return
+	}
+	if h.store == nil {
+		http.Error(w, "task store unavailable", http.StatusServiceUnavailable)
+		return
+	}
+	task, ok := h.store.Get(mux.Vars(r)["id"])
+	if !ok {
+		http.NotFound(w, r)
+		return
+	}
+
+	var ttl time.Duration
+	if raw := strings.TrimSpace(r.FormValue("ttl_hours")); raw != "" {
+		hours, err := strconv.Atoi(raw)
+		if err != nil || hours <= 0 {
+			http.Error(w, errInvalidParam("ttl_hours").Error(), http.StatusBadRequest)
+			return
+		}
+		ttl = time.Duration(hours) * time.Hour
+	}
+	token, expires, err := h.signer.Sign(task.ID, ttl)
+	if err != nil {
+		http.Error(w, fmt.Sprintf("invalid ttl_hours: %v", err), http.StatusBadRequest)
+		return
+	}
+
+	if h.audit != nil {
+		_ = h.audit.Record(audit.Event{
+			Action: audit.ActionShareLinkCreated,
+			Repo:   task.RepoOwner + "/" + task.RepoName,
+			Number: task.IssueNumber,
+			TaskID: task.ID,
+			Detail: "expires " + expires.UTC().Format(time.RFC3339),
+		})
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(http.StatusCreated)
+	_ = json.NewEncoder(w).Encode(ShareLink{TaskID: task.ID, URL: "/share/" + token, ExpiresAt: expires})
+}
+
+// SharedTask renders a task transcript and diff for a valid share token,
+// with secrets redacted server-side. It links nowhere else in the UI.
+func (h *Handler) SharedTask(w http.ResponseWriter, r *http.Request) {
+	w.Header().Set("Cache-Control", "no-store")
+	w.Header().Set("Referrer-Policy", "no-referrer")
+	w.Header().Set("X-Robots-Tag", "noindex")
+
+	taskID, expires, err := h.signer.Verify(mux.Vars(r)["token"])
+	switch {
+	case errors.Is(err, share.ErrExpired):
+		http.Error(w, "share link expired", http.StatusGone)
+		return
+	case err != nil:
+		http.NotFound(w, r)
+		return
+	}
+	if h.store == nil {
+		http.Error(w, "task store unavailable", http.StatusServiceUnavailable)
+		return
+	}
+	task, ok := h.store.Get(taskID)
+	if !ok {
+		http.NotFound(w, r)
+		return
+	}
+
+	if err := h.templates.ExecuteTemplate(w, "share.html", h.redactTask(task, h.readArtifact(r.Context(), task.ID, artifacts.Diff), expires)); err != nil {
+		http.Error(w, "template rendering error", http.StatusInternalServerError)
+	}
+}
+
+func (h *Handler) redactTask(task *taskstore.Task, diff string, expires time.Time) sharedTask {
+	view := sharedTask{
+		Title:     h.redactor.Redact(task.Title),
+		Status:    task.Status,
+		Repo:      task.RepoOwner + "/" + task.RepoName,
+		Number:    task.IssueNumber,
+		Actor:     task.Actor,
+		Summary:   h.redactor.Redact(task.PromptSummary),
+		Diff:      h.redactor.Redact(diff),
+		CreatedAt: task.CreatedAt,
+		UpdatedAt: task.UpdatedAt,
+		ExpiresAt: expires,
+	}
+	for _, entry := range task.Logs {
+		entry.Message = h.redactor.Redact(entry.Message)
+		view.Logs = append(view.Logs, entry)
+	}
+	return view
+}
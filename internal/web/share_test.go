@@ -0,0 +1,129 @@
+package web
+
+import (
+	"context"
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"net/url"
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/gorilla/mux"
+
+	"github.com/cexll/swe/internal/artifacts"
+	"github.com/cexll/swe/internal/audit"
+	"github.com/cexll/swe/internal/share"
+	"github.com/cexll/swe/internal/storage"
+	"github.com/cexll/swe/internal/taskstore"
+)
+
+func TestHandler_ShareLinks(t *testing.T) {
+	tmpl, err := ParseTemplates(filepath.Join("..", "..", "templates", "*.html"))
+	if err != nil {
+		t.Fatalf("parse templates: %v", err)
+	}
+	store := taskstore.NewStore()
+	store.Create(&taskstore.Task{ID: "task-1", Title: "Fix login", RepoOwner: "acme", RepoName: "api", IssueNumber: 7, Status: taskstore.StatusCompleted})
+	store.AddLog("task-1", "info", "using key sk-live-webhook-secret and token ghp_"+strings.Repeat("x", 36))
+
+	arts := artifacts.New(&storage.Local{Dir: t.TempDir()}, 0)
+	if err := arts.Save(context.Background(), "task-1", artifacts.Diff, []byte("diff --git a/login.go b/login.go\n+const key = \"sk-live-webhook-secret\"\n")); err != nil {
+		t.Fatal(err)
+	}
+
+	log, _ := audit.New(audit.Config{})
+	handler := &Handler{store: store, templates: tmpl}
+	handler.SetAuditLog(log)
+	handler.SetArtifacts(arts)
+
+	token := "op-token"
+	create := func(id, ttl string) *httptest.ResponseRecorder {
+		form := url.Values{}
+		if ttl != "" {
+			form.Set("ttl_hours", ttl)
+		}
+		req := httptest.NewRequest(http.MethodPost, "/tasks/"+id+"/share", strings.NewReader(form.Encode()))
+		req.Header.Set("Content-Type", "application/x-www-form-urlencoded")
+		req.Header.Set("Authorization", "Bearer "+token)
+		rr := httptest.NewRecorder()
+		handler.CreateShareLink(rr, mux.SetURLVars(req, map[string]string{"id": id}))
+		return rr
+	}
+	view := func(token string) *httptest.ResponseRecorder {
+		rr := httptest.NewRecorder()
+		req := httptest.NewRequest(http.MethodGet, "/share/"+token, nil)
+		handler.SharedTask(rr, mux.SetURLVars(req, map[string]string{"token": token}))
+		return rr
+	}
+
+	if rr := create("task-1", ""); rr.Code != http.StatusServiceUnavailable || !strings.Contains(rr.Body.String(), "API_TOKEN") {
+		t.Fatalf("without API_TOKEN status = %d %q, want 503", rr.Code, rr.Body.String())
+	}
+	handler.SetAPIToken("op-token")
+	if rr := create("task-1", ""); rr.Code != http.StatusServiceUnavailable {
+		t.Fatalf("disabled sharing status = %d, want 503", rr.Code)
+	}
+	if rr := view("anything"); rr.Code != http.StatusNotFound {
+		t.Fatalf("disabled sharing view status = %d, want 404", rr.Code)
+	}
+
+	handler.SetSharing(share.NewSigner("link-secret", 48*time.Hour), share.NewRedactor("sk-live-webhook-secret"))
+	token = "guess"
+	if rr := create("task-1", ""); rr.Code != http.StatusUnauthorized {
+		t.Fatalf("without the operator token status = %d, want 401", rr.Code)
+	}
+	token = "op-token"
+	for ttl, want := range map[string]int{"abc": http.StatusBadRequest, "0": http.StatusBadRequest, "49": http.StatusBadRequest} {
+		if rr := create("task-1", ttl); rr.Code != want {
+			t.Errorf("ttl_hours=%s status = %d, want %d", ttl, rr.Code, want)
+		}
+	}
+	if rr := create("missing", ""); rr.Code != http.StatusNotFound {
+		t.Fatalf("missing task status = %d, want 404", rr.Code)
+	}
+
+	rr := create("task-1", "2")
+	if rr.Code != http.StatusCreated {
+		t.Fatalf("create status = %d: %s", rr.Code, rr.Body.String())
+	}
+	var link ShareLink
+	if err := json.NewDecoder(rr.Body).Decode(&link); err != nil {
+		t.Fatalf("decode: %v", err)
+	}
+	if !strings.HasPrefix(link.URL, "/share/") || time.Until(link.ExpiresAt) > 2*time.Hour {
+		t.Fatalf("unexpected link: %+v", link)
+	}
+	if events := log.List(audit.Filter{Action: audit.ActionShareLinkCreated}); len(events) != 1 || events[0].TaskID != "task-1" {
+		t.Fatalf("audit events = %+v", events)
+	}
+
+	rr = view(strings.TrimPrefix(link.URL, "/share/"))
+	if rr.Code != http.StatusOK {
+		t.Fatalf("view status = %d: %s", rr.Code, rr.Body.String())
+	}
+	body := rr.Body.String()
+	if !strings.Contains(body, "Fix login") || !strings.Contains(body, "acme/api#7") || !strings.Contains(body, "diff --git a/login.go b/login.go") {
+		t.Fatalf("shared page missing task details:\n%s", body)
+	}
+	if strings.Contains(body, "sk-live-webhook-secret") || strings.Contains(body, "ghp_") || !strings.Contains(body, "[REDACTED]") {
+		t.Fatalf("shared page leaked secrets:\n%s", body)
+	}
+	if strings.Contains(body, `href="/tasks`) {
+		t.Fatal("shared page should not link into the task UI")
+	}
+	if rr.Header().Get("Cache-Control") != "no-store" {
+		t.Fatalf("Cache-Control = %q", rr.Header().Get("Cache-Control"))
+	}
+
+	if rr := view(strings.TrimPrefix(link.URL, "/share/") + "x"); rr.Code != http.StatusNotFound {
+		t.Fatalf("tampered token status = %d, want 404", rr.Code)
+	}
+	expired, _, _ := share.NewSigner("link-secret", 0).Sign("task-1", time.Nanosecond)
+	time.Sleep(time.Millisecond)
+	if rr := view(expired); rr.Code != http.StatusGone {
+		t.Fatalf("expired token status = %d, want 410", rr.Code)
+	}
+}
@@ -0,0 +1,95 @@
+package web
+
+import (
+	"net/http"
+	"time"
+
+	"github.com/gorilla/mux"
+)
+
+// timelinePhase is one phase as rendered by timeline.html.
+type timelinePhase struct {
+	Name     string
+	Start    time.Time
+	Duration string
+	Percent  float64 // share of the task's total time, for the bar width
+	Running  bool
+	Failed   bool
+	Slowest  bool
+	Steps    []timelineStep
+}
+
+type timelineStep struct {
+	Time      time.Time
+	Level     string
+	Type      string
+	Tool      string
+	Message   string
+	Duration  string
+	Milestone string
+}
+
+// TaskTimeline renders the phases of a task (queued, fetch context, clone,
+// provider run with its tool calls, push, tests) with their durations.
+func (h *Handler) TaskTimeline(w http.ResponseWriter, r *http.Request) {
+	if h.store == nil {
+		http.Error(w, "task store unavailable", http.StatusServiceUnavailable)
+		return
+	}
+	id := mux.Vars(r)["id"]
+	task, ok := h.store.Get(id)
+	if !ok {
+		http.NotFound(w, r)
+		return
+	}
+	tl, ok := h.store.Timeline(id, time.Now())
+	if !ok {
+		http.NotFound(w, r)
+		return
+	}
+
+	slowest := tl.Slowest()
+	phases := make([]timelinePhase, 0, len(tl.Phases))
+	for i, p := range tl.Phases {
+		view := timelinePhase{
+			Name:     p.Name,
+			Start:    p.Start,
+			Duration: formatDuration(p.Duration),
+			Running:  p.Running,
+			Failed:   p.Failed,
+			Slowest:  i == slowest && len(tl.Phases) > 1,
+		}
+		if tl.Total > 0 {
+			view.Percent = 100 * float64(p.Duration) / float64(tl.Total)
+		}
+		for _, s := range p.Steps {
+			step := timelineStep{Time: s.Timestamp, Level: s.Level, Type: s.Type, Tool: s.Tool, Message: s.Message, Milestone: s.Milestone()}
+			if s.Duration > 0 {
+				step.Duration = formatDuration(s.Duration)
+			}
+			view.Steps = append(view.Steps, step)
+		}
+		phases = append(phases, view)
+	}
+
+	if err := h.templates.ExecuteTemplate(w, "timeline.html", map[string]interface{}{
+		"Task":   task,
+		"Phases": phases,
+		"Total":  formatDuration(tl.Total),
+	}); err != nil {
+		http.Error(w, "template rendering error", http.StatusInternalServerError)
+	}
+}
+
+// formatDuration rounds d for display: milliseconds under a second, tenths
+// under a minute, seconds above.
+func formatDuration(d time.Duration) string {
+	switch {
+	case d < time.Second:
+		return d.Round(time.Millisecond).String()
+	case d < time.Minute:
+		return d.Round(100 * time.Millisecond).String()
+	default:
+		return d.Round(time.Second).String()
+	}
+}
@@ -0,0 +1,63 @@
+package web
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/gorilla/mux"
+
+	"github.com/cexll/swe/internal/taskstore"
+)
+
+func TestHandler_TaskTimeline_RendersRepoTemplate(t *testing.T) {
+	tmpl, err := ParseTemplates(filepath.Join("..", "..", "templates", "*.html"))
+	if err != nil {
+		t.Fatalf("parse templates: %v", err)
+	}
+	store := taskstore.NewStore()
+	store.Create(&taskstore.Task{ID: "task-1", Title: "demo", Status: taskstore.StatusRunning})
+	store.AddEntry("task-1", taskstore.LogEntry{Type: taskstore.TypePhase, Message: taskstore.PhaseProvider})
+	store.AddEntry("task-1", taskstore.LogEntry{Level: "info", Type: "command", Tool: "git", Message: "git push origin HEAD", Duration: 1500 * time.Millisecond})
+	handler := &Handler{store: store, templates: tmpl}
+
+	req := mux.SetURLVars(httptest.NewRequest(http.MethodGet, "/tasks/task-1/timeline", nil), map[string]string{"id": "task-1"})
+	rr := httptest.NewRecorder()
+	handler.TaskTimeline(rr, req)
+
+	if rr.Code != http.StatusOK {
+		t.Fatalf("status = %d: %s", rr.Code, rr.Body.String())
+	}
+	body := rr.Body.String()
+	for _, want := range []string{"queued", "provider run", "running", `<span class="milestone">push</span>`, "1.5s"} {
+		if !strings.Contains(body, want) {
+			t.Errorf("timeline missing %q", want)
+		}
+	}
+}
+
+func TestHandler_TaskTimeline_NotFound(t *testing.T) {
+	handler := &Handler{store: taskstore.NewStore()}
+	req := mux.SetURLVars(httptest.NewRequest(http.MethodGet, "/tasks/nope/timeline", nil), map[string]string{"id": "nope"})
+	rr := httptest.NewRecorder()
+	handler.TaskTimeline(rr, req)
+	if rr.Code != http.StatusNotFound {
+		t.Fatalf("status = %d, want 404", rr.Code)
+	}
+}
+
+func TestFormatDuration(t *testing.T) {
+	for d, want := range map[time.Duration]string{
+		1234567 * time.Nanosecond: "1ms",
+		12345 * time.Millisecond:  "12.3s",
+		(125*time.Second + 400e6): "2m5s",
+		0:                         "0s",
+	} {
+		if got := formatDuration(d); got != want {
+			t.Errorf("formatDuration(%v) = %q, want %q", d, got, want)
+		}
+	}
+}
@@ -0,0 +1,227 @@
+package webhook
+
+import (
+	"fmt"
+	"net/http"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/cexll/swe/internal/audit"
+	"github.com/cexll/swe/internal/github"
+	"github.com/cexll/swe/internal/policy"
+)
+
+// approvalTTL bounds how long a posted plan waits for approval.
+const approvalTTL = 24 * time.Hour
+
+// approvalCommand is the comment that approves a plan in approval mode,
+// e.g. "/code approve".
+func approvalCommand(trigger string) string {
+	return trigger + " approve"
+}
+
+// isApprovalCommand reports whether body is exactly the approval command, so
+// "/code approve the new API and add tests" stays an ordinary task.
+func isApprovalCommand(body, trigger string) bool {
+	return strings.EqualFold(strings.Join(strings.Fields(body), " "), approvalCommand(trigger))
+}
+
+type pendingTask struct {
+	task    *Task
+	expires time.Time
+	held    bool // by a policy rule: applying needs the same approval as in approval mode
+}
+
+// pendingTasks holds tasks awaiting a follow-up command on their thread
+// (a plan awaiting approval, a dry run awaiting apply), keyed by
+// repo#number; a newer task on the same thread replaces the older one.
+type pendingTasks struct {
+	mu      sync.Mutex
+	pending map[string]pendingTask
+}
+
+func (r *pendingTasks) put(key string, p pendingTask) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	if r.pending == nil {
+		r.pending = make(map[string]pendingTask)
+	}
+	r.pending[key] = p
+}
+
+// get returns the unexpired task for key.
+func (r *pendingTasks) get(key string, now time.Time) (pendingTask, bool) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	p, ok := r.pending[key]
+	if !ok || now.After(p.expires) {
+		delete(r.pending, key)
+		return pendingTask{}, false
+	}
+	return p, true
+}
+
+// remove drops the task for key if it is still the one with taskID.
+func (r *pendingTasks) remove(key, taskID string) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	if p, ok := r.pending[key]; ok && p.task.ID == taskID {
+		delete(r.pending, key)
+	}
+}
+
+// SetApprovalMode makes triggered tasks post a plan and wait for a second
+// user's approval before pushing; safe to call while requests are being
+// served.
+func (h *Handler) SetApprovalMode(enabled bool) {
+	h.settingsMu.Lock()
+	defer h.settingsMu.Unlock()
+	h.approvalMode = enabled
+}
+
+func (h *Handler) approvalEnabled() bool {
+	h.settingsMu.RLock()
+	defer h.settingsMu.RUnlock()
+	return h.approvalMode
+}
+
+// approvalCommandEnabled reports whether "<trigger> approve" can have a plan
+// to approve: in approval mode, or when a policy rule requires approval.
+func (h *Handler) approvalCommandEnabled() bool {
+	return h.approvalEnabled() || h.currentPolicy().HasEffect(policy.RequireApproval)
+}
+
+// requireApproval makes t plan-only and registers it as the plan awaiting
+// approval on its thread.
+func (h *Handler) requireApproval(t *Task, trigger string) {
+	t.ApprovalCommand = approvalCommand(trigger)
+	h.approvals.put(threadKey(t.Repo, t.Number), pendingTask{task: t, expires: time.Now().Add(approvalTTL)})
+}
+
+func threadKey(repo string, number int) string {
+	return fmt.Sprintf("%s#%d", strings.ToLower(repo), number)
+}
+
+// hasWriteAccess reports whether user may approve plans (write, maintain or
+// admin role). Like release permission, it fails closed.
+func hasWriteAccess(ghCtx *github.Context, user string) (bool, string) {
+	if ghCtx.Token == "" {
+		return false, "approval: no installation token to verify write access"
+	}
+	role, err := collaboratorRole(ghCtx.Repository.Owner, ghCtx.Repository.Name, user, ghCtx.Token)
+	if err != nil {
+		return false, fmt.Sprintf("approval: role lookup failed: %v", err)
+	}
+	switch role {
+	case "admin", "maintain", "write":
+		return true, "approval: role " + role
+	}
+	return false, "approval: role " + role
+}
+
+// handleApproval approves the plan posted on the thread: the approver must
+// not be the requester, must have write access, and the plan must have been
+// posted. The approved plan is queued as a new task that may push.
+func (h *Handler) handleApproval(w http.ResponseWriter, ghCtx *github.Context, trigger, eventType string) {
+	repo := ghCtx.Repository.FullName
+	if enabled, reason := h.checkRepo(repo); !enabled {
+		h.rejectRepo(ghCtx, reason)
+		w.WriteHeader(http.StatusOK)
+		_, _ = w.Write([]byte("Repository not enabled"))
+		return
+	}
+	if !h.getDeduper(eventType).markIfNew(ghCtx.TriggerComment.ID) {
+		w.WriteHeader(http.StatusOK)
+		_, _ = w.Write([]byte("Duplicate comment ignored"))
+		return
+	}
+
+	h.setInstallationToken(ghCtx, "approval")
+
+	key := threadKey(repo, ghCtx.IssueNumber)
+	user := ghCtx.TriggerUser
+	pending, ok := h.approvals.get(key, time.Now())
+	if !ok {
+		h.replyThread(ghCtx, "There is no plan waiting for approval here.")
+		w.WriteHeader(http.StatusOK)
+		_, _ = w.Write([]byte("No pending plan"))
+		return
+	}
+	if strings.EqualFold(user, pending.task.Username) {
+		h.recordPermission(ghCtx, false, "approval: requester cannot approve their own plan")
+		h.replyThread(ghCtx, fmt.Sprintf("@%s the plan must be approved by someone other than its requester.", user))
+		w.WriteHeader(http.StatusOK)
+		_, _ = w.Write([]byte("Permission denied"))
+		return
+	}
+	allowed, reason := hasWriteAccess(ghCtx, user)
+	h.recordPermission(ghCtx, allowed, reason)
+	if !allowed {
+		eventLog(ghCtx, phaseAuthorize).Info("Approval denied", "user", user, "reason", reason)
+		h.replyThread(ghCtx, fmt.Sprintf("@%s approving a plan needs write access to this repository.", user))
+		w.WriteHeader(http.StatusOK)
+		_, _ = w.Write([]byte("Permission denied"))
+		return
+	}
+
+	plan, ok := "", false
+	if h.store != nil {
+		plan, ok = h.store.Approve(pending.task.ID, user)
+	}
+	if !ok {
+		h.replyThread(ghCtx, "The plan is not ready yet. Approve it once it has been posted.")
+		w.WriteHeader(http.StatusOK)
+		_, _ = w.Write([]byte("Plan not ready"))
+		return
+	}
+	h.approvals.remove(key, pending.task.ID)
+
+	t := *pending.task
+	t.ID = h.generateTaskID(t.Repo, t.Number)
+	t.Attempt = 0
+	t.ApprovalCommand = ""
+	t.ApprovedPlan = plan
+	t.ApprovedBy = user
+	t.PromptSummary = fmt.Sprintf("%s\n\n**Approved by:** @%s", pending.task.PromptSummary, user)
+	h.createStoreTask(&t)
+	if t.ConfirmCommand != "" {
+		h.requireConfirmation(&t, trigger)
+	}
+	h.recordAudit(audit.Event{
+		Action:            audit.ActionPlanApproved,
+		Actor:             user,
+		Repo:              repo,
+		Number:            ghCtx.IssueNumber,
+		TaskID:            t.ID,
+		TriggerCommentID:  ghCtx.TriggerComment.ID,
+		TrackingCommentID: t.CommentID,
+		Detail:            fmt.Sprintf("plan %s requested by %s", pending.task.ID, pending.task.Username),
+	})
+	taskLog(&t, phaseEnqueue).Info("Plan approved", "number", t.Number, "plan", pending.task.ID, "user", user)
+	h.enqueueTask(w, &t)
+}
+
+// setInstallationToken puts the repository's installation token on ghCtx
+// for the replies and lookups of a command (what names it in the log).
+func (h *Handler) setInstallationToken(ghCtx *github.Context, what string) {
+	if h.appAuth == nil {
+		return
+	}
+	repo := ghCtx.Repository.FullName
+	if token, err := h.appAuth.GetInstallationToken(repo); err != nil {
+		eventLog(ghCtx, phaseReply).Warn("Getting installation token failed", "for", what, "err", err)
+	} else if token != nil {
+		ghCtx.Token = token.Token
+	}
+}
+
+// replyThread posts a comment on the thread a command came from.
+func (h *Handler) replyThread(ghCtx *github.Context, body string) {
+	if ghCtx.Token == "" {
+		return
+	}
+	if _, err := createComment(ghCtx.Repository.Owner, ghCtx.Repository.Name, ghCtx.IssueNumber, body, ghCtx.Token); err != nil {
+		eventLog(ghCtx, phaseReply).Warn("Posting reply failed", "err", err)
+	}
+}
@@ -0,0 +1,108 @@
+package webhook
+
+import (
+	"net/http"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/cexll/swe/internal/audit"
+	"github.com/cexll/swe/internal/taskstore"
+)
+
+func TestIsApprovalCommand(t *testing.T) {
+	tests := map[string]bool{
+		"/code approve":                 true,
+		"  /Code   APPROVE \n":          true,
+		"/code approve the new API":     false,
+		"please /code approve":          false,
+		"/code fix the approve handler": false,
+	}
+	for body, want := range tests {
+		if got := isApprovalCommand(body, "/code"); got != want {
+			t.Errorf("isApprovalCommand(%q) = %t, want %t", body, got, want)
+		}
+	}
+}
+
+func TestApprovalRequests_Expire(t *testing.T) {
+	var r pendingTasks
+	now := time.Now()
+	r.put("o/r#1", pendingTask{task: &Task{ID: "t1"}, expires: now.Add(time.Minute)})
+	if _, ok := r.get("o/r#1", now.Add(2*time.Minute)); ok {
+		t.Fatal("expired plan should not be approvable")
+	}
+	r.put("o/r#1", pendingTask{task: &Task{ID: "t2"}, expires: now.Add(time.Minute)})
+	r.remove("o/r#1", "t1")
+	if p, ok := r.get("o/r#1", now); !ok || p.task.ID != "t2" {
+		t.Fatalf("a newer plan must survive removal of an older one: %+v, %t", p, ok)
+	}
+}
+
+func TestHandleApproval(t *testing.T) {
+	h, dispatcher, log, posted := releaseHandler(t, map[string]string{"installer": "admin", "bob": "write", "eve": "read"})
+	h.SetReleaseMode(false)
+	h.SetApprovalMode(true)
+	h.store = taskstore.NewStore()
+
+	if w := postRelease(t, h, 1, "bob", "/code approve"); w.Body.String() != "No pending plan" {
+		t.Fatalf("approval without a plan = %q", w.Body.String())
+	}
+
+	w := postRelease(t, h, 2, "installer", "/code fix the parser")
+	if w.Code != http.StatusAccepted || dispatcher.enqueueCalls != 1 {
+		t.Fatalf("trigger response = %d %q", w.Code, w.Body.String())
+	}
+	plan := dispatcher.lastTask
+	if plan.ApprovalCommand != "/code approve" || plan.ApprovedBy != "" {
+		t.Fatalf("task should be plan-only: %+v", plan)
+	}
+
+	if w := postRelease(t, h, 3, "bob", "/code approve"); w.Body.String() != "Plan not ready" || dispatcher.enqueueCalls != 1 {
+		t.Fatalf("approval before the plan is posted = %q", w.Body.String())
+	}
+	h.store.AwaitApproval(plan.ID, "1. fix the parser")
+
+	for i, user := range []string{"installer", "eve"} {
+		if w := postRelease(t, h, int64(4+i), user, "/code approve"); w.Body.String() != "Permission denied" || dispatcher.enqueueCalls != 1 {
+			t.Fatalf("approval by %s = %q", user, w.Body.String())
+		}
+	}
+	if !strings.Contains((*posted)[len(*posted)-2], "someone other than its requester") {
+		t.Fatalf("self-approval reply = %q", (*posted)[len(*posted)-2])
+	}
+
+	w = postRelease(t, h, 6, "bob", "/code approve")
+	if w.Code != http.StatusAccepted || dispatcher.enqueueCalls != 2 {
+		t.Fatalf("approval response = %d %q", w.Code, w.Body.String())
+	}
+	run := dispatcher.lastTask
+	if run.ID == plan.ID || run.ApprovalCommand != "" || run.ApprovedPlan != "1. fix the parser" || run.ApprovedBy != "bob" ||
+		run.CommentID != plan.CommentID || !strings.HasSuffix(run.PromptSummary, "**Approved by:** @bob") {
+		t.Fatalf("unexpected approved task: %+v", run)
+	}
+	if got, _ := h.store.Get(plan.ID); got.Approval != taskstore.ApprovalApproved || got.ApprovedBy != "bob" {
+		t.Fatalf("plan task = %+v", got)
+	}
+	if got, _ := h.store.Get(run.ID); got.Approval != taskstore.ApprovalApproved || got.Plan != "1. fix the parser" {
+		t.Fatalf("approved task = %+v", got)
+	}
+
+	if w := postRelease(t, h, 7, "bob", "/code approve"); w.Body.String() != "No pending plan" || dispatcher.enqueueCalls != 2 {
+		t.Fatalf("second approval = %q", w.Body.String())
+	}
+
+	if events := log.List(audit.Filter{Action: audit.ActionPlanApproved}); len(events) != 1 || events[0].Actor != "bob" || events[0].TaskID != run.ID {
+		t.Fatalf("approval audit = %+v", events)
+	}
+	denied := log.List(audit.Filter{Action: audit.ActionPermission, Actor: "eve"})
+	if len(denied) != 1 || denied[0].Decision != audit.DecisionDenied || denied[0].Detail != "approval: role read" {
+		t.Fatalf("permission audit = %+v", denied)
+	}
+
+	// without approval mode the command is an ordinary task
+	h.SetApprovalMode(false)
+	if w := postRelease(t, h, 8, "installer", "/code approve"); w.Code != http.StatusAccepted || dispatcher.lastTask.ApprovalCommand != "" {
+		t.Fatalf("approval mode off = %d %q", w.Code, w.Body.String())
+	}
+}
@@ -0,0 +1,47 @@
+package webhook
+
+import "strings"
+
+// backportCommand is the subcommand that backports a merged pull request to
+// another branch: "/code backport to release-1.2".
+const backportCommand = "backport"
+
+// parseBackportCommand reports whether prompt, the text after the trigger,
+// is a backport command, and returns the branch it names ("" when it names
+// none, or not a valid branch). "to" before the branch is optional.
+func parseBackportCommand(prompt string) (target string, ok bool) {
+	fields := subcommandFields(prompt)
+	if len(fields) == 0 || !strings.EqualFold(fields[0], backportCommand) {
+		return "", false
+	}
+	fields = fields[1:]
+	if len(fields) > 0 && strings.EqualFold(fields[0], "to") {
+		fields = fields[1:]
+	}
+	if len(fields) == 0 || !validBranchName(fields[0]) {
+		return "", true
+	}
+	return fields[0], true
+}
+
+// validBranchName reports whether name can be a branch, following the rules
+// of git check-ref-format.
+func validBranchName(name string) bool {
+	if name == "" || name == "@" || strings.HasPrefix(name, "-") || strings.HasPrefix(name, "/") ||
+		strings.HasSuffix(name, "/") || strings.HasSuffix(name, ".") || strings.HasSuffix(name, ".lock") ||
+		strings.Contains(name, "..") || strings.Contains(name, "//") || strings.Contains(name, "@{") ||
+		strings.ContainsAny(name, " ~^:?*[\\") {
+		return false
+	}
+	for _, r := range name {
+		if r < 0x20 || r == 0x7f {
+			return false
+		}
+	}
+	for _, part := range strings.Split(name, "/") {
+		if strings.HasPrefix(part, ".") {
+			return false
+		}
+	}
+	return true
+}
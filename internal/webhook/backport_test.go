@@ -0,0 +1,74 @@
+package webhook
+
+import (
+	"encoding/json"
+	"net/http/httptest"
+	"strings"
+	"testing"
+)
+
+func TestParseBackportCommand(t *testing.T) {
+	tests := []struct {
+		prompt, target string
+		ok             bool
+	}{
+		{"backport to release-1.2", "release-1.2", true},
+		{"Backport release/2.x", "release/2.x", true},
+		{"--timeout 45m backport to release-1.2", "release-1.2", true},
+		{"backport", "", true},
+		{"backport to", "", true},
+		{"backport to release..1", "", true},
+		{"backport to -f", "", true},
+		{"backport to v1.lock", "", true},
+		{"backport to rel~1", "", true},
+		{"please backport this", "", false},
+		{"fix the backport script", "", false},
+		{"", "", false},
+	}
+	for _, tc := range tests {
+		target, ok := parseBackportCommand(tc.prompt)
+		if target != tc.target || ok != tc.ok {
+			t.Errorf("parseBackportCommand(%q) = %q, %t, want %q, %t", tc.prompt, target, ok, tc.target, tc.ok)
+		}
+	}
+}
+
+func postPRComment(t *testing.T, h *Handler, commentID int64, body string) *httptest.ResponseRecorder {
+	t.Helper()
+	payload, _ := json.Marshal(&IssueCommentEvent{
+		Action:     "created",
+		Issue:      Issue{Number: 12, Title: "Fix the parser", PullRequest: &IssuePRLinks{}},
+		Comment:    Comment{ID: commentID, Body: body, User: User{Login: "installer", Type: "User"}},
+		Repository: Repository{FullName: "owner/repo", DefaultBranch: "main"},
+		Sender:     User{Login: "installer"},
+	})
+	w := httptest.NewRecorder()
+	h.Handle(w, signedDelivery(t, "s3cret", "issue_comment", "", payload))
+	return w
+}
+
+func TestHandle_Backport(t *testing.T) {
+	h, dispatcher, _, posted := releaseHandler(t, nil)
+	h.SetReleaseMode(false)
+
+	if w := postRelease(t, h, 1, "installer", "/code backport to release-1.2"); w.Body.String() != "Invalid backport command" || dispatcher.enqueueCalls != 0 {
+		t.Fatalf("backport on an issue = %q", w.Body.String())
+	}
+	if last := (*posted)[len(*posted)-1]; !strings.Contains(last, "`/code backport` works on pull requests only") {
+		t.Fatalf("reply = %q", last)
+	}
+	if w := postPRComment(t, h, 2, "/code backport"); w.Body.String() != "Invalid backport command" || dispatcher.enqueueCalls != 0 {
+		t.Fatalf("backport without a branch = %q", w.Body.String())
+	}
+	if last := (*posted)[len(*posted)-1]; !strings.Contains(last, "`/code backport to release-1.2`") {
+		t.Fatalf("reply = %q", last)
+	}
+
+	h.SetDefaultDryRun(true)
+	if w := postPRComment(t, h, 3, "/code backport to release-1.2"); dispatcher.enqueueCalls != 1 {
+		t.Fatalf("backport = %q", w.Body.String())
+	}
+	if task := dispatcher.lastTask; task.BackportTo != "release-1.2" || task.ApplyCommand != "" || task.ApprovalCommand != "" {
+		t.Fatalf("task = %+v", task)
+	}
+}
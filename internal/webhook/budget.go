@@ -0,0 +1,86 @@
+package webhook
+
+import (
+	"fmt"
+
+	"github.com/cexll/swe/internal/audit"
+	"github.com/cexll/swe/internal/budget"
+	"github.com/cexll/swe/internal/github"
+	"github.com/cexll/swe/internal/taskstore"
+)
+
+// SetBudgets refuses the tasks of organizations that reached the hard cap
+// of their monthly budget in t (nil refuses none).
+func (h *Handler) SetBudgets(t *budget.Tracker) {
+	h.budgets = t
+}
+
+// budgetExhausted reports whether the organization repo belongs to reached
+// its hard cap, and its spending.
+func (h *Handler) budgetExhausted(repo string) (budget.Status, bool) {
+	if h.budgets == nil {
+		return budget.Status{}, false
+	}
+	st := h.budgets.Status(budget.Org(repo))
+	return st, st.Exhausted
+}
+
+// budgetExhaustedText explains why no task was started.
+func budgetExhaustedText(st budget.Status) string {
+	return fmt.Sprintf("**Budget exhausted.** `%s` has spent $%.2f of its $%.2f hard cap for %s, so no task was started. "+
+		"Tasks run again next month, or once an operator raises the budget or resets the spending.",
+		st.Org, st.SpentUSD, st.HardCapUSD, st.Month)
+}
+
+// refuseBudget logs and audits a trigger refused for the budget and tells
+// the commenter why.
+func (h *Handler) refuseBudget(ghCtx *github.Context, st budget.Status) {
+	eventLog(ghCtx, phaseAuthorize).Info("Budget exhausted; refusing trigger", "org", st.Org, "spent_usd", st.SpentUSD, "user", ghCtx.TriggerUser)
+	ev := audit.Event{
+		Action:   audit.ActionBudgetRefused,
+		Actor:    ghCtx.TriggerUser,
+		Repo:     ghCtx.Repository.FullName,
+		Number:   ghCtx.IssueNumber,
+		Decision: audit.DecisionDenied,
+		Detail:   fmt.Sprintf("spent $%.2f of $%.2f hard cap for %s", st.SpentUSD, st.HardCapUSD, st.Month),
+	}
+	if ghCtx.TriggerComment != nil {
+		ev.TriggerCommentID = ghCtx.TriggerComment.ID
+	}
+	h.recordAudit(ev)
+	h.setInstallationToken(ghCtx, "budget")
+	h.replyThread(ghCtx, budgetExhaustedText(st))
+}
+
+// refuseBudgetTask fails a prepared task whose organization reached its
+// hard cap, explaining why in its tracking comment.
+func (h *Handler) refuseBudgetTask(task *Task, st budget.Status) {
+	taskLog(task, phaseEnqueue).Info("Budget exhausted; refusing task", "org", st.Org, "spent_usd", st.SpentUSD)
+	msg := budgetExhaustedText(st)
+	h.recordAudit(audit.Event{
+		Action:            audit.ActionBudgetRefused,
+		Actor:             task.Username,
+		Repo:              task.Repo,
+		Number:            task.Number,
+		TaskID:            task.ID,
+		TrackingCommentID: task.CommentID,
+		Decision:          audit.DecisionDenied,
+		Detail:            fmt.Sprintf("spent $%.2f of $%.2f hard cap for %s", st.SpentUSD, st.HardCapUSD, st.Month),
+	})
+	if h.store != nil {
+		h.store.UpdateStatus(task.ID, taskstore.StatusFailed)
+		h.store.AddLog(task.ID, "error", msg)
+	}
+	if task.CommentID == 0 || h.appAuth == nil {
+		return
+	}
+	token, err := h.appAuth.GetInstallationToken(task.Repo)
+	if err != nil || token == nil {
+		taskLog(task, phaseReply).Warn("Cannot explain the refused task", "err", err)
+		return
+	}
+	owner, name := splitRepo(task.Repo)
+	if err := updateComment(owner, name, task.CommentID, msg, token.Token); err != nil {
+		taskLog(task, phaseReply).Warn("Updating tracking comment failed", "err", err)
+	}
+}
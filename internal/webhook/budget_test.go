@@ -0,0 +1,100 @@
+package webhook
+
+import (
+	"encoding/json"
+	"errors"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+
+	"github.com/cexll/swe/internal/audit"
+	"github.com/cexll/swe/internal/budget"
+)
+
+func exhaustedBudget(t *testing.T, org string) *budget.Tracker {
+	t.Helper()
+	tracker, err := budget.Open("")
+	if err != nil {
+		t.Fatal(err)
+	}
+	tracker.SetLimits(budget.Limits{Orgs: map[string]float64{org: 10}, HardCapPercent: 100})
+	if _, _, err := tracker.Add(org, 12.5); err != nil {
+		t.Fatal(err)
+	}
+	return tracker
+}
+
+func TestHandleWebhook_BudgetExhausted(t *testing.T) {
+	var posted string
+	orig := createComment
+	t.Cleanup(func() { createComment = orig })
+	createComment = func(_, _ string, _ int, body, _ string) (int64, error) {
+		posted = body
+		return 1, nil
+	}
+
+	secret := "s3cret"
+	dispatcher := &mockDispatcher{}
+	h := NewHandler(secret, "/code", dispatcher, nil, &stubAuthProvider{owner: "tester"})
+	log, _ := audit.New(audit.Config{})
+	h.SetAuditLog(log)
+	h.SetBudgets(exhaustedBudget(t, "acme"))
+
+	payload, _ := json.Marshal(&IssueCommentEvent{
+		Action:     "created",
+		Issue:      Issue{Number: 5, Title: "Costly"},
+		Comment:    Comment{ID: 77, Body: "/code do it", User: User{Login: "tester", Type: "User"}},
+		Repository: Repository{FullName: "Acme/api", DefaultBranch: "main"},
+		Sender:     User{Login: "tester"},
+	})
+	w := httptest.NewRecorder()
+	h.Handle(w, signedDelivery(t, secret, "issue_comment", "", payload))
+
+	if w.Code != http.StatusOK || w.Body.String() != "Budget exhausted" || dispatcher.enqueueCalls != 0 {
+		t.Fatalf("response = %d %q, enqueued %d", w.Code, w.Body.String(), dispatcher.enqueueCalls)
+	}
+	if !strings.Contains(posted, "`acme` has spent $12.50 of its $10.00 hard cap") {
+		t.Fatalf("reply = %q", posted)
+	}
+	events := log.List(audit.Filter{Action: audit.ActionBudgetRefused})
+	if len(events) != 1 || events[0].TriggerCommentID != 77 || events[0].Decision != audit.DecisionDenied {
+		t.Fatalf("audit events = %+v", events)
+	}
+
+	res, _ := simulateRequest(t, h, `{"repo":"acme/api","user":"tester","body":"/code hi"}`)
+	if res.WouldEnqueue || res.Response != "Budget exhausted" || lastStep(res).Check != "budget" {
+		t.Fatalf("unexpected simulation: %+v", res)
+	}
+}
+
+func TestDispatchTask_BudgetExhaustedUpdatesTrackingComment(t *testing.T) {
+	var updated string
+	orig := updateComment
+	t.Cleanup(func() { updateComment = orig })
+	updateComment = func(_, _ string, id int64, body, _ string) error {
+		if id == 42 {
+			updated = body
+		}
+		return nil
+	}
+
+	dispatcher := &mockDispatcher{}
+	h := NewHandler("secret", "/code", dispatcher, nil, &stubAuthProvider{owner: "tester"})
+	h.SetBudgets(exhaustedBudget(t, "acme"))
+
+	err := h.dispatchTask(&Task{ID: "t1", Repo: "acme/api", Number: 3, CommentID: 42})
+	if !errors.Is(err, ErrBudgetExhausted) || dispatcher.enqueueCalls != 0 {
+		t.Fatalf("dispatchTask = %v, enqueued %d", err, dispatcher.enqueueCalls)
+	}
+	if !strings.HasPrefix(updated, "**Budget exhausted.**") {
+		t.Fatalf("tracking comment = %q", updated)
+	}
+	if status, msg := enqueueErrorStatus(err); status != http.StatusPaymentRequired || msg != "Monthly budget exhausted" {
+		t.Fatalf("enqueueErrorStatus = %d %q", status, msg)
+	}
+
+	if err := h.dispatchTask(&Task{ID: "t2", Repo: "other/api", Number: 3}); err != nil || dispatcher.enqueueCalls != 1 {
+		t.Fatalf("other organization: %v, enqueued %d", err, dispatcher.enqueueCalls)
+	}
+}
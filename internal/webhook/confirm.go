@@ -0,0 +1,122 @@
+package webhook
+
+import (
+	"fmt"
+	"net/http"
+	"strings"
+	"time"
+
+	"github.com/cexll/swe/internal/audit"
+	"github.com/cexll/swe/internal/github"
+)
+
+// confirmTTL bounds how long a task stopped for its estimated cost waits for
+// confirmation.
+const confirmTTL = 24 * time.Hour
+
+// confirmCommand is the comment that runs a task whose estimated cost needs
+// confirming, e.g. "/code confirm".
+func confirmCommand(trigger string) string {
+	return trigger + " confirm"
+}
+
+// isConfirmCommand reports whether body is exactly the confirm command.
+func isConfirmCommand(body, trigger string) bool {
+	return strings.EqualFold(strings.Join(strings.Fields(body), " "), confirmCommand(trigger))
+}
+
+// SetCostConfirmation makes triggered tasks stop before the provider runs
+// when the executor estimates they cost too much, until someone comments
+// the confirm command; safe to call while requests are being served.
+func (h *Handler) SetCostConfirmation(enabled bool) {
+	h.settingsMu.Lock()
+	defer h.settingsMu.Unlock()
+	h.costConfirm = enabled
+}
+
+func (h *Handler) costConfirmEnabled() bool {
+	h.settingsMu.RLock()
+	defer h.settingsMu.RUnlock()
+	return h.costConfirm
+}
+
+// requireConfirmation gives t the confirm command and registers it as the
+// task that command confirms on its thread. Whether it stops is up to the
+// executor's estimate; confirming a task that did not stop is refused.
+func (h *Handler) requireConfirmation(t *Task, trigger string) {
+	t.ConfirmCommand = confirmCommand(trigger)
+	h.confirmations.put(threadKey(t.Repo, t.Number), pendingTask{task: t, expires: time.Now().Add(confirmTTL)})
+}
+
+// handleConfirm runs the task the thread's estimate stopped. The commenter
+// needs the same permission as for the trigger. The task is queued again
+// without the confirm command, so it runs whatever its estimate.
+func (h *Handler) handleConfirm(w http.ResponseWriter, ghCtx *github.Context, trigger, eventType string) {
+	repo := ghCtx.Repository.FullName
+	if enabled, reason := h.checkRepo(repo); !enabled {
+		h.rejectRepo(ghCtx, reason)
+		w.WriteHeader(http.StatusOK)
+		_, _ = w.Write([]byte("Repository not enabled"))
+		return
+	}
+	decision := h.authorize(ghCtx, commandName(trigger))
+	h.recordPermission(ghCtx, decision.Allowed, decision.Reason)
+	if !decision.Allowed {
+		eventLog(ghCtx, phaseAuthorize).Info("Permission denied", "user", ghCtx.TriggerUser, "reason", decision.Reason)
+		h.replyDenied(ghCtx, decision)
+		w.WriteHeader(http.StatusOK)
+		_, _ = w.Write([]byte("Permission denied"))
+		return
+	}
+	if !h.getDeduper(eventType).markIfNew(ghCtx.TriggerComment.ID) {
+		w.WriteHeader(http.StatusOK)
+		_, _ = w.Write([]byte("Duplicate comment ignored"))
+		return
+	}
+
+	h.setInstallationToken(ghCtx, "confirm")
+
+	key := threadKey(repo, ghCtx.IssueNumber)
+	user := ghCtx.TriggerUser
+	pending, ok := h.confirmations.get(key, time.Now())
+	if !ok || h.store == nil || !h.store.Confirm(pending.task.ID, user) {
+		h.replyThread(ghCtx, "There is no task waiting for its cost to be confirmed here.")
+		w.WriteHeader(http.StatusOK)
+		_, _ = w.Write([]byte("No task awaiting confirmation"))
+		return
+	}
+	h.confirmations.remove(key, pending.task.ID)
+
+	t := *pending.task
+	t.ID = h.generateTaskID(t.Repo, t.Number)
+	t.Attempt = 0
+	t.ConfirmCommand = ""
+	t.PromptSummary = fmt.Sprintf("%s\n\n**Cost confirmed by:** @%s", pending.task.PromptSummary, user)
+	h.createStoreTask(&t)
+	h.handOver(key, pending.task.ID, &t)
+	h.recordAudit(audit.Event{
+		Action:            audit.ActionCostConfirmed,
+		Actor:             user,
+		Repo:              repo,
+		Number:            ghCtx.IssueNumber,
+		TaskID:            t.ID,
+		TriggerCommentID:  ghCtx.TriggerComment.ID,
+		TrackingCommentID: t.CommentID,
+		Detail:            fmt.Sprintf("task %s requested by %s", pending.task.ID, pending.task.Username),
+	})
+	taskLog(&t, phaseEnqueue).Info("Cost confirmed", "number", t.Number, "stopped", pending.task.ID, "user", user)
+	h.enqueueTask(w, &t)
+}
+
+// handOver registers t, the confirmed run of the stopped task stoppedID, as
+// the plan awaiting approval or the dry run awaiting apply on the thread
+// where the stopped task was.
+func (h *Handler) handOver(key, stoppedID string, t *Task) {
+	now := time.Now()
+	if p, ok := h.approvals.get(key, now); ok && p.task.ID == stoppedID {
+		h.approvals.put(key, pendingTask{task: t, expires: now.Add(approvalTTL), held: p.held})
+	}
+	if p, ok := h.dryRuns.get(key, now); ok && p.task.ID == stoppedID {
+		h.dryRuns.put(key, pendingTask{task: t, expires: now.Add(dryRunApplyTTL), held: p.held})
+	}
+}
@@ -0,0 +1,75 @@
+package webhook
+
+import (
+	"net/http"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/cexll/swe/internal/audit"
+	"github.com/cexll/swe/internal/taskstore"
+)
+
+func TestHandleConfirm(t *testing.T) {
+	h, dispatcher, log, posted := releaseHandler(t, nil)
+	h.SetReleaseMode(false)
+	h.SetCostConfirmation(true)
+	h.SetDefaultDryRun(true)
+	h.store = taskstore.NewStore()
+
+	w := postRelease(t, h, 1, "installer", "/code rewrite the monorepo")
+	if w.Code != http.StatusAccepted || dispatcher.enqueueCalls != 1 {
+		t.Fatalf("trigger response = %d %q", w.Code, w.Body.String())
+	}
+	stopped := dispatcher.lastTask
+	if stopped.ConfirmCommand != "/code confirm" {
+		t.Fatalf("task has no confirm command: %+v", stopped)
+	}
+
+	// the estimate did not stop the task (yet)
+	if w := postRelease(t, h, 2, "installer", "/code confirm"); w.Body.String() != "No task awaiting confirmation" || dispatcher.enqueueCalls != 1 {
+		t.Fatalf("confirm before the estimate = %q", w.Body.String())
+	}
+	if last := (*posted)[len(*posted)-1]; !strings.Contains(last, "no task waiting for its cost to be confirmed") {
+		t.Fatalf("reply = %q", last)
+	}
+	if w := postRelease(t, h, 3, "eve", "/code confirm"); w.Body.String() != "Permission denied" {
+		t.Fatalf("confirm by a stranger = %q", w.Body.String())
+	}
+
+	h.store.AwaitConfirmation(stopped.ID, 42)
+	w = postRelease(t, h, 4, "installer", "/code confirm")
+	if w.Code != http.StatusAccepted || dispatcher.enqueueCalls != 2 {
+		t.Fatalf("confirm response = %d %q", w.Code, w.Body.String())
+	}
+	run := dispatcher.lastTask
+	if run.ID == stopped.ID || run.ConfirmCommand != "" || run.ApplyCommand != "/code apply" || run.CommentID != stopped.CommentID ||
+		!strings.HasSuffix(run.PromptSummary, "**Cost confirmed by:** @installer") {
+		t.Fatalf("unexpected confirmed task: %+v", run)
+	}
+	if got, _ := h.store.Get(stopped.ID); got.Confirmation != taskstore.ConfirmationConfirmed || got.ConfirmedBy != "installer" {
+		t.Fatalf("stopped task = %+v", got)
+	}
+	// applying the dry run applies the confirmed run, not the stopped one
+	if p, ok := h.dryRuns.get(threadKey("owner/repo", 9), time.Now()); !ok || p.task.ID != run.ID {
+		t.Fatalf("dry run awaiting apply = %+v, %t", p, ok)
+	}
+
+	if w := postRelease(t, h, 5, "installer", "/code confirm"); w.Body.String() != "No task awaiting confirmation" || dispatcher.enqueueCalls != 2 {
+		t.Fatalf("second confirm = %q", w.Body.String())
+	}
+	if events := log.List(audit.Filter{Action: audit.ActionCostConfirmed}); len(events) != 1 || events[0].Actor != "installer" || events[0].TaskID != run.ID {
+		t.Fatalf("confirm audit = %+v", events)
+	}
+
+	res, _ := simulateRequest(t, h, `{"repo":"owner/repo","user":"installer","body":"/code confirm"}`)
+	if res.Mode != "confirm" || res.WouldEnqueue {
+		t.Fatalf("unexpected simulation: %+v", res)
+	}
+
+	// without cost confirmation the command is an ordinary task
+	h.SetCostConfirmation(false)
+	if w := postRelease(t, h, 6, "installer", "/code confirm"); w.Code != http.StatusAccepted || dispatcher.lastTask.ConfirmCommand != "" {
+		t.Fatalf("cost confirmation off = %d %q", w.Code, w.Body.String())
+	}
+}
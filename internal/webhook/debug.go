@@ -0,0 +1,28 @@
+package webhook
+
+import (
+	"fmt"
+
+	"github.com/cexll/swe/internal/github"
+)
+
+// isRepoAdmin reports whether user has the admin role on ghCtx's
+// repository, which /code --debug requires: a debug run keeps the provider's
+// stderr and git output with the task. Like release permission, it fails
+// closed.
+func isRepoAdmin(ghCtx *github.Context, user string) (bool, string) {
+	if ghCtx.Token == "" {
+		return false, "debug: no installation token to verify the admin role"
+	}
+	role, err := collaboratorRole(ghCtx.Repository.Owner, ghCtx.Repository.Name, user, ghCtx.Token)
+	if err != nil {
+		return false, fmt.Sprintf("debug: role lookup failed: %v", err)
+	}
+	return role == "admin", "debug: role " + role
+}
+
+// debugDeniedText is the reply to a --debug run asked for by someone who is
+// not a repository admin.
+func debugDeniedText(trigger string) string {
+	return fmt.Sprintf("`%s --debug` is limited to repository admins; run the command without `--debug`.", trigger)
+}
@@ -0,0 +1,91 @@
+package webhook
+
+import (
+	"log/slog"
+	"net/http"
+	"strings"
+	"time"
+
+	"github.com/cexll/swe/internal/delivery"
+	"github.com/cexll/swe/internal/logging"
+)
+
+// maxOutcomeLen caps the response message stored as a delivery outcome.
+const maxOutcomeLen = 200
+
+// deliveryRecorder captures the response sent for a delivery so its outcome
+// can be recorded once the handler returns.
+type deliveryRecorder struct {
+	http.ResponseWriter
+	record  delivery.Record
+	started time.Time
+	status  int
+	body    strings.Builder
+}
+
+func (d *deliveryRecorder) WriteHeader(status int) {
+	if d.status == 0 {
+		d.status = status
+	}
+	d.ResponseWriter.WriteHeader(status)
+}
+
+func (d *deliveryRecorder) Write(p []byte) (int, error) {
+	if d.status == 0 {
+		d.status = http.StatusOK
+	}
+	if remaining := maxOutcomeLen - d.body.Len(); remaining > 0 {
+		if len(p) > remaining {
+			d.body.Write(p[:remaining])
+		} else {
+			d.body.Write(p)
+		}
+	}
+	return d.ResponseWriter.Write(p)
+}
+
+// beginDelivery claims the X-GitHub-Delivery GUID. It returns false when the
+// delivery was already processed; otherwise it returns the writer to use for
+// the rest of the request (a recorder when tracking is enabled).
+func (h *Handler) beginDelivery(w http.ResponseWriter, r *http.Request, eventType string) (http.ResponseWriter, bool) {
+	return h.beginDeliveryID(w, strings.TrimSpace(r.Header.Get("X-GitHub-Delivery")), eventType)
+}
+
+// beginDeliveryID is beginDelivery for a delivery identified by id ("" is
+// not tracked).
+func (h *Handler) beginDeliveryID(w http.ResponseWriter, id, eventType string) (http.ResponseWriter, bool) {
+	if h.deliveries == nil || id == "" {
+		return w, true
+	}
+	if prev, ok := h.deliveries.Begin(id, eventType); !ok {
+		slog.Warn("Replayed delivery rejected", logging.KeyDeliveryID, id, logging.KeyPhase, phaseWebhook, "first_received", prev.ReceivedAt.Format(time.RFC3339), "outcome", prev.Outcome)
+		http.Error(w, "Delivery already processed", http.StatusConflict)
+		return w, false
+	}
+	return &deliveryRecorder{
+		ResponseWriter: w,
+		record:         delivery.Record{ID: id, Event: eventType},
+		started:        time.Now(),
+	}, true
+}
+
+// finishDelivery stores the outcome captured by a recorder.
+func (h *Handler) finishDelivery(w http.ResponseWriter) {
+	rec, ok := w.(*deliveryRecorder)
+	if !ok {
+		return
+	}
+	rec.record.Status = rec.status
+	rec.record.Outcome = strings.TrimSpace(rec.body.String())
+	rec.record.Duration = time.Since(rec.started).Round(time.Millisecond).String()
+	if err := h.deliveries.Complete(rec.record); err != nil {
+		slog.Warn("Recording delivery failed", logging.KeyDeliveryID, rec.record.ID, logging.KeyPhase, phaseWebhook, "err", err)
+	}
+}
+
+// annotateDelivery fills delivery details once they are known.
+func annotateDelivery(w http.ResponseWriter, fill func(*delivery.Record)) {
+	if rec, ok := w.(*deliveryRecorder); ok {
+		fill(&rec.record)
+	}
+}
@@ -0,0 +1,182 @@
+package webhook
+
+import (
+	"bytes"
+	"crypto/hmac"
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+
+	"github.com/cexll/swe/internal/delivery"
+)
+
+func signedDelivery(t *testing.T, secret, eventType, deliveryID string, payload []byte) *http.Request {
+	t.Helper()
+	mac := hmac.New(sha256.New, []byte(secret))
+	mac.Write(payload)
+	req := httptest.NewRequest("POST", "/webhook", bytes.NewReader(payload))
+	req.Header.Set("X-Hub-Signature-256", "sha256="+hex.EncodeToString(mac.Sum(nil)))
+	req.Header.Set("X-GitHub-Event", eventType)
+	if deliveryID != "" {
+		req.Header.Set("X-GitHub-Delivery", deliveryID)
+	}
+	return req
+}
+
+func TestHandleWebhook_DeliveryReplayRejected(t *testing.T) {
+	secret := "test-webhook-secret"
+	payload, err := json.Marshal(&IssueCommentEvent{
+		Action:     "created",
+		Issue:      Issue{Number: 12, Title: "Replay"},
+		Comment:    Comment{ID: 4242, Body: "/code go", User: User{Login: "tester", Type: "User"}},
+		Repository: Repository{FullName: "owner/repo", DefaultBranch: "main"},
+		Sender:     User{Login: "tester"},
+	})
+	if err != nil {
+		t.Fatalf("marshal: %v", err)
+	}
+
+	store, _ := delivery.New(delivery.Config{})
+	dispatcher := &mockDispatcher{}
+	handler := NewHandler(secret, "/code", dispatcher, nil, nil)
+	handler.SetDeliveryStore(store)
+
+	w := httptest.NewRecorder()
+	handler.Handle(w, signedDelivery(t, secret, "issue_comment", "guid-1", payload))
+	if w.Code != http.StatusAccepted {
+		t.Fatalf("first delivery status = %d: %s", w.Code, w.Body.String())
+	}
+
+	rec, ok := store.Get("guid-1")
+	if !ok {
+		t.Fatal("delivery was not recorded")
+	}
+	if rec.Status != http.StatusAccepted || rec.Outcome != "Task queued" || rec.Repo != "owner/repo" ||
+		rec.Number != 12 || rec.Action != "created" || rec.Sender != "tester" || rec.TaskID == "" {
+		t.Fatalf("unexpected record: %+v", rec)
+	}
+
+	w = httptest.NewRecorder()
+	handler.Handle(w, signedDelivery(t, secret, "issue_comment", "guid-1", payload))
+	if w.Code != http.StatusConflict || !strings.Contains(w.Body.String(), "Delivery already processed") {
+		t.Fatalf("replay status = %d body = %q", w.Code, w.Body.String())
+	}
+	if dispatcher.enqueueCalls != 1 {
+		t.Fatalf("replay enqueued a task: %d calls", dispatcher.enqueueCalls)
+	}
+	if rec, _ := store.Get("guid-1"); rec.Replays != 1 || rec.LastReplayAt == nil {
+		t.Fatalf("replay not counted: %+v", rec)
+	}
+}
+
+func TestHandleWebhook_DeliveryOutcomeForIgnoredEvents(t *testing.T) {
+	secret := "s3cret"
+	store, _ := delivery.New(delivery.Config{})
+	handler := NewHandler(secret, "/code", &mockDispatcher{}, nil, nil)
+	handler.SetDeliveryStore(store)
+
+	handler.Handle(httptest.NewRecorder(), signedDelivery(t, secret, "ping", "guid-ping", []byte(`{"zen":"hi"}`)))
+	handler.Handle(httptest.NewRecorder(), signedDelivery(t, secret, "issue_comment", "guid-bad", []byte(`{`)))
+
+	rec, ok := store.Get("guid-ping")
+	if !ok || rec.Event != "ping" || rec.Status != http.StatusOK || rec.Outcome != "Event ignored" {
+		t.Fatalf("unexpected ping record: %+v", rec)
+	}
+	rec, ok = store.Get("guid-bad")
+	if !ok || rec.Status != http.StatusBadRequest || rec.Outcome != "Error parsing event" {
+		t.Fatalf("unexpected parse error record: %+v", rec)
+	}
+}
+
+func TestHandleWebhook_DeliveryUnsignedNotRecorded(t *testing.T) {
+	store, _ := delivery.New(delivery.Config{})
+	handler := NewHandler("secret", "/code", &mockDispatcher{}, nil, nil)
+	handler.SetDeliveryStore(store)
+
+	req := signedDelivery(t, "wrong-secret", "issue_comment", "guid-forged", []byte(`{}`))
+	w := httptest.NewRecorder()
+	handler.Handle(w, req)
+	if w.Code != http.StatusUnauthorized {
+		t.Fatalf("status = %d, want 401", w.Code)
+	}
+	if _, ok := store.Get("guid-forged"); ok {
+		t.Fatal("unverified delivery must not claim its GUID")
+	}
+}
+
+func TestHandleWebhook_DeliveryWithoutHeader(t *testing.T) {
+	secret := "s3cret"
+	store, _ := delivery.New(delivery.Config{})
+	handler := NewHandler(secret, "/code", &mockDispatcher{}, nil, nil)
+	handler.SetDeliveryStore(store)
+
+	for i := 0; i < 2; i++ {
+		w := httptest.NewRecorder()
+		handler.Handle(w, signedDelivery(t, secret, "ping", "", []byte(`{}`)))
+		if w.Code != http.StatusOK {
+			t.Fatalf("status = %d, want 200", w.Code)
+		}
+	}
+	if got := store.List(delivery.Filter{}); len(got) != 0 {
+		t.Fatalf("deliveries without GUID should not be tracked: %+v", got)
+	}
+}
+
+// keyedDispatcher remembers the idempotency keys it accepted.
+type keyedDispatcher struct {
+	mockDispatcher
+	accepted map[string]bool
+}
+
+func (k *keyedDispatcher) Accepted(key string) bool { return k.accepted[key] }
+
+func TestHandleWebhook_RedeliveryAfterRestartIgnored(t *testing.T) {
+	secret := "test-webhook-secret"
+	payload, err := json.Marshal(&IssueCommentEvent{
+		Action:     "created",
+		Issue:      Issue{Number: 12, Title: "Redelivery"},
+		Comment:    Comment{ID: 4242, Body: "/code go", User: User{Login: "tester", Type: "User"}},
+		Repository: Repository{FullName: "owner/repo", DefaultBranch: "main"},
+		Sender:     User{Login: "tester"},
+	})
+	if err != nil {
+		t.Fatalf("marshal: %v", err)
+	}
+
+	dispatcher := &keyedDispatcher{accepted: map[string]bool{}}
+	handler := NewHandler(secret, "/code", dispatcher, nil, nil)
+	w := httptest.NewRecorder()
+	handler.Handle(w, signedDelivery(t, secret, "issue_comment", "guid-1", payload))
+	if w.Code != http.StatusAccepted {
+		t.Fatalf("first delivery status = %d: %s", w.Code, w.Body.String())
+	}
+	if key := dispatcher.lastTask.IdempotencyKey; key != "guid-1:4242" {
+		t.Fatalf("idempotency key = %q", key)
+	}
+	dispatcher.accepted["guid-1:4242"] = true
+
+	// a new handler has forgotten the comment, the dispatcher has not
+	handler = NewHandler(secret, "/code", dispatcher, nil, nil)
+	w = httptest.NewRecorder()
+	handler.Handle(w, signedDelivery(t, secret, "issue_comment", "guid-1", payload))
+	if w.Code != http.StatusOK || !strings.Contains(w.Body.String(), "Duplicate comment ignored") {
+		t.Fatalf("redelivery status = %d body = %q", w.Code, w.Body.String())
+	}
+	if dispatcher.enqueueCalls != 1 {
+		t.Fatalf("redelivery enqueued a task: %d calls", dispatcher.enqueueCalls)
+	}
+
+	// the dispatcher has the last word when the check races an Enqueue
+	dispatcher.accepted = map[string]bool{}
+	dispatcher.enqueueFunc = func(*Task) error { return ErrDuplicateTask }
+	handler = NewHandler(secret, "/code", dispatcher, nil, nil)
+	w = httptest.NewRecorder()
+	handler.Handle(w, signedDelivery(t, secret, "issue_comment", "guid-1", payload))
+	if w.Code != http.StatusOK {
+		t.Fatalf("duplicate refused by Enqueue: status = %d body = %q", w.Code, w.Body.String())
+	}
+}
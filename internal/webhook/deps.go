@@ -0,0 +1,14 @@
+package webhook
+
+import "strings"
+
+// updateDepsCommand is the subcommand that updates the repository's
+// dependencies and opens a pull request: "/code update-deps".
+const updateDepsCommand = "update-deps"
+
+// isUpdateDepsCommand reports whether prompt, the text after the trigger,
+// starts with updateDepsCommand.
+func isUpdateDepsCommand(prompt string) bool {
+	fields := subcommandFields(prompt)
+	return len(fields) > 0 && strings.EqualFold(fields[0], updateDepsCommand)
+}
@@ -0,0 +1,32 @@
+package webhook
+
+import "testing"
+
+func TestIsUpdateDepsCommand(t *testing.T) {
+	for prompt, want := range map[string]bool{
+		"update-deps":                true,
+		"Update-Deps":                true,
+		"--timeout 45m update-deps":  true,
+		"update-deps and fix the CI": true,
+		"please update-deps":         false,
+		"update the deps":            false,
+		"":                           false,
+	} {
+		if got := isUpdateDepsCommand(prompt); got != want {
+			t.Errorf("isUpdateDepsCommand(%q) = %t", prompt, got)
+		}
+	}
+}
+
+func TestHandle_UpdateDeps(t *testing.T) {
+	h, dispatcher, _, _ := releaseHandler(t, nil)
+	h.SetReleaseMode(false)
+	h.SetDefaultDryRun(true)
+
+	if w := postRelease(t, h, 1, "installer", "/code update-deps"); dispatcher.enqueueCalls != 1 {
+		t.Fatalf("update-deps = %q", w.Body.String())
+	}
+	if task := dispatcher.lastTask; !task.UpdateDeps || task.ApplyCommand != "" || task.ApprovalCommand != "" {
+		t.Fatalf("task = %+v", task)
+	}
+}
@@ -0,0 +1,136 @@
+package webhook
+
+import (
+	"fmt"
+	"net/http"
+	"strings"
+	"time"
+
+	"github.com/cexll/swe/internal/github"
+	"github.com/cexll/swe/internal/policy"
+)
+
+// dryRunApplyTTL bounds how long a dry run can be applied; the executor keeps
+// its workspace as long.
+const dryRunApplyTTL = 24 * time.Hour
+
+// applyCommand is the comment that pushes a dry run's changes, e.g.
+// "/code apply".
+func applyCommand(trigger string) string {
+	return trigger + " apply"
+}
+
+// isApplyCommand reports whether body is exactly the apply command.
+func isApplyCommand(body, trigger string) bool {
+	return strings.EqualFold(strings.Join(strings.Fields(body), " "), applyCommand(trigger))
+}
+
+// SetDefaultDryRun makes every triggered task a dry run, as if it asked for
+// --dry-run; safe to call while requests are being served.
+func (h *Handler) SetDefaultDryRun(enabled bool) {
+	h.settingsMu.Lock()
+	defer h.settingsMu.Unlock()
+	h.defaultDryRun = enabled
+}
+
+// wantsDryRun reports whether a task triggered by body is a dry run.
+func (h *Handler) wantsDryRun(body string) bool {
+	h.settingsMu.RLock()
+	defer h.settingsMu.RUnlock()
+	return h.defaultDryRun || hasDryRunFlag(body)
+}
+
+// markDryRun makes t a dry run and registers it as the dry run awaiting
+// apply on its thread; held marks one a policy rule asked for.
+func (h *Handler) markDryRun(t *Task, trigger string, held bool) {
+	t.ApplyCommand = applyCommand(trigger)
+	h.dryRuns.put(threadKey(t.Repo, t.Number), pendingTask{task: t, expires: time.Now().Add(dryRunApplyTTL), held: held})
+}
+
+// HoldPushes registers t, a finished task whose pushes a policy rule held,
+// as the dry run awaiting apply on its thread: its commits were kept, and
+// applying them needs the same approval as in approval mode.
+func (h *Handler) HoldPushes(t *Task) {
+	if t.HoldCommand == "" {
+		return
+	}
+	held := *t
+	held.ApplyCommand = t.HoldCommand
+	h.dryRuns.put(threadKey(t.Repo, t.Number), pendingTask{task: &held, expires: time.Now().Add(dryRunApplyTTL), held: true})
+	taskLog(t, phaseEnqueue).Info("Pushes held by policy", "number", t.Number)
+}
+
+// handleApply queues a task that pushes the changes of the thread's latest
+// dry run. The commenter needs the same permission as for the trigger; in
+// approval mode, for changes a policy rule held, or when the policy would
+// hold the commenter's own changes, applying counts as approval instead, so
+// it needs write access and someone other than the requester.
+func (h *Handler) handleApply(w http.ResponseWriter, ghCtx *github.Context, trigger, eventType string) {
+	repo := ghCtx.Repository.FullName
+	if enabled, reason := h.checkRepo(repo); !enabled {
+		h.rejectRepo(ghCtx, reason)
+		w.WriteHeader(http.StatusOK)
+		_, _ = w.Write([]byte("Repository not enabled"))
+		return
+	}
+
+	approval := h.approvalEnabled()
+	if !approval {
+		decision := h.authorize(ghCtx, commandName(trigger))
+		h.recordPermission(ghCtx, decision.Allowed, decision.Reason)
+		if !decision.Allowed {
+			eventLog(ghCtx, phaseAuthorize).Info("Permission denied", "user", ghCtx.TriggerUser, "reason", decision.Reason)
+			h.replyDenied(ghCtx, decision)
+			w.WriteHeader(http.StatusOK)
+			_, _ = w.Write([]byte("Permission denied"))
+			return
+		}
+		approval = decision.Effect == policy.DryRun || decision.Effect == policy.RequireApproval
+	}
+	if !h.getDeduper(eventType).markIfNew(ghCtx.TriggerComment.ID) {
+		w.WriteHeader(http.StatusOK)
+		_, _ = w.Write([]byte("Duplicate comment ignored"))
+		return
+	}
+
+	h.setInstallationToken(ghCtx, "apply")
+
+	key := threadKey(repo, ghCtx.IssueNumber)
+	user := ghCtx.TriggerUser
+	pending, ok := h.dryRuns.get(key, time.Now())
+	if !ok {
+		h.replyThread(ghCtx, "There is no dry run to apply here.")
+		w.WriteHeader(http.StatusOK)
+		_, _ = w.Write([]byte("No pending dry run"))
+		return
+	}
+	if approval || pending.held {
+		if strings.EqualFold(user, pending.task.Username) {
+			h.recordPermission(ghCtx, false, "approval: requester cannot apply their own dry run")
+			h.replyThread(ghCtx, fmt.Sprintf("@%s the dry run must be applied by someone other than its requester.", user))
+			w.WriteHeader(http.StatusOK)
+			_, _ = w.Write([]byte("Permission denied"))
+			return
+		}
+		allowed, reason := hasWriteAccess(ghCtx, user)
+		h.recordPermission(ghCtx, allowed, reason)
+		if !allowed {
+			h.replyThread(ghCtx, fmt.Sprintf("@%s applying a dry run needs write access to this repository.", user))
+			w.WriteHeader(http.StatusOK)
+			_, _ = w.Write([]byte("Permission denied"))
+			return
+		}
+	}
+	h.dryRuns.remove(key, pending.task.ID)
+
+	t := *pending.task
+	t.ID = h.generateTaskID(t.Repo, t.Number)
+	t.Attempt = 0
+	t.Username = user
+	t.ApplyCommand = ""
+	t.Applies = pending.task.ID
+	t.PromptSummary = fmt.Sprintf("%s\n\n**Dry run applied by:** @%s", pending.task.PromptSummary, user)
+	h.createStoreTask(&t)
+	taskLog(&t, phaseEnqueue).Info("Dry run applied", "number", t.Number, "dry_run", pending.task.ID, "user", user)
+	h.enqueueTask(w, &t)
+}
@@ -0,0 +1,87 @@
+package webhook
+
+import (
+	"net/http"
+	"strings"
+	"testing"
+)
+
+func TestIsApplyCommand(t *testing.T) {
+	tests := map[string]bool{
+		"/code apply":               true,
+		" /Code  APPLY\n":           true,
+		"/code apply the migration": false,
+		"/code --dry-run apply it":  false,
+	}
+	for body, want := range tests {
+		if got := isApplyCommand(body, "/code"); got != want {
+			t.Errorf("isApplyCommand(%q) = %t, want %t", body, got, want)
+		}
+	}
+}
+
+func TestHandleApply(t *testing.T) {
+	h, dispatcher, _, posted := releaseHandler(t, map[string]string{"installer": "admin", "bob": "write"})
+	h.SetReleaseMode(false)
+
+	if w := postRelease(t, h, 1, "installer", "/code apply"); w.Body.String() != "No pending dry run" {
+		t.Fatalf("apply without a dry run = %q", w.Body.String())
+	}
+	if last := (*posted)[len(*posted)-1]; !strings.Contains(last, "no dry run to apply") {
+		t.Fatalf("reply = %q", last)
+	}
+
+	w := postRelease(t, h, 2, "installer", "/code --dry-run fix the parser")
+	if w.Code != http.StatusAccepted || dispatcher.enqueueCalls != 1 {
+		t.Fatalf("trigger response = %d %q", w.Code, w.Body.String())
+	}
+	dry := dispatcher.lastTask
+	if dry.ApplyCommand != "/code apply" || dry.Applies != "" {
+		t.Fatalf("task should be a dry run: %+v", dry)
+	}
+
+	if w := postRelease(t, h, 3, "bob", "/code apply"); w.Body.String() != "Permission denied" || dispatcher.enqueueCalls != 1 {
+		t.Fatalf("apply without trigger permission = %q", w.Body.String())
+	}
+
+	w = postRelease(t, h, 4, "installer", "/code apply")
+	if w.Code != http.StatusAccepted || dispatcher.enqueueCalls != 2 {
+		t.Fatalf("apply response = %d %q", w.Code, w.Body.String())
+	}
+	run := dispatcher.lastTask
+	if run.ID == dry.ID || run.ApplyCommand != "" || run.Applies != dry.ID || run.CommentID != dry.CommentID ||
+		!strings.HasSuffix(run.PromptSummary, "**Dry run applied by:** @installer") {
+		t.Fatalf("unexpected apply task: %+v", run)
+	}
+
+	if w := postRelease(t, h, 5, "installer", "/code apply"); w.Body.String() != "No pending dry run" || dispatcher.enqueueCalls != 2 {
+		t.Fatalf("second apply = %q", w.Body.String())
+	}
+
+	h.SetDefaultDryRun(true)
+	if w := postRelease(t, h, 6, "installer", "/code fix the lexer"); w.Code != http.StatusAccepted || dispatcher.lastTask.ApplyCommand != "/code apply" {
+		t.Fatalf("default dry run = %d %+v", w.Code, dispatcher.lastTask)
+	}
+}
+
+func TestHandleApply_ApprovalMode(t *testing.T) {
+	h, dispatcher, _, _ := releaseHandler(t, map[string]string{"installer": "admin", "bob": "write", "eve": "read"})
+	h.SetReleaseMode(false)
+	h.SetApprovalMode(true)
+
+	if w := postRelease(t, h, 1, "installer", "/code --dry-run fix the parser"); w.Code != http.StatusAccepted {
+		t.Fatalf("trigger response = %d %q", w.Code, w.Body.String())
+	}
+	if dry := dispatcher.lastTask; dry.ApplyCommand == "" || dry.ApprovalCommand != "" {
+		t.Fatalf("a dry run should not also be plan-only: %+v", dry)
+	}
+
+	for i, user := range []string{"installer", "eve"} {
+		if w := postRelease(t, h, int64(2+i), user, "/code apply"); w.Body.String() != "Permission denied" || dispatcher.enqueueCalls != 1 {
+			t.Fatalf("apply by %s = %q", user, w.Body.String())
+		}
+	}
+	if w := postRelease(t, h, 4, "bob", "/code apply"); w.Code != http.StatusAccepted || dispatcher.lastTask.Username != "bob" {
+		t.Fatalf("apply by another writer = %d %q", w.Code, w.Body.String())
+	}
+}
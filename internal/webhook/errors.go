@@ -7,4 +7,10 @@ var (
 	ErrQueueFull = errors.New("task queue is full")
 	// ErrQueueClosed indicates the dispatcher has been shut down.
 	ErrQueueClosed = errors.New("task queue is closed")
+	// ErrDuplicateTask indicates a task with the same idempotency key was
+	// already accepted.
+	ErrDuplicateTask = errors.New("task already accepted")
+	// ErrBudgetExhausted indicates the task's organization reached the hard
+	// cap of its monthly budget.
+	ErrBudgetExhausted = errors.New("monthly budget exhausted")
 )
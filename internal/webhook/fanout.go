@@ -0,0 +1,244 @@
+package webhook
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"log/slog"
+	"net/http"
+	"regexp"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/cexll/swe/internal/github"
+	"github.com/cexll/swe/internal/logging"
+	"github.com/cexll/swe/internal/taskstore"
+)
+
+// maxFanOutRepos bounds the repositories of one fan-out.
+const maxFanOutRepos = 50
+
+// allow tests to stub comment updates
+var updateComment = github.UpdateComment
+
+// originPattern matches the issue a fan-out reports to: owner/repo#123.
+var originPattern = regexp.MustCompile(`^([^/\s#]+/[^/\s#]+)#(\d+)$`)
+
+// FanOutRequest is the body accepted by POST /api/v1/fanout: one prompt
+// launched across several repositories, for org-wide changes such as
+// bumping a shared library.
+type FanOutRequest struct {
+	Repos      []string `json:"repos"`                 // owner/name, one child task each
+	Prompt     string   `json:"prompt"`                // instruction, as written after the trigger keyword
+	Title      string   `json:"title"`                 // of the issue opened in each repository
+	Labels     []string `json:"labels,omitempty"`      // added to each issue
+	BaseBranch string   `json:"base_branch,omitempty"` // defaults to each repository's default branch
+	// Origin is the issue that gets the combined summary once every child
+	// has finished, as owner/repo#123
+	Origin string `json:"origin,omitempty"`
+	Actor  string `json:"actor,omitempty"` // operator recorded in the tasks and audit log
+}
+
+// FanOutResponse is returned when a fan-out is launched.
+type FanOutResponse struct {
+	GroupID string                 `json:"group_id"`
+	URL     string                 `json:"url"`
+	Tasks   []taskstore.GroupChild `json:"tasks"`
+}
+
+func (req *FanOutRequest) validate() error {
+	req.Prompt = strings.TrimSpace(req.Prompt)
+	req.Title = strings.TrimSpace(req.Title)
+	req.Origin = strings.TrimSpace(req.Origin)
+	req.Actor = strings.TrimSpace(req.Actor)
+	if req.Prompt == "" {
+		return errors.New("prompt is required")
+	}
+	if req.Title == "" {
+		return errors.New("title is required")
+	}
+	if len(req.Repos) == 0 {
+		return errors.New("repos is required")
+	}
+	if len(req.Repos) > maxFanOutRepos {
+		return fmt.Errorf("at most %d repos per fan-out", maxFanOutRepos)
+	}
+	seen := make(map[string]bool, len(req.Repos))
+	for i, repo := range req.Repos {
+		repo = strings.TrimSpace(repo)
+		owner, name := splitRepo(repo)
+		if owner == "" || name == "" || strings.Contains(name, "/") {
+			return fmt.Errorf("repo %q must be in owner/name form", repo)
+		}
+		if seen[strings.ToLower(repo)] {
+			return fmt.Errorf("repo %s is listed twice", repo)
+		}
+		seen[strings.ToLower(repo)] = true
+		req.Repos[i] = repo
+	}
+	if req.Origin != "" && !originPattern.MatchString(req.Origin) {
+		return errors.New("origin must be in owner/repo#number form")
+	}
+	if req.Actor == "" {
+		req.Actor = defaultManualActor
+	}
+	return nil
+}
+
+// SubmitFanOut launches a fan-out: it opens an issue in every listed
+// repository and queues the prompt on each as a child task of one group.
+// Repositories that cannot be launched are reported, not fatal.
+func (h *Handler) SubmitFanOut(w http.ResponseWriter, r *http.Request) {
+	if h.apiToken == "" {
+		http.Error(w, "fan-out API disabled (API_TOKEN not set)", http.StatusServiceUnavailable)
+		return
+	}
+	if !h.authorizedOperator(r) {
+		w.Header().Set("WWW-Authenticate", `Bearer realm="swe-agent"`)
+		http.Error(w, "unauthorized", http.StatusUnauthorized)
+		return
+	}
+	if h.store == nil {
+		http.Error(w, "task store unavailable", http.StatusServiceUnavailable)
+		return
+	}
+
+	var req FanOutRequest
+	if err := json.NewDecoder(http.MaxBytesReader(w, r.Body, 1<<20)).Decode(&req); err != nil {
+		http.Error(w, "invalid JSON body", http.StatusBadRequest)
+		return
+	}
+	if err := req.validate(); err != nil {
+		http.Error(w, err.Error(), http.StatusBadRequest)
+		return
+	}
+
+	group := h.launchFanOut(r.Context(), req)
+	slog.InfoContext(r.Context(), "Fan-out launched", logging.KeyPhase, phaseEnqueue, "group", group.ID, "repositories", len(req.Repos), "origin", req.Origin, "user", req.Actor)
+
+	url := "/groups/" + group.ID
+	w.Header().Set("Content-Type", "application/json")
+	w.Header().Set("Location", url)
+	w.WriteHeader(http.StatusAccepted)
+	_ = json.NewEncoder(w).Encode(FanOutResponse{GroupID: group.ID, URL: url, Tasks: group.Children})
+}
+
+// launchFanOut records the group and launches its children one by one.
+func (h *Handler) launchFanOut(ctx context.Context, req FanOutRequest) *taskstore.Group {
+	group := &taskstore.Group{
+		ID:     fmt.Sprintf("fanout-%d", time.Now().UnixNano()),
+		Title:  req.Title,
+		Prompt: req.Prompt,
+		Actor:  req.Actor,
+		Origin: req.Origin,
+	}
+	h.store.CreateGroup(group)
+
+	body := fanOutIssueBody(req)
+	summary := fmt.Sprintf("**Fan-out:** %s (%d repositories)", req.Title, len(req.Repos))
+	for _, repo := range req.Repos {
+		child := taskstore.GroupChild{Repo: repo}
+		task := ManualTaskRequest{Repo: repo, Prompt: req.Prompt, BaseBranch: req.BaseBranch, Actor: req.Actor}
+		t, number, err := h.launchOnNewIssue(ctx, task, req.Title, body, req.Labels, summary)
+		child.Issue = number
+		if err != nil {
+			slog.WarnContext(ctx, "Fan-out task not launched", logging.KeyRepo, repo, logging.KeyPhase, phaseEnqueue, "group", group.ID, "err", err)
+			child.Error = err.Error()
+		} else {
+			child.TaskID = t.ID
+		}
+		h.store.AddGroupChild(group.ID, child)
+	}
+	h.store.SealGroup(group.ID)
+	// the store owns the group now; the children are what the caller needs
+	launched, _ := h.store.Group(group.ID)
+	return launched.Group
+}
+
+// fanOutIssueBody explains where each child issue came from and quotes the
+// task.
+func fanOutIssueBody(req FanOutRequest) string {
+	var b strings.Builder
+	b.WriteString("Opened by a change across several repositories")
+	if req.Origin != "" {
+		fmt.Fprintf(&b, ", tracked in %s", req.Origin)
+	}
+	b.WriteString(". Progress is reported below; changes come as a pull request.\n\n**Task:**\n\n")
+	for _, line := range strings.Split(req.Prompt, "\n") {
+		b.WriteString(strings.TrimRight("> "+line, " ") + "\n")
+	}
+	return b.String()
+}
+
+// ReportGroup posts the combined summary of a finished fan-out to its
+// origin issue, or updates it when a retried child finishes the group
+// again. It is the task store's group done hook.
+func (h *Handler) ReportGroup(groupID string) {
+	if h.store == nil || h.appAuth == nil {
+		return
+	}
+	group, ok := h.store.Group(groupID)
+	if !ok || group.Origin == "" {
+		return
+	}
+	m := originPattern.FindStringSubmatch(group.Origin)
+	if m == nil {
+		return
+	}
+	repo := m[1]
+	number, _ := strconv.Atoi(m[2])
+	token, err := h.appAuth.GetInstallationToken(repo)
+	if err != nil {
+		slog.Warn("Getting installation token for the fan-out summary failed", logging.KeyRepo, repo, logging.KeyPhase, phaseReply, "group", groupID, "err", err)
+		return
+	}
+	owner, name := splitRepo(repo)
+	body := groupSummary(group)
+	if group.SummaryCommentID != 0 {
+		if err := updateComment(owner, name, group.SummaryCommentID, body, token.Token); err != nil {
+			slog.Warn("Updating the fan-out summary failed", logging.KeyRepo, repo, logging.KeyPhase, phaseReply, "group", groupID, "err", err)
+		}
+		return
+	}
+	id, err := createComment(owner, name, number, body, token.Token)
+	if err != nil {
+		slog.Warn("Posting the fan-out summary failed", logging.KeyRepo, repo, logging.KeyPhase, phaseReply, "group", groupID, "err", err)
+		return
+	}
+	h.store.SetGroupSummaryComment(groupID, id)
+}
+
+// groupSummary is the combined summary comment of a finished fan-out.
+func groupSummary(group taskstore.GroupStatus) string {
+	var b strings.Builder
+	fmt.Fprintf(&b, "### %s\n\n", group.Title)
+	fmt.Fprintf(&b, "Finished in %d repositories: %d completed, %d failed.\n\n",
+		len(group.Children), group.Counts[taskstore.StatusCompleted], group.Counts[taskstore.StatusFailed])
+	b.WriteString("| Repository | Result | Issue |\n| --- | --- | --- |\n")
+	for _, c := range group.Children {
+		result := "✅ completed"
+		if c.Status == taskstore.StatusFailed {
+			result = "❌ failed"
+			if detail := firstLine(c.Detail); detail != "" {
+				result += ": " + strings.ReplaceAll(detail, "|", `\|`)
+			}
+		}
+		issue := "—"
+		if c.Issue != 0 {
+			issue = fmt.Sprintf("%s#%d", c.Repo, c.Issue)
+		}
+		fmt.Fprintf(&b, "| %s | %s | %s |\n", c.Repo, result, issue)
+	}
+	return b.String()
+}
+
+// firstLine returns the first line of s, shortened to 200 bytes.
+func firstLine(s string) string {
+	s, _, _ = strings.Cut(strings.TrimSpace(s), "\n")
+	if len(s) > 200 {
+		s = s[:200] + "..."
+	}
+	return s
+}
@@ -0,0 +1,134 @@
+package webhook
+
+import (
+	"encoding/json"
+	"errors"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+
+	"github.com/cexll/swe/internal/github"
+	"github.com/cexll/swe/internal/taskstore"
+)
+
+func TestFanOutRequest_Validate(t *testing.T) {
+	cases := map[string]FanOutRequest{
+		"no prompt":   {Repos: []string{"o/a"}, Title: "t"},
+		"no title":    {Repos: []string{"o/a"}, Prompt: "p"},
+		"no repos":    {Title: "t", Prompt: "p"},
+		"bad repo":    {Repos: []string{"o/a/b"}, Title: "t", Prompt: "p"},
+		"duplicate":   {Repos: []string{"o/a", "O/A"}, Title: "t", Prompt: "p"},
+		"bad origin":  {Repos: []string{"o/a"}, Title: "t", Prompt: "p", Origin: "o/meta"},
+		"many repos":  {Repos: make([]string, maxFanOutRepos+1), Title: "t", Prompt: "p"},
+		"empty repo":  {Repos: []string{" "}, Title: "t", Prompt: "p"},
+		"origin path": {Repos: []string{"o/a"}, Title: "t", Prompt: "p", Origin: "o/m/x#1"},
+	}
+	for name, req := range cases {
+		if err := req.validate(); err == nil {
+			t.Errorf("%s: validate succeeded", name)
+		}
+	}
+	req := FanOutRequest{Repos: []string{" o/a "}, Title: "t", Prompt: "p", Origin: "o/meta#7"}
+	if err := req.validate(); err != nil || req.Repos[0] != "o/a" || req.Actor != defaultManualActor {
+		t.Fatalf("validate = %v, %+v", err, req)
+	}
+}
+
+func TestSubmitFanOut_LaunchesAndReports(t *testing.T) {
+	origIssue, origComment, origUpdate := createIssue, createComment, updateComment
+	t.Cleanup(func() { createIssue, createComment, updateComment = origIssue, origComment, origUpdate })
+	var issueBody string
+	createIssue = func(owner, repo, title, body string, labels []string, token string) (int, error) {
+		if repo == "broken" {
+			return 0, errors.New("issues are disabled")
+		}
+		if title != "Bump lib to v2" || labels[0] != "deps" {
+			t.Errorf("issue %q labeled %v", title, labels)
+		}
+		issueBody = body
+		return 41, nil
+	}
+	var summary string
+	var summaryOn int
+	createComment = func(owner, repo string, number int, body, _ string) (int64, error) {
+		if owner+"/"+repo != "owner/meta" {
+			t.Errorf("comment posted to %s/%s", owner, repo)
+		}
+		summaryOn, summary = number, body
+		return 99, nil
+	}
+	var updated int64
+	updateComment = func(_, _ string, id int64, body, _ string) error {
+		updated, summary = id, body
+		return nil
+	}
+
+	store := taskstore.NewStore()
+	dispatcher := &mockDispatcher{}
+	auth := &mockAppAuth{GetInstallationTokenFunc: func(repo string) (*github.InstallationToken, error) {
+		return &github.InstallationToken{Token: "inst-token"}, nil
+	}}
+	handler := NewHandler("secret", "/code", dispatcher, store, auth)
+	handler.SetAPIToken("op-token")
+	store.SetGroupDoneHook(handler.ReportGroup)
+
+	w := httptest.NewRecorder()
+	handler.SubmitFanOut(w, manualRequest("op-token",
+		`{"repos": ["owner/repo", "owner/broken"], "title": "Bump lib to v2", "prompt": "bump lib to v2 and fix the build", "labels": ["deps"], "origin": "owner/meta#7"}`))
+	if w.Code != http.StatusAccepted {
+		t.Fatalf("status = %d: %s", w.Code, w.Body.String())
+	}
+	var resp FanOutResponse
+	if err := json.Unmarshal(w.Body.Bytes(), &resp); err != nil {
+		t.Fatal(err)
+	}
+	if len(resp.Tasks) != 2 || resp.Tasks[0].Issue != 41 || resp.Tasks[0].TaskID == "" || resp.Tasks[1].Error == "" ||
+		w.Header().Get("Location") != "/groups/"+resp.GroupID {
+		t.Fatalf("response = %+v", resp)
+	}
+	if dispatcher.enqueueCalls != 1 || !strings.Contains(issueBody, "tracked in owner/meta#7") || !strings.Contains(issueBody, "> bump lib to v2 and fix the build") {
+		t.Fatalf("enqueued %d, issue body:\n%s", dispatcher.enqueueCalls, issueBody)
+	}
+	taskID := resp.Tasks[0].TaskID
+	if task, _ := store.Get(taskID); task.Group != resp.GroupID {
+		t.Fatalf("task group = %q", task.Group)
+	}
+	if summary != "" {
+		t.Fatalf("summary posted with a child pending:\n%s", summary)
+	}
+
+	store.AddLog(taskID, "error", "build failed\nsee logs")
+	store.UpdateStatus(taskID, taskstore.StatusFailed)
+	if summaryOn != 7 {
+		t.Fatalf("summary posted on #%d", summaryOn)
+	}
+	for _, want := range []string{"### Bump lib to v2", "0 completed, 2 failed", "| owner/repo | ❌ failed: build failed | owner/repo#41 |",
+		"| owner/broken | ❌ failed: open issue: issues are disabled | — |"} {
+		if !strings.Contains(summary, want) {
+			t.Fatalf("summary lacks %q:\n%s", want, summary)
+		}
+	}
+
+	// a retry that succeeds updates the same comment
+	store.UpdateStatus(taskID, taskstore.StatusRunning)
+	store.UpdateStatus(taskID, taskstore.StatusCompleted)
+	if updated != 99 || !strings.Contains(summary, "1 completed, 1 failed") || !strings.Contains(summary, "| owner/repo | ✅ completed |") {
+		t.Fatalf("updated comment %d:\n%s", updated, summary)
+	}
+}
+
+func TestSubmitFanOut_NeedsToken(t *testing.T) {
+	handler := NewHandler("secret", "/code", &mockDispatcher{}, taskstore.NewStore(), nil)
+	w := httptest.NewRecorder()
+	handler.SubmitFanOut(w, manualRequest("x", `{}`))
+	if w.Code != http.StatusServiceUnavailable {
+		t.Fatalf("status = %d", w.Code)
+	}
+	handler.SetAPIToken("op-token")
+	w = httptest.NewRecorder()
+	handler.SubmitFanOut(w, manualRequest("wrong", `{}`))
+	if w.Code != http.StatusUnauthorized {
+		t.Fatalf("status = %d", w.Code)
+	}
+}
@@ -0,0 +1,49 @@
+package webhook
+
+import (
+	"log/slog"
+	"regexp"
+	"strconv"
+	"time"
+
+	"github.com/cexll/swe/internal/logging"
+)
+
+// timeoutFlagPattern matches `--timeout 45m` (or --timeout=1h30m) in a command.
+var timeoutFlagPattern = regexp.MustCompile(`(?i)(?:^|\s)--timeout[=\s]+(\S+)`)
+
+// parseTimeoutFlag returns the run time a command asks for with --timeout:
+// a Go duration such as 45m or 1h30m, or plain minutes. It returns 0 when
+// the flag is absent or invalid; the executor caps it at the configured
+// maximum.
+func parseTimeoutFlag(body string) time.Duration {
+	m := timeoutFlagPattern.FindStringSubmatch(body)
+	if m == nil {
+		return 0
+	}
+	if n, err := strconv.Atoi(m[1]); err == nil && n > 0 {
+		return time.Duration(n) * time.Minute
+	}
+	d, err := time.ParseDuration(m[1])
+	if err != nil || d <= 0 {
+		slog.Warn("Ignoring invalid --timeout", logging.KeyPhase, phaseWebhook, "value", m[1])
+		return 0
+	}
+	return d
+}
+
+// dryRunFlagPattern matches `--dry-run` in a command.
+var dryRunFlagPattern = regexp.MustCompile(`(?i)(?:^|\s)--dry-run(?:\s|$)`)
+
+// hasDryRunFlag reports whether a command asks for a dry run (--dry-run).
+func hasDryRunFlag(body string) bool {
+	return dryRunFlagPattern.MatchString(body)
+}
+
+// debugFlagPattern matches `--debug` in a command.
+var debugFlagPattern = regexp.MustCompile(`(?i)(?:^|\s)--debug(?:\s|$)`)
+
+// hasDebugFlag reports whether a command asks for a debug run (--debug).
+func hasDebugFlag(body string) bool {
+	return debugFlagPattern.MatchString(body)
+}
@@ -0,0 +1,107 @@
+package webhook
+
+import (
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/cexll/swe/internal/reposettings"
+)
+
+func TestParseTimeoutFlag(t *testing.T) {
+	tests := []struct {
+		body string
+		want time.Duration
+	}{
+		{"/code fix it", 0},
+		{"/code --timeout 45m fix it", 45 * time.Minute},
+		{"/code --timeout=1h30m migrate", 90 * time.Minute},
+		{"/code --TIMEOUT 90 migrate", 90 * time.Minute},
+		{"/code --timeout soon", 0},
+		{"/code --timeout -5m", 0},
+		{"/code see foo--timeout 5m", 0},
+	}
+	for _, tt := range tests {
+		if got := parseTimeoutFlag(tt.body); got != tt.want {
+			t.Errorf("parseTimeoutFlag(%q) = %v, want %v", tt.body, got, tt.want)
+		}
+	}
+}
+
+func TestHasDryRunFlag(t *testing.T) {
+	tests := map[string]bool{
+		"/code fix it":                  false,
+		"/code --dry-run fix it":        true,
+		"/code fix it --DRY-RUN":        true,
+		"/code --dry-runner fix it":     false,
+		"/code see docs/--dry-run flag": false,
+	}
+	for body, want := range tests {
+		if got := hasDryRunFlag(body); got != want {
+			t.Errorf("hasDryRunFlag(%q) = %t, want %t", body, got, want)
+		}
+	}
+}
+
+func TestParsePriorityFlag(t *testing.T) {
+	tests := []struct {
+		body string
+		want Priority
+		ok   bool
+	}{
+		{"/code fix it", PriorityNormal, false},
+		{"/code --priority high fix it", PriorityHigh, true},
+		{"/code --PRIORITY=Low sweep", PriorityLow, true},
+		{"/code --priority normal fix it", PriorityNormal, true},
+		{"/code --priority urgent fix it", PriorityNormal, false},
+	}
+	for _, tt := range tests {
+		if got, ok := parsePriorityFlag(tt.body); got != tt.want || ok != tt.ok {
+			t.Errorf("parsePriorityFlag(%q) = %v, %t, want %v, %t", tt.body, got, ok, tt.want, tt.ok)
+		}
+	}
+}
+
+func TestHandle_TaskPriority(t *testing.T) {
+	h, dispatcher, _, _ := releaseHandler(t, nil)
+	postRelease(t, h, 1, "installer", "/code fix the parser")
+	if got := dispatcher.lastTask.Priority; got != PriorityNormal {
+		t.Fatalf("issue comment priority = %v", got)
+	}
+	postRelease(t, h, 2, "installer", "/code --priority high fix the parser")
+	if got := dispatcher.lastTask.Priority; got != PriorityHigh {
+		t.Fatalf("--priority high = %v", got)
+	}
+
+	settings, err := reposettings.Parse([]byte(`{"owner/repo": {"priority": "low"}}`))
+	if err != nil {
+		t.Fatal(err)
+	}
+	h.SetRepoSettings(settings)
+	postRelease(t, h, 3, "installer", "/code fix the parser")
+	if got := dispatcher.lastTask.Priority; got != PriorityLow {
+		t.Fatalf("repository setting = %v", got)
+	}
+}
+
+func TestHandle_DebugFlagNeedsAdmin(t *testing.T) {
+	roles := map[string]string{"installer": "write"}
+	h, dispatcher, _, posted := releaseHandler(t, roles)
+
+	if w := postRelease(t, h, 1, "installer", "/code --debug fix the parser"); w.Body.String() != "Debug run denied" || dispatcher.enqueueCalls != 0 {
+		t.Fatalf("writer response = %q, enqueued %d", w.Body.String(), dispatcher.enqueueCalls)
+	}
+	if last := (*posted)[len(*posted)-1]; !strings.Contains(last, "`/code --debug` is limited to repository admins") {
+		t.Fatalf("reply = %q", last)
+	}
+
+	roles["installer"] = "admin"
+	postRelease(t, h, 2, "installer", "/code --debug fix the parser")
+	if dispatcher.enqueueCalls != 1 || !dispatcher.lastTask.Debug {
+		t.Fatalf("admin debug run: enqueued %d, task %+v", dispatcher.enqueueCalls, dispatcher.lastTask)
+	}
+	postRelease(t, h, 3, "installer", "/code fix the parser")
+	if dispatcher.enqueueCalls != 2 || dispatcher.lastTask.Debug {
+		t.Fatalf("plain run: enqueued %d, debug %t", dispatcher.enqueueCalls, dispatcher.lastTask.Debug)
+	}
+}
@@ -0,0 +1,179 @@
+package webhook
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"log/slog"
+	"net/http"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/cexll/swe/internal/delivery"
+	"github.com/cexll/swe/internal/hmacsig"
+	"github.com/cexll/swe/internal/logging"
+)
+
+// Headers of a generic webhook request.
+const (
+	// GenericSignatureHeader carries "sha256=<hex>", the HMAC-SHA256 of
+	// "<timestamp>.<body>" keyed with the generic webhook secret.
+	GenericSignatureHeader = "X-Signature-256"
+	// GenericTimestampHeader carries the Unix time the request was signed
+	// at; a request signed more than genericMaxAge away from now is refused
+	// as a replay.
+	GenericTimestampHeader = "X-Timestamp"
+	// GenericDeliveryHeader optionally names the delivery; one already
+	// received is refused, so a sender can retry safely.
+	GenericDeliveryHeader = "X-Delivery-ID"
+)
+
+// EventNameGeneric is the event recorded for generic webhook deliveries.
+const EventNameGeneric = "generic"
+
+// genericMaxAge is how old a signed generic request may be.
+const genericMaxAge = 5 * time.Minute
+
+// defaultGenericSource is recorded as the trigger user when a generic
+// request does not name its source.
+const defaultGenericSource = "webhook"
+
+// GenericTaskRequest is the body accepted by POST /webhook/generic.
+type GenericTaskRequest struct {
+	Repo   string `json:"repo"`             // owner/name
+	Number int    `json:"number"`           // issue or PR number
+	Prompt string `json:"prompt"`           // instruction, as written after the trigger keyword
+	Branch string `json:"branch,omitempty"` // base branch; defaults to the executor fallback (main)
+	IsPR   bool   `json:"is_pr,omitempty"`  // number refers to a pull request
+	// Source names the sending tool ("jira", "chatops"); it is recorded as
+	// the task's trigger user
+	Source string `json:"source,omitempty"`
+}
+
+// SetGenericWebhookSecret enables POST /webhook/generic; requests must be
+// signed with secret.
+func (h *Handler) SetGenericWebhookSecret(secret string) {
+	h.genericSecret = secret
+}
+
+// HandleGeneric launches a task for a tool that is not GitHub, such as a
+// Jira automation or a chatops bot. The request is signed like a GitHub
+// webhook, over its timestamp as well as its body so it cannot be replayed
+// later, and carries a GenericTaskRequest, which goes the way of a manual
+// task.
+func (h *Handler) HandleGeneric(w http.ResponseWriter, r *http.Request) {
+	if h.genericSecret == "" {
+		http.Error(w, "generic webhook disabled (GENERIC_WEBHOOK_SECRET not set)", http.StatusServiceUnavailable)
+		return
+	}
+	payload, err := io.ReadAll(http.MaxBytesReader(w, r.Body, 1<<20))
+	if err != nil {
+		http.Error(w, "Error reading payload", http.StatusBadRequest)
+		return
+	}
+	timestamp, signature := r.Header.Get(GenericTimestampHeader), r.Header.Get(GenericSignatureHeader)
+	if !validGenericSignature(payload, timestamp, signature, h.genericSecret, time.Now()) {
+		slog.WarnContext(r.Context(), "Generic webhook signature verification failed", logging.KeyPhase, phaseWebhook)
+		http.Error(w, "Invalid signature", http.StatusUnauthorized)
+		return
+	}
+
+	deliveryID := strings.TrimSpace(r.Header.Get(GenericDeliveryHeader))
+	w, fresh := h.beginDeliveryID(w, deliveryID, EventNameGeneric)
+	if !fresh {
+		return
+	}
+	defer h.finishDelivery(w)
+
+	var body GenericTaskRequest
+	if err := json.Unmarshal(payload, &body); err != nil {
+		http.Error(w, "invalid JSON body", http.StatusBadRequest)
+		return
+	}
+	req := body.manualRequest()
+	if err := req.validate(); err != nil {
+		http.Error(w, err.Error(), http.StatusBadRequest)
+		return
+	}
+	annotateDelivery(w, func(rec *delivery.Record) {
+		rec.Repo = req.Repo
+		rec.Number = req.Number
+		rec.Sender = req.Actor
+	})
+	if enabled, reason := h.checkRepo(req.Repo); !enabled {
+		http.Error(w, fmt.Sprintf("%s (%s)", RepoNotEnabledMessage, reason), http.StatusForbidden)
+		return
+	}
+	key := genericIdempotencyKey(deliveryID)
+	if h.alreadyAccepted(key) {
+		w.WriteHeader(http.StatusOK)
+		_, _ = w.Write([]byte("Duplicate delivery ignored"))
+		return
+	}
+
+	t, err := h.manualTask(logging.With(r.Context(), logging.KeyDeliveryID, deliveryID), req)
+	if errors.Is(err, errBuildManualTask) {
+		http.Error(w, "failed to build task", http.StatusInternalServerError)
+		return
+	}
+	if err != nil {
+		slog.ErrorContext(r.Context(), "Preparing generic webhook task failed", logging.KeyRepo, req.Repo, logging.KeyPhase, phaseEnqueue, "err", err)
+		http.Error(w, "Task preparation failed", http.StatusInternalServerError)
+		return
+	}
+	t.IdempotencyKey = key
+	if err := h.dispatchTask(t); err != nil {
+		status, msg := enqueueErrorStatus(err)
+		http.Error(w, msg, status)
+		return
+	}
+
+	annotateDelivery(w, func(rec *delivery.Record) { rec.TaskID = t.ID })
+	taskLog(t, phaseEnqueue).Info("Generic webhook task queued", "number", t.Number, "source", t.Username)
+
+	w.Header().Set("Content-Type", "application/json")
+	w.Header().Set("Location", "/tasks/"+t.ID)
+	w.WriteHeader(http.StatusAccepted)
+	_ = json.NewEncoder(w).Encode(ManualTaskResponse{TaskID: t.ID, Status: "queued", URL: "/tasks/" + t.ID})
+}
+
+// validGenericSignature checks the "sha256=<hex>" HMAC-SHA256 of
+// "<timestamp>.<body>" keyed with secret, and that the request was signed
+// recently.
+func validGenericSignature(payload []byte, timestamp, signature, secret string, now time.Time) bool {
+	sec, err := strconv.ParseInt(timestamp, 10, 64)
+	if err != nil {
+		return false
+	}
+	if age := now.Sub(time.Unix(sec, 0)); age > genericMaxAge || age < -genericMaxAge {
+		return false
+	}
+	return hmacsig.ValidPrefixed(append([]byte(timestamp+"."), payload...), signature, secret)
+}
+
+// manualRequest is the manual task req stands for.
+func (req GenericTaskRequest) manualRequest() ManualTaskRequest {
+	source := strings.TrimSpace(req.Source)
+	if source == "" {
+		source = defaultGenericSource
+	}
+	return ManualTaskRequest{
+		Repo:       req.Repo,
+		Number:     req.Number,
+		IsPR:       req.IsPR,
+		Prompt:     req.Prompt,
+		BaseBranch: req.Branch,
+		Actor:      source,
+	}
+}
+
+// genericIdempotencyKey keeps the task of a generic delivery from running
+// twice, across restarts too. It is empty without a delivery ID.
+func genericIdempotencyKey(deliveryID string) string {
+	if deliveryID == "" {
+		return ""
+	}
+	return EventNameGeneric + ":" + deliveryID
+}
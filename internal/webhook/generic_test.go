@@ -0,0 +1,153 @@
+package webhook
+
+import (
+	"bytes"
+	"crypto/hmac"
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"strconv"
+	"testing"
+	"time"
+
+	"github.com/cexll/swe/internal/delivery"
+	"github.com/cexll/swe/internal/github"
+	"github.com/cexll/swe/internal/hmacsig"
+)
+
+func genericRequest(secret, deliveryID, body string) *http.Request {
+	return genericRequestAt(secret, deliveryID, body, time.Now())
+}
+
+// genericRequestAt is a generic request signed at time at.
+func genericRequestAt(secret, deliveryID, body string, at time.Time) *http.Request {
+	req := httptest.NewRequest(http.MethodPost, "/webhook/generic", bytes.NewBufferString(body))
+	timestamp := strconv.FormatInt(at.Unix(), 10)
+	mac := hmac.New(sha256.New, []byte(secret))
+	mac.Write([]byte(timestamp + "." + body))
+	req.Header.Set(GenericTimestampHeader, timestamp)
+	req.Header.Set(GenericSignatureHeader, "sha256="+hex.EncodeToString(mac.Sum(nil)))
+	if deliveryID != "" {
+		req.Header.Set(GenericDeliveryHeader, deliveryID)
+	}
+	return req
+}
+
+func TestHandleGeneric_Rejects(t *testing.T) {
+	handler := NewHandler("github-secret", "/code", &mockDispatcher{}, nil, nil)
+	w := httptest.NewRecorder()
+	handler.HandleGeneric(w, genericRequest("", "", `{}`))
+	if w.Code != http.StatusServiceUnavailable {
+		t.Fatalf("disabled: status = %d, want 503", w.Code)
+	}
+
+	handler.SetGenericWebhookSecret("generic-secret")
+	body := `{"repo":"o/r","number":1,"prompt":"x"}`
+	cases := []struct {
+		name string
+		req  *http.Request
+		want int
+	}{
+		{"github secret", genericRequest("github-secret", "", body), http.StatusUnauthorized},
+		{"unsigned", httptest.NewRequest(http.MethodPost, "/webhook/generic", bytes.NewBufferString(body)), http.StatusUnauthorized},
+		{"bad json", genericRequest("generic-secret", "", `{`), http.StatusBadRequest},
+		{"no prompt", genericRequest("generic-secret", "", `{"repo":"o/r","number":1}`), http.StatusBadRequest},
+		{"bad repo", genericRequest("generic-secret", "", `{"repo":"r","number":1,"prompt":"x"}`), http.StatusBadRequest},
+	}
+	for _, c := range cases {
+		w := httptest.NewRecorder()
+		handler.HandleGeneric(w, c.req)
+		if w.Code != c.want {
+			t.Errorf("%s: status = %d, want %d", c.name, w.Code, c.want)
+		}
+	}
+}
+
+func TestHandleGeneric_RefusesReplays(t *testing.T) {
+	dispatcher := &mockDispatcher{}
+	handler := NewHandler("github-secret", "/code", dispatcher, nil, nil)
+	handler.SetGenericWebhookSecret("generic-secret")
+	body := `{"repo":"o/r","number":1,"prompt":"x"}`
+
+	// a request captured ten minutes ago, sent again as is
+	stale := genericRequestAt("generic-secret", "", body, time.Now().Add(-10*time.Minute))
+	// the same request with a fresh timestamp but its old signature
+	restamped := genericRequestAt("generic-secret", "", body, time.Now().Add(-10*time.Minute))
+	restamped.Header.Set(GenericTimestampHeader, strconv.FormatInt(time.Now().Unix(), 10))
+	// signed over the body only, as before timestamps were required
+	unstamped := genericRequest("generic-secret", "", body)
+	unstamped.Header.Del(GenericTimestampHeader)
+	for name, req := range map[string]*http.Request{
+		"stale":     stale,
+		"restamped": restamped,
+		"unstamped": unstamped,
+		"future":    genericRequestAt("generic-secret", "", body, time.Now().Add(10*time.Minute)),
+	} {
+		w := httptest.NewRecorder()
+		handler.HandleGeneric(w, req)
+		if w.Code != http.StatusUnauthorized {
+			t.Errorf("%s: status = %d, want 401", name, w.Code)
+		}
+	}
+	if dispatcher.enqueueCalls != 0 {
+		t.Fatalf("%d tasks queued", dispatcher.enqueueCalls)
+	}
+
+	if !validGenericSignature([]byte(body), "1700000000", "sha256="+hmacsig.Sign([]byte("1700000000."+body), "generic-secret"), "generic-secret", time.Unix(1700000000, 0).Add(4*time.Minute)) {
+		t.Fatal("a request signed four minutes ago was refused")
+	}
+}
+
+func TestHandleGeneric_QueuesTask(t *testing.T) {
+	dispatcher := &keyedDispatcher{accepted: map[string]bool{}}
+	auth := &mockAppAuth{GetInstallationTokenFunc: func(repo string) (*github.InstallationToken, error) {
+		return &github.InstallationToken{Token: "inst-token"}, nil
+	}}
+	store, _ := delivery.New(delivery.Config{})
+	handler := NewHandler("github-secret", "/code", dispatcher, nil, auth)
+	handler.SetGenericWebhookSecret("generic-secret")
+	handler.SetDeliveryStore(store)
+
+	body := `{"repo":"owner/repo","number":42,"prompt":"fix the flaky test","branch":"develop","source":"jira"}`
+	w := httptest.NewRecorder()
+	handler.HandleGeneric(w, genericRequest("generic-secret", "JIRA-1", body))
+	if w.Code != http.StatusAccepted {
+		t.Fatalf("status = %d: %s", w.Code, w.Body.String())
+	}
+	var resp ManualTaskResponse
+	if err := json.Unmarshal(w.Body.Bytes(), &resp); err != nil {
+		t.Fatalf("decode: %v", err)
+	}
+	task := dispatcher.lastTask
+	if task == nil || resp.TaskID != task.ID {
+		t.Fatalf("response %+v for task %+v", resp, task)
+	}
+	if task.Repo != "owner/repo" || task.Number != 42 || task.IsPR || task.Username != "jira" ||
+		task.BaseBranch != "develop" || task.IdempotencyKey != "generic:JIRA-1" {
+		t.Fatalf("unexpected task: %+v", task)
+	}
+	ghCtx, err := github.ParseWebhookEvent(task.EventType, task.RawPayload)
+	if err != nil || ghCtx.ExtractPrompt("/code") != "fix the flaky test" {
+		t.Fatalf("payload = %v, %v", ghCtx, err)
+	}
+	if rec, ok := store.Get("JIRA-1"); !ok || rec.Event != EventNameGeneric || rec.Sender != "jira" || rec.TaskID != task.ID {
+		t.Fatalf("delivery record = %+v", rec)
+	}
+
+	// retried by the sender
+	w = httptest.NewRecorder()
+	handler.HandleGeneric(w, genericRequest("generic-secret", "JIRA-1", body))
+	if w.Code != http.StatusConflict {
+		t.Fatalf("retry: status = %d", w.Code)
+	}
+	// and after a restart, which forgets deliveries kept in memory
+	dispatcher.accepted[task.IdempotencyKey] = true
+	handler.SetDeliveryStore(nil)
+	w = httptest.NewRecorder()
+	handler.HandleGeneric(w, genericRequest("generic-secret", "JIRA-1", body))
+	if w.Code != http.StatusOK || dispatcher.enqueueCalls != 1 {
+		t.Fatalf("retry after restart: status = %d, %d tasks queued", w.Code, dispatcher.enqueueCalls)
+	}
+}
@@ -1,18 +1,27 @@
 package webhook
 
 import (
-	"encoding/json"
+	"context"
 	"errors"
 	"fmt"
 	"io"
-	"log"
+	"log/slog"
 	"net/http"
 	"os"
 	"strings"
+	"sync"
 	"time"
 
+	"github.com/cexll/swe/internal/audit"
+	"github.com/cexll/swe/internal/budget"
+	"github.com/cexll/swe/internal/delivery"
 	"github.com/cexll/swe/internal/github"
+	"github.com/cexll/swe/internal/github/comment"
+	"github.com/cexll/swe/internal/logging"
 	"github.com/cexll/swe/internal/modes"
+	"github.com/cexll/swe/internal/notify"
+	"github.com/cexll/swe/internal/policy"
+	"github.com/cexll/swe/internal/reposettings"
 	"github.com/cexll/swe/internal/taskstore"
 )
 
@@ -35,11 +44,74 @@ type Task struct {
 	PromptContext map[string]string
 	CommentID     int64  // coordination comment id (when prepared by modes)
 	Mode          string // detected mode name
+	Release       string // confirmed version bump for release tasks
+	Triage        bool   // triage the issue (/triage) instead of changing code
+	// ApprovalCommand makes the task plan-only (approval mode): the comment
+	// that approves the plan, e.g. "/code approve"
+	ApprovalCommand string
+	ApprovedPlan    string // the approved plan an approved task carries out
+	ApprovedBy      string // who approved it
+	// ApplyCommand makes the task a dry run (--dry-run): the comment that
+	// pushes its changes, e.g. "/code apply"
+	ApplyCommand string
+	Applies      string // the dry run task whose changes an apply task pushes
+	// AddressReviews makes the task address the pull request's unresolved
+	// review threads (/code address-reviews)
+	AddressReviews bool
+	// BackportTo makes the task cherry-pick the merged pull request onto
+	// this branch and open a backport pull request (/code backport to ...)
+	BackportTo string
+	// UpdateDeps makes the task update the dependencies and open a pull
+	// request with the bumps (/code update-deps)
+	UpdateDeps bool
+	// Timeout is the run time asked for with /code --timeout (0: default)
+	Timeout time.Duration
+	// Lang is the language of the tracking comment: the repository's
+	// setting, else the trigger comment's ("en" or "zh")
+	Lang string
+	// CommentHistory marks a tracking comment reused across the runs on
+	// the issue, which keeps a history of the earlier ones
+	CommentHistory bool
+	// PolicyInput is the event the policy authorized the task for; the push
+	// rules are evaluated against it
+	PolicyInput *policy.Input
+	// HoldCommand is the comment that applies the commits a push rule held,
+	// e.g. "/code apply"
+	HoldCommand string
+	// ConfirmCommand makes the task stop before the provider runs when its
+	// estimated cost needs confirming: the comment that confirms it, e.g.
+	// "/code confirm"
+	ConfirmCommand string
+	// DependsOn lists the tasks that must complete before this one starts;
+	// the dispatcher holds it until then
+	DependsOn []string
+	// Instruction is what the task was asked, as written after the trigger
+	// keyword; ReplayOf is the task this one replays
+	Instruction string
+	ReplayOf    string
+	// IdempotencyKey identifies the delivery that created the task; the
+	// dispatcher accepts a key once, so a redelivery runs nothing
+	IdempotencyKey string
+	// Priority orders the task in the dispatcher's queue (/code
+	// --priority, the repository's setting or the event)
+	Priority Priority
+	// Debug raises the task's log level and keeps the provider's stderr and
+	// the git output with its artifacts (/code --debug, admins only)
+	Debug bool
+	// DeliveryID is the webhook delivery that created the task, logged
+	// with each line about it
+	DeliveryID string
 	// Raw webhook preservation for adapter-based execution
 	RawPayload []byte
 	EventType  string
 }
 
+// LogContext returns a copy of ctx whose log lines carry the task's ID,
+// repository and delivery.
+func (t *Task) LogContext(ctx context.Context) context.Context {
+	return logging.With(ctx, logging.KeyTaskID, t.ID, logging.KeyRepo, t.Repo, logging.KeyDeliveryID, t.DeliveryID)
+}
+
 // TaskDispatcher enqueues tasks for asynchronous execution
 type TaskDispatcher interface {
 	Enqueue(task *Task) error
@@ -48,12 +120,40 @@ type TaskDispatcher interface {
 // Handler handles GitHub webhook events
 type Handler struct {
 	webhookSecret  string
+	settingsMu     sync.RWMutex // guards settings changed by config reloads
 	triggerKeyword string
+	repos          *RepoFilter
+	policy         *policy.Policy
+	repoSettings   *reposettings.Set
+	releaseMode    bool
+	reuseComment   bool
+	releases       releaseRequests
+	triageMode     bool
+	approvalMode   bool
+	approvals      pendingTasks
+	defaultDryRun  bool
+	dryRuns        pendingTasks
+	costConfirm    bool
+	confirmations  pendingTasks
 	dispatcher     TaskDispatcher
 	issueDeduper   *commentDeduper
 	reviewDeduper  *commentDeduper
 	store          *taskstore.Store
 	appAuth        github.AuthProvider
+	audit          *audit.Log
+	notifier       *notify.Manager
+	deliveries     *delivery.Store
+	apiToken       string
+	genericSecret  string
+	permissions    *permissionCache
+	orgs           *orgCache // team memberships and org roles for the policy
+	providerName   string
+	// invalidateFetch drops GitHub data cached for an issue or pull request
+	// (nil caches nothing)
+	invalidateFetch func(repo string, number int)
+	// budgets refuses tasks past their organization's hard cap (nil
+	// refuses none)
+	budgets *budget.Tracker
 }
 
 // NewHandler creates a new webhook handler
@@ -64,17 +164,111 @@ func NewHandler(webhookSecret, triggerKeyword string, dispatcher TaskDispatcher,
 		dispatcher:     dispatcher,
 		issueDeduper:   newCommentDeduper(12 * time.Hour),
 		reviewDeduper:  newCommentDeduper(12 * time.Hour),
+		permissions:    newPermissionCache(defaultPermissionTTL, defaultPermissionNegativeTTL),
+		orgs:           newOrgCache(defaultPermissionTTL),
 		store:          store,
 		appAuth:        appAuth,
 	}
 }
 
+// SetAuditLog enables audit records for permission decisions and queued tasks.
+func (h *Handler) SetAuditLog(l *audit.Log) {
+	h.audit = l
+}
+
+// SetNotifier enables task queued notifications (nil disables).
+func (h *Handler) SetNotifier(n *notify.Manager) {
+	h.notifier = n
+}
+
+// SetDeliveryStore enables replay protection and outcome tracking keyed by
+// the X-GitHub-Delivery header (nil disables).
+func (h *Handler) SetDeliveryStore(s *delivery.Store) {
+	h.deliveries = s
+}
+
+// SetPermissionCacheTTL sets how long allowed and denied permission checks are
+// cached; a TTL <= 0 disables caching of that result. Team memberships and
+// organization roles looked up for the policy are cached for positive.
+func (h *Handler) SetPermissionCacheTTL(positive, negative time.Duration) {
+	h.orgs.setTTL(positive)
+	if h.permissions == nil {
+		h.permissions = newPermissionCache(positive, negative)
+		return
+	}
+	h.permissions.setTTL(positive, negative)
+}
+
+// SetFetchInvalidator sets what comment events call to drop the GitHub data
+// cached for their issue or pull request (nil disables).
+func (h *Handler) SetFetchInvalidator(invalidate func(repo string, number int)) {
+	h.invalidateFetch = invalidate
+}
+
+// SetTriggerKeyword changes the keyword that triggers tasks; safe to call
+// while requests are being served.
+func (h *Handler) SetTriggerKeyword(keyword string) {
+	h.settingsMu.Lock()
+	defer h.settingsMu.Unlock()
+	h.triggerKeyword = keyword
+}
+
+// trigger returns the current trigger keyword.
+func (h *Handler) trigger() string {
+	h.settingsMu.RLock()
+	defer h.settingsMu.RUnlock()
+	return h.triggerKeyword
+}
+
+// SetRepoSettings sets per-repository overrides, of which the handler uses
+// the trigger keyword (nil removes them); safe to call while requests are
+// being served.
+func (h *Handler) SetRepoSettings(s *reposettings.Set) {
+	h.settingsMu.Lock()
+	defer h.settingsMu.Unlock()
+	h.repoSettings = s
+}
+
+// triggerFor returns repo's trigger keyword: its own, or the server's.
+func (h *Handler) triggerFor(repo string) string {
+	h.settingsMu.RLock()
+	defer h.settingsMu.RUnlock()
+	if keyword := h.repoSettings.For(repo).TriggerKeyword; keyword != "" {
+		return keyword
+	}
+	return h.triggerKeyword
+}
+
+// langFor returns the language of the tracking comment for a trigger: the
+// repository's setting, otherwise the language the comment is written in.
+func (h *Handler) langFor(ghCtx *github.Context) comment.Lang {
+	h.settingsMu.RLock()
+	setting := h.repoSettings.For(ghCtx.Repository.FullName).Language
+	h.settingsMu.RUnlock()
+	return comment.ResolveLang(setting, ghCtx.ExtractPrompt(h.triggerFor(ghCtx.Repository.FullName)))
+}
+
+// SetReuseTrackingComment makes /code runs on an issue or pull request share
+// one tracking comment that keeps a history of the earlier runs; safe to
+// call while requests are being served.
+func (h *Handler) SetReuseTrackingComment(enabled bool) {
+	h.settingsMu.Lock()
+	defer h.settingsMu.Unlock()
+	h.reuseComment = enabled
+}
+
+func (h *Handler) commentReuseEnabled() bool {
+	h.settingsMu.RLock()
+	defer h.settingsMu.RUnlock()
+	return h.reuseComment
+}
+
 // Handle handles GitHub webhook events (issue comments, review comments, etc.)
 func (h *Handler) Handle(w http.ResponseWriter, r *http.Request) {
 	// 1. Read payload
 	payload, err := io.ReadAll(r.Body)
 	if err != nil {
-		log.Printf("Error reading payload: %v", err)
+		slog.WarnContext(r.Context(), "Reading payload failed", logging.KeyPhase, phaseWebhook, "err", err)
 		http.Error(w, "Error reading payload", http.StatusBadRequest)
 		return
 	}
@@ -82,13 +276,13 @@ func (h *Handler) Handle(w http.ResponseWriter, r *http.Request) {
 	// 2. Verify signature
 	signature := r.Header.Get("X-Hub-Signature-256")
 	if err := ValidateSignatureHeader(signature); err != nil {
-		log.Printf("Invalid signature header: %v", err)
+		slog.WarnContext(r.Context(), "Invalid signature header", logging.KeyPhase, phaseWebhook, "err", err)
 		http.Error(w, "Invalid signature", http.StatusUnauthorized)
 		return
 	}
 
 	if !VerifySignature(payload, signature, h.webhookSecret) {
-		log.Printf("Signature verification failed")
+		slog.WarnContext(r.Context(), "Signature verification failed", logging.KeyPhase, phaseWebhook)
 		http.Error(w, "Invalid signature", http.StatusUnauthorized)
 		return
 	}
@@ -96,6 +290,23 @@ func (h *Handler) Handle(w http.ResponseWriter, r *http.Request) {
 	// 3. Determine event type
 	eventType := r.Header.Get("X-GitHub-Event")
 
+	// 3.5. Reject replayed deliveries and track the outcome of new ones
+	w, fresh := h.beginDelivery(w, r, eventType)
+	if !fresh {
+		return
+	}
+	defer h.finishDelivery(w)
+
+	// 3.6. Membership changes invalidate cached permission decisions
+	if isPermissionChangeEvent(eventType) {
+		repo := permissionChangeScope(eventType, payload)
+		n := h.permissions.invalidate(repo) + h.orgs.invalidate()
+		slog.InfoContext(r.Context(), "Permission cache invalidated", logging.KeyRepo, repo, logging.KeyPhase, phaseAuthorize, "event", eventType, "entries", n)
+		w.WriteHeader(http.StatusOK)
+		_, _ = w.Write([]byte("Permission cache invalidated"))
+		return
+	}
+
 	// 4. Only handle comment events (issue_comment, pull_request_review_comment)
 	if !isCommentEvent(eventType) {
 		w.WriteHeader(http.StatusOK)
@@ -106,10 +317,26 @@ func (h *Handler) Handle(w http.ResponseWriter, r *http.Request) {
 	// 5. Parse webhook event into GitHub context
 	ghCtx, err := github.ParseWebhookEvent(eventType, payload)
 	if err != nil {
-		log.Printf("Failed to parse webhook event: %v", err)
+		slog.WarnContext(r.Context(), "Parsing webhook event failed", logging.KeyPhase, phaseWebhook, "event", eventType, "err", err)
 		http.Error(w, "Error parsing event", http.StatusBadRequest)
 		return
 	}
+	annotateDelivery(w, func(rec *delivery.Record) {
+		rec.Action = string(ghCtx.EventAction)
+		rec.Repo = ghCtx.Repository.FullName
+		rec.Number = ghCtx.IssueNumber
+		rec.Sender = ghCtx.TriggerUser
+	})
+
+	// 5.5. Comments other than the bot's own change what tasks on the
+	// thread fetch
+	if h.invalidateFetch != nil && !isBotComment(eventType, payload) {
+		number := ghCtx.IssueNumber
+		if ghCtx.IsPR && ghCtx.PRNumber != 0 {
+			number = ghCtx.PRNumber
+		}
+		h.invalidateFetch(ghCtx.Repository.FullName, number)
+	}
 
 	// 6. Check if this is a created action
 	if ghCtx.EventAction != "created" {
@@ -126,38 +353,221 @@ func (h *Handler) Handle(w http.ResponseWriter, r *http.Request) {
 	}
 
 	// 7. Check if comment is from a bot
-	if ghCtx.TriggerComment != nil && isBotComment(payload) {
+	if ghCtx.TriggerComment != nil && isBotComment(eventType, payload) {
 		w.WriteHeader(http.StatusOK)
 		_, _ = w.Write([]byte("Bot comment ignored"))
 		return
 	}
 
+	// 7.5. Release commands have their own maintainer gate and confirmation step
+	if h.releaseEnabled() {
+		if cmd, ok := parseReleaseCommand(ghCtx.GetTriggerCommentBody()); ok {
+			h.handleRelease(r.Context(), w, ghCtx, cmd, eventType, payload)
+			return
+		}
+	}
+
+	// 7.55. "/triage" labels the issue instead of starting a code task
+	if h.triageEnabled() && isTriageCommand(ghCtx.GetTriggerCommentBody()) {
+		h.handleTriage(r.Context(), w, ghCtx, eventType, payload)
+		return
+	}
+
+	// 7.6. In approval mode "<trigger> approve" approves the plan posted on
+	// the thread instead of starting a task
+	trigger := h.triggerFor(ghCtx.Repository.FullName)
+	approval := h.approvalEnabled()
+	if h.approvalCommandEnabled() && isApprovalCommand(ghCtx.GetTriggerCommentBody(), trigger) {
+		h.handleApproval(w, ghCtx, trigger, eventType)
+		return
+	}
+
+	// 7.7. "<trigger> apply" pushes the changes of the thread's dry run
+	if isApplyCommand(ghCtx.GetTriggerCommentBody(), trigger) {
+		h.handleApply(w, ghCtx, trigger, eventType)
+		return
+	}
+
+	// 7.8. "<trigger> confirm" runs the task the thread's cost estimate
+	// stopped
+	if h.costConfirmEnabled() && isConfirmCommand(ghCtx.GetTriggerCommentBody(), trigger) {
+		h.handleConfirm(w, ghCtx, trigger, eventType)
+		return
+	}
+
 	// 8. Check if comment contains trigger keyword
-	if !ghCtx.ShouldTrigger(h.triggerKeyword) {
-		log.Printf("Comment does not contain trigger keyword '%s'", h.triggerKeyword)
+	if !ghCtx.ShouldTrigger(trigger) {
+		eventLog(ghCtx, phaseWebhook).DebugContext(r.Context(), "Comment does not contain the trigger keyword", "trigger", trigger)
 		w.WriteHeader(http.StatusOK)
 		_, _ = w.Write([]byte("No trigger keyword found"))
 		return
 	}
 
-	// 9. Verify permission: check if user is the app installer
-	if !h.verifyPermission(ghCtx.Repository.FullName, ghCtx.TriggerUser) {
-		log.Printf("Permission denied: user %s is not the app installer", ghCtx.TriggerUser)
+	// 8.5. Refuse repositories that are not enabled
+	if enabled, reason := h.checkRepo(ghCtx.Repository.FullName); !enabled {
+		h.rejectRepo(ghCtx, reason)
+		w.WriteHeader(http.StatusOK)
+		_, _ = w.Write([]byte("Repository not enabled"))
+		return
+	}
+
+	// 9. Verify permission: the policy when configured, otherwise check
+	// if user is the app installer
+	decision := h.authorize(ghCtx, commandName(trigger))
+	h.recordPermission(ghCtx, decision.Allowed, decision.Reason)
+	if !decision.Allowed {
+		eventLog(ghCtx, phaseAuthorize).InfoContext(r.Context(), "Permission denied", "user", ghCtx.TriggerUser, "reason", decision.Reason)
+		h.replyDenied(ghCtx, decision)
 		w.WriteHeader(http.StatusOK)
 		_, _ = w.Write([]byte("Permission denied"))
 		return
 	}
 
-	// 10. Prevent duplicate processing
+	// 10. Prevent duplicate processing, also of deliveries accepted before
+	// a restart
 	commentID := ghCtx.TriggerComment.ID
+	key := idempotencyKey(r.Header.Get("X-GitHub-Delivery"), commentID)
 	deduper := h.getDeduper(eventType)
-	if !deduper.markIfNew(commentID) {
+	if h.alreadyAccepted(key) || !deduper.markIfNew(commentID) {
 		w.WriteHeader(http.StatusOK)
 		_, _ = w.Write([]byte("Duplicate comment ignored"))
 		return
 	}
 
-	// 10.5. Obtain GitHub App installation token for CommandMode (if available)
+	// 10.1. "<trigger> help" lists the commands instead of starting a task
+	if isHelpCommand(ghCtx.ExtractPrompt(trigger)) {
+		h.setInstallationToken(ghCtx, helpCommand)
+		h.replyThread(ghCtx, h.helpText(trigger))
+		w.WriteHeader(http.StatusOK)
+		_, _ = w.Write([]byte("Help posted"))
+		return
+	}
+
+	// 10.2. An unknown flag starts nothing: a mistyped --dry-run would
+	// otherwise push
+	if unknown := unknownFlags(ghCtx.ExtractPrompt(trigger)); len(unknown) > 0 {
+		h.setInstallationToken(ghCtx, "unknown flag")
+		h.replyThread(ghCtx, unknownFlagsText(trigger, unknown))
+		w.WriteHeader(http.StatusOK)
+		_, _ = w.Write([]byte("Unknown flag"))
+		return
+	}
+
+	// 10.3. "<trigger> --debug" is for repository admins only
+	debug := hasDebugFlag(ghCtx.GetTriggerCommentBody())
+	if debug {
+		h.setInstallationToken(ghCtx, "debug")
+		if admin, reason := isRepoAdmin(ghCtx, ghCtx.TriggerUser); !admin {
+			eventLog(ghCtx, phaseAuthorize).InfoContext(r.Context(), "Debug run denied", "user", ghCtx.TriggerUser, "reason", reason)
+			h.replyThread(ghCtx, debugDeniedText(trigger))
+			w.WriteHeader(http.StatusOK)
+			_, _ = w.Write([]byte("Debug run denied"))
+			return
+		}
+	}
+
+	// 10.4. Organizations past the hard cap of their budget start nothing
+	if st, exhausted := h.budgetExhausted(ghCtx.Repository.FullName); exhausted {
+		h.refuseBudget(ghCtx, st)
+		w.WriteHeader(http.StatusOK)
+		_, _ = w.Write([]byte("Budget exhausted"))
+		return
+	}
+
+	// 10.5. "<trigger> address-reviews" only makes sense on a pull request
+	addressReviews := isAddressReviewsCommand(ghCtx.ExtractPrompt(trigger))
+	if addressReviews && !ghCtx.IsPRContext() {
+		h.setInstallationToken(ghCtx, addressReviewsCommand)
+		h.replyThread(ghCtx, fmt.Sprintf("`%s %s` works on pull requests only.", trigger, addressReviewsCommand))
+		w.WriteHeader(http.StatusOK)
+		_, _ = w.Write([]byte("Not a pull request"))
+		return
+	}
+
+	// 10.6. "<trigger> backport to <branch>" needs a pull request and a branch
+	backportTo, backport := parseBackportCommand(ghCtx.ExtractPrompt(trigger))
+	if backport && (!ghCtx.IsPRContext() || backportTo == "") {
+		h.setInstallationToken(ghCtx, backportCommand)
+		msg := fmt.Sprintf("`%s %s` works on pull requests only.", trigger, backportCommand)
+		if ghCtx.IsPRContext() {
+			msg = fmt.Sprintf("Name the branch to backport to: `%s %s to release-1.2`.", trigger, backportCommand)
+		}
+		h.replyThread(ghCtx, msg)
+		w.WriteHeader(http.StatusOK)
+		_, _ = w.Write([]byte("Invalid backport command"))
+		return
+	}
+
+	// 10.7. "<trigger> update-deps" works on issues and pull requests alike
+	updateDeps := isUpdateDepsCommand(ghCtx.ExtractPrompt(trigger))
+
+	// 11-12. Prepare execution context and enqueue the task
+	t, err := h.prepareTask(r.Context(), ghCtx, payload)
+	if err != nil {
+		eventLog(ghCtx, phaseEnqueue).ErrorContext(r.Context(), "Preparing task failed", "err", err)
+		if errors.Is(err, errCommandModeMissing) {
+			http.Error(w, "Internal configuration error", http.StatusInternalServerError)
+		} else {
+			http.Error(w, "Task preparation failed", http.StatusInternalServerError)
+		}
+		return
+	}
+
+	t.AddressReviews = addressReviews
+	t.BackportTo = backportTo
+	t.UpdateDeps = updateDeps
+	t.Debug = debug
+	t.IdempotencyKey = key
+	taskLog(t, phaseEnqueue).InfoContext(r.Context(), "Received task", "number", t.Number, "comment_id", commentID, "user", t.Username)
+
+	// 11.5. Dry runs push nothing until applied; in approval mode the task
+	// only plans and pushing waits for approval (applying a dry run needs
+	// the same approval). A policy rule can ask for either. A backport or
+	// dependency update only opens a pull request, which is reviewed like
+	// any other.
+	switch {
+	case backport, updateDeps:
+	case decision.Effect == policy.DryRun:
+		h.markDryRun(t, trigger, true)
+	case decision.Effect == policy.RequireApproval:
+		h.requireApproval(t, trigger)
+	case h.wantsDryRun(ghCtx.GetTriggerCommentBody()):
+		h.markDryRun(t, trigger, false)
+	case approval:
+		h.requireApproval(t, trigger)
+	}
+	if h.currentPolicy().HasPushRules() {
+		t.HoldCommand = applyCommand(trigger)
+	}
+	// 11.6. A task the executor estimates too costly waits for "<trigger>
+	// confirm"; backports and dependency updates are not estimated
+	if h.costConfirmEnabled() && !backport && !updateDeps {
+		h.requireConfirmation(t, trigger)
+	}
+
+	h.enqueueTask(w, t)
+}
+
+// errCommandModeMissing is returned by prepareTask when no CommandMode is registered.
+var errCommandModeMissing = errors.New("CommandMode not registered")
+
+// prepareTask obtains an installation token, runs CommandMode preparation
+// (coordination comment, branches) and records the task in the store.
+// Shared by webhook deliveries and manually submitted tasks.
+func (h *Handler) prepareTask(ctx context.Context, ghCtx *github.Context, payload []byte) (*Task, error) {
+	mode := modes.GetCommandMode()
+	if mode == nil {
+		return nil, errCommandModeMissing
+	}
+	return h.prepareModeTask(ctx, mode, ghCtx, payload)
+}
+
+// prepareModeTask is prepareTask for an explicit mode.
+func (h *Handler) prepareModeTask(ctx context.Context, mode modes.Mode, ghCtx *github.Context, payload []byte) (*Task, error) {
+	if mode == nil {
+		return nil, errors.New("mode not registered")
+	}
+	// Obtain GitHub App installation token for CommandMode (if available)
 	if h.appAuth != nil {
 		repo := ghCtx.Repository.FullName
 		if repo == "" {
@@ -165,7 +575,7 @@ func (h *Handler) Handle(w http.ResponseWriter, r *http.Request) {
 		}
 		token, err := h.appAuth.GetInstallationToken(repo)
 		if err != nil {
-			log.Printf("Warning: Failed to get installation token: %v (continuing without token)", err)
+			eventLog(ghCtx, phaseEnqueue).WarnContext(ctx, "Getting installation token failed; continuing without token", "err", err)
 			// Continue without token (fail-open for robustness)
 		} else if token != nil {
 			// Inject token into context for CommandMode to use
@@ -173,22 +583,14 @@ func (h *Handler) Handle(w http.ResponseWriter, r *http.Request) {
 		}
 	}
 
-	// 11. Prepare execution context via CommandMode
-	mode := modes.GetCommandMode()
-	if mode == nil {
-		log.Printf("CommandMode not registered")
-		http.Error(w, "Internal configuration error", http.StatusInternalServerError)
-		return
-	}
-
-	prepareResult, err := mode.Prepare(r.Context(), ghCtx)
+	ghCtx.PreparedLang = string(h.langFor(ghCtx))
+	// only /code tasks share a tracking comment across runs
+	ghCtx.PreparedCommentHistory = mode.Name() == "command" && h.commentReuseEnabled()
+	prepareResult, err := mode.Prepare(ctx, ghCtx)
 	if err != nil {
-		log.Printf("Failed to prepare task: %v", err)
-		http.Error(w, "Task preparation failed", http.StatusInternalServerError)
-		return
+		return nil, err
 	}
 
-	// 12. Create and enqueue task
 	prBranch := ""
 	prState := ""
 	if ghCtx.IsPRContext() {
@@ -204,34 +606,59 @@ func (h *Handler) Handle(w http.ResponseWriter, r *http.Request) {
 		summaryBuilder.WriteString("**Issue:** ")
 	}
 	summaryBuilder.WriteString(ghCtx.IssueTitle)
-	if instr := strings.TrimSpace(ghCtx.ExtractPrompt(h.triggerKeyword)); instr != "" {
+	instr := strings.TrimSpace(ghCtx.ExtractPrompt(h.triggerFor(ghCtx.Repository.FullName)))
+	if instr != "" {
 		summaryBuilder.WriteString("\n\n**Instruction:**\n")
 		summaryBuilder.WriteString(instr)
 	}
 
 	t := &Task{
-		ID:            h.generateTaskID(ghCtx.Repository.FullName, ghCtx.IssueNumber),
-		Repo:          ghCtx.Repository.FullName,
-		Number:        ghCtx.IssueNumber,
-		Branch:        prepareResult.Branch,
-		BaseBranch:    prepareResult.BaseBranch,
-		Prompt:        prepareResult.Prompt,
-		PromptSummary: summaryBuilder.String(),
-		IsPR:          ghCtx.IsPR,
-		Username:      ghCtx.TriggerUser,
-		CommentID:     prepareResult.CommentID,
-		PRBranch:      prBranch,
-		PRState:       prState,
-		Mode:          mode.Name(),
-		RawPayload:    payload,
-		EventType:     string(ghCtx.EventName),
+		ID:             h.generateTaskID(ghCtx.Repository.FullName, ghCtx.IssueNumber),
+		Repo:           ghCtx.Repository.FullName,
+		Number:         ghCtx.IssueNumber,
+		Branch:         prepareResult.Branch,
+		BaseBranch:     prepareResult.BaseBranch,
+		Prompt:         prepareResult.Prompt,
+		PromptSummary:  summaryBuilder.String(),
+		IsPR:           ghCtx.IsPR,
+		Username:       ghCtx.TriggerUser,
+		CommentID:      prepareResult.CommentID,
+		PRBranch:       prBranch,
+		PRState:        prState,
+		Mode:           mode.Name(),
+		Timeout:        parseTimeoutFlag(ghCtx.GetTriggerCommentBody()),
+		Priority:       h.taskPriority(ghCtx),
+		Lang:           ghCtx.PreparedLang,
+		CommentHistory: ghCtx.PreparedCommentHistory,
+		PolicyInput:    ghCtx.PreparedPolicyInput,
+		DependsOn:      dependsOnFrom(ctx),
+		Instruction:    instr,
+		ReplayOf:       replayOfFrom(ctx),
+		DeliveryID:     logging.Value(ctx, logging.KeyDeliveryID),
+		RawPayload:     payload,
+		EventType:      string(ghCtx.EventName),
 	}
 
 	h.createStoreTask(t)
+	return t, nil
+}
 
-	log.Printf("Received task: repo=%s, number=%d, commentID=%d, user=%s", t.Repo, t.Number, commentID, t.Username)
+// idempotencyKey identifies the task a comment delivery creates, so a
+// redelivery is recognised after a restart. It is empty without a delivery
+// GUID.
+func idempotencyKey(deliveryID string, commentID int64) string {
+	deliveryID = strings.TrimSpace(deliveryID)
+	if deliveryID == "" {
+		return ""
+	}
+	return fmt.Sprintf("%s:%d", deliveryID, commentID)
+}
 
-	h.enqueueTask(w, t)
+// alreadyAccepted reports whether the dispatcher accepted a task with key,
+// before doing the work of preparing another. Enqueue refuses it regardless.
+func (h *Handler) alreadyAccepted(key string) bool {
+	d, ok := h.dispatcher.(interface{ Accepted(key string) bool })
+	return ok && key != "" && d.Accepted(key)
 }
 
 func (h *Handler) generateTaskID(repo string, number int) string {
@@ -243,35 +670,52 @@ func (h *Handler) generateTaskID(repo string, number int) string {
 // verifyPermission checks if the user has permission to trigger tasks
 // Returns true if user is the GitHub App installer
 func (h *Handler) verifyPermission(repo, username string) bool {
+	allowed, _ := h.checkPermission(repo, username)
+	return allowed
+}
+
+// checkPermission is verifyPermission with a human-readable reason for the
+// decision, as reported by the simulation endpoint.
+func (h *Handler) checkPermission(repo, username string) (bool, string) {
 	// Allow override via environment for development or lenient deployments
 	if strings.EqualFold(strings.TrimSpace(os.Getenv("ALLOW_ALL_USERS")), "true") ||
 		strings.EqualFold(strings.TrimSpace(os.Getenv("PERMISSION_MODE")), "open") {
-		log.Printf("Permission override enabled via env (ALLOW_ALL_USERS/PERMISSION_MODE), allowing user %s", username)
-		return true
+		slog.Info("Permission override enabled via ALLOW_ALL_USERS/PERMISSION_MODE", logging.KeyRepo, repo, logging.KeyPhase, phaseAuthorize, "user", username)
+		return true, "override enabled via ALLOW_ALL_USERS/PERMISSION_MODE"
 	}
 
 	if h.appAuth == nil {
 		// No auth provider, allow all (for testing)
-		log.Printf("Warning: No app auth provider configured, allowing all users")
-		return true
+		slog.Warn("No app auth provider configured, allowing all users", logging.KeyRepo, repo, logging.KeyPhase, phaseAuthorize)
+		return true, "no GitHub App auth configured"
+	}
+
+	if allowed, ok := h.permissions.get(repo, username); ok {
+		slog.Debug("Permission check cached", logging.KeyRepo, repo, logging.KeyPhase, phaseAuthorize, "user", username, "allowed", allowed)
+		if allowed {
+			return true, "cached: user is the app installer"
+		}
+		return false, "cached: user is not the app installer"
 	}
 
 	// Get the installation owner
 	owner, err := h.appAuth.GetInstallationOwner(repo)
 	if err != nil {
-		log.Printf("Warning: Failed to get installation owner: %v (allowing request)", err)
-		// On error, allow the request (fail-open for robustness)
-		return true
+		slog.Warn("Getting installation owner failed; allowing request", logging.KeyRepo, repo, logging.KeyPhase, phaseAuthorize, "err", err)
+		// On error, allow the request (fail-open for robustness); not cached
+		return true, fmt.Sprintf("installer lookup failed, failing open: %v", err)
 	}
 
 	// Check if user matches the installer
 	if username != owner {
-		log.Printf("Permission check failed: user=%s, installer=%s", username, owner)
-		return false
+		slog.Info("Permission check failed", logging.KeyRepo, repo, logging.KeyPhase, phaseAuthorize, "user", username, "installer", owner)
+		h.permissions.set(repo, username, false)
+		return false, fmt.Sprintf("user is not the app installer (%s)", owner)
 	}
 
-	log.Printf("Permission check passed: user=%s is the installer", username)
-	return true
+	slog.Info("Permission check passed: user is the installer", logging.KeyRepo, repo, logging.KeyPhase, phaseAuthorize, "user", username)
+	h.permissions.set(repo, username, true)
+	return true, "user is the app installer"
 }
 
 func (h *Handler) createStoreTask(task *Task) {
@@ -281,20 +725,34 @@ func (h *Handler) createStoreTask(task *Task) {
 
 	owner, name := splitRepo(task.Repo)
 	storeTask := &taskstore.Task{
-		ID:          task.ID,
-		Title:       task.IssueTitle,
-		Status:      taskstore.StatusPending,
-		RepoOwner:   owner,
-		RepoName:    name,
-		IssueNumber: task.Number,
-		Actor:       task.Username,
+		ID:            task.ID,
+		Title:         task.IssueTitle,
+		Status:        taskstore.StatusPending,
+		RepoOwner:     owner,
+		RepoName:      name,
+		IssueNumber:   task.Number,
+		Actor:         task.Username,
+		PromptSummary: task.PromptSummary,
+		DependsOn:     task.DependsOn,
+		Instruction:   task.Instruction,
+		IsPR:          task.IsPR,
+		BaseBranch:    task.BaseBranch,
+		ReplayOf:      task.ReplayOf,
+	}
+	if task.ApprovedBy != "" {
+		storeTask.Approval = taskstore.ApprovalApproved
+		storeTask.Plan = task.ApprovedPlan
+		storeTask.ApprovedBy = task.ApprovedBy
 	}
 	h.store.Create(storeTask)
 	h.store.AddLog(task.ID, "info", "Task queued")
+	if task.ReplayOf != "" {
+		h.store.AddLog(task.ID, "info", fmt.Sprintf("Replay of task %s", task.ReplayOf))
+	}
 
 	// Ensure newest comment wins: mark older tasks for the same issue as superseded.
 	if n := h.store.SupersedeOlder(owner, name, task.Number, task.ID); n > 0 {
-		log.Printf("Superseded %d older task(s) for %s#%d", n, task.Repo, task.Number)
+		taskLog(task, phaseEnqueue).Info("Superseded older tasks", "count", n, "number", task.Number)
 		h.store.AddLog(task.ID, "info", fmt.Sprintf("Superseded %d older task(s)", n))
 	}
 }
@@ -309,54 +767,123 @@ func splitRepo(full string) (string, string) {
 
 // isCommentEvent checks if the event type is a comment event
 func isCommentEvent(eventType string) bool {
-	return eventType == "issue_comment" || eventType == "pull_request_review_comment"
+	return eventType == EventNameIssueComment || eventType == EventNamePullRequestReviewComment
 }
 
 // isBotComment checks if the comment is from a bot
-func isBotComment(payload []byte) bool {
-	var data map[string]interface{}
-	if err := json.Unmarshal(payload, &data); err != nil {
+func isBotComment(eventType string, payload []byte) bool {
+	ev, err := ParseEvent(eventType, payload)
+	if err != nil {
 		return false
 	}
-
-	// Check comment.user.type
-	if comment, ok := data["comment"].(map[string]interface{}); ok {
-		if user, ok := comment["user"].(map[string]interface{}); ok {
-			if userType, ok := user["type"].(string); ok {
-				return userType == "Bot"
-			}
-		}
+	switch e := ev.(type) {
+	case *IssueCommentEvent:
+		return e.Comment.User.IsBot()
+	case *PullRequestReviewCommentEvent:
+		return e.Comment.User.IsBot()
 	}
 	return false
 }
 
 // getDeduper returns the appropriate deduper based on event type
 func (h *Handler) getDeduper(eventType string) *commentDeduper {
-	if eventType == "pull_request_review_comment" {
+	if eventType == EventNamePullRequestReviewComment {
 		return h.reviewDeduper
 	}
 	return h.issueDeduper
 }
 
 func (h *Handler) enqueueTask(w http.ResponseWriter, task *Task) {
-	if err := h.dispatcher.Enqueue(task); err != nil {
-		log.Printf("Failed to enqueue task: %v", err)
-		switch {
-		case errors.Is(err, ErrQueueFull):
-			http.Error(w, "Task queue is busy, try again later", http.StatusServiceUnavailable)
-		case errors.Is(err, ErrQueueClosed):
-			http.Error(w, "Task queue unavailable", http.StatusServiceUnavailable)
-		default:
-			http.Error(w, "Failed to enqueue task", http.StatusInternalServerError)
-		}
+	if err := h.dispatchTask(task); err != nil {
+		status, msg := enqueueErrorStatus(err)
+		http.Error(w, msg, status)
 		return
 	}
 
+	annotateDelivery(w, func(rec *delivery.Record) { rec.TaskID = task.ID })
+
+	w.WriteHeader(http.StatusAccepted)
+	_, _ = w.Write([]byte("Task queued"))
+}
+
+// enqueueErrorStatus maps a dispatcher error to an HTTP status and message.
+func enqueueErrorStatus(err error) (int, string) {
+	switch {
+	case errors.Is(err, ErrQueueFull):
+		return http.StatusServiceUnavailable, "Task queue is busy, try again later"
+	case errors.Is(err, ErrQueueClosed):
+		return http.StatusServiceUnavailable, "Task queue unavailable"
+	case errors.Is(err, ErrDuplicateTask):
+		return http.StatusOK, "Duplicate comment ignored"
+	case errors.Is(err, ErrBudgetExhausted):
+		return http.StatusPaymentRequired, "Monthly budget exhausted"
+	default:
+		return http.StatusInternalServerError, "Failed to enqueue task"
+	}
+}
+
+// dispatchTask enqueues a prepared task and records the queued audit event
+// and notification. Tasks of an organization past its hard cap are refused.
+func (h *Handler) dispatchTask(task *Task) error {
+	if st, exhausted := h.budgetExhausted(task.Repo); exhausted {
+		h.refuseBudgetTask(task, st)
+		return ErrBudgetExhausted
+	}
+	if err := h.dispatcher.Enqueue(task); err != nil {
+		taskLog(task, phaseEnqueue).Error("Enqueueing task failed", "err", err)
+		return err
+	}
+
 	// For review comments, branch is the base branch used by the PR
 	if task.EventType == "pull_request_review_comment" {
 		task.Branch = task.BaseBranch
 	}
 
-	w.WriteHeader(http.StatusAccepted)
-	_, _ = w.Write([]byte("Task queued"))
+	h.recordAudit(audit.Event{
+		Action:            audit.ActionTaskQueued,
+		Actor:             task.Username,
+		Repo:              task.Repo,
+		Number:            task.Number,
+		TaskID:            task.ID,
+		Branch:            task.Branch,
+		TrackingCommentID: task.CommentID,
+		Detail:            task.Mode,
+	})
+	h.notifier.Notify(notify.Event{
+		Type:    notify.EventQueued,
+		TaskID:  task.ID,
+		Repo:    task.Repo,
+		Number:  task.Number,
+		IsPR:    task.IsPR,
+		Actor:   task.Username,
+		Summary: task.PromptSummary,
+	})
+	return nil
+}
+
+func (h *Handler) recordPermission(ghCtx *github.Context, allowed bool, reason string) {
+	ev := audit.Event{
+		Action:   audit.ActionPermission,
+		Actor:    ghCtx.TriggerUser,
+		Repo:     ghCtx.Repository.FullName,
+		Number:   ghCtx.IssueNumber,
+		Decision: audit.DecisionDenied,
+		Detail:   reason,
+	}
+	if allowed {
+		ev.Decision = audit.DecisionAllowed
+	}
+	if ghCtx.TriggerComment != nil {
+		ev.TriggerCommentID = ghCtx.TriggerComment.ID
+	}
+	h.recordAudit(ev)
+}
+
+func (h *Handler) recordAudit(ev audit.Event) {
+	if h.audit == nil {
+		return
+	}
+	if err := h.audit.Record(ev); err != nil {
+		slog.Warn("Recording audit event failed", logging.KeyTaskID, ev.TaskID, logging.KeyRepo, ev.Repo, logging.KeyPhase, "audit", "err", err)
+	}
 }
@@ -221,12 +221,8 @@ func TestHandler_PRBranchInfo(t *testing.T) {
 					Number: 456,
 					Title:  "Test PR",
 					State:  tt.prState,
-					Base: struct {
-						Ref string `json:"ref"`
-					}{Ref: "main"},
-					Head: struct {
-						Ref string `json:"ref"`
-					}{Ref: tt.prHeadRef},
+					Base:   PRBranch{Ref: "main"},
+					Head:   PRBranch{Ref: tt.prHeadRef},
 				},
 				Repository: Repository{
 					FullName:      "owner/repo",
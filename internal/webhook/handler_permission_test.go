@@ -5,6 +5,7 @@ import (
 	"testing"
 	"time"
 
+	"github.com/cexll/swe/internal/audit"
 	"github.com/cexll/swe/internal/github"
 	"github.com/cexll/swe/internal/taskstore"
 )
@@ -112,3 +113,29 @@ func TestHandlerCreateStoreTask(t *testing.T) {
 		t.Fatalf("expected fallback owner only, got %s/%s", got2.RepoOwner, got2.RepoName)
 	}
 }
+
+func TestHandlerRecordPermission(t *testing.T) {
+	log, err := audit.New(audit.Config{})
+	if err != nil {
+		t.Fatalf("audit.New error: %v", err)
+	}
+	h := &Handler{}
+	h.SetAuditLog(log)
+
+	ghCtx := &github.Context{
+		Repository:     github.Repository{FullName: "owner/repo"},
+		IssueNumber:    5,
+		TriggerUser:    "mallory",
+		TriggerComment: &github.Comment{ID: 77},
+	}
+	h.recordPermission(ghCtx, false, "user is not the app installer")
+
+	events := log.List(audit.Filter{})
+	if len(events) != 1 {
+		t.Fatalf("events = %d, want 1", len(events))
+	}
+	ev := events[0]
+	if ev.Action != audit.ActionPermission || ev.Decision != audit.DecisionDenied || ev.Actor != "mallory" || ev.TriggerCommentID != 77 {
+		t.Fatalf("unexpected audit event: %+v", ev)
+	}
+}
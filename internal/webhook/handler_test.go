@@ -7,6 +7,7 @@ import (
 	"encoding/hex"
 	"encoding/json"
 	"errors"
+	"fmt"
 	"io"
 	"net/http"
 	"net/http/httptest"
@@ -16,6 +17,7 @@ import (
 
 	"github.com/cexll/swe/internal/github"
 	_ "github.com/cexll/swe/internal/modes/command" // Import to register CommandMode
+	"github.com/cexll/swe/internal/reposettings"
 )
 
 type mockDispatcher struct {
@@ -57,9 +59,7 @@ func TestHandleWebhook_IssueComment(t *testing.T) {
 			State:  "open",
 		}
 		if isPR {
-			issue.PullRequest = &struct {
-				URL string `json:"url"`
-			}{URL: "https://api.github.com/repos/owner/repo/pulls/123"}
+			issue.PullRequest = &IssuePRLinks{URL: "https://api.github.com/repos/owner/repo/pulls/123"}
 		}
 
 		return &IssueCommentEvent{
@@ -936,6 +936,48 @@ func TestHandleWebhook_ReviewComment_IgnoresNonCreated(t *testing.T) {
 	}
 }
 
+func TestHandleWebhook_InvalidatesFetchCache(t *testing.T) {
+	secret := "test-webhook-secret"
+	var invalidated []string
+	handler := NewHandler(secret, "/code", &mockDispatcher{}, nil, nil)
+	handler.SetFetchInvalidator(func(repo string, number int) {
+		invalidated = append(invalidated, fmt.Sprintf("%s#%d", repo, number))
+	})
+
+	send := func(eventType string, event any) {
+		t.Helper()
+		payload, err := json.Marshal(event)
+		if err != nil {
+			t.Fatalf("Failed to marshal event: %v", err)
+		}
+		mac := hmac.New(sha256.New, []byte(secret))
+		mac.Write(payload)
+		req := httptest.NewRequest("POST", "/webhook", bytes.NewReader(payload))
+		req.Header.Set("X-Hub-Signature-256", "sha256="+hex.EncodeToString(mac.Sum(nil)))
+		req.Header.Set("X-GitHub-Event", eventType)
+		handler.Handle(httptest.NewRecorder(), req)
+	}
+
+	// any comment, triggering or not, even an edit
+	send("pull_request_review_comment", &PullRequestReviewCommentEvent{
+		Action:      "edited",
+		Comment:     ReviewComment{ID: 20, Body: "nit", User: User{Login: "reviewer", Type: "User"}},
+		PullRequest: PullRequest{Number: 7},
+		Repository:  Repository{FullName: "owner/repo"},
+	})
+	// but not the bot's own
+	send("issue_comment", &IssueCommentEvent{
+		Action:     "created",
+		Issue:      Issue{Number: 1},
+		Comment:    Comment{ID: 77, Body: "Working on it", User: User{Login: "swe-agent[bot]", Type: "Bot"}},
+		Repository: Repository{FullName: "owner/repo"},
+	})
+
+	if len(invalidated) != 1 || invalidated[0] != "owner/repo#7" {
+		t.Fatalf("invalidated = %v, want [owner/repo#7]", invalidated)
+	}
+}
+
 func TestHandleWebhook_ReviewComment_DefaultBranchFallback(t *testing.T) {
 	secret := "test-webhook-secret"
 	triggerKeyword := "/code"
@@ -1246,3 +1288,38 @@ func (m *mockAppAuth) GetInstallationOwner(repo string) (string, error) {
 	}
 	return "testuser", nil
 }
+
+func TestHandle_TrackingCommentLanguage(t *testing.T) {
+	h, dispatcher, _, _ := releaseHandler(t, nil)
+	h.SetReleaseMode(false)
+
+	postRelease(t, h, 1, "installer", "/code 修复解析器中的空指针")
+	if dispatcher.enqueueCalls != 1 || dispatcher.lastTask.Lang != "zh" {
+		t.Fatalf("Chinese trigger: task = %+v", dispatcher.lastTask)
+	}
+	settings, err := reposettings.Parse([]byte(`{"owner/repo": {"language": "en"}}`))
+	if err != nil {
+		t.Fatal(err)
+	}
+	h.SetRepoSettings(settings)
+	postRelease(t, h, 2, "installer", "/code 修复解析器中的空指针")
+	if dispatcher.enqueueCalls != 2 || dispatcher.lastTask.Lang != "en" {
+		t.Fatalf("repository setting: task = %+v", dispatcher.lastTask)
+	}
+}
+
+func TestHandle_ReuseTrackingComment(t *testing.T) {
+	h, dispatcher, _, _ := releaseHandler(t, nil)
+	h.SetReleaseMode(false)
+
+	postRelease(t, h, 1, "installer", "/code fix the parser")
+	if dispatcher.enqueueCalls != 1 || dispatcher.lastTask.CommentHistory {
+		t.Fatalf("reuse disabled: task = %+v", dispatcher.lastTask)
+	}
+	h.SetReuseTrackingComment(true)
+	// the mock lists no comments to reuse, so the run starts a new one
+	postRelease(t, h, 2, "installer", "/code fix the parser")
+	if dispatcher.enqueueCalls != 2 || !dispatcher.lastTask.CommentHistory || dispatcher.lastTask.CommentID == 0 {
+		t.Fatalf("reuse enabled: task = %+v", dispatcher.lastTask)
+	}
+}
@@ -0,0 +1,131 @@
+package webhook
+
+import (
+	"fmt"
+	"strings"
+)
+
+// helpCommand follows the trigger to list the commands and flags.
+const helpCommand = "help"
+
+// commandFlags are the flags a command may start with; arg is an example
+// value for flags that take one.
+var commandFlags = []struct {
+	name, arg, help string
+}{
+	{"--dry-run", "", "shows the changes without pushing them; `%s apply` pushes them"},
+	{"--timeout", "45m", "lets the task run up to 45 minutes (or `1h30m`), within the server's maximum"},
+	{"--priority", "high", "starts the task ahead of queued tasks of lower priority (`high`, `normal` or `low`)"},
+	{"--debug", "", "logs the task verbosely and keeps the provider's stderr and git output with its artifacts (repository admins only)"},
+}
+
+// isHelpCommand reports whether prompt, the text after the trigger, asks
+// for help: "help" or "--help" alone.
+func isHelpCommand(prompt string) bool {
+	p := strings.ToLower(strings.Join(strings.Fields(prompt), " "))
+	return p == helpCommand || p == "--"+helpCommand
+}
+
+// unknownFlags returns the flags prompt starts with that are not
+// commandFlags. Flags are only looked for before the instruction, where
+// "--" is not part of its text.
+func unknownFlags(prompt string) []string {
+	var unknown []string
+	fields := strings.Fields(prompt)
+	for i := 0; i < len(fields) && strings.HasPrefix(fields[i], "--"); i++ {
+		name, _, hasValue := strings.Cut(fields[i], "=")
+		name = strings.ToLower(name)
+		switch {
+		case name == "--timeout" || name == "--priority":
+			if !hasValue {
+				i++ // its value
+			}
+		case (name == "--dry-run" || name == "--debug") && !hasValue:
+		case len(name) > 2:
+			unknown = append(unknown, fields[i])
+		}
+	}
+	return unknown
+}
+
+// suggestFlag returns the known flag a mistyped one most likely meant, or
+// "".
+func suggestFlag(flag string) string {
+	normalize := func(s string) string {
+		s, _, _ = strings.Cut(strings.ToLower(s), "=")
+		return strings.Map(func(r rune) rune {
+			if r >= 'a' && r <= 'z' {
+				return r
+			}
+			return -1
+		}, s)
+	}
+	got := normalize(flag)
+	if len(got) < 3 {
+		return ""
+	}
+	for _, f := range commandFlags {
+		if known := normalize(f.name); known == got || strings.HasPrefix(known, got) {
+			return f.name
+		}
+	}
+	return ""
+}
+
+// helpText is the reply to "<trigger> help": the commands enabled on this
+// server and the flags.
+func (h *Handler) helpText(trigger string) string {
+	var b strings.Builder
+	fmt.Fprintf(&b, "**Commands**\n\n")
+	fmt.Fprintf(&b, "- `%s <instruction>` works on this issue or pull request as asked\n", trigger)
+	fmt.Fprintf(&b, "- `%s %s` addresses the unresolved review threads of a pull request\n", trigger, addressReviewsCommand)
+	fmt.Fprintf(&b, "- `%s %s to <branch>` backports a merged pull request to `<branch>`\n", trigger, backportCommand)
+	fmt.Fprintf(&b, "- `%s %s` updates the dependencies and opens a pull request\n", trigger, updateDepsCommand)
+	fmt.Fprintf(&b, "- `%s` pushes the changes of the thread's last dry run\n", applyCommand(trigger))
+	if h.approvalCommandEnabled() {
+		fmt.Fprintf(&b, "- `%s` approves the plan posted on the thread\n", approvalCommand(trigger))
+	}
+	if h.costConfirmEnabled() {
+		fmt.Fprintf(&b, "- `%s` runs the task stopped on the thread for its estimated cost\n", confirmCommand(trigger))
+	}
+	if h.triageEnabled() {
+		fmt.Fprintf(&b, "- `%s` labels the issue, looks for duplicates and sets a priority\n", TriageCommand)
+	}
+	if h.releaseEnabled() {
+		fmt.Fprintf(&b, "- `%s patch|minor|major` prepares a release, after confirmation\n", ReleaseCommand)
+	}
+	fmt.Fprintf(&b, "- `%s %s` shows this list\n", trigger, helpCommand)
+	b.WriteString("\n**Flags**, before the instruction\n\n")
+	b.WriteString(flagList(trigger))
+	return b.String()
+}
+
+// unknownFlagsText is the reply to a command with unknown flags.
+func unknownFlagsText(trigger string, unknown []string) string {
+	var b strings.Builder
+	for _, flag := range unknown {
+		fmt.Fprintf(&b, "Unknown flag `%s`.", flag)
+		if s := suggestFlag(flag); s != "" {
+			fmt.Fprintf(&b, " Did you mean `%s`?", s)
+		}
+		b.WriteString("\n")
+	}
+	fmt.Fprintf(&b, "\nNothing was started. The flags are:\n\n%s\n`%s %s` lists the commands.", flagList(trigger), trigger, helpCommand)
+	return b.String()
+}
+
+func flagList(trigger string) string {
+	var b strings.Builder
+	for _, f := range commandFlags {
+		usage := f.name
+		if f.arg != "" {
+			usage += " " + f.arg
+		}
+		help := f.help
+		if strings.Contains(help, "%s") {
+			help = fmt.Sprintf(help, trigger)
+		}
+		fmt.Fprintf(&b, "- `%s` %s\n", usage, help)
+	}
+	return b.String()
+}
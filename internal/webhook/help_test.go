@@ -0,0 +1,77 @@
+package webhook
+
+import (
+	"reflect"
+	"strings"
+	"testing"
+)
+
+func TestUnknownFlags(t *testing.T) {
+	tests := map[string][]string{
+		"--dry-run fix the parser":            nil,
+		"--DRY-RUN --timeout 45m fix it":      nil,
+		"--timeout=1h30m fix it":              nil,
+		"--debug --dry-run fix it":            nil,
+		"--priority low --dry-run fix it":     nil,
+		"fix it and add a --verbose flag":     nil,
+		"--dryrun fix it":                     {"--dryrun"},
+		"--timeout 45m --verbose --force fix": {"--verbose", "--force"},
+		"--dry-run=yes fix it":                {"--dry-run=yes"},
+		"-- fix it":                           nil,
+	}
+	for prompt, want := range tests {
+		if got := unknownFlags(prompt); !reflect.DeepEqual(got, want) {
+			t.Errorf("unknownFlags(%q) = %q, want %q", prompt, got, want)
+		}
+	}
+	for flag, want := range map[string]string{
+		"--dryrun":  "--dry-run",
+		"--Dry_Run": "--dry-run",
+		"--time":    "--timeout",
+		"--verbose": "",
+		"--d":       "",
+	} {
+		if got := suggestFlag(flag); got != want {
+			t.Errorf("suggestFlag(%q) = %q, want %q", flag, got, want)
+		}
+	}
+}
+
+func TestIsHelpCommand(t *testing.T) {
+	for prompt, want := range map[string]bool{
+		"help":              true,
+		" Help ":            true,
+		"--help":            true,
+		"help me fix it":    false,
+		"fix the help page": false,
+	} {
+		if got := isHelpCommand(prompt); got != want {
+			t.Errorf("isHelpCommand(%q) = %t", prompt, got)
+		}
+	}
+}
+
+func TestHandle_HelpAndUnknownFlags(t *testing.T) {
+	h, dispatcher, _, posted := releaseHandler(t, nil)
+	h.SetReleaseMode(false)
+
+	if w := postRelease(t, h, 1, "installer", "/code help"); w.Body.String() != "Help posted" || dispatcher.enqueueCalls != 0 {
+		t.Fatalf("help = %q", w.Body.String())
+	}
+	help := (*posted)[len(*posted)-1]
+	if !strings.Contains(help, "`/code backport to <branch>`") || !strings.Contains(help, "`--timeout 45m`") ||
+		strings.Contains(help, ReleaseCommand) || strings.Contains(help, TriageCommand) {
+		t.Fatalf("help = %q", help)
+	}
+
+	if w := postRelease(t, h, 2, "installer", "/code --dryrun fix the parser"); w.Body.String() != "Unknown flag" || dispatcher.enqueueCalls != 0 {
+		t.Fatalf("unknown flag = %q", w.Body.String())
+	}
+	if last := (*posted)[len(*posted)-1]; !strings.Contains(last, "Unknown flag `--dryrun`. Did you mean `--dry-run`?") {
+		t.Fatalf("reply = %q", last)
+	}
+
+	if w := postRelease(t, h, 3, "installer", "/code --dry-run add a --verbose flag"); dispatcher.enqueueCalls != 1 {
+		t.Fatalf("known flag = %q", w.Body.String())
+	}
+}
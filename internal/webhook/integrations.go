@@ -0,0 +1,143 @@
+package webhook
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"strings"
+
+	"github.com/cexll/swe/internal/github"
+	"github.com/cexll/swe/internal/integrations/jira"
+	"github.com/cexll/swe/internal/integrations/linear"
+	"github.com/cexll/swe/internal/integrations/slack"
+	"github.com/cexll/swe/internal/integrations/telegram"
+	"github.com/cexll/swe/internal/policy"
+)
+
+// The policy input's events for tasks started from the integrations.
+const (
+	slackEvent  github.EventType = "slack"
+	jiraEvent   github.EventType = "jira"
+	linearEvent github.EventType = "linear"
+)
+
+// LaunchJira queues the task a Jira comment asked for, on the GitHub issue
+// the Jira issue is linked to or on a new one opened for it, as the GitHub
+// user the commenter is mapped to. It satisfies jira.Launch.
+func (h *Handler) LaunchJira(ctx context.Context, req jira.TaskRequest) (string, int, error) {
+	task := ManualTaskRequest{Repo: req.Repo, Number: req.Number, Prompt: req.Prompt, Actor: req.Actor, idempotencyKey: req.IdempotencyKey}
+	if err := h.authorizeLinked(ctx, jiraEvent, "Jira", task); err != nil {
+		return "", req.Number, err
+	}
+	t, number, err := h.launchLinked(ctx, task, req.Title, req.Body, req.Summary)
+	if errors.Is(err, ErrDuplicateTask) {
+		return "", number, jira.ErrDuplicate
+	}
+	if err != nil {
+		return "", number, err
+	}
+	taskLog(t, phaseEnqueue).InfoContext(ctx, "Jira task queued", "number", number)
+	return t.ID, number, nil
+}
+
+// LaunchLinear queues the task a Linear comment asked for, on the GitHub
+// issue or pull request the Linear issue is linked to or on a new issue
+// opened for it, as the GitHub user the commenter is mapped to. It
+// satisfies linear.Launch.
+func (h *Handler) LaunchLinear(ctx context.Context, req linear.TaskRequest) (string, int, error) {
+	task := ManualTaskRequest{Repo: req.Repo, Number: req.Number, IsPR: req.IsPR, Prompt: req.Prompt, Actor: req.Actor, idempotencyKey: req.IdempotencyKey}
+	if err := h.authorizeLinked(ctx, linearEvent, "Linear", task); err != nil {
+		return "", req.Number, err
+	}
+	t, number, err := h.launchLinked(ctx, task, req.Title, req.Body, req.Summary)
+	if errors.Is(err, ErrDuplicateTask) {
+		return "", number, linear.ErrDuplicate
+	}
+	if err != nil {
+		return "", number, err
+	}
+	taskLog(t, phaseEnqueue).InfoContext(ctx, "Linear task queued", "number", number)
+	return t.ID, number, nil
+}
+
+// LaunchSlack queues the task a Slack user asked for, as the GitHub user
+// they are mapped to. It satisfies slack.Launch.
+func (h *Handler) LaunchSlack(ctx context.Context, req slack.TaskRequest) (string, int, error) {
+	task := ManualTaskRequest{Repo: req.Repo, Number: req.Number, Prompt: req.Prompt, Actor: req.Actor}
+	if err := h.authorizeLinked(ctx, slackEvent, "Slack", task); err != nil {
+		return "", req.Number, err
+	}
+	t, number, err := h.launchLinked(ctx, task, req.Title, req.Body, req.Summary)
+	if err != nil {
+		return "", number, err
+	}
+	taskLog(t, phaseEnqueue).InfoContext(ctx, "Slack task queued", "number", number, "user", req.Actor)
+	return t.ID, number, nil
+}
+
+// LaunchTelegram queues the task an allowlisted Telegram chat asked for. It
+// satisfies telegram.Launch.
+func (h *Handler) LaunchTelegram(ctx context.Context, req telegram.TaskRequest) (string, int, error) {
+	task := ManualTaskRequest{Repo: req.Repo, Number: req.Number, Prompt: req.Prompt, Actor: req.Actor, idempotencyKey: req.IdempotencyKey}
+	t, number, err := h.launchLinked(ctx, task, req.Title, req.Body, req.Summary)
+	if errors.Is(err, ErrDuplicateTask) {
+		return "", number, telegram.ErrDuplicate
+	}
+	if err != nil {
+		return "", number, err
+	}
+	taskLog(t, phaseEnqueue).InfoContext(ctx, "Telegram task queued", "number", number)
+	return t.ID, number, nil
+}
+
+// authorizeLinked checks that req.Actor, the GitHub user an integration
+// mapped its user to, has the permission a trigger comment asking for
+// req.Prompt would need: the policy when configured, otherwise the built-in
+// checks. A task the policy would hold for review is refused, since nothing
+// in the integration, named by name, can approve or apply it. The decision
+// is audited; event is the policy input's event.
+func (h *Handler) authorizeLinked(ctx context.Context, event github.EventType, name string, req ManualTaskRequest) error {
+	owner, repo, _ := strings.Cut(req.Repo, "/")
+	trigger := h.triggerFor(req.Repo)
+	ghCtx := &github.Context{
+		EventName:      event,
+		Repository:     github.Repository{Owner: owner, Name: repo, FullName: req.Repo},
+		IsPR:           req.IsPR,
+		IssueNumber:    req.Number,
+		TriggerUser:    req.Actor,
+		TriggerComment: &github.Comment{Body: trigger + " " + req.Prompt, User: req.Actor},
+	}
+	if req.IsPR {
+		ghCtx.PRNumber = req.Number
+	}
+	decision := h.authorize(ghCtx, commandName(trigger))
+	if decision.Allowed && (decision.Effect == policy.RequireApproval || decision.Effect == policy.DryRun) {
+		decision.Allowed = false
+		decision.Reason += fmt.Sprintf(" (%s tasks cannot be held for review)", name)
+	}
+	h.recordPermission(ghCtx, decision.Allowed, decision.Reason)
+	if !decision.Allowed {
+		eventLog(ghCtx, phaseAuthorize).InfoContext(ctx, "Permission denied", "user", req.Actor, "reason", decision.Reason)
+		return fmt.Errorf("%s may not trigger tasks in %s: %s", req.Actor, req.Repo, decision.Reason)
+	}
+	return nil
+}
+
+// launchLinked queues req for an issue tracker that links its issues to
+// GitHub: on req.Number, or without one on a new issue with title and body.
+// It returns the issue or pull request worked on, also when queueing fails
+// after the issue was opened, and ErrDuplicateTask when req's idempotency
+// key was already accepted.
+func (h *Handler) launchLinked(ctx context.Context, req ManualTaskRequest, title, body, summary string) (*Task, int, error) {
+	if h.alreadyAccepted(req.idempotencyKey) {
+		return nil, req.Number, ErrDuplicateTask
+	}
+	if req.Number == 0 {
+		return h.launchOnNewIssue(ctx, req, title, body, nil, summary)
+	}
+	if enabled, reason := h.checkRepo(req.Repo); !enabled {
+		return nil, req.Number, fmt.Errorf("%s (%s)", RepoNotEnabledMessage, reason)
+	}
+	t, err := h.queueOnIssue(ctx, req, summary)
+	return t, req.Number, err
+}
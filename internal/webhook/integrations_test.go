@@ -0,0 +1,170 @@
+package webhook
+
+import (
+	"context"
+	"errors"
+	"strings"
+	"testing"
+
+	"github.com/cexll/swe/internal/audit"
+	"github.com/cexll/swe/internal/github"
+	"github.com/cexll/swe/internal/integrations/jira"
+	"github.com/cexll/swe/internal/integrations/linear"
+	"github.com/cexll/swe/internal/integrations/slack"
+	"github.com/cexll/swe/internal/integrations/telegram"
+	"github.com/cexll/swe/internal/taskstore"
+)
+
+func TestLaunchJira(t *testing.T) {
+	orig := createIssue
+	t.Cleanup(func() { createIssue = orig })
+	createIssue = func(owner, repo, title, body string, labels []string, token string) (int, error) {
+		if title != "[OPS-7] Login times out" || body != "mirrored" {
+			t.Errorf("createIssue(%q, %q)", title, body)
+		}
+		return 12, nil
+	}
+	dispatcher := &keyedDispatcher{accepted: map[string]bool{}}
+	auth := &mockAppAuth{
+		GetInstallationTokenFunc: func(repo string) (*github.InstallationToken, error) {
+			return &github.InstallationToken{Token: "inst-token"}, nil
+		},
+		GetInstallationOwnerFunc: func(string) (string, error) { return "installer", nil },
+	}
+	handler := NewHandler("secret", "/code", dispatcher, taskstore.NewStore(), auth)
+
+	// the commenter's GitHub user needs the permission to trigger tasks
+	req := jira.TaskRequest{Repo: "owner/repo", Title: "[OPS-7] Login times out", Body: "mirrored", Prompt: "fix it",
+		Actor: "mallory", Summary: "**Jira:** OPS-7", IdempotencyKey: "jira:10001"}
+	if _, _, err := handler.LaunchJira(context.Background(), req); err == nil || dispatcher.enqueueCalls != 0 {
+		t.Fatalf("unpermitted user: err = %v, %d tasks queued", err, dispatcher.enqueueCalls)
+	}
+	req.Actor = "installer"
+	taskID, number, err := handler.LaunchJira(context.Background(), req)
+	if err != nil {
+		t.Fatalf("LaunchJira: %v", err)
+	}
+	task := dispatcher.lastTask
+	if task == nil || task.ID != taskID || number != 12 || task.Number != 12 || task.Username != "installer" ||
+		task.IdempotencyKey != "jira:10001" || task.PromptSummary != "**Jira:** OPS-7" {
+		t.Fatalf("task %+v, issue %d", task, number)
+	}
+
+	// a later comment works on the linked issue; a redelivered one is refused
+	req.Number, req.IdempotencyKey = 12, "jira:10002"
+	if _, number, err := handler.LaunchJira(context.Background(), req); err != nil || number != 12 || dispatcher.lastTask.Number != 12 {
+		t.Fatalf("linked issue: %d, %v", number, err)
+	}
+	dispatcher.accepted["jira:10002"] = true
+	if _, _, err := handler.LaunchJira(context.Background(), req); !errors.Is(err, jira.ErrDuplicate) {
+		t.Fatalf("redelivery: err = %v", err)
+	}
+}
+
+func TestLaunchLinear_PullRequest(t *testing.T) {
+	dispatcher := &keyedDispatcher{accepted: map[string]bool{}}
+	auth := &mockAppAuth{
+		GetInstallationTokenFunc: func(repo string) (*github.InstallationToken, error) {
+			return &github.InstallationToken{Token: "inst-token"}, nil
+		},
+		GetInstallationOwnerFunc: func(string) (string, error) { return "installer", nil },
+	}
+	handler := NewHandler("secret", "/code", dispatcher, taskstore.NewStore(), auth)
+
+	// the commenter's GitHub user needs the permission to trigger tasks
+	req := linear.TaskRequest{Repo: "owner/repo", Number: 9, IsPR: true, Prompt: "address the review", Actor: "mallory",
+		Summary: "**Linear:** ENG-42", IdempotencyKey: "linear:c-1"}
+	if _, _, err := handler.LaunchLinear(context.Background(), req); err == nil || dispatcher.enqueueCalls != 0 {
+		t.Fatalf("unpermitted user: err = %v, %d tasks queued", err, dispatcher.enqueueCalls)
+	}
+	req.Actor = "installer"
+	taskID, number, err := handler.LaunchLinear(context.Background(), req)
+	if err != nil {
+		t.Fatalf("LaunchLinear: %v", err)
+	}
+	task := dispatcher.lastTask
+	if task == nil || task.ID != taskID || number != 9 || !task.IsPR || task.Username != "installer" || task.IdempotencyKey != "linear:c-1" {
+		t.Fatalf("task %+v, number %d", task, number)
+	}
+	dispatcher.accepted["linear:c-1"] = true
+	if _, _, err := handler.LaunchLinear(context.Background(), req); !errors.Is(err, linear.ErrDuplicate) {
+		t.Fatalf("redelivery: err = %v", err)
+	}
+}
+
+func TestLaunchSlack_ChecksPermission(t *testing.T) {
+	dispatcher := &keyedDispatcher{accepted: map[string]bool{}}
+	auth := &mockAppAuth{GetInstallationOwnerFunc: func(string) (string, error) { return "installer", nil }}
+	handler := NewHandler("secret", "/code", dispatcher, taskstore.NewStore(), auth)
+
+	req := slack.TaskRequest{Repo: "owner/repo", Number: 5, Prompt: "fix it", Actor: "mallory", Summary: "**Slack:** asked by @mallory"}
+	if _, _, err := handler.LaunchSlack(context.Background(), req); err == nil || dispatcher.enqueueCalls != 0 {
+		t.Fatalf("unpermitted user: err = %v, %d tasks queued", err, dispatcher.enqueueCalls)
+	}
+	req.Actor = "installer"
+	taskID, number, err := handler.LaunchSlack(context.Background(), req)
+	if err != nil {
+		t.Fatalf("LaunchSlack: %v", err)
+	}
+	if task := dispatcher.lastTask; task.ID != taskID || number != 5 || task.Username != "installer" {
+		t.Fatalf("task %+v, number %d", task, number)
+	}
+}
+
+func TestLaunchSlack_AppliesPolicy(t *testing.T) {
+	dispatcher := &keyedDispatcher{accepted: map[string]bool{}}
+	auth := &mockAppAuth{GetInstallationOwnerFunc: func(string) (string, error) { return "installer", nil }}
+	handler := NewHandler("secret", "/code", dispatcher, taskstore.NewStore(), auth)
+	log, _ := audit.New(audit.Config{})
+	handler.SetAuditLog(log)
+	handler.SetPolicy(mustPolicy(t, `{
+	  "rules": [
+	    {"name": "no-slack-force", "effect": "deny", "when": "event == 'slack' && 'force' in flags"},
+	    {"name": "plan-api", "effect": "require_approval", "when": "repo == 'owner/api'"}
+	  ],
+	  "default": "allow"
+	}`))
+
+	// the policy, not the installer check, decides: anyone may ask
+	req := slack.TaskRequest{Repo: "owner/repo", Number: 5, Prompt: "fix it", Actor: "mallory"}
+	if _, _, err := handler.LaunchSlack(context.Background(), req); err != nil || dispatcher.enqueueCalls != 1 {
+		t.Fatalf("allowed by the policy: err = %v, %d tasks queued", err, dispatcher.enqueueCalls)
+	}
+	req.Prompt = "fix it --force"
+	if _, _, err := handler.LaunchSlack(context.Background(), req); err == nil || !strings.Contains(err.Error(), "no-slack-force") || dispatcher.enqueueCalls != 1 {
+		t.Fatalf("denied by the policy: err = %v, %d tasks queued", err, dispatcher.enqueueCalls)
+	}
+	req.Repo, req.Prompt = "owner/api", "fix it"
+	if _, _, err := handler.LaunchSlack(context.Background(), req); err == nil || !strings.Contains(err.Error(), "cannot be held") || dispatcher.enqueueCalls != 1 {
+		t.Fatalf("held by the policy: err = %v, %d tasks queued", err, dispatcher.enqueueCalls)
+	}
+
+	events := log.List(audit.Filter{Action: audit.ActionPermission, Actor: "mallory"})
+	if len(events) != 3 {
+		t.Fatalf("permission audit = %+v", events)
+	}
+	denied := 0
+	for _, ev := range events {
+		if ev.Decision == audit.DecisionDenied {
+			denied++
+		}
+	}
+	if denied != 2 {
+		t.Fatalf("permission audit = %+v", events)
+	}
+}
+
+func TestLaunchTelegram(t *testing.T) {
+	dispatcher := &keyedDispatcher{accepted: map[string]bool{}}
+	handler := NewHandler("secret", "/code", dispatcher, taskstore.NewStore(), &mockAppAuth{})
+
+	req := telegram.TaskRequest{Repo: "owner/repo", Number: 3, Prompt: "fix it", Actor: "telegram", IdempotencyKey: "telegram:102"}
+	taskID, number, err := handler.LaunchTelegram(context.Background(), req)
+	if task := dispatcher.lastTask; err != nil || task == nil || task.ID != taskID || number != 3 || task.IdempotencyKey != "telegram:102" {
+		t.Fatalf("LaunchTelegram: task %+v, number %d, err %v", task, number, err)
+	}
+	dispatcher.accepted["telegram:102"] = true
+	if _, _, err := handler.LaunchTelegram(context.Background(), req); !errors.Is(err, telegram.ErrDuplicate) {
+		t.Fatalf("redelivery: err = %v", err)
+	}
+}
@@ -0,0 +1,28 @@
+package webhook
+
+import (
+	"log/slog"
+
+	"github.com/cexll/swe/internal/github"
+	"github.com/cexll/swe/internal/logging"
+)
+
+// Phases of the lines the handler logs.
+const (
+	phaseWebhook   = "webhook"
+	phaseAuthorize = "authorize"
+	phaseEnqueue   = "enqueue"
+	phaseReply     = "reply"
+)
+
+// eventLog returns the logger for lines about the event of ghCtx in phase:
+// they carry its repository and, logged with the request's context, the
+// request and delivery IDs.
+func eventLog(ghCtx *github.Context, phase string) *slog.Logger {
+	return logging.Logger(logging.KeyRepo, ghCtx.GetRepositoryFullName(), logging.KeyPhase, phase)
+}
+
+// taskLog returns the logger for lines about t in phase.
+func taskLog(t *Task, phase string) *slog.Logger {
+	return logging.Logger(logging.KeyTaskID, t.ID, logging.KeyRepo, t.Repo, logging.KeyDeliveryID, t.DeliveryID, logging.KeyPhase, phase)
+}
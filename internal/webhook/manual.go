@@ -0,0 +1,247 @@
+package webhook
+
+import (
+	"context"
+	"crypto/subtle"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"log/slog"
+	"net/http"
+	"slices"
+	"strings"
+	"time"
+
+	"github.com/cexll/swe/internal/github"
+	"github.com/cexll/swe/internal/logging"
+)
+
+// defaultManualActor is recorded as the trigger user when a manual request
+// does not name one.
+const defaultManualActor = "api"
+
+// ManualTaskRequest is the body accepted by POST /api/v1/tasks.
+type ManualTaskRequest struct {
+	Repo       string `json:"repo"`                  // owner/name
+	Number     int    `json:"number"`                // issue or PR number
+	IsPR       bool   `json:"is_pr,omitempty"`       // number refers to a pull request
+	Prompt     string `json:"prompt"`                // instruction, as written after the trigger keyword
+	BaseBranch string `json:"base_branch,omitempty"` // defaults to the executor fallback (main)
+	Actor      string `json:"actor,omitempty"`       // operator recorded in the task and audit log
+	// DependsOn lists the IDs of tasks that must complete before this one
+	// starts; it fails without running if one of them fails
+	DependsOn []string `json:"depends_on,omitempty"`
+	// Priority is "high", "normal" or "low"; a --priority flag in the
+	// prompt wins, and without either the task is normal
+	Priority string `json:"priority,omitempty"`
+
+	// idempotencyKey is the task's Task.IdempotencyKey, for requests made
+	// on behalf of a delivery
+	idempotencyKey string
+	// replayOf is the task a replay repeats
+	replayOf string
+}
+
+// ManualTaskResponse is returned when a manual task is queued.
+type ManualTaskResponse struct {
+	TaskID string `json:"task_id"`
+	Status string `json:"status"`
+	URL    string `json:"url"`
+}
+
+// SetAPIToken enables the manual task API; requests must send it as a bearer token.
+func (h *Handler) SetAPIToken(token string) {
+	h.apiToken = token
+}
+
+// SubmitTask launches a task directly from an operator request, bypassing
+// webhook delivery. The request is turned into a synthetic issue_comment so
+// it follows the same preparation and executor path as a /code comment.
+func (h *Handler) SubmitTask(w http.ResponseWriter, r *http.Request) {
+	if h.apiToken == "" {
+		http.Error(w, "manual task API disabled (API_TOKEN not set)", http.StatusServiceUnavailable)
+		return
+	}
+	if !h.authorizedOperator(r) {
+		w.Header().Set("WWW-Authenticate", `Bearer realm="swe-agent"`)
+		http.Error(w, "unauthorized", http.StatusUnauthorized)
+		return
+	}
+
+	var req ManualTaskRequest
+	if err := json.NewDecoder(http.MaxBytesReader(w, r.Body, 1<<20)).Decode(&req); err != nil {
+		http.Error(w, "invalid JSON body", http.StatusBadRequest)
+		return
+	}
+	if err := req.validate(); err != nil {
+		http.Error(w, err.Error(), http.StatusBadRequest)
+		return
+	}
+	h.submitManual(r.Context(), w, req)
+}
+
+// submitManual queues the task for a validated request and answers with
+// where to follow it.
+func (h *Handler) submitManual(ctx context.Context, w http.ResponseWriter, req ManualTaskRequest) {
+	if enabled, reason := h.checkRepo(req.Repo); !enabled {
+		http.Error(w, fmt.Sprintf("%s (%s)", RepoNotEnabledMessage, reason), http.StatusForbidden)
+		return
+	}
+	if err := h.checkDependencies(req.DependsOn); err != nil {
+		http.Error(w, err.Error(), http.StatusBadRequest)
+		return
+	}
+
+	t, err := h.manualTask(ctx, req)
+	if errors.Is(err, errBuildManualTask) {
+		http.Error(w, "failed to build task", http.StatusInternalServerError)
+		return
+	}
+	if err != nil {
+		slog.ErrorContext(ctx, "Preparing manual task failed", logging.KeyRepo, req.Repo, logging.KeyPhase, phaseEnqueue, "err", err)
+		http.Error(w, "Task preparation failed", http.StatusInternalServerError)
+		return
+	}
+	if err := h.dispatchTask(t); err != nil {
+		status, msg := enqueueErrorStatus(err)
+		http.Error(w, msg, status)
+		return
+	}
+
+	taskLog(t, phaseEnqueue).InfoContext(ctx, "Manual task queued", "number", t.Number, "user", t.Username)
+
+	w.Header().Set("Content-Type", "application/json")
+	w.Header().Set("Location", "/tasks/"+t.ID)
+	w.WriteHeader(http.StatusAccepted)
+	_ = json.NewEncoder(w).Encode(ManualTaskResponse{TaskID: t.ID, Status: "queued", URL: "/tasks/" + t.ID})
+}
+
+var errBuildManualTask = errors.New("failed to build task")
+
+// manualTask prepares the task for a validated request from the synthetic
+// issue_comment it stands for.
+func (h *Handler) manualTask(ctx context.Context, req ManualTaskRequest) (*Task, error) {
+	payload, err := json.Marshal(req.event(h.triggerFor(req.Repo)))
+	if err != nil {
+		return nil, fmt.Errorf("%w: %v", errBuildManualTask, err)
+	}
+	ghCtx, err := github.ParseWebhookEvent(EventNameIssueComment, payload)
+	if err != nil {
+		return nil, fmt.Errorf("%w: %v", errBuildManualTask, err)
+	}
+	if req.replayOf != "" {
+		ctx = context.WithValue(ctx, replayOfKey{}, req.replayOf)
+	}
+	t, err := h.prepareTask(withDependsOn(ctx, req.DependsOn), ghCtx, payload)
+	if err != nil || req.Priority == "" {
+		return t, err
+	}
+	if _, flagged := parsePriorityFlag(req.Prompt); !flagged {
+		t.Priority, _ = ParsePriority(req.Priority)
+	}
+	return t, nil
+}
+
+// checkDependencies reports an error when a task depended on is unknown.
+func (h *Handler) checkDependencies(ids []string) error {
+	if len(ids) == 0 {
+		return nil
+	}
+	if h.store == nil {
+		return errors.New("depends_on needs the task store")
+	}
+	for _, id := range ids {
+		if _, ok := h.store.Get(id); !ok {
+			return fmt.Errorf("depends_on: unknown task %q", id)
+		}
+	}
+	return nil
+}
+
+type dependsOnKey struct{}
+
+// withDependsOn makes the task prepared with ctx depend on ids.
+func withDependsOn(ctx context.Context, ids []string) context.Context {
+	if len(ids) == 0 {
+		return ctx
+	}
+	return context.WithValue(ctx, dependsOnKey{}, ids)
+}
+
+// dependsOnFrom returns the tasks a task prepared with ctx depends on.
+func dependsOnFrom(ctx context.Context) []string {
+	ids, _ := ctx.Value(dependsOnKey{}).([]string)
+	return ids
+}
+
+func (h *Handler) authorizedOperator(r *http.Request) bool {
+	return OperatorAuthorized(r, h.apiToken)
+}
+
+// OperatorAuthorized reports whether r carries token as its bearer token.
+func OperatorAuthorized(r *http.Request, token string) bool {
+	auth := r.Header.Get("Authorization")
+	const prefix = "Bearer "
+	if len(auth) <= len(prefix) || !strings.EqualFold(auth[:len(prefix)], prefix) {
+		return false
+	}
+	got := strings.TrimSpace(auth[len(prefix):])
+	return subtle.ConstantTimeCompare([]byte(got), []byte(token)) == 1
+}
+
+func (req *ManualTaskRequest) validate() error {
+	req.Repo = strings.TrimSpace(req.Repo)
+	req.Prompt = strings.TrimSpace(req.Prompt)
+	req.Actor = strings.TrimSpace(req.Actor)
+	owner, name := splitRepo(req.Repo)
+	if owner == "" || name == "" || strings.Contains(name, "/") {
+		return errors.New("repo must be in owner/name form")
+	}
+	if req.Number <= 0 {
+		return errors.New("number must be a positive issue or PR number")
+	}
+	if req.Prompt == "" {
+		return errors.New("prompt is required")
+	}
+	if req.Actor == "" {
+		req.Actor = defaultManualActor
+	}
+	var deps []string
+	for _, id := range req.DependsOn {
+		if id = strings.TrimSpace(id); id != "" && !slices.Contains(deps, id) {
+			deps = append(deps, id)
+		}
+	}
+	req.DependsOn = deps
+	if _, err := ParsePriority(req.Priority); err != nil {
+		return err
+	}
+	return nil
+}
+
+// event builds the issue_comment payload equivalent to the operator having
+// commented "<trigger> <prompt>" on the issue or PR.
+func (req ManualTaskRequest) event(triggerKeyword string) *IssueCommentEvent {
+	owner, name := splitRepo(req.Repo)
+	operator := User{Login: req.Actor, Type: "User"}
+	ev := &IssueCommentEvent{
+		Action: "created",
+		Issue:  Issue{Number: req.Number, State: "open"},
+		Comment: Comment{
+			Body:      strings.TrimSpace(triggerKeyword + " " + req.Prompt),
+			User:      operator,
+			CreatedAt: time.Now().UTC(),
+		},
+		Repository: Repository{
+			FullName:      req.Repo,
+			Name:          name,
+			Owner:         User{Login: owner},
+			DefaultBranch: strings.TrimSpace(req.BaseBranch),
+		},
+		Sender: operator,
+	}
+	if req.IsPR {
+		ev.Issue.PullRequest = &IssuePRLinks{URL: fmt.Sprintf("https://api.github.com/repos/%s/pulls/%d", req.Repo, req.Number)}
+	}
+	return ev
+}
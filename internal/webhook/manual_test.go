@@ -0,0 +1,155 @@
+package webhook
+
+import (
+	"bytes"
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+
+	"github.com/cexll/swe/internal/github"
+	"github.com/cexll/swe/internal/taskstore"
+)
+
+func manualRequest(token, body string) *http.Request {
+	req := httptest.NewRequest(http.MethodPost, "/api/v1/tasks", bytes.NewBufferString(body))
+	if token != "" {
+		req.Header.Set("Authorization", "Bearer "+token)
+	}
+	return req
+}
+
+func TestSubmitTask_Disabled(t *testing.T) {
+	handler := NewHandler("secret", "/code", &mockDispatcher{}, nil, nil)
+	w := httptest.NewRecorder()
+	handler.SubmitTask(w, manualRequest("anything", `{}`))
+	if w.Code != http.StatusServiceUnavailable {
+		t.Fatalf("status = %d, want 503", w.Code)
+	}
+}
+
+func TestSubmitTask_Unauthorized(t *testing.T) {
+	handler := NewHandler("secret", "/code", &mockDispatcher{}, nil, nil)
+	handler.SetAPIToken("op-token")
+
+	for _, token := range []string{"", "wrong"} {
+		w := httptest.NewRecorder()
+		handler.SubmitTask(w, manualRequest(token, `{"repo":"o/r","number":1,"prompt":"x"}`))
+		if w.Code != http.StatusUnauthorized || w.Header().Get("WWW-Authenticate") == "" {
+			t.Fatalf("token %q: status = %d", token, w.Code)
+		}
+	}
+}
+
+func TestSubmitTask_Validation(t *testing.T) {
+	handler := NewHandler("secret", "/code", &mockDispatcher{}, nil, nil)
+	handler.SetAPIToken("op-token")
+
+	cases := map[string]string{
+		"bad json":      `{`,
+		"missing repo":  `{"number":1,"prompt":"x"}`,
+		"bad repo":      `{"repo":"a/b/c","number":1,"prompt":"x"}`,
+		"no number":     `{"repo":"o/r","prompt":"x"}`,
+		"empty prompt":  `{"repo":"o/r","number":1,"prompt":"  "}`,
+		"negative":      `{"repo":"o/r","number":-4,"prompt":"x"}`,
+		"no owner part": `{"repo":"/r","number":1,"prompt":"x"}`,
+	}
+	for name, body := range cases {
+		w := httptest.NewRecorder()
+		handler.SubmitTask(w, manualRequest("op-token", body))
+		if w.Code != http.StatusBadRequest {
+			t.Errorf("%s: status = %d, want 400", name, w.Code)
+		}
+	}
+}
+
+func TestSubmitTask_QueuesThroughExecutorPath(t *testing.T) {
+	dispatcher := &mockDispatcher{}
+	store := taskstore.NewStore()
+	auth := &mockAppAuth{GetInstallationTokenFunc: func(repo string) (*github.InstallationToken, error) {
+		return &github.InstallationToken{Token: "inst-token"}, nil
+	}}
+	handler := NewHandler("secret", "/code", dispatcher, store, auth)
+	handler.SetAPIToken("op-token")
+
+	w := httptest.NewRecorder()
+	handler.SubmitTask(w, manualRequest("op-token", `{"repo":"owner/repo","number":77,"is_pr":true,"prompt":"backfill the docs","base_branch":"develop","actor":"oncall"}`))
+	if w.Code != http.StatusAccepted {
+		t.Fatalf("status = %d: %s", w.Code, w.Body.String())
+	}
+
+	var resp ManualTaskResponse
+	if err := json.Unmarshal(w.Body.Bytes(), &resp); err != nil {
+		t.Fatalf("decode: %v", err)
+	}
+	task := dispatcher.lastTask
+	if task == nil || dispatcher.enqueueCalls != 1 {
+		t.Fatalf("task not enqueued (%d calls)", dispatcher.enqueueCalls)
+	}
+	if resp.TaskID != task.ID || resp.Status != "queued" || w.Header().Get("Location") != "/tasks/"+task.ID {
+		t.Fatalf("unexpected response %+v / Location %q", resp, w.Header().Get("Location"))
+	}
+	if task.Repo != "owner/repo" || task.Number != 77 || !task.IsPR || task.Username != "oncall" ||
+		task.EventType != "issue_comment" || task.Mode != "command" || task.BaseBranch != "develop" {
+		t.Fatalf("unexpected task: %+v", task)
+	}
+	if !strings.Contains(task.PromptSummary, "backfill the docs") {
+		t.Fatalf("summary missing instruction: %q", task.PromptSummary)
+	}
+
+	// The synthetic payload must parse exactly like a real /code comment
+	ghCtx, err := github.ParseWebhookEvent(task.EventType, task.RawPayload)
+	if err != nil {
+		t.Fatalf("payload does not parse: %v", err)
+	}
+	if !ghCtx.ShouldTrigger("/code") || ghCtx.ExtractPrompt("/code") != "backfill the docs" || !ghCtx.IsPRContext() {
+		t.Fatalf("unexpected parsed context: %+v", ghCtx)
+	}
+	if _, ok := store.Get(task.ID); !ok {
+		t.Fatal("task missing from store")
+	}
+}
+
+func TestSubmitTask_QueueFull(t *testing.T) {
+	dispatcher := &mockDispatcher{enqueueFunc: func(*Task) error { return ErrQueueFull }}
+	handler := NewHandler("secret", "/code", dispatcher, nil, nil)
+	handler.SetAPIToken("op-token")
+
+	w := httptest.NewRecorder()
+	handler.SubmitTask(w, manualRequest("op-token", `{"repo":"owner/repo","number":1,"prompt":"go"}`))
+	if w.Code != http.StatusServiceUnavailable {
+		t.Fatalf("status = %d, want 503", w.Code)
+	}
+}
+
+func TestSubmitTask_DependsOn(t *testing.T) {
+	dispatcher := &mockDispatcher{}
+	store := taskstore.NewStore()
+	store.Create(&taskstore.Task{ID: "plan", Status: taskstore.StatusPending, RepoOwner: "owner", RepoName: "repo", IssueNumber: 7})
+	handler := NewHandler("secret", "/code", dispatcher, store, nil)
+	handler.SetAPIToken("op-token")
+
+	w := httptest.NewRecorder()
+	handler.SubmitTask(w, manualRequest("op-token", `{"repo":"owner/repo","number":7,"prompt":"implement","depends_on":["unknown"]}`))
+	if w.Code != http.StatusBadRequest || !strings.Contains(w.Body.String(), `unknown task "unknown"`) {
+		t.Fatalf("unknown prerequisite: status = %d: %s", w.Code, w.Body.String())
+	}
+
+	w = httptest.NewRecorder()
+	handler.SubmitTask(w, manualRequest("op-token", `{"repo":"owner/repo","number":7,"prompt":"implement","depends_on":[" plan ","plan"]}`))
+	if w.Code != http.StatusAccepted {
+		t.Fatalf("status = %d: %s", w.Code, w.Body.String())
+	}
+	task := dispatcher.lastTask
+	if len(task.DependsOn) != 1 || task.DependsOn[0] != "plan" {
+		t.Fatalf("task depends on %q", task.DependsOn)
+	}
+	if stored, _ := store.Get(task.ID); len(stored.DependsOn) != 1 {
+		t.Fatalf("stored task depends on %q", stored.DependsOn)
+	}
+	// the prerequisite on the same issue runs first instead of being superseded
+	if status, _ := store.Status("plan"); status != taskstore.StatusPending {
+		t.Fatalf("prerequisite %s", status)
+	}
+}
@@ -0,0 +1,95 @@
+package webhook
+
+import (
+	"strings"
+	"sync"
+	"time"
+)
+
+// orgEntry is what GitHub said about a user in an organization: their team
+// slugs or their role, depending on the key.
+type orgEntry struct {
+	teams  []string
+	role   string
+	expiry time.Time
+}
+
+// orgCache remembers team memberships and organization roles per
+// organization and user for policies that read them, so busy organizations
+// do not pay two API lookups on every trigger. Failed lookups are not
+// cached; membership events drop everything. A nil cache never hits.
+type orgCache struct {
+	mu      sync.Mutex
+	entries map[string]orgEntry
+	ttl     time.Duration
+}
+
+// newOrgCache returns a cache; a TTL <= 0 disables it.
+func newOrgCache(ttl time.Duration) *orgCache {
+	return &orgCache{entries: make(map[string]orgEntry), ttl: ttl}
+}
+
+func orgKey(kind, org, username string) string {
+	return kind + "\x00" + strings.ToLower(org) + "\x00" + strings.ToLower(username)
+}
+
+// get returns the cached entry of kind ("teams" or "role"), if still fresh.
+func (c *orgCache) get(kind, org, username string) (orgEntry, bool) {
+	if c == nil {
+		return orgEntry{}, false
+	}
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	key := orgKey(kind, org, username)
+	entry, ok := c.entries[key]
+	if !ok {
+		return orgEntry{}, false
+	}
+	if time.Now().After(entry.expiry) {
+		delete(c.entries, key)
+		return orgEntry{}, false
+	}
+	return entry, true
+}
+
+func (c *orgCache) set(kind, org, username string, entry orgEntry) {
+	if c == nil {
+		return
+	}
+	now := time.Now()
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	if c.ttl <= 0 {
+		return
+	}
+	for key, e := range c.entries {
+		if now.After(e.expiry) {
+			delete(c.entries, key)
+		}
+	}
+	entry.expiry = now.Add(c.ttl)
+	c.entries[orgKey(kind, org, username)] = entry
+}
+
+// setTTL changes the lifetime and drops entries cached under the old one.
+func (c *orgCache) setTTL(ttl time.Duration) {
+	if c == nil {
+		return
+	}
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	c.ttl = ttl
+	c.entries = make(map[string]orgEntry)
+}
+
+// invalidate drops every entry and returns how many there were.
+func (c *orgCache) invalidate() int {
+	if c == nil {
+		return 0
+	}
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	n := len(c.entries)
+	c.entries = make(map[string]orgEntry)
+	return n
+}
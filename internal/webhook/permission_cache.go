@@ -0,0 +1,147 @@
+package webhook
+
+import (
+	"encoding/json"
+	"strings"
+	"sync"
+	"time"
+)
+
+// Default lifetimes for cached permission decisions. Denials expire sooner so
+// a newly added collaborator is not locked out for long.
+const (
+	defaultPermissionTTL         = 5 * time.Minute
+	defaultPermissionNegativeTTL = time.Minute
+)
+
+type permissionEntry struct {
+	allowed bool
+	expiry  time.Time
+}
+
+// permissionCache remembers permission decisions per repo and user so busy
+// repositories do not pay an API lookup on every trigger. A nil cache never
+// hits.
+type permissionCache struct {
+	mu          sync.Mutex
+	entries     map[string]permissionEntry
+	positiveTTL time.Duration
+	negativeTTL time.Duration
+}
+
+// newPermissionCache returns a cache; a TTL <= 0 disables caching of that result.
+func newPermissionCache(positiveTTL, negativeTTL time.Duration) *permissionCache {
+	return &permissionCache{
+		entries:     make(map[string]permissionEntry),
+		positiveTTL: positiveTTL,
+		negativeTTL: negativeTTL,
+	}
+}
+
+func permissionKey(repo, username string) string {
+	return strings.ToLower(repo) + "\x00" + username
+}
+
+// get returns the cached decision, if still fresh.
+func (c *permissionCache) get(repo, username string) (allowed, ok bool) {
+	if c == nil {
+		return false, false
+	}
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	key := permissionKey(repo, username)
+	entry, found := c.entries[key]
+	if !found {
+		return false, false
+	}
+	if time.Now().After(entry.expiry) {
+		delete(c.entries, key)
+		return false, false
+	}
+	return entry.allowed, true
+}
+
+// set stores a decision with the TTL matching its polarity.
+func (c *permissionCache) set(repo, username string, allowed bool) {
+	if c == nil {
+		return
+	}
+	now := time.Now()
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	ttl := c.positiveTTL
+	if !allowed {
+		ttl = c.negativeTTL
+	}
+	if ttl <= 0 {
+		return
+	}
+
+	// Remove expired entries
+	for key, entry := range c.entries {
+		if now.After(entry.expiry) {
+			delete(c.entries, key)
+		}
+	}
+	c.entries[permissionKey(repo, username)] = permissionEntry{allowed: allowed, expiry: now.Add(ttl)}
+}
+
+// setTTL changes both lifetimes and drops decisions cached under the old ones.
+func (c *permissionCache) setTTL(positiveTTL, negativeTTL time.Duration) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	c.positiveTTL, c.negativeTTL = positiveTTL, negativeTTL
+	c.entries = make(map[string]permissionEntry)
+}
+
+// invalidate drops cached decisions for repo, or for every repo when repo is empty.
+func (c *permissionCache) invalidate(repo string) int {
+	if c == nil {
+		return 0
+	}
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	if repo == "" {
+		n := len(c.entries)
+		c.entries = make(map[string]permissionEntry)
+		return n
+	}
+	prefix := strings.ToLower(repo) + "\x00"
+	n := 0
+	for key := range c.entries {
+		if strings.HasPrefix(key, prefix) {
+			delete(c.entries, key)
+			n++
+		}
+	}
+	return n
+}
+
+// isPermissionChangeEvent reports whether the event may change who is allowed to trigger tasks.
+func isPermissionChangeEvent(eventType string) bool {
+	switch eventType {
+	case EventNameMember, EventNameMembership, EventNameTeam, EventNameTeamAdd,
+		EventNameOrganization, EventNameInstallation:
+		return true
+	}
+	return false
+}
+
+// permissionChangeScope returns the repository affected by a membership
+// event. Events without a repository (org, team, installation changes)
+// return "" so the whole cache is dropped.
+func permissionChangeScope(eventType string, payload []byte) string {
+	if eventType != EventNameMember && eventType != EventNameTeamAdd {
+		return ""
+	}
+	var body struct {
+		Repository Repository `json:"repository"`
+	}
+	if err := json.Unmarshal(payload, &body); err != nil {
+		return ""
+	}
+	return body.Repository.FullName
+}
@@ -0,0 +1,155 @@
+package webhook
+
+import (
+	"errors"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+)
+
+var errTestLookup = errors.New("lookup failed")
+
+type countingAuthProvider struct {
+	stubAuthProvider
+	ownerCalls int
+}
+
+func (c *countingAuthProvider) GetInstallationOwner(repo string) (string, error) {
+	c.ownerCalls++
+	return c.stubAuthProvider.GetInstallationOwner(repo)
+}
+
+func TestPermissionCache_PositiveAndNegative(t *testing.T) {
+	auth := &countingAuthProvider{stubAuthProvider: stubAuthProvider{owner: "installer"}}
+	h := NewHandler("secret", "/code", &mockDispatcher{}, nil, auth)
+
+	for i := 0; i < 3; i++ {
+		if !h.verifyPermission("owner/repo", "installer") {
+			t.Fatal("installer should be allowed")
+		}
+		if h.verifyPermission("owner/repo", "stranger") {
+			t.Fatal("stranger should be denied")
+		}
+	}
+	if auth.ownerCalls != 2 {
+		t.Fatalf("owner lookups = %d, want 2 (one per user)", auth.ownerCalls)
+	}
+}
+
+func TestPermissionCache_ErrorsNotCached(t *testing.T) {
+	auth := &countingAuthProvider{stubAuthProvider: stubAuthProvider{err: errTestLookup}}
+	h := NewHandler("secret", "/code", &mockDispatcher{}, nil, auth)
+
+	h.verifyPermission("owner/repo", "anyone")
+	auth.err = nil
+	auth.owner = "installer"
+	if h.verifyPermission("owner/repo", "anyone") {
+		t.Fatal("fail-open decision must not be cached")
+	}
+	if auth.ownerCalls != 2 {
+		t.Fatalf("owner lookups = %d, want 2", auth.ownerCalls)
+	}
+}
+
+func TestPermissionCache_Expiry(t *testing.T) {
+	c := newPermissionCache(time.Hour, time.Millisecond)
+	c.set("o/r", "alice", true)
+	c.set("o/r", "bob", false)
+	time.Sleep(5 * time.Millisecond)
+
+	if allowed, ok := c.get("O/R", "alice"); !ok || !allowed {
+		t.Fatal("positive entry should still be cached (repo is case-insensitive)")
+	}
+	if _, ok := c.get("o/r", "bob"); ok {
+		t.Fatal("negative entry should have expired")
+	}
+}
+
+func TestPermissionCache_DisabledAndNil(t *testing.T) {
+	c := newPermissionCache(0, time.Minute)
+	c.set("o/r", "alice", true)
+	if _, ok := c.get("o/r", "alice"); ok {
+		t.Fatal("positive caching disabled by zero TTL")
+	}
+
+	var nilCache *permissionCache
+	nilCache.set("o/r", "alice", true)
+	if _, ok := nilCache.get("o/r", "alice"); ok || nilCache.invalidate("") != 0 {
+		t.Fatal("nil cache should never hit")
+	}
+}
+
+func TestPermissionCache_Invalidate(t *testing.T) {
+	c := newPermissionCache(time.Hour, time.Hour)
+	c.set("o/one", "alice", true)
+	c.set("o/one", "bob", false)
+	c.set("o/two", "alice", true)
+
+	if n := c.invalidate("O/One"); n != 2 {
+		t.Fatalf("invalidate repo removed %d, want 2", n)
+	}
+	if _, ok := c.get("o/two", "alice"); !ok {
+		t.Fatal("other repositories should stay cached")
+	}
+	if n := c.invalidate(""); n != 1 {
+		t.Fatalf("invalidate all removed %d, want 1", n)
+	}
+}
+
+func TestHandleWebhook_MembershipEventsInvalidateCache(t *testing.T) {
+	secret := "s3cret"
+	tests := []struct {
+		event    string
+		payload  string
+		wantKept bool // whether the entry for o/two survives
+	}{
+		{EventNameMember, `{"action":"added","repository":{"full_name":"o/one"}}`, true},
+		{EventNameTeamAdd, `{"repository":{"full_name":"o/one"}}`, true},
+		{EventNameMembership, `{"action":"removed","scope":"team"}`, false},
+		{EventNameTeam, `{"action":"edited"}`, false},
+		{EventNameOrganization, `{"action":"member_removed"}`, false},
+		{EventNameInstallation, `{"action":"deleted"}`, false},
+	}
+	for _, tt := range tests {
+		t.Run(tt.event, func(t *testing.T) {
+			h := NewHandler(secret, "/code", &mockDispatcher{}, nil, nil)
+			h.permissions.set("o/one", "alice", false)
+			h.permissions.set("o/two", "alice", true)
+
+			w := httptest.NewRecorder()
+			h.Handle(w, signedDelivery(t, secret, tt.event, "", []byte(tt.payload)))
+			if w.Code != http.StatusOK || w.Body.String() != "Permission cache invalidated" {
+				t.Fatalf("response = %d %q", w.Code, w.Body.String())
+			}
+			if _, ok := h.permissions.get("o/one", "alice"); ok {
+				t.Fatal("affected repository should be invalidated")
+			}
+			if _, ok := h.permissions.get("o/two", "alice"); ok != tt.wantKept {
+				t.Fatalf("o/two cached = %t, want %t", ok, tt.wantKept)
+			}
+		})
+	}
+}
+
+func TestHandler_SetPermissionCacheTTLClearsEntries(t *testing.T) {
+	h := NewHandler("secret", "/code", &mockDispatcher{}, nil, nil)
+	h.permissions.set("o/r", "alice", true)
+	cache := h.permissions
+
+	h.SetPermissionCacheTTL(0, time.Hour)
+	if h.permissions != cache {
+		t.Fatal("reload should update the existing cache in place")
+	}
+	if _, ok := h.permissions.get("o/r", "alice"); ok {
+		t.Fatal("decisions cached under the old TTL should be dropped")
+	}
+	h.permissions.set("o/r", "alice", true)
+	h.permissions.set("o/r", "bob", false)
+	if _, ok := h.permissions.get("o/r", "alice"); ok {
+		t.Fatal("positive caching should now be disabled")
+	}
+	if _, ok := h.permissions.get("o/r", "bob"); !ok {
+		t.Fatal("negative entries should use the new TTL")
+	}
+}
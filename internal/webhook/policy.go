@@ -0,0 +1,196 @@
+package webhook
+
+import (
+	"fmt"
+	"strings"
+	"time"
+
+	"github.com/cexll/swe/internal/github"
+	"github.com/cexll/swe/internal/policy"
+)
+
+// releaseCommandName is the policy command of ReleaseCommand.
+const releaseCommandName = "release"
+
+// triageCommandName is the policy command of TriageCommand.
+const triageCommandName = "triage"
+
+// allow tests to stub organization lookups
+var (
+	userTeams     = github.ListUserTeams
+	orgMemberRole = github.GetOrgRole
+)
+
+// SetPolicy makes p decide who may trigger tasks and releases in place of the
+// built-in checks (the app installer for tasks, maintainers for releases);
+// nil restores them. Safe to call while requests are being served.
+func (h *Handler) SetPolicy(p *policy.Policy) {
+	h.settingsMu.Lock()
+	defer h.settingsMu.Unlock()
+	h.policy = p
+}
+
+func (h *Handler) currentPolicy() *policy.Policy {
+	h.settingsMu.RLock()
+	defer h.settingsMu.RUnlock()
+	return h.policy
+}
+
+// commandName is the policy command of a trigger keyword: "/code" is "code".
+func commandName(trigger string) string {
+	return strings.ToLower(strings.TrimLeft(trigger, "/@"))
+}
+
+// authorize decides whether the commenter may run command (commandName,
+// releaseCommandName or triageCommandName) with the policy, or with the built-in checks when no
+// policy is configured. The policy's input is kept on ghCtx for the push
+// rules. Releases and triage cannot be held for review, so a rule that would
+// hold them denies them.
+func (h *Handler) authorize(ghCtx *github.Context, command string) policy.Decision {
+	repo, user := ghCtx.Repository.FullName, ghCtx.TriggerUser
+	p := h.currentPolicy()
+	if p == nil {
+		if command == releaseCommandName {
+			allowed, reason := isMaintainer(ghCtx, user)
+			return policy.Decision{Allowed: allowed, Reason: reason,
+				Message: fmt.Sprintf("@%s only repository maintainers can run `%s`.", user, ReleaseCommand)}
+		}
+		allowed, reason := h.checkPermission(repo, user)
+		return policy.Decision{Allowed: allowed, Reason: reason}
+	}
+
+	in := policy.Input{
+		User:    user,
+		Repo:    repo,
+		Command: command,
+		Flags:   policy.ParseFlags(ghCtx.GetTriggerCommentBody()),
+		Event:   string(ghCtx.EventName),
+		IsPR:    ghCtx.IsPRContext(),
+		Time:    time.Now(),
+	}
+	if p.Uses("roles") {
+		in.Roles = h.githubRoles(ghCtx)
+	}
+	if p.Uses("teams") {
+		in.Teams = h.orgTeams(ghCtx)
+	}
+	if p.Uses("org_role") {
+		in.OrgRole = h.orgRole(ghCtx)
+	}
+	d := p.Evaluate(in)
+	if (command == releaseCommandName || command == triageCommandName) && (d.Effect == policy.RequireApproval || d.Effect == policy.DryRun) {
+		d.Allowed = false
+		d.Reason += fmt.Sprintf(" (/%s cannot be held for review)", command)
+	}
+	ghCtx.PreparedPolicyInput = &in
+	eventLog(ghCtx, phaseAuthorize).Info("Policy decision", "user", user, "command", command, "effect", d.Effect, "reason", d.Reason)
+	return d
+}
+
+// githubRoles returns the commenter's roles known to GitHub: "installer" for
+// the app installer and the repository role (admin, maintain, write, triage
+// or read). Lookups that fail leave the role out, so allow rules fail closed.
+func (h *Handler) githubRoles(ghCtx *github.Context) []string {
+	repo, user := ghCtx.Repository.FullName, ghCtx.TriggerUser
+	if h.appAuth == nil {
+		return nil
+	}
+	var roles []string
+	if owner, err := h.appAuth.GetInstallationOwner(repo); err != nil {
+		eventLog(ghCtx, phaseAuthorize).Warn("Policy roles: installer lookup failed", "err", err)
+	} else if strings.EqualFold(owner, user) {
+		roles = append(roles, "installer")
+	}
+	token, err := h.lookupToken(ghCtx)
+	if err != nil {
+		eventLog(ghCtx, phaseAuthorize).Warn("Policy roles: token lookup failed", "err", err)
+		return roles
+	}
+	role, err := collaboratorRole(ghCtx.Repository.Owner, ghCtx.Repository.Name, user, token)
+	if err != nil {
+		eventLog(ghCtx, phaseAuthorize).Warn("Policy roles: role lookup failed", "user", user, "err", err)
+		return roles
+	}
+	if role != "" && role != "none" {
+		roles = append(roles, role)
+	}
+	return roles
+}
+
+// orgTeams returns the slugs of the teams of the repository owner's
+// organization the commenter is in, cached per organization and user.
+// A failed lookup returns none, so allow rules fail closed.
+func (h *Handler) orgTeams(ghCtx *github.Context) []string {
+	org, user := ghCtx.Repository.Owner, ghCtx.TriggerUser
+	if e, ok := h.orgs.get("teams", org, user); ok {
+		return e.teams
+	}
+	token, err := h.lookupToken(ghCtx)
+	if err != nil {
+		eventLog(ghCtx, phaseAuthorize).Warn("Policy teams: token lookup failed", "err", err)
+		return nil
+	}
+	teams, err := userTeams(org, user, token)
+	if err != nil {
+		eventLog(ghCtx, phaseAuthorize).Warn("Policy teams: team lookup failed", "user", user, "org", org, "err", err)
+		return nil
+	}
+	h.orgs.set("teams", org, user, orgEntry{teams: teams})
+	return teams
+}
+
+// orgRole returns the commenter's role in the repository owner's
+// organization ("admin", "member" or ""), cached per organization and
+// user. A failed lookup returns "", so allow rules fail closed.
+func (h *Handler) orgRole(ghCtx *github.Context) string {
+	org, user := ghCtx.Repository.Owner, ghCtx.TriggerUser
+	if e, ok := h.orgs.get("role", org, user); ok {
+		return e.role
+	}
+	token, err := h.lookupToken(ghCtx)
+	if err != nil {
+		eventLog(ghCtx, phaseAuthorize).Warn("Policy org role: token lookup failed", "err", err)
+		return ""
+	}
+	role, err := orgMemberRole(org, user, token)
+	if err != nil {
+		eventLog(ghCtx, phaseAuthorize).Warn("Policy org role: membership lookup failed", "user", user, "org", org, "err", err)
+		return ""
+	}
+	h.orgs.set("role", org, user, orgEntry{role: role})
+	return role
+}
+
+// lookupToken returns the token for policy lookups on ghCtx's repository:
+// the task's, or a new installation token.
+func (h *Handler) lookupToken(ghCtx *github.Context) (string, error) {
+	if ghCtx.Token != "" {
+		return ghCtx.Token, nil
+	}
+	repo := ghCtx.Repository.FullName
+	if h.appAuth == nil {
+		return "", fmt.Errorf("no GitHub App auth for %s", repo)
+	}
+	t, err := h.appAuth.GetInstallationToken(repo)
+	if err != nil || t == nil {
+		return "", fmt.Errorf("no installation token for %s: %v", repo, err)
+	}
+	return t.Token, nil
+}
+
+// replyDenied tells the commenter why a policy rule refused them, when the
+// rule has a message.
+func (h *Handler) replyDenied(ghCtx *github.Context, d policy.Decision) {
+	if d.Message == "" || h.appAuth == nil {
+		return
+	}
+	repo := ghCtx.Repository.FullName
+	token, err := h.appAuth.GetInstallationToken(repo)
+	if err != nil || token == nil {
+		eventLog(ghCtx, phaseReply).Warn("Cannot reply to denied trigger", "err", err)
+		return
+	}
+	if _, err := createComment(ghCtx.Repository.Owner, ghCtx.Repository.Name, ghCtx.IssueNumber, d.Message, token.Token); err != nil {
+		eventLog(ghCtx, phaseReply).Warn("Posting policy denial comment failed", "err", err)
+	}
+}
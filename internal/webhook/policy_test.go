@@ -0,0 +1,199 @@
+package webhook
+
+import (
+	"net/http"
+	"strings"
+	"testing"
+
+	"github.com/cexll/swe/internal/audit"
+	"github.com/cexll/swe/internal/policy"
+)
+
+func mustPolicy(t *testing.T, doc string) *policy.Policy {
+	t.Helper()
+	p, err := policy.Parse([]byte(doc))
+	if err != nil {
+		t.Fatalf("policy: %v", err)
+	}
+	return p
+}
+
+const reviewOnlyPolicy = `{
+  "roles": {"intern": ["ivy"]},
+  "rules": [
+    {"name": "interns", "effect": "deny", "when": "'intern' in roles && (command != 'review' || repo.endsWith('-prod'))",
+     "message": "Interns may only run /review on non-production repositories."},
+    {"name": "writers", "effect": "allow", "when": "command != 'release' && ('write' in roles || 'intern' in roles)"},
+    {"name": "releases", "effect": "allow", "when": "command == 'release' && 'installer' in roles"}
+  ]
+}`
+
+func TestHandle_PolicyDecides(t *testing.T) {
+	h, dispatcher, log, posted := releaseHandler(t, map[string]string{"ivy": "read", "wes": "write", "installer": "read"})
+	h.SetPolicy(mustPolicy(t, reviewOnlyPolicy))
+
+	if w := postRelease(t, h, 1, "ivy", "/code fix the build"); w.Body.String() != "Permission denied" {
+		t.Fatalf("intern /code = %q", w.Body.String())
+	}
+	if len(*posted) != 1 || (*posted)[0] != "Interns may only run /review on non-production repositories." {
+		t.Fatalf("posted = %q", *posted)
+	}
+	denied := log.List(audit.Filter{Action: audit.ActionPermission, Actor: "ivy"})
+	if len(denied) != 1 || denied[0].Detail != `policy rule "interns": deny` {
+		t.Fatalf("audit = %+v", denied)
+	}
+
+	// the installer is no longer allowed by default
+	if w := postRelease(t, h, 2, "installer", "/code fix the build"); w.Body.String() != "Permission denied" {
+		t.Fatalf("installer /code = %q", w.Body.String())
+	}
+	if w := postRelease(t, h, 3, "wes", "/code fix the build"); w.Code != http.StatusAccepted || dispatcher.enqueueCalls != 1 {
+		t.Fatalf("writer /code = %d %q", w.Code, w.Body.String())
+	}
+
+	h.SetTriggerKeyword("/review")
+	if w := postRelease(t, h, 4, "ivy", "/review please"); w.Code != http.StatusAccepted {
+		t.Fatalf("intern /review = %d %q", w.Code, w.Body.String())
+	}
+
+	// releases: the installer may now run them, a maintainer may not
+	if w := postRelease(t, h, 5, "installer", "/release patch"); w.Body.String() != "Release confirmation requested" {
+		t.Fatalf("installer /release = %q", w.Body.String())
+	}
+	if w := postRelease(t, h, 6, "wes", "/release patch"); w.Body.String() != "Permission denied" {
+		t.Fatalf("writer /release = %q", w.Body.String())
+	}
+	if last := (*posted)[len(*posted)-1]; last != "@wes you are not allowed to run `/release` here." {
+		t.Fatalf("release denial = %q", last)
+	}
+
+	h.SetPolicy(nil)
+	if w := postRelease(t, h, 7, "installer", "/review please"); w.Code != http.StatusAccepted {
+		t.Fatalf("built-in check after removing the policy = %d %q", w.Code, w.Body.String())
+	}
+}
+
+const holdPolicy = `{
+  "default": "allow",
+  "roles": {"intern": ["ivy"]},
+  "rules": [
+    {"name": "interns-dry-run", "effect": "dry_run", "when": "'intern' in roles"},
+    {"name": "readers-plan", "effect": "require_approval", "when": "'read' in roles"},
+    {"name": "big", "stage": "push", "effect": "dry_run", "when": "changed_lines > 100"}
+  ]
+}`
+
+func TestHandle_PolicyHolds(t *testing.T) {
+	h, dispatcher, _, posted := releaseHandler(t, map[string]string{"ivy": "write", "bob": "write", "eve": "read", "wes": "write"})
+	h.SetReleaseMode(false)
+	h.SetPolicy(mustPolicy(t, holdPolicy))
+
+	// a dry_run rule downgrades the task; only someone else may apply it
+	if w := postRelease(t, h, 1, "ivy", "/code fix the build"); w.Code != http.StatusAccepted {
+		t.Fatalf("intern /code = %d %q", w.Code, w.Body.String())
+	}
+	dry := dispatcher.lastTask
+	if dry.ApplyCommand != "/code apply" || dry.HoldCommand != "/code apply" || dry.PolicyInput == nil || dry.PolicyInput.User != "ivy" {
+		t.Fatalf("intern task = %+v", dry)
+	}
+	if w := postRelease(t, h, 2, "ivy", "/code apply"); w.Body.String() != "Permission denied" || dispatcher.enqueueCalls != 1 {
+		t.Fatalf("requester apply = %q", w.Body.String())
+	}
+	if w := postRelease(t, h, 3, "bob", "/code apply"); w.Code != http.StatusAccepted || dispatcher.lastTask.Applies != dry.ID {
+		t.Fatalf("apply by another writer = %d %q", w.Code, w.Body.String())
+	}
+
+	// a require_approval rule makes the task plan-only without approval mode
+	if w := postRelease(t, h, 4, "eve", "/code fix the build"); w.Code != http.StatusAccepted || dispatcher.lastTask.ApprovalCommand != "/code approve" {
+		t.Fatalf("reader /code = %d %+v", w.Code, dispatcher.lastTask)
+	}
+	if w := postRelease(t, h, 5, "bob", "/code approve"); w.Body.String() != "Plan not ready" {
+		t.Fatalf("approve = %q", w.Body.String())
+	}
+
+	// pushes a push rule held wait for apply the same way
+	if w := postRelease(t, h, 6, "bob", "/code refactor everything"); w.Code != http.StatusAccepted {
+		t.Fatalf("writer /code = %d %q", w.Code, w.Body.String())
+	}
+	big := dispatcher.lastTask
+	if big.ApplyCommand != "" || big.ApprovalCommand != "" {
+		t.Fatalf("writer task should not be held yet: %+v", big)
+	}
+	h.HoldPushes(big)
+	if w := postRelease(t, h, 7, "bob", "/code apply"); w.Body.String() != "Permission denied" {
+		t.Fatalf("requester apply of held pushes = %q", w.Body.String())
+	}
+	if last := (*posted)[len(*posted)-1]; !strings.Contains(last, "someone other than its requester") {
+		t.Fatalf("reply = %q", last)
+	}
+	if w := postRelease(t, h, 8, "wes", "/code apply"); w.Code != http.StatusAccepted || dispatcher.lastTask.Applies != big.ID {
+		t.Fatalf("apply of held pushes = %d %q", w.Code, w.Body.String())
+	}
+}
+
+func TestSimulate_Policy(t *testing.T) {
+	h := NewHandler("secret", "/code", &mockDispatcher{}, nil, nil)
+	h.SetPolicy(mustPolicy(t, `{"rules": [{"name": "no-force", "effect": "deny", "when": "'force' in flags"}], "default": "allow"}`))
+
+	res, _ := simulateRequest(t, h, `{"repo":"owner/repo","user":"u","body":"/code --force push it"}`)
+	if res.WouldEnqueue || res.PolicyRule != "no-force" || lastStep(res).Check != "permission" {
+		t.Fatalf("unexpected simulation: %+v", res)
+	}
+	res, _ = simulateRequest(t, h, `{"repo":"owner/repo","user":"u","body":"/code push it"}`)
+	if !res.WouldEnqueue || res.PermissionReason != "policy default: allow" {
+		t.Fatalf("unexpected simulation: %+v", res)
+	}
+}
+
+func TestHandle_PolicyTeamsAndOrgRoles(t *testing.T) {
+	h, dispatcher, _, _ := releaseHandler(t, nil)
+	origTeams, origRole := userTeams, orgMemberRole
+	t.Cleanup(func() { userTeams, orgMemberRole = origTeams, origRole })
+	lookups := 0
+	userTeams = func(org, user, _ string) ([]string, error) {
+		lookups++
+		if org != "owner" {
+			t.Errorf("teams of %s looked up in %s", user, org)
+		}
+		return map[string][]string{"mia": {"maintainers"}, "rex": {"docs"}}[user], nil
+	}
+	orgMemberRole = func(_, user, _ string) (string, error) {
+		lookups++
+		return map[string]string{"mia": "member", "rex": "member", "ada": "admin"}[user], nil
+	}
+	h.SetPolicy(mustPolicy(t, `{"rules": [
+    {"name": "admins", "effect": "allow", "when": "org_role == 'admin'"},
+    {"name": "maintainers-code", "effect": "allow", "when": "command == 'code' && 'maintainers' in teams"},
+    {"name": "members-review", "effect": "allow", "when": "command == 'review' && org_role != ''"}
+  ]}`))
+
+	if w := postRelease(t, h, 1, "mia", "/code fix it"); w.Code != http.StatusAccepted {
+		t.Fatalf("maintainer /code = %d %q", w.Code, w.Body.String())
+	}
+	if w := postRelease(t, h, 2, "rex", "/code fix it"); w.Body.String() != "Permission denied" {
+		t.Fatalf("member /code = %q", w.Body.String())
+	}
+	if w := postRelease(t, h, 3, "ada", "/code fix it"); w.Code != http.StatusAccepted {
+		t.Fatalf("org admin /code = %d %q", w.Code, w.Body.String())
+	}
+	h.SetTriggerKeyword("/review")
+	if w := postRelease(t, h, 4, "rex", "/review please"); w.Code != http.StatusAccepted || dispatcher.enqueueCalls != 3 {
+		t.Fatalf("member /review = %d %q", w.Code, w.Body.String())
+	}
+
+	// both lookups are cached per user until membership changes
+	if lookups != 6 {
+		t.Fatalf("lookups = %d, want 6", lookups)
+	}
+	postRelease(t, h, 5, "rex", "/review again")
+	if lookups != 6 {
+		t.Fatalf("cached lookups = %d, want 6", lookups)
+	}
+	if n := h.orgs.invalidate(); n != 6 {
+		t.Fatalf("invalidated %d entries, want 6", n)
+	}
+	postRelease(t, h, 6, "rex", "/review once more")
+	if lookups != 8 {
+		t.Fatalf("lookups after invalidation = %d, want 8", lookups)
+	}
+}
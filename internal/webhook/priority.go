@@ -0,0 +1,83 @@
+package webhook
+
+import (
+	"fmt"
+	"log/slog"
+	"regexp"
+	"strings"
+
+	"github.com/cexll/swe/internal/github"
+	"github.com/cexll/swe/internal/logging"
+)
+
+// Priority orders the tasks waiting for a worker: a higher priority starts
+// first, and tasks of the same priority start in the order they came.
+type Priority int
+
+const (
+	PriorityLow Priority = iota - 1
+	PriorityNormal
+	PriorityHigh
+)
+
+// ParsePriority parses "high", "normal" or "low" (any case; empty is
+// normal).
+func ParsePriority(s string) (Priority, error) {
+	switch strings.ToLower(strings.TrimSpace(s)) {
+	case "high":
+		return PriorityHigh, nil
+	case "", "normal":
+		return PriorityNormal, nil
+	case "low":
+		return PriorityLow, nil
+	}
+	return PriorityNormal, fmt.Errorf("unknown priority %q (want high, normal or low)", s)
+}
+
+func (p Priority) String() string {
+	switch {
+	case p > PriorityNormal:
+		return "high"
+	case p < PriorityNormal:
+		return "low"
+	}
+	return "normal"
+}
+
+// priorityFlagPattern matches `--priority high` (or --priority=low) in a
+// command.
+var priorityFlagPattern = regexp.MustCompile(`(?i)(?:^|\s)--priority[=\s]+(\S+)`)
+
+// parsePriorityFlag returns the priority a command asks for with
+// --priority; ok is false when the flag is absent or invalid.
+func parsePriorityFlag(body string) (p Priority, ok bool) {
+	m := priorityFlagPattern.FindStringSubmatch(body)
+	if m == nil {
+		return PriorityNormal, false
+	}
+	p, err := ParsePriority(m[1])
+	if err != nil {
+		slog.Warn("Ignoring invalid --priority", logging.KeyPhase, phaseWebhook, "value", m[1])
+		return PriorityNormal, false
+	}
+	return p, true
+}
+
+// taskPriority is the priority of the task ghCtx asks for: the command's
+// --priority, else the repository's setting, else by event, replies to
+// pull request reviews coming before other comments.
+func (h *Handler) taskPriority(ghCtx *github.Context) Priority {
+	if p, ok := parsePriorityFlag(ghCtx.GetTriggerCommentBody()); ok {
+		return p
+	}
+	if setting := h.repoSettings.For(ghCtx.Repository.FullName).Priority; setting != "" {
+		if p, err := ParsePriority(setting); err == nil {
+			return p
+		}
+	}
+	switch ghCtx.EventName {
+	case github.EventPullRequestReview, github.EventPullRequestReviewComment:
+		return PriorityHigh
+	}
+	return PriorityNormal
+}
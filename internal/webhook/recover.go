@@ -0,0 +1,30 @@
+package webhook
+
+import (
+	"github.com/cexll/swe/internal/audit"
+)
+
+// Requeue queues again a task accepted before a restart that had not
+// finished, as recorded in the dispatcher journal. The task keeps its ID and
+// tracking comment and starts over from its first attempt.
+func (h *Handler) Requeue(task *Task) error {
+	h.createStoreTask(task)
+	if h.store != nil {
+		h.store.AddLog(task.ID, "info", "Requeued after restart")
+	}
+	if err := h.dispatcher.Enqueue(task); err != nil {
+		return err
+	}
+	taskLog(task, "recover").Info("Requeued task after restart", "number", task.Number)
+	h.recordAudit(audit.Event{
+		Action:            audit.ActionTaskQueued,
+		Actor:             task.Username,
+		Repo:              task.Repo,
+		Number:            task.Number,
+		TaskID:            task.ID,
+		Branch:            task.Branch,
+		TrackingCommentID: task.CommentID,
+		Detail:            "requeued after restart",
+	})
+	return nil
+}
@@ -0,0 +1,28 @@
+package webhook
+
+import (
+	"testing"
+
+	"github.com/cexll/swe/internal/taskstore"
+)
+
+func TestRequeue(t *testing.T) {
+	dispatcher := &mockDispatcher{}
+	store := taskstore.NewStore()
+	handler := NewHandler("secret", "/code", dispatcher, store, nil)
+
+	task := &Task{ID: "owner-repo-1-1", Repo: "owner/repo", Number: 1, IssueTitle: "Fix it", Username: "alice", CommentID: 42}
+	if err := handler.Requeue(task); err != nil {
+		t.Fatalf("Requeue: %v", err)
+	}
+	if dispatcher.lastTask != task {
+		t.Fatalf("task not enqueued")
+	}
+	stored, ok := store.Get(task.ID)
+	if !ok || stored.Status != taskstore.StatusPending || stored.Title != "Fix it" {
+		t.Fatalf("stored task = %+v", stored)
+	}
+	if last := stored.Logs[len(stored.Logs)-1].Message; last != "Requeued after restart" {
+		t.Fatalf("last log = %q", last)
+	}
+}
@@ -0,0 +1,195 @@
+package webhook
+
+import (
+	"context"
+	"fmt"
+	"net/http"
+	"regexp"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/cexll/swe/internal/audit"
+	"github.com/cexll/swe/internal/github"
+	"github.com/cexll/swe/internal/modes"
+)
+
+// ReleaseCommand starts release mode: "/release patch|minor|major" asks for a
+// release and "/release confirm" (or "/release cancel") answers it.
+const ReleaseCommand = "/release"
+
+// releaseConfirmTTL bounds how long a requested release waits for confirmation.
+const releaseConfirmTTL = 15 * time.Minute
+
+var releaseCommandPattern = regexp.MustCompile(`(?i)(?:^|\s)/release\s+(patch|minor|major|confirm|cancel)\b`)
+
+// allow tests to stub collaborator role lookups
+var collaboratorRole = github.GetCollaboratorRole
+
+// parseReleaseCommand returns the release subcommand in body, if any.
+func parseReleaseCommand(body string) (string, bool) {
+	m := releaseCommandPattern.FindStringSubmatch(body)
+	if m == nil {
+		return "", false
+	}
+	return strings.ToLower(m[1]), true
+}
+
+type pendingRelease struct {
+	bump    string
+	user    string
+	expires time.Time
+}
+
+// releaseRequests holds releases awaiting confirmation, keyed by repo#number.
+type releaseRequests struct {
+	mu      sync.Mutex
+	pending map[string]pendingRelease
+}
+
+func (r *releaseRequests) put(key string, p pendingRelease) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	if r.pending == nil {
+		r.pending = make(map[string]pendingRelease)
+	}
+	r.pending[key] = p
+}
+
+// take removes and returns the unexpired request for key.
+func (r *releaseRequests) take(key string, now time.Time) (pendingRelease, bool) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	p, ok := r.pending[key]
+	delete(r.pending, key)
+	if !ok || now.After(p.expires) {
+		return pendingRelease{}, false
+	}
+	return p, true
+}
+
+// SetReleaseMode enables the /release command; safe to call while requests
+// are being served.
+func (h *Handler) SetReleaseMode(enabled bool) {
+	h.settingsMu.Lock()
+	defer h.settingsMu.Unlock()
+	h.releaseMode = enabled
+}
+
+func (h *Handler) releaseEnabled() bool {
+	h.settingsMu.RLock()
+	defer h.settingsMu.RUnlock()
+	return h.releaseMode
+}
+
+// isMaintainer reports whether user may cut releases (admin or maintain role).
+// Unlike task triggers, release permission fails closed.
+func isMaintainer(ghCtx *github.Context, user string) (bool, string) {
+	if ghCtx.Token == "" {
+		return false, "release: no installation token to verify the maintainer role"
+	}
+	role, err := collaboratorRole(ghCtx.Repository.Owner, ghCtx.Repository.Name, user, ghCtx.Token)
+	if err != nil {
+		return false, fmt.Sprintf("release: role lookup failed: %v", err)
+	}
+	if role == "admin" || role == "maintain" {
+		return true, "release: role " + role
+	}
+	return false, "release: role " + role
+}
+
+// handleRelease runs the /release conversation: a maintainer requests a bump,
+// then a maintainer confirms it within releaseConfirmTTL, which queues a
+// release task.
+func (h *Handler) handleRelease(ctx context.Context, w http.ResponseWriter, ghCtx *github.Context, cmd string, eventType string, payload []byte) {
+	repo := ghCtx.Repository.FullName
+	if enabled, reason := h.checkRepo(repo); !enabled {
+		h.rejectRepo(ghCtx, reason)
+		w.WriteHeader(http.StatusOK)
+		_, _ = w.Write([]byte("Repository not enabled"))
+		return
+	}
+	if !h.getDeduper(eventType).markIfNew(ghCtx.TriggerComment.ID) {
+		w.WriteHeader(http.StatusOK)
+		_, _ = w.Write([]byte("Duplicate comment ignored"))
+		return
+	}
+
+	if h.appAuth != nil {
+		if token, err := h.appAuth.GetInstallationToken(repo); err != nil {
+			eventLog(ghCtx, phaseReply).WarnContext(ctx, "Getting installation token for release failed", "err", err)
+		} else if token != nil {
+			ghCtx.Token = token.Token
+		}
+	}
+
+	decision := h.authorize(ghCtx, releaseCommandName)
+	h.recordPermission(ghCtx, decision.Allowed, decision.Reason)
+	if !decision.Allowed {
+		eventLog(ghCtx, phaseAuthorize).InfoContext(ctx, "Release permission denied", "user", ghCtx.TriggerUser, "reason", decision.Reason)
+		msg := decision.Message
+		if msg == "" {
+			msg = fmt.Sprintf("@%s you are not allowed to run `%s` here.", ghCtx.TriggerUser, ReleaseCommand)
+		}
+		h.replyRelease(ghCtx, msg)
+		w.WriteHeader(http.StatusOK)
+		_, _ = w.Write([]byte("Permission denied"))
+		return
+	}
+
+	key := fmt.Sprintf("%s#%d", strings.ToLower(repo), ghCtx.IssueNumber)
+	switch cmd {
+	case "cancel":
+		if _, ok := h.releases.take(key, time.Now()); ok {
+			h.replyRelease(ghCtx, "Release cancelled.")
+		}
+		w.WriteHeader(http.StatusOK)
+		_, _ = w.Write([]byte("Release cancelled"))
+
+	case "confirm":
+		pending, ok := h.releases.take(key, time.Now())
+		if !ok {
+			h.replyRelease(ghCtx, fmt.Sprintf("There is no pending release to confirm. Start one with `%s patch`, `%s minor` or `%s major`.", ReleaseCommand, ReleaseCommand, ReleaseCommand))
+			w.WriteHeader(http.StatusOK)
+			_, _ = w.Write([]byte("No pending release"))
+			return
+		}
+		ghCtx.PreparedRelease = pending.bump
+		t, err := h.prepareModeTask(ctx, modes.GetReleaseMode(), ghCtx, payload)
+		if err != nil {
+			eventLog(ghCtx, phaseEnqueue).ErrorContext(ctx, "Preparing release task failed", "err", err)
+			http.Error(w, "Task preparation failed", http.StatusInternalServerError)
+			return
+		}
+		t.Release = pending.bump
+		t.PromptSummary = fmt.Sprintf("**Release:** %s bump of `%s`, requested by @%s, confirmed by @%s", pending.bump, t.BaseBranch, pending.user, ghCtx.TriggerUser)
+		eventLog(ghCtx, phaseEnqueue).InfoContext(ctx, "Release confirmed", "bump", pending.bump, "user", ghCtx.TriggerUser)
+		h.enqueueTask(w, t)
+
+	default:
+		h.releases.put(key, pendingRelease{bump: cmd, user: ghCtx.TriggerUser, expires: time.Now().Add(releaseConfirmTTL)})
+		h.recordAudit(audit.Event{
+			Action:           audit.ActionReleaseRequested,
+			Actor:            ghCtx.TriggerUser,
+			Repo:             repo,
+			Number:           ghCtx.IssueNumber,
+			TriggerCommentID: ghCtx.TriggerComment.ID,
+			Detail:           cmd,
+		})
+		h.replyRelease(ghCtx, fmt.Sprintf("A **%s** release of `%s` was requested by @%s. This bumps the version, updates the changelog, "+
+			"tags the commit and pushes it.\n\nA maintainer must reply `%s confirm` within %d minutes to proceed, or `%s cancel` to abort.",
+			cmd, ghCtx.GetRepositoryDefaultBranch(), ghCtx.TriggerUser, ReleaseCommand, int(releaseConfirmTTL/time.Minute), ReleaseCommand))
+		w.WriteHeader(http.StatusOK)
+		_, _ = w.Write([]byte("Release confirmation requested"))
+	}
+}
+
+// replyRelease posts a comment on the issue or PR the release command came from.
+func (h *Handler) replyRelease(ghCtx *github.Context, body string) {
+	if ghCtx.Token == "" {
+		return
+	}
+	if _, err := createComment(ghCtx.Repository.Owner, ghCtx.Repository.Name, ghCtx.IssueNumber, body, ghCtx.Token); err != nil {
+		eventLog(ghCtx, phaseReply).Warn("Posting release comment failed", "err", err)
+	}
+}
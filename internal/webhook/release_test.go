@@ -0,0 +1,147 @@
+package webhook
+
+import (
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/cexll/swe/internal/audit"
+	_ "github.com/cexll/swe/internal/modes/release" // Register ReleaseMode
+)
+
+func TestParseReleaseCommand(t *testing.T) {
+	tests := map[string]string{
+		"/release patch":           "patch",
+		"please /Release MINOR":    "minor",
+		"/release confirm":         "confirm",
+		"/release cancel now":      "cancel",
+		"/release":                 "",
+		"/release huge":            "",
+		"see docs/release patch":   "",
+		"/code prepare /release x": "",
+	}
+	for body, want := range tests {
+		got, ok := parseReleaseCommand(body)
+		if got != want || ok != (want != "") {
+			t.Errorf("parseReleaseCommand(%q) = %q, %t; want %q", body, got, ok, want)
+		}
+	}
+}
+
+func TestReleaseRequests_Expire(t *testing.T) {
+	var r releaseRequests
+	now := time.Now()
+	r.put("o/r#1", pendingRelease{bump: "patch", expires: now.Add(time.Minute)})
+	if _, ok := r.take("o/r#1", now.Add(2*time.Minute)); ok {
+		t.Fatal("expired request should not be confirmed")
+	}
+	r.put("o/r#1", pendingRelease{bump: "patch", expires: now.Add(time.Minute)})
+	if p, ok := r.take("o/r#1", now); !ok || p.bump != "patch" {
+		t.Fatalf("take = %+v, %t", p, ok)
+	}
+	if _, ok := r.take("o/r#1", now); ok {
+		t.Fatal("a request can only be confirmed once")
+	}
+}
+
+// releaseHandler returns a handler with release mode on, where roles maps
+// users to their repository role, and the comments it posts.
+func releaseHandler(t *testing.T, roles map[string]string) (*Handler, *mockDispatcher, *audit.Log, *[]string) {
+	t.Helper()
+	origComment, origRole := createComment, collaboratorRole
+	t.Cleanup(func() { createComment, collaboratorRole = origComment, origRole })
+	var posted []string
+	createComment = func(_, _ string, _ int, body, _ string) (int64, error) {
+		posted = append(posted, body)
+		return 1, nil
+	}
+	collaboratorRole = func(_, _, user, _ string) (string, error) { return roles[user], nil }
+
+	dispatcher := &mockDispatcher{}
+	h := NewHandler("s3cret", "/code", dispatcher, nil, &stubAuthProvider{owner: "installer"})
+	log, _ := audit.New(audit.Config{})
+	h.SetAuditLog(log)
+	h.SetReleaseMode(true)
+	return h, dispatcher, log, &posted
+}
+
+func postRelease(t *testing.T, h *Handler, commentID int64, user, body string) *httptest.ResponseRecorder {
+	t.Helper()
+	payload, _ := json.Marshal(&IssueCommentEvent{
+		Action:     "created",
+		Issue:      Issue{Number: 9, Title: "Release train"},
+		Comment:    Comment{ID: commentID, Body: body, User: User{Login: user, Type: "User"}},
+		Repository: Repository{FullName: "owner/repo", DefaultBranch: "main"},
+		Sender:     User{Login: user},
+	})
+	w := httptest.NewRecorder()
+	h.Handle(w, signedDelivery(t, "s3cret", "issue_comment", "", payload))
+	return w
+}
+
+func TestHandleRelease_RequestThenConfirm(t *testing.T) {
+	h, dispatcher, log, posted := releaseHandler(t, map[string]string{"alice": "maintain", "bob": "admin", "eve": "write"})
+
+	if w := postRelease(t, h, 1, "eve", "/release patch"); w.Body.String() != "Permission denied" {
+		t.Fatalf("writer response = %q", w.Body.String())
+	}
+	if w := postRelease(t, h, 2, "alice", "/release confirm"); w.Body.String() != "No pending release" {
+		t.Fatalf("confirm without request = %q", w.Body.String())
+	}
+	if w := postRelease(t, h, 3, "alice", "/release minor"); w.Body.String() != "Release confirmation requested" || dispatcher.enqueueCalls != 0 {
+		t.Fatalf("request response = %q, enqueued %d", w.Body.String(), dispatcher.enqueueCalls)
+	}
+	if last := (*posted)[len(*posted)-1]; !strings.Contains(last, "**minor** release of `main`") || !strings.Contains(last, "`/release confirm` within 15 minutes") {
+		t.Fatalf("confirmation prompt = %q", last)
+	}
+
+	w := postRelease(t, h, 4, "bob", "/release confirm")
+	if w.Code != http.StatusAccepted || dispatcher.enqueueCalls != 1 {
+		t.Fatalf("confirm response = %d %q, enqueued %d", w.Code, w.Body.String(), dispatcher.enqueueCalls)
+	}
+	task := dispatcher.lastTask
+	if task.Mode != "release" || task.Release != "minor" || task.BaseBranch != "main" || task.CommentID != 123456 ||
+		!strings.Contains(task.PromptSummary, "requested by @alice, confirmed by @bob") {
+		t.Fatalf("unexpected task: %+v", task)
+	}
+
+	if w := postRelease(t, h, 5, "bob", "/release confirm"); w.Body.String() != "No pending release" || dispatcher.enqueueCalls != 1 {
+		t.Fatalf("second confirm = %q, enqueued %d", w.Body.String(), dispatcher.enqueueCalls)
+	}
+
+	if events := log.List(audit.Filter{Action: audit.ActionReleaseRequested}); len(events) != 1 || events[0].Detail != "minor" || events[0].Actor != "alice" {
+		t.Fatalf("release audit = %+v", events)
+	}
+	denied := log.List(audit.Filter{Action: audit.ActionPermission, Actor: "eve"})
+	if len(denied) != 1 || denied[0].Decision != audit.DecisionDenied || denied[0].Detail != "release: role write" {
+		t.Fatalf("permission audit = %+v", denied)
+	}
+}
+
+func TestHandleRelease_CancelAndDisabled(t *testing.T) {
+	h, dispatcher, _, _ := releaseHandler(t, map[string]string{"alice": "admin"})
+	postRelease(t, h, 1, "alice", "/release major")
+	if w := postRelease(t, h, 2, "alice", "/release cancel"); w.Body.String() != "Release cancelled" {
+		t.Fatalf("cancel = %q", w.Body.String())
+	}
+	if w := postRelease(t, h, 3, "alice", "/release confirm"); w.Body.String() != "No pending release" {
+		t.Fatalf("confirm after cancel = %q", w.Body.String())
+	}
+
+	h.SetReleaseMode(false)
+	if w := postRelease(t, h, 4, "alice", "/release patch"); w.Body.String() != "No trigger keyword found" || dispatcher.enqueueCalls != 0 {
+		t.Fatalf("disabled release mode = %q", w.Body.String())
+	}
+}
+
+func TestSimulate_ReleaseCommand(t *testing.T) {
+	h := NewHandler("secret", "/code", &mockDispatcher{}, nil, nil)
+	h.SetReleaseMode(true)
+	res, _ := simulateRequest(t, h, `{"repo":"owner/repo","user":"u","body":"/release patch"}`)
+	if res.WouldEnqueue || res.Mode != "release" || lastStep(res).Check != "release" {
+		t.Fatalf("unexpected simulation: %+v", res)
+	}
+}
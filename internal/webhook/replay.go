@@ -0,0 +1,86 @@
+package webhook
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"io"
+	"log/slog"
+	"net/http"
+	"strings"
+
+	"github.com/cexll/swe/internal/logging"
+	"github.com/cexll/swe/internal/taskstore"
+	"github.com/gorilla/mux"
+)
+
+// ReplayRequest is the body accepted by POST /api/v1/tasks/{id}/replay;
+// every field is optional.
+type ReplayRequest struct {
+	Prompt string `json:"prompt,omitempty"` // replaces the task's instruction; empty repeats it
+	Actor  string `json:"actor,omitempty"`  // operator recorded in the task and audit log
+}
+
+type replayOfKey struct{}
+
+// replayOfFrom returns the task a task prepared with ctx replays.
+func replayOfFrom(ctx context.Context) string {
+	id, _ := ctx.Value(replayOfKey{}).(string)
+	return id
+}
+
+// ReplayTask queues a finished task again on the same issue or pull request
+// and base branch, with the operator's edited instruction or the original
+// one, so a failed or poor run can be iterated on without a new comment.
+func (h *Handler) ReplayTask(w http.ResponseWriter, r *http.Request) {
+	if h.apiToken == "" {
+		http.Error(w, "manual task API disabled (API_TOKEN not set)", http.StatusServiceUnavailable)
+		return
+	}
+	if !h.authorizedOperator(r) {
+		w.Header().Set("WWW-Authenticate", `Bearer realm="swe-agent"`)
+		http.Error(w, "unauthorized", http.StatusUnauthorized)
+		return
+	}
+	if h.store == nil {
+		http.Error(w, "task store unavailable", http.StatusServiceUnavailable)
+		return
+	}
+	orig, ok := h.store.Get(mux.Vars(r)["id"])
+	if !ok {
+		http.NotFound(w, r)
+		return
+	}
+	if orig.Status == taskstore.StatusPending || orig.Status == taskstore.StatusRunning {
+		http.Error(w, "task has not finished yet", http.StatusConflict)
+		return
+	}
+	if orig.Instruction == "" {
+		http.Error(w, "task cannot be replayed: it has no recorded instruction", http.StatusConflict)
+		return
+	}
+
+	var body ReplayRequest
+	if err := json.NewDecoder(http.MaxBytesReader(w, r.Body, 1<<20)).Decode(&body); err != nil && !errors.Is(err, io.EOF) {
+		http.Error(w, "invalid JSON body", http.StatusBadRequest)
+		return
+	}
+	req := ManualTaskRequest{
+		Repo:       orig.RepoOwner + "/" + orig.RepoName,
+		Number:     orig.IssueNumber,
+		IsPR:       orig.IsPR,
+		Prompt:     orig.Instruction,
+		BaseBranch: orig.BaseBranch,
+		Actor:      body.Actor,
+		replayOf:   orig.ID,
+	}
+	if p := strings.TrimSpace(body.Prompt); p != "" {
+		req.Prompt = p
+	}
+	if err := req.validate(); err != nil {
+		http.Error(w, err.Error(), http.StatusBadRequest)
+		return
+	}
+	slog.InfoContext(r.Context(), "Replaying task", logging.KeyTaskID, orig.ID, logging.KeyRepo, req.Repo, logging.KeyPhase, phaseEnqueue, "number", req.Number, "prompt_edited", req.Prompt != orig.Instruction)
+	h.submitManual(r.Context(), w, req)
+}
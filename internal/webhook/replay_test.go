@@ -0,0 +1,68 @@
+package webhook
+
+import (
+	"bytes"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"github.com/cexll/swe/internal/taskstore"
+	"github.com/gorilla/mux"
+)
+
+func replayRequest(id, body string) *http.Request {
+	req := httptest.NewRequest(http.MethodPost, "/api/v1/tasks/"+id+"/replay", bytes.NewBufferString(body))
+	req.Header.Set("Authorization", "Bearer op-token")
+	return mux.SetURLVars(req, map[string]string{"id": id})
+}
+
+func TestReplayTask(t *testing.T) {
+	dispatcher := &mockDispatcher{}
+	store := taskstore.NewStore()
+	handler := NewHandler("secret", "/code", dispatcher, store, nil)
+	handler.SetAPIToken("op-token")
+
+	w := httptest.NewRecorder()
+	handler.SubmitTask(w, manualRequest("op-token", `{"repo":"owner/repo","number":77,"is_pr":true,"prompt":"fix the flaky test","base_branch":"develop"}`))
+	if w.Code != http.StatusAccepted {
+		t.Fatalf("status = %d: %s", w.Code, w.Body.String())
+	}
+	orig := dispatcher.lastTask
+	if stored, _ := store.Get(orig.ID); stored.Instruction != "fix the flaky test" || !stored.IsPR || stored.BaseBranch != "develop" {
+		t.Fatalf("stored task = %+v", stored)
+	}
+
+	w = httptest.NewRecorder()
+	handler.ReplayTask(w, replayRequest("missing", ""))
+	if w.Code != http.StatusNotFound {
+		t.Fatalf("unknown task: status = %d", w.Code)
+	}
+	w = httptest.NewRecorder()
+	handler.ReplayTask(w, replayRequest(orig.ID, ""))
+	if w.Code != http.StatusConflict {
+		t.Fatalf("unfinished task: status = %d", w.Code)
+	}
+
+	store.UpdateStatus(orig.ID, taskstore.StatusFailed)
+	w = httptest.NewRecorder()
+	handler.ReplayTask(w, replayRequest(orig.ID, `{"prompt":"fix the flaky test without sleeps","actor":"oncall"}`))
+	if w.Code != http.StatusAccepted {
+		t.Fatalf("status = %d: %s", w.Code, w.Body.String())
+	}
+	replay := dispatcher.lastTask
+	if replay.ID == orig.ID || replay.Repo != "owner/repo" || replay.Number != 77 || !replay.IsPR || replay.BaseBranch != "develop" ||
+		replay.Username != "oncall" || replay.Instruction != "fix the flaky test without sleeps" || replay.ReplayOf != orig.ID {
+		t.Fatalf("replay task = %+v", replay)
+	}
+	if stored, _ := store.Get(replay.ID); stored.ReplayOf != orig.ID {
+		t.Fatalf("stored replay = %+v", stored)
+	}
+
+	// without a prompt the original instruction runs again
+	store.UpdateStatus(replay.ID, taskstore.StatusCompleted)
+	w = httptest.NewRecorder()
+	handler.ReplayTask(w, replayRequest(replay.ID, ""))
+	if w.Code != http.StatusAccepted || dispatcher.lastTask.Instruction != "fix the flaky test without sleeps" {
+		t.Fatalf("status = %d, instruction %q", w.Code, dispatcher.lastTask.Instruction)
+	}
+}
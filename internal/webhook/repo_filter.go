@@ -0,0 +1,143 @@
+package webhook
+
+import (
+	"fmt"
+	"path"
+	"strings"
+
+	"github.com/cexll/swe/internal/audit"
+	"github.com/cexll/swe/internal/github"
+)
+
+// RepoNotEnabledMessage is posted when the agent is triggered in a repository
+// outside the allowlist (or on the denylist).
+const RepoNotEnabledMessage = "This repository is not enabled for SWE Agent."
+
+// allow tests to stub comment creation
+var createComment = github.CreateComment
+
+// RepoFilter decides which repositories the agent acts on. Patterns are
+// case-insensitive owner/name globs ("my-org/*", "*/docs"). A repository must
+// match the allowlist (when set) and must not match the denylist. A nil
+// filter allows every repository.
+type RepoFilter struct {
+	allow []string
+	deny  []string
+}
+
+// NewRepoFilter validates the patterns and returns a filter, or nil when both
+// lists are empty.
+func NewRepoFilter(allow, deny []string) (*RepoFilter, error) {
+	f := &RepoFilter{}
+	var err error
+	if f.allow, err = repoPatterns(allow); err != nil {
+		return nil, err
+	}
+	if f.deny, err = repoPatterns(deny); err != nil {
+		return nil, err
+	}
+	if len(f.allow) == 0 && len(f.deny) == 0 {
+		return nil, nil
+	}
+	return f, nil
+}
+
+// ValidateRepoPattern reports whether pattern is a usable owner/name glob.
+func ValidateRepoPattern(pattern string) error {
+	if strings.Count(pattern, "/") != 1 {
+		return fmt.Errorf("repository pattern %q must be owner/name", pattern)
+	}
+	if _, err := path.Match(pattern, ""); err != nil {
+		return fmt.Errorf("repository pattern %q: %w", pattern, err)
+	}
+	return nil
+}
+
+func repoPatterns(patterns []string) ([]string, error) {
+	var out []string
+	for _, p := range patterns {
+		p = strings.ToLower(strings.TrimSpace(p))
+		if p == "" {
+			continue
+		}
+		if err := ValidateRepoPattern(p); err != nil {
+			return nil, err
+		}
+		out = append(out, p)
+	}
+	return out, nil
+}
+
+// Check reports whether repo is enabled and why.
+func (f *RepoFilter) Check(repo string) (bool, string) {
+	if f == nil {
+		return true, "no repository allowlist configured"
+	}
+	repo = strings.ToLower(repo)
+	if p, ok := matchRepo(f.deny, repo); ok {
+		return false, fmt.Sprintf("matches denylist pattern %q", p)
+	}
+	if len(f.allow) == 0 {
+		return true, "not on the denylist"
+	}
+	if p, ok := matchRepo(f.allow, repo); ok {
+		return true, fmt.Sprintf("matches allowlist pattern %q", p)
+	}
+	return false, "not on the allowlist"
+}
+
+func matchRepo(patterns []string, repo string) (string, bool) {
+	for _, p := range patterns {
+		if ok, _ := path.Match(p, repo); ok {
+			return p, true
+		}
+	}
+	return "", false
+}
+
+// SetRepoFilter restricts the repositories the agent acts on (nil allows
+// all); safe to call while requests are being served.
+func (h *Handler) SetRepoFilter(f *RepoFilter) {
+	h.settingsMu.Lock()
+	defer h.settingsMu.Unlock()
+	h.repos = f
+}
+
+// checkRepo applies the repository filter.
+func (h *Handler) checkRepo(repo string) (bool, string) {
+	h.settingsMu.RLock()
+	f := h.repos
+	h.settingsMu.RUnlock()
+	return f.Check(repo)
+}
+
+// rejectRepo logs and audits a trigger in a repository that is not enabled
+// and tells the commenter so.
+func (h *Handler) rejectRepo(ghCtx *github.Context, reason string) {
+	repo := ghCtx.Repository.FullName
+	eventLog(ghCtx, phaseAuthorize).Info("Repository not enabled; rejecting trigger", "reason", reason, "user", ghCtx.TriggerUser)
+	ev := audit.Event{
+		Action:   audit.ActionRepoRejected,
+		Actor:    ghCtx.TriggerUser,
+		Repo:     repo,
+		Number:   ghCtx.IssueNumber,
+		Decision: audit.DecisionDenied,
+		Detail:   reason,
+	}
+	if ghCtx.TriggerComment != nil {
+		ev.TriggerCommentID = ghCtx.TriggerComment.ID
+	}
+	h.recordAudit(ev)
+
+	if h.appAuth == nil {
+		return
+	}
+	token, err := h.appAuth.GetInstallationToken(repo)
+	if err != nil || token == nil {
+		eventLog(ghCtx, phaseReply).Warn("Cannot reply to rejected trigger", "err", err)
+		return
+	}
+	if _, err := createComment(ghCtx.Repository.Owner, ghCtx.Repository.Name, ghCtx.IssueNumber, RepoNotEnabledMessage, token.Token); err != nil {
+		eventLog(ghCtx, phaseReply).Warn("Posting repository rejection comment failed", "err", err)
+	}
+}
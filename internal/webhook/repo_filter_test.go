@@ -0,0 +1,114 @@
+package webhook
+
+import (
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+
+	"github.com/cexll/swe/internal/audit"
+)
+
+func TestRepoFilter_Check(t *testing.T) {
+	f, err := NewRepoFilter([]string{"My-Org/*", " other/docs "}, []string{"my-org/secret-*"})
+	if err != nil {
+		t.Fatalf("NewRepoFilter: %v", err)
+	}
+	tests := map[string]bool{
+		"my-org/app":         true,
+		"MY-ORG/App":         true,
+		"other/docs":         true,
+		"other/app":          false,
+		"my-org/secret-keys": false,
+		"my-org":             false,
+	}
+	for repo, want := range tests {
+		if got, reason := f.Check(repo); got != want {
+			t.Errorf("Check(%q) = %t (%s), want %t", repo, got, reason, want)
+		}
+	}
+
+	denyOnly, _ := NewRepoFilter(nil, []string{"*/archive"})
+	if ok, _ := denyOnly.Check("any/repo"); !ok {
+		t.Error("deny-only filter should allow unlisted repositories")
+	}
+	if ok, _ := denyOnly.Check("team/archive"); ok {
+		t.Error("denylisted repository should be rejected")
+	}
+
+	if f, err := NewRepoFilter([]string{"", " "}, nil); f != nil || err != nil {
+		t.Fatalf("empty lists should yield nil filter, got %v, %v", f, err)
+	}
+	var nilFilter *RepoFilter
+	if ok, _ := nilFilter.Check("any/repo"); !ok {
+		t.Error("nil filter should allow everything")
+	}
+	for _, bad := range []string{"my-org", "a/b/c", "org/[x"} {
+		if _, err := NewRepoFilter([]string{bad}, nil); err == nil {
+			t.Errorf("pattern %q should be rejected", bad)
+		}
+	}
+}
+
+func TestHandleWebhook_RepoNotEnabled(t *testing.T) {
+	var posted struct {
+		owner, repo, body, token string
+		number                   int
+	}
+	orig := createComment
+	t.Cleanup(func() { createComment = orig })
+	createComment = func(owner, repo string, number int, body, token string) (int64, error) {
+		posted.owner, posted.repo, posted.number, posted.body, posted.token = owner, repo, number, body, token
+		return 1, nil
+	}
+
+	secret := "s3cret"
+	dispatcher := &mockDispatcher{}
+	h := NewHandler(secret, "/code", dispatcher, nil, &stubAuthProvider{owner: "tester"})
+	log, _ := audit.New(audit.Config{})
+	h.SetAuditLog(log)
+	filter, _ := NewRepoFilter([]string{"enabled-org/*"}, nil)
+	h.SetRepoFilter(filter)
+
+	payload, _ := json.Marshal(&IssueCommentEvent{
+		Action:     "created",
+		Issue:      Issue{Number: 5, Title: "Outside"},
+		Comment:    Comment{ID: 77, Body: "/code do it", User: User{Login: "tester", Type: "User"}},
+		Repository: Repository{FullName: "other/repo", DefaultBranch: "main"},
+		Sender:     User{Login: "tester"},
+	})
+	w := httptest.NewRecorder()
+	h.Handle(w, signedDelivery(t, secret, "issue_comment", "", payload))
+
+	if w.Code != http.StatusOK || w.Body.String() != "Repository not enabled" || dispatcher.enqueueCalls != 0 {
+		t.Fatalf("response = %d %q, enqueued %d", w.Code, w.Body.String(), dispatcher.enqueueCalls)
+	}
+	if posted.owner != "other" || posted.repo != "repo" || posted.number != 5 || posted.body != RepoNotEnabledMessage || posted.token != "stub-token" {
+		t.Fatalf("unexpected comment: %+v", posted)
+	}
+	events := log.List(audit.Filter{Action: audit.ActionRepoRejected})
+	if len(events) != 1 || events[0].Repo != "other/repo" || events[0].TriggerCommentID != 77 || events[0].Detail != "not on the allowlist" {
+		t.Fatalf("audit events = %+v", events)
+	}
+}
+
+func TestRepoFilter_SimulateAndManual(t *testing.T) {
+	h := NewHandler("secret", "/code", &mockDispatcher{}, nil, nil)
+	h.SetAPIToken("op-token")
+	filter, _ := NewRepoFilter(nil, []string{"owner/blocked"})
+	h.SetRepoFilter(filter)
+
+	res, _ := simulateRequest(t, h, `{"repo":"owner/blocked","user":"u","body":"/code hi"}`)
+	if res.WouldEnqueue || res.Response != "Repository not enabled" || lastStep(res).Check != "repository" {
+		t.Fatalf("unexpected simulation: %+v", res)
+	}
+
+	req := httptest.NewRequest(http.MethodPost, "/api/v1/tasks", strings.NewReader(`{"repo":"owner/blocked","number":1,"prompt":"hi"}`))
+	req.Header.Set("Authorization", "Bearer op-token")
+	w := httptest.NewRecorder()
+	h.SubmitTask(w, req)
+	if w.Code != http.StatusForbidden || !strings.Contains(w.Body.String(), RepoNotEnabledMessage) {
+		t.Fatalf("manual task response = %d %q", w.Code, w.Body.String())
+	}
+}
@@ -0,0 +1,31 @@
+package webhook
+
+import "strings"
+
+// addressReviewsCommand is the subcommand that makes a task address the
+// pull request's unresolved review threads: "/code address-reviews".
+const addressReviewsCommand = "address-reviews"
+
+// isAddressReviewsCommand reports whether prompt, the text after the
+// trigger, starts with addressReviewsCommand; flags such as --dry-run or
+// --timeout 45m may come first.
+func isAddressReviewsCommand(prompt string) bool {
+	fields := subcommandFields(prompt)
+	return len(fields) > 0 && strings.EqualFold(fields[0], addressReviewsCommand)
+}
+
+// subcommandFields returns the words of prompt from the first one that is
+// not a flag such as --dry-run or --timeout 45m.
+func subcommandFields(prompt string) []string {
+	fields := strings.Fields(prompt)
+	for i := 0; i < len(fields); i++ {
+		f := fields[i]
+		if !strings.HasPrefix(f, "--") {
+			return fields[i:]
+		}
+		if strings.EqualFold(f, "--timeout") {
+			i++ // its value
+		}
+	}
+	return nil
+}
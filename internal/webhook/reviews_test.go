@@ -0,0 +1,39 @@
+package webhook
+
+import (
+	"strings"
+	"testing"
+)
+
+func TestIsAddressReviewsCommand(t *testing.T) {
+	tests := map[string]bool{
+		"address-reviews":                        true,
+		"Address-Reviews and keep commits small": true,
+		"--dry-run address-reviews":              true,
+		"--timeout 45m address-reviews":          true,
+		"--timeout=45m address-reviews":          true,
+		"please address-reviews":                 false,
+		"fix the address-reviews handler":        false,
+		"":                                       false,
+	}
+	for prompt, want := range tests {
+		if got := isAddressReviewsCommand(prompt); got != want {
+			t.Errorf("isAddressReviewsCommand(%q) = %t, want %t", prompt, got, want)
+		}
+	}
+}
+
+func TestHandle_AddressReviewsNeedsPullRequest(t *testing.T) {
+	h, dispatcher, _, posted := releaseHandler(t, nil)
+	h.SetReleaseMode(false)
+
+	if w := postRelease(t, h, 1, "installer", "/code address-reviews"); w.Body.String() != "Not a pull request" || dispatcher.enqueueCalls != 0 {
+		t.Fatalf("address-reviews on an issue = %q", w.Body.String())
+	}
+	if last := (*posted)[len(*posted)-1]; !strings.Contains(last, "`/code address-reviews` works on pull requests only") {
+		t.Fatalf("reply = %q", last)
+	}
+	if w := postRelease(t, h, 2, "installer", "/code fix the parser"); w.Body.String() == "Not a pull request" || dispatcher.lastTask.AddressReviews {
+		t.Fatalf("ordinary task = %q %+v", w.Body.String(), dispatcher.lastTask)
+	}
+}
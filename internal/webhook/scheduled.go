@@ -0,0 +1,97 @@
+package webhook
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"log/slog"
+	"strings"
+	"time"
+
+	"github.com/cexll/swe/internal/github"
+	"github.com/cexll/swe/internal/logging"
+	"github.com/cexll/swe/internal/schedule"
+)
+
+// scheduledActor is recorded as the trigger user of scheduled runs.
+const scheduledActor = "schedule"
+
+// allow tests to stub issue creation
+var createIssue = github.CreateIssue
+
+// LaunchScheduled starts a run of a scheduled job: it opens an issue in the
+// job's repository and queues the job's prompt on it as a manual task, so
+// the findings are reported there. It satisfies schedule.Launch.
+func (h *Handler) LaunchScheduled(ctx context.Context, job schedule.Job) (string, int, error) {
+	// batch jobs wait behind the tasks people asked for
+	req := ManualTaskRequest{Repo: job.Repo, Prompt: job.Prompt, BaseBranch: job.BaseBranch, Actor: scheduledActor, Priority: "low"}
+	summary := fmt.Sprintf("**Scheduled:** `%s` (`%s`)", job.Name, job.Cron)
+	t, number, err := h.launchOnNewIssue(ctx, req, job.IssueTitle(time.Now().UTC()), scheduledIssueBody(job), job.Labels, summary)
+	if err != nil {
+		return "", number, err
+	}
+	taskLog(t, phaseEnqueue).InfoContext(ctx, "Scheduled task queued", "job", job.Name, "number", number)
+	return t.ID, number, nil
+}
+
+// launchOnNewIssue opens an issue in req.Repo and queues req's prompt on it
+// as a manual task with the given prompt summary. It returns the issue
+// number even when queueing fails; the failure is then reported on the
+// issue.
+func (h *Handler) launchOnNewIssue(ctx context.Context, req ManualTaskRequest, title, body string, labels []string, summary string) (*Task, int, error) {
+	if enabled, reason := h.checkRepo(req.Repo); !enabled {
+		return nil, 0, fmt.Errorf("%s (%s)", RepoNotEnabledMessage, reason)
+	}
+	if h.appAuth == nil {
+		return nil, 0, errors.New("GitHub App authentication is not configured")
+	}
+	token, err := h.appAuth.GetInstallationToken(req.Repo)
+	if err != nil {
+		return nil, 0, fmt.Errorf("installation token for %s: %w", req.Repo, err)
+	}
+
+	owner, name := splitRepo(req.Repo)
+	number, err := createIssue(owner, name, title, body, labels, token.Token)
+	if err != nil {
+		return nil, 0, fmt.Errorf("open issue: %w", err)
+	}
+
+	req.Number = number
+	t, err := h.queueOnIssue(ctx, req, summary)
+	if err != nil {
+		// say so on the issue rather than leave it waiting for a task
+		msg := fmt.Sprintf("The task could not be started: %v", err)
+		if _, cerr := createComment(owner, name, number, msg, token.Token); cerr != nil {
+			slog.WarnContext(ctx, "Commenting on the issue failed", logging.KeyRepo, req.Repo, logging.KeyPhase, phaseReply, "number", number, "err", cerr)
+		}
+		return nil, number, err
+	}
+	return t, number, nil
+}
+
+// queueOnIssue queues req's prompt with the given prompt summary.
+func (h *Handler) queueOnIssue(ctx context.Context, req ManualTaskRequest, summary string) (*Task, error) {
+	if err := req.validate(); err != nil {
+		return nil, err
+	}
+	t, err := h.manualTask(ctx, req)
+	if err != nil {
+		return nil, fmt.Errorf("prepare task: %w", err)
+	}
+	t.PromptSummary = summary
+	t.IdempotencyKey = req.idempotencyKey
+	if err := h.dispatchTask(t); err != nil {
+		return nil, err
+	}
+	return t, nil
+}
+
+// scheduledIssueBody explains where the issue came from and quotes the task.
+func scheduledIssueBody(job schedule.Job) string {
+	var b strings.Builder
+	fmt.Fprintf(&b, "Opened by the scheduled job `%s` (`%s`). Findings are reported below; fixes come as a pull request.\n\n**Task:**\n\n", job.Name, job.Cron)
+	for _, line := range strings.Split(job.Prompt, "\n") {
+		b.WriteString(strings.TrimRight("> "+line, " ") + "\n")
+	}
+	return b.String()
+}
@@ -0,0 +1,100 @@
+package webhook
+
+import (
+	"context"
+	"strings"
+	"testing"
+
+	"github.com/cexll/swe/internal/github"
+	"github.com/cexll/swe/internal/schedule"
+	"github.com/cexll/swe/internal/taskstore"
+)
+
+func TestLaunchScheduled_OpensIssueAndQueuesTask(t *testing.T) {
+	orig := createIssue
+	t.Cleanup(func() { createIssue = orig })
+	var title, body string
+	var labels []string
+	createIssue = func(owner, repo, gotTitle, gotBody string, gotLabels []string, token string) (int, error) {
+		if owner != "owner" || repo != "repo" || token != "inst-token" {
+			t.Errorf("createIssue(%s, %s, token %s)", owner, repo, token)
+		}
+		title, body, labels = gotTitle, gotBody, gotLabels
+		return 31, nil
+	}
+	dispatcher := &mockDispatcher{}
+	auth := &mockAppAuth{GetInstallationTokenFunc: func(repo string) (*github.InstallationToken, error) {
+		return &github.InstallationToken{Token: "inst-token"}, nil
+	}}
+	handler := NewHandler("secret", "/code", dispatcher, taskstore.NewStore(), auth)
+
+	job := schedule.Job{Name: "deps", Cron: "0 9 * * mon", Repo: "owner/repo", Prompt: "audit the dependencies\nand open a PR for safe bumps", Labels: []string{"chore"}}
+	taskID, issue, err := handler.LaunchScheduled(context.Background(), job)
+	if err != nil {
+		t.Fatalf("LaunchScheduled: %v", err)
+	}
+	if !strings.HasPrefix(title, "deps (") || labels[0] != "chore" ||
+		!strings.Contains(body, "> audit the dependencies\n> and open a PR for safe bumps\n") {
+		t.Fatalf("issue %q labeled %v:\n%s", title, labels, body)
+	}
+	task := dispatcher.lastTask
+	if task == nil || task.ID != taskID || issue != 31 || task.Number != 31 || task.IsPR || task.Username != scheduledActor {
+		t.Fatalf("task %+v, issue %d", task, issue)
+	}
+	if task.PromptSummary != "**Scheduled:** `deps` (`0 9 * * mon`)" {
+		t.Fatalf("summary = %q", task.PromptSummary)
+	}
+	if task.Priority != PriorityLow {
+		t.Fatalf("priority = %v, want low", task.Priority)
+	}
+	ghCtx, err := github.ParseWebhookEvent(task.EventType, task.RawPayload)
+	if err != nil || ghCtx.ExtractPrompt("/code") != job.Prompt {
+		t.Fatalf("payload: %v, prompt %q", err, ghCtx.ExtractPrompt("/code"))
+	}
+}
+
+func TestLaunchScheduled_RepoNotEnabled(t *testing.T) {
+	orig := createIssue
+	t.Cleanup(func() { createIssue = orig })
+	createIssue = func(string, string, string, string, []string, string) (int, error) {
+		t.Fatal("an issue was opened in a repository that is not enabled")
+		return 0, nil
+	}
+	handler := NewHandler("secret", "/code", &mockDispatcher{}, nil, &mockAppAuth{})
+	filter, err := NewRepoFilter([]string{"owner/other"}, nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	handler.SetRepoFilter(filter)
+
+	if _, _, err := handler.LaunchScheduled(context.Background(), schedule.Job{Name: "deps", Repo: "owner/repo", Prompt: "x"}); err == nil || !strings.Contains(err.Error(), RepoNotEnabledMessage) {
+		t.Fatalf("err = %v", err)
+	}
+}
+
+func TestLaunchScheduled_ReportsQueueFailureOnIssue(t *testing.T) {
+	origIssue, origComment := createIssue, createComment
+	t.Cleanup(func() { createIssue, createComment = origIssue, origComment })
+	createIssue = func(string, string, string, string, []string, string) (int, error) { return 5, nil }
+	var comment string
+	createComment = func(_, _ string, number int, body, _ string) (int64, error) {
+		if number != 5 {
+			t.Errorf("comment on #%d", number)
+		}
+		comment = body
+		return 1, nil
+	}
+	dispatcher := &mockDispatcher{enqueueFunc: func(*Task) error { return ErrQueueClosed }}
+	auth := &mockAppAuth{GetInstallationTokenFunc: func(repo string) (*github.InstallationToken, error) {
+		return &github.InstallationToken{Token: "inst-token"}, nil
+	}}
+	handler := NewHandler("secret", "/code", dispatcher, nil, auth)
+
+	_, issue, err := handler.LaunchScheduled(context.Background(), schedule.Job{Name: "deps", Cron: "@daily", Repo: "owner/repo", Prompt: "x"})
+	if err == nil || issue != 5 {
+		t.Fatalf("issue %d, err %v", issue, err)
+	}
+	if !strings.Contains(comment, "could not be started") {
+		t.Fatalf("comment = %q", comment)
+	}
+}
@@ -0,0 +1,308 @@
+package webhook
+
+import (
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"strings"
+
+	"github.com/cexll/swe/internal/github"
+	"github.com/cexll/swe/internal/modes"
+	"github.com/cexll/swe/internal/policy"
+)
+
+// SimulationRequest is a synthetic comment accepted by POST /admin/simulate.
+type SimulationRequest struct {
+	Event    string `json:"event,omitempty"`     // issue_comment (default) or pull_request_review_comment
+	Action   string `json:"action,omitempty"`    // default "created"
+	Repo     string `json:"repo"`                // owner/name
+	Number   int    `json:"number,omitempty"`    // issue or PR number (default 1)
+	IsPR     bool   `json:"is_pr,omitempty"`     // issue_comment on a pull request
+	User     string `json:"user"`                // comment author login
+	UserType string `json:"user_type,omitempty"` // "User" (default) or "Bot"
+	Body     string `json:"body"`                // comment body
+}
+
+// SimulationStep is one check of the webhook decision chain, in order.
+type SimulationStep struct {
+	Check  string `json:"check"`
+	Passed bool   `json:"passed"`
+	Detail string `json:"detail,omitempty"`
+}
+
+// SimulationResult reports what Handle would do with the synthetic event.
+type SimulationResult struct {
+	WouldEnqueue     bool             `json:"would_enqueue"`
+	Response         string           `json:"response"` // message Handle would return
+	TriggerKeyword   string           `json:"trigger_keyword"`
+	TriggerMatched   bool             `json:"trigger_matched"`
+	Prompt           string           `json:"prompt,omitempty"`
+	PermissionAllow  *bool            `json:"permission_allowed,omitempty"`
+	PermissionReason string           `json:"permission_reason,omitempty"`
+	PolicyRule       string           `json:"policy_rule,omitempty"` // policy rule that decided the permission
+	Mode             string           `json:"mode,omitempty"`
+	Provider         string           `json:"provider,omitempty"`
+	Steps            []SimulationStep `json:"steps"`
+}
+
+// SetProviderName records the configured AI provider for simulation reports.
+func (h *Handler) SetProviderName(name string) {
+	h.providerName = name
+}
+
+// Simulate runs a synthetic comment through the same checks as Handle
+// (event, action, bot filter, trigger keyword, permission or policy, mode)
+// and reports the decision without creating comments or enqueuing a task.
+func (h *Handler) Simulate(w http.ResponseWriter, r *http.Request) {
+	if h.apiToken == "" {
+		http.Error(w, "simulation disabled (API_TOKEN not set)", http.StatusServiceUnavailable)
+		return
+	}
+	if !h.authorizedOperator(r) {
+		w.Header().Set("WWW-Authenticate", `Bearer realm="swe-agent"`)
+		http.Error(w, "unauthorized", http.StatusUnauthorized)
+		return
+	}
+
+	var req SimulationRequest
+	if err := json.NewDecoder(http.MaxBytesReader(w, r.Body, 1<<20)).Decode(&req); err != nil {
+		http.Error(w, "invalid JSON body", http.StatusBadRequest)
+		return
+	}
+	payload, err := req.payload()
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusBadRequest)
+		return
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	_ = json.NewEncoder(w).Encode(h.simulate(req.Event, payload))
+}
+
+func (h *Handler) simulate(eventType string, payload []byte) *SimulationResult {
+	res := &SimulationResult{TriggerKeyword: h.trigger(), Provider: h.providerName}
+	step := func(check string, passed bool, detail string) bool {
+		res.Steps = append(res.Steps, SimulationStep{Check: check, Passed: passed, Detail: detail})
+		return passed
+	}
+
+	if !step("event", isCommentEvent(eventType), eventType) {
+		res.Response = "Event ignored"
+		return res
+	}
+	ghCtx, err := github.ParseWebhookEvent(eventType, payload)
+	if err != nil {
+		step("parse", false, err.Error())
+		res.Response = "Error parsing event"
+		return res
+	}
+	if !step("action", ghCtx.EventAction == "created", string(ghCtx.EventAction)) {
+		res.Response = "Non-created action ignored"
+		return res
+	}
+	if !step("not_bot", !isBotComment(eventType, payload), ghCtx.TriggerUser) {
+		res.Response = "Bot comment ignored"
+		return res
+	}
+
+	if cmd, ok := parseReleaseCommand(ghCtx.GetTriggerCommentBody()); ok && h.releaseEnabled() {
+		who := "maintainers only"
+		if h.currentPolicy() != nil {
+			who = "as the policy allows"
+		}
+		step("release", true, fmt.Sprintf("%s %s: %s; a release is queued only after confirmation", ReleaseCommand, cmd, who))
+		res.Mode = "release"
+		res.Response = "Handled by release mode"
+		return res
+	}
+	if h.triageEnabled() && isTriageCommand(ghCtx.GetTriggerCommentBody()) {
+		res.Mode = "triage"
+		if enabled, reason := h.checkRepo(ghCtx.Repository.FullName); !step("repository", enabled, reason) {
+			res.Response = "Repository not enabled"
+			return res
+		}
+		decision := h.authorize(ghCtx, triageCommandName)
+		res.PermissionAllow = &decision.Allowed
+		res.PermissionReason = decision.Reason
+		res.PolicyRule = decision.Rule
+		if !step("permission", decision.Allowed, decision.Reason) {
+			res.Response = "Permission denied"
+			return res
+		}
+		detail := "labels the issue, looks for duplicates and assigns a priority; pushes nothing"
+		if ghCtx.IsPRContext() {
+			detail = "works on issues only"
+		}
+		if !step("triage", !ghCtx.IsPRContext(), detail) {
+			res.Response = "Not an issue"
+			return res
+		}
+		res.WouldEnqueue = true
+		res.Response = "Task queued"
+		return res
+	}
+
+	trigger := h.triggerFor(ghCtx.Repository.FullName)
+	res.TriggerKeyword = trigger
+	if h.approvalCommandEnabled() && isApprovalCommand(ghCtx.GetTriggerCommentBody(), trigger) {
+		step("approval", true, "approves the plan waiting on the thread; needs write access and someone other than the requester")
+		res.Mode = "approval"
+		res.Response = "Handled by approval mode"
+		return res
+	}
+	if isApplyCommand(ghCtx.GetTriggerCommentBody(), trigger) {
+		step("apply", true, "pushes the changes of the thread's latest dry run, with the same permission as the trigger")
+		res.Mode = "apply"
+		res.Response = "Handled by dry run apply"
+		return res
+	}
+	if h.costConfirmEnabled() && isConfirmCommand(ghCtx.GetTriggerCommentBody(), trigger) {
+		step("confirm", true, "runs the task the thread's cost estimate stopped, with the same permission as the trigger")
+		res.Mode = "confirm"
+		res.Response = "Handled by cost confirmation"
+		return res
+	}
+	res.TriggerMatched = ghCtx.ShouldTrigger(trigger)
+	if !step("trigger", res.TriggerMatched, fmt.Sprintf("keyword %q", trigger)) {
+		res.Response = "No trigger keyword found"
+		return res
+	}
+	res.Prompt = strings.TrimSpace(ghCtx.ExtractPrompt(trigger))
+
+	if enabled, reason := h.checkRepo(ghCtx.Repository.FullName); !step("repository", enabled, reason) {
+		res.Response = "Repository not enabled"
+		return res
+	}
+
+	decision := h.authorize(ghCtx, commandName(trigger))
+	res.PermissionAllow = &decision.Allowed
+	res.PermissionReason = decision.Reason
+	res.PolicyRule = decision.Rule
+	if !step("permission", decision.Allowed, decision.Reason) {
+		res.Response = "Permission denied"
+		return res
+	}
+
+	if isHelpCommand(res.Prompt) {
+		step(helpCommand, true, "replies with the commands and flags; starts nothing")
+		res.Response = "Help posted"
+		return res
+	}
+	if unknown := unknownFlags(res.Prompt); len(unknown) > 0 {
+		step("flags", false, "unknown "+strings.Join(unknown, ", ")+"; replies with the flags and starts nothing")
+		res.Response = "Unknown flag"
+		return res
+	}
+	if st, exhausted := h.budgetExhausted(ghCtx.Repository.FullName); exhausted {
+		step("budget", false, fmt.Sprintf("%s spent $%.2f of its $%.2f hard cap for %s; replies and starts nothing", st.Org, st.SpentUSD, st.HardCapUSD, st.Month))
+		res.Response = "Budget exhausted"
+		return res
+	}
+
+	if isAddressReviewsCommand(res.Prompt) {
+		detail := "addresses the pull request's unresolved review threads"
+		if !ghCtx.IsPRContext() {
+			detail = "works on pull requests only"
+		}
+		if !step(addressReviewsCommand, ghCtx.IsPRContext(), detail) {
+			res.Response = "Not a pull request"
+			return res
+		}
+	}
+
+	if target, ok := parseBackportCommand(res.Prompt); ok {
+		detail := fmt.Sprintf("cherry-picks the merged pull request onto %s and opens a backport pull request", target)
+		switch {
+		case !ghCtx.IsPRContext():
+			detail = "works on pull requests only"
+		case target == "":
+			detail = "names no valid branch to backport to"
+		}
+		if !step(backportCommand, ghCtx.IsPRContext() && target != "", detail) {
+			res.Response = "Invalid backport command"
+			return res
+		}
+	}
+
+	mode := modes.GetCommandMode()
+	if mode == nil {
+		step("mode", false, "CommandMode not registered")
+		res.Response = "Internal configuration error"
+		return res
+	}
+	res.Mode = mode.Name()
+	detail := res.Mode
+	if _, backport := parseBackportCommand(res.Prompt); backport {
+		detail += ", backport"
+	} else if isUpdateDepsCommand(res.Prompt) {
+		detail += ", dependency update"
+	} else if decision.Effect == policy.DryRun {
+		detail += fmt.Sprintf(", dry run (policy rule %q) until applied with %q", decision.Rule, applyCommand(trigger))
+	} else if decision.Effect == policy.RequireApproval {
+		detail += fmt.Sprintf(", plan only (policy rule %q) until approved with %q", decision.Rule, approvalCommand(trigger))
+	} else if h.wantsDryRun(ghCtx.GetTriggerCommentBody()) {
+		detail += fmt.Sprintf(", dry run until applied with %q", applyCommand(trigger))
+	} else if h.approvalEnabled() {
+		detail += fmt.Sprintf(", plan only until approved with %q", approvalCommand(trigger))
+	}
+	if _, backport := parseBackportCommand(res.Prompt); h.costConfirmEnabled() && !backport && !isUpdateDepsCommand(res.Prompt) {
+		detail += fmt.Sprintf(", stops for %q if its estimated cost needs confirming", confirmCommand(trigger))
+	}
+	step("mode", true, detail)
+
+	res.WouldEnqueue = true
+	res.Response = "Task queued"
+	return res
+}
+
+// payload renders the request as the webhook payload GitHub would send.
+func (req *SimulationRequest) payload() ([]byte, error) {
+	req.Repo = strings.TrimSpace(req.Repo)
+	owner, name := splitRepo(req.Repo)
+	if owner == "" || name == "" {
+		return nil, fmt.Errorf("repo must be in owner/name form")
+	}
+	if strings.TrimSpace(req.User) == "" {
+		return nil, fmt.Errorf("user is required")
+	}
+	if req.Event == "" {
+		req.Event = EventNameIssueComment
+	}
+	if req.Action == "" {
+		req.Action = "created"
+	}
+	if req.Number <= 0 {
+		req.Number = 1
+	}
+	if req.UserType == "" {
+		req.UserType = "User"
+	}
+
+	repo := Repository{FullName: req.Repo, Name: name, Owner: User{Login: owner}, DefaultBranch: "main"}
+	author := User{Login: req.User, Type: req.UserType}
+
+	var ev interface{}
+	switch req.Event {
+	case EventNamePullRequestReviewComment:
+		ev = &PullRequestReviewCommentEvent{
+			Action:      req.Action,
+			Comment:     ReviewComment{Body: req.Body, User: author},
+			PullRequest: PullRequest{Number: req.Number, State: "open", Base: PRBranch{Ref: "main"}},
+			Repository:  repo,
+			Sender:      author,
+		}
+	default:
+		issue := Issue{Number: req.Number, State: "open"}
+		if req.IsPR {
+			issue.PullRequest = &IssuePRLinks{URL: fmt.Sprintf("https://api.github.com/repos/%s/pulls/%d", req.Repo, req.Number)}
+		}
+		ev = &IssueCommentEvent{
+			Action:     req.Action,
+			Issue:      issue,
+			Comment:    Comment{Body: req.Body, User: author},
+			Repository: repo,
+			Sender:     author,
+		}
+	}
+	return json.Marshal(ev)
+}
@@ -0,0 +1,151 @@
+package webhook
+
+import (
+	"bytes"
+	"encoding/json"
+	"errors"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+)
+
+func simulateRequest(t *testing.T, h *Handler, body string) (*SimulationResult, int) {
+	t.Helper()
+	if h.apiToken == "" {
+		h.SetAPIToken("op-token")
+	}
+	r := httptest.NewRequest(http.MethodPost, "/admin/simulate", bytes.NewBufferString(body))
+	r.Header.Set("Authorization", "Bearer "+h.apiToken)
+	w := httptest.NewRecorder()
+	h.Simulate(w, r)
+	if w.Code != http.StatusOK {
+		return nil, w.Code
+	}
+	var res SimulationResult
+	if err := json.Unmarshal(w.Body.Bytes(), &res); err != nil {
+		t.Fatalf("decode: %v", err)
+	}
+	return &res, w.Code
+}
+
+func lastStep(res *SimulationResult) SimulationStep {
+	return res.Steps[len(res.Steps)-1]
+}
+
+func TestSimulate_WouldEnqueue(t *testing.T) {
+	dispatcher := &mockDispatcher{}
+	h := NewHandler("secret", "/code", dispatcher, nil, &stubAuthProvider{owner: "installer"})
+	h.SetProviderName("codex")
+
+	res, _ := simulateRequest(t, h, `{"repo":"owner/repo","user":"installer","body":"/code fix the tests","is_pr":true}`)
+	if !res.WouldEnqueue || res.Response != "Task queued" || !res.TriggerMatched {
+		t.Fatalf("unexpected result: %+v", res)
+	}
+	if res.Prompt != "fix the tests" || res.Mode != "command" || res.Provider != "codex" {
+		t.Fatalf("unexpected details: %+v", res)
+	}
+	if res.PermissionAllow == nil || !*res.PermissionAllow || res.PermissionReason != "user is the app installer" {
+		t.Fatalf("unexpected permission: %+v", res)
+	}
+	if len(res.Steps) != 7 {
+		t.Fatalf("steps = %+v", res.Steps)
+	}
+	if dispatcher.enqueueCalls != 0 {
+		t.Fatal("simulation must not enqueue")
+	}
+}
+
+func TestSimulate_ReloadedTriggerKeyword(t *testing.T) {
+	h := NewHandler("secret", "/code", &mockDispatcher{}, nil, &stubAuthProvider{owner: "installer"})
+	h.SetTriggerKeyword("/agent")
+
+	res, _ := simulateRequest(t, h, `{"repo":"owner/repo","user":"installer","body":"/code fix it"}`)
+	if res.TriggerMatched || res.TriggerKeyword != "/agent" {
+		t.Fatalf("old keyword should no longer trigger: %+v", res)
+	}
+	res, _ = simulateRequest(t, h, `{"repo":"owner/repo","user":"installer","body":"/agent fix it"}`)
+	if !res.WouldEnqueue || res.Prompt != "fix it" {
+		t.Fatalf("new keyword should trigger: %+v", res)
+	}
+}
+
+func TestSimulate_Decisions(t *testing.T) {
+	tests := []struct {
+		name      string
+		auth      *stubAuthProvider
+		body      string
+		response  string
+		failCheck string
+	}{
+		{"unsupported event", nil, `{"event":"issues","repo":"o/r","user":"u","body":"/code x"}`, "Event ignored", "event"},
+		{"edited action", nil, `{"action":"edited","repo":"o/r","user":"u","body":"/code x"}`, "Non-created action ignored", "action"},
+		{"bot author", nil, `{"repo":"o/r","user":"ci[bot]","user_type":"Bot","body":"/code x"}`, "Bot comment ignored", "not_bot"},
+		{"no trigger", nil, `{"repo":"o/r","user":"u","body":"please fix"}`, "No trigger keyword found", "trigger"},
+		{"not installer", &stubAuthProvider{owner: "installer"}, `{"repo":"o/r","user":"stranger","body":"/code x"}`, "Permission denied", "permission"},
+		{"review comment allowed", nil, `{"event":"pull_request_review_comment","repo":"o/r","user":"u","body":"/code x"}`, "Task queued", ""},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			h := NewHandler("secret", "/code", &mockDispatcher{}, nil, nil)
+			if tt.auth != nil {
+				h.appAuth = tt.auth
+			}
+			res, code := simulateRequest(t, h, tt.body)
+			if code != http.StatusOK {
+				t.Fatalf("status = %d", code)
+			}
+			if res.Response != tt.response {
+				t.Fatalf("response = %q, want %q (%+v)", res.Response, tt.response, res.Steps)
+			}
+			if tt.failCheck != "" {
+				if step := lastStep(res); step.Check != tt.failCheck || step.Passed {
+					t.Fatalf("last step = %+v, want failed %s", step, tt.failCheck)
+				}
+				if res.WouldEnqueue {
+					t.Fatal("should not enqueue")
+				}
+			}
+		})
+	}
+}
+
+func TestSimulate_PermissionFailOpenReason(t *testing.T) {
+	h := NewHandler("secret", "/code", &mockDispatcher{}, nil, &stubAuthProvider{err: errors.New("rate limited")})
+	res, _ := simulateRequest(t, h, `{"repo":"o/r","user":"anyone","body":"/code x"}`)
+	if !res.WouldEnqueue || res.PermissionReason != "installer lookup failed, failing open: rate limited" {
+		t.Fatalf("unexpected result: %+v", res)
+	}
+}
+
+func TestSimulate_BadRequests(t *testing.T) {
+	h := NewHandler("secret", "/code", &mockDispatcher{}, nil, nil)
+	for _, body := range []string{`{`, `{"user":"u","body":"x"}`, `{"repo":"o/r","body":"x"}`} {
+		if _, code := simulateRequest(t, h, body); code != http.StatusBadRequest {
+			t.Errorf("body %s: status = %d, want 400", body, code)
+		}
+	}
+}
+
+func TestSimulate_RequiresOperatorToken(t *testing.T) {
+	h := NewHandler("secret", "/code", &mockDispatcher{}, nil, &stubAuthProvider{owner: "installer"})
+	body := `{"repo":"o/r","user":"installer","body":"/code x"}`
+	w := httptest.NewRecorder()
+	h.Simulate(w, httptest.NewRequest(http.MethodPost, "/admin/simulate", bytes.NewBufferString(body)))
+	if w.Code != http.StatusServiceUnavailable {
+		t.Fatalf("without API_TOKEN: status = %d, want 503", w.Code)
+	}
+
+	h.SetAPIToken("op-token")
+	for _, auth := range []string{"", "Bearer wrong"} {
+		r := httptest.NewRequest(http.MethodPost, "/admin/simulate", bytes.NewBufferString(body))
+		if auth != "" {
+			r.Header.Set("Authorization", auth)
+		}
+		w := httptest.NewRecorder()
+		h.Simulate(w, r)
+		if w.Code != http.StatusUnauthorized || strings.Contains(w.Body.String(), "permission") {
+			t.Fatalf("Authorization %q: status = %d, body %q", auth, w.Code, w.Body.String())
+		}
+	}
+}
@@ -0,0 +1,85 @@
+package webhook
+
+import (
+	"context"
+	"fmt"
+	"net/http"
+	"regexp"
+
+	"github.com/cexll/swe/internal/github"
+	"github.com/cexll/swe/internal/modes"
+)
+
+// TriageCommand starts triage mode: the agent labels the issue, looks for
+// duplicates and assigns a priority.
+const TriageCommand = "/triage"
+
+var triageCommandPattern = regexp.MustCompile(`(?i)(?:^|\s)/triage(?:\s|$)`)
+
+// isTriageCommand reports whether body asks for triage.
+func isTriageCommand(body string) bool {
+	return triageCommandPattern.MatchString(body)
+}
+
+// SetTriageMode enables the /triage command; safe to call while requests are
+// being served.
+func (h *Handler) SetTriageMode(enabled bool) {
+	h.settingsMu.Lock()
+	defer h.settingsMu.Unlock()
+	h.triageMode = enabled
+}
+
+func (h *Handler) triageEnabled() bool {
+	h.settingsMu.RLock()
+	defer h.settingsMu.RUnlock()
+	return h.triageMode
+}
+
+// handleTriage queues a triage task for the issue the command came from.
+func (h *Handler) handleTriage(ctx context.Context, w http.ResponseWriter, ghCtx *github.Context, eventType string, payload []byte) {
+	repo := ghCtx.Repository.FullName
+	if enabled, reason := h.checkRepo(repo); !enabled {
+		h.rejectRepo(ghCtx, reason)
+		w.WriteHeader(http.StatusOK)
+		_, _ = w.Write([]byte("Repository not enabled"))
+		return
+	}
+	if !h.getDeduper(eventType).markIfNew(ghCtx.TriggerComment.ID) {
+		w.WriteHeader(http.StatusOK)
+		_, _ = w.Write([]byte("Duplicate comment ignored"))
+		return
+	}
+	h.setInstallationToken(ghCtx, triageCommandName)
+
+	decision := h.authorize(ghCtx, triageCommandName)
+	h.recordPermission(ghCtx, decision.Allowed, decision.Reason)
+	if !decision.Allowed {
+		eventLog(ghCtx, phaseAuthorize).InfoContext(ctx, "Triage permission denied", "user", ghCtx.TriggerUser, "reason", decision.Reason)
+		msg := decision.Message
+		if msg == "" {
+			msg = fmt.Sprintf("@%s you are not allowed to run `%s` here.", ghCtx.TriggerUser, TriageCommand)
+		}
+		h.replyThread(ghCtx, msg)
+		w.WriteHeader(http.StatusOK)
+		_, _ = w.Write([]byte("Permission denied"))
+		return
+	}
+	if ghCtx.IsPRContext() {
+		h.replyThread(ghCtx, fmt.Sprintf("`%s` works on issues only.", TriageCommand))
+		w.WriteHeader(http.StatusOK)
+		_, _ = w.Write([]byte("Not an issue"))
+		return
+	}
+
+	ghCtx.PreparedTriage = true
+	t, err := h.prepareModeTask(ctx, modes.GetTriageMode(), ghCtx, payload)
+	if err != nil {
+		eventLog(ghCtx, phaseEnqueue).ErrorContext(ctx, "Preparing triage task failed", "err", err)
+		http.Error(w, "Task preparation failed", http.StatusInternalServerError)
+		return
+	}
+	t.Triage = true
+	t.PromptSummary = fmt.Sprintf("**Triage:** issue #%d, requested by @%s", ghCtx.IssueNumber, ghCtx.TriggerUser)
+	eventLog(ghCtx, phaseEnqueue).InfoContext(ctx, "Triage requested", "number", ghCtx.IssueNumber, "user", ghCtx.TriggerUser)
+	h.enqueueTask(w, t)
+}
@@ -0,0 +1,66 @@
+package webhook
+
+import (
+	"net/http"
+	"strings"
+	"testing"
+
+	_ "github.com/cexll/swe/internal/modes/triage" // Register TriageMode
+)
+
+func TestIsTriageCommand(t *testing.T) {
+	tests := map[string]bool{
+		"/triage":                  true,
+		"please /Triage this":      true,
+		"/triage\nthanks":          true,
+		"/triaged":                 false,
+		"see docs/triage for more": false,
+		"/code triage":             false,
+	}
+	for body, want := range tests {
+		if got := isTriageCommand(body); got != want {
+			t.Errorf("isTriageCommand(%q) = %t, want %t", body, got, want)
+		}
+	}
+}
+
+func TestHandleTriage(t *testing.T) {
+	h, dispatcher, _, posted := releaseHandler(t, nil)
+	h.SetReleaseMode(false)
+
+	// disabled, /triage is an ordinary comment
+	if w := postRelease(t, h, 1, "installer", "/triage"); w.Body.String() != "No trigger keyword found" || dispatcher.enqueueCalls != 0 {
+		t.Fatalf("disabled triage mode = %q", w.Body.String())
+	}
+
+	h.SetTriageMode(true)
+	if w := postRelease(t, h, 2, "mallory", "/triage"); w.Body.String() != "Permission denied" || dispatcher.enqueueCalls != 0 {
+		t.Fatalf("unauthorized triage = %q", w.Body.String())
+	}
+	if last := (*posted)[len(*posted)-1]; !strings.Contains(last, "@mallory you are not allowed to run `/triage` here.") {
+		t.Fatalf("denial = %q", last)
+	}
+
+	w := postRelease(t, h, 3, "installer", "/triage")
+	if w.Code != http.StatusAccepted || dispatcher.enqueueCalls != 1 {
+		t.Fatalf("triage response = %d %q, enqueued %d", w.Code, w.Body.String(), dispatcher.enqueueCalls)
+	}
+	task := dispatcher.lastTask
+	if task.Mode != "triage" || !task.Triage || task.BaseBranch != "main" || task.CommentID != 123456 ||
+		!strings.Contains(task.PromptSummary, "**Triage:** issue #9, requested by @installer") {
+		t.Fatalf("unexpected task: %+v", task)
+	}
+
+	if w := postRelease(t, h, 3, "installer", "/triage"); w.Body.String() != "Duplicate comment ignored" {
+		t.Fatalf("redelivered triage = %q", w.Body.String())
+	}
+}
+
+func TestSimulate_TriageCommand(t *testing.T) {
+	h := NewHandler("secret", "/code", &mockDispatcher{}, nil, nil)
+	h.SetTriageMode(true)
+	res, _ := simulateRequest(t, h, `{"repo":"owner/repo","user":"u","body":"/triage","is_pr":true}`)
+	if res.WouldEnqueue || res.Mode != "triage" || lastStep(res).Check != "triage" || res.Response != "Not an issue" {
+		t.Fatalf("unexpected simulation: %+v", res)
+	}
+}
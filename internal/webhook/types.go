@@ -1,68 +1,405 @@
 package webhook
 
-// GitHub webhook event types
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"strings"
+	"time"
+)
+
+// GitHub webhook event types.
+//
+// These mirror the subset of the GitHub webhook schema swe-agent relies on,
+// shared by every event so features stop re-declaring partial payload structs.
+// Unknown fields are ignored; optional objects are pointers.
+
+// Event names (X-GitHub-Event header values)
+const (
+	EventNameIssueComment             = "issue_comment"
+	EventNameIssues                   = "issues"
+	EventNamePullRequest              = "pull_request"
+	EventNamePullRequestReview        = "pull_request_review"
+	EventNamePullRequestReviewComment = "pull_request_review_comment"
+	EventNameInstallation             = "installation"
+	EventNamePing                     = "ping"
+
+	// Membership changes; used only to invalidate cached permission checks.
+	EventNameMember       = "member"
+	EventNameMembership   = "membership"
+	EventNameTeam         = "team"
+	EventNameTeamAdd      = "team_add"
+	EventNameOrganization = "organization"
+)
+
+// ErrUnsupportedEvent is returned by ParseEvent for event names without a typed model.
+var ErrUnsupportedEvent = errors.New("unsupported webhook event")
+
+// AuthorAssociation is the commenter's relationship to the repository.
+type AuthorAssociation string
+
+const (
+	AssociationOwner        AuthorAssociation = "OWNER"
+	AssociationMember       AuthorAssociation = "MEMBER"
+	AssociationCollaborator AuthorAssociation = "COLLABORATOR"
+	AssociationContributor  AuthorAssociation = "CONTRIBUTOR"
+	AssociationFirstTimer   AuthorAssociation = "FIRST_TIMER"
+	AssociationFirstTime    AuthorAssociation = "FIRST_TIME_CONTRIBUTOR"
+	AssociationMannequin    AuthorAssociation = "MANNEQUIN"
+	AssociationNone         AuthorAssociation = "NONE"
+)
+
+// IsTrusted reports whether the association grants write-level trust
+// (owner, organization member or collaborator).
+func (a AuthorAssociation) IsTrusted() bool {
+	switch a {
+	case AssociationOwner, AssociationMember, AssociationCollaborator:
+		return true
+	}
+	return false
+}
+
+// ReviewState is the state of a submitted pull request review.
+type ReviewState string
+
+const (
+	ReviewApproved         ReviewState = "approved"
+	ReviewChangesRequested ReviewState = "changes_requested"
+	ReviewCommented        ReviewState = "commented"
+	ReviewDismissed        ReviewState = "dismissed"
+	ReviewPending          ReviewState = "pending"
+)
+
+// Event is implemented by every typed webhook payload.
+type Event interface {
+	// Name returns the X-GitHub-Event value the payload was delivered as.
+	Name() string
+	GetAction() string
+	GetRepository() Repository
+	GetSender() User
+	GetInstallation() *Installation
+}
 
 type IssueCommentEvent struct {
-	Action     string     `json:"action"`
-	Issue      Issue      `json:"issue"`
-	Comment    Comment    `json:"comment"`
-	Repository Repository `json:"repository"`
-	Sender     User       `json:"sender"`
+	Action       string        `json:"action"`
+	Issue        Issue         `json:"issue"`
+	Comment      Comment       `json:"comment"`
+	Repository   Repository    `json:"repository"`
+	Sender       User          `json:"sender"`
+	Installation *Installation `json:"installation,omitempty"`
 }
 
 type PullRequestReviewCommentEvent struct {
-	Action      string        `json:"action"`
-	Comment     ReviewComment `json:"comment"`
-	PullRequest PullRequest   `json:"pull_request"`
-	Repository  Repository    `json:"repository"`
-	Sender      User          `json:"sender"`
+	Action       string        `json:"action"`
+	Comment      ReviewComment `json:"comment"`
+	PullRequest  PullRequest   `json:"pull_request"`
+	Repository   Repository    `json:"repository"`
+	Sender       User          `json:"sender"`
+	Installation *Installation `json:"installation,omitempty"`
+}
+
+type PullRequestReviewEvent struct {
+	Action       string        `json:"action"`
+	Review       Review        `json:"review"`
+	PullRequest  PullRequest   `json:"pull_request"`
+	Repository   Repository    `json:"repository"`
+	Sender       User          `json:"sender"`
+	Installation *Installation `json:"installation,omitempty"`
+}
+
+type IssuesEvent struct {
+	Action       string        `json:"action"`
+	Issue        Issue         `json:"issue"`
+	Label        *Label        `json:"label,omitempty"`    // labeled/unlabeled
+	Assignee     *User         `json:"assignee,omitempty"` // assigned/unassigned
+	Repository   Repository    `json:"repository"`
+	Sender       User          `json:"sender"`
+	Installation *Installation `json:"installation,omitempty"`
+}
+
+type PullRequestEvent struct {
+	Action       string        `json:"action"`
+	Number       int           `json:"number"`
+	PullRequest  PullRequest   `json:"pull_request"`
+	Label        *Label        `json:"label,omitempty"`
+	Repository   Repository    `json:"repository"`
+	Sender       User          `json:"sender"`
+	Installation *Installation `json:"installation,omitempty"`
+}
+
+// InstallationEvent fires when the GitHub App is installed, removed or changed.
+type InstallationEvent struct {
+	Action       string           `json:"action"`
+	Installation *Installation    `json:"installation"`
+	Repositories []RepositoryStub `json:"repositories,omitempty"`
+	Sender       User             `json:"sender"`
+}
+
+// PingEvent is sent when a webhook is first configured.
+type PingEvent struct {
+	Zen          string        `json:"zen"`
+	HookID       int64         `json:"hook_id"`
+	Repository   *Repository   `json:"repository,omitempty"`
+	Sender       User          `json:"sender"`
+	Installation *Installation `json:"installation,omitempty"`
 }
 
 type Issue struct {
-	Number      int    `json:"number"`
-	Title       string `json:"title"`
-	Body        string `json:"body"`
-	State       string `json:"state"`
-	PullRequest *struct {
-		URL string `json:"url"`
-	} `json:"pull_request,omitempty"`
+	ID                int64             `json:"id"`
+	Number            int               `json:"number"`
+	Title             string            `json:"title"`
+	Body              string            `json:"body"`
+	State             string            `json:"state"` // "open" or "closed"
+	StateReason       string            `json:"state_reason,omitempty"`
+	User              User              `json:"user"`
+	Labels            []Label           `json:"labels,omitempty"`
+	Assignees         []User            `json:"assignees,omitempty"`
+	Locked            bool              `json:"locked,omitempty"`
+	Comments          int               `json:"comments,omitempty"`
+	AuthorAssociation AuthorAssociation `json:"author_association,omitempty"`
+	HTMLURL           string            `json:"html_url,omitempty"`
+	CreatedAt         time.Time         `json:"created_at"`
+	UpdatedAt         time.Time         `json:"updated_at"`
+	// PullRequest is set when the issue is a pull request (issue_comment on a PR)
+	PullRequest *IssuePRLinks `json:"pull_request,omitempty"`
+}
+
+// IsPullRequest reports whether the issue is a pull request.
+func (i Issue) IsPullRequest() bool {
+	return i.PullRequest != nil
+}
+
+// HasLabel reports whether the issue carries the named label.
+func (i Issue) HasLabel(name string) bool {
+	return hasLabel(i.Labels, name)
+}
+
+// IssuePRLinks is the pull_request stub embedded in issues that are PRs.
+type IssuePRLinks struct {
+	URL      string     `json:"url"`
+	HTMLURL  string     `json:"html_url,omitempty"`
+	DiffURL  string     `json:"diff_url,omitempty"`
+	MergedAt *time.Time `json:"merged_at,omitempty"`
 }
 
 type Comment struct {
-	ID   int64  `json:"id"`
-	Body string `json:"body"`
-	User User   `json:"user"`
+	ID                int64             `json:"id"`
+	Body              string            `json:"body"`
+	User              User              `json:"user"`
+	AuthorAssociation AuthorAssociation `json:"author_association,omitempty"`
+	HTMLURL           string            `json:"html_url,omitempty"`
+	CreatedAt         time.Time         `json:"created_at"`
+	UpdatedAt         time.Time         `json:"updated_at"`
 }
 
 type ReviewComment struct {
-	ID       int64  `json:"id"`
-	Body     string `json:"body"`
-	User     User   `json:"user"`
-	Path     string `json:"path"`
-	DiffHunk string `json:"diff_hunk"`
+	ID                  int64             `json:"id"`
+	Body                string            `json:"body"`
+	User                User              `json:"user"`
+	Path                string            `json:"path"`
+	DiffHunk            string            `json:"diff_hunk"`
+	Line                int               `json:"line,omitempty"`
+	StartLine           int               `json:"start_line,omitempty"`
+	Side                string            `json:"side,omitempty"`
+	CommitID            string            `json:"commit_id,omitempty"`
+	PullRequestReviewID int64             `json:"pull_request_review_id,omitempty"`
+	InReplyToID         int64             `json:"in_reply_to_id,omitempty"`
+	AuthorAssociation   AuthorAssociation `json:"author_association,omitempty"`
+	HTMLURL             string            `json:"html_url,omitempty"`
+	CreatedAt           time.Time         `json:"created_at"`
+	UpdatedAt           time.Time         `json:"updated_at"`
+}
+
+type Review struct {
+	ID                int64             `json:"id"`
+	Body              string            `json:"body"`
+	State             ReviewState       `json:"state"`
+	User              User              `json:"user"`
+	CommitID          string            `json:"commit_id,omitempty"`
+	AuthorAssociation AuthorAssociation `json:"author_association,omitempty"`
+	HTMLURL           string            `json:"html_url,omitempty"`
+	SubmittedAt       *time.Time        `json:"submitted_at,omitempty"`
+}
+
+// UnmarshalJSON normalises the review state: webhooks send lowercase values
+// while the REST API uses uppercase (e.g. "APPROVED").
+func (s *ReviewState) UnmarshalJSON(data []byte) error {
+	var raw string
+	if err := json.Unmarshal(data, &raw); err != nil {
+		return err
+	}
+	*s = ReviewState(strings.ToLower(raw))
+	return nil
 }
 
 type Repository struct {
+	ID            int64  `json:"id"`
 	FullName      string `json:"full_name"`
 	DefaultBranch string `json:"default_branch"`
 	Owner         User   `json:"owner"`
 	Name          string `json:"name"`
+	Private       bool   `json:"private,omitempty"`
+	Fork          bool   `json:"fork,omitempty"`
+	Archived      bool   `json:"archived,omitempty"`
+	HTMLURL       string `json:"html_url,omitempty"`
+	CloneURL      string `json:"clone_url,omitempty"`
+}
+
+// RepositoryStub is the abbreviated repository listed in installation events.
+type RepositoryStub struct {
+	ID       int64  `json:"id"`
+	Name     string `json:"name"`
+	FullName string `json:"full_name"`
+	Private  bool   `json:"private"`
 }
 
 type PullRequest struct {
-	Number int    `json:"number"`
-	Title  string `json:"title"`
-	Body   string `json:"body"`
-	State  string `json:"state"` // "open" or "closed"
-	Base   struct {
-		Ref string `json:"ref"`
-	} `json:"base"`
-	Head struct {
-		Ref string `json:"ref"` // Source branch name
-	} `json:"head"`
+	ID                int64             `json:"id"`
+	Number            int               `json:"number"`
+	Title             string            `json:"title"`
+	Body              string            `json:"body"`
+	State             string            `json:"state"` // "open" or "closed"
+	Draft             bool              `json:"draft,omitempty"`
+	Merged            bool              `json:"merged,omitempty"`
+	MergedAt          *time.Time        `json:"merged_at,omitempty"`
+	User              User              `json:"user"`
+	Labels            []Label           `json:"labels,omitempty"`
+	Assignees         []User            `json:"assignees,omitempty"`
+	AuthorAssociation AuthorAssociation `json:"author_association,omitempty"`
+	Base              PRBranch          `json:"base"`
+	Head              PRBranch          `json:"head"` // Head.Ref is the source branch name
+	HTMLURL           string            `json:"html_url,omitempty"`
+	CreatedAt         time.Time         `json:"created_at"`
+	UpdatedAt         time.Time         `json:"updated_at"`
+}
+
+// HasLabel reports whether the pull request carries the named label.
+func (p PullRequest) HasLabel(name string) bool {
+	return hasLabel(p.Labels, name)
+}
+
+// IsFromFork reports whether the head branch lives in a different repository.
+func (p PullRequest) IsFromFork() bool {
+	return p.Head.Repo != nil && p.Base.Repo != nil && p.Head.Repo.FullName != p.Base.Repo.FullName
+}
+
+// PRBranch is one side (base or head) of a pull request.
+type PRBranch struct {
+	Ref   string      `json:"ref"`
+	SHA   string      `json:"sha,omitempty"`
+	Label string      `json:"label,omitempty"` // owner:branch
+	Repo  *Repository `json:"repo,omitempty"`  // nil when the fork was deleted
+}
+
+type Label struct {
+	ID          int64  `json:"id,omitempty"`
+	Name        string `json:"name"`
+	Color       string `json:"color,omitempty"`
+	Description string `json:"description,omitempty"`
 }
 
 type User struct {
+	ID    int64  `json:"id,omitempty"`
 	Login string `json:"login"`
-	Type  string `json:"type"`
+	Type  string `json:"type"` // "User", "Bot" or "Organization"
+}
+
+// IsBot reports whether the account is a GitHub App or bot user.
+func (u User) IsBot() bool {
+	return u.Type == "Bot"
+}
+
+// Installation identifies the GitHub App installation that received the event.
+type Installation struct {
+	ID                  int64             `json:"id"`
+	Account             *User             `json:"account,omitempty"`
+	AppID               int64             `json:"app_id,omitempty"`
+	TargetType          string            `json:"target_type,omitempty"`
+	RepositorySelection string            `json:"repository_selection,omitempty"`
+	Permissions         map[string]string `json:"permissions,omitempty"`
+	Events              []string          `json:"events,omitempty"`
+}
+
+func (e *IssueCommentEvent) Name() string                   { return EventNameIssueComment }
+func (e *IssueCommentEvent) GetAction() string              { return e.Action }
+func (e *IssueCommentEvent) GetRepository() Repository      { return e.Repository }
+func (e *IssueCommentEvent) GetSender() User                { return e.Sender }
+func (e *IssueCommentEvent) GetInstallation() *Installation { return e.Installation }
+
+func (e *PullRequestReviewCommentEvent) Name() string                   { return EventNamePullRequestReviewComment }
+func (e *PullRequestReviewCommentEvent) GetAction() string              { return e.Action }
+func (e *PullRequestReviewCommentEvent) GetRepository() Repository      { return e.Repository }
+func (e *PullRequestReviewCommentEvent) GetSender() User                { return e.Sender }
+func (e *PullRequestReviewCommentEvent) GetInstallation() *Installation { return e.Installation }
+
+func (e *PullRequestReviewEvent) Name() string                   { return EventNamePullRequestReview }
+func (e *PullRequestReviewEvent) GetAction() string              { return e.Action }
+func (e *PullRequestReviewEvent) GetRepository() Repository      { return e.Repository }
+func (e *PullRequestReviewEvent) GetSender() User                { return e.Sender }
+func (e *PullRequestReviewEvent) GetInstallation() *Installation { return e.Installation }
+
+func (e *IssuesEvent) Name() string                   { return EventNameIssues }
+func (e *IssuesEvent) GetAction() string              { return e.Action }
+func (e *IssuesEvent) GetRepository() Repository      { return e.Repository }
+func (e *IssuesEvent) GetSender() User                { return e.Sender }
+func (e *IssuesEvent) GetInstallation() *Installation { return e.Installation }
+
+func (e *PullRequestEvent) Name() string                   { return EventNamePullRequest }
+func (e *PullRequestEvent) GetAction() string              { return e.Action }
+func (e *PullRequestEvent) GetRepository() Repository      { return e.Repository }
+func (e *PullRequestEvent) GetSender() User                { return e.Sender }
+func (e *PullRequestEvent) GetInstallation() *Installation { return e.Installation }
+
+func (e *InstallationEvent) Name() string                   { return EventNameInstallation }
+func (e *InstallationEvent) GetAction() string              { return e.Action }
+func (e *InstallationEvent) GetRepository() Repository      { return Repository{} }
+func (e *InstallationEvent) GetSender() User                { return e.Sender }
+func (e *InstallationEvent) GetInstallation() *Installation { return e.Installation }
+
+func (e *PingEvent) Name() string      { return EventNamePing }
+func (e *PingEvent) GetAction() string { return "" }
+func (e *PingEvent) GetRepository() Repository {
+	if e.Repository == nil {
+		return Repository{}
+	}
+	return *e.Repository
+}
+func (e *PingEvent) GetSender() User                { return e.Sender }
+func (e *PingEvent) GetInstallation() *Installation { return e.Installation }
+
+// ParseEvent decodes payload into the typed event for eventName
+// (the X-GitHub-Event header). Unknown names return ErrUnsupportedEvent.
+func ParseEvent(eventName string, payload []byte) (Event, error) {
+	var ev Event
+	switch eventName {
+	case EventNameIssueComment:
+		ev = &IssueCommentEvent{}
+	case EventNamePullRequestReviewComment:
+		ev = &PullRequestReviewCommentEvent{}
+	case EventNamePullRequestReview:
+		ev = &PullRequestReviewEvent{}
+	case EventNameIssues:
+		ev = &IssuesEvent{}
+	case EventNamePullRequest:
+		ev = &PullRequestEvent{}
+	case EventNameInstallation:
+		ev = &InstallationEvent{}
+	case EventNamePing:
+		ev = &PingEvent{}
+	default:
+		return nil, fmt.Errorf("%w: %q", ErrUnsupportedEvent, eventName)
+	}
+	if err := json.Unmarshal(payload, ev); err != nil {
+		return nil, fmt.Errorf("decode %s payload: %w", eventName, err)
+	}
+	return ev, nil
+}
+
+func hasLabel(labels []Label, name string) bool {
+	for _, l := range labels {
+		if l.Name == name {
+			return true
+		}
+	}
+	return false
 }
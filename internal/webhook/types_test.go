@@ -0,0 +1,288 @@
+package webhook
+
+import (
+	"encoding/json"
+	"errors"
+	"testing"
+	"time"
+)
+
+const issueCommentFixture = `{
+  "action": "created",
+  "issue": {
+    "id": 1001, "number": 42, "title": "Bug", "body": "It breaks", "state": "open",
+    "user": {"id": 7, "login": "alice", "type": "User"},
+    "labels": [{"id": 1, "name": "bug", "color": "d73a4a"}],
+    "assignees": [{"login": "bob", "type": "User"}],
+    "author_association": "CONTRIBUTOR",
+    "created_at": "2025-10-01T10:00:00Z", "updated_at": "2025-10-02T10:00:00Z",
+    "pull_request": {"url": "https://api.github.com/repos/octo/demo/pulls/42", "merged_at": null}
+  },
+  "comment": {
+    "id": 555, "body": "/code fix it", "user": {"login": "carol", "type": "User"},
+    "author_association": "MEMBER", "created_at": "2025-10-03T08:30:00Z", "updated_at": "2025-10-03T08:30:00Z"
+  },
+  "repository": {
+    "id": 99, "name": "demo", "full_name": "octo/demo", "default_branch": "main", "private": true,
+    "owner": {"login": "octo", "type": "Organization"}
+  },
+  "sender": {"login": "carol", "type": "User"},
+  "installation": {"id": 123456}
+}`
+
+const reviewCommentFixture = `{
+  "action": "created",
+  "comment": {
+    "id": 777, "body": "/code rename this", "path": "main.go", "diff_hunk": "@@ -1 +1 @@",
+    "line": 12, "start_line": 10, "side": "RIGHT", "commit_id": "abc123",
+    "pull_request_review_id": 31, "in_reply_to_id": 776,
+    "user": {"login": "dependabot[bot]", "type": "Bot"}, "author_association": "NONE"
+  },
+  "pull_request": {
+    "number": 8, "title": "Feature", "state": "open", "draft": true,
+    "user": {"login": "dave", "type": "User"},
+    "labels": [{"name": "swe-agent"}],
+    "base": {"ref": "main", "sha": "b4se", "repo": {"full_name": "octo/demo"}},
+    "head": {"ref": "feature/x", "sha": "h3ad", "label": "fork:feature/x", "repo": {"full_name": "fork/demo"}}
+  },
+  "repository": {"full_name": "octo/demo", "name": "demo", "owner": {"login": "octo"}},
+  "sender": {"login": "dependabot[bot]", "type": "Bot"}
+}`
+
+const reviewFixture = `{
+  "action": "submitted",
+  "review": {
+    "id": 31, "body": "Looks good", "state": "APPROVED", "commit_id": "h3ad",
+    "user": {"login": "erin", "type": "User"}, "author_association": "COLLABORATOR",
+    "submitted_at": "2025-10-04T12:00:00Z"
+  },
+  "pull_request": {"number": 8, "state": "open", "base": {"ref": "main"}, "head": {"ref": "feature/x"}},
+  "repository": {"full_name": "octo/demo"},
+  "sender": {"login": "erin", "type": "User"},
+  "installation": {"id": 5}
+}`
+
+const issuesFixture = `{
+  "action": "labeled",
+  "issue": {"number": 3, "title": "Add docs", "state": "open", "labels": [{"name": "docs"}, {"name": "swe-agent"}]},
+  "label": {"name": "swe-agent", "color": "0e8a16"},
+  "repository": {"full_name": "octo/demo"},
+  "sender": {"login": "frank", "type": "User"}
+}`
+
+const pullRequestFixture = `{
+  "action": "closed",
+  "number": 8,
+  "pull_request": {
+    "number": 8, "state": "closed", "merged": true, "merged_at": "2025-10-05T09:00:00Z",
+    "base": {"ref": "main", "repo": {"full_name": "octo/demo"}},
+    "head": {"ref": "feature/x", "repo": {"full_name": "octo/demo"}}
+  },
+  "repository": {"full_name": "octo/demo"},
+  "sender": {"login": "grace", "type": "User"}
+}`
+
+const installationFixture = `{
+  "action": "created",
+  "installation": {
+    "id": 42, "app_id": 7, "target_type": "Organization", "repository_selection": "selected",
+    "account": {"login": "octo", "type": "Organization"},
+    "permissions": {"issues": "write", "contents": "write"},
+    "events": ["issue_comment", "pull_request_review_comment"]
+  },
+  "repositories": [{"id": 99, "name": "demo", "full_name": "octo/demo", "private": true}],
+  "sender": {"login": "octo-admin", "type": "User"}
+}`
+
+func TestParseEvent_IssueComment(t *testing.T) {
+	ev, err := ParseEvent("issue_comment", []byte(issueCommentFixture))
+	if err != nil {
+		t.Fatalf("ParseEvent error: %v", err)
+	}
+	e, ok := ev.(*IssueCommentEvent)
+	if !ok {
+		t.Fatalf("got %T, want *IssueCommentEvent", ev)
+	}
+	if e.Name() != EventNameIssueComment || e.GetAction() != "created" {
+		t.Fatalf("name/action = %s/%s", e.Name(), e.GetAction())
+	}
+	if e.Issue.Number != 42 || !e.Issue.IsPullRequest() || !e.Issue.HasLabel("bug") || e.Issue.HasLabel("docs") {
+		t.Fatalf("unexpected issue: %+v", e.Issue)
+	}
+	if e.Issue.User.Login != "alice" || e.Issue.Assignees[0].Login != "bob" || e.Issue.AuthorAssociation != AssociationContributor {
+		t.Fatalf("unexpected issue people: %+v", e.Issue)
+	}
+	if want := time.Date(2025, 10, 1, 10, 0, 0, 0, time.UTC); !e.Issue.CreatedAt.Equal(want) {
+		t.Fatalf("CreatedAt = %v, want %v", e.Issue.CreatedAt, want)
+	}
+	if e.Comment.ID != 555 || e.Comment.AuthorAssociation != AssociationMember || !e.Comment.AuthorAssociation.IsTrusted() {
+		t.Fatalf("unexpected comment: %+v", e.Comment)
+	}
+	if repo := e.GetRepository(); repo.FullName != "octo/demo" || !repo.Private || repo.Owner.Type != "Organization" {
+		t.Fatalf("unexpected repository: %+v", repo)
+	}
+	if inst := e.GetInstallation(); inst == nil || inst.ID != 123456 {
+		t.Fatalf("unexpected installation: %+v", inst)
+	}
+	if e.GetSender().Login != "carol" || e.GetSender().IsBot() {
+		t.Fatalf("unexpected sender: %+v", e.GetSender())
+	}
+}
+
+func TestParseEvent_ReviewComment(t *testing.T) {
+	ev, err := ParseEvent("pull_request_review_comment", []byte(reviewCommentFixture))
+	if err != nil {
+		t.Fatalf("ParseEvent error: %v", err)
+	}
+	e := ev.(*PullRequestReviewCommentEvent)
+	c := e.Comment
+	if c.Path != "main.go" || c.Line != 12 || c.StartLine != 10 || c.Side != "RIGHT" || c.PullRequestReviewID != 31 || c.InReplyToID != 776 {
+		t.Fatalf("unexpected review comment: %+v", c)
+	}
+	if !c.User.IsBot() || c.AuthorAssociation.IsTrusted() {
+		t.Fatalf("bot comment should be untrusted bot: %+v", c)
+	}
+	pr := e.PullRequest
+	if !pr.Draft || pr.Head.Ref != "feature/x" || pr.Head.SHA != "h3ad" || pr.Head.Label != "fork:feature/x" || !pr.HasLabel("swe-agent") {
+		t.Fatalf("unexpected pull request: %+v", pr)
+	}
+	if !pr.IsFromFork() {
+		t.Fatal("head repo differs from base; expected fork")
+	}
+	if e.GetInstallation() != nil {
+		t.Fatal("installation should be nil when absent")
+	}
+}
+
+func TestParseEvent_Review(t *testing.T) {
+	ev, err := ParseEvent("pull_request_review", []byte(reviewFixture))
+	if err != nil {
+		t.Fatalf("ParseEvent error: %v", err)
+	}
+	e := ev.(*PullRequestReviewEvent)
+	if e.Review.State != ReviewApproved {
+		t.Fatalf("State = %q, want normalized %q", e.Review.State, ReviewApproved)
+	}
+	if e.Review.SubmittedAt == nil || e.Review.AuthorAssociation != AssociationCollaborator {
+		t.Fatalf("unexpected review: %+v", e.Review)
+	}
+	if e.PullRequest.Number != 8 || e.GetInstallation().ID != 5 {
+		t.Fatalf("unexpected event: %+v", e)
+	}
+}
+
+func TestParseEvent_Issues(t *testing.T) {
+	ev, err := ParseEvent("issues", []byte(issuesFixture))
+	if err != nil {
+		t.Fatalf("ParseEvent error: %v", err)
+	}
+	e := ev.(*IssuesEvent)
+	if e.GetAction() != "labeled" || e.Label == nil || e.Label.Name != "swe-agent" || !e.Issue.HasLabel("docs") {
+		t.Fatalf("unexpected issues event: %+v", e)
+	}
+	if e.Issue.IsPullRequest() {
+		t.Fatal("plain issue should not be a pull request")
+	}
+}
+
+func TestParseEvent_PullRequest(t *testing.T) {
+	ev, err := ParseEvent("pull_request", []byte(pullRequestFixture))
+	if err != nil {
+		t.Fatalf("ParseEvent error: %v", err)
+	}
+	e := ev.(*PullRequestEvent)
+	if e.Number != 8 || !e.PullRequest.Merged || e.PullRequest.MergedAt == nil || e.PullRequest.IsFromFork() {
+		t.Fatalf("unexpected pull request event: %+v", e.PullRequest)
+	}
+}
+
+func TestParseEvent_Installation(t *testing.T) {
+	ev, err := ParseEvent("installation", []byte(installationFixture))
+	if err != nil {
+		t.Fatalf("ParseEvent error: %v", err)
+	}
+	e := ev.(*InstallationEvent)
+	inst := e.GetInstallation()
+	if inst.ID != 42 || inst.AppID != 7 || inst.Account.Login != "octo" || inst.Permissions["issues"] != "write" || len(inst.Events) != 2 {
+		t.Fatalf("unexpected installation: %+v", inst)
+	}
+	if len(e.Repositories) != 1 || e.Repositories[0].FullName != "octo/demo" {
+		t.Fatalf("unexpected repositories: %+v", e.Repositories)
+	}
+	if e.GetRepository() != (Repository{}) {
+		t.Fatal("installation events have no repository")
+	}
+}
+
+func TestParseEvent_Ping(t *testing.T) {
+	ev, err := ParseEvent("ping", []byte(`{"zen":"Keep it simple.","hook_id":9,"sender":{"login":"octo"}}`))
+	if err != nil {
+		t.Fatalf("ParseEvent error: %v", err)
+	}
+	e := ev.(*PingEvent)
+	if e.Zen != "Keep it simple." || e.HookID != 9 || e.GetRepository().FullName != "" || e.GetAction() != "" {
+		t.Fatalf("unexpected ping: %+v", e)
+	}
+}
+
+func TestParseEvent_Errors(t *testing.T) {
+	if _, err := ParseEvent("deployment", []byte(`{}`)); !errors.Is(err, ErrUnsupportedEvent) {
+		t.Fatalf("err = %v, want ErrUnsupportedEvent", err)
+	}
+	if _, err := ParseEvent("issue_comment", []byte(`{`)); err == nil {
+		t.Fatal("expected decode error")
+	}
+	if _, err := ParseEvent("pull_request_review", []byte(`{"review":{"state":5}}`)); err == nil {
+		t.Fatal("expected error for non-string review state")
+	}
+}
+
+func TestEventRoundTrip(t *testing.T) {
+	ev, err := ParseEvent("issue_comment", []byte(issueCommentFixture))
+	if err != nil {
+		t.Fatalf("ParseEvent error: %v", err)
+	}
+	blob, err := json.Marshal(ev)
+	if err != nil {
+		t.Fatalf("Marshal error: %v", err)
+	}
+	again, err := ParseEvent("issue_comment", blob)
+	if err != nil {
+		t.Fatalf("reparse error: %v", err)
+	}
+	a, b := ev.(*IssueCommentEvent), again.(*IssueCommentEvent)
+	if a.Comment.Body != b.Comment.Body || !a.Issue.CreatedAt.Equal(b.Issue.CreatedAt) || a.Installation.ID != b.Installation.ID {
+		t.Fatalf("round trip mismatch:\n%+v\n%+v", a, b)
+	}
+}
+
+func TestAuthorAssociation_IsTrusted(t *testing.T) {
+	cases := map[AuthorAssociation]bool{
+		AssociationOwner:        true,
+		AssociationMember:       true,
+		AssociationCollaborator: true,
+		AssociationContributor:  false,
+		AssociationFirstTimer:   false,
+		AssociationFirstTime:    false,
+		AssociationMannequin:    false,
+		AssociationNone:         false,
+		"":                      false,
+	}
+	for assoc, want := range cases {
+		if got := assoc.IsTrusted(); got != want {
+			t.Errorf("%q.IsTrusted() = %t, want %t", assoc, got, want)
+		}
+	}
+}
+
+func TestIsBotComment(t *testing.T) {
+	if isBotComment("issue_comment", []byte(issueCommentFixture)) {
+		t.Fatal("human issue comment flagged as bot")
+	}
+	if !isBotComment("pull_request_review_comment", []byte(reviewCommentFixture)) {
+		t.Fatal("bot review comment not detected")
+	}
+	if isBotComment("issue_comment", []byte(`not json`)) {
+		t.Fatal("invalid payload should not be treated as bot")
+	}
+}